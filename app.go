@@ -1,9 +1,11 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -12,16 +14,37 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/mycoool/gohook/internal/chatops"
+	"github.com/mycoool/gohook/internal/client"
+	"github.com/mycoool/gohook/internal/cluster"
 	"github.com/mycoool/gohook/internal/config"
+	"github.com/mycoool/gohook/internal/consumer"
 	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/env"
+	"github.com/mycoool/gohook/internal/gitops"
 	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/inbox"
+	"github.com/mycoool/gohook/internal/locale"
+	"github.com/mycoool/gohook/internal/logforward"
+	"github.com/mycoool/gohook/internal/maintenance"
 	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/openapi"
 	"github.com/mycoool/gohook/internal/pidfile"
+	"github.com/mycoool/gohook/internal/plugin"
+	"github.com/mycoool/gohook/internal/pool"
+	"github.com/mycoool/gohook/internal/redact"
+	"github.com/mycoool/gohook/internal/sandbox"
+	"github.com/mycoool/gohook/internal/selfupdate"
+	"github.com/mycoool/gohook/internal/stream"
 	"github.com/mycoool/gohook/internal/syncnode"
 	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/urls"
+	"github.com/mycoool/gohook/internal/version"
 	"github.com/mycoool/gohook/internal/webhook"
 
 	"github.com/fsnotify/fsnotify"
@@ -38,7 +61,8 @@ var (
 	debug              = flag.Bool("debug", false, "show debug output")
 	ginDebug           = flag.Bool("gin-debug", false, "show gin debug output")
 	hotReload          = flag.Bool("hotreload", false, "watch hooks file for changes and reload them automatically")
-	hooksURLPrefix     = flag.String("urlprefix", "hooks", "url prefix to use for served hooks (protocol://yourserver:port/PREFIX/:hook-id)")
+	hooksURLPrefix     = flag.String("urlprefix", "hooks", "url prefix to use for served hooks (protocol://yourserver:port/PREFIX/:hook-id), overrides server.hooks_url_prefix of app.yaml")
+	basePath           = flag.String("basepath", "", "serve the whole app under this sub-path behind a reverse proxy (e.g. /gohook), overrides server.base_path of app.yaml")
 	secure             = flag.Bool("secure", false, "use HTTPS instead of HTTP")
 	asTemplate         = flag.Bool("template", false, "parse hooks file as a Go template")
 	cert               = flag.String("cert", "cert.pem", "path to the HTTPS certificate pem file")
@@ -48,8 +72,12 @@ var (
 	tlsMinVersion      = flag.String("tls-min-version", "1.2", "minimum TLS version (1.0, 1.1, 1.2, 1.3)")
 	tlsCipherSuites    = flag.String("cipher-suites", "", "comma-separated list of supported TLS cipher suites")
 	maxMultipartMem    = flag.Int64("max-multipart-mem", 1<<20, "maximum memory in bytes for parsing multipart form data before disk caching")
+	spoolThreshold     = flag.Int64("spool-threshold", webhook.DefaultSpoolThreshold, "request bodies larger than this many bytes are written to a temp file instead of memory; 0 keeps every body in memory")
+	spoolDir           = flag.String("spool-dir", "", "directory of spooled request bodies (default the system temp directory)")
+	maxDecodedBody     = flag.Int64("max-decompressed-body", webhook.DefaultMaxDecodedBody, "maximum size in bytes of a compressed request body, before and after decompression")
 	httpMethods        = flag.String("http-methods", "", `set default allowed HTTP methods (ie. "POST"); separate methods with comma`)
 	pidPath            = flag.String("pidfile", "", "create PID file at the given path")
+	lintStrict         = flag.Bool("lint-strict", false, "refuse to start when the configuration lint finds errors")
 
 	responseHeaders webhook.ResponseHeaders
 	hooksFiles      webhook.HooksFiles
@@ -73,6 +101,10 @@ var vInfo = &ui.VersionInfo{
 }
 
 func main() {
+	// sandbox helper processes prepare the sandbox and execute the hook command instead of starting gohook
+	sandbox.Init()
+	router.Build = router.BuildInfo{Version: Version, Commit: Commit, BuildDate: BuildDate}
+
 	flag.Var(&hooksFiles, "hooks", "path to the json file containing defined hooks the webhook should serve, use multiple times to load from different files")
 	flag.Var(&responseHeaders, "header", "response header to return, specified in format name=value, use multiple times to set multiple headers")
 
@@ -150,17 +182,39 @@ func main() {
 	types.GoHookAppConfig = appCfg
 
 	// Initialize i18n with the configured language
-	locale := i18n.Locale(appCfg.Language)
-	if locale == "" {
-		locale = i18n.LocaleChinese // Default to Chinese
+	i18nLocale := i18n.Locale(appCfg.Language)
+	if i18nLocale == "" {
+		i18nLocale = i18n.LocaleChinese // Default to Chinese
+	}
+	i18n.Init(i18nLocale)
+	log.Printf("i18n initialized with locale: %s", i18nLocale)
+
+	// timestamps of API responses are rendered in the configured zone
+	if err := locale.Configure(appCfg.Timezone); err != nil {
+		log.Printf("timezone in app.yaml ignored: %v", err)
+	}
+	// secrets are redacted from logs and WebSocket messages
+	if err := redact.Configure(appCfg.Redaction); err != nil {
+		log.Printf("redaction in app.yaml ignored: %v", err)
 	}
-	i18n.Init(locale)
-	log.Printf("i18n initialized with locale: %s", locale)
 
 	// Init router with the final config
 	webhook.LoadedHooksFromFiles = &loadedHooksFromFiles
 	webhook.HookManager = webhook.NewHookManager(&loadedHooksFromFiles, hooksFiles, *asTemplate)
 	router.InitRouter()
+	if err := applyURLLayout(); err != nil {
+		log.Printf("invalid server URL layout, using the default: %v", err)
+	}
+	webhook.MaxDecodedBody = *maxDecodedBody
+	webhook.SpoolThreshold, webhook.SpoolDir = *spoolThreshold, *spoolDir
+
+	// client addresses in forwarding headers are only taken from trusted proxies
+	if err := middleware.SetTrustedProxies(router.GetRouter(), types.GoHookAppConfig.TrustedProxies); err != nil {
+		log.Fatalf("invalid trusted_proxies in app.yaml: %v", err)
+	}
+	if err := middleware.ConfigureAccessLog(types.GoHookAppConfig.AccessLog); err != nil {
+		log.Printf("access log disabled: %v", err)
+	}
 
 	// by default the listen address is ip:port, but this may be modified by trySocketListener
 	addr = fmt.Sprintf("%s:%d", *ip, appCfg.Port)
@@ -256,6 +310,7 @@ func main() {
 
 		if err != nil {
 			log.Printf("couldn't load hooks from file! %+v\n", err)
+			webhook.RecordHooksFileLoad(hooksFilePath, 0, err)
 		} else {
 			log.Printf("found %d hook(s) in file\n", len(newHooks))
 
@@ -267,22 +322,34 @@ func main() {
 				if webhook.HookManager.MatchLoadedHook(hookValue.ID) != nil {
 					log.Fatalf("error: hook with the id %s has already been loaded!\nplease check your hooks file for duplicate hooks ids!\n", hookValue.ID)
 				}
+				if err := hookValue.Validate(); err != nil {
+					log.Printf("warning: hook %s: %v\n", hookValue.ID, err)
+				}
 				log.Printf("\tloaded: %s\n", hookValue.ID)
 				seenHooksIds[hookValue.ID] = true
 			}
 
 			loadedHooksFromFiles[hooksFilePath] = newHooks
+			webhook.RecordHooksFileLoad(hooksFilePath, len(newHooks), nil)
 		}
 	}
 
-	newHooksFiles := hooksFiles[:0]
+	// lint all configured hooks files, including the ones that failed to load
+	lintConfig(append([]string(nil), hooksFiles...))
+
+	// files that exist but failed to parse stay configured: they are watched and reloaded
+	// once fixed, nothing is saved to them while no hooks are loaded from them
+	var newHooksFiles []string
 	for _, filePath := range hooksFiles {
 		if _, ok := loadedHooksFromFiles[filePath]; ok {
 			newHooksFiles = append(newHooksFiles, filePath)
+		} else if _, err := os.Stat(filePath); err == nil {
+			newHooksFiles = append(newHooksFiles, filePath)
 		}
 	}
 
 	hooksFiles = newHooksFiles
+	webhook.HookManager.HooksFiles = hooksFiles
 
 	// allow zero hooks to start, no longer force exit
 	if webhook.HookManager.LenLoadedHooks() == 0 {
@@ -335,34 +402,14 @@ func main() {
 	}
 	ui.Register(r, *vInfo, true, panelAlias)
 
+	// OpenAPI document (/openapi.json) and swagger UI (/swagger)
+	openapi.Register(r, Version)
+
 	// enable method not allowed handling
 	r.HandleMethodNotAllowed = true
 
-	// set gin middleware
-	if *debug {
-		// debug mode use detailed log middleware
-		r.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-			return fmt.Sprintf("[GoHook] %v | %3d | %13v | %15s | %-7s %#v\n",
-				param.TimeStamp.Format("2006/01/02 - 15:04:05"),
-				param.StatusCode,
-				param.Latency,
-				param.ClientIP,
-				param.Method,
-				param.Path,
-			)
-		}))
-	} else {
-		// production mode use simple log
-		r.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-			return fmt.Sprintf("[GoHook] %3d | %13v | %s | %s %s\n",
-				param.StatusCode,
-				param.Latency,
-				param.ClientIP,
-				param.Method,
-				param.Path,
-			)
-		}))
-	}
+	// set gin middleware, debug mode use detailed log lines
+	r.Use(gin.LoggerWithFormatter(accessLogFormatter(*debug)))
 
 	r.Use(gin.Recovery())
 
@@ -376,12 +423,18 @@ func main() {
 			Port:     appConfig.Database.Port,
 			Username: appConfig.Database.Username,
 			Password: appConfig.Database.Password,
+			SSLMode:  appConfig.Database.SSLMode,
+			DSN:      appConfig.Database.DSN,
 		}
 
 		// Initialize database connection
 		err := database.InitDatabase(dbConfig)
 		if err != nil {
 			log.Printf("Failed to initialize database: %v", err)
+			// an HA instance on a database of its own would split from the other instances
+			if appConfig.Cluster != nil && appConfig.Cluster.Enabled {
+				log.Fatalf("HA mode needs the shared database, not starting")
+			}
 			// Use default SQLite configuration as fallback
 			defaultConfig := database.DefaultDatabaseConfig()
 			if initErr := database.InitDatabase(defaultConfig); initErr != nil {
@@ -394,11 +447,18 @@ func main() {
 			log.Printf("Failed to migrate database: %v", err)
 		}
 
-		// Start sync project file watchers (primary node).
+		// Start sync project file watchers (primary node), only on the leader in HA mode.
 		syncnode.StartAutoSyncController(context.Background())
-		syncnode.StartProjectWatchers()
+		cluster.OnLeader("project-watchers", func(ctx context.Context) {
+			syncnode.StartProjectWatchers()
+			<-ctx.Done()
+			syncnode.StopProjectWatchers()
+		})
 		syncnode.StartLocalRuntimeBroadcaster(context.Background())
 
+		// Replay deliveries queued during maintenance once execution resumes.
+		cluster.OnLeader("maintenance-flush", maintenance.StartAutoFlush)
+
 		// Start agent TCP mTLS transport (primary node).
 		go func() {
 			if err := syncnode.StartAgentTCPServer(context.Background()); err != nil {
@@ -408,13 +468,51 @@ func main() {
 
 		// Initialize global log service
 		database.InitLogService()
+		database.SetAuditHashChain(appConfig.Database.AuditHashChain)
+		applyHookLogEncryption()
+
+		// Ship the logs written by this instance to the configured SIEM destinations
+		logforward.Start(context.Background())
 
 		// Start automatic log cleanup task
 		retentionDays := appConfig.Database.LogRetentionDays
 		if retentionDays <= 0 {
 			retentionDays = 30 // default retention period
 		}
-		database.ScheduleLogCleanup(retentionDays)
+		cluster.OnLeader("log-cleanup", func(ctx context.Context) {
+			database.ScheduleLogCleanup(ctx, retentionDays)
+		})
+
+		// Deleted hooks and projects stay in the trash for the configured days
+		database.TrashRetentionDays = appConfig.Database.TrashRetentionDays
+		cluster.OnLeader("trash-purge", database.ScheduleTrashPurge)
+
+		// Scheduled git gc and prune of project checkouts
+		cluster.OnLeader("git-maintenance", version.ScheduleGitMaintenance)
+
+		// Previous hook and GitHook secrets are retired when their grace period ends
+		cluster.OnLeader("secret-retirement", version.ScheduleSecretRetirement)
+
+		// poll the remotes of projects no GitHook can reach
+		cluster.OnLeader("project-polling", version.SchedulePolling)
+
+		// hooks files, version.yaml and user.yaml reconciled from a git repository
+		if err := gitops.Validate(appConfig.GitOps); err != nil {
+			log.Printf("GitOps disabled: %v", err)
+		} else {
+			gitops.SetReload(reloadConfig)
+			cluster.OnLeader("gitops", gitops.Schedule)
+		}
+
+		// Kafka, NATS and Redis stream consumers delivering to hooks
+		cluster.OnLeader("consumers", consumer.Start)
+
+		// Telegram bot commands, polled by one instance only
+		cluster.OnLeader("telegram", chatops.StartTelegram)
+
+		// Join the HA cluster (if configured) and start the leader tasks once elected.
+		cluster.OnConfigChanged(reloadConfig)
+		cluster.Start(context.Background(), Version, addr)
 
 		// Register log routes
 		logRouter := router.NewLogRouter()
@@ -428,19 +526,49 @@ func main() {
 
 	// note: root path "/" is now handled by frontend UI router (registered in router.InitRouter())
 
-	// webhook router - supports all HTTP methods
-	hooksPath := "/" + *hooksURLPrefix + "/*id"
-	r.Any(hooksPath, ginHookHandler)
+	// webhook router - supports all HTTP methods, /ns/:namespace/... only matches hooks of the namespace
+	router.RegisterHookRoutes(r, ginHookHandler)
+	// canonical /api/v1 paths of the panel API, the unversioned paths stay as deprecated aliases
+	router.RegisterVersionedAPI(r)
+	// test events of POST /hook/:id/test are delivered in-process
+	webhook.SetHookEndpoint(r)
+	// slash commands call the panel API in-process as the mapped user
+	chatops.SetAPIHandler(r)
+	// failures and promotions waiting for approval are sent to the Telegram chats
+	stream.Global.AddListener(chatops.TelegramAlert)
+	// deploy results, failed hooks and approvals are stored in the inboxes of the users
+	stream.Global.AddListener(inbox.Deliver)
+
+	// executables and Go plugins of the plugins directory, enabled ones start now
+	if err := plugin.Load(types.GoHookAppConfig.Plugins); err != nil {
+		log.Printf("Plugins not loaded: %v", err)
+	}
+	// broadcast events are passed to the enabled notifier plugins
+	stream.Global.AddListener(plugin.Notify)
+
+	// workers and queue of the background hook executions
+	pool.Configure(types.GoHookAppConfig.Execution)
+
+	// releases of gohook on GitHub, an admin installs them with POST /admin/update
+	selfupdate.Current = Version
+	selfupdate.SetRestart(gracefulRestart)
+	if err := selfupdate.Validate(types.GoHookAppConfig.Update); err != nil {
+		log.Printf("Update checks disabled: %v", err)
+	} else {
+		selfupdate.Schedule(context.Background())
+	}
 
 	// Create common HTTP server settings
 	svr := &http.Server{
-		Handler: r,
+		Handler: urls.StripBasePath(r),
 	}
+	server = svr
 
 	// Serve HTTP
 	if !*secure {
-		log.Printf("serving hooks on http://%s%s", addr, webhook.MakeHumanPattern(hooksURLPrefix))
+		log.Printf("serving hooks on http://%s%s", addr, urls.Current().HumanPattern())
 		log.Print(svr.Serve(ln))
+		waitForRestart()
 
 		return
 	}
@@ -454,8 +582,9 @@ func main() {
 	}
 	svr.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler)) // disable http/2
 
-	log.Printf("serving hooks on https://%s%s", addr, webhook.MakeHumanPattern(hooksURLPrefix))
+	log.Printf("serving hooks on https://%s%s", addr, urls.Current().HumanPattern())
 	log.Print(svr.ServeTLS(ln, *cert, *key))
+	waitForRestart()
 }
 
 func ginHookHandler(c *gin.Context) {
@@ -475,18 +604,54 @@ func ginHookHandler(c *gin.Context) {
 	// debug mode output more request information
 	if *debug {
 		log.Printf("[%s] Request Headers: %v", req.ID, c.Request.Header)
-		log.Printf("[%s] Request URL: %s", req.ID, c.Request.URL.String())
+		log.Printf("[%s] Request URL: %s", req.ID, redact.Query(c.Request.URL.String()))
 		log.Printf("[%s] User-Agent: %s", req.ID, c.Request.UserAgent())
 	}
 
 	// get id from path parameter
 	id := strings.TrimPrefix(c.Param("id"), "/")
 
-	matchedHook := webhook.HookManager.MatchLoadedHook(id)
+	// renamed hooks still answer to their previous ids, and hooks to their endpoints
+	matchedHook, alias := webhook.HookManager.ResolveHook(id)
 	if matchedHook == nil {
 		c.String(http.StatusNotFound, "Hook not found.")
 		return
 	}
+	endpoint := matchedHook.Endpoint(alias)
+	if endpoint != nil && endpoint.Disabled {
+		log.Printf("[%s] endpoint %s of hook %s is disabled", req.ID, alias, matchedHook.ID)
+		c.String(http.StatusNotFound, "Hook not found.")
+		return
+	}
+	if endpoint == nil && matchedHook.EndpointsOnly {
+		log.Printf("[%s] hook %s only accepts deliveries to its endpoints", req.ID, matchedHook.ID)
+		c.String(http.StatusNotFound, "Hook not found.")
+		return
+	}
+	if endpoint != nil && endpoint.TokenSHA256 != "" {
+		// a wrong token answers like an unknown hook, the capability URL is not confirmed
+		query := c.Request.URL.Query()
+		if !endpoint.Authorized(query.Get(webhook.EndpointTokenParam)) {
+			log.Printf("[%s] missing or wrong token for endpoint %s of hook %s", req.ID, alias, matchedHook.ID)
+			c.String(http.StatusNotFound, "Hook not found.")
+			return
+		}
+		// the token is kept out of the rules, arguments and logs of the delivery
+		query.Del(webhook.EndpointTokenParam)
+		c.Request.URL.RawQuery = query.Encode()
+	}
+	req.Alias = alias
+	req.Starlark = matchedHook.Starlark
+
+	// the namespace selected by the URL prefix or header must own the hook
+	ns := c.Param("namespace")
+	if ns == "" {
+		ns = c.GetHeader(namespace.Header)
+	}
+	if ns != "" && namespace.Normalize(matchedHook.Namespace) != ns {
+		c.String(http.StatusNotFound, "Hook not found.")
+		return
+	}
 
 	// Check for allowed methods
 	var allowedMethod bool
@@ -530,12 +695,36 @@ func ginHookHandler(c *gin.Context) {
 		req.ContentType = matchedHook.IncomingPayloadContentType
 	}
 
+	// compressed bodies are decoded before signature checks and rule evaluation. The decoded
+	// stream is read like an uncompressed body, a large one is spooled to disk.
+	decoding := false
+	if encoding := c.Request.Header.Get("Content-Encoding"); encoding != "" {
+		compressed := http.MaxBytesReader(c.Writer, c.Request.Body, webhook.MaxDecodedBody)
+		decoded, decodeErr := webhook.NewDecodingReader(encoding, compressed, webhook.MaxDecodedBody)
+		if decodeErr != nil {
+			log.Printf("[%s] error decoding the request body: %v", req.ID, decodeErr)
+			c.String(webhook.DecodeErrorStatus(decodeErr), "Error occurred while decoding the request body.")
+			return
+		}
+		c.Request.Body = io.NopCloser(decoded)
+		c.Request.ContentLength = -1
+		c.Request.Header.Del("Content-Encoding")
+		decoding = true
+	}
+
 	isMultipart := strings.HasPrefix(req.ContentType, "multipart/form-data;")
 
 	if !isMultipart {
-		req.Body, err = io.ReadAll(c.Request.Body)
-		if err != nil {
+		// bodies above -spool-threshold go to a temp file, removed once the hook finished
+		err = req.ReadBody(c.Request.Body, webhook.SpoolThreshold)
+		if err != nil && decoding {
+			log.Printf("[%s] error decoding the request body: %v", req.ID, err)
+			c.String(webhook.DecodeErrorStatus(err), "Error occurred while decoding the request body.")
+			return
+		} else if err != nil {
 			log.Printf("[%s] error reading the request body: %+v\n", req.ID, err)
+		} else if req.Spooled() {
+			log.Printf("[%s] request body of %d bytes spooled to %s", req.ID, req.BodySize(), req.BodyFile)
 		} else if *debug && len(req.Body) > 0 {
 			// debug mode output request body content (limit length to avoid log too long)
 			bodyStr := string(req.Body)
@@ -546,6 +735,13 @@ func ginHookHandler(c *gin.Context) {
 		}
 	}
 
+	removeBody := true
+	defer func() {
+		if removeBody {
+			req.RemoveBody()
+		}
+	}()
+
 	req.ParseHeaders(c.Request.Header)
 	req.ParseQuery(c.Request.URL.Query())
 
@@ -573,6 +769,10 @@ func ginHookHandler(c *gin.Context) {
 		if err != nil {
 			msg := fmt.Sprintf("[%s] error parsing multipart form: %+v\n", req.ID, err)
 			log.Println(msg)
+			if decoding {
+				c.String(webhook.DecodeErrorStatus(err), "Error occurred while decoding the request body.")
+				return
+			}
 			c.String(http.StatusInternalServerError, "Error occurred while parsing multipart form.")
 			return
 		}
@@ -636,6 +836,40 @@ func ginHookHandler(c *gin.Context) {
 		log.Printf("[%s] error parsing body payload due to unsupported content type header: %s\n", req.ID, req.ContentType)
 	}
 
+	// S3 and MinIO notifications: verify the sender and expose the objects as payload fields
+	if matchedHook.ObjectEvents != nil {
+		if status, msg := matchedHook.ParseObjectEvent(req); status != 0 {
+			log.Printf("[%s] %s: %s\n", req.ID, matchedHook.ID, msg)
+			c.String(status, msg)
+			return
+		}
+	}
+
+	// transformer plugins rewrite the payload before arguments are extracted and rules evaluated
+	if len(matchedHook.TransformPlugins) > 0 {
+		req.Payload, err = plugin.Transform(matchedHook.TransformPlugins, plugin.TransformRequest{
+			Hook:    matchedHook.ID,
+			Headers: req.Headers,
+			Query:   req.Query,
+			Payload: req.Payload,
+		})
+		var rejected *plugin.RejectError
+		if errors.As(err, &rejected) {
+			log.Printf("[%s] %s: %v\n", req.ID, matchedHook.ID, err)
+			msg := rejected.Message
+			if msg == "" {
+				msg = "Request rejected."
+			}
+			c.String(rejected.Status, msg)
+			return
+		}
+		if err != nil {
+			log.Printf("[%s] %s: %v\n", req.ID, matchedHook.ID, err)
+			c.String(http.StatusBadGateway, "Error occurred while transforming the payload.")
+			return
+		}
+	}
+
 	// handle hook
 	errors := matchedHook.ParseJSONParameters(req)
 	for _, err := range errors {
@@ -644,12 +878,18 @@ func ginHookHandler(c *gin.Context) {
 
 	var ok bool
 
-	if matchedHook.TriggerRule == nil {
+	// an endpoint checks its own secrets instead of the hook's
+	triggerRule, softFailures := matchedHook.TriggerRuleFor(alias)
+	if triggerRule == nil {
 		ok = true
 	} else {
-		req.AllowSignatureErrors = matchedHook.TriggerSignatureSoftFailures
+		req.AllowSignatureErrors = softFailures
+		// the secret replaced by a rotation stays valid during its grace period
+		if triggerRule == matchedHook.TriggerRule && matchedHook.SecretRotation.Active(time.Now()) {
+			req.PreviousSecret = matchedHook.SecretRotation.PreviousSecret
+		}
 
-		ok, err = matchedHook.TriggerRule.Evaluate(req)
+		ok, err = triggerRule.Evaluate(req)
 		if err != nil {
 			if !webhook.IsParameterNodeError(err) {
 				msg := fmt.Sprintf("[%s] error evaluating hook: %s", req.ID, err)
@@ -664,65 +904,182 @@ func ginHookHandler(c *gin.Context) {
 
 	if ok {
 		log.Printf("[%s] %s hook triggered successfully\n", req.ID, matchedHook.ID)
+		if req.PreviousSecretUsed {
+			log.Printf("[%s] %s: the signature matched the previous secret, which expires at %s\n",
+				req.ID, matchedHook.ID, matchedHook.SecretRotation.Expires.Format(time.RFC3339))
+		}
 
 		for _, responseHeader := range matchedHook.ResponseHeaders {
 			c.Header(responseHeader.Name, responseHeader.Value)
 		}
 
+		// repeated deliveries with the same idempotency key get the response of the first one
+		if key := matchedHook.IdempotencyKey(req); key != "" {
+			cached, complete, err := webhook.Idempotency.AcquireDigest(c.Request.Context(), key, req.BodyDigest(), matchedHook.IdempotencyTTL())
+			switch {
+			case err == webhook.ErrIdempotencyMismatch:
+				log.Printf("[%s] %s: %v\n", req.ID, matchedHook.ID, err)
+				c.String(http.StatusUnprocessableEntity, "Idempotency key was already used with a different request body.")
+				return
+			case err != nil:
+				log.Printf("[%s] %s: waiting for the earlier delivery failed: %v\n", req.ID, matchedHook.ID, err)
+				return
+			case cached != nil:
+				log.Printf("[%s] %s: repeated delivery, answering with the cached response\n", req.ID, matchedHook.ID)
+				for name, values := range cached.Header {
+					c.Writer.Header()[name] = values
+				}
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(cached.Status, cached.Header.Get("Content-Type"), cached.Body)
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: c.Writer}
+			c.Writer = recorder
+			defer func() { complete(recorder.cachedResponse()) }()
+		}
+
+		// the circuit breaker of the budget pauses a hook that keeps failing
+		if open, until := webhook.CircuitOpen(matchedHook); open {
+			log.Printf("[%s] %s rejected: circuit breaker open\n", req.ID, matchedHook.ID)
+			if recorder, ok := c.Writer.(*responseRecorder); ok {
+				recorder.skip = true
+			}
+			if !until.IsZero() {
+				c.Header("Retry-After", strconv.Itoa(int(time.Until(until).Seconds())+1))
+			}
+			c.String(http.StatusServiceUnavailable, "Hook is paused by its circuit breaker after repeated failures.")
+			return
+		}
+
+		// maintenance mode or pause window: queue the delivery or reject it
+		if decision := maintenance.Check(maintenance.KindHook, matchedHook.ID); decision.Paused {
+			if decision.RejectStatus != 0 {
+				log.Printf("[%s] %s rejected: %s\n", req.ID, matchedHook.ID, decision.Reason)
+				if recorder, ok := c.Writer.(*responseRecorder); ok {
+					// a retry after the pause must run the hook
+					recorder.skip = true
+				}
+				c.String(decision.RejectStatus, "Hook execution is paused: %s", decision.Reason)
+				return
+			}
+			if err := webhook.QueueHookDelivery(matchedHook, req, decision.Reason); err != nil {
+				log.Printf("[%s] error queuing delivery: %v\n", req.ID, err)
+				c.String(http.StatusServiceUnavailable, "Hook execution is paused and the delivery could not be queued.")
+				return
+			}
+			c.String(http.StatusAccepted, "Hook execution is paused, delivery queued: %s", decision.Reason)
+			return
+		}
+
+		if matchedHook.ResponseContentType != "" {
+			c.Header("Content-Type", matchedHook.ResponseContentType)
+		}
+
+		// the shadow hook runs a copy of the delivery in the background, its result is only logged
+		webhook.MirrorDelivery(matchedHook, req)
+
 		if matchedHook.CaptureCommandOutput {
 			response, err := webhook.HandleHook(matchedHook, req)
 
-			if err != nil {
+			if matchedHook.ResponseTemplate != "" {
+				respondWithTemplate(c, matchedHook, req, response, err, false)
+			} else if err != nil {
 				if matchedHook.CaptureCommandOutputOnError {
-					c.String(http.StatusInternalServerError, response)
+					c.String(matchedHook.FailureStatus(), response)
 				} else {
 					c.Header("Content-Type", "text/plain; charset=utf-8")
-					c.String(http.StatusInternalServerError, "Error occurred while executing the hook's command. Please check your logs for more details.")
+					c.String(matchedHook.FailureStatus(), "Error occurred while executing the hook's command. Please check your logs for more details.")
 				}
 			} else {
-				if matchedHook.SuccessHttpResponseCode != 0 {
-					c.String(matchedHook.SuccessHttpResponseCode, response)
-				} else {
-					c.String(http.StatusOK, response)
-				}
+				c.String(matchedHook.SuccessStatus(), response)
 			}
 		} else {
 			if *verbose {
 				log.Printf("[%s] executing hook in background\n", req.ID)
 			}
-			go func() {
+			// background executions wait for a worker of the pool, a full queue rejects the delivery
+			err := pool.Executions.Submit(func() {
+				defer req.RemoveBody()
 				_, err := webhook.HandleHook(matchedHook, req)
 				if err != nil && *verbose {
 					log.Printf("[%s] background hook execution failed: %v\n", req.ID, err)
 				}
-			}()
+			})
+			if err != nil {
+				log.Printf("[%s] %s rejected: %v\n", req.ID, matchedHook.ID, err)
+				if recorder, ok := c.Writer.(*responseRecorder); ok {
+					recorder.skip = true
+				}
+				c.Header("Retry-After", "30")
+				c.String(http.StatusServiceUnavailable, "Too many hook executions are waiting, try again later.")
+				return
+			}
+			// the background execution still reads a spooled body
+			removeBody = false
 
-			if matchedHook.SuccessHttpResponseCode != 0 {
-				c.String(matchedHook.SuccessHttpResponseCode, matchedHook.ResponseMessage)
+			if matchedHook.ResponseTemplate != "" {
+				respondWithTemplate(c, matchedHook, req, "", nil, true)
 			} else {
-				c.String(http.StatusOK, matchedHook.ResponseMessage)
+				c.String(matchedHook.SuccessStatus(), matchedHook.ResponseMessage)
 			}
 		}
 
 		return
 	}
 
-	// check if a return code is configured for the hook
-	if matchedHook.TriggerRuleMismatchHttpResponseCode != 0 {
-		// validate HTTP status code is valid (100-599 range)
-		statusCode := matchedHook.TriggerRuleMismatchHttpResponseCode
-		if statusCode < 100 || statusCode > 599 {
-			// invalid HTTP status code, use default 200
-			statusCode = http.StatusOK
-		}
-		c.String(statusCode, "Hook rules were not satisfied.")
-	} else {
-		c.String(http.StatusOK, "Hook rules were not satisfied.")
-	}
+	// configured mismatch code, invalid codes fall back to 200
+	c.String(matchedHook.MismatchStatus(), "Hook rules were not satisfied.")
 
 	log.Printf("[%s] %s got matched, but didn't get triggered because the trigger rules were not satisfied\n", req.ID, matchedHook.ID)
 }
 
+// respondWithTemplate answer with the hook's response-template, the status follows the execution result
+func respondWithTemplate(c *gin.Context, h *webhook.Hook, req *webhook.Request, output string, err error, async bool) {
+	body, renderErr := webhook.RenderResponse(h, req, output, err, async)
+	if renderErr != nil {
+		log.Printf("[%s] %v\n", req.ID, renderErr)
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		c.String(http.StatusInternalServerError, "Error occurred while rendering the hook's response.")
+		return
+	}
+
+	status := h.SuccessStatus()
+	if err != nil {
+		status = h.FailureStatus()
+	}
+	c.String(status, body)
+}
+
+// responseRecorder keeps a copy of the hook response for the idempotency cache
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+	skip bool // do not cache the response
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// cachedResponse the recorded response, nil when it must not be reused
+func (w *responseRecorder) cachedResponse() *webhook.CachedResponse {
+	if w.skip || !w.Written() {
+		return nil
+	}
+	return &webhook.CachedResponse{
+		Status: w.Status(),
+		Header: w.Header().Clone(),
+		Body:   w.body.Bytes(),
+	}
+}
+
 // IsFlagPassed checks if a command-line flag was passed.
 func IsFlagPassed(name string) bool {
 	found := false
@@ -733,3 +1090,123 @@ func IsFlagPassed(name string) bool {
 	})
 	return found
 }
+
+// accessLogFormatter gin log line of a request, detailed in debug mode. The path keeps its
+// query without the values of credentials such as the capability tokens of hook endpoints.
+func accessLogFormatter(debug bool) gin.LogFormatter {
+	if debug {
+		return func(param gin.LogFormatterParams) string {
+			return fmt.Sprintf("[GoHook] %v | %3d | %13v | %15s | %-7s %#v\n",
+				param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+				param.StatusCode,
+				param.Latency,
+				param.ClientIP,
+				param.Method,
+				redact.Query(param.Path),
+			)
+		}
+	}
+	return func(param gin.LogFormatterParams) string {
+		return fmt.Sprintf("[GoHook] %3d | %13v | %s | %s %s\n",
+			param.StatusCode,
+			param.Latency,
+			param.ClientIP,
+			param.Method,
+			redact.Query(param.Path),
+		)
+	}
+}
+
+// applyHookLogEncryption encrypt new hook logs with the key of the env vault when app.yaml
+// asks for it; logs stored encrypted stay readable after encryption is turned off
+func applyHookLogEncryption() {
+	if types.GoHookAppConfig != nil && types.GoHookAppConfig.EncryptHookLogs {
+		// create the key up front rather than on the first hook run
+		if err := env.EnsureEncryptionKey(); err != nil {
+			log.Printf("hook log encryption: %v", err)
+		}
+		database.SetHookLogCipher(env.EncryptValue, env.DecryptValue)
+		return
+	}
+	database.SetHookLogCipher(nil, env.DecryptValue)
+}
+
+// applyURLLayout serve hooks and the panel under the URL layout of app.yaml,
+// -urlprefix and -basepath take precedence
+func applyURLLayout() error {
+	var cfg types.ServerConfig
+	if types.GoHookAppConfig != nil && types.GoHookAppConfig.Server != nil {
+		cfg = *types.GoHookAppConfig.Server
+	}
+	if IsFlagPassed("urlprefix") {
+		cfg.HooksURLPrefix = hooksURLPrefix
+	}
+	if IsFlagPassed("basepath") {
+		cfg.BasePath = *basePath
+	}
+	l, err := urls.FromConfig(&cfg)
+	if err != nil {
+		return err
+	}
+	urls.Set(l)
+	return nil
+}
+
+// lintConfig log a summary of the configuration lint, with -lint-strict errors stop the start.
+// The findings are printed to stderr as well since the log is discarded without -verbose.
+func lintConfig(files []string) {
+	report := router.LintConfig(files, *asTemplate)
+	log.Printf("configuration lint: %d error(s), %d warning(s), see GET /admin/lint", report.Errors, report.Warnings)
+	for _, f := range report.Findings {
+		log.Printf("lint %s", f)
+	}
+	if *lintStrict && report.Errors > 0 {
+		for _, f := range report.Findings {
+			if f.Level == router.LintError {
+				fmt.Fprintln(os.Stderr, f)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "error: configuration lint found %d error(s), refusing to start (-lint-strict)\n", report.Errors)
+		os.Exit(1)
+	}
+}
+
+// reloadConfig reload a configuration file saved by another instance of the HA cluster
+func reloadConfig(name string) {
+	var err error
+	switch name {
+	case cluster.ConfigApp:
+		if err = config.LoadAppConfig(); err == nil {
+			err = applyURLLayout()
+		}
+		if err == nil {
+			err = middleware.ConfigureAccessLog(types.GoHookAppConfig.AccessLog)
+		}
+		if err == nil {
+			consumer.Reload()
+			pool.Configure(types.GoHookAppConfig.Execution)
+			database.SetAuditHashChain(types.GoHookAppConfig.Database.AuditHashChain)
+			applyHookLogEncryption()
+			logforward.Reload()
+			if err := locale.Configure(types.GoHookAppConfig.Timezone); err != nil {
+				log.Printf("timezone in app.yaml ignored: %v", err)
+			}
+			if err := redact.Configure(types.GoHookAppConfig.Redaction); err != nil {
+				log.Printf("redaction in app.yaml ignored: %v", err)
+			}
+		}
+	case cluster.ConfigVersion:
+		if err = config.LoadVersionConfig(); err == nil {
+			syncnode.RefreshProjectWatchers()
+		}
+	case cluster.ConfigUsers:
+		err = client.LoadUsersConfig()
+	case cluster.ConfigHooks:
+		webhook.ReloadAllHooks(hooksFiles, *asTemplate)
+	default:
+		return
+	}
+	if err != nil {
+		log.Printf("cluster: reload %s config failed: %v", name, err)
+	}
+}
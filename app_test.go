@@ -8,6 +8,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -19,6 +20,7 @@ import (
 	"text/template"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/mycoool/gohook/internal/webhook"
 )
 
@@ -1324,3 +1326,24 @@ func TestWebhookReloadsFileThatIsReplacedByAnother(t *testing.T) {
 		t.Errorf("error waiting for process to exit: %v", err)
 	}
 }
+
+func TestAccessLogFormatterHidesTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, debug := range []bool{false, true} {
+		out := &bytes.Buffer{}
+		r := gin.New()
+		r.Use(gin.LoggerWithConfig(gin.LoggerConfig{Formatter: accessLogFormatter(debug), Output: out}))
+		r.POST("/hooks/*id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		req := httptest.NewRequest(http.MethodPost, "/hooks/ep-1?ref=main&token=s3cr3t-capability", nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		line := out.String()
+		if strings.Contains(line, "s3cr3t-capability") {
+			t.Errorf("debug %v: log line holds the token: %s", debug, line)
+		}
+		if !strings.Contains(line, "/hooks/ep-1?ref=main&token=[REDACTED]") {
+			t.Errorf("debug %v: log line lost the path: %s", debug, line)
+		}
+	}
+}
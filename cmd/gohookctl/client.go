@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// apiClient minimal client of the GoHook HTTP API
+type apiClient struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// apiError error response of the API
+type apiError struct {
+	Status  int
+	Message string
+	Body    []byte
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+func newAPIClient(server, token string) *apiClient {
+	return &apiClient{
+		server: strings.TrimRight(server, "/"),
+		token:  token,
+		http:   &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// do send a request, body is encoded as JSON unless it is an io.Reader, the response is decoded into out
+func (c *apiClient) do(method, path string, query url.Values, body, out interface{}) error {
+	if c.server == "" {
+		return fmt.Errorf("server is not set, use --server, GOHOOK_URL or gohookctl login")
+	}
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	u := c.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return err
+	}
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("X-GoHook-Key", c.token)
+	}
+	return c.send(req, out)
+}
+
+func (c *apiClient) send(req *http.Request, out interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			msg = e.Error
+		}
+		return &apiError{Status: resp.StatusCode, Message: msg, Body: data}
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if raw, ok := out.(*json.RawMessage); ok {
+		*raw = append((*raw)[:0], data...)
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// login exchange basic credentials for a client token
+func (c *apiClient) login(username, password, clientName string) (string, error) {
+	data, _ := json.Marshal(map[string]string{"name": clientName})
+	req, err := http.NewRequest(http.MethodPost, c.server+"/client", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(username, password)
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := c.send(req, &resp); err != nil {
+		return "", err
+	}
+	return resp.Token, nil
+}
+
+// ctlConfig credentials stored by `gohookctl login`
+type ctlConfig struct {
+	Server string `json:"server"`
+	Token  string `json:"token"`
+}
+
+func defaultConfigPath() string {
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "gohookctl", "config.json")
+	}
+	return ".gohookctl.json"
+}
+
+func loadCtlConfig(path string) ctlConfig {
+	var cfg ctlConfig
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &cfg)
+	}
+	return cfg
+}
+
+func saveCtlConfig(path string, cfg ctlConfig) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Export and import projects and hooks (admin)",
+	}
+
+	var outFile string
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "Write projects and hooks as one JSON bundle",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var bundle json.RawMessage
+			if err := client().do(http.MethodGet, "/system/export", nil, nil, &bundle); err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			if outFile != "" && outFile != "-" {
+				f, err := os.Create(outFile)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(bundle)
+		},
+	}
+	export.Flags().StringVarP(&outFile, "file", "f", "", "output file (default stdout)")
+
+	var inFile string
+	var replace bool
+	imp := &cobra.Command{
+		Use:   "import",
+		Short: "Import a bundle written by export",
+		Long: "Import a bundle written by export. Projects and hooks are matched by name and id;\n" +
+			"with --replace, projects and hooks missing from the bundle are removed.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := readBundle(cmd, inFile)
+			if err != nil {
+				return err
+			}
+
+			query := url.Values{}
+			if replace {
+				query.Set("mode", "replace")
+			}
+			var resp map[string]interface{}
+			if err := client().do(http.MethodPost, "/system/import", query, json.RawMessage(data), &resp); err != nil {
+				return err
+			}
+			return printMessage(cmd.OutOrStdout(), resp, "imported %v projects and %v hooks (%v)", resp["projects"], resp["hooks"], resp["mode"])
+		},
+	}
+	imp.Flags().StringVarP(&inFile, "file", "f", "", "bundle file (default stdin)")
+	imp.Flags().BoolVar(&replace, "replace", false, "remove projects and hooks missing from the bundle")
+
+	var diffFile string
+	var diffReplace bool
+	diff := &cobra.Command{
+		Use:   "diff",
+		Short: "Show what importing a bundle would change, without applying it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := readBundle(cmd, diffFile)
+			if err != nil {
+				return err
+			}
+			query := url.Values{}
+			if diffReplace {
+				query.Set("mode", "replace")
+			}
+			var resp configDiff
+			if err := client().do(http.MethodPost, "/admin/config/diff", query, json.RawMessage(data), &resp); err != nil {
+				return err
+			}
+			if flagOutput == "json" {
+				return printResult(cmd.OutOrStdout(), resp)
+			}
+			return printConfigDiff(cmd.OutOrStdout(), &resp)
+		},
+	}
+	diff.Flags().StringVarP(&diffFile, "file", "f", "", "bundle file (default stdin)")
+	diff.Flags().BoolVar(&diffReplace, "replace", false, "also show projects and hooks missing from the bundle as removed")
+
+	cmd.AddCommand(export, imp, diff)
+	return cmd
+}
+
+// readBundle bundle of the file, stdin for "" or "-"
+func readBundle(cmd *cobra.Command, file string) ([]byte, error) {
+	var data []byte
+	var err error
+	if file == "" || file == "-" {
+		data, err = io.ReadAll(cmd.InOrStdin())
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("bundle is not valid JSON")
+	}
+	return data, nil
+}
+
+// configDiff response of POST /admin/config/diff
+type configDiff struct {
+	Mode             string     `json:"mode"`
+	Hooks            entityDiff `json:"hooks"`
+	Projects         entityDiff `json:"projects"`
+	AffectedProjects []string   `json:"affectedProjects"`
+	Warnings         []string   `json:"warnings,omitempty"`
+}
+
+type entityDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []struct {
+		Name   string `json:"name"`
+		Fields []struct {
+			Field string `json:"field"`
+		} `json:"fields"`
+	} `json:"changed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// printConfigDiff print a diff as "+ hook id", "- project name" and "~ hook id: fields" lines
+func printConfigDiff(w io.Writer, d *configDiff) error {
+	changes := 0
+	for _, kind := range []struct {
+		name string
+		diff entityDiff
+	}{{"project", d.Projects}, {"hook", d.Hooks}} {
+		for _, name := range kind.diff.Added {
+			fmt.Fprintf(w, "+ %s %s\n", kind.name, name)
+		}
+		for _, name := range kind.diff.Removed {
+			fmt.Fprintf(w, "- %s %s\n", kind.name, name)
+		}
+		for _, change := range kind.diff.Changed {
+			fields := make([]string, len(change.Fields))
+			for i, f := range change.Fields {
+				fields[i] = f.Field
+			}
+			fmt.Fprintf(w, "~ %s %s: %s\n", kind.name, change.Name, strings.Join(fields, ", "))
+		}
+		changes += len(kind.diff.Added) + len(kind.diff.Removed) + len(kind.diff.Changed)
+	}
+	if changes == 0 {
+		fmt.Fprintln(w, "no changes")
+	}
+	if len(d.AffectedProjects) > 0 {
+		fmt.Fprintf(w, "affected projects: %s\n", strings.Join(d.AffectedProjects, ", "))
+	}
+	for _, warning := range d.Warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+	return nil
+}
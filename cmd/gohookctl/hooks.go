@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func newHooksCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "hooks",
+		Aliases: []string{"hook"},
+		Short:   "List and trigger hooks",
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List hooks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var hooks []map[string]interface{}
+			if err := client().do(http.MethodGet, "/hook", nil, nil, &hooks); err != nil {
+				return err
+			}
+			return printResult(cmd.OutOrStdout(), hooks, "id", "name", "executeCommand", "httpMethods", "triggerRuleDescription")
+		},
+	}
+
+	get := &cobra.Command{
+		Use:   "get ID",
+		Short: "Show a hook",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var hook json.RawMessage
+			if err := client().do(http.MethodGet, "/hook/"+url.PathEscape(args[0]), nil, nil, &hook); err != nil {
+				return err
+			}
+			return printResult(cmd.OutOrStdout(), hook)
+		},
+	}
+
+	cmd.AddCommand(list, get, newHookTriggerCmd(), &cobra.Command{
+		Use:   "reload",
+		Short: "Reload hooks files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var resp map[string]interface{}
+			if err := client().do(http.MethodPost, "/hook/reload-config", nil, nil, &resp); err != nil {
+				return err
+			}
+			return printMessage(cmd.OutOrStdout(), resp, "%v", resp["message"])
+		},
+	})
+	return cmd
+}
+
+func newHookTriggerCmd() *cobra.Command {
+	var (
+		payload string
+		headers []string
+		query   []string
+		env     []string
+		extra   []string
+	)
+	cmd := &cobra.Command{
+		Use:   "trigger ID",
+		Short: "Run a hook, exits non-zero when the command fails",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := map[string]interface{}{}
+			if payload != "" {
+				data := []byte(payload)
+				if payload == "-" || payload[0] == '@' {
+					var err error
+					if payload == "-" {
+						data, err = io.ReadAll(os.Stdin)
+					} else {
+						data, err = os.ReadFile(payload[1:])
+					}
+					if err != nil {
+						return err
+					}
+				}
+				var body map[string]interface{}
+				if err := json.Unmarshal(data, &body); err != nil {
+					return fmt.Errorf("payload must be a JSON object: %v", err)
+				}
+				req["payload"] = body
+			}
+			for name, values := range map[string][]string{"headers": headers, "query": query, "env": env} {
+				kv, err := parseKeyValues(values)
+				if err != nil {
+					return err
+				}
+				if kv != nil {
+					req[name] = kv
+				}
+			}
+			if len(extra) > 0 {
+				req["args"] = extra
+			}
+
+			// a failed command is answered with 500 and still carries the output
+			var raw json.RawMessage
+			err := client().do(http.MethodPost, "/hook/"+url.PathEscape(args[0])+"/trigger", nil, req, &raw)
+			var apiErr *apiError
+			if errors.As(err, &apiErr) && apiErr.Status == http.StatusInternalServerError && json.Valid(apiErr.Body) {
+				raw = apiErr.Body
+			} else if err != nil {
+				return err
+			}
+
+			var resp struct {
+				Output string `json:"output"`
+				Error  string `json:"error"`
+			}
+			if err := json.Unmarshal(raw, &resp); err != nil {
+				return err
+			}
+			if flagOutput == "json" {
+				if err := printResult(cmd.OutOrStdout(), raw); err != nil {
+					return err
+				}
+			} else if resp.Output != "" {
+				fmt.Fprintln(cmd.OutOrStdout(), resp.Output)
+			}
+			if apiErr != nil {
+				return fmt.Errorf("hook %s failed: %s", args[0], resp.Error)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&payload, "payload", "", "JSON payload, @file or - for stdin")
+	cmd.Flags().StringArrayVar(&headers, "header", nil, "request header NAME=VALUE (repeatable)")
+	cmd.Flags().StringArrayVar(&query, "query", nil, "query value NAME=VALUE (repeatable)")
+	cmd.Flags().StringArrayVar(&env, "env", nil, "environment override NAME=VALUE (repeatable)")
+	cmd.Flags().StringArrayVar(&extra, "arg", nil, "extra command argument (repeatable)")
+	return cmd
+}
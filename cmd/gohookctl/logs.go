@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type logPage struct {
+	Logs  []map[string]interface{} `json:"logs"`
+	Total int64                    `json:"total"`
+}
+
+func newLogsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Read execution and activity logs",
+	}
+
+	var (
+		logType  string
+		project  string
+		user     string
+		hook     string
+		lines    int
+		follow   bool
+		interval time.Duration
+	)
+	tail := &cobra.Command{
+		Use:   "tail",
+		Short: "Show the latest logs, --follow keeps polling for new entries",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := client()
+			query := url.Values{}
+			query.Set("type", logType)
+			query.Set("pageSize", strconv.Itoa(lines))
+			if project != "" {
+				query.Set("project", project)
+			}
+			if user != "" {
+				query.Set("user", user)
+			}
+
+			seen := map[string]bool{}
+			since := ""
+			for {
+				if since != "" {
+					query.Set("startDate", since)
+				}
+				var page logPage
+				if err := c.do(http.MethodGet, "/api/logs", query, nil, &page); err != nil {
+					return err
+				}
+				// the API returns newest first
+				for i := len(page.Logs) - 1; i >= 0; i-- {
+					entry := page.Logs[i]
+					key := fmt.Sprintf("%v/%v", entry["type"], entry["id"])
+					if seen[key] || (hook != "" && entry["hookName"] != hook) {
+						continue
+					}
+					seen[key] = true
+					if ts, ok := entry["timestamp"].(string); ok && ts > since {
+						since = ts
+					}
+					if err := printLogEntry(cmd.OutOrStdout(), entry); err != nil {
+						return err
+					}
+				}
+				if !follow {
+					return nil
+				}
+				select {
+				case <-cmd.Context().Done():
+					return nil
+				case <-time.After(interval):
+				}
+			}
+		},
+	}
+	tail.Flags().StringVarP(&logType, "type", "t", "hook", "log type: hook, system, user or project")
+	tail.Flags().StringVar(&project, "project", "", "only logs of this project (type project)")
+	tail.Flags().StringVar(&user, "user", "", "only logs of this user")
+	tail.Flags().StringVar(&hook, "hook", "", "only executions of the hook with this name (type hook)")
+	tail.Flags().IntVarP(&lines, "lines", "n", 20, "number of entries to show, at most 100")
+	tail.Flags().BoolVarP(&follow, "follow", "f", false, "keep polling for new entries")
+	tail.Flags().DurationVar(&interval, "interval", 2*time.Second, "poll interval with --follow")
+
+	cmd.AddCommand(tail)
+	return cmd
+}
+
+func printLogEntry(w io.Writer, entry map[string]interface{}) error {
+	if flagOutput == "json" {
+		return printResult(w, entry)
+	}
+	status := ""
+	if ok, exists := entry["success"].(bool); exists {
+		status = " [ok]"
+		if !ok {
+			status = " [failed]"
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s %s%s %s\n", formatValue(entry["timestamp"]), formatValue(entry["type"]), status, formatValue(entry["message"]))
+	return err
+}
@@ -0,0 +1,192 @@
+// Command gohookctl administers a GoHook server through its HTTP API.
+// It is meant for scripts and CI pipelines: credentials come from flags, the
+// GOHOOK_URL/GOHOOK_TOKEN environment variables or `gohookctl login`, and every
+// command can print JSON with --output json.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagServer     string
+	flagToken      string
+	flagOutput     string
+	flagConfigPath string
+)
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use:           "gohookctl",
+		Short:         "Administer a GoHook server",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if flagOutput != "table" && flagOutput != "json" {
+				return fmt.Errorf("unsupported output %q, use table or json", flagOutput)
+			}
+			return nil
+		},
+	}
+	root.PersistentFlags().StringVar(&flagServer, "server", "", "GoHook server URL (env GOHOOK_URL)")
+	root.PersistentFlags().StringVar(&flagToken, "token", "", "client token (env GOHOOK_TOKEN)")
+	root.PersistentFlags().StringVarP(&flagOutput, "output", "o", "table", "output format: table or json")
+	root.PersistentFlags().StringVar(&flagConfigPath, "config", defaultConfigPath(), "credentials file written by login")
+
+	root.AddCommand(
+		newLoginCmd(),
+		newHooksCmd(),
+		newLogsCmd(),
+		newProjectsCmd(),
+		newUsersCmd(),
+		newNodesCmd(),
+		newConfigCmd(),
+	)
+	return root
+}
+
+// client resolve server and token: flags, then environment, then the login file
+func client() *apiClient {
+	cfg := loadCtlConfig(flagConfigPath)
+	server := firstNonEmpty(flagServer, os.Getenv("GOHOOK_URL"), cfg.Server)
+	token := firstNonEmpty(flagToken, os.Getenv("GOHOOK_TOKEN"), cfg.Token)
+	return newAPIClient(server, token)
+}
+
+func newLoginCmd() *cobra.Command {
+	var username, password string
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Create a client token and store it for later commands",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if password == "" {
+				password = os.Getenv("GOHOOK_PASSWORD")
+			}
+			if username == "" || password == "" {
+				return fmt.Errorf("--username and --password (or GOHOOK_PASSWORD) are required")
+			}
+			c := client()
+			hostname, _ := os.Hostname()
+			token, err := c.login(username, password, "gohookctl@"+hostname)
+			if err != nil {
+				return err
+			}
+			if err := saveCtlConfig(flagConfigPath, ctlConfig{Server: c.server, Token: token}); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "logged in to %s, token saved to %s\n", c.server, flagConfigPath)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&username, "username", "u", "", "username")
+	cmd.Flags().StringVarP(&password, "password", "p", "", "password (env GOHOOK_PASSWORD)")
+	return cmd
+}
+
+// printResult print v as JSON, or as a table of the given columns read from its JSON fields
+func printResult(w io.Writer, v interface{}, columns ...string) error {
+	if flagOutput == "json" || len(columns) == 0 {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	rows, err := toRows(v)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+	for _, row := range rows {
+		values := make([]string, len(columns))
+		for i, col := range columns {
+			values[i] = formatValue(row[col])
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	return tw.Flush()
+}
+
+// printMessage print a short confirmation, or the raw response with --output json
+func printMessage(w io.Writer, v interface{}, format string, a ...interface{}) error {
+	if flagOutput == "json" {
+		return printResult(w, v)
+	}
+	_, err := fmt.Fprintf(w, format+"\n", a...)
+	return err
+}
+
+func toRows(v interface{}) ([]map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(data, &rows); err == nil {
+		return rows, nil
+	}
+	var row map[string]interface{}
+	if err := json.Unmarshal(data, &row); err != nil {
+		return nil, err
+	}
+	return []map[string]interface{}{row}, nil
+}
+
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		if val == "" {
+			return "-"
+		}
+		return val
+	case float64:
+		return fmt.Sprintf("%v", val)
+	case []interface{}, map[string]interface{}:
+		data, _ := json.Marshal(val)
+		return string(data)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// parseKeyValues parse repeated KEY=VALUE flags
+func parseKeyValues(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]string, len(values))
+	for _, kv := range values {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid KEY=VALUE: %s", kv)
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/cobra"
+)
+
+func newNodesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "nodes",
+		Aliases: []string{"node"},
+		Short:   "Manage sync nodes",
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List sync nodes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var nodes []map[string]interface{}
+			if err := client().do(http.MethodGet, "/api/sync/nodes", nil, nil, &nodes); err != nil {
+				return err
+			}
+			return printResult(cmd.OutOrStdout(), nodes, "id", "name", "type", "connectionStatus", "health", "approvalStatus", "agentVersion", "lastSeen")
+		},
+	}
+
+	get := &cobra.Command{
+		Use:   "get ID",
+		Short: "Show a sync node",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var node json.RawMessage
+			if err := client().do(http.MethodGet, nodePath(args[0], ""), nil, nil, &node); err != nil {
+				return err
+			}
+			return printResult(cmd.OutOrStdout(), node)
+		},
+	}
+
+	var nodeType, address, remark string
+	var tags []string
+	create := &cobra.Command{
+		Use:   "create NAME",
+		Short: "Register a sync node, the agent token is printed once",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var node map[string]interface{}
+			body := map[string]interface{}{"name": args[0], "type": nodeType, "address": address, "remark": remark, "tags": tags}
+			if err := client().do(http.MethodPost, "/api/sync/nodes", nil, body, &node); err != nil {
+				return err
+			}
+			return printResult(cmd.OutOrStdout(), node, "id", "name", "type", "agentToken")
+		},
+	}
+	create.Flags().StringVar(&nodeType, "type", "agent", "node type")
+	create.Flags().StringVar(&address, "address", "", "node address")
+	create.Flags().StringVar(&remark, "remark", "", "remark")
+	create.Flags().StringSliceVar(&tags, "tag", nil, "node tag (repeatable)")
+
+	cmd.AddCommand(list, get, create,
+		nodeActionCmd("approve", "Approve a pending node (admin)", http.MethodPost, "approve"),
+		nodeActionCmd("revoke", "Revoke a node (admin)", http.MethodPost, "revoke"),
+		nodeActionCmd("rotate-token", "Issue a new agent token", http.MethodPost, "rotate-token"),
+		nodeActionCmd("reset-pairing", "Forget the paired agent certificate", http.MethodPost, "reset-pairing"),
+		nodeActionCmd("delete", "Delete a node", http.MethodDelete, ""),
+	)
+	return cmd
+}
+
+// nodeActionCmd command calling an action endpoint of one node and printing the response
+func nodeActionCmd(use, short, method, action string) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " ID",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var resp json.RawMessage
+			if err := client().do(method, nodePath(args[0], action), nil, nil, &resp); err != nil {
+				return err
+			}
+			if len(resp) == 0 {
+				return printMessage(cmd.OutOrStdout(), map[string]string{}, "node %s: %s done", args[0], use)
+			}
+			return printResult(cmd.OutOrStdout(), resp)
+		},
+	}
+}
+
+func nodePath(id, action string) string {
+	path := "/api/sync/nodes/" + url.PathEscape(id)
+	if action != "" {
+		path += "/" + action
+	}
+	return path
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/cobra"
+)
+
+func newProjectsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "projects",
+		Aliases: []string{"project"},
+		Short:   "List projects and switch their branch or tag",
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List projects",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var projects []map[string]interface{}
+			if err := client().do(http.MethodGet, "/version", nil, nil, &projects); err != nil {
+				return err
+			}
+			return printResult(cmd.OutOrStdout(), projects, "name", "mode", "currentBranch", "currentTag", "lastCommit", "status")
+		},
+	}
+
+	branches := &cobra.Command{
+		Use:   "branches NAME",
+		Short: "List branches of a project",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var result []map[string]interface{}
+			if err := client().do(http.MethodGet, projectPath(args[0], "branches"), nil, nil, &result); err != nil {
+				return err
+			}
+			return printResult(cmd.OutOrStdout(), result, "name", "isCurrent", "type", "lastCommit", "lastCommitTime")
+		},
+	}
+
+	tags := &cobra.Command{
+		Use:   "tags NAME",
+		Short: "List tags of a project",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var result []map[string]interface{}
+			if err := client().do(http.MethodGet, projectPath(args[0], "tags"), nil, nil, &result); err != nil {
+				return err
+			}
+			return printResult(cmd.OutOrStdout(), result, "name", "isCurrent", "commitHash", "date")
+		},
+	}
+
+	var force bool
+	switchBranch := &cobra.Command{
+		Use:   "switch-branch NAME BRANCH",
+		Short: "Switch a project to a branch",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var resp map[string]interface{}
+			body := map[string]interface{}{"branch": args[1], "force": force}
+			if err := client().do(http.MethodPost, projectPath(args[0], "switch-branch"), nil, body, &resp); err != nil {
+				return err
+			}
+			return printMessage(cmd.OutOrStdout(), resp, "%s switched to branch %s", args[0], args[1])
+		},
+	}
+	switchBranch.Flags().BoolVar(&force, "force", false, "discard local changes")
+
+	switchTag := &cobra.Command{
+		Use:   "switch-tag NAME TAG",
+		Short: "Switch a project to a tag",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var resp map[string]interface{}
+			body := map[string]interface{}{"tag": args[1], "force": force}
+			if err := client().do(http.MethodPost, projectPath(args[0], "switch-tag"), nil, body, &resp); err != nil {
+				return err
+			}
+			return printMessage(cmd.OutOrStdout(), resp, "%s switched to tag %s", args[0], args[1])
+		},
+	}
+	switchTag.Flags().BoolVar(&force, "force", false, "discard local changes")
+
+	var from string
+	promote := &cobra.Command{
+		Use:   "promote NAME --from SOURCE",
+		Short: "Promote the revision deployed in SOURCE to a project",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var resp map[string]interface{}
+			if err := client().do(http.MethodPost, projectPath(args[0], "promote"), nil, map[string]string{"from": from}, &resp); err != nil {
+				return err
+			}
+			return printResult(cmd.OutOrStdout(), resp)
+		},
+	}
+	promote.Flags().StringVar(&from, "from", "", "source project")
+	_ = promote.MarkFlagRequired("from")
+
+	cmd.AddCommand(list, branches, tags, switchBranch, switchTag, promote)
+	return cmd
+}
+
+func projectPath(name, action string) string {
+	return "/version/" + url.PathEscape(name) + "/" + action
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/cobra"
+)
+
+func newUsersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "users",
+		Aliases: []string{"user"},
+		Short:   "Manage users (admin)",
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List users",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var users []map[string]interface{}
+			if err := client().do(http.MethodGet, "/user", nil, nil, &users); err != nil {
+				return err
+			}
+			return printResult(cmd.OutOrStdout(), users, "username", "role")
+		},
+	}
+
+	var password, role string
+	create := &cobra.Command{
+		Use:   "create USERNAME --password PASSWORD",
+		Short: "Create a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if password == "" {
+				return fmt.Errorf("--password is required")
+			}
+			var resp map[string]interface{}
+			body := map[string]string{"username": args[0], "password": password, "role": role}
+			if err := client().do(http.MethodPost, "/user", nil, body, &resp); err != nil {
+				return err
+			}
+			return printMessage(cmd.OutOrStdout(), resp, "user %s created", args[0])
+		},
+	}
+	create.Flags().StringVar(&password, "password", "", "password")
+	create.Flags().StringVar(&role, "role", "user", "role: admin or user")
+
+	del := &cobra.Command{
+		Use:   "delete USERNAME",
+		Short: "Delete a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var resp map[string]interface{}
+			if err := client().do(http.MethodDelete, "/user/"+url.PathEscape(args[0]), nil, nil, &resp); err != nil {
+				return err
+			}
+			return printMessage(cmd.OutOrStdout(), resp, "user %s deleted", args[0])
+		},
+	}
+
+	var newPassword string
+	reset := &cobra.Command{
+		Use:   "reset-password USERNAME --password PASSWORD",
+		Short: "Set a new password for a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if newPassword == "" {
+				return fmt.Errorf("--password is required")
+			}
+			var resp map[string]interface{}
+			body := map[string]string{"newPassword": newPassword}
+			if err := client().do(http.MethodPost, "/user/"+url.PathEscape(args[0])+"/reset-password", nil, body, &resp); err != nil {
+				return err
+			}
+			return printMessage(cmd.OutOrStdout(), resp, "password of %s reset", args[0])
+		},
+	}
+	reset.Flags().StringVar(&newPassword, "password", "", "new password")
+
+	cmd.AddCommand(list, create, del, reset)
+	return cmd
+}
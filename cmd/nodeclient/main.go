@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	"flag"
+	"io"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -35,7 +37,13 @@ func main() {
 		DataDir:           cfg.DataDir,
 		TLSDir:            cfg.TLSDir,
 		ServerFingerprint: cfg.ServerFingerprint,
+		HooksFile:         cfg.HooksFile,
+		LocalListen:       cfg.LocalListen,
+		ShipLogs:          cfg.ShipLogs,
 	})
+	if w := agent.LogWriter(); w != nil {
+		log.SetOutput(io.MultiWriter(os.Stderr, w))
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
@@ -60,13 +68,16 @@ type runtimeConfig struct {
 	DataDir           string
 	TLSDir            string
 	ServerFingerprint string
+	HooksFile         string
+	LocalListen       string
+	ShipLogs          bool
 }
 
 func loadConfig() runtimeConfig {
 	defaultDir := defaultDataDir()
 
 	var (
-		flagServer = flag.String("server", "", "Primary sync TCP endpoint, e.g. 10.0.0.10:9001")
+		flagServer = flag.String("server", "", "Primary sync TCP endpoint, e.g. 10.0.0.10:9001 (comma-separated list for failover)")
 		flagToken  = flag.String("token", "", "Sync agent token from node management")
 		flagNodeID = flag.Uint("node-id", 0, "Node id (optional; if omitted agent will enroll by token)")
 
@@ -79,6 +90,10 @@ func loadConfig() runtimeConfig {
 		flagVersion  = flag.String("version", "", "Agent version string (optional)")
 		flagInterval = flag.Duration("interval", 30*time.Second, "Reconnect/heartbeat interval (deprecated, reserved)")
 		flagFP       = flag.String("server-fingerprint", "", "Expected server certificate sha256 hex (optional; overrides TOFU)")
+
+		flagHooks  = flag.String("hooks", "", "Local hooks file executed on this node (optional)")
+		flagListen = flag.String("listen", "", "Loopback address of the local hooks listener (default: "+nodeclient.DefaultLocalListen+")")
+		flagShip   = flag.Bool("ship-logs", true, "Ship agent logs and command output to the server")
 	)
 	flag.Parse()
 
@@ -125,9 +140,18 @@ func loadConfig() runtimeConfig {
 			os.Getenv("GOHOOK_SERVER_FINGERPRINT"),
 			os.Getenv("SYNC_SERVER_FINGERPRINT"),
 		),
+		HooksFile:   firstNonEmpty(*flagHooks, os.Getenv("GOHOOK_HOOKS_FILE")),
+		LocalListen: firstNonEmpty(*flagListen, os.Getenv("GOHOOK_LOCAL_LISTEN")),
+		ShipLogs:    *flagShip && !envDisabled("GOHOOK_SHIP_LOGS"),
 	}
 }
 
+// envDisabled reports whether the env var key is set to false or 0.
+func envDisabled(key string) bool {
+	v := strings.TrimSpace(os.Getenv(key))
+	return strings.EqualFold(v, "false") || v == "0"
+}
+
 func getenvDefault(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
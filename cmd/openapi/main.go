@@ -0,0 +1,43 @@
+// Command openapi writes the OpenAPI document of the GoHook HTTP API to stdout.
+// It is run for every release to refresh docs/openapi.json (see `make openapi`).
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/openapi"
+	"github.com/mycoool/gohook/internal/router"
+	"github.com/mycoool/gohook/internal/urls"
+)
+
+func main() {
+	version := flag.String("version", "dev", "API version written to info.version")
+	prefix := flag.String("urlprefix", "hooks", "url prefix of served hooks")
+	flag.Parse()
+
+	gin.SetMode(gin.ReleaseMode)
+	gin.DefaultWriter = io.Discard
+
+	hooksPrefix, err := urls.NormalizeHooksPrefix(*prefix)
+	if err != nil {
+		log.Fatalf("invalid url prefix: %v", err)
+	}
+	urls.Set(urls.Layout{HooksPrefix: hooksPrefix})
+
+	r := router.NewEngine()
+	// webhook deliveries are handled by the server binary itself
+	router.RegisterHookRoutes(r, func(*gin.Context) {})
+	router.RegisterVersionedAPI(r)
+
+	doc := openapi.Generate(r.Routes(), openapi.Info{Version: *version})
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		log.Fatalf("encode openapi document: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// archive layout: magic | salt | nonce | AES-256-GCM(tar.gz), the key is derived
+// from the passphrase with PBKDF2-SHA256
+const (
+	magic         = "GOHOOKB1"
+	saltSize      = 16
+	kdfIterations = 600000
+)
+
+// MinPassphraseLength shortest passphrase accepted for backups
+const MinPassphraseLength = 8
+
+var (
+	// ErrDecrypt wrong passphrase or damaged archive
+	ErrDecrypt = errors.New("cannot decrypt backup: wrong passphrase or damaged archive")
+	// ErrInvalid the archive is not a usable gohook backup
+	ErrInvalid = errors.New("invalid backup")
+)
+
+// entry file stored in the archive
+type entry struct {
+	name string
+	mode int64
+	data []byte
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, salt, kdfIterations, 32)
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal write entries as an encrypted tar.gz to w
+func seal(w io.Writer, passphrase string, entries []entry) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: e.mode, Size: int64(len(e.data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return err
+	}
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	out := make([]byte, 0, len(magic)+saltSize+len(nonce)+buf.Len()+gcm.Overhead())
+	out = append(out, magic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	out = gcm.Seal(out, nonce, buf.Bytes(), []byte(magic))
+	_, err = w.Write(out)
+	return err
+}
+
+// open decrypt an archive written by seal and return its entries by name
+func open(data []byte, passphrase string) (map[string]entry, error) {
+	if len(data) < len(magic)+saltSize || string(data[:len(magic)]) != magic {
+		return nil, fmt.Errorf("%w: not a gohook backup", ErrInvalid)
+	}
+	salt := data[len(magic) : len(magic)+saltSize]
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	rest := data[len(magic)+saltSize:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plain, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], []byte(magic))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	entries := map[string]entry{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		entries[hdr.Name] = entry{name: hdr.Name, mode: hdr.Mode, data: content}
+	}
+	return entries, nil
+}
@@ -0,0 +1,475 @@
+// Package backup writes and restores encrypted archives of the gohook configuration
+// (hooks files, version.yaml, user.yaml, hook scripts and configuration tables) for
+// migrations and disaster recovery.
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/mycoool/gohook/internal/client"
+	"github.com/mycoool/gohook/internal/cluster"
+	"github.com/mycoool/gohook/internal/config"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/env"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/webhook"
+	"gorm.io/gorm"
+)
+
+// file kinds of the manifest
+const (
+	KindHooks   = "hooks"
+	KindVersion = "version"
+	KindUsers   = "users"
+	KindScript  = "script"
+)
+
+// backed up database tables
+const (
+	TableProjectEnvs = "project_envs"
+	TableSyncNodes   = "sync_nodes"
+)
+
+const (
+	formatVersion = 1
+	manifestName  = "manifest.json"
+	// scripts larger than this are not backed up
+	maxScriptSize = 1 << 20
+	// config files of the working directory
+	versionFile = "version.yaml"
+	usersFile   = "user.yaml"
+)
+
+// Manifest describes the content of a backup
+type Manifest struct {
+	Format    int       `json:"format"`
+	CreatedAt time.Time `json:"createdAt"`
+	Files     []File    `json:"files"`
+	Tables    []Table   `json:"tables"`
+	Warnings  []string  `json:"warnings,omitempty"` // items that could not be backed up
+}
+
+// File config file or script stored in a backup
+type File struct {
+	Kind string `json:"kind"` // hooks, version, users or script
+	Path string `json:"path"` // path on the backed up instance
+	Name string `json:"name"` // entry name in the archive
+	Size int    `json:"size"`
+}
+
+// Table database table stored in a backup
+type Table struct {
+	Name string `json:"name"`
+	Rows int    `json:"rows"`
+}
+
+// projectEnv decrypted .env of a project, encrypted again with the key of the restoring instance
+type projectEnv struct {
+	ProjectName string `json:"project_name"`
+	Content     string `json:"content"`
+}
+
+// Create write an encrypted backup of the current configuration to w
+func Create(w io.Writer, passphrase string) (*Manifest, error) {
+	if len(passphrase) < MinPassphraseLength {
+		return nil, fmt.Errorf("passphrase must be at least %d characters", MinPassphraseLength)
+	}
+	m := &Manifest{Format: formatVersion, CreatedAt: time.Now(), Files: []File{}, Tables: []Table{}}
+	var entries []entry
+	addFile := func(kind, path string, data []byte, mode os.FileMode) {
+		name := fmt.Sprintf("files/%d", len(m.Files))
+		m.Files = append(m.Files, File{Kind: kind, Path: path, Name: name, Size: len(data)})
+		entries = append(entries, entry{name: name, mode: int64(mode.Perm()), data: data})
+	}
+
+	if webhook.HookManager != nil {
+		for _, path := range webhook.HookManager.HooksFiles {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				m.Warnings = append(m.Warnings, fmt.Sprintf("hooks file %s: %v", path, err))
+				continue
+			}
+			addFile(KindHooks, path, data, 0644)
+		}
+	}
+	for _, f := range []File{{Kind: KindVersion, Path: versionFile}, {Kind: KindUsers, Path: usersFile}} {
+		if data, err := os.ReadFile(f.Path); err == nil {
+			addFile(f.Kind, f.Path, data, 0644)
+		} else if !os.IsNotExist(err) {
+			m.Warnings = append(m.Warnings, fmt.Sprintf("%s: %v", f.Path, err))
+		}
+	}
+	for _, path := range hookScripts() {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue // inline commands and missing scripts
+		}
+		if info.Size() > maxScriptSize {
+			m.Warnings = append(m.Warnings, fmt.Sprintf("script %s: larger than %d bytes", path, maxScriptSize))
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			m.Warnings = append(m.Warnings, fmt.Sprintf("script %s: %v", path, err))
+			continue
+		}
+		if bytes.IndexByte(data, 0) >= 0 {
+			continue // binaries such as /usr/bin/git are not managed scripts
+		}
+		addFile(KindScript, path, data, info.Mode())
+	}
+
+	if db := database.GetDB(); db != nil {
+		envs, warnings, err := exportProjectEnvs(db)
+		if err != nil {
+			return nil, err
+		}
+		m.Warnings = append(m.Warnings, warnings...)
+		var nodes []database.SyncNode
+		if err := db.Order("id").Find(&nodes).Error; err != nil {
+			return nil, fmt.Errorf("read sync nodes: %w", err)
+		}
+		addTable := func(name string, rows interface{}, count int) error {
+			data, err := json.Marshal(rows)
+			if err != nil {
+				return err
+			}
+			m.Tables = append(m.Tables, Table{Name: name, Rows: count})
+			entries = append(entries, entry{name: "tables/" + name + ".json", mode: 0600, data: data})
+			return nil
+		}
+		if err := addTable(TableProjectEnvs, envs, len(envs)); err != nil {
+			return nil, err
+		}
+		if err := addTable(TableSyncNodes, nodes, len(nodes)); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	entries = append([]entry{{name: manifestName, mode: 0600, data: data}}, entries...)
+	if err := seal(w, passphrase, entries); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// hookScripts absolute paths of the commands run by the loaded hooks
+func hookScripts() []string {
+	if webhook.HookManager == nil {
+		return nil
+	}
+	return scriptPaths(webhook.HookManager.GetAllHooks())
+}
+
+// scriptPaths absolute paths of the commands run by hooks
+func scriptPaths(hooks []webhook.Hook) []string {
+	seen := map[string]bool{}
+	var paths []string
+	for _, h := range hooks {
+		if h.ExecuteCommand == "" || h.Forward != nil {
+			continue
+		}
+		path := h.ExecuteCommand
+		if !filepath.IsAbs(path) && h.CommandWorkingDirectory != "" {
+			path = filepath.Join(h.CommandWorkingDirectory, path)
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func exportProjectEnvs(db *gorm.DB) ([]projectEnv, []string, error) {
+	var rows []database.ProjectEnv
+	if err := db.Order("project_name").Find(&rows).Error; err != nil {
+		return nil, nil, fmt.Errorf("read project envs: %w", err)
+	}
+	envs := []projectEnv{}
+	var warnings []string
+	for _, row := range rows {
+		content, err := env.DecryptValue(row.Content)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("env of project %s: %v", row.ProjectName, err))
+			continue
+		}
+		envs = append(envs, projectEnv{ProjectName: row.ProjectName, Content: content})
+	}
+	return envs, warnings, nil
+}
+
+// Inspect decrypt a backup and return its manifest
+func Inspect(data []byte, passphrase string) (*Manifest, error) {
+	_, m, err := openBackup(data, passphrase)
+	return m, err
+}
+
+func openBackup(data []byte, passphrase string) (map[string]entry, *Manifest, error) {
+	entries, err := open(data, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, ok := entries[manifestName]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: no manifest", ErrInvalid)
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(raw.data, m); err != nil {
+		return nil, nil, fmt.Errorf("%w: manifest: %v", ErrInvalid, err)
+	}
+	if m.Format != formatVersion {
+		return nil, nil, fmt.Errorf("%w: unsupported format %d", ErrInvalid, m.Format)
+	}
+	for _, f := range m.Files {
+		if _, ok := entries[f.Name]; !ok {
+			return nil, nil, fmt.Errorf("%w: missing %s", ErrInvalid, f.Path)
+		}
+	}
+	return entries, m, nil
+}
+
+// RestoreResult what Restore changed
+type RestoreResult struct {
+	Manifest *Manifest `json:"manifest"`
+	Restored []string  `json:"restored"`          // restored files and tables
+	Skipped  []string  `json:"skipped,omitempty"` // items left unchanged, with the reason
+}
+
+// Restore apply a backup written by Create. Config files are validated before anything is
+// written; hooks files that are not loaded by this instance are merged into the loaded ones.
+// Hooks must pass the command checks of the hook API, and only scripts run by the loaded or
+// restored hooks are written.
+func Restore(data []byte, passphrase string) (*RestoreResult, error) {
+	entries, m, err := openBackup(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	res := &RestoreResult{Manifest: m, Restored: []string{}}
+
+	// parse everything first so an invalid backup changes nothing
+	var version *types.VersionConfig
+	var users *types.UsersConfig
+	var hooks []webhook.Hook
+	for _, f := range m.Files {
+		content := entries[f.Name].data
+		switch f.Kind {
+		case KindVersion:
+			version = &types.VersionConfig{}
+			if err := yaml.Unmarshal(content, version); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalid, f.Path, err)
+			}
+		case KindUsers:
+			users = &types.UsersConfig{}
+			if err := yaml.Unmarshal(content, users); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalid, f.Path, err)
+			}
+			if len(users.Users) == 0 {
+				return nil, fmt.Errorf("%w: %s has no users", ErrInvalid, f.Path)
+			}
+		case KindHooks:
+			parsed, err := parseHooks(f.Path, content)
+			if err != nil {
+				return nil, fmt.Errorf("%w: hooks file %s: %v", ErrInvalid, f.Path, err)
+			}
+			for _, h := range parsed {
+				if err := webhook.CheckHookCommand(h); err != nil {
+					return nil, fmt.Errorf("hooks file %s: %w", f.Path, err)
+				}
+			}
+			hooks = append(hooks, parsed...)
+		}
+	}
+
+	// the manifest names the paths scripts are written to, a path no hook runs could be any file
+	scripts := map[string]bool{}
+	for _, path := range append(hookScripts(), scriptPaths(hooks)...) {
+		scripts[path] = true
+	}
+
+	if version != nil {
+		types.GoHookVersionData = version
+		if err := config.SaveVersionConfig(); err != nil {
+			return res, err
+		}
+		res.Restored = append(res.Restored, versionFile)
+	}
+	if users != nil {
+		types.GoHookUsersConfig = users
+		if err := client.SaveUsersConfig(); err != nil {
+			return res, err
+		}
+		res.Restored = append(res.Restored, usersFile)
+	}
+
+	for _, f := range m.Files {
+		content := entries[f.Name].data
+		switch f.Kind {
+		case KindScript:
+			if !scripts[f.Path] {
+				res.Skipped = append(res.Skipped, f.Path+": not run by a hook")
+				continue
+			}
+			if !webhook.ScriptPathAllowed(f.Path) {
+				res.Skipped = append(res.Skipped, f.Path+": outside the script roots")
+				continue
+			}
+			if err := writeFile(f.Path, content, os.FileMode(entries[f.Name].mode)); err != nil {
+				return res, fmt.Errorf("restore script %s: %w", f.Path, err)
+			}
+			res.Restored = append(res.Restored, f.Path)
+		case KindHooks:
+			if err := restoreHooks(f.Path, content); err != nil {
+				return res, err
+			}
+			res.Restored = append(res.Restored, f.Path)
+		}
+	}
+
+	if db := database.GetDB(); db != nil {
+		if err := restoreTables(db, entries, res); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// parseHooks hooks of a hooks file, evaluated as a template when the instance loads templates
+func parseHooks(path string, content []byte) (webhook.Hooks, error) {
+	tmp, err := os.CreateTemp("", "gohook-restore-*"+filepath.Ext(path))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	asTemplate := webhook.HookManager != nil && webhook.HookManager.AsTemplate
+	var hooks webhook.Hooks
+	if err := hooks.LoadFromFile(tmp.Name(), asTemplate); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// restoreHooks write a hooks file loaded by this instance as is, merge the hooks of other files
+func restoreHooks(path string, content []byte) error {
+	if webhook.HookManager == nil {
+		return fmt.Errorf("restore hooks file %s: hooks not loaded", path)
+	}
+	for _, loaded := range webhook.HookManager.HooksFiles {
+		if loaded == path {
+			if err := writeFile(path, content, 0644); err != nil {
+				return fmt.Errorf("restore hooks file %s: %w", path, err)
+			}
+			if err := webhook.HookManager.ReloadHooks(path); err != nil {
+				return fmt.Errorf("reload hooks file %s: %w", path, err)
+			}
+			cluster.ConfigChanged(cluster.ConfigHooks)
+			return nil
+		}
+	}
+	hooks, err := parseHooks(path, content)
+	if err != nil {
+		return err
+	}
+	if _, err := webhook.ImportHooks(hooks, false); err != nil {
+		return fmt.Errorf("import hooks of %s: %w", path, err)
+	}
+	return nil
+}
+
+func restoreTables(db *gorm.DB, entries map[string]entry, res *RestoreResult) error {
+	if raw, ok := entries["tables/"+TableProjectEnvs+".json"]; ok {
+		var envs []projectEnv
+		if err := json.Unmarshal(raw.data, &envs); err != nil {
+			return fmt.Errorf("invalid %s: %w", TableProjectEnvs, err)
+		}
+		for _, e := range envs {
+			encrypted, err := env.EncryptValue(e.Content)
+			if err != nil {
+				return fmt.Errorf("encrypt env of project %s: %w", e.ProjectName, err)
+			}
+			if err := saveProjectEnv(db, e.ProjectName, encrypted); err != nil {
+				return fmt.Errorf("restore env of project %s: %w", e.ProjectName, err)
+			}
+		}
+		res.Restored = append(res.Restored, "table "+TableProjectEnvs+" ("+strconv.Itoa(len(envs))+" rows)")
+	}
+
+	if raw, ok := entries["tables/"+TableSyncNodes+".json"]; ok {
+		var nodes []database.SyncNode
+		if err := json.Unmarshal(raw.data, &nodes); err != nil {
+			return fmt.Errorf("invalid %s: %w", TableSyncNodes, err)
+		}
+		// node ids are referenced by sync tasks, the table is replaced keeping them
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Where("1 = 1").Delete(&database.SyncNode{}).Error; err != nil {
+				return err
+			}
+			if len(nodes) == 0 {
+				return nil
+			}
+			return tx.Create(&nodes).Error
+		})
+		if err != nil {
+			return fmt.Errorf("restore %s: %w", TableSyncNodes, err)
+		}
+		res.Restored = append(res.Restored, "table "+TableSyncNodes+" ("+strconv.Itoa(len(nodes))+" rows)")
+	}
+	return nil
+}
+
+// saveProjectEnv upsert the encrypted env of a project, reviving a deleted row
+func saveProjectEnv(db *gorm.DB, projectName, encrypted string) error {
+	var row database.ProjectEnv
+	err := db.Unscoped().Where("project_name = ?", projectName).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return db.Create(&database.ProjectEnv{ProjectName: projectName, Content: encrypted}).Error
+	}
+	if err != nil {
+		return err
+	}
+	return db.Unscoped().Model(&row).Updates(map[string]interface{}{"content": encrypted, "deleted_at": nil}).Error
+}
+
+// writeFile replace path atomically, creating its directory
+func writeFile(path string, data []byte, mode os.FileMode) error {
+	if mode == 0 {
+		mode = 0644
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".restore"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, mode); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,196 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+func TestSealOpen(t *testing.T) {
+	var buf bytes.Buffer
+	entries := []entry{{name: "a.txt", mode: 0644, data: []byte("hello")}, {name: "dir/b", mode: 0755, data: []byte{}}}
+	if err := seal(&buf, "correct horse", entries); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("hello")) {
+		t.Error("archive is not encrypted")
+	}
+
+	got, err := open(buf.Bytes(), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || string(got["a.txt"].data) != "hello" || got["dir/b"].mode != 0755 {
+		t.Errorf("open() = %+v", got)
+	}
+
+	tampered := append([]byte(nil), buf.Bytes()...)
+	tampered[len(tampered)-1] ^= 1
+	tests := []struct {
+		name       string
+		data       []byte
+		passphrase string
+		want       error
+	}{
+		{"wrong passphrase", buf.Bytes(), "wrong horse", ErrDecrypt},
+		{"tampered", tampered, "correct horse", ErrDecrypt},
+		{"truncated", buf.Bytes()[:len(magic)+saltSize+4], "correct horse", ErrDecrypt},
+		{"not a backup", []byte("PK\x03\x04 some zip file"), "correct horse", ErrInvalid},
+	}
+	for _, tt := range tests {
+		if _, err := open(tt.data, tt.passphrase); !errors.Is(err, tt.want) {
+			t.Errorf("%s: open() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCreateInspect(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.WriteFile(versionFile, []byte("projects: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Create(&bytes.Buffer{}, "short"); err == nil {
+		t.Error("Create() accepted a short passphrase")
+	}
+
+	var buf bytes.Buffer
+	m, err := Create(&buf, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 1 || m.Files[0].Kind != KindVersion || m.Files[0].Path != versionFile {
+		t.Errorf("Create() files = %+v, want only %s", m.Files, versionFile)
+	}
+
+	got, err := Inspect(buf.Bytes(), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Format != formatVersion || len(got.Files) != 1 || !got.CreatedAt.Equal(m.CreatedAt) {
+		t.Errorf("Inspect() = %+v, want %+v", got, m)
+	}
+}
+
+func TestRestoreRejectsInvalidConfig(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	m := []byte(`{"format":1,"files":[{"kind":"version","path":"version.yaml","name":"files/0"},{"kind":"users","path":"user.yaml","name":"files/1"}]}`)
+	var buf bytes.Buffer
+	err = seal(&buf, "passphrase", []entry{
+		{name: manifestName, data: m},
+		{name: "files/0", data: []byte("projects: []\n")},
+		{name: "files/1", data: []byte("users: []\n")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Restore(buf.Bytes(), "passphrase"); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Restore() error = %v, want ErrInvalid", err)
+	}
+	if _, err := os.Stat(versionFile); !os.IsNotExist(err) {
+		t.Error("invalid backup was partially restored")
+	}
+}
+
+// sealFiles backup holding files, the content of each file is in contents at the same index
+func sealFiles(t *testing.T, files []File, contents []string) []byte {
+	t.Helper()
+	entries := make([]entry, 0, len(files)+1)
+	for i := range files {
+		files[i].Name = fmt.Sprintf("files/%d", i)
+		entries = append(entries, entry{name: files[i].Name, mode: 0755, data: []byte(contents[i])})
+	}
+	m, err := json.Marshal(Manifest{Format: formatVersion, Files: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := seal(&buf, "passphrase", append([]entry{{name: manifestName, data: m}}, entries...)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRestoreScripts(t *testing.T) {
+	dir := t.TempDir()
+	hooksFile := filepath.Join(dir, "hooks.json")
+	deploy := filepath.Join(dir, "deploy.sh")
+	cron := filepath.Join(dir, "cron.d", "job")
+	hooksJSON := `[{"id":"deploy","execute-command":"` + deploy + `"}]`
+	if err := os.WriteFile(hooksFile, []byte(hooksJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded := map[string]webhook.Hooks{}
+	webhook.HookManager = webhook.NewHookManager(&loaded, []string{hooksFile}, false)
+	defer func() { webhook.HookManager = nil }()
+	if err := webhook.HookManager.ReloadHooks(hooksFile); err != nil {
+		t.Fatal(err)
+	}
+
+	data := sealFiles(t, []File{
+		{Kind: KindHooks, Path: hooksFile},
+		{Kind: KindScript, Path: deploy},
+		{Kind: KindScript, Path: cron},
+	}, []string{hooksJSON, "#!/bin/sh\necho deploy\n", "* * * * * root sh -c 'id'\n"})
+
+	res, err := Restore(data, "passphrase")
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if _, err := os.Stat(deploy); err != nil {
+		t.Errorf("script of a hook was not restored: %v", err)
+	}
+	if _, err := os.Stat(cron); !os.IsNotExist(err) {
+		t.Errorf("file run by no hook was restored: %v", err)
+	}
+	if want := []string{cron + ": not run by a hook"}; len(res.Skipped) != 1 || res.Skipped[0] != want[0] {
+		t.Errorf("Skipped = %q, want %q", res.Skipped, want)
+	}
+}
+
+func TestRestoreCommandPolicy(t *testing.T) {
+	dir := t.TempDir()
+	types.GoHookAppConfig = &types.AppConfig{Scripts: &types.ScriptsConfig{Roots: []string{dir}}}
+	defer func() { types.GoHookAppConfig = nil }()
+
+	script := filepath.Join(dir, "build.sh")
+	data := sealFiles(t, []File{
+		{Kind: KindScript, Path: script},
+		{Kind: KindHooks, Path: filepath.Join(dir, "hooks.json")},
+	}, []string{
+		"#!/bin/sh\n",
+		`[{"id":"build","execute-command":"` + script + `"},{"id":"wipe","execute-command":"rm -rf /","shell":"bash"}]`,
+	})
+
+	_, err := Restore(data, "passphrase")
+	var denied *webhook.CommandDeniedError
+	if !errors.As(err, &denied) || denied.HookID != "wipe" {
+		t.Fatalf("Restore() error = %v, want a CommandDeniedError of hook wipe", err)
+	}
+	if _, err := os.Stat(script); !os.IsNotExist(err) {
+		t.Error("refused backup was partially restored")
+	}
+}
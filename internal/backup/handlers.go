@@ -0,0 +1,133 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+// PassphraseHeader carries the passphrase encrypting a backup
+const PassphraseHeader = "X-Backup-Passphrase"
+
+// maxUploadSize limits the size of an uploaded backup
+const maxUploadSize = 256 << 20
+
+func logAction(c *gin.Context, action, description string, success bool, details interface{}) {
+	database.LogUserAction(
+		c.GetString("username"),
+		action,
+		"backup",
+		description,
+		middleware.GetClientIP(c),
+		c.GetHeader("User-Agent"),
+		success,
+		details,
+	)
+}
+
+// HandleBackup download an encrypted backup of the configuration
+func HandleBackup(c *gin.Context) {
+	passphrase := c.GetHeader(PassphraseHeader)
+	if len(passphrase) < MinPassphraseLength {
+		i18n.JSONErrorf(c, http.StatusBadRequest, i18n.CodeShortPassphrase, PassphraseHeader, MinPassphraseLength)
+		return
+	}
+
+	var buf bytes.Buffer
+	m, err := Create(&buf, passphrase)
+	if err != nil {
+		logAction(c, database.UserActionBackupConfig, "create backup", false, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeCreateBackupFailed, err)
+		return
+	}
+	logAction(c, database.UserActionBackupConfig, "create backup", true, gin.H{"files": len(m.Files), "tables": m.Tables, "warnings": m.Warnings})
+
+	name := "gohook-backup-" + m.CreatedAt.Format("20060102-150405") + ".bin"
+	c.Header("Content-Disposition", "attachment; filename="+name)
+	c.Header("X-Backup-Files", strconv.Itoa(len(m.Files)))
+	c.Header("X-Backup-Warnings", strconv.Itoa(len(m.Warnings)))
+	c.Data(http.StatusOK, "application/octet-stream", buf.Bytes())
+}
+
+// HandleRestore restore an uploaded backup, the archive is the request body or the
+// "file" field of a multipart form. ?dry_run=true only returns the manifest.
+func HandleRestore(c *gin.Context) {
+	passphrase := c.GetHeader(PassphraseHeader)
+	if passphrase == "" {
+		i18n.JSONErrorf(c, http.StatusBadRequest, i18n.CodePassphraseRequired, PassphraseHeader)
+		return
+	}
+
+	data, err := readUpload(c)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeReadBackupFailed, err)
+		return
+	}
+
+	if dryRun, _ := strconv.ParseBool(c.Query("dry_run")); dryRun {
+		m, err := Inspect(data, passphrase)
+		if err != nil {
+			i18n.JSONError(c, restoreStatus(err), i18n.CodeInspectBackupFailed, err)
+			return
+		}
+		c.JSON(http.StatusOK, RestoreResult{Manifest: m, Restored: []string{}})
+		return
+	}
+
+	start := time.Now()
+	res, err := Restore(data, passphrase)
+	if webhook.DenyImportedCommand(c, err, "restore_backup") {
+		return
+	}
+	if err != nil {
+		details := gin.H{"error": err.Error()}
+		if res != nil {
+			details["restored"] = res.Restored // a failure after the first write leaves a partial restore
+		}
+		logAction(c, database.UserActionRestoreConfig, "restore backup", false, details)
+		c.JSON(restoreStatus(err), gin.H{"error": i18n.Message(c, i18n.CodeRestoreBackupFailed) + ": " + err.Error(), "code": i18n.CodeRestoreBackupFailed, "result": res})
+		return
+	}
+	logAction(c, database.UserActionRestoreConfig, "restore backup", true, gin.H{
+		"created_at": res.Manifest.CreatedAt,
+		"restored":   res.Restored,
+		"skipped":    res.Skipped,
+		"duration":   time.Since(start).String(),
+	})
+	c.JSON(http.StatusOK, res)
+}
+
+func readUpload(c *gin.Context) ([]byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+	return io.ReadAll(c.Request.Body)
+}
+
+// restoreStatus 400 for archives that cannot be read, 500 otherwise
+func restoreStatus(err error) int {
+	if errors.Is(err, ErrDecrypt) || errors.Is(err, ErrInvalid) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
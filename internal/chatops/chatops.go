@@ -0,0 +1,441 @@
+// Package chatops answers Slack and Mattermost slash commands such as "/deploy myapp v1.2.3" and
+// the commands of the Telegram bot. Commands are sent to the panel API in-process with the
+// permissions of the gohook user the chat user is mapped to, so namespaces, admin checks,
+// protection rules and audit logs apply unchanged.
+package chatops
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/client"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/urls"
+)
+
+// chat platforms
+const (
+	platformSlack      = "slack"
+	platformMattermost = "mattermost"
+	platformTelegram   = "telegram"
+)
+
+// reply types of slash command answers
+const (
+	replyEphemeral = "ephemeral"
+	replyInChannel = "in_channel"
+)
+
+const (
+	maxCommandBody = 64 << 10        // slash command requests are small forms
+	maxSlackSkew   = 5 * time.Minute // older Slack requests are rejected as replays
+	maxReplyOutput = 1500            // characters of command output quoted in a reply
+)
+
+var (
+	errNotSigned = errors.New("request is not signed by Slack or Mattermost")
+	replyClient  = &http.Client{Timeout: 10 * time.Second}
+
+	// now current time, replaced in tests
+	now = time.Now
+)
+
+var apiEndpoint struct {
+	handler http.Handler
+}
+
+// SetAPIHandler install the handler serving the panel API, commands are dispatched to it
+func SetAPIHandler(handler http.Handler) {
+	apiEndpoint.handler = handler
+}
+
+// reply answer of a slash command, understood by Slack and Mattermost
+type reply struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// command parsed slash command
+type command struct {
+	platform    string
+	verb        string
+	args        []string
+	force       bool
+	line        string // command as typed, for messages
+	userID      string
+	userName    string
+	responseURL string
+}
+
+// HandleSlashCommand verify a Slack or Mattermost slash command, run it as the mapped gohook
+// user and answer with the result. With a response_url the command is acknowledged at once
+// and the result posted to the channel when it is done.
+func HandleSlashCommand(c *gin.Context) {
+	var cfg *types.ChatOpsConfig
+	if types.GoHookAppConfig != nil {
+		cfg = types.GoHookAppConfig.ChatOps
+	}
+	if cfg == nil {
+		c.String(http.StatusNotFound, "ChatOps is not configured")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCommandBody))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid request")
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid request")
+		return
+	}
+	platform, err := verify(cfg, c.Request.Header, body, form)
+	if err != nil {
+		log.Printf("chatops: rejected request from %s: %v", middleware.GetClientIP(c), err)
+		c.String(http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	cmd, err := parseCommand(platform, form)
+	if err != nil {
+		c.JSON(http.StatusOK, reply{ResponseType: replyEphemeral, Text: err.Error()})
+		return
+	}
+	mapping := lookupUser(cfg, cmd.userID)
+	if mapping == nil {
+		c.JSON(http.StatusOK, reply{ResponseType: replyEphemeral, Text: fmt.Sprintf("Your chat account (%s) is not linked to a GoHook user.", cmd.userID)})
+		return
+	}
+	if len(mapping.Commands) > 0 && !slices.Contains(mapping.Commands, cmd.verb) {
+		c.JSON(http.StatusOK, reply{ResponseType: replyEphemeral, Text: fmt.Sprintf("You are not allowed to %s.", cmd.verb)})
+		return
+	}
+	user := client.FindUser(mapping.User)
+	if user == nil {
+		c.JSON(http.StatusOK, reply{ResponseType: replyEphemeral, Text: fmt.Sprintf("GoHook user %s does not exist.", mapping.User)})
+		return
+	}
+
+	ex := &executor{cmd: cmd, user: user, panel: panelURL(cfg, c), clientIP: middleware.GetClientIP(c)}
+	log.Printf("chatops: %s (%s as %s) runs %q", cmd.userName, cmd.platform, user.Username, cmd.line)
+	if cmd.responseURL == "" {
+		c.JSON(http.StatusOK, ex.run())
+		return
+	}
+	go func() {
+		if err := postReply(cmd.responseURL, ex.run()); err != nil {
+			log.Printf("chatops: posting the result of %q failed: %v", cmd.line, err)
+		}
+	}()
+	c.JSON(http.StatusOK, reply{ResponseType: replyInChannel, Text: fmt.Sprintf("%s runs `%s` …", cmd.userName, cmd.line)})
+}
+
+// lookupUser mapping of a chat user id
+func lookupUser(cfg *types.ChatOpsConfig, id string) *types.ChatOpsUser {
+	for i := range cfg.Users {
+		if cfg.Users[i].Chat == id {
+			return &cfg.Users[i]
+		}
+	}
+	return nil
+}
+
+// verify check the Slack signature or the Mattermost token of a slash command and return the
+// platform that sent it
+func verify(cfg *types.ChatOpsConfig, header http.Header, body []byte, form url.Values) (string, error) {
+	if signature := header.Get("X-Slack-Signature"); signature != "" {
+		if cfg.SlackSigningSecret == "" {
+			return "", errors.New("Slack commands are not configured")
+		}
+		ts := header.Get("X-Slack-Request-Timestamp")
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return "", errors.New("invalid Slack request timestamp")
+		}
+		if skew := now().Sub(time.Unix(sec, 0)); skew > maxSlackSkew || skew < -maxSlackSkew {
+			return "", errors.New("Slack request timestamp is too old")
+		}
+		mac := hmac.New(sha256.New, []byte(cfg.SlackSigningSecret))
+		mac.Write([]byte("v0:" + ts + ":"))
+		mac.Write(body)
+		if !hmac.Equal([]byte(signature), []byte("v0="+hex.EncodeToString(mac.Sum(nil)))) {
+			return "", errors.New("invalid Slack signature")
+		}
+		return platformSlack, nil
+	}
+	if token := form.Get("token"); token != "" {
+		for _, t := range cfg.MattermostTokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return platformMattermost, nil
+			}
+		}
+	}
+	return "", errNotSigned
+}
+
+// parseCommand command and arguments of a slash command. The command name is the operation
+// (/deploy, /run, /promote), any other command name takes it from the first word of the text,
+// e.g. /gohook deploy myapp v1.2.3.
+func parseCommand(platform string, form url.Values) (*command, error) {
+	cmd := &command{
+		platform:    platform,
+		userID:      form.Get("user_id"),
+		userName:    form.Get("user_name"),
+		responseURL: form.Get("response_url"),
+	}
+	words := strings.Fields(form.Get("text"))
+	cmd.verb = strings.TrimPrefix(form.Get("command"), "/")
+	switch cmd.verb {
+	case types.ChatOpsDeploy, types.ChatOpsRun, types.ChatOpsPromote:
+	default:
+		if len(words) == 0 {
+			return nil, errors.New(usage)
+		}
+		cmd.verb, words = words[0], words[1:]
+	}
+	for _, w := range words {
+		if w == "--force" && cmd.verb == types.ChatOpsDeploy {
+			cmd.force = true
+			continue
+		}
+		cmd.args = append(cmd.args, w)
+	}
+	cmd.line = strings.TrimSpace(cmd.verb + " " + strings.Join(words, " "))
+
+	switch {
+	case cmd.verb == types.ChatOpsDeploy && len(cmd.args) != 2,
+		cmd.verb == types.ChatOpsRun && len(cmd.args) == 0,
+		cmd.verb == types.ChatOpsPromote && len(cmd.args) != 2:
+		return nil, errors.New(usage)
+	case cmd.verb != types.ChatOpsDeploy && cmd.verb != types.ChatOpsRun && cmd.verb != types.ChatOpsPromote:
+		return nil, errors.New(usage)
+	}
+	return cmd, nil
+}
+
+const usage = "Usage:\n" +
+	"• `deploy <project> <tag or branch> [--force]` switch a project to a tag or branch\n" +
+	"• `run <hook> [args...]` run a hook, the words after the hook id are passed as arguments\n" +
+	"• `promote <project> <from project>` promote the revision deployed in another project"
+
+// panelURL public URL of the panel, used in links
+func panelURL(cfg *types.ChatOpsConfig, c *gin.Context) string {
+	if cfg.PanelURL != "" {
+		return strings.TrimSuffix(cfg.PanelURL, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + urls.Current().BasePath
+}
+
+// executor runs a command as a gohook user
+type executor struct {
+	cmd      *command
+	user     *types.UserConfig
+	panel    string
+	clientIP string
+}
+
+// apiAnswer fields of the panel API answers the commands use
+type apiAnswer struct {
+	Message string `json:"message"`
+	Error   string `json:"error"`
+	Output  string `json:"output"`
+	LogID   uint   `json:"logId"`
+	Tags    []struct {
+		Name string `json:"name"`
+	} `json:"tags"`
+	Promotion *struct {
+		Ref        string `json:"ref"`
+		CommitHash string `json:"commit_hash"`
+	} `json:"promotion"`
+}
+
+func (e *executor) run() reply {
+	switch e.cmd.verb {
+	case types.ChatOpsDeploy:
+		return e.deploy()
+	case types.ChatOpsRun:
+		return e.runHook()
+	default:
+		return e.promote()
+	}
+}
+
+// deploy switch a project to a tag, or to a branch when no tag has that name
+func (e *executor) deploy() reply {
+	project, ref := e.cmd.args[0], e.cmd.args[1]
+	base := "/version/" + url.PathEscape(project)
+
+	var tags apiAnswer
+	status, err := e.call(http.MethodGet, base+"/tags?limit=1000&filter="+url.QueryEscape(ref), nil, &tags)
+	if err != nil || status != http.StatusOK {
+		return e.failed(fmt.Sprintf("Deploy of `%s` failed", project), status, err, tags)
+	}
+	kind, page, path, body := "branch", "branches", base+"/switch-branch", map[string]interface{}{"branch": ref, "force": e.cmd.force}
+	for _, t := range tags.Tags {
+		if t.Name == ref {
+			kind, page, path, body = "tag", "tags", base+"/switch-tag", map[string]interface{}{"tag": ref, "force": e.cmd.force}
+			break
+		}
+	}
+
+	var answer apiAnswer
+	status, err = e.call(http.MethodPost, path, body, &answer)
+	if err != nil || status != http.StatusOK {
+		return e.failed(fmt.Sprintf("Deploy of %s `%s` to `%s` failed", kind, ref, project), status, err, answer)
+	}
+	return e.done(fmt.Sprintf(":white_check_mark: %s deployed %s `%s` to `%s`. %s", e.cmd.userName, kind, ref, project,
+		e.link("#/versions/"+url.PathEscape(project)+"/"+page, "Project")))
+}
+
+// runHook run a hook with the remaining words as arguments
+func (e *executor) runHook() reply {
+	hook, args := e.cmd.args[0], e.cmd.args[1:]
+	var answer apiAnswer
+	status, err := e.call(http.MethodPost, "/hook/"+url.PathEscape(hook)+"/trigger", map[string]interface{}{"args": args}, &answer)
+	if err != nil || (status != http.StatusOK && answer.LogID == 0) {
+		return e.failed(fmt.Sprintf("Hook `%s` failed", hook), status, err, answer)
+	}
+
+	var b strings.Builder
+	if status == http.StatusOK {
+		fmt.Fprintf(&b, ":white_check_mark: %s ran hook `%s`.", e.cmd.userName, hook)
+	} else {
+		fmt.Fprintf(&b, ":x: Hook `%s` run by %s failed: %s.", hook, e.cmd.userName, answer.Error)
+	}
+	fmt.Fprintf(&b, " %s", e.link("#/logs", fmt.Sprintf("Execution log #%d", answer.LogID)))
+	if output := strings.TrimSpace(answer.Output); output != "" {
+		if len(output) > maxReplyOutput {
+			output = "…" + output[len(output)-maxReplyOutput:]
+		}
+		fmt.Fprintf(&b, "\n```\n%s\n```", strings.ReplaceAll(output, "```", "'''"))
+	}
+	return e.done(b.String())
+}
+
+// promote promote the revision of another project
+func (e *executor) promote() reply {
+	project, from := e.cmd.args[0], e.cmd.args[1]
+	var answer apiAnswer
+	status, err := e.call(http.MethodPost, "/version/"+url.PathEscape(project)+"/promote", map[string]string{"from": from}, &answer)
+	if err != nil || (status != http.StatusOK && status != http.StatusAccepted) {
+		return e.failed(fmt.Sprintf("Promotion of `%s` to `%s` failed", from, project), status, err, answer)
+	}
+	ref := ""
+	if answer.Promotion != nil {
+		ref = " " + answer.Promotion.Ref
+		if answer.Promotion.Ref != answer.Promotion.CommitHash && len(answer.Promotion.CommitHash) >= 7 {
+			ref += " (" + answer.Promotion.CommitHash[:7] + ")"
+		}
+	}
+	link := e.link("#/versions", "Projects")
+	if status == http.StatusAccepted {
+		return e.done(fmt.Sprintf(":hourglass: Promotion of `%s`%s to `%s` requested by %s waits for approval. %s", from, ref, project, e.cmd.userName, link))
+	}
+	return e.done(fmt.Sprintf(":white_check_mark: %s promoted `%s`%s to `%s`. %s", e.cmd.userName, from, ref, project, link))
+}
+
+// call send a panel API request as the mapped user and decode the JSON answer into out
+func (e *executor) call(method, path string, body interface{}, out interface{}) (int, error) {
+	if apiEndpoint.handler == nil {
+		return 0, errors.New("panel API is not available")
+	}
+	token, err := client.GenerateToken(e.user.Username, e.user.Role, e.user.Namespace)
+	if err != nil {
+		return 0, err
+	}
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GoHook-Key", token)
+	req.Header.Set("User-Agent", "gohook-chatops/"+e.cmd.platform)
+	req.RemoteAddr = e.clientIP + ":0"
+	rec := httptest.NewRecorder()
+	apiEndpoint.handler.ServeHTTP(rec, req)
+	if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil && rec.Code < 300 {
+		return rec.Code, fmt.Errorf("unexpected answer: %v", err)
+	}
+	return rec.Code, nil
+}
+
+// failed reply for a failed API call
+func (e *executor) failed(what string, status int, err error, answer apiAnswer) reply {
+	reason := answer.Error
+	switch {
+	case err != nil:
+		reason = err.Error()
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		reason = fmt.Sprintf("permission denied for GoHook user %s", e.user.Username)
+	case status == http.StatusNotFound && reason == "":
+		reason = "not found"
+	case reason == "":
+		reason = fmt.Sprintf("HTTP %d", status)
+	}
+	return e.done(fmt.Sprintf(":x: %s: %s", what, reason))
+}
+
+func (e *executor) done(text string) reply {
+	return reply{ResponseType: replyInChannel, Text: text}
+}
+
+// link markup of a link to a panel page
+func (e *executor) link(page, text string) string {
+	return panelLink(e.cmd.platform, e.panel, page, text)
+}
+
+// panelLink markup of a link to a panel page, just the text when the panel URL is unknown
+func panelLink(platform, panel, page, text string) string {
+	if panel == "" {
+		return text
+	}
+	target := panel + "/" + page
+	if platform == platformSlack {
+		return "<" + target + "|" + text + ">"
+	}
+	return "[" + text + "](" + target + ")"
+}
+
+// postReply send the result of a command to the response_url of the slash command
+func postReply(responseURL string, r reply) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	resp, err := replyClient.Post(responseURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", responseURL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package chatops
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/client"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func slackSign(secret, ts string, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+	fixed := time.Unix(1760000000, 0)
+	now = func() time.Time { return fixed }
+	defer func() { now = time.Now }()
+
+	cfg := &types.ChatOpsConfig{SlackSigningSecret: "s3cret", MattermostTokens: []string{"mm-token"}}
+	body := "command=%2Fdeploy&text=app+v1"
+	ts := fmt.Sprint(fixed.Unix())
+	old := fmt.Sprint(fixed.Add(-10 * time.Minute).Unix())
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		body     string
+		platform string
+	}{
+		{"slack", map[string]string{"X-Slack-Signature": slackSign("s3cret", ts, body), "X-Slack-Request-Timestamp": ts}, body, platformSlack},
+		{"slack wrong secret", map[string]string{"X-Slack-Signature": slackSign("other", ts, body), "X-Slack-Request-Timestamp": ts}, body, ""},
+		{"slack replayed", map[string]string{"X-Slack-Signature": slackSign("s3cret", old, body), "X-Slack-Request-Timestamp": old}, body, ""},
+		{"slack body changed", map[string]string{"X-Slack-Signature": slackSign("s3cret", ts, body), "X-Slack-Request-Timestamp": ts}, body + "x", ""},
+		{"mattermost", nil, body + "&token=mm-token", platformMattermost},
+		{"mattermost wrong token", nil, body + "&token=nope", ""},
+		{"unsigned", nil, body, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tt.headers {
+				header.Set(k, v)
+			}
+			form, _ := url.ParseQuery(tt.body)
+			platform, err := verify(cfg, header, []byte(tt.body), form)
+			if platform != tt.platform || (err == nil) != (tt.platform != "") {
+				t.Fatalf("verify = %q, %v, want %q", platform, err, tt.platform)
+			}
+		})
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		command, text string
+		verb          string
+		args          []string
+		force         bool
+	}{
+		{"/deploy", "myapp v1.2.3", "deploy", []string{"myapp", "v1.2.3"}, false},
+		{"/gohook", "deploy myapp main --force", "deploy", []string{"myapp", "main"}, true},
+		{"/run", "build a --force", "run", []string{"build", "a", "--force"}, false},
+		{"/gohook", "promote prod staging", "promote", []string{"prod", "staging"}, false},
+		{"/deploy", "myapp", "", nil, false},
+		{"/gohook", "help", "", nil, false},
+		{"/gohook", "", "", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command+" "+tt.text, func(t *testing.T) {
+			cmd, err := parseCommand(platformSlack, url.Values{"command": {tt.command}, "text": {tt.text}})
+			if tt.verb == "" {
+				if err == nil {
+					t.Fatalf("expected usage error, got %+v", cmd)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cmd.verb != tt.verb || strings.Join(cmd.args, " ") != strings.Join(tt.args, " ") || cmd.force != tt.force {
+				t.Fatalf("got %s %v force=%v", cmd.verb, cmd.args, cmd.force)
+			}
+		})
+	}
+}
+
+// TestHandleSlashCommand run commands against a fake panel API and check the permissions and replies
+func TestHandleSlashCommand(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	savedApp, savedUsers := types.GoHookAppConfig, types.GoHookUsersConfig
+	defer func() { types.GoHookAppConfig, types.GoHookUsersConfig = savedApp, savedUsers }()
+	types.GoHookAppConfig = &types.AppConfig{JWTSecret: "jwt", JWTExpiryDuration: 5, ChatOps: &types.ChatOpsConfig{
+		MattermostTokens: []string{"mm-token"},
+		PanelURL:         "https://gohook.example.com/",
+		Users: []types.ChatOpsUser{
+			{Chat: "u-dev", User: "dev", Commands: []string{types.ChatOpsRun}},
+			{Chat: "u-ops", User: "ops"},
+			{Chat: "u-gone", User: "gone"},
+		},
+	}}
+	types.GoHookUsersConfig = &types.UsersConfig{Users: []types.UserConfig{
+		{Username: "dev", Role: "user", Namespace: "team-a"},
+		{Username: "ops", Role: "admin"},
+	}}
+
+	var calls []string
+	SetAPIHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := client.ValidateToken(r.Header.Get("X-GoHook-Key"))
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		calls = append(calls, fmt.Sprintf("%s %s %s %s%s", claims.Username, claims.Namespace, r.Method, r.URL.RequestURI(), body))
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/tags"):
+			io.WriteString(w, `{"tags":[{"name":"v1.2.3"},{"name":"v1.2.30"}]}`)
+		case strings.HasSuffix(r.URL.Path, "/switch-tag"):
+			io.WriteString(w, `{"message":"Switched"}`)
+		case r.URL.Path == "/hook/build/trigger":
+			io.WriteString(w, `{"message":"ok","output":"built ok\n","logId":42}`)
+		case strings.HasSuffix(r.URL.Path, "/promote"):
+			w.WriteHeader(http.StatusAccepted)
+			io.WriteString(w, `{"message":"Promotion is waiting for approval","promotion":{"ref":"v2","commit_hash":"0123456789abcdef"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error":"Hook not found"}`)
+		}
+	}))
+	defer SetAPIHandler(nil)
+
+	tests := []struct {
+		name, user, command, text string
+		calls                     []string
+		reply                     []string
+	}{
+		{"run as namespaced user", "u-dev", "/run", "build --fast",
+			[]string{`dev team-a POST /hook/build/trigger{"args":["--fast"]}`},
+			[]string{"ran hook `build`", "[Execution log #42](https://gohook.example.com/#/logs)", "```\nbuilt ok\n```"}},
+		{"command not allowed", "u-dev", "/deploy", "app v1.2.3", nil, []string{"not allowed to deploy"}},
+		{"deploy tag", "u-ops", "/gohook", "deploy app v1.2.3",
+			[]string{"ops  GET /version/app/tags?limit=1000&filter=v1.2.3", `ops  POST /version/app/switch-tag{"force":false,"tag":"v1.2.3"}`},
+			[]string{"deployed tag `v1.2.3` to `app`", "(https://gohook.example.com/#/versions/app/tags)"}},
+		{"promote waits for approval", "u-ops", "/promote", "prod staging",
+			[]string{`ops  POST /version/prod/promote{"from":"staging"}`}, []string{"`staging` v2 (0123456) to `prod`", "waits for approval"}},
+		{"unknown hook", "u-ops", "/run", "missing", []string{`ops  POST /hook/missing/trigger{"args":[]}`}, []string{":x: Hook `missing` failed: Hook not found"}},
+		{"unlinked chat user", "u-nobody", "/run", "build", nil, []string{"not linked"}},
+		{"unknown gohook user", "u-gone", "/run", "build", nil, []string{"GoHook user gone does not exist"}},
+	}
+	r := gin.New()
+	r.POST("/chatops", HandleSlashCommand)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			form := url.Values{"token": {"mm-token"}, "user_id": {tt.user}, "user_name": {"alice"}, "command": {tt.command}, "text": {tt.text}}
+			req := httptest.NewRequest(http.MethodPost, "/chatops", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var got reply
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(calls, "\n") != strings.Join(tt.calls, "\n") {
+				t.Errorf("API calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(tt.calls, "\n"))
+			}
+			for _, part := range tt.reply {
+				if !strings.Contains(got.Text, part) {
+					t.Errorf("reply %q does not contain %q", got.Text, part)
+				}
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/chatops", strings.NewReader("token=nope&command=%2Frun&text=build"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned request answered %d", w.Code)
+	}
+}
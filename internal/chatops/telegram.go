@@ -0,0 +1,486 @@
+package chatops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mycoool/gohook/internal/client"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/stream"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+const (
+	telegramAPI         = "https://api.telegram.org"
+	telegramPollTimeout = 30 // seconds a getUpdates request waits for new messages
+	telegramAlertGap    = time.Minute
+	telegramAlertQueue  = 100
+)
+
+// chat commands besides the ones of TelegramChat.Commands
+const (
+	telegramStart   = "start"
+	telegramHelp    = "help"
+	telegramPending = "pending" // needs ChatOpsApprove
+	telegramReject  = "reject"  // needs ChatOpsApprove
+)
+
+var (
+	telegramClient = &http.Client{Timeout: (telegramPollTimeout + 10) * time.Second}
+
+	// telegram emoji of the Slack shortcodes used in replies
+	telegramEmoji = strings.NewReplacer(":white_check_mark:", "✅", ":x:", "❌", ":hourglass:", "⏳", ":warning:", "⚠️")
+
+	// telegramRetry wait after a failed getUpdates request, replaced in tests
+	telegramRetry = 10 * time.Second
+)
+
+const telegramUsage = "Commands:\n" +
+	"/projects - projects with their current branch or tag\n" +
+	"/run <hook> [args...] - run a hook\n" +
+	"/pending - promotions waiting for approval\n" +
+	"/approve <id> or /reject <id> - decide a pending promotion"
+
+// telegramUpdate update returned by getUpdates, only messages are requested
+type telegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	From *struct {
+		Username  string `json:"username"`
+		FirstName string `json:"first_name"`
+	} `json:"from"`
+	Text string `json:"text"`
+}
+
+// telegramConfig Telegram bot configuration and the panel URL used in links, nil when the bot is off
+func telegramConfig() (*types.TelegramConfig, string) {
+	if types.GoHookAppConfig == nil || types.GoHookAppConfig.Notifications == nil {
+		return nil, ""
+	}
+	n := types.GoHookAppConfig.Notifications
+	return n.Telegram, strings.TrimSuffix(n.PanelURL, "/")
+}
+
+// StartTelegram answer the commands sent to the Telegram bot until ctx is done, registered as a
+// leader task because Telegram hands every update to one getUpdates poller only. Configuration
+// changes are picked up with the next poll.
+func StartTelegram(ctx context.Context) {
+	var offset int64
+	for ctx.Err() == nil {
+		cfg, panel := telegramConfig()
+		if cfg == nil {
+			sleepContext(ctx, time.Minute)
+			continue
+		}
+		var updates []telegramUpdate
+		err := telegramCall(ctx, cfg, "getUpdates", map[string]interface{}{
+			"offset":          offset,
+			"timeout":         telegramPollTimeout,
+			"allowed_updates": []string{"message"},
+		}, &updates)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("telegram: getUpdates failed: %v", err)
+				sleepContext(ctx, telegramRetry)
+			}
+			continue
+		}
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message != nil {
+				handleTelegramMessage(ctx, cfg, panel, u.Message)
+			}
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// handleTelegramMessage run the command of a message and answer in the chat
+func handleTelegramMessage(ctx context.Context, cfg *types.TelegramConfig, panel string, m *telegramMessage) {
+	words := strings.Fields(m.Text)
+	if len(words) == 0 || !strings.HasPrefix(words[0], "/") {
+		return
+	}
+	// "/run@gohook_bot build" in groups, "/approve_12" as a tappable command
+	verb, _, _ := strings.Cut(strings.TrimPrefix(words[0], "/"), "@")
+	args := words[1:]
+	if v, id, ok := strings.Cut(verb, "_"); ok && (v == types.ChatOpsApprove || v == telegramReject) {
+		verb, args = v, append([]string{id}, args...)
+	}
+
+	answer := func(text string) {
+		if err := sendTelegram(ctx, cfg, m.Chat.ID, text); err != nil {
+			log.Printf("telegram: answering chat %d failed: %v", m.Chat.ID, err)
+		}
+	}
+	var chat *types.TelegramChat
+	for i := range cfg.Chats {
+		if cfg.Chats[i].ID == m.Chat.ID {
+			chat = &cfg.Chats[i]
+		}
+	}
+	if chat == nil {
+		answer(fmt.Sprintf("This chat (%d) is not authorized to use GoHook.", m.Chat.ID))
+		return
+	}
+	if verb == telegramStart || verb == telegramHelp {
+		answer(telegramUsage)
+		return
+	}
+
+	permission := verb
+	switch verb {
+	case types.ChatOpsProjects, types.ChatOpsRun, types.ChatOpsApprove:
+	case telegramPending, telegramReject:
+		permission = types.ChatOpsApprove
+	default:
+		answer(telegramUsage)
+		return
+	}
+	if len(chat.Commands) > 0 && !slices.Contains(chat.Commands, permission) {
+		answer(fmt.Sprintf("This chat is not allowed to %s.", permission))
+		return
+	}
+	user := client.FindUser(chat.User)
+	if user == nil {
+		answer(fmt.Sprintf("GoHook user %s does not exist.", chat.User))
+		return
+	}
+
+	cmd := &command{platform: platformTelegram, verb: verb, args: args, line: strings.Join(append([]string{verb}, args...), " "), userName: chat.User}
+	if m.From != nil {
+		cmd.userName = m.From.FirstName
+		if m.From.Username != "" {
+			cmd.userName = "@" + m.From.Username
+		}
+	}
+	ex := &executor{cmd: cmd, user: user, panel: panel, clientIP: "127.0.0.1"}
+	log.Printf("telegram: %s (chat %d as %s) runs %q", cmd.userName, chat.ID, user.Username, cmd.line)
+
+	var r reply
+	switch verb {
+	case types.ChatOpsProjects:
+		r = ex.projects()
+	case types.ChatOpsRun:
+		if len(args) == 0 {
+			answer("Usage: /run <hook> [args...]")
+			return
+		}
+		r = ex.runHook()
+	case telegramPending:
+		r = ex.pending()
+	default:
+		if len(args) != 1 {
+			answer(fmt.Sprintf("Usage: /%s <promotion id>", verb))
+			return
+		}
+		r = ex.decide(args[0], verb == types.ChatOpsApprove)
+	}
+	answer(r.Text)
+}
+
+// projects list the projects the user can see with their current position
+func (e *executor) projects() reply {
+	var projects []types.VersionResponse
+	status, err := e.call(http.MethodGet, "/version", nil, &projects)
+	if err != nil || status != http.StatusOK {
+		return e.failed("Listing projects failed", status, err, apiAnswer{})
+	}
+	if len(projects) == 0 {
+		return e.done("No projects.")
+	}
+	var b strings.Builder
+	for _, p := range projects {
+		position := p.Status
+		switch {
+		case p.Mode == "tag" && p.CurrentTag != "":
+			position = "tag " + p.CurrentTag
+		case p.CurrentBranch != "":
+			position = "branch " + p.CurrentBranch
+		}
+		fmt.Fprintf(&b, "• `%s` %s\n", p.Name, position)
+	}
+	b.WriteString(e.link("#/versions", "Projects"))
+	return e.done(b.String())
+}
+
+// pending list the promotions waiting for approval in the projects the user can see
+func (e *executor) pending() reply {
+	var projects []types.VersionResponse
+	status, err := e.call(http.MethodGet, "/version", nil, &projects)
+	if err != nil || status != http.StatusOK {
+		return e.failed("Listing promotions failed", status, err, apiAnswer{})
+	}
+	var b strings.Builder
+	for _, p := range projects {
+		var promotions []struct {
+			ID            uint   `json:"id"`
+			SourceProject string `json:"source_project"`
+			TargetProject string `json:"target_project"`
+			Ref           string `json:"ref"`
+			Status        string `json:"status"`
+			RequestedBy   string `json:"requested_by"`
+		}
+		if status, err := e.call(http.MethodGet, "/version/"+url.PathEscape(p.Name)+"/promotions", nil, &promotions); err != nil || status != http.StatusOK {
+			continue
+		}
+		for _, pr := range promotions {
+			if pr.Status == "pending" && pr.TargetProject == p.Name {
+				fmt.Fprintf(&b, "#%d `%s` %s → `%s` requested by %s\n/approve_%d  /reject_%d\n",
+					pr.ID, pr.SourceProject, pr.Ref, pr.TargetProject, pr.RequestedBy, pr.ID, pr.ID)
+			}
+		}
+	}
+	if b.Len() == 0 {
+		return e.done("No promotions are waiting for approval.")
+	}
+	return e.done(strings.TrimSpace(b.String()))
+}
+
+// decide approve or reject a pending promotion
+func (e *executor) decide(id string, approve bool) reply {
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return e.done(":x: Invalid promotion id " + id)
+	}
+	action, verb := "approve", "approved"
+	if !approve {
+		action, verb = "reject", "rejected"
+	}
+	var answer apiAnswer
+	status, err := e.call(http.MethodPost, "/version/promotions/"+id+"/"+action, nil, &answer)
+	if err != nil || status != http.StatusOK {
+		return e.failed(fmt.Sprintf("Promotion #%s could not be %s", id, verb), status, err, answer)
+	}
+	what := "#" + id
+	if answer.Promotion != nil {
+		what += " (" + answer.Promotion.Ref + ")"
+	}
+	return e.done(fmt.Sprintf(":white_check_mark: %s %s promotion %s. %s", e.cmd.userName, verb, what, e.link("#/versions", "Projects")))
+}
+
+// telegramCall call a Bot API method and decode its result into out
+func telegramCall(ctx context.Context, cfg *types.TelegramConfig, method string, params interface{}, out interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	api := strings.TrimSuffix(cfg.APIURL, "/")
+	if api == "" {
+		api = telegramAPI
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, api+"/bot"+cfg.BotToken+"/"+method, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := telegramClient.Do(req)
+	if err != nil {
+		// the error quotes the URL, which contains the bot token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%s: %s", method, resp.Status)
+	}
+	if !result.OK {
+		return fmt.Errorf("%s: %s", method, result.Description)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(result.Result, out)
+}
+
+// sendTelegram send a message formatted with Telegram's Markdown, names with unbalanced
+// markup make Telegram refuse it and it is sent again as plain text
+func sendTelegram(ctx context.Context, cfg *types.TelegramConfig, chatID int64, text string) error {
+	text = telegramEmoji.Replace(text)
+	params := map[string]interface{}{"chat_id": chatID, "text": text, "parse_mode": "Markdown", "disable_web_page_preview": true}
+	if err := telegramCall(ctx, cfg, "sendMessage", params, nil); err == nil || !strings.Contains(err.Error(), "can't parse entities") {
+		return err
+	}
+	delete(params, "parse_mode")
+	return telegramCall(ctx, cfg, "sendMessage", params, nil)
+}
+
+// telegramAlert alert about a failure or a promotion waiting for approval
+type telegramAlert struct {
+	kind     string // namespace.KindHook or namespace.KindProject
+	name     string // hook id or project name
+	text     string
+	approval bool   // only for chats whose user may approve promotions
+	topic    string // alerts of another topic on the same hook or project are not held back
+}
+
+var alerts struct {
+	once  sync.Once
+	queue chan telegramAlert
+	mu    sync.Mutex
+	last  map[string]time.Time // time of the last failure alert per hook or project
+}
+
+// TelegramAlert stream listener forwarding failed hook runs, deploys and GitHooks, secret
+// rotations and promotions waiting for approval to the Telegram chats with alerts enabled
+func TelegramAlert(msg stream.WsMessage) {
+	cfg, panel := telegramConfig()
+	if cfg == nil {
+		return
+	}
+	a, ok := alertFor(msg, panel)
+	if !ok {
+		return
+	}
+	if !a.approval {
+		alerts.mu.Lock()
+		key := a.kind + "/" + a.name + "/" + a.topic
+		if time.Since(alerts.last[key]) < telegramAlertGap {
+			alerts.mu.Unlock()
+			return
+		}
+		if alerts.last == nil {
+			alerts.last = map[string]time.Time{}
+		}
+		alerts.last[key] = time.Now()
+		alerts.mu.Unlock()
+	}
+
+	alerts.once.Do(func() {
+		alerts.queue = make(chan telegramAlert, telegramAlertQueue)
+		go func() {
+			for a := range alerts.queue {
+				cfg, _ := telegramConfig()
+				if cfg == nil {
+					continue
+				}
+				for _, id := range alertRecipients(cfg, a) {
+					if err := sendTelegram(context.Background(), cfg, id, a.text); err != nil {
+						log.Printf("telegram: alert to chat %d failed: %v", id, err)
+					}
+				}
+			}
+		}()
+	})
+	select {
+	case alerts.queue <- a:
+	default:
+		log.Printf("telegram: alert queue full, dropped: %s", a.text)
+	}
+}
+
+// alertFor the alert of a broadcast message, ok is false for messages that need none
+func alertFor(msg stream.WsMessage, panel string) (a telegramAlert, ok bool) {
+	link := func(page, text string) string { return panelLink(platformTelegram, panel, page, text) }
+	switch m := msg.Data.(type) {
+	case stream.HookTriggeredMessage:
+		if m.Success {
+			return a, false
+		}
+		a = telegramAlert{kind: namespace.KindHook, name: m.HookID, text: fmt.Sprintf(":x: Hook `%s` failed: %s", m.HookID, m.Error)}
+		if msg.Sealed {
+			// the error is encrypted in the execution log and stays there
+			a.text = fmt.Sprintf(":x: Hook `%s` failed", m.HookID)
+		}
+		if m.LogID != 0 {
+			a.text += "\n" + link("#/logs", fmt.Sprintf("Execution log #%d", m.LogID))
+		}
+	case stream.HookBudgetMessage:
+		a = telegramAlert{kind: namespace.KindHook, name: m.HookID, topic: "budget"}
+		switch m.Event {
+		case "circuit-open":
+			a.text = fmt.Sprintf(":octagonal_sign: Hook `%s` paused by its circuit breaker: %d failures in the last hour (budget %s)", m.HookID, m.Failures, m.Limit)
+			if m.Until != nil {
+				a.text += ", resumes at " + m.Until.UTC().Format(time.RFC3339)
+			}
+		case "circuit-closed":
+			a.text = fmt.Sprintf(":white_check_mark: Circuit breaker of hook `%s` closed by %s", m.HookID, m.By)
+		case "duration":
+			a.text = fmt.Sprintf(":hourglass: Hook `%s` averages %dms in the last hour (budget %s)", m.HookID, m.AverageDurationMs, m.Limit)
+		default:
+			a.text = fmt.Sprintf(":warning: Hook `%s` failed %d times in the last hour (budget %s)", m.HookID, m.Failures, m.Limit)
+		}
+	case stream.VersionSwitchMessage:
+		if m.Success {
+			return a, false
+		}
+		a = telegramAlert{kind: namespace.KindProject, name: m.ProjectName,
+			text: fmt.Sprintf(":x: %s of `%s` to `%s` failed: %s", m.Action, m.ProjectName, m.Target, m.Error)}
+	case stream.GitHookTriggeredMessage:
+		if m.Success || m.Skipped {
+			return a, false
+		}
+		a = telegramAlert{kind: namespace.KindProject, name: m.ProjectName,
+			text: fmt.Sprintf(":x: GitHook of `%s` (%s %s) failed: %s", m.ProjectName, m.Action, m.Target, m.Error)}
+	case stream.SecretRotationMessage:
+		a = telegramAlert{kind: m.Kind, name: m.Name, text: fmt.Sprintf(":key: Previous secret of %s `%s` retired.", m.Kind, m.Name)}
+		if m.Action != "retired" {
+			a.text = fmt.Sprintf(":key: Secret of %s `%s` rotated by %s", m.Kind, m.Name, m.By)
+			if !m.Expires.IsZero() {
+				a.text += ", the previous secret is accepted until " + m.Expires.UTC().Format(time.RFC3339)
+			}
+		}
+	case stream.PromotionRequestMessage:
+		a = telegramAlert{kind: namespace.KindProject, name: m.TargetProject, approval: true,
+			text: fmt.Sprintf(":hourglass: %s requests promoting `%s` %s to `%s`.\n/approve_%d  /reject_%d",
+				m.RequestedBy, m.SourceProject, m.Ref, m.TargetProject, m.ID, m.ID)}
+	default:
+		return a, false
+	}
+	if a.kind == namespace.KindProject {
+		a.text += "\n" + link("#/versions/"+url.PathEscape(a.name)+"/branches", "Project")
+	}
+	return a, true
+}
+
+// alertRecipients chats with alerts enabled whose user can see the hook or project, approval
+// requests only go to chats whose user may approve
+func alertRecipients(cfg *types.TelegramConfig, a telegramAlert) []int64 {
+	ns := namespace.Normalize(namespace.Of(a.kind, a.name))
+	var ids []int64
+	for _, chat := range cfg.Chats {
+		if !chat.Alerts {
+			continue
+		}
+		user := client.FindUser(chat.User)
+		if user == nil || (user.Namespace != "" && user.Namespace != ns) {
+			continue
+		}
+		if a.approval && (user.Role != "admin" || user.Namespace != "" ||
+			(len(chat.Commands) > 0 && !slices.Contains(chat.Commands, types.ChatOpsApprove))) {
+			continue
+		}
+		ids = append(ids, chat.ID)
+	}
+	return ids
+}
@@ -0,0 +1,224 @@
+package chatops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mycoool/gohook/internal/client"
+	"github.com/mycoool/gohook/internal/stream"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// fakeBotAPI Bot API server handing out updates once and recording sent messages
+type fakeBotAPI struct {
+	mu      sync.Mutex
+	updates []telegramUpdate
+	sent    chan map[string]interface{}
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var params map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&params)
+	switch {
+	case r.URL.Path == "/bottok/getUpdates":
+		f.mu.Lock()
+		updates := f.updates
+		f.updates = nil
+		f.mu.Unlock()
+		if len(updates) == 0 {
+			time.Sleep(20 * time.Millisecond)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": updates})
+	case r.URL.Path == "/bottok/sendMessage":
+		if strings.Contains(params["text"].(string), "*") && params["parse_mode"] != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "description": "Bad Request: can't parse entities"})
+			return
+		}
+		f.sent <- params
+		io.WriteString(w, `{"ok":true,"result":{}}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"ok":false,"description":"Not Found"}`)
+	}
+}
+
+func setupTelegram(t *testing.T, apiURL string) {
+	t.Helper()
+	savedApp, savedUsers := types.GoHookAppConfig, types.GoHookUsersConfig
+	t.Cleanup(func() { types.GoHookAppConfig, types.GoHookUsersConfig = savedApp, savedUsers })
+	types.GoHookAppConfig = &types.AppConfig{JWTSecret: "jwt", JWTExpiryDuration: 5, Notifications: &types.NotificationsConfig{
+		PanelURL: "https://gohook.example.com",
+		Telegram: &types.TelegramConfig{BotToken: "tok", APIURL: apiURL, Chats: []types.TelegramChat{
+			{ID: 100, User: "ops", Alerts: true},
+			{ID: 200, User: "dev", Commands: []string{types.ChatOpsRun}, Alerts: true},
+			{ID: 300, User: "viewer", Commands: []string{types.ChatOpsProjects}},
+		}},
+	}}
+	types.GoHookUsersConfig = &types.UsersConfig{Users: []types.UserConfig{
+		{Username: "ops", Role: "admin"},
+		{Username: "dev", Role: "user"},
+		{Username: "viewer", Role: "user", Namespace: "team-a"},
+	}}
+}
+
+func TestTelegramCommands(t *testing.T) {
+	bot := &fakeBotAPI{sent: make(chan map[string]interface{}, 10)}
+	server := httptest.NewServer(bot)
+	defer server.Close()
+	setupTelegram(t, server.URL)
+
+	var calls []string
+	var callsMu sync.Mutex
+	SetAPIHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := client.ValidateToken(r.Header.Get("X-GoHook-Key"))
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		callsMu.Lock()
+		calls = append(calls, fmt.Sprintf("%s %s %s", claims.Username, r.Method, r.URL.Path))
+		callsMu.Unlock()
+		switch r.URL.Path {
+		case "/version":
+			io.WriteString(w, `[{"name":"web","mode":"tag","currentTag":"v1.4.0"},{"name":"api","mode":"branch","currentBranch":"main"}]`)
+		case "/version/web/promotions":
+			io.WriteString(w, `[{"id":7,"source_project":"staging","target_project":"web","ref":"v1.5.0","status":"pending","requested_by":"alice"},
+				{"id":6,"source_project":"staging","target_project":"web","ref":"v1.4.0","status":"success"}]`)
+		case "/version/api/promotions":
+			io.WriteString(w, `[]`)
+		case "/version/promotions/7/approve":
+			if claims.Role != "admin" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			io.WriteString(w, `{"message":"Promoted successfully","promotion":{"ref":"v1.5.0"}}`)
+		case "/hook/build/trigger":
+			io.WriteString(w, `{"message":"ok","output":"done","logId":9}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer SetAPIHandler(nil)
+
+	tests := []struct {
+		name  string
+		chat  int64
+		text  string
+		calls []string
+		reply []string
+	}{
+		{"projects", 100, "/projects", []string{"ops GET /version"}, []string{"• `web` tag v1.4.0", "• `api` branch main", "[Projects](https://gohook.example.com/#/versions)"}},
+		{"pending", 100, "/pending@gohook_bot",
+			[]string{"ops GET /version", "ops GET /version/web/promotions", "ops GET /version/api/promotions"},
+			[]string{"#7 `staging` v1.5.0 → `web` requested by alice", "/approve_7"}},
+		{"tappable approve", 100, "/approve_7", []string{"ops POST /version/promotions/7/approve"}, []string{"✅ @bob approved promotion #7 (v1.5.0)"}},
+		{"run", 200, "/run build", []string{"dev POST /hook/build/trigger"}, []string{"✅ @bob ran hook `build`", "Execution log #9", "done"}},
+		{"command not allowed", 200, "/approve 7", nil, []string{"not allowed to approve"}},
+		{"reject needs approve", 300, "/reject 7", nil, []string{"not allowed to approve"}},
+		{"unknown chat", 999, "/projects", nil, []string{"This chat (999) is not authorized"}},
+		{"help", 300, "/start", nil, []string{"/pending - promotions waiting for approval"}},
+		{"markdown fallback", 100, "/run *", []string{"ops POST /hook/*/trigger"}, []string{"Hook `*` failed"}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go StartTelegram(ctx)
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callsMu.Lock()
+			calls = nil
+			callsMu.Unlock()
+			m := &telegramMessage{Text: tt.text}
+			m.Chat.ID = tt.chat
+			m.From = &struct {
+				Username  string `json:"username"`
+				FirstName string `json:"first_name"`
+			}{Username: "bob"}
+			bot.mu.Lock()
+			bot.updates = append(bot.updates, telegramUpdate{UpdateID: int64(i + 1), Message: m})
+			bot.mu.Unlock()
+
+			var sent map[string]interface{}
+			select {
+			case sent = <-bot.sent:
+			case <-time.After(5 * time.Second):
+				t.Fatal("no answer sent")
+			}
+			if int64(sent["chat_id"].(float64)) != tt.chat {
+				t.Errorf("answered chat %v", sent["chat_id"])
+			}
+			callsMu.Lock()
+			got := strings.Join(calls, "\n")
+			callsMu.Unlock()
+			if got != strings.Join(tt.calls, "\n") {
+				t.Errorf("API calls:\n%s\nwant:\n%s", got, strings.Join(tt.calls, "\n"))
+			}
+			for _, part := range tt.reply {
+				if !strings.Contains(sent["text"].(string), part) {
+					t.Errorf("reply %q does not contain %q", sent["text"], part)
+				}
+			}
+		})
+	}
+}
+
+func TestTelegramAlerts(t *testing.T) {
+	setupTelegram(t, "http://127.0.0.1:0")
+	cfg, panel := telegramConfig()
+	until := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		data       interface{}
+		text       string
+		recipients []int64
+	}{
+		{"hook failed", stream.HookTriggeredMessage{HookID: "build", Error: "exit status 1", LogID: 3}, "Hook `build` failed: exit status 1\n[Execution log #3](https://gohook.example.com/#/logs)", []int64{100, 200}},
+		{"hook succeeded", stream.HookTriggeredMessage{HookID: "build", Success: true}, "", nil},
+		{"deploy failed", stream.VersionSwitchMessage{ProjectName: "web", Action: "switch-tag", Target: "v2", Error: "dirty"}, "switch-tag of `web` to `v2` failed: dirty", []int64{100, 200}},
+		{"githook skipped", stream.GitHookTriggeredMessage{ProjectName: "web", Skipped: true}, "", nil},
+		{"approval to admins", stream.PromotionRequestMessage{ID: 4, SourceProject: "staging", TargetProject: "web", Ref: "v2", RequestedBy: "alice"}, "/approve_4  /reject_4", []int64{100}},
+		{"secret rotated", stream.SecretRotationMessage{Kind: "hook", Name: "build", Action: "rotated", By: "alice", Expires: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}, "Secret of hook `build` rotated by alice, the previous secret is accepted until 2026-01-02T03:04:05Z", []int64{100, 200}},
+		{"secret retired", stream.SecretRotationMessage{Kind: "project", Name: "web", Action: "retired"}, "Previous secret of project `web` retired.", []int64{100, 200}},
+		{"circuit open", stream.HookBudgetMessage{HookID: "build", Event: "circuit-open", Failures: 5, Limit: "5 failures per hour", Until: &until}, "Hook `build` paused by its circuit breaker: 5 failures in the last hour (budget 5 failures per hour), resumes at 2026-01-02T03:04:05Z", []int64{100, 200}},
+		{"other messages", stream.HookManageMessage{HookID: "build"}, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, ok := alertFor(stream.WsMessage{Data: tt.data}, panel)
+			if ok != (tt.text != "") {
+				t.Fatalf("alert = %v, %+v", ok, a)
+			}
+			if !ok {
+				return
+			}
+			if !strings.Contains(a.text, tt.text) {
+				t.Errorf("text %q does not contain %q", a.text, tt.text)
+			}
+			if got := fmt.Sprint(alertRecipients(cfg, a)); got != fmt.Sprint(tt.recipients) {
+				t.Errorf("recipients %s, want %v", got, tt.recipients)
+			}
+		})
+	}
+}
+
+func TestTelegramAlertSealed(t *testing.T) {
+	setupTelegram(t, "http://127.0.0.1:0")
+	_, panel := telegramConfig()
+
+	a, ok := alertFor(stream.WsMessage{Data: stream.HookTriggeredMessage{HookID: "build", Error: "token=hunter2 rejected", LogID: 3}, Sealed: true}, panel)
+	if !ok {
+		t.Fatal("no alert for a failed hook")
+	}
+	if strings.Contains(a.text, "hunter2") || !strings.Contains(a.text, "Execution log #3") {
+		t.Errorf("text %q", a.text)
+	}
+}
@@ -12,17 +12,70 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/mycoool/gohook/internal/cluster"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/locale"
 	"github.com/mycoool/gohook/internal/types"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// global session storage (should use database or Redis in production)
+// global session storage, in HA mode the sessions are kept in the shared database instead
 var ClientSessions = make(map[string]*types.ClientSession)
 var SessionIDCounter = 1
 var SessionMutex sync.RWMutex
 
+// sessionTouchInterval how often the last use of a session stored in the database is updated
+const sessionTouchInterval = time.Minute
+
+// sharedSessions whether the sessions are stored in the database shared by the HA instances
+func sharedSessions() bool {
+	return cluster.Enabled() && database.GetDB() != nil
+}
+
+// sessionFromRecord client session of a database record, the token is not stored
+func sessionFromRecord(r *database.ClientSession) *types.ClientSession {
+	return &types.ClientSession{
+		ID:        int(r.ID),
+		Name:      r.Name,
+		Username:  r.Username,
+		LastUsed:  r.LastUsed,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+// sharedSessionsOf sessions of username stored in the database. Only the session of
+// currentToken carries its token, the others are known by the hash of theirs.
+func sharedSessionsOf(username, currentToken string) []*types.ClientSession {
+	records, err := database.ClientSessionsOf(username)
+	if err != nil {
+		log.Printf("Error reading client sessions: %v", err)
+	}
+	current := database.TokenHash(currentToken)
+	var sessions []*types.ClientSession
+	for i := range records {
+		session := sessionFromRecord(&records[i])
+		if currentToken != "" && records[i].TokenHash == current {
+			session.Token = currentToken
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
 // add client session
 func AddClientSession(token, name, username string) *types.ClientSession {
+	if sharedSessions() {
+		record, err := database.CreateClientSession(token, name, username)
+		if err != nil {
+			log.Printf("Error storing client session: %v", err)
+			return &types.ClientSession{Token: token, Name: name, Username: username, LastUsed: time.Now(), CreatedAt: time.Now()}
+		}
+		session := sessionFromRecord(record)
+		session.Token = token
+		return session
+	}
+
 	SessionMutex.Lock()
 	defer SessionMutex.Unlock()
 
@@ -43,6 +96,10 @@ func AddClientSession(token, name, username string) *types.ClientSession {
 
 // get client sessions by user
 func GetClientSessionsByUser(username string) []*types.ClientSession {
+	if sharedSessions() {
+		return sharedSessionsOf(username, "")
+	}
+
 	SessionMutex.RLock()
 	defer SessionMutex.RUnlock()
 
@@ -58,6 +115,14 @@ func GetClientSessionsByUser(username string) []*types.ClientSession {
 
 // remove client session
 func RemoveClientSession(token string) bool {
+	if sharedSessions() {
+		removed, err := database.DeleteClientSessionByToken(token)
+		if err != nil {
+			log.Printf("Error removing client session: %v", err)
+		}
+		return removed
+	}
+
 	SessionMutex.Lock()
 	defer SessionMutex.Unlock()
 
@@ -71,6 +136,13 @@ func RemoveClientSession(token string) bool {
 
 // update session last used time
 func UpdateSessionLastUsed(token string) {
+	if sharedSessions() {
+		if err := database.TouchClientSession(token, time.Now(), sessionTouchInterval); err != nil {
+			log.Printf("Error updating client session: %v", err)
+		}
+		return
+	}
+
 	SessionMutex.Lock()
 	defer SessionMutex.Unlock()
 
@@ -104,12 +176,13 @@ func VerifyPassword(password, hashedPassword string) bool {
 	return hex.EncodeToString(hash[:]) == hashedPassword
 }
 
-// generate JWT token
-func GenerateToken(username, role string) (string, error) {
+// generate JWT token, a non-empty namespace limits the token to that namespace
+func GenerateToken(username, role, namespace string) (string, error) {
 	expirationTime := time.Now().Add(time.Duration(types.GoHookAppConfig.JWTExpiryDuration) * time.Minute)
 	claims := &types.Claims{
-		Username: username,
-		Role:     role,
+		Username:  username,
+		Role:      role,
+		Namespace: namespace,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -145,7 +218,20 @@ func ValidateToken(tokenString string) (*types.Claims, error) {
 func HandleDeleteClientSession(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidClientID, nil)
+		return
+	}
+
+	if sharedSessions() {
+		removed, err := database.DeleteClientSession(uint(id))
+		if err != nil {
+			log.Printf("Error removing client session: %v", err)
+		}
+		if removed {
+			c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeClientSessionDeleted), "code": i18n.CodeClientSessionDeleted})
+		} else {
+			i18n.JSONError(c, http.StatusNotFound, i18n.CodeClientSessionNotFound, nil)
+		}
 		return
 	}
 
@@ -162,30 +248,31 @@ func HandleDeleteClientSession(c *gin.Context) {
 
 	if tokenToDelete != "" {
 		delete(ClientSessions, tokenToDelete)
-		c.JSON(http.StatusOK, gin.H{"message": "Client session deleted"})
+		c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeClientSessionDeleted), "code": i18n.CodeClientSessionDeleted})
 	} else {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Client session not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeClientSessionNotFound, nil)
 	}
 }
 
 func HandleDeleteCurrentClientSession(c *gin.Context) {
 	token := c.GetHeader("X-GoHook-Key")
 	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token not provided"})
+		i18n.JSONError(c, http.StatusUnauthorized, i18n.CodeMissingToken, nil)
 		return
 	}
 
 	if RemoveClientSession(token) {
-		c.JSON(http.StatusOK, gin.H{"message": "Current session deleted successfully"})
+		c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeLoggedOut), "code": i18n.CodeLoggedOut})
 	} else {
 		// even if the session is not found, return success, because the client's goal is to logout
-		c.JSON(http.StatusOK, gin.H{"message": "Session not found, but logout process can continue"})
+		c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeLogoutSessionNotFound), "code": i18n.CodeLogoutSessionNotFound})
 	}
 }
 
 func HandleModifyCurrentClientPassword(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Password modification function not implemented",
+		"message": i18n.Message(c, i18n.CodePasswordChangeUnsupported),
+		"code":    i18n.CodePasswordChangeUnsupported,
 	})
 }
 
@@ -194,6 +281,9 @@ func HandleGetClientSessions(c *gin.Context) {
 	currentToken, _ := c.Get("token")
 
 	sessions := GetClientSessionsByUser(username.(string))
+	if sharedSessions() {
+		sessions = sharedSessionsOf(username.(string), currentToken.(string))
+	}
 
 	// convert to frontend expected format
 	var clients []gin.H
@@ -205,7 +295,7 @@ func HandleGetClientSessions(c *gin.Context) {
 			"id":       session.ID,
 			"token":    session.Token,
 			"name":     session.Name,
-			"lastUsed": session.LastUsed.Format(time.RFC3339),
+			"lastUsed": locale.Format(session.LastUsed, locale.FromContext(c)),
 			"current":  isCurrent,
 		})
 	}
@@ -218,10 +308,10 @@ func HandleRenewToken(c *gin.Context) {
 	role, _ := c.Get("role")
 	oldToken, _ := c.Get("token")
 
-	// generate new token
-	newToken, err := GenerateToken(username.(string), role.(string))
+	// generate new token, keeping the namespace the old token was limited to
+	newToken, err := GenerateToken(username.(string), role.(string), c.GetString("token_namespace"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate new token"})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeGenerateTokenFailed, nil)
 		return
 	}
 
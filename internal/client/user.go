@@ -9,11 +9,38 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/cluster"
 	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/locale"
+	"github.com/mycoool/gohook/internal/namespace"
 	"github.com/mycoool/gohook/internal/types"
 	"gopkg.in/yaml.v2"
 )
 
+func init() {
+	namespace.Register(namespace.KindUser, namespace.Resource{
+		Lookup: func(username string) (string, bool) {
+			if user := FindUser(username); user != nil {
+				return user.Namespace, true
+			}
+			return "", false
+		},
+		Names: func(ns string) []string {
+			var names []string
+			if types.GoHookUsersConfig == nil {
+				return names
+			}
+			for _, user := range types.GoHookUsersConfig.Users {
+				if user.Namespace == ns {
+					names = append(names, user.Username)
+				}
+			}
+			return names
+		},
+	})
+}
+
 // load users config file
 func LoadUsersConfig() error {
 	filePath := "user.yaml"
@@ -51,6 +78,9 @@ func SaveUsersConfig() error {
 		yamlContent.WriteString(fmt.Sprintf("  - username: %s\n", user.Username))
 		yamlContent.WriteString(fmt.Sprintf("    password: %s\n", user.Password))
 		yamlContent.WriteString(fmt.Sprintf("    role: %s\n", user.Role))
+		if user.Namespace != "" {
+			yamlContent.WriteString(fmt.Sprintf("    namespace: %s\n", user.Namespace))
+		}
 
 		// if it is default admin user and password is hashed, add original password comment
 		if user.Username == "admin" && strings.HasPrefix(user.Password, "$2a$") {
@@ -65,6 +95,7 @@ func SaveUsersConfig() error {
 		return fmt.Errorf("save user config file failed: %v", err)
 	}
 
+	cluster.ConfigChanged(cluster.ConfigUsers)
 	return nil
 }
 
@@ -83,6 +114,12 @@ func FindUser(username string) *types.UserConfig {
 	return nil
 }
 
+// CanDecryptLogs whether username may read hook logs encrypted at rest in clear
+func CanDecryptLogs(username string) bool {
+	user := FindUser(username)
+	return user != nil && user.DecryptLogs
+}
+
 func Login(c *gin.Context) {
 	// get Basic authentication info from Authorization header
 	authHeader := c.GetHeader("Authorization")
@@ -98,7 +135,7 @@ func Login(c *gin.Context) {
 			false,
 			map[string]interface{}{"error": "missing_auth_header"},
 		)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing authorization header"})
+		i18n.JSONError(c, http.StatusUnauthorized, i18n.CodeMissingAuthHeader, nil)
 		return
 	}
 
@@ -115,7 +152,7 @@ func Login(c *gin.Context) {
 			false,
 			map[string]interface{}{"error": "invalid_auth_type"},
 		)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization type"})
+		i18n.JSONError(c, http.StatusUnauthorized, i18n.CodeInvalidAuthType, nil)
 		return
 	}
 
@@ -134,7 +171,7 @@ func Login(c *gin.Context) {
 			false,
 			map[string]interface{}{"error": "invalid_encoding"},
 		)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization encoding"})
+		i18n.JSONError(c, http.StatusUnauthorized, i18n.CodeInvalidAuthEncoding, nil)
 		return
 	}
 
@@ -152,7 +189,7 @@ func Login(c *gin.Context) {
 			false,
 			map[string]interface{}{"error": "invalid_credentials_format"},
 		)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials format"})
+		i18n.JSONError(c, http.StatusUnauthorized, i18n.CodeInvalidCredentialsFormat, nil)
 		return
 	}
 
@@ -173,7 +210,7 @@ func Login(c *gin.Context) {
 			false,
 			map[string]interface{}{"error": "user_not_found"},
 		)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		i18n.JSONError(c, http.StatusUnauthorized, i18n.CodeInvalidCredentials, nil)
 		return
 	}
 
@@ -190,12 +227,12 @@ func Login(c *gin.Context) {
 			false,
 			map[string]interface{}{"error": "invalid_password"},
 		)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		i18n.JSONError(c, http.StatusUnauthorized, i18n.CodeInvalidCredentials, nil)
 		return
 	}
 
 	// generate JWT token
-	token, err := GenerateToken(user.Username, user.Role)
+	token, err := GenerateToken(user.Username, user.Role, user.Namespace)
 	if err != nil {
 		// log failed login attempt
 		database.LogUserAction(
@@ -208,7 +245,7 @@ func Login(c *gin.Context) {
 			false,
 			map[string]interface{}{"error": "token_generation_failed"},
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeGenerateTokenFailed, err)
 		return
 	}
 
@@ -256,8 +293,12 @@ func GetAllUsers(c *gin.Context) {
 	var users []types.UserResponse
 	for _, user := range types.GoHookUsersConfig.Users {
 		users = append(users, types.UserResponse{
-			Username: user.Username,
-			Role:     user.Role,
+			Username:    user.Username,
+			Role:        user.Role,
+			Namespace:   user.Namespace,
+			Timezone:    user.Timezone,
+			Language:    user.Language,
+			DecryptLogs: user.DecryptLogs,
 		})
 	}
 	c.JSON(http.StatusOK, users)
@@ -266,13 +307,15 @@ func GetAllUsers(c *gin.Context) {
 // create user
 func CreateUser(c *gin.Context) {
 	var req struct {
-		Username string `json:"username" binding:"required"`
-		Password string `json:"password" binding:"required"`
-		Role     string `json:"role" binding:"required"`
+		Username    string `json:"username" binding:"required"`
+		Password    string `json:"password" binding:"required"`
+		Role        string `json:"role" binding:"required"`
+		Namespace   string `json:"namespace"`   // empty: user is not limited to a namespace
+		DecryptLogs bool   `json:"decryptLogs"` // user may read hook logs encrypted at rest
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, nil)
 		return
 	}
 
@@ -299,7 +342,7 @@ func CreateUser(c *gin.Context) {
 				"error":           "user_already_exists",
 			},
 		)
-		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+		i18n.JSONError(c, http.StatusConflict, i18n.CodeUserExists, nil)
 		return
 	}
 
@@ -320,15 +363,23 @@ func CreateUser(c *gin.Context) {
 				"error":           "invalid_role",
 			},
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role. Must be 'admin' or 'user'"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRole, nil)
+		return
+	}
+
+	// validate namespace
+	if req.Namespace != "" && !namespace.Exists(req.Namespace) {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeNamespaceNotFound, nil)
 		return
 	}
 
 	// add new user
 	newUser := types.UserConfig{
-		Username: req.Username,
-		Password: HashPassword(req.Password),
-		Role:     req.Role,
+		Username:    req.Username,
+		Password:    HashPassword(req.Password),
+		Role:        req.Role,
+		Namespace:   req.Namespace,
+		DecryptLogs: req.DecryptLogs,
 	}
 
 	types.GoHookUsersConfig.Users = append(types.GoHookUsersConfig.Users, newUser)
@@ -350,7 +401,7 @@ func CreateUser(c *gin.Context) {
 				"error":           "save_config_failed",
 			},
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
 		return
 	}
 
@@ -370,10 +421,13 @@ func CreateUser(c *gin.Context) {
 	)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User created successfully",
+		"message": i18n.Message(c, i18n.CodeUserCreated),
+		"code":    i18n.CodeUserCreated,
 		"user": types.UserResponse{
-			Username: newUser.Username,
-			Role:     newUser.Role,
+			Username:    newUser.Username,
+			Role:        newUser.Role,
+			Namespace:   newUser.Namespace,
+			DecryptLogs: newUser.DecryptLogs,
 		},
 	})
 }
@@ -403,7 +457,7 @@ func DeleteUser(c *gin.Context) {
 				"error":           "cannot_delete_self",
 			},
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete yourself"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeCannotDeleteSelf, nil)
 		return
 	}
 
@@ -433,7 +487,7 @@ func DeleteUser(c *gin.Context) {
 				"error":           "user_not_found",
 			},
 		)
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeUserNotFound, nil)
 		return
 	}
 
@@ -457,10 +511,15 @@ func DeleteUser(c *gin.Context) {
 				"error":           "save_config_failed",
 			},
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
 		return
 	}
 
+	// a user created later with the same name starts with an empty inbox
+	if _, err := database.DeleteUserMessages(username, 0); err != nil {
+		log.Printf("delete messages of user %s failed: %v", username, err)
+	}
+
 	// log successful user deletion
 	database.LogUserAction(
 		currentUserStr,
@@ -477,7 +536,8 @@ func DeleteUser(c *gin.Context) {
 	)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User deleted successfully",
+		"message": i18n.Message(c, i18n.CodeUserDeleted),
+		"code":    i18n.CodeUserDeleted,
 	})
 }
 
@@ -489,20 +549,20 @@ func ChangePassword(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, nil)
 		return
 	}
 
 	username, _ := c.Get("username")
 	user := FindUser(username.(string))
 	if user == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeUserNotFound, nil)
 		return
 	}
 
 	// verify old password
 	if !VerifyPassword(req.OldPassword, user.Password) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid old password"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidOldPassword, nil)
 		return
 	}
 
@@ -511,12 +571,13 @@ func ChangePassword(c *gin.Context) {
 
 	// save config file
 	if err := SaveUsersConfig(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Password updated successfully",
+		"message": i18n.Message(c, i18n.CodePasswordUpdated),
+		"code":    i18n.CodePasswordUpdated,
 	})
 }
 
@@ -528,13 +589,13 @@ func ResetPassword(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, nil)
 		return
 	}
 
 	user := FindUser(username)
 	if user == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeUserNotFound, nil)
 		return
 	}
 
@@ -543,12 +604,13 @@ func ResetPassword(c *gin.Context) {
 
 	// save config file
 	if err := SaveUsersConfig(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Password reset successfully",
+		"message": i18n.Message(c, i18n.CodePasswordReset),
+		"code":    i18n.CodePasswordReset,
 	})
 }
 
@@ -557,11 +619,68 @@ func GetCurrentUser(c *gin.Context) {
 	username, _ := c.Get("username")
 	role, _ := c.Get("role")
 
+	tokenNamespace := c.GetString("token_namespace")
+
 	c.JSON(http.StatusOK, gin.H{
-		"id":       1,
-		"name":     username,
-		"username": username,
-		"role":     role,
-		"admin":    role == "admin",
+		"id":          1,
+		"name":        username,
+		"username":    username,
+		"role":        role,
+		"admin":       role == "admin" && tokenNamespace == "",
+		"namespace":   tokenNamespace,
+		"timezone":    locale.ForUser(c.GetString("username")).String(),
+		"language":    locale.Language(c.GetString("username")),
+		"decryptLogs": CanDecryptLogs(c.GetString("username")),
 	})
 }
+
+// preferencesResponse preferences of user with the zone and language in effect
+func preferencesResponse(user *types.UserConfig) types.UserPreferencesResponse {
+	return types.UserPreferencesResponse{
+		UserPreferences:   types.UserPreferences{Timezone: user.Timezone, Language: user.Language},
+		EffectiveTimezone: locale.ForUser(user.Username).String(),
+		EffectiveLanguage: locale.Language(user.Username),
+	}
+}
+
+// GetPreferences time zone and panel language of the current user
+func GetPreferences(c *gin.Context) {
+	user := FindUser(c.GetString("username"))
+	if user == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeUserNotFound, nil)
+		return
+	}
+	c.JSON(http.StatusOK, preferencesResponse(user))
+}
+
+// UpdatePreferences set the time zone and panel language of the current user, empty values
+// fall back to the server setting
+func UpdatePreferences(c *gin.Context) {
+	var req types.UserPreferences
+	if err := c.ShouldBindJSON(&req); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, nil)
+		return
+	}
+	if _, err := locale.LoadZone(req.Timezone); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidTimezone, err)
+		return
+	}
+	if err := locale.ValidateLanguage(req.Language); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidLanguage, err)
+		return
+	}
+
+	user := FindUser(c.GetString("username"))
+	if user == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeUserNotFound, nil)
+		return
+	}
+	previous := types.UserPreferences{Timezone: user.Timezone, Language: user.Language}
+	user.Timezone, user.Language = req.Timezone, req.Language
+	if err := SaveUsersConfig(); err != nil {
+		user.Timezone, user.Language = previous.Timezone, previous.Language
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, preferencesResponse(user))
+}
@@ -0,0 +1,496 @@
+// Package cluster lets several gohook instances share one database: one instance
+// is elected leader and runs the background tasks, while WebSocket messages and
+// configuration changes are relayed to every instance. Events are stored in the
+// database; on PostgreSQL, LISTEN/NOTIFY wakes the other instances as soon as one
+// is published, other databases are polled.
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/stream"
+	"github.com/mycoool/gohook/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// event types relayed between instances
+const (
+	EventWebSocket     = "ws"
+	EventConfigChanged = "config"
+)
+
+// configuration files announced with EventConfigChanged
+const (
+	ConfigApp     = "app"
+	ConfigVersion = "version"
+	ConfigUsers   = "users"
+	ConfigHooks   = "hooks"
+)
+
+// leaderLease name of the lease held by the leader
+const leaderLease = "leader"
+
+const (
+	// eventsLock database lock publishers hold, events are committed in id order
+	eventsLock = "cluster-events"
+	// notifyChannel PostgreSQL channel announcing new events
+	notifyChannel = "gohook_cluster"
+)
+
+const (
+	defaultLeaseSeconds = 15
+	defaultPollInterval = time.Second
+	// notifyPollInterval polling of the events besides LISTEN/NOTIFY, in case a notification was missed
+	notifyPollInterval = 10 * time.Second
+	// eventRetention relayed events older than this are removed by the leader
+	eventRetention = 5 * time.Minute
+	// nodeRetention instances without heartbeat for this long are removed by the leader
+	nodeRetention = 24 * time.Hour
+)
+
+// Task background task that only runs on the leader, it must return once ctx is done
+type Task func(ctx context.Context)
+
+type namedTask struct {
+	name string
+	task Task
+}
+
+var (
+	mu          sync.Mutex
+	db          *gorm.DB
+	enabled     bool
+	nodeID      string
+	hostname    string
+	version     string
+	startedAt   time.Time
+	leaseTTL    = defaultLeaseSeconds * time.Second
+	pollEvery   = defaultPollInterval
+	leading     bool
+	lastRenewal time.Time
+	holdOff     time.Time // no election before this time after a step down
+	stopped     bool      // no election after Stop
+	tasks       []namedTask
+	cancelTasks context.CancelFunc
+	lastEventID uint
+	pollMu      sync.Mutex // one pollEvents at a time, from the ticker or a notification
+
+	subscribersMu sync.RWMutex
+	subscribers   = map[string][]func(payload []byte){}
+)
+
+// OnLeader register a task run while this instance is the leader.
+// Without HA mode every instance is its own leader and tasks start with Start.
+func OnLeader(name string, task Task) {
+	mu.Lock()
+	tasks = append(tasks, namedTask{name: name, task: task})
+	mu.Unlock()
+}
+
+// Subscribe install a handler for events of typ published by the other instances
+func Subscribe(typ string, fn func(payload []byte)) {
+	subscribersMu.Lock()
+	subscribers[typ] = append(subscribers[typ], fn)
+	subscribersMu.Unlock()
+}
+
+// OnConfigChanged call fn with the name of the configuration file another instance saved
+func OnConfigChanged(fn func(name string)) {
+	Subscribe(EventConfigChanged, func(payload []byte) {
+		var name string
+		if err := json.Unmarshal(payload, &name); err == nil {
+			fn(name)
+		}
+	})
+}
+
+// ConfigChanged tell the other instances to reload a configuration file
+func ConfigChanged(name string) {
+	Publish(EventConfigChanged, name)
+}
+
+// Publish relay an event to the other instances, it does nothing outside HA mode
+func Publish(typ string, data interface{}) {
+	mu.Lock()
+	conn, on, origin := db, enabled, nodeID
+	mu.Unlock()
+	if !on || conn == nil {
+		return
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("cluster: marshal %s event failed: %v", typ, err)
+		return
+	}
+	event := database.ClusterEvent{Origin: origin, Type: typ, Payload: string(payload), CreatedAt: time.Now().UTC()}
+	// under the lock ids are committed in order, readers never skip an event committed late
+	err = database.Locked(conn, eventsLock, func(tx *gorm.DB) error {
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		return database.Notify(tx, notifyChannel, origin)
+	})
+	if err != nil {
+		log.Printf("cluster: publish %s event failed: %v", typ, err)
+	}
+}
+
+// Enabled report whether HA mode is active
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return enabled
+}
+
+// NodeID id of this instance
+func NodeID() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return nodeID
+}
+
+// IsLeader report whether this instance runs the background tasks, always true outside HA mode
+func IsLeader() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return !enabled || leading
+}
+
+// Start join the cluster configured in app.yaml, listenAddr is used for the default node id.
+// Outside HA mode, or without database, the leader tasks start right away.
+func Start(ctx context.Context, appVersion, listenAddr string) {
+	cfg := clusterConfig()
+	conn := database.GetDB()
+
+	mu.Lock()
+	version = appVersion
+	startedAt = time.Now().UTC()
+	hostname, _ = os.Hostname()
+	if cfg == nil || !cfg.Enabled || conn == nil {
+		if cfg != nil && cfg.Enabled {
+			log.Printf("cluster: database not initialized, HA mode disabled")
+		}
+		leading = true
+		mu.Unlock()
+		startTasks(ctx)
+		return
+	}
+
+	db = conn
+	enabled = true
+	nodeID = cfg.NodeID
+	if nodeID == "" {
+		nodeID = hostname
+		if _, port, err := net.SplitHostPort(listenAddr); err == nil {
+			nodeID = net.JoinHostPort(hostname, port)
+		}
+	}
+	if cfg.LeaseSeconds > 0 {
+		leaseTTL = time.Duration(cfg.LeaseSeconds) * time.Second
+	}
+	if cfg.PollIntervalMs > 0 {
+		pollEvery = time.Duration(cfg.PollIntervalMs) * time.Millisecond
+	} else if database.SupportsNotify() {
+		pollEvery = notifyPollInterval
+	}
+	// only events published after joining are relayed
+	db.Model(&database.ClusterEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&lastEventID)
+	mu.Unlock()
+
+	stream.Global.SetRelay(func(msg stream.WsMessage) {
+		Publish(EventWebSocket, msg)
+	})
+	Subscribe(EventWebSocket, func(payload []byte) {
+		var msg stream.WsMessage
+		if err := json.Unmarshal(payload, &msg); err == nil {
+			stream.Global.BroadcastLocal(msg)
+		}
+	})
+
+	log.Printf("cluster: HA mode enabled, node %s", NodeID())
+	if database.SupportsNotify() {
+		go database.Listen(ctx, notifyChannel, func(origin string) {
+			if origin != NodeID() {
+				pollEvents()
+			}
+		})
+	} else {
+		log.Printf("cluster: the database is SQLite, all instances must run on this host")
+	}
+	go runElection(ctx)
+	go runEvents(ctx)
+}
+
+// Stop release the leadership so another instance takes over without waiting for the lease to expire
+func Stop() {
+	mu.Lock()
+	stopped = true
+	mu.Unlock()
+	stepDown(0)
+}
+
+// StepDown hand the leadership over to another instance, this instance does not run
+// for leader again during one lease period. It reports whether this instance was the leader.
+func StepDown() bool {
+	mu.Lock()
+	ttl := leaseTTL
+	mu.Unlock()
+	return stepDown(ttl)
+}
+
+func stepDown(wait time.Duration) bool {
+	mu.Lock()
+	if !enabled || !leading {
+		mu.Unlock()
+		return false
+	}
+	holdOff = time.Now().Add(wait)
+	conn, holder := db, nodeID
+	mu.Unlock()
+
+	stopTasks()
+	if err := release(conn, leaderLease, holder); err != nil {
+		log.Printf("cluster: release leadership failed: %v", err)
+	}
+	log.Printf("cluster: node %s stepped down", holder)
+	return true
+}
+
+// Leader node id of the current leader, empty when there is none
+func Leader() string {
+	mu.Lock()
+	conn, on, self, lead := db, enabled, nodeID, leading
+	mu.Unlock()
+	if !on {
+		return self
+	}
+	if lead {
+		return self
+	}
+
+	var lease database.ClusterLease
+	if err := conn.Where("name = ? AND expires_at >= ?", leaderLease, time.Now().UTC()).First(&lease).Error; err != nil {
+		return ""
+	}
+	return lease.Holder
+}
+
+// Nodes instances that took part in HA mode
+func Nodes() ([]database.ClusterNode, error) {
+	mu.Lock()
+	conn := db
+	mu.Unlock()
+	if conn == nil {
+		return nil, nil
+	}
+
+	var nodes []database.ClusterNode
+	err := conn.Order("node_id").Find(&nodes).Error
+	return nodes, err
+}
+
+// Online report whether an instance sent a heartbeat recently
+func Online(node database.ClusterNode) bool {
+	mu.Lock()
+	ttl := leaseTTL
+	mu.Unlock()
+	return time.Since(node.LastSeen) < 2*ttl
+}
+
+func clusterConfig() *types.ClusterConfig {
+	if types.GoHookAppConfig == nil {
+		return nil
+	}
+	return types.GoHookAppConfig.Cluster
+}
+
+func runElection(ctx context.Context) {
+	ticker := time.NewTicker(leaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		elect(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			stopTasks()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// elect renew or acquire the leader lease and start or stop the leader tasks accordingly
+func elect(ctx context.Context, now time.Time) {
+	mu.Lock()
+	conn, holder, ttl, wasLeading, wait, done := db, nodeID, leaseTTL, leading, holdOff, stopped
+	mu.Unlock()
+
+	if done {
+		return
+	}
+	heartbeat(conn, now)
+	if now.Before(wait) {
+		return
+	}
+
+	won, err := acquire(conn, leaderLease, holder, ttl, now)
+	if err != nil {
+		log.Printf("cluster: leader election failed: %v", err)
+		// keep leading until the lease we already hold runs out
+		mu.Lock()
+		expired := wasLeading && now.Sub(lastRenewal) >= ttl
+		mu.Unlock()
+		if expired {
+			log.Printf("cluster: lost leadership, lease could not be renewed")
+			stopTasks()
+		}
+		return
+	}
+
+	mu.Lock()
+	if won {
+		lastRenewal = now
+	}
+	mu.Unlock()
+
+	switch {
+	case won && !wasLeading:
+		log.Printf("cluster: node %s is now the leader", holder)
+		mu.Lock()
+		leading = true
+		mu.Unlock()
+		startTasks(ctx)
+	case !won && wasLeading:
+		log.Printf("cluster: node %s lost leadership", holder)
+		stopTasks()
+	}
+
+	if won {
+		prune(conn, now)
+	}
+}
+
+// acquire take or renew the lease name for holder. The lease is granted when it
+// is free, expired or already held by holder.
+func acquire(conn *gorm.DB, name, holder string, ttl time.Duration, now time.Time) (bool, error) {
+	now = now.UTC()
+	res := conn.Model(&database.ClusterLease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now).
+		Updates(map[string]interface{}{"holder": holder, "expires_at": now.Add(ttl)})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return true, nil
+	}
+
+	res = conn.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&database.ClusterLease{Name: name, Holder: holder, ExpiresAt: now.Add(ttl)})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// release give up the lease name if it is held by holder
+func release(conn *gorm.DB, name, holder string) error {
+	return conn.Where("name = ? AND holder = ?", name, holder).Delete(&database.ClusterLease{}).Error
+}
+
+func heartbeat(conn *gorm.DB, now time.Time) {
+	mu.Lock()
+	node := database.ClusterNode{
+		NodeID:    nodeID,
+		Hostname:  hostname,
+		Version:   version,
+		StartedAt: startedAt,
+		LastSeen:  now.UTC(),
+	}
+	mu.Unlock()
+	if err := conn.Clauses(clause.OnConflict{UpdateAll: true}).Create(&node).Error; err != nil {
+		log.Printf("cluster: heartbeat failed: %v", err)
+	}
+}
+
+func prune(conn *gorm.DB, now time.Time) {
+	now = now.UTC()
+	conn.Where("created_at < ?", now.Add(-eventRetention)).Delete(&database.ClusterEvent{})
+	conn.Where("last_seen < ?", now.Add(-nodeRetention)).Delete(&database.ClusterNode{})
+}
+
+func startTasks(ctx context.Context) {
+	mu.Lock()
+	defer mu.Unlock()
+	if cancelTasks != nil {
+		return
+	}
+
+	taskCtx, cancel := context.WithCancel(ctx)
+	cancelTasks = cancel
+	for _, t := range tasks {
+		go t.task(taskCtx)
+	}
+}
+
+func stopTasks() {
+	mu.Lock()
+	defer mu.Unlock()
+	leading = false
+	if cancelTasks != nil {
+		cancelTasks()
+		cancelTasks = nil
+	}
+}
+
+func runEvents(ctx context.Context) {
+	ticker := time.NewTicker(pollEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pollEvents()
+		}
+	}
+}
+
+// pollEvents dispatch the events other instances published since the last poll
+func pollEvents() {
+	pollMu.Lock()
+	defer pollMu.Unlock()
+
+	mu.Lock()
+	conn, self, after := db, nodeID, lastEventID
+	mu.Unlock()
+
+	var events []database.ClusterEvent
+	if err := conn.Where("id > ? AND origin <> ?", after, self).Order("id").Limit(500).Find(&events).Error; err != nil {
+		log.Printf("cluster: read events failed: %v", err)
+		return
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	mu.Lock()
+	lastEventID = events[len(events)-1].ID
+	mu.Unlock()
+
+	for _, e := range events {
+		subscribersMu.RLock()
+		handlers := subscribers[e.Type]
+		subscribersMu.RUnlock()
+		for _, fn := range handlers {
+			fn([]byte(e.Payload))
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mycoool/gohook/internal/database"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&database.ClusterLease{}, &database.ClusterEvent{}, &database.Lock{}); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestAcquire(t *testing.T) {
+	conn := openTestDB(t)
+	ttl := 15 * time.Second
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		name   string
+		holder string
+		at     time.Duration
+		want   bool
+	}{
+		{"free lease is granted", "a", 0, true},
+		{"held lease is refused", "b", 5 * time.Second, false},
+		{"holder renews", "a", 10 * time.Second, true},
+		{"renewal extends the lease", "b", 20 * time.Second, false},
+		{"expired lease is taken over", "b", 26 * time.Second, true},
+		{"former holder is refused", "a", 30 * time.Second, false},
+	}
+	for _, s := range steps {
+		got, err := acquire(conn, leaderLease, s.holder, ttl, start.Add(s.at))
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if got != s.want {
+			t.Fatalf("%s: acquire(%q) = %v, want %v", s.name, s.holder, got, s.want)
+		}
+	}
+
+	if err := release(conn, leaderLease, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := acquire(conn, leaderLease, "c", ttl, start.Add(31*time.Second)); ok {
+		t.Fatalf("release by a non-holder freed the lease")
+	}
+	if err := release(conn, leaderLease, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := acquire(conn, leaderLease, "c", ttl, start.Add(32*time.Second)); !ok {
+		t.Fatalf("released lease was not granted")
+	}
+}
+
+func TestPollEvents(t *testing.T) {
+	conn := openTestDB(t)
+
+	mu.Lock()
+	db, nodeID, lastEventID = conn, "self", 0
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		db, nodeID, lastEventID = nil, "", 0
+		mu.Unlock()
+		subscribersMu.Lock()
+		delete(subscribers, "test")
+		subscribersMu.Unlock()
+	})
+
+	var got []string
+	Subscribe("test", func(payload []byte) { got = append(got, string(payload)) })
+
+	for _, e := range []database.ClusterEvent{
+		{Origin: "other", Type: "test", Payload: `"one"`},
+		{Origin: "self", Type: "test", Payload: `"own"`},
+		{Origin: "other", Type: "unknown", Payload: `"skipped"`},
+		{Origin: "other", Type: "test", Payload: `"two"`},
+	} {
+		if err := conn.Create(&e).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pollEvents()
+	pollEvents()
+	if len(got) != 2 || got[0] != `"one"` || got[1] != `"two"` {
+		t.Fatalf("dispatched %v, want [\"one\" \"two\"]", got)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	conn := openTestDB(t)
+
+	mu.Lock()
+	db, enabled, nodeID = conn, true, "self"
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		db, enabled, nodeID = nil, false, ""
+		mu.Unlock()
+	})
+
+	Publish("test", "one")
+	Publish("test", map[string]int{"two": 2})
+
+	var events []database.ClusterEvent
+	if err := conn.Order("id").Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Origin != "self" || events[0].Payload != `"one"` || events[1].Payload != `{"two":2}` {
+		t.Fatalf("published events = %+v", events)
+	}
+}
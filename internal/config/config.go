@@ -7,7 +7,11 @@ import (
 	"os"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/cluster"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/locale"
 	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/urls"
 	"gopkg.in/yaml.v2"
 )
 
@@ -47,6 +51,49 @@ func LoadAppConfig() error {
 	if err := yaml.Unmarshal(data, config); err != nil {
 		return fmt.Errorf("parse app config file failed: %v", err)
 	}
+	if config.HookEnv != nil {
+		if err := config.HookEnv.Validate(); err != nil {
+			return fmt.Errorf("invalid hook_env: %v", err)
+		}
+	}
+	if config.Scripts != nil {
+		if err := config.Scripts.Validate(); err != nil {
+			return fmt.Errorf("invalid scripts: %v", err)
+		}
+	}
+	names := map[string]bool{}
+	for i := range config.Consumers {
+		if err := config.Consumers[i].Validate(); err != nil {
+			return fmt.Errorf("invalid consumers: %v", err)
+		}
+		if names[config.Consumers[i].Name] {
+			return fmt.Errorf("invalid consumers: duplicate name %s", config.Consumers[i].Name)
+		}
+		names[config.Consumers[i].Name] = true
+	}
+
+	names = map[string]bool{}
+	for i := range config.LogForwarders {
+		if err := config.LogForwarders[i].Validate(); err != nil {
+			return fmt.Errorf("invalid log_forwarders: %v", err)
+		}
+		if names[config.LogForwarders[i].Name] {
+			return fmt.Errorf("invalid log_forwarders: duplicate name %s", config.LogForwarders[i].Name)
+		}
+		names[config.LogForwarders[i].Name] = true
+	}
+
+	if config.ChatOps != nil {
+		if err := config.ChatOps.Validate(); err != nil {
+			return fmt.Errorf("invalid chatops: %v", err)
+		}
+	}
+
+	if config.Notifications != nil {
+		if err := config.Notifications.Validate(); err != nil {
+			return fmt.Errorf("invalid notifications: %v", err)
+		}
+	}
 
 	types.GoHookAppConfig = config
 	return nil
@@ -67,6 +114,7 @@ func SaveAppConfig() error {
 		return fmt.Errorf("save app config file failed: %v", err)
 	}
 
+	cluster.ConfigChanged(cluster.ConfigApp)
 	return nil
 }
 
@@ -115,6 +163,7 @@ func SaveVersionConfig() error {
 		return fmt.Errorf("save version config file failed: %v", err)
 	}
 
+	cluster.ConfigChanged(cluster.ConfigVersion)
 	return nil
 }
 
@@ -138,7 +187,7 @@ func GetConfiguredPort() int {
 
 func HandleGetAppConfig(c *gin.Context) {
 	if types.GoHookAppConfig == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "App config not loaded"})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeAppConfigNotLoaded, nil)
 		return
 	}
 
@@ -147,5 +196,9 @@ func HandleGetAppConfig(c *gin.Context) {
 		"mode":        types.GoHookAppConfig.Mode,
 		"panel_alias": types.GoHookAppConfig.PanelAlias,
 		"language":    types.GoHookAppConfig.Language,
+		"timezone":    locale.Server().String(),
+		// URL layout, for building hook URLs in the panel
+		"base_path":        urls.Current().BasePath,
+		"hook_url_pattern": urls.Current().HumanPattern(),
 	})
 }
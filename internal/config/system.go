@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/mycoool/gohook/internal/locale"
 	"gopkg.in/yaml.v2"
 )
 
@@ -14,6 +15,7 @@ type SystemConfig struct {
 	Mode              string `yaml:"mode" json:"mode"`
 	PanelAlias        string `yaml:"panel_alias" json:"panel_alias"` // 面板别名，用于浏览器标题
 	Language          string `yaml:"language" json:"language"`       // 界面语言: zh | en
+	Timezone          string `yaml:"timezone" json:"timezone"`       // 时区（IANA），为空时使用系统时区
 }
 
 const configFilePath = "app.yaml"
@@ -82,6 +84,10 @@ func SaveSystemConfig(config *SystemConfig) error {
 	if config.Language != "zh" && config.Language != "en" {
 		return fmt.Errorf("language must be either 'zh' or 'en'")
 	}
+	// validate timezone: empty uses the system zone
+	if _, err := locale.LoadZone(config.Timezone); err != nil {
+		return err
+	}
 
 	// read existing complete config file
 	var existingConfig map[string]interface{}
@@ -105,6 +111,11 @@ func SaveSystemConfig(config *SystemConfig) error {
 	existingConfig["mode"] = config.Mode
 	existingConfig["panel_alias"] = config.PanelAlias
 	existingConfig["language"] = config.Language
+	if config.Timezone != "" {
+		existingConfig["timezone"] = config.Timezone
+	} else {
+		delete(existingConfig, "timezone")
+	}
 
 	// ensure port field exists and is valid
 	if _, exists := existingConfig["port"]; !exists {
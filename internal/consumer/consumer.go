@@ -0,0 +1,372 @@
+// Package consumer subscribes to Kafka topics, NATS subjects, Redis streams and MQTT topics, polls
+// IMAP mailboxes and delivers every message to a hook through the same endpoint that serves HTTP
+// webhooks, so trigger rules, argument extraction, idempotency and maintenance pauses apply
+// unchanged.
+package consumer
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/urls"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+// defaultGroup consumer group used when a consumer does not name one
+const defaultGroup = "gohook"
+
+// reconnect delays after a failed connection
+const (
+	minBackoff = 2 * time.Second
+	maxBackoff = time.Minute
+)
+
+// dialTimeout limit for connecting to a server
+const dialTimeout = 10 * time.Second
+
+// consumer states reported by the status API
+const (
+	StateConnecting   = "connecting"
+	StateConnected    = "connected"
+	StateDisconnected = "disconnected"
+	StateDisabled     = "disabled"
+	StateStandby      = "standby" // another instance is the leader and consumes
+)
+
+// Message one message read from a queue
+type Message struct {
+	ID      string            // redis entry id, kafka partition/offset, mail message id, empty for nats and mqtt
+	Subject string            // topic, subject or stream the message was read from
+	Headers map[string]string // kafka record headers, nats headers or redis entry fields
+	Body    []byte
+	Hook    string // hook of the mqtt route or imap rule the message matched, empty for the consumer hook
+}
+
+// source subscription of one consumer type. consume connects, calls w.connected once
+// subscribed and w.deliver for every message until ctx is done or the connection fails.
+// A message is acknowledged only after deliver returned nil.
+type source interface {
+	consume(ctx context.Context, w *worker) error
+}
+
+// Status state of a consumer on this instance
+type Status struct {
+	Name          string                `json:"name"`
+	Type          string                `json:"type"`
+	Topic         string                `json:"topic"`
+	Hook          string                `json:"hook"`
+	Routes        []types.ConsumerRoute `json:"routes,omitempty"`
+	State         string                `json:"state"`
+	Delivered     int64                 `json:"delivered"`
+	Failed        int64                 `json:"failed"`
+	LastMessageAt *time.Time            `json:"lastMessageAt,omitempty"`
+	LastError     string                `json:"lastError,omitempty"`
+	LastErrorAt   *time.Time            `json:"lastErrorAt,omitempty"`
+}
+
+// worker runs one consumer and keeps its status
+type worker struct {
+	cfg types.ConsumerConfig
+
+	mu     sync.Mutex
+	status Status
+}
+
+var (
+	mu      sync.Mutex
+	workers map[string]*worker
+	running context.Context    // leader context passed to Start, nil while not the leader
+	cancel  context.CancelFunc // stops the workers of the current configuration
+)
+
+// Start run the configured consumers until ctx is done, registered as a leader task so a
+// message is consumed by one instance of a cluster only
+func Start(ctx context.Context) {
+	mu.Lock()
+	running = ctx
+	mu.Unlock()
+	startWorkers()
+
+	go func() {
+		<-ctx.Done()
+		mu.Lock()
+		defer mu.Unlock()
+		if running == ctx {
+			running, workers, cancel = nil, nil, nil
+		}
+	}()
+}
+
+// Reload restart the consumers with the current app configuration, it does nothing while
+// this instance is not the leader
+func Reload() {
+	startWorkers()
+}
+
+func startWorkers() {
+	mu.Lock()
+	defer mu.Unlock()
+	if running == nil {
+		return
+	}
+	if cancel != nil {
+		cancel()
+	}
+	var ctx context.Context
+	ctx, cancel = context.WithCancel(running)
+
+	workers = map[string]*worker{}
+	for _, cfg := range configured() {
+		w := &worker{cfg: cfg, status: newStatus(cfg)}
+		workers[cfg.Name] = w
+		if cfg.Disabled {
+			w.status.State = StateDisabled
+			continue
+		}
+		go w.run(ctx)
+	}
+}
+
+func configured() []types.ConsumerConfig {
+	if types.GoHookAppConfig == nil {
+		return nil
+	}
+	return types.GoHookAppConfig.Consumers
+}
+
+func newStatus(cfg types.ConsumerConfig) Status {
+	return Status{Name: cfg.Name, Type: cfg.Type, Topic: cfg.Topic, Hook: cfg.Hook, Routes: cfg.Routes, State: StateConnecting}
+}
+
+func newSource(cfg types.ConsumerConfig) source {
+	switch cfg.Type {
+	case types.ConsumerKafka:
+		return &kafkaSource{cfg: cfg}
+	case types.ConsumerNATS:
+		return &natsSource{cfg: cfg}
+	case types.ConsumerMQTT:
+		return &mqttSource{cfg: cfg}
+	case types.ConsumerIMAP:
+		return &imapSource{cfg: cfg}
+	default:
+		return &redisSource{cfg: cfg}
+	}
+}
+
+// run consume until ctx is done, reconnecting with a growing delay
+func (w *worker) run(ctx context.Context) {
+	backoff := minBackoff
+	for {
+		w.setState(StateConnecting)
+		err := newSource(w.cfg).consume(ctx, w)
+		if ctx.Err() != nil {
+			return
+		}
+		if w.state() == StateConnected {
+			// the connection worked for a while, retry quickly
+			backoff = minBackoff
+		}
+		w.setState(StateDisconnected)
+		w.setError(err)
+		log.Printf("consumer %s: %v, reconnecting in %s", w.cfg.Name, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// connected called by the sources once subscribed
+func (w *worker) connected() {
+	w.setState(StateConnected)
+	topic := w.cfg.Topic
+	for _, r := range w.cfg.Routes {
+		topic = strings.TrimPrefix(topic+", "+r.Topic, ", ")
+	}
+	log.Printf("consumer %s: subscribed to %s %s", w.cfg.Name, w.cfg.Type, topic)
+}
+
+// deliver pass m to the hook endpoint. Hooks that reject or fail the delivery are counted
+// as failed and the message is still acknowledged, an error is returned only when the
+// endpoint could not be called and the message must be read again.
+func (w *worker) deliver(m Message) error {
+	headers := map[string]string{}
+	for name, value := range m.Headers {
+		headers[name] = value
+	}
+	if _, ok := lookupHeader(headers, "Content-Type"); !ok {
+		headers["Content-Type"] = "text/plain"
+		if json.Valid(m.Body) {
+			headers["Content-Type"] = "application/json"
+		}
+	}
+	headers["X-Gohook-Consumer"] = w.cfg.Name
+	headers["X-Gohook-Subject"] = m.Subject
+	if m.ID != "" {
+		headers["X-Gohook-Message-Id"] = m.ID
+	}
+
+	hook := w.cfg.Hook
+	if m.Hook != "" {
+		hook = m.Hook
+	}
+	result, err := webhook.SendTestDelivery(&webhook.TestDelivery{
+		Method:  http.MethodPost,
+		URL:     urls.Current().HookPath(hook),
+		Headers: headers,
+		Body:    string(m.Body),
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status.LastMessageAt = &now
+	if result.Status >= http.StatusBadRequest {
+		w.status.Failed++
+		w.status.LastError = fmt.Sprintf("hook %s answered %d: %s", hook, result.Status, truncate(strings.TrimSpace(result.Body), 200))
+		w.status.LastErrorAt = &now
+		log.Printf("consumer %s: %s", w.cfg.Name, w.status.LastError)
+		return nil
+	}
+	w.status.Delivered++
+	return nil
+}
+
+func (w *worker) setState(state string) {
+	w.mu.Lock()
+	w.status.State = state
+	w.mu.Unlock()
+}
+
+func (w *worker) state() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status.State
+}
+
+func (w *worker) setError(err error) {
+	if err == nil {
+		return
+	}
+	now := time.Now()
+	w.mu.Lock()
+	w.status.LastError = err.Error()
+	w.status.LastErrorAt = &now
+	w.mu.Unlock()
+}
+
+// group consumer group of the worker
+func (w *worker) group() string {
+	if w.cfg.Group != "" {
+		return w.cfg.Group
+	}
+	return defaultGroup
+}
+
+// Statuses state of every configured consumer, consumers run by another instance are standby
+func Statuses() []Status {
+	mu.Lock()
+	defer mu.Unlock()
+	list := []Status{}
+	for _, cfg := range configured() {
+		if w, ok := workers[cfg.Name]; ok {
+			w.mu.Lock()
+			list = append(list, w.status)
+			w.mu.Unlock()
+			continue
+		}
+		s := newStatus(cfg)
+		s.State = StateStandby
+		if cfg.Disabled {
+			s.State = StateDisabled
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+// HandleListConsumers list the configured consumers and their state
+func HandleListConsumers(c *gin.Context) {
+	c.JSON(http.StatusOK, Statuses())
+}
+
+// dial connect to the first reachable of servers and return the address connected to
+func dial(ctx context.Context, servers []string, useTLS bool) (net.Conn, string, error) {
+	var lastErr error
+	for _, server := range servers {
+		conn, err := dialServer(ctx, server, useTLS)
+		if err == nil {
+			return conn, server, nil
+		}
+		lastErr = err
+	}
+	return nil, "", lastErr
+}
+
+func dialServer(ctx context.Context, server string, useTLS bool) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}
+	if !useTLS {
+		return dialer.DialContext(ctx, "tcp", server)
+	}
+	host, _, _ := net.SplitHostPort(server)
+	return (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", server)
+}
+
+// closeOnDone close conn when ctx is done so blocking reads return, call the returned
+// function once the connection is no longer used
+func closeOnDone(ctx context.Context, conn net.Conn) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+	}()
+	return func() { close(done) }
+}
+
+// consumerName name this instance uses in redis consumer groups and as kafka client id
+func consumerName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	return "gohook-" + host
+}
+
+func lookupHeader(headers map[string]string, name string) (string, bool) {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,446 @@
+package consumer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+	"golang.org/x/net/html"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// defaultMailbox mailbox polled when an imap consumer does not name one
+const defaultMailbox = "INBOX"
+
+// mail size limits, text bodies are cut after maxMailBody bytes
+const (
+	maxMailSize = 64 << 20
+	maxMailBody = 1 << 20
+)
+
+// imapLiteralPattern literal announced at the end of a response line, e.g. BODY[] {1234}
+var imapLiteralPattern = regexp.MustCompile(`\{(\d+)\}\r\n$`)
+
+// imapUIDPattern UID of a FETCH response
+var imapUIDPattern = regexp.MustCompile(`\bUID (\d+)`)
+
+// mailPayload JSON body delivered to the hook for a mail
+type mailPayload struct {
+	From      string            `json:"from"`
+	FromName  string            `json:"fromName,omitempty"`
+	To        []string          `json:"to"`
+	Subject   string            `json:"subject"`
+	Date      string            `json:"date,omitempty"`
+	MessageID string            `json:"messageId,omitempty"`
+	Body      string            `json:"body"`
+	Fields    map[string]string `json:"fields"` // named groups of the matching rule
+}
+
+// mailRule compiled types.ConsumerMailRule
+type mailRule struct {
+	from, subject, body *regexp.Regexp
+	hook                string
+}
+
+// compileMailRule compile the expressions of a rule, validated when the config was loaded
+func compileMailRule(r types.ConsumerMailRule) mailRule {
+	return mailRule{
+		from:    regexp.MustCompile(r.From),
+		subject: regexp.MustCompile(r.Subject),
+		body:    regexp.MustCompile(r.Body),
+		hook:    r.Hook,
+	}
+}
+
+// imapSource polls a mailbox for unseen mails, delivers the mails matching a rule and marks
+// them seen. Mails matching no rule stay unseen for the people reading the mailbox.
+type imapSource struct {
+	cfg   types.ConsumerConfig
+	rules []mailRule
+
+	r   *bufio.Reader
+	w   io.Writer
+	tag int
+}
+
+func (s *imapSource) consume(ctx context.Context, w *worker) error {
+	s.rules = s.rules[:0]
+	for _, r := range s.cfg.Rules {
+		s.rules = append(s.rules, compileMailRule(r))
+	}
+
+	conn, _, err := dial(ctx, s.cfg.Servers, s.cfg.TLS)
+	if err != nil {
+		return err
+	}
+	defer closeOnDone(ctx, conn)()
+	s.r, s.w = bufio.NewReader(conn), conn
+
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	greeting, _, err := s.readLine()
+	if err != nil {
+		return fmt.Errorf("imap: read greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		return fmt.Errorf("imap: unexpected greeting %q", strings.TrimSpace(greeting))
+	}
+	if !strings.HasPrefix(greeting, "* PREAUTH") {
+		user, err := imapQuote(s.cfg.Username)
+		if err != nil {
+			return err
+		}
+		pass, err := imapQuote(s.cfg.Password)
+		if err != nil {
+			return err
+		}
+		if _, err := s.command("LOGIN " + user + " " + pass); err != nil {
+			return fmt.Errorf("imap login: %w", err)
+		}
+	}
+	mailbox, err := imapQuote(s.mailbox())
+	if err != nil {
+		return err
+	}
+	if _, err := s.command("SELECT " + mailbox); err != nil {
+		return fmt.Errorf("imap select %s: %w", s.mailbox(), err)
+	}
+	w.connected()
+
+	skipped := map[uint32]bool{}
+	for {
+		conn.SetDeadline(time.Now().Add(dialTimeout))
+		if err := s.poll(w, skipped, conn.SetDeadline); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.cfg.PollInterval()):
+		}
+	}
+}
+
+// poll deliver the unseen mails matching a rule
+func (s *imapSource) poll(w *worker, skipped map[uint32]bool, setDeadline func(time.Time) error) error {
+	lines, err := s.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return fmt.Errorf("imap search: %w", err)
+	}
+	var uids []uint32
+	for _, l := range lines {
+		if rest, ok := strings.CutPrefix(l.text, "* SEARCH"); ok {
+			for _, f := range strings.Fields(rest) {
+				if uid, err := strconv.ParseUint(f, 10, 32); err == nil && !skipped[uint32(uid)] {
+					uids = append(uids, uint32(uid))
+				}
+			}
+		}
+	}
+
+	for _, uid := range uids {
+		setDeadline(time.Now().Add(dialTimeout))
+		raw, err := s.fetch(uid)
+		if err != nil {
+			return err
+		}
+		payload, err := parseMail(raw)
+		if err != nil {
+			// not worth retrying, leave it for a human
+			w.setError(fmt.Errorf("imap: mail %d: %v", uid, err))
+			skipped[uid] = true
+			continue
+		}
+		hook, ok := s.match(payload)
+		if !ok {
+			skipped[uid] = true
+			continue
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		id := payload.MessageID
+		if id == "" {
+			id = fmt.Sprintf("%s/%d", s.mailbox(), uid)
+		}
+		m := Message{ID: id, Subject: s.mailbox(), Headers: map[string]string{"Content-Type": "application/json"}, Body: body, Hook: hook}
+		if err := w.deliver(m); err != nil {
+			return err
+		}
+		setDeadline(time.Now().Add(dialTimeout))
+		if _, err := s.command(fmt.Sprintf(`UID STORE %d +FLAGS.SILENT (\Seen)`, uid)); err != nil {
+			return fmt.Errorf("imap store: %w", err)
+		}
+	}
+	return nil
+}
+
+// fetch the whole message without setting the seen flag
+func (s *imapSource) fetch(uid uint32) ([]byte, error) {
+	lines, err := s.command(fmt.Sprintf("UID FETCH %d (UID BODY.PEEK[])", uid))
+	if err != nil {
+		return nil, fmt.Errorf("imap fetch: %w", err)
+	}
+	for _, l := range lines {
+		if m := imapUIDPattern.FindStringSubmatch(l.text); m != nil && m[1] == strconv.FormatUint(uint64(uid), 10) && len(l.literals) > 0 {
+			return l.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("imap fetch: mail %d missing in response", uid)
+}
+
+// match hook of the first rule matching p and its named groups in p.Fields, the consumer hook
+// without rules
+func (s *imapSource) match(p *mailPayload) (string, bool) {
+	p.Fields = map[string]string{}
+	if len(s.rules) == 0 {
+		return s.cfg.Hook, true
+	}
+	for _, r := range s.rules {
+		fields := map[string]string{}
+		if matchNamed(r.from, p.From, fields) && matchNamed(r.subject, p.Subject, fields) && matchNamed(r.body, p.Body, fields) {
+			p.Fields = fields
+			return r.hook, true
+		}
+	}
+	return "", false
+}
+
+// matchNamed match re against s and add its named groups to fields
+func matchNamed(re *regexp.Regexp, s string, fields map[string]string) bool {
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		return false
+	}
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			fields[name] = m[i]
+		}
+	}
+	return true
+}
+
+func (s *imapSource) mailbox() string {
+	if s.cfg.Topic != "" {
+		return s.cfg.Topic
+	}
+	return defaultMailbox
+}
+
+// imapLine response line with the literals it carried
+type imapLine struct {
+	text     string
+	literals [][]byte
+}
+
+// command send a command and return its untagged responses, an error unless it completed OK
+func (s *imapSource) command(cmd string) ([]imapLine, error) {
+	s.tag++
+	tag := "g" + strconv.Itoa(s.tag)
+	if _, err := io.WriteString(s.w, tag+" "+cmd+"\r\n"); err != nil {
+		return nil, err
+	}
+	var lines []imapLine
+	for {
+		text, literals, err := s.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if status, ok := strings.CutPrefix(text, tag+" "); ok {
+			if !strings.HasPrefix(status, "OK") {
+				return nil, fmt.Errorf("%s", strings.TrimSpace(status))
+			}
+			return lines, nil
+		}
+		lines = append(lines, imapLine{text: text, literals: literals})
+	}
+}
+
+// readLine read a response line, literals are read with it and left out of the text
+func (s *imapSource) readLine() (string, [][]byte, error) {
+	var text strings.Builder
+	var literals [][]byte
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			return "", nil, err
+		}
+		m := imapLiteralPattern.FindStringSubmatch(line)
+		if m == nil {
+			text.WriteString(strings.TrimRight(line, "\r\n"))
+			return text.String(), literals, nil
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n > maxMailSize {
+			return "", nil, fmt.Errorf("imap: invalid literal size %s", m[1])
+		}
+		literal := make([]byte, n)
+		if _, err := io.ReadFull(s.r, literal); err != nil {
+			return "", nil, err
+		}
+		text.WriteString(strings.TrimSuffix(line, m[0]))
+		literals = append(literals, literal)
+	}
+}
+
+// imapQuote quote s as an IMAP string
+func imapQuote(s string) (string, error) {
+	for _, c := range s {
+		if c == '\r' || c == '\n' || c > 0x7e {
+			return "", fmt.Errorf("imap: %q cannot be sent as a quoted string", s)
+		}
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`, nil
+}
+
+// mailCharsetReader decode headers in the charsets known to the html index, such as GBK
+func mailCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// parseMail sender, recipients, subject and text body of an RFC 5322 message
+func parseMail(raw []byte) (*mailPayload, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	dec := &mime.WordDecoder{CharsetReader: mailCharsetReader}
+	parser := &mail.AddressParser{WordDecoder: dec}
+
+	p := &mailPayload{To: []string{}, Fields: map[string]string{}}
+	if from, err := parser.Parse(msg.Header.Get("From")); err == nil {
+		p.From, p.FromName = from.Address, from.Name
+	} else {
+		p.From = strings.TrimSpace(msg.Header.Get("From"))
+	}
+	if to, err := parser.ParseList(msg.Header.Get("To")); err == nil {
+		for _, a := range to {
+			p.To = append(p.To, a.Address)
+		}
+	}
+	if p.Subject, err = dec.DecodeHeader(msg.Header.Get("Subject")); err != nil {
+		p.Subject = msg.Header.Get("Subject")
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		p.Date = date.Format(time.RFC3339)
+	}
+	p.MessageID = strings.Trim(strings.TrimSpace(msg.Header.Get("Message-Id")), "<>")
+
+	body, err := textBody(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	p.Body = strings.TrimSpace(body)
+	return p, nil
+}
+
+// textBody text of a message part, the first text/plain part of a multipart message or the
+// text of an HTML part when there is none; attachments are skipped
+func textBody(contentType, transferEncoding string, r io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	switch strings.ToLower(strings.TrimSpace(transferEncoding)) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(r, params["boundary"])
+		htmlText := ""
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+			if disposition, _, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition")); disposition == "attachment" {
+				continue
+			}
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			text, err := textBody(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return "", err
+			}
+			if partType == "text/html" {
+				if htmlText == "" {
+					htmlText = text
+				}
+				continue
+			}
+			if text != "" {
+				return text, nil
+			}
+		}
+		return htmlText, nil
+	}
+	if !strings.HasPrefix(mediaType, "text/") {
+		return "", nil
+	}
+
+	if charset := params["charset"]; charset != "" && !strings.EqualFold(charset, "utf-8") && !strings.EqualFold(charset, "us-ascii") {
+		if decoded, err := mailCharsetReader(charset, r); err == nil {
+			r = decoded
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxMailBody))
+	if err != nil {
+		return "", err
+	}
+	if mediaType == "text/html" {
+		return htmlToText(data), nil
+	}
+	return string(data), nil
+}
+
+// htmlToText visible text of an HTML document, one line per text node
+func htmlToText(data []byte) string {
+	z := html.NewTokenizer(bytes.NewReader(data))
+	var b strings.Builder
+	skip := 0
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return b.String()
+		case html.StartTagToken:
+			if name, _ := z.TagName(); string(name) == "script" || string(name) == "style" {
+				skip++
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); (string(name) == "script" || string(name) == "style") && skip > 0 {
+				skip--
+			}
+		case html.TextToken:
+			if text := strings.TrimSpace(string(z.Text())); text != "" && skip == 0 {
+				b.WriteString(text)
+				b.WriteString("\n")
+			}
+		}
+	}
+}
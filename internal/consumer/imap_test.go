@@ -0,0 +1,236 @@
+package consumer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+const testAlertMail = "From: =?UTF-8?B?55uR5o6n?= <alerts@legacy.example.com>\r\n" +
+	"To: ops@example.com, oncall@example.com\r\n" +
+	"Subject: =?GBK?B?tMXFzLjmvq8=?= DISK web01 critical\r\n" +
+	"Date: Mon, 05 Oct 2026 08:30:00 +0000\r\n" +
+	"Message-ID: <alert-1@legacy.example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=b1\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: multipart/alternative; boundary=b2\r\n" +
+	"\r\n" +
+	"--b2\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>html version</p>\r\n" +
+	"--b2\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"TW91bnQ6IC92YXIKVXNlZDogOTkl\r\n" +
+	"--b2--\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"Content-Disposition: attachment; filename=log.txt\r\n" +
+	"\r\n" +
+	"attached log\r\n" +
+	"--b1--\r\n"
+
+func TestParseMail(t *testing.T) {
+	p, err := parseMail([]byte(testAlertMail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.From != "alerts@legacy.example.com" || p.FromName != "监控" {
+		t.Errorf("from = %q %q", p.From, p.FromName)
+	}
+	if strings.Join(p.To, ",") != "ops@example.com,oncall@example.com" {
+		t.Errorf("to = %v", p.To)
+	}
+	if p.Subject != "磁盘告警 DISK web01 critical" {
+		t.Errorf("subject = %q", p.Subject)
+	}
+	if p.Body != "Mount: /var\nUsed: 99%" {
+		t.Errorf("body = %q", p.Body)
+	}
+	if p.MessageID != "alert-1@legacy.example.com" || p.Date != "2026-10-05T08:30:00Z" {
+		t.Errorf("message id %q date %q", p.MessageID, p.Date)
+	}
+
+	html := "Subject: report\r\nContent-Type: text/html; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n" +
+		"<html><style>p{}</style><body><p>Build =E2=9C=93</p><p>done</p></body></html>\r\n"
+	if p, err = parseMail([]byte(html)); err != nil || p.Body != "Build ✓\ndone" {
+		t.Errorf("html body = %q, %v", p.Body, err)
+	}
+}
+
+func TestMailRules(t *testing.T) {
+	cfg := types.ConsumerConfig{Rules: []types.ConsumerMailRule{
+		{From: `@other\.example\.com$`, Hook: "other"},
+		{From: `^alerts@legacy\.example\.com$`, Subject: `DISK (?P<host>\S+) (?P<level>\w+)`, Body: `Mount: (?P<mount>\S+)`, Hook: "disk"},
+	}}
+	p, err := parseMail([]byte(testAlertMail))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		edit   func(p mailPayload) mailPayload
+		hook   string
+		fields map[string]string
+	}{
+		{"all expressions match", func(p mailPayload) mailPayload { return p }, "disk",
+			map[string]string{"host": "web01", "level": "critical", "mount": "/var"}},
+		{"first rule wins", func(p mailPayload) mailPayload { p.From = "x@other.example.com"; return p }, "other", map[string]string{}},
+		{"body does not match", func(p mailPayload) mailPayload { p.Body = "nothing"; return p }, "", nil},
+		{"unknown sender", func(p mailPayload) mailPayload { p.From = "spam@example.org"; return p }, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &imapSource{cfg: cfg}
+			for _, r := range cfg.Rules {
+				s.rules = append(s.rules, compileMailRule(r))
+			}
+			payload := tt.edit(*p)
+			hook, ok := s.match(&payload)
+			if hook != tt.hook || ok != (tt.hook != "") {
+				t.Fatalf("match = %q %v, want %q", hook, ok, tt.hook)
+			}
+			for k, v := range tt.fields {
+				if payload.Fields[k] != v {
+					t.Errorf("field %s = %q, want %q", k, payload.Fields[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateIMAPConsumer(t *testing.T) {
+	base := types.ConsumerConfig{Name: "mail", Type: types.ConsumerIMAP, Servers: []string{"imap.example.com:993"}, TLS: true}
+	tests := []struct {
+		name    string
+		edit    func(c *types.ConsumerConfig)
+		wantErr bool
+	}{
+		{"hook without topic", func(c *types.ConsumerConfig) { c.Hook = "mail" }, false},
+		{"rules", func(c *types.ConsumerConfig) {
+			c.Interval = "30s"
+			c.Rules = []types.ConsumerMailRule{{Subject: `^ALERT (?P<host>\S+)`, Hook: "alert"}}
+		}, false},
+		{"rules and hook", func(c *types.ConsumerConfig) {
+			c.Hook = "mail"
+			c.Rules = []types.ConsumerMailRule{{From: "a", Hook: "a"}}
+		}, true},
+		{"rule without hook", func(c *types.ConsumerConfig) { c.Rules = []types.ConsumerMailRule{{From: "a"}} }, true},
+		{"invalid expression", func(c *types.ConsumerConfig) { c.Rules = []types.ConsumerMailRule{{Body: "(", Hook: "a"}} }, true},
+		{"interval too short", func(c *types.ConsumerConfig) { c.Hook, c.Interval = "mail", "1s" }, true},
+		{"rules on nats", func(c *types.ConsumerConfig) {
+			c.Type, c.Topic, c.Hook = types.ConsumerNATS, "a", "a"
+			c.Rules = []types.ConsumerMailRule{{From: "a", Hook: "a"}}
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := base
+			tt.edit(&c)
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestIMAPDelivery poll a fake server, deliver the matching mail and mark only it seen
+func TestIMAPDelivery(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	stored := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "* OK IMAP4rev1 ready\r\n")
+		r := bufio.NewReader(conn)
+		other := "From: someone@example.org\r\nSubject: hello\r\n\r\nhi\r\n"
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			tag, cmd, _ := strings.Cut(strings.TrimSpace(line), " ")
+			switch {
+			case cmd == `LOGIN "gohook" "p\"w"`, strings.HasPrefix(cmd, "SELECT"):
+			case cmd == "UID SEARCH UNSEEN":
+				io.WriteString(conn, "* SEARCH 41 42\r\n")
+			case cmd == "UID FETCH 41 (UID BODY.PEEK[])":
+				fmt.Fprintf(conn, "* 1 FETCH (UID 41 BODY[] {%d}\r\n%s)\r\n", len(other), other)
+			case cmd == "UID FETCH 42 (UID BODY.PEEK[])":
+				fmt.Fprintf(conn, "* 2 FETCH (UID 42 BODY[] {%d}\r\n%s)\r\n", len(testAlertMail), testAlertMail)
+			case strings.HasPrefix(cmd, "UID STORE"):
+				stored <- cmd
+			default:
+				fmt.Fprintf(conn, "%s BAD unexpected %s\r\n", tag, cmd)
+				continue
+			}
+			fmt.Fprintf(conn, "%s OK done\r\n", tag)
+		}
+	}()
+
+	delivered := make(chan *http.Request, 2)
+	webhook.SetHookEndpoint(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Header.Set("Body", string(body))
+		delivered <- r
+	}))
+	defer webhook.SetHookEndpoint(nil)
+
+	cfg := types.ConsumerConfig{
+		Name: "mail", Type: types.ConsumerIMAP, Servers: []string{ln.Addr().String()}, Username: "gohook", Password: `p"w`,
+		Rules: []types.ConsumerMailRule{{From: `legacy\.example\.com$`, Subject: `DISK (?P<host>\S+)`, Hook: "disk"}},
+	}
+	w := &worker{cfg: cfg, status: newStatus(cfg)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.run(ctx)
+
+	select {
+	case r := <-delivered:
+		var p mailPayload
+		if err := json.Unmarshal([]byte(r.Header.Get("Body")), &p); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(r.URL.Path, "/disk") || p.Fields["host"] != "web01" ||
+			r.Header.Get("X-Gohook-Message-Id") != "alert-1@legacy.example.com" || r.Header.Get("Content-Type") != "application/json" {
+			t.Fatalf("unexpected delivery %s %v %+v", r.URL, r.Header, p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("mail not delivered, consumer state %s: %s", w.state(), w.status.LastError)
+	}
+	select {
+	case cmd := <-stored:
+		if cmd != `UID STORE 42 +FLAGS.SILENT (\Seen)` {
+			t.Fatalf("unexpected store %q", cmd)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivered mail not marked seen")
+	}
+	select {
+	case cmd := <-stored:
+		t.Fatalf("mail matching no rule must stay unseen, got %q", cmd)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
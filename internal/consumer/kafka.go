@@ -0,0 +1,717 @@
+package consumer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// kafka API keys, the client speaks the versions noted, supported by brokers since 1.0
+const (
+	kafkaFetch            = 1  // v4
+	kafkaListOffsets      = 2  // v1
+	kafkaMetadata         = 3  // v1
+	kafkaOffsetCommit     = 8  // v2
+	kafkaOffsetFetch      = 9  // v1
+	kafkaFindCoordinator  = 10 // v0
+	kafkaSaslHandshake    = 17 // v1
+	kafkaSaslAuthenticate = 36 // v0
+)
+
+// kafka limits of a fetch
+const (
+	kafkaMaxWait           = 5 * time.Second
+	kafkaMaxBytes          = 16 << 20
+	kafkaPartitionMaxBytes = 1 << 20
+	kafkaMaxResponse       = 64 << 20
+)
+
+// ListOffsets timestamps of the latest and the earliest offset
+const (
+	kafkaLatest   = -1
+	kafkaEarliest = -2
+)
+
+// kafkaError error code of a kafka response
+type kafkaError int16
+
+// kafka error codes the consumer handles
+const (
+	kafkaOffsetOutOfRange kafkaError = 1
+)
+
+var kafkaErrorNames = map[kafkaError]string{
+	1:  "OFFSET_OUT_OF_RANGE",
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	14: "COORDINATOR_LOAD_IN_PROGRESS",
+	15: "COORDINATOR_NOT_AVAILABLE",
+	16: "NOT_COORDINATOR",
+	22: "ILLEGAL_GENERATION",
+	25: "UNKNOWN_MEMBER_ID",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	30: "GROUP_AUTHORIZATION_FAILED",
+	33: "UNSUPPORTED_SASL_MECHANISM",
+	58: "SASL_AUTHENTICATION_FAILED",
+}
+
+func (e kafkaError) Error() string {
+	if name, ok := kafkaErrorNames[e]; ok {
+		return "kafka: " + name
+	}
+	return fmt.Sprintf("kafka: error code %d", int16(e))
+}
+
+// errKafkaShort a response ended before all its fields were read
+var errKafkaShort = errors.New("kafka: short response")
+
+// kafkaEncoder builds request bodies
+type kafkaEncoder struct {
+	bytes.Buffer
+}
+
+func (e *kafkaEncoder) int8(v int8)   { e.WriteByte(byte(v)) }
+func (e *kafkaEncoder) int16(v int16) { e.Write(binary.BigEndian.AppendUint16(nil, uint16(v))) }
+func (e *kafkaEncoder) int32(v int32) { e.Write(binary.BigEndian.AppendUint32(nil, uint32(v))) }
+func (e *kafkaEncoder) int64(v int64) { e.Write(binary.BigEndian.AppendUint64(nil, uint64(v))) }
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.WriteString(s)
+}
+
+func (e *kafkaEncoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.Write(b)
+}
+
+// kafkaDecoder reads response fields, the first error sticks and later reads return zero values
+type kafkaDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *kafkaDecoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.b) < n {
+		d.err = errKafkaShort
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.take(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string a string or nullable string, null reads as empty
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+// bytes a nullable byte array
+func (d *kafkaDecoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.take(int(n))
+}
+
+// arrayLen length of an array, null arrays are empty
+func (d *kafkaDecoder) arrayLen() int {
+	n := d.int32()
+	if n < 0 || d.err != nil {
+		return 0
+	}
+	// every element takes at least one byte
+	if int(n) > len(d.b) {
+		d.err = errKafkaShort
+		return 0
+	}
+	return int(n)
+}
+
+// kafkaConn connection to one broker
+type kafkaConn struct {
+	conn        net.Conn
+	r           *bufio.Reader
+	stop        func()
+	correlation int32
+}
+
+// call send a request and return the decoder of the response body
+func (c *kafkaConn) call(apiKey, version int16, body []byte, timeout time.Duration) (*kafkaDecoder, error) {
+	c.correlation++
+	var req kafkaEncoder
+	req.int32(0) // size, filled in below
+	req.int16(apiKey)
+	req.int16(version)
+	req.int32(c.correlation)
+	req.string(consumerName())
+	req.Write(body)
+	msg := req.Bytes()
+	binary.BigEndian.PutUint32(msg, uint32(len(msg)-4))
+
+	c.conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := c.conn.Write(msg); err != nil {
+		return nil, err
+	}
+	var header [8]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return nil, err
+	}
+	size := int32(binary.BigEndian.Uint32(header[:4]))
+	if size < 4 || size > kafkaMaxResponse {
+		return nil, fmt.Errorf("kafka: invalid response size %d", size)
+	}
+	if id := int32(binary.BigEndian.Uint32(header[4:])); id != c.correlation {
+		return nil, fmt.Errorf("kafka: response %d does not match request %d", id, c.correlation)
+	}
+	resp := make([]byte, size-4)
+	if _, err := io.ReadFull(c.r, resp); err != nil {
+		return nil, err
+	}
+	return &kafkaDecoder{b: resp}, nil
+}
+
+// kafkaRecord a record of a fetched batch
+type kafkaRecord struct {
+	offset  int64
+	key     []byte
+	value   []byte
+	headers map[string]string
+}
+
+// kafkaSource consumes all partitions of a topic and commits the offsets of delivered records
+// to a consumer group without joining it, the group must not be used by other consumers
+type kafkaSource struct {
+	cfg types.ConsumerConfig
+
+	conns   map[string]*kafkaConn // by broker address
+	brokers map[int32]string      // node id -> address
+	leaders map[int32]int32       // partition -> leader node id
+	offsets map[int32]int64       // partition -> next offset to read
+	coord   *kafkaConn
+}
+
+func (s *kafkaSource) consume(ctx context.Context, w *worker) error {
+	s.conns = map[string]*kafkaConn{}
+	defer func() {
+		for _, c := range s.conns {
+			c.stop()
+		}
+	}()
+
+	conn, server, err := dial(ctx, s.cfg.Servers, s.cfg.TLS)
+	if err != nil {
+		return err
+	}
+	bootstrap, err := s.register(ctx, server, conn)
+	if err != nil {
+		return err
+	}
+	if err := s.loadMetadata(bootstrap); err != nil {
+		return err
+	}
+	if err := s.findCoordinator(ctx, bootstrap, w.group()); err != nil {
+		return err
+	}
+	if err := s.loadOffsets(ctx, w.group()); err != nil {
+		return err
+	}
+	w.connected()
+
+	for ctx.Err() == nil {
+		byLeader := map[int32][]int32{}
+		for partition, leader := range s.leaders {
+			byLeader[leader] = append(byLeader[leader], partition)
+		}
+		wait := kafkaMaxWait / time.Duration(len(byLeader))
+		for leader, partitions := range byLeader {
+			sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+			if err := s.fetch(ctx, w, leader, partitions, wait); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// register set up a new broker connection, authenticating with SASL/PLAIN when a user is set
+func (s *kafkaSource) register(ctx context.Context, addr string, conn net.Conn) (*kafkaConn, error) {
+	c := &kafkaConn{conn: conn, r: bufio.NewReader(conn), stop: closeOnDone(ctx, conn)}
+	s.conns[addr] = c
+	if s.cfg.Username == "" {
+		return c, nil
+	}
+
+	var req kafkaEncoder
+	req.string("PLAIN")
+	d, err := c.call(kafkaSaslHandshake, 1, req.Bytes(), dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("kafka: SASL handshake: %w", err)
+	}
+	if code := kafkaError(d.int16()); code != 0 {
+		return nil, code
+	}
+	req.Reset()
+	req.bytes([]byte("\x00" + s.cfg.Username + "\x00" + s.cfg.Password))
+	if d, err = c.call(kafkaSaslAuthenticate, 0, req.Bytes(), dialTimeout); err != nil {
+		return nil, fmt.Errorf("kafka: SASL authenticate: %w", err)
+	}
+	if code := kafkaError(d.int16()); code != 0 {
+		if msg := d.string(); msg != "" {
+			return nil, fmt.Errorf("%w: %s", code, msg)
+		}
+		return nil, code
+	}
+	return c, nil
+}
+
+// connTo connection to the broker at addr
+func (s *kafkaSource) connTo(ctx context.Context, addr string) (*kafkaConn, error) {
+	if c, ok := s.conns[addr]; ok {
+		return c, nil
+	}
+	conn, err := dialServer(ctx, addr, s.cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	return s.register(ctx, addr, conn)
+}
+
+// loadMetadata read the brokers and the partition leaders of the topic
+func (s *kafkaSource) loadMetadata(c *kafkaConn) error {
+	var req kafkaEncoder
+	req.int32(1)
+	req.string(s.cfg.Topic)
+	d, err := c.call(kafkaMetadata, 1, req.Bytes(), dialTimeout)
+	if err != nil {
+		return fmt.Errorf("kafka metadata: %w", err)
+	}
+
+	s.brokers = map[int32]string{}
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		id, host, port := d.int32(), d.string(), d.int32()
+		d.string() // rack
+		s.brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32() // controller id
+
+	s.leaders = map[int32]int32{}
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		code, name := kafkaError(d.int16()), d.string()
+		d.int8() // is internal
+		if name == s.cfg.Topic && code != 0 {
+			return fmt.Errorf("kafka topic %s: %w", name, code)
+		}
+		for j, m := 0, d.arrayLen(); j < m; j++ {
+			d.int16() // partition error, a missing leader shows as -1
+			partition, leader := d.int32(), d.int32()
+			for k, r := 0, d.arrayLen(); k < r; k++ {
+				d.int32() // replicas
+			}
+			for k, r := 0, d.arrayLen(); k < r; k++ {
+				d.int32() // in-sync replicas
+			}
+			if name == s.cfg.Topic {
+				if _, ok := s.brokers[leader]; !ok {
+					return fmt.Errorf("kafka topic %s: partition %d has no leader", name, partition)
+				}
+				s.leaders[partition] = leader
+			}
+		}
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if len(s.leaders) == 0 {
+		return fmt.Errorf("kafka topic %s: no partitions", s.cfg.Topic)
+	}
+	return nil
+}
+
+// findCoordinator connect to the broker managing the offsets of group
+func (s *kafkaSource) findCoordinator(ctx context.Context, c *kafkaConn, group string) error {
+	var req kafkaEncoder
+	req.string(group)
+	d, err := c.call(kafkaFindCoordinator, 0, req.Bytes(), dialTimeout)
+	if err != nil {
+		return fmt.Errorf("kafka find coordinator: %w", err)
+	}
+	code, _, host, port := kafkaError(d.int16()), d.int32(), d.string(), d.int32()
+	if d.err != nil {
+		return d.err
+	}
+	if code != 0 {
+		return fmt.Errorf("kafka find coordinator: %w", code)
+	}
+	s.coord, err = s.connTo(ctx, net.JoinHostPort(host, strconv.Itoa(int(port))))
+	return err
+}
+
+// loadOffsets read the committed offsets of group, partitions without one start at the latest
+// or earliest offset
+func (s *kafkaSource) loadOffsets(ctx context.Context, group string) error {
+	partitions := make([]int32, 0, len(s.leaders))
+	for p := range s.leaders {
+		partitions = append(partitions, p)
+	}
+
+	var req kafkaEncoder
+	req.string(group)
+	req.int32(1)
+	req.string(s.cfg.Topic)
+	req.int32(int32(len(partitions)))
+	for _, p := range partitions {
+		req.int32(p)
+	}
+	d, err := s.coord.call(kafkaOffsetFetch, 1, req.Bytes(), dialTimeout)
+	if err != nil {
+		return fmt.Errorf("kafka offset fetch: %w", err)
+	}
+	s.offsets = map[int32]int64{}
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		d.string() // topic
+		for j, m := 0, d.arrayLen(); j < m; j++ {
+			partition, offset := d.int32(), d.int64()
+			d.string() // metadata
+			if code := kafkaError(d.int16()); code != 0 {
+				return fmt.Errorf("kafka offset fetch: %w", code)
+			}
+			if offset >= 0 {
+				s.offsets[partition] = offset
+			}
+		}
+	}
+	if d.err != nil {
+		return d.err
+	}
+
+	start := int64(kafkaLatest)
+	if s.cfg.Start == "earliest" {
+		start = kafkaEarliest
+	}
+	for _, p := range partitions {
+		if _, ok := s.offsets[p]; !ok {
+			if err := s.resetOffset(ctx, p, start); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resetOffset set the next offset of partition to the latest or earliest offset
+func (s *kafkaSource) resetOffset(ctx context.Context, partition int32, timestamp int64) error {
+	c, err := s.connTo(ctx, s.brokers[s.leaders[partition]])
+	if err != nil {
+		return err
+	}
+	var req kafkaEncoder
+	req.int32(-1) // replica id of consumers
+	req.int32(1)
+	req.string(s.cfg.Topic)
+	req.int32(1)
+	req.int32(partition)
+	req.int64(timestamp)
+	d, err := c.call(kafkaListOffsets, 1, req.Bytes(), dialTimeout)
+	if err != nil {
+		return fmt.Errorf("kafka list offsets: %w", err)
+	}
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		d.string() // topic
+		for j, m := 0, d.arrayLen(); j < m; j++ {
+			p, code := d.int32(), kafkaError(d.int16())
+			d.int64() // timestamp
+			offset := d.int64()
+			if code != 0 {
+				return fmt.Errorf("kafka list offsets: %w", code)
+			}
+			if p == partition && d.err == nil {
+				s.offsets[partition] = offset
+				return nil
+			}
+		}
+	}
+	if d.err != nil {
+		return d.err
+	}
+	return fmt.Errorf("kafka list offsets: partition %d missing in response", partition)
+}
+
+// fetch read the next records of partitions from their leader, deliver and commit them
+func (s *kafkaSource) fetch(ctx context.Context, w *worker, leader int32, partitions []int32, wait time.Duration) error {
+	c, err := s.connTo(ctx, s.brokers[leader])
+	if err != nil {
+		return err
+	}
+	var req kafkaEncoder
+	req.int32(-1) // replica id of consumers
+	req.int32(int32(wait / time.Millisecond))
+	req.int32(1) // min bytes
+	req.int32(kafkaMaxBytes)
+	req.int8(0) // read uncommitted
+	req.int32(1)
+	req.string(s.cfg.Topic)
+	req.int32(int32(len(partitions)))
+	for _, p := range partitions {
+		req.int32(p)
+		req.int64(s.offsets[p])
+		req.int32(kafkaPartitionMaxBytes)
+	}
+	d, err := c.call(kafkaFetch, 4, req.Bytes(), wait+dialTimeout)
+	if err != nil {
+		return fmt.Errorf("kafka fetch: %w", err)
+	}
+
+	type fetched struct {
+		partition int32
+		records   []byte
+	}
+	var results []fetched
+	d.int32() // throttle time
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		d.string() // topic
+		for j, m := 0, d.arrayLen(); j < m; j++ {
+			partition, code := d.int32(), kafkaError(d.int16())
+			d.int64() // high watermark
+			d.int64() // last stable offset
+			for k, a := 0, d.arrayLen(); k < a; k++ {
+				d.int64() // aborted producer id
+				d.int64() // first offset
+			}
+			records := d.bytes()
+			switch code {
+			case 0:
+				results = append(results, fetched{partition, records})
+			case kafkaOffsetOutOfRange:
+				// the committed offset was removed by retention
+				if err := s.resetOffset(ctx, partition, kafkaEarliest); err != nil {
+					return err
+				}
+			default:
+				// leader changes and the like, reconnect with fresh metadata
+				return fmt.Errorf("kafka fetch partition %d: %w", partition, code)
+			}
+		}
+	}
+	if d.err != nil {
+		return d.err
+	}
+
+	for _, f := range results {
+		records, next, err := decodeRecordBatches(f.records)
+		if err != nil {
+			return fmt.Errorf("kafka partition %d: %w", f.partition, err)
+		}
+		for _, r := range records {
+			if r.offset < s.offsets[f.partition] {
+				continue
+			}
+			m := Message{
+				ID:      fmt.Sprintf("%s/%d/%d", s.cfg.Topic, f.partition, r.offset),
+				Subject: s.cfg.Topic,
+				Headers: r.headers,
+				Body:    r.value,
+			}
+			if r.key != nil {
+				m.Headers["X-Gohook-Message-Key"] = string(r.key)
+			}
+			if err := w.deliver(m); err != nil {
+				return err
+			}
+			if err := s.commit(w.group(), f.partition, r.offset+1); err != nil {
+				return err
+			}
+			s.offsets[f.partition] = r.offset + 1
+		}
+		// batches of transaction markers hold no records but move the offset
+		if next > s.offsets[f.partition] {
+			s.offsets[f.partition] = next
+		}
+	}
+	return nil
+}
+
+// commit store the next offset of partition for group
+func (s *kafkaSource) commit(group string, partition int32, offset int64) error {
+	var req kafkaEncoder
+	req.string(group)
+	req.int32(-1) // generation of consumers outside group management
+	req.string("")
+	req.int64(-1) // broker default retention
+	req.int32(1)
+	req.string(s.cfg.Topic)
+	req.int32(1)
+	req.int32(partition)
+	req.int64(offset)
+	req.int16(-1) // no metadata
+	d, err := s.coord.call(kafkaOffsetCommit, 2, req.Bytes(), dialTimeout)
+	if err != nil {
+		return fmt.Errorf("kafka offset commit: %w", err)
+	}
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		d.string() // topic
+		for j, m := 0, d.arrayLen(); j < m; j++ {
+			d.int32() // partition
+			if code := kafkaError(d.int16()); code != 0 {
+				return fmt.Errorf("kafka offset commit: %w", code)
+			}
+		}
+	}
+	return d.err
+}
+
+// decodeRecordBatches records of the v2 record batches in data and the offset following the
+// last complete batch. A fetch may end with a partial batch, it is left for the next fetch.
+func decodeRecordBatches(data []byte) ([]kafkaRecord, int64, error) {
+	var records []kafkaRecord
+	next := int64(-1)
+	for len(data) >= 12 {
+		baseOffset := int64(binary.BigEndian.Uint64(data))
+		length := int(int32(binary.BigEndian.Uint32(data[8:])))
+		if length < 0 || len(data)-12 < length {
+			break
+		}
+		batch := data[12 : 12+length]
+		data = data[12+length:]
+
+		// leader epoch 4, magic 1, crc 4, attributes 2, last offset delta 4, timestamps 16,
+		// producer id 8, producer epoch 2, base sequence 4, record count 4
+		if len(batch) < 49 {
+			return nil, 0, errKafkaShort
+		}
+		if magic := batch[4]; magic != 2 {
+			return nil, 0, fmt.Errorf("unsupported message format v%d", magic)
+		}
+		attributes := binary.BigEndian.Uint16(batch[9:])
+		next = baseOffset + int64(int32(binary.BigEndian.Uint32(batch[11:]))) + 1
+		if attributes&0x20 != 0 {
+			continue // control batch
+		}
+		count := int(int32(binary.BigEndian.Uint32(batch[45:])))
+		body := batch[49:]
+		switch codec := attributes & 0x07; codec {
+		case 0:
+		case 1:
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, 0, fmt.Errorf("gzip batch: %w", err)
+			}
+			if body, err = io.ReadAll(zr); err != nil {
+				return nil, 0, fmt.Errorf("gzip batch: %w", err)
+			}
+		default:
+			return nil, 0, fmt.Errorf("compression codec %d is not supported, only none and gzip", codec)
+		}
+
+		for i := 0; i < count; i++ {
+			r, rest, err := decodeRecord(body, baseOffset)
+			if err != nil {
+				return nil, 0, err
+			}
+			records = append(records, r)
+			body = rest
+		}
+	}
+	return records, next, nil
+}
+
+// decodeRecord one record of a batch and the bytes following it
+func decodeRecord(b []byte, baseOffset int64) (kafkaRecord, []byte, error) {
+	varint := func() int64 {
+		v, n := binary.Varint(b)
+		if n <= 0 {
+			b = nil
+			return -2
+		}
+		b = b[n:]
+		return v
+	}
+	field := func() []byte {
+		n := varint()
+		if n < 0 {
+			return nil
+		}
+		if int64(len(b)) < n {
+			b = nil
+			return nil
+		}
+		v := b[:n]
+		b = b[n:]
+		return v
+	}
+
+	length := varint()
+	if length < 0 || int64(len(b)) < length {
+		return kafkaRecord{}, nil, errKafkaShort
+	}
+	rest := b[length:]
+	b = b[:length]
+	if len(b) < 1 {
+		return kafkaRecord{}, nil, errKafkaShort
+	}
+	b = b[1:] // attributes
+	varint()  // timestamp delta
+	r := kafkaRecord{offset: baseOffset + varint(), headers: map[string]string{}}
+	r.key = field()
+	r.value = field()
+	for i, n := int64(0), varint(); i < n; i++ {
+		name := field()
+		r.headers[string(name)] = string(field())
+	}
+	if b == nil {
+		return kafkaRecord{}, nil, errKafkaShort
+	}
+	return r, rest, nil
+}
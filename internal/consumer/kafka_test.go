@@ -0,0 +1,129 @@
+package consumer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"testing"
+)
+
+type testRecord struct {
+	key, value string
+	headers    [][2]string
+}
+
+// encodeBatch build a v2 record batch with the given attributes
+func encodeBatch(t *testing.T, baseOffset int64, attributes uint16, records []testRecord) []byte {
+	t.Helper()
+	var body []byte
+	for i, r := range records {
+		var rec []byte
+		rec = append(rec, 0)                     // attributes
+		rec = binary.AppendVarint(rec, 0)        // timestamp delta
+		rec = binary.AppendVarint(rec, int64(i)) // offset delta
+		if r.key == "" {
+			rec = binary.AppendVarint(rec, -1) // null key
+		} else {
+			rec = binary.AppendVarint(rec, int64(len(r.key)))
+			rec = append(rec, r.key...)
+		}
+		rec = binary.AppendVarint(rec, int64(len(r.value)))
+		rec = append(rec, r.value...)
+		rec = binary.AppendVarint(rec, int64(len(r.headers)))
+		for _, h := range r.headers {
+			rec = binary.AppendVarint(rec, int64(len(h[0])))
+			rec = append(rec, h[0]...)
+			rec = binary.AppendVarint(rec, int64(len(h[1])))
+			rec = append(rec, h[1]...)
+		}
+		body = binary.AppendVarint(body, int64(len(rec)))
+		body = append(body, rec...)
+	}
+	if attributes&0x07 == 1 {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		zw.Close()
+		body = buf.Bytes()
+	}
+
+	var batch []byte
+	batch = binary.BigEndian.AppendUint32(batch, 0) // leader epoch
+	batch = append(batch, 2)                        // magic
+	batch = binary.BigEndian.AppendUint32(batch, 0) // crc, not checked
+	batch = binary.BigEndian.AppendUint16(batch, attributes)
+	batch = binary.BigEndian.AppendUint32(batch, uint32(len(records)-1))
+	batch = append(batch, make([]byte, 8+8+8+2+4)...)
+	batch = binary.BigEndian.AppendUint32(batch, uint32(len(records)))
+	batch = append(batch, body...)
+
+	out := binary.BigEndian.AppendUint64(nil, uint64(baseOffset))
+	out = binary.BigEndian.AppendUint32(out, uint32(len(batch)))
+	return append(out, batch...)
+}
+
+func TestDecodeRecordBatches(t *testing.T) {
+	records := []testRecord{
+		{key: "app", value: `{"ref":"main"}`, headers: [][2]string{{"X-Event", "push"}}},
+		{value: "second"},
+	}
+	plain := encodeBatch(t, 40, 0, records)
+	zipped := encodeBatch(t, 42, 1, records)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		offsets []int64
+		next    int64
+		wantErr bool
+	}{
+		{"plain", plain, []int64{40, 41}, 42, false},
+		{"gzip", zipped, []int64{42, 43}, 44, false},
+		{"two batches", append(append([]byte{}, plain...), zipped...), []int64{40, 41, 42, 43}, 44, false},
+		{"partial batch ignored", append(append([]byte{}, plain...), zipped[:30]...), []int64{40, 41}, 42, false},
+		{"control batch moves offset", encodeBatch(t, 7, 0x20, records[1:]), nil, 8, false},
+		{"snappy unsupported", encodeBatch(t, 0, 2, records), nil, 0, true},
+		{"empty", nil, nil, -1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, next, err := decodeRecordBatches(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if next != tt.next {
+				t.Errorf("next = %d, want %d", next, tt.next)
+			}
+			if len(got) != len(tt.offsets) {
+				t.Fatalf("got %d records, want %d", len(got), len(tt.offsets))
+			}
+			for i, r := range got {
+				if r.offset != tt.offsets[i] {
+					t.Errorf("record %d offset = %d, want %d", i, r.offset, tt.offsets[i])
+				}
+			}
+			if len(got) > 0 {
+				first := got[0]
+				if string(first.key) != "app" || string(first.value) != `{"ref":"main"}` || first.headers["X-Event"] != "push" {
+					t.Errorf("unexpected first record %+v", first)
+				}
+				if got[1].key != nil {
+					t.Errorf("null key decoded as %q", got[1].key)
+				}
+			}
+		})
+	}
+}
+
+func TestKafkaDecoderShort(t *testing.T) {
+	d := &kafkaDecoder{b: []byte{0, 5, 'a'}}
+	if s := d.string(); s != "" || d.err != errKafkaShort {
+		t.Fatalf("got %q, %v", s, d.err)
+	}
+	if d.int32() != 0 || d.arrayLen() != 0 {
+		t.Fatal("reads after an error must return zero values")
+	}
+}
@@ -0,0 +1,353 @@
+package consumer
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// MQTT 3.1.1 control packet types
+const (
+	mqttConnect     = 1
+	mqttConnack     = 2
+	mqttPublish     = 3
+	mqttPuback      = 4
+	mqttPubrec      = 5
+	mqttPubrel      = 6
+	mqttPubcomp     = 7
+	mqttSubscribe   = 8
+	mqttSuback      = 9
+	mqttPingreq     = 12
+	mqttPingresp    = 13
+	mqttSubscribeID = 1 // packet id of the single SUBSCRIBE
+)
+
+// mqtt connection limits
+const (
+	mqttMaxPacket = 64 << 20
+	mqttKeepAlive = 60 * time.Second
+)
+
+var mqttConnackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// mqttSource subscribes to MQTT topic filters. With a QoS above 0 the session is persistent,
+// the broker keeps messages published while gohook is disconnected and redelivers messages
+// not acknowledged before a disconnect.
+type mqttSource struct {
+	cfg types.ConsumerConfig
+
+	writeMu sync.Mutex
+	conn    net.Conn
+}
+
+func (s *mqttSource) consume(ctx context.Context, w *worker) error {
+	conn, _, err := dial(ctx, s.cfg.Servers, s.cfg.TLS)
+	if err != nil {
+		return err
+	}
+	defer closeOnDone(ctx, conn)()
+	s.conn = conn
+	r := bufio.NewReader(conn)
+
+	if err := s.write(mqttConnect<<4, s.connectPacket()); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(dialTimeout))
+	typ, _, body, err := readMQTTPacket(r)
+	if err != nil {
+		return fmt.Errorf("mqtt: read CONNACK: %w", err)
+	}
+	if typ != mqttConnack || len(body) != 2 {
+		return fmt.Errorf("mqtt: expected CONNACK, got packet type %d", typ)
+	}
+	if code := body[1]; code != 0 {
+		if msg, ok := mqttConnackErrors[code]; ok {
+			return fmt.Errorf("mqtt: connection refused: %s", msg)
+		}
+		return fmt.Errorf("mqtt: connection refused with code %d", code)
+	}
+
+	filters := s.filters()
+	var sub []byte
+	sub = binary.BigEndian.AppendUint16(sub, mqttSubscribeID)
+	for _, filter := range filters {
+		sub = appendMQTTString(sub, filter)
+		sub = append(sub, byte(s.cfg.QoS))
+	}
+	if err := s.write(mqttSubscribe<<4|0x02, sub); err != nil {
+		return err
+	}
+
+	pingDone := make(chan struct{})
+	defer close(pingDone)
+	go s.ping(pingDone)
+
+	// QoS 2 messages delivered and acknowledged with PUBREC, waiting for PUBREL
+	released := map[uint16]bool{}
+	for {
+		conn.SetReadDeadline(time.Now().Add(mqttKeepAlive * 3 / 2))
+		typ, flags, body, err := readMQTTPacket(r)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("mqtt: %w", err)
+		}
+		switch typ {
+		case mqttSuback:
+			if len(body) != 2+len(filters) {
+				return fmt.Errorf("mqtt: invalid SUBACK")
+			}
+			for i, code := range body[2:] {
+				if code == 0x80 {
+					return fmt.Errorf("mqtt: subscription to %s refused", filters[i])
+				}
+			}
+			w.connected()
+		case mqttPublish:
+			p, err := parseMQTTPublish(flags, body)
+			if err != nil {
+				return err
+			}
+			if p.qos == 2 && released[p.id] {
+				// redelivered before our PUBREC arrived, already delivered
+				if err := s.ack(mqttPubrec<<4, p.id); err != nil {
+					return err
+				}
+				continue
+			}
+			// retained messages are replayed on every subscribe, they are not new events
+			if !p.retain {
+				if hook, ok := s.route(p.topic); ok {
+					if err := w.deliver(Message{Subject: p.topic, Headers: map[string]string{}, Body: p.payload, Hook: hook}); err != nil {
+						return err
+					}
+				}
+			}
+			switch p.qos {
+			case 1:
+				err = s.ack(mqttPuback<<4, p.id)
+			case 2:
+				released[p.id] = true
+				err = s.ack(mqttPubrec<<4, p.id)
+			}
+			if err != nil {
+				return err
+			}
+		case mqttPubrel:
+			if len(body) != 2 {
+				return fmt.Errorf("mqtt: invalid PUBREL")
+			}
+			id := binary.BigEndian.Uint16(body)
+			delete(released, id)
+			if err := s.ack(mqttPubcomp<<4, id); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// connectPacket variable header and payload of CONNECT
+func (s *mqttSource) connectPacket() []byte {
+	var flags byte
+	if s.cfg.QoS == 0 {
+		flags |= 0x02 // clean session
+	}
+	if s.cfg.Username != "" {
+		flags |= 0x80
+		if s.cfg.Password != "" {
+			flags |= 0x40
+		}
+	}
+	p := appendMQTTString(nil, "MQTT")
+	p = append(p, 4, flags) // protocol level 3.1.1
+	p = binary.BigEndian.AppendUint16(p, uint16(mqttKeepAlive/time.Second))
+	p = appendMQTTString(p, s.clientID())
+	if s.cfg.Username != "" {
+		p = appendMQTTString(p, s.cfg.Username)
+		if s.cfg.Password != "" {
+			p = appendMQTTString(p, s.cfg.Password)
+		}
+	}
+	return p
+}
+
+// clientID stable client id, a persistent session is bound to it
+func (s *mqttSource) clientID() string {
+	if s.cfg.ClientID != "" {
+		return s.cfg.ClientID
+	}
+	return consumerName() + "-" + s.cfg.Name
+}
+
+// filters topic filters subscribed to
+func (s *mqttSource) filters() []string {
+	if len(s.cfg.Routes) == 0 {
+		return []string{s.cfg.Topic}
+	}
+	filters := make([]string, 0, len(s.cfg.Routes))
+	for _, r := range s.cfg.Routes {
+		filters = append(filters, r.Topic)
+	}
+	return filters
+}
+
+// route hook of the first route matching topic
+func (s *mqttSource) route(topic string) (string, bool) {
+	if len(s.cfg.Routes) == 0 {
+		return s.cfg.Hook, mqttMatch(s.cfg.Topic, topic)
+	}
+	for _, r := range s.cfg.Routes {
+		if mqttMatch(r.Topic, topic) {
+			return r.Hook, true
+		}
+	}
+	return "", false
+}
+
+// ping send PINGREQ at half the keep alive interval until done is closed
+func (s *mqttSource) ping(done chan struct{}) {
+	ticker := time.NewTicker(mqttKeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := s.write(mqttPingreq<<4, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ack send PUBACK, PUBREC or PUBCOMP for a packet id
+func (s *mqttSource) ack(header byte, id uint16) error {
+	return s.write(header, binary.BigEndian.AppendUint16(nil, id))
+}
+
+// write send a packet, the reader loop and the pinger share the connection
+func (s *mqttSource) write(header byte, body []byte) error {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	packet = append(packet, body...)
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(dialTimeout))
+	_, err := s.conn.Write(packet)
+	return err
+}
+
+// readMQTTPacket read a control packet and return its type, flags and body
+func readMQTTPacket(r *bufio.Reader) (typ, flags byte, body []byte, err error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, 0, nil, errors.New("invalid remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if length > mqttMaxPacket {
+		return 0, 0, nil, fmt.Errorf("packet of %d bytes is too large", length)
+	}
+	body = make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, 0, nil, err
+	}
+	return first >> 4, first & 0x0f, body, nil
+}
+
+// mqttPublishPacket a received PUBLISH
+type mqttPublishPacket struct {
+	topic   string
+	qos     byte
+	retain  bool
+	id      uint16
+	payload []byte
+}
+
+func parseMQTTPublish(flags byte, body []byte) (mqttPublishPacket, error) {
+	p := mqttPublishPacket{qos: (flags >> 1) & 0x03, retain: flags&0x01 != 0}
+	if p.qos == 3 || len(body) < 2 {
+		return p, fmt.Errorf("mqtt: invalid PUBLISH")
+	}
+	n := int(binary.BigEndian.Uint16(body))
+	body = body[2:]
+	if len(body) < n {
+		return p, fmt.Errorf("mqtt: invalid PUBLISH topic")
+	}
+	p.topic, body = string(body[:n]), body[n:]
+	if p.qos > 0 {
+		if len(body) < 2 {
+			return p, fmt.Errorf("mqtt: invalid PUBLISH packet id")
+		}
+		p.id, body = binary.BigEndian.Uint16(body), body[2:]
+	}
+	p.payload = body
+	return p, nil
+}
+
+func appendMQTTString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// mqttMatch report whether topic matches filter, + matches one level, # the level and all
+// below; wildcards at the first level do not match topics starting with $
+func mqttMatch(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) {
+			return false
+		}
+		if level != "+" && level != t[i] {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
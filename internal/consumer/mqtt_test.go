@@ -0,0 +1,170 @@
+package consumer
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+func TestMQTTMatch(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"sensors/temp", "sensors/temp", true},
+		{"sensors/temp", "sensors/humidity", false},
+		{"sensors/+", "sensors/temp", true},
+		{"sensors/+", "sensors/temp/1", false},
+		{"sensors/+/state", "sensors/door/state", true},
+		{"sensors/#", "sensors", true},
+		{"sensors/#", "sensors/a/b/c", true},
+		{"#", "anything/at/all", true},
+		{"#", "$SYS/broker/load", false},
+		{"+/broker", "$SYS/broker", false},
+		{"$SYS/#", "$SYS/broker", true},
+	}
+	for _, tt := range tests {
+		if got := mqttMatch(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("mqttMatch(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestValidateMQTTConsumer(t *testing.T) {
+	base := types.ConsumerConfig{Name: "m", Type: types.ConsumerMQTT, Servers: []string{"127.0.0.1:1883"}}
+	tests := []struct {
+		name    string
+		edit    func(c *types.ConsumerConfig)
+		wantErr bool
+	}{
+		{"topic and hook", func(c *types.ConsumerConfig) { c.Topic, c.Hook = "home/+/door", "door" }, false},
+		{"routes", func(c *types.ConsumerConfig) {
+			c.Routes = []types.ConsumerRoute{{Topic: "home/#", Hook: "home"}, {Topic: "garden/+", Hook: "garden"}}
+		}, false},
+		{"routes and topic", func(c *types.ConsumerConfig) {
+			c.Topic, c.Hook = "a", "a"
+			c.Routes = []types.ConsumerRoute{{Topic: "b", Hook: "b"}}
+		}, true},
+		{"route without hook", func(c *types.ConsumerConfig) { c.Routes = []types.ConsumerRoute{{Topic: "b"}} }, true},
+		{"hash not last", func(c *types.ConsumerConfig) { c.Topic, c.Hook = "a/#/b", "a" }, true},
+		{"wildcard inside level", func(c *types.ConsumerConfig) { c.Topic, c.Hook = "a/b+", "a" }, true},
+		{"qos 3", func(c *types.ConsumerConfig) { c.Topic, c.Hook, c.QoS = "a", "a", 3 }, true},
+		{"qos on redis", func(c *types.ConsumerConfig) { c.Type, c.Topic, c.Hook, c.QoS = types.ConsumerRedis, "a", "a", 1 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := base
+			tt.edit(&c)
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// mqttPacket encode a control packet with a one byte remaining length
+func mqttPacket(header byte, body []byte) []byte {
+	return append([]byte{header, byte(len(body))}, body...)
+}
+
+func mqttPublishBody(topic string, id uint16, payload string) []byte {
+	b := appendMQTTString(nil, topic)
+	if id != 0 {
+		b = binary.BigEndian.AppendUint16(b, id)
+	}
+	return append(b, payload...)
+}
+
+// TestMQTTDelivery route QoS 1 messages of a fake broker to hooks, skip retained messages and
+// acknowledge after the delivery
+func TestMQTTDelivery(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	acked := make(chan uint16, 4)
+	connect := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			typ, _, body, err := readMQTTPacket(r)
+			if err != nil {
+				return
+			}
+			switch typ {
+			case mqttConnect:
+				connect <- body
+				conn.Write(mqttPacket(mqttConnack<<4, []byte{0, 0}))
+			case mqttSubscribe:
+				conn.Write(mqttPacket(mqttSuback<<4, []byte{0, 1, 1, 1}))
+				conn.Write(mqttPacket(mqttPublish<<4|0x02|0x01, mqttPublishBody("home/door", 7, `{"retained":true}`)))
+				conn.Write(mqttPacket(mqttPublish<<4|0x02, mqttPublishBody("garden/valve", 8, `{"open":true}`)))
+				conn.Write(mqttPacket(mqttPublish<<4|0x02, mqttPublishBody("home/door", 9, `{"open":false}`)))
+			case mqttPuback:
+				acked <- binary.BigEndian.Uint16(body)
+			}
+		}
+	}()
+
+	delivered := make(chan string, 4)
+	webhook.SetHookEndpoint(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		delivered <- r.URL.Path + " " + r.Header.Get("X-Gohook-Subject") + " " + string(body)
+	}))
+	defer webhook.SetHookEndpoint(nil)
+
+	cfg := types.ConsumerConfig{
+		Name: "iot", Type: types.ConsumerMQTT, Servers: []string{ln.Addr().String()}, QoS: 1,
+		Routes: []types.ConsumerRoute{{Topic: "home/#", Hook: "home"}, {Topic: "garden/+", Hook: "garden"}},
+	}
+	w := &worker{cfg: cfg, status: newStatus(cfg)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.run(ctx)
+
+	select {
+	case body := <-connect:
+		// persistent session for QoS 1
+		if len(body) < 8 || body[7]&0x02 != 0 {
+			t.Fatalf("QoS 1 must not request a clean session, CONNECT %v", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no CONNECT")
+	}
+
+	want := []string{"/hooks/garden garden/valve {\"open\":true}", "/hooks/home home/door {\"open\":false}"}
+	for i, w := range want {
+		select {
+		case got := <-delivered:
+			if got != w {
+				t.Fatalf("delivery %d = %q, want %q", i, got, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("delivery %d missing", i)
+		}
+	}
+	for _, id := range []uint16{7, 8, 9} {
+		select {
+		case got := <-acked:
+			if got != id {
+				t.Fatalf("PUBACK %d, want %d", got, id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("PUBACK %d missing", id)
+		}
+	}
+}
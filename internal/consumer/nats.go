@@ -0,0 +1,181 @@
+package consumer
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// natsPingTimeout a connection without any traffic for this long is considered dead, the
+// server pings idle clients every two minutes
+const natsPingTimeout = 5 * time.Minute
+
+// natsInfo fields of the INFO line the server greets with
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+	Headers     bool `json:"headers"`
+}
+
+// natsConnect options of the CONNECT command
+type natsConnect struct {
+	Verbose     bool   `json:"verbose"`
+	Pedantic    bool   `json:"pedantic"`
+	TLSRequired bool   `json:"tls_required"`
+	Name        string `json:"name"`
+	Lang        string `json:"lang"`
+	Version     string `json:"version"`
+	Protocol    int    `json:"protocol"`
+	Headers     bool   `json:"headers"`
+	User        string `json:"user,omitempty"`
+	Pass        string `json:"pass,omitempty"`
+	AuthToken   string `json:"auth_token,omitempty"`
+}
+
+// natsSource subscribes to a NATS subject with a queue group over the core protocol, NATS
+// has no acknowledgements so messages published while disconnected are not seen
+type natsSource struct {
+	cfg types.ConsumerConfig
+}
+
+func (s *natsSource) consume(ctx context.Context, w *worker) error {
+	conn, server, err := dial(ctx, s.cfg.Servers, false)
+	if err != nil {
+		return err
+	}
+	// closing the plain connection also ends a TLS connection on top of it
+	defer closeOnDone(ctx, conn)()
+
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("nats: read INFO: %w", err)
+	}
+	infoJSON, ok := strings.CutPrefix(strings.TrimSpace(line), "INFO ")
+	if !ok {
+		return fmt.Errorf("nats: unexpected greeting %q", strings.TrimSpace(line))
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+		return fmt.Errorf("nats: parse INFO: %w", err)
+	}
+
+	// NATS upgrades the plain connection to TLS after the INFO line
+	useTLS := s.cfg.TLS || info.TLSRequired
+	if useTLS {
+		host, _, _ := net.SplitHostPort(server)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("nats: TLS handshake: %w", err)
+		}
+		conn, r = tlsConn, bufio.NewReader(tlsConn)
+	}
+
+	opts := natsConnect{
+		TLSRequired: useTLS,
+		Name:        consumerName(),
+		Lang:        "go",
+		Version:     "1",
+		Protocol:    1,
+		Headers:     info.Headers,
+	}
+	if s.cfg.Username != "" {
+		opts.User, opts.Pass = s.cfg.Username, s.cfg.Password
+	} else {
+		opts.AuthToken = s.cfg.Password
+	}
+	connectJSON, _ := json.Marshal(opts)
+	sub := "SUB " + s.cfg.Topic + " " + w.group() + " 1\r\n"
+	if _, err := io.WriteString(conn, "CONNECT "+string(connectJSON)+"\r\nPING\r\n"+sub+"PING\r\n"); err != nil {
+		return err
+	}
+	// the server answers the first PING after CONNECT was accepted, the second once SUB was
+	pongs := 0
+
+	for {
+		conn.SetDeadline(time.Now().Add(natsPingTimeout))
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("nats: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		op, args, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(op) {
+		case "PING":
+			if _, err := io.WriteString(conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case "PONG":
+			if pongs++; pongs == 2 {
+				w.connected()
+			}
+		case "-ERR":
+			return fmt.Errorf("nats: %s", strings.Trim(args, "' "))
+		case "MSG", "HMSG":
+			m, err := readNATSMessage(r, strings.ToUpper(op), args)
+			if err != nil {
+				return err
+			}
+			if err := w.deliver(m); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readNATSMessage read the payload of a MSG (subject sid [reply] size) or HMSG
+// (subject sid [reply] header-size total-size) line
+func readNATSMessage(r *bufio.Reader, op, args string) (Message, error) {
+	fields := strings.Fields(args)
+	sizes := 1
+	if op == "HMSG" {
+		sizes = 2
+	}
+	if len(fields) < 2+sizes || len(fields) > 3+sizes {
+		return Message{}, fmt.Errorf("nats: invalid %s line %q", op, args)
+	}
+	total, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || total < 0 {
+		return Message{}, fmt.Errorf("nats: invalid %s size %q", op, args)
+	}
+	headerSize := 0
+	if op == "HMSG" {
+		if headerSize, err = strconv.Atoi(fields[len(fields)-2]); err != nil || headerSize < 0 || headerSize > total {
+			return Message{}, fmt.Errorf("nats: invalid %s header size %q", op, args)
+		}
+	}
+
+	buf := make([]byte, total+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return Message{}, err
+	}
+	m := Message{Subject: fields[0], Headers: map[string]string{}, Body: buf[headerSize:total]}
+	if headerSize > 0 {
+		// NATS/1.0 status line followed by MIME headers
+		tp := textproto.NewReader(bufio.NewReader(strings.NewReader(string(buf[:headerSize]))))
+		if _, err := tp.ReadLine(); err != nil {
+			return Message{}, fmt.Errorf("nats: invalid headers: %w", err)
+		}
+		header, err := tp.ReadMIMEHeader()
+		if err != nil && err != io.EOF {
+			return Message{}, fmt.Errorf("nats: invalid headers: %w", err)
+		}
+		for name, values := range header {
+			m.Headers[name] = strings.Join(values, ", ")
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,112 @@
+package consumer
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+func TestReadNATSMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		op      string
+		args    string
+		data    string
+		body    string
+		headers map[string]string
+		wantErr bool
+	}{
+		{name: "msg", op: "MSG", args: "deploy.app 1 5", data: "hello\r\n", body: "hello"},
+		{name: "msg with reply", op: "MSG", args: "deploy.app 1 _INBOX.x 2", data: "{}\r\n", body: "{}"},
+		{
+			name: "hmsg", op: "HMSG", args: "deploy.app 1 33 37",
+			data:    "NATS/1.0\r\nX-Event: push\r\nA: 1\r\n\r\nbody\r\n",
+			body:    "body",
+			headers: map[string]string{"X-Event": "push", "A": "1"},
+		},
+		{name: "bad size", op: "MSG", args: "deploy.app 1 x", wantErr: true},
+		{name: "short payload", op: "MSG", args: "deploy.app 1 10", data: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := readNATSMessage(bufio.NewReader(strings.NewReader(tt.data)), tt.op, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if string(m.Body) != tt.body || m.Subject != "deploy.app" {
+				t.Errorf("got body %q subject %q", m.Body, m.Subject)
+			}
+			for k, v := range tt.headers {
+				if m.Headers[k] != v {
+					t.Errorf("header %s = %q, want %q", k, m.Headers[k], v)
+				}
+			}
+		})
+	}
+}
+
+// TestNATSDelivery subscribe to a fake server and check the message reaches the hook endpoint
+func TestNATSDelivery(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, `INFO {"headers":true}`+"\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "PING"):
+				io.WriteString(conn, "PONG\r\n")
+			case strings.HasPrefix(line, "SUB deploy.* gohook 1"):
+				io.WriteString(conn, "HMSG deploy.app 1 27 41\r\nNATS/1.0\r\nX-Event: push\r\n\r\n{\"ref\":\"main\"}\r\n")
+			}
+		}
+	}()
+
+	delivered := make(chan *http.Request, 1)
+	webhook.SetHookEndpoint(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Header.Set("Body", string(body))
+		delivered <- r
+	}))
+	defer webhook.SetHookEndpoint(nil)
+
+	cfg := types.ConsumerConfig{Name: "nats", Type: types.ConsumerNATS, Servers: []string{ln.Addr().String()}, Topic: "deploy.*", Hook: "deploy"}
+	w := &worker{cfg: cfg, status: newStatus(cfg)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.run(ctx)
+
+	select {
+	case r := <-delivered:
+		if !strings.HasSuffix(r.URL.Path, "/deploy") || r.Header.Get("X-Event") != "push" ||
+			r.Header.Get("Content-Type") != "application/json" || r.Header.Get("X-Gohook-Consumer") != "nats" ||
+			r.Header.Get("X-Gohook-Subject") != "deploy.app" || r.Header.Get("Body") != `{"ref":"main"}` {
+			t.Fatalf("unexpected delivery %s %v", r.URL, r.Header)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("message not delivered, consumer state %s: %s", w.state(), w.status.LastError)
+	}
+}
@@ -0,0 +1,242 @@
+package consumer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// redisBlock how long XREADGROUP waits for new entries
+const redisBlock = 5 * time.Second
+
+// defaultBodyField redis entry field holding the message body
+const defaultBodyField = "body"
+
+// redisError error reply of the server
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+// respConn RESP2 connection to a redis server
+type respConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// do send a command and read its reply: string, int64, []byte, []interface{}, nil or a redisError
+func (c *respConn) do(timeout time.Duration, args ...string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	c.conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+	reply, err := readRESP(c.r)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := reply.(redisError); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// readRESP read one RESP2 reply
+func readRESP(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRESP(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+// redisSource reads a redis stream with a consumer group and acknowledges delivered entries
+type redisSource struct {
+	cfg types.ConsumerConfig
+}
+
+func (s *redisSource) consume(ctx context.Context, w *worker) error {
+	conn, _, err := dial(ctx, s.cfg.Servers, s.cfg.TLS)
+	if err != nil {
+		return err
+	}
+	defer closeOnDone(ctx, conn)()
+	c := &respConn{conn: conn, r: bufio.NewReader(conn)}
+
+	if s.cfg.Password != "" {
+		args := []string{"AUTH", s.cfg.Password}
+		if s.cfg.Username != "" {
+			args = []string{"AUTH", s.cfg.Username, s.cfg.Password}
+		}
+		if _, err := c.do(dialTimeout, args...); err != nil {
+			return fmt.Errorf("redis auth: %w", err)
+		}
+	}
+
+	start := "$"
+	if s.cfg.Start == "earliest" {
+		start = "0"
+	}
+	group := w.group()
+	if _, err := c.do(dialTimeout, "XGROUP", "CREATE", s.cfg.Topic, group, start, "MKSTREAM"); err != nil {
+		var re redisError
+		if !errors.As(err, &re) || !strings.HasPrefix(string(re), "BUSYGROUP") {
+			return fmt.Errorf("redis create group: %w", err)
+		}
+	}
+	w.connected()
+
+	// entries read before a restart but never acknowledged come first, then new ones
+	id := "0"
+	for ctx.Err() == nil {
+		reply, err := c.do(redisBlock+dialTimeout, "XREADGROUP", "GROUP", group, consumerName(),
+			"COUNT", "16", "BLOCK", strconv.Itoa(int(redisBlock/time.Millisecond)), "STREAMS", s.cfg.Topic, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("redis read: %w", err)
+		}
+		entries, err := parseStreamReply(reply)
+		if err != nil {
+			return err
+		}
+		if id == "0" && len(entries) == 0 {
+			id = ">"
+			continue
+		}
+		for _, e := range entries {
+			if e.fields != nil {
+				if err := w.deliver(s.message(e)); err != nil {
+					return err
+				}
+			}
+			if _, err := c.do(dialTimeout, "XACK", s.cfg.Topic, group, e.id); err != nil {
+				return fmt.Errorf("redis ack: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// streamEntry id and field/value pairs of a stream entry, fields is nil for entries deleted
+// while pending
+type streamEntry struct {
+	id     string
+	fields []string
+}
+
+// parseStreamReply entries of an XREADGROUP reply for a single stream, nil reply on timeout
+func parseStreamReply(reply interface{}) ([]streamEntry, error) {
+	if reply == nil {
+		return nil, nil
+	}
+	streams, ok := reply.([]interface{})
+	if !ok || len(streams) != 1 {
+		return nil, fmt.Errorf("redis: unexpected XREADGROUP reply")
+	}
+	stream, ok := streams[0].([]interface{})
+	if !ok || len(stream) != 2 {
+		return nil, fmt.Errorf("redis: unexpected XREADGROUP stream reply")
+	}
+	items, _ := stream[1].([]interface{})
+	entries := make([]streamEntry, 0, len(items))
+	for _, item := range items {
+		pair, ok := item.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("redis: unexpected stream entry")
+		}
+		id, ok := pair[0].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("redis: unexpected stream entry id")
+		}
+		e := streamEntry{id: string(id)}
+		if values, ok := pair[1].([]interface{}); ok {
+			e.fields = make([]string, 0, len(values))
+			for _, v := range values {
+				b, _ := v.([]byte)
+				e.fields = append(e.fields, string(b))
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// message body field of the entry as body and the other fields as headers, the whole entry
+// as a JSON object when it has no body field
+func (s *redisSource) message(e streamEntry) Message {
+	bodyField := s.cfg.BodyField
+	if bodyField == "" {
+		bodyField = defaultBodyField
+	}
+	m := Message{ID: e.id, Subject: s.cfg.Topic, Headers: map[string]string{}}
+	all := map[string]string{}
+	hasBody := false
+	for i := 0; i+1 < len(e.fields); i += 2 {
+		name, value := e.fields[i], e.fields[i+1]
+		all[name] = value
+		if name == bodyField {
+			m.Body, hasBody = []byte(value), true
+		} else {
+			m.Headers[name] = value
+		}
+	}
+	if !hasBody {
+		m.Body, _ = json.Marshal(all)
+		m.Headers = map[string]string{}
+	}
+	return m
+}
@@ -0,0 +1,59 @@
+package consumer
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestReadRESP(t *testing.T) {
+	reply := "*1\r\n*2\r\n$6\r\ndeploy\r\n*2\r\n" +
+		"*2\r\n$3\r\n1-0\r\n*4\r\n$4\r\nbody\r\n$14\r\n{\"ref\":\"main\"}\r\n$5\r\nevent\r\n$4\r\npush\r\n" +
+		"*2\r\n$3\r\n2-0\r\n*-1\r\n"
+	got, err := readRESP(bufio.NewReader(strings.NewReader(reply)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := parseStreamReply(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].id != "1-0" || len(entries[0].fields) != 4 || entries[1].fields != nil {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	s := &redisSource{cfg: types.ConsumerConfig{Topic: "deploy"}}
+	m := s.message(entries[0])
+	if string(m.Body) != `{"ref":"main"}` || m.Headers["event"] != "push" || m.ID != "1-0" || m.Subject != "deploy" {
+		t.Fatalf("unexpected message %+v", m)
+	}
+
+	s.cfg.BodyField = "payload"
+	m = s.message(entries[0])
+	if string(m.Body) != `{"body":"{\"ref\":\"main\"}","event":"push"}` || len(m.Headers) != 0 {
+		t.Fatalf("entry without body field must be sent as JSON, got %s %v", m.Body, m.Headers)
+	}
+}
+
+func TestReadRESPErrors(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  interface{}
+	}{
+		{"+OK\r\n", "OK"},
+		{"-BUSYGROUP Consumer Group name already exists\r\n", redisError("BUSYGROUP Consumer Group name already exists")},
+		{":3\r\n", int64(3)},
+		{"$-1\r\n", nil},
+	}
+	for _, tt := range tests {
+		got, err := readRESP(bufio.NewReader(strings.NewReader(tt.reply)))
+		if err != nil || got != tt.want {
+			t.Errorf("readRESP(%q) = %v, %v, want %v", tt.reply, got, err, tt.want)
+		}
+	}
+	if _, err := readRESP(bufio.NewReader(strings.NewReader("?\r\n"))); err == nil {
+		t.Error("expected error for an unknown reply type")
+	}
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ErrArtifactsUnavailable artifacts are stored in the database
+var ErrArtifactsUnavailable = errors.New("artifacts require the database")
+
+// HookArtifact file collected after a hook run, linked to its execution log
+type HookArtifact struct {
+	BaseModel
+	HookLogID uint   `json:"execution_id" gorm:"index"` // execution log the file was collected for
+	Name      string `json:"name" gorm:"size:500"`      // path relative to the working directory
+	Size      int64  `json:"size"`                      // size of the file on disk
+	Truncated bool   `json:"truncated"`                 // only the first bytes up to the size cap were kept
+	Data      []byte `json:"-"`                         // stored content, blob on SQLite and bytea on PostgreSQL
+}
+
+// SaveHookArtifacts store the artifacts collected for the execution log logID
+func SaveHookArtifacts(logID uint, artifacts []HookArtifact) error {
+	if DB == nil {
+		return ErrArtifactsUnavailable
+	}
+	if logID == 0 || len(artifacts) == 0 {
+		return nil
+	}
+	for i := range artifacts {
+		artifacts[i].HookLogID = logID
+	}
+	if err := DB.Create(&artifacts).Error; err != nil {
+		return fmt.Errorf("save artifacts of execution %d: %v", logID, err)
+	}
+	return nil
+}
+
+// GetHookExecution execution log id of the webhook hookID
+func GetHookExecution(hookID string, id uint) (*HookLog, error) {
+	if DB == nil {
+		return nil, ErrArtifactsUnavailable
+	}
+	var hookLog HookLog
+	err := DB.Where("id = ? AND hook_id = ? AND hook_type = ?", id, hookID, HookTypeWebhook).First(&hookLog).Error
+	if err != nil {
+		return nil, err
+	}
+	return &hookLog, nil
+}
+
+// ListHookArtifacts artifacts of an execution log without their content
+func ListHookArtifacts(logID uint) ([]HookArtifact, error) {
+	if DB == nil {
+		return nil, ErrArtifactsUnavailable
+	}
+	artifacts := []HookArtifact{}
+	err := DB.Omit("data").Where("hook_log_id = ?", logID).Order("name").Find(&artifacts).Error
+	return artifacts, err
+}
+
+// GetHookArtifact artifact name of an execution log with its content
+func GetHookArtifact(logID uint, name string) (*HookArtifact, error) {
+	if DB == nil {
+		return nil, ErrArtifactsUnavailable
+	}
+	var artifact HookArtifact
+	if err := DB.Where("hook_log_id = ? AND name = ?", logID, name).First(&artifact).Error; err != nil {
+		return nil, err
+	}
+	return &artifact, nil
+}
+
+// cleanArtifacts remove the artifacts of executions logged before the hook logs were cleaned,
+// rows are deleted for good so their content does not stay in the database. Artifacts have no
+// namespace, so conditions of a namespaced tx are dropped and every orphan is removed.
+func cleanArtifacts(tx *gorm.DB) error {
+	tx = tx.Session(&gorm.Session{NewDB: true})
+	return tx.Unscoped().
+		Where("hook_log_id NOT IN (?)", tx.Model(&HookLog{}).Select("id")).
+		Delete(&HookArtifact{}).Error
+}
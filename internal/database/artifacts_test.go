@@ -0,0 +1,84 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestHookArtifacts(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&HookLog{}, &HookArtifact{}, &SystemLog{}, &UserActivity{}, &ProjectActivity{}, &NodeLog{}, &UserMessage{}); err != nil {
+		t.Fatal(err)
+	}
+	saved := DB
+	DB = conn
+	defer func() { DB = saved }()
+
+	run := &HookLog{HookID: "build", HookType: HookTypeWebhook}
+	old := &HookLog{HookID: "build", HookType: HookTypeWebhook}
+	for _, l := range []*HookLog{run, old} {
+		if err := conn.Create(l).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SaveHookArtifacts(run.ID, []HookArtifact{
+		{Name: "report.html", Size: 4, Data: []byte("<p/>")},
+		{Name: "logs/build.log", Size: 10, Truncated: true, Data: []byte("ok")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveHookArtifacts(old.ID, []HookArtifact{{Name: "old.txt", Data: []byte("x")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GetHookExecution("other", run.ID); err == nil {
+		t.Error("GetHookExecution() of another hook must fail")
+	}
+	if l, err := GetHookExecution("build", run.ID); err != nil || l.ID != run.ID {
+		t.Errorf("GetHookExecution() = %v, %v", l, err)
+	}
+
+	list, err := ListHookArtifacts(run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "logs/build.log" || list[0].Data != nil || !list[0].Truncated {
+		t.Errorf("ListHookArtifacts() = %+v", list)
+	}
+	if a, err := GetHookArtifact(run.ID, "report.html"); err != nil || string(a.Data) != "<p/>" {
+		t.Errorf("GetHookArtifact() = %v, %v", a, err)
+	}
+	if _, err := GetHookArtifact(run.ID, "old.txt"); err == nil {
+		t.Error("GetHookArtifact() of another execution must fail")
+	}
+
+	// artifacts go with their cleaned execution logs
+	conn.Model(old).Update("created_at", time.Now().AddDate(0, 0, -10))
+	if err := (&LogService{db: conn}).CleanOldLogs(5); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	conn.Unscoped().Model(&HookArtifact{}).Count(&count)
+	if count != 2 {
+		t.Errorf("%d artifacts left after cleaning, want 2", count)
+	}
+}
+
+func TestCleanArtifactsInNamespace(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&HookLog{}, &HookArtifact{}, &SystemLog{}, &UserActivity{}, &ProjectActivity{}, &NodeLog{}, &UserMessage{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&LogService{db: conn}).InNamespace("team").CleanOldLogs(5); err != nil {
+		t.Errorf("CleanOldLogs() in a namespace = %v", err)
+	}
+}
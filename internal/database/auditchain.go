@@ -0,0 +1,215 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxChainIssues limits the issues reported per table by VerifyAuditChain
+	maxChainIssues = 100
+	// chainBatch rows read at a time by VerifyAuditChain
+	chainBatch = 500
+)
+
+var (
+	// auditHashChain chain new UserActivity and ProjectActivity rows, see SetAuditHashChain
+	auditHashChain atomic.Bool
+	// chainMu serializes the writers of this instance, which then wait for the database lock
+	// in turn instead of all at once
+	chainMu sync.Mutex
+)
+
+// SetAuditHashChain turn hash chaining of new audit records (user and project activity) on or off
+func SetAuditHashChain(enabled bool) {
+	auditHashChain.Store(enabled)
+}
+
+// chainedRecord audit record linked to the previous record of its table by hash
+type chainedRecord interface {
+	chainLink() (prev, hash string)
+	seal(prev string)
+	digest(prev string) string
+}
+
+// ChainIssue record failing the verification
+type ChainIssue struct {
+	ID      uint   `json:"id"`
+	Problem string `json:"problem"`
+}
+
+// ChainReport result of verifying the hash chain of one table
+type ChainReport struct {
+	Table    string       `json:"table"`
+	Records  int64        `json:"records"`  // chained records checked
+	Deleted  int64        `json:"deleted"`  // chained records soft deleted, e.g. by log retention
+	Unlinked int64        `json:"unlinked"` // records written while chaining was off
+	Head     string       `json:"head"`     // hash of the newest record, keep it elsewhere to detect removal of the newest records
+	Valid    bool         `json:"valid"`
+	Issues   []ChainIssue `json:"issues"`
+}
+
+// createChained insert row, linked to the newest chained row of its table when chaining is on.
+// Reading the previous hash and inserting the row hold a database lock per table, so gohook
+// instances sharing the database (HA mode) extend the chain one after another.
+func createChained(db *gorm.DB, row chainedRecord) error {
+	if !auditHashChain.Load() {
+		return db.Create(row).Error
+	}
+	chainMu.Lock()
+	defer chainMu.Unlock()
+	// drop the namespace scope of the log service, the chain spans the whole table
+	db = db.Session(&gorm.Session{NewDB: true})
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(row); err != nil {
+		return err
+	}
+	return Locked(db, "audit-chain-"+stmt.Schema.Table, func(tx *gorm.DB) error {
+		var prev []string
+		if err := tx.Unscoped().Model(row).Where("hash <> ''").Order("id DESC").Limit(1).Pluck("hash", &prev).Error; err != nil {
+			return err
+		}
+		link := ""
+		if len(prev) > 0 {
+			link = prev[0]
+		}
+		row.seal(link)
+		return tx.Create(row).Error
+	})
+}
+
+// VerifyAuditChain recompute the hashes of the chained user and project activity records
+func VerifyAuditChain(db *gorm.DB) ([]ChainReport, error) {
+	user, err := verifyChain("user_activities", func(after uint) ([]chainedRow, error) {
+		var batch []UserActivity
+		err := db.Unscoped().Where("id > ?", after).Order("id").Limit(chainBatch).Find(&batch).Error
+		rows := make([]chainedRow, len(batch))
+		for i := range batch {
+			rows[i] = chainedRow{id: batch[i].ID, deleted: batch[i].DeletedAt.Valid, record: &batch[i]}
+		}
+		return rows, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	project, err := verifyChain("project_activities", func(after uint) ([]chainedRow, error) {
+		var batch []ProjectActivity
+		err := db.Unscoped().Where("id > ?", after).Order("id").Limit(chainBatch).Find(&batch).Error
+		rows := make([]chainedRow, len(batch))
+		for i := range batch {
+			rows[i] = chainedRow{id: batch[i].ID, deleted: batch[i].DeletedAt.Valid, record: &batch[i]}
+		}
+		return rows, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []ChainReport{user, project}, nil
+}
+
+// chainedRow record of a verified batch
+type chainedRow struct {
+	id      uint
+	deleted bool
+	record  chainedRecord
+}
+
+// verifyChain walk the rows of table in id order, next loads the rows following an id
+func verifyChain(table string, next func(after uint) ([]chainedRow, error)) (ChainReport, error) {
+	report := ChainReport{Table: table, Issues: []ChainIssue{}}
+	issue := func(id uint, problem string) {
+		if len(report.Issues) < maxChainIssues {
+			report.Issues = append(report.Issues, ChainIssue{ID: id, Problem: problem})
+		}
+	}
+
+	prev := ""
+	started := false
+	var after uint
+	for {
+		rows, err := next(after)
+		if err != nil {
+			return report, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for _, r := range rows {
+			after = r.id
+			link, hash := r.record.chainLink()
+			if hash == "" {
+				if started {
+					report.Unlinked++
+				}
+				continue
+			}
+			report.Records++
+			if r.deleted {
+				report.Deleted++
+			}
+			switch {
+			case !started && link != "":
+				issue(r.id, "records before the first chained record are missing")
+			case started && link != prev:
+				issue(r.id, "previous record was modified or removed")
+			}
+			if r.record.digest(link) != hash {
+				issue(r.id, "content does not match its hash")
+			}
+			started = true
+			prev = hash
+		}
+	}
+	report.Head = prev
+	report.Valid = len(report.Issues) == 0
+	return report, nil
+}
+
+// chainDigest hex SHA-256 of the previous hash and the fields of a record
+func chainDigest(prev string, createdAt time.Time, fields ...string) string {
+	h := sha256.New()
+	h.Write([]byte(prev))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatInt(createdAt.UnixMilli(), 10)))
+	for _, f := range fields {
+		h.Write([]byte("\x00" + strconv.Itoa(len(f)) + ":" + f))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// chainTime creation time stored for a chained record, at the precision every database keeps
+func chainTime() time.Time {
+	return time.Now().Truncate(time.Millisecond)
+}
+
+func (a *UserActivity) chainLink() (string, string) { return a.PrevHash, a.Hash }
+
+func (a *UserActivity) seal(prev string) {
+	a.CreatedAt = chainTime()
+	a.PrevHash, a.Hash = prev, a.digest(prev)
+}
+
+func (a *UserActivity) digest(prev string) string {
+	return chainDigest(prev, a.CreatedAt, a.Username, a.Action, a.Resource, a.Description,
+		a.IPAddress, a.UserAgent, strconv.FormatBool(a.Success), a.Details)
+}
+
+func (a *ProjectActivity) chainLink() (string, string) { return a.PrevHash, a.Hash }
+
+func (a *ProjectActivity) seal(prev string) {
+	a.CreatedAt = chainTime()
+	a.PrevHash, a.Hash = prev, a.digest(prev)
+}
+
+// digest of a project activity leaves out the project name and namespace, renaming a project
+// rewrites them
+func (a *ProjectActivity) digest(prev string) string {
+	return chainDigest(prev, a.CreatedAt, a.Action, a.OldValue, a.NewValue, a.Username,
+		strconv.FormatBool(a.Success), a.Error, a.CommitHash, a.Description, a.IPAddress, a.Output)
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAuditChain(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&UserActivity{}, &ProjectActivity{}, &Lock{}); err != nil {
+		t.Fatal(err)
+	}
+	s := &LogService{db: conn}
+	defer SetAuditHashChain(false)
+
+	// records written before chaining was turned on are not part of the chain
+	if err := s.CreateUserActivity("admin", UserActionLogin, "auth", "login", "10.0.0.1", "curl", true, nil); err != nil {
+		t.Fatal(err)
+	}
+	SetAuditHashChain(true)
+	for i := 0; i < 4; i++ {
+		if err := s.CreateUserActivity("admin", UserActionUpdateHookBasic, "hook:deploy", "update hook", "10.0.0.1", "curl", true, map[string]int{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CreateProjectActivity("site", ProjectActionTagSwitch, "v1", "v2", "admin", true, "", "", "switch tag", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a namespaced log service extends the same chain
+	if err := s.InNamespace("team").CreateUserActivity("bob", UserActionLogin, "auth", "login", "10.0.0.2", "curl", true, nil); err != nil {
+		t.Fatal(err)
+	}
+	// renaming a project keeps its records valid
+	if err := conn.Model(&ProjectActivity{}).Where("project_name = ?", "site").Update("project_name", "web").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func() (ChainReport, ChainReport) {
+		t.Helper()
+		reports, err := VerifyAuditChain(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reports[0], reports[1]
+	}
+	user, project := verify()
+	if !user.Valid || user.Records != 5 || !project.Valid || project.Records != 4 || user.Head == "" {
+		t.Fatalf("untouched chain: %+v %+v", user, project)
+	}
+
+	// soft deleted records, e.g. by log retention, still verify
+	if err := conn.Where("id = ?", 2).Delete(&UserActivity{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user, _ = verify(); !user.Valid || user.Deleted != 1 {
+		t.Errorf("soft deleted record: %+v", user)
+	}
+
+	tests := []struct {
+		name    string
+		tamper  func() error
+		issueID uint
+	}{
+		{"modified", func() error {
+			return conn.Model(&UserActivity{}).Where("id = ?", 3).Update("description", "nothing happened").Error
+		}, 3},
+		{"removed", func() error {
+			return conn.Unscoped().Delete(&UserActivity{}, 4).Error
+		}, 5},
+	}
+	for _, tt := range tests {
+		if err := tt.tamper(); err != nil {
+			t.Fatal(err)
+		}
+		user, _ := verify()
+		found := false
+		for _, issue := range user.Issues {
+			found = found || issue.ID == tt.issueID
+		}
+		if user.Valid || !found {
+			t.Errorf("%s: report %+v, want an issue for record %d", tt.name, user, tt.issueID)
+		}
+	}
+
+	if err := conn.Model(&ProjectActivity{}).Where("id = ?", 1).Update("new_value", "v3").Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, project = verify(); project.Valid || len(project.Issues) != 1 || project.Issues[0].ID != 1 {
+		t.Errorf("modified project activity: %+v", project)
+	}
+}
+
+func TestAuditChainSharedDatabase(t *testing.T) {
+	// two connections to one database stand for two gohook instances in HA mode
+	dsn := "file:" + filepath.Join(t.TempDir(), "gohook.db") + "?_busy_timeout=10000"
+	var conns []*gorm.DB
+	for i := 0; i < 2; i++ {
+		conn, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.AutoMigrate(&UserActivity{}, &ProjectActivity{}, &Lock{}); err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+	SetAuditHashChain(true)
+	defer SetAuditHashChain(false)
+	s := &LogService{db: conns[0]}
+	if err := s.CreateUserActivity("admin", UserActionLogin, "auth", "login", "10.0.0.1", "curl", true, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the other instance holds the chain lock while it appends; this instance waits for it
+	// and links to the row it wrote instead of forking the chain
+	locked := make(chan struct{})
+	done := make(chan error, 1)
+	err := Locked(conns[1], "audit-chain-user_activities", func(tx *gorm.DB) error {
+		var prev []string
+		if err := tx.Model(&UserActivity{}).Where("hash <> ''").Order("id DESC").Limit(1).Pluck("hash", &prev).Error; err != nil {
+			return err
+		}
+		row := &UserActivity{Username: "bob", Action: UserActionLogin, Resource: "auth", Success: true}
+		row.seal(prev[0])
+		if err := tx.Create(row).Error; err != nil {
+			return err
+		}
+		close(locked)
+		go func() {
+			done <- s.CreateUserActivity("admin", UserActionLogout, "auth", "logout", "10.0.0.1", "curl", true, nil)
+		}()
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-locked
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	reports, err := VerifyAuditChain(conns[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user := reports[0]; !user.Valid || user.Records != 3 {
+		t.Fatalf("chain written by two instances: %+v", user)
+	}
+}
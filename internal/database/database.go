@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -23,6 +24,7 @@ type DatabaseConfig struct {
 	Database string `yaml:"database"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+	SSLMode  string `yaml:"sslmode"` // postgres only, default prefer
 }
 
 // DefaultDatabaseConfig return default database config
@@ -95,6 +97,10 @@ func InitDatabase(config *DatabaseConfig) error {
 		dsn = config.Database
 		dialector = createSQLiteDialector(dsn)
 
+	case "postgres":
+		dsn = config.postgresDSN()
+		dialector = postgres.Open(dsn)
+
 	default:
 		return fmt.Errorf("unsupported database type: %s", config.Type)
 	}
@@ -140,6 +146,10 @@ func InitDatabase(config *DatabaseConfig) error {
 	}
 
 	DB = db
+	listenDSN = ""
+	if config.Type == "postgres" {
+		listenDSN = dsn
+	}
 	log.Printf("Database connected successfully (type: %s)", config.Type)
 
 	return nil
@@ -162,9 +172,25 @@ func AutoMigrate() error {
 		&SystemLog{},
 		&UserActivity{},
 		&ProjectActivity{},
+		&ProjectEnv{},
+		&ProjectDeployKey{},
+		&QueuedDelivery{},
+		&ProjectPromotion{},
+		&GitMaintenanceRun{},
 		&SyncNode{},
 		&SyncTask{},
+		&SyncDeployment{},
 		&SyncFileChange{},
+		&ClusterLease{},
+		&ClusterNode{},
+		&ClusterEvent{},
+		&Lock{},
+		&ClientSession{},
+		&TrashItem{},
+		&NodeLog{},
+		&HookArtifact{},
+		&UserMessage{},
+		&PluginConf{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %v", err)
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Lock row locked by Locked, it serializes writers across gohook instances sharing the database
+type Lock struct {
+	Name     string    `json:"name" gorm:"primaryKey;size:100"`
+	LockedAt time.Time `json:"locked_at"` // last time the lock was taken
+}
+
+// Locked run fn in a transaction holding the lock name. Other transactions taking the
+// same lock, in this or another gohook instance, wait until it commits: PostgreSQL locks
+// the row, SQLite the whole database.
+func Locked(db *gorm.DB, name string, fn func(tx *gorm.DB) error) error {
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&Lock{Name: name}).Error; err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Lock{}).Where("name = ?", name).Update("locked_at", time.Now().UTC()).Error; err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// encryptedPrefix marks an encrypted field of a hook log, followed by the sealed value
+const encryptedPrefix = "enc:v1:"
+
+// EncryptedPlaceholder value of an encrypted field for readers without the permission to
+// decrypt hook logs, or when it cannot be decrypted
+const EncryptedPlaceholder = "[encrypted]"
+
+var (
+	logCipherMu sync.RWMutex
+	sealField   func(string) (string, error) // nil: new hook logs are stored in clear
+	openField   func(string) (string, error)
+)
+
+// SetHookLogCipher encrypt the body, output and error of the hook logs created from now on
+// with seal, nil stores them in clear; open decrypts the fields of stored logs
+func SetHookLogCipher(seal, open func(string) (string, error)) {
+	logCipherMu.Lock()
+	sealField, openField = seal, open
+	logCipherMu.Unlock()
+}
+
+// HookLogsEncrypted whether the body, output and error of new hook logs are stored encrypted
+func HookLogsEncrypted() bool {
+	logCipherMu.RLock()
+	defer logCipherMu.RUnlock()
+	return sealField != nil
+}
+
+// seal encrypt the sensitive fields of a new hook log in place when encryption is on. The log
+// is left untouched when a field fails to encrypt, it must not be stored then.
+func (l *HookLog) seal() error {
+	logCipherMu.RLock()
+	seal := sealField
+	logCipherMu.RUnlock()
+	if seal == nil {
+		return nil
+	}
+	fields := []*string{&l.Body, &l.Output, &l.Error}
+	sealed := make([]string, len(fields))
+	for i, field := range fields {
+		if *field == "" {
+			continue
+		}
+		value, err := seal(*field)
+		if err != nil {
+			return fmt.Errorf("encrypt hook log of %s: %w", l.HookID, err)
+		}
+		sealed[i] = encryptedPrefix + value
+	}
+	for i, field := range fields {
+		if sealed[i] != "" {
+			*field = sealed[i]
+		}
+	}
+	return nil
+}
+
+// Encrypted whether some field of the log is stored encrypted
+func (l *HookLog) Encrypted() bool {
+	return strings.HasPrefix(l.Body, encryptedPrefix) || strings.HasPrefix(l.Output, encryptedPrefix) ||
+		strings.HasPrefix(l.Error, encryptedPrefix)
+}
+
+// Revealed copy of the log with its encrypted fields decrypted when decrypt is set, replaced
+// by EncryptedPlaceholder otherwise
+func (l HookLog) Revealed(decrypt bool) HookLog {
+	logCipherMu.RLock()
+	open := openField
+	logCipherMu.RUnlock()
+	for _, field := range []*string{&l.Body, &l.Output, &l.Error} {
+		sealed, ok := strings.CutPrefix(*field, encryptedPrefix)
+		if !ok {
+			continue
+		}
+		*field = EncryptedPlaceholder
+		if decrypt && open != nil {
+			if plain, err := open(sealed); err == nil {
+				*field = plain
+			}
+		}
+	}
+	return l
+}
+
+// Decrypting service revealing the encrypted fields of hook logs when allowed, the
+// permission of the user the logs are read for; a service masks them by default
+func (s *LogService) Decrypting(allowed bool) *LogService {
+	scoped := *s
+	scoped.decrypt = allowed
+	return &scoped
+}
+
+// reveal decrypt or mask the encrypted fields of logs read by the service
+func (s *LogService) reveal(logs []HookLog) {
+	for i := range logs {
+		logs[i] = logs[i].Revealed(s.decrypt)
+	}
+}
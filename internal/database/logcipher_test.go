@@ -0,0 +1,100 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// reverseCipher stand-in for the env vault, reverses the value
+func reverseCipher(v string) (string, error) {
+	r := []rune(v)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r), nil
+}
+
+func TestHookLogCipher(t *testing.T) {
+	t.Cleanup(func() { SetHookLogCipher(nil, nil) })
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&HookLog{}); err != nil {
+		t.Fatal(err)
+	}
+	s := &LogService{db: conn}
+
+	SetHookLogCipher(reverseCipher, reverseCipher)
+	if _, err := s.createHookLog("deploy", "", "deploy", HookTypeWebhook, "POST", "127.0.0.1",
+		nil, `{"token":"s3cret"}`, false, "deployed", "exit 1", 10, "curl", nil); err != nil {
+		t.Fatal(err)
+	}
+	var stored HookLog
+	if err := conn.First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Encrypted() || strings.Contains(stored.Body, "s3cret") || stored.HookID != "deploy" {
+		t.Fatalf("stored log = %+v, want body, output and error encrypted", stored)
+	}
+
+	tests := []struct {
+		name    string
+		open    func(string) (string, error)
+		decrypt bool
+		want    [3]string
+	}{
+		{"permitted", reverseCipher, true, [3]string{`{"token":"s3cret"}`, "deployed", "exit 1"}},
+		{"not permitted", reverseCipher, false, [3]string{EncryptedPlaceholder, EncryptedPlaceholder, EncryptedPlaceholder}},
+		{"key lost", func(string) (string, error) { return "", errors.New("bad key") }, true,
+			[3]string{EncryptedPlaceholder, EncryptedPlaceholder, EncryptedPlaceholder}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetHookLogCipher(nil, tt.open)
+			logs, _, err := s.Decrypting(tt.decrypt).GetHookLogs(1, 10, "", "", "", nil, nil, nil)
+			if err != nil || len(logs) != 1 {
+				t.Fatalf("GetHookLogs = %d logs, %v", len(logs), err)
+			}
+			if got := [3]string{logs[0].Body, logs[0].Output, logs[0].Error}; got != tt.want {
+				t.Errorf("body, output, error = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// logs created after encryption is turned off are stored and read in clear
+	plain := HookLog{Body: "plain", Output: "out"}
+	if err := plain.seal(); err != nil {
+		t.Fatal(err)
+	}
+	if plain.Encrypted() || plain.Revealed(false).Body != "plain" {
+		t.Errorf("log without cipher = %+v, want it in clear", plain)
+	}
+}
+
+func TestHookLogSealFailure(t *testing.T) {
+	t.Cleanup(func() { SetHookLogCipher(nil, nil) })
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&HookLog{}); err != nil {
+		t.Fatal(err)
+	}
+	s := &LogService{db: conn}
+
+	SetHookLogCipher(func(string) (string, error) { return "", errors.New("no key") }, reverseCipher)
+	if _, err := s.createHookLog("deploy", "", "deploy", HookTypeWebhook, "POST", "127.0.0.1",
+		nil, "body", true, "out", "", 10, "curl", nil); err == nil {
+		t.Fatal("createHookLog = nil error, want the encryption error")
+	}
+	var count int64
+	conn.Model(&HookLog{}).Count(&count)
+	if count != 0 {
+		t.Errorf("stored %d logs, want none stored with a placeholder or in clear", count)
+	}
+}
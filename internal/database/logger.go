@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"log"
 	"time"
 )
@@ -13,21 +14,26 @@ func InitLogService() {
 }
 
 // LogHookExecution log hook execution log (global function)
-func LogHookExecution(hookID, hookName, hookType, method, remoteAddr string,
+// alias is the previous id the delivery addressed, empty when it used the current id.
+// The id of the log is returned, 0 when it was not stored.
+func LogHookExecution(hookID, alias, hookName, hookType, method, remoteAddr string,
 	headers map[string][]string, body string, success bool, output, error string,
-	duration int64, userAgent string, queryParams map[string][]string) {
+	duration int64, userAgent string, queryParams map[string][]string) uint {
 
 	if globalLogService == nil {
 		InitLogService()
 	}
 
 	if globalLogService != nil {
-		err := globalLogService.CreateHookLog(hookID, hookName, hookType, method, remoteAddr,
+		hookLog, err := globalLogService.createHookLog(hookID, alias, hookName, hookType, method, remoteAddr,
 			headers, body, success, output, error, duration, userAgent, queryParams)
 		if err != nil {
 			log.Printf("Failed to log hook execution: %v", err)
+		} else if hookLog != nil {
+			return hookLog.ID
 		}
 	}
+	return 0
 }
 
 // LogSystemEvent log system event log (global function)
@@ -81,6 +87,20 @@ func LogProjectAction(projectName, action, oldValue, newValue,
 	}
 }
 
+// LogProjectActivity log a project activity carrying fields LogProjectAction does not take,
+// such as command output (global function)
+func LogProjectActivity(activity *ProjectActivity) {
+	if globalLogService == nil {
+		InitLogService()
+	}
+
+	if globalLogService != nil {
+		if err := globalLogService.SaveProjectActivity(activity); err != nil {
+			log.Printf("Failed to log project activity: %v", err)
+		}
+	}
+}
+
 // LogHookManagement record hook management operation log (global function)
 func LogHookManagement(action, hookID, hookName, username, ipAddress, userAgent string, success bool, details interface{}) {
 	if globalLogService == nil {
@@ -116,13 +136,25 @@ func getHookManagementDescription(action, hookName string) string {
 		return "update hook script: " + hookName
 	case "DELETE_HOOK":
 		return "delete hook: " + hookName
+	case "RENAME_HOOK":
+		return "rename hook: " + hookName
+	case "UPDATE_HOOK_ALIASES":
+		return "update hook aliases: " + hookName
+	case "UPDATE_HOOK_ENDPOINTS":
+		return "update hook endpoints: " + hookName
+	case "ROTATE_HOOK_SECRET":
+		return "rotate hook secret: " + hookName
+	case "UPDATE_HOOK_QUOTA":
+		return "update hook quota: " + hookName
+	case "TRIGGER_HOOK":
+		return "trigger hook: " + hookName
 	default:
 		return "hook management operation: " + hookName
 	}
 }
 
-// ScheduleLogCleanup start periodic log cleanup task
-func ScheduleLogCleanup(retentionDays int) {
+// ScheduleLogCleanup start periodic log cleanup task, it stops once ctx is done
+func ScheduleLogCleanup(ctx context.Context, retentionDays int) {
 	if retentionDays <= 0 {
 		retentionDays = 30 // default retention 30 days
 	}
@@ -131,7 +163,12 @@ func ScheduleLogCleanup(retentionDays int) {
 		ticker := time.NewTicker(24 * time.Hour) // check once per day
 		defer ticker.Stop()
 
-		for range ticker.C {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
 			if globalLogService != nil {
 				err := globalLogService.CleanOldLogs(retentionDays)
 				if err != nil {
@@ -0,0 +1,112 @@
+package database
+
+// maxMessagesPerUser inbox messages kept per user, older ones are removed when new ones arrive
+const maxMessagesPerUser = 1000
+
+// UserMessage inbox message of a user: a deploy result, a failed hook or an approval waiting
+// for the user
+type UserMessage struct {
+	BaseModel
+	Username string `json:"username" gorm:"size:100;index"`
+	Kind     string `json:"kind" gorm:"size:20;index"`                 // deploy, hook, approval or update
+	Target   string `json:"target" gorm:"size:200"`                    // project or hook the message is about
+	Title    string `json:"title" gorm:"size:200"`                     // short summary
+	Message  string `json:"message" gorm:"type:text"`                  // details
+	Priority int    `json:"priority"`                                  // 2 success, 5 approval, 8 failure
+	Read     bool   `json:"read" gorm:"column:is_read;index;not null"` // set by mark-as-read
+}
+
+// inbox message kinds
+const (
+	MessageKindDeploy   = "deploy"
+	MessageKindHook     = "hook"
+	MessageKindApproval = "approval"
+	MessageKindUpdate   = "update" // a newer release of gohook, for admins
+)
+
+// SaveUserMessages store messages and trim the inboxes of their users to maxMessagesPerUser
+func SaveUserMessages(messages []UserMessage) error {
+	if DB == nil || len(messages) == 0 {
+		return nil
+	}
+	if err := DB.Create(&messages).Error; err != nil {
+		return err
+	}
+	trimmed := map[string]bool{}
+	for _, m := range messages {
+		if trimmed[m.Username] {
+			continue
+		}
+		trimmed[m.Username] = true
+		// the newest message beyond the limit, it and everything older is removed
+		var oldest []uint
+		if err := DB.Model(&UserMessage{}).Where("username = ?", m.Username).Order("id DESC").
+			Offset(maxMessagesPerUser).Limit(1).Pluck("id", &oldest).Error; err != nil {
+			return err
+		}
+		if len(oldest) == 0 {
+			continue
+		}
+		if err := DB.Unscoped().Where("username = ? AND id <= ?", m.Username, oldest[0]).
+			Delete(&UserMessage{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListUserMessages newest messages of a user, only those older than the message since when it
+// is not 0
+func ListUserMessages(username string, since uint, limit int, unreadOnly bool) ([]UserMessage, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	query := DB.Where("username = ?", username)
+	if since > 0 {
+		query = query.Where("id < ?", since)
+	}
+	if unreadOnly {
+		query = query.Where("is_read = ?", false)
+	}
+	var messages []UserMessage
+	err := query.Order("id DESC").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+// CountUnreadUserMessages number of unread messages of a user
+func CountUnreadUserMessages(username string) (int64, error) {
+	if DB == nil {
+		return 0, nil
+	}
+	var count int64
+	err := DB.Model(&UserMessage{}).Where("username = ? AND is_read = ?", username, false).Count(&count).Error
+	return count, err
+}
+
+// MarkUserMessagesRead mark a message of a user as read, or all of them when id is 0, and
+// return the number of messages changed
+func MarkUserMessagesRead(username string, id uint) (int64, error) {
+	if DB == nil {
+		return 0, nil
+	}
+	query := DB.Model(&UserMessage{}).Where("username = ? AND is_read = ?", username, false)
+	if id != 0 {
+		query = query.Where("id = ?", id)
+	}
+	result := query.Update("is_read", true)
+	return result.RowsAffected, result.Error
+}
+
+// DeleteUserMessages delete a message of a user, or all of them when id is 0, and return the
+// number of messages deleted
+func DeleteUserMessages(username string, id uint) (int64, error) {
+	if DB == nil {
+		return 0, nil
+	}
+	query := DB.Unscoped().Where("username = ?", username)
+	if id != 0 {
+		query = query.Where("id = ?", id)
+	}
+	result := query.Delete(&UserMessage{})
+	return result.RowsAffected, result.Error
+}
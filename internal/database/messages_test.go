@@ -0,0 +1,96 @@
+package database
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestUserMessages(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&UserMessage{}); err != nil {
+		t.Fatal(err)
+	}
+	saved := DB
+	DB = conn
+	defer func() { DB = saved }()
+
+	var messages []UserMessage
+	for i := 0; i < 5; i++ {
+		messages = append(messages, UserMessage{Username: "alice", Kind: MessageKindDeploy, Title: "deploy"})
+	}
+	messages = append(messages, UserMessage{Username: "bob", Kind: MessageKindHook, Title: "hook"})
+	if err := SaveUserMessages(messages); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := ListUserMessages("alice", 0, 3, false)
+	if err != nil || len(page) != 3 || page[0].ID <= page[2].ID {
+		t.Fatalf("first page = %v, %v", page, err)
+	}
+	rest, err := ListUserMessages("alice", page[2].ID, 3, false)
+	if err != nil || len(rest) != 2 || rest[0].ID >= page[2].ID {
+		t.Fatalf("second page = %v, %v", rest, err)
+	}
+
+	if n, err := MarkUserMessagesRead("alice", page[0].ID); err != nil || n != 1 {
+		t.Errorf("MarkUserMessagesRead(one) = %d, %v", n, err)
+	}
+	if n, err := MarkUserMessagesRead("bob", page[1].ID); err != nil || n != 0 {
+		t.Errorf("MarkUserMessagesRead() of another user's message = %d, %v", n, err)
+	}
+	if n, _ := CountUnreadUserMessages("alice"); n != 4 {
+		t.Errorf("CountUnreadUserMessages() = %d, want 4", n)
+	}
+	if unread, _ := ListUserMessages("alice", 0, 10, true); len(unread) != 4 {
+		t.Errorf("unread messages = %d, want 4", len(unread))
+	}
+	if n, err := MarkUserMessagesRead("alice", 0); err != nil || n != 4 {
+		t.Errorf("MarkUserMessagesRead(all) = %d, %v", n, err)
+	}
+
+	if n, err := DeleteUserMessages("bob", page[0].ID); err != nil || n != 0 {
+		t.Errorf("DeleteUserMessages() of another user's message = %d, %v", n, err)
+	}
+	if n, err := DeleteUserMessages("alice", page[0].ID); err != nil || n != 1 {
+		t.Errorf("DeleteUserMessages(one) = %d, %v", n, err)
+	}
+	if n, err := DeleteUserMessages("alice", 0); err != nil || n != 4 {
+		t.Errorf("DeleteUserMessages(all) = %d, %v", n, err)
+	}
+	if n, _ := CountUnreadUserMessages("bob"); n != 1 {
+		t.Errorf("bob's inbox = %d, want 1", n)
+	}
+}
+
+func TestUserMessagesTrimmed(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&UserMessage{}); err != nil {
+		t.Fatal(err)
+	}
+	saved := DB
+	DB = conn
+	defer func() { DB = saved }()
+
+	messages := make([]UserMessage, maxMessagesPerUser+5)
+	for i := range messages {
+		messages[i] = UserMessage{Username: "alice", Title: "deploy"}
+	}
+	if err := SaveUserMessages(messages); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := CountUnreadUserMessages("alice"); n != maxMessagesPerUser {
+		t.Fatalf("inbox size = %d, want %d", n, maxMessagesPerUser)
+	}
+	newest, _ := ListUserMessages("alice", 0, 1, false)
+	if len(newest) != 1 || newest[0].ID != messages[len(messages)-1].ID {
+		t.Errorf("newest message = %v, want id %d", newest, messages[len(messages)-1].ID)
+	}
+}
@@ -14,62 +14,146 @@ type BaseModel struct {
 	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 }
 
+// In render the timestamps in loc, unchanged when nil
+func (m *BaseModel) In(loc *time.Location) {
+	if loc == nil {
+		return
+	}
+	m.CreatedAt = m.CreatedAt.In(loc)
+	m.UpdatedAt = m.UpdatedAt.In(loc)
+}
+
 // HookLog hook execution log
 type HookLog struct {
 	BaseModel
-	HookID      string `json:"hook_id" gorm:"size:100;index"`  // hook id
-	HookName    string `json:"hook_name" gorm:"size:200"`      // hook name
-	HookType    string `json:"hook_type" gorm:"size:50;index"` // hook type: webhook, githook
-	Method      string `json:"method" gorm:"size:10"`          // http method
-	RemoteAddr  string `json:"remote_addr" gorm:"size:45"`     // client ip address
-	Headers     string `json:"headers" gorm:"type:text"`       // request headers
-	Body        string `json:"body" gorm:"type:text"`          // request body
-	Success     bool   `json:"success" gorm:"index"`           // success
-	Output      string `json:"output" gorm:"type:text"`        // output
-	Error       string `json:"error" gorm:"type:text"`         // error
-	Duration    int64  `json:"duration"`                       // duration (milliseconds)
-	UserAgent   string `json:"user_agent" gorm:"size:500"`     // user agent
-	QueryParams string `json:"query_params" gorm:"type:text"`  // query params
+	HookID      string `json:"hook_id" gorm:"size:100;index"`   // hook id
+	HookName    string `json:"hook_name" gorm:"size:200"`       // hook name
+	HookType    string `json:"hook_type" gorm:"size:50;index"`  // hook type: webhook, githook
+	Method      string `json:"method" gorm:"size:10"`           // http method
+	RemoteAddr  string `json:"remote_addr" gorm:"size:45"`      // client ip address
+	Headers     string `json:"headers" gorm:"type:text"`        // request headers
+	Body        string `json:"body" gorm:"type:text"`           // request body
+	Success     bool   `json:"success" gorm:"index"`            // success
+	Output      string `json:"output" gorm:"type:text"`         // output
+	Error       string `json:"error" gorm:"type:text"`          // error
+	Duration    int64  `json:"duration"`                        // duration (milliseconds)
+	UserAgent   string `json:"user_agent" gorm:"size:500"`      // user agent
+	QueryParams string `json:"query_params" gorm:"type:text"`   // query params
+	Namespace   string `json:"namespace" gorm:"size:100;index"` // namespace of the hook or project
+	Alias       string `json:"alias,omitempty" gorm:"size:100"` // previous id of a renamed hook or project the entry was recorded under
 }
 
 // SystemLog system log
 type SystemLog struct {
 	BaseModel
-	Level     string `json:"level" gorm:"size:10;index"`    // log level: DEBUG, INFO, WARN, ERROR
-	Category  string `json:"category" gorm:"size:50;index"` // log category: AUTH, CONFIG, DATABASE, etc.
-	Message   string `json:"message" gorm:"type:text"`      // log message
-	Details   string `json:"details" gorm:"type:text"`      // details
-	UserID    string `json:"user_id" gorm:"size:50;index"`  // user id
-	IPAddress string `json:"ip_address" gorm:"size:45"`     // client ip address
-	UserAgent string `json:"user_agent" gorm:"size:500"`    // User Agent
+	Level     string `json:"level" gorm:"size:10;index"`      // log level: DEBUG, INFO, WARN, ERROR
+	Category  string `json:"category" gorm:"size:50;index"`   // log category: AUTH, CONFIG, DATABASE, etc.
+	Message   string `json:"message" gorm:"type:text"`        // log message
+	Details   string `json:"details" gorm:"type:text"`        // details
+	UserID    string `json:"user_id" gorm:"size:50;index"`    // user id
+	IPAddress string `json:"ip_address" gorm:"size:45"`       // client ip address
+	UserAgent string `json:"user_agent" gorm:"size:500"`      // User Agent
+	Namespace string `json:"namespace" gorm:"size:100;index"` // namespace of the user, empty for unrestricted users
 }
 
 // UserActivity user activity record
 type UserActivity struct {
 	BaseModel
-	Username    string `json:"username" gorm:"size:100;index"` // username
-	Action      string `json:"action" gorm:"size:100;index"`   // action type
-	Resource    string `json:"resource" gorm:"size:200"`       // resource
-	Description string `json:"description" gorm:"type:text"`   // description
-	IPAddress   string `json:"ip_address" gorm:"size:45"`      // IP address
-	UserAgent   string `json:"user_agent" gorm:"size:500"`     // User Agent
-	Success     bool   `json:"success" gorm:"index"`           // success
-	Details     string `json:"details" gorm:"type:text"`       // details
+	Username    string `json:"username" gorm:"size:100;index"`      // username
+	Action      string `json:"action" gorm:"size:100;index"`        // action type
+	Resource    string `json:"resource" gorm:"size:200"`            // resource
+	Description string `json:"description" gorm:"type:text"`        // description
+	IPAddress   string `json:"ip_address" gorm:"size:45"`           // IP address
+	UserAgent   string `json:"user_agent" gorm:"size:500"`          // User Agent
+	Success     bool   `json:"success" gorm:"index"`                // success
+	Details     string `json:"details" gorm:"type:text"`            // details
+	Namespace   string `json:"namespace" gorm:"size:100;index"`     // namespace of the user, empty for unrestricted users
+	PrevHash    string `json:"prev_hash,omitempty" gorm:"size:64"`  // hash of the previous chained record, see SetAuditHashChain
+	Hash        string `json:"hash,omitempty" gorm:"size:64;index"` // hash of this record and PrevHash
 }
 
 // ProjectActivity project activity record
 type ProjectActivity struct {
 	BaseModel
-	ProjectName string `json:"project_name" gorm:"size:200;index"` // project name
-	Action      string `json:"action" gorm:"size:100;index"`       // action type: branch_switch, tag_switch, pull, etc.
-	OldValue    string `json:"old_value" gorm:"size:200"`          // old value (e.g. old branch name)
-	NewValue    string `json:"new_value" gorm:"size:200"`          // new value (e.g. new branch name)
-	Username    string `json:"username" gorm:"size:100;index"`     // username
-	Success     bool   `json:"success" gorm:"index"`               // success
-	Error       string `json:"error" gorm:"type:text"`             // error
-	CommitHash  string `json:"commit_hash" gorm:"size:40"`         // commit hash
-	Description string `json:"description" gorm:"type:text"`       // description
-	IPAddress   string `json:"ip_address" gorm:"size:45"`          // IP address
+	ProjectName string `json:"project_name" gorm:"size:200;index"`  // project name
+	Action      string `json:"action" gorm:"size:100;index"`        // action type: branch_switch, tag_switch, pull, etc.
+	OldValue    string `json:"old_value" gorm:"size:200"`           // old value (e.g. old branch name)
+	NewValue    string `json:"new_value" gorm:"size:200"`           // new value (e.g. new branch name)
+	Username    string `json:"username" gorm:"size:100;index"`      // username
+	Success     bool   `json:"success" gorm:"index"`                // success
+	Error       string `json:"error" gorm:"type:text"`              // error
+	CommitHash  string `json:"commit_hash" gorm:"size:40"`          // commit hash
+	Description string `json:"description" gorm:"type:text"`        // description
+	IPAddress   string `json:"ip_address" gorm:"size:45"`           // IP address
+	Namespace   string `json:"namespace" gorm:"size:100;index"`     // namespace of the project
+	Output      string `json:"output,omitempty" gorm:"type:text"`   // command output, e.g. of database migrations
+	PrevHash    string `json:"prev_hash,omitempty" gorm:"size:64"`  // hash of the previous chained record, see SetAuditHashChain
+	Hash        string `json:"hash,omitempty" gorm:"size:64;index"` // hash of this record and PrevHash
+}
+
+// ProjectEnv encrypted .env content of a project
+type ProjectEnv struct {
+	BaseModel
+	ProjectName string `json:"project_name" gorm:"size:200;uniqueIndex"` // project name
+	Content     string `json:"-" gorm:"type:text"`                       // AES-GCM encrypted content (base64)
+}
+
+// ProjectDeployKey SSH deploy key used by the git commands of a project
+type ProjectDeployKey struct {
+	BaseModel
+	ProjectName string `json:"project_name" gorm:"size:200;uniqueIndex"` // project name
+	PublicKey   string `json:"public_key" gorm:"type:text"`              // authorized_keys line
+	PrivateKey  string `json:"-" gorm:"type:text"`                       // AES-GCM encrypted OpenSSH private key (base64)
+	Fingerprint string `json:"fingerprint" gorm:"size:100"`              // SHA256 fingerprint of the public key
+	CreatedBy   string `json:"created_by" gorm:"size:100"`               // user who generated the key
+}
+
+// QueuedDelivery webhook delivery held back by maintenance mode or a pause window
+type QueuedDelivery struct {
+	BaseModel
+	Kind       string `json:"kind" gorm:"size:20;index"`    // hook, githook
+	Target     string `json:"target" gorm:"size:200;index"` // hook id or project name
+	RequestID  string `json:"request_id" gorm:"size:100"`   // original request id
+	Method     string `json:"method" gorm:"size:10"`        // http method
+	RemoteAddr string `json:"remote_addr" gorm:"size:45"`   // client ip address
+	Headers    string `json:"headers" gorm:"type:text"`     // parsed headers (json)
+	Query      string `json:"query" gorm:"type:text"`       // parsed query (json)
+	Payload    string `json:"payload" gorm:"type:text"`     // parsed payload (json)
+	Body       string `json:"body" gorm:"type:text"`        // raw request body
+	Reason     string `json:"reason" gorm:"size:200"`       // why it was queued
+	Attempts   int    `json:"attempts"`                     // replay attempts
+	LastError  string `json:"last_error" gorm:"type:text"`  // last replay error
+}
+
+// ProjectPromotion promotion of a deployed revision from one project to another
+type ProjectPromotion struct {
+	BaseModel
+	SourceProject string     `json:"source_project" gorm:"size:200;index"` // project the revision is taken from
+	TargetProject string     `json:"target_project" gorm:"size:200;index"` // project switched to the revision
+	RefType       string     `json:"ref_type" gorm:"size:20"`              // tag | commit
+	Ref           string     `json:"ref" gorm:"size:200"`                  // tag name or commit hash
+	CommitHash    string     `json:"commit_hash" gorm:"size:40;index"`     // resolved commit hash
+	Status        string     `json:"status" gorm:"size:20;index"`          // pending | rejected | success | failed
+	RequestedBy   string     `json:"requested_by" gorm:"size:100"`         // username
+	ApprovedBy    string     `json:"approved_by" gorm:"size:100"`          // username of approver or rejecter
+	ParentID      *uint      `json:"parent_id" gorm:"index"`               // promotion that brought the revision into the source project
+	Error         string     `json:"error" gorm:"type:text"`               // error
+	FinishedAt    *time.Time `json:"finished_at"`                          // time the target was switched or the request rejected
+}
+
+// GitMaintenanceRun one git maintenance run of a project checkout, the sizes make the
+// repository growth visible over time
+type GitMaintenanceRun struct {
+	BaseModel
+	ProjectName string `json:"project_name" gorm:"size:200;index"`
+	Trigger     string `json:"trigger" gorm:"size:20"` // schedule | manual
+	Success     bool   `json:"success" gorm:"index"`
+	SizeBefore  int64  `json:"size_before"` // bytes of the object database before the run
+	SizeAfter   int64  `json:"size_after"`  // bytes of the object database after the run
+	DurationMs  int64  `json:"duration_ms"`
+	Output      string `json:"output" gorm:"type:text"`
+	Error       string `json:"error" gorm:"type:text"`
+	CreatedBy   string `json:"created_by" gorm:"size:100"` // username, empty for scheduled runs
 }
 
 // SyncNode represents a managed sync target node
@@ -83,6 +167,7 @@ type SyncNode struct {
 	Health               string     `json:"health" gorm:"size:50;index"` // HEALTHY | DEGRADED | UNKNOWN
 	LastSeen             *time.Time `json:"last_seen" gorm:"index"`
 	Tags                 string     `json:"tags" gorm:"type:text"`     // JSON array
+	Labels               string     `json:"labels" gorm:"type:text"`   // JSON object of key=value labels used by selectors
 	Metadata             string     `json:"metadata" gorm:"type:text"` // additional metadata JSON
 	SSHUser              string     `json:"ssh_user" gorm:"size:100"`
 	SSHPort              int        `json:"ssh_port"`
@@ -93,6 +178,9 @@ type SyncNode struct {
 	InstallStatus        string     `json:"install_status" gorm:"size:50"`                // pending | installing | success | failed
 	InstallLog           string     `json:"install_log" gorm:"type:text"`                 // installation log
 	AgentVersion         string     `json:"agent_version" gorm:"size:100"`
+	ApprovalStatus       string     `json:"approval_status" gorm:"size:20;index"` // pending | approved | revoked (empty: legacy, approved)
+	EnrolledAt           *time.Time `json:"enrolled_at"`
+	RevokedAt            *time.Time `json:"revoked_at"`
 }
 
 // SyncTask represents a sync task dispatch record
@@ -103,7 +191,7 @@ type SyncTask struct {
 	NodeID      uint   `json:"node_id" gorm:"index"`
 	NodeName    string `json:"node_name" gorm:"size:200"`
 	Driver      string `json:"driver" gorm:"size:50"`       // agent | rsync
-	Status      string `json:"status" gorm:"size:50;index"` // pending | running | success | failed | retrying
+	Status      string `json:"status" gorm:"size:50;index"` // waiting | pending | running | success | failed | retrying | cancelled
 	Attempt     int    `json:"attempt"`
 	Payload     string `json:"payload" gorm:"type:text"` // JSON payload
 	Logs        string `json:"logs" gorm:"type:text"`
@@ -113,6 +201,21 @@ type SyncTask struct {
 	BlocksTotal int    `json:"blocks_total"`
 	BytesTotal  int64  `json:"bytes_total"`
 	DurationMs  int64  `json:"duration_ms"`
+	// DeploymentID groups the tasks of one rollout, 0 for tasks created before rollouts existed.
+	DeploymentID uint `json:"deployment_id" gorm:"index"`
+}
+
+// SyncDeployment is one rollout of a project to its sync nodes, its tasks carry the id
+type SyncDeployment struct {
+	BaseModel
+	ProjectName    string `json:"project_name" gorm:"size:200;index"`
+	Strategy       string `json:"strategy" gorm:"size:20"`     // all-at-once | rolling | canary
+	MaxUnavailable int    `json:"max_unavailable"`             // nodes synced at a time by rolling, and by canary after approval
+	Status         string `json:"status" gorm:"size:20;index"` // running | paused | success | failed | aborted
+	Message        string `json:"message" gorm:"type:text"`
+	Approved       bool   `json:"approved"` // canary approved, the remaining nodes may start
+	ApprovedBy     string `json:"approved_by" gorm:"size:100"`
+	CreatedBy      string `json:"created_by" gorm:"size:100"`
 }
 
 // SyncFileChange represents pending change detected by scanner/watcher
@@ -130,6 +233,31 @@ type SyncFileChange struct {
 	Error       string    `json:"error" gorm:"type:text"`
 }
 
+// ClusterLease leadership lease shared by gohook instances in HA mode
+type ClusterLease struct {
+	Name      string    `json:"name" gorm:"primaryKey;size:100"` // lease name
+	Holder    string    `json:"holder" gorm:"size:200"`          // node id of the holder
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`         // lease is free after this time
+}
+
+// ClusterNode gohook instance taking part in HA mode
+type ClusterNode struct {
+	NodeID    string    `json:"node_id" gorm:"primaryKey;size:200"` // node id
+	Hostname  string    `json:"hostname" gorm:"size:200"`           // host the instance runs on
+	Version   string    `json:"version" gorm:"size:50"`             // gohook version
+	StartedAt time.Time `json:"started_at"`                         // process start time
+	LastSeen  time.Time `json:"last_seen" gorm:"index"`             // last heartbeat
+}
+
+// ClusterEvent event relayed from one instance to the others in HA mode
+type ClusterEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Origin    string    `json:"origin" gorm:"size:200;index"` // node id of the publisher
+	Type      string    `json:"type" gorm:"size:50"`          // event type
+	Payload   string    `json:"payload" gorm:"type:text"`     // event data (json)
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
 // LogLevel log level constant
 const (
 	LogLevelDebug = "DEBUG"
@@ -166,25 +294,116 @@ const (
 	UserActionUpdateHookScript   = "UPDATE_HOOK_SCRIPT"
 	UserActionDeleteHook         = "DELETE_HOOK"
 	// Add missing constants
-	UserActionUpdateHookParameters = "UPDATE_HOOK_PARAMETERS"
-	UserActionUpdateHookTriggers   = "UPDATE_HOOK_TRIGGERS"
-	UserActionSaveHookScript       = "SAVE_HOOK_SCRIPT"
+	UserActionUpdateHookParameters       = "UPDATE_HOOK_PARAMETERS"
+	UserActionUpdateHookTriggers         = "UPDATE_HOOK_TRIGGERS"
+	UserActionSaveHookScript             = "SAVE_HOOK_SCRIPT"
+	UserActionUpdateHookForward          = "UPDATE_HOOK_FORWARD"
+	UserActionUpdateHookIdempotency      = "UPDATE_HOOK_IDEMPOTENCY"
+	UserActionScriptPathDenied           = "SCRIPT_PATH_DENIED"
+	UserActionCommandDenied              = "COMMAND_POLICY_DENIED"
+	UserActionUpdateHookEnvironment      = "UPDATE_HOOK_ENVIRONMENT"
+	UserActionUpdateHookAliases          = "UPDATE_HOOK_ALIASES"
+	UserActionUpdateHookEndpoints        = "UPDATE_HOOK_ENDPOINTS"
+	UserActionRotateHookSecret           = "ROTATE_HOOK_SECRET"
+	UserActionUpdateHookArtifacts        = "UPDATE_HOOK_ARTIFACTS"
+	UserActionUpdateHookObjectEvents     = "UPDATE_HOOK_OBJECT_EVENTS"
+	UserActionUpdateHookTransformPlugins = "UPDATE_HOOK_TRANSFORM_PLUGINS"
+	UserActionUpdateHookStarlark         = "UPDATE_HOOK_STARLARK"
+	UserActionUpdateHookBudget           = "UPDATE_HOOK_BUDGET"
+	UserActionResetHookCircuit           = "RESET_HOOK_CIRCUIT"
+	UserActionUpdateHookQuota            = "UPDATE_HOOK_QUOTA"
+	UserActionTriggerHook                = "TRIGGER_HOOK"
 
 	// System configuration management operation
 	UserActionViewSystemConfig   = "VIEW_SYSTEM_CONFIG"
 	UserActionUpdateSystemConfig = "UPDATE_SYSTEM_CONFIG"
+	UserActionUpdateServerConfig = "UPDATE_SERVER_CONFIG"
+
+	// Project env operation
+	UserActionRevealEnv = "REVEAL_ENV"
+
+	// Maintenance operation
+	UserActionUpdateMaintenance = "UPDATE_MAINTENANCE"
+	UserActionResizeWorkerPool  = "RESIZE_WORKER_POOL"
+	UserActionFlushQueue        = "FLUSH_DELIVERY_QUEUE"
+	UserActionDiscardQueue      = "DISCARD_DELIVERY_QUEUE"
+
+	// Diagnostics bundle download
+	UserActionDiagnostics = "DOWNLOAD_DIAGNOSTICS"
+
+	// Update of the server binary
+	UserActionSelfUpdate = "SELF_UPDATE"
+
+	// Configuration import
+	UserActionImportConfig = "IMPORT_CONFIG"
+
+	// Configuration backup
+	UserActionBackupConfig  = "BACKUP_CONFIG"
+	UserActionRestoreConfig = "RESTORE_CONFIG"
+	UserActionGitOpsSync    = "GITOPS_SYNC"
+
+	// Project and trash management
+	UserActionDeleteProject = "DELETE_PROJECT"
+	UserActionRenameProject = "RENAME_PROJECT"
+	UserActionRenameHook    = "RENAME_HOOK"
+	UserActionRestoreTrash  = "RESTORE_TRASH"
+	UserActionPurgeTrash    = "PURGE_TRASH"
+
+	// Namespace management
+	UserActionCreateNamespace = "CREATE_NAMESPACE"
+	UserActionUpdateNamespace = "UPDATE_NAMESPACE"
+	UserActionDeleteNamespace = "DELETE_NAMESPACE"
+
+	// HA cluster operation
+	UserActionClusterStepDown = "CLUSTER_STEP_DOWN"
+
+	// Plugin management
+	UserActionEnablePlugin       = "ENABLE_PLUGIN"
+	UserActionDisablePlugin      = "DISABLE_PLUGIN"
+	UserActionUpdatePluginConfig = "UPDATE_PLUGIN_CONFIG"
+	UserActionInstallPlugin      = "INSTALL_PLUGIN"
+	UserActionRemovePlugin       = "REMOVE_PLUGIN"
 )
 
 // ProjectAction project action constant
 const (
-	ProjectActionBranchSwitch = "BRANCH_SWITCH"
-	ProjectActionTagSwitch    = "TAG_SWITCH"
-	ProjectActionPull         = "PULL"
-	ProjectActionAdd          = "ADD"
-	ProjectActionDelete       = "DELETE"
-	ProjectActionUpdate       = "UPDATE"
+	ProjectActionBranchSwitch    = "BRANCH_SWITCH"
+	ProjectActionTagSwitch       = "TAG_SWITCH"
+	ProjectActionPull            = "PULL"
+	ProjectActionAdd             = "ADD"
+	ProjectActionDelete          = "DELETE"
+	ProjectActionUpdate          = "UPDATE"
+	ProjectActionService         = "SERVICE"
+	ProjectActionPromote         = "PROMOTE"
+	ProjectActionRename          = "RENAME"
+	ProjectActionSnapshot        = "SNAPSHOT"
+	ProjectActionPolicyViolation = "POLICY_VIOLATION"
+	ProjectActionKubernetes      = "KUBERNETES"
+	ProjectActionRollback        = "ROLLBACK"
+	ProjectActionCompose         = "COMPOSE"
+	ProjectActionMigration       = "MIGRATION"
+	ProjectActionRelease         = "RELEASE"
+	ProjectActionHealthCheck     = "HEALTH_CHECK"
+	ProjectActionPin             = "PIN"
+	ProjectActionUnpin           = "UNPIN"
+	ProjectActionRotateSecret    = "ROTATE_SECRET"
+	ProjectActionDeployKey       = "DEPLOY_KEY"
+	ProjectActionProviderWebhook = "PROVIDER_WEBHOOK"
 )
 
+// DeployActions project activity actions that change the deployed revision
+var DeployActions = []string{
+	ProjectActionBranchSwitch,
+	ProjectActionTagSwitch,
+	"switch-tag",
+	ProjectActionPromote,
+	ProjectActionKubernetes,
+	ProjectActionCompose,
+	ProjectActionMigration,
+	ProjectActionRelease,
+	ProjectActionHealthCheck,
+}
+
 // HookType hook type constant
 const (
 	HookTypeWebhook = "webhook" // user-defined webhook
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// node log sources
+const (
+	NodeLogSourceAgent   = "agent"   // log output of the agent process
+	NodeLogSourceCommand = "command" // output of a command executed on the node
+)
+
+// NodeLog log line shipped by a sync node agent, created_at is the time on the node
+type NodeLog struct {
+	BaseModel
+	NodeID   uint   `json:"node_id" gorm:"index"`          // sync node id
+	NodeName string `json:"node_name" gorm:"size:100"`     // agent name when the line was shipped
+	Source   string `json:"source" gorm:"size:20;index"`   // agent or command
+	Level    string `json:"level" gorm:"size:10;index"`    // log level: INFO, WARN, ERROR
+	Message  string `json:"message" gorm:"type:text"`      // log line or command output
+	Command  string `json:"command" gorm:"size:200;index"` // command the output belongs to, empty for agent logs
+}
+
+// SaveNodeLogs store a batch of log lines shipped by node nodeID
+func SaveNodeLogs(nodeID uint, nodeName string, logs []NodeLog) error {
+	if DB == nil || len(logs) == 0 {
+		return nil
+	}
+	for i := range logs {
+		logs[i].ID = 0
+		logs[i].NodeID = nodeID
+		logs[i].NodeName = nodeName
+		if logs[i].CreatedAt.IsZero() {
+			logs[i].CreatedAt = time.Now()
+		}
+	}
+	if err := DB.CreateInBatches(&logs, 100).Error; err != nil {
+		return fmt.Errorf("save logs of node %d: %v", nodeID, err)
+	}
+	return nil
+}
+
+// GetNodeLogsForAPI get log lines of node nodeID for the API, search matches the message or command.
+// Node logs have no namespace, use a service that is not scoped to one.
+func (s *LogService) GetNodeLogsForAPI(page, pageSize int, nodeID uint, level, source, search string, startTime, endTime *time.Time) ([]map[string]interface{}, int64, error) {
+	query := s.db.Model(&NodeLog{}).Where("node_id = ?", nodeID)
+	if level != "" {
+		query = query.Where("level = ?", level)
+	}
+	if source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if search != "" {
+		query = query.Where("(message LIKE ? OR command LIKE ?)", "%"+search+"%", "%"+search+"%")
+	}
+	if startTime != nil {
+		query = query.Where("created_at >= ?", *startTime)
+	}
+	if endTime != nil {
+		query = query.Where("created_at <= ?", *endTime)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var logs []NodeLog
+	offset := (page - 1) * pageSize
+	if err := query.Offset(offset).Limit(pageSize).Order("created_at DESC, id DESC").Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]map[string]interface{}, 0, len(logs))
+	for _, log := range logs {
+		result = append(result, map[string]interface{}{
+			"id":        log.ID,
+			"type":      "node",
+			"timestamp": log.CreatedAt.Format(time.RFC3339),
+			"nodeId":    log.NodeID,
+			"nodeName":  log.NodeName,
+			"source":    log.Source,
+			"level":     log.Level,
+			"command":   log.Command,
+			"message":   log.Message,
+		})
+	}
+	return result, total, nil
+}
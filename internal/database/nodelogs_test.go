@@ -0,0 +1,77 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestNodeLogs(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&HookLog{}, &HookArtifact{}, &SystemLog{}, &UserActivity{}, &ProjectActivity{}, &NodeLog{}, &UserMessage{}); err != nil {
+		t.Fatal(err)
+	}
+	saved := DB
+	DB = conn
+	defer func() { DB = saved }()
+
+	old := NodeLog{Source: NodeLogSourceAgent, Level: "INFO", Message: "started"}
+	old.CreatedAt = time.Now().AddDate(0, 0, -10)
+	if err := SaveNodeLogs(1, "edge1", []NodeLog{
+		old,
+		{Source: NodeLogSourceAgent, Level: "ERROR", Message: "tcp connect failed"},
+		{Source: NodeLogSourceCommand, Level: "INFO", Command: "deploy", Message: "done"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveNodeLogs(2, "edge2", []NodeLog{{Source: NodeLogSourceAgent, Level: "ERROR", Message: "other node"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &LogService{db: conn}
+	tests := []struct {
+		name                  string
+		level, source, search string
+		start                 *time.Time
+		want                  int64
+	}{
+		{"all", "", "", "", nil, 3},
+		{"level", "ERROR", "", "", nil, 1},
+		{"source", "", NodeLogSourceCommand, "", nil, 1},
+		{"search command", "", "", "deploy", nil, 1},
+		{"search message", "", "", "connect", nil, 1},
+		{"start", "", "", "", ptrTime(time.Now().AddDate(0, 0, -1)), 2},
+	}
+	for _, tt := range tests {
+		logs, total, err := svc.GetNodeLogsForAPI(1, 20, 1, tt.level, tt.source, tt.search, tt.start, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if total != tt.want || int64(len(logs)) != tt.want {
+			t.Errorf("%s: got %d (%d rows), want %d", tt.name, total, len(logs), tt.want)
+		}
+	}
+
+	// namespaced cleanups leave node logs alone, unrestricted ones remove old lines
+	if err := svc.InNamespace("team").CleanOldLogs(5); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, _ := svc.GetNodeLogsForAPI(1, 20, 1, "", "", "", nil, nil); total != 3 {
+		t.Errorf("%d node logs after a namespaced cleanup, want 3", total)
+	}
+	if err := svc.CleanOldLogs(5); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, _ := svc.GetNodeLogsForAPI(1, 20, 1, "", "", "", nil, nil); total != 2 {
+		t.Errorf("%d node logs after cleaning, want 2", total)
+	}
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
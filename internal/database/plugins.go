@@ -0,0 +1,39 @@
+package database
+
+// PluginConf persisted state of a plugin found in the plugins directory, its id is the id of
+// the plugin in the API
+type PluginConf struct {
+	BaseModel
+	Name    string `json:"name" gorm:"size:100;uniqueIndex"` // file name without extension
+	Enabled bool   `json:"enabled" gorm:"not null"`
+	Config  string `json:"config" gorm:"type:text"` // YAML passed to the plugin
+	Token   string `json:"-" gorm:"size:64"`        // random token of the plugin URLs
+}
+
+// GetPluginConf stored state of the plugin name, nil when it was never seen
+func GetPluginConf(name string) (*PluginConf, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	var confs []PluginConf
+	if err := DB.Where("name = ?", name).Limit(1).Find(&confs).Error; err != nil || len(confs) == 0 {
+		return nil, err
+	}
+	return &confs[0], nil
+}
+
+// SavePluginConf create or update the stored state of a plugin
+func SavePluginConf(conf *PluginConf) error {
+	if DB == nil {
+		return nil
+	}
+	return DB.Save(conf).Error
+}
+
+// DeletePluginConf forget the stored state of the plugin name
+func DeletePluginConf(name string) error {
+	if DB == nil {
+		return nil
+	}
+	return DB.Unscoped().Where("name = ?", name).Delete(&PluginConf{}).Error
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// listenDSN connection string of the PostgreSQL database, empty for other databases
+var listenDSN string
+
+// listenRetry wait before a lost LISTEN connection is opened again
+const listenRetry = 5 * time.Second
+
+// postgresDSN connection URL of a PostgreSQL database, DSN is used as is when set
+func (c *DatabaseConfig) postgresDSN() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+	host := c.Host
+	if host == "" {
+		host = "localhost"
+	}
+	if c.Port != 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(c.Port))
+	}
+	u := url.URL{Scheme: "postgres", Host: host, Path: "/" + c.Database}
+	if c.Username != "" {
+		u.User = url.UserPassword(c.Username, c.Password)
+	}
+	if c.SSLMode != "" {
+		u.RawQuery = url.Values{"sslmode": {c.SSLMode}}.Encode()
+	}
+	return u.String()
+}
+
+// SupportsNotify report whether the database relays notifications between connections,
+// which PostgreSQL does with LISTEN/NOTIFY
+func SupportsNotify() bool {
+	return listenDSN != ""
+}
+
+// Notify send payload to the connections listening on channel once tx commits, it does
+// nothing on databases without LISTEN/NOTIFY
+func Notify(tx *gorm.DB, channel, payload string) error {
+	if !SupportsNotify() {
+		return nil
+	}
+	return tx.Exec("SELECT pg_notify(?, ?)", channel, payload).Error
+}
+
+// Listen call fn with the payload of every notification on channel until ctx is done. A
+// lost connection is opened again, notifications sent in between are missed.
+func Listen(ctx context.Context, channel string, fn func(payload string)) {
+	for ctx.Err() == nil {
+		if err := listen(ctx, channel, fn); err != nil && ctx.Err() == nil {
+			log.Printf("database: listening on %s failed: %v", channel, err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(listenRetry):
+		}
+	}
+}
+
+func listen(ctx context.Context, channel string, fn func(payload string)) error {
+	conn, err := pgx.Connect(ctx, listenDSN)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		fn(n.Payload)
+	}
+}
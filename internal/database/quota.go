@@ -0,0 +1,75 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QuotaScope execution logs counted against a quota
+type QuotaScope struct {
+	HookID    string // webhook executions of a hook
+	Namespace string // executions of the hooks and projects of a namespace
+}
+
+func (s QuotaScope) logs() (*gorm.DB, error) {
+	if DB == nil {
+		return nil, errors.New("database not initialized")
+	}
+	query := DB.Model(&HookLog{})
+	if s.HookID != "" {
+		query = query.Where("hook_id = ? AND hook_type = ?", s.HookID, HookTypeWebhook)
+	}
+	if s.Namespace != "" {
+		query = query.Where("namespace = ?", s.Namespace)
+	}
+	return query, nil
+}
+
+// CountExecutionsSince number of executions of the scope logged since
+func CountExecutionsSince(scope QuotaScope, since time.Time) (int64, error) {
+	query, err := scope.logs()
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = query.Where("created_at >= ?", since).Count(&count).Error
+	return count, err
+}
+
+// StorageBytes size of the execution logs of the scope and of their artifacts. Text columns
+// are measured in characters, so multi-byte output is counted low.
+func StorageBytes(scope QuotaScope) (int64, error) {
+	query, err := scope.logs()
+	if err != nil {
+		return 0, err
+	}
+	var logs, artifacts int64
+	err = query.Session(&gorm.Session{}).
+		Select("COALESCE(SUM(LENGTH(headers) + LENGTH(body) + LENGTH(output) + LENGTH(error) + LENGTH(query_params)), 0)").
+		Scan(&logs).Error
+	if err != nil {
+		return 0, err
+	}
+	err = DB.Model(&HookArtifact{}).
+		Select("COALESCE(SUM(LENGTH(data)), 0)").
+		Where("hook_log_id IN (?)", query.Session(&gorm.Session{}).Select("id")).
+		Scan(&artifacts).Error
+	if err != nil {
+		return 0, err
+	}
+	return logs + artifacts, nil
+}
+
+// CountUserActionsSince number of actions of username recorded since
+func CountUserActionsSince(username, action string, since time.Time) (int64, error) {
+	if DB == nil {
+		return 0, errors.New("database not initialized")
+	}
+	var count int64
+	err := DB.Model(&UserActivity{}).
+		Where("username = ? AND action = ? AND created_at >= ?", username, action, since).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestQuotaUsage(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&HookLog{}, &HookArtifact{}, &UserActivity{}); err != nil {
+		t.Fatal(err)
+	}
+	saved := DB
+	DB = conn
+	defer func() { DB = saved }()
+
+	now := time.Now()
+	logs := []*HookLog{
+		{HookID: "deploy", HookType: HookTypeWebhook, Namespace: "team-a", Output: "done", Headers: "{}"},
+		{HookID: "deploy", HookType: HookTypeWebhook, Namespace: "team-a", Error: "failed"},
+		{HookID: "site", HookType: HookTypeGitHook, Namespace: "team-a", Body: "{}"},
+		{HookID: "build", HookType: HookTypeWebhook, Namespace: "team-b", Output: "ok"},
+	}
+	for _, l := range logs {
+		if err := conn.Create(l).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := &HookLog{HookID: "deploy", HookType: HookTypeWebhook, Namespace: "team-a", Output: "yesterday"}
+	old.CreatedAt = now.Add(-30 * time.Hour)
+	if err := conn.Create(old).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Create(&HookArtifact{HookLogID: logs[0].ID, Name: "report.txt", Data: []byte("0123456789")}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	since := now.Add(-time.Hour)
+	counts := []struct {
+		scope QuotaScope
+		want  int64
+	}{
+		{QuotaScope{HookID: "deploy"}, 2},
+		{QuotaScope{HookID: "site"}, 0}, // githook logs are counted for namespaces only
+		{QuotaScope{Namespace: "team-a"}, 3},
+		{QuotaScope{Namespace: "team-c"}, 0},
+	}
+	for _, tt := range counts {
+		if got, err := CountExecutionsSince(tt.scope, since); err != nil || got != tt.want {
+			t.Errorf("CountExecutionsSince(%+v) = %d, %v, want %d", tt.scope, got, err, tt.want)
+		}
+	}
+
+	// "done" + "{}" + "failed" + "yesterday" + the artifact, empty columns count nothing
+	if got, err := StorageBytes(QuotaScope{HookID: "deploy"}); err != nil || got != 4+2+6+9+10 {
+		t.Errorf("StorageBytes(deploy) = %d, %v", got, err)
+	}
+	if got, err := StorageBytes(QuotaScope{Namespace: "team-b"}); err != nil || got != 2 {
+		t.Errorf("StorageBytes(team-b) = %d, %v", got, err)
+	}
+
+	for _, a := range []*UserActivity{
+		{Username: "alice", Action: UserActionTriggerHook},
+		{Username: "alice", Action: UserActionTriggerHook, Success: true},
+		{Username: "alice", Action: UserActionLogin},
+		{Username: "bob", Action: UserActionTriggerHook},
+	} {
+		if err := conn.Create(a).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := CountUserActionsSince("alice", UserActionTriggerHook, since); err != nil || got != 2 {
+		t.Errorf("CountUserActionsSince(alice) = %d, %v", got, err)
+	}
+}
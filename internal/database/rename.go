@@ -0,0 +1,110 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// queued delivery kinds, see maintenance.KindHook and maintenance.KindGitHook
+const (
+	queueKindHook    = "hook"
+	queueKindGitHook = "githook"
+)
+
+// RenameHookReferences move the logs and queued deliveries of a renamed hook to its new id,
+// existing logs keep the old id as alias
+func RenameHookReferences(oldID, newID string) error {
+	if DB == nil {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := renameHookLogs(tx, HookTypeWebhook, oldID, newID); err != nil {
+			return err
+		}
+		return tx.Model(&QueuedDelivery{}).Where("kind = ? AND target = ?", queueKindHook, oldID).
+			Update("target", newID).Error
+	})
+}
+
+// RenameProjectReferences move the rows referencing a renamed project to its new name,
+// GitHook logs keep the old name as alias
+func RenameProjectReferences(oldName, newName string) error {
+	if DB == nil {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkProjectRename(tx, newName); err != nil {
+			return err
+		}
+		// the name is unique: drop deleted rows left by a former project
+		for _, model := range []interface{}{&ProjectEnv{}, &ProjectDeployKey{}} {
+			if err := tx.Unscoped().Where("project_name = ?", newName).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := renameHookLogs(tx, HookTypeGitHook, oldName, newName); err != nil {
+			return err
+		}
+		updates := []struct {
+			model  interface{}
+			column string
+		}{
+			{&ProjectEnv{}, "project_name"},
+			{&ProjectDeployKey{}, "project_name"},
+			{&ProjectActivity{}, "project_name"},
+			{&ProjectPromotion{}, "source_project"},
+			{&ProjectPromotion{}, "target_project"},
+			{&SyncTask{}, "project_name"},
+			{&SyncDeployment{}, "project_name"},
+			{&SyncFileChange{}, "project_name"},
+			{&GitMaintenanceRun{}, "project_name"},
+		}
+		for _, u := range updates {
+			if err := tx.Unscoped().Model(u.model).Where(u.column+" = ?", oldName).Update(u.column, newName).Error; err != nil {
+				return fmt.Errorf("rename %s: %v", u.column, err)
+			}
+		}
+		return tx.Model(&QueuedDelivery{}).Where("kind = ? AND target = ?", queueKindGitHook, oldName).
+			Update("target", newName).Error
+	})
+}
+
+// CheckProjectRename report why the database cannot move a project to newName
+func CheckProjectRename(newName string) error {
+	if DB == nil {
+		return nil
+	}
+	return checkProjectRename(DB, newName)
+}
+
+func checkProjectRename(tx *gorm.DB, newName string) error {
+	var count int64
+	if err := tx.Model(&ProjectEnv{}).Where("project_name = ?", newName).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("an environment is still stored for project %s", newName)
+	}
+	if err := tx.Model(&ProjectDeployKey{}).Where("project_name = ?", newName).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("a deploy key is still stored for project %s", newName)
+	}
+	return nil
+}
+
+func renameHookLogs(tx *gorm.DB, hookType, oldID, newID string) error {
+	// entries already carrying an alias keep the id they were first recorded under
+	if err := tx.Model(&HookLog{}).Where("hook_type = ? AND hook_id = ? AND (alias IS NULL OR alias = '')", hookType, oldID).
+		Update("alias", oldID).Error; err != nil {
+		return fmt.Errorf("rename hook logs: %v", err)
+	}
+	if err := tx.Model(&HookLog{}).Where("hook_type = ? AND hook_id = ?", hookType, oldID).
+		Update("hook_id", newID).Error; err != nil {
+		return fmt.Errorf("rename hook logs: %v", err)
+	}
+	return nil
+}
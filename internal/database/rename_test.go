@@ -0,0 +1,79 @@
+package database
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRenameReferences(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&HookLog{}, &QueuedDelivery{}, &ProjectEnv{}, &ProjectDeployKey{}, &ProjectActivity{},
+		&ProjectPromotion{}, &SyncTask{}, &SyncDeployment{}, &SyncFileChange{}, &GitMaintenanceRun{}); err != nil {
+		t.Fatal(err)
+	}
+	saved := DB
+	DB = conn
+	defer func() { DB = saved }()
+
+	rows := []interface{}{
+		&HookLog{HookID: "deploy", HookType: HookTypeWebhook},
+		&HookLog{HookID: "deploy", HookType: HookTypeWebhook, Alias: "first"},
+		&HookLog{HookID: "site", HookType: HookTypeGitHook},
+		&HookLog{HookID: "site", HookType: HookTypeWebhook},
+		&QueuedDelivery{Kind: queueKindHook, Target: "deploy"},
+		&QueuedDelivery{Kind: queueKindGitHook, Target: "site"},
+		&ProjectEnv{ProjectName: "site", Content: "x"},
+		&ProjectDeployKey{ProjectName: "site", PrivateKey: "k"},
+		&ProjectPromotion{SourceProject: "site", TargetProject: "prod"},
+	}
+	for _, row := range rows {
+		if err := conn.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := RenameHookReferences("deploy", "deploy-site"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RenameProjectReferences("site", "www"); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs []HookLog
+	conn.Order("id").Find(&logs)
+	want := []struct{ id, alias string }{{"deploy-site", "deploy"}, {"deploy-site", "first"}, {"www", "site"}, {"site", ""}}
+	for i, w := range want {
+		if logs[i].HookID != w.id || logs[i].Alias != w.alias {
+			t.Errorf("log %d = %s (alias %q), want %s (alias %q)", i, logs[i].HookID, logs[i].Alias, w.id, w.alias)
+		}
+	}
+	var count int64
+	conn.Model(&QueuedDelivery{}).Where("target IN ?", []string{"deploy-site", "www"}).Count(&count)
+	if count != 2 {
+		t.Errorf("%d queued deliveries moved, want 2", count)
+	}
+	conn.Model(&ProjectEnv{}).Where("project_name = ?", "www").Count(&count)
+	if count != 1 {
+		t.Error("project env not moved")
+	}
+	conn.Model(&ProjectDeployKey{}).Where("project_name = ?", "www").Count(&count)
+	if count != 1 {
+		t.Error("deploy key not moved")
+	}
+	conn.Model(&ProjectPromotion{}).Where("source_project = ? AND target_project = ?", "www", "prod").Count(&count)
+	if count != 1 {
+		t.Error("promotion not moved")
+	}
+
+	if err := conn.Create(&ProjectEnv{ProjectName: "api", Content: "y"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckProjectRename("api"); err == nil {
+		t.Error("renaming onto a stored environment must fail")
+	}
+}
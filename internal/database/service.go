@@ -6,12 +6,18 @@ import (
 	"sort"
 	"time"
 
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/redact"
 	"gorm.io/gorm"
 )
 
 // LogService log service
 type LogService struct {
-	db *gorm.DB
+	db  *gorm.DB
+	ns  string         // namespace the queries are scoped to, empty for all
+	loc *time.Location // zone of the returned timestamps, as stored when nil
+	// decrypt encrypted fields of hook logs instead of masking them, see Decrypting
+	decrypt bool
 }
 
 // NewLogService create log service instance
@@ -19,35 +25,83 @@ func NewLogService() *LogService {
 	return &LogService{db: GetDB()}
 }
 
+// InNamespace return a log service whose queries only see entries of ns, empty ns sees all entries
+func (s *LogService) InNamespace(ns string) *LogService {
+	if ns == "" || s.db == nil {
+		return s
+	}
+	return &LogService{db: s.db.Where("namespace = ?", ns).Session(&gorm.Session{}), ns: ns, loc: s.loc, decrypt: s.decrypt}
+}
+
+// InZone service returning timestamps in loc
+func (s *LogService) InZone(loc *time.Location) *LogService {
+	scoped := *s
+	scoped.loc = loc
+	return &scoped
+}
+
+// timestamp t as RFC3339 in the zone of the service
+func (s *LogService) timestamp(t time.Time) string {
+	if s.loc != nil {
+		t = t.In(s.loc)
+	}
+	return t.Format(time.RFC3339)
+}
+
 // CreateHookLog create hook execution log
-func (s *LogService) CreateHookLog(hookID, hookName, hookType, method, remoteAddr string,
+func (s *LogService) CreateHookLog(hookID, alias, hookName, hookType, method, remoteAddr string,
 	headers map[string][]string, body string, success bool, output, error string,
 	duration int64, userAgent string, queryParams map[string][]string) error {
 
+	_, err := s.createHookLog(hookID, alias, hookName, hookType, method, remoteAddr,
+		headers, body, success, output, error, duration, userAgent, queryParams)
+	return err
+}
+
+// createHookLog create a hook log and return it, nil without a database
+func (s *LogService) createHookLog(hookID, alias, hookName, hookType, method, remoteAddr string,
+	headers map[string][]string, body string, success bool, output, error string,
+	duration int64, userAgent string, queryParams map[string][]string) (*HookLog, error) {
+
 	if s.db == nil {
-		return nil
+		return nil, nil
 	}
 
-	headersJSON, _ := json.Marshal(headers)
-	queryParamsJSON, _ := json.Marshal(queryParams)
+	// secrets are redacted before the log is stored, then the remaining fields encrypted
+	headersJSON, _ := json.Marshal(redact.Header(headers))
+	queryParamsJSON, _ := json.Marshal(redact.Header(queryParams))
+
+	kind := namespace.KindHook
+	if hookType == HookTypeGitHook {
+		kind = namespace.KindProject // githook logs use the project name as hook id
+	}
 
 	log := &HookLog{
+		Namespace:   namespace.Of(kind, hookID),
 		HookID:      hookID,
 		HookName:    hookName,
 		HookType:    hookType,
 		Method:      method,
 		RemoteAddr:  remoteAddr,
 		Headers:     string(headersJSON),
-		Body:        body,
+		Body:        redact.String(body),
 		Success:     success,
-		Output:      output,
-		Error:       error,
+		Output:      redact.String(output),
+		Error:       redact.String(error),
 		Duration:    duration,
 		UserAgent:   userAgent,
 		QueryParams: string(queryParamsJSON),
+		Alias:       alias,
+	}
+	if err := log.seal(); err != nil {
+		return nil, err
 	}
 
-	return s.db.Create(log).Error
+	if err := s.db.Create(log).Error; err != nil {
+		return nil, err
+	}
+	publishLog(LogEvent{Type: LogEventHook, Hook: log})
+	return log, nil
 }
 
 // CreateSystemLog create system log
@@ -63,21 +117,26 @@ func (s *LogService) CreateSystemLog(level, category, message string, details in
 	}
 	var detailsJSON string
 	if details != nil {
-		detailsBytes, _ := json.Marshal(details)
+		detailsBytes, _ := json.Marshal(redact.Value(details))
 		detailsJSON = string(detailsBytes)
 	}
 
 	log := &SystemLog{
+		Namespace: namespace.Of(namespace.KindUser, userID),
 		Level:     level,
 		Category:  category,
-		Message:   message,
+		Message:   redact.String(message),
 		Details:   detailsJSON,
 		UserID:    userID,
 		IPAddress: ipAddress,
 		UserAgent: userAgent,
 	}
 
-	return s.db.Create(log).Error
+	if err := s.db.Create(log).Error; err != nil {
+		return err
+	}
+	publishLog(LogEvent{Type: LogEventSystem, System: log})
+	return nil
 }
 
 // CreateUserActivity create user activity record
@@ -93,11 +152,12 @@ func (s *LogService) CreateUserActivity(username, action, resource, description,
 	}
 	var detailsJSON string
 	if details != nil {
-		detailsBytes, _ := json.Marshal(details)
+		detailsBytes, _ := json.Marshal(redact.Value(details))
 		detailsJSON = string(detailsBytes)
 	}
 
 	activity := &UserActivity{
+		Namespace:   namespace.Of(namespace.KindUser, username),
 		Username:    username,
 		Action:      action,
 		Resource:    resource,
@@ -108,7 +168,11 @@ func (s *LogService) CreateUserActivity(username, action, resource, description,
 		Details:     detailsJSON,
 	}
 
-	return s.db.Create(activity).Error
+	if err := createChained(s.db, activity); err != nil {
+		return err
+	}
+	publishLog(LogEvent{Type: LogEventUser, User: activity})
+	return nil
 }
 
 // CreateProjectActivity create project activity record
@@ -135,7 +199,16 @@ func (s *LogService) CreateProjectActivity(projectName, action, oldValue, newVal
 		IPAddress:   ipAddress,
 	}
 
-	return s.db.Create(activity).Error
+	return s.SaveProjectActivity(activity)
+}
+
+// SaveProjectActivity create a project activity record, the namespace is taken from the project
+func (s *LogService) SaveProjectActivity(activity *ProjectActivity) error {
+	if s.db == nil {
+		return nil
+	}
+	activity.Namespace = namespace.Of(namespace.KindProject, activity.ProjectName)
+	return createChained(s.db, activity)
 }
 
 // GetHookLogs get hook log list (support pagination and filtering)
@@ -174,6 +247,10 @@ func (s *LogService) GetHookLogs(page, pageSize int, hookID, hookName, hookType
 	var logs []HookLog
 	offset := (page - 1) * pageSize
 	err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&logs).Error
+	s.reveal(logs)
+	for i := range logs {
+		logs[i].In(s.loc)
+	}
 
 	return logs, total, err
 }
@@ -211,6 +288,9 @@ func (s *LogService) GetSystemLogs(page, pageSize int, level, category, userID s
 	var logs []SystemLog
 	offset := (page - 1) * pageSize
 	err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&logs).Error
+	for i := range logs {
+		logs[i].In(s.loc)
+	}
 
 	return logs, total, err
 }
@@ -248,6 +328,9 @@ func (s *LogService) GetUserActivities(page, pageSize int, username, action stri
 	var activities []UserActivity
 	offset := (page - 1) * pageSize
 	err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&activities).Error
+	for i := range activities {
+		activities[i].In(s.loc)
+	}
 
 	return activities, total, err
 }
@@ -288,6 +371,9 @@ func (s *LogService) GetProjectActivities(page, pageSize int, projectName, actio
 	var activities []ProjectActivity
 	offset := (page - 1) * pageSize
 	err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&activities).Error
+	for i := range activities {
+		activities[i].In(s.loc)
+	}
 
 	return activities, total, err
 }
@@ -418,12 +504,25 @@ func (s *LogService) CleanOldLogs(days int) error {
 	if err := s.db.Where("created_at < ?", cutoffTime).Delete(&HookLog{}).Error; err != nil {
 		return fmt.Errorf("failed to clean hook logs: %v", err)
 	}
+	if err := cleanArtifacts(s.db); err != nil {
+		return fmt.Errorf("failed to clean hook artifacts: %v", err)
+	}
 
 	// clean system logs
 	if err := s.db.Where("created_at < ?", cutoffTime).Delete(&SystemLog{}).Error; err != nil {
 		return fmt.Errorf("failed to clean system logs: %v", err)
 	}
 
+	// clean node logs and user inbox messages, they belong to no namespace
+	if s.ns == "" {
+		if err := s.db.Where("created_at < ?", cutoffTime).Delete(&NodeLog{}).Error; err != nil {
+			return fmt.Errorf("failed to clean node logs: %v", err)
+		}
+		if err := s.db.Unscoped().Where("created_at < ?", cutoffTime).Delete(&UserMessage{}).Error; err != nil {
+			return fmt.Errorf("failed to clean user messages: %v", err)
+		}
+	}
+
 	// clean user activity records
 	if err := s.db.Where("created_at < ?", cutoffTime).Delete(&UserActivity{}).Error; err != nil {
 		return fmt.Errorf("failed to clean user activities: %v", err)
@@ -437,6 +536,65 @@ func (s *LogService) CleanOldLogs(days int) error {
 	return nil
 }
 
+// SearchHookLogs most recent hook logs whose hook, output or error contains search
+func (s *LogService) SearchHookLogs(search string, limit int) ([]HookLog, error) {
+	if s.db == nil {
+		return nil, nil
+	}
+	like := "%" + search + "%"
+	var logs []HookLog
+	err := s.db.Where("hook_id LIKE ? OR hook_name LIKE ? OR output LIKE ? OR error LIKE ?", like, like, like, like).
+		Order("created_at DESC").Limit(limit).Find(&logs).Error
+	s.reveal(logs)
+	return logs, err
+}
+
+// LastUsed time of the latest log of each hook of hookType, by hook id, as RFC3339 in the zone
+// of the service
+func (s *LogService) LastUsed(hookType string) (map[string]string, error) {
+	lastUsed := map[string]string{}
+	if s.db == nil {
+		return lastUsed, nil
+	}
+	var ids []uint
+	if err := s.db.Model(&HookLog{}).Where("hook_type = ?", hookType).
+		Select("MAX(id)").Group("hook_id").Pluck("MAX(id)", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return lastUsed, nil
+	}
+	var logs []HookLog
+	if err := s.db.Select("hook_id", "created_at").Where("id IN ?", ids).Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	for _, l := range logs {
+		lastUsed[l.HookID] = s.timestamp(l.CreatedAt)
+	}
+	return lastUsed, nil
+}
+
+// LatestHookLogID id of the newest log of a hook of hookType, 0 without any
+func (s *LogService) LatestHookLogID(hookType string) uint {
+	var id uint
+	if s.db != nil {
+		s.db.Model(&HookLog{}).Where("hook_type = ?", hookType).Select("COALESCE(MAX(id), 0)").Scan(&id)
+	}
+	return id
+}
+
+// SearchSystemLogs most recent system logs whose message or details contain search
+func (s *LogService) SearchSystemLogs(search string, limit int) ([]SystemLog, error) {
+	if s.db == nil {
+		return nil, nil
+	}
+	like := "%" + search + "%"
+	var logs []SystemLog
+	err := s.db.Where("message LIKE ? OR details LIKE ?", like, like).
+		Order("created_at DESC").Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
 // GetAllLogs get all types of logs (uniform interface)
 func (s *LogService) GetAllLogs(page, pageSize int, level, search string, startTime, endTime *time.Time) ([]map[string]interface{}, int64, error) {
 	var allLogs []map[string]interface{}
@@ -609,14 +767,17 @@ func (s *LogService) getHookLogsAsInterfaceAll(search string, startTime, endTime
 	if err != nil {
 		return nil, err
 	}
+	s.reveal(logs)
 
 	var result []map[string]interface{}
 	for _, log := range logs {
 		result = append(result, map[string]interface{}{
 			"id":         log.ID,
 			"type":       "hook",
-			"timestamp":  log.CreatedAt.Format(time.RFC3339), // ensure time format is correct
+			"timestamp":  s.timestamp(log.CreatedAt),
 			"message":    fmt.Sprintf("Hook %s executed", log.HookName),
+			"hookId":     log.HookID,
+			"alias":      log.Alias, // previous id of a renamed hook or project
 			"hookName":   log.HookName,
 			"hookType":   log.HookType,
 			"method":     log.Method,
@@ -658,7 +819,7 @@ func (s *LogService) getSystemLogsAsInterfaceAll(level, search string, startTime
 		result = append(result, map[string]interface{}{
 			"id":        log.ID,
 			"type":      "system",
-			"timestamp": log.CreatedAt.Format(time.RFC3339), // ensure time format is correct
+			"timestamp": s.timestamp(log.CreatedAt),
 			"level":     log.Level,
 			"category":  log.Category,
 			"message":   log.Message,
@@ -702,7 +863,7 @@ func (s *LogService) getUserActivitiesAsInterfaceAll(search string, startTime, e
 		result = append(result, map[string]interface{}{
 			"id":          activity.ID,
 			"type":        "user",
-			"timestamp":   activity.CreatedAt.Format(time.RFC3339), // ensure time format is correct
+			"timestamp":   s.timestamp(activity.CreatedAt),
 			"message":     message,
 			"username":    activity.Username,
 			"action":      activity.Action,
@@ -749,7 +910,7 @@ func (s *LogService) getProjectActivitiesAsInterfaceAll(search string, startTime
 		result = append(result, map[string]interface{}{
 			"id":          activity.ID,
 			"type":        "project",
-			"timestamp":   activity.CreatedAt.Format(time.RFC3339), // ensure time format is correct
+			"timestamp":   s.timestamp(activity.CreatedAt),
 			"message":     message,
 			"projectName": activity.ProjectName,
 			"action":      activity.Action,
@@ -781,7 +942,7 @@ func (s *LogService) ExportLogsToCSV(logType, level, search string, startTime, e
 		}
 		for _, log := range logs {
 			csvData += fmt.Sprintf("%d,hook,%s,Hook %s executed,,,%t,%s\n",
-				log.ID, log.CreatedAt.Format("2006-01-02 15:04:05"), log.HookName, log.Success, log.Output)
+				log.ID, s.timestamp(log.CreatedAt), log.HookName, log.Success, log.Output)
 		}
 	case "system":
 		logs, _, err := s.GetSystemLogs(1, 1000, level, "", "", startTime, endTime)
@@ -790,7 +951,7 @@ func (s *LogService) ExportLogsToCSV(logType, level, search string, startTime, e
 		}
 		for _, log := range logs {
 			csvData += fmt.Sprintf("%d,system,%s,%s,%s,%s,,%s\n",
-				log.ID, log.CreatedAt.Format("2006-01-02 15:04:05"), log.Message, log.Level, log.UserID, log.Details)
+				log.ID, s.timestamp(log.CreatedAt), log.Message, log.Level, log.UserID, log.Details)
 		}
 	case "user":
 		logs, _, err := s.GetUserActivities(1, 1000, "", "", nil, startTime, endTime)
@@ -799,7 +960,7 @@ func (s *LogService) ExportLogsToCSV(logType, level, search string, startTime, e
 		}
 		for _, log := range logs {
 			csvData += fmt.Sprintf("%d,user,%s,User %s: %s,,%s,%t,%s\n",
-				log.ID, log.CreatedAt.Format("2006-01-02 15:04:05"), log.Username, log.Action, log.Username, log.Success, log.Details)
+				log.ID, s.timestamp(log.CreatedAt), log.Username, log.Action, log.Username, log.Success, log.Details)
 		}
 	case "project":
 		logs, _, err := s.GetProjectActivities(1, 1000, "", "", "", nil, startTime, endTime)
@@ -808,7 +969,7 @@ func (s *LogService) ExportLogsToCSV(logType, level, search string, startTime, e
 		}
 		for _, log := range logs {
 			csvData += fmt.Sprintf("%d,project,%s,Project %s: %s,,%s,%t,%s\n",
-				log.ID, log.CreatedAt.Format("2006-01-02 15:04:05"), log.ProjectName, log.Action, log.Username, log.Success, log.Description)
+				log.ID, s.timestamp(log.CreatedAt), log.ProjectName, log.Action, log.Username, log.Success, log.Description)
 		}
 	default:
 		// export
@@ -862,14 +1023,17 @@ func (s *LogService) GetHookLogsForAPI(page, pageSize int, hookID, hookName, hoo
 	if err != nil {
 		return nil, 0, err
 	}
+	s.reveal(logs)
 
 	var result []map[string]interface{}
 	for _, log := range logs {
 		result = append(result, map[string]interface{}{
 			"id":         log.ID,
 			"type":       "hook",
-			"timestamp":  log.CreatedAt.Format(time.RFC3339),
+			"timestamp":  s.timestamp(log.CreatedAt),
 			"message":    fmt.Sprintf("Hook %s executed", log.HookName),
+			"hookId":     log.HookID,
+			"alias":      log.Alias, // previous id of a renamed hook or project
 			"hookName":   log.HookName,
 			"hookType":   log.HookType,
 			"method":     log.Method,
@@ -921,7 +1085,7 @@ func (s *LogService) GetSystemLogsForAPI(page, pageSize int, level, category, us
 		result = append(result, map[string]interface{}{
 			"id":        log.ID,
 			"type":      "system",
-			"timestamp": log.CreatedAt.Format(time.RFC3339),
+			"timestamp": s.timestamp(log.CreatedAt),
 			"level":     log.Level,
 			"category":  log.Category,
 			"message":   log.Message,
@@ -976,7 +1140,7 @@ func (s *LogService) GetUserActivitiesForAPI(page, pageSize int, username, actio
 		result = append(result, map[string]interface{}{
 			"id":          activity.ID,
 			"type":        "user",
-			"timestamp":   activity.CreatedAt.Format(time.RFC3339),
+			"timestamp":   s.timestamp(activity.CreatedAt),
 			"message":     message,
 			"username":    activity.Username,
 			"action":      activity.Action,
@@ -1036,7 +1200,7 @@ func (s *LogService) GetProjectActivitiesForAPI(page, pageSize int, projectName,
 		result = append(result, map[string]interface{}{
 			"id":          activity.ID,
 			"type":        "project",
-			"timestamp":   activity.CreatedAt.Format(time.RFC3339),
+			"timestamp":   s.timestamp(activity.CreatedAt),
 			"message":     message,
 			"projectName": activity.ProjectName,
 			"action":      activity.Action,
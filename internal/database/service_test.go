@@ -0,0 +1,55 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/redact"
+	"github.com/mycoool/gohook/internal/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCreateHookLogRedacts(t *testing.T) {
+	t.Cleanup(func() { _ = redact.Configure(nil) })
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&HookLog{}, &SystemLog{}); err != nil {
+		t.Fatal(err)
+	}
+	s := &LogService{db: conn}
+
+	for _, enabled := range []bool{false, true} {
+		if err := redact.Configure(&types.RedactionConfig{Enabled: enabled}); err != nil {
+			t.Fatal(err)
+		}
+		hookLog, err := s.createHookLog("deploy", "", "deploy", HookTypeWebhook, "POST", "127.0.0.1",
+			map[string][]string{"Authorization": {"Bearer abc"}}, `{"password":"hunter2"}`, false,
+			"login with token=abc123", "", 10, "curl", map[string][]string{"api_key": {"k123"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stored HookLog
+		if err := conn.First(&stored, hookLog.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		fields := stored.Body + stored.Output + stored.Headers + stored.QueryParams
+		leaked := strings.Contains(fields, "hunter2") || strings.Contains(fields, "abc") || strings.Contains(fields, "k123")
+		if leaked == enabled {
+			t.Errorf("redaction enabled=%v, stored log = %+v", enabled, stored)
+		}
+	}
+
+	if err := s.CreateSystemLog("INFO", "CONFIG", "saved password=hunter2", map[string]string{"jwt_secret": "s3cret"}, "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	var system SystemLog
+	if err := conn.Last(&system).Error; err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(system.Message+system.Details, "hunter2") || strings.Contains(system.Details, "s3cret") {
+		t.Errorf("system log = %+v, want secrets redacted", system)
+	}
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// ClientSession API client session stored in the database, so that the instances of HA mode
+// share them. Only the hash of the token is kept.
+type ClientSession struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TokenHash string    `json:"-" gorm:"size:64;uniqueIndex"` // hex SHA-256 of the token
+	Name      string    `json:"name" gorm:"size:200"`
+	Username  string    `json:"username" gorm:"size:100;index"`
+	LastUsed  time.Time `json:"last_used"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TokenHash hex SHA-256 a token is stored as
+func TokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateClientSession store a new session for token
+func CreateClientSession(token, name, username string) (*ClientSession, error) {
+	now := time.Now().UTC()
+	s := &ClientSession{TokenHash: TokenHash(token), Name: name, Username: username, LastUsed: now, CreatedAt: now}
+	if err := DB.Create(s).Error; err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ClientSessionsOf sessions of username, oldest first
+func ClientSessionsOf(username string) ([]ClientSession, error) {
+	var sessions []ClientSession
+	err := DB.Where("username = ?", username).Order("id").Find(&sessions).Error
+	return sessions, err
+}
+
+// TouchClientSession record a use of the session of token, at most once per interval
+func TouchClientSession(token string, now time.Time, interval time.Duration) error {
+	now = now.UTC()
+	return DB.Model(&ClientSession{}).
+		Where("token_hash = ? AND last_used < ?", TokenHash(token), now.Add(-interval)).
+		Update("last_used", now).Error
+}
+
+// DeleteClientSessionByToken remove the session of token, reports whether it existed
+func DeleteClientSessionByToken(token string) (bool, error) {
+	res := DB.Where("token_hash = ?", TokenHash(token)).Delete(&ClientSession{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// DeleteClientSession remove the session id, reports whether it existed
+func DeleteClientSession(id uint) (bool, error) {
+	res := DB.Delete(&ClientSession{}, id)
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// stats bucket intervals
+const (
+	StatsIntervalHour = "hour"
+	StatsIntervalDay  = "day"
+)
+
+// maxStatsBuckets limits the points of a time series
+const maxStatsBuckets = 2000
+
+// StatsFilter restricts the hook logs aggregated by the stats queries
+type StatsFilter struct {
+	Start    time.Time
+	End      time.Time
+	HookType string // webhook or githook, empty for both
+	HookID   string
+}
+
+// StatsOverview totals of a time range
+type StatsOverview struct {
+	Start             time.Time            `json:"start"`
+	End               time.Time            `json:"end"`
+	Executions        int64                `json:"executions"`
+	Successes         int64                `json:"successes"`
+	Failures          int64                `json:"failures"`
+	SuccessRate       float64              `json:"successRate"` // percent, 0 without executions
+	AvgDuration       float64              `json:"avgDuration"` // milliseconds
+	Deploys           int64                `json:"deploys"`
+	TopFailingHooks   []HookFailureStats   `json:"topFailingHooks"`
+	DeploysPerProject []ProjectDeployStats `json:"deploysPerProject"`
+}
+
+// HookFailureStats failed executions of a hook
+type HookFailureStats struct {
+	HookID     string `json:"hookId"`
+	HookType   string `json:"hookType"`
+	Failures   int64  `json:"failures"`
+	Executions int64  `json:"executions"`
+}
+
+// ProjectDeployStats deploys of a project
+type ProjectDeployStats struct {
+	Project   string `json:"project"`
+	Deploys   int64  `json:"deploys"`
+	Successes int64  `json:"successes"`
+}
+
+// StatsBucket aggregated activity of one interval starting at Time
+type StatsBucket struct {
+	Time        time.Time `json:"time"`
+	Executions  int64     `json:"executions"`
+	Successes   int64     `json:"successes"`
+	Failures    int64     `json:"failures"`
+	SuccessRate float64   `json:"successRate"`
+	AvgDuration float64   `json:"avgDuration"`
+	Deploys     int64     `json:"deploys"`
+}
+
+// GetStatsOverview aggregate hook executions and project deploys of f, top limits the top failing hooks
+func (s *LogService) GetStatsOverview(f StatsFilter, top int) (*StatsOverview, error) {
+	overview := &StatsOverview{
+		Start:             f.Start,
+		End:               f.End,
+		TopFailingHooks:   []HookFailureStats{},
+		DeploysPerProject: []ProjectDeployStats{},
+	}
+	if s.db == nil {
+		return overview, nil
+	}
+
+	var totals struct {
+		Executions  int64
+		Successes   int64
+		AvgDuration float64
+	}
+	err := s.hookLogQuery(f).
+		Select("COUNT(*) AS executions, COALESCE(SUM(CASE WHEN success THEN 1 ELSE 0 END), 0) AS successes, COALESCE(AVG(duration), 0) AS avg_duration").
+		Scan(&totals).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate hook logs: %v", err)
+	}
+	overview.Executions = totals.Executions
+	overview.Successes = totals.Successes
+	overview.Failures = totals.Executions - totals.Successes
+	overview.SuccessRate = successRate(totals.Successes, totals.Executions)
+	overview.AvgDuration = totals.AvgDuration
+
+	err = s.hookLogQuery(f).
+		Select("hook_id, hook_type, SUM(CASE WHEN success THEN 0 ELSE 1 END) AS failures, COUNT(*) AS executions").
+		Group("hook_id, hook_type").
+		Having("SUM(CASE WHEN success THEN 0 ELSE 1 END) > 0").
+		Order("failures DESC, hook_id").
+		Limit(top).
+		Scan(&overview.TopFailingHooks).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate failing hooks: %v", err)
+	}
+
+	err = s.deployQuery(f).
+		Select("project_name AS project, COUNT(*) AS deploys, COALESCE(SUM(CASE WHEN success THEN 1 ELSE 0 END), 0) AS successes").
+		Group("project_name").
+		Order("deploys DESC, project_name").
+		Scan(&overview.DeploysPerProject).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate deploys: %v", err)
+	}
+	for _, p := range overview.DeploysPerProject {
+		overview.Deploys += p.Deploys
+	}
+	return overview, nil
+}
+
+// GetStatsTimeseries aggregate hook executions and project deploys of f into
+// hourly or daily buckets in the location of f.Start, empty buckets included
+func (s *LogService) GetStatsTimeseries(f StatsFilter, interval string) ([]StatsBucket, error) {
+	if interval != StatsIntervalHour && interval != StatsIntervalDay {
+		return nil, fmt.Errorf("invalid interval %q, must be hour or day", interval)
+	}
+	if !f.End.After(f.Start) {
+		return nil, fmt.Errorf("end must be after start")
+	}
+
+	var buckets []StatsBucket
+	index := map[int64]int{}
+	for t := bucketStart(f.Start, interval); t.Before(f.End); t = nextBucket(t, interval) {
+		if len(buckets) >= maxStatsBuckets {
+			return nil, fmt.Errorf("too many buckets, at most %d", maxStatsBuckets)
+		}
+		index[t.Unix()] = len(buckets)
+		buckets = append(buckets, StatsBucket{Time: t})
+	}
+	if s.db == nil {
+		return buckets, nil
+	}
+
+	durations := make([]int64, len(buckets))
+	rows, err := s.hookLogQuery(f).Select("created_at, success, duration").Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query hook logs: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var createdAt time.Time
+		var success bool
+		var duration int64
+		if err := rows.Scan(&createdAt, &success, &duration); err != nil {
+			return nil, err
+		}
+		i, ok := index[bucketStart(createdAt.In(f.Start.Location()), interval).Unix()]
+		if !ok {
+			continue
+		}
+		buckets[i].Executions++
+		if success {
+			buckets[i].Successes++
+		}
+		durations[i] += duration
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var deploys []time.Time
+	if err := s.deployQuery(f).Pluck("created_at", &deploys).Error; err != nil {
+		return nil, fmt.Errorf("failed to query deploys: %v", err)
+	}
+	for _, createdAt := range deploys {
+		if i, ok := index[bucketStart(createdAt.In(f.Start.Location()), interval).Unix()]; ok {
+			buckets[i].Deploys++
+		}
+	}
+
+	for i := range buckets {
+		b := &buckets[i]
+		b.Failures = b.Executions - b.Successes
+		b.SuccessRate = successRate(b.Successes, b.Executions)
+		if b.Executions > 0 {
+			b.AvgDuration = float64(durations[i]) / float64(b.Executions)
+		}
+	}
+	return buckets, nil
+}
+
+// hookLogQuery hook logs matching f
+func (s *LogService) hookLogQuery(f StatsFilter) *gorm.DB {
+	query := s.db.Model(&HookLog{}).Where("created_at >= ? AND created_at < ?", f.Start, f.End)
+	if f.HookType != "" {
+		query = query.Where("hook_type = ?", f.HookType)
+	}
+	if f.HookID != "" {
+		query = query.Where("hook_id = ?", f.HookID)
+	}
+	return query
+}
+
+// deployQuery project activities of the time range of f that changed the deployed revision
+func (s *LogService) deployQuery(f StatsFilter) *gorm.DB {
+	return s.db.Model(&ProjectActivity{}).
+		Where("created_at >= ? AND created_at < ?", f.Start, f.End).
+		Where("action IN ?", DeployActions)
+}
+
+func successRate(successes, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(successes) / float64(total) * 100
+}
+
+// bucketStart start of the hour or day containing t, in the location of t
+func bucketStart(t time.Time, interval string) time.Time {
+	if interval == StatsIntervalDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
+
+// nextBucket start of the bucket after t, days follow the calendar across DST changes
+func nextBucket(t time.Time, interval string) time.Time {
+	if interval == StatsIntervalDay {
+		return t.AddDate(0, 0, 1)
+	}
+	return t.Add(time.Hour)
+}
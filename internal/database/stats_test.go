@@ -0,0 +1,147 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openStatsTestService(t *testing.T) *LogService {
+	t.Helper()
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&HookLog{}, &ProjectActivity{}); err != nil {
+		t.Fatal(err)
+	}
+	return &LogService{db: conn}
+}
+
+func TestStats(t *testing.T) {
+	s := openStatsTestService(t)
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	logs := []struct {
+		hook     string
+		at       time.Duration
+		success  bool
+		duration int64
+	}{
+		{"deploy", 10 * time.Minute, true, 100},
+		{"deploy", 20 * time.Minute, false, 300},
+		{"build", 70 * time.Minute, false, 50},
+		{"build", 80 * time.Minute, false, 150},
+		{"build", 26 * time.Hour, true, 10}, // outside the range
+	}
+	for _, l := range logs {
+		entry := &HookLog{HookID: l.hook, HookType: HookTypeWebhook, Success: l.success, Duration: l.duration}
+		entry.CreatedAt = start.Add(l.at)
+		if err := s.db.Create(entry).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	activities := []struct {
+		project string
+		action  string
+		at      time.Duration
+	}{
+		{"site", ProjectActionBranchSwitch, 30 * time.Minute},
+		{"site", ProjectActionPromote, 90 * time.Minute},
+		{"api", ProjectActionTagSwitch, 150 * time.Minute},
+		{"api", ProjectActionService, 150 * time.Minute}, // not a deploy
+	}
+	for _, a := range activities {
+		entry := &ProjectActivity{ProjectName: a.project, Action: a.action, Success: true}
+		entry.CreatedAt = start.Add(a.at)
+		if err := s.db.Create(entry).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter := StatsFilter{Start: start, End: start.Add(24 * time.Hour)}
+	overview, err := s.GetStatsOverview(filter, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overview.Executions != 4 || overview.Failures != 3 || overview.SuccessRate != 25 || overview.AvgDuration != 150 {
+		t.Errorf("overview totals = %+v", overview)
+	}
+	if len(overview.TopFailingHooks) != 2 || overview.TopFailingHooks[0].HookID != "build" || overview.TopFailingHooks[0].Failures != 2 {
+		t.Errorf("top failing hooks = %+v", overview.TopFailingHooks)
+	}
+	if overview.Deploys != 3 || len(overview.DeploysPerProject) != 2 || overview.DeploysPerProject[0].Project != "site" {
+		t.Errorf("deploys = %d %+v", overview.Deploys, overview.DeploysPerProject)
+	}
+
+	buckets, err := s.GetStatsTimeseries(StatsFilter{Start: start, End: start.Add(3 * time.Hour)}, StatsIntervalHour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []StatsBucket{
+		{Executions: 2, Successes: 1, Failures: 1, SuccessRate: 50, AvgDuration: 200, Deploys: 1},
+		{Executions: 2, Failures: 2, AvgDuration: 100, Deploys: 1},
+		{Deploys: 1},
+	}
+	if len(buckets) != len(want) {
+		t.Fatalf("got %d buckets, want %d", len(buckets), len(want))
+	}
+	for i, w := range want {
+		w.Time = start.Add(time.Duration(i) * time.Hour)
+		if got := buckets[i]; !got.Time.Equal(w.Time) || got.Executions != w.Executions || got.Successes != w.Successes ||
+			got.Failures != w.Failures || got.SuccessRate != w.SuccessRate || got.AvgDuration != w.AvgDuration || got.Deploys != w.Deploys {
+			t.Errorf("bucket %d = %+v, want %+v", i, got, w)
+		}
+	}
+
+	days, err := s.GetStatsTimeseries(StatsFilter{Start: start.Add(5 * time.Hour), End: start.Add(48 * time.Hour), HookID: "build"}, StatsIntervalDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 2 || !days[0].Time.Equal(start) || days[0].Executions != 0 || days[1].Executions != 1 {
+		t.Errorf("daily buckets = %+v", days)
+	}
+
+	if _, err := s.GetStatsTimeseries(filter, "week"); err == nil {
+		t.Errorf("invalid interval accepted")
+	}
+}
+
+func TestLastUsed(t *testing.T) {
+	s := openStatsTestService(t)
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	logs := []struct {
+		hook     string
+		hookType string
+		at       time.Duration
+	}{
+		{"deploy", HookTypeWebhook, 10 * time.Minute},
+		{"deploy", HookTypeWebhook, 20 * time.Minute},
+		{"build", HookTypeWebhook, time.Hour},
+		{"site", HookTypeGitHook, 2 * time.Hour},
+	}
+	for _, l := range logs {
+		entry := &HookLog{HookID: l.hook, HookType: l.hookType, Success: true}
+		entry.CreatedAt = start.Add(l.at)
+		if err := s.db.Create(entry).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tokyo := time.FixedZone("JST", 9*3600)
+	lastUsed, err := s.InZone(tokyo).LastUsed(HookTypeWebhook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"deploy": "2026-03-01T09:20:00+09:00", "build": "2026-03-01T10:00:00+09:00"}
+	if len(lastUsed) != len(want) {
+		t.Fatalf("LastUsed = %v, want %v", lastUsed, want)
+	}
+	for hook, at := range want {
+		if lastUsed[hook] != at {
+			t.Errorf("LastUsed[%s] = %s, want %s", hook, lastUsed[hook], at)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package database
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// log subscription entry types, live tail streams hook and system entries
+const (
+	LogEventHook   = "hook"
+	LogEventSystem = "system"
+	LogEventUser   = "user"
+)
+
+// LogEvent log entry delivered to subscribers
+type LogEvent struct {
+	Type   string        // LogEventHook, LogEventSystem or LogEventUser
+	Hook   *HookLog      // set for LogEventHook
+	System *SystemLog    // set for LogEventSystem
+	User   *UserActivity // set for LogEventUser
+}
+
+// ID database id of the entry
+func (e LogEvent) ID() uint {
+	if e.Hook != nil {
+		return e.Hook.ID
+	}
+	if e.System != nil {
+		return e.System.ID
+	}
+	if e.User != nil {
+		return e.User.ID
+	}
+	return 0
+}
+
+// CreatedAt creation time of the entry
+func (e LogEvent) CreatedAt() time.Time {
+	if e.Hook != nil {
+		return e.Hook.CreatedAt
+	}
+	if e.System != nil {
+		return e.System.CreatedAt
+	}
+	if e.User != nil {
+		return e.User.CreatedAt
+	}
+	return time.Time{}
+}
+
+// Entry the log model, for encoding
+func (e LogEvent) Entry() interface{} {
+	if e.Hook != nil {
+		return e.Hook
+	}
+	if e.User != nil {
+		return e.User
+	}
+	return e.System
+}
+
+// TailFilter selects the entries of a live tail, same filters as the log query API.
+// Hook filters (hook id, hook type, project, success) exclude system logs and system
+// filters (level, category, user) exclude hook logs.
+type TailFilter struct {
+	Namespace string // empty sees every namespace
+	Type      string // hook or system, empty for both
+	HookID    string
+	HookType  string
+	Project   string // githook logs of the project
+	Success   *bool
+	Level     string
+	Category  string
+	User      string
+	Search    string // case-insensitive text of the message, output or error
+}
+
+func (f TailFilter) hookOnly() bool {
+	return f.HookID != "" || f.HookType != "" || f.Project != "" || f.Success != nil
+}
+
+func (f TailFilter) systemOnly() bool {
+	return f.Level != "" || f.Category != "" || f.User != ""
+}
+
+// wants reports whether entries of type t can match f
+func (f TailFilter) wants(t string) bool {
+	if t == LogEventUser || f.Type != "" && f.Type != t {
+		return false
+	}
+	if t == LogEventHook {
+		return !f.systemOnly()
+	}
+	return !f.hookOnly()
+}
+
+// Match reports whether e passes f
+func (f TailFilter) Match(e LogEvent) bool {
+	if !f.wants(e.Type) {
+		return false
+	}
+	search := strings.ToLower(f.Search)
+	contains := func(fields ...string) bool {
+		if search == "" {
+			return true
+		}
+		for _, s := range fields {
+			if strings.Contains(strings.ToLower(s), search) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if l := e.Hook; l != nil {
+		return (f.Namespace == "" || l.Namespace == f.Namespace) &&
+			(f.HookID == "" || l.HookID == f.HookID) &&
+			(f.HookType == "" || l.HookType == f.HookType) &&
+			(f.Project == "" || l.HookType == HookTypeGitHook && l.HookID == f.Project) &&
+			(f.Success == nil || l.Success == *f.Success) &&
+			contains(l.HookID, l.HookName, l.Output, l.Error)
+	}
+	if l := e.System; l != nil {
+		return (f.Namespace == "" || l.Namespace == f.Namespace) &&
+			(f.Level == "" || strings.EqualFold(l.Level, f.Level)) &&
+			(f.Category == "" || strings.EqualFold(l.Category, f.Category)) &&
+			(f.User == "" || l.UserID == f.User) &&
+			contains(l.Message, l.Details)
+	}
+	return false
+}
+
+// LogSubscription receives the log entries created after SubscribeLogs
+type LogSubscription struct {
+	C       <-chan LogEvent
+	ch      chan LogEvent
+	dropped atomic.Int64
+}
+
+// Dropped number of entries lost because the subscriber was too slow since the last call
+func (s *LogSubscription) Dropped() int64 {
+	return s.dropped.Swap(0)
+}
+
+// Close stop delivering entries to s
+func (s *LogSubscription) Close() {
+	tailMu.Lock()
+	delete(tailSubscribers, s)
+	tailMu.Unlock()
+}
+
+var (
+	tailMu          sync.RWMutex
+	tailSubscribers = map[*LogSubscription]struct{}{}
+)
+
+// SubscribeLogs deliver the hook, system and user activity logs created by this instance from now on,
+// buffer entries are kept for a slow reader before entries are dropped
+func SubscribeLogs(buffer int) *LogSubscription {
+	ch := make(chan LogEvent, buffer)
+	sub := &LogSubscription{C: ch, ch: ch}
+	tailMu.Lock()
+	tailSubscribers[sub] = struct{}{}
+	tailMu.Unlock()
+	return sub
+}
+
+// publishLog hand e to the subscribers without blocking the caller
+func publishLog(e LogEvent) {
+	tailMu.RLock()
+	defer tailMu.RUnlock()
+	for sub := range tailSubscribers {
+		select {
+		case sub.ch <- e:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// RecentLogs the last n entries matching f, oldest first
+func (s *LogService) RecentLogs(f TailFilter, n int) ([]LogEvent, error) {
+	if s.db == nil || n <= 0 {
+		return nil, nil
+	}
+	var events []LogEvent
+	like := "%" + f.Search + "%"
+
+	if f.wants(LogEventHook) {
+		query := s.db.Model(&HookLog{})
+		if f.Namespace != "" {
+			query = query.Where("namespace = ?", f.Namespace)
+		}
+		if f.HookID != "" {
+			query = query.Where("hook_id = ?", f.HookID)
+		}
+		if f.HookType != "" {
+			query = query.Where("hook_type = ?", f.HookType)
+		}
+		if f.Project != "" {
+			query = query.Where("hook_type = ? AND hook_id = ?", HookTypeGitHook, f.Project)
+		}
+		if f.Success != nil {
+			query = query.Where("success = ?", *f.Success)
+		}
+		if f.Search != "" {
+			query = query.Where("hook_id LIKE ? OR hook_name LIKE ? OR output LIKE ? OR error LIKE ?", like, like, like, like)
+		}
+		var logs []HookLog
+		if err := query.Order("id DESC").Limit(n).Find(&logs).Error; err != nil {
+			return nil, err
+		}
+		s.reveal(logs)
+		for i := range logs {
+			events = append(events, LogEvent{Type: LogEventHook, Hook: &logs[i]})
+		}
+	}
+
+	if f.wants(LogEventSystem) {
+		query := s.db.Model(&SystemLog{})
+		if f.Namespace != "" {
+			query = query.Where("namespace = ?", f.Namespace)
+		}
+		if f.Level != "" {
+			query = query.Where("UPPER(level) = ?", strings.ToUpper(f.Level))
+		}
+		if f.Category != "" {
+			query = query.Where("UPPER(category) = ?", strings.ToUpper(f.Category))
+		}
+		if f.User != "" {
+			query = query.Where("user_id = ?", f.User)
+		}
+		if f.Search != "" {
+			query = query.Where("message LIKE ? OR details LIKE ?", like, like)
+		}
+		var logs []SystemLog
+		if err := query.Order("id DESC").Limit(n).Find(&logs).Error; err != nil {
+			return nil, err
+		}
+		for i := range logs {
+			events = append(events, LogEvent{Type: LogEventSystem, System: &logs[i]})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].CreatedAt().Before(events[j].CreatedAt()) })
+	if len(events) > n {
+		events = events[len(events)-n:]
+	}
+	return events, nil
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestTailFilterMatch(t *testing.T) {
+	failed := false
+	hook := LogEvent{Type: LogEventHook, Hook: &HookLog{HookID: "deploy", HookType: HookTypeWebhook, Output: "Build OK", Namespace: "team-a"}}
+	githook := LogEvent{Type: LogEventHook, Hook: &HookLog{HookID: "site", HookType: HookTypeGitHook, Success: true}}
+	system := LogEvent{Type: LogEventSystem, System: &SystemLog{Level: "ERROR", Category: "AUTH", Message: "login failed", UserID: "bob"}}
+
+	tests := []struct {
+		name   string
+		filter TailFilter
+		event  LogEvent
+		want   bool
+	}{
+		{"empty filter matches hook", TailFilter{}, hook, true},
+		{"empty filter matches system", TailFilter{}, system, true},
+		{"type", TailFilter{Type: LogEventSystem}, hook, false},
+		{"hook id", TailFilter{HookID: "deploy"}, hook, true},
+		{"hook id excludes system", TailFilter{HookID: "deploy"}, system, false},
+		{"project", TailFilter{Project: "site"}, githook, true},
+		{"project ignores webhooks", TailFilter{Project: "deploy"}, hook, false},
+		{"success", TailFilter{Success: &failed}, githook, false},
+		{"level", TailFilter{Level: "error"}, system, true},
+		{"level excludes hooks", TailFilter{Level: "error"}, hook, false},
+		{"user", TailFilter{User: "alice"}, system, false},
+		{"search", TailFilter{Search: "build ok"}, hook, true},
+		{"search misses", TailFilter{Search: "timeout"}, system, false},
+		{"namespace", TailFilter{Namespace: "team-b"}, hook, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(tt.event); got != tt.want {
+			t.Errorf("%s: Match() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSubscribeLogs(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&HookLog{}, &SystemLog{}); err != nil {
+		t.Fatal(err)
+	}
+	s := &LogService{db: conn}
+
+	if err := s.CreateSystemLog("INFO", "CONFIG", "before", nil, "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	sub := SubscribeLogs(1)
+	defer sub.Close()
+
+	if err := s.CreateHookLog("deploy", "", "deploy", HookTypeWebhook, "POST", "", nil, "", true, "ok", "", 5, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateSystemLog("WARN", "CONFIG", "dropped", nil, "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-sub.C:
+		if e.Type != LogEventHook || e.Hook.HookID != "deploy" || e.ID() == 0 {
+			t.Errorf("got %+v, want the hook log", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no entry delivered")
+	}
+	if n := sub.Dropped(); n != 1 {
+		t.Errorf("Dropped() = %d, want 1", n)
+	}
+
+	recent, err := s.RecentLogs(TailFilter{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 2 || recent[0].Type != LogEventHook || recent[1].System == nil || recent[1].System.Message != "dropped" {
+		t.Errorf("RecentLogs() = %+v", recent)
+	}
+	recent, err = s.RecentLogs(TailFilter{Type: LogEventSystem, Search: "before"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 1 {
+		t.Errorf("RecentLogs(search) returned %d entries, want 1", len(recent))
+	}
+
+	sub.Close()
+	if err := s.CreateSystemLog("INFO", "CONFIG", "after close", nil, "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-sub.C:
+		t.Errorf("closed subscription got %+v", e)
+	default:
+	}
+}
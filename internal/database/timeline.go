@@ -0,0 +1,192 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// timeline entry types
+const (
+	TimelineCommit   = "commit"   // revision of the project history
+	TimelineDeploy   = "deploy"   // deploy activity and sync rollouts
+	TimelineGitHook  = "githook"  // GitHook deliveries
+	TimelineConfig   = "config"   // edits of the project configuration
+	TimelineActivity = "activity" // other project activity, e.g. service actions
+)
+
+// TimelineTypes every timeline entry type
+var TimelineTypes = []string{TimelineCommit, TimelineDeploy, TimelineGitHook, TimelineConfig, TimelineActivity}
+
+// configActions project activity actions that edit the project configuration
+var configActions = []string{
+	ProjectActionAdd,
+	ProjectActionUpdate,
+	ProjectActionRename,
+	ProjectActionPin,
+	ProjectActionUnpin,
+}
+
+// TimelineEntry one event in the history of a project
+type TimelineEntry struct {
+	Type     string    `json:"type"`
+	Source   string    `json:"source"`       // project_activity | sync_deployment | hook_log | user_activity | vcs
+	ID       uint      `json:"id,omitempty"` // id of the row in the source table
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Summary  string    `json:"summary"`
+	Username string    `json:"username,omitempty"`
+	Success  *bool     `json:"success,omitempty"` // nil while running and for commits
+	Commit   string    `json:"commit,omitempty"`
+}
+
+// GetProjectTimeline newest limit entries of project stored in the database, of the types in
+// wanted (all when empty), sorted newest first
+func (s *LogService) GetProjectTimeline(project string, wanted map[string]bool, limit int) ([]TimelineEntry, error) {
+	entries := []TimelineEntry{}
+	if s.db == nil || limit <= 0 {
+		return entries, nil
+	}
+	want := func(t string) bool { return len(wanted) == 0 || wanted[t] }
+
+	if activities, ok := activityTypeQuery(s.db, want); ok {
+		var rows []ProjectActivity
+		if err := s.db.Where("project_name = ?", project).Where(activities).
+			Order("created_at DESC").Limit(limit).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, a := range rows {
+			success := a.Success
+			summary := a.Description
+			if summary == "" && a.Error != "" {
+				summary = a.Error
+			}
+			entries = append(entries, TimelineEntry{
+				Type: activityType(a.Action), Source: "project_activity", ID: a.ID, Time: a.CreatedAt,
+				Action: a.Action, Summary: summary, Username: a.Username, Success: &success, Commit: a.CommitHash,
+			})
+		}
+	}
+
+	if want(TimelineDeploy) {
+		var rows []SyncDeployment
+		if err := s.db.Where("project_name = ?", project).
+			Order("created_at DESC").Limit(limit).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, d := range rows {
+			entries = append(entries, TimelineEntry{
+				Type: TimelineDeploy, Source: "sync_deployment", ID: d.ID, Time: d.CreatedAt,
+				Action: "SYNC_DEPLOYMENT", Summary: strings.TrimSpace(fmt.Sprintf("%s rollout to sync nodes %s: %s", d.Strategy, d.Status, d.Message)),
+				Username: d.CreatedBy, Success: deploymentSuccess(d.Status),
+			})
+		}
+	}
+
+	if want(TimelineGitHook) {
+		var rows []HookLog
+		if err := s.db.Select("id", "created_at", "success", "error", "remote_addr").
+			Where("hook_type = ? AND hook_id = ?", HookTypeGitHook, project).
+			Order("created_at DESC").Limit(limit).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		s.reveal(rows)
+		for _, l := range rows {
+			success := l.Success
+			summary := "delivery from " + l.RemoteAddr
+			if l.Error != "" {
+				summary = l.Error
+			}
+			entries = append(entries, TimelineEntry{
+				Type: TimelineGitHook, Source: "hook_log", ID: l.ID, Time: l.CreatedAt,
+				Action: "GITHOOK", Summary: summary, Success: &success,
+			})
+		}
+	}
+
+	if want(TimelineConfig) {
+		var rows []UserActivity
+		if err := s.db.Where("resource = ?", "project:"+project).
+			Order("created_at DESC").Limit(limit).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, a := range rows {
+			success := a.Success
+			entries = append(entries, TimelineEntry{
+				Type: TimelineConfig, Source: "user_activity", ID: a.ID, Time: a.CreatedAt,
+				Action: a.Action, Summary: a.Description, Username: a.Username, Success: &success,
+			})
+		}
+	}
+
+	entries = MergeTimeline(entries)
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// MergeTimeline the entries of lists sorted newest first, entries at the same time keep
+// their order
+func MergeTimeline(lists ...[]TimelineEntry) []TimelineEntry {
+	merged := []TimelineEntry{}
+	for _, list := range lists {
+		merged = append(merged, list...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Time.After(merged[j].Time) })
+	return merged
+}
+
+// activityType timeline type of a project activity action
+func activityType(action string) string {
+	for _, a := range DeployActions {
+		if a == action {
+			return TimelineDeploy
+		}
+	}
+	for _, a := range configActions {
+		if a == action {
+			return TimelineConfig
+		}
+	}
+	return TimelineActivity
+}
+
+// activityTypeQuery condition on project activity rows of the wanted types, false when
+// no project activity is wanted
+func activityTypeQuery(db *gorm.DB, want func(string) bool) (*gorm.DB, bool) {
+	known := append(append([]string{}, DeployActions...), configActions...)
+	switch {
+	case want(TimelineDeploy) && want(TimelineConfig) && want(TimelineActivity):
+		return db.Where("1 = 1"), true
+	case !want(TimelineDeploy) && !want(TimelineConfig) && !want(TimelineActivity):
+		return nil, false
+	}
+	cond := db.Where("1 = 0")
+	if want(TimelineDeploy) {
+		cond = cond.Or("action IN ?", DeployActions)
+	}
+	if want(TimelineConfig) {
+		cond = cond.Or("action IN ?", configActions)
+	}
+	if want(TimelineActivity) {
+		cond = cond.Or("action NOT IN ?", known)
+	}
+	return cond, true
+}
+
+// deploymentSuccess outcome of a sync rollout status, nil while it is not finished
+func deploymentSuccess(status string) *bool {
+	var success bool
+	switch status {
+	case "success":
+		success = true
+	case "failed", "aborted":
+	default:
+		return nil
+	}
+	return &success
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestGetProjectTimeline(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&HookLog{}, &ProjectActivity{}, &UserActivity{}, &SyncDeployment{}); err != nil {
+		t.Fatal(err)
+	}
+	s := &LogService{db: conn}
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	create := func(row interface{}, base *BaseModel, minutes int) {
+		base.CreatedAt = start.Add(time.Duration(minutes) * time.Minute)
+		if err := conn.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	switchTag := &ProjectActivity{ProjectName: "site", Action: ProjectActionTagSwitch, Success: true}
+	create(switchTag, &switchTag.BaseModel, 10)
+	update := &ProjectActivity{ProjectName: "site", Action: ProjectActionUpdate, Success: true}
+	create(update, &update.BaseModel, 20)
+	service := &ProjectActivity{ProjectName: "site", Action: ProjectActionService, Success: false}
+	create(service, &service.BaseModel, 30)
+	other := &ProjectActivity{ProjectName: "api", Action: ProjectActionTagSwitch, Success: true}
+	create(other, &other.BaseModel, 35)
+	githook := &HookLog{HookID: "site", HookType: HookTypeGitHook, Success: true}
+	create(githook, &githook.BaseModel, 40)
+	webhook := &HookLog{HookID: "site", HookType: HookTypeWebhook, Success: true}
+	create(webhook, &webhook.BaseModel, 45)
+	rollout := &SyncDeployment{ProjectName: "site", Strategy: "rolling", Status: "running"}
+	create(rollout, &rollout.BaseModel, 50)
+	edit := &UserActivity{Username: "admin", Action: UserActionRevealEnv, Resource: "project:site", Success: true}
+	create(edit, &edit.BaseModel, 60)
+
+	tests := []struct {
+		name   string
+		wanted map[string]bool
+		limit  int
+		want   string
+	}{
+		{"all", nil, 10, "config/REVEAL_ENV deploy/SYNC_DEPLOYMENT githook/GITHOOK activity/SERVICE config/UPDATE deploy/TAG_SWITCH"},
+		{"limit", nil, 2, "config/REVEAL_ENV deploy/SYNC_DEPLOYMENT"},
+		{"deploys", map[string]bool{TimelineDeploy: true}, 10, "deploy/SYNC_DEPLOYMENT deploy/TAG_SWITCH"},
+		{"activity and githook", map[string]bool{TimelineActivity: true, TimelineGitHook: true}, 10, "githook/GITHOOK activity/SERVICE"},
+		{"commits only", map[string]bool{TimelineCommit: true}, 10, ""},
+	}
+	for _, tt := range tests {
+		entries, err := s.GetProjectTimeline("site", tt.wanted, tt.limit)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Type+"/"+e.Action)
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("%s: timeline = %v, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	entries, _ := s.GetProjectTimeline("site", map[string]bool{TimelineDeploy: true}, 1)
+	if len(entries) != 1 || entries[0].Success != nil {
+		t.Errorf("running rollout = %+v", entries)
+	}
+}
+
+func TestMergeTimeline(t *testing.T) {
+	at := func(minutes int) time.Time { return time.Date(2026, 3, 1, 0, minutes, 0, 0, time.UTC) }
+	commits := []TimelineEntry{{Type: TimelineCommit, Commit: "b", Time: at(30)}, {Type: TimelineCommit, Commit: "a", Time: at(10)}}
+	stored := []TimelineEntry{{Type: TimelineDeploy, Commit: "b", Time: at(30)}, {Type: TimelineConfig, Time: at(20)}}
+
+	var got []string
+	for _, e := range MergeTimeline(commits, stored) {
+		got = append(got, e.Type)
+	}
+	if want := "commit deploy config commit"; strings.Join(got, " ") != want {
+		t.Errorf("MergeTimeline() = %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// trash item kinds
+const (
+	TrashKindHook    = "hook"
+	TrashKindProject = "project"
+)
+
+// DefaultTrashRetentionDays days a deleted item stays restorable when not configured
+const DefaultTrashRetentionDays = 30
+
+// TrashRetentionDays days a deleted item stays restorable, set from the app config
+var TrashRetentionDays = DefaultTrashRetentionDays
+
+// ErrTrashUnavailable the trash needs the database
+var ErrTrashUnavailable = errors.New("trash requires the database")
+
+// TrashItem deleted hook or project, restorable until it expires
+type TrashItem struct {
+	BaseModel
+	Kind      string    `json:"kind" gorm:"size:20;index"`        // hook, project
+	Name      string    `json:"name" gorm:"size:200;index"`       // hook id or project name
+	Namespace string    `json:"namespace" gorm:"size:100;index"`  // namespace of the deleted item
+	Source    string    `json:"source,omitempty" gorm:"size:500"` // hooks file the hook was loaded from
+	DeletedBy string    `json:"deleted_by" gorm:"size:100"`       // user who deleted the item
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`          // purged after this time
+	Snapshot  string    `json:"-" gorm:"type:text"`               // deleted item (json)
+}
+
+// MoveToTrash store a snapshot of a deleted item. Without a database the deletion
+// cannot be undone and nil is returned.
+func MoveToTrash(kind, name, namespace, source string, item interface{}, deletedBy string) (*TrashItem, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	snapshot, err := json.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s %s: %v", kind, name, err)
+	}
+	days := TrashRetentionDays
+	if days <= 0 {
+		days = DefaultTrashRetentionDays
+	}
+	t := &TrashItem{
+		Kind:      kind,
+		Name:      name,
+		Namespace: namespace,
+		Source:    source,
+		DeletedBy: deletedBy,
+		ExpiresAt: time.Now().AddDate(0, 0, days),
+		Snapshot:  string(snapshot),
+	}
+	if err := DB.Create(t).Error; err != nil {
+		return nil, fmt.Errorf("move %s %s to trash: %v", kind, name, err)
+	}
+	return t, nil
+}
+
+// TrashSnapshotPath API path returning the snapshot of a trash item, linked from activity logs
+func TrashSnapshotPath(id uint) string {
+	return fmt.Sprintf("/trash/%d", id)
+}
+
+// ListTrash items that have not expired, newest first. Empty kind and namespace match all.
+func ListTrash(kind, namespace string) ([]TrashItem, error) {
+	if DB == nil {
+		return nil, ErrTrashUnavailable
+	}
+	query := DB.Where("expires_at > ?", time.Now()).Order("id DESC")
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if namespace != "" {
+		query = query.Where("namespace = ?", namespace)
+	}
+	items := []TrashItem{}
+	if err := query.Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetTrashItem item by id, nil when it does not exist or has expired
+func GetTrashItem(id uint) (*TrashItem, error) {
+	if DB == nil {
+		return nil, ErrTrashUnavailable
+	}
+	var items []TrashItem
+	if err := DB.Where("id = ? AND expires_at > ?", id, time.Now()).Limit(1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return &items[0], nil
+}
+
+// PurgeTrashItems permanently remove items from the trash
+func PurgeTrashItems(ids []uint) (int64, error) {
+	if DB == nil {
+		return 0, ErrTrashUnavailable
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res := DB.Unscoped().Where("id IN ?", ids).Delete(&TrashItem{})
+	return res.RowsAffected, res.Error
+}
+
+// PurgeExpiredTrash permanently remove the items that expired before now
+func PurgeExpiredTrash(now time.Time) (int64, error) {
+	if DB == nil {
+		return 0, ErrTrashUnavailable
+	}
+	res := DB.Unscoped().Where("expires_at <= ?", now).Delete(&TrashItem{})
+	return res.RowsAffected, res.Error
+}
+
+// ScheduleTrashPurge purge expired trash items hourly, it stops once ctx is done
+func ScheduleTrashPurge(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			if n, err := PurgeExpiredTrash(time.Now()); err != nil {
+				log.Printf("Failed to purge expired trash: %v", err)
+			} else if n > 0 {
+				log.Printf("Purged %d expired trash items", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestTrash(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&TrashItem{}); err != nil {
+		t.Fatal(err)
+	}
+	saved := DB
+	DB = conn
+	defer func() { DB = saved }()
+
+	hook, err := MoveToTrash(TrashKindHook, "deploy", "default", "hooks.json", map[string]string{"id": "deploy"}, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hook.Snapshot != `{"id":"deploy"}` || hook.ExpiresAt.Before(time.Now().AddDate(0, 0, DefaultTrashRetentionDays-1)) {
+		t.Errorf("MoveToTrash() = %+v", hook)
+	}
+	if _, err := MoveToTrash(TrashKindProject, "site", "team-a", "", map[string]string{"name": "site"}, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	expired := &TrashItem{Kind: TrashKindHook, Name: "old", Namespace: "default", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := conn.Create(expired).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		kind, namespace string
+		want            int
+	}{
+		{"", "", 2},
+		{TrashKindHook, "", 1},
+		{"", "team-a", 1},
+		{TrashKindHook, "team-a", 0},
+	}
+	for _, tt := range tests {
+		items, err := ListTrash(tt.kind, tt.namespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != tt.want {
+			t.Errorf("ListTrash(%q, %q) returned %d items, want %d", tt.kind, tt.namespace, len(items), tt.want)
+		}
+	}
+
+	if item, err := GetTrashItem(expired.ID); err != nil || item != nil {
+		t.Errorf("GetTrashItem(expired) = %+v, %v", item, err)
+	}
+	if n, err := PurgeExpiredTrash(time.Now()); err != nil || n != 1 {
+		t.Errorf("PurgeExpiredTrash() = %d, %v, want 1", n, err)
+	}
+	if n, err := PurgeTrashItems([]uint{hook.ID}); err != nil || n != 1 {
+		t.Errorf("PurgeTrashItems() = %d, %v, want 1", n, err)
+	}
+	if item, err := GetTrashItem(hook.ID); err != nil || item != nil {
+		t.Errorf("GetTrashItem(purged) = %+v, %v", item, err)
+	}
+}
@@ -0,0 +1,101 @@
+package env
+
+import (
+	"strings"
+)
+
+// MaskedValue placeholder returned instead of secret values
+const MaskedValue = "******"
+
+// secretKeyMarkers key fragments that mark a variable as sensitive
+var secretKeyMarkers = []string{"PASSWORD", "PASSWD", "TOKEN", "SECRET", "KEY"}
+
+// IsSecretKey check if env key looks like it holds a secret
+func IsSecretKey(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, marker := range secretKeyMarkers {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitEnvLine split a key=value line into prefix (indent, export, key, =) and value,
+// return ok=false for comments, section headers and lines without equal sign
+func splitEnvLine(line string) (prefix, key, value string, ok bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "[") {
+		return "", "", "", false
+	}
+
+	idx := strings.Index(line, "=")
+	if idx < 0 {
+		return "", "", "", false
+	}
+
+	key = strings.TrimSpace(line[:idx])
+	key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+	if key == "" {
+		return "", "", "", false
+	}
+
+	rest := line[idx+1:]
+	value = strings.TrimLeft(rest, " \t")
+	prefix = line[:idx+1] + rest[:len(rest)-len(value)]
+	return prefix, key, value, true
+}
+
+// maskValue keep surrounding quotes so the masked content stays valid
+func maskValue(value string) string {
+	if value == "" {
+		return value
+	}
+	for _, quote := range []string{`"`, `'`} {
+		if len(value) >= 2 && strings.HasPrefix(value, quote) && strings.HasSuffix(value, quote) {
+			return quote + MaskedValue + quote
+		}
+	}
+	return MaskedValue
+}
+
+// isMaskedValue check if value is the (optionally quoted) mask placeholder
+func isMaskedValue(value string) bool {
+	return value == MaskedValue || value == `"`+MaskedValue+`"` || value == `'`+MaskedValue+`'`
+}
+
+// MaskEnvContent replace values of secret-like keys with MaskedValue
+func MaskEnvContent(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		prefix, key, value, ok := splitEnvLine(line)
+		if !ok || !IsSecretKey(key) {
+			continue
+		}
+		lines[i] = prefix + maskValue(value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MergeMaskedContent restore secret values that the client sent back still masked,
+// so saving a masked view does not overwrite real secrets with the placeholder
+func MergeMaskedContent(content, previous string) string {
+	previousValues := make(map[string]string)
+	for _, line := range strings.Split(previous, "\n") {
+		if _, key, value, ok := splitEnvLine(line); ok {
+			previousValues[key] = value
+		}
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		prefix, key, value, ok := splitEnvLine(line)
+		if !ok || !isMaskedValue(value) {
+			continue
+		}
+		if old, exists := previousValues[key]; exists {
+			lines[i] = prefix + old
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,22 @@
+package env
+
+import "testing"
+
+func TestMaskEnvContent(t *testing.T) {
+	content := "APP_NAME=demo\nDB_PASSWORD=\"hunter2\"\n# API_TOKEN=commented\nexport API_TOKEN=abc\nSECRET_KEY='xyz'\nEMPTY_TOKEN="
+	want := "APP_NAME=demo\nDB_PASSWORD=\"******\"\n# API_TOKEN=commented\nexport API_TOKEN=******\nSECRET_KEY='******'\nEMPTY_TOKEN="
+
+	if got := MaskEnvContent(content); got != want {
+		t.Fatalf("unexpected masked content:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeMaskedContent(t *testing.T) {
+	previous := "DB_PASSWORD=\"hunter2\"\nAPI_TOKEN=abc"
+	content := "DB_PASSWORD=\"******\"\nAPI_TOKEN=new-token\nNEW_SECRET=******"
+	want := "DB_PASSWORD=\"hunter2\"\nAPI_TOKEN=new-token\nNEW_SECRET=******"
+
+	if got := MergeMaskedContent(content, previous); got != want {
+		t.Fatalf("unexpected merged content:\n%s\nwant:\n%s", got, want)
+	}
+}
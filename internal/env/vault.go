@@ -0,0 +1,180 @@
+package env
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/mycoool/gohook/internal/config"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/types"
+	"gorm.io/gorm"
+)
+
+// encrypted env storage, the .env content is kept in the database and
+// written to the project directory (materialized) on save and deploy
+
+// keyMu serializes the creation of the key, so concurrent first uses cannot each generate
+// and save a different one
+var keyMu sync.Mutex
+
+// EnsureEncryptionKey create and save the key used to encrypt env values and hook logs if
+// app.yaml has none yet
+func EnsureEncryptionKey() error {
+	_, err := encryptionKey()
+	return err
+}
+
+// encryptionKey get (or lazily create) the key used to encrypt env values
+func encryptionKey() ([]byte, error) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+
+	if types.GoHookAppConfig == nil {
+		return nil, fmt.Errorf("app config not loaded")
+	}
+
+	if types.GoHookAppConfig.EnvEncryptionKey == "" {
+		raw := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+			return nil, fmt.Errorf("generate env encryption key failed: %v", err)
+		}
+		types.GoHookAppConfig.EnvEncryptionKey = hex.EncodeToString(raw)
+		if err := config.SaveAppConfig(); err != nil {
+			// a key that is not saved would leave the values encrypted with it unreadable
+			types.GoHookAppConfig.EnvEncryptionKey = ""
+			return nil, fmt.Errorf("save env encryption key failed: %v", err)
+		}
+		log.Printf("Generated env encryption key and saved it to app.yaml")
+	}
+
+	sum := sha256.Sum256([]byte(types.GoHookAppConfig.EnvEncryptionKey))
+	return sum[:], nil
+}
+
+// EncryptValue encrypt plaintext with AES-GCM, return base64(nonce|ciphertext)
+func EncryptValue(plaintext string) (string, error) {
+	key, err := encryptionKey()
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptValue reverse of EncryptValue
+func DecryptValue(encoded string) (string, error) {
+	key, err := encryptionKey()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decode encrypted env failed: %v", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("encrypted env data is too short")
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt env failed: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+// GetEncryptedEnv load and decrypt stored env content of a project
+func GetEncryptedEnv(projectName string) (string, bool, error) {
+	db := database.GetDB()
+	if db == nil {
+		return "", false, fmt.Errorf("database not initialized")
+	}
+
+	var record database.ProjectEnv
+	if err := db.Where("project_name = ?", projectName).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	content, err := DecryptValue(record.Content)
+	if err != nil {
+		return "", true, err
+	}
+	return content, true, nil
+}
+
+// SaveEncryptedEnv encrypt and store env content of a project
+func SaveEncryptedEnv(projectName, content string) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	encrypted, err := EncryptValue(normalizeEnvContent(content))
+	if err != nil {
+		return err
+	}
+
+	var record database.ProjectEnv
+	err = db.Where("project_name = ?", projectName).First(&record).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	record.ProjectName = projectName
+	record.Content = encrypted
+	return db.Save(&record).Error
+}
+
+// DeleteEncryptedEnv remove stored env content of a project
+func DeleteEncryptedEnv(projectName string) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	return db.Unscoped().Where("project_name = ?", projectName).Delete(&database.ProjectEnv{}).Error
+}
+
+// MaterializeEnv write the stored encrypted env of a project to its .env file,
+// no-op if the project has no stored env
+func MaterializeEnv(projectName, projectPath string) error {
+	content, exists, err := GetEncryptedEnv(projectName)
+	if err != nil || !exists {
+		return err
+	}
+	return SaveEnvFile(projectPath, content)
+}
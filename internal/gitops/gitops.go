@@ -0,0 +1,508 @@
+// Package gitops reconciles the configuration of gohook (hooks files, version.yaml and
+// user.yaml) from a git repository, so the server itself is managed as code. The repository
+// is pulled on a schedule or when its webhook is called; local edits of the managed files
+// are either refused or reported as drift.
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mycoool/gohook/internal/cluster"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/webhook"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultCheckout = ".gitops"
+	defaultInterval = 5 * time.Minute
+	// gitTimeout limits a clone or fetch of the repository
+	gitTimeout = 2 * time.Minute
+	// config files of the working directory
+	versionFile = "version.yaml"
+	usersFile   = "user.yaml"
+)
+
+// ErrDisabled GitOps mode is not configured
+var ErrDisabled = errors.New("gitops is not enabled")
+
+// FileStatus local config file and its counterpart in the repository
+type FileStatus struct {
+	Kind    string `json:"kind"`             // hooks, version or users
+	Path    string `json:"path"`             // local file
+	Source  string `json:"source"`           // path in the repository
+	Managed bool   `json:"managed"`          // the repository has the file
+	SHA256  string `json:"sha256,omitempty"` // of the repository file
+	Drift   bool   `json:"drift"`            // the local file differs from the repository
+}
+
+// Status state of GitOps mode and the drift of the managed files
+type Status struct {
+	Enabled    bool         `json:"enabled"`
+	Repo       string       `json:"repo,omitempty"` // credentials redacted
+	Branch     string       `json:"branch,omitempty"`
+	LocalEdits string       `json:"localEdits,omitempty"`
+	Revision   string       `json:"revision,omitempty"` // commit of the last sync
+	SyncedAt   *time.Time   `json:"syncedAt,omitempty"`
+	Error      string       `json:"error,omitempty"` // of the last sync
+	Files      []FileStatus `json:"files"`
+	Warnings   []string     `json:"warnings,omitempty"` // of the last sync
+}
+
+// SyncResult what a sync changed
+type SyncResult struct {
+	Revision string   `json:"revision"`
+	Applied  []string `json:"applied"`            // local files replaced by the repository version
+	Warnings []string `json:"warnings,omitempty"` // drift that was overwritten or kept
+}
+
+// target local config file reconciled from the file Name of the repository directory
+type target struct {
+	kind string // cluster config name, reloaded after the file changed
+	path string
+	name string
+}
+
+var (
+	// syncMu serializes syncs, stateMu guards state
+	syncMu  sync.Mutex
+	stateMu sync.RWMutex
+	state   = newSyncState()
+
+	reloadConfig = func(string) {}
+)
+
+// syncState result of the last sync
+type syncState struct {
+	revision string
+	syncedAt time.Time
+	err      string
+	warnings []string
+	applied  map[string]string // local path -> digest of the repository content last applied
+	repo     map[string]string // local path -> digest of the file in the last applied revision
+	managed  map[string]bool   // kinds present in the repository, nil before the first sync
+}
+
+func newSyncState() *syncState {
+	return &syncState{applied: map[string]string{}}
+}
+
+// SetReload install the function reloading a config file after a sync replaced it, it is
+// called with the cluster config names
+func SetReload(fn func(name string)) {
+	reloadConfig = fn
+}
+
+// config GitOps settings of app.yaml, nil when disabled
+func config() *types.GitOpsConfig {
+	if types.GoHookAppConfig == nil || types.GoHookAppConfig.GitOps == nil || !types.GoHookAppConfig.GitOps.Enabled {
+		return nil
+	}
+	return types.GoHookAppConfig.GitOps
+}
+
+func checkoutDir(cfg *types.GitOpsConfig) string {
+	if cfg.Checkout == "" {
+		return defaultCheckout
+	}
+	return cfg.Checkout
+}
+
+func localEdits(cfg *types.GitOpsConfig) string {
+	if cfg.LocalEdits == "" {
+		return types.GitOpsRejectEdits
+	}
+	return cfg.LocalEdits
+}
+
+// Validate check the GitOps settings
+func Validate(cfg *types.GitOpsConfig) error {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	if cfg.Repo == "" {
+		return fmt.Errorf("gitops repo is required")
+	}
+	if strings.HasPrefix(cfg.Repo, "-") {
+		return fmt.Errorf("invalid gitops repo: %s", cfg.Repo)
+	}
+	if strings.HasPrefix(cfg.Branch, "-") {
+		return fmt.Errorf("invalid gitops branch: %s", cfg.Branch)
+	}
+	if filepath.IsAbs(cfg.Dir) || strings.HasPrefix(filepath.Clean(cfg.Dir), "..") {
+		return fmt.Errorf("gitops dir must be relative to the repository: %s", cfg.Dir)
+	}
+	switch localEdits(cfg) {
+	case types.GitOpsRejectEdits, types.GitOpsWarnEdits:
+	default:
+		return fmt.Errorf("unsupported gitops local_edits: %s", cfg.LocalEdits)
+	}
+	return nil
+}
+
+// targets the config files of this instance: the loaded hooks files, version.yaml and user.yaml
+func targets() []target {
+	list := []target{
+		{kind: cluster.ConfigVersion, path: versionFile, name: versionFile},
+		{kind: cluster.ConfigUsers, path: usersFile, name: usersFile},
+	}
+	if webhook.HookManager != nil {
+		for _, path := range webhook.HookManager.HooksFiles {
+			list = append(list, target{kind: cluster.ConfigHooks, path: path, name: filepath.Base(path)})
+		}
+	}
+	return list
+}
+
+// Schedule sync once and then every interval until ctx is done
+func Schedule(ctx context.Context) {
+	cfg := config()
+	if cfg == nil {
+		return
+	}
+	go func() {
+		if _, err := Sync(ctx, false); err != nil {
+			log.Printf("gitops: sync failed: %v", err)
+		}
+		interval := cfg.Interval
+		if interval == 0 {
+			interval = defaultInterval
+		}
+		if interval < 0 {
+			return
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if _, err := Sync(ctx, false); err != nil {
+				log.Printf("gitops: sync failed: %v", err)
+			}
+		}
+	}()
+}
+
+// Sync pull the repository and apply the config files that changed. With force, local
+// edits are overwritten in warn mode too.
+func Sync(ctx context.Context, force bool) (*SyncResult, error) {
+	cfg := config()
+	if cfg == nil {
+		return nil, ErrDisabled
+	}
+	if err := Validate(cfg); err != nil {
+		return nil, err
+	}
+	return reconcile(ctx, cfg, targets(), force)
+}
+
+func reconcile(ctx context.Context, cfg *types.GitOpsConfig, list []target, force bool) (*SyncResult, error) {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+
+	res, err := apply(ctx, cfg, list, force)
+	stateMu.Lock()
+	state.syncedAt = time.Now()
+	state.err = ""
+	if err != nil {
+		state.err = err.Error()
+	} else {
+		state.revision = res.Revision
+		state.warnings = res.Warnings
+	}
+	stateMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Applied) > 0 {
+		log.Printf("gitops: applied %s of revision %s", strings.Join(res.Applied, ", "), shortRevision(res.Revision))
+	}
+	for _, w := range res.Warnings {
+		log.Printf("gitops: %s", w)
+	}
+	return res, nil
+}
+
+// apply fetch the repository, validate every managed file and write the changed ones
+func apply(ctx context.Context, cfg *types.GitOpsConfig, list []target, force bool) (*SyncResult, error) {
+	dir := checkoutDir(cfg)
+	revision, err := fetch(ctx, cfg, dir)
+	if err != nil {
+		return nil, err
+	}
+	res := &SyncResult{Revision: revision, Applied: []string{}}
+
+	// read and parse everything first so an invalid revision changes nothing
+	type change struct {
+		target
+		content []byte
+		sum     string
+	}
+	var changes []change
+	managed := map[string]bool{}
+	inSync := map[string]string{} // local path -> digest of files equal to the repository
+	repo := map[string]string{}
+	stateMu.RLock()
+	applied := state.applied
+	stateMu.RUnlock()
+	for _, t := range list {
+		content, err := os.ReadFile(filepath.Join(dir, cfg.Dir, t.name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		managed[t.kind] = true
+		if err := parse(t, content); err != nil {
+			return nil, fmt.Errorf("%s of revision %s: %v", filepath.Join(cfg.Dir, t.name), shortRevision(revision), err)
+		}
+		sum := digest(content)
+		repo[t.path] = sum
+		local, err := os.ReadFile(t.path)
+		if err == nil && digest(local) == sum {
+			inSync[t.path] = sum
+			continue
+		}
+		if err == nil && applied[t.path] != "" && digest(local) != applied[t.path] {
+			// edited locally since the last sync
+			if applied[t.path] == sum && !force && localEdits(cfg) == types.GitOpsWarnEdits {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("%s differs from the repository, the local edit is kept", t.path))
+				continue
+			}
+			res.Warnings = append(res.Warnings, fmt.Sprintf("local edit of %s overwritten by revision %s", t.path, shortRevision(revision)))
+		}
+		changes = append(changes, change{target: t, content: content, sum: sum})
+	}
+
+	reload := map[string]bool{}
+	for _, c := range changes {
+		if err := writeFile(c.path, c.content); err != nil {
+			return res, fmt.Errorf("write %s: %w", c.path, err)
+		}
+		inSync[c.path] = c.sum
+		res.Applied = append(res.Applied, c.path)
+		reload[c.kind] = true
+	}
+	stateMu.Lock()
+	state.managed = managed
+	state.repo = repo
+	for path, sum := range inSync {
+		state.applied[path] = sum
+	}
+	stateMu.Unlock()
+
+	for _, kind := range []string{cluster.ConfigVersion, cluster.ConfigUsers, cluster.ConfigHooks} {
+		if reload[kind] {
+			reloadConfig(kind)
+			cluster.ConfigChanged(kind)
+		}
+	}
+	return res, nil
+}
+
+// parse check a config file of the repository can be loaded
+func parse(t target, content []byte) error {
+	switch t.kind {
+	case cluster.ConfigVersion:
+		return yaml.Unmarshal(content, &types.VersionConfig{})
+	case cluster.ConfigUsers:
+		users := &types.UsersConfig{}
+		if err := yaml.Unmarshal(content, users); err != nil {
+			return err
+		}
+		for _, u := range users.Users {
+			if u.Role == "admin" {
+				return nil
+			}
+		}
+		return fmt.Errorf("no admin user")
+	case cluster.ConfigHooks:
+		return parseHooks(t.path, content)
+	}
+	return nil
+}
+
+// parseHooks load and validate the hooks of a hooks file, evaluated as a template when the
+// instance loads templates
+func parseHooks(path string, content []byte) error {
+	tmp, err := os.CreateTemp("", "gohook-gitops-*"+filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	asTemplate := webhook.HookManager != nil && webhook.HookManager.AsTemplate
+	var hooks webhook.Hooks
+	if err := hooks.LoadFromFile(tmp.Name(), asTemplate); err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for i := range hooks {
+		if seen[hooks[i].ID] {
+			return fmt.Errorf("duplicate hook id %s", hooks[i].ID)
+		}
+		seen[hooks[i].ID] = true
+		if err := hooks[i].Validate(); err != nil {
+			return fmt.Errorf("hook %s: %v", hooks[i].ID, err)
+		}
+	}
+	return webhook.ValidateMirrors(hooks)
+}
+
+// writeFile replace a config file through a temporary file, keeping its mode
+func writeFile(path string, content []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".gitops"
+	if err := os.WriteFile(tmp, content, mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// fetch clone the repository or update the checkout to the newest commit of the branch
+func fetch(ctx context.Context, cfg *types.GitOpsConfig, dir string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		args := []string{"clone", "--quiet", "--single-branch"}
+		if cfg.Branch != "" {
+			args = append(args, "--branch", cfg.Branch)
+		}
+		if _, err := git(ctx, "", append(args, "--", cfg.Repo, dir)...); err != nil {
+			return "", err
+		}
+	} else {
+		ref := cfg.Branch
+		if ref == "" {
+			ref = "HEAD"
+		}
+		if _, err := git(ctx, dir, "remote", "set-url", "origin", cfg.Repo); err != nil {
+			return "", err
+		}
+		if _, err := git(ctx, dir, "fetch", "--quiet", "origin", ref); err != nil {
+			return "", err
+		}
+		if _, err := git(ctx, dir, "reset", "--quiet", "--hard", "FETCH_HEAD"); err != nil {
+			return "", err
+		}
+	}
+	return git(ctx, dir, "rev-parse", "HEAD")
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// GetStatus state of the last sync and the drift of the config files
+func GetStatus() *Status {
+	cfg := config()
+	if cfg == nil {
+		return &Status{Files: []FileStatus{}}
+	}
+	return status(cfg, targets())
+}
+
+func status(cfg *types.GitOpsConfig, list []target) *Status {
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	s := &Status{
+		Enabled:    true,
+		Repo:       redactRepo(cfg.Repo),
+		Branch:     cfg.Branch,
+		LocalEdits: localEdits(cfg),
+		Revision:   state.revision,
+		Error:      state.err,
+		Files:      []FileStatus{},
+		Warnings:   state.warnings,
+	}
+	if !state.syncedAt.IsZero() {
+		syncedAt := state.syncedAt
+		s.SyncedAt = &syncedAt
+	}
+	for _, t := range list {
+		f := FileStatus{Kind: t.kind, Path: t.path, Source: filepath.ToSlash(filepath.Join(cfg.Dir, t.name))}
+		if sum := state.repo[t.path]; sum != "" {
+			f.Managed = true
+			f.SHA256 = sum
+			local, err := os.ReadFile(t.path)
+			f.Drift = err != nil || digest(local) != f.SHA256
+		}
+		s.Files = append(s.Files, f)
+	}
+	sort.SliceStable(s.Files, func(i, j int) bool { return s.Files[i].Kind < s.Files[j].Kind })
+	return s
+}
+
+// Managed report whether local edits of the config kind are refused: in reject mode, for
+// kinds the repository has, and for every kind before the first sync
+func Managed(kind string) bool {
+	cfg := config()
+	if cfg == nil || localEdits(cfg) != types.GitOpsRejectEdits {
+		return false
+	}
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	return state.managed == nil || state.managed[kind]
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func shortRevision(revision string) string {
+	if len(revision) > 12 {
+		return revision[:12]
+	}
+	return revision
+}
+
+// redactRepo repository URL without its password
+func redactRepo(repo string) string {
+	u, err := url.Parse(repo)
+	if err != nil || u.Scheme == "" {
+		return repo
+	}
+	return u.Redacted()
+}
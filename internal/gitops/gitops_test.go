@@ -0,0 +1,218 @@
+package gitops
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/cluster"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+const (
+	testVersion = "projects:\n  - name: shop\n    path: /srv/shop\n"
+	testUsers   = "users:\n  - username: admin\n    password: hash\n    role: admin\n"
+)
+
+// testRepo git repository committing the files to the directory config
+type testRepo struct {
+	t   *testing.T
+	dir string
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	r := &testRepo{t: t, dir: t.TempDir()}
+	r.git("init", "--quiet", "--initial-branch", "main")
+	return r
+}
+
+func (r *testRepo) git(args ...string) {
+	r.t.Helper()
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	if out, err := exec.Command("git", append([]string{"-C", r.dir}, args...)...).CombinedOutput(); err != nil {
+		r.t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+}
+
+func (r *testRepo) commit(files map[string]string) {
+	r.t.Helper()
+	for name, content := range files {
+		path := filepath.Join(r.dir, "config", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.git("add", "-A")
+	r.git("commit", "--quiet", "-m", "update config")
+}
+
+// setup repository, GitOps settings and local targets in a fresh state
+func setup(t *testing.T, mode string) (*testRepo, *types.GitOpsConfig, []target, *[]string) {
+	repo := newTestRepo(t)
+	local := t.TempDir()
+	cfg := &types.GitOpsConfig{Enabled: true, Repo: repo.dir, Dir: "config", Checkout: filepath.Join(t.TempDir(), "checkout"), LocalEdits: mode}
+	list := []target{
+		{kind: cluster.ConfigVersion, path: filepath.Join(local, versionFile), name: versionFile},
+		{kind: cluster.ConfigUsers, path: filepath.Join(local, usersFile), name: usersFile},
+		{kind: cluster.ConfigHooks, path: filepath.Join(local, "hooks.json"), name: "hooks.json"},
+	}
+	var reloaded []string
+	app := types.GoHookAppConfig
+	types.GoHookAppConfig = &types.AppConfig{GitOps: cfg}
+	state = newSyncState()
+	reloadConfig = func(name string) { reloaded = append(reloaded, name) }
+	t.Cleanup(func() {
+		types.GoHookAppConfig = app
+		state = newSyncState()
+		reloadConfig = func(string) {}
+	})
+	return repo, cfg, list, &reloaded
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestReconcile(t *testing.T) {
+	repo, cfg, list, reloaded := setup(t, "")
+	repo.commit(map[string]string{versionFile: testVersion, usersFile: testUsers})
+	if !Managed(cluster.ConfigHooks) {
+		t.Error("edits allowed before the first sync")
+	}
+
+	res, err := reconcile(context.Background(), cfg, list, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Applied) != 2 || readFile(t, list[0].path) != testVersion || readFile(t, list[1].path) != testUsers {
+		t.Errorf("first sync = %+v", res)
+	}
+	if strings.Join(*reloaded, ",") != "version,users" {
+		t.Errorf("reloaded %v", *reloaded)
+	}
+	if !Managed(cluster.ConfigVersion) || Managed(cluster.ConfigHooks) {
+		t.Error("managed kinds differ from the repository files")
+	}
+
+	// nothing changed
+	*reloaded = nil
+	if res, err = reconcile(context.Background(), cfg, list, false); err != nil || len(res.Applied) != 0 || len(*reloaded) != 0 {
+		t.Errorf("second sync = %+v, %v, reloaded %v", res, err, *reloaded)
+	}
+
+	// a hand edit is overwritten in reject mode
+	if err := os.WriteFile(list[0].path, []byte("projects: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := status(cfg, list)
+	if len(s.Files) != 3 || !s.Files[2].Drift || !s.Files[2].Managed || s.Files[0].Managed {
+		t.Errorf("status = %+v", s.Files)
+	}
+	res, err = reconcile(context.Background(), cfg, list, false)
+	if err != nil || len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "overwritten") || readFile(t, list[0].path) != testVersion {
+		t.Errorf("sync after edit = %+v, %v", res, err)
+	}
+
+	// an invalid revision changes nothing
+	repo.commit(map[string]string{versionFile: "projects: []\n", usersFile: "users:\n  - username: bob\n    role: user\n"})
+	if _, err := reconcile(context.Background(), cfg, list, false); err == nil || !strings.Contains(err.Error(), "no admin user") {
+		t.Errorf("sync of invalid users = %v", err)
+	}
+	if readFile(t, list[0].path) != testVersion || status(cfg, list).Error == "" {
+		t.Error("invalid revision applied")
+	}
+}
+
+func TestReconcileWarn(t *testing.T) {
+	repo, cfg, list, _ := setup(t, types.GitOpsWarnEdits)
+	repo.commit(map[string]string{versionFile: testVersion, "hooks.json": `[{"id":"deploy","execute-command":"/bin/true"}]`})
+	if _, err := reconcile(context.Background(), cfg, list, false); err != nil {
+		t.Fatal(err)
+	}
+	if Managed(cluster.ConfigHooks) {
+		t.Error("edits refused in warn mode")
+	}
+
+	// the local edit is kept until the repository changes the file
+	edited := "projects: []\n"
+	if err := os.WriteFile(list[0].path, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := reconcile(context.Background(), cfg, list, false)
+	if err != nil || len(res.Applied) != 0 || len(res.Warnings) != 1 || readFile(t, list[0].path) != edited {
+		t.Errorf("sync with drift = %+v, %v", res, err)
+	}
+	res, err = reconcile(context.Background(), cfg, list, true)
+	if err != nil || len(res.Applied) != 1 || readFile(t, list[0].path) != testVersion {
+		t.Errorf("forced sync = %+v, %v", res, err)
+	}
+
+	// invalid hooks are refused
+	repo.commit(map[string]string{"hooks.json": `[{"id":"a"},{"id":"a"}]`})
+	if _, err := reconcile(context.Background(), cfg, list, false); err == nil || !strings.Contains(err.Error(), "duplicate hook id a") {
+		t.Errorf("sync of duplicate hooks = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *types.GitOpsConfig
+		wantErr string
+	}{
+		{"disabled", &types.GitOpsConfig{}, ""},
+		{"valid", &types.GitOpsConfig{Enabled: true, Repo: "https://git.example.com/ops/gohook.git", Dir: "prod", LocalEdits: "warn"}, ""},
+		{"no repo", &types.GitOpsConfig{Enabled: true}, "repo is required"},
+		{"option as branch", &types.GitOpsConfig{Enabled: true, Repo: "r", Branch: "--upload-pack=x"}, "invalid gitops branch"},
+		{"dir outside", &types.GitOpsConfig{Enabled: true, Repo: "r", Dir: "../etc"}, "relative to the repository"},
+		{"mode", &types.GitOpsConfig{Enabled: true, Repo: "r", LocalEdits: "ignore"}, "unsupported gitops local_edits"},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.cfg)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: Validate() = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	tests := []struct {
+		name   string
+		header http.Header
+		ok     bool
+	}{
+		{"github", http.Header{"X-Hub-Signature-256": {signature}}, true},
+		{"wrong signature", http.Header{"X-Hub-Signature-256": {"sha256=00"}}, false},
+		{"gitlab", http.Header{"X-Gitlab-Token": {"s3cret"}}, true},
+		{"wrong token", http.Header{"X-Gitlab-Token": {"guess"}}, false},
+		{"unsigned", http.Header{}, false},
+	}
+	for _, tt := range tests {
+		if err := verifyWebhook(tt.header, body, "s3cret"); (err == nil) != tt.ok {
+			t.Errorf("%s: verifyWebhook() = %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package gitops
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+)
+
+// maxWebhookBody limits the push event read to verify its signature
+const maxWebhookBody = 25 << 20
+
+// HandleGetStatus state of the last sync and the drift of the config files
+func HandleGetStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, GetStatus())
+}
+
+// HandleSync pull the repository and apply the changed config files now, ?force=true also
+// overwrites local edits kept in warn mode
+func HandleSync(c *gin.Context) {
+	force, _ := strconv.ParseBool(c.Query("force"))
+	res, err := Sync(c.Request.Context(), force)
+	if errors.Is(err, ErrDisabled) {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeGitOpsDisabled, err)
+		return
+	}
+	details := gin.H{"force": force}
+	if err != nil {
+		details["error"] = err.Error()
+	} else {
+		details["revision"] = res.Revision
+		details["applied"] = res.Applied
+	}
+	database.LogUserAction(c.GetString("username"), database.UserActionGitOpsSync, "gitops",
+		"sync configuration from git", middleware.GetClientIP(c), c.GetHeader("User-Agent"), err == nil, details)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadGateway, i18n.CodeGitOpsSyncFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// HandleWebhook push event of the configuration repository, verified with the webhook
+// secret (GitHub and Gitea X-Hub-Signature-256 or GitLab X-Gitlab-Token). The sync runs in
+// the background so the git host does not time out.
+func HandleWebhook(c *gin.Context) {
+	cfg := config()
+	if cfg == nil || cfg.WebhookSecret == "" {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeGitOpsWebhookDisabled, nil)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeReadPayloadFailed, err)
+		return
+	}
+	if err := verifyWebhook(c.Request.Header, body, cfg.WebhookSecret); err != nil {
+		i18n.JSONError(c, http.StatusUnauthorized, i18n.CodeInvalidSignature, err)
+		return
+	}
+	go func() {
+		if _, err := Sync(context.Background(), false); err != nil {
+			log.Printf("gitops: sync after push failed: %v", err)
+		}
+	}()
+	c.JSON(http.StatusAccepted, gin.H{"message": i18n.Message(c, i18n.CodeGitOpsSyncStarted), "code": i18n.CodeGitOpsSyncStarted})
+}
+
+// verifyWebhook check the signature or token of a push event
+func verifyWebhook(header http.Header, body []byte, secret string) error {
+	if signature := header.Get("X-Hub-Signature-256"); signature != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if !hmac.Equal([]byte(signature), []byte(expected)) {
+			return fmt.Errorf("signature verification failed")
+		}
+		return nil
+	}
+	if token := header.Get("X-Gitlab-Token"); token != "" {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			return fmt.Errorf("token verification failed")
+		}
+		return nil
+	}
+	return fmt.Errorf("X-Hub-Signature-256 or X-Gitlab-Token header is required")
+}
+
+// RejectLocalEdits middleware refusing requests that change config files of the kinds
+// (cluster config names) while they are managed by GitOps in reject mode
+func RejectLocalEdits(kinds ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, kind := range kinds {
+			if Managed(kind) {
+				i18n.JSONErrorf(c, http.StatusConflict, i18n.CodeManagedByGitOps, kind, redactRepo(config().Repo))
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
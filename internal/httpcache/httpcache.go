@@ -0,0 +1,119 @@
+// Package httpcache ETag validators of the read-heavy GET endpoints, built from the state the
+// response is made of so an unchanged response is answered with 304 before it is computed
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/locale"
+)
+
+// Tag accumulates the state of a response into its ETag
+type Tag struct {
+	h hash.Hash
+}
+
+// ForRequest tag of the response to c, seeded with what the response varies by besides the
+// state added later: the path and query, the user and namespace, the time zone and language
+func ForRequest(c *gin.Context) *Tag {
+	t := &Tag{h: sha256.New()}
+	return t.Add(c.Request.URL.Path, c.Request.URL.RawQuery, c.GetString("username"),
+		c.GetHeader("X-GoHook-Namespace"), locale.FromContext(c), i18n.FromContext(c))
+}
+
+// Add mix parts into the tag
+func (t *Tag) Add(parts ...interface{}) *Tag {
+	for _, p := range parts {
+		fmt.Fprintf(t.h, "%v\x00", p)
+	}
+	return t
+}
+
+// File mix the checksum of the content of path into the tag, a missing file mixes "-"
+func (t *Tag) File(path string) *Tag {
+	return t.Add(path, Checksum(path))
+}
+
+// Stat mix the size and modification time of path into the tag, for files too large to hash
+// on every request
+func (t *Tag) Stat(path string) *Tag {
+	info, err := os.Stat(path)
+	if err != nil {
+		return t.Add(path, "-")
+	}
+	return t.Add(path, info.Size(), info.ModTime().UnixNano())
+}
+
+// String the ETag, quoted as the header requires
+func (t *Tag) String() string {
+	return `"` + hex.EncodeToString(t.h.Sum(nil)[:16]) + `"`
+}
+
+type checksumEntry struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+var (
+	checksumsMu sync.Mutex
+	checksums   = map[string]checksumEntry{}
+)
+
+// Checksum SHA-256 of the content of path, "-" when it cannot be read. The checksum is
+// computed again only when the size or modification time of the file changed.
+func Checksum(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "-"
+	}
+	checksumsMu.Lock()
+	entry, ok := checksums[path]
+	checksumsMu.Unlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.sum
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "-"
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "-"
+	}
+	entry = checksumEntry{size: info.Size(), modTime: info.ModTime(), sum: hex.EncodeToString(h.Sum(nil))}
+	checksumsMu.Lock()
+	checksums[path] = entry
+	checksumsMu.Unlock()
+	return entry.sum
+}
+
+// NotModified set the ETag of the response and answer 304 when the If-None-Match header of
+// the request holds it, reporting whether it did. Clients must revalidate a cached response.
+func NotModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	for _, candidate := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			c.Status(http.StatusNotModified)
+			c.Writer.WriteHeaderNow()
+			c.Abort()
+			return true
+		}
+	}
+	return false
+}
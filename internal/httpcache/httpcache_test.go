@@ -0,0 +1,79 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tag := `"0123456789abcdef0123456789abcdef"`
+
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{"", false},
+		{tag, true},
+		{`"other"`, false},
+		{`"other", ` + tag, true},
+		{"W/" + tag, true},
+		{"*", true},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/hook", nil)
+		c.Request.Header.Set("If-None-Match", tt.ifNoneMatch)
+		got := NotModified(c, tag)
+		if got != tt.want {
+			t.Errorf("NotModified with If-None-Match %q = %v, want %v", tt.ifNoneMatch, got, tt.want)
+		}
+		if w.Header().Get("ETag") != tag || w.Header().Get("Cache-Control") != "private, no-cache" {
+			t.Errorf("headers = %v", w.Header())
+		}
+		if got && w.Code != http.StatusNotModified {
+			t.Errorf("status = %d, want 304", w.Code)
+		}
+	}
+}
+
+func TestTagFollowsFiles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	path := filepath.Join(t.TempDir(), "hooks.json")
+	tagOf := func(query string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/hook?"+query, nil)
+		return ForRequest(c).File(path).String()
+	}
+
+	missing := tagOf("")
+	if err := os.WriteFile(path, []byte(`[{"id":"a"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	first := tagOf("")
+	if first == missing || tagOf("") != first {
+		t.Fatalf("tag of a written file = %s, missing %s", first, missing)
+	}
+	if tagOf("sort=name") == first {
+		t.Error("the query does not change the tag")
+	}
+
+	// same size, later modification time
+	if err := os.WriteFile(path, []byte(`[{"id":"b"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if tagOf("") == first {
+		t.Error("the tag did not change with the file")
+	}
+}
@@ -0,0 +1,109 @@
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// ConflictResponse body of an update refused because the resource changed since the revision
+// its If-Match header names
+type ConflictResponse struct {
+	Error    string      `json:"error"`
+	Code     string      `json:"code"`
+	Revision string      `json:"revision"` // current revision, to send in If-Match once the edit is merged
+	Current  interface{} `json:"current"`  // current state of the resource
+}
+
+// Resource how a conditional update finds the resource it edits
+type Resource struct {
+	// Revision current revision of the resource of the request, false when it does not exist
+	Revision func(c *gin.Context) (string, bool)
+	// State current state of the resource, returned with a conflict
+	State func(c *gin.Context) interface{}
+}
+
+// Revision strong ETag of the JSON encoding of v, the revision of a resource
+func Revision(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ifMatchRequired whether updates without an If-Match header are refused, unless app.yaml
+// sets require_if_match: false
+func ifMatchRequired() bool {
+	cfg := types.GoHookAppConfig
+	return cfg == nil || cfg.RequireIfMatch == nil || *cfg.RequireIfMatch
+}
+
+// matches report whether an If-Match header names revision
+func matches(header, revision string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		if candidate = strings.TrimSpace(candidate); candidate == "*" || candidate == revision {
+			return true
+		}
+	}
+	return false
+}
+
+// IfMatch middleware of the updates of a kind of resource: an update whose If-Match header
+// does not name the current revision is refused with 409 and the current state, one without
+// the header with 428 unless app.yaml allows it. Updates of the kind run one at a time, so no
+// other edit lands between the check and the save, and a successful one answers with the new
+// revision in its ETag.
+func IfMatch(r Resource) gin.HandlerFunc {
+	var mu sync.Mutex
+	return func(c *gin.Context) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if current, ok := r.Revision(c); ok {
+			header := c.GetHeader("If-Match")
+			if header == "" && ifMatchRequired() {
+				i18n.JSONError(c, http.StatusPreconditionRequired, i18n.CodeIfMatchRequired, nil)
+				c.Abort()
+				return
+			}
+			if header != "" && !matches(header, current) {
+				c.AbortWithStatusJSON(http.StatusConflict, ConflictResponse{
+					Error:    i18n.Message(c, i18n.CodeRevisionConflict),
+					Code:     i18n.CodeRevisionConflict,
+					Revision: current,
+					Current:  r.State(c),
+				})
+				return
+			}
+		}
+		c.Writer = &revisionWriter{ResponseWriter: c.Writer, c: c, revision: r.Revision}
+		c.Next()
+	}
+}
+
+// revisionWriter sets the ETag of a successful update to the revision of the saved resource
+type revisionWriter struct {
+	gin.ResponseWriter
+	c        *gin.Context
+	revision func(c *gin.Context) (string, bool)
+	written  bool
+}
+
+func (w *revisionWriter) WriteHeader(code int) {
+	if !w.written && code < http.StatusMultipleChoices {
+		if revision, ok := w.revision(w.c); ok {
+			w.Header().Set("ETag", revision)
+		}
+	}
+	w.written = true
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,83 @@
+package httpcache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestIfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	saved := types.GoHookAppConfig
+	t.Cleanup(func() { types.GoHookAppConfig = saved })
+
+	resources := map[string]string{}
+	r := gin.New()
+	r.PUT("/items/:id", IfMatch(Resource{
+		Revision: func(c *gin.Context) (string, bool) {
+			value, ok := resources[c.Param("id")]
+			return Revision(value), ok
+		},
+		State: func(c *gin.Context) interface{} { return resources[c.Param("id")] },
+	}), func(c *gin.Context) {
+		resources[c.Param("id")] = c.Query("value")
+		c.JSON(http.StatusOK, gin.H{"message": "saved"})
+	})
+
+	yes, no := true, false
+	tests := []struct {
+		name     string
+		stored   string // value of a before the update
+		id       string
+		ifMatch  string
+		require  *bool // require_if_match of app.yaml, nil leaves the default
+		wantCode int
+		want     string // value stored afterwards
+	}{
+		{"current revision", "one", "a", Revision("one"), nil, 200, "two"},
+		{"stale revision", "changed", "a", Revision("one"), nil, 409, "changed"},
+		{"one of several", "one", "a", `"x", ` + Revision("one"), nil, 200, "two"},
+		{"any revision", "one", "a", "*", nil, 200, "two"},
+		{"unconditional refused by default", "one", "a", "", nil, 428, "one"},
+		{"unconditional refused", "one", "a", "", &yes, 428, "one"},
+		{"unconditional allowed", "one", "a", "", &no, 200, "two"},
+		{"stale revision with unconditional allowed", "changed", "a", Revision("one"), &no, 409, "changed"},
+		{"unknown resource", "one", "b", `"x"`, nil, 200, "two"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources = map[string]string{"a": tt.stored}
+			types.GoHookAppConfig = &types.AppConfig{RequireIfMatch: tt.require}
+
+			req := httptest.NewRequest(http.MethodPut, "/items/"+tt.id+"?value=two", strings.NewReader("{}"))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode || resources[tt.id] != tt.want {
+				t.Fatalf("response %d, stored %q, want %d and %q", w.Code, resources[tt.id], tt.wantCode, tt.want)
+			}
+			switch w.Code {
+			case http.StatusOK:
+				if got := w.Header().Get("ETag"); got != Revision("two") {
+					t.Errorf("ETag = %s, want the new revision %s", got, Revision("two"))
+				}
+			case http.StatusConflict:
+				var body ConflictResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				if body.Code != "revision_conflict" || body.Revision != Revision("changed") || body.Current != "changed" {
+					t.Errorf("conflict = %+v", body)
+				}
+			}
+		})
+	}
+}
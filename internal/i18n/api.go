@@ -0,0 +1,491 @@
+package i18n
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/locale"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// codes of API errors, stable across languages and releases; the message of a code is the
+// catalog entry "api.<code>"
+const (
+	CodeInvalidRequest         = "invalid_request"
+	CodeUnauthorized           = "unauthorized"
+	CodeMissingToken           = "missing_token"
+	CodeInvalidToken           = "invalid_token"
+	CodeInvalidCredentials     = "invalid_credentials"
+	CodeAdminRequired          = "admin_required"
+	CodeNamespaceDenied        = "namespace_access_denied"
+	CodeDatabaseUnavailable    = "database_unavailable"
+	CodeAppConfigNotLoaded     = "app_config_not_loaded"
+	CodeSaveConfigFailed       = "save_config_failed"
+	CodeProjectNotFound        = "project_not_found"
+	CodeHookNotFound           = "hook_not_found"
+	CodeHookIDRequired         = "hook_id_required"
+	CodeUserNotFound           = "user_not_found"
+	CodeNamespaceNotFound      = "namespace_not_found"
+	CodeSaveHookFailed         = "save_hook_failed"
+	CodeInvalidHTTPMethod      = "invalid_http_method"
+	CodeInvalidResponseCode    = "invalid_response_code"
+	CodeParameterNameRequired  = "parameter_name_required"
+	CodeParameterSourceMissing = "parameter_source_required"
+	CodeEnvNameRequired        = "env_name_required"
+	CodeEnvSourceMissing       = "env_source_required"
+	CodeScriptPathIsDirectory  = "script_path_is_directory"
+	CodeReadScriptFailed       = "read_script_failed"
+	CodeCreateScriptDirFailed  = "create_script_dir_failed"
+	CodeSaveScriptFailed       = "save_script_failed"
+	CodeUnsupportedProtocol    = "unsupported_protocol_version"
+	CodeInvalidListQuery       = "invalid_list_query"
+	CodeRevisionConflict       = "revision_conflict"
+	CodeIfMatchRequired        = "if_match_required"
+	CodeWorkingDirNotFound     = "working_directory_not_found"
+	CodeCommandFailed          = "command_failed"
+	CodeUpdateEndpointsFailed  = "update_endpoints_failed"
+	CodeCreateEndpointFailed   = "create_endpoint_failed"
+	CodeRevokeEndpointFailed   = "revoke_endpoint_failed"
+	CodeRotateSecretFailed     = "rotate_secret_failed"
+
+	// hooks
+	CodeHooksNotLoaded           = "hooks_not_loaded"
+	CodeInvalidTriggerParams     = "invalid_trigger_parameters"
+	CodeHookQuotaExceeded        = "hook_quota_exceeded"
+	CodeInvalidTestEvent         = "invalid_test_event"
+	CodeTestDeliveryFailed       = "test_delivery_failed"
+	CodeHookNoCommandSet         = "hook_no_execute_command"
+	CodeScriptPathRequired       = "script_path_required"
+	CodeScriptCheckFailed        = "script_check_failed"
+	CodeInvalidResponseTemplate  = "invalid_response_template"
+	CodeInvalidOutputPattern     = "invalid_output_pattern"
+	CodeInvalidForward           = "invalid_forward"
+	CodeCommandOrForwardRequired = "command_or_forward_required"
+	CodeForwardRequired          = "forward_required"
+	CodeHookIDExists             = "hook_id_exists"
+	CodeInvalidStarlark          = "invalid_starlark"
+	CodeUnsupportedShell         = "unsupported_shell"
+	CodeUnsupportedSandbox       = "unsupported_sandbox"
+	CodeTrashFailed              = "move_to_trash_failed"
+	CodeInvalidIdempotency       = "invalid_idempotency"
+	CodeInvalidEnvPolicy         = "invalid_env_policy"
+	CodeInvalidArtifacts         = "invalid_artifacts"
+	CodeInvalidObjectEvents      = "invalid_object_events"
+	CodeEmptyPluginName          = "empty_plugin_name"
+	CodeInvalidExecutionID       = "invalid_execution_id"
+	CodeArtifactsUnavailable     = "artifacts_unavailable"
+	CodeExecutionNotFound        = "execution_not_found"
+	CodeLoadExecutionFailed      = "load_execution_failed"
+	CodeListArtifactsFailed      = "list_artifacts_failed"
+	CodeArtifactNotFound         = "artifact_not_found"
+	CodeLoadArtifactFailed       = "load_artifact_failed"
+	CodeUpdateAliasesFailed      = "update_aliases_failed"
+	CodeRenameHookFailed         = "rename_hook_failed"
+	CodeCommandPolicyDenied      = "command_policy_denied"
+	CodeScriptPathDenied         = "script_path_denied"
+	CodeReloadHooksFailed        = "reload_hooks_failed"
+
+	// projects
+	CodeDeleteBranchFailed      = "delete_branch_failed"
+	CodeInvalidPauseWindows     = "invalid_pause_windows"
+	CodeInvalidProjectSettings  = "invalid_project_settings"
+	CodeProjectPathNotExist     = "project_path_not_exist"
+	CodeRenameProjectFailed     = "rename_project_failed"
+	CodeProjectNameExists       = "project_name_exists"
+	CodeListBranchesFailed      = "list_branches_failed"
+	CodeListTagsFailed          = "list_tags_failed"
+	CodeSyncBranchesFailed      = "sync_branches_failed"
+	CodeSwitchBranchFailed      = "switch_branch_failed"
+	CodeSyncTagsFailed          = "sync_tags_failed"
+	CodeSwitchTagFailed         = "switch_tag_failed"
+	CodeDeleteTagFailed         = "delete_tag_failed"
+	CodeInitGitFailed           = "init_git_failed"
+	CodeProjectPathNotWritable  = "project_path_not_writable"
+	CodeRemoteURLRequired       = "remote_url_required"
+	CodeSetRemoteFailed         = "set_remote_failed"
+	CodeGetRemoteFailed         = "get_remote_failed"
+	CodeLoadVersionConfigFailed = "load_version_config_failed"
+	CodeVersionConfigNotLoaded  = "version_config_not_loaded"
+
+	// project features
+	CodeLoadDeployKeyFailed       = "load_deploy_key_failed"
+	CodeNoDeployKey               = "no_deploy_key"
+	CodeDeployKeyExists           = "deploy_key_exists"
+	CodeGenerateDeployKeyFailed   = "generate_deploy_key_failed"
+	CodeDeleteDeployKeyFailed     = "delete_deploy_key_failed"
+	CodeRevealRequiresAdmin       = "reveal_requires_admin"
+	CodeLoadEnvFailed             = "load_env_failed"
+	CodeSaveEnvFailed             = "save_env_failed"
+	CodeDeleteEnvFailed           = "delete_env_failed"
+	CodeGitHookNotEnabled         = "githook_not_enabled"
+	CodeDecodePayloadFailed       = "decode_payload_failed"
+	CodeReadPayloadFailed         = "read_payload_failed"
+	CodeGitHookSecretInvalid      = "githook_secret_invalid"
+	CodeInvalidWebhookPayload     = "invalid_webhook_payload"
+	CodeGitHookPaused             = "githook_paused"
+	CodeGitHookQueueFailed        = "githook_queue_failed"
+	CodeGitMaintenanceRunning     = "git_maintenance_running"
+	CodeGitMaintenanceFailed      = "git_maintenance_failed"
+	CodeListMaintenanceRunsFailed = "list_maintenance_runs_failed"
+	CodeNoKubernetesTarget        = "no_kubernetes_target"
+	CodeKubernetesFailed          = "kubernetes_failed"
+	CodeDeploymentNotInTarget     = "deployment_not_in_target"
+	CodeNoDeploymentsToRollBack   = "no_deployments_to_roll_back"
+	CodeRollbackFailed            = "rollback_failed"
+	CodeProjectPinned             = "project_pinned"
+	CodeProjectNotPinned          = "project_not_pinned"
+	CodeInvalidEnvContent         = "invalid_env_content"
+
+	// project operations
+	CodePromoteToItself        = "promote_to_itself"
+	CodeSourceProjectNotFound  = "source_project_not_found"
+	CodePromotionRequiresGit   = "promotion_requires_git"
+	CodePromotionNotAccepted   = "promotion_not_accepted"
+	CodeSourceDeployFailed     = "source_deploy_failed"
+	CodeResolveRevisionFailed  = "resolve_revision_failed"
+	CodePromotionPending       = "promotion_pending"
+	CodeSavePromotionFailed    = "save_promotion_failed"
+	CodePromotionFailed        = "promotion_failed"
+	CodeListPromotionsFailed   = "list_promotions_failed"
+	CodeInvalidPromotionID     = "invalid_promotion_id"
+	CodePromotionNotFound      = "promotion_not_found"
+	CodeLoadPromotionFailed    = "load_promotion_failed"
+	CodePromotionNotPending    = "promotion_not_pending"
+	CodeRefProtected           = "ref_protected"
+	CodeProviderNotRegistered  = "provider_not_registered"
+	CodeInvalidProvider        = "invalid_provider"
+	CodeQueryProviderFailed    = "query_provider_failed"
+	CodeGitHookDisabled        = "githook_disabled"
+	CodeProviderTokenRequired  = "provider_token_required"
+	CodeGenerateSecretFailed   = "generate_secret_failed"
+	CodeRegisterProviderFailed = "register_provider_failed"
+	CodeReleasesNotConfigured  = "releases_not_configured"
+	CodeListReleasesFailed     = "list_releases_failed"
+	CodeInvalidReleaseID       = "invalid_release_id"
+	CodeReleaseNotFound        = "release_not_found"
+	CodeActivateReleaseFailed  = "activate_release_failed"
+	CodeInvalidServiceConfig   = "invalid_service_config"
+	CodeNoServiceConfigured    = "no_service_configured"
+	CodeInvalidServiceAction   = "invalid_service_action"
+	CodeServiceActionFailed    = "service_action_failed"
+	CodeInvalidSnapshotID      = "invalid_snapshot_id"
+	CodeSnapshotNotFound       = "snapshot_not_found"
+	CodeListSnapshotsFailed    = "list_snapshots_failed"
+	CodeRestoreSnapshotFailed  = "restore_snapshot_failed"
+	CodeDeleteSnapshotFailed   = "delete_snapshot_failed"
+	CodeTimelineTooDeep        = "timeline_too_deep"
+	CodeUnknownTimelineType    = "unknown_timeline_type"
+	CodeLoadTimelineFailed     = "load_timeline_failed"
+	CodeLoadRevisionsFailed    = "load_revisions_failed"
+
+	// sync nodes
+	CodeInvalidID               = "invalid_id"
+	CodeInvalidSelector         = "invalid_selector"
+	CodeListNodesFailed         = "list_nodes_failed"
+	CodeLoadNodeFailed          = "load_node_failed"
+	CodeCreateNodeFailed        = "create_node_failed"
+	CodeUpdateNodeFailed        = "update_node_failed"
+	CodeDeleteNodeFailed        = "delete_node_failed"
+	CodeInstallNodeFailed       = "install_node_failed"
+	CodeRotateNodeTokenFailed   = "rotate_node_token_failed"
+	CodeResetPairingFailed      = "reset_pairing_failed"
+	CodeApproveNodeFailed       = "approve_node_failed"
+	CodeRevokeNodeFailed        = "revoke_node_failed"
+	CodeAgentAuthFailed         = "agent_auth_failed"
+	CodeInvalidLogSource        = "invalid_log_source"
+	CodeListLogsFailed          = "list_logs_failed"
+	CodeInvalidSyncConfig       = "invalid_sync_config"
+	CodeListDeploymentsFailed   = "list_deployments_failed"
+	CodeCreateDeploymentFailed  = "create_deployment_failed"
+	CodeDeploymentNotFound      = "deployment_not_found"
+	CodeDeploymentStateConflict = "deployment_state_conflict"
+	CodeDeploymentActionFailed  = "deployment_action_failed"
+	CodeLoadDeploymentFailed    = "load_deployment_failed"
+	CodeListTasksFailed         = "list_tasks_failed"
+	CodeClearTasksFailed        = "clear_tasks_failed"
+	CodeTaskNotFound            = "task_not_found"
+	CodeLoadTaskFailed          = "load_task_failed"
+	CodeCreateTasksFailed       = "create_tasks_failed"
+	CodePullTaskFailed          = "pull_task_failed"
+	CodeReportTaskFailed        = "report_task_failed"
+	CodeTaskNotOfNode           = "task_not_of_node"
+
+	// system
+	CodeVerifyAuditFailed       = "verify_audit_failed"
+	CodeListClusterNodesFailed  = "list_cluster_nodes_failed"
+	CodeHADisabled              = "ha_disabled"
+	CodeNotLeader               = "not_leader"
+	CodeInvalidBundleProject    = "invalid_bundle_project"
+	CodeProjectNamePathRequired = "project_name_path_required"
+	CodeDiffConfigFailed        = "diff_config_failed"
+	CodeDiagnosticsFailed       = "diagnostics_failed"
+	CodeLoadLogStatsFailed      = "load_log_stats_failed"
+	CodeInvalidDays             = "invalid_days"
+	CodeCleanupLogsFailed       = "cleanup_logs_failed"
+	CodeExportLogsFailed        = "export_logs_failed"
+	CodeInvalidLogType          = "invalid_log_type"
+	CodeInvalidSuccessParam     = "invalid_success_param"
+	CodeInvalidNamespaceName    = "invalid_namespace_name"
+	CodeInvalidQuota            = "invalid_quota"
+	CodeNamespaceExists         = "namespace_exists"
+	CodeDefaultNamespace        = "default_namespace_undeletable"
+	CodeNamespaceNotEmpty       = "namespace_not_empty"
+	CodeInvalidQueueConfig      = "invalid_queue_config"
+	CodeSearchQueryRequired     = "search_query_required"
+	CodeSearchLogsFailed        = "search_logs_failed"
+	CodeInvalidURLLayout        = "invalid_url_layout"
+	CodeInvalidStatsFilter      = "invalid_stats_filter"
+	CodeLoadStatsFailed         = "load_stats_failed"
+	CodeInvalidStatsInterval    = "invalid_stats_interval"
+	CodeStatsRangeTooLarge      = "stats_range_too_large"
+	CodeLoadSystemConfigFailed  = "load_system_config_failed"
+	CodeExportProjectFailed     = "export_project_failed"
+	CodeImportHooksFailed       = "import_hooks_failed"
+	CodeTrashUnavailable        = "trash_unavailable"
+	CodeLoadTrashFailed         = "load_trash_failed"
+	CodeInvalidTrashItemID      = "invalid_trash_item_id"
+	CodeTrashItemNotFound       = "trash_item_not_found"
+	CodeInvalidTrashKind        = "invalid_trash_kind"
+	CodeUnknownTrashKind        = "unknown_trash_kind"
+	CodeRestoreTrashFailed      = "restore_trash_failed"
+
+	// users
+	CodeInvalidClientID          = "invalid_client_id"
+	CodeClientSessionNotFound    = "client_session_not_found"
+	CodeGenerateTokenFailed      = "generate_token_failed"
+	CodeMissingAuthHeader        = "missing_auth_header"
+	CodeInvalidAuthType          = "invalid_auth_type"
+	CodeInvalidAuthEncoding      = "invalid_auth_encoding"
+	CodeInvalidCredentialsFormat = "invalid_credentials_format"
+	CodeUserExists               = "user_exists"
+	CodeInvalidRole              = "invalid_role"
+	CodeCannotDeleteSelf         = "cannot_delete_self"
+	CodeInvalidOldPassword       = "invalid_old_password"
+	CodeInvalidTimezone          = "invalid_timezone"
+	CodeInvalidLanguage          = "invalid_language"
+
+	// features
+	CodeNotFound                 = "not_found"
+	CodeWebSocketUpgradeFailed   = "websocket_upgrade_failed"
+	CodeShortPassphrase          = "short_passphrase"
+	CodePassphraseRequired       = "passphrase_required"
+	CodeCreateBackupFailed       = "create_backup_failed"
+	CodeReadBackupFailed         = "read_backup_failed"
+	CodeInspectBackupFailed      = "inspect_backup_failed"
+	CodeRestoreBackupFailed      = "restore_backup_failed"
+	CodeGitOpsDisabled           = "gitops_disabled"
+	CodeGitOpsSyncFailed         = "gitops_sync_failed"
+	CodeGitOpsWebhookDisabled    = "gitops_webhook_disabled"
+	CodeManagedByGitOps          = "managed_by_gitops"
+	CodeInvalidSignature         = "invalid_signature"
+	CodeInvalidMessageID         = "invalid_message_id"
+	CodeInvalidLimit             = "invalid_limit"
+	CodeInvalidSince             = "invalid_since"
+	CodeListMessagesFailed       = "list_messages_failed"
+	CodeCountMessagesFailed      = "count_messages_failed"
+	CodeMarkMessageReadFailed    = "mark_message_read_failed"
+	CodeMarkMessagesReadFailed   = "mark_messages_read_failed"
+	CodeDeleteMessageFailed      = "delete_message_failed"
+	CodeMessageNotFound          = "message_not_found"
+	CodeDeleteMessagesFailed     = "delete_messages_failed"
+	CodeInvalidUntil             = "invalid_until"
+	CodeInvalidRejectStatus      = "invalid_reject_status"
+	CodeListQueueFailed          = "list_queue_failed"
+	CodeFlushQueueFailed         = "flush_queue_failed"
+	CodeDiscardQueueFailed       = "discard_queue_failed"
+	CodeInvalidPluginID          = "invalid_plugin_id"
+	CodePluginNotFound           = "plugin_not_found"
+	CodePluginNotRunning         = "plugin_not_running"
+	CodeLoadPluginFailed         = "load_plugin_failed"
+	CodePluginLacksCapability    = "plugin_lacks_capability"
+	CodeReadPluginConfigFailed   = "read_plugin_config_failed"
+	CodePluginConfigTooLarge     = "plugin_config_too_large"
+	CodeUpdatePluginConfigFailed = "update_plugin_config_failed"
+	CodePluginDisplayFailed      = "plugin_display_failed"
+	CodeSetPluginStateFailed     = "set_plugin_state_failed"
+	CodeReadPluginModuleFailed   = "read_plugin_module_failed"
+	CodePluginModuleTooLarge     = "plugin_module_too_large"
+	CodeInstallPluginFailed      = "install_plugin_failed"
+	CodeRemovePluginFailed       = "remove_plugin_failed"
+	CodeUpdateCheckFailed        = "update_check_failed"
+	CodeInvalidRestartParam      = "invalid_restart_param"
+	CodeUpdateNotStarted         = "update_not_started"
+	CodeUpdateFailed             = "update_failed"
+)
+
+// codes of API success messages, returned with the localized message
+const (
+	CodeHookTriggered     = "hook_triggered"
+	CodeHookNoCommand     = "hook_triggered_no_command"
+	CodeHookQuotaUpdated  = "hook_quota_updated"
+	CodeHookBudgetUpdated = "hook_budget_updated"
+	CodeHookCircuitClosed = "hook_circuit_closed"
+	CodeEndpointsUpdated  = "hook_endpoints_updated"
+	CodeEndpointCreated   = "hook_endpoint_created"
+	CodeEndpointRevoked   = "hook_endpoint_revoked"
+	CodeHookSecretRotated = "hook_secret_rotated"
+
+	// hooks
+	CodeHooksReloaded            = "hooks_reloaded"
+	CodeHookForwardUpdated       = "hook_forward_updated"
+	CodeHookIdempotencyUpdated   = "hook_idempotency_updated"
+	CodeHookEnvPolicyUpdated     = "hook_env_policy_updated"
+	CodeHookArtifactsUpdated     = "hook_artifacts_updated"
+	CodeHookObjectEventsUpdated  = "hook_object_events_updated"
+	CodeHookPluginsUpdated       = "hook_plugins_updated"
+	CodeHookStarlarkUpdated      = "hook_starlark_updated"
+	CodeHookAliasesUpdated       = "hook_aliases_updated"
+	CodeHookRenamed              = "hook_renamed"
+	CodeScriptIsSystemExecutable = "script_is_system_executable"
+	CodeScriptIsBinary           = "script_is_binary"
+	CodeScriptPathSuggestion     = "script_path_suggestion"
+	CodeScriptDirSuggestion      = "script_directory_suggestion"
+	CodeHookResponseUpdated      = "hook_response_updated"
+	CodeScriptSaved              = "script_saved"
+	CodeHookCreated              = "hook_created"
+	CodeHookBasicUpdated         = "hook_basic_updated"
+	CodeHookParametersUpdated    = "hook_parameters_updated"
+	CodeHookTriggersUpdated      = "hook_triggers_updated"
+	CodeHookCommandUpdated       = "hook_command_updated"
+	CodeHookDeleted              = "hook_deleted"
+
+	// projects
+	CodeBranchDeleted         = "branch_deleted"
+	CodeProjectUpdated        = "project_updated"
+	CodeProjectAdded          = "project_added"
+	CodeProjectDeleted        = "project_deleted"
+	CodeBranchesSynced        = "branches_synced"
+	CodeBranchSwitched        = "branch_switched"
+	CodeTagsSynced            = "tags_synced"
+	CodeTagSwitched           = "tag_switched"
+	CodeTagDeleted            = "tag_deleted"
+	CodeGitInitialized        = "git_initialized"
+	CodeRemoteSet             = "remote_set"
+	CodeVersionConfigReloaded = "version_config_reloaded"
+
+	// project features
+	CodeDeployKeyGenerated = "deploy_key_generated"
+	CodeDeployKeyRemoved   = "deploy_key_removed"
+	CodeEnvSaved           = "env_saved"
+	CodeEnvDeleted         = "env_deleted"
+	CodeGitHookConfigSaved = "githook_config_saved"
+	CodeGitHookQueued      = "githook_queued"
+	CodeRolledBack         = "rolled_back"
+	CodeProjectPinSet      = "project_pin_set"
+	CodeProjectUnpinned    = "project_unpinned"
+
+	// project operations
+	CodePromotionAwaitingApproval = "promotion_awaiting_approval"
+	CodePromoted                  = "promoted"
+	CodePromotionRejected         = "promotion_rejected"
+	CodeProviderWebhookCreated    = "provider_webhook_created"
+	CodeProviderWebhookUpdated    = "provider_webhook_updated"
+	CodeReleaseActivated          = "release_activated"
+	CodeProjectRenamed            = "project_renamed"
+	CodeGitHookSecretRotated      = "githook_secret_rotated"
+	CodeServiceConfigSaved        = "service_config_saved"
+	CodeServiceActionExecuted     = "service_action_executed"
+	CodeSnapshotRestored          = "snapshot_restored"
+	CodeSnapshotDeleted           = "snapshot_deleted"
+
+	// sync nodes
+	CodeSyncConfigUpdated = "sync_config_updated"
+
+	// system
+	CodeLeadershipReleased  = "leadership_released"
+	CodeLogsCleaned         = "logs_cleaned"
+	CodeNamespaceDeleted    = "namespace_deleted"
+	CodeSystemConfigUpdated = "system_config_updated"
+	CodeTrashItemRestored   = "trash_item_restored"
+
+	// users
+	CodeClientSessionDeleted      = "client_session_deleted"
+	CodeLoggedOut                 = "logged_out"
+	CodeLogoutSessionNotFound     = "logout_session_not_found"
+	CodePasswordChangeUnsupported = "password_change_unsupported"
+	CodeUserCreated               = "user_created"
+	CodeUserDeleted               = "user_deleted"
+	CodePasswordUpdated           = "password_updated"
+	CodePasswordReset             = "password_reset"
+
+	// features
+	CodeGitOpsSyncStarted   = "gitops_sync_started"
+	CodeMessageMarkedRead   = "message_marked_read"
+	CodeMessagesMarkedRead  = "messages_marked_read"
+	CodeMessageDeleted      = "message_deleted"
+	CodeMessagesDeleted     = "messages_deleted"
+	CodePluginConfigUpdated = "plugin_config_updated"
+	CodePluginEnabled       = "plugin_enabled"
+	CodePluginDisabled      = "plugin_disabled"
+	CodePluginInstalled     = "plugin_installed"
+	CodePluginRemoved       = "plugin_removed"
+)
+
+// ErrorResponse body of a failed API request
+type ErrorResponse struct {
+	Error string `json:"error"` // message in the language of the request
+	Code  string `json:"code"`  // stable machine-readable code
+}
+
+// Negotiate supported locale an Accept-Language header prefers most, empty when it names none
+func Negotiate(header string) Locale {
+	best, bestQ := Locale(""), 0.0
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if i := strings.IndexAny(tag, "-_"); i >= 0 {
+			tag = tag[:i]
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if (tag == string(LocaleEnglish) || tag == string(LocaleChinese)) && q > bestQ {
+			best, bestQ = Locale(tag), q
+		}
+	}
+	return best
+}
+
+// FromContext language of an API response: the preference of the authenticated user, then
+// the Accept-Language header, then the language of app.yaml; English without any
+func FromContext(c *gin.Context) Locale {
+	if language := locale.UserLanguage(c.GetString("username")); language != "" {
+		return Locale(language)
+	}
+	if negotiated := Negotiate(c.GetHeader("Accept-Language")); negotiated != "" {
+		return negotiated
+	}
+	if types.GoHookAppConfig != nil && types.GoHookAppConfig.Language != "" {
+		return Locale(types.GoHookAppConfig.Language)
+	}
+	return LocaleEnglish
+}
+
+// Message catalog message of code in the language of the request
+func Message(c *gin.Context, code string, args ...interface{}) string {
+	language := FromContext(c)
+	c.Header("Content-Language", string(language))
+	c.Writer.Header().Add("Vary", "Accept-Language")
+	return GetGlobal().TranslateWithLocale(language, MessageID("api."+code), args...)
+}
+
+// JSONError respond status with the code and its localized message, followed by err when
+// not nil
+func JSONError(c *gin.Context, status int, code string, err error) {
+	message := Message(c, code)
+	if err != nil {
+		message += ": " + err.Error()
+	}
+	c.JSON(status, ErrorResponse{Error: message, Code: code})
+}
+
+// JSONErrorf respond status with the code and its localized message formatted with args
+func JSONErrorf(c *gin.Context, status int, code string, args ...interface{}) {
+	c.JSON(status, ErrorResponse{Error: Message(c, code, args...), Code: code})
+}
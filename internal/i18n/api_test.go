@@ -0,0 +1,123 @@
+package i18n
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Locale
+	}{
+		{"", ""},
+		{"zh-CN,zh;q=0.9,en;q=0.8", LocaleChinese},
+		{"en-US,en;q=0.9,zh-CN;q=0.8", LocaleEnglish},
+		{"fr-FR, zh;q=0.5, en;q=0.7", LocaleEnglish},
+		{"de, *;q=0.5", ""},
+		{"zh_TW", LocaleChinese},
+		{"en;q=0, zh;q=0.1", LocaleChinese},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestJSONError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	savedUsers, savedApp := types.GoHookUsersConfig, types.GoHookAppConfig
+	t.Cleanup(func() { types.GoHookUsersConfig, types.GoHookAppConfig = savedUsers, savedApp })
+	types.GoHookUsersConfig = &types.UsersConfig{Users: []types.UserConfig{
+		{Username: "li", Language: "zh"},
+		{Username: "sam"},
+	}}
+
+	tests := []struct {
+		name     string
+		username string
+		header   string
+		server   string
+		want     string
+	}{
+		{"default english", "", "", "", "Project not found"},
+		{"server language", "", "", "zh", "项目不存在"},
+		{"accept-language over server", "sam", "en-GB", "zh", "Project not found"},
+		{"preference over accept-language", "li", "en-US", "", "项目不存在"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			types.GoHookAppConfig = &types.AppConfig{Language: tt.server}
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/version/x", nil)
+			c.Request.Header.Set("Accept-Language", tt.header)
+			c.Set("username", tt.username)
+
+			JSONError(c, http.StatusNotFound, CodeProjectNotFound, nil)
+			var body ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != http.StatusNotFound || body.Code != CodeProjectNotFound || body.Error != tt.want {
+				t.Errorf("response = %d %+v, want %q", w.Code, body, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalogsMatch(t *testing.T) {
+	tr := NewTranslator(LocaleEnglish)
+	for id := range tr.messages[LocaleEnglish] {
+		if _, ok := tr.messages[LocaleChinese][id]; !ok {
+			t.Errorf("%s has no Chinese translation", id)
+		}
+	}
+	for id := range tr.messages[LocaleChinese] {
+		if _, ok := tr.messages[LocaleEnglish][id]; !ok {
+			t.Errorf("%s has no English translation", id)
+		}
+	}
+}
+
+func TestCodesInCatalog(t *testing.T) {
+	// every Code constant of api.go has a message in both catalogs
+	file, err := parser.ParseFile(token.NewFileSet(), "api.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := NewTranslator(LocaleEnglish)
+	count := 0
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok || len(spec.Names) != 1 || !strings.HasPrefix(spec.Names[0].Name, "Code") {
+			return true
+		}
+		lit, ok := spec.Values[0].(*ast.BasicLit)
+		if !ok {
+			return true
+		}
+		code, _ := strconv.Unquote(lit.Value)
+		count++
+		for _, language := range []Locale{LocaleEnglish, LocaleChinese} {
+			if _, ok := tr.messages[language][MessageID("api."+code)]; !ok {
+				t.Errorf("%s: api.%s is not in the %s catalog", spec.Names[0].Name, code, language)
+			}
+		}
+		return true
+	})
+	if count == 0 {
+		t.Fatal("no codes found in api.go")
+	}
+}
@@ -1,6 +1,7 @@
 package i18n
 
 import (
+	"embed"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -9,6 +10,12 @@ import (
 	"sync"
 )
 
+// embedded catalogs built into the binary, files in internal/i18n/locales of the working
+// directory override them
+//
+//go:embed locales/*.json
+var embedded embed.FS
+
 // MessageID is the unique identifier for a translatable message
 type MessageID string
 
@@ -42,16 +49,14 @@ type TranslationFile struct {
 
 // Init initializes the global translator with the specified locale
 func Init(locale Locale) {
-	once.Do(func() {
-		globalTranslator = NewTranslator(locale)
-	})
+	GetGlobal().SetLocale(locale)
 }
 
 // GetGlobal returns the global translator instance
 func GetGlobal() *Translator {
-	if globalTranslator == nil {
+	once.Do(func() {
 		globalTranslator = NewTranslator(LocaleEnglish)
-	}
+	})
 	return globalTranslator
 }
 
@@ -71,61 +76,73 @@ func NewTranslator(locale Locale) *Translator {
 	return t
 }
 
-// loadTranslations loads all translation files from the locales directory
+// loadTranslations loads the embedded catalogs, then the translation files of the locales
+// directory on top of them
 func (t *Translator) loadTranslations() error {
+	entries, err := embedded.ReadDir("locales")
+	if err != nil {
+		return fmt.Errorf("failed to read embedded locales: %w", err)
+	}
+	for _, entry := range entries {
+		data, err := embedded.ReadFile("locales/" + entry.Name())
+		if err != nil {
+			return err
+		}
+		if err := t.addTranslations(entry.Name(), data); err != nil {
+			log.Printf("Warning: Failed to parse embedded translation file %s: %v", entry.Name(), err)
+		}
+	}
+
 	localesDir := filepath.Join("internal", "i18n", "locales")
 
 	// Check if directory exists
 	if _, err := os.Stat(localesDir); os.IsNotExist(err) {
-		return fmt.Errorf("locales directory not found: %s", localesDir)
+		return nil
 	}
 
-	entries, err := os.ReadDir(localesDir)
+	entries, err = os.ReadDir(localesDir)
 	if err != nil {
 		return fmt.Errorf("failed to read locales directory: %w", err)
 	}
 
 	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-
-		filename := entry.Name()
-		ext := filepath.Ext(filename)
-		if ext != ".json" {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
 			continue
 		}
 
-		// Extract locale from filename (e.g., "en.json" -> "en")
-		localeStr := filename[:len(filename)-len(ext)]
-		locale := Locale(localeStr)
-
-		filePath := filepath.Join(localesDir, filename)
+		filePath := filepath.Join(localesDir, entry.Name())
 		data, err := os.ReadFile(filePath)
 		if err != nil {
 			log.Printf("Warning: Failed to read translation file %s: %v", filePath, err)
 			continue
 		}
-
-		var tf TranslationFile
-		if err := json.Unmarshal(data, &tf); err != nil {
+		if err := t.addTranslations(entry.Name(), data); err != nil {
 			log.Printf("Warning: Failed to parse translation file %s: %v", filePath, err)
-			continue
-		}
-
-		// Convert string keys to MessageID
-		messages := make(map[MessageID]string)
-		for key, value := range tf.Messages {
-			messages[MessageID(key)] = value
 		}
+	}
 
-		t.mu.Lock()
-		t.messages[locale] = messages
-		t.mu.Unlock()
+	return nil
+}
 
-		log.Printf("Loaded %d translations for locale %s from %s", len(messages), locale, filePath)
+// addTranslations merge the messages of a translation file named after its locale (e.g.
+// "en.json" -> "en")
+func (t *Translator) addTranslations(filename string, data []byte) error {
+	var tf TranslationFile
+	if err := json.Unmarshal(data, &tf); err != nil {
+		return err
 	}
+	locale := Locale(filename[:len(filename)-len(filepath.Ext(filename))])
 
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	messages := t.messages[locale]
+	if messages == nil {
+		messages = make(map[MessageID]string)
+		t.messages[locale] = messages
+	}
+	for key, value := range tf.Messages {
+		messages[MessageID(key)] = value
+	}
 	return nil
 }
 
@@ -0,0 +1,183 @@
+package inbox
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+)
+
+// page size limits of GET /message
+const (
+	defaultPageSize = 100
+	maxPageSize     = 200
+)
+
+// Message inbox message in the shape the message list of the UI expects
+type Message struct {
+	ID       uint      `json:"id"`
+	AppID    uint      `json:"appid"`
+	Kind     string    `json:"kind"`   // deploy, hook or approval
+	Target   string    `json:"target"` // project or hook
+	Title    string    `json:"title"`
+	Message  string    `json:"message"`
+	Priority int       `json:"priority"`
+	Date     time.Time `json:"date"`
+	Read     bool      `json:"read"`
+}
+
+// Paging position of a page of messages, next is set while older messages exist
+type Paging struct {
+	Size  int    `json:"size"`
+	Since uint   `json:"since,omitempty"` // id to pass as since for the next page
+	Limit int    `json:"limit"`
+	Next  string `json:"next,omitempty"`
+}
+
+// PagedMessages page of the inbox of the current user, newest first
+type PagedMessages struct {
+	Messages []Message `json:"messages"`
+	Paging   Paging    `json:"paging"`
+	Unread   int64     `json:"unread"` // unread messages in the whole inbox
+}
+
+// currentUsername user the request is authenticated as
+func currentUsername(c *gin.Context) string {
+	if username, ok := c.Get("username"); ok {
+		if s, ok := username.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// messageID parse the :id parameter, answering 400 when it is invalid
+func messageID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidMessageID, nil)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// HandleListMessages list the inbox of the current user, newest first. since pages to older
+// messages, limit sets the page size and unread=true hides read messages.
+func HandleListMessages(c *gin.Context) {
+	limit := defaultPageSize
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidLimit, nil)
+			return
+		}
+		limit = n
+	}
+	var since uint
+	if v := c.Query("since"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidSince, nil)
+			return
+		}
+		since = uint(n)
+	}
+	unreadOnly := c.Query("unread") == "true"
+
+	username := currentUsername(c)
+	// one extra message tells whether another page follows
+	stored, err := database.ListUserMessages(username, since, limit+1, unreadOnly)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListMessagesFailed, err)
+		return
+	}
+	more := len(stored) > limit
+	if more {
+		stored = stored[:limit]
+	}
+	unread, err := database.CountUnreadUserMessages(username)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeCountMessagesFailed, err)
+		return
+	}
+
+	page := PagedMessages{Messages: []Message{}, Paging: Paging{Size: len(stored), Limit: limit}, Unread: unread}
+	for _, m := range stored {
+		page.Messages = append(page.Messages, Message{
+			ID:       m.ID,
+			Kind:     m.Kind,
+			Target:   m.Target,
+			Title:    m.Title,
+			Message:  m.Message,
+			Priority: m.Priority,
+			Date:     m.CreatedAt,
+			Read:     m.Read,
+		})
+	}
+	if more {
+		page.Paging.Since = stored[len(stored)-1].ID
+		query := url.Values{}
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("since", strconv.FormatUint(uint64(page.Paging.Since), 10))
+		if unreadOnly {
+			query.Set("unread", "true")
+		}
+		page.Paging.Next = c.Request.URL.Path + "?" + query.Encode()
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// HandleMarkMessageRead mark a message of the current user as read
+func HandleMarkMessageRead(c *gin.Context) {
+	id, ok := messageID(c)
+	if !ok {
+		return
+	}
+	if _, err := database.MarkUserMessagesRead(currentUsername(c), id); err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeMarkMessageReadFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeMessageMarkedRead), "code": i18n.CodeMessageMarkedRead})
+}
+
+// HandleMarkAllMessagesRead mark every message of the current user as read
+func HandleMarkAllMessagesRead(c *gin.Context) {
+	n, err := database.MarkUserMessagesRead(currentUsername(c), 0)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeMarkMessagesReadFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeMessagesMarkedRead), "code": i18n.CodeMessagesMarkedRead, "count": n})
+}
+
+// HandleDeleteMessage delete a message of the current user
+func HandleDeleteMessage(c *gin.Context) {
+	id, ok := messageID(c)
+	if !ok {
+		return
+	}
+	n, err := database.DeleteUserMessages(currentUsername(c), id)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDeleteMessageFailed, err)
+		return
+	}
+	if n == 0 {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeMessageNotFound, nil)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeMessageDeleted), "code": i18n.CodeMessageDeleted})
+}
+
+// HandleDeleteMessages delete every message of the current user
+func HandleDeleteMessages(c *gin.Context) {
+	n, err := database.DeleteUserMessages(currentUsername(c), 0)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDeleteMessagesFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeMessagesDeleted), "code": i18n.CodeMessagesDeleted, "count": n})
+}
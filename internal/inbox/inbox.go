@@ -0,0 +1,167 @@
+// Package inbox keeps a persistent message inbox per user, filled with deploy results, failed
+// hook runs and promotions waiting for approval
+package inbox
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/stream"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// deliveryQueue broadcast messages waiting to be stored, further ones are dropped
+const deliveryQueue = 256
+
+// message priorities, the UI highlights higher ones
+const (
+	prioritySuccess  = 2
+	priorityApproval = 5
+	priorityFailure  = 8
+)
+
+var delivery struct {
+	once  sync.Once
+	queue chan []database.UserMessage
+}
+
+// Deliver stream listener storing deploy results, failed hook runs and promotions waiting for
+// approval in the inboxes of the users allowed to see them
+func Deliver(msg stream.WsMessage) {
+	if database.GetDB() == nil || types.GoHookUsersConfig == nil {
+		return
+	}
+	messages := messagesFor(msg, types.GoHookUsersConfig.Users)
+	if len(messages) == 0 {
+		return
+	}
+
+	delivery.once.Do(func() {
+		delivery.queue = make(chan []database.UserMessage, deliveryQueue)
+		go func() {
+			for messages := range delivery.queue {
+				if err := database.SaveUserMessages(messages); err != nil {
+					log.Printf("inbox: save messages failed: %v", err)
+				}
+			}
+		}()
+	})
+	select {
+	case delivery.queue <- messages:
+	default:
+		log.Printf("inbox: queue full, dropped: %s", messages[0].Title)
+	}
+}
+
+// messagesFor the inbox messages of a broadcast message, one per user who gets it
+func messagesFor(msg stream.WsMessage, users []types.UserConfig) []database.UserMessage {
+	var m database.UserMessage
+	adminsOnly := false
+	switch data := msg.Data.(type) {
+	case stream.HookTriggeredMessage:
+		if data.Success {
+			return nil
+		}
+		m = database.UserMessage{Kind: database.MessageKindHook, Target: data.HookID, Priority: priorityFailure,
+			Title: fmt.Sprintf("Hook %s failed", data.HookID), Message: data.Error}
+		if msg.Sealed {
+			// the error is encrypted in the execution log, the inbox keeps no clear copy
+			m.Message = ""
+		}
+		if data.LogID != 0 {
+			m.Message = strings.TrimPrefix(m.Message+fmt.Sprintf("\nExecution log #%d", data.LogID), "\n")
+		}
+	case stream.HookBudgetMessage:
+		m = database.UserMessage{Kind: database.MessageKindHook, Target: data.HookID, Priority: priorityFailure,
+			Title: budgetTitle(data), Message: budgetText(data)}
+		if data.Event == "circuit-closed" {
+			m.Priority = prioritySuccess
+		}
+	case stream.VersionSwitchMessage:
+		m = database.UserMessage{Kind: database.MessageKindDeploy, Target: data.ProjectName, Priority: prioritySuccess,
+			Title:   fmt.Sprintf("%s deployed", data.ProjectName),
+			Message: fmt.Sprintf("%s to %s succeeded", data.Action, data.Target)}
+		if !data.Success {
+			m.Priority = priorityFailure
+			m.Title = fmt.Sprintf("Deploy of %s failed", data.ProjectName)
+			m.Message = fmt.Sprintf("%s to %s failed: %s", data.Action, data.Target, data.Error)
+		}
+	case stream.GitHookTriggeredMessage:
+		if data.Skipped {
+			return nil
+		}
+		m = database.UserMessage{Kind: database.MessageKindDeploy, Target: data.ProjectName, Priority: prioritySuccess,
+			Title:   fmt.Sprintf("%s deployed by GitHook", data.ProjectName),
+			Message: fmt.Sprintf("%s %s: %s", data.Action, data.Target, data.Message)}
+		if !data.Success {
+			m.Priority = priorityFailure
+			m.Title = fmt.Sprintf("GitHook deploy of %s failed", data.ProjectName)
+			m.Message = fmt.Sprintf("%s %s: %s", data.Action, data.Target, data.Error)
+		}
+	case stream.PromotionRequestMessage:
+		adminsOnly = true
+		m = database.UserMessage{Kind: database.MessageKindApproval, Target: data.TargetProject, Priority: priorityApproval,
+			Title: fmt.Sprintf("Promotion to %s awaits your approval", data.TargetProject),
+			Message: fmt.Sprintf("%s requests promoting %s %s to %s (promotion #%d)",
+				data.RequestedBy, data.SourceProject, data.Ref, data.TargetProject, data.ID)}
+	case stream.UpdateAvailableMessage:
+		adminsOnly = true
+		m = database.UserMessage{Kind: database.MessageKindUpdate, Target: data.Latest, Priority: priorityApproval,
+			Title:   fmt.Sprintf("gohook %s is available", data.Latest),
+			Message: fmt.Sprintf("Running %s, install it with POST /admin/update\n%s", data.Current, data.URL)}
+	default:
+		return nil
+	}
+
+	kind := namespace.KindProject
+	if m.Kind == database.MessageKindHook {
+		kind = namespace.KindHook
+	}
+	ns := namespace.Normalize(namespace.Of(kind, m.Target))
+	var messages []database.UserMessage
+	for _, user := range users {
+		if user.Namespace != "" && user.Namespace != ns {
+			continue
+		}
+		// promotions are approved and updates installed by admins not bound to a namespace
+		if adminsOnly && (user.Role != "admin" || user.Namespace != "") {
+			continue
+		}
+		m.Username = user.Username
+		messages = append(messages, m)
+	}
+	return messages
+}
+
+// budgetTitle title of a hook budget message
+func budgetTitle(m stream.HookBudgetMessage) string {
+	switch m.Event {
+	case "circuit-open":
+		return fmt.Sprintf("Hook %s paused by its circuit breaker", m.HookID)
+	case "circuit-closed":
+		return fmt.Sprintf("Hook %s resumed", m.HookID)
+	}
+	return fmt.Sprintf("Hook %s is over its budget", m.HookID)
+}
+
+// budgetText details of a hook budget message
+func budgetText(m stream.HookBudgetMessage) string {
+	switch m.Event {
+	case "failures", "circuit-open":
+		text := fmt.Sprintf("%d failures in the last hour, budget %s", m.Failures, m.Limit)
+		if m.Until != nil {
+			text += fmt.Sprintf(", paused until %s", m.Until.UTC().Format(time.RFC3339))
+		} else if m.Event == "circuit-open" {
+			text += ", paused until reset"
+		}
+		return text
+	case "duration":
+		return fmt.Sprintf("average duration %dms in the last hour, budget %s", m.AverageDurationMs, m.Limit)
+	}
+	return "circuit breaker closed by " + m.By
+}
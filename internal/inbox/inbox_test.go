@@ -0,0 +1,68 @@
+package inbox
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/stream"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestMessagesFor(t *testing.T) {
+	users := []types.UserConfig{
+		{Username: "root", Role: "admin"},
+		{Username: "dev", Role: "user"},
+		{Username: "team-admin", Role: "admin", Namespace: "team"},
+	}
+
+	tests := []struct {
+		name       string
+		data       interface{}
+		title      string
+		text       string
+		priority   int
+		recipients []string
+	}{
+		{"hook failed", stream.HookTriggeredMessage{HookID: "build", Error: "exit status 1", LogID: 3}, "Hook build failed", "exit status 1\nExecution log #3", priorityFailure, []string{"root", "dev"}},
+		{"hook succeeded", stream.HookTriggeredMessage{HookID: "build", Success: true}, "", "", 0, nil},
+		{"hook over budget", stream.HookBudgetMessage{HookID: "build", Event: "failures", Failures: 5, Limit: "5 failures per hour"}, "Hook build is over its budget", "5 failures in the last hour, budget 5 failures per hour", priorityFailure, []string{"root", "dev"}},
+		{"circuit open", stream.HookBudgetMessage{HookID: "build", Event: "circuit-open", Failures: 5, Limit: "5 failures per hour"}, "Hook build paused by its circuit breaker", "paused until reset", priorityFailure, []string{"root", "dev"}},
+		{"circuit closed", stream.HookBudgetMessage{HookID: "build", Event: "circuit-closed", By: "alice"}, "Hook build resumed", "circuit breaker closed by alice", prioritySuccess, []string{"root", "dev"}},
+		{"deploy succeeded", stream.VersionSwitchMessage{ProjectName: "web", Action: "switch-tag", Target: "v2", Success: true}, "web deployed", "switch-tag to v2 succeeded", prioritySuccess, []string{"root", "dev"}},
+		{"deploy failed", stream.VersionSwitchMessage{ProjectName: "web", Action: "switch-tag", Target: "v2", Error: "dirty"}, "Deploy of web failed", "switch-tag to v2 failed: dirty", priorityFailure, []string{"root", "dev"}},
+		{"githook failed", stream.GitHookTriggeredMessage{ProjectName: "web", Action: "switch-branch", Target: "main", Error: "pinned"}, "GitHook deploy of web failed", "switch-branch main: pinned", priorityFailure, []string{"root", "dev"}},
+		{"githook skipped", stream.GitHookTriggeredMessage{ProjectName: "web", Skipped: true}, "", "", 0, nil},
+		{"approval to admins", stream.PromotionRequestMessage{ID: 4, SourceProject: "staging", TargetProject: "web", Ref: "v2", RequestedBy: "alice"}, "Promotion to web awaits your approval", "alice requests promoting staging v2 to web (promotion #4)", priorityApproval, []string{"root"}},
+		{"update to admins", stream.UpdateAvailableMessage{Current: "0.4.6", Latest: "0.5.0", URL: "https://github.com/mycoool/gohook/releases/tag/v0.5.0"}, "gohook 0.5.0 is available", "Running 0.4.6, install it with POST /admin/update", priorityApproval, []string{"root"}},
+		{"other messages", stream.HookManageMessage{HookID: "build"}, "", "", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := messagesFor(stream.WsMessage{Data: tt.data}, users)
+			var recipients []string
+			for _, m := range messages {
+				recipients = append(recipients, m.Username)
+				if m.Title != tt.title || !strings.Contains(m.Message, tt.text) || m.Priority != tt.priority {
+					t.Errorf("message = %+v, want %q, %q, priority %d", m, tt.title, tt.text, tt.priority)
+				}
+			}
+			if fmt.Sprint(recipients) != fmt.Sprint(tt.recipients) {
+				t.Errorf("recipients %v, want %v", recipients, tt.recipients)
+			}
+		})
+	}
+}
+
+func TestMessagesForSealed(t *testing.T) {
+	users := []types.UserConfig{{Username: "root", Role: "admin"}}
+	msg := stream.WsMessage{Data: stream.HookTriggeredMessage{HookID: "build", Error: "token=hunter2 rejected", LogID: 3}, Sealed: true}
+
+	messages := messagesFor(msg, users)
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	if messages[0].Message != "Execution log #3" {
+		t.Errorf("message = %q, want only the log reference", messages[0].Message)
+	}
+}
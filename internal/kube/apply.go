@@ -0,0 +1,155 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// documentSeparator line separating the documents of a YAML stream
+var documentSeparator = regexp.MustCompile(`(?m)^---[ \t]*(#.*)?$`)
+
+// Object object of a manifest
+type Object struct {
+	APIVersion string
+	Kind       string
+	Name       string
+	Namespace  string // empty for cluster-scoped objects
+	body       []byte // JSON
+}
+
+func (o Object) String() string {
+	if o.Namespace != "" {
+		return fmt.Sprintf("%s %s/%s", o.Kind, o.Namespace, o.Name)
+	}
+	return o.Kind + " " + o.Name
+}
+
+// ReadManifests parse the .yaml, .yml and .json files of dir in name order, a YAML file may
+// hold several documents
+func ReadManifests(dir string) ([]Object, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var objects []Object
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for i, doc := range documentSeparator.Split(string(data), -1) {
+			obj, ok, err := parseObject([]byte(doc))
+			if err != nil {
+				return nil, fmt.Errorf("%s document %d: %v", e.Name(), i+1, err)
+			}
+			if ok {
+				objects = append(objects, obj)
+			}
+		}
+	}
+	return objects, nil
+}
+
+// parseObject parse one manifest document, ok is false for empty documents
+func parseObject(doc []byte) (Object, bool, error) {
+	if len(bytes.TrimSpace(doc)) == 0 {
+		return Object{}, false, nil
+	}
+	body, err := yaml.YAMLToJSON(doc)
+	if err != nil {
+		return Object{}, false, err
+	}
+	if string(body) == "null" {
+		return Object{}, false, nil
+	}
+	var head struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Metadata   struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(body, &head); err != nil {
+		return Object{}, false, fmt.Errorf("not an object: %v", err)
+	}
+	if head.APIVersion == "" || head.Kind == "" || head.Metadata.Name == "" {
+		return Object{}, false, fmt.Errorf("apiVersion, kind and metadata.name are required")
+	}
+	return Object{APIVersion: head.APIVersion, Kind: head.Kind, Name: head.Metadata.Name, Namespace: head.Metadata.Namespace, body: body}, true, nil
+}
+
+// apiResource resource of a kind found by discovery
+type apiResource struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	Namespaced bool   `json:"namespaced"`
+}
+
+// Apply create or update objects with server-side apply, owned by FieldManager and taking
+// over conflicting fields. Namespaced objects without a namespace go to the client's namespace.
+// It returns the applied objects with their namespace filled in.
+func (c *Client) Apply(ctx context.Context, objects []Object) ([]Object, error) {
+	discovered := map[string][]apiResource{}
+	var applied []Object
+	for _, obj := range objects {
+		resources, ok := discovered[obj.APIVersion]
+		if !ok {
+			var list struct {
+				Resources []apiResource `json:"resources"`
+			}
+			if err := c.do(ctx, "GET", groupVersionPath(obj.APIVersion), "", nil, &list); err != nil {
+				return applied, fmt.Errorf("discover %s: %v", obj.APIVersion, err)
+			}
+			resources = list.Resources
+			discovered[obj.APIVersion] = resources
+		}
+		var resource *apiResource
+		for i := range resources {
+			if resources[i].Kind == obj.Kind && !strings.Contains(resources[i].Name, "/") {
+				resource = &resources[i]
+				break
+			}
+		}
+		if resource == nil {
+			return applied, fmt.Errorf("%s: kind %s is not served by %s", obj, obj.Kind, obj.APIVersion)
+		}
+
+		path := groupVersionPath(obj.APIVersion)
+		if resource.Namespaced {
+			if obj.Namespace == "" {
+				obj.Namespace = c.Namespace
+			}
+			path += "/namespaces/" + url.PathEscape(obj.Namespace)
+		} else {
+			obj.Namespace = ""
+		}
+		path += "/" + resource.Name + "/" + url.PathEscape(obj.Name) + "?fieldManager=" + FieldManager + "&force=true"
+		if err := c.do(ctx, "PATCH", path, applyPatch, obj.body, nil); err != nil {
+			return applied, fmt.Errorf("apply %s: %v", obj, err)
+		}
+		applied = append(applied, obj)
+	}
+	return applied, nil
+}
+
+// groupVersionPath API path of an apiVersion, the core group lives below /api
+func groupVersionPath(apiVersion string) string {
+	if strings.Contains(apiVersion, "/") {
+		return "/apis/" + apiVersion
+	}
+	return "/api/" + apiVersion
+}
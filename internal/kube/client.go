@@ -0,0 +1,115 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// content types of PATCH requests
+const (
+	strategicMergePatch = "application/strategic-merge-patch+json"
+	jsonPatch           = "application/json-patch+json"
+	applyPatch          = "application/apply-patch+yaml"
+)
+
+// FieldManager manager of the fields gohook sets with server-side apply
+const FieldManager = "gohook"
+
+// Client Kubernetes API client
+type Client struct {
+	server    string
+	token     string
+	http      *http.Client
+	Namespace string // namespace of objects that do not name one
+}
+
+// NewClient client for the cluster of cfg, namespace overrides the namespace of the context
+// and defaults to "default"
+func NewClient(cfg *Config, namespace string) (*Client, error) {
+	if cfg.Server == "" {
+		return nil, errors.New("no API server configured")
+	}
+	httpClient, err := cfg.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	if namespace == "" {
+		namespace = cfg.Namespace
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	return &Client{server: strings.TrimSuffix(cfg.Server, "/"), token: cfg.Token, http: httpClient, Namespace: namespace}, nil
+}
+
+// APIError error status answered by the API server
+type APIError struct {
+	Code    int
+	Reason  string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("kubernetes API answered %d %s", e.Code, e.Reason)
+}
+
+// IsNotFound report whether err is a 404 of the API server
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// do send a request to the API server and decode the JSON answer into out
+func (c *Client) do(ctx context.Context, method, path, contentType string, body []byte, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{Code: resp.StatusCode, Reason: http.StatusText(resp.StatusCode)}
+		var status struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &status) == nil {
+			if status.Reason != "" {
+				apiErr.Reason = status.Reason
+			}
+			apiErr.Message = status.Message
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,214 @@
+// Package kube is a small Kubernetes API client for deploy targets: it loads kubeconfig files or
+// the in-cluster service account, updates Deployment images, applies manifest directories with
+// server-side apply, waits for rollouts and rolls Deployments back to their previous revision.
+package kube
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+// in-cluster service account files
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Config connection settings of a cluster
+type Config struct {
+	Server    string
+	Token     string
+	CAData    []byte // PEM certificates of the API server CA, empty uses the system roots
+	CertData  []byte // PEM client certificate
+	KeyData   []byte // PEM client key
+	Insecure  bool   // skip verification of the API server certificate
+	Namespace string // default namespace of the context
+}
+
+// kubeconfig fields of a kubeconfig file the client understands
+type kubeconfig struct {
+	CurrentContext string `json:"current-context"`
+	Clusters       []struct {
+		Name    string `json:"name"`
+		Cluster struct {
+			Server                   string `json:"server"`
+			CertificateAuthority     string `json:"certificate-authority"`
+			CertificateAuthorityData string `json:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+	Users []struct {
+		Name string `json:"name"`
+		User struct {
+			Token                 string      `json:"token"`
+			TokenFile             string      `json:"tokenFile"`
+			ClientCertificate     string      `json:"client-certificate"`
+			ClientCertificateData string      `json:"client-certificate-data"`
+			ClientKey             string      `json:"client-key"`
+			ClientKeyData         string      `json:"client-key-data"`
+			Exec                  interface{} `json:"exec"`
+			AuthProvider          interface{} `json:"auth-provider"`
+		} `json:"user"`
+	} `json:"users"`
+	Contexts []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Cluster   string `json:"cluster"`
+			User      string `json:"user"`
+			Namespace string `json:"namespace"`
+		} `json:"context"`
+	} `json:"contexts"`
+}
+
+// DefaultKubeconfig path of the kubeconfig used when none is configured: the first file of
+// $KUBECONFIG or ~/.kube/config, empty when neither exists
+func DefaultKubeconfig() string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		return filepath.SplitList(env)[0]
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		path := filepath.Join(home, ".kube", "config")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// LoadKubeconfig read the cluster and credentials of a context of a kubeconfig file, an empty
+// context selects the current context. Exec and auth-provider plugins are not supported.
+func LoadKubeconfig(path, context string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var kc kubeconfig
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return nil, fmt.Errorf("parse kubeconfig %s: %v", path, err)
+	}
+	if context == "" {
+		context = kc.CurrentContext
+	}
+	dir := filepath.Dir(path)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	cfg := &Config{}
+	var clusterName, userName string
+	found := false
+	for _, c := range kc.Contexts {
+		if c.Name == context {
+			clusterName, userName, cfg.Namespace, found = c.Context.Cluster, c.Context.User, c.Context.Namespace, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("context %q not found in %s", context, path)
+	}
+
+	found = false
+	for _, c := range kc.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		found = true
+		cfg.Server, cfg.Insecure = c.Cluster.Server, c.Cluster.InsecureSkipTLSVerify
+		if cfg.CAData, err = fileOrData(resolve(c.Cluster.CertificateAuthority), c.Cluster.CertificateAuthorityData); err != nil {
+			return nil, fmt.Errorf("cluster %s: %v", clusterName, err)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("cluster %q not found in %s", clusterName, path)
+	}
+
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		if u.User.Exec != nil || u.User.AuthProvider != nil {
+			return nil, fmt.Errorf("user %s: exec and auth-provider credentials are not supported, use a token or a client certificate", userName)
+		}
+		cfg.Token = u.User.Token
+		if u.User.TokenFile != "" {
+			token, err := os.ReadFile(resolve(u.User.TokenFile))
+			if err != nil {
+				return nil, fmt.Errorf("user %s: %v", userName, err)
+			}
+			cfg.Token = strings.TrimSpace(string(token))
+		}
+		if cfg.CertData, err = fileOrData(resolve(u.User.ClientCertificate), u.User.ClientCertificateData); err != nil {
+			return nil, fmt.Errorf("user %s: %v", userName, err)
+		}
+		if cfg.KeyData, err = fileOrData(resolve(u.User.ClientKey), u.User.ClientKeyData); err != nil {
+			return nil, fmt.Errorf("user %s: %v", userName, err)
+		}
+	}
+	return cfg, nil
+}
+
+// fileOrData content of a file or of base64 data, whichever is set
+func fileOrData(path, data string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if path != "" {
+		return os.ReadFile(path)
+	}
+	return nil, nil
+}
+
+// InCluster configuration of the service account gohook runs with inside a pod
+func InCluster() (*Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running inside a cluster and no kubeconfig found")
+	}
+	token, err := os.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return nil, err
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	namespace, _ := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+	return &Config{
+		Server:    "https://" + net.JoinHostPort(host, port),
+		Token:     strings.TrimSpace(string(token)),
+		CAData:    ca,
+		Namespace: strings.TrimSpace(string(namespace)),
+	}, nil
+}
+
+// httpClient HTTP client talking to the API server of cfg
+func (cfg *Config) httpClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.Insecure}
+	if len(cfg.CAData) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(cfg.CAData) {
+			return nil, fmt.Errorf("invalid certificate authority data")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(cfg.CertData) > 0 || len(cfg.KeyData) > 0 {
+		cert, err := tls.X509KeyPair(cfg.CertData, cfg.KeyData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+	}, nil
+}
@@ -0,0 +1,239 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// revisionAnnotation revision of a Deployment and of its ReplicaSets
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// PollInterval interval of rollout status checks
+var PollInterval = 2 * time.Second
+
+// Metadata object metadata the client uses
+type Metadata struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace,omitempty"`
+	UID             string            `json:"uid,omitempty"`
+	Generation      int64             `json:"generation,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	OwnerReferences []struct {
+		UID string `json:"uid"`
+	} `json:"ownerReferences,omitempty"`
+}
+
+// Container container of a pod template
+type Container struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+// Deployment fields of an apps/v1 Deployment the client uses
+type Deployment struct {
+	Metadata Metadata `json:"metadata"`
+	Spec     struct {
+		Replicas *int32 `json:"replicas"`
+		Selector struct {
+			MatchLabels map[string]string `json:"matchLabels"`
+		} `json:"selector"`
+		Template struct {
+			Spec struct {
+				Containers []Container `json:"containers"`
+			} `json:"spec"`
+		} `json:"template"`
+	} `json:"spec"`
+	Status DeploymentStatus `json:"status"`
+}
+
+// DeploymentStatus rollout state of a Deployment
+type DeploymentStatus struct {
+	ObservedGeneration  int64 `json:"observedGeneration"`
+	Replicas            int32 `json:"replicas"`
+	UpdatedReplicas     int32 `json:"updatedReplicas"`
+	ReadyReplicas       int32 `json:"readyReplicas"`
+	AvailableReplicas   int32 `json:"availableReplicas"`
+	UnavailableReplicas int32 `json:"unavailableReplicas"`
+	Conditions          []struct {
+		Type    string `json:"type"`
+		Status  string `json:"status"`
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	} `json:"conditions"`
+}
+
+// Revision rollout revision of the Deployment, 0 when unknown
+func (d *Deployment) Revision() int64 {
+	rev, _ := strconv.ParseInt(d.Metadata.Annotations[revisionAnnotation], 10, 64)
+	return rev
+}
+
+// RolloutDone report whether every replica runs the current template, err is set when the
+// rollout exceeded its progress deadline
+func (d *Deployment) RolloutDone() (done bool, message string, err error) {
+	if d.Metadata.Generation > d.Status.ObservedGeneration {
+		return false, "waiting for the deployment spec update to be observed", nil
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == "Progressing" && c.Reason == "ProgressDeadlineExceeded" {
+			return false, "", fmt.Errorf("deployment %s exceeded its progress deadline: %s", d.Metadata.Name, c.Message)
+		}
+	}
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	s := d.Status
+	switch {
+	case s.UpdatedReplicas < replicas:
+		return false, fmt.Sprintf("%d of %d updated replicas", s.UpdatedReplicas, replicas), nil
+	case s.Replicas > s.UpdatedReplicas:
+		return false, fmt.Sprintf("%d old replicas are pending termination", s.Replicas-s.UpdatedReplicas), nil
+	case s.AvailableReplicas < s.UpdatedReplicas:
+		return false, fmt.Sprintf("%d of %d updated replicas are available", s.AvailableReplicas, s.UpdatedReplicas), nil
+	}
+	return true, fmt.Sprintf("%d of %d replicas available", s.AvailableReplicas, replicas), nil
+}
+
+func deploymentPath(namespace, name string) string {
+	return "/apis/apps/v1/namespaces/" + url.PathEscape(namespace) + "/deployments/" + url.PathEscape(name)
+}
+
+// GetDeployment read a Deployment, an empty namespace is the client's namespace
+func (c *Client) GetDeployment(ctx context.Context, namespace, name string) (*Deployment, error) {
+	if namespace == "" {
+		namespace = c.Namespace
+	}
+	var d Deployment
+	if err := c.do(ctx, "GET", deploymentPath(namespace, name), "", nil, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// SetImage set the image of a container of a Deployment, an empty container selects the
+// first container. It returns the previous image.
+func (c *Client) SetImage(ctx context.Context, namespace, name, container, image string) (string, error) {
+	d, err := c.GetDeployment(ctx, namespace, name)
+	if err != nil {
+		return "", err
+	}
+	var previous string
+	for _, ct := range d.Spec.Template.Spec.Containers {
+		if container == "" || ct.Name == container {
+			container, previous = ct.Name, ct.Image
+			break
+		}
+	}
+	if previous == "" {
+		return "", fmt.Errorf("deployment %s has no container %q", name, container)
+	}
+	patch, _ := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+			"containers": []Container{{Name: container, Image: image}},
+		}}},
+	})
+	if err := c.do(ctx, "PATCH", deploymentPath(d.Metadata.Namespace, name), strategicMergePatch, patch, nil); err != nil {
+		return "", err
+	}
+	return previous, nil
+}
+
+// WaitRollout wait until the rollout of a Deployment is done, failed or timeout passed.
+// progress is called with every new status message.
+func (c *Client) WaitRollout(ctx context.Context, namespace, name string, timeout time.Duration, progress func(string)) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var last string
+	for {
+		d, err := c.GetDeployment(ctx, namespace, name)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("rollout of deployment %s not done after %s: %s", name, timeout, last)
+			}
+			return err
+		}
+		done, message, err := d.RolloutDone()
+		if err != nil {
+			return err
+		}
+		if message != last && progress != nil {
+			progress(message)
+		}
+		last = message
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("rollout of deployment %s not done after %s: %s", name, timeout, last)
+		case <-time.After(PollInterval):
+		}
+	}
+}
+
+// replicaSet fields of a ReplicaSet the rollback needs
+type replicaSet struct {
+	Metadata Metadata `json:"metadata"`
+	Spec     struct {
+		Template map[string]interface{} `json:"template"`
+	} `json:"spec"`
+}
+
+// Undo roll a Deployment back to the pod template of its previous revision like kubectl
+// rollout undo and return the revision rolled back to
+func (c *Client) Undo(ctx context.Context, namespace, name string) (int64, error) {
+	d, err := c.GetDeployment(ctx, namespace, name)
+	if err != nil {
+		return 0, err
+	}
+	var selector []string
+	for k, v := range d.Spec.Selector.MatchLabels {
+		selector = append(selector, k+"="+v)
+	}
+	sort.Strings(selector)
+	var list struct {
+		Items []replicaSet `json:"items"`
+	}
+	path := "/apis/apps/v1/namespaces/" + url.PathEscape(d.Metadata.Namespace) + "/replicasets?labelSelector=" + url.QueryEscape(strings.Join(selector, ","))
+	if err := c.do(ctx, "GET", path, "", nil, &list); err != nil {
+		return 0, err
+	}
+
+	current := d.Revision()
+	var previous *replicaSet
+	var previousRev int64
+	for i := range list.Items {
+		rs := &list.Items[i]
+		owned := false
+		for _, o := range rs.Metadata.OwnerReferences {
+			owned = owned || o.UID == d.Metadata.UID
+		}
+		rev, _ := strconv.ParseInt(rs.Metadata.Annotations[revisionAnnotation], 10, 64)
+		if owned && rev < current && rev > previousRev {
+			previous, previousRev = rs, rev
+		}
+	}
+	if previous == nil {
+		return 0, fmt.Errorf("deployment %s has no revision before %d", name, current)
+	}
+
+	// the ReplicaSet template carries the hash label the Deployment controller adds
+	template := previous.Spec.Template
+	if meta, ok := template["metadata"].(map[string]interface{}); ok {
+		if labels, ok := meta["labels"].(map[string]interface{}); ok {
+			delete(labels, "pod-template-hash")
+		}
+	}
+	patch, _ := json.Marshal([]map[string]interface{}{{"op": "replace", "path": "/spec/template", "value": template}})
+	if err := c.do(ctx, "PATCH", deploymentPath(d.Metadata.Namespace, name), jsonPatch, patch, nil); err != nil {
+		return 0, err
+	}
+	return previousRev, nil
+}
@@ -0,0 +1,311 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer minimal API server holding one Deployment and its ReplicaSets
+type fakeServer struct {
+	mu          sync.Mutex
+	deployment  map[string]interface{}
+	replicaSets []map[string]interface{}
+	patches     []string // content type and body of every PATCH
+	applied     []string // paths of server-side applies
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, *Client) {
+	f := &fakeServer{}
+	json.Unmarshal([]byte(`{
+		"metadata": {"name": "web", "namespace": "prod", "uid": "u1", "generation": 2,
+			"annotations": {"deployment.kubernetes.io/revision": "2"}},
+		"spec": {"replicas": 2, "selector": {"matchLabels": {"app": "web"}},
+			"template": {"spec": {"containers": [{"name": "app", "image": "repo/web:v2"}]}}},
+		"status": {"observedGeneration": 2, "replicas": 2, "updatedReplicas": 2, "availableReplicas": 2}
+	}`), &f.deployment)
+	for _, rev := range []string{"1", "2"} {
+		var rs map[string]interface{}
+		json.Unmarshal([]byte(`{
+			"metadata": {"name": "web-`+rev+`", "ownerReferences": [{"uid": "u1"}],
+				"annotations": {"deployment.kubernetes.io/revision": "`+rev+`"}},
+			"spec": {"template": {"metadata": {"labels": {"app": "web", "pod-template-hash": "h`+rev+`"}},
+				"spec": {"containers": [{"name": "app", "image": "repo/web:v`+rev+`"}]}}}
+		}`), &rs)
+		f.replicaSets = append(f.replicaSets, rs)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "PATCH" && r.Header.Get("Content-Type") == applyPatch:
+			if r.URL.Query().Get("fieldManager") != FieldManager || r.URL.Query().Get("force") != "true" {
+				t.Errorf("apply query = %s", r.URL.RawQuery)
+			}
+			f.applied = append(f.applied, r.URL.Path)
+			w.Write([]byte(`{}`))
+		case r.URL.Path == "/apis/apps/v1/namespaces/prod/deployments/web" && r.Method == "GET":
+			json.NewEncoder(w).Encode(f.deployment)
+		case r.URL.Path == "/apis/apps/v1/namespaces/prod/deployments/web" && r.Method == "PATCH":
+			body, _ := io.ReadAll(r.Body)
+			f.patches = append(f.patches, r.Header.Get("Content-Type")+" "+string(body))
+			w.Write([]byte(`{}`))
+		case r.URL.Path == "/apis/apps/v1/namespaces/prod/replicasets":
+			if r.URL.Query().Get("labelSelector") != "app=web" {
+				t.Errorf("labelSelector = %q", r.URL.Query().Get("labelSelector"))
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"items": f.replicaSets})
+		case r.URL.Path == "/apis/apps/v1":
+			w.Write([]byte(`{"resources": [{"name": "deployments", "kind": "Deployment", "namespaced": true},
+				{"name": "deployments/scale", "kind": "Scale", "namespaced": true}]}`))
+		case r.URL.Path == "/api/v1":
+			w.Write([]byte(`{"resources": [{"name": "namespaces", "kind": "Namespace", "namespaced": false}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"kind": "Status", "reason": "NotFound", "message": "not found: ` + r.URL.Path + `"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	client, err := NewClient(&Config{Server: srv.URL, Token: "secret"}, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, client
+}
+
+func TestSetImage(t *testing.T) {
+	f, client := newFakeServer(t)
+	previous, err := client.SetImage(context.Background(), "", "web", "", "repo/web:v3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != "repo/web:v2" {
+		t.Errorf("previous = %q", previous)
+	}
+	want := strategicMergePatch + ` {"spec":{"template":{"spec":{"containers":[{"name":"app","image":"repo/web:v3"}]}}}}`
+	if len(f.patches) != 1 || f.patches[0] != want {
+		t.Errorf("patches = %q", f.patches)
+	}
+
+	if _, err := client.SetImage(context.Background(), "", "web", "sidecar", "x:1"); err == nil {
+		t.Error("unknown container accepted")
+	}
+	if _, err := client.SetImage(context.Background(), "", "api", "", "x:1"); !IsNotFound(err) {
+		t.Errorf("missing deployment: err = %v", err)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	f, client := newFakeServer(t)
+	rev, err := client.Undo(context.Background(), "", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev != 1 {
+		t.Errorf("revision = %d, want 1", rev)
+	}
+	if len(f.patches) != 1 || !strings.HasPrefix(f.patches[0], jsonPatch+" ") {
+		t.Fatalf("patches = %q", f.patches)
+	}
+	body := strings.TrimPrefix(f.patches[0], jsonPatch+" ")
+	if !strings.Contains(body, `"image":"repo/web:v1"`) || strings.Contains(body, "pod-template-hash") {
+		t.Errorf("patch = %s", body)
+	}
+
+	// only revisions below the current one count
+	f.deployment["metadata"].(map[string]interface{})["annotations"] = map[string]interface{}{revisionAnnotation: "1"}
+	if _, err := client.Undo(context.Background(), "", "web"); err == nil {
+		t.Error("undo without an earlier revision succeeded")
+	}
+}
+
+func TestWaitRollout(t *testing.T) {
+	PollInterval = 10 * time.Millisecond
+	f, client := newFakeServer(t)
+	status := f.deployment["status"].(map[string]interface{})
+
+	var messages []string
+	if err := client.WaitRollout(context.Background(), "", "web", time.Second, func(m string) { messages = append(messages, m) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0] != "2 of 2 replicas available" {
+		t.Errorf("messages = %q", messages)
+	}
+
+	f.mu.Lock()
+	status["updatedReplicas"] = 1
+	f.mu.Unlock()
+	err := client.WaitRollout(context.Background(), "", "web", 50*time.Millisecond, nil)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 updated replicas") {
+		t.Errorf("stuck rollout: err = %v", err)
+	}
+
+	f.mu.Lock()
+	status["conditions"] = []interface{}{map[string]interface{}{"type": "Progressing", "reason": "ProgressDeadlineExceeded", "message": "timed out"}}
+	f.mu.Unlock()
+	if err := client.WaitRollout(context.Background(), "", "web", time.Second, nil); err == nil || !strings.Contains(err.Error(), "progress deadline") {
+		t.Errorf("failed rollout: err = %v", err)
+	}
+}
+
+func TestRolloutDone(t *testing.T) {
+	two := int32(2)
+	tests := []struct {
+		name   string
+		status DeploymentStatus
+		gen    int64
+		done   bool
+	}{
+		{"done", DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}, 1, true},
+		{"spec not observed", DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}, 2, false},
+		{"updating", DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 2}, 1, false},
+		{"old replicas", DeploymentStatus{ObservedGeneration: 1, Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 2}, 1, false},
+		{"unavailable", DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Deployment{Status: tt.status}
+			d.Metadata.Generation = tt.gen
+			d.Spec.Replicas = &two
+			done, message, err := d.RolloutDone()
+			if err != nil || done != tt.done || message == "" {
+				t.Errorf("RolloutDone() = %v, %q, %v; want %v", done, message, err, tt.done)
+			}
+		})
+	}
+}
+
+func TestReadManifestsAndApply(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.yaml"), []byte(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: prod
+---
+# comment only
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+`), 0o644)
+	os.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "api", "namespace": "other"}}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# not a manifest"), 0o644)
+
+	objects, err := ReadManifests(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, o := range objects {
+		names = append(names, o.String())
+	}
+	if got := strings.Join(names, ","); got != "Namespace prod,Deployment web,Deployment other/api" {
+		t.Fatalf("objects = %s", got)
+	}
+
+	f, client := newFakeServer(t)
+	applied, err := client.Apply(context.Background(), objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"/api/v1/namespaces/prod",
+		"/apis/apps/v1/namespaces/prod/deployments/web",
+		"/apis/apps/v1/namespaces/other/deployments/api",
+	}
+	if strings.Join(f.applied, ",") != strings.Join(want, ",") {
+		t.Errorf("applied = %q", f.applied)
+	}
+	if applied[1].Namespace != "prod" || applied[0].Namespace != "" {
+		t.Errorf("namespaces = %q, %q", applied[0].Namespace, applied[1].Namespace)
+	}
+
+	os.WriteFile(filepath.Join(dir, "c.yaml"), []byte("kind: ConfigMap\n"), 0o644)
+	if _, err := ReadManifests(dir); err == nil || !strings.Contains(err.Error(), "c.yaml") {
+		t.Errorf("invalid manifest: err = %v", err)
+	}
+	if _, err := client.Apply(context.Background(), []Object{{APIVersion: "apps/v1", Kind: "Widget", Name: "x"}}); err == nil {
+		t.Error("unknown kind applied")
+	}
+}
+
+func TestLoadKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "token"), []byte("from-file\n"), 0o600)
+	path := filepath.Join(dir, "config")
+	os.WriteFile(path, []byte(`apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example:6443
+    insecure-skip-tls-verify: true
+- name: prod
+  cluster:
+    server: https://prod.example:6443
+users:
+- name: dev
+  user:
+    token: dev-token
+- name: prod
+  user:
+    tokenFile: token
+- name: sso
+  user:
+    exec:
+      command: kubelogin
+contexts:
+- name: dev
+  context: {cluster: dev, user: dev, namespace: team}
+- name: prod
+  context: {cluster: prod, user: prod}
+- name: sso
+  context: {cluster: prod, user: sso}
+`), 0o600)
+
+	tests := []struct {
+		context   string
+		server    string
+		token     string
+		namespace string
+		err       bool
+	}{
+		{"", "https://dev.example:6443", "dev-token", "team", false},
+		{"prod", "https://prod.example:6443", "from-file", "", false},
+		{"sso", "", "", "", true},
+		{"missing", "", "", "", true},
+	}
+	for _, tt := range tests {
+		cfg, err := LoadKubeconfig(path, tt.context)
+		if tt.err {
+			if err == nil {
+				t.Errorf("context %q: no error", tt.context)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("context %q: %v", tt.context, err)
+			continue
+		}
+		if cfg.Server != tt.server || cfg.Token != tt.token || cfg.Namespace != tt.namespace {
+			t.Errorf("context %q: got %+v", tt.context, cfg)
+		}
+	}
+}
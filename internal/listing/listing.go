@@ -0,0 +1,181 @@
+// Package listing pagination, sorting and field selection of the list endpoints
+package listing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Options limit, offset, sort key and fields of a list request
+type Options struct {
+	Limit  int // 0: all items from Offset
+	Offset int
+	Sort   string   // one of the keys passed to Parse, empty keeps the order of the caller
+	Desc   bool     // sort given as "-key"
+	Fields []string // JSON fields of the items to return, empty returns every field
+}
+
+// Parse read ?limit, ?offset, ?sort and ?fields; sort must be one of keys, prefixed with "-"
+// for the descending order, fields is a comma separated list of JSON field names
+func Parse(c *gin.Context, keys ...string) (Options, error) {
+	var o Options
+	var err error
+	if v := c.Query("limit"); v != "" {
+		if o.Limit, err = strconv.Atoi(v); err != nil || o.Limit < 0 {
+			return o, fmt.Errorf("invalid limit %q", v)
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		if o.Offset, err = strconv.Atoi(v); err != nil || o.Offset < 0 {
+			return o, fmt.Errorf("invalid offset %q", v)
+		}
+	}
+	if v := c.Query("sort"); v != "" {
+		o.Sort, o.Desc = strings.TrimPrefix(v, "-"), strings.HasPrefix(v, "-")
+		known := false
+		for _, k := range keys {
+			known = known || k == o.Sort
+		}
+		if !known {
+			return o, fmt.Errorf("unknown sort key %q, expected one of %s", o.Sort, strings.Join(keys, ", "))
+		}
+	}
+	for _, f := range strings.Split(c.Query("fields"), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			o.Fields = append(o.Fields, f)
+		}
+	}
+	return o, nil
+}
+
+// SortBy sort items, a slice, stably by cmp in the direction of o; cmp compares the items at
+// i and j like strings.Compare
+func (o Options) SortBy(items interface{}, cmp func(i, j int) int) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if o.Desc {
+			return cmp(j, i) < 0
+		}
+		return cmp(i, j) < 0
+	})
+}
+
+// CompareTimes compare two optional RFC3339 times like strings.Compare, nil comes first
+func CompareTimes(a, b *string) int {
+	var ta, tb time.Time
+	if a != nil {
+		ta, _ = time.Parse(time.RFC3339, *a)
+	}
+	if b != nil {
+		tb, _ = time.Parse(time.RFC3339, *b)
+	}
+	return ta.Compare(tb)
+}
+
+// Page bounds of the requested page of total items
+func (o Options) Page(total int) (start, end int) {
+	start = min(o.Offset, total)
+	end = total
+	if o.Limit > 0 {
+		end = min(start+o.Limit, total)
+	}
+	return start, end
+}
+
+// Respond write the requested page of items, a sorted slice, as a JSON array trimmed to the
+// selected fields. X-Total-Count holds the number of items and Link the next and previous pages.
+func Respond(c *gin.Context, o Options, items interface{}) error {
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("items of type %T are not a slice", items)
+	}
+	total := v.Len()
+	start, end := o.Page(total)
+	page := v.Slice(start, end).Interface()
+
+	var body interface{} = page
+	if len(o.Fields) > 0 {
+		if err := checkFields(v.Type().Elem(), o.Fields); err != nil {
+			return err
+		}
+		selected, err := selectFields(page, o.Fields)
+		if err != nil {
+			return err
+		}
+		body = selected
+	} else if end == start {
+		body = []interface{}{} // not null for an empty or nil slice
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	if o.Limit > 0 && end < total {
+		c.Writer.Header().Add("Link", pageLink(c.Request.URL, end, o.Limit, "next"))
+	}
+	if o.Limit > 0 && start > 0 {
+		c.Writer.Header().Add("Link", pageLink(c.Request.URL, max(start-o.Limit, 0), o.Limit, "prev"))
+	}
+	c.JSON(200, body)
+	return nil
+}
+
+// pageLink Link header value of the page at offset
+func pageLink(u *url.URL, offset, limit int, rel string) string {
+	q := u.Query()
+	q.Set("offset", strconv.Itoa(offset))
+	q.Set("limit", strconv.Itoa(limit))
+	return "<" + u.Path + "?" + q.Encode() + ">; rel=\"" + rel + "\""
+}
+
+// checkFields report the first field that is not the JSON name of a field of t
+func checkFields(t reflect.Type, fields []string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		names[name] = true
+	}
+	for _, f := range fields {
+		if !names[f] {
+			return fmt.Errorf("unknown field %q", f)
+		}
+	}
+	return nil
+}
+
+// selectFields items of the page as JSON objects holding only fields
+func selectFields(page interface{}, fields []string) ([]map[string]json.RawMessage, error) {
+	data, err := json.Marshal(page)
+	if err != nil {
+		return nil, err
+	}
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return nil, err
+	}
+	selected := make([]map[string]json.RawMessage, 0, len(objects))
+	for _, obj := range objects {
+		trimmed := make(map[string]json.RawMessage, len(fields))
+		for _, f := range fields {
+			if value, ok := obj[f]; ok {
+				trimmed[f] = value
+			}
+		}
+		selected = append(selected, trimmed)
+	}
+	return selected, nil
+}
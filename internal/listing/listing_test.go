@@ -0,0 +1,86 @@
+package listing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type item struct {
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	LastUsed *string `json:"lastUsed"`
+}
+
+func TestRespond(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	early, late := "2026-03-01T08:00:00Z", "2026-03-01T10:00:00+01:00"
+	items := []item{
+		{"beta", "active", &late},
+		{"alpha", "inactive", nil},
+		{"gamma", "active", &early},
+	}
+
+	tests := []struct {
+		query     string
+		wantCode  int
+		wantBody  string
+		wantLinks string
+	}{
+		{"", 200, `[{"name":"beta","status":"active","lastUsed":"2026-03-01T10:00:00+01:00"},{"name":"alpha","status":"inactive","lastUsed":null},{"name":"gamma","status":"active","lastUsed":"2026-03-01T08:00:00Z"}]`, ""},
+		{"sort=name&fields=name", 200, `[{"name":"alpha"},{"name":"beta"},{"name":"gamma"}]`, ""},
+		{"sort=-name&fields=name", 200, `[{"name":"gamma"},{"name":"beta"},{"name":"alpha"}]`, ""},
+		{"sort=last-used&fields=name", 200, `[{"name":"alpha"},{"name":"gamma"},{"name":"beta"}]`, ""},
+		{"sort=-status&fields=name,status", 200, `[{"name":"alpha","status":"inactive"},{"name":"beta","status":"active"},{"name":"gamma","status":"active"}]`, ""},
+		{"sort=name&limit=1&fields=name", 200, `[{"name":"alpha"}]`, `</items?fields=name&limit=1&offset=1&sort=name>; rel="next"`},
+		{"sort=name&limit=1&offset=1&fields=name", 200, `[{"name":"beta"}]`, `</items?fields=name&limit=1&offset=2&sort=name>; rel="next" </items?fields=name&limit=1&offset=0&sort=name>; rel="prev"`},
+		{"sort=name&limit=2&offset=2&fields=name", 200, `[{"name":"gamma"}]`, `</items?fields=name&limit=2&offset=0&sort=name>; rel="prev"`},
+		{"offset=5", 200, `[]`, ""},
+		{"limit=-1", 400, "", ""},
+		{"offset=x", 400, "", ""},
+		{"sort=size", 400, "", ""},
+		{"fields=name,size", 400, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/items?"+tt.query, nil)
+
+			sorted := append([]item(nil), items...)
+			opts, err := Parse(c, "name", "last-used", "status")
+			if err == nil {
+				switch opts.Sort {
+				case "name":
+					opts.SortBy(sorted, func(i, j int) int { return strings.Compare(sorted[i].Name, sorted[j].Name) })
+				case "last-used":
+					opts.SortBy(sorted, func(i, j int) int { return CompareTimes(sorted[i].LastUsed, sorted[j].LastUsed) })
+				case "status":
+					opts.SortBy(sorted, func(i, j int) int { return strings.Compare(sorted[i].Status, sorted[j].Status) })
+				}
+				err = Respond(c, opts, sorted)
+			}
+			if tt.wantCode == 400 {
+				if err == nil {
+					t.Errorf("no error, response %s", w.Body.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %s, want %s", got, tt.wantBody)
+			}
+			if got := strings.Join(w.Header().Values("Link"), " "); got != tt.wantLinks {
+				t.Errorf("Link = %s, want %s", got, tt.wantLinks)
+			}
+			if got := w.Header().Get("X-Total-Count"); got != "3" {
+				t.Errorf("X-Total-Count = %s, want 3", got)
+			}
+		})
+	}
+}
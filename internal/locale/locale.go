@@ -0,0 +1,182 @@
+// Package locale resolves the time zone and language of API responses: the server setting
+// (timezone and language in app.yaml), overridden by the preference of the user in
+// user.yaml. Timestamps are rendered as RFC3339 with the offset of that zone, and dates
+// printed by git, hg and svn are normalized the same way.
+package locale
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// languages of the panel
+const (
+	LanguageChinese = "zh"
+	LanguageEnglish = "en"
+)
+
+var (
+	mu     sync.RWMutex
+	server = time.Local
+)
+
+// vcsDateLayouts date formats printed by the VCS backends
+var vcsDateLayouts = []string{
+	time.RFC3339Nano,                 // git %cI and iso-strict, svn, artifact
+	"2006-01-02 15:04:05 -0700",      // git %ci and iso
+	"Mon Jan 2 15:04:05 2006 -0700",  // git default date of for-each-ref
+	"2006-01-02 15:04 -0700",         // hg isodate
+	"Mon, 2 Jan 2006 15:04:05 -0700", // git rfc2822
+}
+
+// queryLayouts formats of time query parameters without a zone, taken in the zone of the request
+var queryLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// LoadZone IANA time zone such as Asia/Shanghai or UTC, nil for an empty name
+func LoadZone(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return loc, nil
+}
+
+// ValidateLanguage check a panel language, empty keeps the default
+func ValidateLanguage(language string) error {
+	if language != "" && language != LanguageChinese && language != LanguageEnglish {
+		return fmt.Errorf("language must be either 'zh' or 'en'")
+	}
+	return nil
+}
+
+// Configure use the zone name for server timestamps, the system zone when empty
+func Configure(name string) error {
+	loc, err := LoadZone(name)
+	if err != nil {
+		return err
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	mu.Lock()
+	server = loc
+	mu.Unlock()
+	return nil
+}
+
+// Server zone of the server setting
+func Server() *time.Location {
+	mu.RLock()
+	defer mu.RUnlock()
+	return server
+}
+
+// user preferences of username, nil for unknown users
+func user(username string) *types.UserConfig {
+	if username == "" || types.GoHookUsersConfig == nil {
+		return nil
+	}
+	for i := range types.GoHookUsersConfig.Users {
+		if types.GoHookUsersConfig.Users[i].Username == username {
+			return &types.GoHookUsersConfig.Users[i]
+		}
+	}
+	return nil
+}
+
+// ForUser zone of the user's preference, the server zone without one
+func ForUser(username string) *time.Location {
+	if u := user(username); u != nil {
+		if loc, err := LoadZone(u.Timezone); err == nil && loc != nil {
+			return loc
+		}
+	}
+	return Server()
+}
+
+// UserLanguage panel language stored in the preferences of username, empty without one
+func UserLanguage(username string) string {
+	if u := user(username); u != nil {
+		return u.Language
+	}
+	return ""
+}
+
+// Language panel language of the user's preference, the server setting without one
+func Language(username string) string {
+	if language := UserLanguage(username); language != "" {
+		return language
+	}
+	if types.GoHookAppConfig != nil && types.GoHookAppConfig.Language != "" {
+		return types.GoHookAppConfig.Language
+	}
+	return LanguageChinese
+}
+
+// FromContext zone of a request: the tz query parameter, then the preference of the
+// authenticated user, then the server setting
+func FromContext(c *gin.Context) *time.Location {
+	if loc, err := LoadZone(c.Query("tz")); err == nil && loc != nil {
+		return loc
+	}
+	return ForUser(c.GetString("username"))
+}
+
+// Format t as RFC3339 in loc, empty for the zero time
+func Format(t time.Time, loc *time.Location) string {
+	if t.IsZero() {
+		return ""
+	}
+	if loc != nil {
+		t = t.In(loc)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// ParseVCSDate parse a date printed by git, hg or svn
+func ParseVCSDate(s string) (time.Time, error) {
+	for _, layout := range vcsDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown date format %q", s)
+}
+
+// VCSDate date printed by a VCS as RFC3339 in loc, dates that do not parse are kept
+func VCSDate(s string, loc *time.Location) string {
+	t, err := ParseVCSDate(s)
+	if err != nil {
+		return s
+	}
+	return Format(t, loc)
+}
+
+// ParseQuery time of a query parameter: RFC3339, or a date or date-time without a zone
+// taken in loc
+func ParseQuery(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	if loc == nil {
+		loc = Server()
+	}
+	for _, layout := range queryLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("time must be RFC3339 or YYYY-MM-DD[THH:MM:SS], got %q", s)
+}
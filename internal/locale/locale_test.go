@@ -0,0 +1,110 @@
+package locale
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestVCSDate(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	tests := []struct {
+		name string
+		in   string
+		loc  *time.Location
+		want string
+	}{
+		{"git strict", "2024-03-01T10:00:00+02:00", time.UTC, "2024-03-01T08:00:00Z"},
+		{"git ci", "2024-03-01 10:00:00 +0200", shanghai, "2024-03-01T16:00:00+08:00"},
+		{"git default", "Fri Mar 1 10:00:00 2024 +0200", time.UTC, "2024-03-01T08:00:00Z"},
+		{"hg isodate", "2024-03-01 10:00 +0200", time.UTC, "2024-03-01T08:00:00Z"},
+		{"rfc2822", "Fri, 1 Mar 2024 10:00:00 +0200", time.UTC, "2024-03-01T08:00:00Z"},
+		{"svn", "2024-03-01T08:00:00.123456Z", shanghai, "2024-03-01T16:00:00+08:00"},
+		{"unknown kept", "yesterday", time.UTC, "yesterday"},
+		{"empty kept", "", time.UTC, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VCSDate(tt.in, tt.loc); got != tt.want {
+				t.Errorf("VCSDate(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"2024-03-01T08:00:00Z", "2024-03-01T08:00:00Z", false},
+		{"2024-03-01T10:00:00+02:00", "2024-03-01T08:00:00Z", false},
+		{"2024-03-01T16:00:00", "2024-03-01T08:00:00Z", false},
+		{"2024-03-01 16:00:00", "2024-03-01T08:00:00Z", false},
+		{"2024-03-01", "2024-02-29T16:00:00Z", false},
+		{"", "", true},
+		{"01/03/2024", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseQuery(tt.in, shanghai)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseQuery(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.UTC().Format(time.RFC3339) != tt.want {
+			t.Errorf("ParseQuery(%q) = %s, want %s", tt.in, got.UTC().Format(time.RFC3339), tt.want)
+		}
+	}
+}
+
+func TestForUser(t *testing.T) {
+	savedUsers, savedApp := types.GoHookUsersConfig, types.GoHookAppConfig
+	t.Cleanup(func() {
+		types.GoHookUsersConfig, types.GoHookAppConfig = savedUsers, savedApp
+		_ = Configure("")
+	})
+	types.GoHookUsersConfig = &types.UsersConfig{Users: []types.UserConfig{
+		{Username: "alice", Timezone: "Asia/Tokyo", Language: LanguageEnglish},
+		{Username: "bob"},
+		{Username: "carol", Timezone: "Mars/Olympus"},
+	}}
+	types.GoHookAppConfig = &types.AppConfig{Language: LanguageChinese}
+	if err := Configure("UTC"); err != nil {
+		t.Skip("no time zone database")
+	}
+
+	tests := []struct {
+		username     string
+		wantZone     string
+		wantLanguage string
+	}{
+		{"alice", "Asia/Tokyo", LanguageEnglish},
+		{"bob", "UTC", LanguageChinese},
+		{"carol", "UTC", LanguageChinese},
+		{"", "UTC", LanguageChinese},
+	}
+	for _, tt := range tests {
+		if got := ForUser(tt.username).String(); got != tt.wantZone {
+			t.Errorf("ForUser(%q) = %s, want %s", tt.username, got, tt.wantZone)
+		}
+		if got := Language(tt.username); got != tt.wantLanguage {
+			t.Errorf("Language(%q) = %s, want %s", tt.username, got, tt.wantLanguage)
+		}
+	}
+
+	if err := Configure("Nowhere/City"); err == nil {
+		t.Error("Configure accepted an unknown zone")
+	}
+	if Server().String() != "UTC" {
+		t.Error("failed Configure changed the server zone")
+	}
+}
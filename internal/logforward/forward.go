@@ -0,0 +1,328 @@
+// Package logforward ships the hook, system and user activity logs of this instance to external
+// systems: RFC 5424 syslog over UDP, TCP or TLS, a Splunk HTTP Event Collector or any HTTP
+// endpoint taking JSON. Entries are buffered while a destination is slow or down and sent in
+// batches with retries.
+package logforward
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// retry delays after a failed send
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// sendTimeout limit for one send to a destination
+const sendTimeout = 15 * time.Second
+
+// maxOutput hook output forwarded with an entry, longer output is truncated
+const maxOutput = 4096
+
+// forwarder states reported by the status API
+const (
+	StateRunning  = "running"
+	StateFailing  = "failing"
+	StateDisabled = "disabled"
+)
+
+// Record log entry as sent to a destination
+type Record struct {
+	Time     time.Time              `json:"time"`
+	Host     string                 `json:"host"`
+	Type     string                 `json:"type"`     // hook, system or user
+	Severity int                    `json:"severity"` // syslog severity, 3 error to 7 debug
+	Message  string                 `json:"message"`
+	Fields   map[string]interface{} `json:"fields"`
+}
+
+// sink destination of one forwarder type
+type sink interface {
+	send(ctx context.Context, records []Record) error
+	close()
+}
+
+// Status state of a forwarder on this instance
+type Status struct {
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	State       string     `json:"state"`
+	Sent        int64      `json:"sent"`
+	Failed      int64      `json:"failed"`  // entries dropped after the retries failed
+	Dropped     int64      `json:"dropped"` // entries dropped because the buffer was full
+	LastSentAt  *time.Time `json:"lastSentAt,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// forwarder ships the entries of one subscription to its sink
+type forwarder struct {
+	cfg  types.LogForwarderConfig
+	sink sink
+	sub  *database.LogSubscription
+
+	mu     sync.Mutex
+	status Status
+}
+
+var (
+	mu         sync.Mutex
+	forwarders []*forwarder
+	cancel     context.CancelFunc // stops the forwarders of the current configuration
+	hostname   = localHostname()
+)
+
+// Start run the configured forwarders, every instance forwards the entries it writes
+func Start(ctx context.Context) {
+	mu.Lock()
+	defer mu.Unlock()
+	startForwarders(ctx)
+}
+
+// Reload restart the forwarders with the current app configuration
+func Reload() {
+	mu.Lock()
+	defer mu.Unlock()
+	startForwarders(context.Background())
+}
+
+func startForwarders(parent context.Context) {
+	if cancel != nil {
+		cancel()
+	}
+	var ctx context.Context
+	ctx, cancel = context.WithCancel(parent)
+
+	forwarders = nil
+	for _, cfg := range configured() {
+		f := &forwarder{cfg: cfg, status: Status{Name: cfg.Name, Type: cfg.Type, State: StateRunning}}
+		forwarders = append(forwarders, f)
+		if cfg.Disabled {
+			f.status.State = StateDisabled
+			continue
+		}
+		f.sink = newSink(cfg)
+		buffer := cfg.BufferSize
+		if buffer <= 0 {
+			buffer = types.DefaultLogForwardBuffer
+		}
+		f.sub = database.SubscribeLogs(buffer)
+		go f.run(ctx)
+	}
+}
+
+func configured() []types.LogForwarderConfig {
+	if types.GoHookAppConfig == nil {
+		return nil
+	}
+	return types.GoHookAppConfig.LogForwarders
+}
+
+func newSink(cfg types.LogForwarderConfig) sink {
+	switch cfg.Type {
+	case types.LogForwarderSyslog:
+		return &syslogSink{cfg: cfg}
+	case types.LogForwarderSplunk:
+		return &splunkSink{httpSink{cfg: cfg, client: newHTTPClient(cfg)}}
+	default:
+		return &httpSink{cfg: cfg, client: newHTTPClient(cfg)}
+	}
+}
+
+// run collect the routed entries into batches until ctx is done
+func (f *forwarder) run(ctx context.Context) {
+	defer f.sub.Close()
+	defer f.sink.close()
+
+	batchSize := f.cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = types.DefaultLogForwardBatch
+	}
+	ticker := time.NewTicker(f.cfg.Flush())
+	defer ticker.Stop()
+
+	var batch []Record
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-f.sub.C:
+			if !Routed(f.cfg.Categories, e) {
+				continue
+			}
+			batch = append(batch, NewRecord(e))
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		if dropped := f.sub.Dropped(); dropped > 0 {
+			f.mu.Lock()
+			f.status.Dropped += dropped
+			f.mu.Unlock()
+		}
+		if len(batch) > 0 {
+			f.flush(ctx, batch)
+			batch = nil
+		}
+	}
+}
+
+// flush send batch, retrying with a growing delay, new entries wait in the subscription buffer
+func (f *forwarder) flush(ctx context.Context, batch []Record) {
+	retries := f.cfg.MaxRetries
+	if retries == 0 {
+		retries = types.DefaultLogForwardRetries
+	}
+	backoff := minBackoff
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+		}
+		sendCtx, cancelSend := context.WithTimeout(ctx, sendTimeout)
+		err = f.sink.send(sendCtx, batch)
+		cancelSend()
+		if err == nil {
+			now := time.Now()
+			f.mu.Lock()
+			f.status.Sent += int64(len(batch))
+			f.status.LastSentAt = &now
+			f.status.State = StateRunning
+			f.mu.Unlock()
+			return
+		}
+		f.setError(err)
+	}
+	log.Printf("logforward: %s dropped %d entries: %v", f.cfg.Name, len(batch), err)
+	f.mu.Lock()
+	f.status.Failed += int64(len(batch))
+	f.mu.Unlock()
+}
+
+func (f *forwarder) setError(err error) {
+	now := time.Now()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status.State = StateFailing
+	f.status.LastError = err.Error()
+	f.status.LastErrorAt = &now
+}
+
+// Statuses state of every configured forwarder
+func Statuses() []Status {
+	mu.Lock()
+	defer mu.Unlock()
+	list := []Status{}
+	for _, f := range forwarders {
+		f.mu.Lock()
+		list = append(list, f.status)
+		f.mu.Unlock()
+	}
+	return list
+}
+
+// HandleListForwarders list the configured log forwarders and their counters
+func HandleListForwarders(c *gin.Context) {
+	c.JSON(http.StatusOK, Statuses())
+}
+
+// Routed reports whether e belongs to one of categories: hook, system, user or
+// system:<CATEGORY> for the system logs of one category. No categories routes everything.
+func Routed(categories []string, e database.LogEvent) bool {
+	if len(categories) == 0 {
+		return true
+	}
+	for _, c := range categories {
+		kind, category, scoped := strings.Cut(c, ":")
+		if kind != e.Type {
+			continue
+		}
+		if !scoped || e.System != nil && strings.EqualFold(e.System.Category, category) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewRecord the fields of e forwarded to a destination. Request bodies and headers of hook
+// logs are left out, they may carry secrets.
+func NewRecord(e database.LogEvent) Record {
+	r := Record{Time: e.CreatedAt(), Host: hostname, Type: e.Type, Severity: 6}
+	switch {
+	case e.Hook != nil:
+		revealed := e.Hook.Revealed(false)
+		l := &revealed
+		output := l.Output
+		if len(output) > maxOutput {
+			output = output[:maxOutput]
+		}
+		r.Message = fmt.Sprintf("%s %s executed, success=%t", l.HookType, l.HookID, l.Success)
+		if !l.Success {
+			r.Severity = 4
+		}
+		r.Fields = map[string]interface{}{
+			"id": l.ID, "hook_id": l.HookID, "hook_name": l.HookName, "hook_type": l.HookType,
+			"method": l.Method, "remote_addr": l.RemoteAddr, "success": l.Success, "output": output,
+			"error": l.Error, "duration_ms": l.Duration, "user_agent": l.UserAgent, "namespace": l.Namespace,
+		}
+	case e.System != nil:
+		l := e.System
+		r.Message = l.Message
+		r.Severity = levelSeverity(l.Level)
+		r.Fields = map[string]interface{}{
+			"id": l.ID, "level": l.Level, "category": l.Category, "details": l.Details,
+			"user_id": l.UserID, "ip_address": l.IPAddress, "namespace": l.Namespace,
+		}
+	case e.User != nil:
+		a := e.User
+		r.Message = fmt.Sprintf("%s %s %s, success=%t", a.Username, a.Action, a.Resource, a.Success)
+		r.Severity = 5
+		if !a.Success {
+			r.Severity = 4
+		}
+		r.Fields = map[string]interface{}{
+			"id": a.ID, "username": a.Username, "action": a.Action, "resource": a.Resource,
+			"description": a.Description, "ip_address": a.IPAddress, "user_agent": a.UserAgent,
+			"success": a.Success, "details": a.Details, "namespace": a.Namespace,
+		}
+	}
+	return r
+}
+
+// levelSeverity syslog severity of a system log level
+func levelSeverity(level string) int {
+	switch strings.ToUpper(level) {
+	case "ERROR":
+		return 3
+	case "WARN", "WARNING":
+		return 4
+	case "DEBUG":
+		return 7
+	}
+	return 6
+}
+
+func localHostname() string {
+	if name, err := os.Hostname(); err == nil && name != "" {
+		return name
+	}
+	return "gohook"
+}
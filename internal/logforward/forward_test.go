@@ -0,0 +1,163 @@
+package logforward
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+var (
+	hookEvent   = database.LogEvent{Type: database.LogEventHook, Hook: &database.HookLog{HookID: "deploy", HookType: "webhook", Body: "secret body", Success: false}}
+	authEvent   = database.LogEvent{Type: database.LogEventSystem, System: &database.SystemLog{Level: "ERROR", Category: "AUTH", Message: "login failed"}}
+	configEvent = database.LogEvent{Type: database.LogEventSystem, System: &database.SystemLog{Level: "INFO", Category: "CONFIG", Message: "reloaded"}}
+	userEvent   = database.LogEvent{Type: database.LogEventUser, User: &database.UserActivity{Username: "admin", Action: "DELETE_HOOK", Success: true}}
+)
+
+func TestRouted(t *testing.T) {
+	tests := []struct {
+		categories []string
+		want       string // routed events of hook, auth, config, user
+	}{
+		{nil, "1111"},
+		{[]string{"hook"}, "1000"},
+		{[]string{"system"}, "0110"},
+		{[]string{"system:auth", "user"}, "0101"},
+	}
+	for _, tt := range tests {
+		got := ""
+		for _, e := range []database.LogEvent{hookEvent, authEvent, configEvent, userEvent} {
+			if Routed(tt.categories, e) {
+				got += "1"
+			} else {
+				got += "0"
+			}
+		}
+		if got != tt.want {
+			t.Errorf("Routed(%v) = %s, want %s", tt.categories, got, tt.want)
+		}
+	}
+}
+
+func TestNewRecord(t *testing.T) {
+	r := NewRecord(hookEvent)
+	data, _ := json.Marshal(r)
+	if r.Severity != 4 || r.Fields["hook_id"] != "deploy" || strings.Contains(string(data), "secret body") {
+		t.Errorf("hook record = %s", data)
+	}
+	if r := NewRecord(authEvent); r.Severity != 3 || r.Message != "login failed" {
+		t.Errorf("system record = %+v", r)
+	}
+	if r := NewRecord(userEvent); r.Severity != 5 || r.Fields["action"] != "DELETE_HOOK" {
+		t.Errorf("user record = %+v", r)
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for i := 0; i < 2; i++ {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(reader, msg); err != nil {
+				return
+			}
+			lines <- string(msg)
+		}
+	}()
+
+	s := &syslogSink{cfg: types.LogForwarderConfig{Type: types.LogForwarderSyslog, Network: "tcp", Address: ln.Addr().String()}}
+	defer s.close()
+	if err := s.send(context.Background(), []Record{NewRecord(authEvent), NewRecord(userEvent)}); err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range []string{"<131>1 ", "<133>1 "} {
+		select {
+		case line := <-lines:
+			if !strings.HasPrefix(line, prefix) || !strings.Contains(line, " gohook ") || !strings.HasSuffix(line, "}") {
+				t.Errorf("syslog message %q, want prefix %q", line, prefix)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no syslog message received")
+		}
+	}
+}
+
+func TestSplunkSink(t *testing.T) {
+	var auth, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer srv.Close()
+
+	cfg := types.LogForwarderConfig{Type: types.LogForwarderSplunk, URL: srv.URL, Token: "hec", Index: "ops"}
+	s := newSink(cfg)
+	defer s.close()
+	if err := s.send(context.Background(), []Record{NewRecord(hookEvent), NewRecord(userEvent)}); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Splunk hec" || strings.Count(body, `"index":"ops"`) != 2 || !strings.Contains(body, `"sourcetype":"gohook:user"`) {
+		t.Errorf("splunk request %q: %s", auth, body)
+	}
+}
+
+func TestForwarderRetries(t *testing.T) {
+	var calls atomic.Int32
+	received := make(chan []Record, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var records []Record
+		json.NewDecoder(r.Body).Decode(&records)
+		received <- records
+	}))
+	defer srv.Close()
+
+	cfg := types.LogForwarderConfig{Name: "siem", Type: types.LogForwarderHTTP, URL: srv.URL}
+	f := &forwarder{cfg: cfg, sink: newSink(cfg), status: Status{Name: "siem"}}
+	defer f.sink.close()
+	f.flush(context.Background(), []Record{NewRecord(userEvent)})
+	select {
+	case records := <-received:
+		if len(records) != 1 || records[0].Type != "user" {
+			t.Errorf("received %+v", records)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch not resent")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status.Sent != 1 || f.status.LastError == "" || f.status.State != StateRunning {
+		t.Errorf("status = %+v", f.status)
+	}
+}
@@ -0,0 +1,102 @@
+package logforward
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// httpSink POST of every batch as a JSON array of records
+type httpSink struct {
+	cfg    types.LogForwarderConfig
+	client *http.Client
+}
+
+func newHTTPClient(cfg types.LogForwarderConfig) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{Transport: transport, Timeout: sendTimeout}
+}
+
+func (s *httpSink) send(ctx context.Context, records []Record) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	auth := ""
+	if s.cfg.Token != "" {
+		auth = "Bearer " + s.cfg.Token
+	}
+	return s.post(ctx, body, auth)
+}
+
+// post send body to the configured URL, any status outside 2xx is an error
+func (s *httpSink) post(ctx context.Context, body []byte, auth string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s answered %d: %s", s.cfg.URL, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func (s *httpSink) close() {
+	s.client.CloseIdleConnections()
+}
+
+// splunkSink Splunk HTTP Event Collector, every record is one event of the batch
+type splunkSink struct {
+	httpSink
+}
+
+// splunkEvent event envelope of the HTTP Event Collector
+type splunkEvent struct {
+	Time       float64 `json:"time"`
+	Host       string  `json:"host"`
+	Source     string  `json:"source"`
+	Sourcetype string  `json:"sourcetype"`
+	Index      string  `json:"index,omitempty"`
+	Event      Record  `json:"event"`
+}
+
+func (s *splunkSink) send(ctx context.Context, records []Record) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, r := range records {
+		event := splunkEvent{
+			Time:       float64(r.Time.UnixMilli()) / 1000,
+			Host:       r.Host,
+			Source:     syslogAppName,
+			Sourcetype: "gohook:" + r.Type,
+			Index:      s.cfg.Index,
+			Event:      r,
+		}
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+	return s.post(ctx, body.Bytes(), "Splunk "+s.cfg.Token)
+}
@@ -0,0 +1,87 @@
+package logforward
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// syslogFacility local0, the facility of every message
+const syslogFacility = 16
+
+// syslogAppName APP-NAME of the messages
+const syslogAppName = "gohook"
+
+// syslogSink RFC 5424 messages over UDP, or TCP and TLS with octet counting framing (RFC 6587)
+type syslogSink struct {
+	cfg  types.LogForwarderConfig
+	conn net.Conn
+}
+
+func (s *syslogSink) send(ctx context.Context, records []Record) error {
+	if s.conn == nil {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+	stream := s.cfg.Network == "tcp" || s.cfg.Network == "tls"
+	for _, r := range records {
+		msg, err := formatSyslog(r)
+		if err != nil {
+			return err
+		}
+		if stream {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+		if _, err := s.conn.Write(msg); err != nil {
+			// reconnect on the next attempt, the batch is sent again from its start
+			s.close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syslogSink) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: sendTimeout}
+	switch s.cfg.Network {
+	case "tls":
+		host, _, _ := net.SplitHostPort(s.cfg.Address)
+		td := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host, InsecureSkipVerify: s.cfg.InsecureSkipVerify}}
+		return td.DialContext(ctx, "tcp", s.cfg.Address)
+	case "tcp":
+		return dialer.DialContext(ctx, "tcp", s.cfg.Address)
+	default:
+		return dialer.DialContext(ctx, "udp", s.cfg.Address)
+	}
+}
+
+func (s *syslogSink) close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// formatSyslog RFC 5424 message of r, the MSG part is the record as JSON
+func formatSyslog(r Record) ([]byte, error) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf("<%d>1 %s %s %s %d %s - ",
+		syslogFacility*8+r.Severity, r.Time.UTC().Format(time.RFC3339Nano), r.Host, syslogAppName, os.Getpid(), r.Type)
+	return append([]byte(header), body...), nil
+}
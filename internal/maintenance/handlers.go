@@ -0,0 +1,150 @@
+package maintenance
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/config"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+type statusResponse struct {
+	types.MaintenanceConfig
+	Active bool  `json:"active"`
+	Queued int64 `json:"queued"`
+}
+
+func logAction(c *gin.Context, action, description string, success bool, details interface{}) {
+	username, _ := c.Get("username")
+	usernameStr, _ := username.(string)
+	database.LogUserAction(
+		usernameStr,
+		action,
+		"maintenance",
+		description,
+		middleware.GetClientIP(c),
+		c.GetHeader("User-Agent"),
+		success,
+		details,
+	)
+}
+
+// QueuedCount number of deliveries waiting in the queue
+func QueuedCount() int64 {
+	var count int64
+	if db := database.GetDB(); db != nil {
+		db.Model(&database.QueuedDelivery{}).Count(&count)
+	}
+	return count
+}
+
+// HandleGetMaintenance return the maintenance mode config and queue size
+func HandleGetMaintenance(c *gin.Context) {
+	resp := statusResponse{Queued: QueuedCount()}
+	cfg, active := globalActive(time.Now())
+	if cfg != nil {
+		resp.MaintenanceConfig = *cfg
+	}
+	resp.Active = active
+	c.JSON(http.StatusOK, resp)
+}
+
+// HandleUpdateMaintenance enable or disable global maintenance mode
+func HandleUpdateMaintenance(c *gin.Context) {
+	var req types.MaintenanceConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if req.Until != "" {
+		if _, err := time.Parse(time.RFC3339, req.Until); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidUntil, nil)
+			return
+		}
+	}
+	if req.RejectStatus != 0 && (req.RejectStatus < 400 || req.RejectStatus > 599) {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRejectStatus, nil)
+		return
+	}
+	if types.GoHookAppConfig == nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeAppConfigNotLoaded, nil)
+		return
+	}
+
+	types.GoHookAppConfig.Maintenance = &req
+	if err := config.SaveAppConfig(); err != nil {
+		logAction(c, database.UserActionUpdateMaintenance, "update maintenance mode", false, err.Error())
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
+		return
+	}
+	logAction(c, database.UserActionUpdateMaintenance, fmt.Sprintf("set maintenance mode enabled=%t", req.Enabled), true, req)
+	HandleGetMaintenance(c)
+}
+
+// HandleListQueue list queued deliveries, oldest first
+func HandleListQueue(c *gin.Context) {
+	db := database.GetDB()
+	if db == nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDatabaseUnavailable, nil)
+		return
+	}
+	query := db.Model(&database.QueuedDelivery{}).Order("id ASC")
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if target := c.Query("target"); target != "" {
+		query = query.Where("target = ?", target)
+	}
+	var deliveries []database.QueuedDelivery
+	if err := query.Limit(500).Find(&deliveries).Error; err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListQueueFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// HandleFlushQueue replay queued deliveries now.
+// Deliveries of targets that are still paused are skipped unless force=true.
+func HandleFlushQueue(c *gin.Context) {
+	opts := FlushOptions{
+		Kind:   c.Query("kind"),
+		Target: c.Query("target"),
+		Force:  c.Query("force") == "true",
+	}
+	result, err := Flush(c.Request.Context(), opts)
+	if err != nil {
+		logAction(c, database.UserActionFlushQueue, "flush delivery queue", false, err.Error())
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeFlushQueueFailed, err)
+		return
+	}
+	logAction(c, database.UserActionFlushQueue, fmt.Sprintf("flush delivery queue: replayed %d, failed %d", result.Replayed, result.Failed), result.Failed == 0, opts)
+	c.JSON(http.StatusOK, result)
+}
+
+// HandleDiscardQueue drop queued deliveries without executing them
+func HandleDiscardQueue(c *gin.Context) {
+	db := database.GetDB()
+	if db == nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDatabaseUnavailable, nil)
+		return
+	}
+	query := db.Unscoped().Where("1 = 1")
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if target := c.Query("target"); target != "" {
+		query = query.Where("target = ?", target)
+	}
+	res := query.Delete(&database.QueuedDelivery{})
+	if res.Error != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDiscardQueueFailed, res.Error)
+		return
+	}
+	logAction(c, database.UserActionDiscardQueue, fmt.Sprintf("discard %d queued deliveries", res.RowsAffected), true, nil)
+	c.JSON(http.StatusOK, gin.H{"deleted": res.RowsAffected})
+}
@@ -0,0 +1,223 @@
+// Package maintenance holds back webhook deliveries during global maintenance
+// mode or per hook/project pause windows, and replays them once execution resumes.
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// delivery kinds
+const (
+	KindHook    = "hook"
+	KindGitHook = "githook"
+)
+
+// autoFlushInterval how often queued deliveries of resumed targets are replayed
+const autoFlushInterval = 30 * time.Second
+
+// autoFlushMaxAttempts automatic replays give up after this many failures, a manual flush still retries
+const autoFlushMaxAttempts = 3
+
+// Handler connects a delivery kind to its pause windows and replay logic
+type Handler struct {
+	// Windows returns the pause windows configured for target
+	Windows func(target string) []types.PauseWindow
+	// Replay executes a queued delivery
+	Replay func(d *database.QueuedDelivery) error
+	// Hold optionally holds back deliveries for target outside pause windows, e.g. over a quota
+	Hold func(target string) Decision
+}
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]Handler{}
+	flushMu    sync.Mutex
+)
+
+// Register install the handler for a delivery kind
+func Register(kind string, h Handler) {
+	handlersMu.Lock()
+	handlers[kind] = h
+	handlersMu.Unlock()
+}
+
+func handlerFor(kind string) (Handler, bool) {
+	handlersMu.RLock()
+	h, ok := handlers[kind]
+	handlersMu.RUnlock()
+	return h, ok
+}
+
+// Decision result of a pause check
+type Decision struct {
+	Paused       bool
+	RejectStatus int // 0: queue the delivery
+	Reason       string
+}
+
+// globalActive report whether global maintenance mode is on at now
+func globalActive(now time.Time) (*types.MaintenanceConfig, bool) {
+	if types.GoHookAppConfig == nil || types.GoHookAppConfig.Maintenance == nil {
+		return nil, false
+	}
+	cfg := types.GoHookAppConfig.Maintenance
+	if !cfg.Enabled {
+		return cfg, false
+	}
+	if cfg.Until != "" {
+		if until, err := time.Parse(time.RFC3339, cfg.Until); err == nil && !now.Before(until) {
+			return cfg, false
+		}
+	}
+	return cfg, true
+}
+
+// Check decide whether a delivery for kind/target must be held back now
+func Check(kind, target string) Decision {
+	now := time.Now()
+	if cfg, ok := globalActive(now); ok {
+		reason := "maintenance mode"
+		if cfg.Message != "" {
+			reason = cfg.Message
+		}
+		return Decision{Paused: true, RejectStatus: cfg.RejectStatus, Reason: reason}
+	}
+	h, ok := handlerFor(kind)
+	if !ok {
+		return Decision{}
+	}
+	if h.Windows != nil {
+		if w, ok := activeWindow(h.Windows(target), now); ok {
+			return Decision{Paused: true, RejectStatus: w.RejectStatus, Reason: fmt.Sprintf("paused %s-%s", w.Start, w.End)}
+		}
+	}
+	if h.Hold != nil {
+		return h.Hold(target)
+	}
+	return Decision{}
+}
+
+// Enqueue store a delivery for later replay
+func Enqueue(d *database.QueuedDelivery) error {
+	db := database.GetDB()
+	if db == nil {
+		return errors.New("database not initialized")
+	}
+	if err := db.Create(d).Error; err != nil {
+		return err
+	}
+	log.Printf("[%s] %s delivery for %s queued: %s", d.RequestID, d.Kind, d.Target, d.Reason)
+	return nil
+}
+
+// EncodeJSON marshal a parsed request part for QueuedDelivery, empty on failure
+func EncodeJSON(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// FlushOptions select deliveries to replay
+type FlushOptions struct {
+	Kind   string
+	Target string
+	// Force replays even while the target is still paused
+	Force bool
+}
+
+// FlushResult summary of a flush run
+type FlushResult struct {
+	Replayed int      `json:"replayed"`
+	Failed   int      `json:"failed"`
+	Skipped  int      `json:"skipped"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// Flush replay queued deliveries oldest first
+func Flush(ctx context.Context, opts FlushOptions) (FlushResult, error) {
+	flushMu.Lock()
+	defer flushMu.Unlock()
+
+	var result FlushResult
+	db := database.GetDB()
+	if db == nil {
+		return result, errors.New("database not initialized")
+	}
+
+	query := db.WithContext(ctx).Order("id ASC")
+	if opts.Kind != "" {
+		query = query.Where("kind = ?", opts.Kind)
+	}
+	if opts.Target != "" {
+		query = query.Where("target = ?", opts.Target)
+	}
+	if !opts.Force {
+		query = query.Where("attempts < ?", autoFlushMaxAttempts)
+	}
+	var deliveries []database.QueuedDelivery
+	if err := query.Find(&deliveries).Error; err != nil {
+		return result, err
+	}
+
+	for i := range deliveries {
+		d := &deliveries[i]
+		h, ok := handlerFor(d.Kind)
+		if !ok || h.Replay == nil {
+			result.Skipped++
+			continue
+		}
+		if !opts.Force && Check(d.Kind, d.Target).Paused {
+			result.Skipped++
+			continue
+		}
+
+		if err := h.Replay(d); err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, fmt.Sprintf("#%d %s %s: %v", d.ID, d.Kind, d.Target, err))
+			d.Attempts++
+			d.LastError = err.Error()
+			_ = db.WithContext(ctx).Model(d).Updates(map[string]interface{}{"attempts": d.Attempts, "last_error": d.LastError}).Error
+			continue
+		}
+		result.Replayed++
+		_ = db.WithContext(ctx).Unscoped().Delete(d).Error
+	}
+	return result, nil
+}
+
+// StartAutoFlush replay queued deliveries once maintenance ends or pause windows close
+func StartAutoFlush(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(autoFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, paused := globalActive(time.Now()); paused {
+					continue
+				}
+				if res, err := Flush(ctx, FlushOptions{}); err != nil {
+					log.Printf("maintenance: auto flush failed: %v", err)
+				} else if res.Replayed > 0 || res.Failed > 0 {
+					log.Printf("maintenance: replayed %d queued deliveries, %d failed", res.Replayed, res.Failed)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,104 @@
+package maintenance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseClock parse HH:MM into minutes since midnight
+func parseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(v))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ValidateWindows check pause window definitions
+func ValidateWindows(windows []types.PauseWindow) error {
+	for _, w := range windows {
+		if _, err := parseClock(w.Start); err != nil {
+			return err
+		}
+		if _, err := parseClock(w.End); err != nil {
+			return err
+		}
+		for _, d := range w.Days {
+			if _, ok := weekdays[strings.ToLower(strings.TrimSpace(d))]; !ok {
+				return fmt.Errorf("invalid day %q, expected mon..sun", d)
+			}
+		}
+		if w.RejectStatus != 0 && (w.RejectStatus < 400 || w.RejectStatus > 599) {
+			return fmt.Errorf("invalid reject status %d", w.RejectStatus)
+		}
+	}
+	return nil
+}
+
+// windowContains report whether now falls inside the window.
+// A window whose end is before its start spans midnight and belongs to the day it starts on.
+func windowContains(w types.PauseWindow, now time.Time) bool {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+
+	day := now.Weekday()
+	switch {
+	case start == end:
+		// whole day
+	case start < end:
+		if minute < start || minute >= end {
+			return false
+		}
+	default:
+		if minute < start && minute >= end {
+			return false
+		}
+		if minute < end {
+			// early morning part of a window that started the previous day
+			day = (day + 6) % 7
+		}
+	}
+	return dayMatches(w.Days, day)
+}
+
+func dayMatches(days []string, day time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if weekdays[strings.ToLower(strings.TrimSpace(d))] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// activeWindow return the first window containing now
+func activeWindow(windows []types.PauseWindow, now time.Time) (types.PauseWindow, bool) {
+	for _, w := range windows {
+		if windowContains(w, now) {
+			return w, true
+		}
+	}
+	return types.PauseWindow{}, false
+}
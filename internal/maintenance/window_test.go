@@ -0,0 +1,68 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestWindowContains(t *testing.T) {
+	// 2024-01-06 is a Saturday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name   string
+		window types.PauseWindow
+		now    time.Time
+		want   bool
+	}{
+		{"inside same-day window", types.PauseWindow{Start: "09:00", End: "17:00"}, at(6, 12, 0), true},
+		{"end is exclusive", types.PauseWindow{Start: "09:00", End: "17:00"}, at(6, 17, 0), false},
+		{"day filter", types.PauseWindow{Days: []string{"mon"}, Start: "09:00", End: "17:00"}, at(6, 12, 0), false},
+		{"overnight before midnight", types.PauseWindow{Days: []string{"sat"}, Start: "22:00", End: "06:00"}, at(6, 23, 30), true},
+		{"overnight after midnight belongs to start day", types.PauseWindow{Days: []string{"sat"}, Start: "22:00", End: "06:00"}, at(7, 5, 59), true},
+		{"overnight after midnight of other day", types.PauseWindow{Days: []string{"sun"}, Start: "22:00", End: "06:00"}, at(7, 5, 0), false},
+		{"outside overnight", types.PauseWindow{Start: "22:00", End: "06:00"}, at(6, 12, 0), false},
+		{"start equals end is whole day", types.PauseWindow{Days: []string{"Sat"}, Start: "00:00", End: "00:00"}, at(6, 3, 0), true},
+	}
+	for _, tt := range tests {
+		if got := windowContains(tt.window, tt.now); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateWindows(t *testing.T) {
+	if err := ValidateWindows([]types.PauseWindow{{Days: []string{"mon", "fri"}, Start: "22:00", End: "06:00", RejectStatus: 503}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bad := []types.PauseWindow{
+		{Start: "25:00", End: "06:00"},
+		{Start: "22:00", End: "6"},
+		{Days: []string{"funday"}, Start: "22:00", End: "06:00"},
+		{Start: "22:00", End: "06:00", RejectStatus: 200},
+	}
+	for _, w := range bad {
+		if err := ValidateWindows([]types.PauseWindow{w}); err == nil {
+			t.Errorf("expected error for %+v", w)
+		}
+	}
+}
+
+func TestCheckGlobalMaintenance(t *testing.T) {
+	saved := types.GoHookAppConfig
+	defer func() { types.GoHookAppConfig = saved }()
+
+	types.GoHookAppConfig = &types.AppConfig{Maintenance: &types.MaintenanceConfig{Enabled: true, RejectStatus: 503}}
+	if d := Check(KindHook, "any"); !d.Paused || d.RejectStatus != 503 {
+		t.Fatalf("expected paused with 503, got %+v", d)
+	}
+
+	types.GoHookAppConfig.Maintenance.Until = time.Now().Add(-time.Minute).Format(time.RFC3339)
+	if d := Check(KindHook, "any"); d.Paused {
+		t.Fatalf("expected maintenance to have ended, got %+v", d)
+	}
+}
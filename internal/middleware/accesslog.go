@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// RequestIDHeader header carrying the request ID, taken from the client when set
+const RequestIDHeader = "X-Request-ID"
+
+// AccessLogEntry one line of the access log
+type AccessLogEntry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"requestId"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Route     string    `json:"route,omitempty"` // matched route pattern, empty when no route matched
+	Status    int       `json:"status"`
+	LatencyMs float64   `json:"latencyMs"`
+	BytesIn   int64     `json:"bytesIn"`
+	BytesOut  int       `json:"bytesOut"`
+	ClientIP  string    `json:"clientIp"`
+	User      string    `json:"user,omitempty"`
+	UserAgent string    `json:"userAgent,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+var accessLog struct {
+	sync.Mutex
+	cfg *types.AccessLogConfig
+	out io.Writer
+	// file is closed when the output changes
+	file *os.File
+}
+
+// ConfigureAccessLog start, change or stop the access log, nil or disabled stops it
+func ConfigureAccessLog(cfg *types.AccessLogConfig) error {
+	var (
+		out  io.Writer
+		file *os.File
+	)
+	if cfg != nil && cfg.Enabled {
+		if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+			return fmt.Errorf("access log sample_rate must be between 0 and 1")
+		}
+		switch cfg.Output {
+		case "", "stdout":
+			out = os.Stdout
+		case "stderr":
+			out = os.Stderr
+		default:
+			f, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+			if err != nil {
+				return fmt.Errorf("open access log: %v", err)
+			}
+			out, file = f, f
+		}
+	} else {
+		cfg = nil
+	}
+
+	accessLog.Lock()
+	old := accessLog.file
+	accessLog.cfg, accessLog.out, accessLog.file = cfg, out, file
+	accessLog.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+func newRequestID() string {
+	return fmt.Sprintf("%016x", rand.Uint64())
+}
+
+// validRequestID accept short printable IDs from clients and proxies
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 100 {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestIDMiddleware set "request-id" for the handlers and answer it in X-Request-ID
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set("request-id", id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// countingBody count the request body bytes read by the handlers
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// shouldLog decide if a finished request is written to the access log
+func shouldLog(cfg *types.AccessLogConfig, path string, status int, latency time.Duration) bool {
+	for _, prefix := range cfg.SkipPaths {
+		if prefix != "" && strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	if status >= 400 || (cfg.SlowMs > 0 && latency >= time.Duration(cfg.SlowMs)*time.Millisecond) {
+		return true
+	}
+	return cfg.SampleRate <= 0 || cfg.SampleRate >= 1 || rand.Float64() < cfg.SampleRate
+}
+
+// AccessLogMiddleware write method, path, status, latency, sizes, user and request ID
+// of every request to the access log once it is configured
+func AccessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accessLog.Lock()
+		enabled := accessLog.cfg != nil
+		accessLog.Unlock()
+		if !enabled {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		var body *countingBody
+		if c.Request.Body != nil {
+			body = &countingBody{ReadCloser: c.Request.Body}
+			c.Request.Body = body
+		}
+		path := c.Request.URL.Path
+
+		c.Next()
+
+		latency := time.Since(start)
+		accessLog.Lock()
+		defer accessLog.Unlock()
+		cfg := accessLog.cfg
+		if cfg == nil || !shouldLog(cfg, path, c.Writer.Status(), latency) {
+			return
+		}
+
+		entry := AccessLogEntry{
+			Time:      start,
+			RequestID: c.GetString("request-id"),
+			Method:    c.Request.Method,
+			Path:      path,
+			Route:     c.FullPath(),
+			Status:    c.Writer.Status(),
+			LatencyMs: float64(latency.Microseconds()) / 1000,
+			BytesIn:   c.Request.ContentLength,
+			BytesOut:  c.Writer.Size(),
+			ClientIP:  GetClientIP(c),
+			User:      c.GetString("username"),
+			UserAgent: c.Request.UserAgent(),
+			Error:     c.Errors.ByType(gin.ErrorTypePrivate).String(),
+		}
+		if entry.BytesIn < 0 && body != nil {
+			entry.BytesIn = body.n
+		}
+		if entry.BytesIn < 0 {
+			entry.BytesIn = 0
+		}
+		if entry.BytesOut < 0 {
+			entry.BytesOut = 0
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		accessLog.out.Write(append(line, '\n'))
+	}
+}
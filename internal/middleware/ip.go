@@ -1,100 +1,148 @@
 package middleware
 
 import (
+	"fmt"
 	"net"
 	"strings"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetRealIP get real client IP address, support proxy environment
-// priority: X-Forwarded-For > X-Real-IP > X-Client-IP > RemoteAddr
-func GetRealIP(c *gin.Context) string {
-	// get all possible IP headers
-	xForwardedFor := c.GetHeader("X-Forwarded-For")
-	xRealIP := c.GetHeader("X-Real-IP")
-	xClientIP := c.GetHeader("X-Client-IP")
-	cfConnectingIP := c.GetHeader("CF-Connecting-IP") // Cloudflare
-	trueClientIP := c.GetHeader("True-Client-IP")     // Akamai
+// DefaultTrustedProxies proxies whose forwarding headers are honored when none are configured:
+// loopback and private networks, where reverse proxies and container gateways usually live
+var DefaultTrustedProxies = []string{
+	"127.0.0.0/8", "::1/128",
+	"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7",
+}
 
-	// 1. first check X-Forwarded-For
-	if xForwardedFor != "" {
-		// X-Forwarded-For may contain multiple IPs, format: client, proxy1, proxy2
-		ips := strings.Split(xForwardedFor, ",")
-		for _, ip := range ips {
-			ip = strings.TrimSpace(ip)
-			if isValidIP(ip) && !isPrivateIP(ip) {
-				return ip
-			}
-		}
-	}
+// remoteIPHeaders headers carrying the client address set by a trusted proxy, in priority order
+var remoteIPHeaders = []string{
+	"X-Forwarded-For",
+	"CF-Connecting-IP", // Cloudflare
+	"True-Client-IP",   // Akamai
+	"X-Real-IP",
+	"X-Client-IP",
+}
 
-	// 2. check CF-Connecting-IP (Cloudflare)
-	if cfConnectingIP != "" && isValidIP(cfConnectingIP) {
-		return cfConnectingIP
-	}
+var (
+	trustedMu   sync.RWMutex
+	trustedNets = mustParseProxies(DefaultTrustedProxies)
+)
 
-	// 3. check True-Client-IP (Akamai)
-	if trueClientIP != "" && isValidIP(trueClientIP) {
-		return trueClientIP
+// parseProxies parse CIDRs and single IP addresses
+func parseProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", p)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			p = fmt.Sprintf("%s/%d", p, bits)
+		}
+		_, ipNet, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", p)
+		}
+		nets = append(nets, ipNet)
 	}
+	return nets, nil
+}
 
-	// 4. check X-Real-IP
-	if xRealIP != "" && isValidIP(xRealIP) {
-		return xRealIP
+func mustParseProxies(proxies []string) []*net.IPNet {
+	nets, err := parseProxies(proxies)
+	if err != nil {
+		panic(err)
 	}
+	return nets
+}
 
-	// 5. check X-Client-IP
-	if xClientIP != "" && isValidIP(xClientIP) {
-		return xClientIP
+// SetTrustedProxies honor the client address headers only on requests from these CIDRs or
+// addresses, empty restores DefaultTrustedProxies. gin's ClientIP follows the same list
+// when r is given; r must not be serving requests yet.
+func SetTrustedProxies(r *gin.Engine, proxies []string) error {
+	if len(proxies) == 0 {
+		proxies = DefaultTrustedProxies
 	}
-
-	// 6. if none of the above, use Gin's ClientIP() method
-	clientIP := c.ClientIP()
-	if clientIP != "" {
-		return clientIP
+	nets, err := parseProxies(proxies)
+	if err != nil {
+		return err
 	}
-
-	// 7. finally fallback to RemoteAddr
-	if c.Request.RemoteAddr != "" {
-		host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
-		if err == nil {
-			return host
+	if r != nil {
+		if err := r.SetTrustedProxies(proxies); err != nil {
+			return err
 		}
-		return c.Request.RemoteAddr
+		r.ForwardedByClientIP = true
+		r.RemoteIPHeaders = remoteIPHeaders
 	}
-
-	return "unknown"
+	trustedMu.Lock()
+	trustedNets = nets
+	trustedMu.Unlock()
+	return nil
 }
 
-// isValidIP check if IP is valid
-func isValidIP(ip string) bool {
-	return net.ParseIP(ip) != nil
+// isTrustedProxy check if ip is a trusted proxy
+func isTrustedProxy(ip net.IP) bool {
+	trustedMu.RLock()
+	defer trustedMu.RUnlock()
+	for _, n := range trustedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
 }
 
-// isPrivateIP check if IP is private
-func isPrivateIP(ip string) bool {
-	parsedIP := net.ParseIP(ip)
-	if parsedIP == nil {
-		return false
+// headerClientIP client address in a header set by a trusted proxy. A forwarded list is read
+// from the right, skipping trusted proxies, so addresses prepended by the client are ignored.
+func headerClientIP(value string) (string, bool) {
+	if value == "" {
+		return "", false
 	}
+	items := strings.Split(value, ",")
+	for i := len(items) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(items[i]))
+		if ip == nil {
+			return "", false
+		}
+		if i == 0 || !isTrustedProxy(ip) {
+			return ip.String(), true
+		}
+	}
+	return "", false
+}
 
-	// check if IP is private
-	privateIPBlocks := []*net.IPNet{
-		{IP: net.IPv4(10, 0, 0, 0), Mask: net.CIDRMask(8, 32)},     // 10.0.0.0/8
-		{IP: net.IPv4(172, 16, 0, 0), Mask: net.CIDRMask(12, 32)},  // 172.16.0.0/12
-		{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(16, 32)}, // 192.168.0.0/16
-		{IP: net.IPv4(127, 0, 0, 0), Mask: net.CIDRMask(8, 32)},    // 127.0.0.0/8 (loopback)
-		{IP: net.IPv4(169, 254, 0, 0), Mask: net.CIDRMask(16, 32)}, // 169.254.0.0/16 (link-local)
+// GetRealIP get real client IP address, support proxy environment.
+// The headers X-Forwarded-For > CF-Connecting-IP > True-Client-IP > X-Real-IP > X-Client-IP
+// are only honored when the request comes from a trusted proxy, see SetTrustedProxies.
+func GetRealIP(c *gin.Context) string {
+	remoteAddr := c.Request.RemoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		remoteAddr = host
 	}
+	remoteIP := net.ParseIP(remoteAddr)
 
-	for _, block := range privateIPBlocks {
-		if block.Contains(parsedIP) {
-			return true
+	// requests on a unix socket have no address, only local proxies can send them
+	if remoteIP == nil || isTrustedProxy(remoteIP) {
+		for _, header := range remoteIPHeaders {
+			if ip, ok := headerClientIP(c.GetHeader(header)); ok {
+				return ip
+			}
 		}
 	}
-
-	return false
+	if remoteIP != nil {
+		return remoteIP.String()
+	}
+	if remoteAddr != "" {
+		return remoteAddr
+	}
+	return "unknown"
 }
 
 // IPMiddleware Gin middleware, set real IP to context
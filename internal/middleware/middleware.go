@@ -6,6 +6,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/mycoool/gohook/internal/client"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/types"
 )
 
 // wsAuthMiddleware WebSocket auth middleware, support query parameter token and Sec-WebSocket-Protocol header
@@ -36,14 +39,14 @@ func WsAuthMiddleware() gin.HandlerFunc {
 		}
 
 		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing token"})
+			i18n.JSONError(c, http.StatusUnauthorized, i18n.CodeMissingToken, nil)
 			c.Abort()
 			return
 		}
 
 		claims, err := client.ValidateToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			i18n.JSONError(c, http.StatusUnauthorized, i18n.CodeInvalidToken, nil)
 			c.Abort()
 			return
 		}
@@ -54,16 +57,50 @@ func WsAuthMiddleware() gin.HandlerFunc {
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
 		c.Set("token", tokenString)
+		c.Set("decrypt_logs", client.CanDecryptLogs(claims.Username)) // sealed stream messages are sent in full
+		if !resolveNamespace(c, claims) {
+			return
+		}
 		c.Next()
 	}
 }
 
-// adminMiddleware admin permission middleware
+// resolveNamespace limit the request to the token's namespace, or to the one selected by
+// the /ns/:namespace path prefix or the X-GoHook-Namespace header for unrestricted tokens.
+// It aborts the request and returns false when the selection is not allowed.
+func resolveNamespace(c *gin.Context, claims *types.Claims) bool {
+	c.Set("token_namespace", claims.Namespace)
+
+	requested := c.Param("namespace")
+	if requested == "" {
+		requested = c.GetHeader(namespace.Header)
+	}
+
+	ns := requested
+	if claims.Namespace != "" {
+		if requested != "" && requested != claims.Namespace {
+			i18n.JSONError(c, http.StatusForbidden, i18n.CodeNamespaceDenied, nil)
+			c.Abort()
+			return false
+		}
+		ns = claims.Namespace
+	}
+	if ns != "" && !namespace.Exists(ns) {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeNamespaceNotFound, nil)
+		c.Abort()
+		return false
+	}
+
+	namespace.SetContext(c, ns)
+	return true
+}
+
+// adminMiddleware admin permission middleware, tokens limited to a namespace have no admin rights
 func AdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, exists := c.Get("role")
-		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		if !exists || role != "admin" || c.GetString("token_namespace") != "" {
+			i18n.JSONError(c, http.StatusForbidden, i18n.CodeAdminRequired, nil)
 			c.Abort()
 			return
 		}
@@ -76,14 +113,14 @@ func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := c.GetHeader("X-GoHook-Key")
 		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing token"})
+			i18n.JSONError(c, http.StatusUnauthorized, i18n.CodeMissingToken, nil)
 			c.Abort()
 			return
 		}
 
 		claims, err := client.ValidateToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			i18n.JSONError(c, http.StatusUnauthorized, i18n.CodeInvalidToken, nil)
 			c.Abort()
 			return
 		}
@@ -94,6 +131,9 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
 		c.Set("token", tokenString)
+		if !resolveNamespace(c, claims) {
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,169 @@
+// Package namespace isolates hooks, projects, users and their logs into tenants
+// so one instance can serve several teams.
+package namespace
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// resource kinds
+const (
+	KindHook    = "hook"
+	KindProject = "project"
+	KindUser    = "user"
+)
+
+// Header selects the namespace of a management request
+const Header = "X-GoHook-Namespace"
+
+// contextKey gin context key holding the resolved namespace, empty means all namespaces
+const contextKey = "namespace"
+
+var namePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Resource connects a resource kind to the namespaces its items belong to
+type Resource struct {
+	// Lookup returns the namespace of the named item and whether it exists
+	Lookup func(name string) (string, bool)
+	// Names lists the items that belong to ns
+	Names func(ns string) []string
+}
+
+var (
+	resourcesMu sync.RWMutex
+	resources   = map[string]Resource{}
+)
+
+// Register install the resource handler for a kind
+func Register(kind string, r Resource) {
+	resourcesMu.Lock()
+	resources[kind] = r
+	resourcesMu.Unlock()
+}
+
+func resourceFor(kind string) (Resource, bool) {
+	resourcesMu.RLock()
+	r, ok := resources[kind]
+	resourcesMu.RUnlock()
+	return r, ok
+}
+
+// Normalize map the empty namespace of a hook or project to DefaultNamespace
+func Normalize(ns string) string {
+	if ns == "" {
+		return types.DefaultNamespace
+	}
+	return ns
+}
+
+// ValidName report whether name can be used as a namespace (lowercase DNS label)
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// List return the configured namespaces, DefaultNamespace first
+func List() []types.NamespaceConfig {
+	list := []types.NamespaceConfig{{Name: types.DefaultNamespace}}
+	if types.GoHookAppConfig == nil {
+		return list
+	}
+	for _, ns := range types.GoHookAppConfig.Namespaces {
+		if ns.Name == types.DefaultNamespace {
+			list[0] = ns
+			continue
+		}
+		list = append(list, ns)
+	}
+	sort.SliceStable(list[1:], func(i, j int) bool { return list[i+1].Name < list[j+1].Name })
+	return list
+}
+
+// Exists report whether ns is configured, DefaultNamespace always exists
+func Exists(ns string) bool {
+	for _, n := range List() {
+		if n.Name == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup return the namespace of the named resource of kind
+func Lookup(kind, name string) (string, bool) {
+	r, ok := resourceFor(kind)
+	if !ok || r.Lookup == nil {
+		return "", false
+	}
+	return r.Lookup(name)
+}
+
+// Of return the namespace of the named resource, empty when it is unknown
+func Of(kind, name string) string {
+	ns, _ := Lookup(kind, name)
+	return ns
+}
+
+// Members list the resources of kind that belong to ns
+func Members(kind, ns string) []string {
+	r, ok := resourceFor(kind)
+	if !ok || r.Names == nil {
+		return nil
+	}
+	return r.Names(ns)
+}
+
+// SetContext store the namespace a request is limited to
+func SetContext(c *gin.Context, ns string) {
+	c.Set(contextKey, ns)
+}
+
+// FromContext return the namespace a request is limited to, empty means all namespaces
+func FromContext(c *gin.Context) string {
+	return c.GetString(contextKey)
+}
+
+// Allowed report whether the request may access an item of namespace ns
+func Allowed(c *gin.Context, ns string) bool {
+	scope := FromContext(c)
+	return scope == "" || Normalize(ns) == scope
+}
+
+// ForCreate return the namespace to store on a hook or project created by the request,
+// requested is only honoured when the request is not limited to a namespace.
+// DefaultNamespace is stored as empty so existing config files stay unchanged.
+func ForCreate(c *gin.Context, requested string) string {
+	ns := requested
+	if scope := FromContext(c); scope != "" {
+		ns = scope
+	}
+	if ns == types.DefaultNamespace {
+		return ""
+	}
+	return ns
+}
+
+// RequireAccess reject requests for an item of kind, named by path parameter param,
+// that lives outside the request's namespace. Unknown items are left to the handler.
+func RequireAccess(kind, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param(param)
+		if name == "" || FromContext(c) == "" {
+			c.Next()
+			return
+		}
+		if ns, ok := Lookup(kind, name); ok && !Allowed(c, ns) {
+			// answer like a missing item, so other tenants' names are not disclosed
+			i18n.JSONError(c, http.StatusNotFound, i18n.CodeNotFound, nil)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,95 @@
+package namespace
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestValidName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"default", true},
+		{"team-a", true},
+		{"a1", true},
+		{"", false},
+		{"Team", false},
+		{"-team", false},
+		{"team-", false},
+		{"team_a", false},
+		{"../etc", false},
+	}
+	for _, tt := range tests {
+		if got := ValidName(tt.name); got != tt.want {
+			t.Errorf("ValidName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	saved := types.GoHookAppConfig
+	defer func() { types.GoHookAppConfig = saved }()
+
+	types.GoHookAppConfig = &types.AppConfig{Namespaces: []types.NamespaceConfig{
+		{Name: "team-b"},
+		{Name: types.DefaultNamespace, Description: "shared"},
+		{Name: "team-a"},
+	}}
+
+	list := List()
+	if len(list) != 3 || list[0].Name != types.DefaultNamespace || list[0].Description != "shared" ||
+		list[1].Name != "team-a" || list[2].Name != "team-b" {
+		t.Fatalf("List() = %+v", list)
+	}
+	if !Exists("team-a") || Exists("team-c") {
+		t.Fatalf("Exists mismatch")
+	}
+}
+
+func TestRequireAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	Register("test", Resource{
+		Lookup: func(name string) (string, bool) {
+			switch name {
+			case "a":
+				return "team-a", true
+			case "d":
+				return Normalize(""), true
+			}
+			return "", false
+		},
+	})
+
+	tests := []struct {
+		scope string
+		item  string
+		want  int
+	}{
+		{"", "a", http.StatusOK},
+		{"team-a", "a", http.StatusOK},
+		{"team-b", "a", http.StatusNotFound},
+		{"team-a", "d", http.StatusNotFound},
+		{types.DefaultNamespace, "d", http.StatusOK},
+		{"team-b", "missing", http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := gin.New()
+		r.GET("/items/:name", func(c *gin.Context) {
+			SetContext(c, tt.scope)
+			c.Next()
+		}, RequireAccess("test", "name"), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/"+tt.item, nil))
+		if w.Code != tt.want {
+			t.Errorf("scope %q item %q: status %d, want %d", tt.scope, tt.item, w.Code, tt.want)
+		}
+	}
+}
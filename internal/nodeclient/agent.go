@@ -2,9 +2,13 @@ package nodeclient
 
 import (
 	"context"
+	"io"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,6 +17,13 @@ type Agent struct {
 	cfg       Config
 	http      HTTPClient
 	statePath string
+	spool     *spool
+	logs      *logShipper
+	endpoints []string
+
+	// last failed self-update, reported to the server in hello
+	updateFailedVersion string
+	updateError         string
 }
 
 // Config controls agent behavior.
@@ -28,6 +39,11 @@ type Config struct {
 	DataDir           string
 	TLSDir            string
 	ServerFingerprint string
+	// HooksFile enables local hooks served on LocalListen (loopback only, default 127.0.0.1:9090).
+	HooksFile   string
+	LocalListen string
+	// ShipLogs sends agent logs and command output to the server, see LogWriter.
+	ShipLogs bool
 }
 
 // HTTPClient defines the http.Client subset required by Agent.
@@ -50,7 +66,14 @@ func New(cfg Config) *Agent {
 		cfg.TLSDir = filepath.Join(cfg.DataDir, "tls")
 	}
 	client := &http.Client{Timeout: 10 * time.Second}
-	a := &Agent{cfg: cfg, http: client}
+	a := &Agent{cfg: cfg, http: client, spool: newSpool(cfg.DataDir)}
+	if cfg.ShipLogs {
+		max := 0
+		if raw := strings.TrimSpace(os.Getenv("GOHOOK_LOG_BUFFER_MAX")); raw != "" {
+			max, _ = strconv.Atoi(raw)
+		}
+		a.logs = newLogShipper(max)
+	}
 	if cfg.DataDir != "" {
 		a.statePath = filepath.Join(cfg.DataDir, "state.json")
 		if st, err := LoadState(a.statePath); err == nil {
@@ -68,6 +91,15 @@ func New(cfg Config) *Agent {
 	return a
 }
 
+// LogWriter returns the writer to add to the log output so agent logs are shipped to the
+// server, nil when log shipping is disabled.
+func (a *Agent) LogWriter() io.Writer {
+	if a.logs == nil {
+		return nil
+	}
+	return a.logs
+}
+
 // Run starts the agent loop until the context is cancelled.
 // Node heartbeat is now derived from the TCP/mTLS long connection; HTTP heartbeat has been removed.
 func (a *Agent) Run(ctx context.Context) {
@@ -75,6 +107,13 @@ func (a *Agent) Run(ctx context.Context) {
 	go func() {
 		a.serveTCPWithRetry(ctx)
 	}()
+	if a.cfg.HooksFile != "" {
+		go func() {
+			if err := a.serveLocalHooks(ctx); err != nil {
+				log.Printf("nodeclient: local hooks disabled: %v", err)
+			}
+		}()
+	}
 	<-ctx.Done()
 	log.Printf("nodeclient: stopped")
 }
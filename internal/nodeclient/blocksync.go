@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"os"
 	"path/filepath"
@@ -101,18 +102,18 @@ func (a *Agent) runTaskTCP(ctx context.Context, conn io.ReadWriter, task *taskRe
 	var payload taskPayload
 	if err := json.Unmarshal([]byte(task.Payload), &payload); err != nil {
 		ce := classifyError(err)
-		_ = syncnode.WriteStreamMessage(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
+		a.sendTaskReport(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
 		return
 	}
 
 	if payload.TargetPath == "" || payload.TargetPath == "/" {
-		_ = syncnode.WriteStreamMessage(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: "invalid targetPath", ErrorCode: "INVALID_TARGET"})
+		a.sendTaskReport(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: "invalid targetPath", ErrorCode: "INVALID_TARGET"})
 		return
 	}
 
-	if err := ensureTargetWritable(payload.TargetPath); err != nil {
+	if err := preflightTarget(payload.TargetPath, payload.MinFreeBytes); err != nil {
 		ce := classifyError(err)
-		_ = syncnode.WriteStreamMessage(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
+		a.sendTaskReport(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
 		return
 	}
 
@@ -148,7 +149,7 @@ func (a *Agent) runTaskTCP(ctx context.Context, conn io.ReadWriter, task *taskRe
 
 	var begin indexBeginMsg
 	if err := syncnode.ReadStreamMessage(conn, &begin); err != nil || begin.Type != "index_begin" || begin.TaskID != task.ID {
-		_ = syncnode.WriteStreamMessage(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: "missing index_begin", ErrorCode: "PROTO"})
+		a.sendTaskReport(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: "missing index_begin", ErrorCode: "PROTO"})
 		return
 	}
 
@@ -164,7 +165,7 @@ func (a *Agent) runTaskTCP(ctx context.Context, conn io.ReadWriter, task *taskRe
 		var envelope map[string]any
 		if err := syncnode.ReadStreamMessage(conn, &envelope); err != nil {
 			ce := classifyError(err)
-			_ = syncnode.WriteStreamMessage(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
+			a.sendTaskReport(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
 			return
 		}
 		typ, _ := envelope["type"].(string)
@@ -185,7 +186,7 @@ func (a *Agent) runTaskTCP(ctx context.Context, conn io.ReadWriter, task *taskRe
 				case "dir":
 					if err := applyDirEntry(payload.TargetPath, preserveMode, preserveMtime, c.Files[i]); err != nil {
 						ce := classifyError(err)
-						_ = syncnode.WriteStreamMessage(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
+						a.sendTaskReport(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
 						return
 					}
 					expectedDirs[c.Files[i].Path] = struct{}{}
@@ -195,7 +196,7 @@ func (a *Agent) runTaskTCP(ctx context.Context, conn io.ReadWriter, task *taskRe
 					}
 					if err := applySymlinkEntry(payload.TargetPath, c.Files[i]); err != nil {
 						ce := classifyError(err)
-						_ = syncnode.WriteStreamMessage(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
+						a.sendTaskReport(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
 						return
 					}
 					expectedFiles[c.Files[i].Path] = struct{}{}
@@ -207,7 +208,7 @@ func (a *Agent) runTaskTCP(ctx context.Context, conn io.ReadWriter, task *taskRe
 					bytesFetched += by
 					if err != nil {
 						ce := classifyError(err)
-						_ = syncnode.WriteStreamMessage(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
+						a.sendTaskReport(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
 						return
 					}
 					filesApplied++
@@ -226,7 +227,7 @@ func (a *Agent) runTaskTCP(ctx context.Context, conn io.ReadWriter, task *taskRe
 			case "dir":
 				if err := applyDirEntry(payload.TargetPath, preserveMode, preserveMtime, f.File); err != nil {
 					ce := classifyError(err)
-					_ = syncnode.WriteStreamMessage(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
+					a.sendTaskReport(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
 					return
 				}
 				expectedDirs[f.File.Path] = struct{}{}
@@ -236,7 +237,7 @@ func (a *Agent) runTaskTCP(ctx context.Context, conn io.ReadWriter, task *taskRe
 				}
 				if err := applySymlinkEntry(payload.TargetPath, f.File); err != nil {
 					ce := classifyError(err)
-					_ = syncnode.WriteStreamMessage(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
+					a.sendTaskReport(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
 					return
 				}
 				expectedFiles[f.File.Path] = struct{}{}
@@ -268,7 +269,7 @@ indexDone:
 			bytesFetched += by
 			if err != nil {
 				ce := classifyError(err)
-				_ = syncnode.WriteStreamMessage(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
+				a.sendTaskReport(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
 				return
 			}
 			filesApplied++
@@ -276,7 +277,7 @@ indexDone:
 	}
 
 	if strings.ToLower(payload.Strategy) == "" || strings.ToLower(payload.Strategy) == "mirror" {
-		ig := syncignore.New(payload.TargetPath, payload.IgnoreDefaults, payload.IgnorePatterns)
+		ig := syncignore.New(payload.TargetPath, payload.IgnoreDefaults, payload.IgnorePatterns).Only(payload.Include)
 
 		fastDelete := payload.MirrorFastDelete || shouldUseFastMirrorDelete()
 		cleanEmpty := payload.MirrorCleanEmptyDirs || shouldCleanEmptyDirs()
@@ -301,7 +302,7 @@ indexDone:
 			if needFull {
 				if err := mirrorDeleteExtras(payload.TargetPath, expectedFiles, expectedDirs, ig, cleanEmpty); err != nil {
 					ce := classifyError(err)
-					_ = syncnode.WriteStreamMessage(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
+					a.sendTaskReport(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
 					return
 				}
 			} else {
@@ -309,14 +310,14 @@ indexDone:
 					// Fallback to strict cleanup when manifest is missing/corrupt.
 					if err := mirrorDeleteExtras(payload.TargetPath, expectedFiles, expectedDirs, ig, cleanEmpty); err != nil {
 						ce := classifyError(err)
-						_ = syncnode.WriteStreamMessage(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
+						a.sendTaskReport(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
 						return
 					}
 				}
 			}
 		} else if err := mirrorDeleteExtras(payload.TargetPath, expectedFiles, expectedDirs, ig, cleanEmpty); err != nil {
 			ce := classifyError(err)
-			_ = syncnode.WriteStreamMessage(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
+			a.sendTaskReport(conn, taskReportMsg{Type: "task_report", TaskID: task.ID, Status: "failed", LastError: ce.Message, ErrorCode: ce.Code})
 			return
 		}
 
@@ -326,7 +327,7 @@ indexDone:
 
 	_ = writeExpectedManifest(payload.TargetPath, expectedFiles)
 
-	_ = syncnode.WriteStreamMessage(conn, taskReportMsg{
+	a.sendTaskReport(conn, taskReportMsg{
 		Type:       "task_report",
 		TaskID:     task.ID,
 		Status:     "success",
@@ -338,6 +339,17 @@ indexDone:
 	})
 }
 
+// sendTaskReport delivers a task result, spooling it to disk when the connection is gone.
+func (a *Agent) sendTaskReport(conn io.Writer, msg taskReportMsg) {
+	if err := syncnode.WriteStreamMessage(conn, msg); err != nil {
+		if spErr := a.spool.Push(msg); spErr != nil {
+			log.Printf("nodeclient: drop task %d report: write=%v spool=%v", msg.TaskID, err, spErr)
+			return
+		}
+		log.Printf("nodeclient: task %d report spooled: %v", msg.TaskID, err)
+	}
+}
+
 func (a *Agent) applyFileBlocks(ctx context.Context, conn io.ReadWriter, taskID uint, targetRoot string, preserveMode, preserveMtime bool, file syncnode.IndexFileEntry) (int, int64, error) {
 	dst := filepath.Join(targetRoot, filepath.FromSlash(file.Path))
 	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
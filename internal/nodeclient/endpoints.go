@@ -0,0 +1,47 @@
+package nodeclient
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// parseEndpoints splits a comma-separated server list, dropping blanks and duplicates.
+func parseEndpoints(raw string) []string {
+	var out []string
+	seen := map[string]struct{}{}
+	for _, part := range strings.Split(raw, ",") {
+		ep := strings.TrimSpace(part)
+		if ep == "" {
+			continue
+		}
+		if _, ok := seen[ep]; ok {
+			continue
+		}
+		seen[ep] = struct{}{}
+		out = append(out, ep)
+	}
+	return out
+}
+
+// resolveEndpoints returns the configured sync servers in failover order.
+func (a *Agent) resolveEndpoints() []string {
+	raw := strings.TrimSpace(a.cfg.Endpoint)
+	if raw == "" {
+		raw = strings.TrimSpace(os.Getenv("GOHOOK_SERVER"))
+	}
+	if raw == "" {
+		raw = strings.TrimSpace(os.Getenv("SYNC_TCP_ENDPOINT"))
+	}
+	return parseEndpoints(raw)
+}
+
+// serverFingerprintFile returns where the TOFU fingerprint of endpoint is kept.
+// A single server keeps the historical server.fp; with failover each server pins its own.
+func (a *Agent) serverFingerprintFile(tlsDir, endpoint string) string {
+	if len(a.endpoints) <= 1 {
+		return filepath.Join(tlsDir, "server.fp")
+	}
+	name := strings.NewReplacer(":", "_", "/", "_", "\\", "_", "[", "", "]", "").Replace(endpoint)
+	return filepath.Join(tlsDir, "server-"+name+".fp")
+}
@@ -0,0 +1,17 @@
+package nodeclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEndpoints(t *testing.T) {
+	got := parseEndpoints(" 10.0.0.1:9001, ,10.0.0.2:9001,10.0.0.1:9001 ")
+	want := []string{"10.0.0.1:9001", "10.0.0.2:9001"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected endpoints: %v, want %v", got, want)
+	}
+	if got := parseEndpoints(""); len(got) != 0 {
+		t.Fatalf("expected no endpoints, got %v", got)
+	}
+}
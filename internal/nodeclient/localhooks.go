@@ -0,0 +1,209 @@
+package nodeclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultLocalListen is the loopback address of the local hooks listener.
+const DefaultLocalListen = "127.0.0.1:9090"
+
+const (
+	defaultLocalHookTimeout = 30 * time.Second
+	// localBodyLimit bounds the request body passed to a local hook on stdin.
+	localBodyLimit = 1 << 20
+	// localOutputLimit bounds the command output kept in a hook report.
+	localOutputLimit = 64 << 10
+)
+
+// LocalHook is a hook executed on the node itself, a subset of the server hook definition.
+type LocalHook struct {
+	ID                      string   `json:"id"`
+	ExecuteCommand          string   `json:"execute-command"`
+	Arguments               []string `json:"arguments,omitempty"`
+	CommandWorkingDirectory string   `json:"command-working-directory,omitempty"`
+	ResponseMessage         string   `json:"response-message,omitempty"`
+	CaptureCommandOutput    bool     `json:"include-command-output-in-response,omitempty"`
+	Timeout                 string   `json:"timeout,omitempty"` // default 30s
+
+	timeout time.Duration
+}
+
+// hookReportMsg reports a local hook run upstream; the server stores it in the hook logs.
+type hookReportMsg struct {
+	Type       string `json:"type"`
+	HookID     string `json:"hookId"`
+	Method     string `json:"method,omitempty"`
+	Success    bool   `json:"success"`
+	Output     string `json:"output,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+}
+
+// LoadLocalHooks reads a JSON array of local hooks from path.
+func LoadLocalHooks(path string) (map[string]*LocalHook, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []*LocalHook
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	hooks := make(map[string]*LocalHook, len(list))
+	for i, h := range list {
+		h.ID = strings.TrimSpace(h.ID)
+		if h.ID == "" {
+			return nil, fmt.Errorf("hook #%d: missing id", i)
+		}
+		if _, dup := hooks[h.ID]; dup {
+			return nil, fmt.Errorf("hook %s: duplicate id", h.ID)
+		}
+		if strings.TrimSpace(h.ExecuteCommand) == "" {
+			return nil, fmt.Errorf("hook %s: missing execute-command", h.ID)
+		}
+		h.timeout = defaultLocalHookTimeout
+		if h.Timeout != "" {
+			d, err := time.ParseDuration(h.Timeout)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("hook %s: invalid timeout %q", h.ID, h.Timeout)
+			}
+			h.timeout = d
+		}
+		hooks[h.ID] = h
+	}
+	return hooks, nil
+}
+
+// checkLoopback rejects listen addresses other than loopback ones: local hooks carry no
+// authentication and must not be reachable from the network.
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("listen address %s is not a loopback address", addr)
+}
+
+// serveLocalHooks loads the hooks file and serves POST /hooks/{id} on the loopback listener
+// until ctx is cancelled. Results are queued in the spool and delivered with the next status
+// reply, or after reconnecting when the server is unreachable.
+func (a *Agent) serveLocalHooks(ctx context.Context) error {
+	hooks, err := LoadLocalHooks(a.cfg.HooksFile)
+	if err != nil {
+		return err
+	}
+	addr := a.cfg.LocalListen
+	if addr == "" {
+		addr = DefaultLocalListen
+	}
+	if err := checkLoopback(addr); err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /hooks/{id}", a.localHookHandler(hooks))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	log.Printf("nodeclient: serving %d local hooks on http://%s/hooks/", len(hooks), ln.Addr())
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (a *Agent) localHookHandler(hooks map[string]*LocalHook) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h, ok := hooks[r.PathValue("id")]
+		if !ok {
+			http.Error(w, "Hook not found.", http.StatusNotFound)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, localBodyLimit))
+		if err != nil {
+			http.Error(w, "Request body too large.", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		rep := runLocalHook(r.Context(), h, body)
+		rep.Method = r.Method
+		a.logs.command(h.ID, rep.Output, rep.Success)
+		if err := a.spool.Push(rep); err != nil {
+			log.Printf("nodeclient: queue report of local hook %s failed: %v", h.ID, err)
+		}
+
+		switch {
+		case !rep.Success:
+			w.WriteHeader(http.StatusInternalServerError)
+			if h.CaptureCommandOutput {
+				_, _ = io.WriteString(w, rep.Output)
+			} else {
+				_, _ = io.WriteString(w, "Error occurred while executing the hook's command.")
+			}
+		case h.CaptureCommandOutput:
+			_, _ = io.WriteString(w, rep.Output)
+		default:
+			_, _ = io.WriteString(w, h.ResponseMessage)
+		}
+	}
+}
+
+// runLocalHook executes h with body on stdin and the hook id in HOOK_ID.
+func runLocalHook(ctx context.Context, h *LocalHook, body []byte) hookReportMsg {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.ExecuteCommand, h.Arguments...)
+	cmd.Dir = h.CommandWorkingDirectory
+	cmd.Env = append(os.Environ(), "HOOK_ID="+h.ID)
+	cmd.Stdin = bytes.NewReader(body)
+
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	rep := hookReportMsg{
+		Type:       "hook_report",
+		HookID:     h.ID,
+		Success:    err == nil,
+		Output:     truncateOutput(out),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", h.timeout)
+		}
+		rep.Error = err.Error()
+		log.Printf("nodeclient: local hook %s failed: %v", h.ID, err)
+	}
+	return rep
+}
+
+func truncateOutput(out []byte) string {
+	if len(out) <= localOutputLimit {
+		return string(out)
+	}
+	return string(out[:localOutputLimit]) + "\n... (truncated)"
+}
@@ -0,0 +1,106 @@
+package nodeclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckLoopback(t *testing.T) {
+	tests := []struct {
+		addr    string
+		wantErr bool
+	}{
+		{"127.0.0.1:9090", false},
+		{"[::1]:9090", false},
+		{"localhost:9090", false},
+		{"0.0.0.0:9090", true},
+		{":9090", true},
+		{"10.0.0.1:9090", true},
+		{"127.0.0.1", true},
+	}
+	for _, tt := range tests {
+		if err := checkLoopback(tt.addr); (err != nil) != tt.wantErr {
+			t.Errorf("checkLoopback(%q) error = %v, wantErr %v", tt.addr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadLocalHooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"valid", `[{"id":"a","execute-command":"/bin/true","timeout":"5s"}]`, false},
+		{"missing id", `[{"execute-command":"/bin/true"}]`, true},
+		{"missing command", `[{"id":"a"}]`, true},
+		{"duplicate id", `[{"id":"a","execute-command":"/bin/true"},{"id":"a","execute-command":"/bin/true"}]`, true},
+		{"invalid timeout", `[{"id":"a","execute-command":"/bin/true","timeout":"soon"}]`, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "hooks.json")
+		if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadLocalHooks(path); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLocalHookHandlerQueuesReport(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("requires /bin/sh")
+	}
+	hooks := map[string]*LocalHook{
+		"echo": {ID: "echo", ExecuteCommand: "/bin/sh", Arguments: []string{"-c", `printf "%s:" "$HOOK_ID"; cat`}, CaptureCommandOutput: true, timeout: defaultLocalHookTimeout},
+		"fail": {ID: "fail", ExecuteCommand: "/bin/sh", Arguments: []string{"-c", "exit 3"}, timeout: defaultLocalHookTimeout},
+	}
+	a := &Agent{spool: newSpool(t.TempDir())}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /hooks/{id}", a.localHookHandler(hooks))
+
+	tests := []struct {
+		id       string
+		wantCode int
+		wantBody string
+	}{
+		{"echo", http.StatusOK, "echo:payload"},
+		{"fail", http.StatusInternalServerError, "Error occurred while executing the hook's command."},
+		{"missing", http.StatusNotFound, "Hook not found."},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/hooks/"+tt.id, strings.NewReader("payload"))
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tt.wantCode || strings.TrimSpace(rec.Body.String()) != tt.wantBody {
+			t.Errorf("%s: got %d %q, want %d %q", tt.id, rec.Code, rec.Body.String(), tt.wantCode, tt.wantBody)
+		}
+	}
+
+	var reports []hookReportMsg
+	if _, err := a.spool.Drain(func(raw json.RawMessage) error {
+		var rep hookReportMsg
+		if err := json.Unmarshal(raw, &rep); err != nil {
+			return err
+		}
+		reports = append(reports, rep)
+		return nil
+	}); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 queued reports, got %d", len(reports))
+	}
+	if r := reports[0]; r.Type != "hook_report" || r.HookID != "echo" || !r.Success || r.Method != http.MethodPost {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	if r := reports[1]; r.HookID != "fail" || r.Success || r.Error == "" {
+		t.Errorf("unexpected report: %+v", r)
+	}
+}
@@ -0,0 +1,129 @@
+package nodeclient
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mycoool/gohook/internal/syncnode"
+)
+
+const (
+	// defaultLogBufferMax bounds the log lines kept while the server is unreachable.
+	defaultLogBufferMax = 2000
+	// logBatchSize is the number of lines sent per node_logs frame.
+	logBatchSize = 200
+	// logTimeLayout is the prefix written by the standard logger with log.LstdFlags.
+	logTimeLayout = "2006/01/02 15:04:05"
+)
+
+// logEntry is one agent log line or command output shipped to the server.
+type logEntry struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Level   string    `json:"level"`
+	Command string    `json:"command,omitempty"`
+	Message string    `json:"message"`
+}
+
+type nodeLogsMsg struct {
+	Type    string     `json:"type"`
+	Entries []logEntry `json:"entries"`
+	Dropped int        `json:"dropped,omitempty"`
+}
+
+// logShipper buffers log lines in memory until they are shipped with the next status reply.
+// The oldest lines are dropped once the buffer is full; the count is reported to the server.
+type logShipper struct {
+	mu      sync.Mutex
+	entries []logEntry
+	max     int
+	dropped int
+}
+
+func newLogShipper(max int) *logShipper {
+	if max <= 0 {
+		max = defaultLogBufferMax
+	}
+	return &logShipper{max: max}
+}
+
+// Write implements io.Writer for log.SetOutput, each call carries one log line.
+func (s *logShipper) Write(p []byte) (int, error) {
+	line := strings.TrimRight(string(p), "\n")
+	at := time.Now()
+	if len(line) > len(logTimeLayout) {
+		if t, err := time.ParseInLocation(logTimeLayout, line[:len(logTimeLayout)], time.Local); err == nil {
+			at = t
+			line = strings.TrimLeft(line[len(logTimeLayout):], " ")
+		}
+	}
+	s.add(logEntry{Time: at, Source: "agent", Level: lineLevel(line), Message: line})
+	return len(p), nil
+}
+
+// command records the output of a command executed on the node.
+func (s *logShipper) command(name, output string, success bool) {
+	if s == nil {
+		return
+	}
+	level := "INFO"
+	if !success {
+		level = "ERROR"
+	}
+	s.add(logEntry{Time: time.Now(), Source: "command", Level: level, Command: name, Message: output})
+}
+
+func (s *logShipper) add(e logEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) >= s.max {
+		n := len(s.entries) - s.max + 1
+		s.entries = s.entries[n:]
+		s.dropped += n
+	}
+	s.entries = append(s.entries, e)
+}
+
+// ship sends the buffered lines in batches; lines of a failed batch stay buffered.
+func (s *logShipper) ship(conn net.Conn) error {
+	if s == nil {
+		return nil
+	}
+	for {
+		s.mu.Lock()
+		n := min(len(s.entries), logBatchSize)
+		if n == 0 && s.dropped == 0 {
+			s.mu.Unlock()
+			return nil
+		}
+		msg := nodeLogsMsg{Type: "node_logs", Entries: append([]logEntry(nil), s.entries[:n]...), Dropped: s.dropped}
+		s.mu.Unlock()
+
+		if err := syncnode.WriteStreamMessage(conn, msg); err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		// lines added meanwhile are appended; the sent ones are still at the front unless
+		// they were dropped to make room, which does not count as lost
+		dropped := s.dropped - msg.Dropped
+		gone := min(dropped, n)
+		s.entries = s.entries[n-gone:]
+		s.dropped = dropped - gone
+		s.mu.Unlock()
+	}
+}
+
+// lineLevel guesses the level of an agent log line, which carries none.
+func lineLevel(line string) string {
+	lower := strings.ToLower(line)
+	switch {
+	case strings.Contains(lower, "error") || strings.Contains(lower, "failed"):
+		return "ERROR"
+	case strings.Contains(lower, "warn") || strings.Contains(lower, "retry"):
+		return "WARN"
+	}
+	return "INFO"
+}
@@ -0,0 +1,64 @@
+package nodeclient
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mycoool/gohook/internal/syncnode"
+)
+
+func TestLogShipperWrite(t *testing.T) {
+	s := newLogShipper(10)
+	_, _ = s.Write([]byte("2026/01/02 15:04:05 nodeclient: tcp connect failed: refused\n"))
+	_, _ = s.Write([]byte("no timestamp\n"))
+	s.command("deploy", "done", true)
+
+	tests := []struct {
+		source, level, command, message string
+	}{
+		{"agent", "ERROR", "", "nodeclient: tcp connect failed: refused"},
+		{"agent", "INFO", "", "no timestamp"},
+		{"command", "INFO", "deploy", "done"},
+	}
+	if len(s.entries) != len(tests) {
+		t.Fatalf("expected %d entries, got %d", len(tests), len(s.entries))
+	}
+	for i, tt := range tests {
+		e := s.entries[i]
+		if e.Source != tt.source || e.Level != tt.level || e.Command != tt.command || e.Message != tt.message {
+			t.Errorf("entry %d = %+v", i, e)
+		}
+	}
+	if want := time.Date(2026, 1, 2, 15, 4, 5, 0, time.Local); !s.entries[0].Time.Equal(want) {
+		t.Errorf("entry time = %v, want %v", s.entries[0].Time, want)
+	}
+}
+
+func TestLogShipperShip(t *testing.T) {
+	s := newLogShipper(3)
+	for _, msg := range []string{"a", "b", "c", "d", "e"} {
+		_, _ = s.Write([]byte(msg + "\n"))
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	got := make(chan nodeLogsMsg, 1)
+	go func() {
+		var msg nodeLogsMsg
+		_ = syncnode.ReadStreamMessage(server, &msg)
+		got <- msg
+	}()
+	if err := s.ship(client); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-got
+	if msg.Type != "node_logs" || msg.Dropped != 2 || len(msg.Entries) != 3 || msg.Entries[0].Message != "c" {
+		t.Fatalf("unexpected batch: %+v", msg)
+	}
+	if len(s.entries) != 0 || s.dropped != 0 {
+		t.Fatalf("expected an empty buffer after shipping, got %d entries, %d dropped", len(s.entries), s.dropped)
+	}
+}
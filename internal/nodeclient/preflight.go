@@ -4,8 +4,35 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/shirou/gopsutil/v3/disk"
 )
 
+// preflightTarget checks the target before any file is written, so a sync that cannot finish
+// fails without leaving the target half updated.
+func preflightTarget(targetPath string, minFree int64) error {
+	if err := ensureTargetWritable(targetPath); err != nil {
+		return err
+	}
+	return ensureFreeSpace(targetPath, minFree)
+}
+
+// ensureFreeSpace requires minFree bytes available on the filesystem of path, 0 skips the check.
+func ensureFreeSpace(path string, minFree int64) error {
+	if minFree <= 0 {
+		return nil
+	}
+	usage, err := disk.Usage(path)
+	if err != nil {
+		return fmt.Errorf("read free space of %s: %w", path, err)
+	}
+	if int64(usage.Free) < minFree {
+		return fmt.Errorf("PREFLIGHT: %d MB free on %s, %d MB required. Fix: free space on target filesystem or lower preflight min_free_mb.",
+			usage.Free>>20, path, minFree>>20)
+	}
+	return nil
+}
+
 func ensureTargetWritable(targetPath string) error {
 	clean := filepath.Clean(targetPath)
 	if clean == "" || clean == "." || clean == "/" {
@@ -0,0 +1,36 @@
+package nodeclient
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPreflightTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		minFree  int64
+		wantCode string
+	}{
+		{"no disk check", 0, ""},
+		{"enough space", 1, ""},
+		{"not enough space", 1 << 62, "PREFLIGHT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := filepath.Join(t.TempDir(), "app")
+			err := preflightTarget(target, tt.minFree)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if ce := classifyError(err); ce.Code != tt.wantCode {
+				t.Fatalf("code = %q (%s), want %q", ce.Code, ce.Message, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package nodeclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// selfUpdateTimeout bounds the binary download.
+const selfUpdateTimeout = 5 * time.Minute
+
+// agentUpdate mirrors the update offer the server sends in hello_ack.
+type agentUpdate struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	SHA256  string `json:"sha256"`
+}
+
+// selfUpdateDisabled allows operators to pin the agent binary.
+// Env: GOHOOK_DISABLE_SELF_UPDATE (true|1)
+func selfUpdateDisabled() bool {
+	raw := strings.TrimSpace(os.Getenv("GOHOOK_DISABLE_SELF_UPDATE"))
+	return strings.EqualFold(raw, "1") || strings.EqualFold(raw, "true")
+}
+
+// shouldApplyUpdate reports whether the offered update should be installed now.
+// A version that already failed is not retried until the agent restarts.
+func (a *Agent) shouldApplyUpdate(up *agentUpdate) bool {
+	if up == nil || selfUpdateDisabled() {
+		return false
+	}
+	if up.Version == "" || up.Version == a.cfg.Version {
+		return false
+	}
+	return a.updateFailedVersion != up.Version
+}
+
+// applySelfUpdate downloads, verifies and swaps the agent binary, then restarts the process.
+// It only returns on failure.
+func (a *Agent) applySelfUpdate(ctx context.Context, up *agentUpdate) error {
+	exe, err := a.installUpdate(ctx, up)
+	if err == nil {
+		log.Printf("nodeclient: updated to %s, restarting", up.Version)
+		err = restartSelf(exe)
+	}
+	a.updateFailedVersion = up.Version
+	a.updateError = err.Error()
+	log.Printf("nodeclient: self-update to %s failed: %v", up.Version, err)
+	return err
+}
+
+// installUpdate returns the path of the replaced binary.
+func (a *Agent) installUpdate(ctx context.Context, up *agentUpdate) (string, error) {
+	if strings.TrimSpace(up.SHA256) == "" {
+		return "", fmt.Errorf("update checksum missing")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, selfUpdateTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, up.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	// stage next to the binary so the final rename stays on one filesystem
+	tmp, err := os.CreateTemp(filepath.Dir(exe), filepath.Base(exe)+".update-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hasher), resp.Body); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if sum := hex.EncodeToString(hasher.Sum(nil)); !strings.EqualFold(sum, strings.TrimSpace(up.SHA256)) {
+		return "", fmt.Errorf("checksum mismatch: got %s", sum)
+	}
+	if err := os.Chmod(tmpPath, 0o755); err != nil {
+		return "", err
+	}
+
+	backup := exe + ".old"
+	_ = os.Remove(backup)
+	if err := os.Rename(exe, backup); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpPath, exe); err != nil {
+		_ = os.Rename(backup, exe)
+		return "", err
+	}
+	return exe, nil
+}
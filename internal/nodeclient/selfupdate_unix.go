@@ -0,0 +1,13 @@
+//go:build !windows
+
+package nodeclient
+
+import (
+	"os"
+	"syscall"
+)
+
+// restartSelf replaces the current process image with the freshly installed binary.
+func restartSelf(exe string) error {
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
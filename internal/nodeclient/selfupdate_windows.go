@@ -0,0 +1,21 @@
+//go:build windows
+
+package nodeclient
+
+import (
+	"os"
+	"os/exec"
+)
+
+// restartSelf starts the new binary and exits; Windows has no exec(2).
+func restartSelf(exe string) error {
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
@@ -0,0 +1,116 @@
+package nodeclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultSpoolMax bounds the number of messages kept while the server is unreachable.
+const defaultSpoolMax = 1000
+
+// spool is a bounded on-disk FIFO of outgoing messages that could not be delivered.
+// Each message is stored as one JSON file so a crash never corrupts the queue.
+type spool struct {
+	mu  sync.Mutex
+	dir string
+	max int
+	seq uint64
+}
+
+func newSpool(dataDir string) *spool {
+	if strings.TrimSpace(dataDir) == "" {
+		return nil
+	}
+	max := defaultSpoolMax
+	if raw := strings.TrimSpace(os.Getenv("GOHOOK_SPOOL_MAX")); raw != "" {
+		if v, err := strconv.Atoi(raw); err == nil && v > 0 {
+			max = v
+		}
+	}
+	return &spool{dir: filepath.Join(dataDir, "spool"), max: max}
+}
+
+// Push stores a message; the oldest entries are dropped once the spool is full.
+func (s *spool) Push(msg any) error {
+	if s == nil {
+		return fmt.Errorf("spool disabled")
+	}
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	names := s.listLocked()
+	for len(names) >= s.max {
+		_ = os.Remove(filepath.Join(s.dir, names[0]))
+		names = names[1:]
+	}
+	s.seq++
+	name := fmt.Sprintf("%020d-%06d.json", time.Now().UnixNano(), s.seq%1000000)
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, name))
+}
+
+// Len returns the number of queued messages.
+func (s *spool) Len() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.listLocked())
+}
+
+// Drain sends queued messages oldest first and removes each one once delivered.
+// It stops at the first send error, leaving the rest for the next connection.
+func (s *spool) Drain(send func(raw json.RawMessage) error) (int, error) {
+	if s == nil {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sent := 0
+	for _, name := range s.listLocked() {
+		path := filepath.Join(s.dir, name)
+		raw, err := os.ReadFile(path)
+		if err != nil || !json.Valid(raw) {
+			_ = os.Remove(path)
+			continue
+		}
+		if err := send(raw); err != nil {
+			return sent, err
+		}
+		_ = os.Remove(path)
+		sent++
+	}
+	return sent, nil
+}
+
+func (s *spool) listLocked() []string {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
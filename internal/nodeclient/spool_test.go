@@ -0,0 +1,39 @@
+package nodeclient
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSpoolBoundedFIFO(t *testing.T) {
+	t.Setenv("GOHOOK_SPOOL_MAX", "2")
+	sp := newSpool(t.TempDir())
+	for i := 1; i <= 3; i++ {
+		if err := sp.Push(taskReportMsg{Type: "task_report", TaskID: uint(i), Status: "success"}); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+	if n := sp.Len(); n != 2 {
+		t.Fatalf("expected 2 spooled messages, got %d", n)
+	}
+
+	var got []uint
+	sent, err := sp.Drain(func(raw json.RawMessage) error {
+		var msg taskReportMsg
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			return err
+		}
+		if msg.TaskID == 3 {
+			return errors.New("connection lost")
+		}
+		got = append(got, msg.TaskID)
+		return nil
+	})
+	if err == nil || sent != 1 || len(got) != 1 || got[0] != 2 {
+		t.Fatalf("unexpected drain result: sent=%d got=%v err=%v", sent, got, err)
+	}
+	if n := sp.Len(); n != 1 {
+		t.Fatalf("expected undelivered message to stay queued, got %d", n)
+	}
+}
@@ -12,6 +12,9 @@ import (
 	"github.com/shirou/gopsutil/v3/mem"
 )
 
+// agentStartedAt is used to report agent process uptime.
+var agentStartedAt = time.Now()
+
 type runtimeStatus struct {
 	Type            string  `json:"type"`
 	NodeID          uint    `json:"nodeId"`
@@ -24,13 +27,20 @@ type runtimeStatus struct {
 	Load1Percent    float64 `json:"load1Percent,omitempty"`
 	DiskUsedPercent float64 `json:"diskUsedPercent,omitempty"`
 	CPUCores        int     `json:"cpuCores,omitempty"`
+	MemTotalBytes   uint64  `json:"memTotalBytes,omitempty"`
+	MemAvailBytes   uint64  `json:"memAvailBytes,omitempty"`
+	WorkDirFree     uint64  `json:"workDirFree,omitempty"`
+	WorkDirTotal    uint64  `json:"workDirTotal,omitempty"`
+	AgentUptimeSec  uint64  `json:"agentUptimeSec,omitempty"`
+	SpoolSize       int     `json:"spoolSize,omitempty"`
 }
 
-func collectRuntimeStatus(ctx context.Context, nodeID uint) runtimeStatus {
+func collectRuntimeStatus(ctx context.Context, nodeID uint, workDir string) runtimeStatus {
 	out := runtimeStatus{
-		Type:      "node_status",
-		NodeID:    nodeID,
-		UpdatedAt: time.Now().Format(time.RFC3339),
+		Type:           "node_status",
+		NodeID:         nodeID,
+		UpdatedAt:      time.Now().Format(time.RFC3339),
+		AgentUptimeSec: uint64(time.Since(agentStartedAt).Seconds()),
 	}
 
 	if hi, err := host.InfoWithContext(ctx); err == nil && hi != nil {
@@ -42,10 +52,18 @@ func collectRuntimeStatus(ctx context.Context, nodeID uint) runtimeStatus {
 	}
 	if vm, err := mem.VirtualMemoryWithContext(ctx); err == nil && vm != nil {
 		out.MemUsedPercent = vm.UsedPercent
+		out.MemTotalBytes = vm.Total
+		out.MemAvailBytes = vm.Available
 	}
 	if du, err := disk.UsageWithContext(ctx, "/"); err == nil && du != nil {
 		out.DiskUsedPercent = du.UsedPercent
 	}
+	if workDir != "" {
+		if du, err := disk.UsageWithContext(ctx, workDir); err == nil && du != nil {
+			out.WorkDirFree = du.Free
+			out.WorkDirTotal = du.Total
+		}
+	}
 	if perc, err := cpu.PercentWithContext(ctx, 0, false); err == nil && len(perc) > 0 {
 		out.CPUPercent = perc[0]
 	}
@@ -18,6 +18,7 @@ type taskPayload struct {
 	TargetPath              string   `json:"targetPath"`
 	Strategy                string   `json:"strategy"`
 	IgnoreDefaults          bool     `json:"ignoreDefaults"`
+	Include                 []string `json:"include,omitempty"`
 	IgnorePatterns          []string `json:"ignorePatterns"`
 	IgnoreFile              string   `json:"ignoreFile"`
 	IgnorePermissions       bool     `json:"ignorePermissions"`
@@ -28,4 +29,5 @@ type taskPayload struct {
 	MirrorFastFullscanEvery int      `json:"mirrorFastFullscanEvery,omitempty"`
 	MirrorCleanEmptyDirs    bool     `json:"mirrorCleanEmptyDirs,omitempty"`
 	MirrorSyncEmptyDirs     bool     `json:"mirrorSyncEmptyDirs,omitempty"`
+	MinFreeBytes            int64    `json:"minFreeBytes,omitempty"` // free space required on the target before syncing
 }
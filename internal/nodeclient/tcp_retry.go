@@ -3,41 +3,78 @@ package nodeclient
 import (
 	"context"
 	"log"
-	"os"
-	"strings"
+	"math/rand/v2"
 	"time"
 )
 
 func (a *Agent) serveTCPWithRetry(ctx context.Context) {
-	endpoint := strings.TrimSpace(a.cfg.Endpoint)
-	if endpoint == "" {
-		endpoint = strings.TrimSpace(os.Getenv("GOHOOK_SERVER"))
-	}
-	if endpoint == "" {
-		endpoint = strings.TrimSpace(os.Getenv("SYNC_TCP_ENDPOINT"))
-	}
-	if endpoint == "" {
+	a.endpoints = a.resolveEndpoints()
+	if len(a.endpoints) == 0 {
 		log.Printf("nodeclient: server endpoint not set; TCP sync disabled")
 		return
 	}
 
-	backoff := 1 * time.Second
+	// active sticks to the last server that accepted us; failures move on in configured order
+	active := 0
+	lastServer := ""
+	failures := 0
+	backoff := minRetryBackoff
 	for {
 		if ctx.Err() != nil {
 			return
 		}
-		if err := a.connectAndServeTCP(ctx); err != nil && ctx.Err() == nil {
-			log.Printf("nodeclient: tcp sync disconnected: %v", err)
-		}
+		endpoint := a.endpoints[active]
+		startedAt := time.Now()
+		established, err := a.connectAndServeTCP(ctx, endpoint)
 		if ctx.Err() != nil {
 			return
 		}
-		time.Sleep(backoff)
-		if backoff < 30*time.Second {
-			backoff *= 2
-			if backoff > 30*time.Second {
-				backoff = 30 * time.Second
+		if established {
+			if lastServer != "" && lastServer != endpoint {
+				log.Printf("nodeclient: active server changed %s -> %s", lastServer, endpoint)
+			}
+			lastServer = endpoint
+			failures = 0
+		}
+		if err != nil {
+			log.Printf("nodeclient: tcp sync disconnected (%s): %v", endpoint, err)
+		}
+		if !established && len(a.endpoints) > 1 {
+			active = (active + 1) % len(a.endpoints)
+			failures++
+			if failures < len(a.endpoints) {
+				// try the next server right away, back off only after a full round failed
+				continue
 			}
+			failures = 0
 		}
+		// a connection that stayed up for a while was healthy; start over from the minimum delay
+		if time.Since(startedAt) >= stableConnDuration {
+			backoff = minRetryBackoff
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(jitterBackoff(backoff)):
+		}
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+const (
+	minRetryBackoff    = 1 * time.Second
+	maxRetryBackoff    = 30 * time.Second
+	stableConnDuration = 60 * time.Second
+)
+
+// jitterBackoff spreads reconnects over [d/2, d) so agents do not stampede a restarted server.
+func jitterBackoff(d time.Duration) time.Duration {
+	half := d / 2
+	if half <= 0 {
+		return d
 	}
+	return half + time.Duration(rand.Int64N(int64(half)))
 }
@@ -6,12 +6,14 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"time"
 
@@ -19,18 +21,9 @@ import (
 )
 
 // connectAndServeTCP tries to establish a long-lived mTLS connection for task push.
-// It blocks until the connection breaks or ctx is cancelled.
-func (a *Agent) connectAndServeTCP(ctx context.Context) error {
-	endpoint := strings.TrimSpace(a.cfg.Endpoint)
-	if endpoint == "" {
-		endpoint = strings.TrimSpace(os.Getenv("GOHOOK_SERVER"))
-	}
-	if endpoint == "" {
-		endpoint = strings.TrimSpace(os.Getenv("SYNC_TCP_ENDPOINT"))
-	}
-	if endpoint == "" {
-		return errors.New("SYNC_TCP_ENDPOINT not set")
-	}
+// It blocks until the connection breaks or ctx is cancelled. established reports whether
+// the server accepted the hello, so callers can tell an unreachable server from a dropped session.
+func (a *Agent) connectAndServeTCP(ctx context.Context, endpoint string) (established bool, err error) {
 	tlsDir := strings.TrimSpace(a.cfg.TLSDir)
 	if tlsDir == "" {
 		tlsDir = strings.TrimSpace(os.Getenv("GOHOOK_TLS_DIR"))
@@ -42,29 +35,29 @@ func (a *Agent) connectAndServeTCP(ctx context.Context) error {
 		tlsDir = "./agent_tls"
 	}
 
-	cfg, err := loadOrCreateClientTLS(tlsDir, a.cfg.ServerFingerprint)
+	cfg, err := loadOrCreateClientTLS(tlsDir, a.cfg.ServerFingerprint, a.serverFingerprintFile(tlsDir, endpoint))
 	if err != nil {
 		log.Printf("nodeclient: tls init failed: %v", err)
-		return err
+		return false, err
 	}
 
 	dialer := &net.Dialer{Timeout: 5 * time.Second}
 	raw, err := dialer.DialContext(ctx, "tcp", endpoint)
 	if err != nil {
 		log.Printf("nodeclient: tcp connect failed: %v", err)
-		return err
+		return false, err
 	}
 	conn := tls.Client(raw, cfg)
 	if err := conn.Handshake(); err != nil {
 		log.Printf("nodeclient: tls handshake failed: %v", err)
 		conn.Close()
-		return err
+		return false, err
 	}
 
 	if a.cfg.ID == 0 {
-		if err := a.enrollNode(ctx, conn, endpoint); err != nil {
+		if err := a.enrollNode(ctx, conn); err != nil {
 			conn.Close()
-			return err
+			return false, err
 		}
 	}
 
@@ -78,23 +71,57 @@ func (a *Agent) connectAndServeTCP(ctx context.Context) error {
 	if feats := agentFeatures(); len(feats) > 0 {
 		hello["features"] = feats
 	}
+	spooled := a.spool.Len()
+	if spooled > 0 {
+		hello["spooled"] = spooled
+	}
+	if !selfUpdateDisabled() {
+		hello["os"] = runtime.GOOS
+		hello["arch"] = runtime.GOARCH
+		if a.updateFailedVersion != "" {
+			hello["updateVersion"] = a.updateFailedVersion
+			hello["updateError"] = a.updateError
+		}
+	}
 	if err := syncnode.WriteStreamMessage(conn, hello); err != nil {
 		conn.Close()
-		return err
+		return false, err
 	}
 
 	var ack struct {
-		Type  string `json:"type"`
-		OK    bool   `json:"ok"`
-		Error string `json:"error"`
+		Type   string       `json:"type"`
+		OK     bool         `json:"ok"`
+		Error  string       `json:"error"`
+		Update *agentUpdate `json:"update"`
 	}
 	if err := syncnode.ReadStreamMessage(conn, &ack); err != nil || !ack.OK {
 		log.Printf("nodeclient: hello rejected: %v %s", err, ack.Error)
 		conn.Close()
 		if err != nil {
-			return err
+			return false, err
 		}
-		return errors.New(ack.Error)
+		return false, errors.New(ack.Error)
+	}
+
+	if a.shouldApplyUpdate(ack.Update) {
+		// drop the connection so the server does not push tasks to an agent about to restart
+		conn.Close()
+		log.Printf("nodeclient: server requested update %s -> %s", a.cfg.Version, ack.Update.Version)
+		return true, a.applySelfUpdate(ctx, ack.Update)
+	}
+
+	if spooled > 0 {
+		sent, err := a.spool.Drain(func(raw json.RawMessage) error {
+			return syncnode.WriteStreamMessage(conn, raw)
+		})
+		if err == nil {
+			err = syncnode.WriteStreamMessage(conn, map[string]any{"type": "spool_done"})
+		}
+		if err != nil {
+			conn.Close()
+			return true, fmt.Errorf("deliver spooled messages: %w", err)
+		}
+		log.Printf("nodeclient: delivered %d spooled messages", sent)
 	}
 
 	log.Printf("nodeclient: tcp connected, waiting for tasks")
@@ -107,7 +134,7 @@ func (a *Agent) connectAndServeTCP(ctx context.Context) error {
 		select {
 		case <-ctx.Done():
 			_ = conn.Close()
-			return nil
+			return true, nil
 		default:
 			var msg struct {
 				Type string       `json:"type"`
@@ -115,7 +142,7 @@ func (a *Agent) connectAndServeTCP(ctx context.Context) error {
 			}
 			if err := syncnode.ReadStreamMessage(conn, &msg); err != nil {
 				log.Printf("nodeclient: tcp read error: %v", err)
-				return err
+				return true, err
 			}
 			switch msg.Type {
 			case "task":
@@ -123,8 +150,18 @@ func (a *Agent) connectAndServeTCP(ctx context.Context) error {
 					a.runTaskTCP(ctx, conn, &msg.Task)
 				}
 			case "server_ping":
+				// Deliver queued local hook reports and logs first, the server reads them until the status arrives.
+				if _, err := a.spool.Drain(func(raw json.RawMessage) error {
+					return syncnode.WriteStreamMessage(conn, raw)
+				}); err != nil {
+					log.Printf("nodeclient: deliver queued reports failed: %v", err)
+				}
+				if err := a.logs.ship(conn); err != nil {
+					log.Printf("nodeclient: ship logs failed: %v", err)
+				}
 				// Respond with lightweight runtime status snapshot (in-memory on server).
-				status := collectRuntimeStatus(ctx, a.cfg.ID)
+				status := collectRuntimeStatus(ctx, a.cfg.ID, a.cfg.WorkDir)
+				status.SpoolSize = a.spool.Len()
 				_ = syncnode.WriteStreamMessage(conn, status)
 			default:
 				// ignore
@@ -141,7 +178,7 @@ func agentFeatures() []string {
 	return nil
 }
 
-func loadOrCreateClientTLS(dir string, serverFPOverride string) (*tls.Config, error) {
+func loadOrCreateClientTLS(dir string, serverFPOverride string, fpFile string) (*tls.Config, error) {
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return nil, err
 	}
@@ -164,7 +201,6 @@ func loadOrCreateClientTLS(dir string, serverFPOverride string) (*tls.Config, er
 	if serverFP == "" {
 		serverFP = strings.TrimSpace(os.Getenv("SYNC_SERVER_FINGERPRINT"))
 	}
-	fpFile := filepath.Join(dir, "server.fp")
 	if serverFP == "" {
 		if b, err := os.ReadFile(fpFile); err == nil {
 			serverFP = strings.TrimSpace(string(b))
@@ -211,7 +247,7 @@ func generateSelfSignedCert(certPath, keyPath, cn string) error {
 	return nil
 }
 
-func (a *Agent) enrollNode(ctx context.Context, conn net.Conn, endpoint string) error {
+func (a *Agent) enrollNode(ctx context.Context, conn net.Conn) error {
 	if strings.TrimSpace(a.cfg.Token) == "" {
 		return errors.New("missing node token")
 	}
@@ -243,7 +279,7 @@ func (a *Agent) enrollNode(ctx context.Context, conn net.Conn, endpoint string)
 	}
 	a.cfg.ID = ack.NodeID
 	log.Printf("nodeclient: enrolled node id %d", a.cfg.ID)
-	a.saveState(endpoint)
+	a.saveState(strings.Join(a.endpoints, ","))
 	return nil
 }
 
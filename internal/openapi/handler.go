@@ -0,0 +1,53 @@
+package openapi
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// swaggerUIVersion swagger-ui-dist release loaded by the UI page
+const swaggerUIVersion = "5.17.14"
+
+const swaggerPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>GoHook API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@{{version}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@{{version}}/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({
+      url: "openapi.json",
+      dom_id: "#swagger-ui",
+      persistAuthorization: true
+    });
+  </script>
+</body>
+</html>
+`
+
+// Register serve the document at /openapi.json and the swagger UI at /swagger.
+// The document is generated on first request so that every route is registered by then.
+func Register(r *gin.Engine, version string) {
+	var (
+		once sync.Once
+		doc  *Document
+	)
+	r.GET("/openapi.json", func(c *gin.Context) {
+		once.Do(func() {
+			doc = Generate(r.Routes(), Info{Version: version})
+		})
+		c.JSON(http.StatusOK, doc)
+	})
+
+	page := strings.ReplaceAll(swaggerPage, "{{version}}", swaggerUIVersion)
+	r.GET("/swagger", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+	})
+}
@@ -0,0 +1,333 @@
+// Package openapi generates an OpenAPI 3 document from the registered gin routes
+// and serves it together with a swagger UI page.
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/httpcache"
+	"github.com/mycoool/gohook/internal/i18n"
+)
+
+// Security scheme names
+const (
+	SecurityToken      = "tokenAuth"  // X-GoHook-Key header with a login JWT
+	SecurityAgentToken = "agentToken" // X-Sync-Token header with a sync node token
+	SecurityBasic      = "basicAuth"  // HTTP basic auth, login only
+)
+
+// Document OpenAPI 3 document
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+// Info document info
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Components reusable schemas and security schemes
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme OpenAPI security scheme
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// Operation OpenAPI operation
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security"`
+}
+
+// Parameter OpenAPI parameter
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody OpenAPI request body
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response OpenAPI response
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType OpenAPI media type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Spec optional details for a route; routes without a spec are still documented
+type Spec struct {
+	Summary  string
+	Request  interface{} // zero value of the JSON request body type
+	Response interface{} // zero value of the JSON response body type
+	// Security scheme required by the route, SecurityToken by default; "-" marks a public route
+	Security string
+}
+
+var (
+	specsMu sync.RWMutex
+	specs   = map[string]Spec{}
+	aliases = map[string]gin.RouteInfo{} // "METHOD path" of a route -> the route serving it
+	served  = map[string]bool{}          // "METHOD path" of the routes documented through an alias
+	// "METHOD path" of the edits refused without an If-Match header naming the current revision
+	conditional = map[string]bool{}
+)
+
+// Describe attach details to the route registered with method and gin path
+func Describe(method, path string, spec Spec) {
+	specsMu.Lock()
+	specs[method+" "+path] = spec
+	specsMu.Unlock()
+}
+
+// Conditional document the route registered with method and gin path as an edit that needs an
+// If-Match header with the revision of the resource
+func Conditional(method, path string) {
+	specsMu.Lock()
+	conditional[method+" "+path] = true
+	specsMu.Unlock()
+}
+
+// Alias document the route registered with method and gin path as the route target, which
+// serves its requests and is left out of the document
+func Alias(method, path string, target gin.RouteInfo) {
+	specsMu.Lock()
+	aliases[method+" "+path] = target
+	served[target.Method+" "+target.Path] = true
+	specsMu.Unlock()
+}
+
+// aliasFor route serving the route registered with method and gin path, the route itself
+// without an alias; hidden reports a route documented through an alias
+func aliasFor(route gin.RouteInfo) (target gin.RouteInfo, hidden bool) {
+	specsMu.RLock()
+	defer specsMu.RUnlock()
+	if served[route.Method+" "+route.Path] {
+		return route, true
+	}
+	if target, ok := aliases[route.Method+" "+route.Path]; ok {
+		return target, false
+	}
+	return route, false
+}
+
+func specFor(method, path string) (Spec, bool) {
+	specsMu.RLock()
+	defer specsMu.RUnlock()
+	spec, ok := specs[method+" "+path]
+	return spec, ok
+}
+
+func isConditional(method, path string) bool {
+	specsMu.RLock()
+	defer specsMu.RUnlock()
+	return conditional[method+" "+path]
+}
+
+// skipPaths routes that are not part of the HTTP API
+var skipPaths = map[string]bool{
+	"/":                    true,
+	"/index.html":          true,
+	"/favicon.ico":         true,
+	"/manifest.json":       true,
+	"/asset-manifest.json": true,
+	"/static/*filepath":    true,
+	"/openapi.json":        true,
+	"/swagger":             true,
+}
+
+// documentedMethods HTTP methods emitted in the document (r.Any also registers CONNECT etc.)
+var documentedMethods = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true,
+}
+
+var pathParamPattern = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// versionSegment version of a versioned API path such as /api/v1
+var versionSegment = regexp.MustCompile(`^v[0-9]+$`)
+
+// Generate build the document for routes
+func Generate(routes gin.RoutesInfo, info Info) *Document {
+	if info.Title == "" {
+		info.Title = "GoHook API"
+	}
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   map[string]map[string]*Operation{},
+		Components: Components{
+			SecuritySchemes: map[string]*SecurityScheme{
+				SecurityToken:      {Type: "apiKey", In: "header", Name: "X-GoHook-Key"},
+				SecurityAgentToken: {Type: "apiKey", In: "header", Name: "X-Sync-Token"},
+				SecurityBasic:      {Type: "http", Scheme: "basic"},
+			},
+		},
+	}
+	builder := newSchemaBuilder()
+	usedIDs := map[string]bool{}
+
+	sorted := append(gin.RoutesInfo(nil), routes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Path != sorted[j].Path {
+			return sorted[i].Path < sorted[j].Path
+		}
+		return sorted[i].Method < sorted[j].Method
+	})
+
+	for _, route := range sorted {
+		if skipPaths[route.Path] || !documentedMethods[route.Method] {
+			continue
+		}
+		target, hidden := aliasFor(route)
+		if hidden {
+			continue
+		}
+		apiPath := pathParamPattern.ReplaceAllString(route.Path, "{$1}")
+		spec, _ := specFor(target.Method, target.Path)
+
+		op := &Operation{
+			OperationID: operationID(target, usedIDs),
+			Summary:     spec.Summary,
+			Tags:        []string{routeTag(route.Path)},
+			Responses:   map[string]*Response{},
+			Security:    []map[string][]string{},
+		}
+		if op.Summary == "" {
+			op.Summary = summaryFromHandler(target.Handler)
+		}
+		for _, m := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			op.Parameters = append(op.Parameters, Parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+		if isConditional(target.Method, target.Path) {
+			op.Parameters = append(op.Parameters, Parameter{Name: "If-Match", In: "header", Required: true, Schema: &Schema{Type: "string"}})
+		}
+		switch spec.Security {
+		case "-":
+		case "":
+			op.Security = append(op.Security, map[string][]string{SecurityToken: {}})
+		default:
+			op.Security = append(op.Security, map[string][]string{spec.Security: {}})
+		}
+		if spec.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]*MediaType{"application/json": {Schema: builder.schemaFor(reflect.TypeOf(spec.Request))}},
+			}
+		}
+		ok := &Response{Description: "OK"}
+		if spec.Response != nil {
+			ok.Content = map[string]*MediaType{"application/json": {Schema: builder.schemaFor(reflect.TypeOf(spec.Response))}}
+		}
+		op.Responses["200"] = ok
+		errorContent := map[string]*MediaType{"application/json": {Schema: builder.schemaFor(reflect.TypeOf(i18n.ErrorResponse{}))}}
+		if spec.Security != "-" {
+			op.Responses["401"] = &Response{Description: "Missing or invalid credentials", Content: errorContent}
+		}
+		if isConditional(target.Method, target.Path) {
+			op.Responses["409"] = &Response{Description: "The resource changed since the revision named in If-Match, the body holds the current revision and state",
+				Content: map[string]*MediaType{"application/json": {Schema: builder.schemaFor(reflect.TypeOf(httpcache.ConflictResponse{}))}}}
+			op.Responses["428"] = &Response{Description: "If-Match header missing", Content: errorContent}
+		}
+		op.Responses["default"] = &Response{Description: "Error message in the language of Accept-Language or the user preference, with a stable code", Content: errorContent}
+
+		if doc.Paths[apiPath] == nil {
+			doc.Paths[apiPath] = map[string]*Operation{}
+		}
+		doc.Paths[apiPath][strings.ToLower(route.Method)] = op
+	}
+
+	doc.Components.Schemas = builder.components
+	return doc
+}
+
+// routeTag group operations by their first meaningful path segment
+func routeTag(p string) string {
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	if len(parts) > 1 && parts[0] == "api" {
+		parts = parts[1:]
+	}
+	if len(parts) > 1 && versionSegment.MatchString(parts[0]) {
+		parts = parts[1:]
+	}
+	if len(parts) == 0 || parts[0] == "" || strings.HasPrefix(parts[0], ":") || strings.HasPrefix(parts[0], "*") {
+		return "default"
+	}
+	return parts[0]
+}
+
+// handlerName short name of a named handler function, empty for closures
+func handlerName(handler string) string {
+	name := handler[strings.LastIndex(handler, "/")+1:]
+	name = name[strings.LastIndex(name, ".")+1:]
+	name = strings.TrimSuffix(name, "-fm") // method values
+	if name == "" || strings.HasPrefix(name, "func") || unicode.IsDigit(rune(name[0])) {
+		return ""
+	}
+	return name
+}
+
+// operationID unique id from the handler name, or from method and path
+func operationID(route gin.RouteInfo, used map[string]bool) string {
+	id := handlerName(route.Handler)
+	if id == "" || used[id] {
+		id = strings.ToLower(route.Method) + pathParamPattern.ReplaceAllStringFunc(route.Path, func(m string) string {
+			return "By" + strings.ToUpper(m[1:2]) + m[2:]
+		})
+		id = strings.NewReplacer("/", "_", "-", "_").Replace(id)
+	}
+	for base, i := id, 2; used[id]; i++ {
+		id = base + "_" + strconv.Itoa(i)
+	}
+	used[id] = true
+	return id
+}
+
+// summaryFromHandler "HandleSwitchTag" -> "Switch tag"
+func summaryFromHandler(handler string) string {
+	name := strings.TrimPrefix(handlerName(handler), "Handle")
+	if name == "" {
+		return ""
+	}
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteByte(' ')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
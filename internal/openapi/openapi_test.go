@@ -0,0 +1,86 @@
+package openapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testItem struct {
+	ID      uint      `json:"id"`
+	Name    string    `json:"name,omitempty"`
+	Secret  string    `json:"-"`
+	Created time.Time `json:"created"`
+	Child   *testItem `json:"child,omitempty"`
+	Tags    []string  `json:"tags"`
+}
+
+func HandleGetItem(*gin.Context) {}
+
+func TestGenerate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/items/:name", HandleGetItem)
+	r.POST("/public/*path", func(*gin.Context) {})
+	r.GET("/static/*filepath", func(*gin.Context) {})
+	r.PUT("/api/items/:name", func(*gin.Context) {})
+
+	Describe("GET", "/api/items/:name", Spec{Response: []testItem{}})
+	Describe("POST", "/public/*path", Spec{Security: "-"})
+	Conditional("PUT", "/api/items/:name")
+
+	doc := Generate(r.Routes(), Info{Version: "1.2.3"})
+	if doc.Info.Version != "1.2.3" {
+		t.Fatalf("version = %q", doc.Info.Version)
+	}
+	if _, ok := doc.Paths["/static/{filepath}"]; ok {
+		t.Fatalf("ui routes must not be documented")
+	}
+
+	op := doc.Paths["/api/items/{name}"]["get"]
+	if op == nil {
+		t.Fatalf("missing operation, paths: %v", doc.Paths)
+	}
+	if op.OperationID != "HandleGetItem" || op.Summary != "Get item" || op.Tags[0] != "items" {
+		t.Fatalf("unexpected operation: %+v", op)
+	}
+	if len(op.Parameters) != 1 || op.Parameters[0].Name != "name" || op.Parameters[0].In != "path" {
+		t.Fatalf("unexpected parameters: %+v", op.Parameters)
+	}
+	if op.Responses["428"] != nil {
+		t.Fatalf("unconditional operation documents 428")
+	}
+	update := doc.Paths["/api/items/{name}"]["put"]
+	if len(update.Parameters) != 2 || update.Parameters[1].Name != "If-Match" || !update.Parameters[1].Required {
+		t.Fatalf("If-Match not documented: %+v", update.Parameters)
+	}
+	if update.Responses["409"] == nil || update.Responses["428"] == nil {
+		t.Fatalf("conflict responses not documented: %v", update.Responses)
+	}
+	if len(op.Security) != 1 || op.Security[0][SecurityToken] == nil {
+		t.Fatalf("expected token security, got %+v", op.Security)
+	}
+	if ref := op.Responses["200"].Content["application/json"].Schema.Items.Ref; ref != "#/components/schemas/testItem" {
+		t.Fatalf("response ref = %q", ref)
+	}
+
+	item := doc.Components.Schemas["testItem"]
+	if item == nil {
+		t.Fatalf("missing component schema")
+	}
+	if _, ok := item.Properties["Secret"]; ok {
+		t.Fatalf(`json:"-" field must be skipped`)
+	}
+	if item.Properties["created"].Format != "date-time" || item.Properties["tags"].Items.Type != "string" {
+		t.Fatalf("unexpected properties: %+v", item.Properties)
+	}
+	if item.Properties["child"].Ref != "#/components/schemas/testItem" {
+		t.Fatalf("recursive type not referenced: %+v", item.Properties["child"])
+	}
+
+	public := doc.Paths["/public/{path}"]["post"]
+	if public == nil || len(public.Security) != 0 || public.OperationID != "post_public_ByPath" {
+		t.Fatalf("unexpected public operation: %+v", public)
+	}
+}
@@ -0,0 +1,189 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/mycoool/gohook/internal/stream"
+	"github.com/mycoool/gohook/internal/urls"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+// TriggerRequest params of the trigger call a plugin makes to run a hook
+type TriggerRequest struct {
+	Hook    string            `json:"hook"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Payload json.RawMessage   `json:"payload,omitempty"` // body of the delivery, JSON unless Content-Type says otherwise
+}
+
+// TriggerResult answer of the hook endpoint to a trigger call
+type TriggerResult struct {
+	Status int    `json:"status"`
+	Body   string `json:"body"`
+}
+
+// TransformRequest params of the transform call, the plugin answers with the new payload or
+// rejects the request
+type TransformRequest struct {
+	Hook    string                 `json:"hook"`
+	Headers map[string]interface{} `json:"headers"`
+	Query   map[string]interface{} `json:"query"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// hostCall answer a call of plugin p into gohook
+func hostCall(p *Plugin, method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "trigger":
+		if !p.Info.Has(CapabilityTrigger) {
+			return nil, fmt.Errorf("plugin %s lacks the %s capability", p.Name, CapabilityTrigger)
+		}
+		if !p.active.Load() {
+			return nil, ErrNotRunning
+		}
+		var req TriggerRequest
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, fmt.Errorf("invalid trigger params: %v", err)
+		}
+		return trigger(p.Name, req)
+	default:
+		return nil, &RPCError{Code: codeMethodNotFound, Message: "method not found: " + method}
+	}
+}
+
+// trigger deliver the payload of a plugin to a hook through the hook endpoint, so trigger
+// rules, idempotency and maintenance pauses apply as for HTTP webhooks
+func trigger(name string, req TriggerRequest) (*TriggerResult, error) {
+	if req.Hook == "" {
+		return nil, fmt.Errorf("hook is required")
+	}
+	headers := map[string]string{}
+	for k, v := range req.Headers {
+		headers[k] = v
+	}
+	body := req.Payload
+	var s string
+	if json.Unmarshal(body, &s) == nil {
+		// a JSON string is delivered as is, for form or XML bodies
+		body = []byte(s)
+	} else if _, ok := lookupHeader(headers, "Content-Type"); !ok && len(bytes.TrimSpace(body)) > 0 {
+		headers["Content-Type"] = "application/json"
+	}
+	headers["X-Gohook-Plugin"] = name
+
+	result, err := webhook.SendTestDelivery(&webhook.TestDelivery{
+		Method:  http.MethodPost,
+		URL:     urls.Current().HookPath(req.Hook),
+		Headers: headers,
+		Body:    string(body),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TriggerResult{Status: result.Status, Body: result.Body}, nil
+}
+
+func lookupHeader(headers map[string]string, name string) (string, bool) {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Notify stream listener passing every broadcast event to the enabled notifier plugins, an
+// event is dropped for a plugin whose queue is full
+func Notify(msg stream.WsMessage) {
+	registry.mu.RLock()
+	plugins := registry.plugins
+	registry.mu.RUnlock()
+	for _, p := range plugins {
+		if !p.Info.Has(CapabilityNotifier) {
+			continue
+		}
+		p.mu.Lock()
+		if p.events != nil {
+			select {
+			case p.events <- msg:
+			default:
+				log.Printf("plugin %s: event queue full, dropped %s", p.Name, msg.Type)
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// deliverEvents pass the events of a notifier plugin to it until events is closed
+func deliverEvents(name string, c conn, events <-chan stream.WsMessage) {
+	for msg := range events {
+		if err := invoke(c, "notify", msg, nil); err != nil {
+			log.Printf("plugin %s: notify %s: %v", name, msg.Type, err)
+		}
+	}
+}
+
+// RejectError hook request rejected by a transformer plugin
+type RejectError struct {
+	Plugin  string
+	Status  int // HTTP status of the answer, 403 when the plugin set none
+	Message string
+}
+
+func (e *RejectError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("rejected by plugin %s", e.Plugin)
+	}
+	return fmt.Sprintf("rejected by plugin %s: %s", e.Plugin, e.Message)
+}
+
+// Transform pass the payload of a hook request through the transformer plugins names in order.
+// A plugin answering without a payload keeps it unchanged, one answering with reject stops the
+// request with a *RejectError.
+func Transform(names []string, req TransformRequest) (map[string]interface{}, error) {
+	for _, name := range names {
+		p := lookupName(name)
+		if p == nil {
+			return nil, fmt.Errorf("transform plugin %s not found", name)
+		}
+		if !p.Info.Has(CapabilityTransformer) {
+			return nil, fmt.Errorf("plugin %s lacks the %s capability", name, CapabilityTransformer)
+		}
+		c := p.running()
+		if c == nil {
+			return nil, fmt.Errorf("transform plugin %s is disabled", name)
+		}
+		var result struct {
+			Payload json.RawMessage `json:"payload"`
+			Reject  bool            `json:"reject"`
+			Status  int             `json:"status"`
+			Message string          `json:"message"`
+		}
+		if err := invoke(c, "transform", req, &result); err != nil {
+			return nil, fmt.Errorf("transform plugin %s: %v", name, err)
+		}
+		if result.Reject {
+			status := result.Status
+			if status < 400 || status > 599 {
+				status = http.StatusForbidden
+			}
+			return nil, &RejectError{Plugin: name, Status: status, Message: result.Message}
+		}
+		if len(result.Payload) == 0 || string(result.Payload) == "null" {
+			continue
+		}
+		// numbers stay json.Number like in payloads parsed from requests
+		decoder := json.NewDecoder(bytes.NewReader(result.Payload))
+		decoder.UseNumber()
+		var payload map[string]interface{}
+		if err := decoder.Decode(&payload); err != nil {
+			return nil, fmt.Errorf("transform plugin %s: payload is not an object: %v", name, err)
+		}
+		req.Payload = payload
+	}
+	return req.Payload, nil
+}
@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	goplugin "plugin"
+)
+
+// goCallFunc signature of the GohookCall symbol of Go plugins, params and the result are JSON
+type goCallFunc = func(method string, params []byte) ([]byte, error)
+
+// goConn Go plugin (.so built with -buildmode=plugin) exporting
+//
+//	func GohookCall(method string, params []byte) ([]byte, error)
+//
+// and optionally
+//
+//	func GohookSetHost(call func(method string, params []byte) ([]byte, error))
+//
+// which receives the function calling back into gohook. Go plugins cannot be unloaded, they
+// stay open once discovered.
+type goConn struct {
+	fn goCallFunc
+}
+
+// openGo load the Go plugin path
+func openGo(path string, host hostHandler) (*goConn, error) {
+	p, err := goplugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup("GohookCall")
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := sym.(goCallFunc)
+	if !ok {
+		return nil, fmt.Errorf("GohookCall of %s has type %T, want %T", path, sym, goCallFunc(nil))
+	}
+	if sym, err := p.Lookup("GohookSetHost"); err == nil {
+		setHost, ok := sym.(func(goCallFunc))
+		if !ok {
+			return nil, fmt.Errorf("GohookSetHost of %s has type %T", path, sym)
+		}
+		setHost(func(method string, params []byte) ([]byte, error) {
+			result, err := host(method, params)
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(result)
+		})
+	}
+	return &goConn{fn: fn}, nil
+}
+
+func (c *goConn) call(ctx context.Context, method string, params, result interface{}) error {
+	var raw []byte
+	if params != nil {
+		var err error
+		if raw, err = json.Marshal(params); err != nil {
+			return err
+		}
+	}
+
+	type answer struct {
+		data []byte
+		err  error
+	}
+	// the plugin cannot be interrupted, a call running past ctx keeps running in the background
+	ch := make(chan answer, 1)
+	go func() {
+		data, err := c.fn(method, raw)
+		ch <- answer{data, err}
+	}()
+	select {
+	case a := <-ch:
+		if a.err != nil {
+			return a.err
+		}
+		if result == nil || len(a.data) == 0 {
+			return nil
+		}
+		return json.Unmarshal(a.data, result)
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %v", method, ctx.Err())
+	}
+}
+
+func (c *goConn) close() {}
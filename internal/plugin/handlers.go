@@ -0,0 +1,214 @@
+package plugin
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+)
+
+// maxConfigSize largest plugin configuration accepted by POST /plugin/:id/config
+const maxConfigSize = 1 << 20
+
+// pluginID parse the :id parameter, answering 400 when it is invalid
+func pluginID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidPluginID, nil)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// answerError answer err of a plugin API call, code names the call
+func answerError(c *gin.Context, code string, err error) {
+	var configErr *ConfigError
+	var moduleErr *ModuleError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodePluginNotFound, nil)
+	case errors.Is(err, ErrNotRunning):
+		i18n.JSONError(c, http.StatusConflict, i18n.CodePluginNotRunning, nil)
+	case errors.Is(err, ErrNotWasm):
+		i18n.JSONError(c, http.StatusConflict, code, err)
+	case errors.As(err, &configErr), errors.As(err, &moduleErr), errors.Is(err, ErrInvalidName):
+		i18n.JSONError(c, http.StatusBadRequest, code, err)
+	default:
+		i18n.JSONError(c, http.StatusBadGateway, code, err)
+	}
+}
+
+// requireCapability answer 400 unless the plugin id has the capability
+func requireCapability(c *gin.Context, id uint, capability string) (Status, string, bool) {
+	status, config, err := Get(id)
+	if err != nil {
+		answerError(c, i18n.CodeLoadPluginFailed, err)
+		return status, "", false
+	}
+	for _, have := range status.Capabilities {
+		if have == capability {
+			return status, config, true
+		}
+	}
+	i18n.JSONErrorf(c, http.StatusBadRequest, i18n.CodePluginLacksCapability, capability)
+	return status, "", false
+}
+
+// HandleListPlugins list the plugins found in the plugins directory
+func HandleListPlugins(c *gin.Context) {
+	c.JSON(http.StatusOK, List())
+}
+
+// HandleGetPluginConfig answer the YAML configuration of a configurer plugin
+func HandleGetPluginConfig(c *gin.Context) {
+	id, ok := pluginID(c)
+	if !ok {
+		return
+	}
+	_, config, ok := requireCapability(c, id, CapabilityConfigurer)
+	if !ok {
+		return
+	}
+	c.Data(http.StatusOK, "application/x-yaml", []byte(config))
+}
+
+// HandleUpdatePluginConfig replace the YAML configuration of a configurer plugin, an enabled
+// plugin is reconfigured at once and may reject it
+func HandleUpdatePluginConfig(c *gin.Context) {
+	id, ok := pluginID(c)
+	if !ok {
+		return
+	}
+	status, _, ok := requireCapability(c, id, CapabilityConfigurer)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxConfigSize+1))
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeReadPluginConfigFailed, err)
+		return
+	}
+	if len(body) > maxConfigSize {
+		i18n.JSONError(c, http.StatusRequestEntityTooLarge, i18n.CodePluginConfigTooLarge, nil)
+		return
+	}
+
+	err = SetConfig(id, string(body))
+	logPluginAction(c, database.UserActionUpdatePluginConfig, status.Name, err)
+	if err != nil {
+		answerError(c, i18n.CodeUpdatePluginConfigFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodePluginConfigUpdated), "code": i18n.CodePluginConfigUpdated})
+}
+
+// HandleGetPluginDisplay answer the markdown status page of an enabled displayer plugin
+func HandleGetPluginDisplay(c *gin.Context) {
+	id, ok := pluginID(c)
+	if !ok {
+		return
+	}
+	if _, _, ok := requireCapability(c, id, CapabilityDisplayer); !ok {
+		return
+	}
+	page, err := Display(id)
+	if err != nil {
+		answerError(c, i18n.CodePluginDisplayFailed, err)
+		return
+	}
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(page))
+}
+
+// HandleEnablePlugin start a plugin and keep it enabled across restarts
+func HandleEnablePlugin(c *gin.Context) {
+	setEnabled(c, true)
+}
+
+// HandleDisablePlugin stop a plugin and keep it disabled across restarts
+func HandleDisablePlugin(c *gin.Context) {
+	setEnabled(c, false)
+}
+
+func setEnabled(c *gin.Context, enabled bool) {
+	id, ok := pluginID(c)
+	if !ok {
+		return
+	}
+	action, code := database.UserActionEnablePlugin, i18n.CodePluginEnabled
+	if !enabled {
+		action, code = database.UserActionDisablePlugin, i18n.CodePluginDisabled
+	}
+	status, err := SetEnabled(id, enabled)
+	if errors.Is(err, ErrNotFound) {
+		answerError(c, i18n.CodeSetPluginStateFailed, err)
+		return
+	}
+	logPluginAction(c, action, status.Name, err)
+	if err != nil {
+		answerError(c, i18n.CodeSetPluginStateFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, code), "code": code, "plugin": status})
+}
+
+// HandleInstallWasmPlugin upload the request body as the WebAssembly transformer plugin
+// :name, replacing the module of an existing one
+func HandleInstallWasmPlugin(c *gin.Context) {
+	name := c.Param("name")
+	module, err := io.ReadAll(io.LimitReader(c.Request.Body, MaxWasmModuleSize+1))
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeReadPluginModuleFailed, err)
+		return
+	}
+	if len(module) > MaxWasmModuleSize {
+		i18n.JSONError(c, http.StatusRequestEntityTooLarge, i18n.CodePluginModuleTooLarge, nil)
+		return
+	}
+
+	status, err := Install(name, module)
+	logPluginAction(c, database.UserActionInstallPlugin, name, err)
+	if err != nil {
+		answerError(c, i18n.CodeInstallPluginFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodePluginInstalled), "code": i18n.CodePluginInstalled, "plugin": status})
+}
+
+// HandleRemoveWasmPlugin delete the uploaded WebAssembly plugin :name
+func HandleRemoveWasmPlugin(c *gin.Context) {
+	name := c.Param("name")
+	err := Remove(name)
+	if errors.Is(err, ErrNotFound) {
+		answerError(c, i18n.CodeRemovePluginFailed, err)
+		return
+	}
+	logPluginAction(c, database.UserActionRemovePlugin, name, err)
+	if err != nil {
+		answerError(c, i18n.CodeRemovePluginFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodePluginRemoved), "code": i18n.CodePluginRemoved})
+}
+
+// logPluginAction record a change of a plugin in the user activity log
+func logPluginAction(c *gin.Context, action, name string, err error) {
+	details := map[string]interface{}{"plugin": name}
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	database.LogUserAction(
+		c.GetString("username"),
+		action,
+		"plugin:"+name,
+		"",
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		err == nil,
+		details,
+	)
+}
@@ -0,0 +1,557 @@
+// Package plugin loads extensions from the plugins directory: executables speaking JSON-RPC 2.0
+// over stdin and stdout, and Go plugins built with -buildmode=plugin. Enabled plugins can
+// trigger hooks, receive the events broadcast to the UI and rewrite hook payloads.
+package plugin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/stream"
+	"github.com/mycoool/gohook/internal/types"
+	"gopkg.in/yaml.v2"
+)
+
+// plugin capabilities, reported by the info method
+const (
+	CapabilityConfigurer  = "configurer"  // takes a YAML configuration
+	CapabilityDisplayer   = "displayer"   // renders a markdown status page
+	CapabilityNotifier    = "notifier"    // receives the events broadcast to the UI
+	CapabilityTransformer = "transformer" // rewrites the payload of hooks listing it in transform-plugins
+	CapabilityTrigger     = "trigger"     // triggers hooks
+)
+
+// plugin kinds
+const (
+	KindExec = "exec"
+	KindGo   = "go"
+	KindWasm = "wasm"
+)
+
+// notifyQueue events waiting for a notifier plugin, further ones are dropped
+const notifyQueue = 64
+
+// Info what a plugin reports about itself
+type Info struct {
+	Name          string   `json:"name"`
+	Version       string   `json:"version,omitempty"`
+	Author        string   `json:"author,omitempty"`
+	Website       string   `json:"website,omitempty"`
+	License       string   `json:"license,omitempty"`
+	Capabilities  []string `json:"capabilities"`
+	DefaultConfig string   `json:"defaultConfig,omitempty"` // YAML stored when the plugin is first seen
+}
+
+// Has the plugin reported the capability
+func (i Info) Has(capability string) bool {
+	for _, c := range i.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Plugin plugin found in the plugins directory
+type Plugin struct {
+	ID    uint
+	Name  string // file name without extension, unique
+	Path  string
+	Kind  string // exec, go or wasm
+	Info  Info
+	Token string
+
+	// mu serializes enabling, disabling and configuring the plugin, it is not held while the
+	// plugin answers events, transforms or display calls
+	mu        sync.Mutex
+	Enabled   bool
+	Config    string
+	LastError string
+	conn      conn                  // set while enabled, Go and WebAssembly plugins keep it once loaded
+	events    chan stream.WsMessage // events of a running notifier
+	active    atomic.Bool           // enabled, read by calls coming from plugins without mu
+}
+
+// Status plugin in the shape the plugin list of the UI expects
+type Status struct {
+	ID           uint     `json:"id"`
+	Token        string   `json:"token"`
+	Name         string   `json:"name"`
+	ModulePath   string   `json:"modulePath"`
+	Kind         string   `json:"kind"`
+	Enabled      bool     `json:"enabled"`
+	Version      string   `json:"version,omitempty"`
+	Author       string   `json:"author,omitempty"`
+	Website      string   `json:"website,omitempty"`
+	License      string   `json:"license,omitempty"`
+	Capabilities []string `json:"capabilities"`
+	LastError    string   `json:"lastError,omitempty"`
+}
+
+var registry struct {
+	mu      sync.RWMutex
+	dir     string
+	plugins []*Plugin
+}
+
+var (
+	// callTimeout limit for a call into a plugin in nanoseconds
+	callTimeout atomic.Int64
+	// wasmPages memory limit of a run of a WebAssembly plugin in pages
+	wasmPages atomic.Uint32
+)
+
+// Load discover the plugins of the configured directory, replacing the ones loaded before, and
+// start those enabled in the database. A plugin that fails to load is skipped and logged.
+func Load(cfg *types.PluginsConfig) error {
+	dir, timeout, memoryMB := types.DefaultPluginsDir, types.DefaultPluginCallTimeout, types.DefaultPluginWASMMemoryMB
+	if cfg != nil {
+		if cfg.Dir != "" {
+			dir = cfg.Dir
+		}
+		if cfg.CallTimeout > 0 {
+			timeout = cfg.CallTimeout
+		}
+		if cfg.WASMMemoryMB > 0 {
+			memoryMB = cfg.WASMMemoryMB
+		}
+	}
+	Close()
+	callTimeout.Store(int64(timeout))
+	wasmPages.Store(uint32(memoryMB << 20 / wasmPageSize))
+	registry.mu.Lock()
+	registry.dir = dir
+	registry.mu.Unlock()
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read plugins directory: %v", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var plugins []*Plugin
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		p := candidate(dir, entry)
+		if p == nil {
+			continue
+		}
+		if seen[p.Name] {
+			log.Printf("plugin %s: %s skipped, another plugin has the same name", p.Name, p.Path)
+			continue
+		}
+		seen[p.Name] = true
+		if err := load(p, uint(len(plugins)+1)); err != nil {
+			log.Printf("plugin %s: load %s failed: %v", p.Name, p.Path, err)
+			continue
+		}
+		plugins = append(plugins, p)
+	}
+	if len(plugins) > 0 {
+		log.Printf("Loaded %d plugins from %s", len(plugins), dir)
+	}
+
+	registry.mu.Lock()
+	registry.plugins = plugins
+	registry.mu.Unlock()
+	return nil
+}
+
+// Close stop every running plugin and forget the loaded ones
+func Close() {
+	registry.mu.Lock()
+	plugins := registry.plugins
+	registry.plugins = nil
+	registry.mu.Unlock()
+	for _, p := range plugins {
+		p.mu.Lock()
+		p.active.Store(false)
+		if p.Enabled {
+			stop(p)
+		}
+		if p.conn != nil {
+			p.conn.close()
+			p.conn = nil
+		}
+		p.mu.Unlock()
+	}
+}
+
+// load discover the plugin and restore its stored state, an enabled plugin that fails to
+// start stays loaded and disabled. id is used while the database is not available.
+func load(p *Plugin, id uint) error {
+	if err := discover(p); err != nil {
+		return err
+	}
+	p.ID = id
+	if err := restore(p); err != nil {
+		log.Printf("plugin %s: %v", p.Name, err)
+	}
+	if p.Enabled {
+		if err := start(p); err != nil {
+			p.Enabled = false
+			p.LastError = err.Error()
+			log.Printf("plugin %s: start failed: %v", p.Name, err)
+		}
+	}
+	p.active.Store(p.Enabled)
+	return nil
+}
+
+// candidate plugin of a directory entry: Go plugins end with .so and WebAssembly modules with
+// .wasm, any other executable file is started as an executable plugin. Hidden files and
+// everything else are ignored.
+func candidate(dir string, entry os.DirEntry) *Plugin {
+	name := entry.Name()
+	if strings.HasPrefix(name, ".") {
+		return nil
+	}
+	path, err := filepath.Abs(filepath.Join(dir, name))
+	if err != nil {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	switch ext := filepath.Ext(name); ext {
+	case ".so":
+		return &Plugin{Name: strings.TrimSuffix(name, ext), Path: path, Kind: KindGo}
+	case ".wasm":
+		return &Plugin{Name: strings.TrimSuffix(name, ext), Path: path, Kind: KindWasm}
+	}
+	if info.Mode().Perm()&0111 == 0 {
+		return nil
+	}
+	return &Plugin{Name: strings.TrimSuffix(name, filepath.Ext(name)), Path: path, Kind: KindExec}
+}
+
+// discover ask the plugin for its info. Executables are stopped again, Go plugins and compiled
+// WebAssembly modules stay loaded.
+func discover(p *Plugin) error {
+	c := p.conn
+	if c == nil {
+		var err error
+		if c, err = connect(p); err != nil {
+			return err
+		}
+	}
+	if err := invoke(c, "info", nil, &p.Info); err != nil {
+		c.close()
+		return fmt.Errorf("info: %v", err)
+	}
+	if p.Kind == KindExec {
+		c.close()
+	} else {
+		p.conn = c
+	}
+	return nil
+}
+
+// running connection to the plugin while it is enabled, nil otherwise
+func (p *Plugin) running() conn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.Enabled {
+		return nil
+	}
+	return p.conn
+}
+
+// connect start the plugin process, load the Go plugin or compile the WebAssembly module
+func connect(p *Plugin) (conn, error) {
+	host := func(method string, params json.RawMessage) (interface{}, error) {
+		return hostCall(p, method, params)
+	}
+	switch p.Kind {
+	case KindGo:
+		return openGo(p.Path, host)
+	case KindWasm:
+		return openWasm(p.Name, p.Path, memoryPages())
+	}
+	return startExec(p.Name, p.Path, host)
+}
+
+// restore the enabled state, configuration and token stored for the plugin, a plugin seen
+// for the first time is stored disabled with its default configuration
+func restore(p *Plugin) error {
+	conf, err := database.GetPluginConf(p.Name)
+	if err != nil {
+		return fmt.Errorf("load state: %v", err)
+	}
+	if conf == nil {
+		conf = &database.PluginConf{Name: p.Name, Config: p.Info.DefaultConfig, Token: newToken()}
+		if err := database.SavePluginConf(conf); err != nil {
+			return fmt.Errorf("save state: %v", err)
+		}
+	}
+	if conf.ID != 0 {
+		p.ID = conf.ID
+	}
+	p.Enabled, p.Config, p.Token = conf.Enabled, conf.Config, conf.Token
+	return nil
+}
+
+// save store the enabled state and configuration of the plugin
+func save(p *Plugin) error {
+	conf, err := database.GetPluginConf(p.Name)
+	if err != nil {
+		return err
+	}
+	if conf == nil {
+		conf = &database.PluginConf{Name: p.Name, Token: p.Token}
+	}
+	conf.Enabled, conf.Config = p.Enabled, p.Config
+	return database.SavePluginConf(conf)
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// start connect to the plugin, pass it its configuration and enable it, the caller holds p.mu
+func start(p *Plugin) error {
+	if p.conn == nil {
+		c, err := connect(p)
+		if err != nil {
+			return err
+		}
+		p.conn = c
+	}
+	fail := func(err error) error {
+		if p.Kind == KindExec {
+			p.conn.close()
+			p.conn = nil
+		}
+		return err
+	}
+	if p.Info.Has(CapabilityConfigurer) {
+		if err := invoke(p.conn, "configure", map[string]string{"config": p.Config}, nil); err != nil {
+			return fail(fmt.Errorf("configure: %v", err))
+		}
+	}
+	if err := invoke(p.conn, "enable", nil, nil); err != nil && !isMethodNotFound(err) {
+		return fail(fmt.Errorf("enable: %v", err))
+	}
+	if p.Info.Has(CapabilityNotifier) {
+		p.events = make(chan stream.WsMessage, notifyQueue)
+		go deliverEvents(p.Name, p.conn, p.events)
+	}
+	p.LastError = ""
+	return nil
+}
+
+// stop disable the plugin and stop its process, the caller holds p.mu
+func stop(p *Plugin) {
+	if p.events != nil {
+		close(p.events)
+		p.events = nil
+	}
+	if p.conn == nil {
+		return
+	}
+	if err := invoke(p.conn, "disable", nil, nil); err != nil && !isMethodNotFound(err) {
+		log.Printf("plugin %s: disable: %v", p.Name, err)
+	}
+	if p.Kind == KindExec {
+		p.conn.close()
+		p.conn = nil
+	}
+}
+
+// invoke call a method of a plugin within the configured timeout
+func invoke(c conn, method string, params, result interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout())
+	defer cancel()
+	return c.call(ctx, method, params, result)
+}
+
+func timeout() time.Duration {
+	if d := time.Duration(callTimeout.Load()); d > 0 {
+		return d
+	}
+	return types.DefaultPluginCallTimeout
+}
+
+func memoryPages() uint32 {
+	if pages := wasmPages.Load(); pages > 0 {
+		return pages
+	}
+	return types.DefaultPluginWASMMemoryMB << 20 / wasmPageSize
+}
+
+// List status of the loaded plugins
+func List() []Status {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	list := make([]Status, 0, len(registry.plugins))
+	for _, p := range registry.plugins {
+		p.mu.Lock()
+		list = append(list, status(p))
+		p.mu.Unlock()
+	}
+	return list
+}
+
+// status of the plugin, the caller holds p.mu
+func status(p *Plugin) Status {
+	capabilities := p.Info.Capabilities
+	if capabilities == nil {
+		capabilities = []string{}
+	}
+	return Status{
+		ID:           p.ID,
+		Token:        p.Token,
+		Name:         p.Name,
+		ModulePath:   p.Path,
+		Kind:         p.Kind,
+		Enabled:      p.Enabled,
+		Version:      p.Info.Version,
+		Author:       p.Info.Author,
+		Website:      p.Info.Website,
+		License:      p.Info.License,
+		Capabilities: capabilities,
+		LastError:    p.LastError,
+	}
+}
+
+// lookup loaded plugin by id
+func lookup(id uint) *Plugin {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	for _, p := range registry.plugins {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// lookupName loaded plugin by name
+func lookupName(name string) *Plugin {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	for _, p := range registry.plugins {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// errors of the plugin API
+var (
+	ErrNotFound   = errors.New("plugin not found")
+	ErrNotRunning = errors.New("plugin is disabled")
+)
+
+// SetEnabled enable or disable the plugin id and store the new state
+func SetEnabled(id uint, enabled bool) (Status, error) {
+	p := lookup(id)
+	if p == nil {
+		return Status{}, ErrNotFound
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Enabled == enabled {
+		return status(p), nil
+	}
+	if enabled {
+		if err := start(p); err != nil {
+			p.LastError = err.Error()
+			return status(p), err
+		}
+	} else {
+		p.active.Store(false)
+		stop(p)
+	}
+	p.Enabled = enabled
+	p.active.Store(enabled)
+	if err := save(p); err != nil {
+		log.Printf("plugin %s: save state: %v", p.Name, err)
+	}
+	return status(p), nil
+}
+
+// Get status and YAML configuration of the plugin id
+func Get(id uint) (Status, string, error) {
+	p := lookup(id)
+	if p == nil {
+		return Status{}, "", ErrNotFound
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return status(p), p.Config, nil
+}
+
+// ConfigError configuration rejected as invalid YAML or by the plugin
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+// SetConfig validate and store the YAML configuration of the plugin id, a running plugin is
+// reconfigured first and may reject it
+func SetConfig(id uint, config string) error {
+	var parsed interface{}
+	if err := yaml.Unmarshal([]byte(config), &parsed); err != nil {
+		return &ConfigError{fmt.Errorf("invalid YAML: %v", err)}
+	}
+
+	p := lookup(id)
+	if p == nil {
+		return ErrNotFound
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Enabled {
+		if err := invoke(p.conn, "configure", map[string]string{"config": config}, nil); err != nil {
+			return &ConfigError{fmt.Errorf("rejected by the plugin: %v", err)}
+		}
+	}
+	previous := p.Config
+	p.Config = config
+	if err := save(p); err != nil {
+		p.Config = previous
+		return err
+	}
+	return nil
+}
+
+// Display markdown status page of the running plugin id
+func Display(id uint) (string, error) {
+	p := lookup(id)
+	if p == nil {
+		return "", ErrNotFound
+	}
+	c := p.running()
+	if c == nil {
+		return "", ErrNotRunning
+	}
+	var page string
+	err := invoke(c, "display", nil, &page)
+	return page, err
+}
@@ -0,0 +1,315 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/stream"
+	"github.com/mycoool/gohook/internal/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestHelperPlugin is the executable plugin started by the tests, it does nothing when run
+// as a test
+func TestHelperPlugin(t *testing.T) {
+	if os.Getenv("GOHOOK_TEST_PLUGIN") != "1" {
+		return
+	}
+	notified := 0
+	config := ""
+	out := json.NewEncoder(os.Stdout)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req rpcMessage
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			continue
+		}
+		var result interface{}
+		var rpcErr *RPCError
+		switch req.Method {
+		case "info":
+			result = Info{Name: "echo", Version: "1.0", Capabilities: []string{
+				CapabilityConfigurer, CapabilityDisplayer, CapabilityNotifier, CapabilityTransformer,
+			}, DefaultConfig: "greeting: hi\n"}
+		case "configure":
+			var params struct{ Config string }
+			json.Unmarshal(req.Params, &params)
+			if strings.Contains(params.Config, "bad") {
+				rpcErr = &RPCError{Code: 1, Message: "bad config"}
+			} else {
+				config = params.Config
+			}
+		case "display":
+			result = fmt.Sprintf("notified %d, config %q", notified, config)
+		case "notify":
+			notified++
+		case "transform":
+			var params TransformRequest
+			json.Unmarshal(req.Params, &params)
+			params.Payload["transformed"] = params.Hook
+			result = map[string]interface{}{"payload": params.Payload}
+		default:
+			rpcErr = &RPCError{Code: codeMethodNotFound, Message: "method not found"}
+		}
+		response := rpcMessage{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+		if rpcErr == nil {
+			response.Result, _ = json.Marshal(result)
+		}
+		out.Encode(response)
+	}
+	os.Exit(0)
+}
+
+func setupPlugins(t *testing.T) string {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&database.PluginConf{}); err != nil {
+		t.Fatal(err)
+	}
+	saved := database.DB
+	database.DB = conn
+	t.Cleanup(func() {
+		Close()
+		database.DB = saved
+	})
+
+	t.Setenv("GOHOOK_TEST_PLUGIN", "1")
+	dir := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\nexec %q -test.run=^TestHelperPlugin$\n", os.Args[0])
+	if err := os.WriteFile(filepath.Join(dir, "echo.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// neither executable nor a Go plugin
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("plugins"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLifecycle(t *testing.T) {
+	dir := setupPlugins(t)
+	if err := Load(&types.PluginsConfig{Dir: dir, CallTimeout: 5 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	list := List()
+	if len(list) != 1 || list[0].Name != "echo" || list[0].Kind != KindExec || list[0].Enabled {
+		t.Fatalf("List() = %+v, want the disabled echo plugin", list)
+	}
+	id := list[0].ID
+	if _, config, _ := Get(id); config != "greeting: hi\n" {
+		t.Errorf("config = %q, want the default config", config)
+	}
+	if _, err := Display(id); err != ErrNotRunning {
+		t.Errorf("Display() of a disabled plugin = %v, want ErrNotRunning", err)
+	}
+
+	if _, err := SetEnabled(id, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetConfig(id, "greeting: [unclosed"); err == nil {
+		t.Error("SetConfig() accepted invalid YAML")
+	}
+	if err := SetConfig(id, "greeting: bad\n"); err == nil {
+		t.Error("SetConfig() stored a config the plugin rejected")
+	}
+	if err := SetConfig(id, "greeting: hello\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	Notify(stream.WsMessage{Type: "hook_triggered"})
+	Notify(stream.WsMessage{Type: "version_switched"})
+	want := `notified 2, config "greeting: hello\n"`
+	var page string
+	for i := 0; i < 50; i++ {
+		if page, _ = Display(id); page == want {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if page != want {
+		t.Errorf("Display() = %q, want %q", page, want)
+	}
+
+	payload, err := Transform([]string{"echo"}, TransformRequest{Hook: "build", Payload: map[string]interface{}{"ref": "main"}})
+	if err != nil || payload["transformed"] != "build" || payload["ref"] != "main" {
+		t.Errorf("Transform() = %v, %v", payload, err)
+	}
+	if _, err := Transform([]string{"missing"}, TransformRequest{}); err == nil {
+		t.Error("Transform() with an unknown plugin succeeded")
+	}
+
+	// the enabled state and the config survive a restart
+	if err := Load(&types.PluginsConfig{Dir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if list := List(); len(list) != 1 || list[0].ID != id || !list[0].Enabled {
+		t.Fatalf("List() after reload = %+v", list)
+	}
+	if page, _ := Display(id); page != `notified 0, config "greeting: hello\n"` {
+		t.Errorf("Display() after reload = %q", page)
+	}
+
+	if _, err := SetEnabled(id, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Transform([]string{"echo"}, TransformRequest{}); err == nil {
+		t.Error("Transform() with a disabled plugin succeeded")
+	}
+}
+
+func TestHostCall(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities []string
+		active       bool
+		method       string
+		wantErr      string
+	}{
+		{"unknown method", []string{CapabilityTrigger}, true, "shutdown", "method not found"},
+		{"missing capability", nil, true, "trigger", "lacks the trigger capability"},
+		{"disabled", []string{CapabilityTrigger}, false, "trigger", "plugin is disabled"},
+		{"no hook", []string{CapabilityTrigger}, true, "trigger", "hook is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Plugin{Name: "p", Info: Info{Capabilities: tt.capabilities}}
+			p.active.Store(tt.active)
+			_, err := hostCall(p, tt.method, json.RawMessage(`{}`))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("hostCall() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+var wasmModule struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// buildWasm compile testdata/wasm for WASI, the test is skipped when it cannot be built
+func buildWasm(t *testing.T) []byte {
+	wasmModule.once.Do(func() {
+		dir, err := os.MkdirTemp("", "gohook-wasm")
+		if err != nil {
+			wasmModule.err = err
+			return
+		}
+		defer os.RemoveAll(dir)
+		out := filepath.Join(dir, "transform.wasm")
+		cmd := exec.Command("go", "build", "-o", out, ".")
+		cmd.Dir = filepath.Join("testdata", "wasm")
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			wasmModule.err = fmt.Errorf("%v: %s", err, output)
+			return
+		}
+		wasmModule.data, wasmModule.err = os.ReadFile(out)
+	})
+	if wasmModule.err != nil {
+		t.Skipf("cannot build the WebAssembly module: %v", wasmModule.err)
+	}
+	return wasmModule.data
+}
+
+func TestWasmTransform(t *testing.T) {
+	module := buildWasm(t)
+	dir := setupPlugins(t)
+	if err := Load(&types.PluginsConfig{Dir: dir, CallTimeout: 2 * time.Second, WASMMemoryMB: 32}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Install("../escape", module); err != ErrInvalidName {
+		t.Errorf("Install() with an invalid name = %v, want ErrInvalidName", err)
+	}
+	if _, err := Install("echo", module); err != ErrNotWasm {
+		t.Errorf("Install() over an executable plugin = %v, want ErrNotWasm", err)
+	}
+	var moduleErr *ModuleError
+	if _, err := Install("broken", []byte("not wasm")); !errors.As(err, &moduleErr) {
+		t.Errorf("Install() of an invalid module = %v, want a ModuleError", err)
+	}
+
+	status, err := Install("filter", module)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Kind != KindWasm || status.Enabled {
+		t.Fatalf("Install() = %+v, want a disabled wasm plugin", status)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "filter.wasm")); err != nil {
+		t.Errorf("module not stored: %v", err)
+	}
+	if _, err := SetEnabled(status.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetConfig(status.ID, "team: ops\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		mode       string
+		wantStatus int
+		wantErr    string
+	}{
+		{"transform", "", 0, ""},
+		{"reject", "reject", 422, "not for build"},
+		{"time limit", "loop", 0, "deadline exceeded"},
+		{"memory limit", "alloc", 0, "transform"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := Transform([]string{"filter"}, TransformRequest{
+				Hook:    "build",
+				Payload: map[string]interface{}{"mode": tt.mode},
+			})
+			var rejected *RejectError
+			switch {
+			case tt.wantStatus != 0:
+				if !errors.As(err, &rejected) || rejected.Status != tt.wantStatus || !strings.Contains(rejected.Message, tt.wantErr) {
+					t.Errorf("Transform() = %v, want a rejection with %d", err, tt.wantStatus)
+				}
+			case tt.wantErr != "":
+				if err == nil || errors.As(err, &rejected) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Transform() = %v, want %q", err, tt.wantErr)
+				}
+			default:
+				if err != nil || payload["config"] != "team: ops\n" {
+					t.Errorf("Transform() = %v, %v", payload, err)
+				}
+			}
+		})
+	}
+
+	// an upgrade keeps the plugin enabled
+	if status, err := Install("filter", module); err != nil || !status.Enabled {
+		t.Errorf("Install() upgrade = %+v, %v", status, err)
+	}
+	if err := Remove("filter"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "filter.wasm")); !os.IsNotExist(err) {
+		t.Errorf("module still stored after Remove(): %v", err)
+	}
+	if err := Remove("echo"); err != ErrNotWasm {
+		t.Errorf("Remove() of an executable plugin = %v, want ErrNotWasm", err)
+	}
+	if conf, _ := database.GetPluginConf("filter"); conf != nil {
+		t.Errorf("state of the removed plugin kept: %+v", conf)
+	}
+}
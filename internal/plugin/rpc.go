@@ -0,0 +1,259 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxMessageSize longest JSON-RPC line accepted from a plugin
+const maxMessageSize = 16 << 20
+
+// stopTimeout time an executable gets to exit after its stdin was closed
+const stopTimeout = 5 * time.Second
+
+// JSON-RPC 2.0 error codes used by the protocol
+const (
+	codeMethodNotFound = -32601
+	codeInternalError  = -32603
+)
+
+// errPluginExited calls into an executable that is no longer running
+var errPluginExited = errors.New("plugin process exited")
+
+// RPCError error object of a JSON-RPC response
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// rpcMessage request, notification or response of JSON-RPC 2.0, one per line
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// hostHandler answers the calls a plugin makes into gohook
+type hostHandler func(method string, params json.RawMessage) (interface{}, error)
+
+// conn connection to a loaded plugin
+type conn interface {
+	// call method with params and decode the result into result unless it is nil
+	call(ctx context.Context, method string, params, result interface{}) error
+	close()
+}
+
+// isMethodNotFound the plugin does not implement an optional method
+func isMethodNotFound(err error) bool {
+	var rpcErr *RPCError
+	return errors.As(err, &rpcErr) && rpcErr.Code == codeMethodNotFound
+}
+
+// execConn executable plugin speaking newline-delimited JSON-RPC 2.0 on stdin and stdout,
+// every line it writes to stderr is logged
+type execConn struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	host  hostHandler
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan rpcMessage
+	done    chan struct{}
+	err     error // why the process exited, set before done is closed
+}
+
+// startExec run the executable path as plugin name
+func startExec(name, path string, host hostHandler) (*execConn, error) {
+	cmd := exec.Command(path)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Env = append(os.Environ(), "GOHOOK_PLUGIN_NAME="+name)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %v", path, err)
+	}
+
+	c := &execConn{
+		name:    name,
+		cmd:     cmd,
+		stdin:   stdin,
+		host:    host,
+		pending: map[int64]chan rpcMessage{},
+		done:    make(chan struct{}),
+	}
+	logged := make(chan struct{})
+	go func() {
+		defer close(logged)
+		scanner := bufio.NewScanner(stderr)
+		scanner.Buffer(make([]byte, 4096), maxMessageSize)
+		for scanner.Scan() {
+			log.Printf("plugin %s: %s", name, scanner.Text())
+		}
+	}()
+	go c.read(stdout, logged)
+	return c, nil
+}
+
+// read dispatch the messages of the plugin until its stdout is closed
+func (c *execConn) read(stdout io.Reader, logged <-chan struct{}) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 4096), maxMessageSize)
+	for scanner.Scan() {
+		var msg rpcMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			log.Printf("plugin %s: invalid message: %v", c.name, err)
+			continue
+		}
+		if msg.Method != "" {
+			go c.serve(msg)
+			continue
+		}
+		var id int64
+		if err := json.Unmarshal(msg.ID, &id); err != nil {
+			log.Printf("plugin %s: response with unknown id %s", c.name, msg.ID)
+			continue
+		}
+		c.mu.Lock()
+		ch := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- msg
+		}
+	}
+
+	<-logged
+	err := c.cmd.Wait()
+	if err == nil {
+		err = errPluginExited
+	} else {
+		err = fmt.Errorf("%v: %v", errPluginExited, err)
+	}
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// serve answer a call of the plugin, notifications get no response
+func (c *execConn) serve(msg rpcMessage) {
+	result, err := c.host(msg.Method, msg.Params)
+	if len(msg.ID) == 0 {
+		if err != nil {
+			log.Printf("plugin %s: %s failed: %v", c.name, msg.Method, err)
+		}
+		return
+	}
+	response := rpcMessage{JSONRPC: "2.0", ID: msg.ID}
+	if err != nil {
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &RPCError{Code: codeInternalError, Message: err.Error()}
+		}
+		response.Error = rpcErr
+	} else if response.Result, err = json.Marshal(result); err != nil {
+		response.Error = &RPCError{Code: codeInternalError, Message: err.Error()}
+	}
+	if err := c.write(response); err != nil {
+		log.Printf("plugin %s: answer %s: %v", c.name, msg.Method, err)
+	}
+}
+
+func (c *execConn) write(msg rpcMessage) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = c.stdin.Write(append(line, '\n'))
+	return err
+}
+
+func (c *execConn) call(ctx context.Context, method string, params, result interface{}) error {
+	request := rpcMessage{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		request.Params = raw
+	}
+
+	ch := make(chan rpcMessage, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	request.ID = json.RawMessage(fmt.Sprint(id))
+	if err := c.write(request); err != nil {
+		return fmt.Errorf("%s: %v", method, err)
+	}
+	select {
+	case response := <-ch:
+		if response.Error != nil {
+			return response.Error
+		}
+		if result == nil || len(response.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(response.Result, result)
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %v", method, ctx.Err())
+	}
+}
+
+// close close stdin of the plugin and kill it when it does not exit in stopTimeout
+func (c *execConn) close() {
+	c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(stopTimeout):
+		c.cmd.Process.Kill()
+		<-c.done
+	}
+}
@@ -0,0 +1,34 @@
+// Command wasm is the WebAssembly transformer used by the plugin tests, built with
+// GOOS=wasip1 GOARCH=wasm.
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+func main() {
+	var req struct {
+		Hook    string                 `json:"hook"`
+		Payload map[string]interface{} `json:"payload"`
+	}
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		os.Stderr.WriteString(err.Error())
+		os.Exit(1)
+	}
+	switch req.Payload["mode"] {
+	case "reject":
+		json.NewEncoder(os.Stdout).Encode(map[string]interface{}{"reject": true, "status": 422, "message": "not for " + req.Hook})
+		return
+	case "loop":
+		for {
+		}
+	case "alloc":
+		var chunks [][]byte
+		for {
+			chunks = append(chunks, make([]byte, 1<<20))
+		}
+	}
+	req.Payload["config"] = os.Getenv("GOHOOK_PLUGIN_CONFIG")
+	json.NewEncoder(os.Stdout).Encode(map[string]interface{}{"payload": req.Payload})
+}
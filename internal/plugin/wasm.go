@@ -0,0 +1,305 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// wasmPageSize size of a WebAssembly memory page
+const wasmPageSize = 64 << 10
+
+// limitedBuffer buffer keeping the first max bytes written to it, it fails writes beyond that
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.max {
+		return 0, fmt.Errorf("output larger than %d bytes", b.max)
+	}
+	return b.Buffer.Write(p)
+}
+
+// wasmConn WebAssembly module (WASI preview 1) transforming hook payloads. Every transform
+// runs a new instance of the module: the TransformRequest is its stdin, the transform result
+// is read from its stdout and the YAML configuration is in GOHOOK_PLUGIN_CONFIG. The module
+// has no file system or network access, its memory is capped and a run is cancelled after
+// the call timeout.
+type wasmConn struct {
+	name     string
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+
+	mu     sync.Mutex
+	config string
+}
+
+// compileWasm compile the module of plugin name, limiting instances to pages of memory
+func compileWasm(name string, module []byte, pages uint32) (*wasmConn, error) {
+	ctx := context.Background()
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(pages).
+		WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		runtime.Close(ctx)
+		return nil, err
+	}
+	compiled, err := runtime.CompileModule(ctx, module)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("compile: %v", err)
+	}
+	if _, ok := compiled.ExportedFunctions()["_start"]; !ok {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("module does not export _start, build it as a WASI command")
+	}
+	return &wasmConn{name: name, runtime: runtime, compiled: compiled}, nil
+}
+
+// openWasm compile the module file path
+func openWasm(name, path string, pages uint32) (*wasmConn, error) {
+	module, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return compileWasm(name, module, pages)
+}
+
+func (c *wasmConn) call(ctx context.Context, method string, params, result interface{}) error {
+	switch method {
+	case "info":
+		if info, ok := result.(*Info); ok {
+			*info = Info{Name: c.name, Capabilities: []string{CapabilityConfigurer, CapabilityTransformer}}
+		}
+		return nil
+	case "configure":
+		config, _ := params.(map[string]string)
+		c.mu.Lock()
+		c.config = config["config"]
+		c.mu.Unlock()
+		return nil
+	case "enable", "disable":
+		return nil
+	case "transform":
+		return c.run(ctx, params, result)
+	default:
+		return &RPCError{Code: codeMethodNotFound, Message: "method not found: " + method}
+	}
+}
+
+// run run an instance of the module with params as stdin and decode its stdout into result
+func (c *wasmConn) run(ctx context.Context, params, result interface{}) error {
+	input, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	config := c.config
+	c.mu.Unlock()
+
+	stdout := &limitedBuffer{max: maxMessageSize}
+	stderr := &limitedBuffer{max: 4 << 10}
+	moduleConfig := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(c.name).
+		WithEnv("GOHOOK_PLUGIN_NAME", c.name).
+		WithEnv("GOHOOK_PLUGIN_CONFIG", config).
+		WithStdin(bytes.NewReader(input)).
+		WithStdout(stdout).
+		WithStderr(stderr)
+	mod, err := c.runtime.InstantiateModule(ctx, c.compiled, moduleConfig)
+	if mod != nil {
+		mod.Close(ctx)
+	}
+	for _, line := range bytes.Split(bytes.TrimSpace(stderr.Bytes()), []byte("\n")) {
+		if len(line) > 0 {
+			log.Printf("plugin %s: %s", c.name, line)
+		}
+	}
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 0 {
+		err = nil
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("transform: %v", ctx.Err())
+		}
+		return fmt.Errorf("transform: %v", err)
+	}
+	if result == nil || stdout.Len() == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(stdout.Bytes(), result); err != nil {
+		return fmt.Errorf("transform: invalid output: %v", err)
+	}
+	return nil
+}
+
+func (c *wasmConn) close() {
+	c.runtime.Close(context.Background())
+}
+
+// MaxWasmModuleSize largest WebAssembly module accepted by Install
+const MaxWasmModuleSize = 32 << 20
+
+// validWasmName names of uploaded WebAssembly plugins, also their file name without .wasm
+var validWasmName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,99}$`)
+
+// errors of installing and removing WebAssembly plugins
+var (
+	ErrInvalidName = errors.New("plugin names are 1-100 letters, digits, _ or -")
+	ErrNotWasm     = errors.New("a plugin that is not a WebAssembly module has the name")
+)
+
+// ModuleError upload that is not a valid WebAssembly command module
+type ModuleError struct {
+	Err error
+}
+
+func (e *ModuleError) Error() string {
+	return e.Err.Error()
+}
+
+// installMu serializes installing and removing WebAssembly plugins
+var installMu sync.Mutex
+
+// Install store module as the WebAssembly plugin name in the plugins directory and load it. A
+// plugin with the name is upgraded in place and keeps its enabled state and configuration.
+func Install(name string, module []byte) (Status, error) {
+	if !validWasmName.MatchString(name) {
+		return Status{}, ErrInvalidName
+	}
+	installMu.Lock()
+	defer installMu.Unlock()
+
+	existing := lookupName(name)
+	if existing != nil && existing.Kind != KindWasm {
+		return Status{}, ErrNotWasm
+	}
+	c, err := compileWasm(name, module, memoryPages())
+	if err != nil {
+		return Status{}, &ModuleError{err}
+	}
+
+	registry.mu.RLock()
+	dir := registry.dir
+	registry.mu.RUnlock()
+	if dir == "" {
+		dir = types.DefaultPluginsDir
+	}
+	path, err := filepath.Abs(filepath.Join(dir, name+".wasm"))
+	if err == nil {
+		err = writeModule(path, module)
+	}
+	if err != nil {
+		c.close()
+		return Status{}, err
+	}
+
+	if existing != nil {
+		existing.mu.Lock()
+		defer existing.mu.Unlock()
+		enabled := existing.Enabled
+		if enabled {
+			stop(existing)
+		}
+		existing.conn.close()
+		existing.conn = c
+		existing.LastError = ""
+		if enabled {
+			if err := start(existing); err != nil {
+				existing.Enabled = false
+				existing.LastError = err.Error()
+			}
+		}
+		existing.active.Store(existing.Enabled)
+		return status(existing), nil
+	}
+
+	p := &Plugin{Name: name, Path: path, Kind: KindWasm, conn: c}
+	registry.mu.RLock()
+	var id uint
+	for _, loaded := range registry.plugins {
+		if loaded.ID > id {
+			id = loaded.ID
+		}
+	}
+	registry.mu.RUnlock()
+	if err := load(p, id+1); err != nil {
+		c.close()
+		return Status{}, &ModuleError{err}
+	}
+	registry.mu.Lock()
+	registry.plugins = append(registry.plugins, p)
+	registry.mu.Unlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return status(p), nil
+}
+
+// writeModule write the module file atomically
+func writeModule(path string, module []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, module, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Remove stop the WebAssembly plugin name, delete its module file and forget its state
+func Remove(name string) error {
+	installMu.Lock()
+	defer installMu.Unlock()
+
+	p := lookupName(name)
+	if p == nil {
+		return ErrNotFound
+	}
+	if p.Kind != KindWasm {
+		return ErrNotWasm
+	}
+	if err := os.Remove(p.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	registry.mu.Lock()
+	for i, loaded := range registry.plugins {
+		if loaded == p {
+			registry.plugins = append(registry.plugins[:i:i], registry.plugins[i+1:]...)
+			break
+		}
+	}
+	registry.mu.Unlock()
+
+	p.mu.Lock()
+	p.active.Store(false)
+	if p.Enabled {
+		stop(p)
+	}
+	p.conn.close()
+	p.conn = nil
+	p.mu.Unlock()
+	return database.DeletePluginConf(name)
+}
@@ -0,0 +1,214 @@
+// Package pool runs background hook executions on a bounded number of workers. Executions
+// wait in a bounded queue for a free worker, a full queue rejects them so bursts of
+// deliveries apply backpressure instead of starting unlimited commands.
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// pool defaults and limits
+const (
+	DefaultWorkers   = 16
+	DefaultQueueSize = 1000
+	MaxWorkers       = 1024
+	MaxQueueSize     = 100000
+)
+
+// ErrQueueFull the execution was rejected because every worker is busy and the queue is full
+var ErrQueueFull = errors.New("execution queue is full")
+
+// Executions pool of the background hook executions
+var Executions = New("executions", DefaultWorkers, DefaultQueueSize)
+
+type job struct {
+	fn       func()
+	queuedAt time.Time
+}
+
+// Pool fixed set of workers running submitted functions in order
+type Pool struct {
+	name string
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	queue     []job
+	workers   int // target number of workers
+	running   int // worker goroutines alive, above workers while the pool shrinks
+	busy      int
+	queueSize int
+	submitted int64
+	completed int64
+	rejected  int64
+	waited    time.Duration // total time executions spent queued
+	started   int64         // executions taken from the queue
+}
+
+// Stats state and counters of a pool
+type Stats struct {
+	Name          string  `json:"name"`
+	Workers       int     `json:"workers"`
+	QueueSize     int     `json:"queueSize"`
+	Queued        int     `json:"queued"`
+	InFlight      int     `json:"inFlight"`
+	Utilization   float64 `json:"utilization"` // busy workers / workers
+	OldestQueued  string  `json:"oldestQueued,omitempty"`
+	AverageWaitMs int64   `json:"averageWaitMs"` // time executions spent queued before starting
+	Submitted     int64   `json:"submitted"`
+	Completed     int64   `json:"completed"`
+	Rejected      int64   `json:"rejected"`
+}
+
+// New create a pool and start its workers
+func New(name string, workers, queueSize int) *Pool {
+	p := &Pool{name: name}
+	p.cond = sync.NewCond(&p.mu)
+	p.Resize(workers, queueSize)
+	return p
+}
+
+// Validate check the worker and queue size of an execution config, zero keeps the default
+func Validate(cfg *types.ExecutionConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Workers < 0 || cfg.Workers > MaxWorkers {
+		return fmt.Errorf("workers must be between 1 and %d", MaxWorkers)
+	}
+	if cfg.QueueSize < 0 || cfg.QueueSize > MaxQueueSize {
+		return fmt.Errorf("queue size must be between 1 and %d", MaxQueueSize)
+	}
+	return nil
+}
+
+// Configure apply the execution config to the Executions pool, defaults for unset values
+func Configure(cfg *types.ExecutionConfig) {
+	workers, queueSize := DefaultWorkers, DefaultQueueSize
+	if cfg != nil {
+		if cfg.Workers > 0 {
+			workers = cfg.Workers
+		}
+		if cfg.QueueSize > 0 {
+			queueSize = cfg.QueueSize
+		}
+	}
+	Executions.Resize(workers, queueSize)
+}
+
+// Resize change the number of workers and the queue size. Surplus workers stop after their
+// current execution; executions already queued beyond a smaller queue size still run.
+func (p *Pool) Resize(workers, queueSize int) {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.workers, p.queueSize = workers, queueSize
+	for p.running < p.workers {
+		p.running++
+		go p.work()
+	}
+	p.cond.Broadcast()
+}
+
+// Submit queue fn for the next free worker, ErrQueueFull when the queue is full
+func (p *Pool) Submit(fn func()) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// an idle worker takes the execution at once, the queue only holds what waits
+	if len(p.queue)-(p.workers-p.busy) >= p.queueSize {
+		p.rejected++
+		return ErrQueueFull
+	}
+	p.submitted++
+	p.queue = append(p.queue, job{fn: fn, queuedAt: time.Now()})
+	p.cond.Signal()
+	return nil
+}
+
+func (p *Pool) work() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		for len(p.queue) == 0 && p.running <= p.workers {
+			p.cond.Wait()
+		}
+		if p.running > p.workers {
+			p.running--
+			return
+		}
+		j := p.queue[0]
+		p.queue[0] = job{}
+		p.queue = p.queue[1:]
+		p.busy++
+		p.started++
+		p.waited += time.Since(j.queuedAt)
+		p.mu.Unlock()
+
+		p.run(j.fn)
+
+		p.mu.Lock()
+		p.busy--
+		p.completed++
+	}
+}
+
+// run fn, a panicking execution does not take the worker down
+func (p *Pool) run(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("pool %s: execution panicked: %v", p.name, r)
+		}
+	}()
+	fn()
+}
+
+// Drain wait until no execution is queued or running, false when ctx ends first
+func (p *Pool) Drain(ctx context.Context) bool {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if s := p.Stats(); s.Queued == 0 && s.InFlight == 0 {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stats current state of the pool
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := Stats{
+		Name:        p.name,
+		Workers:     p.workers,
+		QueueSize:   p.queueSize,
+		Queued:      len(p.queue),
+		InFlight:    p.busy,
+		Utilization: float64(p.busy) / float64(p.workers),
+		Submitted:   p.submitted,
+		Completed:   p.completed,
+		Rejected:    p.rejected,
+	}
+	if len(p.queue) > 0 {
+		s.OldestQueued = time.Since(p.queue[0].queuedAt).Round(time.Millisecond).String()
+	}
+	if p.started > 0 {
+		s.AverageWaitMs = (p.waited / time.Duration(p.started)).Milliseconds()
+	}
+	return s
+}
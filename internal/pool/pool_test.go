@@ -0,0 +1,100 @@
+package pool
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// waitFor poll cond until it holds or a second passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolBackpressure(t *testing.T) {
+	p := New("test", 2, 1)
+	release := make(chan struct{})
+	var done sync.WaitGroup
+	block := func() {
+		defer done.Done()
+		<-release
+	}
+
+	done.Add(3)
+	for i := 0; i < 3; i++ {
+		if err := p.Submit(block); err != nil {
+			t.Fatalf("submit %d: %v", i, err)
+		}
+	}
+	waitFor(t, "two running executions", func() bool { return p.Stats().InFlight == 2 })
+	if err := p.Submit(block); err != ErrQueueFull {
+		t.Fatalf("submit beyond the queue = %v, want ErrQueueFull", err)
+	}
+	s := p.Stats()
+	if s.Queued != 1 || s.Utilization != 1 || s.Submitted != 3 || s.Rejected != 1 || s.OldestQueued == "" {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	// a larger pool picks up the queued execution at once
+	p.Resize(3, 1)
+	waitFor(t, "the queued execution", func() bool { return p.Stats().InFlight == 3 })
+
+	close(release)
+	done.Wait()
+	waitFor(t, "completed executions", func() bool { return p.Stats().Completed == 3 })
+	if s := p.Stats(); s.Queued != 0 || s.InFlight != 0 || s.Workers != 3 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+func TestPoolShrink(t *testing.T) {
+	p := New("test", 4, 0)
+	p.Resize(1, 0)
+	waitFor(t, "surplus workers to stop", func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.running == 1
+	})
+
+	ran := make(chan struct{})
+	if err := p.Submit(func() { panic("boom") }); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the panicking execution", func() bool { return p.Stats().Completed == 1 })
+	if err := p.Submit(func() { close(ran) }); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not survive a panicking execution")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		cfg     *types.ExecutionConfig
+		wantErr bool
+	}{
+		{nil, false},
+		{&types.ExecutionConfig{}, false},
+		{&types.ExecutionConfig{Workers: 32, QueueSize: 5000}, false},
+		{&types.ExecutionConfig{Workers: -1}, true},
+		{&types.ExecutionConfig{Workers: MaxWorkers + 1}, true},
+		{&types.ExecutionConfig{QueueSize: MaxQueueSize + 1}, true},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.cfg); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) = %v, wantErr %v", tt.cfg, err, tt.wantErr)
+		}
+	}
+}
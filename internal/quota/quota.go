@@ -0,0 +1,203 @@
+// Package quota limits the daily executions and the stored execution logs of hooks,
+// namespaces and users.
+package quota
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/maintenance"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// quota kinds of Usage
+const (
+	KindHook      = "hook"
+	KindNamespace = "namespace"
+	KindUser      = "user"
+)
+
+// limits of Usage.Exceeded
+const (
+	ExceededExecutions = "executions"
+	ExceededStorage    = "storage"
+)
+
+// storageCacheTTL storage is summed over all execution logs, the result is reused for this long
+const storageCacheTTL = time.Minute
+
+// Validate check the limits and the behavior of a quota
+func Validate(q *types.QuotaConfig) error {
+	if q == nil {
+		return nil
+	}
+	if q.MaxExecutionsPerDay < 0 || q.MaxStorageBytes < 0 {
+		return fmt.Errorf("quota limits can not be negative")
+	}
+	if q.MaxExecutionsPerDay == 0 && q.MaxStorageBytes == 0 {
+		return fmt.Errorf("quota needs a daily execution or a storage limit")
+	}
+	switch q.OnExceeded {
+	case "", types.QuotaReject, types.QuotaQueue:
+	default:
+		return fmt.Errorf("invalid quota behavior %q, use %s or %s", q.OnExceeded, types.QuotaReject, types.QuotaQueue)
+	}
+	return nil
+}
+
+// Usage consumption of a quota
+type Usage struct {
+	Kind         string            `json:"kind"` // hook | namespace | user
+	Name         string            `json:"name"`
+	Quota        types.QuotaConfig `json:"quota"`
+	Executions   int64             `json:"executions"`             // since local midnight
+	StorageBytes int64             `json:"storageBytes,omitempty"` // not counted for users
+	Exceeded     string            `json:"exceeded,omitempty"`     // executions | storage
+}
+
+// Reason describe the exceeded limit
+func (u *Usage) Reason() string {
+	if u.Exceeded == ExceededStorage {
+		return fmt.Sprintf("storage quota of %s %s reached (%d of %d bytes)", u.Kind, u.Name, u.StorageBytes, u.Quota.MaxStorageBytes)
+	}
+	return fmt.Sprintf("daily execution quota of %s %s reached (%d)", u.Kind, u.Name, u.Quota.MaxExecutionsPerDay)
+}
+
+// Subject an execution and the quotas it counts against
+type Subject struct {
+	Hook      string // webhook id, empty for projects
+	HookQuota *types.QuotaConfig
+	Namespace string
+	User      string // manual triggers only
+}
+
+// NamespaceQuota quota of a namespace, nil without one
+func NamespaceQuota(ns string) *types.QuotaConfig {
+	ns = namespace.Normalize(ns)
+	for _, n := range namespace.List() {
+		if n.Name == ns {
+			return n.Quota
+		}
+	}
+	return nil
+}
+
+// UserQuota quota of a user, nil without one
+func UserQuota(username string) *types.QuotaConfig {
+	if types.GoHookUsersConfig == nil || username == "" {
+		return nil
+	}
+	for _, user := range types.GoHookUsersConfig.Users {
+		if user.Username == username {
+			return user.Quota
+		}
+	}
+	return nil
+}
+
+// Check the first exceeded quota of an execution of s, nil when it may run. Usage that
+// cannot be measured does not hold back executions.
+func Check(s Subject) *Usage {
+	if s.HookQuota != nil {
+		if u := Measure(KindHook, s.Hook, s.HookQuota); u.Exceeded != "" {
+			return u
+		}
+	}
+	if q := UserQuota(s.User); q != nil {
+		if u := Measure(KindUser, s.User, q); u.Exceeded != "" {
+			return u
+		}
+	}
+	ns := namespace.Normalize(s.Namespace)
+	if q := NamespaceQuota(ns); q != nil {
+		if u := Measure(KindNamespace, ns, q); u.Exceeded != "" {
+			return u
+		}
+	}
+	return nil
+}
+
+// Hold maintenance decision for a delivery of s: queued or rejected with 429 while a quota
+// is exceeded
+func Hold(s Subject) maintenance.Decision {
+	u := Check(s)
+	if u == nil {
+		return maintenance.Decision{}
+	}
+	d := maintenance.Decision{Paused: true, Reason: u.Reason()}
+	if u.Quota.OnExceeded != types.QuotaQueue {
+		d.RejectStatus = http.StatusTooManyRequests
+	}
+	return d
+}
+
+// Measure the usage of the quota q of the named hook, namespace or user
+func Measure(kind, name string, q *types.QuotaConfig) *Usage {
+	u := &Usage{Kind: kind, Name: name, Quota: *q}
+	now := time.Now()
+	year, month, day := now.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+
+	var err error
+	if kind == KindUser {
+		u.Executions, err = database.CountUserActionsSince(name, database.UserActionTriggerHook, midnight)
+	} else {
+		u.Executions, err = database.CountExecutionsSince(scope(kind, name), midnight)
+	}
+	if err != nil {
+		log.Printf("quota: counting executions of %s %s: %v", kind, name, err)
+	}
+	if kind != KindUser {
+		u.StorageBytes = storageBytes(scope(kind, name))
+	}
+
+	switch {
+	case q.MaxExecutionsPerDay > 0 && u.Executions >= int64(q.MaxExecutionsPerDay):
+		u.Exceeded = ExceededExecutions
+	case q.MaxStorageBytes > 0 && u.StorageBytes >= q.MaxStorageBytes:
+		u.Exceeded = ExceededStorage
+	}
+	return u
+}
+
+func scope(kind, name string) database.QuotaScope {
+	if kind == KindHook {
+		return database.QuotaScope{HookID: name}
+	}
+	return database.QuotaScope{Namespace: name}
+}
+
+type cachedStorage struct {
+	bytes int64
+	at    time.Time
+}
+
+var (
+	storageMu    sync.Mutex
+	storageCache = map[database.QuotaScope]cachedStorage{}
+)
+
+// storageBytes stored bytes of the scope, measured at most once per storageCacheTTL
+func storageBytes(s database.QuotaScope) int64 {
+	storageMu.Lock()
+	cached, ok := storageCache[s]
+	storageMu.Unlock()
+	if ok && time.Since(cached.at) < storageCacheTTL {
+		return cached.bytes
+	}
+
+	bytes, err := database.StorageBytes(s)
+	if err != nil {
+		log.Printf("quota: measuring storage of %+v: %v", s, err)
+		return cached.bytes
+	}
+	storageMu.Lock()
+	storageCache[s] = cachedStorage{bytes: bytes, at: time.Now()}
+	storageMu.Unlock()
+	return bytes
+}
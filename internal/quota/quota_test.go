@@ -0,0 +1,103 @@
+package quota
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		quota   *types.QuotaConfig
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"executions", &types.QuotaConfig{MaxExecutionsPerDay: 100}, false},
+		{"storage queued", &types.QuotaConfig{MaxStorageBytes: 1 << 20, OnExceeded: types.QuotaQueue}, false},
+		{"no limit", &types.QuotaConfig{OnExceeded: types.QuotaReject}, true},
+		{"negative", &types.QuotaConfig{MaxExecutionsPerDay: -1}, true},
+		{"unknown behavior", &types.QuotaConfig{MaxExecutionsPerDay: 1, OnExceeded: "drop"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.quota); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&database.HookLog{}, &database.HookArtifact{}, &database.UserActivity{}); err != nil {
+		t.Fatal(err)
+	}
+	savedDB, savedApp, savedUsers := database.DB, types.GoHookAppConfig, types.GoHookUsersConfig
+	database.DB = conn
+	types.GoHookAppConfig = &types.AppConfig{Namespaces: []types.NamespaceConfig{
+		{Name: "team-a", Quota: &types.QuotaConfig{MaxExecutionsPerDay: 3, OnExceeded: types.QuotaQueue}},
+		{Name: "team-b", Quota: &types.QuotaConfig{MaxStorageBytes: 10}},
+	}}
+	types.GoHookUsersConfig = &types.UsersConfig{Users: []types.UserConfig{
+		{Username: "alice", Quota: &types.QuotaConfig{MaxExecutionsPerDay: 1}},
+	}}
+	defer func() {
+		database.DB, types.GoHookAppConfig, types.GoHookUsersConfig = savedDB, savedApp, savedUsers
+	}()
+
+	for _, l := range []*database.HookLog{
+		{HookID: "deploy", HookType: database.HookTypeWebhook, Namespace: "team-a"},
+		{HookID: "deploy", HookType: database.HookTypeWebhook, Namespace: "team-a"},
+		{HookID: "build", HookType: database.HookTypeWebhook, Namespace: "team-b", Output: "a long build log"},
+	} {
+		if err := conn.Create(l).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := conn.Create(&database.UserActivity{Username: "alice", Action: database.UserActionTriggerHook}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		subject Subject
+		want    string // kind/exceeded of the exceeded quota
+	}{
+		{"within quotas", Subject{Hook: "deploy", Namespace: "team-a"}, ""},
+		{"hook quota", Subject{Hook: "deploy", HookQuota: &types.QuotaConfig{MaxExecutionsPerDay: 2}, Namespace: "team-a"}, "hook/executions"},
+		{"user quota", Subject{Hook: "deploy", Namespace: "team-a", User: "alice"}, "user/executions"},
+		{"user without quota", Subject{Hook: "deploy", Namespace: "team-a", User: "bob"}, ""},
+		{"namespace storage", Subject{Hook: "lint", Namespace: "team-b"}, "namespace/storage"},
+		{"default namespace", Subject{Hook: "other"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if u := Check(tt.subject); u != nil {
+				got = u.Kind + "/" + u.Exceeded
+			}
+			if got != tt.want {
+				t.Errorf("Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if d := Hold(Subject{Hook: "lint", Namespace: "team-b"}); !d.Paused || d.RejectStatus != http.StatusTooManyRequests {
+		t.Errorf("Hold(storage) = %+v, want rejected", d)
+	}
+	if err := conn.Create(&database.HookLog{HookID: "deploy", HookType: database.HookTypeWebhook, Namespace: "team-a"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	d := Hold(Subject{Hook: "deploy", Namespace: "team-a"})
+	if !d.Paused || d.RejectStatus != 0 || d.Reason != "daily execution quota of namespace team-a reached (3)" {
+		t.Errorf("Hold(executions) = %+v, want queued", d)
+	}
+}
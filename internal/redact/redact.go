@@ -0,0 +1,305 @@
+// Package redact removes secrets from the text and values gohook stores in its logs and
+// broadcasts over /stream: values of secret-looking keys in JSON, form, header and
+// environment syntax, well-known token formats, the patterns configured in app.yaml and
+// the values of secret environment variables handed to hook commands.
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// Placeholder replaces a redacted secret
+const Placeholder = "[REDACTED]"
+
+// minValueLength shortest environment value redacted literally, shorter values such as
+// "1" or "yes" would mask unrelated output
+const minValueLength = 4
+
+// builtinKeys key names whose values are secrets, matched as fragments of a key without case,
+// '-' and '_'
+var builtinKeys = []string{
+	"password", "passwd", "secret", "token", "api_key", "access_key", "private_key",
+	"authorization", "cookie", "credential",
+}
+
+// builtinPatterns well-known secret formats
+var builtinPatterns = []string{
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,                  // GitHub tokens
+	`\bglpat-[A-Za-z0-9_-]{20,}\b`,                    // GitLab personal access tokens
+	`\bAKIA[0-9A-Z]{16}\b`,                            // AWS access key ids
+	`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`,                // Slack tokens
+	`[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:([^/\s@]+)@`, // password of credentials in URLs
+}
+
+// Rules compiled redaction rules, a nil Rules redacts nothing
+type Rules struct {
+	keys     []string       // key fragments lowercased without '-' and '_'
+	assign   *regexp.Regexp // key=value, key: value and "key": "value" of the keys
+	patterns []*regexp.Regexp
+}
+
+var (
+	mu     sync.RWMutex
+	active *Rules // nil while redaction is off
+)
+
+// Compile rules of cfg, nil when redaction is not enabled
+func Compile(cfg *types.RedactionConfig) (*Rules, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+	r := &Rules{}
+	var alternatives []string
+	for _, key := range append(append([]string{}, builtinKeys...), cfg.Keys...) {
+		words := strings.FieldsFunc(strings.ToLower(key), func(c rune) bool { return c == '-' || c == '_' })
+		if len(words) == 0 {
+			continue
+		}
+		r.keys = append(r.keys, strings.Join(words, ""))
+		for i := range words {
+			words[i] = regexp.QuoteMeta(words[i])
+		}
+		alternatives = append(alternatives, strings.Join(words, "[_-]?"))
+	}
+	r.assign = regexp.MustCompile(`(?i)(["']?[\w.-]*(?:` + strings.Join(alternatives, "|") + `)[\w.-]*["']?[ \t]*[:=][ \t]*)` +
+		`((?:bearer|basic|token)[ \t]+)?("[^"]*"|'[^']*'|[^\s,;&"'{}\[\]]+)`)
+	for _, p := range append(append([]string{}, builtinPatterns...), cfg.Patterns...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %v", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Configure redact with the rules of cfg from now on, a nil or disabled cfg turns redaction
+// off. Invalid rules leave the active ones in place.
+func Configure(cfg *types.RedactionConfig) error {
+	r, err := Compile(cfg)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	active = r
+	mu.Unlock()
+	return nil
+}
+
+// Active rules in use, nil while redaction is off
+func Active() *Rules {
+	mu.RLock()
+	defer mu.RUnlock()
+	return active
+}
+
+// String s redacted with the active rules, see Rules.String
+func String(s string, values ...string) string {
+	return Active().String(s, values...)
+}
+
+// Header h redacted with the active rules, see Rules.Header
+func Header(h map[string][]string) map[string][]string {
+	return Active().Header(h)
+}
+
+// Value v redacted with the active rules, see Rules.Value
+func Value(v interface{}) interface{} {
+	return Active().Value(v)
+}
+
+// EnvValues values of the secret variables of env with the active rules, see Rules.EnvValues
+func EnvValues(env []string) []string {
+	return Active().EnvValues(env)
+}
+
+// credentialParams query parameters carrying credentials whatever the configuration: the
+// capability tokens of hook endpoints and the login token of /stream
+var credentialParams = map[string]bool{"token": true}
+
+// Query path with query of a request, as written to logs, with the values of credential
+// parameters replaced by the placeholder. The order and encoding of the others are kept.
+func Query(path string) string {
+	base, rawQuery, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err != nil || credentialParams[strings.ToLower(name)] {
+			params[i] = key + "=" + Placeholder
+		}
+	}
+	return base + "?" + strings.Join(params, "&")
+}
+
+// SecretKey whether the values of key name are secrets
+func (r *Rules) SecretKey(name string) bool {
+	if r == nil {
+		return false
+	}
+	normalized := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(name))
+	for _, key := range r.keys {
+		if strings.Contains(normalized, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// String s with the values of secret keys, the matches of the patterns and the literal
+// values replaced by Placeholder
+func (r *Rules) String(s string, values ...string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	// longer values first, a value containing another is replaced whole
+	values = append([]string(nil), values...)
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, v := range values {
+		if len(v) >= minValueLength {
+			s = strings.ReplaceAll(s, v, Placeholder)
+		}
+	}
+	s = r.assign.ReplaceAllStringFunc(s, func(match string) string {
+		m := r.assign.FindStringSubmatch(match)
+		value := m[3]
+		switch {
+		case value == `""` || value == `''`:
+			return match
+		case strings.HasPrefix(value, `"`) || strings.HasPrefix(value, `'`):
+			return m[1] + m[2] + value[:1] + Placeholder + value[:1]
+		}
+		return m[1] + m[2] + Placeholder
+	})
+	for _, re := range r.patterns {
+		s = replaceMatches(re, s)
+	}
+	return s
+}
+
+// replaceMatches s with the matches of re replaced by Placeholder, only the groups of a
+// pattern with groups
+func replaceMatches(re *regexp.Regexp, s string) string {
+	if re.NumSubexp() == 0 {
+		return re.ReplaceAllLiteralString(s, Placeholder)
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+		for g := 1; g <= re.NumSubexp(); g++ {
+			start, end := m[2*g], m[2*g+1]
+			if start < last || start < 0 {
+				continue
+			}
+			b.WriteString(s[last:start])
+			b.WriteString(Placeholder)
+			last = end
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// Header copy of h with the values of secret headers or query parameters replaced
+func (r *Rules) Header(h map[string][]string) map[string][]string {
+	if r == nil || h == nil {
+		return h
+	}
+	redacted := make(map[string][]string, len(h))
+	for name, values := range h {
+		copied := make([]string, len(values))
+		for i, v := range values {
+			if r.SecretKey(name) {
+				copied[i] = Placeholder
+			} else {
+				copied[i] = r.String(v)
+			}
+		}
+		redacted[name] = copied
+	}
+	return redacted
+}
+
+// Value v with the values of secret keys and the secrets in strings replaced. v keeps its
+// type when it survives a JSON round trip; a value without secrets is returned as is.
+func (r *Rules) Value(v interface{}) interface{} {
+	if r == nil || v == nil {
+		return v
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return v
+	}
+	generic, changed := r.walk(generic)
+	if !changed {
+		return v
+	}
+	redacted, err := json.Marshal(generic)
+	if err != nil {
+		return generic
+	}
+	typed := reflect.New(reflect.TypeOf(v))
+	if err := json.Unmarshal(redacted, typed.Interface()); err != nil {
+		return generic
+	}
+	return typed.Elem().Interface()
+}
+
+// walk redact a decoded JSON value, reports whether anything was replaced
+func (r *Rules) walk(v interface{}) (interface{}, bool) {
+	changed := false
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if r.SecretKey(key) {
+				if item != nil && item != "" && item != Placeholder {
+					value[key], changed = Placeholder, true
+				}
+				continue
+			}
+			redacted, c := r.walk(item)
+			value[key], changed = redacted, changed || c
+		}
+	case []interface{}:
+		for i, item := range value {
+			redacted, c := r.walk(item)
+			value[i], changed = redacted, changed || c
+		}
+	case string:
+		if redacted := r.String(value); redacted != value {
+			return redacted, true
+		}
+	}
+	return v, changed
+}
+
+// EnvValues values of the variables of env, in KEY=value form, whose key holds secrets
+func (r *Rules) EnvValues(env []string) []string {
+	if r == nil {
+		return nil
+	}
+	var values []string
+	for _, entry := range env {
+		key, value, ok := strings.Cut(entry, "=")
+		if ok && len(value) >= minValueLength && r.SecretKey(key) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
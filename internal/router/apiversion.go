@@ -0,0 +1,141 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/openapi"
+)
+
+// APIPrefix prefix of the versioned API, the canonical paths of the panel API
+const APIPrefix = "/api/v1"
+
+// LegacySunset date after which the unversioned API paths may be removed, announced in the
+// Sunset header of their responses
+var LegacySunset = time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+
+// legacyDeprecated date the unversioned paths were deprecated, announced in the Deprecation header
+var legacyDeprecated = time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+// unversionedPaths routes that keep their path and are not deprecated: receivers whose URL is
+// configured in git providers and chat apps, the agent protocol and the health check
+var unversionedPaths = []string{
+	"/ping",
+	"/githook/",
+	"/gitops/webhook",
+	"/chatops",
+	"/api/sync/nodes/:id/tasks/",
+}
+
+// canonicalKey marks a request forwarded from its /api/v1 path
+type canonicalKey struct{}
+
+var (
+	legacyMu     sync.RWMutex
+	legacyRoutes = map[string]bool{} // "METHOD /path" of the unversioned routes with an /api/v1 path
+)
+
+// canonicalPath /api/v1 path of an unversioned route, routes already under /api lose that prefix
+func canonicalPath(p string) string {
+	if strings.HasPrefix(p, "/api/") {
+		return APIPrefix + strings.TrimPrefix(p, "/api")
+	}
+	return APIPrefix + p
+}
+
+// versioned whether the route belongs to the versioned panel API: routes of the internal
+// packages except hook deliveries and the receivers of unversionedPaths
+func versioned(route gin.RouteInfo) bool {
+	if hookRoutePaths[route.Path] || strings.HasPrefix(route.Path, APIPrefix+"/") ||
+		!strings.HasPrefix(route.Handler, "github.com/mycoool/gohook/internal/") ||
+		strings.HasPrefix(route.Handler, "github.com/mycoool/gohook/internal/openapi.") {
+		return false
+	}
+	for _, p := range unversionedPaths {
+		if route.Path == p || strings.HasSuffix(p, "/") && strings.HasPrefix(route.Path, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// RegisterVersionedAPI serve the panel API under /api/v1, the canonical paths, and mark the
+// unversioned paths as deprecated aliases. Called once all API routes are registered; the
+// /api/v1 routes hand the request to the unversioned route, so its middleware runs once.
+func RegisterVersionedAPI(r *gin.Engine) {
+	routes := r.Routes()
+	// routes under /api come first, they own a canonical path also reachable without /api
+	sort.SliceStable(routes, func(i, j int) bool {
+		return strings.HasPrefix(routes[i].Path, "/api/") && !strings.HasPrefix(routes[j].Path, "/api/")
+	})
+
+	v1 := r.Group(APIPrefix)
+	v1.Handlers = nil // the middleware runs on the forwarded request
+	registered := map[string]bool{}
+	aliases := map[string]bool{}
+	for _, route := range routes {
+		if !versioned(route) {
+			continue
+		}
+		canonical := canonicalPath(route.Path)
+		aliases[route.Method+" "+route.Path] = true
+		if registered[route.Method+" "+canonical] {
+			continue
+		}
+		registered[route.Method+" "+canonical] = true
+		v1.Handle(route.Method, strings.TrimPrefix(canonical, APIPrefix), forwardTo(r, route.Path))
+		openapi.Alias(route.Method, canonical, route)
+	}
+
+	legacyMu.Lock()
+	legacyRoutes = aliases
+	legacyMu.Unlock()
+}
+
+// forwardTo hand a request of the canonical path to the unversioned route legacy
+func forwardTo(r *gin.Engine, legacy string) gin.HandlerFunc {
+	apiPrefixed := strings.HasPrefix(legacy, "/api/")
+	return func(c *gin.Context) {
+		p := strings.TrimPrefix(c.Request.URL.Path, APIPrefix)
+		if apiPrefixed {
+			p = "/api" + p
+		}
+		c.Request.URL.Path = p
+		c.Request.URL.RawPath = ""
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), canonicalKey{}, true))
+		r.HandleContext(c)
+		// HandleContext leaves the handlers of the unversioned route on c, they must not run again
+		c.Abort()
+	}
+}
+
+// DeprecatedAliasMiddleware announce the deprecation of an unversioned API path: Deprecation
+// and Sunset headers and a Link to the /api/v1 path succeeding it
+func DeprecatedAliasMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Context().Value(canonicalKey{}) != nil {
+			c.Header("API-Version", strings.TrimPrefix(APIPrefix, "/api/"))
+			c.Next()
+			return
+		}
+		legacyMu.RLock()
+		deprecated := legacyRoutes[c.Request.Method+" "+c.FullPath()]
+		legacyMu.RUnlock()
+		if deprecated {
+			successor := canonicalPath(c.Request.URL.Path)
+			if c.Request.URL.RawQuery != "" {
+				successor += "?" + c.Request.URL.RawQuery
+			}
+			c.Header("Deprecation", "@"+strconv.FormatInt(legacyDeprecated.Unix(), 10))
+			c.Header("Sunset", LegacySunset.Format(http.TimeFormat))
+			c.Header("Link", "<"+successor+">; rel=\"successor-version\"")
+		}
+		c.Next()
+	}
+}
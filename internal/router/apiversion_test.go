@@ -0,0 +1,62 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRegisterVersionedAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(DeprecatedAliasMiddleware())
+	echo := func(c *gin.Context) { c.String(http.StatusOK, c.Request.URL.Path+" "+c.Param("id")) }
+	r.GET("/ping", echo)
+	r.GET("/current/user", echo)
+	r.GET("/api/quotas", echo)
+	r.GET("/project/:id", echo)
+	r.POST("/githook/:id", echo)
+	RegisterVersionedAPI(r)
+
+	tests := []struct {
+		method         string
+		path           string
+		wantCode       int
+		wantBody       string
+		wantVersion    string
+		wantSuccessor  string
+		wantDeprecated bool
+	}{
+		{http.MethodGet, "/api/v1/current/user", 200, "/current/user ", "v1", "", false},
+		{http.MethodGet, "/current/user", 200, "/current/user ", "", "</api/v1/current/user>; rel=\"successor-version\"", true},
+		{http.MethodGet, "/api/v1/quotas", 200, "/api/quotas ", "v1", "", false},
+		{http.MethodGet, "/api/quotas", 200, "/api/quotas ", "", "</api/v1/quotas>; rel=\"successor-version\"", true},
+		{http.MethodGet, "/api/v1/project/demo", 200, "/project/demo demo", "v1", "", false},
+		{http.MethodGet, "/project/demo?tz=UTC", 200, "/project/demo demo", "", "</api/v1/project/demo?tz=UTC>; rel=\"successor-version\"", true},
+		{http.MethodPost, "/githook/demo", 200, "/githook/demo demo", "", "", false},
+		{http.MethodGet, "/ping", 200, "/ping ", "", "", false},
+		{http.MethodPost, "/api/v1/githook/demo", 404, "404 page not found", "", "", false},
+		{http.MethodGet, "/api/v1/ping", 404, "404 page not found", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.wantCode || w.Body.String() != tt.wantBody {
+				t.Fatalf("response = %d %q, want %d %q", w.Code, w.Body.String(), tt.wantCode, tt.wantBody)
+			}
+			if got := w.Header().Get("API-Version"); got != tt.wantVersion {
+				t.Errorf("API-Version = %q, want %q", got, tt.wantVersion)
+			}
+			if got := w.Header().Get("Link"); got != tt.wantSuccessor {
+				t.Errorf("Link = %q, want %q", got, tt.wantSuccessor)
+			}
+			deprecated := w.Header().Get("Deprecation") != "" && w.Header().Get("Sunset") != ""
+			if deprecated != tt.wantDeprecated {
+				t.Errorf("deprecated = %v, want %v", deprecated, tt.wantDeprecated)
+			}
+		})
+	}
+}
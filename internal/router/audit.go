@@ -0,0 +1,29 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+)
+
+// HandleVerifyAudit recompute the hash chain of the user and project activity records and
+// report modified, removed and out of chain records
+func HandleVerifyAudit(c *gin.Context) {
+	db := database.GetDB()
+	if db == nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDatabaseUnavailable, nil)
+		return
+	}
+	reports, err := database.VerifyAuditChain(db)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeVerifyAuditFailed, err)
+		return
+	}
+	valid := true
+	for _, r := range reports {
+		valid = valid && r.Valid
+	}
+	c.JSON(http.StatusOK, gin.H{"valid": valid, "tables": reports})
+}
@@ -0,0 +1,86 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/cluster"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+)
+
+// ClusterNodeResponse instance of the HA cluster
+type ClusterNodeResponse struct {
+	NodeID    string    `json:"nodeId"`
+	Hostname  string    `json:"hostname"`
+	Version   string    `json:"version"`
+	StartedAt time.Time `json:"startedAt"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Online    bool      `json:"online"`
+	Leader    bool      `json:"leader"`
+}
+
+// ClusterResponse HA mode state as seen by the instance answering the request
+type ClusterResponse struct {
+	Enabled  bool                  `json:"enabled"`
+	NodeID   string                `json:"nodeId"`
+	Leader   string                `json:"leader"`
+	IsLeader bool                  `json:"isLeader"`
+	Nodes    []ClusterNodeResponse `json:"nodes"`
+}
+
+// HandleGetCluster return the HA mode state and the known instances
+func HandleGetCluster(c *gin.Context) {
+	resp := ClusterResponse{
+		Enabled:  cluster.Enabled(),
+		NodeID:   cluster.NodeID(),
+		Leader:   cluster.Leader(),
+		IsLeader: cluster.IsLeader(),
+		Nodes:    []ClusterNodeResponse{},
+	}
+
+	nodes, err := cluster.Nodes()
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListClusterNodesFailed, err)
+		return
+	}
+	for _, n := range nodes {
+		resp.Nodes = append(resp.Nodes, ClusterNodeResponse{
+			NodeID:    n.NodeID,
+			Hostname:  n.Hostname,
+			Version:   n.Version,
+			StartedAt: n.StartedAt,
+			LastSeen:  n.LastSeen,
+			Online:    cluster.Online(n),
+			Leader:    n.NodeID == resp.Leader,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// HandleClusterStepDown hand the leadership of this instance over to another one,
+// e.g. before stopping it for an upgrade
+func HandleClusterStepDown(c *gin.Context) {
+	if !cluster.Enabled() {
+		i18n.JSONError(c, http.StatusConflict, i18n.CodeHADisabled, nil)
+		return
+	}
+	if !cluster.StepDown() {
+		i18n.JSONError(c, http.StatusConflict, i18n.CodeNotLeader, nil)
+		return
+	}
+
+	database.LogUserAction(
+		c.GetString("username"),
+		database.UserActionClusterStepDown,
+		"cluster:"+cluster.NodeID(),
+		"leader stepped down",
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		true,
+		nil,
+	)
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeLeadershipReleased), "code": i18n.CodeLeadershipReleased, "nodeId": cluster.NodeID()})
+}
@@ -0,0 +1,296 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+// redactedValue shown for secrets in a configuration diff
+const redactedValue = "******"
+
+// diffSecretKeys field names whose values are not shown in a configuration diff
+var diffSecretKeys = map[string]bool{
+	"secret": true, "hooksecret": true, "token": true, "password": true,
+	"secret_access_key": true, "auth-token": true, "previous-secret": true, "previous_secret": true,
+}
+
+// ConfigDiff changes an import of the proposed configuration would make
+type ConfigDiff struct {
+	Mode             string     `json:"mode"` // merge | replace
+	Hooks            EntityDiff `json:"hooks"`
+	Projects         EntityDiff `json:"projects"`
+	AffectedProjects []string   `json:"affectedProjects"`   // changed projects and projects deployed by changed hooks
+	Warnings         []string   `json:"warnings,omitempty"` // proposed hooks that fail validation
+}
+
+// EntityDiff added, removed and changed hooks or projects, by id or name
+type EntityDiff struct {
+	Added     []string       `json:"added"`
+	Removed   []string       `json:"removed"`
+	Changed   []EntityChange `json:"changed"`
+	Unchanged int            `json:"unchanged"`
+}
+
+// EntityChange fields of a hook or project that change
+type EntityChange struct {
+	Name   string        `json:"name"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange old and new value of a top level field, secrets are redacted
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new,omitempty"`
+}
+
+// HandleConfigDiff compare a bundle in the format of /system/import with the loaded
+// configuration without applying it, ?mode=replace also reports entries missing from the bundle
+func HandleConfigDiff(c *gin.Context) {
+	replace := c.DefaultQuery("mode", "merge") == "replace"
+
+	var bundle ConfigBundle
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	projects := make([]types.ProjectConfig, 0, len(bundle.Projects))
+	for _, raw := range bundle.Projects {
+		project, err := projectFromJSON(raw)
+		if err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidBundleProject, err)
+			return
+		}
+		if project.Name == "" || project.Path == "" {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeProjectNamePathRequired, nil)
+			return
+		}
+		projects = append(projects, project)
+	}
+
+	var currentProjects []types.ProjectConfig
+	if types.GoHookVersionData != nil {
+		currentProjects = types.GoHookVersionData.Projects
+	}
+	var currentHooks []webhook.Hook
+	if webhook.HookManager != nil {
+		currentHooks = webhook.HookManager.GetAllHooks()
+	}
+	diff, err := diffConfig(currentHooks, currentProjects, bundle.Hooks, projects, replace)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeDiffConfigFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+// diffConfig diff of importing hooks and projects into the current ones
+func diffConfig(currentHooks []webhook.Hook, currentProjects []types.ProjectConfig, hooks []webhook.Hook, projects []types.ProjectConfig, replace bool) (*ConfigDiff, error) {
+	diff := &ConfigDiff{Mode: "merge", AffectedProjects: []string{}}
+	if replace {
+		diff.Mode = "replace"
+	}
+
+	seen := map[string]bool{}
+	for i := range hooks {
+		if hooks[i].ID == "" {
+			return nil, fmt.Errorf("hook without id")
+		}
+		if seen[hooks[i].ID] {
+			return nil, fmt.Errorf("duplicate hook id %s", hooks[i].ID)
+		}
+		seen[hooks[i].ID] = true
+		if err := hooks[i].Validate(); err != nil {
+			diff.Warnings = append(diff.Warnings, fmt.Sprintf("hook %s: %v", hooks[i].ID, err))
+		}
+	}
+	if err := webhook.ValidateMirrors(hooks); err != nil {
+		diff.Warnings = append(diff.Warnings, err.Error())
+	}
+	seenProjects := map[string]bool{}
+	for _, p := range projects {
+		if seenProjects[p.Name] {
+			return nil, fmt.Errorf("duplicate project name %s", p.Name)
+		}
+		seenProjects[p.Name] = true
+	}
+
+	newProjects := mergeProjects(currentProjects, projects, replace)
+	affected := map[string]bool{}
+
+	oldProjects := map[string]interface{}{}
+	for _, p := range currentProjects {
+		oldProjects[p.Name] = p
+	}
+	proposedProjects := map[string]interface{}{}
+	for _, p := range projects {
+		proposedProjects[p.Name] = p
+	}
+	var err error
+	diff.Projects, err = diffEntities(oldProjects, proposedProjects, replace, projectFields)
+	if err != nil {
+		return nil, err
+	}
+	for _, names := range [][]string{diff.Projects.Added, diff.Projects.Removed} {
+		for _, name := range names {
+			affected[name] = true
+		}
+	}
+	for _, change := range diff.Projects.Changed {
+		affected[change.Name] = true
+	}
+
+	oldHooks := map[string]interface{}{}
+	for _, h := range currentHooks {
+		oldHooks[h.ID] = h
+	}
+	proposedHooks := map[string]interface{}{}
+	for _, h := range hooks {
+		proposedHooks[h.ID] = h
+	}
+	diff.Hooks, err = diffEntities(oldHooks, proposedHooks, replace, hookFields)
+	if err != nil {
+		return nil, err
+	}
+	touched := append(append([]string{}, diff.Hooks.Added...), diff.Hooks.Removed...)
+	for _, change := range diff.Hooks.Changed {
+		touched = append(touched, change.Name)
+	}
+	for _, id := range touched {
+		for _, h := range []interface{}{oldHooks[id], proposedHooks[id]} {
+			if h, ok := h.(webhook.Hook); ok {
+				if name := webhook.DeployedProject(&h, currentProjects); name != "" {
+					affected[name] = true
+				}
+				if name := webhook.DeployedProject(&h, newProjects); name != "" {
+					affected[name] = true
+				}
+			}
+		}
+	}
+	for name := range affected {
+		diff.AffectedProjects = append(diff.AffectedProjects, name)
+	}
+	sort.Strings(diff.AffectedProjects)
+	return diff, nil
+}
+
+// diffEntities compare the proposed entities with the current ones by name, fields encodes
+// an entity as its configuration fields
+func diffEntities(current, proposed map[string]interface{}, replace bool, fields func(interface{}) (map[string]interface{}, error)) (EntityDiff, error) {
+	d := EntityDiff{Added: []string{}, Removed: []string{}, Changed: []EntityChange{}}
+	for _, name := range sortedKeys(proposed) {
+		old, ok := current[name]
+		if !ok {
+			d.Added = append(d.Added, name)
+			continue
+		}
+		oldFields, err := fields(old)
+		if err != nil {
+			return d, err
+		}
+		newFields, err := fields(proposed[name])
+		if err != nil {
+			return d, err
+		}
+		if changes := diffFields(oldFields, newFields); len(changes) > 0 {
+			d.Changed = append(d.Changed, EntityChange{Name: name, Fields: changes})
+		} else {
+			d.Unchanged++
+		}
+	}
+	for _, name := range sortedKeys(current) {
+		if _, ok := proposed[name]; ok {
+			continue
+		}
+		if replace {
+			d.Removed = append(d.Removed, name)
+		} else {
+			d.Unchanged++
+		}
+	}
+	return d, nil
+}
+
+// diffFields changed top level fields, sorted by name
+func diffFields(old, proposed map[string]interface{}) []FieldChange {
+	keys := map[string]interface{}{}
+	for k := range old {
+		keys[k] = nil
+	}
+	for k := range proposed {
+		keys[k] = nil
+	}
+	var changes []FieldChange
+	for _, k := range sortedKeys(keys) {
+		if reflect.DeepEqual(old[k], proposed[k]) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: k, Old: redactSecrets(k, old[k]), New: redactSecrets(k, proposed[k])})
+	}
+	return changes
+}
+
+// redactSecrets value of the field key with the values of secret fields replaced
+func redactSecrets(key string, v interface{}) interface{} {
+	if diffSecretKeys[key] {
+		if v == nil || v == "" {
+			return v
+		}
+		return redactedValue
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		r := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			r[k] = redactSecrets(k, value)
+		}
+		return r
+	case []interface{}:
+		r := make([]interface{}, len(v))
+		for i := range v {
+			r[i] = redactSecrets("", v[i])
+		}
+		return r
+	}
+	return v
+}
+
+// hookFields a hook as its fields of the hooks file
+func hookFields(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+// projectFields a project as its fields of version.yaml
+func projectFields(v interface{}) (map[string]interface{}, error) {
+	data, err := projectToJSON(v.(types.ProjectConfig))
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,96 @@
+package router
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+func TestDiffConfig(t *testing.T) {
+	currentProjects := []types.ProjectConfig{
+		{Name: "api", Path: "/srv/api", Enabled: true},
+		{Name: "blog", Path: "/srv/blog", Enabled: true, Hooksecret: "old"},
+		{Name: "shop", Path: "/srv/shop", Enabled: true},
+	}
+	currentHooks := []webhook.Hook{
+		{ID: "deploy-api", ExecuteCommand: "deploy.sh", CommandWorkingDirectory: "/srv/api"},
+		{ID: "deploy-shop", ExecuteCommand: "/srv/shop/deploy.sh"},
+		{ID: "ping", ExecuteCommand: "/bin/true"},
+	}
+	projects := []types.ProjectConfig{
+		{Name: "blog", Path: "/srv/blog", Enabled: true, Hooksecret: "new"},
+		{Name: "docs", Path: "/srv/docs", Enabled: true},
+		{Name: "shop", Path: "/srv/shop", Enabled: true},
+	}
+	hooks := []webhook.Hook{
+		{ID: "deploy-api", ExecuteCommand: "deploy.sh --prod", CommandWorkingDirectory: "/srv/api"},
+		{ID: "deploy-docs", ExecuteCommand: "/srv/docs/build.sh"},
+		{ID: "ping", ExecuteCommand: "/bin/true"},
+	}
+
+	tests := []struct {
+		name     string
+		replace  bool
+		hooks    string
+		projects string
+		affected string
+	}{
+		{"merge", false,
+			"+deploy-docs - ~deploy-api=execute-command =2",
+			"+docs - ~blog=hooksecret =2",
+			"api,blog,docs"},
+		{"replace", true,
+			"+deploy-docs -deploy-shop ~deploy-api=execute-command =1",
+			"+docs -api ~blog=hooksecret =1",
+			"api,blog,docs,shop"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := diffConfig(currentHooks, currentProjects, hooks, projects, tt.replace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := summarizeDiff(diff.Hooks); got != tt.hooks {
+				t.Errorf("hooks = %q, want %q", got, tt.hooks)
+			}
+			if got := summarizeDiff(diff.Projects); got != tt.projects {
+				t.Errorf("projects = %q, want %q", got, tt.projects)
+			}
+			if got := strings.Join(diff.AffectedProjects, ","); got != tt.affected {
+				t.Errorf("affected projects = %q, want %q", got, tt.affected)
+			}
+		})
+	}
+
+	diff, _ := diffConfig(currentHooks, currentProjects, nil, projects, false)
+	data, _ := json.Marshal(diff)
+	if strings.Contains(string(data), `"old":"old"`) || strings.Contains(string(data), `"new":"new"`) || !strings.Contains(string(data), redactedValue) {
+		t.Errorf("secrets in the diff: %s", data)
+	}
+
+	if _, err := diffConfig(nil, nil, []webhook.Hook{{ID: "a"}, {ID: "a"}}, nil, false); err == nil {
+		t.Error("duplicate hook ids accepted")
+	}
+	diff, err := diffConfig(nil, nil, []webhook.Hook{{ID: "bad", Starlark: &webhook.StarlarkConfig{Source: "def ("}}}, nil, false)
+	if err != nil || len(diff.Warnings) != 1 {
+		t.Errorf("diff of an invalid hook = %+v, %v", diff, err)
+	}
+}
+
+// summarizeDiff "+added -removed ~changed=fields =unchanged"
+func summarizeDiff(d EntityDiff) string {
+	var changed []string
+	for _, c := range d.Changed {
+		var fields []string
+		for _, f := range c.Fields {
+			fields = append(fields, f.Field)
+		}
+		changed = append(changed, c.Name+"="+strings.Join(fields, "/"))
+	}
+	return "+" + strings.Join(d.Added, ",") + " -" + strings.Join(d.Removed, ",") +
+		" ~" + strings.Join(changed, ",") + " =" + strconv.Itoa(d.Unchanged)
+}
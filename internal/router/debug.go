@@ -0,0 +1,231 @@
+package router
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	rpprof "runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/maintenance"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/pool"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+// diagnostics bundle limits
+const (
+	bundleSystemErrors     = 100
+	bundleFailedExecutions = 50
+	bundleErrorLength      = 1000
+)
+
+// BuildInfo version of the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+}
+
+// Build version of the running binary, set by main
+var Build = BuildInfo{Version: "unknown", Commit: "unknown", BuildDate: "unknown"}
+
+// processStart time the process started, for the uptime
+var processStart = time.Now()
+
+// RuntimeStats build and runtime metrics of the process
+type RuntimeStats struct {
+	BuildInfo
+	GoVersion      string     `json:"goVersion"`
+	OS             string     `json:"os"`
+	Arch           string     `json:"arch"`
+	PID            int        `json:"pid"`
+	CPUs           int        `json:"cpus"`
+	GOMAXPROCS     int        `json:"gomaxprocs"`
+	StartedAt      time.Time  `json:"startedAt"`
+	Uptime         string     `json:"uptime"`
+	Goroutines     int        `json:"goroutines"`
+	HeapAlloc      uint64     `json:"heapAllocBytes"` // bytes of allocated heap objects
+	HeapInuse      uint64     `json:"heapInuseBytes"`
+	HeapObjects    uint64     `json:"heapObjects"`
+	Sys            uint64     `json:"sysBytes"` // memory obtained from the OS
+	NumGC          uint32     `json:"numGC"`
+	LastGC         *time.Time `json:"lastGC,omitempty"`
+	GCPauseTotalMs float64    `json:"gcPauseTotalMs"`
+}
+
+// runtimeStats read the current runtime metrics
+func runtimeStats(now time.Time) RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	s := RuntimeStats{
+		BuildInfo:      Build,
+		GoVersion:      runtime.Version(),
+		OS:             runtime.GOOS,
+		Arch:           runtime.GOARCH,
+		PID:            os.Getpid(),
+		CPUs:           runtime.NumCPU(),
+		GOMAXPROCS:     runtime.GOMAXPROCS(0),
+		StartedAt:      processStart,
+		Uptime:         now.Sub(processStart).Round(time.Second).String(),
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAlloc:      m.HeapAlloc,
+		HeapInuse:      m.HeapInuse,
+		HeapObjects:    m.HeapObjects,
+		Sys:            m.Sys,
+		NumGC:          m.NumGC,
+		GCPauseTotalMs: float64(m.PauseTotalNs) / float64(time.Millisecond),
+	}
+	if m.LastGC > 0 {
+		last := time.Unix(0, int64(m.LastGC))
+		s.LastGC = &last
+	}
+	return s
+}
+
+// HandleDebugRuntime answer the goroutine count, heap and GC metrics of the process
+func HandleDebugRuntime(c *gin.Context) {
+	c.JSON(http.StatusOK, runtimeStats(time.Now()))
+}
+
+// HandlePprof serve the net/http/pprof profiles under /debug/pprof/
+func HandlePprof(c *gin.Context) {
+	switch strings.TrimPrefix(c.Param("profile"), "/") {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		// the index and the named profiles such as heap and goroutine
+		pprof.Index(c.Writer, c.Request)
+	}
+}
+
+// DiagnosticsErrors recent errors of the instance, without request bodies or command output
+type DiagnosticsErrors struct {
+	SystemErrors     []DiagnosticsSystemError `json:"systemErrors"`
+	FailedExecutions []DiagnosticsExecution   `json:"failedExecutions"`
+	Warnings         []string                 `json:"warnings,omitempty"`
+}
+
+// DiagnosticsSystemError system log entry of level ERROR
+type DiagnosticsSystemError struct {
+	Time     time.Time `json:"time"`
+	Category string    `json:"category"`
+	Message  string    `json:"message"`
+}
+
+// DiagnosticsExecution failed hook or GitHook execution
+type DiagnosticsExecution struct {
+	Time     time.Time `json:"time"`
+	HookID   string    `json:"hookId"`
+	HookType string    `json:"hookType"`
+	Error    string    `json:"error"`
+	Duration int64     `json:"durationMs"`
+}
+
+// recentErrors latest system errors and failed executions from the log database
+func recentErrors() DiagnosticsErrors {
+	errs := DiagnosticsErrors{SystemErrors: []DiagnosticsSystemError{}, FailedExecutions: []DiagnosticsExecution{}}
+	db := database.GetDB()
+	if db == nil {
+		errs.Warnings = append(errs.Warnings, "database not initialized")
+		return errs
+	}
+
+	var logs []database.SystemLog
+	if err := db.Where("level = ?", "ERROR").Order("created_at DESC").Limit(bundleSystemErrors).Find(&logs).Error; err != nil {
+		errs.Warnings = append(errs.Warnings, "system logs: "+err.Error())
+	}
+	for _, l := range logs {
+		errs.SystemErrors = append(errs.SystemErrors, DiagnosticsSystemError{Time: l.CreatedAt, Category: l.Category, Message: l.Message})
+	}
+
+	var runs []database.HookLog
+	err := db.Select("created_at", "hook_id", "hook_type", "error", "duration").
+		Where("success = ?", false).Order("created_at DESC").Limit(bundleFailedExecutions).Find(&runs).Error
+	if err != nil {
+		errs.Warnings = append(errs.Warnings, "hook logs: "+err.Error())
+	}
+	for _, r := range runs {
+		message := r.Revealed(false).Error
+		if len(message) > bundleErrorLength {
+			message = message[:bundleErrorLength] + "..."
+		}
+		errs.FailedExecutions = append(errs.FailedExecutions, DiagnosticsExecution{
+			Time: r.CreatedAt, HookID: r.HookID, HookType: r.HookType, Error: message, Duration: r.Duration,
+		})
+	}
+	return errs
+}
+
+// writeDiagnosticsBundle write the zip archive of the diagnostics bundle to buf
+func writeDiagnosticsBundle(buf *bytes.Buffer, now time.Time) error {
+	var files []string
+	asTemplate := false
+	if webhook.HookManager != nil {
+		files, asTemplate = webhook.HookManager.HooksFiles, webhook.HookManager.AsTemplate
+	}
+	entries := []struct {
+		name  string
+		value interface{}
+	}{
+		{"runtime.json", runtimeStats(now)},
+		{"inventory.json", buildInventory(now)},
+		{"lint.json", LintConfig(files, asTemplate)},
+		{"hooks-files.json", webhook.HooksFileStatuses()},
+		{"queues.json", QueuesResponse{Executions: pool.Executions.Stats(), Held: maintenance.QueuedCount()}},
+		{"errors.json", recentErrors()},
+	}
+
+	zw := zip.NewWriter(buf)
+	for _, e := range entries {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(e.value, "", "  ")
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "goroutines.txt", Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return err
+	}
+	if err := rpprof.Lookup("goroutine").WriteTo(w, 1); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// HandleDiagnosticsBundle download a zip of the runtime metrics, the configuration inventory
+// and lint report, the hooks file and queue states, recent errors and the goroutine stacks.
+// Secrets, request bodies and command output are left out.
+func HandleDiagnosticsBundle(c *gin.Context) {
+	now := time.Now()
+	var buf bytes.Buffer
+	if err := writeDiagnosticsBundle(&buf, now); err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDiagnosticsFailed, err)
+		return
+	}
+	database.LogUserAction(c.GetString("username"), database.UserActionDiagnostics, "diagnostics", "download diagnostics bundle",
+		middleware.GetClientIP(c), c.Request.UserAgent(), true, nil)
+	c.Header("Content-Disposition", "attachment; filename=gohook-diagnostics-"+now.Format("20060102-150405")+".zip")
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
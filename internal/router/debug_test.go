@@ -0,0 +1,61 @@
+package router
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiagnosticsBundle(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeDiagnosticsBundle(&buf, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := map[string][]byte{}
+	var names []string
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, f.Name)
+		contents[f.Name] = data
+	}
+	want := "runtime.json,inventory.json,lint.json,hooks-files.json,queues.json,errors.json,goroutines.txt"
+	if got := strings.Join(names, ","); got != want {
+		t.Fatalf("entries = %s, want %s", got, want)
+	}
+
+	var stats RuntimeStats
+	if err := json.Unmarshal(contents["runtime.json"], &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Goroutines == 0 || stats.HeapAlloc == 0 || stats.Version != Build.Version || stats.GoVersion == "" {
+		t.Errorf("runtime = %+v", stats)
+	}
+
+	var errs DiagnosticsErrors
+	if err := json.Unmarshal(contents["errors.json"], &errs); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs.SystemErrors) != 0 || len(errs.FailedExecutions) != 0 || len(errs.Warnings) != 1 {
+		t.Errorf("errors without a database = %+v", errs)
+	}
+	if !strings.Contains(string(contents["goroutines.txt"]), "TestDiagnosticsBundle") {
+		t.Error("goroutines.txt does not hold the stack of the test")
+	}
+}
@@ -0,0 +1,362 @@
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/plugin"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/version"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+// scripts larger than this are listed without a digest
+const inventoryMaxScriptSize = 16 << 20
+
+// Inventory machine-readable summary of the configuration of the instance, for compliance
+// reviews and drift detection against infrastructure as code. Secrets are never included:
+// passwords, tokens and plugin configurations are at most reported as set or by digest.
+type Inventory struct {
+	GeneratedAt time.Time            `json:"generatedAt"`
+	Digest      string               `json:"digest"` // SHA-256 of the inventory without generatedAt and digest
+	HooksFiles  []InventoryHooksFile `json:"hooksFiles"`
+	Hooks       []InventoryHook      `json:"hooks"`
+	Projects    []InventoryProject   `json:"projects"`
+	Namespaces  []string             `json:"namespaces"`
+	Users       []InventoryUser      `json:"users"`
+	Nodes       []InventoryNode      `json:"nodes"`
+	Plugins     []InventoryPlugin    `json:"plugins"`
+	Warnings    []string             `json:"warnings,omitempty"` // sources that could not be read
+}
+
+// InventoryHooksFile loaded hooks file
+type InventoryHooksFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256,omitempty"`
+	Hooks  int    `json:"hooks"`
+}
+
+// InventoryHook hook and the files it runs
+type InventoryHook struct {
+	ID               string   `json:"id"`
+	Namespace        string   `json:"namespace"`
+	File             string   `json:"file"` // hooks file defining the hook
+	Aliases          []string `json:"aliases,omitempty"`
+	Command          string   `json:"command,omitempty"`
+	ScriptSHA256     string   `json:"scriptSha256,omitempty"` // digest of the command when it is a file
+	WorkingDirectory string   `json:"workingDirectory,omitempty"`
+	Shell            string   `json:"shell,omitempty"`
+	Sandbox          string   `json:"sandbox,omitempty"`
+	HTTPMethods      []string `json:"httpMethods,omitempty"`
+	ForwardURL       string   `json:"forwardUrl,omitempty"`
+	TriggerRule      bool     `json:"triggerRule"`
+	TransformPlugins []string `json:"transformPlugins,omitempty"`
+	Features         []string `json:"features,omitempty"` // optional settings in use, e.g. idempotency
+}
+
+// InventoryProject project managed by the version manager
+type InventoryProject struct {
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Path      string   `json:"path"`
+	VCS       string   `json:"vcs"`
+	Remote    string   `json:"remote,omitempty"` // origin remote, credentials redacted
+	Enabled   bool     `json:"enabled"`
+	GitHook   bool     `json:"githook"`
+	HookMode  string   `json:"hookMode,omitempty"`
+	Branch    string   `json:"hookBranch,omitempty"`
+	SecretSet bool     `json:"hookSecretSet"`
+	HookPaths []string `json:"hookPaths,omitempty"`
+	Pinned    bool     `json:"pinned"`
+	Features  []string `json:"features,omitempty"`
+}
+
+// InventoryUser user and role
+type InventoryUser struct {
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// InventoryNode registered sync node, without its credentials and live status
+type InventoryNode struct {
+	ID             uint   `json:"id"`
+	Name           string `json:"name"`
+	Address        string `json:"address"`
+	Type           string `json:"type"`
+	Tags           string `json:"tags,omitempty"`
+	Labels         string `json:"labels,omitempty"`
+	ApprovalStatus string `json:"approvalStatus,omitempty"`
+	AgentVersion   string `json:"agentVersion,omitempty"`
+}
+
+// InventoryPlugin plugin found in the plugins directory
+type InventoryPlugin struct {
+	Name         string   `json:"name"`
+	Kind         string   `json:"kind"`
+	Path         string   `json:"path"`
+	Version      string   `json:"version,omitempty"`
+	Enabled      bool     `json:"enabled"`
+	Capabilities []string `json:"capabilities"`
+	ConfigSHA256 string   `json:"configSha256,omitempty"`
+}
+
+// HandleInventory answer the configuration inventory of the instance
+func HandleInventory(c *gin.Context) {
+	c.JSON(http.StatusOK, buildInventory(time.Now()))
+}
+
+// buildInventory collect the inventory of the current configuration
+func buildInventory(now time.Time) *Inventory {
+	inv := &Inventory{}
+	if webhook.LoadedHooksFromFiles != nil {
+		inv.HooksFiles, inv.Hooks = inventoryHooks(*webhook.LoadedHooksFromFiles)
+	}
+	if types.GoHookVersionData != nil {
+		inv.Projects = inventoryProjects(types.GoHookVersionData.Projects, version.ProjectRemote)
+	}
+	for _, ns := range namespace.List() {
+		inv.Namespaces = append(inv.Namespaces, ns.Name)
+	}
+	if types.GoHookUsersConfig != nil {
+		inv.Users = inventoryUsers(types.GoHookUsersConfig.Users)
+	}
+	if db := database.GetDB(); db != nil {
+		var nodes []database.SyncNode
+		if err := db.Order("id").Find(&nodes).Error; err != nil {
+			inv.Warnings = append(inv.Warnings, "sync nodes: "+err.Error())
+		}
+		inv.Nodes = inventoryNodes(nodes)
+	}
+	for _, p := range plugin.List() {
+		item := InventoryPlugin{Name: p.Name, Kind: p.Kind, Path: p.ModulePath, Version: p.Version, Enabled: p.Enabled, Capabilities: p.Capabilities}
+		if _, config, err := plugin.Get(p.ID); err == nil && config != "" {
+			item.ConfigSHA256 = digest([]byte(config))
+		}
+		inv.Plugins = append(inv.Plugins, item)
+	}
+	inv.seal(now)
+	return inv
+}
+
+// seal set the generation time and the digest of the content
+func (inv *Inventory) seal(now time.Time) {
+	if inv.HooksFiles == nil {
+		inv.HooksFiles = []InventoryHooksFile{}
+	}
+	if inv.Hooks == nil {
+		inv.Hooks = []InventoryHook{}
+	}
+	if inv.Projects == nil {
+		inv.Projects = []InventoryProject{}
+	}
+	if inv.Namespaces == nil {
+		inv.Namespaces = []string{}
+	}
+	if inv.Users == nil {
+		inv.Users = []InventoryUser{}
+	}
+	if inv.Nodes == nil {
+		inv.Nodes = []InventoryNode{}
+	}
+	if inv.Plugins == nil {
+		inv.Plugins = []InventoryPlugin{}
+	}
+	inv.GeneratedAt, inv.Digest = time.Time{}, ""
+	data, _ := json.Marshal(inv)
+	inv.GeneratedAt, inv.Digest = now, digest(data)
+}
+
+// inventoryHooks hooks files and their hooks, sorted by path and id
+func inventoryHooks(loaded map[string]webhook.Hooks) ([]InventoryHooksFile, []InventoryHook) {
+	paths := make([]string, 0, len(loaded))
+	for path := range loaded {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var files []InventoryHooksFile
+	var hooks []InventoryHook
+	for _, path := range paths {
+		file := InventoryHooksFile{Path: path, Hooks: len(loaded[path])}
+		if data, err := os.ReadFile(path); err == nil {
+			file.SHA256 = digest(data)
+		}
+		files = append(files, file)
+		for _, h := range loaded[path] {
+			item := InventoryHook{
+				ID:               h.ID,
+				Namespace:        namespace.Normalize(h.Namespace),
+				File:             path,
+				Aliases:          h.Aliases,
+				Command:          h.ExecuteCommand,
+				WorkingDirectory: h.CommandWorkingDirectory,
+				Shell:            h.Shell,
+				Sandbox:          h.Sandbox,
+				HTTPMethods:      h.HTTPMethods,
+				TriggerRule:      h.TriggerRule != nil,
+				TransformPlugins: h.TransformPlugins,
+				Features:         hookFeatures(&h),
+			}
+			if h.Forward != nil {
+				item.ForwardURL = h.Forward.URL
+				item.Command = ""
+			} else {
+				item.ScriptSHA256 = scriptDigest(h.ExecuteCommand, h.CommandWorkingDirectory)
+			}
+			hooks = append(hooks, item)
+		}
+	}
+	sort.SliceStable(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	return files, hooks
+}
+
+// hookFeatures optional settings of h in use
+func hookFeatures(h *webhook.Hook) []string {
+	var features []string
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"forward", h.Forward != nil},
+		{"idempotency", h.Idempotency != nil},
+		{"artifacts", h.Artifacts != nil},
+		{"object-events", h.ObjectEvents != nil},
+		{"starlark", h.Starlark != nil},
+		{"pause-windows", len(h.PauseWindows) > 0},
+		{"inherit-environment", h.InheritEnvironment != nil},
+		{"pass-request-body-to-stdin", h.PassRequestBodyToStdin},
+		{"response-template", h.ResponseTemplate != ""},
+	} {
+		if f.set {
+			features = append(features, f.name)
+		}
+	}
+	return features
+}
+
+// scriptDigest SHA-256 of the command file, empty for inline commands and missing files
+func scriptDigest(command, workingDirectory string) string {
+	if command == "" {
+		return ""
+	}
+	path := command
+	if !filepath.IsAbs(path) && workingDirectory != "" {
+		path = filepath.Join(workingDirectory, path)
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > inventoryMaxScriptSize {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return digest(data)
+}
+
+// inventoryProjects projects sorted by name, remote looks the origin remote up
+func inventoryProjects(projects []types.ProjectConfig, remote func(path string) string) []InventoryProject {
+	var items []InventoryProject
+	for _, p := range projects {
+		vcs := p.VCS
+		if vcs == "" {
+			vcs = "git"
+		}
+		item := InventoryProject{
+			Name:      p.Name,
+			Namespace: namespace.Normalize(p.Namespace),
+			Path:      p.Path,
+			VCS:       vcs,
+			Remote:    redactRemote(remote(p.Path)),
+			Enabled:   p.Enabled,
+			GitHook:   p.Enhook,
+			HookMode:  p.Hookmode,
+			Branch:    p.Hookbranch,
+			SecretSet: p.Hooksecret != "",
+			HookPaths: p.Hookpaths,
+			Pinned:    p.Pin != nil,
+		}
+		for _, f := range []struct {
+			name string
+			set  bool
+		}{
+			{"monorepo", p.Monorepo != ""},
+			{"force-sync", p.ForceSync},
+			{"encrypt-env", p.EncryptEnv},
+			{"service", p.Service != nil},
+			{"sync", p.Sync != nil},
+			{"pause-windows", len(p.PauseWindows) > 0},
+			{"promotion", p.Promotion != nil},
+			{"preflight", p.Preflight != nil},
+			{"snapshots", p.Snapshots != nil},
+			{"protection", p.Protection != nil},
+			{"git-maintenance", p.GitMaintenance != nil},
+			{"signatures", p.Signatures != nil},
+			{"kubernetes", p.Kubernetes != nil},
+			{"compose", p.Compose != nil},
+			{"migrations", p.Migrations != nil},
+			{"releases", p.Releases != nil},
+			{"healthcheck", p.HealthCheck != nil},
+		} {
+			if f.set {
+				item.Features = append(item.Features, f.name)
+			}
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items
+}
+
+// redactRemote remote URL without the password of embedded credentials
+func redactRemote(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || u.User == nil {
+		return remote
+	}
+	return u.Redacted()
+}
+
+// inventoryUsers users sorted by name, without passwords
+func inventoryUsers(users []types.UserConfig) []InventoryUser {
+	var items []InventoryUser
+	for _, u := range users {
+		items = append(items, InventoryUser{Username: u.Username, Role: u.Role, Namespace: u.Namespace})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Username < items[j].Username })
+	return items
+}
+
+// inventoryNodes registered nodes, without credentials and live status
+func inventoryNodes(nodes []database.SyncNode) []InventoryNode {
+	var items []InventoryNode
+	for _, n := range nodes {
+		items = append(items, InventoryNode{
+			ID:             n.ID,
+			Name:           n.Name,
+			Address:        n.Address,
+			Type:           n.Type,
+			Tags:           n.Tags,
+			Labels:         n.Labels,
+			ApprovalStatus: n.ApprovalStatus,
+			AgentVersion:   n.AgentVersion,
+		})
+	}
+	return items
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
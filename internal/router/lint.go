@@ -0,0 +1,377 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/client"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/quota"
+	"github.com/mycoool/gohook/internal/redact"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+// Levels of lint findings, errors break hooks or deploys, warnings are risky settings
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// lintMinSecretLength secrets shorter than this are reported as weak
+const lintMinSecretLength = 16
+
+// lintDefaultJWTSecret JWT secret used when app.yaml sets none
+const lintDefaultJWTSecret = "gohook-secret-key-change-in-production"
+
+// lintWeakPasswords passwords reported when a user still has one of them, the first is the
+// password of the generated admin user
+var lintWeakPasswords = []string{"admin123", "admin", "password", "123456"}
+
+// lintWeakSecrets placeholder secrets reported regardless of their length
+var lintWeakSecrets = map[string]bool{
+	"secret": true, "changeme": true, "change-me": true, "password": true, "123456": true, "test": true,
+}
+
+// LintFinding problem found in the configuration
+type LintFinding struct {
+	Level   string `json:"level"` // error | warning
+	File    string `json:"file"`  // hooks file, version.yaml, user.yaml or app.yaml
+	Item    string `json:"item,omitempty"`
+	Message string `json:"message"`
+}
+
+// String finding as one log line
+func (f LintFinding) String() string {
+	if f.Item == "" {
+		return fmt.Sprintf("%s: %s: %s", f.Level, f.File, f.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s: %s", f.Level, f.File, f.Item, f.Message)
+}
+
+// LintReport findings of a configuration lint
+type LintReport struct {
+	CheckedAt time.Time     `json:"checkedAt"`
+	Errors    int           `json:"errors"`
+	Warnings  int           `json:"warnings"`
+	Findings  []LintFinding `json:"findings"`
+}
+
+type linter struct {
+	findings []LintFinding
+}
+
+func (l *linter) add(level, file, item, format string, args ...interface{}) {
+	l.findings = append(l.findings, LintFinding{Level: level, File: file, Item: item, Message: fmt.Sprintf(format, args...)})
+}
+
+// LintConfig lint the hooks files, read again from disk, and the loaded version.yaml,
+// user.yaml and app.yaml
+func LintConfig(hooksFiles []string, asTemplate bool) *LintReport {
+	l := &linter{}
+	l.lintHooks(hooksFiles, asTemplate)
+	if types.GoHookVersionData != nil {
+		l.lintProjects(types.GoHookVersionData.Projects)
+	}
+	if types.GoHookUsersConfig != nil {
+		l.lintUsers(types.GoHookUsersConfig.Users)
+	}
+	for _, ns := range namespace.List() {
+		if err := quota.Validate(ns.Quota); err != nil {
+			l.add(LintError, "app.yaml", "namespace "+ns.Name, "%v", err)
+		}
+	}
+	if types.GoHookAppConfig != nil && (types.GoHookAppConfig.JWTSecret == "" || types.GoHookAppConfig.JWTSecret == lintDefaultJWTSecret) {
+		l.add(LintWarning, "app.yaml", "jwt_secret", "the default JWT secret is used, anyone can sign panel tokens: set a random jwt_secret")
+	}
+	if types.GoHookAppConfig != nil {
+		if _, err := redact.Compile(types.GoHookAppConfig.Redaction); err != nil {
+			l.add(LintError, "app.yaml", "redaction", "%v", err)
+		}
+	}
+	return l.report(time.Now())
+}
+
+// report findings sorted by level, file and item
+func (l *linter) report(now time.Time) *LintReport {
+	r := &LintReport{CheckedAt: now, Findings: l.findings}
+	if r.Findings == nil {
+		r.Findings = []LintFinding{}
+	}
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		a, b := r.Findings[i], r.Findings[j]
+		if a.Level != b.Level {
+			return a.Level == LintError
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Item < b.Item
+	})
+	for _, f := range r.Findings {
+		if f.Level == LintError {
+			r.Errors++
+		} else {
+			r.Warnings++
+		}
+	}
+	return r
+}
+
+// lintHooks parse the hooks files and check their hooks. Ids, aliases and endpoints must be
+// unique across all files, mirror hooks must exist in one of them, in the same namespace.
+func (l *linter) lintHooks(files []string, asTemplate bool) {
+	owners := map[string]string{}     // hook id, alias or endpoint -> hook owning it
+	mirrors := map[string]string{}    // hook id -> its mirror hook
+	namespaces := map[string]string{} // hook id -> its namespace
+	var mirroring []struct{ file, hook, namespace, mirror string }
+	seenFiles := map[string]bool{}
+	for _, file := range files {
+		if seenFiles[file] {
+			continue
+		}
+		seenFiles[file] = true
+
+		var hooks webhook.Hooks
+		if err := hooks.LoadFromFile(file, asTemplate); err != nil {
+			l.add(LintError, file, "", "cannot be loaded, its hooks are not served: %v", err)
+			continue
+		}
+		for i := range hooks {
+			h := &hooks[i]
+			names := []string{h.ID}
+			names = append(names, h.Aliases...)
+			for _, e := range h.Endpoints {
+				names = append(names, e.ID)
+			}
+			for _, name := range names {
+				if owner, ok := owners[name]; ok {
+					l.add(LintError, file, h.ID, "id %s is already used by hook %s, rename one of them", name, owner)
+					continue
+				}
+				owners[name] = h.ID
+			}
+			l.lintHook(file, h)
+			if _, ok := mirrors[h.ID]; !ok {
+				mirrors[h.ID] = h.Mirror
+				namespaces[h.ID] = namespace.Normalize(h.Namespace)
+			}
+			if h.Mirror != "" {
+				mirroring = append(mirroring, struct{ file, hook, namespace, mirror string }{file, h.ID, namespace.Normalize(h.Namespace), h.Mirror})
+			}
+		}
+	}
+
+	for _, m := range mirroring {
+		next, ok := mirrors[m.mirror]
+		switch {
+		case !ok:
+			l.add(LintError, m.file, m.hook, "mirror hook %s not found, deliveries are not mirrored", m.mirror)
+		case namespaces[m.mirror] != m.namespace:
+			l.add(LintError, m.file, m.hook, "mirror hook %s is in namespace %s, not %s, deliveries are not mirrored", m.mirror, namespaces[m.mirror], m.namespace)
+		case next != "":
+			l.add(LintWarning, m.file, m.hook, "mirror hook %s mirrors to %s itself, mirrored deliveries are not mirrored again", m.mirror, next)
+		}
+	}
+}
+
+// lintHook check the settings, command and secrets of one hook
+func (l *linter) lintHook(file string, h *webhook.Hook) {
+	if err := h.Validate(); err != nil {
+		l.add(LintError, file, h.ID, "%v", err)
+	}
+	if h.Namespace != "" && !namespace.Exists(h.Namespace) {
+		l.add(LintError, file, h.ID, "namespace %s is not configured in app.yaml", h.Namespace)
+	}
+	if h.CommandWorkingDirectory != "" {
+		if info, err := os.Stat(h.CommandWorkingDirectory); err != nil || !info.IsDir() {
+			l.add(LintError, file, h.ID, "command-working-directory %s does not exist", h.CommandWorkingDirectory)
+		}
+	}
+	if h.Forward == nil && (h.Shell == "" || h.Shell == webhook.ShellNone) {
+		if h.ExecuteCommand == "" {
+			l.add(LintError, file, h.ID, "execute-command is empty, set a command or forward")
+		} else if _, err := exec.LookPath(lintCommandPath(h)); err != nil {
+			hint := ""
+			if strings.ContainsAny(h.ExecuteCommand, " \t") {
+				hint = ", pass arguments with pass-arguments-to-command or set a shell"
+			}
+			l.add(LintError, file, h.ID, "execute-command %s not found or not executable%s", h.ExecuteCommand, hint)
+		}
+	}
+
+	if h.TriggerRule == nil && h.Forward == nil {
+		l.add(LintWarning, file, h.ID, "no trigger-rule, anyone who knows the URL can run the hook")
+	}
+	l.lintRules(file, h.ID, h.TriggerRule)
+	for _, e := range h.Endpoints {
+		l.lintRules(file, h.ID+"/"+e.ID, e.TriggerRule)
+	}
+}
+
+// lintCommandPath file executed for the command of h, relative commands are looked up in
+// the working directory like at execution
+func lintCommandPath(h *webhook.Hook) string {
+	if filepath.IsAbs(h.ExecuteCommand) || h.CommandWorkingDirectory == "" {
+		return h.ExecuteCommand
+	}
+	return filepath.Join(h.CommandWorkingDirectory, h.ExecuteCommand)
+}
+
+// lintRules report weak secrets of the signature rules in r
+func (l *linter) lintRules(file, item string, r *webhook.Rules) {
+	if r == nil {
+		return
+	}
+	if r.And != nil {
+		for i := range *r.And {
+			l.lintRules(file, item, &(*r.And)[i])
+		}
+	}
+	if r.Or != nil {
+		for i := range *r.Or {
+			l.lintRules(file, item, &(*r.Or)[i])
+		}
+	}
+	if r.Not != nil {
+		not := webhook.Rules(*r.Not)
+		l.lintRules(file, item, &not)
+	}
+	if r.Match == nil {
+		return
+	}
+	switch r.Match.Type {
+	case webhook.MatchHashSHA1, webhook.MatchHashSHA256, webhook.MatchHashSHA512:
+		l.add(LintWarning, file, item, "match type %s is deprecated, use payload-hmac-%s", r.Match.Type, strings.TrimPrefix(r.Match.Type, "payload-hash-"))
+		fallthrough
+	case webhook.MatchHMACSHA1, webhook.MatchHMACSHA256, webhook.MatchHMACSHA512, webhook.ScalrSignature:
+		if weak := weakSecret(r.Match.Secret); weak != "" {
+			l.add(LintWarning, file, item, "%s secret %s", r.Match.Type, weak)
+		}
+	case webhook.MatchValue:
+		if r.Match.Parameter.Source == "header" && weakSecret(r.Match.Value) != "" && lintTokenHeader(r.Match.Parameter.Name) {
+			l.add(LintWarning, file, item, "token in header %s %s", r.Match.Parameter.Name, weakSecret(r.Match.Value))
+		}
+	}
+}
+
+// lintTokenHeader header that carries a shared token compared by a value rule
+func lintTokenHeader(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "token") || strings.Contains(name, "secret") || strings.Contains(name, "key")
+}
+
+// weakSecret why secret is weak, empty when it is not
+func weakSecret(secret string) string {
+	switch {
+	case secret == "":
+		return "is empty"
+	case lintWeakSecrets[strings.ToLower(secret)]:
+		return "is a placeholder value"
+	case len(secret) < lintMinSecretLength:
+		return fmt.Sprintf("is shorter than %d characters", lintMinSecretLength)
+	}
+	return ""
+}
+
+// lintProjects check names, paths, GitHook secrets and the settings of the projects
+func (l *linter) lintProjects(projects []types.ProjectConfig) {
+	const file = "version.yaml"
+	seen := map[string]bool{}
+	for i := range projects {
+		p := &projects[i]
+		if p.Name == "" {
+			l.add(LintError, file, p.Path, "project has no name")
+		} else if seen[p.Name] {
+			l.add(LintError, file, p.Name, "project name is used more than once")
+		}
+		seen[p.Name] = true
+
+		level := LintError
+		if !p.Enabled {
+			level = LintWarning
+		}
+		if p.Path == "" {
+			l.add(level, file, p.Name, "project has no path")
+		} else if info, err := os.Stat(p.Path); err != nil || !info.IsDir() {
+			l.add(level, file, p.Name, "path %s does not exist, clone the project or fix the path", p.Path)
+		}
+		if p.Hookmode != "" && p.Hookmode != "branch" && p.Hookmode != "tag" {
+			l.add(LintError, file, p.Name, "hookmode must be branch or tag, got %s", p.Hookmode)
+		}
+		if p.Enhook {
+			if weak := weakSecret(p.Hooksecret); weak != "" {
+				l.add(LintWarning, file, p.Name, "GitHook hooksecret %s, deliveries are not authenticated reliably", weak)
+			}
+		}
+
+		for name, err := range map[string]error{
+			"signatures":      p.Signatures.Validate(),
+			"kubernetes":      p.Kubernetes.Validate(),
+			"artifact":        p.Artifact.Validate(),
+			"provider":        p.Provider.Validate(),
+			"healthcheck":     p.HealthCheck.Validate(),
+			"releases":        p.Releases.Validate(),
+			"migrations":      p.Migrations.Validate(),
+			"compose":         p.Compose.Validate(),
+			"git_maintenance": p.GitMaintenance.Validate(),
+			"poll":            p.Poll.Validate(),
+			"protection":      p.Protection.Validate(),
+			"preflight":       p.Preflight.Validate(),
+		} {
+			if err != nil {
+				l.add(LintError, file, p.Name, "invalid %s: %v", name, err)
+			}
+		}
+	}
+}
+
+// lintUsers check for duplicate users, unknown roles and namespaces and default passwords
+func (l *linter) lintUsers(users []types.UserConfig) {
+	const file = "user.yaml"
+	seen := map[string]bool{}
+	for _, u := range users {
+		if u.Username == "" {
+			l.add(LintError, file, "", "user without username")
+			continue
+		}
+		if seen[u.Username] {
+			l.add(LintError, file, u.Username, "username is used more than once, only the first can log in")
+		}
+		seen[u.Username] = true
+		if u.Role != "admin" && u.Role != "user" {
+			l.add(LintError, file, u.Username, "role must be admin or user, got %q", u.Role)
+		}
+		if u.Namespace != "" && !namespace.Exists(u.Namespace) {
+			l.add(LintError, file, u.Username, "namespace %s is not configured in app.yaml", u.Namespace)
+		}
+		if err := quota.Validate(u.Quota); err != nil {
+			l.add(LintError, file, u.Username, "%v", err)
+		} else if u.Quota != nil && u.Quota.MaxStorageBytes > 0 {
+			l.add(LintWarning, file, u.Username, "user quotas only limit executions, max_storage_bytes is ignored")
+		}
+		for _, password := range lintWeakPasswords {
+			if client.VerifyPassword(password, u.Password) {
+				l.add(LintWarning, file, u.Username, "the password is a well-known default, change it")
+				break
+			}
+		}
+	}
+}
+
+// HandleLint lint the current configuration
+func HandleLint(c *gin.Context) {
+	var files []string
+	asTemplate := false
+	if webhook.HookManager != nil {
+		files, asTemplate = webhook.HookManager.HooksFiles, webhook.HookManager.AsTemplate
+	}
+	c.JSON(http.StatusOK, LintConfig(files, asTemplate))
+}
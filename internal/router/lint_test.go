@@ -0,0 +1,127 @@
+package router
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mycoool/gohook/internal/client"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// lintMessages findings as "level item: message" lines
+func lintMessages(findings []LintFinding) string {
+	var lines []string
+	for _, f := range findings {
+		lines = append(lines, f.Level+" "+f.Item+": "+f.Message)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestLintHooks(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "deploy.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	first := filepath.Join(dir, "hooks.json")
+	if err := os.WriteFile(first, []byte(`[
+		{"id": "deploy", "execute-command": "deploy.sh", "command-working-directory": "`+dir+`",
+		 "trigger-rule": {"match": {"type": "payload-hmac-sha256", "secret": "a-long-enough-secret-value", "parameter": {"source": "header", "name": "X-Hub-Signature-256"}}}},
+		{"id": "build", "execute-command": "/missing/build.sh", "aliases": ["ci"],
+		 "trigger-rule": {"and": [{"match": {"type": "payload-hmac-sha1", "secret": "changeme", "parameter": {"source": "header", "name": "X-Hub-Signature"}}}]}}
+	]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	second := filepath.Join(dir, "more.json")
+	if err := os.WriteFile(second, []byte(`[{"id": "ci", "execute-command": "`+script+`", "success-http-response-code": 42},
+		{"id": "canary", "execute-command": "`+script+`", "mirror": "missing"},
+		{"id": "staging", "execute-command": "`+script+`", "mirror": "canary"},
+		{"id": "team-canary", "namespace": "team", "execute-command": "`+script+`", "mirror": "staging"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := &linter{}
+	l.lintHooks([]string{first, second, first, filepath.Join(dir, "broken.json")}, false)
+	got := lintMessages(l.findings)
+	for _, want := range []string{
+		"error build: execute-command /missing/build.sh not found or not executable",
+		"warning build: payload-hmac-sha1 secret is a placeholder value",
+		"error ci: id ci is already used by hook build, rename one of them",
+		"error ci: invalid success-http-response-code: 42",
+		"warning ci: no trigger-rule, anyone who knows the URL can run the hook",
+		"error canary: mirror hook missing not found, deliveries are not mirrored",
+		"warning staging: mirror hook canary mirrors to missing itself",
+		"error team-canary: mirror hook staging is in namespace default, not team",
+		"error : cannot be loaded, its hooks are not served",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("findings missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "deploy:") {
+		t.Errorf("deploy is valid, got:\n%s", got)
+	}
+}
+
+func TestLintProjects(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		project types.ProjectConfig
+		want    string
+	}{
+		{"valid", types.ProjectConfig{Name: "shop", Path: dir, Enabled: true, Enhook: true, Hooksecret: "0123456789abcdef0123"}, ""},
+		{"missing path", types.ProjectConfig{Name: "shop", Path: filepath.Join(dir, "gone"), Enabled: true}, "error shop: path"},
+		{"missing path disabled", types.ProjectConfig{Name: "shop", Path: filepath.Join(dir, "gone")}, "warning shop: path"},
+		{"short secret", types.ProjectConfig{Name: "shop", Path: dir, Enabled: true, Enhook: true, Hooksecret: "abc"}, "warning shop: GitHook hooksecret is shorter than 16 characters"},
+		{"hookmode", types.ProjectConfig{Name: "shop", Path: dir, Enabled: true, Hookmode: "commit"}, "error shop: hookmode must be branch or tag"},
+		{"sub config", types.ProjectConfig{Name: "shop", Path: dir, Enabled: true, Preflight: &types.ProjectPreflightConfig{MinFreeMB: -5}}, "error shop: invalid preflight"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &linter{}
+			l.lintProjects([]types.ProjectConfig{tt.project})
+			got := lintMessages(l.findings)
+			if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+				t.Errorf("findings = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	l := &linter{}
+	l.lintProjects([]types.ProjectConfig{{Name: "shop", Path: dir}, {Name: "shop", Path: dir}})
+	if got := lintMessages(l.findings); got != "error shop: project name is used more than once" {
+		t.Errorf("duplicate findings = %q", got)
+	}
+}
+
+func TestLintUsers(t *testing.T) {
+	l := &linter{}
+	l.lintUsers([]types.UserConfig{
+		{Username: "admin", Password: client.HashPassword("admin123"), Role: "admin"},
+		{Username: "dev", Password: client.HashPassword("a-strong-password"), Role: "user"},
+		{Username: "dev", Password: client.HashPassword("a-strong-password"), Role: "owner", Namespace: "team-x"},
+		{Username: "ops", Password: client.HashPassword("a-strong-password"), Role: "user", Quota: &types.QuotaConfig{OnExceeded: types.QuotaQueue}},
+		{Username: "ci", Password: client.HashPassword("a-strong-password"), Role: "user", Quota: &types.QuotaConfig{MaxExecutionsPerDay: 50, MaxStorageBytes: 1 << 20}},
+	})
+	got := lintMessages(l.findings)
+	want := strings.Join([]string{
+		"warning admin: the password is a well-known default, change it",
+		"error dev: username is used more than once, only the first can log in",
+		`error dev: role must be admin or user, got "owner"`,
+		"error dev: namespace team-x is not configured in app.yaml",
+		"error ops: quota needs a daily execution or a storage limit",
+		"warning ci: user quotas only limit executions, max_storage_bytes is ignored",
+	}, "\n")
+	if got != want {
+		t.Errorf("findings:\n%s\nwant:\n%s", got, want)
+	}
+
+	r := l.report(time.Now())
+	if r.Errors != 4 || r.Warnings != 2 || r.Findings[0].Level != LintError {
+		t.Errorf("report = %+v", r)
+	}
+}
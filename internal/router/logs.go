@@ -1,12 +1,18 @@
 package router
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/client"
 	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/locale"
+	"github.com/mycoool/gohook/internal/namespace"
 )
 
 // LogRouter log router handler
@@ -84,22 +90,12 @@ func (lr *LogRouter) GetWebhookLogs(c *gin.Context) {
 	}
 
 	// parse time parameters
-	var startTime, endTime *time.Time
-	if startStr := c.Query("start_time"); startStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", startStr); err == nil {
-			startTime = &t
-		}
-	}
-	if endStr := c.Query("end_time"); endStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", endStr); err == nil {
-			endTime = &t
-		}
-	}
+	startTime, endTime := queryTimeRange(c, "start_time", "end_time")
 
 	// query data (Webhook type)
-	logs, total, err := lr.logService.GetHookLogs(page, pageSize, hookID, hookName, "webhook", success, startTime, endTime)
+	logs, total, err := lr.logService.InNamespace(namespace.FromContext(c)).InZone(locale.FromContext(c)).Decrypting(decryptsLogs(c)).GetHookLogs(page, pageSize, hookID, hookName, "webhook", success, startTime, endTime)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListLogsFailed, err)
 		return
 	}
 
@@ -115,21 +111,11 @@ func (lr *LogRouter) GetWebhookLogs(c *gin.Context) {
 // GetWebhookLogStats get webhook log stats
 func (lr *LogRouter) GetWebhookLogStats(c *gin.Context) {
 	// parse time parameters
-	var startTime, endTime *time.Time
-	if startStr := c.Query("start_time"); startStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", startStr); err == nil {
-			startTime = &t
-		}
-	}
-	if endStr := c.Query("end_time"); endStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", endStr); err == nil {
-			endTime = &t
-		}
-	}
+	startTime, endTime := queryTimeRange(c, "start_time", "end_time")
 
-	stats, err := lr.logService.GetHookLogStats("webhook", startTime, endTime)
+	stats, err := lr.logService.InNamespace(namespace.FromContext(c)).GetHookLogStats("webhook", startTime, endTime)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadLogStatsFailed, err)
 		return
 	}
 
@@ -151,22 +137,12 @@ func (lr *LogRouter) GetSystemLogs(c *gin.Context) {
 	userID := c.Query("user_id")
 
 	// parse time parameters
-	var startTime, endTime *time.Time
-	if startStr := c.Query("start_time"); startStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", startStr); err == nil {
-			startTime = &t
-		}
-	}
-	if endStr := c.Query("end_time"); endStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", endStr); err == nil {
-			endTime = &t
-		}
-	}
+	startTime, endTime := queryTimeRange(c, "start_time", "end_time")
 
 	// query data
-	logs, total, err := lr.logService.GetSystemLogs(page, pageSize, level, category, userID, startTime, endTime)
+	logs, total, err := lr.logService.InNamespace(namespace.FromContext(c)).InZone(locale.FromContext(c)).GetSystemLogs(page, pageSize, level, category, userID, startTime, endTime)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListLogsFailed, err)
 		return
 	}
 
@@ -200,22 +176,12 @@ func (lr *LogRouter) GetUserActivities(c *gin.Context) {
 	}
 
 	// parse time parameters
-	var startTime, endTime *time.Time
-	if startStr := c.Query("start_time"); startStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", startStr); err == nil {
-			startTime = &t
-		}
-	}
-	if endStr := c.Query("end_time"); endStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", endStr); err == nil {
-			endTime = &t
-		}
-	}
+	startTime, endTime := queryTimeRange(c, "start_time", "end_time")
 
 	// query data
-	activities, total, err := lr.logService.GetUserActivities(page, pageSize, username, action, success, startTime, endTime)
+	activities, total, err := lr.logService.InNamespace(namespace.FromContext(c)).InZone(locale.FromContext(c)).GetUserActivities(page, pageSize, username, action, success, startTime, endTime)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListLogsFailed, err)
 		return
 	}
 
@@ -250,22 +216,12 @@ func (lr *LogRouter) GetProjectActivities(c *gin.Context) {
 	}
 
 	// parse time parameters
-	var startTime, endTime *time.Time
-	if startStr := c.Query("start_time"); startStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", startStr); err == nil {
-			startTime = &t
-		}
-	}
-	if endStr := c.Query("end_time"); endStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", endStr); err == nil {
-			endTime = &t
-		}
-	}
+	startTime, endTime := queryTimeRange(c, "start_time", "end_time")
 
 	// query data
-	activities, total, err := lr.logService.GetProjectActivities(page, pageSize, projectName, action, username, success, startTime, endTime)
+	activities, total, err := lr.logService.InNamespace(namespace.FromContext(c)).InZone(locale.FromContext(c)).GetProjectActivities(page, pageSize, projectName, action, username, success, startTime, endTime)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListLogsFailed, err)
 		return
 	}
 
@@ -283,17 +239,17 @@ func (lr *LogRouter) CleanupLogs(c *gin.Context) {
 	// get retention days from query parameters, default 30 days
 	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
 	if days <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidDays, nil)
 		return
 	}
 
-	err := lr.logService.CleanOldLogs(days)
+	err := lr.logService.InNamespace(namespace.FromContext(c)).CleanOldLogs(days)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeCleanupLogsFailed, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Old logs cleaned successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeLogsCleaned), "code": i18n.CodeLogsCleaned})
 }
 
 // GetGitHookLogs get GitHook execution log
@@ -317,22 +273,12 @@ func (lr *LogRouter) GetGitHookLogs(c *gin.Context) {
 	}
 
 	// parse time parameters
-	var startTime, endTime *time.Time
-	if startStr := c.Query("start_time"); startStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", startStr); err == nil {
-			startTime = &t
-		}
-	}
-	if endStr := c.Query("end_time"); endStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", endStr); err == nil {
-			endTime = &t
-		}
-	}
+	startTime, endTime := queryTimeRange(c, "start_time", "end_time")
 
 	// query data (GitHook type)
-	logs, total, err := lr.logService.GetHookLogs(page, pageSize, hookID, hookName, "githook", success, startTime, endTime)
+	logs, total, err := lr.logService.InNamespace(namespace.FromContext(c)).InZone(locale.FromContext(c)).Decrypting(decryptsLogs(c)).GetHookLogs(page, pageSize, hookID, hookName, "githook", success, startTime, endTime)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListLogsFailed, err)
 		return
 	}
 
@@ -348,21 +294,11 @@ func (lr *LogRouter) GetGitHookLogs(c *gin.Context) {
 // GetGitHookLogStats get GitHook log stats
 func (lr *LogRouter) GetGitHookLogStats(c *gin.Context) {
 	// parse time parameters
-	var startTime, endTime *time.Time
-	if startStr := c.Query("start_time"); startStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", startStr); err == nil {
-			startTime = &t
-		}
-	}
-	if endStr := c.Query("end_time"); endStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", endStr); err == nil {
-			endTime = &t
-		}
-	}
+	startTime, endTime := queryTimeRange(c, "start_time", "end_time")
 
-	stats, err := lr.logService.GetHookLogStats("githook", startTime, endTime)
+	stats, err := lr.logService.InNamespace(namespace.FromContext(c)).GetHookLogStats("githook", startTime, endTime)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadLogStatsFailed, err)
 		return
 	}
 
@@ -375,21 +311,11 @@ func (lr *LogRouter) GetUserActivityStats(c *gin.Context) {
 	username := c.Query("username")
 
 	// parse time parameters
-	var startTime, endTime *time.Time
-	if startStr := c.Query("start_time"); startStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", startStr); err == nil {
-			startTime = &t
-		}
-	}
-	if endStr := c.Query("end_time"); endStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04:05Z", endStr); err == nil {
-			endTime = &t
-		}
-	}
+	startTime, endTime := queryTimeRange(c, "start_time", "end_time")
 
-	stats, err := lr.logService.GetUserActivityStats(username, startTime, endTime)
+	stats, err := lr.logService.InNamespace(namespace.FromContext(c)).GetUserActivityStats(username, startTime, endTime)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadLogStatsFailed, err)
 		return
 	}
 
@@ -421,19 +347,9 @@ func HandleGetLogs(c *gin.Context) {
 	}
 
 	// parse time parameters
-	var startTime, endTime *time.Time
-	if startDate := c.Query("startDate"); startDate != "" {
-		if t, err := time.Parse(time.RFC3339, startDate); err == nil {
-			startTime = &t
-		}
-	}
-	if endDate := c.Query("endDate"); endDate != "" {
-		if t, err := time.Parse(time.RFC3339, endDate); err == nil {
-			endTime = &t
-		}
-	}
+	startTime, endTime := queryTimeRange(c, "startDate", "endDate")
 
-	logService := database.NewLogService()
+	logService := database.NewLogService().InNamespace(namespace.FromContext(c)).InZone(locale.FromContext(c)).Decrypting(decryptsLogs(c))
 
 	// call different query methods based on type
 	var logs interface{}
@@ -455,7 +371,7 @@ func HandleGetLogs(c *gin.Context) {
 	}
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListLogsFailed, err)
 		return
 	}
 
@@ -478,24 +394,14 @@ func HandleExportLogs(c *gin.Context) {
 	search := c.Query("search")
 
 	// parse time parameters
-	var startTime, endTime *time.Time
-	if startDate := c.Query("startDate"); startDate != "" {
-		if t, err := time.Parse(time.RFC3339, startDate); err == nil {
-			startTime = &t
-		}
-	}
-	if endDate := c.Query("endDate"); endDate != "" {
-		if t, err := time.Parse(time.RFC3339, endDate); err == nil {
-			endTime = &t
-		}
-	}
+	startTime, endTime := queryTimeRange(c, "startDate", "endDate")
 
-	logService := database.NewLogService()
+	logService := database.NewLogService().InNamespace(namespace.FromContext(c)).InZone(locale.FromContext(c)).Decrypting(decryptsLogs(c))
 
 	// export CSV format logs
 	csvData, err := logService.ExportLogsToCSV(logType, level, search, startTime, endTime)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeExportLogsFailed, err)
 		return
 	}
 
@@ -511,16 +417,131 @@ func HandleCleanupLogs(c *gin.Context) {
 	// get retention days from query parameters, default 30 days
 	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
 	if days <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidDays, nil)
 		return
 	}
 
-	logService := database.NewLogService()
+	logService := database.NewLogService().InNamespace(namespace.FromContext(c))
 	err := logService.CleanOldLogs(days)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeCleanupLogsFailed, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Old logs cleaned successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeLogsCleaned), "code": i18n.CodeLogsCleaned})
+}
+
+// decryptsLogs whether the user of the request reads hook logs encrypted at rest in clear
+func decryptsLogs(c *gin.Context) bool {
+	return client.CanDecryptLogs(c.GetString("username"))
+}
+
+// tail stream settings
+const (
+	tailBuffer       = 256
+	tailMaxBacklog   = 500
+	tailPingInterval = 15 * time.Second
+)
+
+// HandleTailLogs stream new hook and system logs as server-sent events, with the filters of
+// the log list. backlog=N first sends the last N matching entries.
+func HandleTailLogs(c *gin.Context) {
+	filter := database.TailFilter{
+		Namespace: namespace.FromContext(c),
+		Type:      c.Query("type"),
+		HookID:    c.Query("hook_id"),
+		HookType:  c.Query("hook_type"),
+		Project:   c.Query("project"),
+		Level:     c.Query("level"),
+		Category:  c.Query("category"),
+		User:      c.Query("user"),
+		Search:    c.Query("search"),
+	}
+	if filter.Type != "" && filter.Type != database.LogEventHook && filter.Type != database.LogEventSystem {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidLogType, nil)
+		return
+	}
+	if successStr := c.Query("success"); successStr != "" {
+		successBool, err := strconv.ParseBool(successStr)
+		if err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidSuccessParam, nil)
+			return
+		}
+		filter.Success = &successBool
+	}
+	backlog, _ := strconv.Atoi(c.DefaultQuery("backlog", "0"))
+	if backlog > tailMaxBacklog {
+		backlog = tailMaxBacklog
+	}
+
+	// subscribe before reading the backlog so no entry falls in between
+	sub := database.SubscribeLogs(tailBuffer)
+	defer sub.Close()
+
+	decrypt := decryptsLogs(c)
+	recent, err := database.NewLogService().Decrypting(decrypt).RecentLogs(filter, backlog)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListLogsFailed, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+	c.Status(http.StatusOK)
+
+	// highest id sent per type, live entries already part of the backlog are skipped
+	sent := map[string]uint{}
+	send := func(e database.LogEvent) {
+		c.Render(-1, sse.Event{Event: e.Type, Id: fmt.Sprintf("%s-%d", e.Type, e.ID()), Data: e.Entry()})
+		if e.ID() > sent[e.Type] {
+			sent[e.Type] = e.ID()
+		}
+	}
+	for _, e := range recent {
+		send(e)
+	}
+	c.Render(-1, sse.Event{Event: "ready", Data: gin.H{"backlog": len(recent)}})
+	c.Writer.Flush()
+
+	ping := time.NewTicker(tailPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e := <-sub.C:
+			if e.ID() <= sent[e.Type] || !filter.Match(e) {
+				continue
+			}
+			if e.Hook != nil {
+				revealed := e.Hook.Revealed(decrypt)
+				e.Hook = &revealed
+			}
+			if dropped := sub.Dropped(); dropped > 0 {
+				c.Render(-1, sse.Event{Event: "dropped", Data: gin.H{"count": dropped}})
+			}
+			send(e)
+		case <-ping.C:
+			// comment line keeping proxies from closing an idle stream
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// queryTimeRange start and end of the time range query parameters, RFC3339 or a date or
+// date-time without a zone taken in the zone of the request; values that do not parse are ignored
+func queryTimeRange(c *gin.Context, startParam, endParam string) (startTime, endTime *time.Time) {
+	loc := locale.FromContext(c)
+	if t, err := locale.ParseQuery(c.Query(startParam), loc); err == nil {
+		startTime = &t
+	}
+	if t, err := locale.ParseQuery(c.Query(endParam), loc); err == nil {
+		endTime = &t
+	}
+	return startTime, endTime
 }
@@ -0,0 +1,183 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/config"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/quota"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// NamespaceResponse namespace with the number of items it owns
+type NamespaceResponse struct {
+	types.NamespaceConfig
+	Hooks    int `json:"hooks"`
+	Projects int `json:"projects"`
+	Users    int `json:"users"`
+}
+
+func logNamespaceAction(c *gin.Context, action, name, description string, success bool, details interface{}) {
+	database.LogUserAction(
+		c.GetString("username"),
+		action,
+		"namespace:"+name,
+		description,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		success,
+		details,
+	)
+}
+
+func describeNamespace(ns types.NamespaceConfig) NamespaceResponse {
+	return NamespaceResponse{
+		NamespaceConfig: ns,
+		Hooks:           len(namespace.Members(namespace.KindHook, ns.Name)),
+		Projects:        len(namespace.Members(namespace.KindProject, ns.Name)),
+		Users:           len(namespace.Members(namespace.KindUser, ns.Name)),
+	}
+}
+
+// HandleListNamespaces list namespaces, a token limited to a namespace only sees its own
+func HandleListNamespaces(c *gin.Context) {
+	scope := namespace.FromContext(c)
+	list := []NamespaceResponse{}
+	for _, ns := range namespace.List() {
+		if scope != "" && ns.Name != scope {
+			continue
+		}
+		list = append(list, describeNamespace(ns))
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// HandleCreateNamespace add a namespace
+func HandleCreateNamespace(c *gin.Context) {
+	var req types.NamespaceConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if !namespace.ValidName(req.Name) {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidNamespaceName, nil)
+		return
+	}
+	if err := quota.Validate(req.Quota); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidQuota, err)
+		return
+	}
+	if namespace.Exists(req.Name) {
+		i18n.JSONError(c, http.StatusConflict, i18n.CodeNamespaceExists, nil)
+		return
+	}
+	if types.GoHookAppConfig == nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeAppConfigNotLoaded, nil)
+		return
+	}
+
+	types.GoHookAppConfig.Namespaces = append(types.GoHookAppConfig.Namespaces, req)
+	if err := config.SaveAppConfig(); err != nil {
+		types.GoHookAppConfig.Namespaces = types.GoHookAppConfig.Namespaces[:len(types.GoHookAppConfig.Namespaces)-1]
+		logNamespaceAction(c, database.UserActionCreateNamespace, req.Name, "create namespace "+req.Name, false, err.Error())
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
+		return
+	}
+
+	logNamespaceAction(c, database.UserActionCreateNamespace, req.Name, "create namespace "+req.Name, true, req)
+	c.JSON(http.StatusOK, describeNamespace(req))
+}
+
+// HandleUpdateNamespace change the description of a namespace and its quota, the quota is
+// kept when the request leaves it out and removed when it is null
+func HandleUpdateNamespace(c *gin.Context) {
+	name := c.Param("name")
+	var req struct {
+		Description string          `json:"description"`
+		Quota       json.RawMessage `json:"quota"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	nsQuota := quota.NamespaceQuota(name)
+	if len(req.Quota) > 0 {
+		nsQuota = nil
+		if err := json.Unmarshal(req.Quota, &nsQuota); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidQuota, err)
+			return
+		}
+		if err := quota.Validate(nsQuota); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidQuota, err)
+			return
+		}
+	}
+	if !namespace.Exists(name) {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeNamespaceNotFound, nil)
+		return
+	}
+	if types.GoHookAppConfig == nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeAppConfigNotLoaded, nil)
+		return
+	}
+
+	updated := types.NamespaceConfig{Name: name, Description: req.Description, Quota: nsQuota}
+	found := false
+	for i := range types.GoHookAppConfig.Namespaces {
+		if types.GoHookAppConfig.Namespaces[i].Name == name {
+			types.GoHookAppConfig.Namespaces[i] = updated
+			found = true
+			break
+		}
+	}
+	if !found {
+		// the default namespace is implicit until it gets a description
+		types.GoHookAppConfig.Namespaces = append(types.GoHookAppConfig.Namespaces, updated)
+	}
+	if err := config.SaveAppConfig(); err != nil {
+		logNamespaceAction(c, database.UserActionUpdateNamespace, name, "update namespace "+name, false, err.Error())
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
+		return
+	}
+
+	logNamespaceAction(c, database.UserActionUpdateNamespace, name, "update namespace "+name, true, updated)
+	c.JSON(http.StatusOK, describeNamespace(updated))
+}
+
+// HandleDeleteNamespace remove an empty namespace, the default namespace cannot be removed
+func HandleDeleteNamespace(c *gin.Context) {
+	name := c.Param("name")
+	if name == types.DefaultNamespace {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeDefaultNamespace, nil)
+		return
+	}
+	if !namespace.Exists(name) {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeNamespaceNotFound, nil)
+		return
+	}
+	if resp := describeNamespace(types.NamespaceConfig{Name: name}); resp.Hooks+resp.Projects+resp.Users > 0 {
+		i18n.JSONErrorf(c, http.StatusConflict, i18n.CodeNamespaceNotEmpty, resp.Hooks, resp.Projects, resp.Users)
+		return
+	}
+
+	namespaces := types.GoHookAppConfig.Namespaces[:0]
+	for _, ns := range types.GoHookAppConfig.Namespaces {
+		if ns.Name != name {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	types.GoHookAppConfig.Namespaces = namespaces
+	if err := config.SaveAppConfig(); err != nil {
+		logNamespaceAction(c, database.UserActionDeleteNamespace, name, "delete namespace "+name, false, err.Error())
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
+		return
+	}
+
+	logNamespaceAction(c, database.UserActionDeleteNamespace, name, "delete namespace "+name, true, nil)
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeNamespaceDeleted), "code": i18n.CodeNamespaceDeleted})
+}
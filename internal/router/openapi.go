@@ -0,0 +1,222 @@
+package router
+
+import (
+	"github.com/mycoool/gohook/internal/backup"
+	"github.com/mycoool/gohook/internal/consumer"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/gitops"
+	"github.com/mycoool/gohook/internal/inbox"
+	"github.com/mycoool/gohook/internal/logforward"
+	"github.com/mycoool/gohook/internal/maintenance"
+	"github.com/mycoool/gohook/internal/openapi"
+	"github.com/mycoool/gohook/internal/plugin"
+	"github.com/mycoool/gohook/internal/quota"
+	"github.com/mycoool/gohook/internal/selfupdate"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/version"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+// describeRoutes annotate routes for the OpenAPI document: public endpoints and known body types.
+// Routes without an annotation are still documented and require the X-GoHook-Key token.
+func describeRoutes() {
+	// public endpoints
+	openapi.Describe("GET", "/ping", openapi.Spec{Summary: "Health check", Security: "-"})
+	openapi.Describe("GET", "/message", openapi.Spec{Summary: "Inbox of the current user, newest first; since pages to older messages, limit (max 200) sets the page size, unread=true hides read messages", Response: inbox.PagedMessages{}})
+	openapi.Describe("DELETE", "/message", openapi.Spec{Summary: "Delete every message of the current user"})
+	openapi.Describe("POST", "/message/read", openapi.Spec{Summary: "Mark every message of the current user as read"})
+	openapi.Describe("POST", "/message/:id/read", openapi.Spec{Summary: "Mark a message as read"})
+	openapi.Describe("DELETE", "/message/:id", openapi.Spec{Summary: "Delete a message"})
+	openapi.Describe("GET", "/plugin", openapi.Spec{Summary: "Plugins found in the plugins directory", Response: []plugin.Status{}})
+	openapi.Describe("GET", "/plugin/:id/config", openapi.Spec{Summary: "YAML configuration of a configurer plugin (admin)"})
+	openapi.Describe("POST", "/plugin/:id/config", openapi.Spec{Summary: "Replace the YAML configuration of a configurer plugin (admin), an enabled plugin may reject it"})
+	openapi.Describe("GET", "/plugin/:id/display", openapi.Spec{Summary: "Markdown status page of an enabled displayer plugin"})
+	openapi.Describe("POST", "/plugin/:id/enable", openapi.Spec{Summary: "Start a plugin and keep it enabled across restarts (admin)"})
+	openapi.Describe("POST", "/plugin/:id/disable", openapi.Spec{Summary: "Stop a plugin and keep it disabled across restarts (admin)"})
+	openapi.Describe("PUT", "/plugin/wasm/:name", openapi.Spec{Summary: "Upload a WebAssembly (WASI) transformer plugin as the request body, max 32 MiB (admin); an existing module is replaced and keeps its state"})
+	openapi.Describe("DELETE", "/plugin/wasm/:name", openapi.Spec{Summary: "Remove an uploaded WebAssembly plugin and its stored state (admin)"})
+	openapi.Describe("GET", "/app/config", openapi.Spec{Summary: "Public app config", Security: "-"})
+	openapi.Describe("POST", "/chatops", openapi.Spec{Summary: "Slack or Mattermost slash command, signed by Slack or carrying a Mattermost token", Security: "-"})
+	openapi.Describe("POST", "/client", openapi.Spec{Summary: "Login and create a client token", Response: types.ClientResponse{}, Security: openapi.SecurityBasic})
+	openapi.Describe("POST", "/githook/:name", openapi.Spec{Summary: "GitHook delivery from a Git platform, verified by the project secret", Security: "-"})
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+		openapi.Describe(method, "/hooks/*id", openapi.Spec{Summary: "Webhook delivery, authorized by the hook trigger rules", Security: "-"})
+		openapi.Describe(method, "/ns/:namespace/hooks/*id", openapi.Spec{Summary: "Webhook delivery to a hook of the namespace", Security: "-"})
+	}
+
+	// websocket, the token may also be passed as ?token= or Sec-WebSocket-Protocol
+	openapi.Describe("GET", "/stream", openapi.Spec{Summary: "Event stream (WebSocket)"})
+	openapi.Describe("GET", "/stream/:id", openapi.Spec{Summary: "Event stream (WebSocket)"})
+
+	// sync agent endpoints
+	openapi.Describe("GET", "/api/sync/nodes/:id/tasks/pull", openapi.Spec{Summary: "Pull the next sync task", Security: openapi.SecurityAgentToken})
+	openapi.Describe("POST", "/api/sync/nodes/:id/tasks/:taskId/report", openapi.Spec{Summary: "Report sync task result", Security: openapi.SecurityAgentToken})
+	openapi.Describe("GET", "/api/sync/nodes/:id/tasks/:taskId/bundle", openapi.Spec{Summary: "Download sync task bundle", Security: openapi.SecurityAgentToken})
+
+	// users
+	openapi.Describe("GET", "/user", openapi.Spec{Summary: "List users", Response: []types.UserResponse{}})
+	openapi.Describe("GET", "/user/preferences", openapi.Spec{Summary: "Time zone and panel language of the current user, with the values in effect", Response: types.UserPreferencesResponse{}})
+	openapi.Describe("PUT", "/user/preferences", openapi.Spec{Summary: "Set the time zone (IANA, e.g. Asia/Shanghai) and panel language (zh | en) of the current user, empty values use the server setting", Request: types.UserPreferences{}, Response: types.UserPreferencesResponse{}})
+
+	// hooks
+	openapi.Describe("GET", "/hook", openapi.Spec{Summary: "List hooks", Response: []types.HookResponse{}})
+	openapi.Describe("GET", "/hook/graph", openapi.Spec{Summary: "Dependency graph of hooks, projects and forward targets", Response: webhook.HookGraph{}})
+	openapi.Describe("GET", "/hook/files", openapi.Spec{Summary: "Hooks files with their load state, broken files keep serving their last good hooks and report the parse error and line", Response: []webhook.HooksFileStatus{}})
+	openapi.Describe("GET", "/hook/:id", openapi.Spec{Summary: "Get hook", Response: types.HookResponse{}})
+	openapi.Describe("POST", "/hook/:id/script/check", openapi.Spec{Summary: "Syntax check a script without saving it (bash -n, py_compile, shellcheck)", Response: webhook.ScriptCheckResult{}})
+	openapi.Describe("POST", "/hook/:id/test", openapi.Spec{Summary: "Send a synthetic GitHub/GitLab/Gitea push, tag or release event to the hook, signatures are computed from the configured secrets", Request: webhook.TestEventOptions{}})
+	openapi.Describe("POST", "/hook/:id/rename", openapi.Spec{Summary: "Rename a hook to body.id, keepAlias (default true) keeps the old id as an alias"})
+	openapi.Describe("PUT", "/hook/:id/aliases", openapi.Spec{Summary: "Replace the aliases (custom slugs) a hook is also served under, body.aliases"})
+	openapi.Describe("POST", "/hook/:id/rotate-secret", openapi.Spec{Summary: "Replace the secret of the hook's signature rules by a new random one, the previous secret stays valid for body.gracePeriod (default 24h)"})
+	openapi.Describe("PUT", "/hook/:id/endpoints", openapi.Spec{Summary: "Replace the endpoints of a hook, additional ids with their own trigger rules and enable flag, body.endpoints; body.endpointsOnly refuses deliveries to the hook id"})
+	openapi.Describe("POST", "/hook/:id/endpoints", openapi.Spec{Summary: "Add an endpoint with a random id to a hook, body.token also requires a capability token in ?token=, returned once"})
+	openapi.Describe("DELETE", "/hook/:id/endpoints/:endpoint", openapi.Spec{Summary: "Revoke an endpoint of a hook"})
+	openapi.Describe("POST", "/version/:name/rename", openapi.Spec{Summary: "Rename a project to body.name, keepAlias (default true) keeps the old name as an alias"})
+	openapi.Describe("PUT", "/hook/:id/artifacts", openapi.Spec{Summary: "Set the files collected after each run, null disables artifact capture", Request: struct {
+		Artifacts *webhook.ArtifactsConfig `json:"artifacts"`
+	}{}})
+	openapi.Describe("GET", "/hook/:id/executions/:execID/artifacts", openapi.Spec{Summary: "List the artifacts collected for an execution log entry of the hook", Response: []database.HookArtifact{}})
+	openapi.Describe("GET", "/hook/:id/executions/:execID/artifacts/*name", openapi.Spec{Summary: "Download an artifact, X-GoHook-Artifact-Truncated is set when it was cut at max-file-bytes"})
+	openapi.Describe("PUT", "/hook/:id/object-events", openapi.Spec{Summary: "Accept S3 event notifications through SNS and MinIO bucket webhooks, null disables it", Request: struct {
+		ObjectEvents *webhook.ObjectEventsConfig `json:"object-events"`
+	}{}})
+	openapi.Describe("PUT", "/hook/:id/transform-plugins", openapi.Spec{Summary: "Set the transformer plugins rewriting the payload before the trigger rules, in order, an empty list removes them", Request: struct {
+		TransformPlugins []string `json:"transform-plugins"`
+	}{}})
+	openapi.Describe("PUT", "/hook/:id/starlark", openapi.Spec{Summary: "Set the Starlark program called by starlark trigger rules and arguments of the hook, null removes it", Request: struct {
+		Starlark *webhook.StarlarkConfig `json:"starlark"`
+	}{}})
+	openapi.Describe("PUT", "/hook/:id/budget", openapi.Spec{Summary: "Set the failure and duration budget and circuit breaker of the hook, null removes it", Request: struct {
+		Budget *webhook.BudgetConfig `json:"budget"`
+	}{}})
+	openapi.Describe("GET", "/hook/:id/budget", openapi.Spec{Summary: "Budget of the hook, its executions, failures and average duration in the last hour and the state of its circuit breaker", Response: webhook.BudgetStatus{}})
+	openapi.Describe("POST", "/hook/:id/budget/reset", openapi.Spec{Summary: "Close the circuit breaker of the hook and reset its budget usage"})
+	openapi.Describe("PUT", "/hook/:id/quota", openapi.Spec{Summary: "Set the daily execution and storage quota of the hook, null removes it", Request: struct {
+		Quota *types.QuotaConfig `json:"quota"`
+	}{}})
+	openapi.Describe("GET", "/hook/:id/quota", openapi.Spec{Summary: "Quota of the hook and usage of the quotas of the hook and its namespace", Response: struct {
+		Quota *types.QuotaConfig `json:"quota"`
+		Usage []quota.Usage      `json:"usage"`
+	}{}})
+	openapi.Describe("PUT", "/hook/:id/environment", openapi.Spec{Summary: "Set which variables of the gohook process the hook command inherits, null falls back to hook_env"})
+
+	// version management
+	openapi.Describe("GET", "/version", openapi.Spec{Summary: "List projects", Response: []types.VersionResponse{}})
+	openapi.Describe("GET", "/version/:name/branches", openapi.Spec{Response: []types.BranchResponse{}})
+	openapi.Describe("GET", "/version/:name/tags", openapi.Spec{Response: []types.TagResponse{}})
+	openapi.Describe("GET", "/version/:name/promotions", openapi.Spec{Response: []database.ProjectPromotion{}})
+	openapi.Describe("GET", "/version/:name/log", openapi.Spec{Summary: "Newest revisions of the working copy (?limit=, default 20) for git, svn and hg projects", Response: []version.Revision{}})
+	openapi.Describe("GET", "/version/:name/githook/provider", openapi.Spec{Summary: "Verify the webhook registered on GitHub, GitLab or Gitea still delivers to the project's GitHook with the configured events"})
+	openapi.Describe("POST", "/version/:name/githook/provider", openapi.Spec{Summary: "Create or repair the webhook of the project's repository on GitHub, GitLab or Gitea (body: type, token, optional apiUrl, repo, url, events)"})
+	openapi.Describe("GET", "/version/:name/deploy-key", openapi.Spec{Summary: "Public key and fingerprint of the project's SSH deploy key"})
+	openapi.Describe("POST", "/version/:name/deploy-key", openapi.Spec{Summary: "Generate an ed25519 deploy key used by the project's git commands and return its public key, body.rotate replaces an existing key"})
+	openapi.Describe("DELETE", "/version/:name/deploy-key", openapi.Spec{Summary: "Remove the project's deploy key, git falls back to the server's SSH configuration"})
+	openapi.Describe("POST", "/version/:name/githook/rotate-secret", openapi.Spec{Summary: "Replace the GitHook secret of the project by a new random one, the previous secret stays valid for body.gracePeriod (default 24h)"})
+	openapi.Describe("GET", "/version/:name/timeline", openapi.Spec{Summary: "Commits, deploys, GitHook deliveries, config edits and other activity of the project, newest first (?type=commit,deploy,githook,config,activity&page=&page_size=), response {entries, page, page_size, has_more}"})
+	openapi.Describe("POST", "/version/:name/maintenance", openapi.Spec{Summary: "Run git remote prune, prune and gc on the project checkout now", Response: database.GitMaintenanceRun{}})
+	openapi.Describe("GET", "/version/:name/maintenance", openapi.Spec{Summary: "Git maintenance runs, newest first (?limit=), with the current repository size"})
+	openapi.Describe("GET", "/version/:name/releases", openapi.Spec{Summary: "Release directories of the project, newest first", Response: []version.Release{}})
+	openapi.Describe("POST", "/version/:name/releases/:id/activate", openapi.Spec{Summary: "Point the current symlink at a release and restart the service when it restarts on deploys"})
+	openapi.Describe("POST", "/version/:name/pin", openapi.Spec{Summary: "Pin the project at its current revision, GitHooks, switches, promotions and release activations are refused with 423 until it is unpinned", Request: struct {
+		Reason string `json:"reason"`
+	}{}})
+	openapi.Describe("DELETE", "/version/:name/pin", openapi.Spec{Summary: "Unpin the project"})
+	openapi.Describe("GET", "/version/:name/kubernetes", openapi.Spec{Summary: "Rollout state (revision, images, replicas) of the Deployments of the project's Kubernetes target"})
+	openapi.Describe("POST", "/version/:name/kubernetes/rollback", openapi.Spec{Summary: "Roll the Deployments of the Kubernetes target, or the one in the body, back to their previous revision"})
+
+	// configuration bundle
+	openapi.Describe("GET", "/system/export", openapi.Spec{Summary: "Export projects and hooks", Response: ConfigBundle{}})
+	openapi.Describe("POST", "/system/import", openapi.Spec{Summary: "Import projects and hooks, ?mode=replace removes missing entries", Request: ConfigBundle{}})
+	openapi.Describe("GET", "/system/server", openapi.Spec{Summary: "URL layout of hook endpoints and the panel", Response: ServerConfigResponse{}})
+	openapi.Describe("PUT", "/system/server", openapi.Spec{Summary: "Change the hooks URL prefix and base path, applied immediately", Request: types.ServerConfig{}, Response: ServerConfigResponse{}})
+
+	// namespaces
+	openapi.Describe("GET", "/api/namespaces", openapi.Spec{Summary: "List namespaces", Response: []NamespaceResponse{}})
+	openapi.Describe("POST", "/api/namespaces", openapi.Spec{Summary: "Create namespace", Request: types.NamespaceConfig{}, Response: NamespaceResponse{}})
+	openapi.Describe("PUT", "/api/namespaces/:name", openapi.Spec{Summary: "Update namespace description and quota, a left out quota is kept", Request: types.NamespaceConfig{}, Response: NamespaceResponse{}})
+	openapi.Describe("GET", "/api/quotas", openapi.Spec{Summary: "Executions today and stored bytes of the namespace, user and hook quotas visible to the caller", Response: []quota.Usage{}})
+
+	// logs
+	openapi.Describe("GET", "/api/logs/tail", openapi.Spec{Summary: "Stream new hook and system logs as server-sent events (hook, system, ready, dropped), accepts ?token="})
+
+	// search
+	openapi.Describe("GET", "/search", openapi.Spec{Summary: "Search hooks, projects, hook scripts and recent logs", Response: SearchResponse{}})
+
+	// statistics
+	openapi.Describe("GET", "/stats/overview", openapi.Spec{Summary: "Executions, success rate, average duration, top failing hooks and deploys per project of a time range", Response: database.StatsOverview{}})
+	openapi.Describe("GET", "/stats/timeseries", openapi.Spec{Summary: "Hourly or daily buckets of executions, success rate, average duration and deploys", Response: StatsTimeseriesResponse{}})
+
+	// HA cluster
+	openapi.Describe("GET", "/api/cluster", openapi.Spec{Summary: "Get HA cluster state", Response: ClusterResponse{}})
+	openapi.Describe("POST", "/api/cluster/step-down", openapi.Spec{Summary: "Hand the leadership over to another instance"})
+
+	// maintenance
+	openapi.Describe("PUT", "/api/maintenance", openapi.Spec{Summary: "Update maintenance mode", Request: types.MaintenanceConfig{}})
+	openapi.Describe("GET", "/api/maintenance/queue", openapi.Spec{Summary: "List queued deliveries", Response: []database.QueuedDelivery{}})
+	openapi.Describe("POST", "/api/maintenance/queue/flush", openapi.Spec{Summary: "Replay queued deliveries", Response: maintenance.FlushResult{}})
+
+	// message queue consumers
+	openapi.Describe("GET", "/api/consumers", openapi.Spec{Summary: "List Kafka, NATS and Redis stream consumers and their state on this instance", Response: []consumer.Status{}})
+
+	// trash
+	openapi.Describe("GET", "/trash", openapi.Spec{Summary: "List deleted hooks and projects, ?kind=hook|project", Response: []database.TrashItem{}})
+	openapi.Describe("DELETE", "/trash", openapi.Spec{Summary: "Permanently delete all trash items, ?kind=hook|project", Response: PurgeTrashResponse{}})
+	openapi.Describe("GET", "/trash/:id", openapi.Spec{Summary: "Get a trash item with the snapshot of the deleted item", Response: TrashItemResponse{}})
+	openapi.Describe("POST", "/trash/:id/restore", openapi.Spec{Summary: "Restore a deleted hook or project"})
+	openapi.Describe("DELETE", "/trash/:id", openapi.Spec{Summary: "Permanently delete a trash item", Response: PurgeTrashResponse{}})
+
+	// backup
+	openapi.Describe("GET", "/admin/backup", openapi.Spec{Summary: "Download an encrypted configuration backup, the passphrase is sent in X-Backup-Passphrase"})
+	openapi.Describe("POST", "/admin/restore", openapi.Spec{Summary: "Restore a configuration backup, ?dry_run=true only returns its manifest", Response: backup.RestoreResult{}})
+	openapi.Describe("GET", "/admin/inventory", openapi.Spec{Summary: "Summary of the configured hooks, projects, namespaces, users, sync nodes and plugins without secrets, digest changes with the configuration", Response: Inventory{}})
+	openapi.Describe("GET", "/admin/queues", openapi.Spec{Summary: "Worker pool of background executions: workers, queue depth, in-flight executions, utilization and rejected deliveries, and the deliveries held back", Response: QueuesResponse{}})
+	openapi.Describe("PUT", "/admin/queues", openapi.Spec{Summary: "Resize the worker pool and its queue without a restart, left out values are kept", Request: types.ExecutionConfig{}, Response: QueuesResponse{}})
+	openapi.Describe("GET", "/admin/update", openapi.Spec{Summary: "Running version and the latest release on GitHub from the last check, ?refresh=true checks now", Response: selfupdate.Status{}})
+	openapi.Describe("POST", "/admin/update", openapi.Spec{Summary: "Download the latest release, verify it against checksums.txt (and its signature with update.public_key), replace the binary and restart gracefully; ?restart=false only installs it", Response: selfupdate.ApplyResponse{}})
+	openapi.Describe("GET", "/debug/runtime", openapi.Spec{Summary: "Version, uptime, goroutine count, heap and GC metrics of the process", Response: RuntimeStats{}})
+	openapi.Describe("GET", "/debug/bundle", openapi.Spec{Summary: "Download a zip for support cases: runtime metrics, configuration inventory, lint report, hooks file and queue states, recent errors and goroutine stacks, without secrets"})
+	openapi.Describe("GET", "/debug/pprof/*profile", openapi.Spec{Summary: "net/http/pprof profiles (heap, goroutine, profile, trace, ...) for go tool pprof, the index without a profile"})
+	openapi.Describe("GET", "/admin/lint", openapi.Spec{Summary: "Lint the hooks files, version.yaml and user.yaml: duplicate ids, missing scripts and project paths, invalid rules and weak secrets", Response: LintReport{}})
+	openapi.Describe("POST", "/admin/config/diff", openapi.Spec{Summary: "Compare projects and hooks in the format of /system/import with the loaded configuration without applying them, ?mode=replace also lists entries missing from the bundle", Request: ConfigBundle{}, Response: ConfigDiff{}})
+	openapi.Describe("GET", "/admin/log-forwarders", openapi.Spec{Summary: "Configured log forwarders (log_forwarders in app.yaml) with their sent, failed and dropped entries on this instance", Response: []logforward.Status{}})
+	openapi.Describe("GET", "/admin/audit/verify", openapi.Spec{Summary: "Verify the hash chain of user and project activity records (database.audit_hash_chain), response {valid, tables: per table records, head hash and issues}"})
+	openapi.Describe("GET", "/admin/gitops", openapi.Spec{Summary: "Revision of the last GitOps sync and the drift of the hooks files, version.yaml and user.yaml", Response: gitops.Status{}})
+	openapi.Describe("POST", "/admin/gitops/sync", openapi.Spec{Summary: "Pull the GitOps repository and apply the changed config files now, ?force=true also overwrites local edits kept in warn mode", Response: gitops.SyncResult{}})
+	openapi.Describe("POST", "/gitops/webhook", openapi.Spec{Summary: "Push event of the GitOps repository, verified by X-Hub-Signature-256 or X-Gitlab-Token; the sync runs in the background"})
+
+	// edits of hooks and projects behind webhook.HookIfMatch and version.ProjectIfMatch
+	for _, route := range [][2]string{
+		{"PUT", "/hook/:id/basic"},
+		{"PUT", "/hook/:id/parameters"},
+		{"PUT", "/hook/:id/triggers"},
+		{"PUT", "/hook/:id/response"},
+		{"PUT", "/hook/:id/execute-command"},
+		{"PUT", "/hook/:id/forward"},
+		{"PUT", "/hook/:id/idempotency"},
+		{"PUT", "/hook/:id/environment"},
+		{"PUT", "/hook/:id/artifacts"},
+		{"PUT", "/hook/:id/object-events"},
+		{"PUT", "/hook/:id/transform-plugins"},
+		{"PUT", "/hook/:id/starlark"},
+		{"PUT", "/hook/:id/budget"},
+		{"PUT", "/hook/:id/quota"},
+		{"POST", "/hook/:id/rename"},
+		{"PUT", "/hook/:id/aliases"},
+		{"PUT", "/hook/:id/endpoints"},
+		{"POST", "/hook/:id/endpoints"},
+		{"DELETE", "/hook/:id/endpoints/:endpoint"},
+		{"POST", "/hook/:id/rotate-secret"},
+		{"DELETE", "/hook/:id"},
+		{"POST", "/version/:name/githook"},
+		{"POST", "/version/:name/githook/rotate-secret"},
+		{"POST", "/version/:name/githook/provider"},
+		{"PUT", "/version/:name/service"},
+		{"POST", "/version/:name/pin"},
+		{"DELETE", "/version/:name/pin"},
+		{"PUT", "/version/:name"},
+		{"POST", "/version/:name/rename"},
+		{"DELETE", "/version/:name"},
+	} {
+		openapi.Conditional(route[0], route[1])
+	}
+}
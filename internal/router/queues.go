@@ -0,0 +1,79 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/config"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/maintenance"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/pool"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// QueuesResponse backpressure state of hook executions
+type QueuesResponse struct {
+	Executions pool.Stats `json:"executions"` // worker pool of background executions
+	Held       int64      `json:"held"`       // deliveries held back by maintenance mode, pause windows and quotas
+}
+
+// HandleGetQueues report the worker pool and the queued deliveries
+func HandleGetQueues(c *gin.Context) {
+	c.JSON(http.StatusOK, QueuesResponse{Executions: pool.Executions.Stats(), Held: maintenance.QueuedCount()})
+}
+
+// HandleResizeQueues change the workers and the queue size of the execution pool without a
+// restart, the new size is saved to app.yaml
+func HandleResizeQueues(c *gin.Context) {
+	var req types.ExecutionConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if err := pool.Validate(&req); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidQueueConfig, err)
+		return
+	}
+	if types.GoHookAppConfig == nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeAppConfigNotLoaded, nil)
+		return
+	}
+
+	// values left out keep the current size
+	current := pool.Executions.Stats()
+	if req.Workers == 0 {
+		req.Workers = current.Workers
+	}
+	if req.QueueSize == 0 {
+		req.QueueSize = current.QueueSize
+	}
+	previous := types.GoHookAppConfig.Execution
+	types.GoHookAppConfig.Execution = &req
+	description := fmt.Sprintf("resize execution pool to %d workers, queue size %d", req.Workers, req.QueueSize)
+	if err := config.SaveAppConfig(); err != nil {
+		types.GoHookAppConfig.Execution = previous
+		logQueuesAction(c, description, false, err.Error())
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
+		return
+	}
+	pool.Configure(&req)
+
+	logQueuesAction(c, description, true, map[string]interface{}{"old": previous, "new": req})
+	c.JSON(http.StatusOK, QueuesResponse{Executions: pool.Executions.Stats(), Held: maintenance.QueuedCount()})
+}
+
+func logQueuesAction(c *gin.Context, description string, success bool, details interface{}) {
+	database.LogUserAction(
+		c.GetString("username"),
+		database.UserActionResizeWorkerPool,
+		"queues",
+		description,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		success,
+		details,
+	)
+}
@@ -0,0 +1,42 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/quota"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+// HandleListQuotas usage of the configured namespace, user and hook quotas. A token limited
+// to a namespace only sees its quotas, users other than admins only their own user quota.
+func HandleListQuotas(c *gin.Context) {
+	scope := namespace.FromContext(c)
+	admin := c.GetString("role") == "admin"
+	username := c.GetString("username")
+
+	usages := []*quota.Usage{}
+	for _, ns := range namespace.List() {
+		if ns.Quota != nil && (scope == "" || ns.Name == scope) {
+			usages = append(usages, quota.Measure(quota.KindNamespace, ns.Name, ns.Quota))
+		}
+	}
+	if types.GoHookUsersConfig != nil {
+		for _, user := range types.GoHookUsersConfig.Users {
+			if user.Quota == nil || (!admin && user.Username != username) || (scope != "" && user.Namespace != scope) {
+				continue
+			}
+			usages = append(usages, quota.Measure(quota.KindUser, user.Username, user.Quota))
+		}
+	}
+	if webhook.HookManager != nil {
+		for _, h := range webhook.HookManager.GetAllHooks() {
+			if h.Quota != nil && (scope == "" || namespace.Normalize(h.Namespace) == scope) {
+				usages = append(usages, quota.Measure(quota.KindHook, h.ID, h.Quota))
+			}
+		}
+	}
+	c.JSON(http.StatusOK, usages)
+}
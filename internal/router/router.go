@@ -6,9 +6,20 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/backup"
+	"github.com/mycoool/gohook/internal/chatops"
 	"github.com/mycoool/gohook/internal/client"
+	"github.com/mycoool/gohook/internal/cluster"
 	"github.com/mycoool/gohook/internal/config"
+	"github.com/mycoool/gohook/internal/consumer"
+	"github.com/mycoool/gohook/internal/gitops"
+	"github.com/mycoool/gohook/internal/inbox"
+	"github.com/mycoool/gohook/internal/logforward"
+	"github.com/mycoool/gohook/internal/maintenance"
 	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/plugin"
+	"github.com/mycoool/gohook/internal/selfupdate"
 	"github.com/mycoool/gohook/internal/stream"
 	"github.com/mycoool/gohook/internal/syncnode"
 	"github.com/mycoool/gohook/internal/types"
@@ -16,36 +27,15 @@ import (
 	"github.com/mycoool/gohook/internal/webhook"
 )
 
+// InitRouter load the configuration files and build the router instance
 func InitRouter() *gin.Engine {
-	// create engine without default middleware
-	g := gin.New()
-
-	// use custom logger middleware, skip requests with "disable_log" tag
-	g.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		// if context has "disable_log" tag, skip logging
-		if param.Keys != nil {
-			if noLog, exists := param.Keys["disable_log"]; exists && noLog == true {
-				return ""
-			}
-		}
-		// otherwise use default format to record log
-		return fmt.Sprintf("[GoHook] %v | %3d | %13v | %15s | %-7s %#v\n%s",
-			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
-			param.StatusCode,
-			param.Latency,
-			param.ClientIP,
-			param.Method,
-			param.Path,
-			param.ErrorMessage,
-		)
-	}))
-
-	// use Recovery middleware
-	g.Use(gin.Recovery())
-
-	// use IP middleware, support real IP in proxy environment
-	g.Use(middleware.IPMiddleware())
+	loadConfigs()
+	routerInstance = NewEngine()
+	return routerInstance
+}
 
+// loadConfigs load version, app and user config, falling back to defaults
+func loadConfigs() {
 	// load version config file
 	if err := config.LoadVersionConfig(); err != nil {
 		// if version config file load failed, use default value
@@ -84,12 +74,48 @@ func InitRouter() *gin.Engine {
 		}
 		log.Printf("Warning: failed to load user config, created default admin user")
 	}
+}
+
+// NewEngine build the gin engine with all API routes, without loading any configuration
+func NewEngine() *gin.Engine {
+	// create engine without default middleware
+	g := gin.New()
+
+	// use custom logger middleware, skip requests with "disable_log" tag
+	g.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		// if context has "disable_log" tag, skip logging
+		if param.Keys != nil {
+			if noLog, exists := param.Keys["disable_log"]; exists && noLog == true {
+				return ""
+			}
+		}
+		// otherwise use default format to record log
+		return fmt.Sprintf("[GoHook] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			param.StatusCode,
+			param.Latency,
+			param.ClientIP,
+			param.Method,
+			param.Path,
+			param.ErrorMessage,
+		)
+	}))
+
+	// request ID and access log, outside Recovery so failed requests are logged with their status
+	g.Use(middleware.RequestIDMiddleware(), middleware.AccessLogMiddleware())
+
+	// use Recovery middleware
+	g.Use(gin.Recovery())
+
+	// use IP middleware, support real IP in proxy environment
+	g.Use(middleware.IPMiddleware())
 
 	// CORS middleware - add after router registration, avoid wildcard conflict
 	g.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-GoHook-Key")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-GoHook-Key, X-GoHook-Namespace, X-Request-ID, If-None-Match, If-Match")
+		c.Header("Access-Control-Expose-Headers", "X-Total-Count, Link, ETag")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -99,21 +125,33 @@ func InitRouter() *gin.Engine {
 		c.Next()
 	})
 
+	// unversioned API paths are deprecated aliases of the /api/v1 paths of RegisterVersionedAPI
+	g.Use(DeprecatedAliasMiddleware())
+
 	g.GET("/ping", func(c *gin.Context) {
 		c.String(http.StatusOK, "OK")
 	})
 
-	// adapt frontend All Messages page, return empty for now
-	g.GET("/message", middleware.DisableLogMiddleware(), func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"messages": []interface{}{},
-			"paging":   gin.H{},
-		})
-	})
+	// inbox of the current user: deploy results, failed hooks and approvals waiting for them
+	messageAPI := g.Group("/message")
+	messageAPI.Use(middleware.AuthMiddleware(), middleware.DisableLogMiddleware())
+	{
+		messageAPI.GET("", inbox.HandleListMessages)
+		messageAPI.DELETE("", inbox.HandleDeleteMessages)
+		messageAPI.POST("/read", inbox.HandleMarkAllMessagesRead)
+		messageAPI.POST("/:id/read", inbox.HandleMarkMessageRead)
+		messageAPI.DELETE("/:id", inbox.HandleDeleteMessage)
+	}
 
 	// login interface - support Basic authentication
 	g.POST("/client", client.Login)
 
+	// Slack and Mattermost slash commands, verified by signature or token
+	g.POST("/chatops", chatops.HandleSlashCommand)
+
+	// push events of the GitOps configuration repository, verified by the webhook secret
+	g.POST("/gitops/webhook", middleware.DisableLogMiddleware(), gitops.HandleWebhook)
+
 	// token renew interface
 	g.POST("/client/renew", middleware.AuthMiddleware(), client.HandleRenewToken)
 
@@ -126,53 +164,98 @@ func InitRouter() *gin.Engine {
 	// user management API group
 	userAPI := g.Group("/user")
 	userAPI.Use(middleware.AuthMiddleware(), middleware.DisableLogMiddleware())
+	managedUsers := gitops.RejectLocalEdits(cluster.ConfigUsers) // user.yaml reconciled from git
 	{
 		// get all users list (only admin)
 		userAPI.GET("", middleware.AdminMiddleware(), client.GetAllUsers)
 
 		// create user (only admin)
-		userAPI.POST("", middleware.AdminMiddleware(), client.CreateUser)
+		userAPI.POST("", middleware.AdminMiddleware(), managedUsers, client.CreateUser)
 
 		// delete user (only admin)
-		userAPI.DELETE("/:username", middleware.AdminMiddleware(), client.DeleteUser)
+		userAPI.DELETE("/:username", middleware.AdminMiddleware(), managedUsers, client.DeleteUser)
 
 		// change password
-		userAPI.POST("/password", client.ChangePassword)
+		userAPI.POST("/password", managedUsers, client.ChangePassword)
+
+		// time zone and panel language of the current user
+		userAPI.GET("/preferences", client.GetPreferences)
+		userAPI.PUT("/preferences", managedUsers, client.UpdatePreferences)
 
 		// admin reset user password
-		userAPI.POST("/:username/reset-password", middleware.AdminMiddleware(), client.ResetPassword)
+		userAPI.POST("/:username/reset-password", middleware.AdminMiddleware(), managedUsers, client.ResetPassword)
 	}
 
 	// Hooks API group
 	hookAPI := g.Group("/hook")
 	hookAPI.Use(middleware.AuthMiddleware(), middleware.DisableLogMiddleware()) // add auth middleware
+	hookAPI.Use(namespace.RequireAccess(namespace.KindHook, "id"))              // hide hooks of other namespaces
+	managedHooks := gitops.RejectLocalEdits(cluster.ConfigHooks)                // hooks files reconciled from git
+	hookIfMatch := webhook.HookIfMatch()                                        // edits naming a stale revision are refused
 	{
 		// get all hooks
 		hookAPI.GET("", webhook.HandleGetAllHooks)
 
+		// dependency graph of hooks and projects
+		hookAPI.GET("/graph", webhook.HandleGetHookGraph)
+
+		// load state of the hooks files, with the parse errors of broken ones
+		hookAPI.GET("/files", webhook.HandleGetHooksFiles)
+
 		// get single hook details (for editing)
 		hookAPI.GET("/:id", webhook.HandleGetHook)
 
 		// trigger hook (test interface)
 		hookAPI.POST("/:id/trigger", webhook.HandleTriggerHook)
+		// send a synthetic GitHub/GitLab/Gitea event to the hook endpoint
+		hookAPI.POST("/:id/test", webhook.HandleTestHook)
 
 		// reload hooks config interface
 		hookAPI.POST("/reload-config", webhook.HandleReloadHooksConfig)
 
 		// hook configuration management - split into multiple endpoints
-		hookAPI.POST("", webhook.HandleCreateHook)                         // create new hook
-		hookAPI.PUT("/:id/basic", webhook.HandleUpdateHookBasic)           // update basic info
-		hookAPI.PUT("/:id/parameters", webhook.HandleUpdateHookParameters) // update parameters
-		hookAPI.PUT("/:id/triggers", webhook.HandleUpdateHookTriggers)     // update trigger rules
-		hookAPI.PUT("/:id/response", webhook.HandleUpdateHookResponse)     // update response config
+		hookAPI.POST("", managedHooks, webhook.HandleCreateHook)                                      // create new hook
+		hookAPI.PUT("/:id/basic", managedHooks, hookIfMatch, webhook.HandleUpdateHookBasic)           // update basic info
+		hookAPI.PUT("/:id/parameters", managedHooks, hookIfMatch, webhook.HandleUpdateHookParameters) // update parameters
+		hookAPI.PUT("/:id/triggers", managedHooks, hookIfMatch, webhook.HandleUpdateHookTriggers)     // update trigger rules
+		hookAPI.PUT("/:id/response", managedHooks, hookIfMatch, webhook.HandleUpdateHookResponse)     // update response config
 
 		// script management
 		hookAPI.GET("/:id/script", webhook.HandleGetHookScript)
 		hookAPI.POST("/:id/script", webhook.HandleSaveHookScript)
-		hookAPI.PUT("/:id/execute-command", webhook.HandleUpdateHookExecuteCommand)
+		hookAPI.POST("/:id/script/check", webhook.HandleCheckHookScript)
+		hookAPI.PUT("/:id/execute-command", managedHooks, hookIfMatch, webhook.HandleUpdateHookExecuteCommand)
+		hookAPI.PUT("/:id/forward", managedHooks, hookIfMatch, webhook.HandleUpdateHookForward)
+		hookAPI.PUT("/:id/idempotency", managedHooks, hookIfMatch, webhook.HandleUpdateHookIdempotency)
+		hookAPI.PUT("/:id/environment", managedHooks, hookIfMatch, webhook.HandleUpdateHookEnvironment)
+		hookAPI.PUT("/:id/artifacts", managedHooks, hookIfMatch, webhook.HandleUpdateHookArtifacts)
+		hookAPI.PUT("/:id/object-events", managedHooks, hookIfMatch, webhook.HandleUpdateHookObjectEvents)
+		hookAPI.PUT("/:id/transform-plugins", managedHooks, hookIfMatch, webhook.HandleUpdateHookTransformPlugins)
+		hookAPI.PUT("/:id/starlark", managedHooks, hookIfMatch, webhook.HandleUpdateHookStarlark)
+		hookAPI.PUT("/:id/budget", managedHooks, hookIfMatch, webhook.HandleUpdateHookBudget)
+
+		// budget usage of the last hour and the circuit breaker
+		hookAPI.GET("/:id/budget", webhook.HandleGetHookBudget)
+		hookAPI.POST("/:id/budget/reset", webhook.HandleResetHookCircuit)
+
+		// daily execution and storage quotas
+		hookAPI.PUT("/:id/quota", managedHooks, hookIfMatch, webhook.HandleUpdateHookQuota)
+		hookAPI.GET("/:id/quota", webhook.HandleGetHookQuota)
+
+		// files collected after a run
+		hookAPI.GET("/:id/executions/:execID/artifacts", webhook.HandleListHookArtifacts)
+		hookAPI.GET("/:id/executions/:execID/artifacts/*name", webhook.HandleGetHookArtifact)
+
+		// rename hook, the old id stays an alias
+		hookAPI.POST("/:id/rename", managedHooks, hookIfMatch, webhook.HandleRenameHook)
+		hookAPI.PUT("/:id/aliases", managedHooks, hookIfMatch, webhook.HandleUpdateHookAliases)
+		hookAPI.PUT("/:id/endpoints", managedHooks, hookIfMatch, webhook.HandleUpdateHookEndpoints)
+		hookAPI.POST("/:id/endpoints", managedHooks, hookIfMatch, webhook.HandleCreateHookEndpoint)
+		hookAPI.DELETE("/:id/endpoints/:endpoint", managedHooks, hookIfMatch, webhook.HandleDeleteHookEndpoint)
+		hookAPI.POST("/:id/rotate-secret", managedHooks, hookIfMatch, webhook.HandleRotateHookSecret)
 
 		// delete hook
-		hookAPI.DELETE("/:id", webhook.HandleDeleteHook)
+		hookAPI.DELETE("/:id", managedHooks, hookIfMatch, webhook.HandleDeleteHook)
 	}
 
 	// add websocket
@@ -189,6 +272,9 @@ func InitRouter() *gin.Engine {
 	// version management API group
 	versionAPI := g.Group("/version")
 	versionAPI.Use(middleware.AuthMiddleware(), middleware.DisableLogMiddleware()) // add auth middleware
+	versionAPI.Use(namespace.RequireAccess(namespace.KindProject, "name"))         // hide projects of other namespaces
+	managedProjects := gitops.RejectLocalEdits(cluster.ConfigVersion)              // version.yaml reconciled from git
+	projectIfMatch := version.ProjectIfMatch()                                     // edits naming a stale revision are refused
 	{
 		// get all projects list
 		versionAPI.GET("", version.HandleGetProjects)
@@ -197,7 +283,7 @@ func InitRouter() *gin.Engine {
 		versionAPI.POST("/reload-config", version.HandleReloadConfig)
 
 		// add project
-		versionAPI.POST("/add-project", version.HandleAddProject)
+		versionAPI.POST("/add-project", managedProjects, version.HandleAddProject)
 
 		// project-specific routes (more specific paths first to avoid conflicts)
 		// get project branches list
@@ -248,38 +334,102 @@ func InitRouter() *gin.Engine {
 		// delete project environment variable file (.env)
 		versionAPI.DELETE("/:name/env", version.HandleDeleteEnv)
 
+		// SSH deploy key used by the git commands of the project
+		versionAPI.GET("/:name/deploy-key", version.HandleGetDeployKey)
+		versionAPI.POST("/:name/deploy-key", version.HandleCreateDeployKey)
+		versionAPI.DELETE("/:name/deploy-key", version.HandleDeleteDeployKey)
+
 		// save project GitHook configuration
-		versionAPI.POST("/:name/githook", version.HandleSaveGitHook)
+		versionAPI.POST("/:name/githook", managedProjects, projectIfMatch, version.HandleSaveGitHook)
+		versionAPI.POST("/:name/githook/rotate-secret", managedProjects, projectIfMatch, version.HandleRotateGitHookSecret)
+		versionAPI.GET("/:name/githook/provider", version.HandleGetProviderWebhook)
+		versionAPI.POST("/:name/githook/provider", managedProjects, projectIfMatch, version.HandleRegisterProviderWebhook)
+
+		// project service management (systemd / docker compose / pm2)
+		versionAPI.GET("/:name/service", version.HandleGetService)
+		versionAPI.PUT("/:name/service", managedProjects, projectIfMatch, version.HandleSaveService)
+		versionAPI.POST("/:name/service/:action", version.HandleServiceAction)
+
+		// snapshots of local changes taken before force deploys
+		versionAPI.GET("/:name/snapshots", version.HandleListSnapshots)
+		versionAPI.POST("/:name/snapshots/:id/restore", version.HandleRestoreSnapshot)
+		versionAPI.DELETE("/:name/snapshots/:id", version.HandleDeleteSnapshot)
+
+		// newest revisions of the working copy (git, svn, hg or artifact)
+		versionAPI.GET("/:name/log", version.HandleGetLog)
+
+		// commits, deploys, GitHook deliveries and config edits in one feed
+		versionAPI.GET("/:name/timeline", version.HandleGetTimeline)
+
+		// preflight checks run before every deploy, also available on demand
+		versionAPI.GET("/:name/preflight", version.HandlePreflight)
+
+		// git gc / prune / remote prune, scheduled per project or run on demand
+		versionAPI.GET("/:name/maintenance", version.HandleListGitMaintenance)
+		versionAPI.POST("/:name/maintenance", version.HandleGitMaintenance)
+
+		// release directories of release mode deploys, activating one rolls back or forward
+		versionAPI.GET("/:name/releases", version.HandleListReleases)
+		versionAPI.POST("/:name/releases/:id/activate", version.HandleActivateRelease)
+
+		// pin a project at its current revision, deploys are refused until it is unpinned
+		versionAPI.POST("/:name/pin", managedProjects, projectIfMatch, version.HandlePinProject)
+		versionAPI.DELETE("/:name/pin", managedProjects, projectIfMatch, version.HandleUnpinProject)
+
+		// Kubernetes deploy target: rollout state and rollback to the previous revision
+		versionAPI.GET("/:name/kubernetes", version.HandleKubernetesStatus)
+		versionAPI.POST("/:name/kubernetes/rollback", version.HandleKubernetesRollback)
+
+		// promote the revision deployed in another project (e.g. staging -> production)
+		versionAPI.POST("/:name/promote", version.HandlePromoteProject)
+		versionAPI.GET("/:name/promotions", version.HandleListPromotions)
+		versionAPI.GET("/promotions/:id", version.HandleGetPromotion)
+		versionAPI.POST("/promotions/:id/approve", middleware.AdminMiddleware(), version.HandleApprovePromotion)
+		versionAPI.POST("/promotions/:id/reject", middleware.AdminMiddleware(), version.HandleRejectPromotion)
 
 		// project management routes (less specific paths last)
 		// edit project
-		versionAPI.PUT("/:name", version.HandleEditProject)
+		versionAPI.PUT("/:name", managedProjects, projectIfMatch, version.HandleEditProject)
+		versionAPI.POST("/:name/rename", managedProjects, projectIfMatch, version.HandleRenameProject)
 
 		// delete project
-		versionAPI.DELETE("/:name", version.HandleDeleteProject)
+		versionAPI.DELETE("/:name", managedProjects, projectIfMatch, version.HandleDeleteProject)
 	}
 
 	// sync node management API (user-authenticated)
 	syncAPI := g.Group("/api/sync")
 	syncAPI.Use(middleware.AuthMiddleware(), middleware.DisableLogMiddleware())
+	syncAPI.Use(namespace.RequireAccess(namespace.KindProject, "name"))
 	{
 		syncAPI.GET("/local-runtime", syncnode.HandleLocalRuntime)
 
 		// sync projects (folders) management
 		syncAPI.GET("/projects", syncnode.HandleListSyncProjects)
-		syncAPI.PUT("/projects/:name/config", syncnode.HandleUpdateProjectSyncConfig)
+		syncAPI.PUT("/projects/:name/config", gitops.RejectLocalEdits(cluster.ConfigVersion), syncnode.HandleUpdateProjectSyncConfig)
 		syncAPI.GET("/tasks", syncnode.HandleListTasks)
 		syncAPI.GET("/tasks/:id", syncnode.HandleGetTask)
 		syncAPI.DELETE("/tasks", middleware.AdminMiddleware(), syncnode.HandleClearTasks)
 
+		// multi-node rollouts of a project sync run
+		syncAPI.GET("/deployments", syncnode.HandleListDeployments)
+		syncAPI.POST("/deployments", syncnode.HandleCreateDeployment)
+		syncAPI.GET("/deployments/:id", syncnode.HandleGetDeployment)
+		syncAPI.POST("/deployments/:id/approve", middleware.AdminMiddleware(), syncnode.HandleApproveDeployment)
+		syncAPI.POST("/deployments/:id/abort", syncnode.HandleAbortDeployment)
+		syncAPI.POST("/deployments/:id/retry", syncnode.HandleRetryDeployment)
+
 		nodeAPI := syncAPI.Group("/nodes")
 		nodeAPI.GET("", syncnode.HandleListNodes)
 		nodeAPI.POST("", syncnode.HandleCreateNode)
 		nodeAPI.GET("/:id", syncnode.HandleGetNode)
+		nodeAPI.GET("/:id/metrics", syncnode.HandleGetNodeMetrics)
+		nodeAPI.GET("/:id/logs", syncnode.HandleGetNodeLogs)
 		nodeAPI.PUT("/:id", syncnode.HandleUpdateNode)
 		nodeAPI.DELETE("/:id", syncnode.HandleDeleteNode)
 		nodeAPI.POST("/:id/rotate-token", syncnode.HandleRotateToken)
 		nodeAPI.POST("/:id/reset-pairing", syncnode.HandleResetPairing)
+		nodeAPI.POST("/:id/approve", middleware.AdminMiddleware(), syncnode.HandleApproveNode)
+		nodeAPI.POST("/:id/revoke", middleware.AdminMiddleware(), syncnode.HandleRevokeNode)
 		nodeAPI.POST("/:id/install", syncnode.HandleInstallNode)
 	}
 
@@ -302,52 +452,104 @@ func InitRouter() *gin.Engine {
 	// GitHook webhook endpoint
 	g.POST("/githook/:name", version.HandleGitHook)
 
-	// plugin management API group (temporary empty interface)
+	// plugin management, changes are admin only
 	pluginAPI := g.Group("/plugin")
-	pluginAPI.Use(middleware.AuthMiddleware(), middleware.DisableLogMiddleware()) // add authentication middleware
+	pluginAPI.Use(middleware.AuthMiddleware(), middleware.DisableLogMiddleware())
 	{
-		// get all plugins list
-		pluginAPI.GET("", func(c *gin.Context) {
-			// return empty plugin list
-			c.JSON(http.StatusOK, []gin.H{})
-		})
-
-		// get specified plugin configuration
-		pluginAPI.GET("/:id/config", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "Plugin configuration function not implemented",
-			})
-		})
-
-		// get specified plugin display information
-		pluginAPI.GET("/:id/display", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "Plugin display function not implemented",
-			})
-		})
-
-		// update plugin configuration
-		pluginAPI.POST("/:id/config", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "Plugin configuration update function not implemented",
-			})
-		})
-
-		// enable plugin
-		pluginAPI.POST("/:id/enable", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "Plugin enable function not implemented",
-			})
-		})
-
-		// disable plugin
-		pluginAPI.POST("/:id/disable", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "Plugin disable function not implemented",
-			})
-		})
+		pluginAPI.GET("", plugin.HandleListPlugins)
+		pluginAPI.GET("/:id/config", middleware.AdminMiddleware(), plugin.HandleGetPluginConfig)
+		pluginAPI.POST("/:id/config", middleware.AdminMiddleware(), plugin.HandleUpdatePluginConfig)
+		pluginAPI.GET("/:id/display", plugin.HandleGetPluginDisplay)
+		pluginAPI.POST("/:id/enable", middleware.AdminMiddleware(), plugin.HandleEnablePlugin)
+		pluginAPI.POST("/:id/disable", middleware.AdminMiddleware(), plugin.HandleDisablePlugin)
+		pluginAPI.PUT("/wasm/:name", middleware.AdminMiddleware(), plugin.HandleInstallWasmPlugin)
+		pluginAPI.DELETE("/wasm/:name", middleware.AdminMiddleware(), plugin.HandleRemoveWasmPlugin)
 	}
 
+	// usage of the namespace, user and hook quotas
+	g.GET("/api/quotas", middleware.AuthMiddleware(), middleware.DisableLogMiddleware(), HandleListQuotas)
+
+	// namespace (tenant) management, changes are admin only
+	namespaceAPI := g.Group("/api/namespaces")
+	namespaceAPI.Use(middleware.AuthMiddleware(), middleware.DisableLogMiddleware())
+	{
+		namespaceAPI.GET("", HandleListNamespaces)
+		namespaceAPI.POST("", middleware.AdminMiddleware(), HandleCreateNamespace)
+		namespaceAPI.PUT("/:name", middleware.AdminMiddleware(), HandleUpdateNamespace)
+		namespaceAPI.DELETE("/:name", middleware.AdminMiddleware(), HandleDeleteNamespace)
+	}
+
+	// HA cluster state and leader hand-over (admin only)
+	clusterAPI := g.Group("/api/cluster")
+	clusterAPI.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		clusterAPI.GET("", HandleGetCluster)
+		clusterAPI.POST("/step-down", HandleClusterStepDown)
+	}
+
+	// maintenance mode and queued webhook deliveries (admin only)
+	maintenanceAPI := g.Group("/api/maintenance")
+	maintenanceAPI.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		maintenanceAPI.GET("", maintenance.HandleGetMaintenance)
+		maintenanceAPI.PUT("", maintenance.HandleUpdateMaintenance)
+		maintenanceAPI.GET("/queue", maintenance.HandleListQueue)
+		maintenanceAPI.POST("/queue/flush", maintenance.HandleFlushQueue)
+		maintenanceAPI.DELETE("/queue", maintenance.HandleDiscardQueue)
+	}
+
+	// message queue consumers of this instance (admin only)
+	consumerAPI := g.Group("/api/consumers")
+	consumerAPI.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		consumerAPI.GET("", consumer.HandleListConsumers)
+	}
+
+	// deleted hooks and projects, restorable until the retention period ends
+	trashAPI := g.Group("/trash")
+	trashAPI.Use(middleware.AuthMiddleware(), middleware.DisableLogMiddleware())
+	{
+		trashAPI.GET("", HandleListTrash)
+		trashAPI.DELETE("", HandleEmptyTrash)
+		trashAPI.GET("/:id", HandleGetTrashItem)
+		trashAPI.POST("/:id/restore", gitops.RejectLocalEdits(cluster.ConfigHooks, cluster.ConfigVersion), HandleRestoreTrashItem)
+		trashAPI.DELETE("/:id", HandlePurgeTrashItem)
+	}
+
+	// encrypted backup and restore of the whole configuration and its inventory (admin only)
+	adminAPI := g.Group("/admin")
+	adminAPI.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware(), middleware.DisableLogMiddleware())
+	{
+		adminAPI.GET("/backup", backup.HandleBackup)
+		adminAPI.POST("/restore", gitops.RejectLocalEdits(cluster.ConfigVersion, cluster.ConfigUsers, cluster.ConfigHooks), backup.HandleRestore)
+		adminAPI.GET("/inventory", HandleInventory)
+		adminAPI.POST("/config/diff", HandleConfigDiff)
+		adminAPI.GET("/lint", HandleLint)
+		adminAPI.GET("/queues", HandleGetQueues)
+		adminAPI.PUT("/queues", HandleResizeQueues)
+		adminAPI.GET("/audit/verify", HandleVerifyAudit)
+		adminAPI.GET("/log-forwarders", logforward.HandleListForwarders)
+
+		// configuration reconciled from a git repository
+		adminAPI.GET("/gitops", gitops.HandleGetStatus)
+		adminAPI.POST("/gitops/sync", gitops.HandleSync)
+		adminAPI.GET("/update", selfupdate.HandleGetStatus)
+		adminAPI.POST("/update", selfupdate.HandleApply)
+	}
+
+	// runtime metrics, pprof profiles and the diagnostics bundle for support cases (admin only)
+	debugAPI := g.Group("/debug")
+	debugAPI.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware(), middleware.DisableLogMiddleware())
+	{
+		debugAPI.GET("/runtime", HandleDebugRuntime)
+		debugAPI.GET("/bundle", HandleDiagnosticsBundle)
+		debugAPI.GET("/pprof/*profile", HandlePprof)
+		debugAPI.POST("/pprof/*profile", HandlePprof) // symbol lookups of go tool pprof
+	}
+
+	// live tail of new logs (server-sent events), the token can be passed as ?token= for EventSource
+	g.GET("/api/logs/tail", middleware.WsAuthMiddleware(), middleware.DisableLogMiddleware(), HandleTailLogs)
+
 	// log management API group
 	logAPI := g.Group("/api/logs")
 	logAPI.Use(middleware.AuthMiddleware(), middleware.DisableLogMiddleware()) // add authentication middleware
@@ -362,6 +564,17 @@ func InitRouter() *gin.Engine {
 		logAPI.DELETE("/cleanup", HandleCleanupLogs)
 	}
 
+	// global search across hooks, projects, scripts and logs
+	g.GET("/search", middleware.AuthMiddleware(), middleware.DisableLogMiddleware(), HandleSearch)
+
+	// aggregated metrics for dashboard charts
+	statsAPI := g.Group("/stats")
+	statsAPI.Use(middleware.AuthMiddleware(), middleware.DisableLogMiddleware())
+	{
+		statsAPI.GET("/overview", HandleStatsOverview)
+		statsAPI.GET("/timeseries", HandleStatsTimeseries)
+	}
+
 	// system configuration management API group
 	systemRouter := NewSystemRouter()
 	systemRouter.RegisterSystemRoutes(&g.RouterGroup)
@@ -378,8 +591,7 @@ func InitRouter() *gin.Engine {
 	// modify current user password API (add to existing current route)
 	g.POST("/current/user/password", client.HandleModifyCurrentClientPassword)
 
-	// save router instance
-	routerInstance = g
+	describeRoutes()
 
 	return g
 }
@@ -0,0 +1,327 @@
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/version"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+// search result types
+const (
+	SearchTypeHook    = "hook"
+	SearchTypeProject = "project"
+	SearchTypeScript  = "script"
+	SearchTypeLog     = "log"
+)
+
+const (
+	searchDefaultLimit = 20
+	searchMaxLimit     = 100
+	// scripts larger than this are not searched
+	searchMaxScriptSize = 1 << 20
+	// characters kept on each side of a match in snippets
+	searchSnippetContext = 60
+)
+
+// SearchResult item matched by the global search
+type SearchResult struct {
+	Type      string `json:"type"`                // hook, project, script or log
+	ID        string `json:"id"`                  // hook id, project name, script path or log id
+	Title     string `json:"title"`               // text to display for the item
+	Field     string `json:"field"`               // field the query matched in
+	Snippet   string `json:"snippet"`             // matched text with some context
+	Line      int    `json:"line,omitempty"`      // line of the match in scripts
+	Namespace string `json:"namespace,omitempty"` // namespace of the hook or project
+	Link      string `json:"link"`                // dashboard route of the item
+}
+
+// SearchResponse results of GET /search, at most limit per type
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+}
+
+// HandleSearch search hooks, projects, hook scripts and recent logs for q
+func HandleSearch(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeSearchQueryRequired, nil)
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(searchDefaultLimit)))
+	if limit <= 0 || limit > searchMaxLimit {
+		limit = searchDefaultLimit
+	}
+	wanted := map[string]bool{}
+	for _, t := range strings.Split(c.Query("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			wanted[t] = true
+		}
+	}
+	want := func(t string) bool { return len(wanted) == 0 || wanted[t] }
+
+	var hooks []webhook.Hook
+	if webhook.LoadedHooksFromFiles != nil {
+		for _, hooksInFile := range *webhook.LoadedHooksFromFiles {
+			for _, h := range hooksInFile {
+				if namespace.Allowed(c, h.Namespace) {
+					hooks = append(hooks, h)
+				}
+			}
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+
+	results := []SearchResult{}
+	if want(SearchTypeHook) {
+		results = append(results, searchHooks(hooks, q, limit)...)
+	}
+	if want(SearchTypeProject) && types.GoHookVersionData != nil {
+		var projects []types.ProjectConfig
+		for _, p := range types.GoHookVersionData.Projects {
+			if namespace.Allowed(c, p.Namespace) {
+				projects = append(projects, p)
+			}
+		}
+		results = append(results, searchProjects(projects, version.ProjectRemote, q, limit)...)
+	}
+	if want(SearchTypeScript) {
+		results = append(results, searchScripts(hooks, q, limit)...)
+	}
+	if want(SearchTypeLog) {
+		logs, err := searchLogs(database.NewLogService().InNamespace(namespace.FromContext(c)).Decrypting(decryptsLogs(c)), q, limit)
+		if err != nil {
+			i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSearchLogsFailed, err)
+			return
+		}
+		results = append(results, logs...)
+	}
+
+	c.JSON(http.StatusOK, SearchResponse{Query: q, Results: results})
+}
+
+// searchHooks match hooks by ID, command and trigger rule values, secrets are never searched
+func searchHooks(hooks []webhook.Hook, q string, limit int) []SearchResult {
+	var results []SearchResult
+	for _, h := range hooks {
+		if len(results) >= limit {
+			break
+		}
+		fields := [][2]string{{"id", h.ID}, {"execute-command", h.ExecuteCommand}}
+		if h.TriggerRule != nil {
+			for _, v := range ruleValues(*h.TriggerRule) {
+				fields = append(fields, [2]string{"trigger-rule", v})
+			}
+		}
+		for _, f := range fields {
+			if snippet, ok := searchSnippet(f[1], q); ok {
+				results = append(results, SearchResult{
+					Type:      SearchTypeHook,
+					ID:        h.ID,
+					Title:     h.ID,
+					Field:     f[0],
+					Snippet:   snippet,
+					Namespace: namespace.Normalize(h.Namespace),
+					Link:      "/hooks?id=" + url.QueryEscape(h.ID),
+				})
+				break
+			}
+		}
+	}
+	return results
+}
+
+// ruleValues values, regexes, parameter names and IP ranges of the match rules in r and the
+// functions of its starlark rules
+func ruleValues(r webhook.Rules) []string {
+	var values []string
+	switch {
+	case r.And != nil:
+		for _, child := range *r.And {
+			values = append(values, ruleValues(child)...)
+		}
+	case r.Or != nil:
+		for _, child := range *r.Or {
+			values = append(values, ruleValues(child)...)
+		}
+	case r.Not != nil:
+		values = ruleValues(webhook.Rules(*r.Not))
+	case r.Match != nil:
+		for _, v := range []string{r.Match.Value, r.Match.Regex, r.Match.Parameter.Name, r.Match.IPRange} {
+			if v != "" {
+				values = append(values, v)
+			}
+		}
+	case r.Starlark != nil && r.Starlark.Function != "":
+		values = append(values, r.Starlark.Function)
+	}
+	return values
+}
+
+// searchProjects match projects by name, path and origin remote, remote looks the remote up lazily
+func searchProjects(projects []types.ProjectConfig, remote func(path string) string, q string, limit int) []SearchResult {
+	var results []SearchResult
+	for _, p := range projects {
+		if len(results) >= limit {
+			break
+		}
+		field, snippet, ok := "name", "", false
+		if snippet, ok = searchSnippet(p.Name, q); !ok {
+			field = "path"
+			if snippet, ok = searchSnippet(p.Path, q); !ok && remote != nil {
+				field = "remote"
+				snippet, ok = searchSnippet(remote(p.Path), q)
+			}
+		}
+		if ok {
+			results = append(results, SearchResult{
+				Type:      SearchTypeProject,
+				ID:        p.Name,
+				Title:     p.Name,
+				Field:     field,
+				Snippet:   snippet,
+				Namespace: namespace.Normalize(p.Namespace),
+				Link:      "/versions/" + url.PathEscape(p.Name) + "/branches",
+			})
+		}
+	}
+	return results
+}
+
+// searchScripts match the script files run by hooks, one result per matching line
+func searchScripts(hooks []webhook.Hook, q string, limit int) []SearchResult {
+	var results []SearchResult
+	seen := map[string]bool{}
+	for _, h := range hooks {
+		path := h.ExecuteCommand
+		if path == "" || seen[path] || !webhook.ScriptPathAllowed(path) {
+			continue
+		}
+		seen[path] = true
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Size() > searchMaxScriptSize {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil || bytes.IndexByte(content, 0) >= 0 {
+			continue // unreadable or binary
+		}
+		for i, line := range strings.Split(string(content), "\n") {
+			if len(results) >= limit {
+				return results
+			}
+			if snippet, ok := searchSnippet(line, q); ok {
+				results = append(results, SearchResult{
+					Type:      SearchTypeScript,
+					ID:        path,
+					Title:     path,
+					Field:     "content",
+					Snippet:   snippet,
+					Line:      i + 1,
+					Namespace: namespace.Normalize(h.Namespace),
+					Link:      "/hooks?id=" + url.QueryEscape(h.ID) + "&tab=script&line=" + strconv.Itoa(i+1),
+				})
+			}
+		}
+	}
+	return results
+}
+
+// searchLogs match recent hook and system logs
+func searchLogs(logService *database.LogService, q string, limit int) ([]SearchResult, error) {
+	hookLogs, err := logService.SearchHookLogs(q, limit)
+	if err != nil {
+		return nil, err
+	}
+	systemLogs, err := logService.SearchSystemLogs(q, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, l := range hookLogs {
+		field, snippet := "hook", l.HookID
+		for _, f := range [][2]string{{"output", l.Output}, {"error", l.Error}} {
+			if s, ok := searchSnippet(f[1], q); ok {
+				field, snippet = f[0], s
+				break
+			}
+		}
+		results = append(results, SearchResult{
+			Type:      SearchTypeLog,
+			ID:        strconv.FormatUint(uint64(l.ID), 10),
+			Title:     fmt.Sprintf("%s %s", l.HookID, l.CreatedAt.Format("2006-01-02 15:04:05")),
+			Field:     field,
+			Snippet:   snippet,
+			Namespace: l.Namespace,
+			Link:      "/logs?type=hook&id=" + strconv.FormatUint(uint64(l.ID), 10),
+		})
+	}
+	for _, l := range systemLogs {
+		field, snippet := "message", l.Message
+		if s, ok := searchSnippet(l.Message, q); ok {
+			snippet = s
+		} else if s, ok := searchSnippet(l.Details, q); ok {
+			field, snippet = "details", s
+		}
+		results = append(results, SearchResult{
+			Type:      SearchTypeLog,
+			ID:        strconv.FormatUint(uint64(l.ID), 10),
+			Title:     fmt.Sprintf("%s %s", l.Level, l.CreatedAt.Format("2006-01-02 15:04:05")),
+			Field:     field,
+			Snippet:   snippet,
+			Namespace: l.Namespace,
+			Link:      "/logs?type=system&id=" + strconv.FormatUint(uint64(l.ID), 10),
+		})
+	}
+	return results, nil
+}
+
+// searchSnippet report whether text contains q (case-insensitive) and return the
+// line of the match shortened to some context around it
+func searchSnippet(text, q string) (string, bool) {
+	lower := strings.ToLower(text)
+	var idx int
+	if len(lower) == len(text) {
+		idx = strings.Index(lower, strings.ToLower(q))
+	} else {
+		idx = strings.Index(text, q) // lowercasing changed the offsets
+	}
+	if idx < 0 {
+		return "", false
+	}
+
+	start := strings.LastIndexByte(text[:idx], '\n') + 1
+	end := len(text)
+	if i := strings.IndexByte(text[idx:], '\n'); i >= 0 {
+		end = idx + i
+	}
+	prefix, suffix := "", ""
+	if idx-start > searchSnippetContext {
+		start, prefix = idx-searchSnippetContext, "..."
+	}
+	if end-(idx+len(q)) > searchSnippetContext {
+		end, suffix = idx+len(q)+searchSnippetContext, "..."
+	}
+	for start < idx && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	return prefix + strings.TrimSpace(text[start:end]) + suffix, true
+}
@@ -0,0 +1,112 @@
+package router
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+func TestSearchSnippet(t *testing.T) {
+	long := strings.Repeat("a", 100) + "needle" + strings.Repeat("b", 100)
+	tests := []struct {
+		text, q string
+		want    string
+		ok      bool
+	}{
+		{"git pull origin main", "PULL", "git pull origin main", true},
+		{"first line\n  deploy --prod  \nlast", "deploy", "deploy --prod", true},
+		{long, "needle", "..." + strings.Repeat("a", 60) + "needle" + strings.Repeat("b", 60) + "...", true},
+		{"nothing here", "needle", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := searchSnippet(tt.text, tt.q)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("searchSnippet(%q, %q) = %q, %v, want %q, %v", tt.text, tt.q, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSearchHooks(t *testing.T) {
+	hooks := []webhook.Hook{
+		{ID: "deploy-api", ExecuteCommand: "/srv/api.sh"},
+		{ID: "build", ExecuteCommand: "/srv/build.sh", TriggerRule: &webhook.Rules{And: &webhook.AndRule{
+			{Match: &webhook.MatchRule{Type: "value", Value: "refs/heads/release", Parameter: webhook.Argument{Source: "payload", Name: "ref"}}},
+			{Match: &webhook.MatchRule{Type: "payload-hmac-sha256", Secret: "topsecret", Parameter: webhook.Argument{Source: "header", Name: "X-Hub-Signature-256"}}},
+		}}},
+	}
+
+	tests := []struct {
+		q      string
+		want   []string
+		fields []string
+	}{
+		{"deploy", []string{"deploy-api"}, []string{"id"}},
+		{"build.sh", []string{"build"}, []string{"execute-command"}},
+		{"release", []string{"build"}, []string{"trigger-rule"}},
+		{"topsecret", nil, nil},
+	}
+	for _, tt := range tests {
+		results := searchHooks(hooks, tt.q, searchDefaultLimit)
+		var ids, fields []string
+		for _, r := range results {
+			ids = append(ids, r.ID)
+			fields = append(fields, r.Field)
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.want, ",") || strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+			t.Errorf("searchHooks(%q) = %v %v, want %v %v", tt.q, ids, fields, tt.want, tt.fields)
+		}
+	}
+}
+
+func TestSearchProjects(t *testing.T) {
+	projects := []types.ProjectConfig{
+		{Name: "shop", Path: "/srv/shop"},
+		{Name: "blog", Path: "/var/www/blog"},
+	}
+	remote := func(path string) string {
+		if path == "/srv/shop" {
+			return "git@github.com:acme/storefront.git"
+		}
+		return ""
+	}
+
+	tests := []struct {
+		q     string
+		field string
+	}{
+		{"shop", "name"},
+		{"www", "path"},
+		{"storefront", "remote"},
+	}
+	for _, tt := range tests {
+		results := searchProjects(projects, remote, tt.q, searchDefaultLimit)
+		if len(results) != 1 || results[0].Field != tt.field {
+			t.Errorf("searchProjects(%q) = %+v, want one match in %s", tt.q, results, tt.field)
+		}
+	}
+}
+
+func TestSearchScripts(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "deploy.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncd /srv/app\nsystemctl restart app\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(dir, "tool")
+	if err := os.WriteFile(binary, []byte("restart\x00\x01"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	hooks := []webhook.Hook{{ID: "deploy", ExecuteCommand: script}, {ID: "tool", ExecuteCommand: binary}}
+
+	results := searchScripts(hooks, "restart", searchDefaultLimit)
+	if len(results) != 1 {
+		t.Fatalf("searchScripts() = %+v, want one match", results)
+	}
+	if r := results[0]; r.ID != script || r.Line != 3 || r.Snippet != "systemctl restart app" {
+		t.Errorf("searchScripts() = %+v", r)
+	}
+}
@@ -0,0 +1,161 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/config"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/urls"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+// ServerConfigResponse URL layout of the server and the hook URLs it results in
+type ServerConfigResponse struct {
+	HooksURLPrefix string   `json:"hooksUrlPrefix"`
+	BasePath       string   `json:"basePath"`
+	HookURLPattern string   `json:"hookUrlPattern"`          // e.g. /gohook/hooks/{id}
+	ShadowedHooks  []string `json:"shadowedHooks,omitempty"` // hooks whose URL is taken by a panel or API route
+}
+
+// hookRoutePaths routes registered by RegisterHookRoutes, they do not reserve path segments
+var hookRoutePaths = map[string]bool{}
+
+// RegisterHookRoutes serve hook deliveries with handler. Paths are matched against the current
+// URL layout, so a prefix changed at runtime applies at once: the routes of the prefix in use
+// at startup are registered for the API document, any other hook URL arrives through NoRoute.
+func RegisterHookRoutes(r *gin.Engine, handler gin.HandlerFunc) {
+	dispatch := func(c *gin.Context) {
+		id, ns, ok := urls.Current().MatchHook(c.Request.URL.Path)
+		if !ok {
+			c.String(http.StatusNotFound, "404 page not found")
+			return
+		}
+		c.Params = gin.Params{{Key: "namespace", Value: ns}, {Key: "id", Value: id}}
+		handler(c)
+	}
+
+	hooksPath := urls.Current().HooksPath()
+	paths := []string{"/ns/:namespace" + hooksPath + "*id"}
+	if hooksPath != "/" {
+		// a catch-all on the root would conflict with every other route
+		paths = append(paths, hooksPath+"*id")
+	}
+	for _, p := range paths {
+		r.Any(p, dispatch)
+		hookRoutePaths[p] = true
+	}
+	r.NoRoute(dispatch)
+}
+
+// reservedSegments first path segments used by panel and API routes
+func reservedSegments(r *gin.Engine) map[string]bool {
+	reserved := map[string]bool{"ns": true}
+	for _, route := range r.Routes() {
+		if hookRoutePaths[route.Path] {
+			continue
+		}
+		segment := strings.SplitN(strings.TrimPrefix(route.Path, "/"), "/", 2)[0]
+		if segment != "" && !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			reserved[segment] = true
+		}
+	}
+	return reserved
+}
+
+func firstSegment(p string) string {
+	return strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2)[0]
+}
+
+// checkLayout reject a layout whose paths would hide panel or API routes
+func checkLayout(r *gin.Engine, l urls.Layout) error {
+	reserved := reservedSegments(r)
+	if l.BasePath != "" && reserved[firstSegment(l.BasePath)] {
+		return fmt.Errorf("base path %s conflicts with the /%s routes", l.BasePath, firstSegment(l.BasePath))
+	}
+	if l.HooksPrefix != "" && reserved[firstSegment(l.HooksPrefix)] {
+		return fmt.Errorf("hooks URL prefix %s conflicts with the /%s routes", l.HooksPrefix, firstSegment(l.HooksPrefix))
+	}
+	return nil
+}
+
+// shadowedHooks hooks not reachable under l because a panel or API route owns their URL
+func shadowedHooks(r *gin.Engine, l urls.Layout) []string {
+	if l.HooksPrefix != "" {
+		return nil
+	}
+	reserved := reservedSegments(r)
+	var shadowed []string
+	for _, h := range webhook.HookManager.GetAllHooks() {
+		if reserved[firstSegment(h.ID)] {
+			shadowed = append(shadowed, h.ID)
+		}
+	}
+	sort.Strings(shadowed)
+	return shadowed
+}
+
+func serverConfigResponse(l urls.Layout) ServerConfigResponse {
+	resp := ServerConfigResponse{HooksURLPrefix: l.HooksPrefix, BasePath: l.BasePath, HookURLPattern: l.HumanPattern()}
+	if r := GetRouter(); r != nil && webhook.HookManager != nil {
+		resp.ShadowedHooks = shadowedHooks(r, l)
+	}
+	return resp
+}
+
+// GetServerConfig get the URL layout of hook endpoints and the panel
+func (sr *SystemRouter) GetServerConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, serverConfigResponse(urls.Current()))
+}
+
+// UpdateServerConfig change the hooks URL prefix and the base path, both apply immediately.
+// A missing hooksUrlPrefix restores the default prefix, an empty one serves hooks on /{id}.
+func (sr *SystemRouter) UpdateServerConfig(c *gin.Context) {
+	var req types.ServerConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	l, err := urls.FromConfig(&req)
+	if err == nil {
+		if r := GetRouter(); r != nil {
+			err = checkLayout(r, l)
+		}
+	}
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidURLLayout, err)
+		return
+	}
+	if types.GoHookAppConfig == nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeAppConfigNotLoaded, nil)
+		return
+	}
+
+	old := urls.Current()
+	oldServer := types.GoHookAppConfig.Server
+	types.GoHookAppConfig.Server = &types.ServerConfig{BasePath: l.BasePath}
+	if l.HooksPrefix != urls.DefaultHooksPrefix {
+		prefix := l.HooksPrefix
+		types.GoHookAppConfig.Server.HooksURLPrefix = &prefix
+	}
+	details := map[string]interface{}{"old": serverConfigResponse(old), "new": serverConfigResponse(l)}
+	if err := config.SaveAppConfig(); err != nil {
+		types.GoHookAppConfig.Server = oldServer
+		details["error"] = err.Error()
+		database.LogUserAction(c.GetString("username"), database.UserActionUpdateServerConfig, "system_config",
+			"update server URL layout failed", middleware.GetClientIP(c), c.Request.UserAgent(), false, details)
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
+		return
+	}
+	urls.Set(l)
+	database.LogUserAction(c.GetString("username"), database.UserActionUpdateServerConfig, "system_config",
+		"update server URL layout: "+l.HumanPattern(), middleware.GetClientIP(c), c.Request.UserAgent(), true, details)
+
+	c.JSON(http.StatusOK, serverConfigResponse(l))
+}
@@ -0,0 +1,105 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/namespace"
+)
+
+// statsMaxBuckets keeps time series requests below the limit of the log service
+const statsMaxBuckets = 1000
+
+// StatsTimeseriesResponse buckets of GET /stats/timeseries
+type StatsTimeseriesResponse struct {
+	Interval string                 `json:"interval"`
+	Start    time.Time              `json:"start"`
+	End      time.Time              `json:"end"`
+	Buckets  []database.StatsBucket `json:"buckets"`
+}
+
+// parseStatsFilter read start, end, hook_type and hook_id, the range defaults to the last defaultRange
+func parseStatsFilter(c *gin.Context, defaultRange time.Duration) (database.StatsFilter, error) {
+	f := database.StatsFilter{
+		End:      time.Now(),
+		HookType: c.Query("hook_type"),
+		HookID:   c.Query("hook_id"),
+	}
+	if end := c.Query("end"); end != "" {
+		t, err := time.Parse(time.RFC3339, end)
+		if err != nil {
+			return f, fmt.Errorf("invalid end: %v", err)
+		}
+		f.End = t
+	}
+	f.Start = f.End.Add(-defaultRange)
+	if start := c.Query("start"); start != "" {
+		t, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			return f, fmt.Errorf("invalid start: %v", err)
+		}
+		f.Start = t
+	}
+	if !f.End.After(f.Start) {
+		return f, fmt.Errorf("end must be after start")
+	}
+	if f.HookType != "" && f.HookType != database.HookTypeWebhook && f.HookType != database.HookTypeGitHook {
+		return f, fmt.Errorf("hook_type must be webhook or githook")
+	}
+	return f, nil
+}
+
+// HandleStatsOverview totals, success rate, top failing hooks and deploys per project, last 7 days by default
+func HandleStatsOverview(c *gin.Context) {
+	f, err := parseStatsFilter(c, 7*24*time.Hour)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidStatsFilter, err)
+		return
+	}
+	top, _ := strconv.Atoi(c.DefaultQuery("top", "5"))
+	if top <= 0 || top > 50 {
+		top = 5
+	}
+
+	overview, err := database.NewLogService().InNamespace(namespace.FromContext(c)).GetStatsOverview(f, top)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadStatsFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, overview)
+}
+
+// HandleStatsTimeseries hourly or daily buckets, hourly over the last 24 hours by default
+func HandleStatsTimeseries(c *gin.Context) {
+	interval := c.DefaultQuery("interval", database.StatsIntervalHour)
+	step, defaultRange := time.Hour, 24*time.Hour
+	switch interval {
+	case database.StatsIntervalHour:
+	case database.StatsIntervalDay:
+		step, defaultRange = 24*time.Hour, 30*24*time.Hour
+	default:
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidStatsInterval, nil)
+		return
+	}
+	f, err := parseStatsFilter(c, defaultRange)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidStatsFilter, err)
+		return
+	}
+	if f.End.Sub(f.Start)/step >= statsMaxBuckets {
+		i18n.JSONErrorf(c, http.StatusBadRequest, i18n.CodeStatsRangeTooLarge, statsMaxBuckets)
+		return
+	}
+
+	buckets, err := database.NewLogService().InNamespace(namespace.FromContext(c)).GetStatsTimeseries(f, interval)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadStatsFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, StatsTimeseriesResponse{Interval: interval, Start: f.Start, End: f.End, Buckets: buckets})
+}
@@ -1,14 +1,23 @@
 package router
 
 import (
+	"encoding/json"
+	"log"
 	"net/http"
 
+	"github.com/mycoool/gohook/internal/cluster"
 	"github.com/mycoool/gohook/internal/config"
 	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/gitops"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/locale"
 	"github.com/mycoool/gohook/internal/middleware"
 	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/webhook"
 
+	"github.com/ghodss/yaml"
 	"github.com/gin-gonic/gin"
+	yamlv2 "gopkg.in/yaml.v2"
 )
 
 // SystemRouter system config router
@@ -26,6 +35,10 @@ func (sr *SystemRouter) RegisterSystemRoutes(rg *gin.RouterGroup) {
 	{
 		systemGroup.GET("/config", sr.GetSystemConfig)
 		systemGroup.PUT("/config", sr.UpdateSystemConfig)
+		systemGroup.GET("/export", sr.ExportConfig)
+		systemGroup.POST("/import", gitops.RejectLocalEdits(cluster.ConfigHooks, cluster.ConfigVersion), sr.ImportConfig)
+		systemGroup.GET("/server", sr.GetServerConfig)
+		systemGroup.PUT("/server", sr.UpdateServerConfig)
 	}
 }
 
@@ -34,19 +47,19 @@ func (sr *SystemRouter) GetSystemConfig(c *gin.Context) {
 	// check admin permission
 	_, exists := c.Get("username")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized access"})
+		i18n.JSONError(c, http.StatusUnauthorized, i18n.CodeUnauthorized, nil)
 		return
 	}
 
 	role, exists := c.Get("role")
 	if !exists || role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "need admin permission"})
+		i18n.JSONError(c, http.StatusForbidden, i18n.CodeAdminRequired, nil)
 		return
 	}
 
 	systemConfig, err := config.LoadSystemConfig()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "load config failed: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadSystemConfigFailed, err)
 		return
 	}
 
@@ -58,26 +71,26 @@ func (sr *SystemRouter) UpdateSystemConfig(c *gin.Context) {
 	// check admin permission
 	username, exists := c.Get("username")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized access"})
+		i18n.JSONError(c, http.StatusUnauthorized, i18n.CodeUnauthorized, nil)
 		return
 	}
 
 	role, exists := c.Get("role")
 	if !exists || role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "need admin permission"})
+		i18n.JSONError(c, http.StatusForbidden, i18n.CodeAdminRequired, nil)
 		return
 	}
 
 	var newConfig config.SystemConfig
 	if err := c.ShouldBindJSON(&newConfig); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request data: " + err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
 	// load original config for record change
 	oldConfig, err := config.LoadSystemConfig()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "load original config failed: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadSystemConfigFailed, err)
 		return
 	}
 
@@ -88,14 +101,14 @@ func (sr *SystemRouter) UpdateSystemConfig(c *gin.Context) {
 
 	// save new config
 	if err := config.SaveSystemConfig(&newConfig); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "save config failed: " + err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeSaveConfigFailed, err)
 		// record failed log
 		database.LogUserAction(
 			username.(string),
 			"UPDATE_SYSTEM_CONFIG",
 			"system_config",
 			"update system config failed",
-			c.ClientIP(),
+			middleware.GetClientIP(c),
 			c.GetHeader("User-Agent"),
 			false,
 			gin.H{
@@ -112,7 +125,7 @@ func (sr *SystemRouter) UpdateSystemConfig(c *gin.Context) {
 		"UPDATE_SYSTEM_CONFIG",
 		"system_config",
 		"update system config success",
-		c.ClientIP(),
+		middleware.GetClientIP(c),
 		c.GetHeader("User-Agent"),
 		true,
 		gin.H{
@@ -123,6 +136,148 @@ func (sr *SystemRouter) UpdateSystemConfig(c *gin.Context) {
 
 	// update types.GoHookAppConfig in memory
 	types.UpdateAppConfig(newConfig)
+	if err := locale.Configure(newConfig.Timezone); err != nil {
+		log.Printf("timezone not applied: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeSystemConfigUpdated), "code": i18n.CodeSystemConfigUpdated})
+}
+
+// ConfigBundle exported projects and hooks, projects keep the field names of version.yaml
+type ConfigBundle struct {
+	Projects []json.RawMessage `json:"projects"`
+	Hooks    []webhook.Hook    `json:"hooks"`
+}
+
+// ExportConfig export projects and hooks as one bundle
+func (sr *SystemRouter) ExportConfig(c *gin.Context) {
+	bundle := ConfigBundle{Projects: []json.RawMessage{}, Hooks: []webhook.Hook{}}
+	if types.GoHookVersionData != nil {
+		for _, project := range types.GoHookVersionData.Projects {
+			raw, err := projectToJSON(project)
+			if err != nil {
+				i18n.JSONErrorf(c, http.StatusInternalServerError, i18n.CodeExportProjectFailed, project.Name, err.Error())
+				return
+			}
+			bundle.Projects = append(bundle.Projects, raw)
+		}
+	}
+	if webhook.HookManager != nil {
+		bundle.Hooks = append(bundle.Hooks, webhook.HookManager.GetAllHooks()...)
+	}
+	c.JSON(http.StatusOK, bundle)
+}
+
+// ImportConfig import a bundle created by ExportConfig.
+// Projects and hooks are matched by name and id, ?mode=replace also removes entries missing from the bundle.
+func (sr *SystemRouter) ImportConfig(c *gin.Context) {
+	username := c.GetString("username")
+	replace := c.DefaultQuery("mode", "merge") == "replace"
+
+	var bundle ConfigBundle
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	projects := make([]types.ProjectConfig, 0, len(bundle.Projects))
+	for _, raw := range bundle.Projects {
+		project, err := projectFromJSON(raw)
+		if err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidBundleProject, err)
+			return
+		}
+		if project.Name == "" || project.Path == "" {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeProjectNamePathRequired, nil)
+			return
+		}
+		projects = append(projects, project)
+	}
+
+	result := gin.H{"mode": "merge", "projects": len(projects), "hooks": 0}
+	if replace {
+		result["mode"] = "replace"
+	}
+
+	// a command the hook handlers would refuse rejects the import before anything is saved
+	for _, h := range bundle.Hooks {
+		if webhook.DenyImportedCommand(c, webhook.CheckHookCommand(h), "import_config") {
+			return
+		}
+	}
+
+	if len(projects) > 0 || replace {
+		if types.GoHookVersionData == nil {
+			types.GoHookVersionData = &types.VersionConfig{}
+		}
+		types.GoHookVersionData.Projects = mergeProjects(types.GoHookVersionData.Projects, projects, replace)
+		if err := config.SaveVersionConfig(); err != nil {
+			i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
+			return
+		}
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "config updated successfully"})
+	if len(bundle.Hooks) > 0 || replace {
+		count, err := webhook.ImportHooks(bundle.Hooks, replace)
+		if err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeImportHooksFailed, err)
+			return
+		}
+		result["hooks"] = count
+	}
+
+	database.LogUserAction(
+		username,
+		database.UserActionImportConfig,
+		"system_config",
+		"import projects and hooks",
+		middleware.GetClientIP(c),
+		c.GetHeader("User-Agent"),
+		true,
+		result,
+	)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// mergeProjects upsert imported projects by name
+func mergeProjects(current, imported []types.ProjectConfig, replace bool) []types.ProjectConfig {
+	if replace {
+		return imported
+	}
+	merged := append([]types.ProjectConfig{}, current...)
+	for _, project := range imported {
+		found := false
+		for i := range merged {
+			if merged[i].Name == project.Name {
+				merged[i] = project
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, project)
+		}
+	}
+	return merged
+}
+
+// projectToJSON encode a project with its version.yaml field names
+func projectToJSON(project types.ProjectConfig) (json.RawMessage, error) {
+	data, err := yamlv2.Marshal(project)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.YAMLToJSON(data)
+}
+
+// projectFromJSON decode a project encoded by projectToJSON
+func projectFromJSON(raw json.RawMessage) (types.ProjectConfig, error) {
+	var project types.ProjectConfig
+	data, err := yaml.JSONToYAML(raw)
+	if err != nil {
+		return project, err
+	}
+	err = yamlv2.Unmarshal(data, &project)
+	return project, err
 }
@@ -0,0 +1,197 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/version"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+// TrashItemResponse trash item with the snapshot of the deleted hook or project
+type TrashItemResponse struct {
+	database.TrashItem
+	Snapshot json.RawMessage `json:"snapshot,omitempty"`
+}
+
+// PurgeTrashResponse result of purging trash items
+type PurgeTrashResponse struct {
+	Purged int64 `json:"purged"`
+}
+
+func logTrashAction(c *gin.Context, action string, item *database.TrashItem, description string, success bool, details map[string]interface{}) {
+	details["trashId"] = item.ID
+	details["kind"] = item.Kind
+	details["name"] = item.Name
+	database.LogUserAction(
+		c.GetString("username"),
+		action,
+		item.Kind+":"+item.Name,
+		description,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		success,
+		details,
+	)
+}
+
+func trashError(c *gin.Context, err error) {
+	if errors.Is(err, database.ErrTrashUnavailable) {
+		i18n.JSONError(c, http.StatusServiceUnavailable, i18n.CodeTrashUnavailable, err)
+		return
+	}
+	i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadTrashFailed, err)
+}
+
+// trashItemFor trash item named by the :id parameter, nil after the error response was written
+func trashItemFor(c *gin.Context) *database.TrashItem {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidTrashItemID, nil)
+		return nil
+	}
+	item, err := database.GetTrashItem(uint(id))
+	if err != nil {
+		trashError(c, err)
+		return nil
+	}
+	if item == nil || !namespace.Allowed(c, item.Namespace) {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeTrashItemNotFound, nil)
+		return nil
+	}
+	return item
+}
+
+func trashKind(c *gin.Context) (string, bool) {
+	kind := c.Query("kind")
+	switch kind {
+	case "", database.TrashKindHook, database.TrashKindProject:
+		return kind, true
+	}
+	i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidTrashKind, nil)
+	return "", false
+}
+
+// HandleListTrash list deleted hooks and projects that can still be restored, ?kind=hook|project
+func HandleListTrash(c *gin.Context) {
+	kind, ok := trashKind(c)
+	if !ok {
+		return
+	}
+	items, err := database.ListTrash(kind, namespace.FromContext(c))
+	if err != nil {
+		trashError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// HandleGetTrashItem get a trash item with the snapshot of the deleted hook or project
+func HandleGetTrashItem(c *gin.Context) {
+	item := trashItemFor(c)
+	if item == nil {
+		return
+	}
+	c.JSON(http.StatusOK, TrashItemResponse{TrashItem: *item, Snapshot: json.RawMessage(item.Snapshot)})
+}
+
+// HandleRestoreTrashItem put a deleted hook or project back and remove it from the trash
+func HandleRestoreTrashItem(c *gin.Context) {
+	item := trashItemFor(c)
+	if item == nil {
+		return
+	}
+
+	details := map[string]interface{}{}
+	var err error
+	switch item.Kind {
+	case database.TrashKindHook:
+		var hook webhook.Hook
+		if err = json.Unmarshal([]byte(item.Snapshot), &hook); err == nil {
+			var file string
+			file, err = webhook.RestoreHook(hook, item.Source)
+			details["filePath"] = file
+		}
+	case database.TrashKindProject:
+		var project types.ProjectConfig
+		if err = json.Unmarshal([]byte(item.Snapshot), &project); err == nil {
+			err = version.RestoreProject(project)
+			details["path"] = project.Path
+		}
+	default:
+		i18n.JSONErrorf(c, http.StatusInternalServerError, i18n.CodeUnknownTrashKind, item.Kind)
+		return
+	}
+	if err != nil {
+		details["error"] = err.Error()
+		logTrashAction(c, database.UserActionRestoreTrash, item, "restore "+item.Kind+": "+item.Name, false, details)
+		if webhook.DenyImportedCommand(c, err, "restore_trash") {
+			return
+		}
+		status := http.StatusInternalServerError
+		if errors.Is(err, webhook.ErrHookExists) || errors.Is(err, version.ErrProjectExists) {
+			status = http.StatusConflict
+		}
+		i18n.JSONError(c, status, i18n.CodeRestoreTrashFailed, err)
+		return
+	}
+
+	if _, err := database.PurgeTrashItems([]uint{item.ID}); err != nil {
+		details["purgeError"] = err.Error()
+	}
+	logTrashAction(c, database.UserActionRestoreTrash, item, "restore "+item.Kind+": "+item.Name, true, details)
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeTrashItemRestored),
+		"code":    i18n.CodeTrashItemRestored,
+		"kind":    item.Kind,
+		"name":    item.Name,
+	})
+}
+
+// HandlePurgeTrashItem permanently delete one trash item
+func HandlePurgeTrashItem(c *gin.Context) {
+	item := trashItemFor(c)
+	if item == nil {
+		return
+	}
+	n, err := database.PurgeTrashItems([]uint{item.ID})
+	if err != nil {
+		trashError(c, err)
+		return
+	}
+	logTrashAction(c, database.UserActionPurgeTrash, item, "purge "+item.Kind+": "+item.Name, true, map[string]interface{}{})
+	c.JSON(http.StatusOK, PurgeTrashResponse{Purged: n})
+}
+
+// HandleEmptyTrash permanently delete every trash item visible to the request, ?kind=hook|project
+func HandleEmptyTrash(c *gin.Context) {
+	kind, ok := trashKind(c)
+	if !ok {
+		return
+	}
+	items, err := database.ListTrash(kind, namespace.FromContext(c))
+	if err != nil {
+		trashError(c, err)
+		return
+	}
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	n, err := database.PurgeTrashItems(ids)
+	if err != nil {
+		trashError(c, err)
+		return
+	}
+	database.LogUserAction(c.GetString("username"), database.UserActionPurgeTrash, "trash", "empty trash",
+		middleware.GetClientIP(c), c.Request.UserAgent(), true, map[string]interface{}{"kind": kind, "purged": n, "trashIds": ids})
+	c.JSON(http.StatusOK, PurgeTrashResponse{Purged: n})
+}
@@ -0,0 +1,198 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func wrap(cmd *exec.Cmd, profile string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("sandbox: %w", err)
+	}
+	dir := cmd.Dir
+	if dir != "" {
+		if dir, err = filepath.Abs(dir); err != nil {
+			return fmt.Errorf("sandbox: %w", err)
+		}
+	}
+
+	// helper arguments: profile, writable dir, command path, command argv
+	cmd.Args = append([]string{initArg, profile, dir, cmd.Path}, cmd.Args...)
+	cmd.Path = self
+
+	attr := &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWNET | syscall.CLONE_NEWNS,
+		Pdeathsig:  syscall.SIGKILL,
+	}
+	if uid, gid := os.Geteuid(), os.Getegid(); uid != 0 {
+		// unprivileged: a user namespace grants the rights to create the other namespaces
+		attr.Cloneflags |= syscall.CLONE_NEWUSER
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+		attr.GidMappingsEnableSetgroups = false
+		// kept across the exec of the helper for its mounts, cleared before the command runs
+		attr.AmbientCaps = []uintptr{unix.CAP_SYS_ADMIN}
+	}
+	cmd.SysProcAttr = attr
+	return nil
+}
+
+func initSandbox(args []string) error {
+	if len(args) < 4 {
+		return errors.New("missing helper arguments")
+	}
+	profile, dir, path, argv := args[0], args[1], args[2], args[3:]
+
+	// the seccomp filter is installed on this thread and inherited by exec
+	runtime.LockOSThread()
+
+	if profile == ProfileStrict {
+		if err := readOnlyFilesystem(dir); err != nil {
+			return fmt.Errorf("read-only filesystem: %w", err)
+		}
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("clear capabilities: %w", err)
+	}
+	if err := installSeccomp(); err != nil {
+		return fmt.Errorf("seccomp: %w", err)
+	}
+	return syscall.Exec(path, argv, os.Environ())
+}
+
+// readOnlyFilesystem remount every mount read-only except dir and a fresh /tmp
+func readOnlyFilesystem(dir string) error {
+	// keep mount changes inside this namespace
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make / private: %w", err)
+	}
+	if dir != "" {
+		// a bind mount of dir on itself is not affected by remounting its parent
+		if err := unix.Mount(dir, dir, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind %s: %w", dir, err)
+		}
+	}
+
+	mounts, err := mountPoints()
+	if err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		if dir != "" && within(m.point, dir) {
+			continue
+		}
+		err := unix.Mount("", m.point, "", m.flags|unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY, "")
+		if err != nil && !errors.Is(err, unix.ENOENT) {
+			return fmt.Errorf("remount %s: %w", m.point, err)
+		}
+	}
+
+	// a working directory below /tmp is hidden by the new /tmp, keep a handle to bind it back
+	keep := -1
+	if dir != "" && within(dir, "/tmp") {
+		if keep, err = unix.Open(dir, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0); err != nil {
+			return err
+		}
+		defer unix.Close(keep)
+	}
+	if err := unix.Mount("tmpfs", "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777,size=64m"); err != nil {
+		return fmt.Errorf("mount /tmp: %w", err)
+	}
+	if keep >= 0 {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+		if err := unix.Mount("/proc/self/fd/"+strconv.Itoa(keep), dir, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind %s: %w", dir, err)
+		}
+	}
+	if dir != "" {
+		// the working directory was entered before dir was mounted over
+		return os.Chdir(dir)
+	}
+	return nil
+}
+
+type mountPoint struct {
+	point string
+	flags uintptr
+}
+
+// mountOptionFlags per-mount options that have to be kept when remounting
+var mountOptionFlags = map[string]uintptr{
+	"nosuid":      unix.MS_NOSUID,
+	"nodev":       unix.MS_NODEV,
+	"noexec":      unix.MS_NOEXEC,
+	"noatime":     unix.MS_NOATIME,
+	"nodiratime":  unix.MS_NODIRATIME,
+	"relatime":    unix.MS_RELATIME,
+	"strictatime": unix.MS_STRICTATIME,
+}
+
+// mountPoints mounts of this namespace from /proc/self/mountinfo, parents first
+func mountPoints() ([]mountPoint, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []mountPoint
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// id parent major:minor root mount-point options ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		m := mountPoint{point: unescapeMountPath(fields[4])}
+		for _, opt := range strings.Split(fields[5], ",") {
+			m.flags |= mountOptionFlags[opt]
+		}
+		mounts = append(mounts, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(mounts, func(i, j int) bool { return len(mounts[i].point) < len(mounts[j].point) })
+	return mounts, nil
+}
+
+// unescapeMountPath decode the octal escapes (\040 for space) of mountinfo paths
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// within reports whether path is root or lies below it
+func within(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
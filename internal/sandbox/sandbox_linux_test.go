@@ -0,0 +1,84 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// the test binary is the sandbox helper of the commands it starts
+	Init()
+	os.Exit(m.Run())
+}
+
+func runSandboxed(t *testing.T, profile, dir, script string) (string, error) {
+	t.Helper()
+	cmd := exec.Command("/bin/sh", "-c", script)
+	cmd.Dir = dir
+	if err := Wrap(cmd, profile); err != nil {
+		t.Fatalf("Wrap: %v", err)
+	}
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestSandbox(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("/bin/sh not available")
+	}
+	dir := t.TempDir()
+	if out, err := runSandboxed(t, ProfileStandard, dir, "true"); err != nil {
+		t.Skipf("namespaces not available: %v %s", err, out)
+	}
+	outside := t.TempDir()
+	wd, _ := os.Getwd()
+	probe := filepath.Join(wd, ".sandbox-probe")
+
+	tests := []struct {
+		name    string
+		profile string
+		script  string
+		wantErr bool
+	}{
+		{"standard runs commands", ProfileStandard, "echo ok", false},
+		{"standard has no network", ProfileStandard, "! grep -qv -E 'lo:|Inter|face' /proc/net/dev", false},
+		{"standard blocks unshare", ProfileStandard, "unshare -n true", true},
+		{"standard can write anywhere", ProfileStandard, "touch " + filepath.Join(outside, "a"), false},
+		{"strict writes working dir", ProfileStrict, "touch ./file && test -f " + filepath.Join(dir, "file"), false},
+		{"strict writes /tmp", ProfileStrict, "touch /tmp/gohook-sandbox-test", false},
+		{"strict blocks other dirs", ProfileStrict, "touch " + probe, true},
+		{"strict blocks remount", ProfileStrict, "mount -o remount,rw /", true},
+	}
+	for _, tt := range tests {
+		if tt.name == "standard blocks unshare" {
+			if _, err := exec.LookPath("unshare"); err != nil {
+				continue
+			}
+		}
+		out, err := runSandboxed(t, tt.profile, dir, tt.script)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v, output %s", tt.name, err, tt.wantErr, strings.TrimSpace(out))
+		}
+	}
+	if _, err := os.Stat(probe); err == nil {
+		os.Remove(probe)
+		t.Errorf("strict filesystem is writable")
+	}
+	if _, err := os.Stat("/tmp/gohook-sandbox-test"); err == nil {
+		os.Remove("/tmp/gohook-sandbox-test")
+		t.Errorf("strict /tmp is not private")
+	}
+}
+
+func TestValid(t *testing.T) {
+	for profile, want := range map[string]bool{"": true, ProfileNone: true, ProfileStandard: true, ProfileStrict: true, "paranoid": false} {
+		if got := Valid(profile); got != want {
+			t.Errorf("Valid(%q) = %v, want %v", profile, got, want)
+		}
+	}
+}
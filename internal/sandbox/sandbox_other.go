@@ -0,0 +1,17 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+)
+
+func wrap(_ *exec.Cmd, _ string) error {
+	return errors.New("sandbox profiles are not supported on " + runtime.GOOS)
+}
+
+func initSandbox(_ []string) error {
+	return errors.New("sandbox profiles are not supported on " + runtime.GOOS)
+}
@@ -0,0 +1,12 @@
+//go:build linux && !amd64 && !arm64
+
+package sandbox
+
+import (
+	"errors"
+	"runtime"
+)
+
+func installSeccomp() error {
+	return errors.New("seccomp filter not available on " + runtime.GOARCH)
+}
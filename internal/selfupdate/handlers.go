@@ -0,0 +1,72 @@
+package selfupdate
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+)
+
+// ApplyResponse installed release and whether the server restarts on it now
+type ApplyResponse struct {
+	Result
+	Restarting bool `json:"restarting"`
+}
+
+// HandleGetStatus result of the last release check, ?refresh=true checks GitHub now
+func HandleGetStatus(c *gin.Context) {
+	if refresh, _ := strconv.ParseBool(c.Query("refresh")); refresh {
+		status, err := Check(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": i18n.Message(c, i18n.CodeUpdateCheckFailed) + ": " + err.Error(), "code": i18n.CodeUpdateCheckFailed, "status": status})
+			return
+		}
+		c.JSON(http.StatusOK, status)
+		return
+	}
+	c.JSON(http.StatusOK, GetStatus())
+}
+
+// HandleApply download, verify and install the latest release, then restart gracefully on
+// it; ?restart=false only installs the binary for a restart by the service manager
+func HandleApply(c *gin.Context) {
+	restartNow := true
+	if v := c.Query("restart"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRestartParam, nil)
+			return
+		}
+		restartNow = parsed
+	}
+
+	res, err := Apply(c.Request.Context())
+	details := gin.H{"from": Current, "restart": restartNow}
+	if err != nil {
+		details["error"] = err.Error()
+	} else {
+		details["to"] = res.Version
+		details["sha256"] = res.SHA256
+		details["signed"] = res.Signed
+	}
+	database.LogUserAction(c.GetString("username"), database.UserActionSelfUpdate, "gohook",
+		"update gohook to the latest release", middleware.GetClientIP(c), c.GetHeader("User-Agent"), err == nil, details)
+	switch {
+	case errors.Is(err, ErrUpToDate), errors.Is(err, ErrInProgress):
+		i18n.JSONError(c, http.StatusConflict, i18n.CodeUpdateNotStarted, err)
+		return
+	case err != nil:
+		i18n.JSONError(c, http.StatusBadGateway, i18n.CodeUpdateFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, ApplyResponse{Result: *res, Restarting: restartNow})
+	if restartNow {
+		// the graceful shutdown lets this response finish first
+		go Restart(res.Executable)
+	}
+}
@@ -0,0 +1,623 @@
+// Package selfupdate checks GitHub for newer releases of the server binary and, when an admin
+// asks for it, replaces the running binary with the release archive of this platform. The
+// archive must match checksums.txt of the release, whose signature is verified as well when
+// a public key is configured; the server then restarts gracefully on the new binary.
+package selfupdate
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mycoool/gohook/internal/stream"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+const (
+	defaultRepository = "mycoool/gohook"
+	defaultAPIURL     = "https://api.github.com"
+	defaultInterval   = 24 * time.Hour
+	minInterval       = time.Hour
+
+	// release assets next to the archives of the platforms
+	checksumsAsset = "checksums.txt"
+	signatureAsset = "checksums.txt.sig"
+
+	apiTimeout      = 30 * time.Second
+	downloadTimeout = 10 * time.Minute
+	versionTimeout  = 10 * time.Second
+	maxArchiveSize  = 256 << 20
+	maxMetadataSize = 1 << 20 // release JSON, checksums and signature
+)
+
+var (
+	// ErrUpToDate no release newer than the running binary
+	ErrUpToDate = errors.New("already running the latest release")
+	// ErrInProgress another update is downloading or installing
+	ErrInProgress = errors.New("an update is already in progress")
+)
+
+// Current version of the running binary, set by main
+var Current = "unknown"
+
+var repositoryPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+// Status result of the last release check
+type Status struct {
+	Current     string     `json:"current"`
+	Latest      string     `json:"latest,omitempty"`
+	Available   bool       `json:"available"`             // Latest is newer than Current
+	URL         string     `json:"url,omitempty"`         // release page
+	PublishedAt *time.Time `json:"publishedAt,omitempty"` // of the latest release
+	Notes       string     `json:"notes,omitempty"`       // release notes
+	Asset       string     `json:"asset"`                 // archive of this platform
+	Installable bool       `json:"installable"`           // the release has the archive and checksums.txt
+	CheckedAt   *time.Time `json:"checkedAt,omitempty"`
+	Error       string     `json:"error,omitempty"`     // of the last check
+	Check       bool       `json:"check"`               // periodic checks enabled
+	Signed      bool       `json:"signed"`              // a public key is configured, the signature is required
+	Installed   string     `json:"installed,omitempty"` // release installed, the restart is pending
+}
+
+// Result release installed by Apply
+type Result struct {
+	Previous   string `json:"previous"`
+	Version    string `json:"version"`
+	Executable string `json:"executable"`
+	Backup     string `json:"backup"` // previous binary, kept for a manual rollback
+	SHA256     string `json:"sha256"` // of the release archive
+	Signed     bool   `json:"signed"` // the signature of checksums.txt was verified
+}
+
+// release GitHub release of the repository
+type release struct {
+	TagName     string    `json:"tag_name"`
+	HTMLURL     string    `json:"html_url"`
+	Body        string    `json:"body"`
+	PublishedAt time.Time `json:"published_at"`
+	Assets      []asset   `json:"assets"`
+}
+
+type asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+func (r *release) asset(name string) *asset {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i]
+		}
+	}
+	return nil
+}
+
+func (r *release) version() string {
+	return strings.TrimPrefix(r.TagName, "v")
+}
+
+var (
+	// applyMu serializes updates, stateMu guards state
+	applyMu sync.Mutex
+	stateMu sync.RWMutex
+	state   Status
+	// announced latest release broadcast as available
+	announced string
+
+	restart func(exe string)
+
+	// executable path of the running binary, replaced by tests
+	executable = func() (string, error) {
+		exe, err := os.Executable()
+		if err != nil {
+			return "", err
+		}
+		return filepath.EvalSymlinks(exe)
+	}
+
+	client = &http.Client{}
+)
+
+// SetRestart install the function restarting the server on the binary at exe
+func SetRestart(fn func(exe string)) {
+	restart = fn
+}
+
+// Restart restart the server on the updated binary
+func Restart(exe string) {
+	if restart == nil {
+		log.Printf("update: installed %s, restart gohook to run it", exe)
+		return
+	}
+	restart(exe)
+}
+
+// settings update settings of app.yaml with the defaults filled in
+func settings() types.UpdateConfig {
+	var cfg types.UpdateConfig
+	if types.GoHookAppConfig != nil && types.GoHookAppConfig.Update != nil {
+		cfg = *types.GoHookAppConfig.Update
+	}
+	if cfg.Repository == "" {
+		cfg.Repository = defaultRepository
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = defaultAPIURL
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultInterval
+	}
+	return cfg
+}
+
+// Validate check the update settings
+func Validate(cfg *types.UpdateConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Repository != "" && !repositoryPattern.MatchString(cfg.Repository) {
+		return fmt.Errorf("update repository must be owner/name, got %q", cfg.Repository)
+	}
+	if cfg.APIURL != "" {
+		u, err := url.Parse(cfg.APIURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("update api_url must be an http(s) URL")
+		}
+	}
+	if cfg.Interval != 0 && cfg.Interval < minInterval {
+		return fmt.Errorf("update interval must be at least %s", minInterval)
+	}
+	if _, err := parsePublicKey(cfg.PublicKey); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AssetName release archive of a platform, as packaged by make package-zip
+func AssetName(goos, goarch string) string {
+	name := "gohook-" + goos + "-" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name + ".zip"
+}
+
+// CompareVersions compare two versions such as 0.4.6 or v1.2.0-rc.1, a release ranks above
+// its pre-releases
+func CompareVersions(a, b string) int {
+	a, _, _ = strings.Cut(strings.TrimPrefix(a, "v"), "+")
+	b, _, _ = strings.Cut(strings.TrimPrefix(b, "v"), "+")
+	aCore, aPre, _ := strings.Cut(a, "-")
+	bCore, bPre, _ := strings.Cut(b, "-")
+	aParts, bParts := strings.Split(aCore, "."), strings.Split(bCore, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		x, y := versionPart(aParts, i), versionPart(bParts, i)
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	case aPre < bPre:
+		return -1
+	}
+	return 1
+}
+
+func versionPart(parts []string, i int) int {
+	if i >= len(parts) {
+		return 0
+	}
+	n, _ := strconv.Atoi(parts[i])
+	return n
+}
+
+// GetStatus result of the last check
+func GetStatus() Status {
+	cfg := settings()
+	stateMu.RLock()
+	s := state
+	stateMu.RUnlock()
+	s.Current = Current
+	s.Asset = AssetName(runtime.GOOS, runtime.GOARCH)
+	s.Check = cfg.Check
+	s.Signed = cfg.PublicKey != ""
+	return s
+}
+
+// Check look up the latest release, admins are notified once about each newer release
+func Check(ctx context.Context) (Status, error) {
+	cfg := settings()
+	rel, err := latestRelease(ctx, cfg)
+	now := time.Now()
+
+	stateMu.Lock()
+	state.CheckedAt = &now
+	if err != nil {
+		state.Error = err.Error()
+		stateMu.Unlock()
+		return GetStatus(), err
+	}
+	published := rel.PublishedAt
+	state.Error = ""
+	state.Latest = rel.version()
+	state.Available = CompareVersions(state.Latest, Current) > 0
+	state.URL = rel.HTMLURL
+	state.PublishedAt = &published
+	state.Notes = rel.Body
+	state.Installable = rel.asset(AssetName(runtime.GOOS, runtime.GOARCH)) != nil && rel.asset(checksumsAsset) != nil
+	notify := state.Available && state.Latest != announced && state.Latest != state.Installed
+	if notify {
+		announced = state.Latest
+	}
+	stateMu.Unlock()
+
+	if notify {
+		log.Printf("update: gohook %s is available (running %s)", rel.version(), Current)
+		stream.Global.Broadcast(stream.WsMessage{Type: "update_available", Timestamp: now,
+			Data: stream.UpdateAvailableMessage{Current: Current, Latest: rel.version(), URL: rel.HTMLURL}})
+	}
+	return GetStatus(), nil
+}
+
+// Schedule check for releases every interval when periodic checks are enabled. Each instance
+// checks for itself, as instances of a cluster may run different versions.
+func Schedule(ctx context.Context) {
+	if !settings().Check {
+		return
+	}
+	go func() {
+		for {
+			if cfg := settings(); cfg.Check {
+				if _, err := Check(ctx); err != nil {
+					log.Printf("update: check failed: %v", err)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(settings().Interval):
+			}
+		}
+	}()
+}
+
+// latestRelease latest published release of the repository, GitHub leaves out drafts and
+// pre-releases
+func latestRelease(ctx context.Context, cfg types.UpdateConfig) (*release, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeout)
+	defer cancel()
+	endpoint := strings.TrimSuffix(cfg.APIURL, "/") + "/repos/" + cfg.Repository + "/releases/latest"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	}
+	body, err := fetch(req, maxMetadataSize)
+	if err != nil {
+		return nil, fmt.Errorf("latest release of %s: %w", cfg.Repository, err)
+	}
+	var rel release
+	if err := json.Unmarshal(body, &rel); err != nil {
+		return nil, fmt.Errorf("latest release of %s: %w", cfg.Repository, err)
+	}
+	if rel.TagName == "" {
+		return nil, fmt.Errorf("latest release of %s has no tag", cfg.Repository)
+	}
+	return &rel, nil
+}
+
+// download fetch a release asset, the token is only sent to the API
+func download(ctx context.Context, a *asset, limit int64) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fetch(req, limit)
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", a.Name, err)
+	}
+	return data, nil
+}
+
+func fetch(req *http.Request, limit int64) ([]byte, error) {
+	req.Header.Set("User-Agent", "gohook/"+Current)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("larger than %d bytes", limit)
+	}
+	return data, nil
+}
+
+// Apply install the latest release in place of the running binary. The archive is verified
+// against checksums.txt (and its signature with a public key), the new binary must report
+// the release version, and the previous binary is kept as <binary>.old. The server keeps
+// running the old binary until Restart.
+func Apply(ctx context.Context) (*Result, error) {
+	if !applyMu.TryLock() {
+		return nil, ErrInProgress
+	}
+	defer applyMu.Unlock()
+
+	cfg := settings()
+	key, err := parsePublicKey(cfg.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := latestRelease(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	version := rel.version()
+	stateMu.RLock()
+	installed := state.Installed
+	stateMu.RUnlock()
+	if CompareVersions(version, Current) <= 0 || version == installed {
+		return nil, ErrUpToDate
+	}
+
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+	archive := rel.asset(name)
+	if archive == nil {
+		return nil, fmt.Errorf("release %s has no %s", rel.TagName, name)
+	}
+	sumsAsset := rel.asset(checksumsAsset)
+	if sumsAsset == nil {
+		return nil, fmt.Errorf("release %s has no %s, refusing to install an unverified binary", rel.TagName, checksumsAsset)
+	}
+	sums, err := download(ctx, sumsAsset, maxMetadataSize)
+	if err != nil {
+		return nil, err
+	}
+	if key != nil {
+		sigAsset := rel.asset(signatureAsset)
+		if sigAsset == nil {
+			return nil, fmt.Errorf("release %s has no %s but a public key is configured", rel.TagName, signatureAsset)
+		}
+		sig, err := download(ctx, sigAsset, maxMetadataSize)
+		if err != nil {
+			return nil, err
+		}
+		if err := verifySignature(key, sums, sig); err != nil {
+			return nil, err
+		}
+	}
+	want, err := checksumOf(sums, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := download(ctx, archive, maxArchiveSize)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("checksum mismatch of %s: got %s, want %s", name, got, want)
+	}
+	binary, err := extract(data, strings.TrimSuffix(name, ".zip"))
+	if err != nil {
+		return nil, err
+	}
+
+	exe, err := executable()
+	if err != nil {
+		return nil, fmt.Errorf("locate the running binary: %w", err)
+	}
+	if err := install(ctx, exe, binary, version); err != nil {
+		return nil, err
+	}
+
+	stateMu.Lock()
+	state.Installed = version
+	stateMu.Unlock()
+	log.Printf("update: installed gohook %s in place of %s at %s", version, Current, exe)
+	return &Result{Previous: Current, Version: version, Executable: exe, Backup: exe + ".old",
+		SHA256: want, Signed: key != nil}, nil
+}
+
+// parsePublicKey ed25519 public key in base64 or as a PEM PUBLIC KEY block, nil when empty
+func parsePublicKey(s string) (ed25519.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("update public_key: %w", err)
+		}
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("update public_key must be an ed25519 key")
+		}
+		return edKey, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("update public_key must be a base64 ed25519 key or a PEM public key")
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// verifySignature check the ed25519 signature of data, raw or base64 encoded
+func verifySignature(key ed25519.PublicKey, data, sig []byte) error {
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", signatureAsset, err)
+		}
+		sig = decoded
+	}
+	if len(sig) != ed25519.SignatureSize || !ed25519.Verify(key, data, sig) {
+		return fmt.Errorf("signature of %s does not verify with the configured public key", checksumsAsset)
+	}
+	return nil
+}
+
+// checksumOf sha256 of name in a sha256sum listing
+func checksumOf(sums []byte, name string) (string, error) {
+	for _, line := range strings.Split(string(sums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if path.Base(strings.TrimPrefix(fields[1], "*")) == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no checksum of %s", checksumsAsset, name)
+}
+
+// extract the binary from the release archive, the entry named name or the only file
+func extract(data []byte, name string) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("open release archive: %w", err)
+	}
+	var files []*zip.File
+	for _, f := range zr.File {
+		if f.FileInfo().Mode().IsRegular() {
+			files = append(files, f)
+		}
+	}
+	var entry *zip.File
+	for _, f := range files {
+		if path.Base(f.Name) == name {
+			entry = f
+			break
+		}
+	}
+	if entry == nil && len(files) == 1 {
+		entry = files[0]
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("release archive has no %s", name)
+	}
+	r, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	binary, err := io.ReadAll(io.LimitReader(r, maxArchiveSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(binary) > maxArchiveSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", name, maxArchiveSize)
+	}
+	return binary, nil
+}
+
+// install write the binary next to exe, check that it runs and reports version, then move
+// exe to exe.old and the new binary in its place
+func install(ctx context.Context, exe string, binary []byte, version string) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	tmp := exe + ".new"
+	if err := writeFile(tmp, binary, info.Mode().Perm()|0100); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write new binary: %w", err)
+	}
+	if err := checkBinary(ctx, tmp, version); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	backup := exe + ".old"
+	if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+		os.Remove(tmp)
+		return fmt.Errorf("remove previous backup: %w", err)
+	}
+	if err := os.Rename(exe, backup); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("back up the running binary: %w", err)
+	}
+	if err := os.Rename(tmp, exe); err != nil {
+		if rbErr := os.Rename(backup, exe); rbErr != nil {
+			log.Printf("update: restore %s from %s failed: %v", exe, backup, rbErr)
+		}
+		os.Remove(tmp)
+		return fmt.Errorf("replace the running binary: %w", err)
+	}
+	return nil
+}
+
+func writeFile(name string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// checkBinary run the binary with -version, it must report the release version
+func checkBinary(ctx context.Context, name, version string) error {
+	ctx, cancel := context.WithTimeout(ctx, versionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, "-version").Output()
+	if err != nil {
+		return fmt.Errorf("new binary does not run: %w", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "gohook version "+version {
+		return fmt.Errorf("new binary reports %q, want gohook version %s", got, version)
+	}
+	return nil
+}
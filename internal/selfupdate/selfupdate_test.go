@@ -0,0 +1,239 @@
+package selfupdate
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"0.4.6", "0.4.6", 0},
+		{"v0.5.0", "0.4.6", 1},
+		{"0.4.6", "0.4.10", -1},
+		{"1.0", "1.0.0", 0},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-rc.2", "1.0.0-rc.1", 1},
+		{"1.0.0+build.5", "1.0.0", 0},
+		{"0.0.1", "unknown", 1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		cfg     *types.UpdateConfig
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"defaults", &types.UpdateConfig{Check: true}, false},
+		{"fork with key", &types.UpdateConfig{Repository: "acme/gohook", PublicKey: base64.StdEncoding.EncodeToString(pub)}, false},
+		{"bad repository", &types.UpdateConfig{Repository: "gohook"}, true},
+		{"bad api url", &types.UpdateConfig{APIURL: "ftp://example.com"}, true},
+		{"short interval", &types.UpdateConfig{Interval: 5 * time.Minute}, true},
+		{"bad key", &types.UpdateConfig{PublicKey: "bm90IGEga2V5"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeRelease GitHub API and release assets of version serving the zipped binary
+type fakeRelease struct {
+	version   string
+	archive   []byte
+	checksums string
+	signature []byte // checksums.txt.sig, left out when nil
+}
+
+func newFakeRelease(t *testing.T, version string, binary []byte) *fakeRelease {
+	t.Helper()
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(strings.TrimSuffix(name, ".zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(binary); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return &fakeRelease{version: version, archive: buf.Bytes(),
+		checksums: hex.EncodeToString(sum[:]) + "  " + name + "\n" + strings.Repeat("0", 64) + "  gohook-plan9-386.zip\n"}
+}
+
+func (f *fakeRelease) serve(t *testing.T) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/mycoool/gohook/releases/latest":
+			assets := []asset{
+				{Name: AssetName(runtime.GOOS, runtime.GOARCH), URL: srv.URL + "/download/archive"},
+				{Name: checksumsAsset, URL: srv.URL + "/download/checksums"},
+			}
+			if f.signature != nil {
+				assets = append(assets, asset{Name: signatureAsset, URL: srv.URL + "/download/signature"})
+			}
+			_ = json.NewEncoder(w).Encode(release{TagName: "v" + f.version, HTMLURL: "https://github.com/mycoool/gohook/releases/v" + f.version, Assets: assets})
+		case "/download/archive":
+			_, _ = w.Write(f.archive)
+		case "/download/checksums":
+			_, _ = w.Write([]byte(f.checksums))
+		case "/download/signature":
+			_, _ = w.Write(f.signature)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// setup point the package at the fake release and a running "binary" in a temp dir
+func setup(t *testing.T, apiURL, publicKey string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake binaries are shell scripts")
+	}
+	exe := filepath.Join(t.TempDir(), "gohook")
+	if err := os.WriteFile(exe, []byte("#!/bin/sh\necho gohook version 0.4.6\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	savedApp, savedCurrent, savedExecutable := types.GoHookAppConfig, Current, executable
+	types.GoHookAppConfig = &types.AppConfig{Update: &types.UpdateConfig{APIURL: apiURL, PublicKey: publicKey}}
+	Current = "0.4.6"
+	executable = func() (string, error) { return exe, nil }
+	stateMu.Lock()
+	state = Status{}
+	stateMu.Unlock()
+	t.Cleanup(func() {
+		types.GoHookAppConfig, Current, executable = savedApp, savedCurrent, savedExecutable
+	})
+	return exe
+}
+
+func TestApply(t *testing.T) {
+	binary := []byte("#!/bin/sh\necho gohook version 0.5.0\n")
+	f := newFakeRelease(t, "0.5.0", binary)
+	exe := setup(t, f.serve(t).URL, "")
+
+	status, err := Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Available || status.Latest != "0.5.0" || !status.Installable {
+		t.Fatalf("status = %+v", status)
+	}
+
+	res, err := Apply(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Previous != "0.4.6" || res.Version != "0.5.0" || res.Backup != exe+".old" || res.Signed {
+		t.Errorf("result = %+v", res)
+	}
+	if data, _ := os.ReadFile(exe); !bytes.Equal(data, binary) {
+		t.Errorf("binary = %q, want the release", data)
+	}
+	if data, _ := os.ReadFile(exe + ".old"); !strings.Contains(string(data), "0.4.6") {
+		t.Errorf("backup = %q, want the previous binary", data)
+	}
+	if GetStatus().Installed != "0.5.0" {
+		t.Error("installed release not recorded")
+	}
+	if _, err := Apply(context.Background()); err != ErrUpToDate {
+		t.Errorf("second Apply = %v, want ErrUpToDate", err)
+	}
+}
+
+func TestApplyRejects(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(pub)
+	good := []byte("#!/bin/sh\necho gohook version 0.5.0\n")
+
+	tests := []struct {
+		name    string
+		key     string
+		prepare func(f *fakeRelease)
+		wantErr string
+	}{
+		{"checksum mismatch", "", func(f *fakeRelease) {
+			f.archive = append([]byte{}, f.archive...)
+			f.archive[len(f.archive)-1] ^= 0xff
+		}, "checksum mismatch"},
+		{"missing checksum", "", func(f *fakeRelease) { f.checksums = "" }, "has no checksum"},
+		{"missing signature", key, func(f *fakeRelease) {}, "has no checksums.txt.sig"},
+		{"wrong signature", key, func(f *fakeRelease) {
+			f.signature = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte("other"))))
+		}, "does not verify"},
+		{"wrong version", "", func(f *fakeRelease) {
+			*f = *newFakeRelease(t, "0.5.0", []byte("#!/bin/sh\necho gohook version 0.4.9\n"))
+		}, "reports"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeRelease(t, "0.5.0", good)
+			tt.prepare(f)
+			exe := setup(t, f.serve(t).URL, tt.key)
+			_, err := Apply(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Apply() = %v, want %q", err, tt.wantErr)
+			}
+			if data, _ := os.ReadFile(exe); !strings.Contains(string(data), "0.4.6") {
+				t.Errorf("binary replaced after a failed update: %q", data)
+			}
+			if _, err := os.Stat(exe + ".new"); !os.IsNotExist(err) {
+				t.Error("temporary binary left behind")
+			}
+		})
+	}
+
+	// a raw signature of checksums.txt is accepted as well as a base64 one
+	f := newFakeRelease(t, "0.5.0", good)
+	f.signature = ed25519.Sign(priv, []byte(f.checksums))
+	setup(t, f.serve(t).URL, key)
+	res, err := Apply(context.Background())
+	if err != nil || !res.Signed {
+		t.Fatalf("Apply() = %+v, %v", res, err)
+	}
+}
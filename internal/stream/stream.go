@@ -4,17 +4,23 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/redact"
 )
 
 // WebSocket connection manager
 type StreamManager struct {
 	clients    map[*websocket.Conn]*ClientInfo
 	clientsMux sync.RWMutex
+	relay      func(WsMessage)   // forwards broadcasts to other instances in HA mode
+	listeners  []func(WsMessage) // in-process observers of the messages broadcast by this instance
 }
 
 // Client connection info - for tracking connection status and heartbeat
@@ -23,6 +29,8 @@ type ClientInfo struct {
 	LastPing    time.Time
 	UserAgent   string
 	RemoteAddr  string
+	Namespace   string // namespace the connection is limited to, empty for all namespaces
+	DecryptLogs bool   // user may read hook logs encrypted at rest, and so sealed messages
 }
 
 // global WebSocket manager instance
@@ -30,11 +38,18 @@ var Global = &StreamManager{
 	clients: make(map[*websocket.Conn]*ClientInfo),
 }
 
+// ProtocolVersion version of the WebSocket message format, sent in every message. Clients may
+// ask for it with ?protocol= when connecting; a change of the message format raises it.
+const ProtocolVersion = 1
+
 // WebSocket message type
 type WsMessage struct {
 	Type      string      `json:"type"`
 	Timestamp time.Time   `json:"timestamp"`
 	Data      interface{} `json:"data"`
+	Version   int         `json:"version"`             // ProtocolVersion when left zero
+	Namespace string      `json:"namespace,omitempty"` // namespace of the hook or project, empty for every client
+	Sealed    bool        `json:"sealed,omitempty"`    // output and error of the data only go to clients allowed to decrypt hook logs
 }
 
 // hook triggered message
@@ -46,6 +61,16 @@ type HookTriggeredMessage struct {
 	Success    bool   `json:"success"`
 	Output     string `json:"output,omitempty"`
 	Error      string `json:"error,omitempty"`
+	LogID      uint   `json:"logId,omitempty"`
+}
+
+// promotion waiting for approval message
+type PromotionRequestMessage struct {
+	ID            uint   `json:"id"`
+	SourceProject string `json:"sourceProject"`
+	TargetProject string `json:"targetProject"`
+	Ref           string `json:"ref"`
+	RequestedBy   string `json:"requestedBy"`
 }
 
 // hook manage message
@@ -68,7 +93,7 @@ type VersionSwitchMessage struct {
 
 // project manage message
 type ProjectManageMessage struct {
-	Action      string `json:"action"` // "add" | "delete" | "edit"
+	Action      string `json:"action"` // "add" | "delete" | "edit" | "rename" | "pin" | "unpin"
 	ProjectName string `json:"projectName"`
 	ProjectPath string `json:"projectPath,omitempty"`
 	Success     bool   `json:"success"`
@@ -86,6 +111,33 @@ type GitHookTriggeredMessage struct {
 	Message     string `json:"message,omitempty"` // detailed message
 }
 
+// secret rotation message
+type SecretRotationMessage struct {
+	Kind    string    `json:"kind"`   // "hook" | "project"
+	Name    string    `json:"name"`   // hook id or project name
+	Action  string    `json:"action"` // "rotated" | "retired"
+	By      string    `json:"by,omitempty"`
+	Expires time.Time `json:"expires,omitempty"` // end of the grace period of the previous secret
+}
+
+// hook budget message
+type HookBudgetMessage struct {
+	HookID            string     `json:"hookId"`
+	Event             string     `json:"event"` // "failures" | "duration" | "circuit-open" | "circuit-closed"
+	Failures          int        `json:"failures,omitempty"`
+	AverageDurationMs int64      `json:"averageDurationMs,omitempty"`
+	Limit             string     `json:"limit,omitempty"` // threshold reached, e.g. "5 failures per hour"
+	Until             *time.Time `json:"until,omitempty"` // end of the cooldown of an open circuit breaker
+	By                string     `json:"by,omitempty"`    // user or "cooldown" closing the breaker
+}
+
+// update available message, a newer release of gohook was published
+type UpdateAvailableMessage struct {
+	Current string `json:"current"`
+	Latest  string `json:"latest"`
+	URL     string `json:"url,omitempty"` // release page
+}
+
 // WebSocket upgrader
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
@@ -95,15 +147,69 @@ var upgrader = websocket.Upgrader{
 }
 
 // add WebSocket connection with client tracking
-func (m *StreamManager) AddClient(conn *websocket.Conn, userAgent, remoteAddr string) {
+func (m *StreamManager) AddClient(conn *websocket.Conn, info ClientInfo) {
 	m.clientsMux.Lock()
 	defer m.clientsMux.Unlock()
-	m.clients[conn] = &ClientInfo{
-		ConnectedAt: time.Now(),
-		LastPing:    time.Now(),
-		UserAgent:   userAgent,
-		RemoteAddr:  remoteAddr,
+	info.ConnectedAt = time.Now()
+	info.LastPing = time.Now()
+	m.clients[conn] = &info
+}
+
+// receives whether the client gets message: messages of a namespace only go to the clients
+// of that namespace and to those not limited to one
+func (ci *ClientInfo) receives(message WsMessage) bool {
+	return message.Namespace == "" || ci.Namespace == "" || ci.Namespace == message.Namespace
+}
+
+// sealedFields fields of a sealed message held back from clients not allowed to decrypt hook logs
+var sealedFields = []string{"output", "error"}
+
+// withoutSealed encoding of message without the sealed fields of its data
+func withoutSealed(message WsMessage) ([]byte, error) {
+	raw, err := json.Marshal(message.Data)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	for _, field := range sealedFields {
+		delete(fields, field)
+	}
+	message.Data = fields
+	return json.Marshal(message)
+}
+
+// namespaceOf namespace of the hook or project a message is about, empty when the message
+// concerns no single one or it no longer exists
+func namespaceOf(data interface{}) string {
+	kind, name := "", ""
+	switch d := data.(type) {
+	case HookTriggeredMessage:
+		kind, name = namespace.KindHook, d.HookID
+	case HookManageMessage:
+		kind, name = namespace.KindHook, d.HookID
+	case HookBudgetMessage:
+		kind, name = namespace.KindHook, d.HookID
+	case VersionSwitchMessage:
+		kind, name = namespace.KindProject, d.ProjectName
+	case ProjectManageMessage:
+		kind, name = namespace.KindProject, d.ProjectName
+	case GitHookTriggeredMessage:
+		kind, name = namespace.KindProject, d.ProjectName
+	case PromotionRequestMessage:
+		kind, name = namespace.KindProject, d.TargetProject
+	case SecretRotationMessage:
+		kind, name = d.Kind, d.Name
+	default:
+		return ""
+	}
+	ns, ok := namespace.Lookup(kind, name)
+	if !ok {
+		return ""
 	}
+	return namespace.Normalize(ns)
 }
 
 // remove WebSocket connection safely
@@ -142,22 +248,74 @@ func (m *StreamManager) GetStaleConnections() []*websocket.Conn {
 	return staleConns
 }
 
-// broadcast message to all connected clients
-// fix race condition: collect dead connections first, then batch clean up
+// set the function receiving every message broadcast by this instance, nil disables relaying
+func (m *StreamManager) SetRelay(relay func(WsMessage)) {
+	m.clientsMux.Lock()
+	defer m.clientsMux.Unlock()
+	m.relay = relay
+}
+
+// add a function called with every message broadcast by this instance, messages relayed
+// from other instances are not passed to it. Listeners must not block.
+func (m *StreamManager) AddListener(listener func(WsMessage)) {
+	m.clientsMux.Lock()
+	defer m.clientsMux.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+// broadcast message to all connected clients of every instance, secrets in its data redacted.
+// A message about a hook or project only reaches the clients allowed to see its namespace.
 func (m *StreamManager) Broadcast(message WsMessage) {
+	if message.Namespace == "" {
+		message.Namespace = namespaceOf(message.Data)
+	}
+	message.Data = redact.Value(message.Data)
+	m.BroadcastLocal(message)
+
+	m.clientsMux.RLock()
+	relay, listeners := m.relay, m.listeners
+	m.clientsMux.RUnlock()
+	if relay != nil {
+		relay(message)
+	}
+	for _, listener := range listeners {
+		listener(message)
+	}
+}
+
+// broadcast message to the clients connected to this instance only
+// fix race condition: collect dead connections first, then batch clean up
+func (m *StreamManager) BroadcastLocal(message WsMessage) {
+	if message.Version == 0 {
+		message.Version = ProtocolVersion
+	}
 	data, err := json.Marshal(message)
 	if err != nil {
 		log.Printf("Failed to marshal WebSocket message: %v", err)
 		return
 	}
+	masked := data
+	if message.Sealed {
+		if masked, err = withoutSealed(message); err != nil {
+			log.Printf("Failed to marshal WebSocket message: %v", err)
+			return
+		}
+	}
 
 	// collect connections to delete, avoid modifying map during read lock
 	var deadConnections []*websocket.Conn
 
 	// get read lock for iteration
 	m.clientsMux.RLock()
-	for client := range m.clients {
-		if err := client.WriteMessage(websocket.TextMessage, data); err != nil {
+	for client, info := range m.clients {
+		if !info.receives(message) {
+			continue
+		}
+		payload := data
+		if !info.DecryptLogs {
+			payload = masked
+		}
+		if err := client.WriteMessage(websocket.TextMessage, payload); err != nil {
 			// collect connections to delete, not delete immediately
 			deadConnections = append(deadConnections, client)
 		}
@@ -203,9 +361,13 @@ func (m *StreamManager) StartCleanup() {
 
 // handle WebSocket connection
 func HandleWebSocket(c *gin.Context) {
+	if v := c.Query("protocol"); v != "" && v != strconv.Itoa(ProtocolVersion) {
+		i18n.JSONErrorf(c, http.StatusBadRequest, i18n.CodeUnsupportedProtocol, v, ProtocolVersion)
+		return
+	}
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "WebSocket upgrade failed"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeWebSocketUpgradeFailed, err)
 		return
 	}
 
@@ -216,9 +378,13 @@ func HandleWebSocket(c *gin.Context) {
 	}()
 
 	// add connection to manager, include client info
-	userAgent := c.GetHeader("User-Agent")
 	remoteAddr := c.ClientIP()
-	Global.AddClient(conn, userAgent, remoteAddr)
+	Global.AddClient(conn, ClientInfo{
+		UserAgent:   c.GetHeader("User-Agent"),
+		RemoteAddr:  remoteAddr,
+		Namespace:   namespace.FromContext(c),
+		DecryptLogs: c.GetBool("decrypt_logs"),
+	})
 	log.Printf("WebSocket client connected from %s, total clients: %d", remoteAddr, Global.ClientCount())
 
 	// set connection timeout, prevent dead connection
@@ -236,6 +402,7 @@ func HandleWebSocket(c *gin.Context) {
 		Type:      "connected",
 		Timestamp: time.Now(),
 		Data:      map[string]string{"message": "WebSocket connected successfully"},
+		Version:   ProtocolVersion,
 	}
 	if connectedData, err := json.Marshal(connectedMsg); err == nil {
 		if err := conn.WriteMessage(websocket.TextMessage, connectedData); err != nil {
@@ -273,6 +440,7 @@ func HandleWebSocket(c *gin.Context) {
 					Type:      "pong",
 					Timestamp: time.Now(),
 					Data:      map[string]string{"message": "pong"},
+					Version:   ProtocolVersion,
 				}
 
 				if pongData, err := json.Marshal(pongMsg); err == nil {
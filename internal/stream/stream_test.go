@@ -0,0 +1,153 @@
+package stream
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mycoool/gohook/internal/namespace"
+)
+
+// connect a client to server with the query parameters of query
+func connect(t *testing.T, server *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?"+query, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// next the next message the client receives
+func next(t *testing.T, conn *websocket.Conn) WsMessage {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var msg WsMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	return msg
+}
+
+// newServer server registering its WebSocket connections with the returned manager, the ns
+// and decrypt query parameters set the namespace and log permission of a client
+func newServer() (*StreamManager, *httptest.Server) {
+	m := &StreamManager{clients: make(map[*websocket.Conn]*ClientInfo)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		m.AddClient(conn, ClientInfo{Namespace: r.URL.Query().Get("ns"), DecryptLogs: r.URL.Query().Get("decrypt") != ""})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				m.RemoveClient(conn)
+				return
+			}
+		}
+	}))
+	return m, server
+}
+
+// waitClients wait until n clients are registered with m
+func waitClients(t *testing.T, m *StreamManager, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); m.ClientCount() < n; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d clients connected, want %d", m.ClientCount(), n)
+		}
+	}
+}
+
+func TestBroadcastNamespaces(t *testing.T) {
+	namespace.Register(namespace.KindHook, namespace.Resource{
+		Lookup: func(id string) (string, bool) {
+			ns, ok := map[string]string{"build": "team-a", "legacy": ""}[id]
+			return ns, ok
+		},
+	})
+	defer namespace.Register(namespace.KindHook, namespace.Resource{})
+
+	m, server := newServer()
+	defer server.Close()
+
+	teamA := connect(t, server, "ns=team-a")
+	teamB := connect(t, server, "ns=team-b")
+	defaultNs := connect(t, server, "ns=default")
+	admin := connect(t, server, "")
+	waitClients(t, m, 4)
+
+	m.Broadcast(WsMessage{Type: "hook_triggered", Data: HookTriggeredMessage{HookID: "build", Output: "secret build output"}})
+	m.Broadcast(WsMessage{Type: "hook_triggered", Data: HookTriggeredMessage{HookID: "legacy"}})
+	m.Broadcast(WsMessage{Type: "hook_managed", Data: HookManageMessage{Action: "delete", HookID: "gone"}, Namespace: "team-b"})
+	m.Broadcast(WsMessage{Type: "update_available", Data: UpdateAvailableMessage{Latest: "9.9.9"}})
+
+	tests := []struct {
+		name string
+		conn *websocket.Conn
+		want []string // namespaces of the messages received, in order
+	}{
+		{"team-a", teamA, []string{"team-a", ""}},
+		{"team-b", teamB, []string{"team-b", ""}},
+		{"default", defaultNs, []string{"default", ""}},
+		{"unrestricted", admin, []string{"team-a", "default", "team-b", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, want := range tt.want {
+				if msg := next(t, tt.conn); msg.Namespace != want {
+					t.Errorf("got a %s message of namespace %q, want namespace %q", msg.Type, msg.Namespace, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBroadcastSealed(t *testing.T) {
+	m, server := newServer()
+	defer server.Close()
+
+	permitted := connect(t, server, "decrypt=1")
+	other := connect(t, server, "")
+	waitClients(t, m, 2)
+
+	m.Broadcast(WsMessage{Type: "hook_triggered", Data: HookTriggeredMessage{HookID: "build", Output: "built", Error: "exit status 1", LogID: 3}, Sealed: true})
+	m.Broadcast(WsMessage{Type: "hook_triggered", Data: HookTriggeredMessage{HookID: "lint", Output: "clean"}})
+
+	tests := []struct {
+		name string
+		conn *websocket.Conn
+		want []map[string]interface{} // data of the messages received, in order
+	}{
+		{"decrypt_logs", permitted, []map[string]interface{}{
+			{"hookId": "build", "output": "built", "error": "exit status 1", "logId": float64(3)},
+			{"hookId": "lint", "output": "clean"},
+		}},
+		{"without decrypt_logs", other, []map[string]interface{}{
+			{"hookId": "build", "output": nil, "error": nil, "logId": float64(3)},
+			{"hookId": "lint", "output": "clean"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, want := range tt.want {
+				msg := next(t, tt.conn)
+				data, _ := msg.Data.(map[string]interface{})
+				for field, value := range want {
+					if data[field] != value {
+						t.Errorf("%s = %v, want %v in %v", field, data[field], value, data)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package syncnode
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+)
+
+// agent self-update rollout states
+const (
+	AgentUpdateUpToDate = "up_to_date"
+	AgentUpdateOutdated = "outdated"
+	AgentUpdateUpdating = "updating"
+	AgentUpdateFailed   = "failed"
+)
+
+// AgentUpdateInfo is advertised to agents in hello_ack when their version differs from the target.
+type AgentUpdateInfo struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	SHA256  string `json:"sha256"`
+}
+
+// AgentUpdateState is the rollout status shown in the node list.
+type AgentUpdateState struct {
+	Status        string `json:"status"`
+	TargetVersion string `json:"targetVersion"`
+	Error         string `json:"error,omitempty"`
+	UpdatedAt     string `json:"updatedAt,omitempty"`
+}
+
+// agentUpdateTarget resolves the advertised agent build for the given platform.
+// Env:
+// - SYNC_AGENT_TARGET_VERSION: expected agent version (empty disables self-update)
+// - SYNC_AGENT_DOWNLOAD_URL: binary URL, supports {version}, {os} and {arch} placeholders
+// - SYNC_AGENT_SHA256_<OS>_<ARCH> / SYNC_AGENT_SHA256: expected binary checksum (hex)
+func agentUpdateTarget(goos, goarch string) (AgentUpdateInfo, bool) {
+	version := strings.TrimSpace(os.Getenv("SYNC_AGENT_TARGET_VERSION"))
+	url := strings.TrimSpace(os.Getenv("SYNC_AGENT_DOWNLOAD_URL"))
+	if version == "" || url == "" {
+		return AgentUpdateInfo{}, false
+	}
+	sum := strings.TrimSpace(os.Getenv("SYNC_AGENT_SHA256_" + strings.ToUpper(goos) + "_" + strings.ToUpper(goarch)))
+	if sum == "" {
+		sum = strings.TrimSpace(os.Getenv("SYNC_AGENT_SHA256"))
+	}
+	if sum == "" {
+		// never advertise a binary the agent cannot verify
+		return AgentUpdateInfo{}, false
+	}
+	url = strings.NewReplacer("{version}", version, "{os}", goos, "{arch}", goarch).Replace(url)
+	return AgentUpdateInfo{Version: version, URL: url, SHA256: strings.ToLower(sum)}, true
+}
+
+// offerAgentUpdate returns the update to advertise for a connecting agent and records the rollout state.
+func (s *Service) offerAgentUpdate(ctx context.Context, nodeID uint, hello helloMessage) *AgentUpdateInfo {
+	if hello.OS == "" || hello.Arch == "" {
+		// agents without self-update support do not report their platform
+		return nil
+	}
+	target, ok := agentUpdateTarget(hello.OS, hello.Arch)
+	if !ok {
+		return nil
+	}
+
+	state := AgentUpdateState{TargetVersion: target.Version, UpdatedAt: time.Now().Format(time.RFC3339)}
+	var offer *AgentUpdateInfo
+	switch {
+	case hello.AgentVersion == target.Version:
+		state.Status = AgentUpdateUpToDate
+	case hello.UpdateError != "" && hello.UpdateVersion == target.Version:
+		// agent already tried this version; it will not retry until restarted
+		state.Status = AgentUpdateFailed
+		state.Error = hello.UpdateError
+	default:
+		state.Status = AgentUpdateUpdating
+		offer = &target
+	}
+	s.saveAgentUpdateState(ctx, nodeID, state)
+	return offer
+}
+
+func (s *Service) saveAgentUpdateState(ctx context.Context, nodeID uint, state AgentUpdateState) {
+	db, err := s.ensureDB()
+	if err != nil {
+		return
+	}
+	node, err := s.GetNode(ctx, nodeID)
+	if err != nil {
+		return
+	}
+	meta := decodeMap(node.Metadata)
+	meta["agentUpdate"] = map[string]interface{}{
+		"status":        state.Status,
+		"targetVersion": state.TargetVersion,
+		"error":         state.Error,
+		"updatedAt":     state.UpdatedAt,
+	}
+	_ = db.WithContext(ctx).Model(node).Update("metadata", encodeMap(meta)).Error
+}
+
+// agentUpdateStateFromMetadata reads the last recorded rollout state from node metadata.
+func agentUpdateStateFromMetadata(meta map[string]interface{}, agentVersion string) *AgentUpdateState {
+	raw, ok := meta["agentUpdate"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	state := &AgentUpdateState{}
+	state.Status, _ = raw["status"].(string)
+	state.TargetVersion, _ = raw["targetVersion"].(string)
+	state.Error, _ = raw["error"].(string)
+	state.UpdatedAt, _ = raw["updatedAt"].(string)
+	if state.TargetVersion == "" {
+		return nil
+	}
+	if agentVersion == state.TargetVersion {
+		state.Status = AgentUpdateUpToDate
+		state.Error = ""
+	} else if state.Status == "" || state.Status == AgentUpdateUpToDate {
+		state.Status = AgentUpdateOutdated
+	}
+	return state
+}
@@ -0,0 +1,37 @@
+package syncnode
+
+import "testing"
+
+func TestAgentUpdateTarget(t *testing.T) {
+	t.Setenv("SYNC_AGENT_TARGET_VERSION", "v1.2.0")
+	t.Setenv("SYNC_AGENT_DOWNLOAD_URL", "https://example.com/{version}/nodeclient-{os}-{arch}")
+	t.Setenv("SYNC_AGENT_SHA256", "")
+	if _, ok := agentUpdateTarget("linux", "amd64"); ok {
+		t.Fatalf("expected no update without checksum")
+	}
+
+	t.Setenv("SYNC_AGENT_SHA256", "AAAA")
+	t.Setenv("SYNC_AGENT_SHA256_LINUX_ARM64", "bbbb")
+	info, ok := agentUpdateTarget("linux", "amd64")
+	if !ok || info.URL != "https://example.com/v1.2.0/nodeclient-linux-amd64" || info.SHA256 != "aaaa" {
+		t.Fatalf("unexpected update info: %+v", info)
+	}
+	if info, _ := agentUpdateTarget("linux", "arm64"); info.SHA256 != "bbbb" {
+		t.Fatalf("expected platform checksum, got %q", info.SHA256)
+	}
+}
+
+func TestAgentUpdateStateFromMetadata(t *testing.T) {
+	meta := map[string]interface{}{
+		"agentUpdate": map[string]interface{}{"status": AgentUpdateFailed, "targetVersion": "v2", "error": "checksum mismatch"},
+	}
+	if st := agentUpdateStateFromMetadata(meta, "v1"); st == nil || st.Status != AgentUpdateFailed {
+		t.Fatalf("expected failed state, got %+v", st)
+	}
+	if st := agentUpdateStateFromMetadata(meta, "v2"); st == nil || st.Status != AgentUpdateUpToDate || st.Error != "" {
+		t.Fatalf("expected up to date state, got %+v", st)
+	}
+	if st := agentUpdateStateFromMetadata(map[string]interface{}{}, "v1"); st != nil {
+		t.Fatalf("expected nil state, got %+v", st)
+	}
+}
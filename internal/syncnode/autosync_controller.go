@@ -131,7 +131,7 @@ func maybeEnqueueAutoSync(ctx context.Context, projectName string) error {
 	var existing database.SyncTask
 	if err := db.WithContext(ctx).
 		Select("id").
-		Where("project_name = ? AND status IN ?", projectName, []string{TaskStatusPending, TaskStatusRunning, TaskStatusRetrying}).
+		Where("project_name = ? AND status IN ?", projectName, []string{TaskStatusWaiting, TaskStatusPending, TaskStatusRunning, TaskStatusRetrying}).
 		Order("id DESC").
 		First(&existing).Error; err == nil {
 		return nil
@@ -149,3 +149,27 @@ func maybeEnqueueAutoSync(ctx context.Context, projectName string) error {
 	_, err := defaultTaskService.CreateProjectTasks(ctx, projectName)
 	return err
 }
+
+// EnqueueDeploySync queues a sync run after a successful deploy when the project opts in.
+// Runs already pending or waiting for the project will pick up the deployed tree, so none is added then.
+func EnqueueDeploySync(ctx context.Context, projectName string) error {
+	project := findProject(projectName)
+	if project == nil || project.Sync == nil || !project.Sync.Enabled || !project.Sync.SyncOnDeploy {
+		return nil
+	}
+
+	db, err := defaultTaskService.ensureDB()
+	if err != nil {
+		return err
+	}
+	var existing database.SyncTask
+	if err := db.WithContext(ctx).
+		Select("id").
+		Where("project_name = ? AND status IN ?", projectName, []string{TaskStatusWaiting, TaskStatusPending, TaskStatusRetrying}).
+		First(&existing).Error; err == nil {
+		return nil
+	}
+
+	_, err = defaultTaskService.CreateProjectTasks(ctx, projectName)
+	return err
+}
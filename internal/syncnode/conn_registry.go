@@ -61,4 +61,3 @@ func getConnState(nodeID uint) (connState, bool) {
 	connRegistry.mu.RUnlock()
 	return st, ok
 }
-
@@ -0,0 +1,257 @@
+package syncnode
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"gorm.io/gorm"
+)
+
+type deploymentNodeResponse struct {
+	TaskID     uint      `json:"taskId"`
+	NodeID     uint      `json:"nodeId"`
+	NodeName   string    `json:"nodeName"`
+	Status     string    `json:"status"`
+	Attempt    int       `json:"attempt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	LastError  string    `json:"lastError,omitempty"`
+	ErrorCode  string    `json:"errorCode,omitempty"`
+	DurationMs int64     `json:"durationMs,omitempty"`
+}
+
+type deploymentResponse struct {
+	ID             uint                     `json:"id"`
+	ProjectName    string                   `json:"projectName"`
+	Strategy       string                   `json:"strategy"`
+	MaxUnavailable int                      `json:"maxUnavailable"`
+	Status         string                   `json:"status"`
+	Message        string                   `json:"message,omitempty"`
+	Approved       bool                     `json:"approved"`
+	ApprovedBy     string                   `json:"approvedBy,omitempty"`
+	CreatedBy      string                   `json:"createdBy,omitempty"`
+	CreatedAt      time.Time                `json:"createdAt"`
+	UpdatedAt      time.Time                `json:"updatedAt"`
+	Counts         map[string]int           `json:"counts"` // tasks per status
+	Nodes          []deploymentNodeResponse `json:"nodes,omitempty"`
+}
+
+func mapDeployment(d *database.SyncDeployment, tasks []database.SyncTask, withNodes bool) deploymentResponse {
+	resp := deploymentResponse{
+		ID:             d.ID,
+		ProjectName:    d.ProjectName,
+		Strategy:       d.Strategy,
+		MaxUnavailable: d.MaxUnavailable,
+		Status:         d.Status,
+		Message:        d.Message,
+		Approved:       d.Approved,
+		ApprovedBy:     d.ApprovedBy,
+		CreatedBy:      d.CreatedBy,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+		Counts:         map[string]int{},
+	}
+	for _, t := range tasks {
+		resp.Counts[t.Status]++
+		if withNodes {
+			resp.Nodes = append(resp.Nodes, deploymentNodeResponse{
+				TaskID:     t.ID,
+				NodeID:     t.NodeID,
+				NodeName:   t.NodeName,
+				Status:     t.Status,
+				Attempt:    t.Attempt,
+				UpdatedAt:  t.UpdatedAt,
+				LastError:  t.LastError,
+				ErrorCode:  t.ErrorCode,
+				DurationMs: t.DurationMs,
+			})
+		}
+	}
+	return resp
+}
+
+func deploymentTasks(db *gorm.DB, ids []uint) (map[uint][]database.SyncTask, error) {
+	out := map[uint][]database.SyncTask{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	var tasks []database.SyncTask
+	if err := db.Select("id, deployment_id, node_id, node_name, status, attempt, updated_at, last_error, error_code, duration_ms").
+		Where("deployment_id IN ?", ids).
+		Order("id ASC").
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range tasks {
+		out[t.DeploymentID] = append(out[t.DeploymentID], t)
+	}
+	return out, nil
+}
+
+// HandleListDeployments list recent deployments with task counts per status.
+// Query:
+// - projectName (optional)
+// - status (optional)
+// - limit (default 50, max 200)
+func HandleListDeployments(c *gin.Context) {
+	db := database.GetDB()
+	if db == nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDatabaseUnavailable, nil)
+		return
+	}
+	db = db.WithContext(c.Request.Context())
+
+	limit := 50
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		if v, err := strconv.Atoi(raw); err == nil && v > 0 {
+			limit = v
+		}
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	query := db.Model(&database.SyncDeployment{})
+	if pn := strings.TrimSpace(c.Query("projectName")); pn != "" {
+		query = query.Where("project_name = ?", pn)
+	}
+	if st := strings.TrimSpace(c.Query("status")); st != "" {
+		query = query.Where("status = ?", strings.ToLower(st))
+	}
+
+	var deps []database.SyncDeployment
+	if err := query.Order("id DESC").Limit(limit).Find(&deps).Error; err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListDeploymentsFailed, err)
+		return
+	}
+	ids := make([]uint, 0, len(deps))
+	for i := range deps {
+		ids = append(ids, deps[i].ID)
+	}
+	tasks, err := deploymentTasks(db, ids)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListDeploymentsFailed, err)
+		return
+	}
+
+	out := make([]deploymentResponse, 0, len(deps))
+	for i := range deps {
+		out = append(out, mapDeployment(&deps[i], tasks[deps[i].ID], false))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// HandleGetDeployment get one deployment with the status of every node
+func HandleGetDeployment(c *gin.Context) {
+	id, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
+		return
+	}
+	respondDeployment(c, http.StatusOK, id)
+}
+
+// HandleCreateDeployment start a deployment of a project, strategy and maxUnavailable
+// override the rollout configured for the project for this run only
+func HandleCreateDeployment(c *gin.Context) {
+	var req struct {
+		ProjectName    string `json:"projectName" binding:"required"`
+		Strategy       string `json:"strategy"`
+		MaxUnavailable *int   `json:"maxUnavailable"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	dep, _, err := defaultTaskService.CreateDeployment(c.Request.Context(), req.ProjectName, DeploymentOptions{
+		Strategy:       req.Strategy,
+		MaxUnavailable: req.MaxUnavailable,
+		CreatedBy:      currentUsername(c),
+	})
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeCreateDeploymentFailed, err)
+		return
+	}
+	respondDeployment(c, http.StatusCreated, dep.ID)
+}
+
+// HandleApproveDeployment let a paused canary deployment continue to the remaining nodes
+func HandleApproveDeployment(c *gin.Context) {
+	handleDeploymentAction(c, func(id uint) (*database.SyncDeployment, error) {
+		return defaultTaskService.ApproveDeployment(c.Request.Context(), id, currentUsername(c))
+	})
+}
+
+// HandleAbortDeployment cancel the tasks of a deployment that have not started yet
+func HandleAbortDeployment(c *gin.Context) {
+	handleDeploymentAction(c, func(id uint) (*database.SyncDeployment, error) {
+		return defaultTaskService.AbortDeployment(c.Request.Context(), id, currentUsername(c))
+	})
+}
+
+// HandleRetryDeployment retry the failed and cancelled nodes of a deployment
+func HandleRetryDeployment(c *gin.Context) {
+	handleDeploymentAction(c, func(id uint) (*database.SyncDeployment, error) {
+		return defaultTaskService.RetryDeployment(c.Request.Context(), id)
+	})
+}
+
+func handleDeploymentAction(c *gin.Context, action func(id uint) (*database.SyncDeployment, error)) {
+	id, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
+		return
+	}
+	if _, err := action(id); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			i18n.JSONError(c, http.StatusNotFound, i18n.CodeDeploymentNotFound, nil)
+		case errors.Is(err, ErrDeploymentState):
+			i18n.JSONError(c, http.StatusConflict, i18n.CodeDeploymentStateConflict, err)
+		default:
+			i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDeploymentActionFailed, err)
+		}
+		return
+	}
+	respondDeployment(c, http.StatusOK, id)
+}
+
+func respondDeployment(c *gin.Context, code int, id uint) {
+	db := database.GetDB()
+	if db == nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDatabaseUnavailable, nil)
+		return
+	}
+	db = db.WithContext(c.Request.Context())
+
+	var dep database.SyncDeployment
+	if err := db.First(&dep, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			i18n.JSONError(c, http.StatusNotFound, i18n.CodeDeploymentNotFound, nil)
+			return
+		}
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadDeploymentFailed, err)
+		return
+	}
+	tasks, err := deploymentTasks(db, []uint{id})
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadDeploymentFailed, err)
+		return
+	}
+	c.JSON(code, mapDeployment(&dep, tasks[id], true))
+}
+
+func currentUsername(c *gin.Context) string {
+	if username, ok := c.Get("username"); ok {
+		if s, ok := username.(string); ok {
+			return s
+		}
+	}
+	return "unknown"
+}
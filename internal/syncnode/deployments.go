@@ -0,0 +1,301 @@
+package syncnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/types"
+	"gorm.io/gorm"
+)
+
+// rollout strategies of a multi-node sync run
+const (
+	RolloutAllAtOnce = "all-at-once"
+	RolloutRolling   = "rolling"
+	RolloutCanary    = "canary"
+)
+
+// deployment statuses
+const (
+	DeploymentRunning = "running"
+	DeploymentPaused  = "paused" // canary succeeded, waiting for approval
+	DeploymentSuccess = "success"
+	DeploymentFailed  = "failed"
+	DeploymentAborted = "aborted"
+)
+
+// ErrDeploymentState is returned when a deployment is not in a state that allows the action.
+var ErrDeploymentState = errors.New("deployment state does not allow this action")
+
+// deployMu serializes rollout decisions, so two reports finishing together cannot both
+// start the next node of a rolling deployment.
+var deployMu sync.Mutex
+
+// DeploymentOptions override the rollout configured for the project.
+type DeploymentOptions struct {
+	Strategy       string
+	MaxUnavailable *int
+	CreatedBy      string
+}
+
+// ValidateRollout checks the rollout strategy of a project sync config.
+func ValidateRollout(cfg *types.ProjectRolloutConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	switch normalizeRolloutStrategy(cfg.Strategy) {
+	case RolloutAllAtOnce, RolloutRolling, RolloutCanary:
+	default:
+		return fmt.Errorf("invalid rollout strategy %q (all-at-once, rolling or canary)", cfg.Strategy)
+	}
+	if cfg.MaxUnavailable < 0 {
+		return fmt.Errorf("rollout maxUnavailable must not be negative")
+	}
+	return nil
+}
+
+func normalizeRolloutStrategy(v string) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v == "" {
+		return RolloutAllAtOnce
+	}
+	return v
+}
+
+// rolloutFor merges the project rollout and the per-run options. Rolling syncs one node at a
+// time unless maxUnavailable says otherwise; canary continues all at once after approval when
+// maxUnavailable is 0.
+func rolloutFor(cfg *types.ProjectSyncConfig, opts DeploymentOptions) (types.ProjectRolloutConfig, error) {
+	var r types.ProjectRolloutConfig
+	if cfg != nil && cfg.Rollout != nil {
+		r = *cfg.Rollout
+	}
+	if opts.Strategy != "" {
+		r.Strategy = opts.Strategy
+	}
+	if opts.MaxUnavailable != nil {
+		r.MaxUnavailable = *opts.MaxUnavailable
+	}
+	if err := ValidateRollout(&r); err != nil {
+		return r, err
+	}
+	r.Strategy = normalizeRolloutStrategy(r.Strategy)
+	if r.Strategy == RolloutRolling && r.MaxUnavailable == 0 {
+		r.MaxUnavailable = 1
+	}
+	return r, nil
+}
+
+// advanceDeployment moves a deployment forward after one of its tasks changed: waiting tasks are
+// released according to the strategy, and the deployment status follows its tasks. A failed
+// task halts the rollout; the nodes not started yet stay waiting for a retry.
+func (s *TaskService) advanceDeployment(ctx context.Context, id uint) error {
+	db, err := s.ensureDB()
+	if err != nil {
+		return err
+	}
+	deployMu.Lock()
+	defer deployMu.Unlock()
+
+	var dep database.SyncDeployment
+	if err := db.WithContext(ctx).First(&dep, id).Error; err != nil {
+		return err
+	}
+	if dep.Status != DeploymentRunning && dep.Status != DeploymentPaused {
+		return nil
+	}
+
+	var tasks []database.SyncTask
+	if err := db.WithContext(ctx).
+		Select("id, node_id, node_name, status").
+		Where("deployment_id = ?", dep.ID).
+		Order("id ASC").
+		Find(&tasks).Error; err != nil {
+		return err
+	}
+
+	var waiting []uint
+	active, started := 0, 0
+	var failed *database.SyncTask
+	for i := range tasks {
+		switch tasks[i].Status {
+		case TaskStatusWaiting:
+			waiting = append(waiting, tasks[i].ID)
+			continue
+		case TaskStatusPending, TaskStatusRunning, TaskStatusRetrying:
+			active++
+		case TaskStatusFailed, TaskStatusCancelled:
+			if failed == nil {
+				failed = &tasks[i]
+			}
+		}
+		started++
+	}
+
+	status, message := DeploymentRunning, ""
+	limit := -1 // tasks that may be active at once, -1 for no limit
+	switch {
+	case failed != nil:
+		status = DeploymentFailed
+		message = fmt.Sprintf("sync to node %s %s", failed.NodeName, failed.Status)
+		limit = 0
+	case len(waiting) == 0 && active == 0:
+		status = DeploymentSuccess
+	case dep.Strategy == RolloutCanary && !dep.Approved:
+		limit = 1
+		if started > 0 && active == 0 {
+			status = DeploymentPaused
+			message = fmt.Sprintf("canary node %s synced, waiting for approval", tasks[0].NodeName)
+			limit = 0
+		} else if started > 0 {
+			limit = 0
+		}
+	case dep.Strategy == RolloutRolling || dep.Strategy == RolloutCanary:
+		if dep.MaxUnavailable > 0 {
+			limit = dep.MaxUnavailable
+		}
+	}
+
+	release := waiting
+	if limit >= 0 {
+		release = waiting[:min(len(waiting), max(limit-active, 0))]
+	}
+	if len(release) > 0 {
+		if err := db.WithContext(ctx).Model(&database.SyncTask{}).
+			Where("id IN ? AND status = ?", release, TaskStatusWaiting).
+			Update("status", TaskStatusPending).Error; err != nil {
+			return err
+		}
+		broadcastWS(wsTypeSyncTaskEvent, map[string]any{
+			"event":       "released",
+			"projectName": dep.ProjectName,
+			"taskIds":     release,
+		})
+	}
+
+	if status != dep.Status || message != dep.Message {
+		if err := db.WithContext(ctx).Model(&dep).
+			Updates(map[string]any{"status": status, "message": message}).Error; err != nil {
+			return err
+		}
+		broadcastWS(wsTypeSyncDeploymentEvent, syncDeploymentEvent{
+			DeploymentID: dep.ID,
+			ProjectName:  dep.ProjectName,
+			Status:       status,
+			Event:        "status",
+		})
+	}
+	return nil
+}
+
+// advanceOpenDeployments re-evaluates every running deployment, used after bulk task updates.
+func (s *TaskService) advanceOpenDeployments(ctx context.Context) {
+	db, err := s.ensureDB()
+	if err != nil {
+		return
+	}
+	var ids []uint
+	if err := db.WithContext(ctx).Model(&database.SyncDeployment{}).
+		Where("status = ?", DeploymentRunning).
+		Pluck("id", &ids).Error; err != nil {
+		return
+	}
+	for _, id := range ids {
+		_ = s.advanceDeployment(ctx, id)
+	}
+}
+
+// ApproveDeployment lets a paused canary deployment continue to the remaining nodes.
+func (s *TaskService) ApproveDeployment(ctx context.Context, id uint, username string) (*database.SyncDeployment, error) {
+	return s.changeDeployment(ctx, id, func(tx *gorm.DB, dep *database.SyncDeployment) error {
+		if dep.Status != DeploymentPaused {
+			return ErrDeploymentState
+		}
+		return tx.Model(dep).Updates(map[string]any{
+			"status":      DeploymentRunning,
+			"message":     "",
+			"approved":    true,
+			"approved_by": username,
+		}).Error
+	})
+}
+
+// AbortDeployment cancels the tasks that have not started yet. Tasks already running on a
+// node finish, their result is still recorded.
+func (s *TaskService) AbortDeployment(ctx context.Context, id uint, username string) (*database.SyncDeployment, error) {
+	return s.changeDeployment(ctx, id, func(tx *gorm.DB, dep *database.SyncDeployment) error {
+		if dep.Status != DeploymentRunning && dep.Status != DeploymentPaused {
+			return ErrDeploymentState
+		}
+		if err := tx.Model(&database.SyncTask{}).
+			Where("deployment_id = ? AND status IN ?", dep.ID,
+				[]string{TaskStatusWaiting, TaskStatusPending, TaskStatusRetrying}).
+			Updates(map[string]any{"status": TaskStatusCancelled, "last_error": "deployment aborted"}).Error; err != nil {
+			return err
+		}
+		return tx.Model(dep).Updates(map[string]any{
+			"status":  DeploymentAborted,
+			"message": "aborted by " + username,
+		}).Error
+	})
+}
+
+// RetryDeployment puts the failed and cancelled tasks of a failed or aborted deployment back
+// in line and resumes the rollout where it stopped.
+func (s *TaskService) RetryDeployment(ctx context.Context, id uint) (*database.SyncDeployment, error) {
+	return s.changeDeployment(ctx, id, func(tx *gorm.DB, dep *database.SyncDeployment) error {
+		if dep.Status != DeploymentFailed && dep.Status != DeploymentAborted {
+			return ErrDeploymentState
+		}
+		if err := tx.Model(&database.SyncTask{}).
+			Where("deployment_id = ? AND status IN ?", dep.ID, []string{TaskStatusFailed, TaskStatusCancelled}).
+			Updates(map[string]any{
+				"status":     TaskStatusWaiting,
+				"attempt":    0,
+				"last_error": "",
+				"error_code": "",
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Model(dep).Updates(map[string]any{"status": DeploymentRunning, "message": ""}).Error
+	})
+}
+
+// changeDeployment applies change to the deployment in a transaction and advances it.
+func (s *TaskService) changeDeployment(ctx context.Context, id uint, change func(tx *gorm.DB, dep *database.SyncDeployment) error) (*database.SyncDeployment, error) {
+	db, err := s.ensureDB()
+	if err != nil {
+		return nil, err
+	}
+	deployMu.Lock()
+	var dep database.SyncDeployment
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&dep, id).Error; err != nil {
+			return err
+		}
+		return change(tx, &dep)
+	})
+	deployMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.advanceDeployment(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := db.WithContext(ctx).First(&dep, id).Error; err != nil {
+		return nil, err
+	}
+	broadcastWS(wsTypeSyncDeploymentEvent, syncDeploymentEvent{
+		DeploymentID: dep.ID,
+		ProjectName:  dep.ProjectName,
+		Status:       dep.Status,
+		Event:        "updated",
+	})
+	broadcastWS(wsTypeSyncProjectEvent, syncProjectEvent{ProjectName: dep.ProjectName, Event: "tasks"})
+	return &dep, nil
+}
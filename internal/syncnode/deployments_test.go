@@ -0,0 +1,172 @@
+package syncnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func newTestDeployment(t *testing.T, strategy string, maxUnavailable, nodes int) (*TaskService, uint) {
+	t.Helper()
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&database.SyncTask{}, &database.SyncDeployment{}); err != nil {
+		t.Fatal(err)
+	}
+	dep := database.SyncDeployment{ProjectName: "p", Strategy: strategy, MaxUnavailable: maxUnavailable, Status: DeploymentRunning}
+	if err := db.Create(&dep).Error; err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < nodes; i++ {
+		task := database.SyncTask{ProjectName: "p", NodeID: uint(i + 1), NodeName: fmt.Sprintf("n%d", i+1), Status: TaskStatusWaiting, DeploymentID: dep.ID}
+		if err := db.Create(&task).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := &TaskService{db: db}
+	if err := svc.advanceDeployment(context.Background(), dep.ID); err != nil {
+		t.Fatal(err)
+	}
+	return svc, dep.ID
+}
+
+// deploymentState returns the deployment status and its task statuses in node order.
+func deploymentState(t *testing.T, svc *TaskService, id uint) (string, []string) {
+	t.Helper()
+	var dep database.SyncDeployment
+	if err := svc.db.First(&dep, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	var tasks []database.SyncTask
+	if err := svc.db.Where("deployment_id = ?", id).Order("id").Find(&tasks).Error; err != nil {
+		t.Fatal(err)
+	}
+	statuses := make([]string, len(tasks))
+	for i := range tasks {
+		statuses[i] = tasks[i].Status
+	}
+	return dep.Status, statuses
+}
+
+// finishTask reports the result of the task on node like the agent does.
+func finishTask(t *testing.T, svc *TaskService, nodeID uint, status string) {
+	t.Helper()
+	var task database.SyncTask
+	if err := svc.db.Where("node_id = ?", nodeID).First(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ReportTask(context.Background(), nodeID, task.ID, TaskReport{Status: status, ErrorCode: "EXEC"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertDeployment(t *testing.T, svc *TaskService, id uint, wantStatus string, wantTasks ...string) {
+	t.Helper()
+	status, tasks := deploymentState(t, svc, id)
+	if status != wantStatus {
+		t.Fatalf("deployment status = %s, want %s", status, wantStatus)
+	}
+	if len(tasks) != len(wantTasks) {
+		t.Fatalf("tasks = %v, want %v", tasks, wantTasks)
+	}
+	for i := range tasks {
+		if tasks[i] != wantTasks[i] {
+			t.Fatalf("tasks = %v, want %v", tasks, wantTasks)
+		}
+	}
+}
+
+func TestRolloutFor(t *testing.T) {
+	two := 2
+	tests := []struct {
+		name     string
+		cfg      *types.ProjectRolloutConfig
+		opts     DeploymentOptions
+		strategy string
+		max      int
+		wantErr  bool
+	}{
+		{"default", nil, DeploymentOptions{}, RolloutAllAtOnce, 0, false},
+		{"rolling defaults to one", &types.ProjectRolloutConfig{Strategy: "Rolling"}, DeploymentOptions{}, RolloutRolling, 1, false},
+		{"override", &types.ProjectRolloutConfig{Strategy: "canary"}, DeploymentOptions{Strategy: "rolling", MaxUnavailable: &two}, RolloutRolling, 2, false},
+		{"unknown strategy", &types.ProjectRolloutConfig{Strategy: "blue-green"}, DeploymentOptions{}, "", 0, true},
+		{"negative", &types.ProjectRolloutConfig{Strategy: "rolling", MaxUnavailable: -1}, DeploymentOptions{}, "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rolloutFor(&types.ProjectSyncConfig{Rollout: tt.cfg}, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Strategy != tt.strategy || got.MaxUnavailable != tt.max) {
+				t.Fatalf("got %+v, want %s/%d", got, tt.strategy, tt.max)
+			}
+		})
+	}
+}
+
+func TestAllAtOnceDeployment(t *testing.T) {
+	svc, id := newTestDeployment(t, RolloutAllAtOnce, 0, 3)
+	assertDeployment(t, svc, id, DeploymentRunning, "pending", "pending", "pending")
+	for n := uint(1); n <= 3; n++ {
+		finishTask(t, svc, n, "success")
+	}
+	assertDeployment(t, svc, id, DeploymentSuccess, "success", "success", "success")
+}
+
+func TestRollingDeployment(t *testing.T) {
+	svc, id := newTestDeployment(t, RolloutRolling, 2, 3)
+	assertDeployment(t, svc, id, DeploymentRunning, "pending", "pending", "waiting")
+	finishTask(t, svc, 1, "success")
+	assertDeployment(t, svc, id, DeploymentRunning, "success", "pending", "pending")
+
+	// a failure halts the rollout, retry resumes it
+	finishTask(t, svc, 2, "failed")
+	assertDeployment(t, svc, id, DeploymentFailed, "success", "failed", "pending")
+	if _, err := svc.RetryDeployment(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	assertDeployment(t, svc, id, DeploymentRunning, "success", "pending", "pending")
+	finishTask(t, svc, 2, "success")
+	finishTask(t, svc, 3, "success")
+	assertDeployment(t, svc, id, DeploymentSuccess, "success", "success", "success")
+}
+
+func TestCanaryDeployment(t *testing.T) {
+	svc, id := newTestDeployment(t, RolloutCanary, 0, 3)
+	assertDeployment(t, svc, id, DeploymentRunning, "pending", "waiting", "waiting")
+
+	if _, err := svc.ApproveDeployment(context.Background(), id, "admin"); !errors.Is(err, ErrDeploymentState) {
+		t.Fatalf("approve before canary finished: err = %v", err)
+	}
+	finishTask(t, svc, 1, "success")
+	assertDeployment(t, svc, id, DeploymentPaused, "success", "waiting", "waiting")
+
+	dep, err := svc.ApproveDeployment(context.Background(), id, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dep.Approved || dep.ApprovedBy != "admin" {
+		t.Fatalf("approval not recorded: %+v", dep)
+	}
+	assertDeployment(t, svc, id, DeploymentRunning, "success", "pending", "pending")
+}
+
+func TestAbortDeployment(t *testing.T) {
+	svc, id := newTestDeployment(t, RolloutRolling, 1, 3)
+	if _, err := svc.AbortDeployment(context.Background(), id, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	assertDeployment(t, svc, id, DeploymentAborted, "cancelled", "cancelled", "cancelled")
+	if _, err := svc.AbortDeployment(context.Background(), id, "admin"); !errors.Is(err, ErrDeploymentState) {
+		t.Fatalf("second abort: err = %v", err)
+	}
+
+	if _, err := svc.RetryDeployment(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	assertDeployment(t, svc, id, DeploymentRunning, "pending", "waiting", "waiting")
+}
@@ -0,0 +1,151 @@
+package syncnode
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mycoool/gohook/internal/database"
+)
+
+const (
+	// ApprovalStatusApproved is also assumed for legacy nodes with an empty status.
+	ApprovalStatusPending  = "pending"
+	ApprovalStatusApproved = "approved"
+	ApprovalStatusRevoked  = "revoked"
+)
+
+var (
+	// ErrNodePendingApproval indicates an enrolled agent is waiting for admin approval
+	ErrNodePendingApproval = errors.New("node pending approval")
+	// ErrNodeRevoked indicates the node credentials were revoked
+	ErrNodeRevoked = errors.New("node revoked")
+)
+
+// requireEnrollmentApproval reports whether new agents must be approved before they get tasks.
+// Env: SYNC_REQUIRE_APPROVAL (true|1)
+func requireEnrollmentApproval() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("SYNC_REQUIRE_APPROVAL"))) {
+	case "1", "true", "yes", "on":
+		return true
+	default:
+		return false
+	}
+}
+
+// activeConns tracks live agent connections so revocation can drop them immediately.
+var activeConns = struct {
+	mu sync.Mutex
+	m  map[uint]net.Conn
+}{
+	m: make(map[uint]net.Conn),
+}
+
+func registerActiveConn(nodeID uint, conn net.Conn) {
+	activeConns.mu.Lock()
+	activeConns.m[nodeID] = conn
+	activeConns.mu.Unlock()
+}
+
+func unregisterActiveConn(nodeID uint, conn net.Conn) {
+	activeConns.mu.Lock()
+	if activeConns.m[nodeID] == conn {
+		delete(activeConns.m, nodeID)
+	}
+	activeConns.mu.Unlock()
+}
+
+func closeActiveConn(nodeID uint) {
+	activeConns.mu.Lock()
+	conn := activeConns.m[nodeID]
+	delete(activeConns.m, nodeID)
+	activeConns.mu.Unlock()
+	if conn != nil {
+		_ = conn.Close()
+	}
+}
+
+// checkEnrollment gates an authenticated agent on its approval status.
+// With approval required, a node seen for the first time is moved to pending.
+func (s *Service) checkEnrollment(ctx context.Context, node *database.SyncNode, agentName string) error {
+	switch node.ApprovalStatus {
+	case ApprovalStatusRevoked:
+		return ErrNodeRevoked
+	case ApprovalStatusApproved:
+		return nil
+	case ApprovalStatusPending:
+		return ErrNodePendingApproval
+	}
+
+	if !requireEnrollmentApproval() {
+		return nil
+	}
+
+	db, err := s.ensureDB()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	node.ApprovalStatus = ApprovalStatusPending
+	node.EnrolledAt = &now
+	if strings.TrimSpace(agentName) != "" {
+		meta := decodeMap(node.Metadata)
+		meta["enrollAgentName"] = agentName
+		node.Metadata = encodeMap(meta)
+	}
+	if err := db.WithContext(ctx).Save(node).Error; err != nil {
+		return err
+	}
+	broadcastWS(wsTypeSyncNodeEvent, syncNodeEvent{NodeID: node.ID, Event: "enroll_pending"})
+	return ErrNodePendingApproval
+}
+
+// ApproveNode allows a pending (or legacy) node to connect and receive tasks.
+func (s *Service) ApproveNode(ctx context.Context, id uint) (*database.SyncNode, error) {
+	db, err := s.ensureDB()
+	if err != nil {
+		return nil, err
+	}
+	node, err := s.GetNode(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if node.ApprovalStatus == ApprovalStatusRevoked {
+		return nil, errors.New("node is revoked, rotate its token to enroll it again")
+	}
+	node.ApprovalStatus = ApprovalStatusApproved
+	if err := db.WithContext(ctx).Save(node).Error; err != nil {
+		return nil, err
+	}
+	broadcastWS(wsTypeSyncNodeEvent, syncNodeEvent{NodeID: node.ID, Event: "approved"})
+	return node, nil
+}
+
+// RevokeNode invalidates the agent token and pinned certificate and drops the live connection.
+func (s *Service) RevokeNode(ctx context.Context, id uint) (*database.SyncNode, error) {
+	db, err := s.ensureDB()
+	if err != nil {
+		return nil, err
+	}
+	node, err := s.GetNode(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	node.ApprovalStatus = ApprovalStatusRevoked
+	node.RevokedAt = &now
+	node.CredentialValue = ""
+	node.AgentCertFingerprint = ""
+	node.Status = NodeStatusOffline
+	node.Health = NodeHealthUnknown
+	if err := db.WithContext(ctx).Save(node).Error; err != nil {
+		return nil, err
+	}
+	closeActiveConn(node.ID)
+	broadcastWS(wsTypeSyncNodeEvent, syncNodeEvent{NodeID: node.ID, Event: "revoked"})
+	return node, nil
+}
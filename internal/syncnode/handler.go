@@ -13,6 +13,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
 	"gorm.io/gorm"
 )
 
@@ -35,6 +36,7 @@ type nodeResponse struct {
 	LastError            string                 `json:"lastError,omitempty"`
 	LastErrorCode        string                 `json:"lastErrorCode,omitempty"`
 	Tags                 []string               `json:"tags"`
+	Labels               map[string]string      `json:"labels"`
 	Metadata             map[string]interface{} `json:"metadata"`
 	SSHUser              string                 `json:"sshUser"`
 	SSHPort              int                    `json:"sshPort"`
@@ -44,22 +46,36 @@ type nodeResponse struct {
 	InstallStatus        string                 `json:"installStatus"`
 	InstallLog           string                 `json:"installLog"`
 	AgentVersion         string                 `json:"agentVersion"`
+	ApprovalStatus       string                 `json:"approvalStatus"`
+	AgentUpdate          *AgentUpdateState      `json:"agentUpdate,omitempty"`
+	EnrolledAt           *time.Time             `json:"enrolledAt,omitempty"`
+	RevokedAt            *time.Time             `json:"revokedAt,omitempty"`
 	LastSeen             *time.Time             `json:"lastSeen"`
 	Runtime              *NodeRuntimeStatus     `json:"runtime,omitempty"`
+	RuntimeWarnings      []string               `json:"runtimeWarnings,omitempty"`
 	CreatedAt            time.Time              `json:"createdAt"`
 	UpdatedAt            time.Time              `json:"updatedAt"`
 }
 
 func HandleListNodes(c *gin.Context) {
 	filter := NodeListFilter{
-		Status: c.Query("status"),
-		Type:   c.Query("type"),
-		Search: c.Query("search"),
+		Status:   c.Query("status"),
+		Type:     c.Query("type"),
+		Search:   c.Query("search"),
+		Approval: c.Query("approval"),
+	}
+	if expr := c.Query("selector"); expr != "" {
+		sel, err := ParseSelector(expr)
+		if err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidSelector, err)
+			return
+		}
+		filter.Selector = sel
 	}
 
 	nodes, err := defaultService.ListNodes(c.Request.Context(), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListNodesFailed, err)
 		return
 	}
 
@@ -75,7 +91,7 @@ func HandleLocalRuntime(c *gin.Context) {
 func HandleGetNode(c *gin.Context) {
 	id, err := parseIDParam(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
 		return
 	}
 
@@ -85,7 +101,7 @@ func HandleGetNode(c *gin.Context) {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			status = http.StatusNotFound
 		}
-		c.JSON(status, gin.H{"error": err.Error()})
+		i18n.JSONError(c, status, i18n.CodeLoadNodeFailed, err)
 		return
 	}
 
@@ -93,16 +109,53 @@ func HandleGetNode(c *gin.Context) {
 	c.JSON(http.StatusOK, mapNode(node, taskSummary[node.ID]))
 }
 
+// HandleGetNodeMetrics returns the latest host metrics reported by the node agent.
+func HandleGetNodeMetrics(c *gin.Context) {
+	id, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
+		return
+	}
+
+	node, err := defaultService.GetNode(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		i18n.JSONError(c, status, i18n.CodeLoadNodeFailed, err)
+		return
+	}
+
+	resp := gin.H{
+		"nodeId":    node.ID,
+		"connected": false,
+		"runtime":   nil,
+		"warnings":  []string{},
+	}
+	if cs, ok := getConnState(node.ID); ok {
+		resp["connected"] = cs.Connected
+		resp["lastSeen"] = cs.LastSeen
+	}
+	if rs, ok := getRuntimeStatus(node.ID); ok {
+		resp["runtime"] = rs
+		if w := rs.Warnings(); len(w) > 0 {
+			resp["warnings"] = w
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
 func HandleCreateNode(c *gin.Context) {
 	var req CreateNodeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
 	node, err := defaultService.CreateNode(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeCreateNodeFailed, err)
 		return
 	}
 
@@ -112,13 +165,17 @@ func HandleCreateNode(c *gin.Context) {
 func HandleUpdateNode(c *gin.Context) {
 	id, err := parseIDParam(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
 		return
 	}
 
 	var req UpdateNodeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if err := req.ValidateUpdate(); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
@@ -128,7 +185,7 @@ func HandleUpdateNode(c *gin.Context) {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			status = http.StatusNotFound
 		}
-		c.JSON(status, gin.H{"error": err.Error()})
+		i18n.JSONError(c, status, i18n.CodeUpdateNodeFailed, err)
 		return
 	}
 
@@ -138,7 +195,7 @@ func HandleUpdateNode(c *gin.Context) {
 func HandleDeleteNode(c *gin.Context) {
 	id, err := parseIDParam(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
 		return
 	}
 
@@ -147,7 +204,7 @@ func HandleDeleteNode(c *gin.Context) {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			status = http.StatusNotFound
 		}
-		c.JSON(status, gin.H{"error": err.Error()})
+		i18n.JSONError(c, status, i18n.CodeDeleteNodeFailed, err)
 		return
 	}
 
@@ -157,13 +214,13 @@ func HandleDeleteNode(c *gin.Context) {
 func HandleInstallNode(c *gin.Context) {
 	id, err := parseIDParam(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
 		return
 	}
 
 	var req InstallRequest
 	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
@@ -173,7 +230,7 @@ func HandleInstallNode(c *gin.Context) {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			status = http.StatusNotFound
 		}
-		c.JSON(status, gin.H{"error": err.Error()})
+		i18n.JSONError(c, status, i18n.CodeInstallNodeFailed, err)
 		return
 	}
 
@@ -183,7 +240,7 @@ func HandleInstallNode(c *gin.Context) {
 func HandleRotateToken(c *gin.Context) {
 	id, err := parseIDParam(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
 		return
 	}
 
@@ -193,7 +250,7 @@ func HandleRotateToken(c *gin.Context) {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			status = http.StatusNotFound
 		}
-		c.JSON(status, gin.H{"error": err.Error()})
+		i18n.JSONError(c, status, i18n.CodeRotateNodeTokenFailed, err)
 		return
 	}
 
@@ -203,7 +260,7 @@ func HandleRotateToken(c *gin.Context) {
 func HandleResetPairing(c *gin.Context) {
 	id, err := parseIDParam(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
 		return
 	}
 	node, err := defaultService.ResetPairing(c.Request.Context(), id)
@@ -212,7 +269,43 @@ func HandleResetPairing(c *gin.Context) {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			status = http.StatusNotFound
 		}
-		c.JSON(status, gin.H{"error": err.Error()})
+		i18n.JSONError(c, status, i18n.CodeResetPairingFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, mapNode(node, nodeTaskSummary{}))
+}
+
+func HandleApproveNode(c *gin.Context) {
+	id, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
+		return
+	}
+	node, err := defaultService.ApproveNode(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		i18n.JSONError(c, status, i18n.CodeApproveNodeFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, mapNode(node, nodeTaskSummary{}))
+}
+
+func HandleRevokeNode(c *gin.Context) {
+	id, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
+		return
+	}
+	node, err := defaultService.RevokeNode(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		i18n.JSONError(c, status, i18n.CodeRevokeNodeFailed, err)
 		return
 	}
 	c.JSON(http.StatusOK, mapNode(node, nodeTaskSummary{}))
@@ -347,10 +440,18 @@ func mapNode(node *database.SyncNode, summary nodeTaskSummary) nodeResponse {
 		}
 	}
 
+	metadata := decodeMap(node.Metadata)
+	approvalStatus := node.ApprovalStatus
+	if approvalStatus == "" {
+		approvalStatus = ApprovalStatusApproved
+	}
+
 	var runtime *NodeRuntimeStatus
+	var runtimeWarnings []string
 	if rs, ok := getRuntimeStatus(node.ID); ok {
 		copy := rs
 		runtime = &copy
+		runtimeWarnings = rs.Warnings()
 	}
 
 	return nodeResponse{
@@ -370,7 +471,8 @@ func mapNode(node *database.SyncNode, summary nodeTaskSummary) nodeResponse {
 		LastError:            lastError,
 		LastErrorCode:        lastErrorCode,
 		Tags:                 decodeStringSlice(node.Tags),
-		Metadata:             decodeMap(node.Metadata),
+		Labels:               decodeLabels(node.Labels),
+		Metadata:             metadata,
 		SSHUser:              node.SSHUser,
 		SSHPort:              node.SSHPort,
 		AuthType:             node.AuthType,
@@ -379,8 +481,13 @@ func mapNode(node *database.SyncNode, summary nodeTaskSummary) nodeResponse {
 		InstallStatus:        node.InstallStatus,
 		InstallLog:           node.InstallLog,
 		AgentVersion:         node.AgentVersion,
+		ApprovalStatus:       approvalStatus,
+		AgentUpdate:          agentUpdateStateFromMetadata(metadata, node.AgentVersion),
+		EnrolledAt:           node.EnrolledAt,
+		RevokedAt:            node.RevokedAt,
 		LastSeen:             lastSeen,
 		Runtime:              runtime,
+		RuntimeWarnings:      runtimeWarnings,
 		CreatedAt:            node.CreatedAt,
 		UpdatedAt:            node.UpdatedAt,
 	}
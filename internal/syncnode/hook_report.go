@@ -0,0 +1,35 @@
+package syncnode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mycoool/gohook/internal/database"
+)
+
+// hookReportMsg is the result of a local hook executed by the agent from its own hooks file.
+type hookReportMsg struct {
+	Type       string `json:"type"`
+	HookID     string `json:"hookId"`
+	Method     string `json:"method,omitempty"`
+	Success    bool   `json:"success"`
+	Output     string `json:"output,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+}
+
+// recordHookReport stores a local hook run in the hook logs, named "<agent>/<hook id>" so
+// runs on the node can be told apart from runs on the primary.
+func recordHookReport(nodeID uint, agentName, remoteAddr string, rep hookReportMsg) bool {
+	hookID := strings.TrimSpace(rep.HookID)
+	if hookID == "" {
+		return false
+	}
+	name := strings.TrimSpace(agentName)
+	if name == "" {
+		name = fmt.Sprintf("node-%d", nodeID)
+	}
+	database.LogHookExecution(hookID, "", name+"/"+hookID, database.HookTypeWebhook, rep.Method, remoteAddr,
+		nil, "", rep.Success, rep.Output, rep.Error, rep.DurationMs, "gohook-agent", nil)
+	return true
+}
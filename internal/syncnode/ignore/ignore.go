@@ -16,6 +16,7 @@ import (
 // This is intentionally a small, Syncthing-inspired subset.
 type Matcher struct {
 	rules []rule
+	only  []rule // whitelist set by Only
 }
 
 type rule struct {
@@ -62,6 +63,17 @@ func New(root string, ignoreDefaults bool, patterns []string, ignoreFiles ...str
 	return m
 }
 
+// Only restricts the matcher to the files matching one of globs (rule syntax without "!"):
+// any other file is ignored, directories are still walked. The rules then apply to the kept files.
+func (m *Matcher) Only(globs []string) *Matcher {
+	for _, glob := range globs {
+		if r, ok := parseRule(glob); ok && !r.include {
+			m.only = append(m.only, r)
+		}
+	}
+	return m
+}
+
 func (m *Matcher) Match(rel string, isDir bool) bool {
 	rel = strings.TrimSpace(rel)
 	if rel == "" || rel == "." {
@@ -69,6 +81,10 @@ func (m *Matcher) Match(rel string, isDir bool) bool {
 	}
 	rel = filepath.ToSlash(rel)
 
+	if !isDir && len(m.only) > 0 && !m.whitelisted(rel) {
+		return true
+	}
+
 	ignored := false
 	for _, r := range m.rules {
 		if r.matches(rel, isDir) {
@@ -82,6 +98,15 @@ func (m *Matcher) Match(rel string, isDir bool) bool {
 	return ignored
 }
 
+func (m *Matcher) whitelisted(rel string) bool {
+	for _, r := range m.only {
+		if r.matches(rel, false) {
+			return true
+		}
+	}
+	return false
+}
+
 func (r rule) matches(rel string, isDir bool) bool {
 	if r.re == nil {
 		return false
@@ -150,6 +175,12 @@ func globToRegexp(pattern string) string {
 		switch ch {
 		case '*':
 			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				// "**/" also matches no directory at all, so patterns apply at the top level
+				if i+2 < len(pattern) && pattern[i+2] == '/' {
+					b.WriteString("(?:.*/)?")
+					i += 2
+					continue
+				}
 				b.WriteString(".*")
 				i++
 				continue
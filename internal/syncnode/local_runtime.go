@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/mycoool/gohook/internal/stream"
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/host"
@@ -69,7 +70,12 @@ func StartLocalRuntimeBroadcaster(ctx context.Context) {
 				return
 			case <-ticker.C:
 				runtime := collectLocalRuntime(ctx)
-				broadcastWS(wsTypeSyncNodeStatus, map[string]any{"nodeId": 0, "runtime": runtime})
+				// runtime of this instance, not relayed to the others in HA mode
+				stream.Global.BroadcastLocal(stream.WsMessage{
+					Type:      wsTypeSyncNodeStatus,
+					Timestamp: time.Now(),
+					Data:      map[string]any{"nodeId": 0, "runtime": runtime},
+				})
 			}
 		}
 	}()
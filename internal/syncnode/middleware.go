@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/i18n"
 	"gorm.io/gorm"
 )
 
@@ -21,7 +22,7 @@ func AgentTokenMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := parseIDParam(c.Param("id"))
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
 			c.Abort()
 			return
 		}
@@ -35,7 +36,7 @@ func AgentTokenMiddleware() gin.HandlerFunc {
 			} else if errors.Is(err, gorm.ErrRecordNotFound) {
 				status = http.StatusNotFound
 			}
-			c.JSON(status, gin.H{"error": err.Error()})
+			i18n.JSONError(c, status, i18n.CodeAgentAuthFailed, err)
 			c.Abort()
 			return
 		}
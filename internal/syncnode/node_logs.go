@@ -0,0 +1,124 @@
+package syncnode
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"gorm.io/gorm"
+)
+
+// nodeLogsMsg is a batch of agent log lines and command output shipped by the agent.
+type nodeLogsMsg struct {
+	Type    string         `json:"type"`
+	Entries []nodeLogEntry `json:"entries"`
+	// Dropped counts lines the agent discarded because its buffer was full.
+	Dropped int `json:"dropped,omitempty"`
+}
+
+type nodeLogEntry struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Level   string    `json:"level"`
+	Command string    `json:"command,omitempty"`
+	Message string    `json:"message"`
+}
+
+// recordNodeLogs stores a shipped batch with the node identity.
+func recordNodeLogs(nodeID uint, agentName string, msg nodeLogsMsg) bool {
+	logs := make([]database.NodeLog, 0, len(msg.Entries)+1)
+	for _, e := range msg.Entries {
+		source := e.Source
+		if source != database.NodeLogSourceCommand {
+			source = database.NodeLogSourceAgent
+		}
+		level := strings.ToUpper(strings.TrimSpace(e.Level))
+		if level == "" {
+			level = "INFO"
+		}
+		entry := database.NodeLog{Source: source, Level: level, Command: e.Command, Message: e.Message}
+		entry.CreatedAt = e.Time
+		logs = append(logs, entry)
+	}
+	if msg.Dropped > 0 {
+		logs = append(logs, database.NodeLog{
+			Source:  database.NodeLogSourceAgent,
+			Level:   "WARN",
+			Message: fmt.Sprintf("%d log lines dropped on the node, the log buffer was full", msg.Dropped),
+		})
+	}
+	if err := database.SaveNodeLogs(nodeID, agentName, logs); err != nil {
+		log.Printf("syncnode: %v", err)
+		return false
+	}
+	return len(logs) > 0
+}
+
+// HandleGetNodeLogs lists the logs shipped by a node agent, with the filters of the log list
+// plus source (agent or command).
+func HandleGetNodeLogs(c *gin.Context) {
+	id, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
+		return
+	}
+	if _, err := defaultService.GetNode(c.Request.Context(), id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		i18n.JSONError(c, status, i18n.CodeLoadNodeFailed, err)
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+	source := c.Query("source")
+	if source != "" && source != database.NodeLogSourceAgent && source != database.NodeLogSourceCommand {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidLogSource, nil)
+		return
+	}
+
+	var startTime, endTime *time.Time
+	if startDate := c.Query("startDate"); startDate != "" {
+		if t, err := time.Parse(time.RFC3339, startDate); err == nil {
+			startTime = &t
+		}
+	}
+	if endDate := c.Query("endDate"); endDate != "" {
+		if t, err := time.Parse(time.RFC3339, endDate); err == nil {
+			endTime = &t
+		}
+	}
+
+	logs, total, err := database.NewLogService().GetNodeLogsForAPI(page, pageSize, id,
+		strings.ToUpper(c.Query("level")), source, c.Query("search"), startTime, endTime)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListLogsFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"logs":     logs,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+		"hasMore":  int64(page*pageSize) < total,
+	})
+}
@@ -1,6 +1,7 @@
 package syncnode
 
 import (
+	"strings"
 	"sync"
 	"time"
 )
@@ -15,6 +16,34 @@ type NodeRuntimeStatus struct {
 	Load1Percent    float64   `json:"load1Percent,omitempty"`
 	DiskUsedPercent float64   `json:"diskUsedPercent,omitempty"`
 	CPUCores        int       `json:"cpuCores,omitempty"`
+	MemTotalBytes   uint64    `json:"memTotalBytes,omitempty"`
+	MemAvailBytes   uint64    `json:"memAvailBytes,omitempty"`
+	WorkDirFree     uint64    `json:"workDirFree,omitempty"`
+	WorkDirTotal    uint64    `json:"workDirTotal,omitempty"`
+	AgentUptimeSec  uint64    `json:"agentUptimeSec,omitempty"`
+	SpoolSize       int       `json:"spoolSize,omitempty"`
+}
+
+// resource thresholds above which a node is reported as unhealthy
+const (
+	runtimeMemWarnPercent     = 90
+	runtimeLoadWarnPercent    = 100
+	runtimeWorkDirFreePercent = 10
+)
+
+// Warnings lists resource conditions that make the node a risky deploy target.
+func (st NodeRuntimeStatus) Warnings() []string {
+	var out []string
+	if st.MemUsedPercent >= runtimeMemWarnPercent {
+		out = append(out, "memory_high")
+	}
+	if st.Load1Percent >= runtimeLoadWarnPercent {
+		out = append(out, "load_high")
+	}
+	if st.WorkDirTotal > 0 && float64(st.WorkDirFree)*100/float64(st.WorkDirTotal) < runtimeWorkDirFreePercent {
+		out = append(out, "disk_low")
+	}
+	return out
 }
 
 var runtimeRegistry = struct {
@@ -39,3 +68,30 @@ func getRuntimeStatus(nodeID uint) (NodeRuntimeStatus, bool) {
 	runtimeRegistry.mu.RUnlock()
 	return st, ok
 }
+
+// recordNodeStatus stores a node_status frame from an agent and pushes it to UI clients.
+func recordNodeStatus(st nodeStatusMsg) {
+	updated := time.Now()
+	if ts, err := time.Parse(time.RFC3339, strings.TrimSpace(st.UpdatedAt)); err == nil {
+		updated = ts
+	}
+	rs := NodeRuntimeStatus{
+		UpdatedAt:       updated,
+		Hostname:        strings.TrimSpace(st.Hostname),
+		UptimeSec:       st.UptimeSec,
+		CPUPercent:      st.CPUPercent,
+		MemUsedPercent:  st.MemUsedPercent,
+		Load1:           st.Load1,
+		Load1Percent:    st.Load1Percent,
+		DiskUsedPercent: st.DiskUsedPercent,
+		CPUCores:        st.CPUCores,
+		MemTotalBytes:   st.MemTotalBytes,
+		MemAvailBytes:   st.MemAvailBytes,
+		WorkDirFree:     st.WorkDirFree,
+		WorkDirTotal:    st.WorkDirTotal,
+		AgentUptimeSec:  st.AgentUptimeSec,
+		SpoolSize:       st.SpoolSize,
+	}
+	setRuntimeStatus(st.NodeID, rs)
+	broadcastWS(wsTypeSyncNodeStatus, map[string]any{"nodeId": st.NodeID, "runtime": rs, "warnings": rs.Warnings()})
+}
@@ -0,0 +1,19 @@
+package syncnode
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNodeRuntimeStatusWarnings(t *testing.T) {
+	healthy := NodeRuntimeStatus{MemUsedPercent: 40, Load1Percent: 20, WorkDirFree: 50, WorkDirTotal: 100}
+	if w := healthy.Warnings(); len(w) != 0 {
+		t.Fatalf("expected no warnings, got %v", w)
+	}
+
+	busy := NodeRuntimeStatus{MemUsedPercent: 95, Load1Percent: 150, WorkDirFree: 5, WorkDirTotal: 100}
+	want := []string{"memory_high", "load_high", "disk_low"}
+	if w := busy.Warnings(); !reflect.DeepEqual(w, want) {
+		t.Fatalf("unexpected warnings: %v, want %v", w, want)
+	}
+}
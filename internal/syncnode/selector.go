@@ -0,0 +1,135 @@
+package syncnode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Selector matches nodes by their labels, e.g. "role=web && region=eu".
+//
+// Terms are key=value, key!=value, key (label present or tag key) and !key (neither).
+// && binds tighter than ||; there are no parentheses.
+type Selector struct {
+	expr string
+	any  [][]selectorTerm // OR of AND groups
+}
+
+type selectorTerm struct {
+	key   string
+	value string
+	op    string // "=", "!=", "exists" or "!exists"
+}
+
+// ParseSelector parses a selector expression, an empty expression is an error.
+func ParseSelector(expr string) (*Selector, error) {
+	sel := &Selector{expr: strings.TrimSpace(expr)}
+	if sel.expr == "" {
+		return nil, fmt.Errorf("empty selector")
+	}
+	for _, group := range strings.Split(sel.expr, "||") {
+		var terms []selectorTerm
+		for _, raw := range strings.Split(group, "&&") {
+			term, err := parseSelectorTerm(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid selector %q: %v", sel.expr, err)
+			}
+			terms = append(terms, term)
+		}
+		sel.any = append(sel.any, terms)
+	}
+	return sel, nil
+}
+
+func parseSelectorTerm(raw string) (selectorTerm, error) {
+	raw = strings.TrimSpace(raw)
+	var t selectorTerm
+	switch {
+	case raw == "":
+		return t, fmt.Errorf("empty term")
+	case strings.Contains(raw, "!="):
+		k, v, _ := strings.Cut(raw, "!=")
+		t = selectorTerm{key: strings.TrimSpace(k), value: strings.TrimSpace(v), op: "!="}
+	case strings.Contains(raw, "="):
+		k, v, _ := strings.Cut(strings.Replace(raw, "==", "=", 1), "=")
+		t = selectorTerm{key: strings.TrimSpace(k), value: strings.TrimSpace(v), op: "="}
+	case strings.HasPrefix(raw, "!"):
+		t = selectorTerm{key: strings.TrimSpace(raw[1:]), op: "!exists"}
+	default:
+		t = selectorTerm{key: raw, op: "exists"}
+	}
+	if err := validateLabel(t.key, t.value); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// String returns the selector expression.
+func (s *Selector) String() string {
+	return s.expr
+}
+
+// Matches reports whether a node with labels and tags is selected.
+func (s *Selector) Matches(labels map[string]string, tags []string) bool {
+	for _, group := range s.any {
+		ok := true
+		for _, t := range group {
+			if !t.matches(labels, tags) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (t selectorTerm) matches(labels map[string]string, tags []string) bool {
+	value, has := labels[t.key]
+	switch t.op {
+	case "=":
+		return has && value == t.value
+	case "!=":
+		return !has || value != t.value
+	}
+	if !has {
+		for _, tag := range tags {
+			if tag == t.key {
+				has = true
+				break
+			}
+		}
+	}
+	if t.op == "!exists" {
+		return !has
+	}
+	return has
+}
+
+// validateLabel checks a label key and value: the key is required and neither may contain
+// whitespace or selector operators.
+func validateLabel(key, value string) error {
+	if key == "" {
+		return fmt.Errorf("label key is required")
+	}
+	for _, s := range []string{key, value} {
+		if len(s) > 63 {
+			return fmt.Errorf("label %q is longer than 63 characters", s)
+		}
+		if strings.ContainsAny(s, "=!&|() \t\r\n,") {
+			return fmt.Errorf("label %q contains whitespace or one of =!&|(),", s)
+		}
+	}
+	return nil
+}
+
+// validateLabels checks every label of a node.
+func validateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if err := validateLabel(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
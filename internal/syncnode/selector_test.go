@@ -0,0 +1,109 @@
+package syncnode
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{"role": "web", "region": "eu"}
+	tags := []string{"canary"}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"role=web", true},
+		{"role==web", true},
+		{"role=db", false},
+		{"role=web && region=eu", true},
+		{"role=web && region=us", false},
+		{"role=db || region=eu", true},
+		{"role!=db", true},
+		{"zone!=a", true},
+		{"region", true},
+		{"canary", true},
+		{"!canary", false},
+		{"!zone && role=web", true},
+	}
+	for _, tt := range tests {
+		sel, err := ParseSelector(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseSelector(%q) error = %v", tt.expr, err)
+		}
+		if got := sel.Matches(labels, tags); got != tt.want {
+			t.Errorf("%q.Matches() = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseSelectorErrors(t *testing.T) {
+	for _, expr := range []string{"", "role=web &&", "=web", "role=we b", "|| role=web", "role=(web)"} {
+		if _, err := ParseSelector(expr); err == nil {
+			t.Errorf("ParseSelector(%q) expected an error", expr)
+		}
+	}
+}
+
+func TestValidateSyncNodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		node    types.ProjectSyncNodeConfig
+		wantErr bool
+	}{
+		{"node id", types.ProjectSyncNodeConfig{NodeID: "1"}, false},
+		{"target", types.ProjectSyncNodeConfig{Target: "role=web"}, false},
+		{"both", types.ProjectSyncNodeConfig{NodeID: "1", Target: "role=web"}, true},
+		{"neither", types.ProjectSyncNodeConfig{}, true},
+		{"bad id", types.ProjectSyncNodeConfig{NodeID: "web"}, true},
+		{"bad target", types.ProjectSyncNodeConfig{Target: "role=web &&"}, true},
+	}
+	for _, tt := range tests {
+		cfg := &types.ProjectSyncConfig{Nodes: []types.ProjectSyncNodeConfig{tt.node}}
+		if err := ValidateSyncNodes(cfg); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestResolveSyncTargets(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&database.SyncNode{}); err != nil {
+		t.Fatal(err)
+	}
+	nodes := []database.SyncNode{
+		{Name: "web-eu", Labels: `{"role":"web","region":"eu"}`},
+		{Name: "web-us", Labels: `{"role":"web","region":"us"}`},
+		{Name: "db-eu", Labels: `{"role":"db","region":"eu"}`},
+		{Name: "web-old", Labels: `{"role":"web","region":"eu"}`, ApprovalStatus: ApprovalStatusRevoked},
+	}
+	for i := range nodes {
+		if err := db.Create(&nodes[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &types.ProjectSyncConfig{Nodes: []types.ProjectSyncNodeConfig{
+		{NodeID: "3", TargetPath: "/db"},
+		{Target: "region=eu", TargetPath: "/eu"},
+		{Target: "role=cache", TargetPath: "/cache"},
+	}}
+	targets, err := resolveSyncTargets(context.Background(), db, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, target := range targets {
+		got = append(got, target.Node.Name+":"+target.Config.TargetPath)
+	}
+	if len(got) != 2 || got[0] != "db-eu:/db" || got[1] != "web-eu:/eu" {
+		t.Errorf("resolveSyncTargets() = %v", got)
+	}
+
+	cfg.Nodes = append(cfg.Nodes, types.ProjectSyncNodeConfig{NodeID: "99"})
+	if targets, err := resolveSyncTargets(context.Background(), db, cfg); err == nil || len(targets) != 2 {
+		t.Errorf("resolveSyncTargets() with a missing node = %d targets, %v", len(targets), err)
+	}
+}
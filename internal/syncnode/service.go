@@ -49,9 +49,11 @@ func NewService() *Service {
 
 // NodeListFilter filters list queries
 type NodeListFilter struct {
-	Status string
-	Type   string
-	Search string
+	Status   string
+	Type     string
+	Search   string
+	Approval string
+	Selector *Selector // nil lists every node
 }
 
 // CreateNodeRequest payload
@@ -65,6 +67,7 @@ type CreateNodeRequest struct {
 	AuthType      string                 `json:"authType"`
 	CredentialRef string                 `json:"credentialRef"`
 	Tags          []string               `json:"tags"`
+	Labels        map[string]string      `json:"labels"`
 	Metadata      map[string]interface{} `json:"metadata"`
 }
 
@@ -79,6 +82,7 @@ type UpdateNodeRequest struct {
 	AuthType      string                 `json:"authType"`
 	CredentialRef string                 `json:"credentialRef"`
 	Tags          []string               `json:"tags"`
+	Labels        map[string]string      `json:"labels"` // nil keeps the labels, {} clears them
 	Metadata      map[string]interface{} `json:"metadata"`
 }
 
@@ -116,6 +120,13 @@ func (s *Service) ListNodes(ctx context.Context, filter NodeListFilter) ([]datab
 	if filter.Type != "" {
 		query = query.Where("type = ?", filter.Type)
 	}
+	if filter.Approval != "" {
+		if filter.Approval == ApprovalStatusApproved {
+			query = query.Where("approval_status = ? OR approval_status = '' OR approval_status IS NULL", filter.Approval)
+		} else {
+			query = query.Where("approval_status = ?", filter.Approval)
+		}
+	}
 	if filter.Search != "" {
 		like := "%" + filter.Search + "%"
 		query = query.Where("name LIKE ? OR address LIKE ? OR remark LIKE ?", like, like, like)
@@ -125,9 +136,23 @@ func (s *Service) ListNodes(ctx context.Context, filter NodeListFilter) ([]datab
 	if err := query.Order("created_at DESC").Find(&nodes).Error; err != nil {
 		return nil, err
 	}
+	if filter.Selector != nil {
+		nodes = selectNodes(nodes, filter.Selector)
+	}
 	return nodes, nil
 }
 
+// selectNodes keeps the nodes matched by sel.
+func selectNodes(nodes []database.SyncNode, sel *Selector) []database.SyncNode {
+	out := nodes[:0]
+	for _, node := range nodes {
+		if sel.Matches(decodeLabels(node.Labels), decodeStringSlice(node.Tags)) {
+			out = append(out, node)
+		}
+	}
+	return out
+}
+
 // GetNode fetches a single node
 func (s *Service) GetNode(ctx context.Context, id uint) (*database.SyncNode, error) {
 	db, err := s.ensureDB()
@@ -233,6 +258,11 @@ func (s *Service) RotateToken(ctx context.Context, id uint) (*database.SyncNode,
 		return nil, fmt.Errorf("failed to generate node token: %w", err)
 	}
 	node.CredentialValue = token
+	if node.ApprovalStatus == ApprovalStatusRevoked {
+		// a new token re-opens enrollment for a revoked node
+		node.ApprovalStatus = ""
+		node.RevokedAt = nil
+	}
 	if err := db.WithContext(ctx).Save(node).Error; err != nil {
 		return nil, err
 	}
@@ -504,6 +534,7 @@ func (s *Service) applyCreateRequest(node *database.SyncNode, req CreateNodeRequ
 	node.AuthType = normalizeAuthType(node.Type, req.AuthType)
 	node.CredentialRef = req.CredentialRef
 	node.Tags = encodeStringSlice(req.Tags)
+	node.Labels = encodeLabels(req.Labels)
 	node.Metadata = encodeMap(req.Metadata)
 }
 
@@ -551,6 +582,9 @@ func (s *Service) applyUpdateRequest(node *database.SyncNode, req UpdateNodeRequ
 	if req.Tags != nil {
 		node.Tags = encodeStringSlice(req.Tags)
 	}
+	if req.Labels != nil {
+		node.Labels = encodeLabels(req.Labels)
+	}
 	if req.Metadata != nil {
 		node.Metadata = encodeMap(req.Metadata)
 	}
@@ -620,6 +654,14 @@ func encodeStringSlice(values []string) string {
 	return string(raw)
 }
 
+func encodeLabels(values map[string]string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	raw, _ := json.Marshal(values)
+	return string(raw)
+}
+
 func encodeMap(values map[string]interface{}) string {
 	if len(values) == 0 {
 		return ""
@@ -673,6 +715,17 @@ func decodeStringSlice(raw string) []string {
 	return out
 }
 
+func decodeLabels(raw string) map[string]string {
+	out := map[string]string{}
+	if strings.TrimSpace(raw) == "" {
+		return out
+	}
+	if err := json.Unmarshal([]byte(raw), &out); err != nil {
+		return map[string]string{}
+	}
+	return out
+}
+
 func decodeMap(raw string) map[string]interface{} {
 	if strings.TrimSpace(raw) == "" {
 		return map[string]interface{}{}
@@ -692,12 +745,12 @@ func (req *CreateNodeRequest) Validate() error {
 	if strings.TrimSpace(req.Type) == "" {
 		req.Type = NodeTypeAgent
 	}
-	return nil
+	return validateLabels(req.Labels)
 }
 
 // ValidateUpdate ensures updates won't switch node to unsupported types.
 func (req UpdateNodeRequest) ValidateUpdate() error {
-	return nil
+	return validateLabels(req.Labels)
 }
 func (s *Service) ensureDB() (*gorm.DB, error) {
 	if s.db == nil {
@@ -4,13 +4,13 @@ import (
 	"database/sql"
 	"errors"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mycoool/gohook/internal/config"
 	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
 	"github.com/mycoool/gohook/internal/types"
 )
 
@@ -46,7 +46,7 @@ func HandleListSyncProjects(c *gin.Context) {
 
 	db := database.GetDB()
 	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDatabaseUnavailable, nil)
 		return
 	}
 
@@ -57,26 +57,15 @@ func HandleListSyncProjects(c *gin.Context) {
 			continue
 		}
 
-		nodeCfgs := project.Sync.Nodes
-		nodeIDs := make([]uint, 0, len(nodeCfgs))
+		// target selectors expand to the nodes they match now; unresolvable entries are left out
+		targets, _ := resolveSyncTargets(c.Request.Context(), db, project.Sync)
+		nodeIDs := make([]uint, 0, len(targets))
 		cfgByID := map[uint]types.ProjectSyncNodeConfig{}
-		for _, cfg := range nodeCfgs {
-			id, err := strconv.ParseUint(strings.TrimSpace(cfg.NodeID), 10, 64)
-			if err != nil || id == 0 {
-				continue
-			}
-			uid := uint(id)
-			nodeIDs = append(nodeIDs, uid)
-			cfgByID[uid] = cfg
-		}
-
 		nodesByID := map[uint]database.SyncNode{}
-		if len(nodeIDs) > 0 {
-			var nodes []database.SyncNode
-			_ = db.WithContext(c.Request.Context()).Where("id IN ?", nodeIDs).Find(&nodes).Error
-			for i := range nodes {
-				nodesByID[nodes[i].ID] = nodes[i]
-			}
+		for _, target := range targets {
+			nodeIDs = append(nodeIDs, target.Node.ID)
+			cfgByID[target.Node.ID] = target.Config
+			nodesByID[target.Node.ID] = target.Node
 		}
 
 		type lastTaskRow struct {
@@ -227,13 +216,13 @@ func HandleUpdateProjectSyncConfig(c *gin.Context) {
 		Sync types.ProjectSyncConfig `json:"sync" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
 	versionData := types.GoHookVersionData
 	if versionData == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "version data not initialized"})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeVersionConfigNotLoaded, nil)
 		return
 	}
 
@@ -245,13 +234,22 @@ func HandleUpdateProjectSyncConfig(c *gin.Context) {
 		}
 	}
 	if idx == -1 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+
+	if err := ValidateSyncNodes(&req.Sync); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidSyncConfig, err)
+		return
+	}
+	if err := ValidateRollout(req.Sync.Rollout); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidSyncConfig, err)
 		return
 	}
 
 	versionData.Projects[idx].Sync = &req.Sync
 	if err := config.SaveVersionConfig(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Save config failed: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
 		return
 	}
 
@@ -259,5 +257,5 @@ func HandleUpdateProjectSyncConfig(c *gin.Context) {
 	RefreshProjectWatchers()
 
 	broadcastWS(wsTypeSyncProjectEvent, syncProjectEvent{ProjectName: projectName, Event: "changed"})
-	c.JSON(http.StatusOK, gin.H{"message": "Sync config updated successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeSyncConfigUpdated), "code": i18n.CodeSyncConfigUpdated})
 }
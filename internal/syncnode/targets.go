@@ -0,0 +1,107 @@
+package syncnode
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/types"
+	"gorm.io/gorm"
+)
+
+// syncTarget is a node a project syncs to, with the config entry that selected it.
+type syncTarget struct {
+	Node   database.SyncNode
+	Config types.ProjectSyncNodeConfig
+}
+
+// ValidateSyncNodes checks that every sync node entry names a node id or a target selector.
+func ValidateSyncNodes(cfg *types.ProjectSyncConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	for i, nodeCfg := range cfg.Nodes {
+		hasID := strings.TrimSpace(nodeCfg.NodeID) != ""
+		hasTarget := strings.TrimSpace(nodeCfg.Target) != ""
+		switch {
+		case hasID && hasTarget:
+			return fmt.Errorf("sync node #%d: nodeId and target are exclusive", i)
+		case hasTarget:
+			if _, err := ParseSelector(nodeCfg.Target); err != nil {
+				return fmt.Errorf("sync node #%d: %v", i, err)
+			}
+		case hasID:
+			if id, err := strconv.ParseUint(strings.TrimSpace(nodeCfg.NodeID), 10, 64); err != nil || id == 0 {
+				return fmt.Errorf("sync node #%d: invalid node_id: %s", i, nodeCfg.NodeID)
+			}
+		default:
+			return fmt.Errorf("sync node #%d: nodeId or target is required", i)
+		}
+	}
+	return nil
+}
+
+// resolveSyncTargets expands the sync node entries of cfg: a node_id entry names one node, a
+// target entry every node its selector matches (possibly none), revoked nodes excepted. A node
+// selected by several entries keeps the first one. Entries that fail to resolve are skipped and
+// the first error is returned along with the resolved targets.
+func resolveSyncTargets(ctx context.Context, db *gorm.DB, cfg *types.ProjectSyncConfig) ([]syncTarget, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	var (
+		targets  []syncTarget
+		firstErr error
+		seen     = map[uint]bool{}
+		all      []database.SyncNode
+		loaded   bool
+	)
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, nodeCfg := range cfg.Nodes {
+		if expr := strings.TrimSpace(nodeCfg.Target); expr != "" {
+			sel, err := ParseSelector(expr)
+			if err != nil {
+				fail(err)
+				continue
+			}
+			if !loaded {
+				if err := db.WithContext(ctx).Order("id").Find(&all).Error; err != nil {
+					return targets, err
+				}
+				loaded = true
+			}
+			for _, node := range all {
+				if seen[node.ID] || node.ApprovalStatus == ApprovalStatusRevoked {
+					continue
+				}
+				if sel.Matches(decodeLabels(node.Labels), decodeStringSlice(node.Tags)) {
+					seen[node.ID] = true
+					targets = append(targets, syncTarget{Node: node, Config: nodeCfg})
+				}
+			}
+			continue
+		}
+
+		id64, err := strconv.ParseUint(strings.TrimSpace(nodeCfg.NodeID), 10, 64)
+		if err != nil || id64 == 0 {
+			fail(fmt.Errorf("invalid node_id: %s", nodeCfg.NodeID))
+			continue
+		}
+		var node database.SyncNode
+		if err := db.WithContext(ctx).First(&node, uint(id64)).Error; err != nil {
+			fail(fmt.Errorf("node %d: %w", id64, err))
+			continue
+		}
+		if !seen[node.ID] {
+			seen[node.ID] = true
+			targets = append(targets, syncTarget{Node: node, Config: nodeCfg})
+		}
+	}
+	return targets, firstErr
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
 	"gorm.io/gorm"
 )
 
@@ -40,7 +41,7 @@ type adminTaskResponse struct {
 func HandleListTasks(c *gin.Context) {
 	db := database.GetDB()
 	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDatabaseUnavailable, nil)
 		return
 	}
 
@@ -79,7 +80,7 @@ func HandleListTasks(c *gin.Context) {
 
 	var tasks []database.SyncTask
 	if err := query.Order("id DESC").Limit(limit).Find(&tasks).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListTasksFailed, err)
 		return
 	}
 
@@ -121,7 +122,7 @@ func HandleListTasks(c *gin.Context) {
 func HandleClearTasks(c *gin.Context) {
 	db := database.GetDB()
 	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDatabaseUnavailable, nil)
 		return
 	}
 
@@ -140,12 +141,12 @@ func HandleClearTasks(c *gin.Context) {
 		query = query.Where("status = ?", strings.ToLower(st))
 	} else if !includeActive {
 		// Default: only clear completed records.
-		query = query.Where("status IN ?", []string{"success", "failed"})
+		query = query.Where("status IN ?", []string{"success", "failed", "cancelled"})
 	}
 
 	res := query.Delete(&database.SyncTask{})
 	if res.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeClearTasksFailed, res.Error)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"deleted": res.RowsAffected})
@@ -157,13 +158,13 @@ func HandleClearTasks(c *gin.Context) {
 func HandleGetTask(c *gin.Context) {
 	db := database.GetDB()
 	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDatabaseUnavailable, nil)
 		return
 	}
 
 	id, err := parseIDParam(c.Param("id"))
 	if err != nil || id == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
 		return
 	}
 	includeLogs := true
@@ -174,10 +175,10 @@ func HandleGetTask(c *gin.Context) {
 	var t database.SyncTask
 	if err := db.WithContext(c.Request.Context()).First(&t, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+			i18n.JSONError(c, http.StatusNotFound, i18n.CodeTaskNotFound, nil)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadTaskFailed, err)
 		return
 	}
 	resp := adminTaskResponse{
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
 	"gorm.io/gorm"
 )
 
@@ -45,7 +46,7 @@ func HandleRunProjectSync(c *gin.Context) {
 	projectName := c.Param("name")
 	tasks, err := defaultTaskService.CreateProjectTasks(c.Request.Context(), projectName)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeCreateTasksFailed, err)
 		return
 	}
 	out := make([]taskResponse, 0, len(tasks))
@@ -59,7 +60,7 @@ func HandleRunProjectSync(c *gin.Context) {
 func HandlePullTask(c *gin.Context) {
 	id, err := parseIDParam(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
 		return
 	}
 	task, err := defaultTaskService.PullNextTask(c.Request.Context(), id)
@@ -68,7 +69,7 @@ func HandlePullTask(c *gin.Context) {
 			c.Status(http.StatusNoContent)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodePullTaskFailed, err)
 		return
 	}
 	c.JSON(http.StatusOK, mapTask(task))
@@ -78,22 +79,22 @@ func HandlePullTask(c *gin.Context) {
 func HandleReportTask(c *gin.Context) {
 	nodeID, err := parseIDParam(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
 		return
 	}
 	taskID, err := parseIDParam(c.Param("taskId"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
 		return
 	}
 	var req TaskReport
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 	task, err := defaultTaskService.ReportTask(c.Request.Context(), nodeID, taskID, req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeReportTaskFailed, err)
 		return
 	}
 	c.JSON(http.StatusOK, mapTask(task))
@@ -103,18 +104,18 @@ func HandleReportTask(c *gin.Context) {
 func HandleDownloadBundle(c *gin.Context) {
 	nodeID, err := parseIDParam(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
 		return
 	}
 	taskID, err := parseIDParam(c.Param("taskId"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidID, nil)
 		return
 	}
 
 	db := database.GetDB()
 	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDatabaseUnavailable, nil)
 		return
 	}
 	var task database.SyncTask
@@ -123,11 +124,11 @@ func HandleDownloadBundle(c *gin.Context) {
 		if err == gorm.ErrRecordNotFound {
 			status = http.StatusNotFound
 		}
-		c.JSON(status, gin.H{"error": err.Error()})
+		i18n.JSONError(c, status, i18n.CodeLoadTaskFailed, err)
 		return
 	}
 	if task.NodeID != nodeID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "task does not belong to node"})
+		i18n.JSONError(c, http.StatusForbidden, i18n.CodeTaskNotOfNode, nil)
 		return
 	}
 
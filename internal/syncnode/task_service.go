@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -23,14 +24,16 @@ import (
 )
 
 const (
-	TaskStatusPending  = "pending"
-	TaskStatusRetrying = "retrying"
-	TaskStatusRunning  = "running"
-	TaskStatusSuccess  = "success"
-	TaskStatusFailed   = "failed"
-	TaskDriverAgent    = "agent"
-	TaskDriverRsync    = "rsync"
-	defaultBundleLimit = int64(1024 * 1024 * 1024) // 1GiB safety cap
+	TaskStatusWaiting   = "waiting" // held back by the rollout strategy of its deployment
+	TaskStatusPending   = "pending"
+	TaskStatusRetrying  = "retrying"
+	TaskStatusRunning   = "running"
+	TaskStatusSuccess   = "success"
+	TaskStatusFailed    = "failed"
+	TaskStatusCancelled = "cancelled" // deployment aborted before the task started
+	TaskDriverAgent     = "agent"
+	TaskDriverRsync     = "rsync"
+	defaultBundleLimit  = int64(1024 * 1024 * 1024) // 1GiB safety cap
 )
 
 type TaskService struct {
@@ -46,6 +49,7 @@ type TaskPayload struct {
 	TargetPath              string   `json:"targetPath"`
 	Strategy                string   `json:"strategy"` // mirror | overlay
 	IgnoreDefaults          bool     `json:"ignoreDefaults"`
+	Include                 []string `json:"include,omitempty"` // only files matching these globs are synced
 	IgnorePatterns          []string `json:"ignorePatterns,omitempty"`
 	IgnoreFile              string   `json:"ignoreFile,omitempty"`
 	IgnoreFiles             []string `json:"ignoreFiles,omitempty"`
@@ -57,6 +61,7 @@ type TaskPayload struct {
 	MirrorFastFullscanEvery int      `json:"mirrorFastFullscanEvery,omitempty"`
 	MirrorCleanEmptyDirs    bool     `json:"mirrorCleanEmptyDirs,omitempty"`
 	MirrorSyncEmptyDirs     bool     `json:"mirrorSyncEmptyDirs,omitempty"`
+	MinFreeBytes            int64    `json:"minFreeBytes,omitempty"` // preflight: free space required on the target
 }
 
 type IndexFileEntry struct {
@@ -91,9 +96,17 @@ func (s *TaskService) ensureDB() (*gorm.DB, error) {
 	return s.db, nil
 }
 
+// CreateProjectTasks starts a deployment of the project with its configured rollout.
 func (s *TaskService) CreateProjectTasks(ctx context.Context, projectName string) ([]database.SyncTask, error) {
+	_, tasks, err := s.CreateDeployment(ctx, projectName, DeploymentOptions{})
+	return tasks, err
+}
+
+// CreateDeployment queues a task per target node of the project, grouped in a deployment that
+// releases them according to the rollout strategy.
+func (s *TaskService) CreateDeployment(ctx context.Context, projectName string, opts DeploymentOptions) (*database.SyncDeployment, []database.SyncTask, error) {
 	if types.GoHookVersionData == nil {
-		return nil, errors.New("version config not loaded")
+		return nil, nil, errors.New("version config not loaded")
 	}
 	var project *types.ProjectConfig
 	for i := range types.GoHookVersionData.Projects {
@@ -103,39 +116,54 @@ func (s *TaskService) CreateProjectTasks(ctx context.Context, projectName string
 		}
 	}
 	if project == nil {
-		return nil, fmt.Errorf("project not found: %s", projectName)
+		return nil, nil, fmt.Errorf("project not found: %s", projectName)
 	}
 	if project.Sync == nil || !project.Sync.Enabled {
-		return nil, fmt.Errorf("project sync not enabled: %s", projectName)
+		return nil, nil, fmt.Errorf("project sync not enabled: %s", projectName)
 	}
 	if len(project.Sync.Nodes) == 0 {
-		return nil, fmt.Errorf("project has no sync nodes: %s", projectName)
+		return nil, nil, fmt.Errorf("project has no sync nodes: %s", projectName)
 	}
 
 	db, err := s.ensureDB()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	var created []database.SyncTask
-	for _, nodeCfg := range project.Sync.Nodes {
-		id64, parseErr := strconv.ParseUint(strings.TrimSpace(nodeCfg.NodeID), 10, 64)
-		if parseErr != nil || id64 == 0 {
-			return nil, fmt.Errorf("invalid node_id: %s", nodeCfg.NodeID)
-		}
-		nodeID := uint(id64)
+	targets, err := resolveSyncTargets(ctx, db, project.Sync)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(targets) == 0 {
+		return nil, nil, fmt.Errorf("no sync node matches the targets of project: %s", projectName)
+	}
 
-		var node database.SyncNode
-		if err := db.WithContext(ctx).First(&node, nodeID).Error; err != nil {
-			return nil, err
-		}
+	rollout, err := rolloutFor(project.Sync, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	dep := database.SyncDeployment{
+		ProjectName:    projectName,
+		Strategy:       rollout.Strategy,
+		MaxUnavailable: rollout.MaxUnavailable,
+		Status:         DeploymentRunning,
+		CreatedBy:      opts.CreatedBy,
+	}
+	if err := db.WithContext(ctx).Create(&dep).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var created []database.SyncTask
+	for _, target := range targets {
+		node, nodeCfg := target.Node, target.Config
 
 		payload := TaskPayload{
 			ProjectName:             projectName,
 			TargetPath:              nodeCfg.TargetPath,
 			Strategy:                defaultStrategy(nodeCfg.Strategy),
 			IgnoreDefaults:          project.Sync.IgnoreDefaults,
-			IgnorePatterns:          append(append([]string{}, project.Sync.IgnorePatterns...), nodeCfg.IgnorePatterns...),
+			Include:                 nodeCfg.Include,
+			IgnorePatterns:          nodeIgnorePatterns(project.Sync, nodeCfg),
 			IgnoreFiles:             []string{project.Sync.IgnoreFile, nodeCfg.IgnoreFile},
 			IgnorePermissions:       project.Sync.IgnorePermissions,
 			PreserveMode:            effectivePreserveMode(project.Sync),
@@ -145,23 +173,36 @@ func (s *TaskService) CreateProjectTasks(ctx context.Context, projectName string
 			MirrorFastFullscanEvery: nodeCfg.MirrorFastFullscanEvery,
 			MirrorCleanEmptyDirs:    nodeCfg.MirrorCleanEmptyDirs,
 			MirrorSyncEmptyDirs:     nodeCfg.MirrorSyncEmptyDirs,
+			MinFreeBytes:            project.Preflight.MinFreeBytes(),
 		}
 		raw, _ := json.Marshal(payload)
 
 		task := database.SyncTask{
-			ProjectName: projectName,
-			NodeID:      nodeID,
-			NodeName:    node.Name,
-			Driver:      TaskDriverAgent,
-			Status:      TaskStatusPending,
-			Payload:     string(raw),
+			ProjectName:  projectName,
+			NodeID:       node.ID,
+			NodeName:     node.Name,
+			Driver:       TaskDriverAgent,
+			Status:       TaskStatusWaiting,
+			Payload:      string(raw),
+			DeploymentID: dep.ID,
 		}
 		if err := db.WithContext(ctx).Create(&task).Error; err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		created = append(created, task)
 	}
 
+	if err := s.advanceDeployment(ctx, dep.ID); err != nil {
+		return nil, nil, err
+	}
+	// pick up the statuses set by the rollout
+	if err := db.WithContext(ctx).First(&dep, dep.ID).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := db.WithContext(ctx).Where("deployment_id = ?", dep.ID).Order("id ASC").Find(&created).Error; err != nil {
+		return nil, nil, err
+	}
+
 	if len(created) > 0 {
 		taskIDs := make([]uint, 0, len(created))
 		for i := range created {
@@ -172,10 +213,23 @@ func (s *TaskService) CreateProjectTasks(ctx context.Context, projectName string
 			"projectName": projectName,
 			"taskIds":     taskIDs,
 		})
+		broadcastWS(wsTypeSyncDeploymentEvent, syncDeploymentEvent{
+			DeploymentID: dep.ID,
+			ProjectName:  projectName,
+			Status:       dep.Status,
+			Event:        "created",
+		})
 		broadcastWS(wsTypeSyncProjectEvent, syncProjectEvent{ProjectName: projectName, Event: "tasks"})
 	}
 
-	return created, nil
+	return &dep, created, nil
+}
+
+// nodeIgnorePatterns merges project and per-node ignore globs, exclude globs are ignored on the node too.
+func nodeIgnorePatterns(cfg *types.ProjectSyncConfig, nodeCfg types.ProjectSyncNodeConfig) []string {
+	out := append([]string{}, cfg.IgnorePatterns...)
+	out = append(out, nodeCfg.IgnorePatterns...)
+	return append(out, nodeCfg.Exclude...)
 }
 
 func effectivePreserveMode(cfg *types.ProjectSyncConfig) bool {
@@ -385,9 +439,45 @@ func (s *TaskService) ReportTask(ctx context.Context, nodeID, taskID uint, repor
 		Event:       "reported",
 	})
 	broadcastWS(wsTypeSyncProjectEvent, syncProjectEvent{ProjectName: task.ProjectName, Event: "tasks"})
+	if task.DeploymentID != 0 {
+		if err := s.advanceDeployment(ctx, task.DeploymentID); err != nil {
+			log.Printf("syncnode: advance deployment %d failed: %v", task.DeploymentID, err)
+		}
+	}
 	return &task, nil
 }
 
+// ApplySpooledReport records a report the agent could not deliver before disconnecting.
+// Only tasks requeued by that disconnect are updated; anything else has moved on already.
+func (s *TaskService) ApplySpooledReport(ctx context.Context, nodeID uint, rep taskReportMsg) bool {
+	db, err := s.ensureDB()
+	if err != nil || rep.TaskID == 0 {
+		return false
+	}
+	var task database.SyncTask
+	if err := db.WithContext(ctx).Select("id, node_id, status").First(&task, rep.TaskID).Error; err != nil {
+		return false
+	}
+	if task.NodeID != nodeID || task.Status != TaskStatusRetrying {
+		return false
+	}
+	status := "failed"
+	if strings.EqualFold(rep.Status, "success") {
+		status = "success"
+	}
+	_, err = s.ReportTask(ctx, nodeID, rep.TaskID, TaskReport{
+		Status:     status,
+		Logs:       rep.Logs,
+		LastError:  rep.LastError,
+		ErrorCode:  rep.ErrorCode,
+		Files:      rep.Files,
+		Blocks:     rep.Blocks,
+		Bytes:      rep.Bytes,
+		DurationMs: rep.DurationMs,
+	})
+	return err == nil
+}
+
 // FailStaleRunningTasks marks long-running tasks as failed to avoid "RUNNING forever" when a connection drops.
 func (s *TaskService) FailStaleRunningTasks(ctx context.Context, maxAge time.Duration) {
 	if maxAge <= 0 {
@@ -408,6 +498,7 @@ func (s *TaskService) FailStaleRunningTasks(ctx context.Context, maxAge time.Dur
 		})
 	if res.Error == nil && res.RowsAffected > 0 {
 		broadcastWS(wsTypeSyncTaskEvent, syncTaskEvent{Event: "reaped"})
+		s.advanceOpenDeployments(ctx)
 	}
 }
 
@@ -900,7 +991,7 @@ func newIgnoreMatcher(payload TaskPayload, root string) *ignoreMatcher {
 		files = append(files, payload.IgnoreFile)
 	}
 	return &ignoreMatcher{
-		m: syncignore.New(root, payload.IgnoreDefaults, payload.IgnorePatterns, files...),
+		m: syncignore.New(root, payload.IgnoreDefaults, payload.IgnorePatterns, files...).Only(payload.Include),
 	}
 }
 
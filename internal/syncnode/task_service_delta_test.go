@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -293,3 +294,38 @@ func writeFile(t *testing.T, path, content string) {
 		t.Fatal(err)
 	}
 }
+
+func TestNodeIgnorePatternsIncludesExclude(t *testing.T) {
+	cfg := &types.ProjectSyncConfig{IgnorePatterns: []string{"*.log"}}
+	nodeCfg := types.ProjectSyncNodeConfig{IgnorePatterns: []string{"tmp/"}, Exclude: []string{"uploads/**"}}
+	got := nodeIgnorePatterns(cfg, nodeCfg)
+	want := []string{"*.log", "tmp/", "uploads/**"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected patterns: %v, want %v", got, want)
+	}
+	if len(cfg.IgnorePatterns) != 1 {
+		t.Fatalf("project patterns mutated: %v", cfg.IgnorePatterns)
+	}
+}
+
+func TestIgnoreMatcherInclude(t *testing.T) {
+	payload := TaskPayload{Include: []string{"public/", "*.conf"}, IgnorePatterns: []string{"public/cache/"}}
+	ig := newIgnoreMatcher(payload, t.TempDir())
+	tests := []struct {
+		rel     string
+		isDir   bool
+		ignored bool
+	}{
+		{"public/index.html", false, false},
+		{"etc/app.conf", false, false},
+		{"app.conf", false, false},
+		{"src/main.go", false, true},
+		{"src", true, false}, // directories are still walked for whitelisted files
+		{"public/cache/a.html", false, true},
+	}
+	for _, tt := range tests {
+		if got := ig.Match(tt.rel, tt.isDir); got != tt.ignored {
+			t.Errorf("Match(%q, %v) = %v, want %v", tt.rel, tt.isDir, got, tt.ignored)
+		}
+	}
+}
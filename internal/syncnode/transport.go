@@ -27,13 +27,21 @@ type helloMessage struct {
 	AgentName    string   `json:"agentName,omitempty"`
 	AgentVersion string   `json:"agentVersion,omitempty"`
 	Features     []string `json:"features,omitempty"`
+	// self-update support: platform and the last failed update attempt
+	OS            string `json:"os,omitempty"`
+	Arch          string `json:"arch,omitempty"`
+	UpdateVersion string `json:"updateVersion,omitempty"`
+	UpdateError   string `json:"updateError,omitempty"`
+	// Spooled is the number of reports the agent queued while offline, sent right after hello_ack.
+	Spooled int `json:"spooled,omitempty"`
 }
 
 type helloAck struct {
-	Type   string `json:"type"`
-	OK     bool   `json:"ok"`
-	Error  string `json:"error,omitempty"`
-	Server string `json:"server,omitempty"`
+	Type   string           `json:"type"`
+	OK     bool             `json:"ok"`
+	Error  string           `json:"error,omitempty"`
+	Server string           `json:"server,omitempty"`
+	Update *AgentUpdateInfo `json:"update,omitempty"`
 }
 
 type enrollMessage struct {
@@ -135,6 +143,70 @@ type nodeStatusMsg struct {
 	Load1Percent    float64 `json:"load1Percent,omitempty"`
 	DiskUsedPercent float64 `json:"diskUsedPercent,omitempty"`
 	CPUCores        int     `json:"cpuCores,omitempty"`
+	MemTotalBytes   uint64  `json:"memTotalBytes,omitempty"`
+	MemAvailBytes   uint64  `json:"memAvailBytes,omitempty"`
+	WorkDirFree     uint64  `json:"workDirFree,omitempty"`
+	WorkDirTotal    uint64  `json:"workDirTotal,omitempty"`
+	AgentUptimeSec  uint64  `json:"agentUptimeSec,omitempty"`
+	SpoolSize       int     `json:"spoolSize,omitempty"`
+}
+
+// spoolReadTimeout bounds each spooled frame read right after hello.
+const spoolReadTimeout = 10 * time.Second
+
+// receiveSpooledReports applies task and hook reports an agent queued while the server was unreachable.
+// The agent terminates the batch with a spool_done frame.
+func receiveSpooledReports(ctx context.Context, conn net.Conn, hello helloMessage) error {
+	defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
+	applied := 0
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(spoolReadTimeout))
+		frame, err := ReadStreamFrame(conn)
+		if err != nil {
+			return err
+		}
+		typ, ok := applyAgentReport(ctx, conn, hello, frame)
+		if typ == "spool_done" {
+			if applied > 0 {
+				log.Printf("syncnode: applied %d spooled reports from node %d", applied, hello.NodeID)
+			}
+			return nil
+		}
+		if ok {
+			applied++
+		}
+	}
+}
+
+// applyAgentReport handles a report frame the agent may send outside of a task: a spooled
+// task_report, a hook_report of a local hook or a node_logs batch. It returns the frame type
+// and whether a report was applied.
+func applyAgentReport(ctx context.Context, conn net.Conn, hello helloMessage, frame []byte) (string, bool) {
+	var base streamMessage
+	if err := json.Unmarshal(frame, &base); err != nil {
+		return "", false
+	}
+	switch base.Type {
+	case "task_report":
+		var rep taskReportMsg
+		if err := json.Unmarshal(frame, &rep); err != nil {
+			return base.Type, false
+		}
+		return base.Type, defaultTaskService.ApplySpooledReport(ctx, hello.NodeID, rep)
+	case "hook_report":
+		var rep hookReportMsg
+		if err := json.Unmarshal(frame, &rep); err != nil {
+			return base.Type, false
+		}
+		return base.Type, recordHookReport(hello.NodeID, hello.AgentName, conn.RemoteAddr().String(), rep)
+	case "node_logs":
+		var msg nodeLogsMsg
+		if err := json.Unmarshal(frame, &msg); err != nil {
+			return base.Type, false
+		}
+		return base.Type, recordNodeLogs(hello.NodeID, hello.AgentName, msg)
+	}
+	return base.Type, false
 }
 
 // StartAgentTCPServer starts a TLS-enabled TCP server for agent long connections.
@@ -292,7 +364,22 @@ func handleAgentConn(ctx context.Context, conn net.Conn) {
 		return
 	}
 
-	_ = WriteStreamMessage(conn, helloAck{Type: "hello_ack", OK: true, Server: "gohook"})
+	if err := svc.checkEnrollment(ctx, node, hello.AgentName); err != nil {
+		_ = WriteStreamMessage(conn, helloAck{Type: "hello_ack", OK: false, Error: err.Error()})
+		return
+	}
+
+	update := svc.offerAgentUpdate(ctx, hello.NodeID, hello)
+	_ = WriteStreamMessage(conn, helloAck{Type: "hello_ack", OK: true, Server: "gohook", Update: update})
+	registerActiveConn(hello.NodeID, conn)
+	defer unregisterActiveConn(hello.NodeID, conn)
+
+	if hello.Spooled > 0 {
+		if err := receiveSpooledReports(ctx, conn, hello); err != nil {
+			log.Printf("syncnode: receive spooled reports from node %d failed: %v", hello.NodeID, err)
+			return
+		}
+	}
 
 	// Heartbeat via TCP connection: mark online on connect, mark offline on close.
 	_ = svc.RecordTCPConnected(ctx, hello.NodeID, hello.AgentName, hello.AgentVersion, conn.RemoteAddr().String())
@@ -345,30 +432,21 @@ func handleAgentConn(ctx context.Context, conn net.Conn) {
 				_ = conn.SetWriteDeadline(time.Time{})
 				touchConn(hello.NodeID)
 
-				// Best-effort: read one status frame back (timeout is expected).
-				_ = conn.SetReadDeadline(time.Now().Add(600 * time.Millisecond))
-				var reply map[string]any
-				if err := ReadStreamMessage(conn, &reply); err == nil {
-					if typ, _ := reply["type"].(string); typ == "node_status" {
-						raw, _ := json.Marshal(reply)
+				// Best-effort: read the status frame back (timeout is expected). The agent sends
+				// its queued hook reports and logs ahead of the status, so keep reading until it arrives.
+				for {
+					_ = conn.SetReadDeadline(time.Now().Add(600 * time.Millisecond))
+					frame, err := ReadStreamFrame(conn)
+					if err != nil {
+						break
+					}
+					typ, _ := applyAgentReport(ctx, conn, hello, frame)
+					if typ == "node_status" {
 						var st nodeStatusMsg
-						if json.Unmarshal(raw, &st) == nil && st.NodeID != 0 {
-							updated := time.Now()
-							if ts, err := time.Parse(time.RFC3339, strings.TrimSpace(st.UpdatedAt)); err == nil {
-								updated = ts
-							}
-							rs := NodeRuntimeStatus{
-								UpdatedAt:       updated,
-								Hostname:        strings.TrimSpace(st.Hostname),
-								UptimeSec:       st.UptimeSec,
-								CPUPercent:      st.CPUPercent,
-								MemUsedPercent:  st.MemUsedPercent,
-								Load1:           st.Load1,
-								DiskUsedPercent: st.DiskUsedPercent,
-							}
-							setRuntimeStatus(st.NodeID, rs)
-							broadcastWS(wsTypeSyncNodeStatus, map[string]any{"nodeId": st.NodeID, "runtime": rs})
+						if json.Unmarshal(frame, &st) == nil && st.NodeID != 0 {
+							recordNodeStatus(st)
 						}
+						break
 					}
 				}
 				_ = conn.SetReadDeadline(time.Time{})
@@ -431,23 +509,7 @@ func handleAgentConn(ctx context.Context, conn net.Conn) {
 				raw, _ := json.Marshal(envelope)
 				var st nodeStatusMsg
 				if json.Unmarshal(raw, &st) == nil && st.NodeID != 0 {
-					updated := time.Now()
-					if ts, err := time.Parse(time.RFC3339, strings.TrimSpace(st.UpdatedAt)); err == nil {
-						updated = ts
-					}
-					rs := NodeRuntimeStatus{
-						UpdatedAt:       updated,
-						Hostname:        strings.TrimSpace(st.Hostname),
-						UptimeSec:       st.UptimeSec,
-						CPUPercent:      st.CPUPercent,
-						MemUsedPercent:  st.MemUsedPercent,
-						Load1:           st.Load1,
-						Load1Percent:    st.Load1Percent,
-						DiskUsedPercent: st.DiskUsedPercent,
-						CPUCores:        st.CPUCores,
-					}
-					setRuntimeStatus(st.NodeID, rs)
-					broadcastWS(wsTypeSyncNodeStatus, map[string]any{"nodeId": st.NodeID, "runtime": rs})
+					recordNodeStatus(st)
 				}
 				continue
 			case "sync_start":
@@ -655,23 +717,7 @@ func handleAgentConn(ctx context.Context, conn net.Conn) {
 				raw, _ := json.Marshal(envelope)
 				var st nodeStatusMsg
 				if json.Unmarshal(raw, &st) == nil && st.NodeID != 0 {
-					updated := time.Now()
-					if ts, err := time.Parse(time.RFC3339, strings.TrimSpace(st.UpdatedAt)); err == nil {
-						updated = ts
-					}
-					rs := NodeRuntimeStatus{
-						UpdatedAt:       updated,
-						Hostname:        strings.TrimSpace(st.Hostname),
-						UptimeSec:       st.UptimeSec,
-						CPUPercent:      st.CPUPercent,
-						MemUsedPercent:  st.MemUsedPercent,
-						Load1:           st.Load1,
-						Load1Percent:    st.Load1Percent,
-						DiskUsedPercent: st.DiskUsedPercent,
-						CPUCores:        st.CPUCores,
-					}
-					setRuntimeStatus(st.NodeID, rs)
-					broadcastWS(wsTypeSyncNodeStatus, map[string]any{"nodeId": st.NodeID, "runtime": rs})
+					recordNodeStatus(st)
 				}
 				continue
 			default:
@@ -915,21 +961,7 @@ func serveIndexChunk(ctx context.Context, conn net.Conn, nodeID uint, task datab
 			raw, _ := json.Marshal(envelope)
 			var st nodeStatusMsg
 			if json.Unmarshal(raw, &st) == nil && st.NodeID != 0 {
-				updated := time.Now()
-				if ts, err := time.Parse(time.RFC3339, strings.TrimSpace(st.UpdatedAt)); err == nil {
-					updated = ts
-				}
-				rs := NodeRuntimeStatus{
-					UpdatedAt:       updated,
-					Hostname:        strings.TrimSpace(st.Hostname),
-					UptimeSec:       st.UptimeSec,
-					CPUPercent:      st.CPUPercent,
-					MemUsedPercent:  st.MemUsedPercent,
-					Load1:           st.Load1,
-					DiskUsedPercent: st.DiskUsedPercent,
-				}
-				setRuntimeStatus(st.NodeID, rs)
-				broadcastWS(wsTypeSyncNodeStatus, map[string]any{"nodeId": st.NodeID, "runtime": rs})
+				recordNodeStatus(st)
 			}
 		default:
 			continue
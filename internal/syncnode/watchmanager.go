@@ -15,6 +15,7 @@ type WatchManager struct {
 	mu       sync.Mutex
 	scanners map[string]*watcher.Scanner
 	queue    ChangeQueue
+	running  bool // set by Start, Refresh does nothing until then (e.g. on HA followers)
 }
 
 var defaultWatchManager = &WatchManager{
@@ -41,6 +42,8 @@ func (m *WatchManager) Start() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.running = true
+
 	if m.queue == nil {
 		db := database.GetDB()
 		if db == nil {
@@ -93,6 +96,8 @@ func (m *WatchManager) Stop() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.running = false
+
 	for name, scanner := range m.scanners {
 		if scanner != nil {
 			_ = scanner.Close()
@@ -112,6 +117,9 @@ func (m *WatchManager) Refresh() {
 		}
 		delete(m.scanners, name)
 	}
+	if !m.running {
+		return
+	}
 
 	// Recreate based on the latest config.
 	// Reuse Start() logic by inlining here to keep single lock.
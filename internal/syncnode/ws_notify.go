@@ -11,11 +11,13 @@ const (
 	wsTypeSyncTaskEvent    = "sync_task_event"
 	wsTypeSyncProjectEvent = "sync_project_event"
 	wsTypeSyncNodeStatus   = "sync_node_status"
+
+	wsTypeSyncDeploymentEvent = "sync_deployment_event"
 )
 
 type syncNodeEvent struct {
 	NodeID uint   `json:"nodeId"`
-	Event  string `json:"event"` // created|updated|deleted|connected|disconnected|heartbeat
+	Event  string `json:"event"` // created|updated|deleted|connected|disconnected|heartbeat|enroll_pending|approved|revoked
 }
 
 type syncTaskEvent struct {
@@ -26,6 +28,13 @@ type syncTaskEvent struct {
 	Event       string `json:"event"` // created|running|reported|reaped
 }
 
+type syncDeploymentEvent struct {
+	DeploymentID uint   `json:"deploymentId"`
+	ProjectName  string `json:"projectName"`
+	Status       string `json:"status"`
+	Event        string `json:"event"` // created|status|updated
+}
+
 type syncProjectEvent struct {
 	ProjectName string `json:"projectName"`
 	Event       string `json:"event"` // changed|tasks
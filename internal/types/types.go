@@ -1,7 +1,13 @@
 package types
 
 import (
+	"fmt"
+	"net"
+	"path"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -9,9 +15,15 @@ import (
 
 // UserConfig user config structure
 type UserConfig struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
-	Role     string `yaml:"role"`
+	Username  string       `yaml:"username"`
+	Password  string       `yaml:"password"`
+	Role      string       `yaml:"role"`
+	Namespace string       `yaml:"namespace,omitempty"` // empty: not bound to a namespace, sees all of them
+	Quota     *QuotaConfig `yaml:"quota,omitempty"`     // limits of the hooks triggered manually by the user
+	Timezone  string       `yaml:"timezone,omitempty"`  // IANA zone of the timestamps in responses, default the server timezone
+	Language  string       `yaml:"language,omitempty"`  // panel language zh | en, default the server language
+	// DecryptLogs may read the body, output and error of hook logs encrypted at rest
+	DecryptLogs bool `yaml:"decrypt_logs,omitempty"`
 }
 
 // UsersConfig user config file structure (original AppConfig)
@@ -21,37 +33,642 @@ type UsersConfig struct {
 
 // AppConfig application config structure
 type AppConfig struct {
-	Port              int            `yaml:"port"`
-	JWTSecret         string         `yaml:"jwt_secret"`
-	JWTExpiryDuration int            `yaml:"jwt_expiry_duration"`
-	Mode              string         `yaml:"mode"` // "dev" | "prod" | "test"
-	Database          DatabaseConfig `yaml:"database"`
-	PanelAlias        string         `yaml:"panel_alias"` // 面板别名，用于浏览器标题
-	Language          string         `yaml:"language"`    // 语言设置: "en" | "zh"
+	Port              int                  `yaml:"port"`
+	JWTSecret         string               `yaml:"jwt_secret"`
+	JWTExpiryDuration int                  `yaml:"jwt_expiry_duration"`
+	Mode              string               `yaml:"mode"` // "dev" | "prod" | "test"
+	Database          DatabaseConfig       `yaml:"database"`
+	PanelAlias        string               `yaml:"panel_alias"`                  // 面板别名，用于浏览器标题
+	Language          string               `yaml:"language"`                     // 语言设置: "en" | "zh"
+	Timezone          string               `yaml:"timezone,omitempty"`           // IANA zone of timestamps in responses, default the system zone
+	EnvEncryptionKey  string               `yaml:"env_encryption_key,omitempty"` // key for encrypted .env storage, generated on first use
+	Maintenance       *MaintenanceConfig   `yaml:"maintenance,omitempty"`        // global maintenance mode
+	Execution         *ExecutionConfig     `yaml:"execution,omitempty"`          // worker pool of background hook executions
+	Namespaces        []NamespaceConfig    `yaml:"namespaces,omitempty"`         // tenants, DefaultNamespace always exists
+	Cluster           *ClusterConfig       `yaml:"cluster,omitempty"`            // high-availability mode
+	Scripts           *ScriptsConfig       `yaml:"scripts,omitempty"`            // hook scripts edited in the panel
+	HookEnv           *EnvPolicy           `yaml:"hook_env,omitempty"`           // environment inherited by hook commands, hooks may override it
+	Server            *ServerConfig        `yaml:"server,omitempty"`             // URL layout of hook endpoints and the panel
+	TrustedProxies    []string             `yaml:"trusted_proxies,omitempty"`    // CIDRs or IPs whose X-Forwarded-For / X-Real-IP headers are honored, default loopback and private networks
+	AccessLog         *AccessLogConfig     `yaml:"access_log,omitempty"`         // HTTP access log of API and hook requests
+	Consumers         []ConsumerConfig     `yaml:"consumers,omitempty"`          // message queue subscriptions delivering to hooks
+	ChatOps           *ChatOpsConfig       `yaml:"chatops,omitempty"`            // Slack and Mattermost slash commands
+	Notifications     *NotificationsConfig `yaml:"notifications,omitempty"`      // failure alerts and chat bots
+	Plugins           *PluginsConfig       `yaml:"plugins,omitempty"`            // executables and Go plugins extending gohook
+	GitOps            *GitOpsConfig        `yaml:"gitops,omitempty"`             // hooks files, version.yaml and user.yaml pulled from a git repository
+	LogForwarders     []LogForwarderConfig `yaml:"log_forwarders,omitempty"`     // hook, system and user activity logs shipped to a SIEM
+	Update            *UpdateConfig        `yaml:"update,omitempty"`             // checks for new releases of the server binary
+	RequireIfMatch    *bool                `yaml:"require_if_match,omitempty"`   // refuse edits of hooks and projects without an If-Match header, default true
+	EncryptHookLogs   bool                 `yaml:"encrypt_hook_logs,omitempty"`  // store body, output and error of hook logs encrypted with env_encryption_key
+	Redaction         *RedactionConfig     `yaml:"redaction,omitempty"`          // secrets removed from logs and WebSocket messages
+}
+
+// message queue types of ConsumerConfig
+const (
+	ConsumerKafka = "kafka"
+	ConsumerNATS  = "nats"
+	ConsumerRedis = "redis"
+	ConsumerMQTT  = "mqtt"
+	ConsumerIMAP  = "imap"
+)
+
+// DefaultConsumerPollInterval how often imap consumers check the mailbox
+const DefaultConsumerPollInterval = time.Minute
+
+// ConsumerMailRule imap rule selecting mails by sender, subject and body, the named groups of
+// the expressions are passed to the hook as payload fields
+type ConsumerMailRule struct {
+	From    string `yaml:"from,omitempty" json:"from,omitempty"`       // regexp on the sender address
+	Subject string `yaml:"subject,omitempty" json:"subject,omitempty"` // regexp on the decoded subject
+	Body    string `yaml:"body,omitempty" json:"body,omitempty"`       // regexp on the text body
+	Hook    string `yaml:"hook" json:"hook"`
+}
+
+// ConsumerRoute MQTT topic filter and the hook its messages are delivered to
+type ConsumerRoute struct {
+	Topic string `yaml:"topic" json:"topic"` // topic filter, + matches one level and # the rest
+	Hook  string `yaml:"hook" json:"hook"`
+}
+
+// ConsumerConfig message queue subscription, every message is delivered to a hook like an HTTP webhook
+type ConsumerConfig struct {
+	Name      string   `yaml:"name" json:"name"`
+	Type      string   `yaml:"type" json:"type"`                                // kafka | nats | redis | mqtt | imap
+	Servers   []string `yaml:"servers" json:"servers"`                          // host:port of the brokers, the first reachable one is used
+	Topic     string   `yaml:"topic" json:"topic"`                              // kafka topic, nats subject, redis stream, mqtt topic filter or imap mailbox (default INBOX)
+	Group     string   `yaml:"group,omitempty" json:"group,omitempty"`          // kafka consumer group, nats queue group or redis consumer group, default gohook
+	Hook      string   `yaml:"hook" json:"hook"`                                // id of the hook the messages are delivered to
+	Username  string   `yaml:"username,omitempty" json:"username,omitempty"`    // nats, mqtt, imap or kafka SASL/PLAIN user, redis ACL user
+	Password  string   `yaml:"password,omitempty" json:"-"`                     // nats password or token, redis, mqtt, imap or kafka password
+	TLS       bool     `yaml:"tls,omitempty" json:"tls,omitempty"`              // connect with TLS
+	Start     string   `yaml:"start,omitempty" json:"start,omitempty"`          // latest (default) | earliest, where a new kafka or redis group starts reading
+	BodyField string   `yaml:"body_field,omitempty" json:"bodyField,omitempty"` // redis entry field holding the body, default body
+	Disabled  bool     `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+
+	Routes   []ConsumerRoute `yaml:"routes,omitempty" json:"routes,omitempty"`      // mqtt topic filters and their hooks, instead of topic and hook
+	QoS      int             `yaml:"qos,omitempty" json:"qos,omitempty"`            // mqtt subscription QoS 0, 1 or 2; above 0 the broker keeps messages while disconnected
+	ClientID string          `yaml:"client_id,omitempty" json:"clientId,omitempty"` // mqtt client id, default gohook-<hostname>-<name>
+
+	Rules    []ConsumerMailRule `yaml:"rules,omitempty" json:"rules,omitempty"`       // imap rules and their hooks, instead of hook
+	Interval string             `yaml:"interval,omitempty" json:"interval,omitempty"` // imap poll interval, default 1m
+}
+
+// PollInterval how often an imap consumer checks the mailbox
+func (c *ConsumerConfig) PollInterval() time.Duration {
+	if d, err := time.ParseDuration(c.Interval); err == nil && d > 0 {
+		return d
+	}
+	return DefaultConsumerPollInterval
+}
+
+// Validate check a consumer definition
+func (c *ConsumerConfig) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("consumer name is required")
+	}
+	switch c.Type {
+	case ConsumerKafka, ConsumerNATS, ConsumerRedis, ConsumerMQTT, ConsumerIMAP:
+	default:
+		return fmt.Errorf("consumer %s: unsupported type %q, use kafka, nats, redis, mqtt or imap", c.Name, c.Type)
+	}
+	if len(c.Servers) == 0 {
+		return fmt.Errorf("consumer %s: at least one server is required", c.Name)
+	}
+	for _, server := range c.Servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			return fmt.Errorf("consumer %s: invalid server %q: %v", c.Name, server, err)
+		}
+	}
+	if c.Type == ConsumerMQTT {
+		if err := c.validateMQTT(); err != nil {
+			return fmt.Errorf("consumer %s: %v", c.Name, err)
+		}
+	} else if len(c.Routes) > 0 || c.QoS != 0 || c.ClientID != "" {
+		return fmt.Errorf("consumer %s: routes, qos and client_id are mqtt options", c.Name)
+	}
+	if c.Type == ConsumerIMAP {
+		if err := c.validateIMAP(); err != nil {
+			return fmt.Errorf("consumer %s: %v", c.Name, err)
+		}
+	} else if len(c.Rules) > 0 || c.Interval != "" {
+		return fmt.Errorf("consumer %s: rules and interval are imap options", c.Name)
+	}
+	if c.Topic == "" && len(c.Routes) == 0 && c.Type != ConsumerIMAP {
+		return fmt.Errorf("consumer %s: topic is required", c.Name)
+	}
+	if c.Hook == "" && len(c.Routes) == 0 && len(c.Rules) == 0 {
+		return fmt.Errorf("consumer %s: hook is required", c.Name)
+	}
+	switch c.Start {
+	case "", "latest", "earliest":
+	default:
+		return fmt.Errorf("consumer %s: start must be latest or earliest", c.Name)
+	}
+	return nil
+}
+
+// validateIMAP check the poll interval and the rule expressions
+func (c *ConsumerConfig) validateIMAP() error {
+	if c.Interval != "" {
+		d, err := time.ParseDuration(c.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid interval %q: %v", c.Interval, err)
+		}
+		if d < 10*time.Second {
+			return fmt.Errorf("interval must be at least 10s")
+		}
+	}
+	if len(c.Rules) > 0 && c.Hook != "" {
+		return fmt.Errorf("set either hook or rules")
+	}
+	for i, r := range c.Rules {
+		if r.Hook == "" {
+			return fmt.Errorf("rule %d: hook is required", i+1)
+		}
+		for _, expr := range []string{r.From, r.Subject, r.Body} {
+			if _, err := regexp.Compile(expr); err != nil {
+				return fmt.Errorf("rule %d: %v", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateMQTT check the qos, the topic filters and that topic and hook or routes are set
+func (c *ConsumerConfig) validateMQTT() error {
+	if c.QoS < 0 || c.QoS > 2 {
+		return fmt.Errorf("qos must be 0, 1 or 2")
+	}
+	if len(c.Routes) > 0 && (c.Topic != "" || c.Hook != "") {
+		return fmt.Errorf("set either topic and hook or routes")
+	}
+	filters := []string{c.Topic}
+	if len(c.Routes) > 0 {
+		filters = filters[:0]
+		for _, r := range c.Routes {
+			if r.Hook == "" {
+				return fmt.Errorf("route %s: hook is required", r.Topic)
+			}
+			filters = append(filters, r.Topic)
+		}
+	}
+	for _, filter := range filters {
+		if filter == "" {
+			continue // reported as a missing topic
+		}
+		levels := strings.Split(filter, "/")
+		for i, level := range levels {
+			if (level == "#" && i != len(levels)-1) || (level != "#" && level != "+" && strings.ContainsAny(level, "#+")) {
+				return fmt.Errorf("invalid topic filter %q", filter)
+			}
+		}
+	}
+	return nil
+}
+
+// chat operations of ChatOpsUser.Commands
+const (
+	ChatOpsDeploy  = "deploy"
+	ChatOpsRun     = "run"
+	ChatOpsPromote = "promote"
+)
+
+// ChatOpsConfig Slack and Mattermost slash commands mapped to hook and project operations
+type ChatOpsConfig struct {
+	SlackSigningSecret string        `yaml:"slack_signing_secret,omitempty" json:"-"`       // signing secret of the Slack app
+	MattermostTokens   []string      `yaml:"mattermost_tokens,omitempty" json:"-"`          // tokens of the Mattermost slash commands
+	PanelURL           string        `yaml:"panel_url,omitempty" json:"panelUrl,omitempty"` // public panel URL used in links, default derived from the request
+	Users              []ChatOpsUser `yaml:"users" json:"users"`
+}
+
+// ChatOpsUser chat user allowed to run commands with the permissions of a gohook user
+type ChatOpsUser struct {
+	Chat     string   `yaml:"chat" json:"chat"`                             // Slack or Mattermost user id
+	User     string   `yaml:"user" json:"user"`                             // gohook user whose role and namespace apply
+	Commands []string `yaml:"commands,omitempty" json:"commands,omitempty"` // deploy | run | promote, all when empty
+}
+
+// Validate check the credentials, the panel URL and the user mappings
+func (c *ChatOpsConfig) Validate() error {
+	if c.SlackSigningSecret == "" && len(c.MattermostTokens) == 0 {
+		return fmt.Errorf("slack_signing_secret or mattermost_tokens is required")
+	}
+	if c.PanelURL != "" {
+		if !strings.HasPrefix(c.PanelURL, "http://") && !strings.HasPrefix(c.PanelURL, "https://") {
+			return fmt.Errorf("invalid panel_url: %s", c.PanelURL)
+		}
+	}
+	seen := map[string]bool{}
+	for _, u := range c.Users {
+		if u.Chat == "" || u.User == "" {
+			return fmt.Errorf("users need chat and user")
+		}
+		if seen[u.Chat] {
+			return fmt.Errorf("duplicate chat user %s", u.Chat)
+		}
+		seen[u.Chat] = true
+		for _, cmd := range u.Commands {
+			switch cmd {
+			case ChatOpsDeploy, ChatOpsRun, ChatOpsPromote:
+			default:
+				return fmt.Errorf("chat user %s: unknown command %s", u.Chat, cmd)
+			}
+		}
+	}
+	return nil
+}
+
+// bot commands of TelegramChat.Commands besides ChatOpsRun
+const (
+	ChatOpsProjects = "projects"
+	ChatOpsApprove  = "approve" // approve and reject pending promotions
+)
+
+// NotificationsConfig failure alerts and approval requests sent to chats
+type NotificationsConfig struct {
+	PanelURL string          `yaml:"panel_url,omitempty" json:"panelUrl,omitempty"` // public panel URL used in links
+	Telegram *TelegramConfig `yaml:"telegram,omitempty" json:"telegram,omitempty"`
+}
+
+// DefaultPluginsDir directory scanned for plugins when none is configured
+const DefaultPluginsDir = "plugins"
+
+// DefaultPluginCallTimeout limit for a call into a plugin
+const DefaultPluginCallTimeout = 10 * time.Second
+
+// DefaultPluginWASMMemoryMB memory a run of a WebAssembly plugin may use
+const DefaultPluginWASMMemoryMB = 64
+
+// PluginsConfig where plugins are discovered and the resources a call into one may take
+type PluginsConfig struct {
+	Dir          string        `yaml:"dir,omitempty" json:"dir,omitempty"`                     // default plugins, relative to the working directory
+	CallTimeout  time.Duration `yaml:"call_timeout,omitempty" json:"callTimeout,omitempty"`    // default 10s, also the run time limit of WebAssembly plugins
+	WASMMemoryMB int           `yaml:"wasm_memory_mb,omitempty" json:"wasmMemoryMb,omitempty"` // default 64
+}
+
+// how local edits of files managed by GitOps are handled
+const (
+	GitOpsRejectEdits = "reject" // config changes of the API are refused, hand edits are overwritten
+	GitOpsWarnEdits   = "warn"   // edits are kept and reported as drift until the repository changes the file
+)
+
+// GitOpsConfig git repository the hooks files, version.yaml and user.yaml are reconciled from.
+// Files are matched by name in Dir; files missing from the repository stay managed locally.
+type GitOpsConfig struct {
+	Enabled       bool          `yaml:"enabled" json:"enabled"`
+	Repo          string        `yaml:"repo" json:"repo"`                                  // URL or path cloned with git
+	Branch        string        `yaml:"branch,omitempty" json:"branch,omitempty"`          // default branch of the repository when empty
+	Dir           string        `yaml:"dir,omitempty" json:"dir,omitempty"`                // directory of the config files in the repository
+	Checkout      string        `yaml:"checkout,omitempty" json:"checkout,omitempty"`      // local clone, default .gitops
+	Interval      time.Duration `yaml:"interval,omitempty" json:"interval,omitempty"`      // default 5m, negative only syncs on webhook or demand
+	LocalEdits    string        `yaml:"local_edits,omitempty" json:"localEdits,omitempty"` // reject (default) | warn
+	WebhookSecret string        `yaml:"webhook_secret,omitempty" json:"-"`                 // enables POST /gitops/webhook for push events
+}
+
+// TelegramConfig Telegram bot answering commands of authorized chats and sending them alerts
+type TelegramConfig struct {
+	BotToken string         `yaml:"bot_token" json:"-"`                        // token from @BotFather
+	APIURL   string         `yaml:"api_url,omitempty" json:"apiUrl,omitempty"` // Bot API server, default https://api.telegram.org
+	Chats    []TelegramChat `yaml:"chats" json:"chats"`
+}
+
+// TelegramChat chat allowed to use the bot with the permissions of a gohook user
+type TelegramChat struct {
+	ID       int64    `yaml:"id" json:"id"`                                 // private chat or group id
+	User     string   `yaml:"user" json:"user"`                             // gohook user whose role and namespace apply
+	Commands []string `yaml:"commands,omitempty" json:"commands,omitempty"` // projects | run | approve, all when empty
+	Alerts   bool     `yaml:"alerts,omitempty" json:"alerts,omitempty"`     // send failures and promotions waiting for approval
+}
+
+// Validate check the panel URL and the Telegram bot
+func (c *NotificationsConfig) Validate() error {
+	if c.PanelURL != "" {
+		if !strings.HasPrefix(c.PanelURL, "http://") && !strings.HasPrefix(c.PanelURL, "https://") {
+			return fmt.Errorf("invalid panel_url: %s", c.PanelURL)
+		}
+	}
+	if c.Telegram != nil {
+		if err := c.Telegram.Validate(); err != nil {
+			return fmt.Errorf("telegram: %v", err)
+		}
+	}
+	return nil
+}
+
+// Validate check the bot token and the chats
+func (c *TelegramConfig) Validate() error {
+	if c.BotToken == "" {
+		return fmt.Errorf("bot_token is required")
+	}
+	if c.APIURL != "" {
+		if !strings.HasPrefix(c.APIURL, "http://") && !strings.HasPrefix(c.APIURL, "https://") {
+			return fmt.Errorf("invalid api_url: %s", c.APIURL)
+		}
+	}
+	seen := map[int64]bool{}
+	for _, chat := range c.Chats {
+		if chat.ID == 0 || chat.User == "" {
+			return fmt.Errorf("chats need id and user")
+		}
+		if seen[chat.ID] {
+			return fmt.Errorf("duplicate chat %d", chat.ID)
+		}
+		seen[chat.ID] = true
+		for _, cmd := range chat.Commands {
+			switch cmd {
+			case ChatOpsProjects, ChatOpsRun, ChatOpsApprove:
+			default:
+				return fmt.Errorf("chat %d: unknown command %s", chat.ID, cmd)
+			}
+		}
+	}
+	return nil
+}
+
+// AccessLogConfig structured HTTP access log, one JSON object per request
+type AccessLogConfig struct {
+	Enabled    bool     `yaml:"enabled" json:"enabled"`
+	Output     string   `yaml:"output,omitempty" json:"output,omitempty"`          // stdout (default) | stderr | path of a file the log is appended to
+	SampleRate float64  `yaml:"sample_rate,omitempty" json:"sampleRate,omitempty"` // fraction of successful requests logged, 0 logs all; errors and slow requests are always logged
+	SlowMs     int      `yaml:"slow_ms,omitempty" json:"slowMs,omitempty"`         // requests taking at least this long are always logged, 0 disables
+	SkipPaths  []string `yaml:"skip_paths,omitempty" json:"skipPaths,omitempty"`   // path prefixes not logged, e.g. /static/
+}
+
+// RedactionConfig secrets removed from request bodies, command output and other log fields
+// before they are stored or broadcast
+type RedactionConfig struct {
+	Enabled  bool     `yaml:"enabled" json:"enabled"`
+	Keys     []string `yaml:"keys,omitempty" json:"keys,omitempty"`         // key names whose values are secrets, besides the built-in ones, e.g. session_id
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty"` // regular expressions of secrets; with groups only the groups are redacted
+}
+
+// ServerConfig URL layout of the server
+type ServerConfig struct {
+	HooksURLPrefix *string `yaml:"hooks_url_prefix,omitempty" json:"hooksUrlPrefix,omitempty"` // path before hook ids, default hooks, empty serves hooks on /{id}
+	BasePath       string  `yaml:"base_path,omitempty" json:"basePath,omitempty"`              // sub-path the app is served under behind a reverse proxy, e.g. /gohook
+}
+
+// environment inheritance modes of EnvPolicy
+const (
+	EnvInheritAll       = "all"
+	EnvInheritAllowlist = "allowlist"
+	EnvInheritNone      = "none"
+)
+
+// EnvPolicy which variables of the gohook environment a hook command inherits
+type EnvPolicy struct {
+	Mode  string   `yaml:"mode" json:"mode"`                       // all (default) | allowlist | none
+	Allow []string `yaml:"allow,omitempty" json:"allow,omitempty"` // names passed in allowlist mode, NAME* matches a prefix
+}
+
+// Validate check the mode and variable names
+func (p *EnvPolicy) Validate() error {
+	switch p.Mode {
+	case "", EnvInheritAll, EnvInheritAllowlist, EnvInheritNone:
+	default:
+		return fmt.Errorf("unsupported environment mode: %s", p.Mode)
+	}
+	for _, name := range p.Allow {
+		if name == "" || name == "*" || strings.ContainsAny(name, "= ") || strings.Contains(strings.TrimSuffix(name, "*"), "*") {
+			return fmt.Errorf("invalid environment variable name: %q", name)
+		}
+	}
+	return nil
+}
+
+// log forwarder types of LogForwarderConfig
+const (
+	LogForwarderSyslog = "syslog"
+	LogForwarderSplunk = "splunk"
+	LogForwarderHTTP   = "http"
+)
+
+// log forwarder defaults
+const (
+	DefaultLogForwardBuffer   = 1000
+	DefaultLogForwardBatch    = 100
+	DefaultLogForwardInterval = 2 * time.Second
+	DefaultLogForwardRetries  = 3
+)
+
+// LogForwarderConfig destination the hook, system and user activity logs of this instance are
+// shipped to: RFC 5424 syslog, a Splunk HTTP Event Collector or any HTTP endpoint taking JSON
+type LogForwarderConfig struct {
+	Name               string            `yaml:"name" json:"name"`
+	Type               string            `yaml:"type" json:"type"`                                                   // syslog | splunk | http
+	Address            string            `yaml:"address,omitempty" json:"address,omitempty"`                         // syslog host:port
+	Network            string            `yaml:"network,omitempty" json:"network,omitempty"`                         // syslog udp (default) | tcp | tls
+	URL                string            `yaml:"url,omitempty" json:"url,omitempty"`                                 // splunk event endpoint or http endpoint
+	Token              string            `yaml:"token,omitempty" json:"-"`                                           // splunk HEC token, bearer token of the http endpoint
+	Headers            map[string]string `yaml:"headers,omitempty" json:"-"`                                         // extra headers of http requests
+	Index              string            `yaml:"index,omitempty" json:"index,omitempty"`                             // splunk index, default of the token
+	Categories         []string          `yaml:"categories,omitempty" json:"categories,omitempty"`                   // hook, system, user or system:<CATEGORY>, empty forwards everything
+	BufferSize         int               `yaml:"buffer_size,omitempty" json:"bufferSize,omitempty"`                  // entries held while the destination is slow or down, default 1000
+	BatchSize          int               `yaml:"batch_size,omitempty" json:"batchSize,omitempty"`                    // entries per request, default 100
+	FlushInterval      string            `yaml:"flush_interval,omitempty" json:"flushInterval,omitempty"`            // longest wait before a partial batch is sent, default 2s
+	MaxRetries         int               `yaml:"max_retries,omitempty" json:"maxRetries,omitempty"`                  // attempts after a failed send before a batch is dropped, default 3
+	InsecureSkipVerify bool              `yaml:"insecure_skip_verify,omitempty" json:"insecureSkipVerify,omitempty"` // accept any TLS certificate
+	Disabled           bool              `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+}
+
+// Flush longest wait before a partial batch is sent
+func (c *LogForwarderConfig) Flush() time.Duration {
+	if d, err := time.ParseDuration(c.FlushInterval); err == nil && d > 0 {
+		return d
+	}
+	return DefaultLogForwardInterval
+}
+
+// Validate check a log forwarder definition
+func (c *LogForwarderConfig) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("log forwarder name is required")
+	}
+	switch c.Type {
+	case LogForwarderSyslog:
+		if _, _, err := net.SplitHostPort(c.Address); err != nil {
+			return fmt.Errorf("log forwarder %s: invalid address %q: %v", c.Name, c.Address, err)
+		}
+		switch c.Network {
+		case "", "udp", "tcp", "tls":
+		default:
+			return fmt.Errorf("log forwarder %s: network must be udp, tcp or tls", c.Name)
+		}
+	case LogForwarderSplunk, LogForwarderHTTP:
+		if !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+			return fmt.Errorf("log forwarder %s: url must be an http or https URL", c.Name)
+		}
+		if c.Type == LogForwarderSplunk && c.Token == "" {
+			return fmt.Errorf("log forwarder %s: token is required", c.Name)
+		}
+	default:
+		return fmt.Errorf("log forwarder %s: unsupported type %q, use syslog, splunk or http", c.Name, c.Type)
+	}
+	for _, category := range c.Categories {
+		kind, _, _ := strings.Cut(category, ":")
+		if kind != "hook" && kind != "system" && kind != "user" || kind != "system" && category != kind {
+			return fmt.Errorf("log forwarder %s: invalid category %q, use hook, system, user or system:<CATEGORY>", c.Name, category)
+		}
+	}
+	if c.BufferSize < 0 || c.BatchSize < 0 || c.MaxRetries < 0 {
+		return fmt.Errorf("log forwarder %s: buffer_size, batch_size and max_retries must not be negative", c.Name)
+	}
+	if c.FlushInterval != "" {
+		if d, err := time.ParseDuration(c.FlushInterval); err != nil || d <= 0 {
+			return fmt.Errorf("log forwarder %s: invalid flush_interval %q", c.Name, c.FlushInterval)
+		}
+	}
+	return nil
+}
+
+// ScriptsConfig checks run on hook scripts saved in the panel and the directories scripts may live in
+type ScriptsConfig struct {
+	Roots         []string          `yaml:"roots,omitempty" json:"roots,omitempty"`                   // script files and commands must be inside these directories, empty allows any path
+	Checkers      map[string]string `yaml:"checkers,omitempty" json:"checkers,omitempty"`             // file extension -> syntax check command, the script path is appended; empty disables a default
+	Shellcheck    *bool             `yaml:"shellcheck,omitempty" json:"shellcheck,omitempty"`         // run shellcheck on shell scripts when installed, default true
+	RejectOnError bool              `yaml:"reject_on_error,omitempty" json:"rejectOnError,omitempty"` // refuse to save scripts failing the syntax check
+	Commands      *CommandPolicy    `yaml:"commands,omitempty" json:"commands,omitempty"`             // execute-command values accepted through the API
+}
+
+// CommandPolicy restricts the execute-command values that can be set through the API
+type CommandPolicy struct {
+	AllowedDirs             []string `yaml:"allowed_dirs,omitempty" json:"allowedDirs,omitempty"`                          // the command must resolve to a file below one of these directories
+	AllowedCommands         []string `yaml:"allowed_commands,omitempty" json:"allowedCommands,omitempty"`                  // or be one of these binaries, a bare name is looked up in PATH
+	DenyShellMetacharacters bool     `yaml:"deny_shell_metacharacters,omitempty" json:"denyShellMetacharacters,omitempty"` // refuse ; & | ` $ < > ( ) and line breaks
+	RequireScriptsRoot      bool     `yaml:"require_scripts_root,omitempty" json:"requireScriptsRoot,omitempty"`           // refuse inline shell commands, commands must be files inside roots
+}
+
+// Validate check the script settings
+func (s *ScriptsConfig) Validate() error {
+	p := s.Commands
+	if p == nil {
+		return nil
+	}
+	if p.RequireScriptsRoot && len(s.Roots) == 0 {
+		return fmt.Errorf("commands.require_scripts_root needs roots")
+	}
+	for _, dir := range p.AllowedDirs {
+		if !filepath.IsAbs(dir) {
+			return fmt.Errorf("commands.allowed_dirs must be absolute: %s", dir)
+		}
+	}
+	for _, cmd := range p.AllowedCommands {
+		if cmd == "" || strings.ContainsAny(cmd, " \t") {
+			return fmt.Errorf("invalid commands.allowed_commands entry: %q", cmd)
+		}
+	}
+	return nil
+}
+
+// ClusterConfig high-availability mode, instances sharing the database elect a leader for background tasks
+type ClusterConfig struct {
+	Enabled        bool   `yaml:"enabled" json:"enabled"`
+	NodeID         string `yaml:"node_id,omitempty" json:"nodeId,omitempty"`                  // defaults to hostname and port
+	LeaseSeconds   int    `yaml:"lease_seconds,omitempty" json:"leaseSeconds,omitempty"`      // leader lease duration, default 15
+	PollIntervalMs int    `yaml:"poll_interval_ms,omitempty" json:"pollIntervalMs,omitempty"` // how often events of other instances are read, default 1000
+}
+
+// DefaultNamespace namespace of hooks, projects and logs that do not name one
+const DefaultNamespace = "default"
+
+// NamespaceConfig tenant that owns hooks, projects, users and their logs
+type NamespaceConfig struct {
+	Name        string       `yaml:"name" json:"name"`
+	Description string       `yaml:"description,omitempty" json:"description,omitempty"`
+	Quota       *QuotaConfig `yaml:"quota,omitempty" json:"quota,omitempty"` // limits of all hooks and projects of the namespace
+}
+
+// quota behaviors of QuotaConfig.OnExceeded
+const (
+	QuotaReject = "reject"
+	QuotaQueue  = "queue"
+)
+
+// QuotaConfig daily execution and storage limits of a hook, user or namespace
+type QuotaConfig struct {
+	MaxExecutionsPerDay int    `yaml:"max_executions_per_day,omitempty" json:"maxExecutionsPerDay,omitempty"` // executions since local midnight
+	MaxStorageBytes     int64  `yaml:"max_storage_bytes,omitempty" json:"maxStorageBytes,omitempty"`          // stored execution logs and artifacts
+	OnExceeded          string `yaml:"on_exceeded,omitempty" json:"onExceeded,omitempty"`                     // reject (default) | queue deliveries until the quota frees up
+}
+
+// ExecutionConfig worker pool running hooks that answer before their command finishes
+type ExecutionConfig struct {
+	Workers   int `yaml:"workers,omitempty" json:"workers,omitempty"`      // commands running at once, default 16
+	QueueSize int `yaml:"queue_size,omitempty" json:"queueSize,omitempty"` // executions waiting for a worker, default 1000; deliveries beyond are rejected
+}
+
+// UpdateConfig releases of the server binary checked on GitHub. Admins install a newer
+// release with POST /admin/update, the download must match checksums.txt of the release.
+type UpdateConfig struct {
+	Check      bool          `yaml:"check" json:"check"`                               // check for a newer release periodically
+	Interval   time.Duration `yaml:"interval,omitempty" json:"interval,omitempty"`     // default 24h
+	Repository string        `yaml:"repository,omitempty" json:"repository,omitempty"` // owner/name, default mycoool/gohook
+	APIURL     string        `yaml:"api_url,omitempty" json:"apiUrl,omitempty"`        // default https://api.github.com
+	Token      string        `yaml:"token,omitempty" json:"-"`                         // GitHub token against rate limits
+	PublicKey  string        `yaml:"public_key,omitempty" json:"publicKey,omitempty"`  // ed25519 key (base64 or PEM) checksums.txt.sig must verify with
+}
+
+// MaintenanceConfig global maintenance mode, incoming webhooks are queued or rejected while active
+type MaintenanceConfig struct {
+	Enabled      bool   `yaml:"enabled" json:"enabled"`
+	Until        string `yaml:"until,omitempty" json:"until,omitempty"`                // RFC3339, resume automatically after this time
+	RejectStatus int    `yaml:"reject_status,omitempty" json:"rejectStatus,omitempty"` // 0: queue deliveries, otherwise reject with this HTTP status
+	Message      string `yaml:"message,omitempty" json:"message,omitempty"`
+}
+
+// PauseWindow recurring time window during which a hook or project does not execute deliveries
+type PauseWindow struct {
+	Days         []string `yaml:"days,omitempty" json:"days,omitempty"`                  // mon..sun, empty means every day
+	Start        string   `yaml:"start" json:"start"`                                    // HH:MM, local time
+	End          string   `yaml:"end" json:"end"`                                        // HH:MM, may be earlier than start to span midnight
+	RejectStatus int      `yaml:"reject_status,omitempty" json:"rejectStatus,omitempty"` // 0: queue deliveries, otherwise reject with this HTTP status
 }
 
 // DatabaseConfig database config
 type DatabaseConfig struct {
-	Type             string `yaml:"type"`     // sqlite, mysql, postgres
-	Database         string `yaml:"database"` // database name or file path
-	Host             string `yaml:"host,omitempty"`
-	Port             int    `yaml:"port,omitempty"`
-	Username         string `yaml:"username,omitempty"`
-	Password         string `yaml:"password,omitempty"`
-	LogRetentionDays int    `yaml:"log_retention_days"` // log retention days
+	Type               string `yaml:"type"`     // sqlite or postgres
+	Database           string `yaml:"database"` // database name or file path
+	Host               string `yaml:"host,omitempty"`
+	Port               int    `yaml:"port,omitempty"`
+	Username           string `yaml:"username,omitempty"`
+	Password           string `yaml:"password,omitempty"`
+	SSLMode            string `yaml:"sslmode,omitempty"`              // postgres only: disable, require, verify-full..., default prefer
+	DSN                string `yaml:"dsn,omitempty"`                  // postgres connection string, replaces host, port, username, password and sslmode
+	LogRetentionDays   int    `yaml:"log_retention_days"`             // log retention days
+	TrashRetentionDays int    `yaml:"trash_retention_days,omitempty"` // days deleted hooks and projects stay restorable, 0 means 30
+	AuditHashChain     bool   `yaml:"audit_hash_chain,omitempty"`     // link user and project activity records by hash to detect tampering
 }
 
 // Claims JWT claim structure
 type Claims struct {
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	Namespace string `json:"namespace,omitempty"` // token is limited to this namespace when set
 	jwt.RegisteredClaims
 }
 
 // UserResponse user response structure
 type UserResponse struct {
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	Namespace string `json:"namespace,omitempty"`
+	Timezone  string `json:"timezone,omitempty"`
+	Language  string `json:"language,omitempty"`
+	// DecryptLogs user reads hook logs encrypted at rest in clear
+	DecryptLogs bool `json:"decryptLogs,omitempty"`
+}
+
+// UserPreferences time zone and panel language of a user, empty uses the server setting
+type UserPreferences struct {
+	Timezone string `json:"timezone"`
+	Language string `json:"language"`
+}
+
+// UserPreferencesResponse stored preferences and the time zone and language in effect
+type UserPreferencesResponse struct {
+	UserPreferences
+	EffectiveTimezone string `json:"effectiveTimezone"`
+	EffectiveLanguage string `json:"effectiveLanguage"`
 }
 
 // Config config file structure
@@ -61,16 +678,746 @@ type VersionConfig struct {
 
 // ProjectConfig project config structure
 type ProjectConfig struct {
-	Name        string             `yaml:"name"`
-	Path        string             `yaml:"path"`
-	Description string             `yaml:"description"`
-	Enabled     bool               `yaml:"enabled"`
-	Enhook      bool               `yaml:"enhook,omitempty"`
-	Hookmode    string             `yaml:"hookmode,omitempty"`
-	Hookbranch  string             `yaml:"hookbranch,omitempty"`
-	Hooksecret  string             `yaml:"hooksecret,omitempty"`
-	ForceSync   bool               `yaml:"forcesync,omitempty"` // GitHook 是否使用强制同步模式
-	Sync        *ProjectSyncConfig `yaml:"sync,omitempty"`      // Sync node settings
+	Name           string                       `yaml:"name"`
+	Aliases        []string                     `yaml:"aliases,omitempty"`   // previous names, still accepted in GitHook URLs
+	Namespace      string                       `yaml:"namespace,omitempty"` // empty means DefaultNamespace
+	Path           string                       `yaml:"path"`
+	VCS            string                       `yaml:"vcs,omitempty"` // git (default) | svn | hg | artifact
+	Description    string                       `yaml:"description"`
+	Enabled        bool                         `yaml:"enabled"`
+	Enhook         bool                         `yaml:"enhook,omitempty"`
+	Hookmode       string                       `yaml:"hookmode,omitempty"`
+	Hookbranch     string                       `yaml:"hookbranch,omitempty"`
+	Hooksecret     string                       `yaml:"hooksecret,omitempty"`
+	HookRotation   *SecretRotation              `yaml:"hook_rotation,omitempty"`   // previous hooksecret during the grace period of a rotation
+	Hookpaths      []string                     `yaml:"hookpaths,omitempty"`       // GitHook branch pushes deploy only when they change files matching these paths
+	Monorepo       string                       `yaml:"monorepo,omitempty"`        // projects with the same monorepo name share GitHook deliveries of one repository
+	ForceSync      bool                         `yaml:"forcesync,omitempty"`       // GitHook 是否使用强制同步模式
+	EncryptEnv     bool                         `yaml:"encrypt_env,omitempty"`     // store .env encrypted in database, materialize on deploy
+	Service        *ProjectServiceConfig        `yaml:"service,omitempty"`         // managed service restarted after deploy
+	Sync           *ProjectSyncConfig           `yaml:"sync,omitempty"`            // Sync node settings
+	PauseWindows   []PauseWindow                `yaml:"pause_windows,omitempty"`   // GitHook deliveries are queued or rejected inside these windows
+	Promotion      *ProjectPromotionConfig      `yaml:"promotion,omitempty"`       // promotion of deployed revisions from upstream projects
+	Preflight      *ProjectPreflightConfig      `yaml:"preflight,omitempty"`       // checks run before a deploy or sync touches any file
+	Snapshots      *ProjectSnapshotConfig       `yaml:"snapshots,omitempty"`       // snapshot local changes before a force deploy discards them
+	Protection     *ProjectProtectionConfig     `yaml:"protection,omitempty"`      // branches and tags that cannot be deleted or force-switched
+	GitMaintenance *ProjectGitMaintenanceConfig `yaml:"git_maintenance,omitempty"` // scheduled git gc, prune and remote prune
+	Signatures     *ProjectSignatureConfig      `yaml:"signatures,omitempty"`      // deployed commits or tags must carry a trusted signature
+	Kubernetes     *ProjectKubernetesConfig     `yaml:"kubernetes,omitempty"`      // GitHook deploys update a Kubernetes cluster
+	Compose        *ProjectComposeConfig        `yaml:"compose,omitempty"`         // GitHook deploys run docker compose pull and up
+	Migrations     *ProjectMigrationConfig      `yaml:"migrations,omitempty"`      // database migrations run after each deploy, a failure fails the deploy
+	Releases       *ProjectReleaseConfig        `yaml:"releases,omitempty"`        // deploys build a release directory and switch a current symlink to it
+	Artifact       *ProjectArtifactConfig       `yaml:"artifact,omitempty"`        // where projects with vcs artifact download their builds
+	HealthCheck    *ProjectHealthCheckConfig    `yaml:"healthcheck,omitempty"`     // checked after each deploy, a failing deploy is rolled back
+	Pin            *ProjectPin                  `yaml:"pin,omitempty"`             // set while the project is pinned, GitHooks and switches are refused
+	Provider       *ProjectProviderConfig       `yaml:"provider,omitempty"`        // Git hosting account the GitHook is registered with
+	Poll           *ProjectPollConfig           `yaml:"poll,omitempty"`            // fetch the remote periodically when no GitHook can reach the server
+}
+
+// ProjectSignatureConfig require deployed commits or tags to carry a valid GPG or SSH signature.
+// GPG keys are trusted through the keyring of the gohook user, SSH keys through the allowed
+// signers file
+type ProjectSignatureConfig struct {
+	Required       bool     `yaml:"required" json:"required"`
+	AllowedSigners string   `yaml:"allowed_signers,omitempty" json:"allowedSigners,omitempty"` // SSH allowed signers file, see ssh-keygen(1)
+	Keys           []string `yaml:"keys,omitempty" json:"keys,omitempty"`                      // accepted GPG or SSH (SHA256:...) fingerprints, empty accepts every trusted key
+}
+
+// Validate check the allowed signers path
+func (s *ProjectSignatureConfig) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.AllowedSigners != "" && !filepath.IsAbs(s.AllowedSigners) {
+		return fmt.Errorf("allowed signers file must be an absolute path: %s", s.AllowedSigners)
+	}
+	for _, key := range s.Keys {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("empty signing key fingerprint")
+		}
+	}
+	return nil
+}
+
+// deploy modes of ProjectKubernetesConfig
+const (
+	KubernetesImage     = "image"     // set the image tag of a Deployment, the checkout is not touched
+	KubernetesManifests = "manifests" // check out the ref, then apply a manifest directory of it
+)
+
+// ProjectKubernetesConfig deploy target of a project in a Kubernetes cluster. The cluster is
+// reached through Kubeconfig, through Server and Token, or through the in-cluster service account.
+type ProjectKubernetesConfig struct {
+	Mode                  string `yaml:"mode" json:"mode"`                                                          // image | manifests
+	Kubeconfig            string `yaml:"kubeconfig,omitempty" json:"kubeconfig,omitempty"`                          // default $KUBECONFIG or ~/.kube/config
+	Context               string `yaml:"context,omitempty" json:"context,omitempty"`                                // kubeconfig context, default the current context
+	Server                string `yaml:"server,omitempty" json:"server,omitempty"`                                  // API server URL, instead of a kubeconfig
+	Token                 string `yaml:"token,omitempty" json:"token,omitempty"`                                    // bearer token for Server, never returned by the API
+	CAFile                string `yaml:"ca_file,omitempty" json:"caFile,omitempty"`                                 // CA certificate of Server
+	InsecureSkipTLSVerify bool   `yaml:"insecure_skip_tls_verify,omitempty" json:"insecureSkipTlsVerify,omitempty"` // do not verify the certificate of Server
+	Namespace             string `yaml:"namespace,omitempty" json:"namespace,omitempty"`                            // default the namespace of the context or default
+	Deployment            string `yaml:"deployment,omitempty" json:"deployment,omitempty"`                          // Deployment whose image is set, and whose rollout is checked
+	Container             string `yaml:"container,omitempty" json:"container,omitempty"`                            // container of Deployment, default the first one
+	Image                 string `yaml:"image,omitempty" json:"image,omitempty"`                                    // image repository, the tag comes from TagTemplate
+	TagTemplate           string `yaml:"tag_template,omitempty" json:"tagTemplate,omitempty"`                       // {ref}, {commit} and {short_commit}, default {ref}
+	ManifestDir           string `yaml:"manifest_dir,omitempty" json:"manifestDir,omitempty"`                       // manifests mode, directory relative to the project path
+	Timeout               string `yaml:"timeout,omitempty" json:"timeout,omitempty"`                                // rollout status check, Go duration, default 5m, 0 skips the check
+	Rollback              bool   `yaml:"rollback,omitempty" json:"rollback,omitempty"`                              // roll Deployments back when their rollout fails
+}
+
+// DefaultKubernetesTimeout time a rollout may take unless configured
+const DefaultKubernetesTimeout = 5 * time.Minute
+
+// Validate check the mode and the fields it needs
+func (k *ProjectKubernetesConfig) Validate() error {
+	if k == nil {
+		return nil
+	}
+	switch k.Mode {
+	case KubernetesImage:
+		if k.Deployment == "" || k.Image == "" {
+			return fmt.Errorf("kubernetes image mode needs deployment and image")
+		}
+		if strings.Contains(k.Image[strings.LastIndex(k.Image, "/")+1:], ":") {
+			return fmt.Errorf("kubernetes image %s must not carry a tag, it is set from tag_template", k.Image)
+		}
+	case KubernetesManifests:
+		if k.ManifestDir == "" || filepath.IsAbs(k.ManifestDir) || strings.HasPrefix(filepath.Clean(k.ManifestDir), "..") {
+			return fmt.Errorf("kubernetes manifests mode needs a manifest_dir inside the project")
+		}
+	default:
+		return fmt.Errorf("unknown kubernetes mode %q, use image or manifests", k.Mode)
+	}
+	if k.Server != "" && !strings.HasPrefix(k.Server, "https://") && !strings.HasPrefix(k.Server, "http://") {
+		return fmt.Errorf("invalid kubernetes server: %s", k.Server)
+	}
+	if k.Server != "" && k.Kubeconfig != "" {
+		return fmt.Errorf("kubernetes server and kubeconfig are exclusive")
+	}
+	if _, err := k.RolloutTimeout(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Redacted copy of the config without the token, for API responses
+func (k *ProjectKubernetesConfig) Redacted() *ProjectKubernetesConfig {
+	if k == nil {
+		return nil
+	}
+	r := *k
+	r.Token = ""
+	return &r
+}
+
+// RolloutTimeout time the rollout may take, 0 skips the status check
+func (k *ProjectKubernetesConfig) RolloutTimeout() (time.Duration, error) {
+	if k.Timeout == "" {
+		return DefaultKubernetesTimeout, nil
+	}
+	d, err := time.ParseDuration(k.Timeout)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid kubernetes timeout: %s", k.Timeout)
+	}
+	return d, nil
+}
+
+// artifact sources of ProjectArtifactConfig
+const (
+	ArtifactURL    = "url"    // plain HTTP(S) download
+	ArtifactGitHub = "github" // asset of the GitHub release tagged with the deployed ref
+	ArtifactS3     = "s3"     // object of an S3 compatible bucket
+)
+
+// ProjectArtifactConfig build artifact (tar, tar.gz or zip) a project with vcs artifact deploys
+// instead of a source checkout. URL, Asset and Key, and the checksum and signature locations,
+// may contain {ref} and {version} (the ref without a leading v).
+type ProjectArtifactConfig struct {
+	Source          string `yaml:"source" json:"source"`                                         // url | github | s3
+	URL             string `yaml:"url,omitempty" json:"url,omitempty"`                           // url: artifact URL
+	Repo            string `yaml:"repo,omitempty" json:"repo,omitempty"`                         // github: owner/name
+	Asset           string `yaml:"asset,omitempty" json:"asset,omitempty"`                       // github: release asset name
+	APIURL          string `yaml:"api_url,omitempty" json:"apiUrl,omitempty"`                    // github: API URL, default https://api.github.com
+	Token           string `yaml:"token,omitempty" json:"token,omitempty"`                       // github token or bearer token of url downloads, never returned by the API
+	Bucket          string `yaml:"bucket,omitempty" json:"bucket,omitempty"`                     // s3: bucket
+	Key             string `yaml:"key,omitempty" json:"key,omitempty"`                           // s3: object key
+	Region          string `yaml:"region,omitempty" json:"region,omitempty"`                     // s3: region, default us-east-1
+	Endpoint        string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`                 // s3: endpoint of MinIO and other compatible stores
+	AccessKeyID     string `yaml:"access_key_id,omitempty" json:"accessKeyId,omitempty"`         // s3: credentials, anonymous when empty
+	SecretAccessKey string `yaml:"secret_access_key,omitempty" json:"secretAccessKey,omitempty"` // s3: never returned by the API
+	Checksum        string `yaml:"checksum,omitempty" json:"checksum,omitempty"`                 // location of a SHA256SUMS file, a URL, asset or key like the artifact
+	Signature       string `yaml:"signature,omitempty" json:"signature,omitempty"`               // location of a detached GPG or SSH signature of the artifact
+	AllowedSigners  string `yaml:"allowed_signers,omitempty" json:"allowedSigners,omitempty"`    // SSH allowed signers file for SSH signatures
+	StripComponents int    `yaml:"strip_components,omitempty" json:"stripComponents,omitempty"`  // leading path components removed from archive entries
+	Clean           bool   `yaml:"clean,omitempty" json:"clean,omitempty"`                       // replace the project directory instead of unpacking over it
+}
+
+// Validate check the source, its location fields and that the artifact is verified
+func (a *ProjectArtifactConfig) Validate() error {
+	if a == nil {
+		return nil
+	}
+	switch a.Source {
+	case ArtifactURL:
+		if !strings.HasPrefix(a.URL, "https://") && !strings.HasPrefix(a.URL, "http://") {
+			return fmt.Errorf("artifact url must be an http(s) URL: %q", a.URL)
+		}
+	case ArtifactGitHub:
+		if strings.Count(a.Repo, "/") != 1 || a.Asset == "" {
+			return fmt.Errorf("github artifacts need repo owner/name and asset")
+		}
+	case ArtifactS3:
+		if a.Bucket == "" || a.Key == "" {
+			return fmt.Errorf("s3 artifacts need bucket and key")
+		}
+		if (a.AccessKeyID == "") != (a.SecretAccessKey == "") {
+			return fmt.Errorf("s3 artifacts need both access_key_id and secret_access_key, or neither")
+		}
+	default:
+		return fmt.Errorf("unknown artifact source %q, use url, github or s3", a.Source)
+	}
+	if a.Checksum == "" && a.Signature == "" {
+		return fmt.Errorf("artifacts must be verified, set checksum or signature")
+	}
+	if a.StripComponents < 0 {
+		return fmt.Errorf("invalid artifact strip_components: %d", a.StripComponents)
+	}
+	return nil
+}
+
+// Redacted copy of the config without credentials, for API responses
+func (a *ProjectArtifactConfig) Redacted() *ProjectArtifactConfig {
+	if a == nil {
+		return nil
+	}
+	r := *a
+	r.Token, r.SecretAccessKey = "", ""
+	return &r
+}
+
+// ProjectPin lock of a project on the revision it served when it was pinned, e.g. during an
+// incident
+type ProjectPin struct {
+	Ref      string    `yaml:"ref,omitempty" json:"ref,omitempty"`       // branch or tag checked out when pinned
+	Commit   string    `yaml:"commit,omitempty" json:"commit,omitempty"` // commit or revision checked out when pinned
+	Reason   string    `yaml:"reason,omitempty" json:"reason,omitempty"`
+	PinnedBy string    `yaml:"pinned_by" json:"pinnedBy"`
+	PinnedAt time.Time `yaml:"pinned_at" json:"pinnedAt"`
+}
+
+// provider types of ProjectProviderConfig
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea"
+)
+
+// ProjectProviderConfig repository of a project on GitHub, GitLab or Gitea, and the webhook
+// gohook registered there to deliver pushes to the project's GitHook
+type ProjectProviderConfig struct {
+	Type           string   `yaml:"type" json:"type"`                                          // github | gitlab | gitea
+	APIURL         string   `yaml:"api_url,omitempty" json:"apiUrl,omitempty"`                 // default derived from the remote host
+	Repo           string   `yaml:"repo,omitempty" json:"repo,omitempty"`                      // owner/name, GitLab group/subgroup/name; default from the remote URL
+	Token          string   `yaml:"token,omitempty" json:"token,omitempty"`                    // API token allowed to manage webhooks, never returned by the API
+	URL            string   `yaml:"url,omitempty" json:"url,omitempty"`                        // registered GitHook URL, default from the request registering it
+	Events         []string `yaml:"events,omitempty" json:"events,omitempty"`                  // default push, GitLab push and tag_push
+	HookID         int64    `yaml:"hook_id,omitempty" json:"hookId,omitempty"`                 // id of the webhook on the provider, set by the registration
+	Deployments    bool     `yaml:"deployments,omitempty" json:"deployments,omitempty"`        // report GitHook deploys as GitHub deployments or GitLab environment deployments
+	Environment    string   `yaml:"environment,omitempty" json:"environment,omitempty"`        // deployment environment, default the project name
+	EnvironmentURL string   `yaml:"environment_url,omitempty" json:"environmentUrl,omitempty"` // URL of the deployed site shown by GitHub
+}
+
+// Validate check the provider type and the API URL
+func (p *ProjectProviderConfig) Validate() error {
+	if p == nil {
+		return nil
+	}
+	switch p.Type {
+	case ProviderGitHub, ProviderGitLab, ProviderGitea:
+	default:
+		return fmt.Errorf("unknown provider %q, use github, gitlab or gitea", p.Type)
+	}
+	if p.Deployments && p.Type == ProviderGitea {
+		return fmt.Errorf("deployments are reported to github and gitlab only")
+	}
+	for _, u := range []string{p.APIURL, p.URL, p.EnvironmentURL} {
+		if u != "" && !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+			return fmt.Errorf("provider URLs must be http(s) URLs: %q", u)
+		}
+	}
+	if p.Repo != "" && strings.Count(strings.Trim(p.Repo, "/"), "/") < 1 {
+		return fmt.Errorf("provider repo must be owner/name: %q", p.Repo)
+	}
+	return nil
+}
+
+// Redacted copy of the config without the token, for API responses
+func (p *ProjectProviderConfig) Redacted() *ProjectProviderConfig {
+	if p == nil {
+		return nil
+	}
+	r := *p
+	r.Token = ""
+	return &r
+}
+
+// SecretRotation previous secret of a hook or project GitHook after a rotation, accepted
+// together with the new secret until Expires so the senders can be updated
+type SecretRotation struct {
+	PreviousSecret string    `yaml:"previous_secret" json:"previous-secret"`
+	RotatedBy      string    `yaml:"rotated_by,omitempty" json:"rotated-by,omitempty"`
+	RotatedAt      time.Time `yaml:"rotated_at" json:"rotated-at"`
+	Expires        time.Time `yaml:"expires" json:"expires"`
+}
+
+// Active whether the previous secret is still accepted at now
+func (r *SecretRotation) Active(now time.Time) bool {
+	return r != nil && r.PreviousSecret != "" && now.Before(r.Expires)
+}
+
+// DefaultSecretGracePeriod time the previous secret stays valid after a rotation
+const DefaultSecretGracePeriod = 24 * time.Hour
+
+// MaxSecretGracePeriod longest grace period of a rotation
+const MaxSecretGracePeriod = 30 * 24 * time.Hour
+
+// ProjectHealthCheckConfig check run after each deploy, a deploy that does not pass it within
+// its retries is rolled back to what the project served before
+type ProjectHealthCheckConfig struct {
+	URL      string   `yaml:"url,omitempty" json:"url,omitempty"`           // http(s) URL fetched with GET
+	Status   int      `yaml:"status,omitempty" json:"status,omitempty"`     // expected URL status, any 2xx when 0
+	Body     string   `yaml:"body,omitempty" json:"body,omitempty"`         // text the URL response must contain
+	TCP      string   `yaml:"tcp,omitempty" json:"tcp,omitempty"`           // host:port that must accept connections
+	Command  []string `yaml:"command,omitempty" json:"command,omitempty"`   // program and arguments that must exit 0, run in the project path
+	Delay    string   `yaml:"delay,omitempty" json:"delay,omitempty"`       // Go duration waited before the first attempt
+	Retries  int      `yaml:"retries,omitempty" json:"retries,omitempty"`   // attempts after the first failed one, default 5
+	Interval string   `yaml:"interval,omitempty" json:"interval,omitempty"` // Go duration between attempts, default 5s
+	Timeout  string   `yaml:"timeout,omitempty" json:"timeout,omitempty"`   // Go duration of one attempt, default 5s
+}
+
+// defaults of the health check retries, interval and attempt timeout
+const (
+	DefaultHealthCheckRetries  = 5
+	DefaultHealthCheckInterval = 5 * time.Second
+	DefaultHealthCheckTimeout  = 5 * time.Second
+)
+
+// Validate check that exactly one check is configured and its timings
+func (h *ProjectHealthCheckConfig) Validate() error {
+	if h == nil {
+		return nil
+	}
+	checks := 0
+	if h.URL != "" {
+		checks++
+		if !strings.HasPrefix(h.URL, "http://") && !strings.HasPrefix(h.URL, "https://") {
+			return fmt.Errorf("healthcheck url must be an http(s) URL: %q", h.URL)
+		}
+	}
+	if h.TCP != "" {
+		checks++
+		if _, _, err := net.SplitHostPort(h.TCP); err != nil {
+			return fmt.Errorf("invalid healthcheck tcp address %q: %v", h.TCP, err)
+		}
+	}
+	if len(h.Command) > 0 {
+		checks++
+		if strings.TrimSpace(h.Command[0]) == "" {
+			return fmt.Errorf("healthcheck command needs a program")
+		}
+	}
+	if checks != 1 {
+		return fmt.Errorf("healthcheck needs exactly one of url, tcp or command")
+	}
+	if (h.Status != 0 || h.Body != "") && h.URL == "" {
+		return fmt.Errorf("healthcheck status and body apply to url checks only")
+	}
+	if h.Status != 0 && (h.Status < 100 || h.Status > 599) {
+		return fmt.Errorf("invalid healthcheck status: %d", h.Status)
+	}
+	if h.Retries < 0 {
+		return fmt.Errorf("invalid healthcheck retries: %d", h.Retries)
+	}
+	if _, _, _, err := h.Timings(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Timings delay before the first attempt, interval between attempts and timeout of one attempt
+func (h *ProjectHealthCheckConfig) Timings() (delay, interval, timeout time.Duration, err error) {
+	parse := func(name, value string, def time.Duration, zero bool) (time.Duration, error) {
+		if value == "" {
+			return def, nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 || (d == 0 && !zero) {
+			return 0, fmt.Errorf("invalid healthcheck %s: %s", name, value)
+		}
+		return d, nil
+	}
+	if delay, err = parse("delay", h.Delay, 0, true); err != nil {
+		return
+	}
+	if interval, err = parse("interval", h.Interval, DefaultHealthCheckInterval, false); err != nil {
+		return
+	}
+	timeout, err = parse("timeout", h.Timeout, DefaultHealthCheckTimeout, false)
+	return
+}
+
+// Attempts number of times the check runs before the deploy is rolled back
+func (h *ProjectHealthCheckConfig) Attempts() int {
+	if h.Retries == 0 {
+		return DefaultHealthCheckRetries + 1
+	}
+	return h.Retries + 1
+}
+
+// ProjectReleaseConfig release directory deploys: every deploy exports the deployed commit of
+// the project repository into Dir/releases/<id>, links the shared paths, runs the build steps
+// and then atomically points the Dir/current symlink at the new release.
+type ProjectReleaseConfig struct {
+	Dir    string               `yaml:"dir" json:"dir"`                           // absolute directory holding releases/, shared/ and current
+	Keep   int                  `yaml:"keep,omitempty" json:"keep,omitempty"`     // releases kept for rollback including the current one, default 5
+	Shared []string             `yaml:"shared,omitempty" json:"shared,omitempty"` // paths linked from Dir/shared into every release, e.g. .env or storage
+	Build  []ProjectReleaseStep `yaml:"build,omitempty" json:"build,omitempty"`   // commands run in the new release before it is activated
+}
+
+// ProjectReleaseStep build command of a release
+type ProjectReleaseStep struct {
+	Command []string `yaml:"command" json:"command"`                     // program and arguments, not run through a shell
+	Timeout string   `yaml:"timeout,omitempty" json:"timeout,omitempty"` // Go duration, default 10m
+}
+
+// DefaultReleaseKeep releases kept unless configured
+const DefaultReleaseKeep = 5
+
+// DefaultReleaseStepTimeout time a build step may run unless configured
+const DefaultReleaseStepTimeout = 10 * time.Minute
+
+// Validate check the directory, shared paths and build steps
+func (r *ProjectReleaseConfig) Validate() error {
+	if r == nil {
+		return nil
+	}
+	if !filepath.IsAbs(r.Dir) {
+		return fmt.Errorf("releases dir must be an absolute path: %q", r.Dir)
+	}
+	if r.Keep < 0 {
+		return fmt.Errorf("invalid releases keep: %d", r.Keep)
+	}
+	for _, p := range r.Shared {
+		clean := filepath.Clean(p)
+		if p == "" || filepath.IsAbs(p) || clean == "." || strings.HasPrefix(clean, "..") {
+			return fmt.Errorf("releases shared path must be relative and inside the release: %q", p)
+		}
+	}
+	for _, step := range r.Build {
+		if len(step.Command) == 0 || strings.TrimSpace(step.Command[0]) == "" {
+			return fmt.Errorf("releases build steps need a command")
+		}
+		if _, err := step.RunTimeout(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KeepCount releases kept including the current one
+func (r *ProjectReleaseConfig) KeepCount() int {
+	if r.Keep <= 0 {
+		return DefaultReleaseKeep
+	}
+	return r.Keep
+}
+
+// RunTimeout time the build step may run
+func (s ProjectReleaseStep) RunTimeout() (time.Duration, error) {
+	if s.Timeout == "" {
+		return DefaultReleaseStepTimeout, nil
+	}
+	d, err := time.ParseDuration(s.Timeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid releases build timeout: %s", s.Timeout)
+	}
+	return d, nil
+}
+
+// ProjectMigrationConfig database migration command run after the checkout of a GitHook deploy
+// or a promotion, before the service is restarted
+type ProjectMigrationConfig struct {
+	Command []string `yaml:"command" json:"command"`                     // program and arguments, not run through a shell
+	Dir     string   `yaml:"dir,omitempty" json:"dir,omitempty"`         // working directory relative to the project path
+	Env     []string `yaml:"env,omitempty" json:"env,omitempty"`         // extra KEY=value variables
+	Timeout string   `yaml:"timeout,omitempty" json:"timeout,omitempty"` // Go duration, default 10m
+}
+
+// DefaultMigrationTimeout time a migration may run unless configured
+const DefaultMigrationTimeout = 10 * time.Minute
+
+// Validate check the command, directory, variables and timeout
+func (m *ProjectMigrationConfig) Validate() error {
+	if m == nil {
+		return nil
+	}
+	if len(m.Command) == 0 || strings.TrimSpace(m.Command[0]) == "" {
+		return fmt.Errorf("migrations need a command")
+	}
+	if m.Dir != "" && (filepath.IsAbs(m.Dir) || strings.HasPrefix(filepath.Clean(m.Dir), "..")) {
+		return fmt.Errorf("migrations dir must be inside the project: %s", m.Dir)
+	}
+	for _, kv := range m.Env {
+		if i := strings.Index(kv, "="); i <= 0 {
+			return fmt.Errorf("invalid migrations env %q, use KEY=value", kv)
+		}
+	}
+	if _, err := m.RunTimeout(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RunTimeout time the migration command may run
+func (m *ProjectMigrationConfig) RunTimeout() (time.Duration, error) {
+	if m.Timeout == "" {
+		return DefaultMigrationTimeout, nil
+	}
+	d, err := time.ParseDuration(m.Timeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid migrations timeout: %s", m.Timeout)
+	}
+	return d, nil
+}
+
+// composeProjectName valid docker compose project name
+var composeProjectName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ProjectComposeConfig deploy target of a project run with docker compose: after the checkout
+// is switched the images are pulled and the services brought up.
+type ProjectComposeConfig struct {
+	Files         []string `yaml:"files,omitempty" json:"files,omitempty"`                  // compose files relative to the project path, default compose.yaml / docker-compose.yml
+	ProjectName   string   `yaml:"project_name,omitempty" json:"projectName,omitempty"`     // default the directory name
+	Services      []string `yaml:"services,omitempty" json:"services,omitempty"`            // services to pull and bring up, default all
+	SkipPull      bool     `yaml:"skip_pull,omitempty" json:"skipPull,omitempty"`           // do not run compose pull, e.g. for locally built images
+	Build         bool     `yaml:"build,omitempty" json:"build,omitempty"`                  // build images before starting the containers
+	RemoveOrphans bool     `yaml:"remove_orphans,omitempty" json:"removeOrphans,omitempty"` // remove containers of services no longer in the files
+	Wait          string   `yaml:"wait,omitempty" json:"wait,omitempty"`                    // Go duration to wait for the services to be running and healthy, empty does not wait
+}
+
+// Validate check names and the wait duration
+func (c *ProjectComposeConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	for _, f := range c.Files {
+		if f == "" || strings.HasPrefix(f, "-") {
+			return fmt.Errorf("invalid compose file: %q", f)
+		}
+	}
+	if c.ProjectName != "" && !composeProjectName.MatchString(c.ProjectName) {
+		return fmt.Errorf("invalid compose project name %q, use lowercase letters, digits, - and _", c.ProjectName)
+	}
+	for _, s := range c.Services {
+		if s == "" || strings.HasPrefix(s, "-") {
+			return fmt.Errorf("invalid compose service: %q", s)
+		}
+	}
+	if _, err := c.WaitTimeout(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// WaitTimeout time the services may take to become healthy, 0 does not wait
+func (c *ProjectComposeConfig) WaitTimeout() (time.Duration, error) {
+	if c.Wait == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.Wait)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid compose wait: %s", c.Wait)
+	}
+	return d, nil
+}
+
+// DefaultGitMaintenanceInterval time between scheduled git maintenance runs unless configured
+const DefaultGitMaintenanceInterval = 7 * 24 * time.Hour
+
+// ProjectGitMaintenanceConfig scheduled repository maintenance of a project checkout:
+// git remote prune, git prune and git gc
+type ProjectGitMaintenanceConfig struct {
+	Enabled    bool   `yaml:"enabled" json:"enabled"`
+	Interval   string `yaml:"interval,omitempty" json:"interval,omitempty"`     // Go duration such as 24h, default 168h
+	Aggressive bool   `yaml:"aggressive,omitempty" json:"aggressive,omitempty"` // git gc --aggressive, slower but smaller
+}
+
+// Every return the time between scheduled runs
+func (m *ProjectGitMaintenanceConfig) Every() time.Duration {
+	if m == nil || m.Interval == "" {
+		return DefaultGitMaintenanceInterval
+	}
+	d, err := time.ParseDuration(m.Interval)
+	if err != nil || d <= 0 {
+		return DefaultGitMaintenanceInterval
+	}
+	return d
+}
+
+// Validate check the interval, runs more often than hourly are refused
+func (m *ProjectGitMaintenanceConfig) Validate() error {
+	if m == nil || m.Interval == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(m.Interval)
+	if err != nil {
+		return fmt.Errorf("invalid git maintenance interval %q: %v", m.Interval, err)
+	}
+	if d < time.Hour {
+		return fmt.Errorf("git maintenance interval must be at least 1h, got %s", m.Interval)
+	}
+	return nil
+}
+
+// polling intervals of ProjectPollConfig
+const (
+	DefaultPollInterval = 5 * time.Minute
+	MinPollInterval     = 30 * time.Second
+)
+
+// ProjectPollConfig GitHook fallback for projects behind NAT or without provider webhooks:
+// the remote is fetched every interval and a moved branch or a new tag is deployed like a push
+type ProjectPollConfig struct {
+	Enabled  bool   `yaml:"enabled" json:"enabled"`
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"` // Go duration such as 2m, default 5m, at least 30s
+	Jitter   string `yaml:"jitter,omitempty" json:"jitter,omitempty"`     // random delay up to this added to each interval, default a tenth of the interval
+}
+
+// Every return the time between polls
+func (p *ProjectPollConfig) Every() time.Duration {
+	if p == nil || p.Interval == "" {
+		return DefaultPollInterval
+	}
+	d, err := time.ParseDuration(p.Interval)
+	if err != nil || d < MinPollInterval {
+		return DefaultPollInterval
+	}
+	return d
+}
+
+// MaxJitter return the largest random delay added to an interval
+func (p *ProjectPollConfig) MaxJitter() time.Duration {
+	if p != nil && p.Jitter != "" {
+		if d, err := time.ParseDuration(p.Jitter); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return p.Every() / 10
+}
+
+// Validate check the interval and the jitter
+func (p *ProjectPollConfig) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.Interval != "" {
+		d, err := time.ParseDuration(p.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid poll interval %q: %v", p.Interval, err)
+		}
+		if d < MinPollInterval {
+			return fmt.Errorf("poll interval must be at least %s, got %s", MinPollInterval, p.Interval)
+		}
+	}
+	if p.Jitter != "" {
+		d, err := time.ParseDuration(p.Jitter)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid poll jitter %q", p.Jitter)
+		}
+	}
+	return nil
+}
+
+// DefaultProtectedBranches branches that cannot be deleted when a project has no protection config
+var DefaultProtectedBranches = []string{"main", "master"}
+
+// ProjectProtectionConfig branches and tags that cannot be deleted or force-switched through the
+// API or GitHook. Patterns use path.Match syntax, e.g. release/* or v*
+type ProjectProtectionConfig struct {
+	Branches []string `yaml:"branches,omitempty" json:"branches,omitempty"`
+	Tags     []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+}
+
+// Validate check the patterns
+func (p *ProjectProtectionConfig) Validate() error {
+	if p == nil {
+		return nil
+	}
+	for _, pattern := range append(append([]string{}, p.Branches...), p.Tags...) {
+		if _, err := path.Match(pattern, ""); err != nil || strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("invalid protection pattern: %q", pattern)
+		}
+	}
+	return nil
+}
+
+// DefaultSnapshotKeep snapshots kept per project unless configured
+const DefaultSnapshotKeep = 10
+
+// ProjectSnapshotConfig controls snapshots of local changes taken before a force deploy resets
+// the working tree, they are kept as git refs under refs/gohook/snapshots
+type ProjectSnapshotConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	Keep    int  `yaml:"keep,omitempty" json:"keep,omitempty"` // newest snapshots kept, default 10
+}
+
+// DefaultPreflightMinFreeMB free disk space required by preflight checks unless configured
+const DefaultPreflightMinFreeMB = 100
+
+// ProjectPreflightConfig controls the checks run before fetch/checkout and before file sync,
+// nil runs the disk and write checks with their defaults
+type ProjectPreflightConfig struct {
+	Disabled       bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`               // skip all checks
+	MinFreeMB      int  `yaml:"min_free_mb,omitempty" json:"minFreeMB,omitempty"`           // free space required on the project and sync target paths, default 100, -1 skips
+	SkipWriteCheck bool `yaml:"skip_write_check,omitempty" json:"skipWriteCheck,omitempty"` // do not test write permission on the project path
+	GitFsck        bool `yaml:"git_fsck,omitempty" json:"gitFsck,omitempty"`                // run git fsck --connectivity-only, slow on large repositories
+}
+
+// MinFreeBytes return the free disk space required, 0 when the disk check is off
+func (p *ProjectPreflightConfig) MinFreeBytes() int64 {
+	mb := DefaultPreflightMinFreeMB
+	if p != nil {
+		if p.Disabled || p.MinFreeMB < 0 {
+			return 0
+		}
+		if p.MinFreeMB > 0 {
+			mb = p.MinFreeMB
+		}
+	}
+	return int64(mb) << 20
+}
+
+// Validate check the configured values
+func (p *ProjectPreflightConfig) Validate() error {
+	if p != nil && p.MinFreeMB < -1 {
+		return fmt.Errorf("invalid preflight min_free_mb: %d", p.MinFreeMB)
+	}
+	return nil
+}
+
+// ProjectPromotionConfig controls promotions into a project (e.g. staging -> production)
+type ProjectPromotionConfig struct {
+	From            []string `yaml:"from,omitempty" json:"from,omitempty"`                        // projects allowed to promote into this one, empty allows any
+	RequireApproval bool     `yaml:"require_approval,omitempty" json:"requireApproval,omitempty"` // an admin must approve before the project is switched
+}
+
+// ProjectServiceConfig describes the process/service that runs a deployed project
+type ProjectServiceConfig struct {
+	Type            string `yaml:"type" json:"type"`                                             // systemd | compose | pm2
+	Name            string `yaml:"name,omitempty" json:"name,omitempty"`                         // systemd unit, compose project or pm2 app name
+	ComposeFile     string `yaml:"compose_file,omitempty" json:"composeFile,omitempty"`          // compose file, relative to project path
+	RestartOnDeploy bool   `yaml:"restart_on_deploy,omitempty" json:"restartOnDeploy,omitempty"` // run DeployAction after a successful GitHook deploy
+	DeployAction    string `yaml:"deploy_action,omitempty" json:"deployAction,omitempty"`        // restart (default) | reload
 }
 
 // ProjectSyncConfig describes sync strategy for a project
@@ -85,6 +1432,8 @@ type ProjectSyncConfig struct {
 	// Watch toggles (primary node fsnotify scanner + auto task enqueue).
 	// Nil means enabled (default on) to preserve backward compatibility.
 	WatchEnabled *bool `yaml:"watch_enabled,omitempty" json:"watchEnabled,omitempty"`
+	// SyncOnDeploy enqueues a sync run to all nodes after a successful GitHook deploy.
+	SyncOnDeploy bool `yaml:"sync_on_deploy,omitempty" json:"syncOnDeploy,omitempty"`
 	// Semantics knobs (UI-managed, applied by agent).
 	PreserveMode  *bool  `yaml:"preserve_mode,omitempty" json:"preserveMode,omitempty"`   // keep file/dir permission bits (default true)
 	PreserveMtime *bool  `yaml:"preserve_mtime,omitempty" json:"preserveMtime,omitempty"` // keep mtime (default true)
@@ -95,11 +1444,20 @@ type ProjectSyncConfig struct {
 	OverlayFullScanEvery    int                     `yaml:"overlay_fullscan_every,omitempty" json:"overlayFullScanEvery,omitempty"`       // force full index every N tasks (overlay)
 	OverlayFullScanInterval string                  `yaml:"overlay_fullscan_interval,omitempty" json:"overlayFullScanInterval,omitempty"` // force full index at least every duration (e.g. 30m)
 	Nodes                   []ProjectSyncNodeConfig `yaml:"nodes,omitempty" json:"nodes,omitempty"`
+	// Rollout controls how a run reaches multiple nodes, nil means all at once.
+	Rollout *ProjectRolloutConfig `yaml:"rollout,omitempty" json:"rollout,omitempty"`
+}
+
+// ProjectRolloutConfig describes the rollout strategy of a multi-node sync run
+type ProjectRolloutConfig struct {
+	Strategy       string `yaml:"strategy,omitempty" json:"strategy,omitempty"`              // all-at-once | rolling | canary
+	MaxUnavailable int    `yaml:"max_unavailable,omitempty" json:"maxUnavailable,omitempty"` // nodes synced at a time (rolling default 1; canary after approval, 0 = all)
 }
 
 // ProjectSyncNodeConfig describes one target node for the project
 type ProjectSyncNodeConfig struct {
-	NodeID         string   `yaml:"node_id" json:"nodeId"`
+	NodeID         string   `yaml:"node_id,omitempty" json:"nodeId,omitempty"`
+	Target         string   `yaml:"target,omitempty" json:"target,omitempty"` // node selector such as "role=web && region=eu", exclusive with node_id
 	TargetPath     string   `yaml:"target_path" json:"targetPath"`
 	Strategy       string   `yaml:"strategy,omitempty" json:"strategy,omitempty"`              // mirror | overlay
 	Driver         string   `yaml:"driver,omitempty" json:"driver,omitempty"`                  // override driver per node
@@ -117,21 +1475,46 @@ type ProjectSyncNodeConfig struct {
 
 // VersionResponse version response structure
 type VersionResponse struct {
-	Name           string             `json:"name"`
-	Path           string             `json:"path"`
-	Description    string             `json:"description"`
-	CurrentBranch  string             `json:"currentBranch"`
-	CurrentTag     string             `json:"currentTag"`
-	Mode           string             `json:"mode"` // "branch" or "tag"
-	Status         string             `json:"status"`
-	LastCommit     string             `json:"lastCommit"`
-	LastCommitTime string             `json:"lastCommitTime"`
-	Enhook         bool               `json:"enhook,omitempty"`
-	Hookmode       string             `json:"hookmode,omitempty"`
-	Hookbranch     string             `json:"hookbranch,omitempty"`
-	Hooksecret     string             `json:"hooksecret,omitempty"`
-	ForceSync      bool               `json:"forcesync,omitempty"` // GitHook 是否使用强制同步模式
-	Sync           *ProjectSyncConfig `json:"sync,omitempty"`
+	Name           string                       `json:"name"`
+	Namespace      string                       `json:"namespace"`
+	Path           string                       `json:"path"`
+	Description    string                       `json:"description"`
+	CurrentBranch  string                       `json:"currentBranch"`
+	CurrentTag     string                       `json:"currentTag"`
+	Mode           string                       `json:"mode"` // "branch" or "tag"
+	Status         string                       `json:"status"`
+	LastCommit     string                       `json:"lastCommit"`
+	LastCommitTime string                       `json:"lastCommitTime"`
+	LastUsed       *string                      `json:"lastUsed"` // last GitHook delivery, null when never delivered
+	Revision       string                       `json:"revision"` // ETag of the project config, sent in If-Match by edits
+	Enhook         bool                         `json:"enhook,omitempty"`
+	Hookmode       string                       `json:"hookmode,omitempty"`
+	Hookbranch     string                       `json:"hookbranch,omitempty"`
+	Hooksecret     string                       `json:"hooksecret,omitempty"`
+	SecretExpires  *time.Time                   `json:"secretExpires,omitempty"` // end of the grace period of the previous hooksecret
+	VCS            string                       `json:"vcs,omitempty"`
+	Hookpaths      []string                     `json:"hookpaths,omitempty"`
+	Monorepo       string                       `json:"monorepo,omitempty"`
+	ForceSync      bool                         `json:"forcesync,omitempty"` // GitHook 是否使用强制同步模式
+	EncryptEnv     bool                         `json:"encryptEnv,omitempty"`
+	Service        *ProjectServiceConfig        `json:"service,omitempty"`
+	Sync           *ProjectSyncConfig           `json:"sync,omitempty"`
+	PauseWindows   []PauseWindow                `json:"pauseWindows,omitempty"`
+	Promotion      *ProjectPromotionConfig      `json:"promotion,omitempty"`
+	Preflight      *ProjectPreflightConfig      `json:"preflight,omitempty"`
+	Snapshots      *ProjectSnapshotConfig       `json:"snapshots,omitempty"`
+	Protection     *ProjectProtectionConfig     `json:"protection,omitempty"`
+	GitMaintenance *ProjectGitMaintenanceConfig `json:"gitMaintenance,omitempty"`
+	Signatures     *ProjectSignatureConfig      `json:"signatures,omitempty"`
+	Kubernetes     *ProjectKubernetesConfig     `json:"kubernetes,omitempty"`
+	Compose        *ProjectComposeConfig        `json:"compose,omitempty"`
+	Migrations     *ProjectMigrationConfig      `json:"migrations,omitempty"`
+	Releases       *ProjectReleaseConfig        `json:"releases,omitempty"`
+	Artifact       *ProjectArtifactConfig       `json:"artifact,omitempty"`
+	HealthCheck    *ProjectHealthCheckConfig    `json:"healthcheck,omitempty"`
+	Pin            *ProjectPin                  `json:"pin,omitempty"`
+	Provider       *ProjectProviderConfig       `json:"provider,omitempty"`
+	Poll           *ProjectPollConfig           `json:"poll,omitempty"`
 }
 
 // BranchResponse branch response structure
@@ -216,18 +1599,45 @@ type ClientResponse struct {
 
 // HookResponse Hook response structure
 type HookResponse struct {
-	ID                     string      `json:"id"`
-	Name                   string      `json:"name"`
-	ExecuteCommand         string      `json:"executeCommand"`
-	WorkingDirectory       string      `json:"workingDirectory"`
-	ResponseMessage        string      `json:"responseMessage"`
-	HTTPMethods            []string    `json:"httpMethods"`
-	ArgumentsCount         int         `json:"argumentsCount"`
-	EnvironmentCount       int         `json:"environmentCount"`
-	TriggerRuleDescription string      `json:"triggerRuleDescription"`
-	TriggerRule            interface{} `json:"trigger-rule,omitempty"`
-	LastUsed               *string     `json:"lastUsed"`
-	Status                 string      `json:"status"` // active, inactive
+	ID                     string        `json:"id"`
+	Name                   string        `json:"name"`
+	Aliases                []string      `json:"aliases,omitempty"`       // previous ids and custom slugs
+	Endpoints              interface{}   `json:"endpoints,omitempty"`     // see webhook.Endpoint
+	EndpointsOnly          bool          `json:"endpointsOnly,omitempty"` // only the endpoints accept deliveries
+	Mirror                 string        `json:"mirror,omitempty"`        // shadow hook running a copy of each triggered delivery
+	SecretExpires          *time.Time    `json:"secretExpires,omitempty"` // end of the grace period of the previous secret
+	URL                    string        `json:"url"`                     // path of the hook endpoint, including the base path
+	Namespace              string        `json:"namespace"`
+	ExecuteCommand         string        `json:"executeCommand"`
+	Shell                  string        `json:"shell,omitempty"`
+	Sandbox                string        `json:"sandbox,omitempty"`
+	WorkingDirectory       string        `json:"workingDirectory"`
+	ResponseMessage        string        `json:"responseMessage"`
+	HTTPMethods            []string      `json:"httpMethods"`
+	ArgumentsCount         int           `json:"argumentsCount"`
+	EnvironmentCount       int           `json:"environmentCount"`
+	TriggerRuleDescription string        `json:"triggerRuleDescription"`
+	TriggerRule            interface{}   `json:"trigger-rule,omitempty"`
+	PauseWindows           []PauseWindow `json:"pauseWindows,omitempty"`
+	Forward                interface{}   `json:"forward,omitempty"`      // gateway target, see webhook.ForwardConfig
+	Idempotency            interface{}   `json:"idempotency,omitempty"`  // see webhook.IdempotencyConfig
+	Artifacts              interface{}   `json:"artifacts,omitempty"`    // see webhook.ArtifactsConfig
+	ObjectEvents           interface{}   `json:"objectEvents,omitempty"` // see webhook.ObjectEventsConfig
+	TransformPlugins       []string      `json:"transformPlugins,omitempty"`
+	Starlark               interface{}   `json:"starlark,omitempty"` // see webhook.StarlarkConfig
+	Budget                 interface{}   `json:"budget,omitempty"`   // see webhook.BudgetConfig
+	CircuitOpen            bool          `json:"circuitOpen,omitempty"`
+	Quota                  *QuotaConfig  `json:"quota,omitempty"`
+	InheritEnvironment     *EnvPolicy    `json:"inheritEnvironment,omitempty"`
+	ResponseTemplate       string        `json:"responseTemplate,omitempty"`
+	ResponseContentType    string        `json:"responseContentType,omitempty"`
+	SuccessHTTPCode        int           `json:"successHttpResponseCode,omitempty"`
+	FailureHTTPCode        int           `json:"failureHttpResponseCode,omitempty"`
+	SuccessOutputPattern   string        `json:"successOutputPattern,omitempty"`
+	FailureOutputPattern   string        `json:"failureOutputPattern,omitempty"`
+	LastUsed               *string       `json:"lastUsed"`
+	Revision               string        `json:"revision"` // ETag of the hook definition, sent in If-Match by edits
+	Status                 string        `json:"status"`   // active, inactive
 }
 
 func (c *AppConfig) SetMode(mode string) {
@@ -266,5 +1676,13 @@ func UpdateAppConfig(systemConfig interface{}) {
 		if panelAliasField := configValue.FieldByName("PanelAlias"); panelAliasField.IsValid() {
 			GoHookAppConfig.PanelAlias = panelAliasField.String()
 		}
+
+		// get Language and Timezone fields
+		if languageField := configValue.FieldByName("Language"); languageField.IsValid() {
+			GoHookAppConfig.Language = languageField.String()
+		}
+		if timezoneField := configValue.FieldByName("Timezone"); timezoneField.IsValid() {
+			GoHookAppConfig.Timezone = timezoneField.String()
+		}
 	}
 }
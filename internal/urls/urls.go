@@ -0,0 +1,167 @@
+// Package urls keeps the URL layout of the server: the sub-path the whole app is served
+// under behind a reverse proxy and the path prefix of hook URLs. Both can change at runtime.
+package urls
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// DefaultHooksPrefix path prefix of hook URLs when none is configured, /hooks/{id}
+const DefaultHooksPrefix = "hooks"
+
+var segmentPattern = regexp.MustCompile(`^[A-Za-z0-9._~-]+$`)
+
+// Layout URL layout of the server
+type Layout struct {
+	BasePath    string // sub-path the app is served under, "" or /path without trailing slash
+	HooksPrefix string // path before hook ids without slashes around it, "" serves hooks on /{id}
+}
+
+var (
+	mu      sync.RWMutex
+	current = Layout{HooksPrefix: DefaultHooksPrefix}
+)
+
+// Current layout in use
+func Current() Layout {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Set replace the layout in use, l must be normalized
+func Set(l Layout) {
+	mu.Lock()
+	current = l
+	mu.Unlock()
+}
+
+// FromConfig layout configured in app.yaml, defaults for the missing settings
+func FromConfig(cfg *types.ServerConfig) (Layout, error) {
+	l := Layout{HooksPrefix: DefaultHooksPrefix}
+	if cfg == nil {
+		return l, nil
+	}
+	var err error
+	if l.BasePath, err = NormalizeBasePath(cfg.BasePath); err != nil {
+		return l, err
+	}
+	if cfg.HooksURLPrefix != nil {
+		if l.HooksPrefix, err = NormalizeHooksPrefix(*cfg.HooksURLPrefix); err != nil {
+			return l, err
+		}
+	}
+	return l, nil
+}
+
+func normalizePath(p string) (string, error) {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return "", nil
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == "." || segment == ".." || !segmentPattern.MatchString(segment) {
+			return "", fmt.Errorf("invalid path segment %q", segment)
+		}
+	}
+	return p, nil
+}
+
+// NormalizeBasePath check a base path and return it as /path, "" and "/" mean the root
+func NormalizeBasePath(p string) (string, error) {
+	p, err := normalizePath(p)
+	if err != nil || p == "" {
+		return "", err
+	}
+	return "/" + p, nil
+}
+
+// NormalizeHooksPrefix check a hooks prefix and return it without surrounding slashes
+func NormalizeHooksPrefix(p string) (string, error) {
+	return normalizePath(p)
+}
+
+// HooksPath path of the hook routes relative to the base path, ends with a slash
+func (l Layout) HooksPath() string {
+	if l.HooksPrefix == "" {
+		return "/"
+	}
+	return "/" + l.HooksPrefix + "/"
+}
+
+// HookPath path of a hook relative to the base path, as routed by the server
+func (l Layout) HookPath(id string) string {
+	return l.HooksPath() + id
+}
+
+// PublicHookPath path of a hook as called by clients, including the base path
+func (l Layout) PublicHookPath(id string) string {
+	return l.BasePath + l.HookPath(id)
+}
+
+// HumanPattern hook URL pattern for display, e.g. /hooks/{id}
+func (l Layout) HumanPattern() string {
+	return l.PublicHookPath("{id}")
+}
+
+// MatchHook id and namespace addressed by a path relative to the base path, either
+// {hooks path}{id} or /ns/{namespace}{hooks path}{id}
+func (l Layout) MatchHook(path string) (id, ns string, ok bool) {
+	if strings.HasPrefix(path, "/ns/") {
+		rest := path[len("/ns/"):]
+		if i := strings.Index(rest, "/"); i > 0 {
+			if id, ok := l.matchHooksPath(rest[i:]); ok {
+				return id, rest[:i], true
+			}
+		}
+	}
+	id, ok = l.matchHooksPath(path)
+	return id, "", ok
+}
+
+func (l Layout) matchHooksPath(path string) (string, bool) {
+	prefix := l.HooksPath()
+	if !strings.HasPrefix(path, prefix) || len(path) == len(prefix) {
+		return "", false
+	}
+	return path[len(prefix):], true
+}
+
+// StripBasePath serve the app under the base path. Requests outside the base path are served
+// unchanged so proxies that already strip the sub-path keep working, the bare base path
+// redirects to the base path with a trailing slash so relative UI links resolve.
+func StripBasePath(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		base := Current().BasePath
+		if base == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p := r.URL.Path
+		if p == base {
+			target := base + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		if !strings.HasPrefix(p, base+"/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = p[len(base):]
+		if r.URL.RawPath != "" {
+			r2.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, base)
+		}
+		r2.RequestURI = r2.URL.RequestURI()
+		next.ServeHTTP(w, r2)
+	})
+}
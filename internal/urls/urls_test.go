@@ -0,0 +1,105 @@
+package urls
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestFromConfig(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name    string
+		cfg     *types.ServerConfig
+		want    Layout
+		wantErr bool
+	}{
+		{"defaults", nil, Layout{HooksPrefix: "hooks"}, false},
+		{"no prefix", &types.ServerConfig{HooksURLPrefix: str("")}, Layout{}, false},
+		{"slashes trimmed", &types.ServerConfig{HooksURLPrefix: str("/api/in/"), BasePath: "gohook/"}, Layout{BasePath: "/gohook", HooksPrefix: "api/in"}, false},
+		{"root base path", &types.ServerConfig{BasePath: "/"}, Layout{HooksPrefix: "hooks"}, false},
+		{"dot segment", &types.ServerConfig{BasePath: "/a/../b"}, Layout{}, true},
+		{"space", &types.ServerConfig{HooksURLPrefix: str("my hooks")}, Layout{}, true},
+		{"wildcard", &types.ServerConfig{HooksURLPrefix: str(":id")}, Layout{}, true},
+	}
+	for _, tt := range tests {
+		got, err := FromConfig(tt.cfg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: FromConfig() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("%s: FromConfig() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchHook(t *testing.T) {
+	hooks := Layout{BasePath: "/gohook", HooksPrefix: "hooks"}
+	root := Layout{}
+	tests := []struct {
+		layout Layout
+		path   string
+		id, ns string
+		ok     bool
+	}{
+		{hooks, "/hooks/deploy", "deploy", "", true},
+		{hooks, "/hooks/releases/site", "releases/site", "", true},
+		{hooks, "/ns/team/hooks/deploy", "deploy", "team", true},
+		{hooks, "/hooks/", "", "", false},
+		{hooks, "/hooksx/deploy", "", "", false},
+		{hooks, "/deploy", "", "", false},
+		{root, "/deploy", "deploy", "", true},
+		{root, "/ns/team/deploy", "deploy", "team", true},
+		{root, "/", "", "", false},
+	}
+	for _, tt := range tests {
+		id, ns, ok := tt.layout.MatchHook(tt.path)
+		if id != tt.id || ns != tt.ns || ok != tt.ok {
+			t.Errorf("%+v.MatchHook(%q) = %q, %q, %t, want %q, %q, %t", tt.layout, tt.path, id, ns, ok, tt.id, tt.ns, tt.ok)
+		}
+	}
+	if got := hooks.HumanPattern(); got != "/gohook/hooks/{id}" {
+		t.Errorf("HumanPattern() = %q", got)
+	}
+	if got := root.HumanPattern(); got != "/{id}" {
+		t.Errorf("HumanPattern() = %q", got)
+	}
+}
+
+func TestStripBasePath(t *testing.T) {
+	saved := Current()
+	defer Set(saved)
+	Set(Layout{BasePath: "/gohook", HooksPrefix: "hooks"})
+
+	handler := StripBasePath(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	tests := []struct {
+		target   string
+		status   int
+		body     string
+		location string
+	}{
+		{"/gohook/hooks/deploy?x=1", http.StatusOK, "/hooks/deploy", ""},
+		{"/gohook/", http.StatusOK, "/", ""},
+		{"/gohook?tab=1", http.StatusMovedPermanently, "", "/gohook/?tab=1"},
+		{"/hooks/deploy", http.StatusOK, "/hooks/deploy", ""},
+		{"/gohookx/deploy", http.StatusOK, "/gohookx/deploy", ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.target, rec.Code, tt.status)
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s: path = %q, want %q", tt.target, rec.Body.String(), tt.body)
+		}
+		if loc := rec.Header().Get("Location"); loc != tt.location {
+			t.Errorf("%s: Location = %q, want %q", tt.target, loc, tt.location)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package version
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// composeCommandTimeout max time compose pull or up may run, the health-check wait is added
+const composeCommandTimeout = 10 * time.Minute
+
+// maxComposeOutput bytes of compose output kept in the project activity log
+const maxComposeOutput = 32 << 10
+
+// composeArgs docker compose command line for the project's compose files and project name
+func composeArgs(cfg *types.ProjectComposeConfig, args ...string) []string {
+	cmd := []string{"compose"}
+	if cfg.ProjectName != "" {
+		cmd = append(cmd, "-p", cfg.ProjectName)
+	}
+	for _, f := range cfg.Files {
+		cmd = append(cmd, "-f", f)
+	}
+	return append(cmd, args...)
+}
+
+// composeDeployCommands pull and up commands of a compose deploy
+func composeDeployCommands(cfg *types.ProjectComposeConfig) ([][]string, error) {
+	wait, err := cfg.WaitTimeout()
+	if err != nil {
+		return nil, err
+	}
+	var commands [][]string
+	if !cfg.SkipPull {
+		commands = append(commands, composeArgs(cfg, append([]string{"pull"}, cfg.Services...)...))
+	}
+	up := []string{"up", "-d"}
+	if cfg.Build {
+		up = append(up, "--build")
+	}
+	if cfg.RemoveOrphans {
+		up = append(up, "--remove-orphans")
+	}
+	if wait > 0 {
+		// compose waits for running containers and passing health checks
+		up = append(up, "--wait", "--wait-timeout", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+	}
+	return append(commands, composeArgs(cfg, append(up, cfg.Services...)...)), nil
+}
+
+// runCompose run the pull and up commands in the project directory and return their combined
+// output, each command preceded by its command line
+func runCompose(project *types.ProjectConfig) (string, error) {
+	commands, err := composeDeployCommands(project.Compose)
+	if err != nil {
+		return "", err
+	}
+	wait, _ := project.Compose.WaitTimeout()
+	var output bytes.Buffer
+	for _, args := range commands {
+		fmt.Fprintf(&output, "$ docker %s\n", strings.Join(args, " "))
+		ctx, cancel := context.WithTimeout(context.Background(), composeCommandTimeout+wait)
+		cmd := exec.CommandContext(ctx, "docker", args...)
+		cmd.Dir = project.Path
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		err := cmd.Run()
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", composeCommandTimeout+wait)
+		}
+		cancel()
+		if err != nil {
+			return output.String(), fmt.Errorf("docker %s: %v", strings.Join(args, " "), err)
+		}
+	}
+	return output.String(), nil
+}
+
+// deployCompose pull and bring up the compose services of a project after its checkout was
+// switched to ref, the output is recorded in the project activity log
+func deployCompose(project *types.ProjectConfig, ref, username, ipAddress string) error {
+	start := time.Now()
+	output, err := runCompose(project)
+	if len(output) > maxComposeOutput {
+		output = "...\n" + output[len(output)-maxComposeOutput:]
+	}
+
+	errMsg := ""
+	description := fmt.Sprintf("Compose deploy of %s succeeded in %s", ref, time.Since(start).Round(time.Second))
+	if err != nil {
+		errMsg = err.Error()
+		description = fmt.Sprintf("Compose deploy of %s failed: %s", ref, errMsg)
+		log.Printf("compose deploy failed: project=%s, ref=%s, error=%v", project.Name, ref, err)
+	}
+	if output != "" {
+		description += "\n" + output
+	}
+	database.LogProjectAction(
+		project.Name,                  // projectName
+		database.ProjectActionCompose, // action
+		"",                            // oldValue
+		ref,                           // newValue
+		username,                      // username
+		err == nil,                    // success
+		errMsg,                        // error
+		"",                            // commitHash
+		description,                   // description
+		ipAddress,                     // ipAddress
+	)
+	return err
+}
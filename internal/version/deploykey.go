@@ -0,0 +1,301 @@
+package version
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/env"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/types"
+	"gorm.io/gorm"
+)
+
+// remoteGitCommands git subcommands that contact the remote and use the deploy key
+var remoteGitCommands = map[string]bool{
+	"clone": true, "fetch": true, "pull": true, "push": true,
+	"ls-remote": true, "remote": true, "submodule": true,
+}
+
+// getDeployKey stored deploy key of a project, nil when it has none
+func getDeployKey(projectName string) (*database.ProjectDeployKey, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	var key database.ProjectDeployKey
+	err := db.Where("project_name = ?", projectName).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// generateDeployKey create an ed25519 keypair with ssh-keygen, returning the
+// OpenSSH private key and the authorized_keys line of the public key
+func generateDeployKey(comment string) (string, string, error) {
+	dir, err := os.MkdirTemp("", "gohook-deploy-key-")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "id_ed25519")
+	output, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", comment, "-f", keyFile).CombinedOutput()
+	if err != nil {
+		return "", "", fmt.Errorf("ssh-keygen failed: %v, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	privateKey, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", "", err
+	}
+	publicKey, err := os.ReadFile(keyFile + ".pub")
+	if err != nil {
+		return "", "", err
+	}
+	return string(privateKey), strings.TrimSpace(string(publicKey)), nil
+}
+
+// publicKeyFingerprint SHA256 fingerprint of an authorized_keys line, as printed by ssh-keygen -l
+func publicKeyFingerprint(publicKey string) (string, error) {
+	fields := strings.Fields(publicKey)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("invalid public key")
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid public key: %v", err)
+	}
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
+
+// saveDeployKey generate a new deploy key for a project, replacing the current one
+func saveDeployKey(projectName, username string) (*database.ProjectDeployKey, error) {
+	privateKey, publicKey, err := generateDeployKey("gohook-" + projectName)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := publicKeyFingerprint(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := env.EncryptValue(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	db := database.GetDB()
+	var key database.ProjectDeployKey
+	err = db.Where("project_name = ?", projectName).First(&key).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	key.ProjectName = projectName
+	key.PublicKey = publicKey
+	key.PrivateKey = encrypted
+	key.Fingerprint = fingerprint
+	key.CreatedBy = username
+	if err := db.Save(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// deployKeyResponse public part of a deploy key
+func deployKeyResponse(key *database.ProjectDeployKey) gin.H {
+	return gin.H{
+		"publicKey":   key.PublicKey,
+		"fingerprint": key.Fingerprint,
+		"createdBy":   key.CreatedBy,
+		"createdAt":   key.UpdatedAt,
+	}
+}
+
+// projectByPath project configured with the given path
+func projectByPath(projectPath string) *types.ProjectConfig {
+	if types.GoHookVersionData == nil {
+		return nil
+	}
+	projectPath = filepath.Clean(projectPath)
+	for i := range types.GoHookVersionData.Projects {
+		if filepath.Clean(types.GoHookVersionData.Projects[i].Path) == projectPath {
+			return &types.GoHookVersionData.Projects[i]
+		}
+	}
+	return nil
+}
+
+// deployKeyEnv GIT_SSH_COMMAND environment making a remote git command of the project at
+// projectPath authenticate with its deploy key. The returned cleanup removes the key file.
+// A deploy key that can not be used is an error: running git with the credentials of the
+// server instead could reach the remote as someone else.
+func deployKeyEnv(projectPath string, args []string) ([]string, func(), error) {
+	noop := func() {}
+	if len(args) == 0 || !remoteGitCommands[args[0]] || database.GetDB() == nil {
+		return nil, noop, nil
+	}
+	project := projectByPath(projectPath)
+	if project == nil {
+		return nil, noop, nil
+	}
+	key, err := getDeployKey(project.Name)
+	if err != nil {
+		return nil, noop, fmt.Errorf("load deploy key of project %s: %v", project.Name, err)
+	}
+	if key == nil {
+		return nil, noop, nil
+	}
+	privateKey, err := env.DecryptValue(key.PrivateKey)
+	if err != nil {
+		return nil, noop, fmt.Errorf("decrypt deploy key of project %s: %v", project.Name, err)
+	}
+
+	f, err := os.CreateTemp("", "gohook-deploy-key-")
+	if err != nil {
+		return nil, noop, fmt.Errorf("write deploy key of project %s: %v", project.Name, err)
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+	_, err = f.WriteString(privateKey)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0600)
+	}
+	if err != nil {
+		cleanup()
+		return nil, noop, fmt.Errorf("write deploy key of project %s: %v", project.Name, err)
+	}
+
+	sshCommand := fmt.Sprintf("ssh -i '%s' -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new", f.Name())
+	return []string{"GIT_SSH_COMMAND=" + sshCommand}, cleanup, nil
+}
+
+// HandleGetDeployKey get the public deploy key of a project
+func HandleGetDeployKey(c *gin.Context) {
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	key, err := getDeployKey(project.Name)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadDeployKeyFailed, err)
+		return
+	}
+	if key == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeNoDeployKey, nil)
+		return
+	}
+	c.JSON(http.StatusOK, deployKeyResponse(key))
+}
+
+// HandleCreateDeployKey generate an SSH deploy key for a project, {"rotate": true}
+// replaces an existing key
+func HandleCreateDeployKey(c *gin.Context) {
+	var req struct {
+		Rotate bool `json:"rotate"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+			return
+		}
+	}
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	existing, err := getDeployKey(project.Name)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadDeployKeyFailed, err)
+		return
+	}
+	if existing != nil && !req.Rotate {
+		i18n.JSONError(c, http.StatusConflict, i18n.CodeDeployKeyExists, nil)
+		return
+	}
+
+	username := currentUsername(c)
+	key, err := saveDeployKey(project.Name, username)
+	oldFingerprint, description := "", "deploy key generated"
+	if existing != nil {
+		oldFingerprint, description = existing.Fingerprint, "deploy key rotated"
+	}
+	newFingerprint, errMsg := "", ""
+	if err != nil {
+		errMsg = err.Error()
+	} else {
+		newFingerprint = key.Fingerprint
+	}
+	database.LogProjectAction(
+		project.Name,                    // projectName
+		database.ProjectActionDeployKey, // action
+		oldFingerprint,                  // oldValue
+		newFingerprint,                  // newValue
+		username,                        // username
+		err == nil,                      // success
+		errMsg,                          // error
+		"",                              // commitHash
+		description,                     // description
+		middleware.GetClientIP(c),       // ipAddress
+	)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeGenerateDeployKeyFailed, err)
+		return
+	}
+	response := deployKeyResponse(key)
+	response["message"] = i18n.Message(c, i18n.CodeDeployKeyGenerated)
+	response["code"] = i18n.CodeDeployKeyGenerated
+	c.JSON(http.StatusOK, response)
+}
+
+// HandleDeleteDeployKey remove the deploy key of a project, git falls back to the
+// SSH configuration of the server
+func HandleDeleteDeployKey(c *gin.Context) {
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	existing, err := getDeployKey(project.Name)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadDeployKeyFailed, err)
+		return
+	}
+	if existing == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeNoDeployKey, nil)
+		return
+	}
+	if err := database.GetDB().Unscoped().Delete(existing).Error; err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDeleteDeployKeyFailed, err)
+		return
+	}
+	database.LogProjectAction(
+		project.Name,                    // projectName
+		database.ProjectActionDeployKey, // action
+		existing.Fingerprint,            // oldValue
+		"",                              // newValue
+		currentUsername(c),              // username
+		true,                            // success
+		"",                              // error
+		"",                              // commitHash
+		"deploy key removed",            // description
+		middleware.GetClientIP(c),       // ipAddress
+	)
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeDeployKeyRemoved), "code": i18n.CodeDeployKeyRemoved})
+}
@@ -5,38 +5,81 @@ import (
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/config"
+	"github.com/mycoool/gohook/internal/database"
 	"github.com/mycoool/gohook/internal/env"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
 	"github.com/mycoool/gohook/internal/types"
 )
 
+// findEnabledProject find enabled project config by name
+func findEnabledProject(projectName string) *types.ProjectConfig {
+	for i := range types.GoHookVersionData.Projects {
+		proj := &types.GoHookVersionData.Projects[i]
+		if proj.Name == projectName && proj.Enabled {
+			return proj
+		}
+	}
+	return nil
+}
+
+// loadProjectEnv load env content from encrypted storage or .env file
+func loadProjectEnv(project *types.ProjectConfig) (string, bool, error) {
+	if project.EncryptEnv {
+		return env.GetEncryptedEnv(project.Name)
+	}
+	return env.GetEnvFile(project.Path)
+}
+
 // get project environment variable file (.env)
 func HandleGetEnv(c *gin.Context) {
 	projectName := c.Param("name")
 
-	// find project path
-	var projectPath string
-	for _, proj := range types.GoHookVersionData.Projects {
-		if proj.Name == projectName && proj.Enabled {
-			projectPath = proj.Path
-			break
-		}
+	project := findEnabledProject(projectName)
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
 	}
 
-	if projectPath == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
-		return
+	// secret values are masked unless an admin explicitly asks to reveal them
+	reveal := c.Query("reveal") == "true"
+	if reveal {
+		if role, _ := c.Get("role"); role != "admin" {
+			i18n.JSONError(c, http.StatusForbidden, i18n.CodeRevealRequiresAdmin, nil)
+			return
+		}
 	}
 
-	envContent, exists, err := env.GetEnvFile(projectPath)
+	envContent, exists, err := loadProjectEnv(project)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadEnvFailed, err)
 		return
 	}
 
+	if reveal {
+		username, _ := c.Get("username")
+		usernameStr, _ := username.(string)
+		database.LogUserAction(
+			usernameStr,
+			database.UserActionRevealEnv,
+			"project:"+projectName,
+			"reveal env secrets of project: "+projectName,
+			middleware.GetClientIP(c),
+			c.GetHeader("User-Agent"),
+			true,
+			nil,
+		)
+	} else {
+		envContent = env.MaskEnvContent(envContent)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"content": envContent,
-		"exists":  exists,
-		"path":    filepath.Join(projectPath, ".env"),
+		"content":   envContent,
+		"exists":    exists,
+		"path":      filepath.Join(project.Path, ".env"),
+		"masked":    !reveal,
+		"encrypted": project.EncryptEnv,
 	})
 }
 
@@ -46,44 +89,74 @@ func HandleSaveEnv(c *gin.Context) {
 
 	var req struct {
 		Content string `json:"content" binding:"required"`
+		Encrypt *bool  `json:"encrypt,omitempty"` // switch encrypted storage on/off, nil keeps current setting
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, nil)
 		return
 	}
 
-	// find project path
-	var projectPath string
-	for _, proj := range types.GoHookVersionData.Projects {
-		if proj.Name == projectName && proj.Enabled {
-			projectPath = proj.Path
-			break
-		}
+	project := findEnabledProject(projectName)
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
 	}
 
-	if projectPath == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+	// keep real values for secrets that were sent back masked
+	previous, _, err := loadProjectEnv(project)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadEnvFailed, err)
 		return
 	}
+	content := env.MergeMaskedContent(req.Content, previous)
 
 	// validate environment variable file format
-	if errors := env.ValidateEnvContent(req.Content); len(errors) > 0 {
+	if errors := env.ValidateEnvContent(content); len(errors) > 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Environment variable file format validation failed",
+			"error":   i18n.Message(c, i18n.CodeInvalidEnvContent),
+			"code":    i18n.CodeInvalidEnvContent,
 			"details": errors,
 		})
 		return
 	}
 
+	encrypt := project.EncryptEnv
+	if req.Encrypt != nil {
+		encrypt = *req.Encrypt
+	}
+
+	if encrypt {
+		if err := env.SaveEncryptedEnv(project.Name, content); err != nil {
+			i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveEnvFailed, err)
+			return
+		}
+	}
+
 	// save environment variable file
-	if err := env.SaveEnvFile(projectPath, req.Content); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := env.SaveEnvFile(project.Path, content); err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveEnvFailed, err)
 		return
 	}
 
+	if encrypt != project.EncryptEnv {
+		if !encrypt {
+			if err := env.DeleteEncryptedEnv(project.Name); err != nil {
+				i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDeleteEnvFailed, err)
+				return
+			}
+		}
+		project.EncryptEnv = encrypt
+		if err := config.SaveVersionConfig(); err != nil {
+			i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Environment variable file saved successfully",
-		"path":    filepath.Join(projectPath, ".env"),
+		"message":   i18n.Message(c, i18n.CodeEnvSaved),
+		"code":      i18n.CodeEnvSaved,
+		"path":      filepath.Join(project.Path, ".env"),
+		"encrypted": encrypt,
 	})
 }
 
@@ -91,26 +164,28 @@ func HandleSaveEnv(c *gin.Context) {
 func HandleDeleteEnv(c *gin.Context) {
 	projectName := c.Param("name")
 
-	// find project path
-	var projectPath string
-	for _, proj := range types.GoHookVersionData.Projects {
-		if proj.Name == projectName && proj.Enabled {
-			projectPath = proj.Path
-			break
-		}
+	project := findEnabledProject(projectName)
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
 	}
 
-	if projectPath == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
-		return
+	if project.EncryptEnv {
+		if err := env.DeleteEncryptedEnv(project.Name); err != nil {
+			i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDeleteEnvFailed, err)
+			return
+		}
+		// the materialized file may already be gone (e.g. cleaned by a force deploy)
+		if _, exists, _ := env.GetEnvFile(project.Path); !exists {
+			c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeEnvDeleted), "code": i18n.CodeEnvDeleted})
+			return
+		}
 	}
 
-	if err := env.DeleteEnvFile(projectPath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := env.DeleteEnvFile(project.Path); err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDeleteEnvFailed, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Environment variable file deleted successfully",
-	})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeEnvDeleted), "code": i18n.CodeEnvDeleted})
 }
@@ -0,0 +1,113 @@
+package version
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/httpcache"
+	"github.com/mycoool/gohook/internal/locale"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// projectsNotModified answer the project list with 304 when version.yaml, the working copies of
+// the projects and their GitHook deliveries did not change since the ETag of the request
+func projectsNotModified(c *gin.Context) bool {
+	if types.GoHookVersionData == nil {
+		return false
+	}
+	// projects of a changed version.yaml are not loaded yet, its checksum changes the tag anyway
+	tag := httpcache.ForRequest(c).File("version.yaml").
+		Add(database.NewLogService().LatestHookLogID(database.HookTypeGitHook))
+	for i := range types.GoHookVersionData.Projects {
+		if proj := &types.GoHookVersionData.Projects[i]; proj.Enabled && namespace.Allowed(c, proj.Namespace) {
+			// the grace period of a rotated secret ends without a change of the file
+			tag.Add(proj.HookRotation.Active(time.Now()))
+			addWorkingCopyState(tag, proj)
+		}
+	}
+	return httpcache.NotModified(c, tag.String())
+}
+
+// gitRefsETag ETag of the branches and tags of the repository at path
+func gitRefsETag(c *gin.Context, path string) string {
+	tag := httpcache.ForRequest(c)
+	addGitRefs(tag, path)
+	return tag.String()
+}
+
+// addWorkingCopyState mix the state of the working copy of project into tag: the HEAD and
+// refs of a git repository, the metadata the other backends read their status from
+func addWorkingCopyState(tag *httpcache.Tag, project *types.ProjectConfig) {
+	tag.Add(project.Name, project.Path)
+	switch project.VCS {
+	case "", VCSGit:
+		addGitRefs(tag, project.Path)
+	case VCSMercurial:
+		tag.Stat(filepath.Join(project.Path, ".hg", "dirstate"))
+		tag.Stat(filepath.Join(project.Path, ".hg", "store", "00changelog.i"))
+	case VCSSubversion:
+		tag.Stat(filepath.Join(project.Path, ".svn", "wc.db"))
+	case VCSArtifact:
+		tag.File(filepath.Join(project.Path, artifactStateFile))
+	}
+}
+
+// addGitRefs mix HEAD, packed-refs and the loose refs of the repository at path into tag,
+// they change whenever a checkout, commit, fetch or tag changes the branches and tags
+func addGitRefs(tag *httpcache.Tag, path string) {
+	gitDir := filepath.Join(path, ".git")
+	if data, err := os.ReadFile(gitDir); err == nil {
+		// worktrees and submodules point to their git directory
+		if dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: "); ok {
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(path, dir)
+			}
+			gitDir = dir
+		}
+	}
+	tag.File(filepath.Join(gitDir, "HEAD"))
+	tag.File(filepath.Join(gitDir, "packed-refs"))
+	filepath.WalkDir(filepath.Join(gitDir, "refs"), func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			tag.File(p)
+		}
+		return nil
+	})
+}
+
+// ProjectRevision revision of the config of project, the revision of the project list
+func ProjectRevision(project *types.ProjectConfig) string {
+	return httpcache.Revision(project)
+}
+
+// ProjectIfMatch conditional updates of the project of the :name parameter
+func ProjectIfMatch() gin.HandlerFunc {
+	find := func(c *gin.Context) *types.ProjectConfig {
+		if types.GoHookVersionData == nil {
+			return nil
+		}
+		for i := range types.GoHookVersionData.Projects {
+			if proj := &types.GoHookVersionData.Projects[i]; proj.Name == c.Param("name") {
+				return proj
+			}
+		}
+		return nil
+	}
+	return httpcache.IfMatch(httpcache.Resource{
+		Revision: func(c *gin.Context) (string, bool) {
+			if proj := find(c); proj != nil {
+				return ProjectRevision(proj), true
+			}
+			return "", false
+		},
+		State: func(c *gin.Context) interface{} {
+			return projectResponse(find(c), locale.FromContext(c), nil)
+		},
+	})
+}
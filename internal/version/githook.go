@@ -2,11 +2,13 @@ package version
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -20,9 +22,15 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/mycoool/gohook/internal/config"
 	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/env"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/maintenance"
 	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/quota"
 	"github.com/mycoool/gohook/internal/stream"
+	"github.com/mycoool/gohook/internal/syncnode"
 	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/webhook"
 )
 
 type GitHookResult struct {
@@ -35,7 +43,7 @@ type GitHookResult struct {
 }
 
 // GitHook handle GitHook webhook request
-func tryGitHook(project *types.ProjectConfig, payload map[string]interface{}) (GitHookResult, error) {
+func tryGitHook(project *types.ProjectConfig, payload map[string]interface{}, deployed map[string]bool) (result GitHookResult, err error) {
 	log.Printf("handle GitHook: project=%s, mode=%s, branch=%s", project.Name, project.Hookmode, project.Hookbranch)
 
 	// parse webhook payload, extract branch or tag information
@@ -158,6 +166,34 @@ func tryGitHook(project *types.ProjectConfig, payload map[string]interface{}) (G
 		}
 	}
 
+	// a monorepo project only deploys pushes changing files under its hookpaths
+	if refType == "branch" && !pushTouchesHookPaths(project, payload) {
+		log.Printf("webhook push to %s does not change files under %v, skip but return success", targetRef, project.Hookpaths)
+
+		// 记录跳过的项目活动日志
+		database.LogProjectAction(
+			project.Name,                       // projectName
+			database.ProjectActionBranchSwitch, // action
+			"",                                 // oldValue
+			fmt.Sprintf("分支:%s", targetRef),    // newValue - 推送的分支
+			"GitHook",                          // username
+			true,                               // success
+			"",                                 // error - 无错误
+			"",                                 // commitHash
+			fmt.Sprintf("GitHook路径过滤：推送未修改 %s 下的文件，无需部署", strings.Join(project.Hookpaths, ", ")), // description
+			"", // ipAddress
+		)
+
+		return GitHookResult{
+			Action:  "skip-paths",
+			Target:  targetRef,
+			Success: true,
+			Error:   "",
+			Skipped: true,
+			Message: fmt.Sprintf("推送未修改 %s 下的文件，无需部署", strings.Join(project.Hookpaths, ", ")),
+		}, nil
+	}
+
 	// check if it is a deletion operation (after field is all zeros)
 	if afterCommit == "0000000000000000000000000000000000000000" {
 		switch refType {
@@ -214,8 +250,48 @@ func tryGitHook(project *types.ProjectConfig, payload map[string]interface{}) (G
 		}
 	}
 
-	// execute Git operation
-	if err := executeGitHook(project, refType, targetRef); err != nil {
+	// a pinned project keeps serving the revision it was pinned at
+	if err := checkPin(project); err != nil {
+		log.Printf("GitHook refused: %v", err)
+		return GitHookResult{
+			Action:  "switch-" + refType,
+			Target:  targetRef,
+			Success: false,
+			Error:   err.Error(),
+			Skipped: false,
+			Message: "",
+		}, err
+	}
+
+	// a force deploy would discard local changes on a protected branch or tag
+	if project.ForceSync {
+		if err := checkProtection(project, refType, targetRef, protectForceSwitch); err != nil {
+			recordPolicyViolation(project.Name, err, "GitHook", "")
+			return GitHookResult{
+				Action:  "switch-" + refType,
+				Target:  targetRef,
+				Success: false,
+				Error:   err.Error(),
+				Skipped: false,
+				Message: "",
+			}, err
+		}
+	}
+
+	// the deploy shows on GitHub or GitLab next to the commit
+	deployment := startProviderDeployment(project, refType, targetRef, payload)
+	defer func() { deployment.finish(err) }()
+
+	// what the project serves now, a failed health check rolls back to it
+	before := captureDeployPoint(project)
+
+	// execute Git operation, projects sharing a checkout are switched once per delivery
+	checkout := filepath.Clean(project.Path)
+	if kubernetesImageOnly(project) {
+		log.Printf("project %s deploys a Kubernetes image, skip git operation", project.Name)
+	} else if deployed[checkout] {
+		log.Printf("checkout %s already switched to %s by this delivery, skip git operation", checkout, targetRef)
+	} else if err := executeGitHook(project, refType, targetRef); err != nil {
 		// 记录GitHook触发的失败项目活动日志
 		var actionType string
 		var newValue string
@@ -254,13 +330,97 @@ func tryGitHook(project *types.ProjectConfig, payload map[string]interface{}) (G
 			Message: "",
 		}, fmt.Errorf("execute Git operation failed: %v", err)
 	}
+	if deployed != nil && !kubernetesImageOnly(project) {
+		deployed[checkout] = true
+	}
+
+	if project.Releases != nil {
+		if err := deployRelease(project, targetRef, "GitHook", ""); err != nil {
+			return GitHookResult{
+				Action:  "switch-" + refType,
+				Target:  targetRef,
+				Success: false,
+				Error:   err.Error(),
+				Skipped: false,
+				Message: "",
+			}, err
+		}
+	}
+
+	if project.Kubernetes != nil {
+		if err := deployKubernetes(project, targetRef, afterCommit); err != nil {
+			database.LogProjectAction(
+				project.Name,                     // projectName
+				database.ProjectActionKubernetes, // action
+				currentPosition,                  // oldValue
+				targetRef,                        // newValue
+				"GitHook",                        // username
+				false,                            // success
+				err.Error(),                      // error
+				"",                               // commitHash
+				fmt.Sprintf("GitHook Kubernetes部署失败：部署 %s 时出错: %s", targetRef, err.Error()), // description
+				"", // ipAddress
+			)
+			return GitHookResult{
+				Action:  "switch-" + refType,
+				Target:  targetRef,
+				Success: false,
+				Error:   "kubernetes deploy failed: " + err.Error(),
+				Skipped: false,
+				Message: "",
+			}, fmt.Errorf("kubernetes deploy failed: %v", err)
+		}
+	}
+
+	if project.Compose != nil {
+		if err := deployCompose(project, targetRef, "GitHook", ""); err != nil {
+			return GitHookResult{
+				Action:  "switch-" + refType,
+				Target:  targetRef,
+				Success: false,
+				Error:   "compose deploy failed: " + err.Error(),
+				Skipped: false,
+				Message: "",
+			}, fmt.Errorf("compose deploy failed: %v", err)
+		}
+	}
+
+	if project.Migrations != nil {
+		if err := runMigrations(project, targetRef, afterCommit, "GitHook", ""); err != nil {
+			return GitHookResult{
+				Action:  "switch-" + refType,
+				Target:  targetRef,
+				Success: false,
+				Error:   err.Error(),
+				Skipped: false,
+				Message: "",
+			}, err
+		}
+	}
+
+	runPostDeploy(project)
+
+	if project.HealthCheck != nil {
+		if err := verifyDeploy(project, before, targetRef, "GitHook", ""); err != nil {
+			return GitHookResult{
+				Action:  "switch-" + refType,
+				Target:  targetRef,
+				Success: false,
+				Error:   err.Error(),
+				Skipped: false,
+				Message: "",
+			}, err
+		}
+	}
 
 	// 获取执行后的提交哈希
-	if output, err := execGitCommandOutput(project.Path, "rev-parse", "HEAD"); err == nil {
+	if kubernetesImageOnly(project) {
+		commitHash = afterCommit
+	} else if output, err := execGitCommandOutput(project.Path, "rev-parse", "HEAD"); err == nil {
 		commitHash = strings.TrimSpace(string(output))
-		if len(commitHash) > 7 {
-			commitHash = commitHash[:7]
-		}
+	}
+	if len(commitHash) > 7 {
+		commitHash = commitHash[:7]
 	}
 
 	// 记录GitHook触发的成功项目活动日志
@@ -320,11 +480,31 @@ func tryGitHook(project *types.ProjectConfig, payload map[string]interface{}) (G
 func executeGitHook(project *types.ProjectConfig, refType, targetRef string) error {
 	projectPath := project.Path
 
+	// Subversion and Mercurial working copies are updated by their backend
+	if !isGitProject(project) {
+		if err := preflightDeploy(project); err != nil {
+			return err
+		}
+		switch refType {
+		case "branch":
+			return switchProjectBranch(project, targetRef, project.ForceSync)
+		case "tag":
+			return switchProjectRevision(project, targetRef, project.ForceSync)
+		default:
+			return fmt.Errorf("unsupported reference type: %s", refType)
+		}
+	}
+
 	// check if it is a Git repository
 	if _, err := os.Stat(filepath.Join(projectPath, ".git")); os.IsNotExist(err) {
 		return fmt.Errorf("project path is not a Git repository: %s", projectPath)
 	}
 
+	// stop before touching the work tree when the deploy could not finish
+	if err := preflightDeploy(project); err != nil {
+		return err
+	}
+
 	// fetch latest remote information
 	if output, err := execGitCommand(projectPath, "fetch", "--all"); err != nil {
 		log.Printf("warning: failed to fetch remote information: %s", string(output))
@@ -345,6 +525,22 @@ func executeGitHook(project *types.ProjectConfig, refType, targetRef string) err
 	}
 }
 
+// runPostDeploy run post deploy steps after a successful GitHook deploy
+func runPostDeploy(project *types.ProjectConfig) {
+	// a force deploy may clean untracked files, write encrypted env back to disk
+	if project.EncryptEnv {
+		if err := env.MaterializeEnv(project.Name, project.Path); err != nil {
+			log.Printf("materialize env failed: project=%s, error=%v", project.Name, err)
+		}
+	}
+
+	restartServiceAfterDeploy(project)
+
+	if err := syncnode.EnqueueDeploySync(context.Background(), project.Name); err != nil {
+		log.Printf("enqueue deploy sync failed: project=%s, error=%v", project.Name, err)
+	}
+}
+
 // verify GitHub HMAC-SHA256 signature
 func verifyGitHubSignature(payload []byte, secret, signature string) error {
 	if !strings.HasPrefix(signature, "sha256=") {
@@ -502,9 +698,12 @@ func HandleSaveGitHook(c *gin.Context) {
 		Hookbranch string `json:"hookbranch"`
 		Hooksecret string `json:"hooksecret"`
 		ForceSync  bool   `json:"forcesync"` // 是否强制同步
+		// optional, left unchanged when omitted
+		Hookpaths *[]string `json:"hookpaths"`
+		Monorepo  *string   `json:"monorepo"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, nil)
 		return
 	}
 
@@ -515,27 +714,37 @@ func HandleSaveGitHook(c *gin.Context) {
 			types.GoHookVersionData.Projects[i].Enhook = req.Enhook
 			types.GoHookVersionData.Projects[i].Hookmode = req.Hookmode
 			types.GoHookVersionData.Projects[i].Hookbranch = req.Hookbranch
+			if req.Hooksecret != proj.Hooksecret {
+				// a secret set by hand ends the grace period of a rotation
+				types.GoHookVersionData.Projects[i].HookRotation = nil
+			}
 			types.GoHookVersionData.Projects[i].Hooksecret = req.Hooksecret
 			types.GoHookVersionData.Projects[i].ForceSync = req.ForceSync
+			if req.Hookpaths != nil {
+				types.GoHookVersionData.Projects[i].Hookpaths = *req.Hookpaths
+			}
+			if req.Monorepo != nil {
+				types.GoHookVersionData.Projects[i].Monorepo = strings.TrimSpace(*req.Monorepo)
+			}
 			projectFound = true
 			break
 		}
 	}
 
 	if !projectFound {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
 		return
 	}
 
 	// save configuration file
 	if err := config.SaveVersionConfig(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Save configuration failed: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"code":    0,
-		"message": "GitHook configuration saved successfully",
+		"message": i18n.Message(c, i18n.CodeGitHookConfigSaved),
+		"code":    i18n.CodeGitHookConfigSaved,
 	})
 }
 
@@ -543,17 +752,14 @@ func HandleSaveGitHook(c *gin.Context) {
 func HandleGitHook(c *gin.Context) {
 	projectName := c.Param("name")
 
-	// find project configuration
-	var project *types.ProjectConfig
-	for _, proj := range types.GoHookVersionData.Projects {
-		if proj.Name == projectName && proj.Enabled && proj.Enhook {
-			project = &proj
-			break
-		}
+	// find project configuration, renamed projects still answer to their previous names
+	project, alias := resolveProject(projectName)
+	if project != nil && (!project.Enabled || !project.Enhook) {
+		project = nil
 	}
 
 	if project == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found or GitHook not enabled"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeGitHookNotEnabled, nil)
 		return
 	}
 
@@ -561,9 +767,21 @@ func HandleGitHook(c *gin.Context) {
 	var payloadBody []byte
 	if c.Request.Body != nil {
 		var err error
-		payloadBody, err = io.ReadAll(c.Request.Body)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Read payload failed"})
+		// compressed payloads are verified and parsed decompressed
+		if encoding := c.Request.Header.Get("Content-Encoding"); encoding != "" {
+			compressed := http.MaxBytesReader(c.Writer, c.Request.Body, webhook.MaxDecodedBody)
+			decoded, err := webhook.NewDecodingReader(encoding, compressed, webhook.MaxDecodedBody)
+			if err == nil {
+				payloadBody, err = io.ReadAll(decoded)
+			}
+			if err != nil {
+				i18n.JSONError(c, webhook.DecodeErrorStatus(err), i18n.CodeDecodePayloadFailed, err)
+				return
+			}
+			c.Request.Header.Del("Content-Encoding")
+			c.Request.ContentLength = int64(len(payloadBody))
+		} else if payloadBody, err = io.ReadAll(c.Request.Body); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeReadPayloadFailed, err)
 			return
 		}
 		// reset body for subsequent use
@@ -572,9 +790,9 @@ func HandleGitHook(c *gin.Context) {
 
 	// verify webhook password (if set)
 	if project.Hooksecret != "" {
-		if err := verifyWebhookSignature(c, payloadBody, project.Hooksecret); err != nil {
+		if err := verifyGitHookSecret(c, payloadBody, project); err != nil {
 			log.Printf("GitHook password verification failed: project=%s, error=%v", project.Name, err)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Password verification failed: " + err.Error()})
+			i18n.JSONError(c, http.StatusUnauthorized, i18n.CodeGitHookSecretInvalid, err)
 			return
 		}
 	}
@@ -582,12 +800,86 @@ func HandleGitHook(c *gin.Context) {
 	// parse webhook payload (support GitHub, GitLab, Gitee, etc.)
 	var payload map[string]interface{}
 	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook payload"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidWebhookPayload, err)
 		return
 	}
 
+	d := gitHookDelivery{
+		Alias:      alias,
+		Method:     c.Request.Method,
+		RemoteAddr: middleware.GetClientIP(c),
+		UserAgent:  c.Request.UserAgent(),
+		Headers:    c.Request.Header,
+		Body:       payloadBody,
+		Payload:    payload,
+	}
+
+	// one delivery of a monorepo runs for every project of the group
+	if project.Monorepo != "" {
+		handleMonorepoGitHook(c, monorepoProjects(project), d)
+		return
+	}
+
+	// maintenance mode or pause window: queue the delivery or reject it
+	if decision := maintenance.Check(maintenance.KindGitHook, project.Name); decision.Paused {
+		if decision.RejectStatus != 0 {
+			i18n.JSONErrorf(c, decision.RejectStatus, i18n.CodeGitHookPaused, decision.Reason)
+			return
+		}
+		if err := queueGitHook(project, d, decision.Reason); err != nil {
+			i18n.JSONError(c, http.StatusServiceUnavailable, i18n.CodeGitHookQueueFailed, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"message": i18n.Message(c, i18n.CodeGitHookQueued, decision.Reason), "code": i18n.CodeGitHookQueued})
+		return
+	}
+
+	result, err := processGitHook(project, d)
+	if err != nil {
+		c.String(deployErrorStatus(err), "GitHook processing failed: "+result.Action+" "+result.Target+" "+strconv.FormatBool(result.Success)+" "+err.Error())
+		return
+	}
+
+	var responseMessage string
+	if result.Skipped {
+		responseMessage = fmt.Sprintf("GitHook processing successfully (skipped): %s %s - %s", result.Action, result.Target, result.Message)
+	} else {
+		responseMessage = fmt.Sprintf("GitHook processing successfully: %s %s", result.Action, result.Target)
+	}
+	c.String(http.StatusOK, responseMessage)
+}
+
+// gitHookDelivery request data a GitHook is processed and logged with
+type gitHookDelivery struct {
+	Alias      string // previous project name the delivery addressed
+	Method     string
+	RemoteAddr string
+	UserAgent  string
+	Headers    map[string][]string
+	Body       []byte
+	Payload    map[string]interface{}
+	Deployed   map[string]bool // checkout paths already switched by this delivery, see Monorepo
+}
+
+// queueGitHook keep a delivery for a paused project, it is replayed once the pause ends
+func queueGitHook(project *types.ProjectConfig, d gitHookDelivery, reason string) error {
+	return maintenance.Enqueue(&database.QueuedDelivery{
+		Kind:       maintenance.KindGitHook,
+		Target:     project.Name,
+		RequestID:  fmt.Sprintf("githook-%s-%d", project.Name, time.Now().UnixNano()),
+		Method:     d.Method,
+		RemoteAddr: d.RemoteAddr,
+		Headers:    maintenance.EncodeJSON(d.Headers),
+		Payload:    maintenance.EncodeJSON(d.Payload),
+		Body:       string(d.Body),
+		Reason:     reason,
+	})
+}
+
+// processGitHook run the GitHook for a verified delivery, record the execution log and push the result
+func processGitHook(project *types.ProjectConfig, d gitHookDelivery) (GitHookResult, error) {
 	// handle GitHook logic
-	result, err := tryGitHook(project, payload)
+	result, err := tryGitHook(project, d.Payload, d.Deployed)
 
 	// 记录GitHook执行日志到数据库
 	var outputMessage string
@@ -598,65 +890,87 @@ func HandleGitHook(c *gin.Context) {
 	}
 
 	database.LogHookExecution(
-		project.Name,              // hookID (使用项目名作为ID)
-		"GitHook-"+project.Name,   // hookName
-		"githook",                 // hookType
-		c.Request.Method,          // method
-		middleware.GetClientIP(c), // remoteAddr
-		c.Request.Header,          // headers
-		string(payloadBody),       // body
-		result.Success,            // success
-		outputMessage,             // output
-		result.Error,              // error
-		0,                         // duration (无精确执行时间)
-		c.Request.UserAgent(),     // userAgent
+		project.Name,            // hookID (使用项目名作为ID)
+		d.Alias,                 // alias
+		"GitHook-"+project.Name, // hookName
+		"githook",               // hookType
+		d.Method,                // method
+		d.RemoteAddr,            // remoteAddr
+		d.Headers,               // headers
+		string(d.Body),          // body
+		result.Success,          // success
+		outputMessage,           // output
+		result.Error,            // error
+		0,                       // duration (无精确执行时间)
+		d.UserAgent,             // userAgent
 		map[string][]string{ // queryParams
 			"project": {project.Name},
 			"mode":    {project.Hookmode},
 		},
 	)
 
+	message := stream.GitHookTriggeredMessage{
+		Action:      result.Action,
+		ProjectName: project.Name,
+		Target:      result.Target,
+		Success:     result.Success,
+		Skipped:     result.Skipped,
+		Message:     result.Message,
+	}
 	if err != nil {
-		// push failed message
-		wsMessage := stream.WsMessage{
-			Type:      "githook_triggered",
-			Timestamp: time.Now(),
-			Data: stream.GitHookTriggeredMessage{
-				Action:      result.Action,
-				ProjectName: projectName,
-				Target:      result.Target,
-				Success:     result.Success,
-				Error:       "GitHook processing failed: " + err.Error(),
-				Skipped:     result.Skipped,
-				Message:     result.Message,
-			},
-		}
-		stream.Global.Broadcast(wsMessage)
+		message.Error = "GitHook processing failed: " + err.Error()
 		log.Printf("GitHook processing failed: project=%s, error=%v", project.Name, err)
-		c.String(http.StatusInternalServerError, "GitHook processing failed: "+result.Action+" "+result.Target+" "+strconv.FormatBool(result.Success)+" "+err.Error())
-		return
 	}
-
-	// push success message
-	wsMessage := stream.WsMessage{
+	stream.Global.Broadcast(stream.WsMessage{
 		Type:      "githook_triggered",
 		Timestamp: time.Now(),
-		Data: stream.GitHookTriggeredMessage{
-			Action:      result.Action,
-			ProjectName: projectName,
-			Target:      result.Target,
-			Success:     result.Success,
-			Skipped:     result.Skipped,
-			Message:     result.Message,
-		},
+		Data:      message,
+	})
+	return result, err
+}
+
+// replayQueuedGitHook process a GitHook delivery queued during maintenance
+func replayQueuedGitHook(q *database.QueuedDelivery) error {
+	project := findEnabledProject(q.Target)
+	if project == nil || !project.Enhook {
+		return fmt.Errorf("project %s not found or GitHook not enabled", q.Target)
 	}
-	stream.Global.Broadcast(wsMessage)
 
-	var responseMessage string
-	if result.Skipped {
-		responseMessage = fmt.Sprintf("GitHook processing successfully (skipped): %s %s - %s", result.Action, result.Target, result.Message)
-	} else {
-		responseMessage = fmt.Sprintf("GitHook processing successfully: %s %s", result.Action, result.Target)
+	d := gitHookDelivery{
+		Method:     q.Method,
+		RemoteAddr: q.RemoteAddr,
+		Body:       []byte(q.Body),
 	}
-	c.String(http.StatusOK, responseMessage)
+	if q.Headers != "" {
+		if err := json.Unmarshal([]byte(q.Headers), &d.Headers); err != nil {
+			return fmt.Errorf("decode headers: %w", err)
+		}
+	}
+	if err := json.Unmarshal([]byte(q.Payload), &d.Payload); err != nil {
+		return fmt.Errorf("decode payload: %w", err)
+	}
+	if len(d.Headers["User-Agent"]) > 0 {
+		d.UserAgent = d.Headers["User-Agent"][0]
+	}
+
+	_, err := processGitHook(project, d)
+	return err
+}
+
+func init() {
+	maintenance.Register(maintenance.KindGitHook, maintenance.Handler{
+		Windows: func(target string) []types.PauseWindow {
+			if project := findEnabledProject(target); project != nil {
+				return project.PauseWindows
+			}
+			return nil
+		},
+		Replay: replayQueuedGitHook,
+		Hold: func(target string) maintenance.Decision {
+			if project := findEnabledProject(target); project != nil {
+				return quota.Hold(quota.Subject{Namespace: project.Namespace})
+			}
+			return maintenance.Decision{}
+		},
+	})
 }
@@ -0,0 +1,226 @@
+package version
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// git maintenance triggers
+const (
+	MaintenanceTriggerSchedule = "schedule"
+	MaintenanceTriggerManual   = "manual"
+)
+
+// gitMaintenanceCheckInterval how often the scheduler looks for projects that are due
+const gitMaintenanceCheckInterval = 10 * time.Minute
+
+// ErrMaintenanceRunning a maintenance run of the project has not finished yet
+var ErrMaintenanceRunning = errors.New("git maintenance is already running for this project")
+
+// projects with a maintenance run in progress
+var (
+	maintenanceMu      sync.Mutex
+	maintenanceRunning = map[string]bool{}
+)
+
+// repoSize return the bytes used by the object database, loose objects, packs and garbage
+func repoSize(projectPath string) (int64, error) {
+	output, err := execGitCommandOutput(projectPath, "count-objects", "-v")
+	if err != nil {
+		return 0, fmt.Errorf("git count-objects failed: %s", strings.TrimSpace(string(output)))
+	}
+	var kib int64
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ": ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "size", "size-pack", "size-garbage":
+			n, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			kib += n
+		}
+	}
+	return kib << 10, nil
+}
+
+// runGitMaintenance prune stale remote-tracking branches and unreachable objects, then repack
+// with git gc. The run is recorded with the repository size before and after.
+func runGitMaintenance(project *types.ProjectConfig, trigger, username string) (*database.GitMaintenanceRun, error) {
+	maintenanceMu.Lock()
+	if maintenanceRunning[project.Name] {
+		maintenanceMu.Unlock()
+		return nil, ErrMaintenanceRunning
+	}
+	maintenanceRunning[project.Name] = true
+	maintenanceMu.Unlock()
+	defer func() {
+		maintenanceMu.Lock()
+		delete(maintenanceRunning, project.Name)
+		maintenanceMu.Unlock()
+	}()
+
+	start := time.Now()
+	run := &database.GitMaintenanceRun{ProjectName: project.Name, Trigger: trigger, CreatedBy: username}
+	var out strings.Builder
+	err := func() error {
+		size, err := repoSize(project.Path)
+		if err != nil {
+			return err
+		}
+		run.SizeBefore = size
+
+		steps := [][]string{}
+		if remotes, err := execGitCommandOutput(project.Path, "remote"); err == nil {
+			for _, remote := range strings.Fields(string(remotes)) {
+				steps = append(steps, []string{"remote", "prune", remote})
+			}
+		}
+		steps = append(steps, []string{"prune"})
+		gc := []string{"gc", "--quiet"}
+		if project.GitMaintenance != nil && project.GitMaintenance.Aggressive {
+			gc = append(gc, "--aggressive")
+		}
+		steps = append(steps, gc)
+
+		for _, args := range steps {
+			output, err := execGitCommand(project.Path, args...)
+			fmt.Fprintf(&out, "$ git %s\n%s", strings.Join(args, " "), output)
+			if err != nil {
+				return fmt.Errorf("git %s failed: %s", strings.Join(args, " "), strings.TrimSpace(string(output)))
+			}
+		}
+
+		if run.SizeAfter, err = repoSize(project.Path); err != nil {
+			return err
+		}
+		return nil
+	}()
+
+	run.DurationMs = time.Since(start).Milliseconds()
+	run.Output = out.String()
+	run.Success = err == nil
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if db := database.GetDB(); db != nil {
+		if saveErr := db.Create(run).Error; saveErr != nil {
+			log.Printf("save git maintenance run failed: project=%s, error=%v", project.Name, saveErr)
+		}
+	}
+	if err != nil {
+		log.Printf("git maintenance of %s failed: %v", project.Name, err)
+	} else {
+		log.Printf("git maintenance of %s done in %dms: %d KB -> %d KB", project.Name, run.DurationMs, run.SizeBefore>>10, run.SizeAfter>>10)
+	}
+	return run, err
+}
+
+// gitMaintenanceDue report whether the last run of the project, scheduled or manual, is older
+// than its interval
+func gitMaintenanceDue(project *types.ProjectConfig, now time.Time) bool {
+	db := database.GetDB()
+	if db == nil {
+		return false
+	}
+	var last database.GitMaintenanceRun
+	err := db.Where("project_name = ?", project.Name).Order("created_at DESC").Limit(1).Find(&last).Error
+	if err != nil {
+		return false
+	}
+	return last.ID == 0 || now.Sub(last.CreatedAt) >= project.GitMaintenance.Every()
+}
+
+// ScheduleGitMaintenance run git maintenance of the projects that enable it once their interval
+// has passed, it stops once ctx is done. The last run is read from the database so a new
+// leader continues the schedule.
+func ScheduleGitMaintenance(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(gitMaintenanceCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if types.GoHookVersionData == nil {
+				continue
+			}
+			var due []types.ProjectConfig
+			for _, project := range types.GoHookVersionData.Projects {
+				if project.Enabled && project.GitMaintenance != nil && project.GitMaintenance.Enabled &&
+					gitMaintenanceDue(&project, time.Now()) {
+					due = append(due, project)
+				}
+			}
+			for i := range due {
+				if ctx.Err() != nil {
+					return
+				}
+				_, _ = runGitMaintenance(&due[i], MaintenanceTriggerSchedule, "")
+			}
+		}
+	}()
+}
+
+// HandleGitMaintenance run git maintenance of a project now
+func HandleGitMaintenance(c *gin.Context) {
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	run, err := runGitMaintenance(project, MaintenanceTriggerManual, currentUsername(c))
+	if errors.Is(err, ErrMaintenanceRunning) {
+		i18n.JSONError(c, http.StatusConflict, i18n.CodeGitMaintenanceRunning, nil)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Message(c, i18n.CodeGitMaintenanceFailed) + ": " + err.Error(), "code": i18n.CodeGitMaintenanceFailed, "run": run})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// HandleListGitMaintenance list the maintenance runs of a project, newest first, with the
+// current repository size
+func HandleListGitMaintenance(c *gin.Context) {
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	runs := []database.GitMaintenanceRun{}
+	if db := database.GetDB(); db != nil {
+		if err := db.Where("project_name = ?", project.Name).Order("created_at DESC").Limit(limit).Find(&runs).Error; err != nil {
+			i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListMaintenanceRunsFailed, err)
+			return
+		}
+	}
+	resp := gin.H{"runs": runs, "interval": project.GitMaintenance.Every().String(),
+		"enabled": project.GitMaintenance != nil && project.GitMaintenance.Enabled}
+	if size, err := repoSize(project.Path); err == nil {
+		resp["size"] = size
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,268 @@
+package version
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/kube"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// maxHealthCheckOutput bytes of a failed check command or response body kept in its error
+const maxHealthCheckOutput = 1 << 10
+
+// deployPoint what a project served before a deploy, a failed health check rolls back to it
+type deployPoint struct {
+	release string // active release of release directory deploys
+	refType string // branch | tag | commit | revision of the working copy, empty when unknown
+	ref     string
+	kube    map[kubeTarget]int64 // revisions of the Deployments of the Kubernetes target
+	targets []kubeTarget
+}
+
+// captureDeployPoint record what the project serves before a deploy, nothing is recorded for
+// projects without a health check
+func captureDeployPoint(project *types.ProjectConfig) deployPoint {
+	var p deployPoint
+	if project.HealthCheck == nil {
+		return p
+	}
+	switch {
+	case project.Releases != nil:
+		p.release = activeRelease(project.Releases)
+	case kubernetesImageOnly(project):
+	case isGitProject(project):
+		if refType, ref, _, err := resolveDeployedRevision(project.Path); err == nil {
+			p.refType, p.ref = refType, ref
+		}
+	default:
+		if status, err := projectStatus(project); err == nil {
+			switch {
+			case status.CurrentTag != "":
+				p.refType, p.ref = "tag", status.CurrentTag
+			case project.VCS == VCSArtifact && status.CurrentBranch != "":
+				p.refType, p.ref = "branch", status.CurrentBranch
+			case status.LastCommit != "":
+				p.refType, p.ref = "revision", status.LastCommit
+			}
+		}
+	}
+	if project.Kubernetes != nil {
+		client, targets, err := kubeProjectTargets(project)
+		if err != nil {
+			log.Printf("healthcheck: project %s Kubernetes revisions unknown: %v", project.Name, err)
+			return p
+		}
+		p.targets, p.kube = targets, map[kubeTarget]int64{}
+		for _, t := range targets {
+			if d, err := client.GetDeployment(context.Background(), t.Namespace, t.Name); err == nil {
+				p.kube[t] = d.Revision()
+			} else if !kube.IsNotFound(err) {
+				log.Printf("healthcheck: project %s deployment %s revision unknown: %v", project.Name, t, err)
+			}
+		}
+	}
+	return p
+}
+
+// verifyDeploy run the health check of a project deployed to ref and roll the project back to
+// before when it does not pass. The returned error fails the deploy, which sends the failure
+// alerts of the deploy.
+func verifyDeploy(project *types.ProjectConfig, before deployPoint, ref, username, ipAddress string) error {
+	start := time.Now()
+	attempts, err := runHealthCheck(project)
+	elapsed := time.Since(start).Round(time.Second)
+
+	description := fmt.Sprintf("Health check of %s passed after %d attempt(s) in %s", ref, attempts, elapsed)
+	errMsg := ""
+	if err != nil {
+		log.Printf("healthcheck failed: project=%s, ref=%s, error=%v", project.Name, ref, err)
+		err = fmt.Errorf("health check failed after %d attempt(s): %v; %s", attempts, err,
+			rollbackDeploy(project, before, ref, username, ipAddress))
+		errMsg = err.Error()
+		description = fmt.Sprintf("Health check of %s failed in %s", ref, elapsed)
+	}
+	database.LogProjectAction(
+		project.Name,                      // projectName
+		database.ProjectActionHealthCheck, // action
+		"",                                // oldValue
+		ref,                               // newValue
+		username,                          // username
+		err == nil,                        // success
+		errMsg,                            // error
+		"",                                // commitHash
+		description,                       // description
+		ipAddress,                         // ipAddress
+	)
+	return err
+}
+
+// runHealthCheck run the health check until it passes or its attempts are used up
+func runHealthCheck(project *types.ProjectConfig) (int, error) {
+	hc := project.HealthCheck
+	delay, interval, timeout, err := hc.Timings()
+	if err != nil {
+		return 0, err
+	}
+	time.Sleep(delay)
+	for attempt := 1; ; attempt++ {
+		err := checkHealth(project, timeout)
+		if err == nil || attempt >= hc.Attempts() {
+			return attempt, err
+		}
+		log.Printf("healthcheck: project %s attempt %d failed: %v", project.Name, attempt, err)
+		time.Sleep(interval)
+	}
+}
+
+// checkHealth run one attempt of the health check
+func checkHealth(project *types.ProjectConfig, timeout time.Duration) error {
+	hc := project.HealthCheck
+	switch {
+	case hc.URL != "":
+		client := &http.Client{Timeout: timeout}
+		resp, err := client.Get(hc.URL)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return fmt.Errorf("read %s: %v", hc.URL, err)
+		}
+		if hc.Status != 0 && resp.StatusCode != hc.Status {
+			return fmt.Errorf("%s returned %s, expected %d", hc.URL, resp.Status, hc.Status)
+		}
+		if hc.Status == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+			return fmt.Errorf("%s returned %s", hc.URL, resp.Status)
+		}
+		if hc.Body != "" && !bytes.Contains(body, []byte(hc.Body)) {
+			return fmt.Errorf("%s response does not contain %q: %s", hc.URL, hc.Body, truncateOutput(body))
+		}
+		return nil
+	case hc.TCP != "":
+		conn, err := net.DialTimeout("tcp", hc.TCP, timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, hc.Command[0], hc.Command[1:]...)
+		cmd.Dir = project.Path
+		if project.Releases != nil {
+			cmd.Dir = filepath.Join(project.Releases.Dir, "current")
+		}
+		cmd.Env = append(os.Environ(), "GOHOOK_PROJECT="+project.Name, "GOHOOK_PROJECT_PATH="+project.Path)
+		output, err := cmd.CombinedOutput()
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%s timed out after %s", hc.Command[0], timeout)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v: %s", hc.Command[0], err, truncateOutput(output))
+		}
+		return nil
+	}
+}
+
+// truncateOutput trimmed output of a failed check, cut to its last maxHealthCheckOutput bytes
+func truncateOutput(output []byte) string {
+	if len(output) > maxHealthCheckOutput {
+		output = append([]byte("..."), output[len(output)-maxHealthCheckOutput:]...)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// rollbackDeploy restore the release, working copy and Kubernetes revisions of before, bring the
+// compose services and the project service back up and describe the result
+func rollbackDeploy(project *types.ProjectConfig, before deployPoint, ref, username, ipAddress string) string {
+	var results []string
+	switch {
+	case project.Releases != nil:
+		current := activeRelease(project.Releases)
+		switch {
+		case before.release == "":
+			results = append(results, "no previous release to roll back to")
+		case before.release != current:
+			err := activateRelease(project.Releases, before.release)
+			results = append(results, logRollback(project, "release:"+current, "release:"+before.release, err, username, ipAddress))
+		}
+	case kubernetesImageOnly(project):
+	case before.ref == "":
+		results = append(results, "previous revision unknown, working copy not rolled back")
+	default:
+		var err error
+		switch {
+		case before.refType == "tag" && isGitProject(project):
+			err = switchToTag(project.Path, before.ref, project.ForceSync)
+		case before.refType == "commit":
+			err = switchToCommit(project.Path, before.ref, project.ForceSync)
+		case before.refType == "branch":
+			err = switchProjectBranch(project, before.ref, project.ForceSync)
+		default:
+			err = switchProjectRevision(project, before.ref, project.ForceSync)
+		}
+		results = append(results, logRollback(project, ref, before.refType+":"+before.ref, err, username, ipAddress))
+	}
+
+	if project.Compose != nil && !kubernetesImageOnly(project) {
+		previous := before.ref
+		if previous == "" {
+			previous = before.release
+		}
+		if err := deployCompose(project, previous, username, ipAddress); err != nil {
+			results = append(results, "compose services not restored: "+err.Error())
+		} else {
+			results = append(results, "compose services restored")
+		}
+	}
+
+	if project.Kubernetes != nil && before.kube != nil {
+		client, err := kubeClient(project)
+		if err != nil {
+			results = append(results, "Kubernetes rollback failed: "+err.Error())
+		} else {
+			results = append(results, rollbackKubernetes(context.Background(), client, project, before.targets, before.kube, username, ipAddress))
+		}
+	}
+
+	runPostDeploy(project)
+	return strings.Join(results, "; ")
+}
+
+// logRollback record the rollback of a release or working copy in the project activity log
+// and describe it
+func logRollback(project *types.ProjectConfig, from, to string, err error, username, ipAddress string) string {
+	result := "rolled back to " + to
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+		result = fmt.Sprintf("rollback to %s failed: %v", to, err)
+	}
+	database.LogProjectAction(
+		project.Name,                   // projectName
+		database.ProjectActionRollback, // action
+		from,                           // oldValue
+		to,                             // newValue
+		username,                       // username
+		err == nil,                     // success
+		errMsg,                         // error
+		"",                             // commitHash
+		"Health check failed, "+result, // description
+		ipAddress,                      // ipAddress
+	)
+	log.Printf("healthcheck: project %s %s", project.Name, result)
+	return result
+}
@@ -0,0 +1,359 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/kube"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// invalidTagChars characters not allowed in an image tag
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// kubeTarget Deployment whose rollout a Kubernetes deploy checks
+type kubeTarget struct {
+	Namespace string
+	Name      string
+}
+
+func (t kubeTarget) String() string {
+	return t.Namespace + "/" + t.Name
+}
+
+// kubernetesImageOnly report whether GitHook deploys of the project only set an image and
+// leave the checkout alone
+func kubernetesImageOnly(project *types.ProjectConfig) bool {
+	return project.Kubernetes != nil && project.Kubernetes.Mode == types.KubernetesImage
+}
+
+// kubeClient client for the cluster of the Kubernetes target of a project
+func kubeClient(project *types.ProjectConfig) (*kube.Client, error) {
+	k := project.Kubernetes
+	var cfg *kube.Config
+	var err error
+	switch {
+	case k.Server != "":
+		cfg = &kube.Config{Server: k.Server, Token: k.Token, Insecure: k.InsecureSkipTLSVerify}
+		if k.CAFile != "" {
+			if cfg.CAData, err = os.ReadFile(k.CAFile); err != nil {
+				return nil, err
+			}
+		}
+	case k.Kubeconfig != "":
+		cfg, err = kube.LoadKubeconfig(k.Kubeconfig, k.Context)
+	default:
+		if path := kube.DefaultKubeconfig(); path != "" {
+			cfg, err = kube.LoadKubeconfig(path, k.Context)
+		} else {
+			cfg, err = kube.InCluster()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return kube.NewClient(cfg, k.Namespace)
+}
+
+// imageTag tag of the image deployed for ref, characters a tag cannot hold become "-"
+func imageTag(template, ref, commit string) string {
+	if template == "" {
+		template = "{ref}"
+	}
+	short := commit
+	if len(short) > 7 {
+		short = short[:7]
+	}
+	tag := strings.NewReplacer("{ref}", ref, "{commit}", commit, "{short_commit}", short).Replace(template)
+	tag = invalidTagChars.ReplaceAllString(tag, "-")
+	if len(tag) > 128 {
+		tag = tag[:128]
+	}
+	return tag
+}
+
+// kubeTargets Deployments of a project whose rollout is checked: the configured Deployment
+// and, in manifests mode, the Deployments of the manifests
+func kubeTargets(client *kube.Client, project *types.ProjectConfig, objects []kube.Object) []kubeTarget {
+	var targets []kubeTarget
+	if project.Kubernetes.Deployment != "" {
+		targets = append(targets, kubeTarget{Namespace: client.Namespace, Name: project.Kubernetes.Deployment})
+	}
+	for _, obj := range objects {
+		if obj.Kind != "Deployment" {
+			continue
+		}
+		t := kubeTarget{Namespace: obj.Namespace, Name: obj.Name}
+		if t.Namespace == "" {
+			t.Namespace = client.Namespace
+		}
+		if len(targets) == 0 || targets[0] != t {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// deployKubernetes update the Kubernetes target of a project to ref: set the image of the
+// Deployment or apply the manifests of the checkout, then wait until the Deployments rolled
+// out. Failed rollouts are rolled back when the project asks for it.
+func deployKubernetes(project *types.ProjectConfig, ref, commit string) error {
+	k := project.Kubernetes
+	client, err := kubeClient(project)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	var objects []kube.Object
+	if k.Mode == types.KubernetesManifests {
+		if objects, err = kube.ReadManifests(filepath.Join(project.Path, k.ManifestDir)); err != nil {
+			return fmt.Errorf("manifests: %v", err)
+		}
+		if len(objects) == 0 {
+			return fmt.Errorf("manifests: no objects in %s", k.ManifestDir)
+		}
+	}
+	targets := kubeTargets(client, project, objects)
+
+	// revisions before the update tell which Deployments a rollback has to undo
+	before := map[kubeTarget]int64{}
+	for _, t := range targets {
+		if d, err := client.GetDeployment(ctx, t.Namespace, t.Name); err == nil {
+			before[t] = d.Revision()
+		} else if !kube.IsNotFound(err) || k.Mode == types.KubernetesImage {
+			return err
+		}
+	}
+
+	switch k.Mode {
+	case types.KubernetesImage:
+		image := k.Image + ":" + imageTag(k.TagTemplate, ref, commit)
+		previous, err := client.SetImage(ctx, "", k.Deployment, k.Container, image)
+		if err != nil {
+			return fmt.Errorf("set image of %s: %v", k.Deployment, err)
+		}
+		log.Printf("kubernetes: project %s set deployment %s/%s image %s (was %s)", project.Name, client.Namespace, k.Deployment, image, previous)
+	default:
+		applied, err := client.Apply(ctx, objects)
+		if err != nil {
+			return err
+		}
+		log.Printf("kubernetes: project %s applied %d objects from %s", project.Name, len(applied), k.ManifestDir)
+	}
+
+	timeout, _ := k.RolloutTimeout()
+	if timeout == 0 {
+		return nil
+	}
+	for _, t := range targets {
+		err := client.WaitRollout(ctx, t.Namespace, t.Name, timeout, func(status string) {
+			log.Printf("kubernetes: project %s deployment %s: %s", project.Name, t, status)
+		})
+		if err == nil {
+			continue
+		}
+		if !k.Rollback {
+			return err
+		}
+		return fmt.Errorf("%v; %s", err, rollbackKubernetes(ctx, client, project, targets, before, "GitHook", ""))
+	}
+	return nil
+}
+
+// rollbackKubernetes undo the Deployments whose revision changed since before and describe
+// the result
+func rollbackKubernetes(ctx context.Context, client *kube.Client, project *types.ProjectConfig, targets []kubeTarget,
+	before map[kubeTarget]int64, username, ipAddress string) string {
+	var results []string
+	for _, t := range targets {
+		d, err := client.GetDeployment(ctx, t.Namespace, t.Name)
+		if err != nil {
+			results = append(results, fmt.Sprintf("rollback of %s failed: %v", t, err))
+			continue
+		}
+		if rev, ok := before[t]; ok && d.Revision() == rev {
+			continue
+		}
+		results = append(results, undoDeployment(ctx, client, project, t, d.Revision(), username, ipAddress))
+	}
+	if len(results) == 0 {
+		return "nothing to roll back"
+	}
+	return strings.Join(results, "; ")
+}
+
+// undoDeployment roll a Deployment back to its previous revision and record it in the project
+// activity log
+func undoDeployment(ctx context.Context, client *kube.Client, project *types.ProjectConfig, t kubeTarget, current int64,
+	username, ipAddress string) string {
+	rev, err := client.Undo(ctx, t.Namespace, t.Name)
+	result := fmt.Sprintf("rolled back %s to revision %d", t, rev)
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+		result = fmt.Sprintf("rollback of %s failed: %v", t, err)
+	}
+	database.LogProjectAction(
+		project.Name,                        // projectName
+		database.ProjectActionRollback,      // action
+		fmt.Sprintf("revision:%d", current), // oldValue
+		fmt.Sprintf("revision:%d", rev),     // newValue
+		username,                            // username
+		err == nil,                          // success
+		errMsg,                              // error
+		"",                                  // commitHash
+		"Kubernetes "+result,                // description
+		ipAddress,                           // ipAddress
+	)
+	log.Printf("kubernetes: project %s %s", project.Name, result)
+	return result
+}
+
+// kubeProject enabled project with a Kubernetes target named by the :name parameter
+func kubeProject(c *gin.Context) *types.ProjectConfig {
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return nil
+	}
+	if project.Kubernetes == nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeNoKubernetesTarget, nil)
+		return nil
+	}
+	return project
+}
+
+// kubeProjectTargets client and checked Deployments of a project, the manifests are read
+// from the current checkout
+func kubeProjectTargets(project *types.ProjectConfig) (*kube.Client, []kubeTarget, error) {
+	client, err := kubeClient(project)
+	if err != nil {
+		return nil, nil, err
+	}
+	var objects []kube.Object
+	if project.Kubernetes.Mode == types.KubernetesManifests {
+		if objects, err = kube.ReadManifests(filepath.Join(project.Path, project.Kubernetes.ManifestDir)); err != nil {
+			return nil, nil, err
+		}
+	}
+	return client, kubeTargets(client, project, objects), nil
+}
+
+// KubeDeploymentStatus rollout state of a Deployment of a Kubernetes target
+type KubeDeploymentStatus struct {
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Revision  int64    `json:"revision"`
+	Images    []string `json:"images"`
+	Replicas  int32    `json:"replicas"`
+	Updated   int32    `json:"updated"`
+	Ready     int32    `json:"ready"`
+	Available int32    `json:"available"`
+	Done      bool     `json:"done"`
+	Message   string   `json:"message"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// HandleKubernetesStatus rollout state of the Deployments of the project's Kubernetes target
+func HandleKubernetesStatus(c *gin.Context) {
+	project := kubeProject(c)
+	if project == nil {
+		return
+	}
+	client, targets, err := kubeProjectTargets(project)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadGateway, i18n.CodeKubernetesFailed, err)
+		return
+	}
+	statuses := []KubeDeploymentStatus{}
+	for _, t := range targets {
+		s := KubeDeploymentStatus{Namespace: t.Namespace, Name: t.Name}
+		d, err := client.GetDeployment(c.Request.Context(), t.Namespace, t.Name)
+		if err != nil {
+			s.Error = err.Error()
+			statuses = append(statuses, s)
+			continue
+		}
+		for _, ct := range d.Spec.Template.Spec.Containers {
+			s.Images = append(s.Images, ct.Image)
+		}
+		s.Revision = d.Revision()
+		s.Replicas, s.Updated, s.Ready, s.Available = d.Status.Replicas, d.Status.UpdatedReplicas, d.Status.ReadyReplicas, d.Status.AvailableReplicas
+		if s.Done, s.Message, err = d.RolloutDone(); err != nil {
+			s.Error = err.Error()
+		}
+		statuses = append(statuses, s)
+	}
+	c.JSON(http.StatusOK, gin.H{"mode": project.Kubernetes.Mode, "deployments": statuses})
+}
+
+// HandleKubernetesRollback roll the Deployments of the project's Kubernetes target, or the
+// one named in the body, back to their previous revision
+func HandleKubernetesRollback(c *gin.Context) {
+	project := kubeProject(c)
+	if project == nil {
+		return
+	}
+	var req struct {
+		Deployment string `json:"deployment"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+			return
+		}
+	}
+	client, targets, err := kubeProjectTargets(project)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadGateway, i18n.CodeKubernetesFailed, err)
+		return
+	}
+	if req.Deployment != "" {
+		var selected []kubeTarget
+		for _, t := range targets {
+			if t.Name == req.Deployment || t.String() == req.Deployment {
+				selected = append(selected, t)
+			}
+		}
+		if len(selected) == 0 {
+			i18n.JSONError(c, http.StatusNotFound, i18n.CodeDeploymentNotInTarget, nil)
+			return
+		}
+		targets = selected
+	}
+	if len(targets) == 0 {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeNoDeploymentsToRollBack, nil)
+		return
+	}
+
+	ctx := c.Request.Context()
+	var results []string
+	failed := false
+	for _, t := range targets {
+		d, err := client.GetDeployment(ctx, t.Namespace, t.Name)
+		if err != nil {
+			failed = true
+			results = append(results, fmt.Sprintf("rollback of %s failed: %v", t, err))
+			continue
+		}
+		result := undoDeployment(ctx, client, project, t, d.Revision(), currentUsername(c), middleware.GetClientIP(c))
+		failed = failed || strings.HasPrefix(result, "rollback of")
+		results = append(results, result)
+	}
+	if failed {
+		c.JSON(http.StatusBadGateway, gin.H{"error": i18n.Message(c, i18n.CodeRollbackFailed) + ": " + strings.Join(results, "; "), "code": i18n.CodeRollbackFailed, "results": results})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeRolledBack), "code": i18n.CodeRolledBack, "results": results})
+}
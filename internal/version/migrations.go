@@ -0,0 +1,79 @@
+package version
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// maxMigrationOutput bytes of migration output kept in the project activity
+const maxMigrationOutput = 64 << 10
+
+// runMigrations run the database migrations of a project deployed to ref at commit and record
+// the result with its output as a MIGRATION activity. A failed or timed out migration fails
+// the deploy.
+func runMigrations(project *types.ProjectConfig, ref, commit, username, ipAddress string) error {
+	m := project.Migrations
+	timeout, err := m.RunTimeout()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, m.Command[0], m.Command[1:]...)
+	cmd.Dir = filepath.Join(project.Path, m.Dir)
+	cmd.Env = append(os.Environ(),
+		"GOHOOK_PROJECT="+project.Name,
+		"GOHOOK_PROJECT_PATH="+project.Path,
+		"GOHOOK_REF="+ref,
+		"GOHOOK_COMMIT="+commit,
+	)
+	cmd.Env = append(cmd.Env, m.Env...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	start := time.Now()
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	out := output.String()
+	if len(out) > maxMigrationOutput {
+		out = "...\n" + out[len(out)-maxMigrationOutput:]
+	}
+	shortCommit := commit
+	if len(shortCommit) > 7 {
+		shortCommit = shortCommit[:7]
+	}
+	activity := &database.ProjectActivity{
+		ProjectName: project.Name,
+		Action:      database.ProjectActionMigration,
+		NewValue:    ref,
+		Username:    username,
+		Success:     err == nil,
+		CommitHash:  shortCommit,
+		Description: fmt.Sprintf("Migrations for %s succeeded in %s", ref, elapsed),
+		IPAddress:   ipAddress,
+		Output:      out,
+	}
+	if err != nil {
+		err = fmt.Errorf("migration %s failed: %v", m.Command[0], err)
+		activity.Error = err.Error()
+		activity.Description = fmt.Sprintf("Migrations for %s failed after %s: %v", ref, elapsed, err)
+		log.Printf("migrations failed: project=%s, ref=%s, error=%v", project.Name, ref, err)
+	}
+	database.LogProjectActivity(activity)
+	return err
+}
@@ -0,0 +1,155 @@
+package version
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/maintenance"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// changedFiles files added, modified or removed by a push according to the payload commits
+// (GitHub, GitLab, Gitea, Gogs and Gitee). ok is false when the payload does not list every
+// change, such as force pushes or truncated commit lists, and the push must be treated as
+// touching everything.
+func changedFiles(payload map[string]interface{}) (files []string, ok bool) {
+	if forced, _ := payload["forced"].(bool); forced {
+		return nil, false
+	}
+	commits, _ := payload["commits"].([]interface{})
+	if len(commits) == 0 {
+		return nil, false
+	}
+	if total, isNum := payload["total_commits_count"].(float64); isNum && int(total) > len(commits) {
+		return nil, false
+	}
+
+	seen := map[string]bool{}
+	for _, c := range commits {
+		commit, isMap := c.(map[string]interface{})
+		if !isMap {
+			return nil, false
+		}
+		for _, key := range []string{"added", "modified", "removed"} {
+			list, _ := commit[key].([]interface{})
+			for _, f := range list {
+				if name, isStr := f.(string); isStr && !seen[name] {
+					seen[name] = true
+					files = append(files, name)
+				}
+			}
+		}
+	}
+	return files, true
+}
+
+// matchHookPath report whether file is covered by pattern: a directory ("services/api" or
+// "services/api/"), a directory tree ("services/api/**") or a path.Match pattern ("*.md")
+func matchHookPath(pattern, file string) bool {
+	pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "/")
+	if pattern == "" {
+		return false
+	}
+	if dir, isTree := strings.CutSuffix(pattern, "**"); isTree {
+		return dir == "" || strings.HasPrefix(file, dir)
+	}
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(file, pattern)
+	}
+	if ok, _ := path.Match(pattern, file); ok {
+		return true
+	}
+	return strings.HasPrefix(file, pattern+"/")
+}
+
+// pushTouchesHookPaths report whether a push changes files under the project hookpaths, pushes
+// whose changes are unknown always do
+func pushTouchesHookPaths(project *types.ProjectConfig, payload map[string]interface{}) bool {
+	if len(project.Hookpaths) == 0 {
+		return true
+	}
+	files, ok := changedFiles(payload)
+	if !ok {
+		return true
+	}
+	for _, file := range files {
+		for _, pattern := range project.Hookpaths {
+			if matchHookPath(pattern, file) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// monorepoProjects the enabled GitHook projects of the monorepo group of project, project first
+func monorepoProjects(project *types.ProjectConfig) []*types.ProjectConfig {
+	projects := []*types.ProjectConfig{project}
+	if project.Monorepo == "" {
+		return projects
+	}
+	for i := range types.GoHookVersionData.Projects {
+		p := &types.GoHookVersionData.Projects[i]
+		if p.Name != project.Name && p.Enabled && p.Enhook && p.Monorepo == project.Monorepo {
+			projects = append(projects, p)
+		}
+	}
+	return projects
+}
+
+// monorepoResult outcome of a monorepo delivery for one project
+type monorepoResult struct {
+	Project string `json:"project"`
+	Action  string `json:"action,omitempty"`
+	Target  string `json:"target,omitempty"`
+	Success bool   `json:"success"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Queued  bool   `json:"queued,omitempty"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// handleMonorepoGitHook run a verified delivery for every project of the monorepo group. The
+// projects not touched by the push are skipped by their hookpaths, projects sharing a checkout
+// are switched once.
+func handleMonorepoGitHook(c *gin.Context, projects []*types.ProjectConfig, d gitHookDelivery) {
+	if d.Deployed == nil {
+		d.Deployed = map[string]bool{}
+	}
+	results := make([]monorepoResult, 0, len(projects))
+	status := http.StatusOK
+	for _, project := range projects {
+		r := monorepoResult{Project: project.Name}
+		if decision := maintenance.Check(maintenance.KindGitHook, project.Name); decision.Paused {
+			if decision.RejectStatus != 0 {
+				r.Error = "GitHook is paused: " + decision.Reason
+			} else if err := queueGitHook(project, d, decision.Reason); err != nil {
+				r.Error = "GitHook is paused and the delivery could not be queued"
+			} else {
+				r.Success, r.Queued = true, true
+				r.Message = "GitHook is paused, delivery queued: " + decision.Reason
+			}
+			results = append(results, r)
+			continue
+		}
+
+		result, err := processGitHook(project, d)
+		r.Action, r.Target = result.Action, result.Target
+		r.Success, r.Skipped, r.Message = result.Success && err == nil, result.Skipped, result.Message
+		if err != nil {
+			r.Error = err.Error()
+			status = http.StatusInternalServerError
+		}
+		results = append(results, r)
+	}
+	log.Printf("GitHook monorepo %s: delivery routed to %d projects", projects[0].Monorepo, len(projects))
+	c.JSON(status, gin.H{
+		"monorepo": projects[0].Monorepo,
+		"message":  fmt.Sprintf("GitHook processed for %d projects", len(results)),
+		"results":  results,
+	})
+}
@@ -0,0 +1,34 @@
+package version
+
+import (
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func init() {
+	namespace.Register(namespace.KindProject, namespace.Resource{
+		Lookup: func(name string) (string, bool) {
+			if types.GoHookVersionData == nil {
+				return "", false
+			}
+			for _, proj := range types.GoHookVersionData.Projects {
+				if proj.Name == name {
+					return namespace.Normalize(proj.Namespace), true
+				}
+			}
+			return "", false
+		},
+		Names: func(ns string) []string {
+			var names []string
+			if types.GoHookVersionData == nil {
+				return names
+			}
+			for _, proj := range types.GoHookVersionData.Projects {
+				if namespace.Normalize(proj.Namespace) == ns {
+					names = append(names, proj.Name)
+				}
+			}
+			return names
+		},
+	})
+}
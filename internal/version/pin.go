@@ -0,0 +1,181 @@
+package version
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/config"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/stream"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// PinnedError a deploy of a pinned project was refused
+type PinnedError struct {
+	Project string
+	Pin     *types.ProjectPin
+}
+
+func (e *PinnedError) Error() string {
+	msg := fmt.Sprintf("project %s is pinned at %s by %s since %s", e.Project, pinPosition(e.Pin),
+		e.Pin.PinnedBy, e.Pin.PinnedAt.Format(time.RFC3339))
+	if e.Pin.Reason != "" {
+		msg += " (" + e.Pin.Reason + ")"
+	}
+	return msg + ", unpin it to deploy"
+}
+
+// pinPosition describe the ref and commit a project is pinned at
+func pinPosition(pin *types.ProjectPin) string {
+	commit := pin.Commit
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	switch {
+	case pin.Ref == "":
+		return commit
+	case commit == "":
+		return pin.Ref
+	default:
+		return pin.Ref + "@" + commit
+	}
+}
+
+// checkPin return a PinnedError when the project is pinned
+func checkPin(project *types.ProjectConfig) error {
+	if project.Pin == nil {
+		return nil
+	}
+	return &PinnedError{Project: project.Name, Pin: project.Pin}
+}
+
+// enforcePin refuse a deploy of a pinned project with 423
+func enforcePin(c *gin.Context, project *types.ProjectConfig) bool {
+	err := checkPin(project)
+	if err == nil {
+		return true
+	}
+	i18n.JSONError(c, http.StatusLocked, i18n.CodeProjectPinned, err)
+	return false
+}
+
+// HandlePinProject lock a project on the revision it serves, GitHooks, switches, promotions
+// and release activations are refused until it is unpinned
+func HandlePinProject(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+			return
+		}
+	}
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	if project.Pin != nil {
+		i18n.JSONError(c, http.StatusConflict, i18n.CodeProjectPinned, checkPin(project))
+		return
+	}
+
+	pin := &types.ProjectPin{
+		Reason:   req.Reason,
+		PinnedBy: currentUsername(c),
+		PinnedAt: time.Now(),
+	}
+	if status, err := projectStatus(project); err == nil {
+		pin.Ref = status.CurrentTag
+		if pin.Ref == "" {
+			pin.Ref = status.CurrentBranch
+		}
+		pin.Commit = status.LastCommit
+	} else {
+		log.Printf("pin: project %s position unknown: %v", project.Name, err)
+	}
+	if isGitProject(project) {
+		if _, _, commit, err := resolveDeployedRevision(project.Path); err == nil {
+			pin.Commit = commit
+		}
+	}
+
+	project.Pin = pin
+	if err := config.SaveVersionConfig(); err != nil {
+		project.Pin = nil
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
+		return
+	}
+	logPinAction(c, project, database.ProjectActionPin, pin)
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeProjectPinSet), "code": i18n.CodeProjectPinSet, "pin": pin})
+}
+
+// HandleUnpinProject remove the pin of a project
+func HandleUnpinProject(c *gin.Context) {
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	pin := project.Pin
+	if pin == nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeProjectNotPinned, nil)
+		return
+	}
+
+	project.Pin = nil
+	if err := config.SaveVersionConfig(); err != nil {
+		project.Pin = pin
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
+		return
+	}
+	logPinAction(c, project, database.ProjectActionUnpin, pin)
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeProjectUnpinned), "code": i18n.CodeProjectUnpinned})
+}
+
+// logPinAction record a pin or unpin in the project activity log and tell the clients
+func logPinAction(c *gin.Context, project *types.ProjectConfig, action string, pin *types.ProjectPin) {
+	position := pinPosition(pin)
+	description := fmt.Sprintf("Project pinned at %s", position)
+	oldValue, newValue := "", position
+	if action == database.ProjectActionUnpin {
+		description = fmt.Sprintf("Project unpinned, was pinned at %s by %s", position, pin.PinnedBy)
+		oldValue, newValue = position, ""
+	}
+	if pin.Reason != "" {
+		description += ": " + pin.Reason
+	}
+	shortCommit := pin.Commit
+	if len(shortCommit) > 7 {
+		shortCommit = shortCommit[:7]
+	}
+	database.LogProjectAction(
+		project.Name,              // projectName
+		action,                    // action
+		oldValue,                  // oldValue
+		newValue,                  // newValue
+		currentUsername(c),        // username
+		true,                      // success
+		"",                        // error
+		shortCommit,               // commitHash
+		description,               // description
+		middleware.GetClientIP(c), // ipAddress
+	)
+
+	stream.Global.Broadcast(stream.WsMessage{
+		Type:      "project_managed",
+		Timestamp: time.Now(),
+		Data: stream.ProjectManageMessage{
+			Action:      strings.ToLower(action),
+			ProjectName: project.Name,
+			Success:     true,
+		},
+	})
+}
@@ -0,0 +1,227 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mycoool/gohook/internal/maintenance"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// pollCheckInterval how often the poller looks for projects that are due
+const pollCheckInterval = 10 * time.Second
+
+// pollMethod method of the GitHook executions started by polling
+const pollMethod = "POLL"
+
+// pollState schedule and last seen remote revision of a polled project
+type pollState struct {
+	next    time.Time
+	seen    string // last remote commit, or newest tag in tag mode, a deploy was started for
+	running bool
+}
+
+var (
+	pollMu     sync.Mutex
+	pollStates = map[string]*pollState{}
+)
+
+// pollTarget the ref a project follows and its commit on the remote, fetched beforehand.
+// Tag mode follows the newest tag.
+func pollTarget(project *types.ProjectConfig) (string, string, error) {
+	if project.Hookmode == "tag" {
+		output, err := execGitCommandOutput(project.Path, "for-each-ref", "--sort=-creatordate", "--count=1", "--format=%(refname:short)", "refs/tags")
+		if err != nil {
+			return "", "", fmt.Errorf("list tags failed: %v, output: %s", err, strings.TrimSpace(string(output)))
+		}
+		tag := strings.TrimSpace(string(output))
+		if tag == "" {
+			return "", "", nil
+		}
+		return "refs/tags/" + tag, tag, nil
+	}
+
+	branch := project.Hookbranch
+	if branch == "" || branch == "*" {
+		// any branch is deployed: follow the one checked out
+		output, err := execGitCommandOutput(project.Path, "rev-parse", "--abbrev-ref", "HEAD")
+		if err != nil {
+			return "", "", fmt.Errorf("read current branch failed: %v", err)
+		}
+		branch = strings.TrimSpace(string(output))
+	}
+	output, err := execGitCommandOutput(project.Path, "rev-parse", "refs/remotes/origin/"+branch)
+	if err != nil {
+		return "", "", fmt.Errorf("branch %s not found on origin", branch)
+	}
+	return "refs/heads/" + branch, strings.TrimSpace(string(output)), nil
+}
+
+// pollPayload push payload of a polled change, listing the changed files for hookpaths
+func pollPayload(project *types.ProjectConfig, ref, before, after string) map[string]interface{} {
+	payload := map[string]interface{}{"ref": ref, "before": before, "after": after}
+	if !strings.HasPrefix(ref, "refs/heads/") || before == "" {
+		return payload
+	}
+	output, err := execGitCommandOutput(project.Path, "diff", "--name-only", before, after)
+	if err != nil {
+		return payload
+	}
+	var files []interface{}
+	for _, f := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	payload["commits"] = []interface{}{map[string]interface{}{"id": after, "modified": files}}
+	return payload
+}
+
+// pollProject fetch the remote of a project and deploy its branch or newest tag when it moved
+// since the last poll. The first poll after a start only deploys a branch the checkout is
+// behind on, a newer tag is taken as the starting point.
+func pollProject(project *types.ProjectConfig, seen string) (string, error) {
+	if _, err := os.Stat(filepath.Join(project.Path, ".git")); err != nil {
+		return seen, fmt.Errorf("project path is not a Git repository")
+	}
+	if output, err := execGitCommand(project.Path, "fetch", "--tags", "origin"); err != nil {
+		return seen, fmt.Errorf("fetch failed: %v, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	ref, target, err := pollTarget(project)
+	if err != nil || target == "" || target == seen {
+		return seen, err
+	}
+
+	head := ""
+	if output, err := execGitCommandOutput(project.Path, "rev-parse", "HEAD"); err == nil {
+		head = strings.TrimSpace(string(output))
+	}
+	if seen == "" {
+		if strings.HasPrefix(ref, "refs/tags/") || head == target {
+			return target, nil
+		}
+		// only a fast-forward of the branch checked out counts as a missed push
+		current, _ := execGitCommandOutput(project.Path, "rev-parse", "--abbrev-ref", "HEAD")
+		if "refs/heads/"+strings.TrimSpace(string(current)) != ref {
+			return target, nil
+		}
+		if _, err := execGitCommandOutput(project.Path, "merge-base", "--is-ancestor", head, target); err != nil {
+			return target, nil
+		}
+	} else if head == target {
+		return target, nil
+	}
+
+	log.Printf("poll: %s of project %s moved to %s, deploying", ref, project.Name, target)
+	before := seen
+	if strings.HasPrefix(ref, "refs/heads/") {
+		before = head
+	}
+	d := gitHookDelivery{
+		Method:    pollMethod,
+		UserAgent: "gohook-poller",
+		Headers:   map[string][]string{"X-GoHook-Event": {"poll"}},
+		Payload:   pollPayload(project, ref, before, target),
+	}
+	if decision := maintenance.Check(maintenance.KindGitHook, project.Name); decision.Paused {
+		if decision.RejectStatus != 0 {
+			log.Printf("poll: GitHook of project %s is paused, change skipped: %s", project.Name, decision.Reason)
+			return target, nil
+		}
+		return target, queueGitHook(project, d, decision.Reason)
+	}
+	_, err = processGitHook(project, d)
+	return target, err
+}
+
+// pollDue projects whose poll is due at now, marked as running; projects no longer polled
+// are forgotten
+func pollDue(now time.Time) []types.ProjectConfig {
+	pollMu.Lock()
+	defer pollMu.Unlock()
+	var due []types.ProjectConfig
+	polled := map[string]bool{}
+	for _, project := range types.GoHookVersionData.Projects {
+		if !project.Enabled || !project.Enhook || project.Poll == nil || !project.Poll.Enabled {
+			continue
+		}
+		polled[project.Name] = true
+		state := pollStates[project.Name]
+		if state == nil {
+			// spread the first polls after a start
+			state = &pollState{next: now.Add(pollJitter(project.Poll))}
+			pollStates[project.Name] = state
+		}
+		if state.running || now.Before(state.next) {
+			continue
+		}
+		state.running = true
+		due = append(due, project)
+	}
+	for name, state := range pollStates {
+		if !polled[name] && !state.running {
+			delete(pollStates, name)
+		}
+	}
+	return due
+}
+
+// pollJitter random delay up to the jitter of the project
+func pollJitter(cfg *types.ProjectPollConfig) time.Duration {
+	if max := cfg.MaxJitter(); max > 0 {
+		return time.Duration(rand.Int63n(int64(max)))
+	}
+	return 0
+}
+
+// runPoll poll one project and schedule its next poll
+func runPoll(name string, cfg *types.ProjectPollConfig) {
+	pollMu.Lock()
+	seen := pollStates[name].seen
+	pollMu.Unlock()
+
+	// the deploy works on the live configuration, like a GitHook delivery
+	if project := findEnabledProject(name); project != nil {
+		var err error
+		if seen, err = pollProject(project, seen); err != nil {
+			log.Printf("poll of project %s failed: %v", name, err)
+		}
+	}
+
+	pollMu.Lock()
+	defer pollMu.Unlock()
+	state := pollStates[name]
+	state.seen = seen
+	state.running = false
+	state.next = time.Now().Add(cfg.Every() + pollJitter(cfg))
+}
+
+// SchedulePolling poll the remotes of projects with polling enabled, it stops once ctx is
+// done. Each project is polled on its own interval plus jitter, slow remotes do not delay
+// the others.
+func SchedulePolling(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(pollCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if types.GoHookVersionData == nil {
+				continue
+			}
+			for _, project := range pollDue(time.Now()) {
+				go runPoll(project.Name, project.Poll)
+			}
+		}
+	}()
+}
@@ -0,0 +1,159 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// preflight checks
+const (
+	PreflightDisk  = "disk"
+	PreflightWrite = "write"
+	PreflightGit   = "git"
+)
+
+// PreflightError a preflight check failed, nothing was changed in the project
+type PreflightError struct {
+	Check   string
+	Message string
+}
+
+func (e *PreflightError) Error() string {
+	return fmt.Sprintf("preflight %s check failed: %s", e.Check, e.Message)
+}
+
+// PreflightResult outcome of one preflight check
+type PreflightResult struct {
+	Check   string `json:"check"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// runPreflight run the checks configured for project, every check is run so a report shows
+// all problems at once
+func runPreflight(project *types.ProjectConfig) []PreflightResult {
+	cfg := project.Preflight
+	if cfg != nil && cfg.Disabled {
+		return nil
+	}
+	var results []PreflightResult
+	add := func(check string, err error) {
+		r := PreflightResult{Check: check, OK: err == nil}
+		if err != nil {
+			r.Message = err.Error()
+		}
+		results = append(results, r)
+	}
+
+	if need := cfg.MinFreeBytes(); need > 0 {
+		add(PreflightDisk, checkFreeSpace(project.Path, need))
+	}
+	if cfg == nil || !cfg.SkipWriteCheck {
+		add(PreflightWrite, checkWritable(project.Path))
+	}
+	if cfg != nil && cfg.GitFsck {
+		add(PreflightGit, checkGitConnectivity(project.Path))
+	}
+	return results
+}
+
+// preflightDeploy run the preflight checks before fetch/checkout and return the first failure
+func preflightDeploy(project *types.ProjectConfig) error {
+	for _, r := range runPreflight(project) {
+		if !r.OK {
+			return &PreflightError{Check: r.Check, Message: r.Message}
+		}
+	}
+	return nil
+}
+
+// deployErrorStatus return the HTTP status for a failed deploy, preflight failures are 412,
+// protection violations and untrusted signatures 403, pinned projects 423
+func deployErrorStatus(err error) int {
+	var pe *PreflightError
+	if errors.As(err, &pe) {
+		return http.StatusPreconditionFailed
+	}
+	var pinErr *PinnedError
+	if errors.As(err, &pinErr) {
+		return http.StatusLocked
+	}
+	var protErr *ProtectionError
+	if errors.As(err, &protErr) {
+		return http.StatusForbidden
+	}
+	var sigErr *SignatureError
+	if errors.As(err, &sigErr) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+func checkFreeSpace(path string, need int64) error {
+	usage, err := disk.Usage(path)
+	if err != nil {
+		return fmt.Errorf("read free space of %s: %v", path, err)
+	}
+	if int64(usage.Free) < need {
+		return fmt.Errorf("%d MB free on %s, %d MB required", usage.Free>>20, path, need>>20)
+	}
+	return nil
+}
+
+// checkWritable create and remove a file in the project path and in .git, where fetch
+// writes objects and checkout writes the index
+func checkWritable(projectPath string) error {
+	dirs := []string{projectPath}
+	if info, err := os.Stat(filepath.Join(projectPath, ".git")); err == nil && info.IsDir() {
+		dirs = append(dirs, filepath.Join(projectPath, ".git"))
+	}
+	for _, dir := range dirs {
+		f, err := os.CreateTemp(dir, ".gohook-preflight-*")
+		if err != nil {
+			return fmt.Errorf("%s is not writable: %v", dir, err)
+		}
+		name := f.Name()
+		_ = f.Close()
+		_ = os.Remove(name)
+	}
+	return nil
+}
+
+func checkGitConnectivity(projectPath string) error {
+	output, err := execGitCommand(projectPath, "fsck", "--connectivity-only", "--no-progress")
+	if err != nil {
+		msg := strings.TrimSpace(string(output))
+		if lines := strings.Split(msg, "\n"); len(lines) > 5 {
+			msg = strings.Join(lines[:5], "\n") + "\n..."
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("git fsck --connectivity-only: %s", msg)
+	}
+	return nil
+}
+
+// HandlePreflight run the preflight checks of a project without deploying
+func HandlePreflight(c *gin.Context) {
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	results := runPreflight(project)
+	ok := true
+	for _, r := range results {
+		ok = ok && r.OK
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": ok, "checks": results})
+}
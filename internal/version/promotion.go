@@ -0,0 +1,457 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/stream"
+	"github.com/mycoool/gohook/internal/types"
+	"gorm.io/gorm"
+)
+
+// promotion status
+const (
+	PromotionPending  = "pending"
+	PromotionRejected = "rejected"
+	PromotionSuccess  = "success"
+	PromotionFailed   = "failed"
+)
+
+// promotionMu serializes promotion execution so a pending promotion is applied once
+var promotionMu sync.Mutex
+
+// maxPromotionChain guards against cycles when walking parent promotions
+const maxPromotionChain = 20
+
+// promotionAllowed check the target accepts promotions from source
+func promotionAllowed(target *types.ProjectConfig, source string) bool {
+	if target.Promotion == nil || len(target.Promotion.From) == 0 {
+		return true
+	}
+	for _, name := range target.Promotion.From {
+		if name == source {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDeployedRevision return the deployed revision of a project: the tag HEAD is at, or the commit
+func resolveDeployedRevision(projectPath string) (refType, ref, commit string, err error) {
+	output, err := execGitCommandOutput(projectPath, "rev-parse", "HEAD")
+	if err != nil {
+		return "", "", "", fmt.Errorf("resolve HEAD failed: %v", err)
+	}
+	commit = strings.TrimSpace(string(output))
+	if output, err := execGitCommandOutput(projectPath, "describe", "--tags", "--exact-match", "HEAD"); err == nil {
+		return "tag", strings.TrimSpace(string(output)), commit, nil
+	}
+	return "commit", commit, commit, nil
+}
+
+// lastDeployError return the error of the latest deploy of a project if it failed
+func lastDeployError(projectName string) string {
+	db := database.GetDB()
+	if db == nil {
+		return ""
+	}
+	var activity database.ProjectActivity
+	err := db.Where("project_name = ? AND action IN ?", projectName, database.DeployActions).
+		Order("id DESC").First(&activity).Error
+	if err != nil || activity.Success {
+		return ""
+	}
+	if activity.Error != "" {
+		return activity.Error
+	}
+	return "last deploy failed"
+}
+
+// describePosition describe the current tag or branch@commit of a project for logging
+func describePosition(projectPath string) string {
+	if output, err := execGitCommandOutput(projectPath, "describe", "--tags", "--exact-match", "HEAD"); err == nil {
+		return fmt.Sprintf("Tag:%s", strings.TrimSpace(string(output)))
+	}
+	gitStatus, err := getGitStatus(projectPath)
+	if err != nil || gitStatus.CurrentBranch == "" {
+		return "Unknown position"
+	}
+	position := fmt.Sprintf("Branch:%s", gitStatus.CurrentBranch)
+	if len(gitStatus.LastCommit) > 7 {
+		position += "@" + gitStatus.LastCommit[:7]
+	}
+	return position
+}
+
+// switchToCommit check out a commit in detached HEAD
+// force: if true, will discard all local changes before switching
+func switchToCommit(projectPath, commit string, force bool) error {
+	if output, err := execGitCommand(projectPath, "fetch", "--all", "--tags"); err != nil {
+		log.Printf("warning: failed to fetch remote information: %s", string(output))
+	}
+
+	if err := execGitCommandRun(projectPath, "cat-file", "-e", commit+"^{commit}"); err != nil {
+		return fmt.Errorf("commit %s not found, make sure both projects use the same remote", commit)
+	}
+
+	if err := verifyDeploySignature(projectPath, "commit", commit); err != nil {
+		return err
+	}
+	if force {
+		if err := forceCleanWorkingDirectory(projectPath); err != nil {
+			return fmt.Errorf("force clean failed: %v", err)
+		}
+	}
+
+	if output, err := execGitCommand(projectPath, "checkout", "--detach", commit); err != nil {
+		return fmt.Errorf("switch to commit %s failed: %s", commit, string(output))
+	}
+
+	log.Printf("successfully switched to commit: %s", commit)
+	return nil
+}
+
+// executePromotion switch the target project to the promoted revision and record the result
+func executePromotion(p *database.ProjectPromotion, target *types.ProjectConfig, username, ipAddress string) error {
+	oldPosition := describePosition(target.Path)
+	before := captureDeployPoint(target)
+
+	err := checkPin(target)
+	if err == nil {
+		err = preflightDeploy(target)
+	}
+	if err == nil && target.ForceSync && p.RefType == "tag" {
+		if err = checkProtection(target, "tag", p.Ref, protectForceSwitch); err != nil {
+			recordPolicyViolation(target.Name, err, username, ipAddress)
+		}
+	}
+	if err == nil {
+		if p.RefType == "tag" {
+			err = switchToTag(target.Path, p.Ref, target.ForceSync)
+		} else {
+			err = switchToCommit(target.Path, p.CommitHash, target.ForceSync)
+		}
+	}
+	if err == nil {
+		// a tag with the same name may point elsewhere in the target repository
+		if _, _, commit, resolveErr := resolveDeployedRevision(target.Path); resolveErr != nil {
+			err = resolveErr
+		} else if commit != p.CommitHash {
+			err = fmt.Errorf("%s resolves to %s in %s, expected %s", p.Ref, commit, target.Name, p.CommitHash)
+		}
+	}
+	if err == nil && target.Releases != nil {
+		err = deployRelease(target, p.Ref, username, ipAddress)
+	}
+	if err == nil && target.Migrations != nil {
+		err = runMigrations(target, p.Ref, p.CommitHash, username, ipAddress)
+	}
+	if err == nil && target.HealthCheck != nil {
+		// the service has to run the new revision before it can be checked
+		runPostDeploy(target)
+		err = verifyDeploy(target, before, p.Ref, username, ipAddress)
+	}
+
+	now := time.Now()
+	p.FinishedAt = &now
+	p.Status = PromotionSuccess
+	p.Error = ""
+	if err != nil {
+		p.Status = PromotionFailed
+		p.Error = err.Error()
+	}
+	if db := database.GetDB(); db != nil {
+		if saveErr := db.Save(p).Error; saveErr != nil {
+			log.Printf("save promotion failed: id=%d, error=%v", p.ID, saveErr)
+		}
+	}
+
+	shortCommit := p.CommitHash
+	if len(shortCommit) > 7 {
+		shortCommit = shortCommit[:7]
+	}
+	newPosition := fmt.Sprintf("%s:%s", p.SourceProject, p.Ref)
+	if p.RefType == "tag" {
+		newPosition = fmt.Sprintf("%s:Tag:%s", p.SourceProject, p.Ref)
+	}
+	description := fmt.Sprintf("Promote from %s: %s -> %s (commit: %s)", p.SourceProject, oldPosition, p.Ref, shortCommit)
+	if err != nil {
+		description = fmt.Sprintf("Promote from %s failed: %s -> %s: %s", p.SourceProject, oldPosition, p.Ref, err.Error())
+	}
+	database.LogProjectAction(
+		target.Name,
+		database.ProjectActionPromote,
+		oldPosition,
+		newPosition,
+		username,
+		err == nil,
+		p.Error,
+		shortCommit,
+		description,
+		ipAddress,
+	)
+
+	stream.Global.Broadcast(stream.WsMessage{
+		Type:      "version_switched",
+		Timestamp: time.Now(),
+		Data: stream.VersionSwitchMessage{
+			ProjectName: target.Name,
+			Action:      "promote",
+			Target:      p.Ref,
+			Success:     err == nil,
+			Error:       p.Error,
+		},
+	})
+
+	if err != nil {
+		return err
+	}
+	if target.HealthCheck == nil {
+		runPostDeploy(target)
+	}
+	return nil
+}
+
+// promotionChain return the promotion and its ancestors, newest first
+func promotionChain(db *gorm.DB, p database.ProjectPromotion) []database.ProjectPromotion {
+	chain := []database.ProjectPromotion{p}
+	for p.ParentID != nil && len(chain) < maxPromotionChain {
+		var parent database.ProjectPromotion
+		if err := db.First(&parent, *p.ParentID).Error; err != nil {
+			break
+		}
+		chain = append(chain, parent)
+		p = parent
+	}
+	return chain
+}
+
+func currentUsername(c *gin.Context) string {
+	if username, ok := c.Get("username"); ok {
+		if s, ok := username.(string); ok {
+			return s
+		}
+	}
+	return "unknown"
+}
+
+// HandlePromoteProject promote the revision deployed in another project to this one
+func HandlePromoteProject(c *gin.Context) {
+	projectName := c.Param("name")
+
+	var req struct {
+		From string `json:"from" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if req.From == projectName {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodePromoteToItself, nil)
+		return
+	}
+
+	target := findEnabledProject(projectName)
+	if target == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	source := findEnabledProject(req.From)
+	if source == nil || !namespace.Allowed(c, source.Namespace) {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeSourceProjectNotFound, nil)
+		return
+	}
+	if !isGitProject(target) || !isGitProject(source) {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodePromotionRequiresGit, nil)
+		return
+	}
+	if !promotionAllowed(target, source.Name) {
+		i18n.JSONErrorf(c, http.StatusForbidden, i18n.CodePromotionNotAccepted, target.Name, source.Name)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDatabaseUnavailable, nil)
+		return
+	}
+
+	if lastErr := lastDeployError(source.Name); lastErr != "" {
+		i18n.JSONErrorf(c, http.StatusConflict, i18n.CodeSourceDeployFailed, source.Name, lastErr)
+		return
+	}
+	refType, ref, commit, err := resolveDeployedRevision(source.Path)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeResolveRevisionFailed, err)
+		return
+	}
+
+	promotionMu.Lock()
+	defer promotionMu.Unlock()
+
+	var pending int64
+	db.Model(&database.ProjectPromotion{}).Where("target_project = ? AND status = ?", target.Name, PromotionPending).Count(&pending)
+	if pending > 0 {
+		i18n.JSONErrorf(c, http.StatusConflict, i18n.CodePromotionPending, target.Name)
+		return
+	}
+
+	promotion := database.ProjectPromotion{
+		SourceProject: source.Name,
+		TargetProject: target.Name,
+		RefType:       refType,
+		Ref:           ref,
+		CommitHash:    commit,
+		Status:        PromotionPending,
+		RequestedBy:   currentUsername(c),
+	}
+	// link to the promotion that brought this revision into the source project
+	var parent database.ProjectPromotion
+	if err := db.Where("target_project = ? AND commit_hash = ? AND status = ?", source.Name, commit, PromotionSuccess).
+		Order("id DESC").First(&parent).Error; err == nil {
+		promotion.ParentID = &parent.ID
+	}
+	if err := db.Create(&promotion).Error; err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSavePromotionFailed, err)
+		return
+	}
+
+	if target.Promotion != nil && target.Promotion.RequireApproval {
+		stream.Global.Broadcast(stream.WsMessage{
+			Type:      "promotion_requested",
+			Timestamp: time.Now(),
+			Data: stream.PromotionRequestMessage{
+				ID:            promotion.ID,
+				SourceProject: promotion.SourceProject,
+				TargetProject: promotion.TargetProject,
+				Ref:           promotion.Ref,
+				RequestedBy:   promotion.RequestedBy,
+			},
+		})
+		c.JSON(http.StatusAccepted, gin.H{"message": i18n.Message(c, i18n.CodePromotionAwaitingApproval), "code": i18n.CodePromotionAwaitingApproval, "promotion": promotion})
+		return
+	}
+
+	if err := executePromotion(&promotion, target, promotion.RequestedBy, middleware.GetClientIP(c)); err != nil {
+		c.JSON(deployErrorStatus(err), gin.H{"error": i18n.Message(c, i18n.CodePromotionFailed) + ": " + err.Error(), "code": i18n.CodePromotionFailed, "promotion": promotion})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodePromoted), "code": i18n.CodePromoted, "promotion": promotion})
+}
+
+// HandleListPromotions list promotions into or out of a project
+func HandleListPromotions(c *gin.Context) {
+	projectName := c.Param("name")
+	db := database.GetDB()
+	if db == nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDatabaseUnavailable, nil)
+		return
+	}
+	var promotions []database.ProjectPromotion
+	if err := db.Where("target_project = ? OR source_project = ?", projectName, projectName).
+		Order("id DESC").Limit(100).Find(&promotions).Error; err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListPromotionsFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, promotions)
+}
+
+// loadPromotion load the promotion referenced by the :id path parameter
+func loadPromotion(c *gin.Context) (*gorm.DB, *database.ProjectPromotion, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidPromotionID, nil)
+		return nil, nil, false
+	}
+	db := database.GetDB()
+	if db == nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDatabaseUnavailable, nil)
+		return nil, nil, false
+	}
+	var promotion database.ProjectPromotion
+	if err := db.First(&promotion, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			i18n.JSONError(c, http.StatusNotFound, i18n.CodePromotionNotFound, nil)
+		} else {
+			i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadPromotionFailed, err)
+		}
+		return nil, nil, false
+	}
+	if !namespace.Allowed(c, namespace.Of(namespace.KindProject, promotion.TargetProject)) {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodePromotionNotFound, nil)
+		return nil, nil, false
+	}
+	return db, &promotion, true
+}
+
+// HandleGetPromotion return a promotion with its promotion chain
+func HandleGetPromotion(c *gin.Context) {
+	db, promotion, ok := loadPromotion(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"promotion": promotion, "chain": promotionChain(db, *promotion)})
+}
+
+// HandleApprovePromotion approve a pending promotion and switch the target project
+func HandleApprovePromotion(c *gin.Context) {
+	promotionMu.Lock()
+	defer promotionMu.Unlock()
+
+	_, promotion, ok := loadPromotion(c)
+	if !ok {
+		return
+	}
+	if promotion.Status != PromotionPending {
+		i18n.JSONErrorf(c, http.StatusConflict, i18n.CodePromotionNotPending, promotion.Status)
+		return
+	}
+	target := findEnabledProject(promotion.TargetProject)
+	if target == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+
+	username := currentUsername(c)
+	promotion.ApprovedBy = username
+	if err := executePromotion(promotion, target, username, middleware.GetClientIP(c)); err != nil {
+		c.JSON(deployErrorStatus(err), gin.H{"error": i18n.Message(c, i18n.CodePromotionFailed) + ": " + err.Error(), "code": i18n.CodePromotionFailed, "promotion": promotion})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodePromoted), "code": i18n.CodePromoted, "promotion": promotion})
+}
+
+// HandleRejectPromotion reject a pending promotion
+func HandleRejectPromotion(c *gin.Context) {
+	promotionMu.Lock()
+	defer promotionMu.Unlock()
+
+	db, promotion, ok := loadPromotion(c)
+	if !ok {
+		return
+	}
+	if promotion.Status != PromotionPending {
+		i18n.JSONErrorf(c, http.StatusConflict, i18n.CodePromotionNotPending, promotion.Status)
+		return
+	}
+	now := time.Now()
+	promotion.Status = PromotionRejected
+	promotion.ApprovedBy = currentUsername(c)
+	promotion.FinishedAt = &now
+	if err := db.Save(promotion).Error; err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSavePromotionFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodePromotionRejected), "code": i18n.CodePromotionRejected, "promotion": promotion})
+}
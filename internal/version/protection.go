@@ -0,0 +1,91 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// operations refused on protected branches and tags
+const (
+	protectDelete      = "delete"
+	protectForceSwitch = "force switch"
+)
+
+// ProtectionError an operation on a protected branch or tag was refused
+type ProtectionError struct {
+	Kind    string // branch | tag
+	Name    string
+	Pattern string
+	Op      string
+}
+
+func (e *ProtectionError) Error() string {
+	return fmt.Sprintf("%s %s is protected by pattern %q, %s is not allowed", e.Kind, e.Name, e.Pattern, e.Op)
+}
+
+// checkProtection return a ProtectionError when op is not allowed on the branch or tag.
+// Without a protection config only the default branches are protected, and only from deletion,
+// so force deploys of existing projects keep working.
+func checkProtection(project *types.ProjectConfig, kind, name, op string) error {
+	var patterns []string
+	switch cfg := project.Protection; {
+	case cfg == nil && kind == "branch" && op == protectDelete:
+		patterns = types.DefaultProtectedBranches
+	case cfg == nil:
+		return nil
+	case kind == "branch":
+		patterns = cfg.Branches
+	default:
+		patterns = cfg.Tags
+	}
+
+	// remote branches such as origin/release are protected by the local name
+	name = strings.TrimPrefix(name, "origin/")
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return &ProtectionError{Kind: kind, Name: name, Pattern: pattern, Op: op}
+		}
+	}
+	return nil
+}
+
+// recordPolicyViolation record a refused operation in the project activity
+func recordPolicyViolation(projectName string, err error, username, ipAddress string) {
+	var pe *ProtectionError
+	target := ""
+	if errors.As(err, &pe) {
+		target = pe.Kind + ":" + pe.Name
+	}
+	database.LogProjectAction(
+		projectName,                           // projectName
+		database.ProjectActionPolicyViolation, // action
+		target,                                // oldValue
+		"",                                    // newValue
+		username,                              // username
+		false,                                 // success
+		err.Error(),                           // error
+		"",                                    // commitHash
+		"Policy violation: "+err.Error(),      // description
+		ipAddress,                             // ipAddress
+	)
+}
+
+// enforceProtection refuse op on a protected branch or tag with 403, recording the violation
+func enforceProtection(c *gin.Context, project *types.ProjectConfig, kind, name, op string) bool {
+	err := checkProtection(project, kind, name, op)
+	if err == nil {
+		return true
+	}
+	recordPolicyViolation(project.Name, err, currentUsername(c), middleware.GetClientIP(c))
+	i18n.JSONError(c, http.StatusForbidden, i18n.CodeRefProtected, err)
+	return false
+}
@@ -0,0 +1,472 @@
+package version
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/config"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/urls"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+// providerHTTPClient client of the GitHub, GitLab and Gitea APIs
+var providerHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// errProviderHookNotFound the registered webhook no longer exists on the provider
+var errProviderHookNotFound = errors.New("webhook not found on the provider")
+
+// providerWebhook webhook of a repository as reported by the provider
+type providerWebhook struct {
+	ID     int64    `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Active bool     `json:"active"`
+}
+
+// parseRemoteURL host and repository path of a git remote, for https://host/owner/repo.git,
+// ssh://git@host:22/owner/repo.git and git@host:owner/repo.git
+func parseRemoteURL(remote string) (string, string, error) {
+	remote = strings.TrimSpace(remote)
+	var host, repoPath string
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid remote URL: %v", err)
+		}
+		host, repoPath = u.Hostname(), u.Path
+	} else if at := strings.Index(remote, "@"); at >= 0 && strings.Contains(remote[at:], ":") {
+		rest := remote[at+1:]
+		colon := strings.Index(rest, ":")
+		host, repoPath = rest[:colon], rest[colon+1:]
+	}
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	if host == "" || strings.Count(repoPath, "/") < 1 {
+		return "", "", fmt.Errorf("cannot derive the repository from remote %q", remote)
+	}
+	return host, repoPath, nil
+}
+
+// defaultProviderAPI API URL of a provider on host
+func defaultProviderAPI(providerType, host string) string {
+	switch providerType {
+	case types.ProviderGitHub:
+		if host == "github.com" {
+			return "https://api.github.com"
+		}
+		return "https://" + host + "/api/v3" // GitHub Enterprise Server
+	case types.ProviderGitLab:
+		return "https://" + host + "/api/v4"
+	default:
+		return "https://" + host + "/api/v1"
+	}
+}
+
+// resolveProvider fill the repository and API URL of cfg from the remote of the project
+func resolveProvider(project *types.ProjectConfig, cfg *types.ProjectProviderConfig) error {
+	if cfg.Repo != "" && cfg.APIURL != "" {
+		return nil
+	}
+	remote, err := getRemote(project.Path)
+	if err != nil || remote == "" {
+		return fmt.Errorf("project has no origin remote, set repo and apiUrl")
+	}
+	host, repoPath, err := parseRemoteURL(remote)
+	if err != nil {
+		return err
+	}
+	if cfg.Repo == "" {
+		cfg.Repo = repoPath
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = defaultProviderAPI(cfg.Type, host)
+	}
+	return nil
+}
+
+// providerEvents events the webhook is registered for
+func providerEvents(cfg *types.ProjectProviderConfig) []string {
+	if len(cfg.Events) > 0 {
+		return cfg.Events
+	}
+	if cfg.Type == types.ProviderGitLab {
+		return []string{"push", "tag_push"}
+	}
+	return []string{"push"}
+}
+
+// gitlabProjectPath API path of the GitLab project of the repository
+func gitlabProjectPath(cfg *types.ProjectProviderConfig) string {
+	return "/projects/" + url.PathEscape(strings.Trim(cfg.Repo, "/"))
+}
+
+// providerHooksPath API path of the webhooks of the repository
+func providerHooksPath(cfg *types.ProjectProviderConfig) string {
+	if cfg.Type == types.ProviderGitLab {
+		return gitlabProjectPath(cfg) + "/hooks"
+	}
+	return "/repos/" + strings.Trim(cfg.Repo, "/") + "/hooks"
+}
+
+// providerHookBody create and update request of the webhook delivering to hookURL
+func providerHookBody(cfg *types.ProjectProviderConfig, hookURL, secret string) map[string]interface{} {
+	events := providerEvents(cfg)
+	if cfg.Type == types.ProviderGitLab {
+		body := map[string]interface{}{"url": hookURL, "token": secret, "enable_ssl_verification": true}
+		for _, e := range events {
+			body[e+"_events"] = true
+		}
+		return body
+	}
+	body := map[string]interface{}{
+		"active": true,
+		"events": events,
+		"config": map[string]interface{}{"url": hookURL, "content_type": "json", "secret": secret},
+	}
+	if cfg.Type == types.ProviderGitHub {
+		body["name"] = "web"
+	} else {
+		body["type"] = "gitea"
+	}
+	return body
+}
+
+// providerRequest call the provider API, decoding the JSON answer into out
+func providerRequest(ctx context.Context, cfg *types.ProjectProviderConfig, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(cfg.APIURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	switch cfg.Type {
+	case types.ProviderGitHub:
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	case types.ProviderGitLab:
+		req.Header.Set("PRIVATE-TOKEN", cfg.Token)
+	default:
+		req.Header.Set("Authorization", "token "+cfg.Token)
+	}
+	resp, err := providerHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodGet {
+		return errProviderHookNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s answered %s: %s", method, path, cfg.Type, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// decodeProviderWebhook webhook of a GitHub, Gitea or GitLab API answer
+func decodeProviderWebhook(providerType string, raw json.RawMessage) (providerWebhook, error) {
+	var hook providerWebhook
+	if providerType == types.ProviderGitLab {
+		// GitLab has one <event>_events flag per event and no active flag
+		var fields map[string]interface{}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return hook, err
+		}
+		if id, ok := fields["id"].(float64); ok {
+			hook.ID = int64(id)
+		}
+		hook.URL, _ = fields["url"].(string)
+		hook.Active = true
+		for name, value := range fields {
+			if on, ok := value.(bool); ok && on && strings.HasSuffix(name, "_events") {
+				hook.Events = append(hook.Events, strings.TrimSuffix(name, "_events"))
+			}
+		}
+		return hook, nil
+	}
+	var h struct {
+		ID     int64    `json:"id"`
+		Active bool     `json:"active"`
+		Events []string `json:"events"`
+		Config struct {
+			URL string `json:"url"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(raw, &h); err != nil {
+		return hook, err
+	}
+	return providerWebhook{ID: h.ID, URL: h.Config.URL, Events: h.Events, Active: h.Active}, nil
+}
+
+// findProviderWebhook the registered webhook of the project: the one with the stored id or,
+// when there is none, the webhook already delivering to the GitHook URL
+func findProviderWebhook(ctx context.Context, cfg *types.ProjectProviderConfig) (*providerWebhook, error) {
+	if cfg.HookID != 0 {
+		var raw json.RawMessage
+		err := providerRequest(ctx, cfg, http.MethodGet, providerHooksPath(cfg)+"/"+strconv.FormatInt(cfg.HookID, 10), nil, &raw)
+		if err == nil {
+			hook, err := decodeProviderWebhook(cfg.Type, raw)
+			return &hook, err
+		}
+		if !errors.Is(err, errProviderHookNotFound) {
+			return nil, err
+		}
+	}
+	var list []json.RawMessage
+	if err := providerRequest(ctx, cfg, http.MethodGet, providerHooksPath(cfg)+"?per_page=100", nil, &list); err != nil {
+		return nil, err
+	}
+	for _, raw := range list {
+		hook, err := decodeProviderWebhook(cfg.Type, raw)
+		if err == nil && hook.URL == cfg.URL {
+			return &hook, nil
+		}
+	}
+	return nil, nil
+}
+
+// providerWebhookProblems differences between the webhook on the provider and the registration
+func providerWebhookProblems(cfg *types.ProjectProviderConfig, hook *providerWebhook) []string {
+	if hook == nil {
+		return []string{"webhook is not registered on the provider"}
+	}
+	problems := []string{}
+	if hook.URL != cfg.URL {
+		problems = append(problems, fmt.Sprintf("webhook delivers to %s instead of %s", hook.URL, cfg.URL))
+	}
+	if !hook.Active {
+		problems = append(problems, "webhook is inactive")
+	}
+	have := map[string]bool{}
+	for _, e := range hook.Events {
+		have[e] = true
+	}
+	for _, e := range providerEvents(cfg) {
+		if !have[e] && !have["*"] {
+			problems = append(problems, "webhook is not subscribed to "+e+" events")
+		}
+	}
+	return problems
+}
+
+// registerProviderWebhook create the webhook of the project on the provider, or update the
+// registered one so its URL, secret and events match again. Returns whether it was created.
+func registerProviderWebhook(ctx context.Context, cfg *types.ProjectProviderConfig, secret string) (bool, error) {
+	existing, err := findProviderWebhook(ctx, cfg)
+	if err != nil {
+		return false, err
+	}
+	body := providerHookBody(cfg, cfg.URL, secret)
+	var raw json.RawMessage
+	if existing != nil {
+		method := http.MethodPatch
+		if cfg.Type == types.ProviderGitLab {
+			method = http.MethodPut
+		}
+		if err := providerRequest(ctx, cfg, method, providerHooksPath(cfg)+"/"+strconv.FormatInt(existing.ID, 10), body, &raw); err != nil {
+			return false, err
+		}
+		cfg.HookID = existing.ID
+		return false, nil
+	}
+	if err := providerRequest(ctx, cfg, http.MethodPost, providerHooksPath(cfg), body, &raw); err != nil {
+		return false, err
+	}
+	hook, err := decodeProviderWebhook(cfg.Type, raw)
+	if err != nil {
+		return true, err
+	}
+	cfg.HookID = hook.ID
+	return true, nil
+}
+
+// gitHookURL public URL of the GitHook of a project, as seen by the request
+func gitHookURL(c *gin.Context, projectName string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + urls.Current().BasePath + "/githook/" + url.PathEscape(projectName)
+}
+
+// HandleGetProviderWebhook verify the webhook of the project on its provider still delivers
+// to the GitHook with the configured events
+func HandleGetProviderWebhook(c *gin.Context) {
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	if project.Provider == nil || project.Provider.HookID == 0 {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProviderNotRegistered, nil)
+		return
+	}
+	cfg := *project.Provider
+	if err := resolveProvider(project, &cfg); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProvider, err)
+		return
+	}
+	hook, err := findProviderWebhook(c.Request.Context(), &cfg)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadGateway, i18n.CodeQueryProviderFailed, err)
+		return
+	}
+	problems := providerWebhookProblems(&cfg, hook)
+	c.JSON(http.StatusOK, gin.H{
+		"provider": cfg.Redacted(),
+		"webhook":  hook,
+		"ok":       len(problems) == 0,
+		"problems": problems,
+	})
+}
+
+// HandleRegisterProviderWebhook create or repair the webhook of the project on GitHub, GitLab
+// or Gitea, {"type": "github", "token": "..."}; stored settings fill the omitted fields
+func HandleRegisterProviderWebhook(c *gin.Context) {
+	var req types.ProjectProviderConfig
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+			return
+		}
+	}
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	if !project.Enhook {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeGitHookDisabled, nil)
+		return
+	}
+
+	// the token is never returned, omitted fields keep the stored settings
+	cfg := types.ProjectProviderConfig{}
+	if project.Provider != nil {
+		cfg = *project.Provider
+	}
+	if req.Type != "" && req.Type != cfg.Type {
+		cfg = types.ProjectProviderConfig{Type: req.Type}
+	}
+	if req.APIURL != "" {
+		cfg.APIURL = req.APIURL
+	}
+	if req.Repo != "" {
+		cfg.Repo = req.Repo
+	}
+	if req.Token != "" {
+		cfg.Token = req.Token
+	}
+	if req.URL != "" {
+		cfg.URL = req.URL
+	}
+	if len(req.Events) > 0 {
+		cfg.Events = req.Events
+	}
+	if cfg.URL == "" {
+		cfg.URL = gitHookURL(c, project.Name)
+	}
+	if err := cfg.Validate(); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProvider, err)
+		return
+	}
+	if cfg.Token == "" {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeProviderTokenRequired, nil)
+		return
+	}
+	stored := cfg
+	if err := resolveProvider(project, &cfg); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProvider, err)
+		return
+	}
+
+	// deliveries are signed, a project without hooksecret gets one
+	oldSecret, oldProvider := project.Hooksecret, project.Provider
+	secretGenerated := false
+	if project.Hooksecret == "" {
+		secret, err := webhook.GenerateSecret()
+		if err != nil {
+			i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeGenerateSecretFailed, err)
+			return
+		}
+		project.Hooksecret, secretGenerated = secret, true
+	}
+
+	username := currentUsername(c)
+	created, err := registerProviderWebhook(c.Request.Context(), &cfg, project.Hooksecret)
+	description := fmt.Sprintf("webhook of %s %s updated", cfg.Type, cfg.Repo)
+	if created {
+		description = fmt.Sprintf("webhook of %s %s created", cfg.Type, cfg.Repo)
+	}
+	if err == nil || cfg.HookID != 0 {
+		// a created webhook is kept even when its answer could not be read
+		stored.HookID = cfg.HookID
+		project.Provider = &stored
+		if saveErr := config.SaveVersionConfig(); saveErr != nil {
+			project.Hooksecret, project.Provider = oldSecret, oldProvider
+			if err == nil {
+				err = fmt.Errorf("save configuration failed: %v", saveErr)
+			}
+		}
+	} else {
+		project.Hooksecret = oldSecret
+	}
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	}
+	database.LogProjectAction(
+		project.Name,                          // projectName
+		database.ProjectActionProviderWebhook, // action
+		"",                                    // oldValue
+		cfg.URL,                               // newValue
+		username,                              // username
+		err == nil,                            // success
+		errMsg,                                // error
+		"",                                    // commitHash
+		description,                           // description
+		middleware.GetClientIP(c),             // ipAddress
+	)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadGateway, i18n.CodeRegisterProviderFailed, err)
+		return
+	}
+
+	code := i18n.CodeProviderWebhookUpdated
+	if created {
+		code = i18n.CodeProviderWebhookCreated
+	}
+	response := gin.H{
+		"message":  i18n.Message(c, code),
+		"code":     code,
+		"created":  created,
+		"provider": cfg.Redacted(),
+	}
+	if secretGenerated {
+		response["secretGenerated"] = true
+	}
+	c.JSON(http.StatusOK, response)
+}
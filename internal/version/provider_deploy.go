@@ -0,0 +1,136 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// providerDeployTimeout time a deployment status update may take, the deploy does not wait longer
+const providerDeployTimeout = 10 * time.Second
+
+// commitSHAPattern full commit id, payload fields holding anything else are not sent as sha
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// providerDeployment GitHook deploy reported to GitHub or GitLab, nil when the project does not
+// report deployments
+type providerDeployment struct {
+	cfg         types.ProjectProviderConfig
+	environment string
+	id          int64
+}
+
+// payloadCommit commit a push payload deploys: GitLab's checkout_sha, otherwise after
+func payloadCommit(payload map[string]interface{}) string {
+	for _, key := range []string{"checkout_sha", "after"} {
+		if sha, _ := payload[key].(string); commitSHAPattern.MatchString(sha) && strings.Trim(sha, "0") != "" {
+			return sha
+		}
+	}
+	return ""
+}
+
+// startProviderDeployment create the deployment of a GitHook deploy of ref on the provider
+// with status in progress. Provider errors are logged, they never fail the deploy.
+func startProviderDeployment(project *types.ProjectConfig, refType, ref string, payload map[string]interface{}) *providerDeployment {
+	if project.Provider == nil || !project.Provider.Deployments || project.Provider.Token == "" {
+		return nil
+	}
+	d := &providerDeployment{cfg: *project.Provider, environment: project.Provider.Environment}
+	if d.environment == "" {
+		d.environment = project.Name
+	}
+	if err := resolveProvider(project, &d.cfg); err != nil {
+		log.Printf("deployment of project %s not reported: %v", project.Name, err)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), providerDeployTimeout)
+	defer cancel()
+	sha := payloadCommit(payload)
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	var err error
+	switch d.cfg.Type {
+	case types.ProviderGitHub:
+		// GitHub resolves the branch or tag, required_contexts skips the commit status checks
+		err = providerRequest(ctx, &d.cfg, http.MethodPost, "/repos/"+strings.Trim(d.cfg.Repo, "/")+"/deployments", map[string]interface{}{
+			"ref":               ref,
+			"environment":       d.environment,
+			"description":       "gohook GitHook deploy of " + project.Name,
+			"auto_merge":        false,
+			"required_contexts": []string{},
+		}, &created)
+	case types.ProviderGitLab:
+		if sha == "" {
+			log.Printf("deployment of project %s not reported: the payload carries no commit", project.Name)
+			return nil
+		}
+		err = providerRequest(ctx, &d.cfg, http.MethodPost, gitlabProjectPath(&d.cfg)+"/deployments", map[string]interface{}{
+			"environment": d.environment,
+			"ref":         ref,
+			"sha":         sha,
+			"tag":         refType == "tag",
+			"status":      "running",
+		}, &created)
+	default:
+		return nil
+	}
+	if err != nil || created.ID == 0 {
+		log.Printf("creating deployment of project %s failed: %v", project.Name, err)
+		return nil
+	}
+	d.id = created.ID
+	if d.cfg.Type == types.ProviderGitHub {
+		d.setGitHubStatus(ctx, "in_progress", "deploying "+ref)
+	}
+	return d
+}
+
+// finish set the final status of the deployment, failure when err is set
+func (d *providerDeployment) finish(err error) {
+	if d == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), providerDeployTimeout)
+	defer cancel()
+	if d.cfg.Type == types.ProviderGitHub {
+		state, description := "success", "deployed"
+		if err != nil {
+			state, description = "failure", err.Error()
+		}
+		d.setGitHubStatus(ctx, state, description)
+		return
+	}
+	status := "success"
+	if err != nil {
+		status = "failed"
+	}
+	path := gitlabProjectPath(&d.cfg) + "/deployments/" + strconv.FormatInt(d.id, 10)
+	if err := providerRequest(ctx, &d.cfg, http.MethodPut, path, map[string]interface{}{"status": status}, nil); err != nil {
+		log.Printf("updating deployment %d on gitlab failed: %v", d.id, err)
+	}
+}
+
+// setGitHubStatus add a status to the GitHub deployment, descriptions are limited to 140 characters
+func (d *providerDeployment) setGitHubStatus(ctx context.Context, state, description string) {
+	if len(description) > 140 {
+		description = description[:137] + "..."
+	}
+	body := map[string]interface{}{"state": state, "description": description, "environment": d.environment}
+	if d.cfg.EnvironmentURL != "" {
+		body["environment_url"] = d.cfg.EnvironmentURL
+	}
+	path := fmt.Sprintf("/repos/%s/deployments/%d/statuses", strings.Trim(d.cfg.Repo, "/"), d.id)
+	if err := providerRequest(ctx, &d.cfg, http.MethodPost, path, body, nil); err != nil {
+		log.Printf("setting status %s of deployment %d on github failed: %v", state, d.id, err)
+	}
+}
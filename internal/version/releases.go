@@ -0,0 +1,443 @@
+package version
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// maxReleaseOutput bytes of build output kept in the project activity
+const maxReleaseOutput = 64 << 10
+
+// releaseLocks serialize release deploys and activations per release directory
+var releaseLocks sync.Map
+
+// Release release directory of a project
+type Release struct {
+	ID        string    `json:"id"`
+	Commit    string    `json:"commit"`
+	CreatedAt time.Time `json:"createdAt"`
+	Active    bool      `json:"active"` // the current symlink points at it
+	Path      string    `json:"path"`
+}
+
+func releaseLock(cfg *types.ProjectReleaseConfig) *sync.Mutex {
+	lock, _ := releaseLocks.LoadOrStore(filepath.Clean(cfg.Dir), &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// activeRelease id of the release the current symlink points at, empty when there is none
+func activeRelease(cfg *types.ProjectReleaseConfig) string {
+	target, err := os.Readlink(filepath.Join(cfg.Dir, "current"))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// listReleases releases of a project, newest first
+func listReleases(cfg *types.ProjectReleaseConfig) ([]Release, error) {
+	entries, err := os.ReadDir(filepath.Join(cfg.Dir, "releases"))
+	if errors.Is(err, os.ErrNotExist) {
+		return []Release{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	active := activeRelease(cfg)
+	releases := []Release{}
+	for _, e := range entries {
+		// release ids share the <date>-<time>-<short commit> form of snapshot ids
+		if !e.IsDir() || !snapshotIDPattern.MatchString(e.Name()) {
+			continue
+		}
+		path := filepath.Join(cfg.Dir, "releases", e.Name())
+		r := Release{ID: e.Name(), Active: e.Name() == active, Path: path}
+		r.CreatedAt, _ = time.ParseInLocation("20060102-150405", e.Name()[:15], time.Local)
+		// REVISION is written once when the release is created, its mtime orders releases
+		// created within the same second
+		if info, err := os.Stat(filepath.Join(path, "REVISION")); err == nil {
+			r.CreatedAt = info.ModTime()
+		}
+		if revision, err := os.ReadFile(filepath.Join(path, "REVISION")); err == nil {
+			r.Commit = strings.TrimSpace(string(revision))
+		}
+		releases = append(releases, r)
+	}
+	sort.Slice(releases, func(i, j int) bool { return releases[i].CreatedAt.After(releases[j].CreatedAt) })
+	return releases, nil
+}
+
+// exportCommit write the tree of commit into dest with git archive, without the .git directory
+func exportCommit(repoPath, commit, dest string) error {
+	archive, err := os.CreateTemp(filepath.Dir(dest), ".archive-*.tar")
+	if err != nil {
+		return err
+	}
+	archive.Close()
+	defer os.Remove(archive.Name())
+	if output, err := execGitCommand(repoPath, "archive", "--format=tar", "-o", archive.Name(), commit); err != nil {
+		return fmt.Errorf("git archive failed: %s", strings.TrimSpace(string(output)))
+	}
+	f, err := os.Open(archive.Name())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return extractTar(f, dest, 0)
+}
+
+// archiveTarget path below dest of an archive entry without its first strip components, ok is
+// false for entries stripped entirely
+func archiveTarget(dest, name string, strip int) (string, bool, error) {
+	parts := strings.Split(strings.Trim(filepath.ToSlash(name), "/"), "/")
+	if len(parts) <= strip {
+		return "", false, nil
+	}
+	target := filepath.Join(dest, filepath.FromSlash(strings.Join(parts[strip:], "/")))
+	if target != dest && !strings.HasPrefix(target, dest+string(filepath.Separator)) {
+		return "", false, fmt.Errorf("archive entry %s escapes %s", name, dest)
+	}
+	return target, true, nil
+}
+
+// extractTar unpack the directories, files and symlinks of a tar stream below dest, without the
+// first strip components of their paths
+func extractTar(r io.Reader, dest string, strip int) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target, ok, err := archiveTarget(dest, hdr.Name, strip)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := prepareArchiveTarget(dest, target); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeArchiveFile(dest, target, os.FileMode(hdr.Mode), tr); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := prepareArchiveTarget(dest, target); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// prepareArchiveTarget create the parent of an archive entry and remove what the entry replaces.
+// The parent must resolve inside dest, so symlinks of earlier entries or of the existing tree
+// cannot redirect writes outside of it.
+func prepareArchiveTarget(dest, target string) error {
+	if target == dest {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	realDest, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return err
+	}
+	realParent, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	if realParent != realDest && !strings.HasPrefix(realParent, realDest+string(filepath.Separator)) {
+		return fmt.Errorf("archive entry %s resolves outside of %s", target, dest)
+	}
+	if info, err := os.Lstat(target); err == nil && !info.IsDir() {
+		return os.Remove(target)
+	}
+	return nil
+}
+
+// writeArchiveFile write a regular file of an archive
+func writeArchiveFile(dest, target string, mode os.FileMode, r io.Reader) error {
+	if err := prepareArchiveTarget(dest, target); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode&0o777)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// linkShared replace the shared paths of a release with symlinks into Dir/shared
+func linkShared(cfg *types.ProjectReleaseConfig, releaseDir string) error {
+	for _, p := range cfg.Shared {
+		source := filepath.Join(cfg.Dir, "shared", p)
+		if _, err := os.Stat(source); err != nil {
+			return fmt.Errorf("shared path %s: %v", p, err)
+		}
+		target := filepath.Join(releaseDir, p)
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := os.Symlink(source, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runReleaseStep run a build step in the release directory, the output goes to output
+func runReleaseStep(step types.ProjectReleaseStep, project *types.ProjectConfig, releaseDir, ref, commit string, output *bytes.Buffer) error {
+	timeout, err := step.RunTimeout()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(output, "$ %s\n", strings.Join(step.Command, " "))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, step.Command[0], step.Command[1:]...)
+	cmd.Dir = releaseDir
+	cmd.Env = append(os.Environ(),
+		"GOHOOK_PROJECT="+project.Name,
+		"GOHOOK_PROJECT_PATH="+project.Path,
+		"GOHOOK_RELEASE_DIR="+releaseDir,
+		"GOHOOK_REF="+ref,
+		"GOHOOK_COMMIT="+commit,
+	)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("build step %s failed: %v", step.Command[0], err)
+	}
+	return nil
+}
+
+// activateRelease point the current symlink at a release, the rename over the old link is atomic
+func activateRelease(cfg *types.ProjectReleaseConfig, id string) error {
+	tmp := filepath.Join(cfg.Dir, ".current-"+id)
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Join("releases", id), tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(cfg.Dir, "current")); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("switch current symlink failed: %v", err)
+	}
+	return nil
+}
+
+// pruneReleases remove the oldest releases beyond the configured count, never the active one
+func pruneReleases(cfg *types.ProjectReleaseConfig) {
+	releases, err := listReleases(cfg)
+	if err != nil {
+		return
+	}
+	kept := 0
+	for _, r := range releases {
+		if r.Active || kept < cfg.KeepCount() {
+			kept++
+			continue
+		}
+		if err := os.RemoveAll(r.Path); err != nil {
+			log.Printf("remove release failed: path=%s, error=%v", r.Path, err)
+		}
+	}
+}
+
+// deployRelease build a release of the commit checked out in the project repository and make it
+// current. Build output and errors are recorded as a RELEASE activity; a failed build removes
+// the release and leaves the current one in place.
+func deployRelease(project *types.ProjectConfig, ref, username, ipAddress string) error {
+	cfg := project.Releases
+	lock := releaseLock(cfg)
+	lock.Lock()
+	defer lock.Unlock()
+
+	output, err := execGitCommand(project.Path, "rev-parse", "HEAD")
+	if err != nil {
+		return fmt.Errorf("resolve deployed commit failed: %s", strings.TrimSpace(string(output)))
+	}
+	commit := strings.TrimSpace(string(output))
+	id := time.Now().Format("20060102-150405") + "-" + commit[:7]
+	releaseDir := filepath.Join(cfg.Dir, "releases", id)
+	previous := activeRelease(cfg)
+
+	var buildOutput bytes.Buffer
+	created := false
+	err = func() error {
+		if err := os.MkdirAll(filepath.Join(cfg.Dir, "releases"), 0o755); err != nil {
+			return err
+		}
+		if err := os.Mkdir(releaseDir, 0o755); err != nil {
+			return err
+		}
+		created = true
+		if err := exportCommit(project.Path, commit, releaseDir); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(releaseDir, "REVISION"), []byte(commit+"\n"), 0o644); err != nil {
+			return err
+		}
+		if err := linkShared(cfg, releaseDir); err != nil {
+			return err
+		}
+		for _, step := range cfg.Build {
+			if err := runReleaseStep(step, project, releaseDir, ref, commit, &buildOutput); err != nil {
+				return err
+			}
+		}
+		return activateRelease(cfg, id)
+	}()
+
+	out := buildOutput.String()
+	if len(out) > maxReleaseOutput {
+		out = "...\n" + out[len(out)-maxReleaseOutput:]
+	}
+	activity := &database.ProjectActivity{
+		ProjectName: project.Name,
+		Action:      database.ProjectActionRelease,
+		OldValue:    previous,
+		NewValue:    id,
+		Username:    username,
+		Success:     err == nil,
+		CommitHash:  commit[:7],
+		Description: fmt.Sprintf("Release %s of %s activated", id, ref),
+		IPAddress:   ipAddress,
+		Output:      out,
+	}
+	if err != nil {
+		if created {
+			os.RemoveAll(releaseDir)
+		}
+		err = fmt.Errorf("release %s failed: %v", id, err)
+		activity.Error = err.Error()
+		activity.Description = fmt.Sprintf("Release %s of %s failed, %s stays current: %v", id, ref, previous, err)
+		log.Printf("release deploy failed: project=%s, ref=%s, error=%v", project.Name, ref, err)
+	}
+	database.LogProjectActivity(activity)
+	if err == nil {
+		pruneReleases(cfg)
+	}
+	return err
+}
+
+// releasesProject enabled project in release mode named by the :name parameter
+func releasesProject(c *gin.Context) *types.ProjectConfig {
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return nil
+	}
+	if project.Releases == nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeReleasesNotConfigured, nil)
+		return nil
+	}
+	return project
+}
+
+// HandleListReleases list the release directories of a project, newest first
+func HandleListReleases(c *gin.Context) {
+	project := releasesProject(c)
+	if project == nil {
+		return
+	}
+	releases, err := listReleases(project.Releases)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListReleasesFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, releases)
+}
+
+// HandleActivateRelease point the current symlink at an existing release, e.g. to roll back,
+// then restart the project service when it restarts on deploys
+func HandleActivateRelease(c *gin.Context) {
+	project := releasesProject(c)
+	if project == nil || !enforcePin(c, project) {
+		return
+	}
+	id := c.Param("id")
+	if !snapshotIDPattern.MatchString(id) {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidReleaseID, nil)
+		return
+	}
+	cfg := project.Releases
+	lock := releaseLock(cfg)
+	lock.Lock()
+	if _, err := os.Stat(filepath.Join(cfg.Dir, "releases", id)); err != nil {
+		lock.Unlock()
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeReleaseNotFound, nil)
+		return
+	}
+	previous := activeRelease(cfg)
+	err := activateRelease(cfg, id)
+	lock.Unlock()
+
+	errMsg := ""
+	description := fmt.Sprintf("Release %s activated, was %s", id, previous)
+	if err != nil {
+		errMsg = err.Error()
+		description = fmt.Sprintf("Activate release %s failed: %s", id, errMsg)
+	}
+	database.LogProjectAction(
+		project.Name,                  // projectName
+		database.ProjectActionRelease, // action
+		previous,                      // oldValue
+		id,                            // newValue
+		currentUsername(c),            // username
+		err == nil,                    // success
+		errMsg,                        // error
+		id[len(id)-7:],                // commitHash
+		description,                   // description
+		middleware.GetClientIP(c),     // ipAddress
+	)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeActivateReleaseFailed, err)
+		return
+	}
+	restartServiceAfterDeploy(project)
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeReleaseActivated), "code": i18n.CodeReleaseActivated, "id": id, "previous": previous})
+}
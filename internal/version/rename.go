@@ -0,0 +1,190 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/config"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/stream"
+	"github.com/mycoool/gohook/internal/syncnode"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+var (
+	// ErrProjectNameInUse the name is taken by a project or an alias of a renamed project
+	ErrProjectNameInUse = errors.New("project name already in use")
+	// ErrInvalidProjectName the name cannot be used in GitHook URLs
+	ErrInvalidProjectName = errors.New("invalid project name")
+)
+
+// resolveProject copy of the project with the given name or, for a renamed project, one of
+// its previous names. alias is the name that was resolved through an alias, empty otherwise.
+func resolveProject(name string) (*types.ProjectConfig, string) {
+	for _, proj := range types.GoHookVersionData.Projects {
+		if proj.Name == name {
+			return &proj, ""
+		}
+	}
+	for _, proj := range types.GoHookVersionData.Projects {
+		for _, alias := range proj.Aliases {
+			if alias == name {
+				return &proj, name
+			}
+		}
+	}
+	return nil, ""
+}
+
+// RenameProject change the name of a project, update the promotion sources of other projects
+// and move the database rows of the project to the new name. With keepAlias the old name keeps
+// resolving to the project so GitHooks configured with the old URL still arrive.
+func RenameProject(oldName, newName string, keepAlias bool) error {
+	newName = strings.TrimSpace(newName)
+	if newName == "" || strings.ContainsAny(newName, "/ \t\r\n") {
+		return fmt.Errorf("%w %q", ErrInvalidProjectName, newName)
+	}
+	projects := types.GoHookVersionData.Projects
+	index := -1
+	for i, proj := range projects {
+		if proj.Name == oldName {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return fmt.Errorf("project %s not found", oldName)
+	}
+	if newName == oldName {
+		return nil
+	}
+	// renaming back to a previous name takes that alias over
+	if owner, _ := resolveProject(newName); owner != nil && owner.Name != oldName {
+		return fmt.Errorf("%w: %s", ErrProjectNameInUse, newName)
+	}
+	if err := database.CheckProjectRename(newName); err != nil {
+		return err
+	}
+
+	renamed := make([]types.ProjectConfig, len(projects))
+	copy(renamed, projects)
+	for i := range renamed {
+		if i == index {
+			continue
+		}
+		if p := renamed[i].Promotion; p != nil && containsString(p.From, oldName) {
+			promotion := *p
+			promotion.From = replaceString(p.From, oldName, newName)
+			renamed[i].Promotion = &promotion
+		}
+	}
+	proj := &renamed[index]
+	aliases := make([]string, 0, len(proj.Aliases)+1)
+	for _, alias := range proj.Aliases {
+		if alias != newName && alias != oldName {
+			aliases = append(aliases, alias)
+		}
+	}
+	if keepAlias {
+		aliases = append(aliases, oldName)
+	}
+	if len(aliases) == 0 {
+		aliases = nil
+	}
+	proj.Name, proj.Aliases = newName, aliases
+
+	types.GoHookVersionData.Projects = renamed
+	if err := config.SaveVersionConfig(); err != nil {
+		types.GoHookVersionData.Projects = projects
+		return err
+	}
+	if err := database.RenameProjectReferences(oldName, newName); err != nil {
+		return fmt.Errorf("project renamed, but its database records still use the old name: %v", err)
+	}
+	syncnode.RefreshProjectWatchers()
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func replaceString(list []string, old, new string) []string {
+	out := make([]string, len(list))
+	for i, v := range list {
+		if v == old {
+			v = new
+		}
+		out[i] = v
+	}
+	return out
+}
+
+// HandleRenameProject rename a project, {"name": "new", "keepAlias": true}
+func HandleRenameProject(c *gin.Context) {
+	projectName := c.Param("name")
+	var req struct {
+		Name      string `json:"name" binding:"required"`
+		KeepAlias *bool  `json:"keepAlias"` // default true
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	keepAlias := req.KeepAlias == nil || *req.KeepAlias
+	req.Name = strings.TrimSpace(req.Name)
+
+	if proj, alias := resolveProject(projectName); proj == nil || alias != "" {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+
+	username := c.GetString("username")
+	details := map[string]interface{}{"oldName": projectName, "newName": req.Name, "keepAlias": keepAlias}
+	err := RenameProject(projectName, req.Name, keepAlias)
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	database.LogUserAction(username, database.UserActionRenameProject, "project:"+projectName,
+		"rename project: "+projectName+" -> "+req.Name, middleware.GetClientIP(c), c.Request.UserAgent(), err == nil, details)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrProjectNameInUse) {
+			status = http.StatusConflict
+		} else if errors.Is(err, ErrInvalidProjectName) {
+			status = http.StatusBadRequest
+		}
+		i18n.JSONError(c, status, i18n.CodeRenameProjectFailed, err)
+		return
+	}
+	database.LogProjectAction(req.Name, database.ProjectActionRename, projectName, req.Name, username, true, "", "",
+		"renamed from "+projectName, middleware.GetClientIP(c))
+
+	stream.Global.Broadcast(stream.WsMessage{
+		Type:      "project_managed",
+		Timestamp: time.Now(),
+		Data: stream.ProjectManageMessage{
+			Action:      "rename",
+			ProjectName: req.Name,
+			Success:     true,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeProjectRenamed),
+		"code":    i18n.CodeProjectRenamed,
+		"name":    req.Name,
+		"oldName": projectName,
+	})
+}
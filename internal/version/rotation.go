@@ -0,0 +1,148 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/config"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/stream"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/webhook"
+)
+
+// secretRetirementInterval how often expired previous secrets are looked for
+const secretRetirementInterval = time.Minute
+
+// verifyGitHookSecret verify a GitHook delivery with the hooksecret of the project or, during
+// the grace period of a rotation, with the previous one
+func verifyGitHookSecret(c *gin.Context, payloadBody []byte, project *types.ProjectConfig) error {
+	err := verifyWebhookSignature(c, payloadBody, project.Hooksecret)
+	if err != nil && project.HookRotation.Active(time.Now()) {
+		if verifyWebhookSignature(c, payloadBody, project.HookRotation.PreviousSecret) == nil {
+			log.Printf("GitHook of project %s verified with the previous secret, which expires at %s",
+				project.Name, project.HookRotation.Expires.Format(time.RFC3339))
+			return nil
+		}
+	}
+	return err
+}
+
+// HandleRotateGitHookSecret generate a new GitHook secret for a project,
+// {"gracePeriod": "24h"} keeps the previous secret valid meanwhile
+func HandleRotateGitHookSecret(c *gin.Context) {
+	var req struct {
+		GracePeriod string `json:"gracePeriod"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+			return
+		}
+	}
+	grace, err := webhook.ParseGracePeriod(req.GracePeriod)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeGenerateSecretFailed, err)
+		return
+	}
+
+	username := currentUsername(c)
+	oldSecret, oldRotation := project.Hooksecret, project.HookRotation
+	project.Hooksecret = secret
+	project.HookRotation = webhook.NewSecretRotation(oldSecret, username, grace)
+	if err := config.SaveVersionConfig(); err != nil {
+		project.Hooksecret, project.HookRotation = oldSecret, oldRotation
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
+		return
+	}
+
+	description := "GitHook secret rotated, the previous secret was retired"
+	msg := stream.SecretRotationMessage{Kind: namespace.KindProject, Name: project.Name, Action: webhook.SecretRotated, By: username}
+	response := gin.H{"message": i18n.Message(c, i18n.CodeGitHookSecretRotated), "code": i18n.CodeGitHookSecretRotated, "secret": secret}
+	if project.HookRotation != nil {
+		description = fmt.Sprintf("GitHook secret rotated, the previous secret is accepted until %s", project.HookRotation.Expires.Format(time.RFC3339))
+		msg.Expires = project.HookRotation.Expires
+		response["previousSecretExpires"] = project.HookRotation.Expires
+	}
+	database.LogProjectAction(
+		project.Name,                       // projectName
+		database.ProjectActionRotateSecret, // action
+		"",                                 // oldValue
+		"",                                 // newValue
+		username,                           // username
+		true,                               // success
+		"",                                 // error
+		"",                                 // commitHash
+		description,                        // description
+		middleware.GetClientIP(c),          // ipAddress
+	)
+	stream.Global.Broadcast(stream.WsMessage{Type: "secret_rotation", Timestamp: time.Now(), Data: msg})
+	c.JSON(http.StatusOK, response)
+}
+
+// RetireGitHookSecrets drop the previous GitHook secrets whose grace period ended before now
+func RetireGitHookSecrets(now time.Time) {
+	if types.GoHookVersionData == nil {
+		return
+	}
+	retired := map[string]*types.SecretRotation{}
+	for i := range types.GoHookVersionData.Projects {
+		project := &types.GoHookVersionData.Projects[i]
+		if project.HookRotation != nil && !project.HookRotation.Active(now) {
+			retired[project.Name] = project.HookRotation
+			project.HookRotation = nil
+		}
+	}
+	if len(retired) == 0 {
+		return
+	}
+	if err := config.SaveVersionConfig(); err != nil {
+		for i := range types.GoHookVersionData.Projects {
+			if rotation, ok := retired[types.GoHookVersionData.Projects[i].Name]; ok {
+				types.GoHookVersionData.Projects[i].HookRotation = rotation
+			}
+		}
+		log.Printf("retiring previous GitHook secrets failed: %v", err)
+		return
+	}
+	for name, rotation := range retired {
+		webhook.LogSecretRetired(namespace.KindProject, name, rotation)
+	}
+}
+
+// ScheduleSecretRetirement retire the previous secrets of hooks and project GitHooks once their
+// grace period ended. Expired secrets are refused right away, this removes them from the
+// configuration and announces it.
+func ScheduleSecretRetirement(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(secretRetirementInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			now := time.Now()
+			webhook.RetireHookSecrets(now)
+			RetireGitHookSecrets(now)
+		}
+	}()
+}
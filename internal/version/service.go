@@ -0,0 +1,274 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/config"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// service manager types supported by project service definition
+const (
+	ServiceTypeSystemd = "systemd"
+	ServiceTypeCompose = "compose"
+	ServiceTypePM2     = "pm2"
+)
+
+// service actions
+const (
+	ServiceActionStatus  = "status"
+	ServiceActionRestart = "restart"
+	ServiceActionReload  = "reload"
+	ServiceActionStart   = "start"
+	ServiceActionStop    = "stop"
+)
+
+// serviceCommandTimeout max time a service command may run
+const serviceCommandTimeout = 2 * time.Minute
+
+// validateServiceConfig validate project service definition
+func validateServiceConfig(svc *types.ProjectServiceConfig) error {
+	switch svc.Type {
+	case ServiceTypeSystemd, ServiceTypePM2:
+		if strings.TrimSpace(svc.Name) == "" {
+			return fmt.Errorf("service name is required for %s", svc.Type)
+		}
+	case ServiceTypeCompose:
+	default:
+		return fmt.Errorf("unsupported service type: %s", svc.Type)
+	}
+	if strings.HasPrefix(svc.Name, "-") {
+		return fmt.Errorf("invalid service name: %s", svc.Name)
+	}
+	return nil
+}
+
+// buildServiceCommand build command line for the given service action
+func buildServiceCommand(svc *types.ProjectServiceConfig, action string) ([]string, error) {
+	switch svc.Type {
+	case ServiceTypeSystemd:
+		switch action {
+		case ServiceActionStatus:
+			return []string{"systemctl", "status", "--no-pager", svc.Name}, nil
+		case ServiceActionRestart, ServiceActionReload, ServiceActionStart, ServiceActionStop:
+			return []string{"systemctl", action, svc.Name}, nil
+		}
+	case ServiceTypeCompose:
+		args := []string{"docker", "compose"}
+		if svc.Name != "" {
+			args = append(args, "-p", svc.Name)
+		}
+		if svc.ComposeFile != "" {
+			args = append(args, "-f", svc.ComposeFile)
+		}
+		switch action {
+		case ServiceActionStatus:
+			return append(args, "ps"), nil
+		case ServiceActionRestart, ServiceActionStop:
+			return append(args, action), nil
+		case ServiceActionReload, ServiceActionStart:
+			// recreate containers whose configuration or image changed
+			return append(args, "up", "-d"), nil
+		}
+	case ServiceTypePM2:
+		switch action {
+		case ServiceActionStatus:
+			return []string{"pm2", "describe", svc.Name}, nil
+		case ServiceActionRestart, ServiceActionReload, ServiceActionStart, ServiceActionStop:
+			return []string{"pm2", action, svc.Name}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported service type: %s", svc.Type)
+	}
+	return nil, fmt.Errorf("unsupported service action: %s", action)
+}
+
+// runServiceAction execute a service action for the project and return combined output
+func runServiceAction(project *types.ProjectConfig, action string) (string, error) {
+	if project.Service == nil {
+		return "", fmt.Errorf("project has no service configured")
+	}
+
+	args, err := buildServiceCommand(project.Service, action)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), serviceCommandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = project.Path
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("service command timed out after %s", serviceCommandTimeout)
+	}
+	return string(output), err
+}
+
+// logServiceAction record service action result to project activity log
+func logServiceAction(projectName, action, username, output string, err error, ipAddress string) {
+	errMsg := ""
+	description := fmt.Sprintf("Service %s succeeded", action)
+	if err != nil {
+		errMsg = err.Error()
+		description = fmt.Sprintf("Service %s failed: %s", action, errMsg)
+	}
+	if output != "" {
+		description += "\n" + output
+	}
+
+	database.LogProjectAction(
+		projectName,
+		database.ProjectActionService,
+		"",
+		action,
+		username,
+		err == nil,
+		errMsg,
+		"",
+		description,
+		ipAddress,
+	)
+}
+
+// restartServiceAfterDeploy restart project service if configured to do so after deploy
+func restartServiceAfterDeploy(project *types.ProjectConfig) {
+	if project.Service == nil || !project.Service.RestartOnDeploy {
+		return
+	}
+
+	action := project.Service.DeployAction
+	if action == "" {
+		action = ServiceActionRestart
+	}
+
+	output, err := runServiceAction(project, action)
+	if err != nil {
+		log.Printf("service %s after deploy failed: project=%s, error=%v", action, project.Name, err)
+	}
+	logServiceAction(project.Name, action, "GitHook", output, err, "")
+}
+
+// HandleGetService get project service definition and current status
+func HandleGetService(c *gin.Context) {
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+
+	if project.Service == nil {
+		c.JSON(http.StatusOK, gin.H{"service": nil})
+		return
+	}
+
+	// status commands exit non-zero for stopped services, output is still meaningful
+	output, err := runServiceAction(project, ServiceActionStatus)
+	resp := gin.H{
+		"service": project.Service,
+		"running": err == nil,
+		"output":  output,
+	}
+	if err != nil {
+		resp["error"] = err.Error()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// HandleSaveService save project service definition, empty type removes it
+func HandleSaveService(c *gin.Context) {
+	var req types.ProjectServiceConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+
+	if req.Type == "" {
+		project.Service = nil
+	} else {
+		if err := validateServiceConfig(&req); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidServiceConfig, err)
+			return
+		}
+		if req.DeployAction != "" {
+			if _, err := buildServiceCommand(&req, req.DeployAction); err != nil {
+				i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidServiceConfig, err)
+				return
+			}
+		}
+		project.Service = &req
+	}
+
+	if err := config.SaveVersionConfig(); err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeServiceConfigSaved),
+		"code":    i18n.CodeServiceConfigSaved,
+		"service": project.Service,
+	})
+}
+
+// HandleServiceAction run restart/reload/start/stop/status on project service
+func HandleServiceAction(c *gin.Context) {
+	projectName := c.Param("name")
+	action := c.Param("action")
+
+	project := findEnabledProject(projectName)
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	if project.Service == nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeNoServiceConfigured, nil)
+		return
+	}
+	if _, err := buildServiceCommand(project.Service, action); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidServiceAction, err)
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr, _ := username.(string)
+
+	output, err := runServiceAction(project, action)
+	if action != ServiceActionStatus {
+		logServiceAction(projectName, action, usernameStr, output, err, middleware.GetClientIP(c))
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  i18n.Message(c, i18n.CodeServiceActionFailed) + ": " + err.Error(),
+			"code":   i18n.CodeServiceActionFailed,
+			"action": action,
+			"output": output,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeServiceActionExecuted, action),
+		"code":    i18n.CodeServiceActionExecuted,
+		"action":  action,
+		"output":  output,
+	})
+}
@@ -0,0 +1,98 @@
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// signing key in the output of git verify-commit/verify-tag: "using RSA key <fingerprint>" for
+// GPG, "with ED25519 key SHA256:..." for SSH
+var signingKeyPattern = regexp.MustCompile(`using \S+ key ([0-9A-Fa-f]{16,})|key (SHA256:\S+)`)
+
+// SignatureError the revision to deploy does not carry a trusted signature, nothing was changed
+type SignatureError struct {
+	Ref    string
+	Reason string
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("signature verification of %s failed: %s", e.Ref, e.Reason)
+}
+
+// signaturesRequired reports whether the project at projectPath only deploys signed revisions
+func signaturesRequired(projectPath string) bool {
+	project := findProjectByPath(projectPath)
+	return project != nil && project.Signatures != nil && project.Signatures.Required
+}
+
+// verifyDeploySignature check the signature of rev before the project at projectPath is switched
+// to it, when the project requires signed revisions. A tag passes with a valid signature on the
+// tag itself or on the commit it points to.
+func verifyDeploySignature(projectPath, refType, rev string) error {
+	if !signaturesRequired(projectPath) {
+		return nil
+	}
+	cfg := findProjectByPath(projectPath).Signatures
+
+	var reasons []string
+	if refType == "tag" {
+		// lightweight tags have no signature of their own
+		if output, err := execGitCommandOutput(projectPath, "cat-file", "-t", rev); err == nil && strings.TrimSpace(string(output)) == "tag" {
+			reason := verifySignedObject(projectPath, cfg, "verify-tag", rev)
+			if reason == "" {
+				return nil
+			}
+			reasons = append(reasons, "tag: "+reason)
+		} else {
+			reasons = append(reasons, "tag: lightweight tag, not signed")
+		}
+		rev += "^{commit}"
+	}
+
+	reason := verifySignedObject(projectPath, cfg, "verify-commit", rev)
+	if reason == "" {
+		return nil
+	}
+	if len(reasons) > 0 {
+		reason = "commit: " + reason
+	}
+	return &SignatureError{Ref: strings.TrimSuffix(rev, "^{commit}"), Reason: strings.Join(append(reasons, reason), "; ")}
+}
+
+// verifySignedObject run git verify-commit or verify-tag and return why the object is not
+// trusted, empty when it is
+func verifySignedObject(projectPath string, cfg *types.ProjectSignatureConfig, command, rev string) string {
+	args := []string{}
+	if cfg.AllowedSigners != "" {
+		args = append(args, "-c", "gpg.ssh.allowedSignersFile="+cfg.AllowedSigners)
+	}
+	output, err := execGitCommand(projectPath, append(args, command, rev)...)
+	text := strings.TrimSpace(string(output))
+	if err != nil {
+		// verify-commit prints nothing for an unsigned commit
+		if text == "" || strings.Contains(text, "no signature found") {
+			return "not signed"
+		}
+		return strings.Join(strings.Fields(text), " ")
+	}
+
+	if len(cfg.Keys) == 0 {
+		return ""
+	}
+	key := ""
+	if m := signingKeyPattern.FindStringSubmatch(text); m != nil {
+		key = m[1] + m[2]
+	}
+	if key == "" {
+		return "signing key not found in: " + strings.Join(strings.Fields(text), " ")
+	}
+	for _, allowed := range cfg.Keys {
+		if strings.EqualFold(strings.ReplaceAll(allowed, " ", ""), key) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("signed with key %s, which is not in the allowed keys", key)
+}
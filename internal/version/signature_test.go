@@ -0,0 +1,195 @@
+package version
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// signingFixture git repository with commits signed by SSH keys
+type signingFixture struct {
+	t              *testing.T
+	dir            string
+	allowedSigners string
+	keys           map[string]string // key name -> private key file
+	fingerprints   map[string]string // key name -> SHA256 fingerprint
+}
+
+func newSigningFixture(t *testing.T) *signingFixture {
+	t.Helper()
+	for _, tool := range []string{"git", "ssh-keygen"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "dev")
+	t.Setenv("GIT_AUTHOR_EMAIL", "dev@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "dev")
+	t.Setenv("GIT_COMMITTER_EMAIL", "dev@example.com")
+
+	f := &signingFixture{
+		t:              t,
+		dir:            filepath.Join(home, "origin"),
+		allowedSigners: filepath.Join(home, "allowed_signers"),
+		keys:           map[string]string{},
+		fingerprints:   map[string]string{},
+	}
+	var signers []string
+	for _, name := range []string{"trusted", "other"} {
+		key := filepath.Join(home, name)
+		f.run(home, "ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", name, "-f", key)
+		pub, err := os.ReadFile(key + ".pub")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.keys[name] = key
+		f.fingerprints[name] = strings.Fields(f.run(home, "ssh-keygen", "-l", "-f", key+".pub"))[1]
+		signers = append(signers, `dev@example.com namespaces="git" `+strings.TrimSpace(string(pub)))
+	}
+	if err := os.WriteFile(f.allowedSigners, []byte(strings.Join(signers, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f.run(home, "git", "init", "-q", "-b", "main", f.dir)
+	return f
+}
+
+func (f *signingFixture) run(dir, name string, args ...string) string {
+	f.t.Helper()
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		f.t.Fatalf("%s %v: %v: %s", name, args, err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// commit add a commit to the origin repository signed with the named key, unsigned when key is
+// empty, and return its SHA
+func (f *signingFixture) commit(key, message string) string {
+	f.t.Helper()
+	args := []string{"commit", "-q", "--allow-empty", "-m", message}
+	if key != "" {
+		args = append([]string{"-c", "gpg.format=ssh", "-c", "user.signingkey=" + f.keys[key]}, append(args, "-S")...)
+	}
+	f.run(f.dir, "git", args...)
+	return f.run(f.dir, "git", "rev-parse", "HEAD")
+}
+
+func TestVerifySignedObject(t *testing.T) {
+	f := newSigningFixture(t)
+	signed := f.commit("trusted", "signed")
+	other := f.commit("other", "signed by another key")
+	unsigned := f.commit("", "unsigned")
+
+	tests := []struct {
+		name       string
+		rev        string
+		keys       []string
+		wantReason string // substring of the reason, empty when the commit is trusted
+	}{
+		{"signed", signed, nil, ""},
+		{"signed with allowed key", signed, []string{f.fingerprints["trusted"]}, ""},
+		{"unsigned", unsigned, nil, "not signed"},
+		{"unsigned with allowed keys", unsigned, []string{f.fingerprints["trusted"]}, "not signed"},
+		{"key not in the allowed keys", other, []string{f.fingerprints["trusted"]}, "not in the allowed keys"},
+		{"any trusted key", other, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &types.ProjectSignatureConfig{Required: true, AllowedSigners: f.allowedSigners, Keys: tt.keys}
+			reason := verifySignedObject(f.dir, cfg, "verify-commit", tt.rev)
+			if tt.wantReason == "" && reason != "" {
+				t.Errorf("verifySignedObject() = %q, want trusted", reason)
+			}
+			if tt.wantReason != "" && !strings.Contains(reason, tt.wantReason) {
+				t.Errorf("verifySignedObject() = %q, want %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+// withProject register a project at path for the duration of the test
+func withProject(t *testing.T, project types.ProjectConfig) {
+	t.Helper()
+	saved := types.GoHookVersionData
+	types.GoHookVersionData = &types.VersionConfig{Projects: []types.ProjectConfig{project}}
+	t.Cleanup(func() { types.GoHookVersionData = saved })
+}
+
+func TestVerifyDeploySignature(t *testing.T) {
+	f := newSigningFixture(t)
+	signed := f.commit("trusted", "signed")
+	f.run(f.dir, "git", "tag", "v1-light", signed)
+	f.run(f.dir, "git", "-c", "gpg.format=ssh", "-c", "user.signingkey="+f.keys["other"], "tag", "-s", "-m", "v1", "v1-signed-other", signed)
+	other := f.commit("other", "signed by another key")
+	unsigned := f.commit("", "unsigned")
+	f.run(f.dir, "git", "tag", "v2-light", unsigned)
+
+	trusted := []string{f.fingerprints["trusted"]}
+	tests := []struct {
+		name       string
+		signatures *types.ProjectSignatureConfig
+		refType    string
+		rev        string
+		wantErr    bool
+	}{
+		{"not required", nil, "branch", unsigned, false},
+		{"signed commit", &types.ProjectSignatureConfig{Required: true, AllowedSigners: f.allowedSigners, Keys: trusted}, "branch", signed, false},
+		{"unsigned commit", &types.ProjectSignatureConfig{Required: true, AllowedSigners: f.allowedSigners}, "branch", unsigned, true},
+		{"key not in the allowed keys", &types.ProjectSignatureConfig{Required: true, AllowedSigners: f.allowedSigners, Keys: trusted}, "branch", other, true},
+		{"lightweight tag on a signed commit", &types.ProjectSignatureConfig{Required: true, AllowedSigners: f.allowedSigners, Keys: trusted}, "tag", "v1-light", false},
+		{"tag signed by another key on a signed commit", &types.ProjectSignatureConfig{Required: true, AllowedSigners: f.allowedSigners, Keys: trusted}, "tag", "v1-signed-other", false},
+		{"lightweight tag on an unsigned commit", &types.ProjectSignatureConfig{Required: true, AllowedSigners: f.allowedSigners}, "tag", "v2-light", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withProject(t, types.ProjectConfig{Name: "app", Path: f.dir, Signatures: tt.signatures})
+			err := verifyDeploySignature(f.dir, tt.refType, tt.rev)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyDeploySignature() = %v, want error %v", err, tt.wantErr)
+			}
+			if _, ok := err.(*SignatureError); err != nil && !ok {
+				t.Errorf("verifyDeploySignature() error %T, want *SignatureError", err)
+			}
+		})
+	}
+}
+
+func TestSwitchAndPullBranchDeploysVerifiedCommit(t *testing.T) {
+	f := newSigningFixture(t)
+	signed := f.commit("trusted", "signed")
+	clone := filepath.Join(filepath.Dir(f.dir), "clone")
+	f.run(filepath.Dir(f.dir), "git", "clone", "-q", f.dir, clone)
+	withProject(t, types.ProjectConfig{Name: "app", Path: clone, Signatures: &types.ProjectSignatureConfig{
+		Required: true, AllowedSigners: f.allowedSigners, Keys: []string{f.fingerprints["trusted"]},
+	}})
+
+	// an unsigned tip is refused, the work tree stays at the signed commit
+	f.commit("", "unsigned")
+	f.run(clone, "git", "fetch", "-q", "origin")
+	if err := switchAndPullBranch(clone, "main", false); err == nil {
+		t.Fatal("switchAndPullBranch() deployed an unsigned commit")
+	}
+	if head := f.run(clone, "git", "rev-parse", "HEAD"); head != signed {
+		t.Fatalf("HEAD = %s, want %s", head, signed)
+	}
+
+	// the fetched tip is deployed, not a commit pushed after the fetch
+	next := f.commit("trusted", "signed again")
+	f.run(clone, "git", "fetch", "-q", "origin")
+	f.commit("", "pushed after the fetch")
+	if err := switchAndPullBranch(clone, "main", false); err != nil {
+		t.Fatal(err)
+	}
+	if head := f.run(clone, "git", "rev-parse", "HEAD"); head != next {
+		t.Errorf("HEAD = %s, want %s", head, next)
+	}
+}
@@ -0,0 +1,220 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// snapshotRefPrefix git refs holding snapshots, outside refs/heads and refs/tags so they are
+// neither pushed nor fetched
+const snapshotRefPrefix = "refs/gohook/snapshots/"
+
+// snapshot ids are <date>-<time>-<short commit>, checked before they are used in a ref name
+var snapshotIDPattern = regexp.MustCompile(`^\d{8}-\d{6}-[0-9a-f]{7,40}$`)
+
+// Snapshot local changes of a project saved before a force deploy
+type Snapshot struct {
+	ID        string    `json:"id"`
+	Commit    string    `json:"commit"` // stash commit holding the changes
+	Base      string    `json:"base"`   // commit the changes were made on
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"createdAt"`
+	Files     []string  `json:"files"`
+}
+
+// findProjectByPath find the project deployed at projectPath
+func findProjectByPath(projectPath string) *types.ProjectConfig {
+	if types.GoHookVersionData == nil {
+		return nil
+	}
+	clean := filepath.Clean(projectPath)
+	for i := range types.GoHookVersionData.Projects {
+		if filepath.Clean(types.GoHookVersionData.Projects[i].Path) == clean {
+			return &types.GoHookVersionData.Projects[i]
+		}
+	}
+	return nil
+}
+
+// createSnapshot save the changes to tracked files with git stash create, which leaves the
+// working tree untouched, and keep the commit under a snapshot ref. Nothing is saved when
+// there are no changes.
+func createSnapshot(project *types.ProjectConfig, reason string) (*Snapshot, error) {
+	message := "gohook snapshot: " + reason
+	output, err := execGitCommand(project.Path, "-c", "user.name=gohook", "-c", "user.email=gohook@localhost",
+		"stash", "create", message)
+	if err != nil {
+		return nil, fmt.Errorf("git stash create failed: %s", strings.TrimSpace(string(output)))
+	}
+	commit := strings.TrimSpace(string(output))
+	if commit == "" {
+		return nil, nil
+	}
+
+	now := time.Now()
+	id := now.Format("20060102-150405") + "-" + commit[:7]
+	if output, err := execGitCommand(project.Path, "update-ref", snapshotRefPrefix+id, commit); err != nil {
+		return nil, fmt.Errorf("save snapshot ref failed: %s", strings.TrimSpace(string(output)))
+	}
+	log.Printf("snapshot %s of %s saved before %s", id, project.Name, reason)
+
+	keep := types.DefaultSnapshotKeep
+	if project.Snapshots != nil && project.Snapshots.Keep > 0 {
+		keep = project.Snapshots.Keep
+	}
+	pruneSnapshots(project.Path, keep)
+	return &Snapshot{ID: id, Commit: commit, Message: message, CreatedAt: now}, nil
+}
+
+// listSnapshots list the snapshots of a project, newest first
+func listSnapshots(projectPath string) ([]Snapshot, error) {
+	output, err := execGitCommandOutput(projectPath, "for-each-ref",
+		"--format=%(refname)%09%(objectname)%09%(parent)%09%(creatordate:unix)%09%(subject)", snapshotRefPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list snapshots failed: %s", strings.TrimSpace(string(output)))
+	}
+	snapshots := []Snapshot{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, "\t", 5)
+		if len(fields) < 5 {
+			continue
+		}
+		s := Snapshot{
+			ID:      strings.TrimPrefix(fields[0], snapshotRefPrefix),
+			Commit:  fields[1],
+			Message: fields[4],
+		}
+		if parents := strings.Fields(fields[2]); len(parents) > 0 {
+			s.Base = parents[0]
+		}
+		if unix, err := strconv.ParseInt(fields[3], 10, 64); err == nil {
+			s.CreatedAt = time.Unix(unix, 0)
+		}
+		s.Files = []string{}
+		if s.Base != "" {
+			if out, err := execGitCommandOutput(projectPath, "diff", "--name-only", s.Base, s.Commit); err == nil {
+				if names := strings.TrimSpace(string(out)); names != "" {
+					s.Files = strings.Split(names, "\n")
+				}
+			}
+		}
+		snapshots = append(snapshots, s)
+	}
+	// ids start with the time, so they sort by age
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].ID > snapshots[j].ID })
+	return snapshots, nil
+}
+
+// pruneSnapshots delete all but the newest keep snapshots
+func pruneSnapshots(projectPath string, keep int) {
+	snapshots, err := listSnapshots(projectPath)
+	if err != nil || len(snapshots) <= keep {
+		return
+	}
+	for _, s := range snapshots[keep:] {
+		if output, err := execGitCommand(projectPath, "update-ref", "-d", snapshotRefPrefix+s.ID); err != nil {
+			log.Printf("delete snapshot %s failed: %s", s.ID, strings.TrimSpace(string(output)))
+		}
+	}
+}
+
+// snapshotRef find the enabled project and the ref of the snapshot named in the request
+func snapshotRef(c *gin.Context) (*types.ProjectConfig, string, bool) {
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return nil, "", false
+	}
+	id := c.Param("id")
+	if !snapshotIDPattern.MatchString(id) {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidSnapshotID, nil)
+		return nil, "", false
+	}
+	ref := snapshotRefPrefix + id
+	if err := execGitCommandRun(project.Path, "rev-parse", "--verify", "--quiet", ref); err != nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeSnapshotNotFound, nil)
+		return nil, "", false
+	}
+	return project, ref, true
+}
+
+// HandleListSnapshots list the snapshots of a project
+func HandleListSnapshots(c *gin.Context) {
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	snapshots, err := listSnapshots(project.Path)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListSnapshotsFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, snapshots)
+}
+
+// HandleRestoreSnapshot apply a snapshot to the working tree with git stash apply, changes to
+// the same files in the working tree make the restore fail without touching anything
+func HandleRestoreSnapshot(c *gin.Context) {
+	project, ref, ok := snapshotRef(c)
+	if !ok {
+		return
+	}
+
+	id := strings.TrimPrefix(ref, snapshotRefPrefix)
+	output, err := execGitCommand(project.Path, "stash", "apply", ref)
+	errMsg := ""
+	if err != nil {
+		if errMsg = strings.TrimSpace(string(output)); errMsg == "" {
+			errMsg = err.Error()
+		}
+	}
+	description := fmt.Sprintf("Snapshot %s restored", id)
+	if errMsg != "" {
+		description = fmt.Sprintf("Restore snapshot %s failed: %s", id, errMsg)
+	}
+	database.LogProjectAction(
+		project.Name,                   // projectName
+		database.ProjectActionSnapshot, // action
+		"",                             // oldValue
+		id,                             // newValue
+		currentUsername(c),             // username
+		errMsg == "",                   // success
+		errMsg,                         // error
+		"",                             // commitHash
+		description,                    // description
+		middleware.GetClientIP(c),      // ipAddress
+	)
+	if errMsg != "" {
+		i18n.JSONError(c, http.StatusConflict, i18n.CodeRestoreSnapshotFailed, errors.New(errMsg))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeSnapshotRestored), "code": i18n.CodeSnapshotRestored, "id": id})
+}
+
+// HandleDeleteSnapshot delete a snapshot
+func HandleDeleteSnapshot(c *gin.Context) {
+	project, ref, ok := snapshotRef(c)
+	if !ok {
+		return
+	}
+	if output, err := execGitCommand(project.Path, "update-ref", "-d", ref); err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDeleteSnapshotFailed, errors.New(strings.TrimSpace(string(output))))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeSnapshotDeleted), "code": i18n.CodeSnapshotDeleted})
+}
@@ -0,0 +1,131 @@
+package version
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/locale"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// maxTimelineDepth limits page*page_size of a timeline, every source is read up to this depth
+const maxTimelineDepth = 1000
+
+// HandleGetTimeline history of a project: commits, deploys, GitHook deliveries, config edits
+// and other activity newest first. ?type=deploy,config restricts the entry types, page and
+// page_size paginate.
+func HandleGetTimeline(c *gin.Context) {
+	var project *types.ProjectConfig
+	if types.GoHookVersionData != nil {
+		for i := range types.GoHookVersionData.Projects {
+			if types.GoHookVersionData.Projects[i].Name == c.Param("name") {
+				project = &types.GoHookVersionData.Projects[i]
+				break
+			}
+		}
+	}
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	if page*pageSize > maxTimelineDepth {
+		i18n.JSONErrorf(c, http.StatusBadRequest, i18n.CodeTimelineTooDeep, maxTimelineDepth)
+		return
+	}
+
+	wanted := map[string]bool{}
+	if list := c.Query("type"); list != "" {
+		for _, t := range strings.Split(list, ",") {
+			t = strings.TrimSpace(t)
+			valid := false
+			for _, known := range database.TimelineTypes {
+				valid = valid || t == known
+			}
+			if !valid {
+				i18n.JSONErrorf(c, http.StatusBadRequest, i18n.CodeUnknownTimelineType, t)
+				return
+			}
+			wanted[t] = true
+		}
+	}
+
+	// one more entry than the page needs tells whether there is a next page
+	depth := page*pageSize + 1
+	stored, err := database.NewLogService().GetProjectTimeline(project.Name, wanted, depth)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadTimelineFailed, err)
+		return
+	}
+	var warnings []string
+	commits := []database.TimelineEntry{}
+	if len(wanted) == 0 || wanted[database.TimelineCommit] {
+		if commits, err = revisionTimeline(project, depth); err != nil {
+			warnings = append(warnings, "commits not listed: "+err.Error())
+		}
+	}
+
+	entries := database.MergeTimeline(commits, stored)
+	loc := locale.FromContext(c)
+	for i := range entries {
+		entries[i].Time = entries[i].Time.In(loc)
+	}
+	hasMore := len(entries) > page*pageSize
+	start := (page - 1) * pageSize
+	if start > len(entries) {
+		start = len(entries)
+	}
+	end := start + pageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+	response := gin.H{
+		"entries":   entries[start:end],
+		"page":      page,
+		"page_size": pageSize,
+		"has_more":  hasMore,
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// revisionTimeline newest limit revisions of the project as timeline entries
+func revisionTimeline(project *types.ProjectConfig, limit int) ([]database.TimelineEntry, error) {
+	entries := []database.TimelineEntry{}
+	if !project.Enabled {
+		return entries, nil
+	}
+	backend, err := vcsFor(project)
+	if err != nil {
+		return entries, err
+	}
+	revisions, err := backend.Log(project.Path, limit)
+	if err != nil {
+		return entries, err
+	}
+	for _, rev := range revisions {
+		at, err := locale.ParseVCSDate(rev.Date)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, database.TimelineEntry{
+			Type: database.TimelineCommit, Source: "vcs", Time: at,
+			Action: "COMMIT", Summary: rev.Message, Username: rev.Author, Commit: rev.ID,
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,189 @@
+package version
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/locale"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// version control systems a project can use, selected by the vcs field
+const (
+	VCSGit        = "git"
+	VCSSubversion = "svn"
+	VCSMercurial  = "hg"
+	VCSArtifact   = "artifact"
+)
+
+// VCS working copy operations a deploy needs. Git projects support everything, Subversion and
+// Mercurial projects support status, branch and revision switches, GitHook deploys and the log.
+// Artifact projects download the build named by the branch or tag instead of checking it out.
+type VCS interface {
+	// Status current branch, tag and last revision of the working copy
+	Status(projectPath string) (*types.VersionResponse, error)
+	// SwitchBranch check out the newest revision of branch, force discards local changes
+	SwitchBranch(projectPath, branch string, force bool) error
+	// SwitchRevision check out a tag or a revision, force discards local changes
+	SwitchRevision(projectPath, rev string, force bool) error
+	// Log the newest limit revisions of the working copy
+	Log(projectPath string, limit int) ([]Revision, error)
+}
+
+// Revision one entry of a project log
+type Revision struct {
+	ID      string `json:"id"`
+	Author  string `json:"author"`
+	Date    string `json:"date"`
+	Message string `json:"message"`
+}
+
+var vcsBackends = map[string]VCS{
+	VCSGit:        gitVCS{},
+	VCSSubversion: svnVCS{},
+	VCSMercurial:  hgVCS{},
+	VCSArtifact:   artifactVCS{},
+}
+
+// ValidateVCS check a vcs field, empty means git
+func ValidateVCS(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, ok := vcsBackends[name]; !ok {
+		return fmt.Errorf("unsupported vcs %q, use git, svn, hg or artifact", name)
+	}
+	return nil
+}
+
+// vcsFor return the backend of a project
+func vcsFor(project *types.ProjectConfig) (VCS, error) {
+	if project.VCS == "" {
+		return vcsBackends[VCSGit], nil
+	}
+	backend, ok := vcsBackends[project.VCS]
+	if !ok {
+		return nil, fmt.Errorf("project %s: unsupported vcs %q", project.Name, project.VCS)
+	}
+	return backend, nil
+}
+
+// isGitProject report whether the project uses git, the git-only features check this
+func isGitProject(project *types.ProjectConfig) bool {
+	return project.VCS == "" || project.VCS == VCSGit
+}
+
+// projectStatus status of the project working copy
+func projectStatus(project *types.ProjectConfig) (*types.VersionResponse, error) {
+	backend, err := vcsFor(project)
+	if err != nil {
+		return nil, err
+	}
+	status, err := backend.Status(project.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !isGitProject(project) {
+		status.VCS = project.VCS
+	}
+	return status, nil
+}
+
+// switchProjectBranch switch the project working copy to branch
+func switchProjectBranch(project *types.ProjectConfig, branch string, force bool) error {
+	backend, err := vcsFor(project)
+	if err != nil {
+		return err
+	}
+	return backend.SwitchBranch(project.Path, branch, force)
+}
+
+// switchProjectRevision switch the project working copy to a tag or revision
+func switchProjectRevision(project *types.ProjectConfig, rev string, force bool) error {
+	backend, err := vcsFor(project)
+	if err != nil {
+		return err
+	}
+	return backend.SwitchRevision(project.Path, rev, force)
+}
+
+// execVCSCommand run a svn or hg command in the project working copy
+func execVCSCommand(projectPath, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = projectPath
+	// plain, untranslated output without user aliases or pagers
+	cmd.Env = append(os.Environ(), "HGPLAIN=1", "LC_ALL=C")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(output))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return output, fmt.Errorf("%s %s failed: %s", name, strings.Join(args, " "), msg)
+	}
+	return output, nil
+}
+
+// HandleGetLog list the newest revisions of a project
+func HandleGetLog(c *gin.Context) {
+	project := findEnabledProject(c.Param("name"))
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 500 {
+		limit = 20
+	}
+	backend, err := vcsFor(project)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadRevisionsFailed, err)
+		return
+	}
+	revisions, err := backend.Log(project.Path, limit)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadRevisionsFailed, err)
+		return
+	}
+	loc := locale.FromContext(c)
+	for i := range revisions {
+		revisions[i].Date = locale.VCSDate(revisions[i].Date, loc)
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+// gitVCS git backend over the existing git helpers
+type gitVCS struct{}
+
+func (gitVCS) Status(projectPath string) (*types.VersionResponse, error) {
+	return getGitStatus(projectPath)
+}
+
+func (gitVCS) SwitchBranch(projectPath, branch string, force bool) error {
+	return switchBranch(projectPath, branch, force)
+}
+
+func (gitVCS) SwitchRevision(projectPath, rev string, force bool) error {
+	return switchToTag(projectPath, rev, force)
+}
+
+func (gitVCS) Log(projectPath string, limit int) ([]Revision, error) {
+	output, err := execGitCommandOutput(projectPath, "log", "-n", strconv.Itoa(limit), "--format=%H%x1f%an%x1f%cI%x1f%s")
+	if err != nil {
+		return nil, fmt.Errorf("git log failed: %s", strings.TrimSpace(string(output)))
+	}
+	revisions := []Revision{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, "\x1f", 4)
+		if len(fields) == 4 {
+			revisions = append(revisions, Revision{ID: fields[0], Author: fields[1], Date: fields[2], Message: fields[3]})
+		}
+	}
+	return revisions, nil
+}
@@ -0,0 +1,542 @@
+package version
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// artifactStateFile records the deployed artifacts in the project directory
+const artifactStateFile = ".gohook-artifact.json"
+
+// maxArtifactSize largest artifact that is downloaded
+const maxArtifactSize = 4 << 30
+
+// maxArtifactHistory deployed artifacts kept in the state file for the log
+const maxArtifactHistory = 50
+
+// artifactHTTPClient client of artifact downloads
+var artifactHTTPClient = &http.Client{Timeout: 30 * time.Minute}
+
+// artifactDeploy one deployed artifact
+type artifactDeploy struct {
+	Ref        string    `json:"ref"`
+	RefType    string    `json:"refType"` // branch | tag
+	Location   string    `json:"location"`
+	SHA256     string    `json:"sha256"`
+	Verified   string    `json:"verified"` // how the artifact was verified
+	DeployedAt time.Time `json:"deployedAt"`
+}
+
+// artifactState content of the state file, newest deploy first
+type artifactState struct {
+	History []artifactDeploy `json:"history"`
+}
+
+// artifactVCS backend of projects deploying build artifacts: branches and tags name the
+// artifact to download, the project directory holds its unpacked content
+type artifactVCS struct{}
+
+func readArtifactState(projectPath string) artifactState {
+	var state artifactState
+	if data, err := os.ReadFile(filepath.Join(projectPath, artifactStateFile)); err == nil {
+		json.Unmarshal(data, &state)
+	}
+	return state
+}
+
+func (artifactVCS) Status(projectPath string) (*types.VersionResponse, error) {
+	status := &types.VersionResponse{Mode: "none", Status: "active"}
+	state := readArtifactState(projectPath)
+	if len(state.History) == 0 {
+		return status, nil
+	}
+	last := state.History[0]
+	status.Mode = last.RefType
+	if last.RefType == "tag" {
+		status.CurrentTag = last.Ref
+	} else {
+		status.CurrentBranch = last.Ref
+	}
+	status.LastCommit = last.SHA256[:12]
+	status.LastCommitTime = last.DeployedAt.Format(time.RFC3339)
+	return status, nil
+}
+
+func (artifactVCS) SwitchBranch(projectPath, branch string, force bool) error {
+	return deployArtifact(projectPath, "branch", branch)
+}
+
+func (artifactVCS) SwitchRevision(projectPath, rev string, force bool) error {
+	return deployArtifact(projectPath, "tag", rev)
+}
+
+func (artifactVCS) Log(projectPath string, limit int) ([]Revision, error) {
+	revisions := []Revision{}
+	for _, d := range readArtifactState(projectPath).History {
+		if len(revisions) == limit {
+			break
+		}
+		revisions = append(revisions, Revision{
+			ID:      d.SHA256[:12],
+			Author:  d.Verified,
+			Date:    d.DeployedAt.Format(time.RFC3339),
+			Message: fmt.Sprintf("%s %s from %s", d.RefType, d.Ref, d.Location),
+		})
+	}
+	return revisions, nil
+}
+
+// artifactLocation expand {ref} and {version} in an artifact location
+func artifactLocation(template, ref string) string {
+	return strings.NewReplacer("{ref}", ref, "{version}", strings.TrimPrefix(ref, "v")).Replace(template)
+}
+
+// deployArtifact download the artifact of ref, verify it and unpack it into the project directory
+func deployArtifact(projectPath, refType, ref string) error {
+	project := findProjectByPath(projectPath)
+	if project == nil || project.Artifact == nil {
+		return fmt.Errorf("project at %s has no artifact configured", projectPath)
+	}
+	cfg := project.Artifact
+	ctx := context.Background()
+
+	location := artifactLocation(artifactName(cfg), ref)
+	archive, err := os.CreateTemp("", "gohook-artifact-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+	hash := sha256.New()
+	if err := fetchArtifact(ctx, cfg, ref, location, io.MultiWriter(archive, hash)); err != nil {
+		return fmt.Errorf("download %s failed: %v", location, err)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	var verified []string
+	if cfg.Checksum != "" {
+		if err := verifyArtifactChecksum(ctx, cfg, ref, artifactLocation(cfg.Checksum, ref), path.Base(location), sum); err != nil {
+			return err
+		}
+		verified = append(verified, "sha256")
+	}
+	if cfg.Signature != "" {
+		method, err := verifyArtifactSignature(ctx, cfg, ref, artifactLocation(cfg.Signature, ref), archive.Name())
+		if err != nil {
+			return err
+		}
+		verified = append(verified, method)
+	}
+
+	state := readArtifactState(projectPath)
+	if err := unpackArtifact(archive.Name(), projectPath, cfg); err != nil {
+		return fmt.Errorf("unpack %s failed: %v", location, err)
+	}
+
+	state.History = append([]artifactDeploy{{
+		Ref:        ref,
+		RefType:    refType,
+		Location:   location,
+		SHA256:     sum,
+		Verified:   strings.Join(verified, "+"),
+		DeployedAt: time.Now(),
+	}}, state.History...)
+	if len(state.History) > maxArtifactHistory {
+		state.History = state.History[:maxArtifactHistory]
+	}
+	data, _ := json.MarshalIndent(state, "", "  ")
+	return os.WriteFile(filepath.Join(projectPath, artifactStateFile), data, 0o644)
+}
+
+// artifactName location template of the artifact itself
+func artifactName(cfg *types.ProjectArtifactConfig) string {
+	switch cfg.Source {
+	case types.ArtifactGitHub:
+		return cfg.Asset
+	case types.ArtifactS3:
+		return cfg.Key
+	}
+	return cfg.URL
+}
+
+// fetchArtifact write the file at location of the artifact source to w, GitHub assets are looked
+// up in the release tagged ref
+func fetchArtifact(ctx context.Context, cfg *types.ProjectArtifactConfig, ref, location string, w io.Writer) error {
+	var req *http.Request
+	var err error
+	switch cfg.Source {
+	case types.ArtifactGitHub:
+		req, err = githubAssetRequest(ctx, cfg, ref, location)
+	case types.ArtifactS3:
+		req, err = s3ObjectRequest(ctx, cfg, location)
+	default:
+		req, err = http.NewRequestWithContext(ctx, "GET", location, nil)
+		if err == nil && cfg.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+cfg.Token)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	resp, err := artifactHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server answered %s", resp.Status)
+	}
+	n, err := io.Copy(w, io.LimitReader(resp.Body, maxArtifactSize+1))
+	if err == nil && n > maxArtifactSize {
+		err = fmt.Errorf("artifact is larger than %d bytes", int64(maxArtifactSize))
+	}
+	return err
+}
+
+// fetchArtifactFile small file such as a checksum list or a signature
+func fetchArtifactFile(ctx context.Context, cfg *types.ProjectArtifactConfig, ref, location string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := fetchArtifact(ctx, cfg, ref, location, &limitedWriter{w: &buf, n: 1 << 20}); err != nil {
+		return nil, fmt.Errorf("download %s failed: %v", location, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// limitedWriter writer failing after n bytes
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > l.n {
+		return 0, fmt.Errorf("file is too large")
+	}
+	l.n -= len(p)
+	return l.w.Write(p)
+}
+
+// githubAssetRequest download request of the asset named name of the release tagged tag
+func githubAssetRequest(ctx context.Context, cfg *types.ProjectArtifactConfig, tag, name string) (*http.Request, error) {
+	api := strings.TrimSuffix(cfg.APIURL, "/")
+	if api == "" {
+		api = "https://api.github.com"
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", api+"/repos/"+cfg.Repo+"/releases/tags/"+url.PathEscape(tag), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	}
+	resp, err := artifactHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("release %s of %s: GitHub answered %s", tag, cfg.Repo, resp.Status)
+	}
+	var release struct {
+		Assets []struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"assets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, err
+	}
+	for _, asset := range release.Assets {
+		if asset.Name != name {
+			continue
+		}
+		// the API URL of the asset also works for private repositories
+		req, err := http.NewRequestWithContext(ctx, "GET", asset.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/octet-stream")
+		if cfg.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+cfg.Token)
+		}
+		return req, nil
+	}
+	return nil, fmt.Errorf("release %s of %s has no asset %s", tag, cfg.Repo, name)
+}
+
+// s3ObjectRequest GET request of an object, path-style so it works with MinIO and other S3
+// compatible stores, signed with AWS Signature Version 4 unless the bucket is read anonymously
+func s3ObjectRequest(ctx context.Context, cfg *types.ProjectArtifactConfig, objectKey string) (*http.Request, error) {
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	canonicalPath := "/" + s3Escape(cfg.Bucket) + "/" + s3Escape(strings.TrimPrefix(objectKey, "/"))
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+canonicalPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.AccessKeyID == "" {
+		return req, nil
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	const payloadHash = "UNSIGNED-PAYLOAD"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		canonicalPath,
+		"",
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		"host;x-amz-content-sha256;x-amz-date",
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	key := []byte("AWS4" + cfg.SecretAccessKey)
+	for _, part := range []string{day, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+cfg.AccessKeyID+"/"+scope+
+		", SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="+signature)
+	return req, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape URI-encode a bucket or key the way Signature Version 4 expects, keeping slashes
+func s3Escape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// verifyArtifactChecksum check sum against the entry for name of a sha256sum style checksum file,
+// a file holding a single checksum applies to the artifact whatever its name
+func verifyArtifactChecksum(ctx context.Context, cfg *types.ProjectArtifactConfig, ref, location, name, sum string) error {
+	data, err := fetchArtifactFile(ctx, cfg, ref, location)
+	if err != nil {
+		return err
+	}
+	var expected string
+	var sums []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		sums = append(sums, fields[0])
+		if len(fields) >= 2 && path.Base(strings.TrimPrefix(fields[1], "*")) == name {
+			expected = fields[0]
+			break
+		}
+	}
+	if expected == "" && len(sums) == 1 {
+		expected = sums[0]
+	}
+	if expected == "" {
+		return fmt.Errorf("checksum file %s has no entry for %s", location, name)
+	}
+	if !strings.EqualFold(expected, sum) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, expected, sum)
+	}
+	return nil
+}
+
+// verifyArtifactSignature check a detached SSH signature against the allowed signers, or a GPG
+// signature against the keyring of the user running gohook, and return the method used
+func verifyArtifactSignature(ctx context.Context, cfg *types.ProjectArtifactConfig, ref, location, artifact string) (string, error) {
+	data, err := fetchArtifactFile(ctx, cfg, ref, location)
+	if err != nil {
+		return "", err
+	}
+	sig, err := os.CreateTemp("", "gohook-artifact-sig-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(sig.Name())
+	sig.Write(data)
+	sig.Close()
+
+	if !bytes.Contains(data, []byte("-----BEGIN SSH SIGNATURE-----")) {
+		output, err := exec.CommandContext(ctx, "gpg", "--batch", "--verify", sig.Name(), artifact).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("gpg signature verification failed: %s", strings.TrimSpace(string(output)))
+		}
+		return "gpg", nil
+	}
+
+	if cfg.AllowedSigners == "" {
+		return "", fmt.Errorf("SSH signature verification needs allowed_signers")
+	}
+	output, err := exec.CommandContext(ctx, "ssh-keygen", "-Y", "find-principals", "-f", cfg.AllowedSigners, "-s", sig.Name()).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("SSH signature verification failed: no allowed signer: %s", strings.TrimSpace(string(output)))
+	}
+	principal := strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0])
+	f, err := os.Open(artifact)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	cmd := exec.CommandContext(ctx, "ssh-keygen", "-Y", "verify", "-f", cfg.AllowedSigners, "-I", principal, "-n", "file", "-s", sig.Name())
+	cmd.Stdin = f
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("SSH signature verification failed: %s", strings.TrimSpace(string(output)))
+	}
+	return "ssh:" + principal, nil
+}
+
+// unpackArtifact unpack a tar, tar.gz or zip archive into the project directory. With clean the
+// archive is unpacked next to it and the directories are swapped, so files removed from the
+// build disappear; the state file is carried over.
+func unpackArtifact(archive, projectPath string, cfg *types.ProjectArtifactConfig) error {
+	dest := projectPath
+	if cfg.Clean {
+		dest = filepath.Clean(projectPath) + ".gohook-new"
+		os.RemoveAll(dest)
+		if err := os.Mkdir(dest, 0o755); err != nil {
+			return err
+		}
+		defer os.RemoveAll(dest)
+	}
+	if err := extractArchive(archive, dest, cfg.StripComponents); err != nil {
+		return err
+	}
+	if !cfg.Clean {
+		return nil
+	}
+
+	if data, err := os.ReadFile(filepath.Join(projectPath, artifactStateFile)); err == nil {
+		os.WriteFile(filepath.Join(dest, artifactStateFile), data, 0o644)
+	}
+	if info, err := os.Stat(projectPath); err == nil {
+		os.Chmod(dest, info.Mode().Perm())
+	}
+	old := filepath.Clean(projectPath) + ".gohook-old"
+	os.RemoveAll(old)
+	if err := os.Rename(projectPath, old); err != nil {
+		return err
+	}
+	if err := os.Rename(dest, projectPath); err != nil {
+		os.Rename(old, projectPath)
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+// extractArchive unpack an archive, the format is detected from its first bytes
+func extractArchive(archive, dest string, strip int) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	n, _ := io.ReadFull(f, magic)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	switch {
+	case n >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		return extractTar(gz, dest, strip)
+	case n == 4 && string(magic) == "PK\x03\x04":
+		return extractZip(archive, dest, strip)
+	}
+	return extractTar(f, dest, strip)
+}
+
+// extractZip unpack the directories, files and symlinks of a zip archive below dest
+func extractZip(archive, dest string, strip int) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, zf := range zr.File {
+		target, ok, err := archiveTarget(dest, zf.Name, strip)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		mode := zf.Mode()
+		if mode.IsDir() {
+			if err := prepareArchiveTarget(dest, target); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		if mode&os.ModeSymlink != 0 {
+			var link []byte
+			if link, err = io.ReadAll(io.LimitReader(rc, 4096)); err == nil {
+				if err = prepareArchiveTarget(dest, target); err == nil {
+					err = os.Symlink(string(link), target)
+				}
+			}
+		} else {
+			if mode.Perm() == 0 {
+				mode = 0o644
+			}
+			err = writeArchiveFile(dest, target, mode, rc)
+		}
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package version
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// hgVCS Mercurial backend
+type hgVCS struct{}
+
+func (hgVCS) Status(projectPath string) (*types.VersionResponse, error) {
+	output, err := execVCSCommand(projectPath, "hg", "log", "-r", ".", "--template", "{node|short}\\t{branch}\\t{tags}\\t{date|isodate}\\n")
+	if err != nil {
+		return nil, err
+	}
+	return parseHgStatus(string(output)), nil
+}
+
+func parseHgStatus(output string) *types.VersionResponse {
+	fields := strings.SplitN(strings.TrimSpace(output), "\t", 4)
+	for len(fields) < 4 {
+		fields = append(fields, "")
+	}
+	status := &types.VersionResponse{
+		CurrentBranch:  fields[1],
+		Mode:           "branch",
+		Status:         "active",
+		LastCommit:     fields[0],
+		LastCommitTime: fields[3],
+	}
+	// tip moves with every commit, it is not a deploy tag
+	for _, tag := range strings.Fields(fields[2]) {
+		if tag != "tip" {
+			status.CurrentTag = tag
+			status.Mode = "tag"
+			break
+		}
+	}
+	return status
+}
+
+// hgUpdate pull and update the working copy to rev, a branch name updates to the branch head
+func hgUpdate(projectPath, rev string, force bool) error {
+	if output, err := execVCSCommand(projectPath, "hg", "pull"); err != nil {
+		log.Printf("warning: hg pull failed: %s", strings.TrimSpace(string(output)))
+	}
+	args := []string{"update", "-r", rev}
+	if force {
+		args = append(args, "--clean")
+	}
+	_, err := execVCSCommand(projectPath, "hg", args...)
+	return err
+}
+
+func (hgVCS) SwitchBranch(projectPath, branch string, force bool) error {
+	return hgUpdate(projectPath, branch, force)
+}
+
+func (hgVCS) SwitchRevision(projectPath, rev string, force bool) error {
+	return hgUpdate(projectPath, rev, force)
+}
+
+func (hgVCS) Log(projectPath string, limit int) ([]Revision, error) {
+	output, err := execVCSCommand(projectPath, "hg", "log", "-r", "reverse(ancestors(.))", "-l", strconv.Itoa(limit),
+		"--template", "{node}\\t{author|person}\\t{date|isodate}\\t{desc|firstline}\\n")
+	if err != nil {
+		return nil, err
+	}
+	revisions := []Revision{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) == 4 {
+			revisions = append(revisions, Revision{ID: fields[0], Author: fields[1], Date: fields[2], Message: fields[3]})
+		}
+	}
+	return revisions, nil
+}
@@ -0,0 +1,167 @@
+package version
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// svn revisions are given as 123 or r123, anything else is a tag
+var svnRevisionPattern = regexp.MustCompile(`^r?(\d+)$`)
+
+// svnVCS Subversion backend for working copies of a trunk/branches/tags layout
+type svnVCS struct{}
+
+type svnInfo struct {
+	Entry struct {
+		Revision    string `xml:"revision,attr"`
+		RelativeURL string `xml:"relative-url"`
+		Commit      struct {
+			Revision string `xml:"revision,attr"`
+			Author   string `xml:"author"`
+			Date     string `xml:"date"`
+		} `xml:"commit"`
+	} `xml:"entry"`
+}
+
+type svnLog struct {
+	Entries []struct {
+		Revision string `xml:"revision,attr"`
+		Author   string `xml:"author"`
+		Date     string `xml:"date"`
+		Msg      string `xml:"msg"`
+	} `xml:"logentry"`
+}
+
+func svnReadInfo(projectPath string) (*svnInfo, error) {
+	output, err := execVCSCommand(projectPath, "svn", "info", "--xml", "--non-interactive")
+	if err != nil {
+		return nil, err
+	}
+	var info svnInfo
+	if err := xml.Unmarshal(output, &info); err != nil {
+		return nil, fmt.Errorf("parse svn info: %v", err)
+	}
+	return &info, nil
+}
+
+// svnLocation split a relative url such as ^/app/branches/release/sub into the layout root
+// ^/app and the branch or tag it points into
+func svnLocation(relativeURL string) (root, branch, tag string, ok bool) {
+	segments := strings.Split(strings.TrimPrefix(relativeURL, "^/"), "/")
+	for i, segment := range segments {
+		root = "^/" + strings.Join(segments[:i], "/")
+		switch {
+		case segment == "trunk":
+			return strings.TrimSuffix(root, "/"), "trunk", "", true
+		case segment == "branches" && i+1 < len(segments):
+			return strings.TrimSuffix(root, "/"), segments[i+1], "", true
+		case segment == "tags" && i+1 < len(segments):
+			return strings.TrimSuffix(root, "/"), "", segments[i+1], true
+		}
+	}
+	return "", "", "", false
+}
+
+func (svnVCS) Status(projectPath string) (*types.VersionResponse, error) {
+	info, err := svnReadInfo(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	status := &types.VersionResponse{
+		Mode:           "branch",
+		Status:         "active",
+		LastCommit:     "r" + info.Entry.Commit.Revision,
+		LastCommitTime: info.Entry.Commit.Date,
+	}
+	if _, branch, tag, ok := svnLocation(info.Entry.RelativeURL); ok {
+		status.CurrentBranch, status.CurrentTag = branch, tag
+		if tag != "" {
+			status.Mode = "tag"
+		}
+	} else {
+		status.CurrentBranch = info.Entry.RelativeURL
+	}
+	return status, nil
+}
+
+// svnSwitch switch the working copy to target (a ^/ url) below the layout root
+func svnSwitch(projectPath, target string, force bool) error {
+	if force {
+		if _, err := execVCSCommand(projectPath, "svn", "revert", "-R", "--non-interactive", "."); err != nil {
+			return fmt.Errorf("force clean failed: %v", err)
+		}
+	}
+	_, err := execVCSCommand(projectPath, "svn", "switch", "--non-interactive", target)
+	return err
+}
+
+// svnLayoutRoot the ^/ url holding trunk, branches and tags of the working copy
+func svnLayoutRoot(projectPath string) (string, error) {
+	info, err := svnReadInfo(projectPath)
+	if err != nil {
+		return "", err
+	}
+	root, _, _, ok := svnLocation(info.Entry.RelativeURL)
+	if !ok {
+		return "", fmt.Errorf("%s is not in a trunk/branches/tags layout", info.Entry.RelativeURL)
+	}
+	return root, nil
+}
+
+func (svnVCS) SwitchBranch(projectPath, branch string, force bool) error {
+	target := branch
+	if !strings.HasPrefix(branch, "^/") {
+		root, err := svnLayoutRoot(projectPath)
+		if err != nil {
+			return err
+		}
+		target = root + "/branches/" + branch
+		if branch == "trunk" {
+			target = root + "/trunk"
+		}
+	}
+	return svnSwitch(projectPath, target, force)
+}
+
+func (svnVCS) SwitchRevision(projectPath, rev string, force bool) error {
+	if m := svnRevisionPattern.FindStringSubmatch(rev); m != nil {
+		if force {
+			if _, err := execVCSCommand(projectPath, "svn", "revert", "-R", "--non-interactive", "."); err != nil {
+				return fmt.Errorf("force clean failed: %v", err)
+			}
+		}
+		_, err := execVCSCommand(projectPath, "svn", "update", "--non-interactive", "-r", m[1])
+		return err
+	}
+	root, err := svnLayoutRoot(projectPath)
+	if err != nil {
+		return err
+	}
+	return svnSwitch(projectPath, root+"/tags/"+rev, force)
+}
+
+func (svnVCS) Log(projectPath string, limit int) ([]Revision, error) {
+	output, err := execVCSCommand(projectPath, "svn", "log", "--xml", "--non-interactive", "-l", strconv.Itoa(limit))
+	if err != nil {
+		return nil, err
+	}
+	return parseSvnLog(output)
+}
+
+func parseSvnLog(data []byte) ([]Revision, error) {
+	var log svnLog
+	if err := xml.Unmarshal(data, &log); err != nil {
+		return nil, fmt.Errorf("parse svn log: %v", err)
+	}
+	revisions := make([]Revision, 0, len(log.Entries))
+	for _, e := range log.Entries {
+		message, _, _ := strings.Cut(strings.TrimSpace(e.Msg), "\n")
+		revisions = append(revisions, Revision{ID: "r" + e.Revision, Author: e.Author, Date: e.Date, Message: message})
+	}
+	return revisions, nil
+}
@@ -17,7 +17,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/mycoool/gohook/internal/config"
 	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/httpcache"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/listing"
+	"github.com/mycoool/gohook/internal/locale"
+	"github.com/mycoool/gohook/internal/maintenance"
 	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/namespace"
 	"github.com/mycoool/gohook/internal/stream"
 	"github.com/mycoool/gohook/internal/syncnode"
 	"github.com/mycoool/gohook/internal/types"
@@ -44,8 +50,16 @@ func currentServiceUserAndGroup() (username, group string) {
 
 // execGitCommand execute git command, automatically handle safe.directory permission issues
 func execGitCommand(projectPath string, args ...string) ([]byte, error) {
+	// remote commands authenticate with the deploy key of the project, if any
+	sshEnv, cleanup, err := deployKeyEnv(projectPath, args)
+	if err != nil {
+		log.Printf("git %s in %s refused: %v", strings.Join(args, " "), projectPath, err)
+		return nil, err
+	}
+	defer cleanup()
+
 	// first try to execute git command normally
-	cmd := exec.Command("git", append([]string{"-C", projectPath}, args...)...)
+	cmd := newGitCommand(projectPath, sshEnv, args...)
 	output, err := cmd.CombinedOutput()
 
 	// if successful or not safe.directory related error, return directly
@@ -79,7 +93,7 @@ func execGitCommand(projectPath string, args ...string) ([]byte, error) {
 	}
 
 	// retry to execute original git command
-	cmd = exec.Command("git", append([]string{"-C", projectPath}, args...)...)
+	cmd = newGitCommand(projectPath, sshEnv, args...)
 	retryOutput, retryErr := cmd.CombinedOutput()
 	if retryErr != nil {
 		log.Printf("retry after safe.directory configuration failed: %s", string(retryOutput))
@@ -90,6 +104,15 @@ func execGitCommand(projectPath string, args ...string) ([]byte, error) {
 	return retryOutput, nil
 }
 
+// newGitCommand git command run in projectPath with extraEnv added to the environment
+func newGitCommand(projectPath string, extraEnv []string, args ...string) *exec.Cmd {
+	cmd := exec.Command("git", append([]string{"-C", projectPath}, args...)...)
+	if len(extraEnv) > 0 {
+		cmd.Env = append(os.Environ(), extraEnv...)
+	}
+	return cmd
+}
+
 // execGitCommandOutput execute git command and return output, using safe.directory to automatically fix
 func execGitCommandOutput(projectPath string, args ...string) ([]byte, error) {
 	return execGitCommand(projectPath, args...)
@@ -151,6 +174,13 @@ func initGit(projectPath string) error {
 func forceCleanWorkingDirectory(projectPath string) error {
 	log.Printf("Force cleaning working directory: %s", projectPath)
 
+	// keep what the reset discards when the project asks for snapshots
+	if project := findProjectByPath(projectPath); project != nil && project.Snapshots != nil && project.Snapshots.Enabled {
+		if _, err := createSnapshot(project, "force deploy"); err != nil {
+			return fmt.Errorf("snapshot before reset failed, local changes kept: %v", err)
+		}
+	}
+
 	// Reset all changes to tracked files (staged and unstaged)
 	// This will discard all local modifications but preserve untracked files like .env, runtime/, etc.
 	if output, err := execGitCommand(projectPath, "reset", "--hard", "HEAD"); err != nil {
@@ -161,9 +191,30 @@ func forceCleanWorkingDirectory(projectPath string) error {
 	return nil
 }
 
-// switchAndPullBranch switch to specified branch and pull latest code
+// resolveCommit resolve rev to the SHA of the commit it points to
+func resolveCommit(projectPath, rev string) (string, error) {
+	output, err := execGitCommandOutput(projectPath, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	sha := strings.TrimSpace(string(output))
+	if err != nil || sha == "" {
+		return "", fmt.Errorf("revision %s not found", rev)
+	}
+	return sha, nil
+}
+
+// switchAndPullBranch switch to specified branch and move it to the fetched remote branch.
+// The remote tip is resolved once, so the commit whose signature is checked is the commit
+// deployed, even when the remote branch moves meanwhile.
 // force: if true, will discard all local changes before switching
 func switchAndPullBranch(projectPath, branchName string, force bool) error {
+	remoteBranch := "origin/" + branchName
+	sha, err := resolveCommit(projectPath, remoteBranch)
+	if err != nil {
+		return fmt.Errorf("remote branch %s not found, fetch it first: %v", remoteBranch, err)
+	}
+	if err := verifyDeploySignature(projectPath, "branch", sha); err != nil {
+		return err
+	}
+
 	// if force mode, clean working directory first
 	if force {
 		if err := forceCleanWorkingDirectory(projectPath); err != nil {
@@ -176,42 +227,41 @@ func switchAndPullBranch(projectPath, branchName string, force bool) error {
 	localBranchExists := err == nil && strings.TrimSpace(string(output)) != ""
 
 	if !localBranchExists {
-		// local branch does not exist, try to create from remote
-		if output, err := execGitCommand(projectPath, "checkout", "-b", branchName, "origin/"+branchName); err != nil {
+		// local branch does not exist, create it at the verified commit
+		if output, err := execGitCommand(projectPath, "checkout", "-b", branchName, sha); err != nil {
 			return fmt.Errorf("create and switch to branch %s failed: %s", branchName, string(output))
 		}
-	} else {
-		// local branch exists, switch directly
-		if output, err := execGitCommand(projectPath, "checkout", branchName); err != nil {
-			return fmt.Errorf("switch to branch %s failed: %s", branchName, string(output))
+		if output, err := execGitCommand(projectPath, "branch", "--set-upstream-to="+remoteBranch, branchName); err != nil {
+			log.Printf("warning: failed to track %s: %s", remoteBranch, string(output))
 		}
+		return nil
+	}
 
-		// if force mode, use reset to sync with remote instead of pull
-		if force {
-			if output, err := execGitCommand(projectPath, "reset", "--hard", "origin/"+branchName); err != nil {
-				return fmt.Errorf("failed to force sync with remote branch %s: %s", branchName, string(output))
-			}
-		} else {
-			// normal mode: pull latest code
-			if output, err := execGitCommand(projectPath, "pull", "origin", branchName); err != nil {
-				return fmt.Errorf("failed to fetch latest code for branch %s: %s", branchName, string(output))
-			}
-		}
+	// local branch exists, switch directly
+	if output, err := execGitCommand(projectPath, "checkout", branchName); err != nil {
+		return fmt.Errorf("switch to branch %s failed: %s", branchName, string(output))
 	}
+	return moveBranchTo(projectPath, branchName, sha, force)
+}
 
+// moveBranchTo move the checked out branch to the commit sha: reset in force mode, otherwise
+// fast-forward only, a merge commit would not carry a signature
+func moveBranchTo(projectPath, branchName, sha string, force bool) error {
+	if force {
+		if output, err := execGitCommand(projectPath, "reset", "--hard", sha); err != nil {
+			return fmt.Errorf("failed to force sync branch %s with %s: %s", branchName, sha, string(output))
+		}
+		return nil
+	}
+	if output, err := execGitCommand(projectPath, "merge", "--ff-only", sha); err != nil {
+		return fmt.Errorf("failed to fast-forward branch %s to %s: %s", branchName, sha, string(output))
+	}
 	return nil
 }
 
 // switchToTag switch to specified tag
 // force: if true, will discard all local changes before switching
 func switchToTag(projectPath, tagName string, force bool) error {
-	// if force mode, clean working directory first
-	if force {
-		if err := forceCleanWorkingDirectory(projectPath); err != nil {
-			return fmt.Errorf("force clean failed: %v", err)
-		}
-	}
-
 	// fetch tag information
 	if output, err := execGitCommand(projectPath, "fetch", "--tags"); err != nil {
 		log.Printf("warning: failed to fetch tag information: %s", string(output))
@@ -230,6 +280,17 @@ func switchToTag(projectPath, tagName string, force bool) error {
 		}
 	}
 
+	if err := verifyDeploySignature(projectPath, "tag", tagName); err != nil {
+		return err
+	}
+
+	// if force mode, clean working directory first
+	if force {
+		if err := forceCleanWorkingDirectory(projectPath); err != nil {
+			return fmt.Errorf("force clean failed: %v", err)
+		}
+	}
+
 	// switch to specified tag
 	if output, err := execGitCommand(projectPath, "checkout", tagName); err != nil {
 		return fmt.Errorf("switch to tag %s failed: %s", tagName, string(output))
@@ -264,25 +325,20 @@ func deleteLocalTag(projectPath, tagName string) error {
 func HandleDeleteLocalBranch(c *gin.Context) {
 	projectName := c.Param("name")
 	branchName := c.Param("branchName")
-	// find project path
-	var projectPath string
-	for _, proj := range types.GoHookVersionData.Projects {
-		if proj.Name == projectName && proj.Enabled {
-			projectPath = proj.Path
-			break
-		}
+	project := findEnabledProject(projectName)
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
 	}
-
-	if projectPath == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+	if !enforceProtection(c, project, "branch", branchName, protectDelete) {
 		return
 	}
 
-	if err := deleteLocalBranch(projectPath, branchName); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := deleteLocalBranch(project.Path, branchName); err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDeleteBranchFailed, err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Branch deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeBranchDeleted), "code": i18n.CodeBranchDeleted})
 }
 
 // deleteLocalBranch delete local branch
@@ -412,6 +468,12 @@ func getRemote(projectPath string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// ProjectRemote origin URL of the project at projectPath, empty when not a repository or unset
+func ProjectRemote(projectPath string) string {
+	remote, _ := getRemote(projectPath)
+	return remote
+}
+
 // SetRemote set remote repository
 func setRemote(projectPath, remoteUrl string) error {
 	// check if it is a Git repository
@@ -448,6 +510,15 @@ func syncBranches(projectPath string) error {
 // SwitchBranch switch branch
 // force: if true, will discard all local changes before switching
 func switchBranch(projectPath, branchName string, force bool) error {
+	// the commit verified is the commit deployed
+	sha, err := resolveCommit(projectPath, branchName)
+	if err != nil {
+		return fmt.Errorf("switch branch failed: %v", err)
+	}
+	if err := verifyDeploySignature(projectPath, "branch", sha); err != nil {
+		return err
+	}
+
 	// if force mode, clean working directory first
 	if force {
 		if err := forceCleanWorkingDirectory(projectPath); err != nil {
@@ -470,10 +541,13 @@ func switchBranch(projectPath, branchName string, force bool) error {
 				return fmt.Errorf("switch branch failed: %s", string(output))
 			}
 		} else {
-			// local branch does not exist, create a new local branch based on the remote branch
-			if output, err := execGitCommand(projectPath, "checkout", "-b", localBranchName, branchName); err != nil {
+			// local branch does not exist, create a new local branch at the verified commit
+			if output, err := execGitCommand(projectPath, "checkout", "-b", localBranchName, sha); err != nil {
 				return fmt.Errorf("switch branch failed: %s", string(output))
 			}
+			if output, err := execGitCommand(projectPath, "branch", "--set-upstream-to="+branchName, localBranchName); err != nil {
+				log.Printf("warning: failed to track %s: %s", branchName, string(output))
+			}
 		}
 	} else {
 		// normal local branch switch
@@ -484,21 +558,14 @@ func switchBranch(projectPath, branchName string, force bool) error {
 		}
 	}
 
-	// if a new branch is created based on a remote branch, try to pull the latest code
+	// an existing local branch is moved to the verified commit of the remote branch
 	if isRemoteBranch {
-		if force {
-			// force mode: use reset instead of pull
-			resetOutput, resetErr := execGitCommand(projectPath, "reset", "--hard", "origin/"+localBranchName)
-			if resetErr != nil {
-				log.Printf("force reset after switching branch failed (project: %s, branch: %s): %s", projectPath, localBranchName, string(resetOutput))
-			}
-		} else {
-			// normal mode: try to pull
-			pullOutput, pullErr := execGitCommand(projectPath, "pull", "origin", localBranchName)
-			if pullErr != nil {
-				// pull failed is not a fatal error, but log it
-				log.Printf("pull latest code after switching branch failed (project: %s, branch: %s): %s", projectPath, localBranchName, string(pullOutput))
+		if err := moveBranchTo(projectPath, localBranchName, sha, force); err != nil {
+			// the local branch was not verified, it is only kept when no signature is required
+			if signaturesRequired(projectPath) {
+				return err
 			}
+			log.Printf("update after switching branch failed (project: %s): %v", projectPath, err)
 		}
 	}
 
@@ -512,7 +579,7 @@ func getTags(projectPath string) ([]types.TagResponse, error) {
 	currentTag := strings.TrimSpace(string(currentOutput))
 
 	// get all tags
-	output, err := execGitCommandOutput(projectPath, "tag", "-l", "--sort=-version:refname", "--format=%(refname:short)|%(creatordate)|%(objectname:short)|%(subject)")
+	output, err := execGitCommandOutput(projectPath, "tag", "-l", "--sort=-version:refname", "--format=%(refname:short)|%(creatordate:iso-strict)|%(objectname:short)|%(subject)")
 	if err != nil {
 		return nil, fmt.Errorf("get tag list failed: %v", err)
 	}
@@ -578,7 +645,7 @@ func getBranches(projectPath string) ([]types.BranchResponse, error) {
 		}
 
 		// get last commit information
-		commitOutput, _ := execGitCommandOutput(projectPath, "log", "-1", "HEAD", "--format=%H|%ci")
+		commitOutput, _ := execGitCommandOutput(projectPath, "log", "-1", "HEAD", "--format=%H|%cI")
 		parts := strings.Split(strings.TrimSpace(string(commitOutput)), "|")
 		lastCommit, lastCommitTime := "", ""
 		if len(parts) > 0 {
@@ -598,7 +665,7 @@ func getBranches(projectPath string) ([]types.BranchResponse, error) {
 	}
 
 	// 4. get all local branches
-	localOutput, err := execGitCommandOutput(projectPath, "for-each-ref", "refs/heads", "--format=%(refname:short)|%(committerdate:iso)|%(objectname:short)")
+	localOutput, err := execGitCommandOutput(projectPath, "for-each-ref", "refs/heads", "--format=%(refname:short)|%(committerdate:iso-strict)|%(objectname:short)")
 	if err != nil {
 		return nil, fmt.Errorf("get local branch list failed: %v", err)
 	}
@@ -624,7 +691,7 @@ func getBranches(projectPath string) ([]types.BranchResponse, error) {
 	}
 
 	// 5. get all remote branches
-	remoteOutput, err := execGitCommandOutput(projectPath, "for-each-ref", "refs/remotes", "--format=%(refname:short)|%(committerdate:iso)|%(objectname:short)")
+	remoteOutput, err := execGitCommandOutput(projectPath, "for-each-ref", "refs/remotes", "--format=%(refname:short)|%(committerdate:iso-strict)|%(objectname:short)")
 	if err == nil {
 		for _, line := range strings.Split(strings.TrimSpace(string(remoteOutput)), "\n") {
 			if line == "" {
@@ -681,7 +748,7 @@ func getGitStatus(projectPath string) (*types.VersionResponse, error) {
 	}
 
 	// get last commit information
-	commitOutput, _ := execGitCommandOutput(projectPath, "log", "-1", "--format=%H|%ci|%s")
+	commitOutput, _ := execGitCommandOutput(projectPath, "log", "-1", "--format=%H|%cI|%s")
 	commitInfo := strings.TrimSpace(string(commitOutput))
 
 	parts := strings.Split(commitInfo, "|")
@@ -707,16 +774,92 @@ func HandleEditProject(c *gin.Context) {
 	projectName := c.Param("name")
 
 	var req struct {
-		Name        string                   `json:"name" binding:"required"`
-		Path        string                   `json:"path" binding:"required"`
-		Description string                   `json:"description"`
-		Sync        *types.ProjectSyncConfig `json:"sync,omitempty"`
+		Name           string                             `json:"name" binding:"required"`
+		Path           string                             `json:"path" binding:"required"`
+		Description    string                             `json:"description"`
+		Sync           *types.ProjectSyncConfig           `json:"sync,omitempty"`
+		PauseWindows   *[]types.PauseWindow               `json:"pauseWindows,omitempty"`
+		Promotion      *types.ProjectPromotionConfig      `json:"promotion,omitempty"`
+		Preflight      *types.ProjectPreflightConfig      `json:"preflight,omitempty"`
+		Snapshots      *types.ProjectSnapshotConfig       `json:"snapshots,omitempty"`
+		Protection     *types.ProjectProtectionConfig     `json:"protection,omitempty"`
+		GitMaintenance *types.ProjectGitMaintenanceConfig `json:"gitMaintenance,omitempty"`
+		Signatures     *types.ProjectSignatureConfig      `json:"signatures,omitempty"`
+		Kubernetes     *types.ProjectKubernetesConfig     `json:"kubernetes,omitempty"`
+		Compose        *types.ProjectComposeConfig        `json:"compose,omitempty"`
+		Migrations     *types.ProjectMigrationConfig      `json:"migrations,omitempty"`
+		Releases       *types.ProjectReleaseConfig        `json:"releases,omitempty"`
+		Artifact       *types.ProjectArtifactConfig       `json:"artifact,omitempty"`
+		HealthCheck    *types.ProjectHealthCheckConfig    `json:"healthcheck,omitempty"`
+		Poll           *types.ProjectPollConfig           `json:"poll,omitempty"`
+		Provider       *types.ProjectProviderConfig       `json:"provider,omitempty"`
+		VCS            *string                            `json:"vcs,omitempty"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if req.PauseWindows != nil {
+		if err := maintenance.ValidateWindows(*req.PauseWindows); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidPauseWindows, err)
+			return
+		}
+	}
+	if err := req.Preflight.Validate(); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProjectSettings, err)
+		return
+	}
+	if err := req.Protection.Validate(); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProjectSettings, err)
+		return
+	}
+	if err := req.GitMaintenance.Validate(); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProjectSettings, err)
+		return
+	}
+	if err := req.Poll.Validate(); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProjectSettings, err)
+		return
+	}
+	if err := req.Provider.Validate(); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProjectSettings, err)
+		return
+	}
+	if err := req.Signatures.Validate(); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProjectSettings, err)
+		return
+	}
+	if err := req.Kubernetes.Validate(); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProjectSettings, err)
+		return
+	}
+	if err := req.Compose.Validate(); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProjectSettings, err)
+		return
+	}
+	if err := req.Migrations.Validate(); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProjectSettings, err)
+		return
+	}
+	if err := req.Releases.Validate(); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProjectSettings, err)
 		return
 	}
+	if err := req.Artifact.Validate(); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProjectSettings, err)
+		return
+	}
+	if err := req.HealthCheck.Validate(); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProjectSettings, err)
+		return
+	}
+	if req.VCS != nil {
+		if err := ValidateVCS(*req.VCS); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProjectSettings, err)
+			return
+		}
+	}
 
 	// 智能清理路径末尾的斜杠
 	if len(req.Path) > 1 {
@@ -733,45 +876,107 @@ func HandleEditProject(c *gin.Context) {
 	}
 
 	if projectIndex == -1 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
 		return
 	}
 
-	// check if new name conflicts with existing projects (except current one)
-	if req.Name != projectName {
-		for _, proj := range types.GoHookVersionData.Projects {
-			if proj.Name == req.Name {
-				c.JSON(http.StatusConflict, gin.H{"error": "Project name already exists"})
-				return
-			}
-		}
-	}
-
 	// check if path exists
 	if _, err := os.Stat(req.Path); os.IsNotExist(err) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Specified path does not exist"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeProjectPathNotExist, nil)
 		return
 	}
 
-	// preserve existing configuration that is not being updated
-	currentProject := types.GoHookVersionData.Projects[projectIndex]
+	// a new name is applied like POST /version/:name/rename: the old name stays an alias
+	// and the database records follow the project
+	if req.Name != projectName {
+		if err := RenameProject(projectName, req.Name, true); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrProjectNameInUse) {
+				status = http.StatusConflict
+			} else if errors.Is(err, ErrInvalidProjectName) {
+				status = http.StatusBadRequest
+			}
+			i18n.JSONError(c, status, i18n.CodeRenameProjectFailed, err)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+	}
 
 	// update project while preserving existing fields
-	types.GoHookVersionData.Projects[projectIndex] = types.ProjectConfig{
-		Name:        req.Name,
-		Path:        req.Path,
-		Description: req.Description,
-		Enabled:     currentProject.Enabled,
-		Enhook:      currentProject.Enhook,
-		Hookmode:    currentProject.Hookmode,
-		Hookbranch:  currentProject.Hookbranch,
-		Hooksecret:  currentProject.Hooksecret,
-		ForceSync:   currentProject.ForceSync,
-		Sync:        currentProject.Sync,
-	}
+	currentProject := &types.GoHookVersionData.Projects[projectIndex]
+	currentProject.Path = req.Path
+	currentProject.Description = req.Description
 	if req.Sync != nil {
 		types.GoHookVersionData.Projects[projectIndex].Sync = req.Sync
 	}
+	if req.PauseWindows != nil {
+		types.GoHookVersionData.Projects[projectIndex].PauseWindows = *req.PauseWindows
+	}
+	if req.Promotion != nil {
+		types.GoHookVersionData.Projects[projectIndex].Promotion = req.Promotion
+	}
+	if req.Preflight != nil {
+		types.GoHookVersionData.Projects[projectIndex].Preflight = req.Preflight
+	}
+	if req.Snapshots != nil {
+		types.GoHookVersionData.Projects[projectIndex].Snapshots = req.Snapshots
+	}
+	if req.Protection != nil {
+		types.GoHookVersionData.Projects[projectIndex].Protection = req.Protection
+	}
+	if req.GitMaintenance != nil {
+		types.GoHookVersionData.Projects[projectIndex].GitMaintenance = req.GitMaintenance
+	}
+	if req.Signatures != nil {
+		types.GoHookVersionData.Projects[projectIndex].Signatures = req.Signatures
+	}
+	if req.Kubernetes != nil {
+		// the token is never returned, an empty one keeps the stored token
+		if old := types.GoHookVersionData.Projects[projectIndex].Kubernetes; req.Kubernetes.Token == "" && old != nil && old.Server == req.Kubernetes.Server {
+			req.Kubernetes.Token = old.Token
+		}
+		types.GoHookVersionData.Projects[projectIndex].Kubernetes = req.Kubernetes
+	}
+	if req.Compose != nil {
+		types.GoHookVersionData.Projects[projectIndex].Compose = req.Compose
+	}
+	if req.Migrations != nil {
+		types.GoHookVersionData.Projects[projectIndex].Migrations = req.Migrations
+	}
+	if req.Releases != nil {
+		types.GoHookVersionData.Projects[projectIndex].Releases = req.Releases
+	}
+	if req.Artifact != nil {
+		// credentials are never returned, empty ones keep the stored credentials
+		if old := types.GoHookVersionData.Projects[projectIndex].Artifact; old != nil && old.Source == req.Artifact.Source {
+			if req.Artifact.Token == "" {
+				req.Artifact.Token = old.Token
+			}
+			if req.Artifact.SecretAccessKey == "" && req.Artifact.AccessKeyID == old.AccessKeyID {
+				req.Artifact.SecretAccessKey = old.SecretAccessKey
+			}
+		}
+		types.GoHookVersionData.Projects[projectIndex].Artifact = req.Artifact
+	}
+	if req.HealthCheck != nil {
+		types.GoHookVersionData.Projects[projectIndex].HealthCheck = req.HealthCheck
+	}
+	if req.Poll != nil {
+		types.GoHookVersionData.Projects[projectIndex].Poll = req.Poll
+	}
+	if req.Provider != nil {
+		// the token is never returned and the webhook id is set by the registration
+		if old := types.GoHookVersionData.Projects[projectIndex].Provider; old != nil && old.Type == req.Provider.Type {
+			if req.Provider.Token == "" {
+				req.Provider.Token = old.Token
+			}
+			req.Provider.HookID = old.HookID
+		}
+		types.GoHookVersionData.Projects[projectIndex].Provider = req.Provider
+	}
+	if req.VCS != nil {
+		types.GoHookVersionData.Projects[projectIndex].VCS = *req.VCS
+	}
 
 	// save config file
 	if err := config.SaveVersionConfig(); err != nil {
@@ -789,7 +994,7 @@ func HandleEditProject(c *gin.Context) {
 		}
 		stream.Global.Broadcast(wsMessage)
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Save config failed: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
 		return
 	}
 
@@ -809,7 +1014,7 @@ func HandleEditProject(c *gin.Context) {
 	// Refresh sync watchers so config changes take effect without restart.
 	syncnode.RefreshProjectWatchers()
 
-	c.JSON(http.StatusOK, gin.H{"message": "Project updated successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeProjectUpdated), "code": i18n.CodeProjectUpdated})
 }
 
 // AddProject add project
@@ -818,11 +1023,22 @@ func HandleAddProject(c *gin.Context) {
 		Name        string                   `json:"name" binding:"required"`
 		Path        string                   `json:"path" binding:"required"`
 		Description string                   `json:"description"`
+		Namespace   string                   `json:"namespace"`
+		VCS         string                   `json:"vcs"` // git (default) | svn | hg | artifact
 		Sync        *types.ProjectSyncConfig `json:"sync,omitempty"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if err := ValidateVCS(req.VCS); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidProjectSettings, err)
+		return
+	}
+	req.Namespace = namespace.ForCreate(c, req.Namespace)
+	if !namespace.Exists(namespace.Normalize(req.Namespace)) {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeNamespaceNotFound, nil)
 		return
 	}
 
@@ -831,24 +1047,24 @@ func HandleAddProject(c *gin.Context) {
 		req.Path = strings.TrimRight(req.Path, string(os.PathSeparator))
 	}
 
-	// check if project name already exists
-	for _, proj := range types.GoHookVersionData.Projects {
-		if proj.Name == req.Name {
-			c.JSON(http.StatusConflict, gin.H{"error": "Project name already exists"})
-			return
-		}
+	// check if project name already exists, previous names of renamed projects included
+	if proj, _ := resolveProject(req.Name); proj != nil {
+		i18n.JSONError(c, http.StatusConflict, i18n.CodeProjectNameExists, nil)
+		return
 	}
 
 	// check if path exists
 	if _, err := os.Stat(req.Path); os.IsNotExist(err) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Specified path does not exist"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeProjectPathNotExist, nil)
 		return
 	}
 
 	// add new project
 	newProject := types.ProjectConfig{
 		Name:        req.Name,
+		Namespace:   req.Namespace,
 		Path:        req.Path,
+		VCS:         req.VCS,
 		Description: req.Description,
 		Enabled:     true,
 		Sync:        req.Sync,
@@ -872,14 +1088,14 @@ func HandleAddProject(c *gin.Context) {
 		}
 		stream.Global.Broadcast(wsMessage)
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Save config failed: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
 		return
 	}
 
 	// execute git status check in background to trigger safe.directory etc.
 	go func(p types.ProjectConfig) {
 		log.Printf("project '%s' added successfully, starting background git status check...", p.Name)
-		_, err := getGitStatus(p.Path)
+		_, err := projectStatus(&p)
 		if err != nil {
 			log.Printf("background git status check failed for project '%s': %v", p.Name, err)
 		} else {
@@ -904,7 +1120,8 @@ func HandleAddProject(c *gin.Context) {
 	syncnode.RefreshProjectWatchers()
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Project added successfully",
+		"message": i18n.Message(c, i18n.CodeProjectAdded),
+		"code":    i18n.CodeProjectAdded,
 		"project": newProject,
 	})
 }
@@ -923,15 +1140,31 @@ func HandleDeleteProject(c *gin.Context) {
 	}
 
 	if projectIndex == -1 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+
+	// keep a snapshot in the trash so the project can be restored within the retention period
+	deleted := types.GoHookVersionData.Projects[projectIndex]
+	currentUser, _ := c.Get("username")
+	username, _ := currentUser.(string)
+	trashItem, err := database.MoveToTrash(database.TrashKindProject, projectName, namespace.Normalize(deleted.Namespace), "", deleted, username)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeTrashFailed, err)
 		return
 	}
 
-	// delete project
-	types.GoHookVersionData.Projects = append(types.GoHookVersionData.Projects[:projectIndex], types.GoHookVersionData.Projects[projectIndex+1:]...)
+	// delete project, the slice is copied so a failed save can put it back
+	projects := types.GoHookVersionData.Projects
+	types.GoHookVersionData.Projects = append(append([]types.ProjectConfig{}, projects[:projectIndex]...), projects[projectIndex+1:]...)
 
 	// save config file
 	if err := config.SaveVersionConfig(); err != nil {
+		types.GoHookVersionData.Projects = projects
+		if trashItem != nil {
+			_, _ = database.PurgeTrashItems([]uint{trashItem.ID})
+		}
+
 		// push failed message
 		wsMessage := stream.WsMessage{
 			Type:      "project_managed",
@@ -945,7 +1178,7 @@ func HandleDeleteProject(c *gin.Context) {
 		}
 		stream.Global.Broadcast(wsMessage)
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Save config failed: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveConfigFailed, err)
 		return
 	}
 
@@ -958,18 +1191,62 @@ func HandleDeleteProject(c *gin.Context) {
 			ProjectName: projectName,
 			Success:     true,
 		},
+		Namespace: namespace.Normalize(deleted.Namespace),
 	}
 	stream.Global.Broadcast(wsMessage)
 
 	// Refresh sync watchers so removed projects stop watching without restart.
 	syncnode.RefreshProjectWatchers()
 
+	details := map[string]interface{}{"project": projectName, "path": deleted.Path}
+	var trashID uint
+	if trashItem != nil {
+		trashID = trashItem.ID
+		details["trashId"] = trashItem.ID
+		details["snapshot"] = database.TrashSnapshotPath(trashItem.ID)
+	}
+	database.LogUserAction(username, database.UserActionDeleteProject, "project", "delete project: "+projectName,
+		middleware.GetClientIP(c), c.Request.UserAgent(), true, details)
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Project deleted successfully",
+		"message": i18n.Message(c, i18n.CodeProjectDeleted),
+		"code":    i18n.CodeProjectDeleted,
 		"name":    projectName,
+		"trashId": trashID,
 	})
 }
 
+// ErrProjectExists a project with the same name is configured
+var ErrProjectExists = errors.New("project already exists")
+
+// RestoreProject add a project taken from the trash back to version.yaml
+func RestoreProject(project types.ProjectConfig) error {
+	for _, proj := range types.GoHookVersionData.Projects {
+		if proj.Name == project.Name {
+			return fmt.Errorf("%w: %s", ErrProjectExists, project.Name)
+		}
+	}
+	projects := types.GoHookVersionData.Projects
+	types.GoHookVersionData.Projects = append(append([]types.ProjectConfig{}, projects...), project)
+	if err := config.SaveVersionConfig(); err != nil {
+		types.GoHookVersionData.Projects = projects
+		return err
+	}
+
+	stream.Global.Broadcast(stream.WsMessage{
+		Type:      "project_managed",
+		Timestamp: time.Now(),
+		Data: stream.ProjectManageMessage{
+			Action:      "restore",
+			ProjectName: project.Name,
+			ProjectPath: project.Path,
+			Success:     true,
+		},
+	})
+	syncnode.RefreshProjectWatchers()
+	return nil
+}
+
 // GetBranches get branch list
 func HandleGetBranches(c *gin.Context) {
 	projectName := c.Param("name")
@@ -984,15 +1261,23 @@ func HandleGetBranches(c *gin.Context) {
 	}
 
 	if projectPath == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+
+	if httpcache.NotModified(c, gitRefsETag(c, projectPath)) {
 		return
 	}
 
 	branches, err := getBranches(projectPath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListBranchesFailed, err)
 		return
 	}
+	loc := locale.FromContext(c)
+	for i := range branches {
+		branches[i].LastCommitTime = locale.VCSDate(branches[i].LastCommitTime, loc)
+	}
 
 	c.JSON(http.StatusOK, branches)
 }
@@ -1029,15 +1314,23 @@ func HandleGetTags(c *gin.Context) {
 	}
 
 	if projectPath == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+
+	if httpcache.NotModified(c, gitRefsETag(c, projectPath)) {
 		return
 	}
 
 	allTags, err := getTags(projectPath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListTagsFailed, err)
 		return
 	}
+	loc := locale.FromContext(c)
+	for i := range allTags {
+		allTags[i].Date = locale.VCSDate(allTags[i].Date, loc)
+	}
 
 	// if there is filter condition, filter tags
 	var filteredTags []types.TagResponse
@@ -1107,16 +1400,16 @@ func HandleSyncBranches(c *gin.Context) {
 	}
 
 	if projectPath == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
 		return
 	}
 
 	if err := syncBranches(projectPath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSyncBranchesFailed, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Branches synced successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeBranchesSynced), "code": i18n.CodeBranchesSynced})
 }
 
 // DeleteBranch delete local branch
@@ -1124,26 +1417,21 @@ func HandleDeleteBranch(c *gin.Context) {
 	projectName := c.Param("name")
 	branchName := c.Param("branchName")
 
-	// find project path
-	var projectPath string
-	for _, proj := range types.GoHookVersionData.Projects {
-		if proj.Name == projectName && proj.Enabled {
-			projectPath = proj.Path
-			break
-		}
+	project := findEnabledProject(projectName)
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
 	}
-
-	if projectPath == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+	if !enforceProtection(c, project, "branch", branchName, protectDelete) {
 		return
 	}
 
-	if err := deleteBranch(projectPath, branchName); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := deleteBranch(project.Path, branchName); err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDeleteBranchFailed, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Branch deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeBranchDeleted), "code": i18n.CodeBranchDeleted})
 }
 
 // SwitchBranch switch branch
@@ -1155,7 +1443,7 @@ func HandleSwitchBranch(c *gin.Context) {
 		Force  bool   `json:"force"` // force switch, discard local changes
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, nil)
 		return
 	}
 
@@ -1167,11 +1455,9 @@ func HandleSwitchBranch(c *gin.Context) {
 
 	// find project path
 	var projectPath string
-	for _, proj := range types.GoHookVersionData.Projects {
-		if proj.Name == projectName && proj.Enabled {
-			projectPath = proj.Path
-			break
-		}
+	project := findEnabledProject(projectName)
+	if project != nil {
+		projectPath = project.Path
 	}
 
 	if projectPath == "" {
@@ -1188,17 +1474,28 @@ func HandleSwitchBranch(c *gin.Context) {
 			fmt.Sprintf("Switch branch failed: Project %s not found", projectName), // description
 			middleware.GetClientIP(c), // ipAddress
 		)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
 		return
 	}
 
 	// get current branch for logging
 	currentBranch := ""
-	if gitStatus, err := getGitStatus(projectPath); err == nil {
+	if gitStatus, err := projectStatus(project); err == nil {
 		currentBranch = gitStatus.CurrentBranch
 	}
 
-	if err := switchBranch(projectPath, req.Branch, req.Force); err != nil {
+	if req.Force && !enforceProtection(c, project, "branch", req.Branch, protectForceSwitch) {
+		return
+	}
+
+	err := checkPin(project)
+	if err == nil {
+		err = preflightDeploy(project)
+	}
+	if err == nil {
+		err = switchProjectBranch(project, req.Branch, req.Force)
+	}
+	if err != nil {
 		// log failed branch switch attempt
 		database.LogProjectAction(
 			projectName,                        // projectName
@@ -1227,7 +1524,7 @@ func HandleSwitchBranch(c *gin.Context) {
 		}
 		stream.Global.Broadcast(wsMessage)
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, deployErrorStatus(err), i18n.CodeSwitchBranchFailed, err)
 		return
 	}
 
@@ -1258,7 +1555,7 @@ func HandleSwitchBranch(c *gin.Context) {
 	}
 	stream.Global.Broadcast(wsMessage)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Branch switched successfully", "branch": req.Branch})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeBranchSwitched), "code": i18n.CodeBranchSwitched, "branch": req.Branch})
 }
 
 // SyncTags sync remote tags
@@ -1275,16 +1572,16 @@ func HandleSyncTags(c *gin.Context) {
 	}
 
 	if projectPath == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
 		return
 	}
 
 	if err := syncTags(projectPath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSyncTagsFailed, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Tags synced successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeTagsSynced), "code": i18n.CodeTagsSynced})
 }
 
 // SwitchTag switch tag
@@ -1296,7 +1593,7 @@ func HandleSwitchTag(c *gin.Context) {
 		Force bool   `json:"force"` // force switch, discard local changes
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, nil)
 		return
 	}
 
@@ -1309,11 +1606,9 @@ func HandleSwitchTag(c *gin.Context) {
 
 	// find project path
 	var projectPath string
-	for _, proj := range types.GoHookVersionData.Projects {
-		if proj.Name == projectName && proj.Enabled {
-			projectPath = proj.Path
-			break
-		}
+	project := findEnabledProject(projectName)
+	if project != nil {
+		projectPath = project.Path
 	}
 
 	if projectPath == "" {
@@ -1330,7 +1625,7 @@ func HandleSwitchTag(c *gin.Context) {
 			fmt.Sprintf("标签切换失败：项目 %s 未找到", projectName), // description
 			middleware.GetClientIP(c), // ipAddress
 		)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
 		return
 	}
 
@@ -1346,7 +1641,7 @@ func HandleSwitchTag(c *gin.Context) {
 
 	// if not on a tag, get current branch
 	if currentTag == "" {
-		if gitStatus, err := getGitStatus(projectPath); err == nil {
+		if gitStatus, err := projectStatus(project); err == nil {
 			currentBranch = gitStatus.CurrentBranch
 			currentCommit = gitStatus.LastCommit
 		}
@@ -1365,7 +1660,18 @@ func HandleSwitchTag(c *gin.Context) {
 		currentPosition = "Unknown position"
 	}
 
-	if err := switchTag(projectPath, req.Tag, req.Force); err != nil {
+	if req.Force && !enforceProtection(c, project, "tag", req.Tag, protectForceSwitch) {
+		return
+	}
+
+	err := checkPin(project)
+	if err == nil {
+		err = preflightDeploy(project)
+	}
+	if err == nil {
+		err = switchProjectRevision(project, req.Tag, req.Force)
+	}
+	if err != nil {
 		// log failed project action
 		database.LogProjectAction(
 			projectName,
@@ -1394,7 +1700,7 @@ func HandleSwitchTag(c *gin.Context) {
 		}
 		stream.Global.Broadcast(wsMessage)
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, deployErrorStatus(err), i18n.CodeSwitchTagFailed, err)
 		return
 	}
 
@@ -1434,7 +1740,7 @@ func HandleSwitchTag(c *gin.Context) {
 	}
 	stream.Global.Broadcast(wsMessage)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Tag switched successfully", "tag": req.Tag})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeTagSwitched), "code": i18n.CodeTagSwitched, "tag": req.Tag})
 }
 
 // DeleteTag delete local and remote tag
@@ -1472,7 +1778,10 @@ func HandleDeleteTag(c *gin.Context) {
 			fmt.Sprintf("Delete tag failed: project %s not found", projectName), // description
 			middleware.GetClientIP(c), // ipAddress
 		)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
+	}
+	if !enforceProtection(c, findEnabledProject(projectName), "tag", tagName, protectDelete) {
 		return
 	}
 
@@ -1489,9 +1798,9 @@ func HandleDeleteTag(c *gin.Context) {
 	}
 
 	// get tag creation date
-	if output, err := execGitCommandOutput(projectPath, "log", "-1", "--format=%ci", tagName); err == nil {
-		if t, err := time.Parse("2006-01-02 15:04:05 -0700", strings.TrimSpace(string(output))); err == nil {
-			tagDate = t.Format("2006-01-02 15:04")
+	if output, err := execGitCommandOutput(projectPath, "log", "-1", "--format=%cI", tagName); err == nil {
+		if t, err := locale.ParseVCSDate(strings.TrimSpace(string(output))); err == nil {
+			tagDate = t.In(locale.Server()).Format("2006-01-02 15:04")
 		}
 	}
 
@@ -1524,7 +1833,7 @@ func HandleDeleteTag(c *gin.Context) {
 		}
 		stream.Global.Broadcast(wsMessage)
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDeleteTagFailed, err)
 		return
 	}
 
@@ -1555,7 +1864,7 @@ func HandleDeleteTag(c *gin.Context) {
 	}
 	stream.Global.Broadcast(wsMessage)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeTagDeleted), "code": i18n.CodeTagDeleted})
 }
 
 // DeleteLocalTag delete local tag
@@ -1563,26 +1872,21 @@ func HandleDeleteLocalTag(c *gin.Context) {
 	projectName := c.Param("name")
 	tagName := c.Param("tagName")
 
-	// find project path
-	var projectPath string
-	for _, proj := range types.GoHookVersionData.Projects {
-		if proj.Name == projectName && proj.Enabled {
-			projectPath = proj.Path
-			break
-		}
+	project := findEnabledProject(projectName)
+	if project == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
+		return
 	}
-
-	if projectPath == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+	if !enforceProtection(c, project, "tag", tagName, protectDelete) {
 		return
 	}
 
-	if err := deleteLocalTag(projectPath, tagName); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := deleteLocalTag(project.Path, tagName); err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeDeleteTagFailed, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeTagDeleted), "code": i18n.CodeTagDeleted})
 }
 
 // InitGitRepository initialize git repository
@@ -1601,7 +1905,7 @@ func HandleInitGitRepository(c *gin.Context) {
 
 	if projectPath == "" {
 		fmt.Printf("Git initialization failed: project not found, project name=%s\n", projectName)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
 		return
 	}
 
@@ -1611,24 +1915,15 @@ func HandleInitGitRepository(c *gin.Context) {
 		fmt.Printf("Git initialization failed: project name=%s, path=%s, error=%v\n", projectName, projectPath, err)
 		if errors.Is(err, errProjectPathNotWritable) {
 			username, group := currentServiceUserAndGroup()
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": fmt.Sprintf(
-					"目录没有写入权限：%s。请确保运行 GoHook 的用户（%s）对此目录有写权限。参考：sudo chown -R %s:%s %s",
-					projectPath,
-					username,
-					username,
-					group,
-					projectPath,
-				),
-			})
+			i18n.JSONErrorf(c, http.StatusForbidden, i18n.CodeProjectPathNotWritable, projectPath, username, username, group, projectPath)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeInitGitFailed, err)
 		return
 	}
 
 	fmt.Printf("Git initialization successful: project name=%s, path=%s\n", projectName, projectPath)
-	c.JSON(http.StatusOK, gin.H{"message": "Git repository initialized successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeGitInitialized), "code": i18n.CodeGitInitialized})
 }
 
 func HandleSetRemote(c *gin.Context) {
@@ -1638,12 +1933,12 @@ func HandleSetRemote(c *gin.Context) {
 		RemoteUrl string `json:"remoteUrl"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, nil)
 		return
 	}
 
 	if req.RemoteUrl == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Remote repository URL cannot be empty"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeRemoteURLRequired, nil)
 		return
 	}
 
@@ -1657,16 +1952,16 @@ func HandleSetRemote(c *gin.Context) {
 	}
 
 	if projectPath == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
 		return
 	}
 
 	if err := setRemote(projectPath, req.RemoteUrl); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSetRemoteFailed, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Remote repository set successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeRemoteSet), "code": i18n.CodeRemoteSet})
 }
 
 func HandleGetRemote(c *gin.Context) {
@@ -1682,71 +1977,153 @@ func HandleGetRemote(c *gin.Context) {
 	}
 
 	if projectPath == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeProjectNotFound, nil)
 		return
 	}
 
 	remoteURL, err := getRemote(projectPath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeGetRemoteFailed, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"url": remoteURL})
 }
 
+// HandleGetProjects list the enabled projects visible to the request in the order of the
+// config unless ?sort names another, paginated with ?limit and ?offset and trimmed to ?fields
 func HandleGetProjects(c *gin.Context) {
+	if projectsNotModified(c) {
+		return
+	}
+
 	// load config file every time get projects list
 	if err := config.LoadVersionConfig(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Load version config failed: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadVersionConfigFailed, err)
 		return
 	}
 
 	if types.GoHookVersionData == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Version config not loaded"})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeVersionConfigNotLoaded, nil)
 		return
 	}
 
-	var projects []types.VersionResponse
+	opts, err := listing.Parse(c, "name", "last-used", "status")
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidListQuery, err)
+		return
+	}
+
+	loc := locale.FromContext(c)
+	lastUsed, err := database.NewLogService().InZone(loc).LastUsed(database.HookTypeGitHook)
+	if err != nil {
+		log.Printf("failed to read the last GitHook deliveries: %v", err)
+	}
+	projects := []types.VersionResponse{}
 	for _, proj := range types.GoHookVersionData.Projects {
-		if !proj.Enabled {
+		if !proj.Enabled || !namespace.Allowed(c, proj.Namespace) {
 			continue
 		}
-
-		gitStatus, err := getGitStatus(proj.Path)
-		if err != nil {
-			// if not Git repository, still display but mark as non-Git project
-			projects = append(projects, types.VersionResponse{
-				Name:        proj.Name,
-				Path:        proj.Path,
-				Description: proj.Description,
-				Mode:        "none",
-				Status:      "not-git",
-				Sync:        proj.Sync,
-			})
-			continue
+		var projLastUsed *string
+		if at, ok := lastUsed[proj.Name]; ok {
+			projLastUsed = &at
 		}
+		projects = append(projects, projectResponse(&proj, loc, projLastUsed))
+	}
 
-		gitStatus.Name = proj.Name
-		gitStatus.Path = proj.Path
-		gitStatus.Description = proj.Description
-		gitStatus.Enhook = proj.Enhook
-		gitStatus.Hookmode = proj.Hookmode
-		gitStatus.Hookbranch = proj.Hookbranch
-		gitStatus.Hooksecret = proj.Hooksecret
-		gitStatus.ForceSync = proj.ForceSync
-		gitStatus.Sync = proj.Sync
-		projects = append(projects, *gitStatus)
+	switch opts.Sort {
+	case "name":
+		opts.SortBy(projects, func(i, j int) int { return strings.Compare(projects[i].Name, projects[j].Name) })
+	case "last-used":
+		opts.SortBy(projects, func(i, j int) int { return listing.CompareTimes(projects[i].LastUsed, projects[j].LastUsed) })
+	case "status":
+		opts.SortBy(projects, func(i, j int) int { return strings.Compare(projects[i].Status, projects[j].Status) })
 	}
+	if err := listing.Respond(c, opts, projects); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidListQuery, err)
+	}
+}
 
-	c.JSON(http.StatusOK, projects)
+// projectResponse project in the format of the project list with its working copy status,
+// dates in loc; lastUsed is its last GitHook delivery
+func projectResponse(proj *types.ProjectConfig, loc *time.Location, lastUsed *string) types.VersionResponse {
+	gitStatus, err := projectStatus(proj)
+	if err != nil {
+		// if not Git repository, still display but mark as non-Git project
+		return types.VersionResponse{
+			Name:           proj.Name,
+			Namespace:      namespace.Normalize(proj.Namespace),
+			Path:           proj.Path,
+			Description:    proj.Description,
+			VCS:            proj.VCS,
+			Mode:           "none",
+			Status:         "not-git",
+			LastUsed:       lastUsed,
+			EncryptEnv:     proj.EncryptEnv,
+			Service:        proj.Service,
+			Sync:           proj.Sync,
+			PauseWindows:   proj.PauseWindows,
+			Promotion:      proj.Promotion,
+			Preflight:      proj.Preflight,
+			Snapshots:      proj.Snapshots,
+			Protection:     proj.Protection,
+			GitMaintenance: proj.GitMaintenance,
+			Signatures:     proj.Signatures,
+			Kubernetes:     proj.Kubernetes.Redacted(),
+			Compose:        proj.Compose,
+			Migrations:     proj.Migrations,
+			Releases:       proj.Releases,
+			Artifact:       proj.Artifact.Redacted(),
+			HealthCheck:    proj.HealthCheck,
+			Pin:            proj.Pin,
+			Provider:       proj.Provider.Redacted(),
+			Poll:           proj.Poll,
+			Revision:       ProjectRevision(proj),
+		}
+	}
+
+	gitStatus.LastCommitTime = locale.VCSDate(gitStatus.LastCommitTime, loc)
+	gitStatus.LastUsed = lastUsed
+	gitStatus.Name = proj.Name
+	gitStatus.Namespace = namespace.Normalize(proj.Namespace)
+	gitStatus.Path = proj.Path
+	gitStatus.Description = proj.Description
+	gitStatus.Enhook = proj.Enhook
+	gitStatus.Hookmode = proj.Hookmode
+	gitStatus.Hookbranch = proj.Hookbranch
+	gitStatus.Hooksecret = proj.Hooksecret
+	if proj.HookRotation.Active(time.Now()) {
+		gitStatus.SecretExpires = &proj.HookRotation.Expires
+	}
+	gitStatus.Hookpaths = proj.Hookpaths
+	gitStatus.Monorepo = proj.Monorepo
+	gitStatus.ForceSync = proj.ForceSync
+	gitStatus.EncryptEnv = proj.EncryptEnv
+	gitStatus.Service = proj.Service
+	gitStatus.Sync = proj.Sync
+	gitStatus.PauseWindows = proj.PauseWindows
+	gitStatus.Promotion = proj.Promotion
+	gitStatus.Preflight = proj.Preflight
+	gitStatus.Snapshots = proj.Snapshots
+	gitStatus.Protection = proj.Protection
+	gitStatus.GitMaintenance = proj.GitMaintenance
+	gitStatus.Signatures = proj.Signatures
+	gitStatus.Kubernetes = proj.Kubernetes.Redacted()
+	gitStatus.Compose = proj.Compose
+	gitStatus.Migrations = proj.Migrations
+	gitStatus.Releases = proj.Releases
+	gitStatus.Artifact = proj.Artifact.Redacted()
+	gitStatus.HealthCheck = proj.HealthCheck
+	gitStatus.Pin = proj.Pin
+	gitStatus.Provider = proj.Provider.Redacted()
+	gitStatus.Poll = proj.Poll
+	gitStatus.Revision = ProjectRevision(proj)
+	return *gitStatus
 }
 
 func HandleReloadConfig(c *gin.Context) {
 	if err := config.LoadVersionConfig(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Load version config failed: " + err.Error(),
-		})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadVersionConfigFailed, err)
 		return
 	}
 
@@ -1760,7 +2137,8 @@ func HandleReloadConfig(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":      "Version config loaded successfully",
+		"message":      i18n.Message(c, i18n.CodeVersionConfigReloaded),
+		"code":         i18n.CodeVersionConfigReloaded,
 		"projectCount": projectCount,
 	})
 }
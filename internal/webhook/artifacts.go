@@ -0,0 +1,233 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultArtifactMaxFileBytes  = 1 << 20
+	defaultArtifactMaxTotalBytes = 5 << 20
+)
+
+// ArtifactsConfig files collected after a run and stored with its execution log
+type ArtifactsConfig struct {
+	Paths         []string `json:"paths"`                     // file paths or globs, relative to command-working-directory
+	MaxFileBytes  int64    `json:"max-file-bytes,omitempty"`  // larger files are truncated, default 1 MiB
+	MaxTotalBytes int64    `json:"max-total-bytes,omitempty"` // stored bytes per run, default 5 MiB
+}
+
+// Validate check the artifact globs and size caps
+func (a *ArtifactsConfig) Validate() error {
+	if len(a.Paths) == 0 {
+		return fmt.Errorf("artifacts paths are required")
+	}
+	for _, p := range a.Paths {
+		if strings.TrimSpace(p) == "" {
+			return fmt.Errorf("artifacts path can not be empty")
+		}
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid artifacts path %q: %v", p, err)
+		}
+	}
+	if a.MaxFileBytes < 0 || a.MaxTotalBytes < 0 {
+		return fmt.Errorf("artifacts size caps can not be negative")
+	}
+	return nil
+}
+
+func (a *ArtifactsConfig) maxFileBytes() int64 {
+	if a.MaxFileBytes > 0 {
+		return a.MaxFileBytes
+	}
+	return defaultArtifactMaxFileBytes
+}
+
+func (a *ArtifactsConfig) maxTotalBytes() int64 {
+	if a.MaxTotalBytes > 0 {
+		return a.MaxTotalBytes
+	}
+	return defaultArtifactMaxTotalBytes
+}
+
+// collectArtifacts read the artifact files of h after a run. Only regular files inside the
+// working directory are collected; files above max-file-bytes are truncated and collection
+// stops at max-total-bytes.
+func collectArtifacts(h *Hook, requestID string) []database.HookArtifact {
+	if h.Artifacts == nil || h.Forward != nil {
+		return nil
+	}
+	dir := h.CommandWorkingDirectory
+	if dir == "" {
+		dir = "."
+	}
+	root := resolvePath(dir)
+
+	var (
+		artifacts []database.HookArtifact
+		seen      = map[string]bool{}
+		remaining = h.Artifacts.maxTotalBytes()
+	)
+	for _, pattern := range h.Artifacts.Paths {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(root, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Printf("[%s] invalid artifacts path %q: %v", requestID, pattern, err)
+			continue
+		}
+		for _, match := range matches {
+			path := resolvePath(match)
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			if !pathWithin(path, root) {
+				log.Printf("[%s] artifact %s is outside the working directory, skipped", requestID, match)
+				continue
+			}
+			if remaining <= 0 {
+				log.Printf("[%s] artifacts of %s exceed max-total-bytes, %s skipped", requestID, h.ID, match)
+				continue
+			}
+
+			artifact, err := readArtifact(path, root, min(h.Artifacts.maxFileBytes(), remaining))
+			if err != nil {
+				if err != errNotRegular {
+					log.Printf("[%s] error reading artifact %s: %v", requestID, match, err)
+				}
+				continue
+			}
+			remaining -= int64(len(artifact.Data))
+			artifacts = append(artifacts, *artifact)
+		}
+	}
+	return artifacts
+}
+
+var errNotRegular = errors.New("not a regular file")
+
+// readArtifact read at most limit bytes of the file path
+func readArtifact(path, root string, limit int64) (*database.HookArtifact, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, errNotRegular
+	}
+	data, err := io.ReadAll(io.LimitReader(f, limit))
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := filepath.Rel(root, path)
+	if err != nil {
+		return nil, err
+	}
+	return &database.HookArtifact{
+		Name:      filepath.ToSlash(name),
+		Size:      info.Size(),
+		Truncated: info.Size() > int64(len(data)),
+		Data:      data,
+	}, nil
+}
+
+// saveArtifacts collect the artifacts of h and store them with the execution log logID
+func saveArtifacts(h *Hook, requestID string, logID uint) {
+	if h.Artifacts == nil || logID == 0 {
+		return
+	}
+	artifacts := collectArtifacts(h, requestID)
+	if err := database.SaveHookArtifacts(logID, artifacts); err != nil {
+		log.Printf("[%s] %v", requestID, err)
+		return
+	}
+	if len(artifacts) > 0 {
+		log.Printf("[%s] stored %d artifacts of %s", requestID, len(artifacts), h.ID)
+	}
+}
+
+// hookExecution execution log of the :execID param belonging to the :id hook, answers the error itself
+func hookExecution(c *gin.Context) (*database.HookLog, bool) {
+	id, err := strconv.ParseUint(c.Param("execID"), 10, 64)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidExecutionID, nil)
+		return nil, false
+	}
+	hookLog, err := database.GetHookExecution(c.Param("id"), uint(id))
+	switch {
+	case errors.Is(err, database.ErrArtifactsUnavailable):
+		i18n.JSONError(c, http.StatusServiceUnavailable, i18n.CodeArtifactsUnavailable, err)
+		return nil, false
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeExecutionNotFound, nil)
+		return nil, false
+	case err != nil:
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadExecutionFailed, err)
+		return nil, false
+	}
+	return hookLog, true
+}
+
+// HandleListHookArtifacts list the artifacts collected for an execution of the hook
+func HandleListHookArtifacts(c *gin.Context) {
+	hookLog, ok := hookExecution(c)
+	if !ok {
+		return
+	}
+	artifacts, err := database.ListHookArtifacts(hookLog.ID)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeListArtifactsFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, artifacts)
+}
+
+// HandleGetHookArtifact download one artifact of an execution of the hook
+func HandleGetHookArtifact(c *gin.Context) {
+	hookLog, ok := hookExecution(c)
+	if !ok {
+		return
+	}
+	name := strings.TrimPrefix(c.Param("name"), "/")
+	artifact, err := database.GetHookArtifact(hookLog.ID, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeArtifactNotFound, nil)
+		return
+	}
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeLoadArtifactFailed, err)
+		return
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(artifact.Name))
+	if contentType == "" {
+		contentType = http.DetectContentType(artifact.Data)
+	}
+	if artifact.Truncated {
+		c.Header("X-GoHook-Artifact-Truncated", "true")
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(artifact.Name)}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, contentType, artifact.Data)
+}
@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCollectArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	files := map[string]string{
+		"report.html":     "<h1>ok</h1>",
+		"logs/build.log":  strings.Repeat("l", 100),
+		"logs/test.log":   "passed",
+		"logs/other.txt":  "not matched",
+		"dist/app.tar.gz": strings.Repeat("z", 50),
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(dir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Hook{
+		ID:                      "build",
+		CommandWorkingDirectory: dir,
+		Artifacts: &ArtifactsConfig{
+			Paths:         []string{"report.html", "logs/*.log", "link.txt", filepath.Join(outside, "*"), "dist/*", "report.html"},
+			MaxFileBytes:  40,
+			MaxTotalBytes: 80,
+		},
+	}
+	if err := h.Artifacts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	truncated := map[string]bool{}
+	for _, a := range collectArtifacts(h, "1") {
+		got[a.Name] = string(a.Data)
+		truncated[a.Name] = a.Truncated
+	}
+	want := map[string]string{
+		"report.html":     "<h1>ok</h1>",
+		"logs/build.log":  strings.Repeat("l", 40),
+		"logs/test.log":   "passed",
+		"dist/app.tar.gz": strings.Repeat("z", 80-11-40-6),
+	}
+	if len(got) != len(want) {
+		t.Errorf("collected %v, want %v", got, want)
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("%s = %q, want %q", name, got[name], content)
+		}
+	}
+	if !truncated["logs/build.log"] || truncated["logs/test.log"] {
+		t.Errorf("truncated = %v", truncated)
+	}
+
+	for _, bad := range []*ArtifactsConfig{{}, {Paths: []string{" "}}, {Paths: []string{"[a"}}, {Paths: []string{"a"}, MaxFileBytes: -1}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", bad)
+		}
+	}
+}
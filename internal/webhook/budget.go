@@ -0,0 +1,374 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/stream"
+)
+
+// budgetWindow period failures and durations are counted over
+const budgetWindow = time.Hour
+
+// events of stream.HookBudgetMessage
+const (
+	BudgetFailures      = "failures"
+	BudgetDuration      = "duration"
+	BudgetCircuitOpen   = "circuit-open"
+	BudgetCircuitClosed = "circuit-closed"
+)
+
+// BudgetConfig execution budget of a hook: alerts when it fails too often or runs too long
+// within an hour, and an optional circuit breaker pausing a hook that keeps failing
+type BudgetConfig struct {
+	MaxFailuresPerHour int    `json:"max-failures-per-hour,omitempty"`
+	MaxAverageDuration string `json:"max-average-duration,omitempty"` // average run time over the last hour, e.g. 2m
+	CircuitBreaker     bool   `json:"circuit-breaker,omitempty"`      // pause the hook once max-failures-per-hour is reached
+	Cooldown           string `json:"cooldown,omitempty"`             // the breaker closes again after, empty: only manually
+}
+
+// Validate check the thresholds and durations of the budget
+func (b *BudgetConfig) Validate() error {
+	if b == nil {
+		return nil
+	}
+	if b.MaxFailuresPerHour < 0 {
+		return fmt.Errorf("invalid budget max-failures-per-hour: %d", b.MaxFailuresPerHour)
+	}
+	for name, value := range map[string]string{"max-average-duration": b.MaxAverageDuration, "cooldown": b.Cooldown} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("invalid budget %s: %s", name, value)
+		}
+	}
+	if b.MaxFailuresPerHour == 0 && b.MaxAverageDuration == "" {
+		return fmt.Errorf("budget needs max-failures-per-hour or max-average-duration")
+	}
+	if b.CircuitBreaker && b.MaxFailuresPerHour == 0 {
+		return fmt.Errorf("budget circuit-breaker requires max-failures-per-hour")
+	}
+	if b.Cooldown != "" && !b.CircuitBreaker {
+		return fmt.Errorf("budget cooldown requires circuit-breaker")
+	}
+	return nil
+}
+
+// maxAverage parsed max-average-duration, 0 when not set
+func (b *BudgetConfig) maxAverage() time.Duration {
+	d, _ := time.ParseDuration(b.MaxAverageDuration)
+	return d
+}
+
+// cooldown parsed cooldown, 0 when the breaker is only closed manually
+func (b *BudgetConfig) cooldown() time.Duration {
+	d, _ := time.ParseDuration(b.Cooldown)
+	return d
+}
+
+// budgetBucket executions of one minute
+type budgetBucket struct {
+	minute   int64
+	runs     int
+	failures int
+	total    time.Duration
+}
+
+// budgetState executions of a hook within the budget window and its breaker
+type budgetState struct {
+	buckets         [60]budgetBucket // ring indexed by minute
+	failuresAlerted bool
+	durationAlerted bool
+	openedAt        time.Time // zero while the breaker is closed
+	openUntil       time.Time // zero: closed manually only
+}
+
+// usage executions, failures and average duration within the window ending at now
+func (s *budgetState) usage(now time.Time) (runs, failures int, average time.Duration) {
+	var total time.Duration
+	oldest := now.Add(-budgetWindow).Unix() / 60
+	for _, b := range s.buckets {
+		if b.minute > oldest {
+			runs += b.runs
+			failures += b.failures
+			total += b.total
+		}
+	}
+	if runs > 0 {
+		average = total / time.Duration(runs)
+	}
+	return runs, failures, average
+}
+
+var budgets = struct {
+	sync.Mutex
+	states map[string]*budgetState
+}{states: map[string]*budgetState{}}
+
+// BudgetStatus usage of the budget of a hook in the last hour and its circuit breaker
+type BudgetStatus struct {
+	Budget            *BudgetConfig `json:"budget"`
+	Executions        int           `json:"executions"`
+	Failures          int           `json:"failures"`
+	AverageDurationMs int64         `json:"averageDurationMs"`
+	CircuitOpen       bool          `json:"circuitOpen"`
+	OpenedAt          *time.Time    `json:"openedAt,omitempty"`
+	OpenUntil         *time.Time    `json:"openUntil,omitempty"` // unset while open: closed manually only
+}
+
+// recordBudget count an execution of h against its budget, alerting when a threshold is
+// reached and opening the circuit breaker
+func recordBudget(h *Hook, duration time.Duration, failed bool, now time.Time) {
+	if h.Budget == nil {
+		return
+	}
+	budgets.Lock()
+	s := budgets.states[h.ID]
+	if s == nil {
+		s = &budgetState{}
+		budgets.states[h.ID] = s
+	}
+	minute := now.Unix() / 60
+	b := &s.buckets[minute%int64(len(s.buckets))]
+	if b.minute != minute {
+		*b = budgetBucket{minute: minute}
+	}
+	b.runs++
+	b.total += duration
+	if failed {
+		b.failures++
+	}
+
+	_, failures, average := s.usage(now)
+	var alerts []stream.HookBudgetMessage
+	if limit := h.Budget.MaxFailuresPerHour; limit > 0 {
+		breached := failures >= limit
+		if breached && !s.failuresAlerted {
+			alerts = append(alerts, stream.HookBudgetMessage{HookID: h.ID, Event: BudgetFailures, Failures: failures,
+				Limit: fmt.Sprintf("%d failures per hour", limit)})
+		}
+		s.failuresAlerted = breached
+		if breached && failed && h.Budget.CircuitBreaker && s.openedAt.IsZero() {
+			s.openedAt, s.openUntil = now, time.Time{}
+			msg := stream.HookBudgetMessage{HookID: h.ID, Event: BudgetCircuitOpen, Failures: failures,
+				Limit: fmt.Sprintf("%d failures per hour", limit)}
+			if cooldown := h.Budget.cooldown(); cooldown > 0 {
+				s.openUntil = now.Add(cooldown)
+				until := s.openUntil
+				msg.Until = &until
+			}
+			alerts = append(alerts, msg)
+		}
+	}
+	if limit := h.Budget.maxAverage(); limit > 0 {
+		breached := average > limit
+		if breached && !s.durationAlerted {
+			alerts = append(alerts, stream.HookBudgetMessage{HookID: h.ID, Event: BudgetDuration,
+				AverageDurationMs: average.Milliseconds(), Limit: limit.String() + " on average"})
+		}
+		s.durationAlerted = breached
+	}
+	budgets.Unlock()
+
+	for _, msg := range alerts {
+		stream.Global.Broadcast(stream.WsMessage{Type: "hook_budget", Timestamp: now, Data: msg})
+	}
+}
+
+// CircuitOpen report whether the circuit breaker of the hook is open and until when, a zero
+// time when it stays open until closed manually. A breaker whose cooldown ended closes.
+// The failures of the last hour are kept, so the next failure opens it again.
+func CircuitOpen(h *Hook) (bool, time.Time) {
+	if h.Budget == nil || !h.Budget.CircuitBreaker {
+		return false, time.Time{}
+	}
+	budgets.Lock()
+	s := budgets.states[h.ID]
+	if s == nil || s.openedAt.IsZero() {
+		budgets.Unlock()
+		return false, time.Time{}
+	}
+	now := time.Now()
+	if s.openUntil.IsZero() || now.Before(s.openUntil) {
+		until := s.openUntil
+		budgets.Unlock()
+		return true, until
+	}
+	s.openedAt, s.openUntil = time.Time{}, time.Time{}
+	budgets.Unlock()
+
+	stream.Global.Broadcast(stream.WsMessage{Type: "hook_budget", Timestamp: now,
+		Data: stream.HookBudgetMessage{HookID: h.ID, Event: BudgetCircuitClosed, By: "cooldown"}})
+	return false, time.Time{}
+}
+
+// closeCircuit close the breaker of a hook and forget its executions, reports whether it was open
+func closeCircuit(hookID string) bool {
+	budgets.Lock()
+	defer budgets.Unlock()
+	s := budgets.states[hookID]
+	if s == nil {
+		return false
+	}
+	delete(budgets.states, hookID)
+	return !s.openedAt.IsZero()
+}
+
+// budgetStatus current budget usage of h
+func budgetStatus(h *Hook) BudgetStatus {
+	open, _ := CircuitOpen(h)
+	status := BudgetStatus{Budget: h.Budget, CircuitOpen: open}
+	budgets.Lock()
+	defer budgets.Unlock()
+	s := budgets.states[h.ID]
+	if s == nil {
+		return status
+	}
+	runs, failures, average := s.usage(time.Now())
+	status.Executions, status.Failures, status.AverageDurationMs = runs, failures, average.Milliseconds()
+	if open {
+		openedAt := s.openedAt
+		status.OpenedAt = &openedAt
+		if !s.openUntil.IsZero() {
+			until := s.openUntil
+			status.OpenUntil = &until
+		}
+	}
+	return status
+}
+
+// HandleGetHookBudget get the budget of a hook, its usage in the last hour and its circuit breaker
+func HandleGetHookBudget(c *gin.Context) {
+	h := HookManager.MatchLoadedHook(c.Param("id"))
+	if h == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+	c.JSON(http.StatusOK, budgetStatus(h))
+}
+
+// HandleUpdateHookBudget set or clear the execution budget of a hook, a null budget disables it
+func HandleUpdateHookBudget(c *gin.Context) {
+	hookID := c.Param("id")
+	existingHook := HookManager.MatchLoadedHook(hookID)
+	if existingHook == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+
+	var request struct {
+		Budget *BudgetConfig `json:"budget"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if err := request.Budget.Validate(); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	originalBudget := existingHook.Budget
+	existingHook.Budget = request.Budget
+
+	username := c.GetString("username")
+	if username == "" {
+		username = "unknown"
+	}
+	if err := HookManager.SaveHookChanges(hookID); err != nil {
+		existingHook.Budget = originalBudget
+		database.LogHookManagement(
+			database.UserActionUpdateHookBudget,
+			hookID,
+			hookID,
+			username,
+			middleware.GetClientIP(c),
+			c.Request.UserAgent(),
+			false,
+			map[string]interface{}{
+				"hookId": hookID,
+				"error":  err.Error(),
+			},
+		)
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
+		return
+	}
+	if request.Budget == nil || !request.Budget.CircuitBreaker {
+		closeCircuit(hookID)
+	}
+
+	database.LogHookManagement(
+		database.UserActionUpdateHookBudget,
+		hookID,
+		hookID,
+		username,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		true,
+		map[string]interface{}{
+			"hookId": hookID,
+			"changes": map[string]interface{}{
+				"budget": map[string]interface{}{
+					"old": originalBudget,
+					"new": request.Budget,
+				},
+			},
+		},
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeHookBudgetUpdated),
+		"code":    i18n.CodeHookBudgetUpdated,
+		"hook":    convertHookToResponse(existingHook),
+	})
+}
+
+// HandleResetHookCircuit close the circuit breaker of a hook and reset its budget usage
+func HandleResetHookCircuit(c *gin.Context) {
+	hookID := c.Param("id")
+	h := HookManager.MatchLoadedHook(hookID)
+	if h == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+	username := c.GetString("username")
+	if username == "" {
+		username = "unknown"
+	}
+	wasOpen := closeCircuit(h.ID)
+	database.LogHookManagement(
+		database.UserActionResetHookCircuit,
+		h.ID,
+		h.ID,
+		username,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		true,
+		map[string]interface{}{
+			"hookId":  h.ID,
+			"wasOpen": wasOpen,
+		},
+	)
+	if wasOpen {
+		stream.Global.Broadcast(stream.WsMessage{Type: "hook_budget", Timestamp: time.Now(),
+			Data: stream.HookBudgetMessage{HookID: h.ID, Event: BudgetCircuitClosed, By: username}})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeHookCircuitClosed),
+		"code":    i18n.CodeHookCircuitClosed,
+		"wasOpen": wasOpen,
+	})
+}
+
+// circuitOpen report whether the circuit breaker of h is open
+func circuitOpen(h *Hook) bool {
+	open, _ := CircuitOpen(h)
+	return open
+}
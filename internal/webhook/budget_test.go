@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudgetValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		budget  *BudgetConfig
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"failures", &BudgetConfig{MaxFailuresPerHour: 5}, false},
+		{"duration", &BudgetConfig{MaxAverageDuration: "2m"}, false},
+		{"breaker", &BudgetConfig{MaxFailuresPerHour: 5, CircuitBreaker: true, Cooldown: "15m"}, false},
+		{"empty", &BudgetConfig{}, true},
+		{"negative failures", &BudgetConfig{MaxFailuresPerHour: -1}, true},
+		{"invalid duration", &BudgetConfig{MaxAverageDuration: "soon"}, true},
+		{"breaker without failures", &BudgetConfig{MaxAverageDuration: "2m", CircuitBreaker: true}, true},
+		{"cooldown without breaker", &BudgetConfig{MaxFailuresPerHour: 5, Cooldown: "15m"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.budget.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBudgetCircuitBreaker(t *testing.T) {
+	h := &Hook{ID: "budget-breaker", Budget: &BudgetConfig{MaxFailuresPerHour: 3, MaxAverageDuration: "1s", CircuitBreaker: true}}
+	defer closeCircuit(h.ID)
+
+	now := time.Now()
+	recordBudget(h, 100*time.Millisecond, true, now.Add(-2*time.Hour)) // outside the window
+	recordBudget(h, 100*time.Millisecond, true, now.Add(-30*time.Minute))
+	recordBudget(h, 100*time.Millisecond, false, now.Add(-10*time.Minute))
+	recordBudget(h, 100*time.Millisecond, true, now)
+	if open, _ := CircuitOpen(h); open {
+		t.Fatal("breaker open below the budget")
+	}
+	status := budgetStatus(h)
+	if status.Executions != 3 || status.Failures != 2 || status.AverageDurationMs != 100 {
+		t.Fatalf("status = %+v", status)
+	}
+
+	recordBudget(h, 5*time.Second, true, now)
+	open, until := CircuitOpen(h)
+	if !open || !until.IsZero() {
+		t.Fatalf("breaker = %v until %v, want open until reset", open, until)
+	}
+	if s := budgets.states[h.ID]; !s.failuresAlerted || !s.durationAlerted {
+		t.Errorf("alerts not recorded: %+v", s)
+	}
+
+	if !closeCircuit(h.ID) {
+		t.Fatal("closeCircuit did not report the open breaker")
+	}
+	if open, _ := CircuitOpen(h); open {
+		t.Fatal("breaker still open after reset")
+	}
+}
+
+func TestBudgetCooldown(t *testing.T) {
+	h := &Hook{ID: "budget-cooldown", Budget: &BudgetConfig{MaxFailuresPerHour: 1, CircuitBreaker: true, Cooldown: "1m"}}
+	defer closeCircuit(h.ID)
+
+	now := time.Now()
+	recordBudget(h, time.Millisecond, true, now)
+	if open, until := CircuitOpen(h); !open || until.Sub(now) != time.Minute {
+		t.Fatalf("breaker = %v until %v, want open for the cooldown", open, until)
+	}
+
+	// the cooldown ends: the breaker closes, the next failure opens it again
+	budgets.Lock()
+	budgets.states[h.ID].openUntil = now.Add(-time.Second)
+	budgets.Unlock()
+	if open, _ := CircuitOpen(h); open {
+		t.Fatal("breaker still open after the cooldown")
+	}
+	recordBudget(h, time.Millisecond, true, time.Now())
+	if open, _ := CircuitOpen(h); !open {
+		t.Fatal("failure after the cooldown did not open the breaker")
+	}
+
+	// hooks without a breaker are never paused
+	h.Budget.CircuitBreaker = false
+	if open, _ := CircuitOpen(h); open {
+		t.Fatal("breaker open while disabled")
+	}
+}
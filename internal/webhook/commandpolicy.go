@@ -0,0 +1,175 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// shellMetacharacters characters a shell uses to chain, substitute or redirect commands
+const shellMetacharacters = ";&|`$<>()\n\r"
+
+// commandPolicy configured execute-command policy, nil when any command may be set
+func commandPolicy() *types.CommandPolicy {
+	cfg := types.GoHookAppConfig
+	if cfg == nil || cfg.Scripts == nil {
+		return nil
+	}
+	return cfg.Scripts.Commands
+}
+
+// CheckCommandPolicy error when execute-command, run in workDir with shell, is refused by
+// scripts.commands in app.yaml. The script roots are checked by CommandAllowed.
+func CheckCommandPolicy(command, workDir, shell string) error {
+	policy := commandPolicy()
+	if policy == nil {
+		return nil
+	}
+	inline := shell != "" && shell != ShellNone
+	if policy.DenyShellMetacharacters && strings.ContainsAny(command, shellMetacharacters) {
+		return fmt.Errorf("command contains shell metacharacters")
+	}
+	if policy.RequireScriptsRoot {
+		if inline {
+			return fmt.Errorf("inline shell commands are not allowed, use a script inside the script roots")
+		}
+		if len(scriptRoots()) == 0 {
+			return fmt.Errorf("no script roots are configured")
+		}
+	}
+	if len(policy.AllowedDirs) == 0 && len(policy.AllowedCommands) == 0 {
+		return nil
+	}
+
+	// an inline command is checked by the program it starts
+	program := command
+	if inline {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			return fmt.Errorf("empty command")
+		}
+		program = fields[0]
+	}
+	path := programPath(program, workDir, inline)
+	if path != "" && commandListed(resolvePath(path), policy) {
+		return nil
+	}
+	return fmt.Errorf("%s is not in the allowed directories or commands", program)
+}
+
+// CommandDeniedError execute-command of an imported or restored hook refused by the script
+// roots or the command policy
+type CommandDeniedError struct {
+	HookID  string
+	Command string
+	Reason  error // refusal of the command policy, nil when the command is outside the script roots
+}
+
+func (e *CommandDeniedError) Error() string {
+	if e.Reason == nil {
+		return fmt.Sprintf("hook %s: path is outside the allowed script roots: %s", e.HookID, e.Command)
+	}
+	return fmt.Sprintf("hook %s: command refused by the command policy: %v", e.HookID, e.Reason)
+}
+
+// CheckHookCommand run the checks of the hook handlers on the execute-command of h, the
+// script roots and the command policy, for hooks that do not come through those handlers
+func CheckHookCommand(h Hook) error {
+	if h.ExecuteCommand == "" {
+		return nil
+	}
+	if !CommandAllowed(h.ExecuteCommand, h.CommandWorkingDirectory, h.Shell) {
+		return &CommandDeniedError{HookID: h.ID, Command: h.ExecuteCommand}
+	}
+	if err := CheckCommandPolicy(h.ExecuteCommand, h.CommandWorkingDirectory, h.Shell); err != nil {
+		return &CommandDeniedError{HookID: h.ID, Command: h.ExecuteCommand, Reason: err}
+	}
+	return nil
+}
+
+// DenyImportedCommand audit and answer 403 like the hook handlers when err holds a
+// CommandDeniedError, false leaves err to the caller
+func DenyImportedCommand(c *gin.Context, err error, action string) bool {
+	var denied *CommandDeniedError
+	if !errors.As(err, &denied) {
+		return false
+	}
+	if denied.Reason == nil {
+		denyScriptPath(c, denied.HookID, denied.Command, action)
+	} else {
+		denyCommand(c, denied.HookID, denied.Command, action, denied.Reason)
+	}
+	return true
+}
+
+// programPath file run for program, inline shell commands look bare names up in PATH and
+// resolve relative paths against workDir like the shell started there does
+func programPath(program, workDir string, inline bool) string {
+	if !inline {
+		return commandPath(program, workDir, "")
+	}
+	if !strings.ContainsRune(program, '/') && !strings.ContainsRune(program, filepath.Separator) {
+		p, _ := exec.LookPath(program)
+		return p
+	}
+	if !filepath.IsAbs(program) && workDir != "" {
+		return filepath.Join(workDir, program)
+	}
+	return program
+}
+
+// commandListed reports whether the resolved path is below an allowed directory or is an
+// allowed command
+func commandListed(path string, policy *types.CommandPolicy) bool {
+	for _, dir := range policy.AllowedDirs {
+		if pathWithin(path, resolvePath(dir)) {
+			return true
+		}
+	}
+	for _, allowed := range policy.AllowedCommands {
+		if !strings.ContainsRune(allowed, '/') && !strings.ContainsRune(allowed, filepath.Separator) {
+			p, err := exec.LookPath(allowed)
+			if err != nil {
+				continue
+			}
+			allowed = p
+		}
+		if resolvePath(allowed) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// denyCommand audit a command refused by the command policy and answer 403
+func denyCommand(c *gin.Context, hookID, command, action string, reason error) {
+	username := c.GetString("username")
+	if username == "" {
+		username = "unknown"
+	}
+	database.LogHookManagement(
+		database.UserActionCommandDenied,
+		hookID,
+		hookID,
+		username,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		false,
+		map[string]interface{}{
+			"hookId":  hookID,
+			"command": command,
+			"action":  action,
+			"reason":  reason.Error(),
+		},
+	)
+	i18n.JSONErrorf(c, http.StatusForbidden, i18n.CodeCommandPolicyDenied, reason)
+}
@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestCheckCommandPolicy(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	root := t.TempDir()
+	scripts := filepath.Join(root, "scripts")
+	if err := os.MkdirAll(scripts, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(scripts, "deploy.sh"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := types.GoHookAppConfig
+	defer func() { types.GoHookAppConfig = saved }()
+
+	types.GoHookAppConfig = &types.AppConfig{}
+	if err := CheckCommandPolicy("rm -rf / ; reboot", "", ShellSh); err != nil {
+		t.Errorf("command refused without a policy: %v", err)
+	}
+
+	allowlist := &types.CommandPolicy{AllowedDirs: []string{scripts}, AllowedCommands: []string{"sh"}, DenyShellMetacharacters: true}
+	strict := &types.CommandPolicy{RequireScriptsRoot: true}
+	tests := []struct {
+		name    string
+		policy  *types.CommandPolicy
+		roots   []string
+		command string
+		workDir string
+		shell   string
+		ok      bool
+	}{
+		{"script in allowed dir", allowlist, nil, filepath.Join(scripts, "deploy.sh"), "", "", true},
+		{"relative script", allowlist, nil, "deploy.sh", scripts, "", true},
+		{"escaping allowed dir", allowlist, nil, "../deploy.sh", scripts, "", false},
+		{"allowed binary by name", allowlist, nil, sh, "", "", true},
+		{"binary not listed", allowlist, nil, "/usr/bin/env", "", "", false},
+		{"inline allowed program", allowlist, nil, "sh -c true", "", ShellBash, true},
+		{"inline other program", allowlist, nil, "curl example.com", "", ShellBash, false},
+		{"metacharacters", allowlist, nil, "sh -c true; reboot", "", ShellBash, false},
+		{"substitution", allowlist, nil, filepath.Join(scripts, "deploy.sh") + " $(id)", "", ShellBash, false},
+		{"script under root", strict, []string{scripts}, filepath.Join(scripts, "deploy.sh"), "", "", true},
+		{"inline refused", strict, []string{scripts}, "echo ok", "", ShellSh, false},
+		{"no roots", strict, nil, filepath.Join(scripts, "deploy.sh"), "", "", false},
+	}
+	for _, tt := range tests {
+		types.GoHookAppConfig = &types.AppConfig{Scripts: &types.ScriptsConfig{Roots: tt.roots, Commands: tt.policy}}
+		if err := CheckCommandPolicy(tt.command, tt.workDir, tt.shell); (err == nil) != tt.ok {
+			t.Errorf("%s: CheckCommandPolicy(%q) = %v, want ok %v", tt.name, tt.command, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// DefaultMaxDecodedBody default limit of a decompressed request body
+const DefaultMaxDecodedBody = 10 << 20
+
+// MaxDecodedBody limit of decompressed hook and GitHook bodies, set by -max-decompressed-body.
+// The compressed body is held to the same limit.
+var MaxDecodedBody int64 = DefaultMaxDecodedBody
+
+var (
+	// ErrUnsupportedEncoding the Content-Encoding of a request body cannot be decoded
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+	// ErrDecodedBodyTooLarge the decompressed request body exceeds the limit
+	ErrDecodedBodyTooLarge = errors.New("decompressed body too large")
+)
+
+// DecodeBody decompress a request body sent with the Content-Encoding header value encoding,
+// see NewDecodingReader. limit caps the size of the result, 0 means DefaultMaxDecodedBody.
+func DecodeBody(encoding string, body []byte, limit int64) ([]byte, error) {
+	r, err := NewDecodingReader(encoding, bytes.NewReader(body), limit)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// NewDecodingReader reader decompressing body sent with the Content-Encoding header value
+// encoding, gzip, deflate and br are supported. Encodings listed in order of application are
+// undone in reverse. Reading fails with ErrDecodedBodyTooLarge once the output exceeds limit,
+// 0 means DefaultMaxDecodedBody.
+func NewDecodingReader(encoding string, body io.Reader, limit int64) (io.Reader, error) {
+	if limit <= 0 {
+		limit = DefaultMaxDecodedBody
+	}
+	r := body
+	codings := strings.Split(encoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		var err error
+		switch coding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(r)
+		case "deflate":
+			// deflate is zlib wrapped, some clients send raw deflate data
+			buffered := bufio.NewReader(r)
+			if header, _ := buffered.Peek(2); zlibHeader(header) {
+				r, err = zlib.NewReader(buffered)
+			} else {
+				r = flate.NewReader(buffered)
+			}
+		case "br":
+			r = brotli.NewReader(r)
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, coding)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode %s body: %v", coding, err)
+		}
+	}
+	return &decodedReader{r: r, remaining: limit, limit: limit, encoding: encoding}, nil
+}
+
+// DecodeErrorStatus HTTP status answering a request whose body could not be decoded: 415 for
+// an unsupported encoding, 413 when the compressed or decompressed body is too large
+func DecodeErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, ErrUnsupportedEncoding):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrDecodedBodyTooLarge), errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// zlibHeader reports whether b starts a zlib stream: deflate method and a valid check value
+func zlibHeader(b []byte) bool {
+	return len(b) == 2 && b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
+
+// decodedReader decompressed body, fails once more than limit bytes were read
+type decodedReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+	encoding  string
+}
+
+func (d *decodedReader) Read(p []byte) (int, error) {
+	if d.remaining < 0 {
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrDecodedBodyTooLarge, d.limit)
+	}
+	// one byte more than allowed tells a body at the limit from a larger one
+	if int64(len(p)) > d.remaining+1 {
+		p = p[:d.remaining+1]
+	}
+	n, err := d.r.Read(p)
+	d.remaining -= int64(n)
+	if d.remaining < 0 {
+		return n + int(d.remaining), fmt.Errorf("%w: more than %d bytes", ErrDecodedBodyTooLarge, d.limit)
+	}
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("decode %s body: %w", d.encoding, err)
+	}
+	return n, err
+}
@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func compress(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+		err error
+	)
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	payload := []byte(`{"ref":"refs/heads/main"}`)
+	large := []byte(strings.Repeat("a", 2048))
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		limit    int64
+		want     []byte
+		wantErr  bool
+		is       error // expected error kind, nil accepts any error
+	}{
+		{"gzip", "gzip", compress(t, "gzip", payload), 0, payload, false, nil},
+		{"x-gzip upper case", "X-GZIP", compress(t, "gzip", payload), 0, payload, false, nil},
+		{"zlib deflate", "deflate", compress(t, "zlib", payload), 0, payload, false, nil},
+		{"raw deflate", "deflate", compress(t, "flate", payload), 0, payload, false, nil},
+		{"identity", "identity", payload, 0, payload, false, nil},
+		{"chain", "deflate, gzip", compress(t, "gzip", compress(t, "zlib", payload)), 0, payload, false, nil},
+		{"brotli", "br", compress(t, "br", payload), 0, payload, false, nil},
+		{"corrupt brotli", "br", payload, 0, nil, true, nil},
+		{"unsupported", "compress", payload, 0, nil, true, ErrUnsupportedEncoding},
+		{"too large", "gzip", compress(t, "gzip", large), 1024, nil, true, ErrDecodedBodyTooLarge},
+		{"at limit", "gzip", compress(t, "gzip", large), 2048, large, false, nil},
+		{"corrupt gzip", "gzip", []byte("not gzip"), 0, nil, true, nil},
+	}
+	for _, tt := range tests {
+		got, err := DecodeBody(tt.encoding, tt.body, tt.limit)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: DecodeBody() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("%s: DecodeBody() error = %v, want %v", tt.name, err, tt.is)
+			}
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: DecodeBody() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewDecodingReader(t *testing.T) {
+	large := []byte(strings.Repeat("a", 4096))
+
+	// the decoded body is read in pieces, up to the limit
+	r, err := NewDecodingReader("br", bytes.NewReader(compress(t, "br", large)), 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, large) {
+		t.Fatalf("ReadAll() = %d bytes, %v", len(got), err)
+	}
+
+	r, err = NewDecodingReader("gzip", bytes.NewReader(compress(t, "gzip", large)), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err = io.ReadAll(r); !errors.Is(err, ErrDecodedBodyTooLarge) || len(got) > 1024 {
+		t.Errorf("ReadAll() = %d bytes, %v, want %v", len(got), err, ErrDecodedBodyTooLarge)
+	}
+
+	// the compressed input is capped by the caller, its error reaches the reader
+	req := httptest.NewRequest(http.MethodPost, "/hooks/x", bytes.NewReader(compress(t, "gzip", large)))
+	capped := http.MaxBytesReader(httptest.NewRecorder(), req.Body, 16)
+	if r, err = NewDecodingReader("gzip", capped, 0); err == nil {
+		_, err = io.ReadAll(r)
+	}
+	if DecodeErrorStatus(err) != http.StatusRequestEntityTooLarge {
+		t.Errorf("capped input: error %v, status %d", err, DecodeErrorStatus(err))
+	}
+}
+
+func TestDecodeErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{ErrUnsupportedEncoding, http.StatusUnsupportedMediaType},
+		{ErrDecodedBodyTooLarge, http.StatusRequestEntityTooLarge},
+		{&http.MaxBytesError{Limit: 1}, http.StatusRequestEntityTooLarge},
+		{errors.New("unexpected EOF"), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if got := DecodeErrorStatus(tt.err); got != tt.want {
+			t.Errorf("DecodeErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
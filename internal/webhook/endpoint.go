@@ -0,0 +1,372 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/urls"
+)
+
+// ErrInvalidEndpoint an endpoint trigger rule calls a Starlark function the hook does not define
+var ErrInvalidEndpoint = errors.New("invalid endpoint")
+
+// ErrEndpointNotFound the hook has no endpoint with the id
+var ErrEndpointNotFound = errors.New("endpoint not found")
+
+// EndpointTokenParam query parameter carrying the capability token of an endpoint
+const EndpointTokenParam = "token"
+
+// random bytes of generated endpoint ids and capability tokens
+const (
+	endpointSlugBytes  = 16
+	endpointTokenBytes = 32
+)
+
+// Endpoint additional URL of a hook with its own credentials. Different providers or teams
+// call the same hook under their own id, and the secret of one endpoint is rotated or the
+// endpoint disabled without touching the others.
+type Endpoint struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"` // deliveries to a disabled endpoint answer 404
+	// TriggerRule replaces the trigger rule of the hook for deliveries to this endpoint, it
+	// carries the secrets and signature checks of the endpoint. Nil uses the hook's rule.
+	TriggerRule                  *Rules `json:"trigger-rule,omitempty"`
+	TriggerSignatureSoftFailures bool   `json:"trigger-signature-soft-failures,omitempty"`
+	// TokenSHA256 hex SHA-256 of the capability token deliveries pass in ?token=, checked
+	// besides the trigger rule. Empty accepts deliveries without a token.
+	TokenSHA256 string `json:"token-sha256,omitempty"`
+}
+
+// Authorized whether a delivery to the endpoint passing token is let through
+func (e *Endpoint) Authorized(token string) bool {
+	if e.TokenSHA256 == "" {
+		return true
+	}
+	sum := sha256.Sum256([]byte(token))
+	return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(e.TokenSHA256))) == 1
+}
+
+// NewCapabilityEndpoint endpoint with a random id nobody can guess, and a capability token
+// when withToken is set. The token is only returned here, the endpoint keeps its hash.
+func NewCapabilityEndpoint(description string, withToken bool) (Endpoint, string, error) {
+	slug := make([]byte, endpointSlugBytes)
+	if _, err := rand.Read(slug); err != nil {
+		return Endpoint{}, "", err
+	}
+	e := Endpoint{
+		ID:          strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(slug)),
+		Description: description,
+	}
+	if !withToken {
+		return e, "", nil
+	}
+	raw := make([]byte, endpointTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return Endpoint{}, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	sum := sha256.Sum256([]byte(token))
+	e.TokenSHA256 = hex.EncodeToString(sum[:])
+	return e, token, nil
+}
+
+// MatchEndpoint return the hook serving the endpoint id, and the endpoint
+func (h *Hooks) MatchEndpoint(id string) (*Hook, *Endpoint) {
+	for i := range *h {
+		if e := (*h)[i].Endpoint(id); e != nil {
+			return &(*h)[i], e
+		}
+	}
+	return nil, nil
+}
+
+// Endpoint return the endpoint of the hook with the given id, nil when there is none
+func (h *Hook) Endpoint(id string) *Endpoint {
+	for i := range h.Endpoints {
+		if h.Endpoints[i].ID == id {
+			return &h.Endpoints[i]
+		}
+	}
+	return nil
+}
+
+// TriggerRuleFor trigger rule and soft signature failure setting of a delivery addressed to
+// id, the hook id, an alias or an endpoint
+func (h *Hook) TriggerRuleFor(id string) (*Rules, bool) {
+	if e := h.Endpoint(id); e != nil && e.TriggerRule != nil {
+		return e.TriggerRule, e.TriggerSignatureSoftFailures
+	}
+	return h.TriggerRule, h.TriggerSignatureSoftFailures
+}
+
+// validateEndpoints check the endpoint ids are usable in hook URLs and unique within the hook
+func (h *Hook) validateEndpoints() error {
+	seen := map[string]bool{h.ID: true}
+	for _, alias := range h.Aliases {
+		seen[alias] = true
+	}
+	for _, e := range h.Endpoints {
+		if e.ID == "" || strings.ContainsAny(e.ID, " \t\r\n") {
+			return fmt.Errorf("%w %q", ErrInvalidHookID, e.ID)
+		}
+		if seen[e.ID] {
+			return fmt.Errorf("endpoint %s: %w", e.ID, ErrHookIDInUse)
+		}
+		if digest, err := hex.DecodeString(e.TokenSHA256); e.TokenSHA256 != "" && (err != nil || len(digest) != sha256.Size) {
+			return fmt.Errorf("%w %s: token-sha256 must be a hex SHA-256 digest", ErrInvalidEndpoint, e.ID)
+		}
+		seen[e.ID] = true
+	}
+	return nil
+}
+
+// SetHookEndpoints replace the endpoints of a hook and save its hooks file
+func SetHookEndpoints(id string, endpoints []Endpoint) error {
+	if HookManager == nil {
+		return fmt.Errorf("no hooks loaded")
+	}
+	h := HookManager.MatchLoadedHook(id)
+	if h == nil {
+		return fmt.Errorf("hook %s not found", id)
+	}
+	for i := range endpoints {
+		endpoints[i].ID = strings.TrimSpace(endpoints[i].ID)
+		if owner, _ := HookManager.ResolveHook(endpoints[i].ID); owner != nil && owner != h {
+			return fmt.Errorf("%w: %s", ErrHookIDInUse, endpoints[i].ID)
+		}
+	}
+	if len(endpoints) == 0 {
+		endpoints = nil
+	}
+
+	oldEndpoints := h.Endpoints
+	h.Endpoints = endpoints
+	if err := h.validateEndpoints(); err != nil {
+		h.Endpoints = oldEndpoints
+		return err
+	}
+	if err := h.Starlark.CheckFunctions(h.StarlarkFunctions()); err != nil {
+		h.Endpoints = oldEndpoints
+		return fmt.Errorf("%w: %v", ErrInvalidEndpoint, err)
+	}
+	if err := HookManager.SaveHookChanges(id); err != nil {
+		h.Endpoints = oldEndpoints
+		return err
+	}
+	return nil
+}
+
+// AddHookEndpoint add an endpoint to a hook and save its hooks file
+func AddHookEndpoint(id string, e Endpoint) error {
+	if HookManager == nil {
+		return fmt.Errorf("no hooks loaded")
+	}
+	h := HookManager.MatchLoadedHook(id)
+	if h == nil {
+		return fmt.Errorf("hook %s not found", id)
+	}
+	endpoints := append(append([]Endpoint{}, h.Endpoints...), e)
+	return SetHookEndpoints(id, endpoints)
+}
+
+// RemoveHookEndpoint revoke an endpoint of a hook and save its hooks file, deliveries to the
+// endpoint answer 404 from then on
+func RemoveHookEndpoint(id, endpointID string) error {
+	if HookManager == nil {
+		return fmt.Errorf("no hooks loaded")
+	}
+	h := HookManager.MatchLoadedHook(id)
+	if h == nil {
+		return fmt.Errorf("hook %s not found", id)
+	}
+	var endpoints []Endpoint
+	for _, e := range h.Endpoints {
+		if e.ID != endpointID {
+			endpoints = append(endpoints, e)
+		}
+	}
+	if len(endpoints) == len(h.Endpoints) {
+		return fmt.Errorf("%w: %s", ErrEndpointNotFound, endpointID)
+	}
+	return SetHookEndpoints(id, endpoints)
+}
+
+// endpointSummary ids and states of endpoints for the audit log, leaving out their secrets
+func endpointSummary(endpoints []Endpoint) []map[string]interface{} {
+	summary := []map[string]interface{}{}
+	for _, e := range endpoints {
+		summary = append(summary, map[string]interface{}{
+			"id":          e.ID,
+			"disabled":    e.Disabled,
+			"triggerRule": e.TriggerRule != nil,
+			"token":       e.TokenSHA256 != "",
+		})
+	}
+	return summary
+}
+
+// HandleUpdateHookEndpoints replace the endpoints of a hook, {"endpoints": [{"id": "ci", "trigger-rule": {...}}]}
+func HandleUpdateHookEndpoints(c *gin.Context) {
+	hookID := c.Param("id")
+	existingHook := HookManager.MatchLoadedHook(hookID)
+	if existingHook == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+	var request struct {
+		Endpoints     []Endpoint `json:"endpoints"`
+		EndpointsOnly *bool      `json:"endpointsOnly"` // unchanged when left out
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	originalEndpoints, originalOnly := existingHook.Endpoints, existingHook.EndpointsOnly
+	if request.EndpointsOnly != nil {
+		existingHook.EndpointsOnly = *request.EndpointsOnly // saved with the endpoints
+	}
+	err := SetHookEndpoints(hookID, request.Endpoints)
+	details := map[string]interface{}{"hookId": hookID}
+	if err != nil {
+		existingHook.EndpointsOnly = originalOnly
+		details["error"] = err.Error()
+	} else {
+		changes := map[string]interface{}{
+			"endpoints": map[string]interface{}{"old": endpointSummary(originalEndpoints), "new": endpointSummary(existingHook.Endpoints)},
+		}
+		if originalOnly != existingHook.EndpointsOnly {
+			changes["endpointsOnly"] = map[string]interface{}{"old": originalOnly, "new": existingHook.EndpointsOnly}
+		}
+		details["changes"] = changes
+	}
+	database.LogHookManagement(
+		database.UserActionUpdateHookEndpoints,
+		hookID,
+		hookID,
+		c.GetString("username"),
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		err == nil,
+		details,
+	)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrHookIDInUse) {
+			status = http.StatusConflict
+		} else if errors.Is(err, ErrInvalidHookID) || errors.Is(err, ErrInvalidEndpoint) {
+			status = http.StatusBadRequest
+		}
+		i18n.JSONError(c, status, i18n.CodeUpdateEndpointsFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeEndpointsUpdated),
+		"code":    i18n.CodeEndpointsUpdated,
+		"hook":    convertHookToResponse(existingHook),
+	})
+}
+
+// HandleCreateHookEndpoint add an endpoint with a random id to a hook, {"description": "ci",
+// "token": true} also requires a capability token in ?token=. The token is only part of
+// this response.
+func HandleCreateHookEndpoint(c *gin.Context) {
+	hookID := c.Param("id")
+	if HookManager.MatchLoadedHook(hookID) == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+	var request struct {
+		Description string `json:"description"`
+		Token       bool   `json:"token"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+			return
+		}
+	}
+
+	endpoint, token, err := NewCapabilityEndpoint(request.Description, request.Token)
+	if err == nil {
+		err = AddHookEndpoint(hookID, endpoint)
+	}
+	details := map[string]interface{}{"hookId": hookID, "endpoint": endpoint.ID, "token": token != ""}
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	database.LogHookManagement(
+		database.UserActionUpdateHookEndpoints,
+		hookID,
+		hookID,
+		c.GetString("username"),
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		err == nil,
+		details,
+	)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeCreateEndpointFailed, err)
+		return
+	}
+
+	url := urls.Current().PublicHookPath(endpoint.ID)
+	if token != "" {
+		url += "?" + EndpointTokenParam + "=" + token
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  i18n.Message(c, i18n.CodeEndpointCreated),
+		"code":     i18n.CodeEndpointCreated,
+		"endpoint": endpoint,
+		"url":      url,
+		"token":    token,
+	})
+}
+
+// HandleDeleteHookEndpoint revoke an endpoint of a hook
+func HandleDeleteHookEndpoint(c *gin.Context) {
+	hookID, endpointID := c.Param("id"), c.Param("endpoint")
+	if HookManager.MatchLoadedHook(hookID) == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+
+	err := RemoveHookEndpoint(hookID, endpointID)
+	details := map[string]interface{}{"hookId": hookID, "endpoint": endpointID, "revoked": err == nil}
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	database.LogHookManagement(
+		database.UserActionUpdateHookEndpoints,
+		hookID,
+		hookID,
+		c.GetString("username"),
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		err == nil,
+		details,
+	)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrEndpointNotFound) {
+			status = http.StatusNotFound
+		}
+		i18n.JSONError(c, status, i18n.CodeRevokeEndpointFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Message(c, i18n.CodeEndpointRevoked), "code": i18n.CodeEndpointRevoked})
+}
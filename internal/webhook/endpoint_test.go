@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetHookEndpoints(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hooks.json")
+	loaded := map[string]Hooks{
+		file: {{ID: "deploy", ExecuteCommand: "/bin/true"}, {ID: "backup", Aliases: []string{"nightly"}, ExecuteCommand: "/bin/true"}},
+	}
+	saved := HookManager
+	HookManager = NewHookManager(&loaded, []string{file}, false)
+	defer func() { HookManager = saved }()
+
+	tests := []struct {
+		name      string
+		endpoints []Endpoint
+		wantErr   error
+	}{
+		{"endpoint ids", []Endpoint{{ID: "deploy-ci"}, {ID: " deploy-ops ", Disabled: true}}, nil},
+		{"id of another hook", []Endpoint{{ID: "backup"}}, ErrHookIDInUse},
+		{"alias of another hook", []Endpoint{{ID: "nightly"}}, ErrHookIDInUse},
+		{"hook id", []Endpoint{{ID: "deploy"}}, ErrHookIDInUse},
+		{"repeated id", []Endpoint{{ID: "ci"}, {ID: "ci"}}, ErrHookIDInUse},
+		{"id with spaces", []Endpoint{{ID: "deploy ci"}}, ErrInvalidHookID},
+		{"starlark rule without program", []Endpoint{{ID: "ci", TriggerRule: &Rules{Match: &MatchRule{Type: MatchValue, Value: "1", Parameter: Argument{Source: SourceStarlark, Name: "ok"}}}}}, ErrInvalidEndpoint},
+	}
+	for _, tt := range tests {
+		err := SetHookEndpoints("deploy", tt.endpoints)
+		if !errors.Is(err, tt.wantErr) && (tt.wantErr != nil || err != nil) {
+			t.Errorf("%s: SetHookEndpoints() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// failed updates keep the endpoints of the first one
+	if h, alias := HookManager.ResolveHook("deploy-ops"); h == nil || h.ID != "deploy" || alias != "deploy-ops" {
+		t.Fatalf("ResolveHook(endpoint) = %+v, %q", h, alias)
+	}
+	if err := SetHookAliases("backup", []string{"deploy-ci"}); !errors.Is(err, ErrHookIDInUse) {
+		t.Errorf("alias taking an endpoint id: error = %v", err)
+	}
+	if err := SetHookAliases("deploy", []string{"deploy-ci"}); !errors.Is(err, ErrHookIDInUse) {
+		t.Errorf("alias taking an endpoint id of the same hook: error = %v", err)
+	}
+}
+
+func TestTriggerRuleFor(t *testing.T) {
+	hookRule := &Rules{Match: &MatchRule{Type: MatchValue, Value: "hook"}}
+	endpointRule := &Rules{Match: &MatchRule{Type: MatchValue, Value: "endpoint"}}
+	h := Hook{
+		ID:          "deploy",
+		Aliases:     []string{"old"},
+		TriggerRule: hookRule,
+		Endpoints: []Endpoint{
+			{ID: "ci", TriggerRule: endpointRule, TriggerSignatureSoftFailures: true},
+			{ID: "shared"},
+		},
+	}
+
+	tests := []struct {
+		id       string
+		want     *Rules
+		wantSoft bool
+	}{
+		{"deploy", hookRule, false},
+		{"old", hookRule, false},
+		{"ci", endpointRule, true},
+		{"shared", hookRule, false},
+	}
+	for _, tt := range tests {
+		if got, soft := h.TriggerRuleFor(tt.id); got != tt.want || soft != tt.wantSoft {
+			t.Errorf("TriggerRuleFor(%q) = %v, %t, want %v, %t", tt.id, got, soft, tt.want, tt.wantSoft)
+		}
+	}
+}
+
+func TestCapabilityEndpoint(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hooks.json")
+	loaded := map[string]Hooks{file: {{ID: "deploy", ExecuteCommand: "/bin/true"}}}
+	saved := HookManager
+	HookManager = NewHookManager(&loaded, []string{file}, false)
+	defer func() { HookManager = saved }()
+
+	open, token, err := NewCapabilityEndpoint("cron", false)
+	if err != nil || token != "" || open.TokenSHA256 != "" || len(open.ID) < 26 {
+		t.Fatalf("NewCapabilityEndpoint without token = %+v, %q, %v", open, token, err)
+	}
+	capability, token, err := NewCapabilityEndpoint("ci", true)
+	if err != nil || token == "" || capability.ID == open.ID {
+		t.Fatalf("NewCapabilityEndpoint with token = %+v, %q, %v", capability, token, err)
+	}
+
+	tests := []struct {
+		name     string
+		endpoint Endpoint
+		token    string
+		want     bool
+	}{
+		{"no token required", open, "", true},
+		{"right token", capability, token, true},
+		{"wrong token", capability, token + "x", false},
+		{"missing token", capability, "", false},
+	}
+	for _, tt := range tests {
+		if got := tt.endpoint.Authorized(tt.token); got != tt.want {
+			t.Errorf("%s: Authorized() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+
+	for _, e := range []Endpoint{open, capability} {
+		if err := AddHookEndpoint("deploy", e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if h, alias := HookManager.ResolveHook(capability.ID); h == nil || h.ID != "deploy" || alias != capability.ID {
+		t.Fatalf("ResolveHook(capability endpoint) = %+v, %q", h, alias)
+	}
+	if err := AddHookEndpoint("deploy", Endpoint{ID: "ci", TokenSHA256: "not-a-digest"}); !errors.Is(err, ErrInvalidEndpoint) {
+		t.Errorf("endpoint with a malformed token hash: error = %v", err)
+	}
+
+	if err := RemoveHookEndpoint("deploy", capability.ID); err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := HookManager.ResolveHook(capability.ID); h != nil {
+		t.Error("revoked endpoint still resolves")
+	}
+	if err := RemoveHookEndpoint("deploy", capability.ID); !errors.Is(err, ErrEndpointNotFound) {
+		t.Errorf("revoking twice: error = %v", err)
+	}
+}
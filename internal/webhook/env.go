@@ -0,0 +1,117 @@
+package webhook
+
+import (
+	"runtime"
+	"strings"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// request metadata set for every command, before the pass-environment-to-command variables
+// so those can override them
+const (
+	EnvHookID     = "GOHOOK_HOOK_ID"
+	EnvDeliveryID = "GOHOOK_DELIVERY_ID"
+	EnvEvent      = "GOHOOK_EVENT"
+	EnvRemoteAddr = "GOHOOK_REMOTE_ADDR"
+	EnvProject    = "GOHOOK_PROJECT"
+	EnvRef        = "GOHOOK_REF"
+)
+
+// eventHeaders headers holding the event name of a git host, tried in order
+var eventHeaders = []string{
+	"X-GitHub-Event",
+	"X-Gitea-Event",
+	"X-Gogs-Event",
+	"X-Gitlab-Event",
+	"X-Gitee-Event",
+	"X-Event-Key", // Bitbucket
+}
+
+// RequestEnv request metadata variables (NAME=value) of a run of h, a variable is empty
+// when the request does not carry the value
+func (h *Hook) RequestEnv(r *Request) []string {
+	deliveryID := firstHeader(r, idempotencyHeaders)
+	if deliveryID == "" {
+		deliveryID = r.ID
+	}
+	addrArg := Argument{Source: SourceRequest, Name: "remote-addr"}
+	remoteAddr, _ := addrArg.Get(r)
+	var project string
+	if types.GoHookVersionData != nil {
+		project = DeployedProject(h, types.GoHookVersionData.Projects)
+	}
+	refArg := Argument{Source: SourcePayload, Name: "ref"}
+	ref, _ := refArg.Get(r)
+
+	return []string{
+		EnvHookID + "=" + h.ID,
+		EnvDeliveryID + "=" + deliveryID,
+		EnvEvent + "=" + firstHeader(r, eventHeaders),
+		EnvRemoteAddr + "=" + remoteAddr,
+		EnvProject + "=" + project,
+		EnvRef + "=" + ref,
+	}
+}
+
+// firstHeader value of the first of names set on the request
+func firstHeader(r *Request, names []string) string {
+	for _, name := range names {
+		arg := Argument{Source: SourceHeader, Name: name}
+		if value, _ := arg.Get(r); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// EnvPolicy policy deciding the inherited environment of h: its own inherit-environment,
+// otherwise the global hook_env. nil inherits everything.
+func (h *Hook) EnvPolicy() *types.EnvPolicy {
+	if h.InheritEnvironment != nil {
+		return h.InheritEnvironment
+	}
+	if cfg := types.GoHookAppConfig; cfg != nil {
+		return cfg.HookEnv
+	}
+	return nil
+}
+
+// InheritedEnv entries of environ (NAME=value) passed on to the command of h
+func (h *Hook) InheritedEnv(environ []string) []string {
+	policy := h.EnvPolicy()
+	if policy == nil {
+		return environ
+	}
+
+	switch policy.Mode {
+	case types.EnvInheritNone:
+		return []string{}
+	case types.EnvInheritAllowlist:
+		inherited := make([]string, 0, len(policy.Allow))
+		for _, entry := range environ {
+			name, _, _ := strings.Cut(entry, "=")
+			if envAllowed(name, policy.Allow) {
+				inherited = append(inherited, entry)
+			}
+		}
+		return inherited
+	}
+	return environ
+}
+
+// envAllowed reports whether name matches one of allow, NAME* matches a prefix
+func envAllowed(name string, allow []string) bool {
+	// environment variable names are case-insensitive on Windows
+	fold := runtime.GOOS == "windows"
+	for _, pattern := range allow {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if len(name) >= len(prefix) && (name[:len(prefix)] == prefix || fold && strings.EqualFold(name[:len(prefix)], prefix)) {
+				return true
+			}
+		} else if name == pattern || fold && strings.EqualFold(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestInheritedEnv(t *testing.T) {
+	environ := []string{"PATH=/usr/bin", "HOME=/root", "DB_PASSWORD=secret", "LC_ALL=C", "LC_CTYPE=UTF-8", "LANG=C"}
+	global := &types.EnvPolicy{Mode: types.EnvInheritAllowlist, Allow: []string{"PATH"}}
+
+	tests := []struct {
+		name   string
+		global *types.EnvPolicy
+		hook   *types.EnvPolicy
+		want   []string
+	}{
+		{"default inherits all", nil, nil, environ},
+		{"hook all", global, &types.EnvPolicy{Mode: types.EnvInheritAll}, environ},
+		{"global allowlist", global, nil, []string{"PATH=/usr/bin"}},
+		{"hook allowlist with prefix", global, &types.EnvPolicy{Mode: types.EnvInheritAllowlist, Allow: []string{"HOME", "LC_*"}},
+			[]string{"HOME=/root", "LC_ALL=C", "LC_CTYPE=UTF-8"}},
+		{"hook none", nil, &types.EnvPolicy{Mode: types.EnvInheritNone}, []string{}},
+	}
+
+	saved := types.GoHookAppConfig
+	defer func() { types.GoHookAppConfig = saved }()
+	for _, tt := range tests {
+		types.GoHookAppConfig = &types.AppConfig{HookEnv: tt.global}
+		h := &Hook{ID: "deploy", InheritEnvironment: tt.hook}
+		if got := h.InheritedEnv(environ); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: InheritedEnv() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEnvPolicyValidate(t *testing.T) {
+	tests := []struct {
+		policy  types.EnvPolicy
+		wantErr bool
+	}{
+		{types.EnvPolicy{}, false},
+		{types.EnvPolicy{Mode: types.EnvInheritAllowlist, Allow: []string{"PATH", "LC_*"}}, false},
+		{types.EnvPolicy{Mode: "some"}, true},
+		{types.EnvPolicy{Mode: types.EnvInheritAllowlist, Allow: []string{"*"}}, true},
+		{types.EnvPolicy{Mode: types.EnvInheritAllowlist, Allow: []string{"A*B"}}, true},
+		{types.EnvPolicy{Mode: types.EnvInheritAllowlist, Allow: []string{"A=B"}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.policy, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRequestEnv(t *testing.T) {
+	saved := types.GoHookVersionData
+	defer func() { types.GoHookVersionData = saved }()
+	types.GoHookVersionData = &types.VersionConfig{Projects: []types.ProjectConfig{{Name: "shop", Path: "/srv/shop"}}}
+
+	tests := []struct {
+		name string
+		hook *Hook
+		req  *Request
+		want []string
+	}{
+		{"github push",
+			&Hook{ID: "deploy", CommandWorkingDirectory: "/srv/shop"},
+			&Request{
+				ID:         "req-1",
+				Headers:    map[string]interface{}{"X-Github-Event": "push", "X-Github-Delivery": "d-1"},
+				Payload:    map[string]interface{}{"ref": "refs/heads/main"},
+				ClientIP:   "203.0.113.7",
+				RawRequest: &http.Request{RemoteAddr: "10.0.0.1:4000"},
+			},
+			[]string{"GOHOOK_HOOK_ID=deploy", "GOHOOK_DELIVERY_ID=d-1", "GOHOOK_EVENT=push", "GOHOOK_REMOTE_ADDR=203.0.113.7",
+				"GOHOOK_PROJECT=shop", "GOHOOK_REF=refs/heads/main"}},
+		{"gitlab without client ip",
+			&Hook{ID: "build", ExecuteCommand: "/srv/other/build.sh"},
+			&Request{
+				ID:         "req-2",
+				Headers:    map[string]interface{}{"X-Gitlab-Event": "Tag Push Hook"},
+				RawRequest: &http.Request{RemoteAddr: "10.0.0.1:4000"},
+			},
+			[]string{"GOHOOK_HOOK_ID=build", "GOHOOK_DELIVERY_ID=req-2", "GOHOOK_EVENT=Tag Push Hook", "GOHOOK_REMOTE_ADDR=10.0.0.1:4000",
+				"GOHOOK_PROJECT=", "GOHOOK_REF="}},
+		{"manual run",
+			&Hook{ID: "ping"},
+			&Request{ID: "req-3"},
+			[]string{"GOHOOK_HOOK_ID=ping", "GOHOOK_DELIVERY_ID=req-3", "GOHOOK_EVENT=", "GOHOOK_REMOTE_ADDR=",
+				"GOHOOK_PROJECT=", "GOHOOK_REF="}},
+	}
+	for _, tt := range tests {
+		if got := tt.hook.RequestEnv(tt.req); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: RequestEnv() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/httpcache"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/urls"
+)
+
+// hooksETag tag of a hooks response: the checksums of the hooks files and the public URL
+// the hook paths are built from
+func hooksETag(c *gin.Context) *httpcache.Tag {
+	tag := httpcache.ForRequest(c).Add(urls.Current().PublicHookPath(""))
+	if HookManager != nil {
+		for _, path := range HookManager.HooksFiles {
+			tag.File(path)
+		}
+	}
+	return tag
+}
+
+// hooksNotModified answer the hook list with 304 when the hooks files, the deliveries and the
+// circuit breakers did not change since the ETag of the request
+func hooksNotModified(c *gin.Context) bool {
+	tag := hooksETag(c).Add(database.NewLogService().LatestHookLogID(database.HookTypeWebhook))
+	now := time.Now()
+	files := make([]string, 0, len(*LoadedHooksFromFiles))
+	for file := range *LoadedHooksFromFiles {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		hooksInFile := (*LoadedHooksFromFiles)[file]
+		for i := range hooksInFile {
+			if h := &hooksInFile[i]; namespace.Allowed(c, h.Namespace) {
+				// neither a closing breaker nor the end of a rotation touches the files
+				tag.Add(h.ID, circuitOpen(h), h.SecretRotation.Active(now))
+			}
+		}
+	}
+	return httpcache.NotModified(c, tag.String())
+}
+
+// HookRevision revision of the definition of h, the ETag of GET /hook/:id
+func HookRevision(h *Hook) string {
+	return httpcache.Revision(h)
+}
+
+// HookIfMatch conditional updates of the hook of the :id parameter
+func HookIfMatch() gin.HandlerFunc {
+	return httpcache.IfMatch(httpcache.Resource{
+		Revision: func(c *gin.Context) (string, bool) {
+			if h := HookManager.MatchLoadedHook(c.Param("id")); h != nil {
+				return HookRevision(h), true
+			}
+			return "", false
+		},
+		State: func(c *gin.Context) interface{} {
+			return hookDetail(HookManager.MatchLoadedHook(c.Param("id")))
+		},
+	})
+}
@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mycoool/gohook/internal/redact"
+	"github.com/mycoool/gohook/internal/sandbox"
+)
+
+// Shell values for Hook.Shell
+const (
+	ShellNone       = "none"
+	ShellSh         = "sh"
+	ShellBash       = "bash"
+	ShellPowerShell = "powershell"
+)
+
+// ValidShell reports whether shell is a supported Hook.Shell value (empty means none).
+func ValidShell(shell string) bool {
+	switch shell {
+	case "", ShellNone, ShellSh, ShellBash, ShellPowerShell:
+		return true
+	}
+	return false
+}
+
+// hookCommand is a prepared command with the temp files it needs cleaned up.
+type hookCommand struct {
+	cmd   *exec.Cmd
+	envs  []string
+	files []FileParameter
+	stdin io.Closer // spooled body streamed to stdin
+}
+
+// cleanup close the stdin file and remove the temp files of the command
+func (hc *hookCommand) cleanup(r *Request) {
+	if hc.stdin != nil {
+		hc.stdin.Close()
+	}
+	for i := range hc.files {
+		if hc.files[i].File != nil {
+			log.Printf("[%s] removing file %s\n", r.ID, hc.files[i].File.Name())
+			err := os.Remove(hc.files[i].File.Name())
+			if err != nil {
+				log.Printf("[%s] error removing file %s [%s]", r.ID, hc.files[i].File.Name(), err)
+			}
+		}
+	}
+}
+
+// buildHookCommand prepares the command for h from the request.
+// Extracted values are never spliced into the command text: without a shell they
+// are argv entries, for sh/bash they become positional parameters ("$1"...) and
+// for PowerShell they are exposed as HOOK_ARG_<n> environment variables.
+func buildHookCommand(h *Hook, r *Request) (*hookCommand, error) {
+	args, errs := h.ExtractCommandArguments(r)
+	for _, err := range errs {
+		log.Printf("[%s] error extracting command arguments: %s\n", r.ID, err)
+	}
+	args = append(args, r.ExtraArgs...)
+
+	var cmd *exec.Cmd
+	var extraEnv []string
+	switch h.Shell {
+	case "", ShellNone:
+		// check the command exists
+		var lookpath string
+		if filepath.IsAbs(h.ExecuteCommand) || h.CommandWorkingDirectory == "" {
+			lookpath = h.ExecuteCommand
+		} else {
+			lookpath = filepath.Join(h.CommandWorkingDirectory, h.ExecuteCommand)
+		}
+
+		cmdPath, err := exec.LookPath(lookpath)
+		if err != nil {
+			log.Printf("[%s] error in %s", r.ID, err)
+
+			// check if parameters specified in execute-command by mistake
+			if strings.IndexByte(h.ExecuteCommand, ' ') != -1 {
+				s := strings.Fields(h.ExecuteCommand)[0]
+				log.Printf("[%s] use 'pass-arguments-to-command' to specify args for '%s' or set a shell", r.ID, s)
+			}
+
+			return nil, err
+		}
+		cmd = exec.Command(cmdPath)
+		cmd.Args = args
+	case ShellSh, ShellBash:
+		shellPath, err := exec.LookPath(h.Shell)
+		if err != nil {
+			return nil, err
+		}
+		cmd = exec.Command(shellPath)
+		// $0 is the hook id, extracted arguments follow as "$@"
+		cmd.Args = append([]string{h.Shell, "-c", h.ExecuteCommand, h.ID}, args[1:]...)
+	case ShellPowerShell:
+		shellPath, err := exec.LookPath("pwsh")
+		if err != nil {
+			if shellPath, err = exec.LookPath("powershell"); err != nil {
+				return nil, err
+			}
+		}
+		cmd = exec.Command(shellPath, "-NoProfile", "-NonInteractive", "-Command", h.ExecuteCommand)
+		for i, arg := range args[1:] {
+			extraEnv = append(extraEnv, "HOOK_ARG_"+strconv.Itoa(i+1)+"="+arg)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported shell: %s", h.Shell)
+	}
+	cmd.Dir = h.CommandWorkingDirectory
+
+	var stdin io.Closer
+	if h.PassRequestBodyToStdin {
+		body, closer, err := stdinReader(h, r)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Stdin, stdin = body, closer
+	}
+
+	passEnv, errs := h.ExtractCommandArgumentsForEnv(r)
+	for _, err := range errs {
+		log.Printf("[%s] error extracting command arguments for environment: %s\n", r.ID, err)
+	}
+	envs := append(h.RequestEnv(r), passEnv...)
+	envs = append(envs, extraEnv...)
+
+	files, errs := h.ExtractCommandArgumentsForFile(r)
+	for _, err := range errs {
+		log.Printf("[%s] error extracting command arguments for file: %s\n", r.ID, err)
+	}
+
+	for i := range files {
+		tmpfile, err := os.CreateTemp(h.CommandWorkingDirectory, files[i].EnvName)
+		if err != nil {
+			log.Printf("[%s] error creating temp file [%s]", r.ID, err)
+			continue
+		}
+		log.Printf("[%s] writing env %s file %s", r.ID, files[i].EnvName, tmpfile.Name())
+		if _, err := tmpfile.Write(files[i].Data); err != nil {
+			log.Printf("[%s] error writing file %s [%s]", r.ID, tmpfile.Name(), err)
+			continue
+		}
+		if err := tmpfile.Close(); err != nil {
+			log.Printf("[%s] error closing file %s [%s]", r.ID, tmpfile.Name(), err)
+			continue
+		}
+
+		files[i].File = tmpfile
+		envs = append(envs, files[i].EnvName+"="+tmpfile.Name())
+	}
+
+	// a spooled body is handed over by path, it is too large for an environment variable
+	if r.Spooled() {
+		envs = append(envs, EnvRequestBodyFile+"="+r.BodyFile)
+	}
+
+	envs = append(envs, r.ExtraEnv...)
+
+	hc := &hookCommand{cmd: cmd, envs: envs, files: files, stdin: stdin}
+	cmd.Env = append(h.InheritedEnv(os.Environ()), envs...)
+	r.secrets = redact.EnvValues(cmd.Env)
+	if err := sandbox.Wrap(cmd, h.Sandbox); err != nil {
+		hc.cleanup(r)
+		return nil, err
+	}
+	return hc, nil
+}
+
+// runHookCommand executes h for the request and returns its combined output.
+// Webhook deliveries and manual triggers share this path; gateway hooks forward the request instead.
+func runHookCommand(h *Hook, r *Request) (string, time.Duration, error) {
+	if h.Forward != nil {
+		out, duration, err := runHookForward(h, r)
+		return out, duration, h.CheckOutput(out, err)
+	}
+
+	hc, err := buildHookCommand(h, r)
+	if err != nil {
+		return "", 0, err
+	}
+	cmd := hc.cmd
+
+	log.Printf("[%s] executing %s (%s) with arguments %q and environment %s using %s as cwd\n", r.ID, h.ExecuteCommand, cmd.Path, cmd.Args, hc.envs, cmd.Dir)
+
+	startedAt := time.Now()
+	out, err := cmd.CombinedOutput()
+	duration := time.Since(startedAt)
+
+	log.Printf("[%s] command output: %s\n", r.ID, out)
+
+	err = h.CheckOutput(string(out), err)
+	if err != nil {
+		log.Printf("[%s] error occurred: %+v\n", r.ID, err)
+	}
+
+	hc.cleanup(r)
+
+	log.Printf("[%s] finished handling %s\n", r.ID, h.ID)
+	return string(out), duration, err
+}
@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestRunHookCommandShellPositionalArgs(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	h := &Hook{
+		ID:                     "shell-test",
+		ExecuteCommand:         `printf '%s|%s' "$0" "$1"`,
+		Shell:                  ShellSh,
+		PassArgumentsToCommand: []Argument{{Source: SourcePayload, Name: "ref"}},
+	}
+	r := &Request{
+		ID:      "test",
+		Payload: map[string]interface{}{"ref": "main; echo injected"},
+	}
+
+	out, _, err := runHookCommand(h, r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "shell-test|main; echo injected"; out != want {
+		t.Fatalf("unexpected output %q, want %q", out, want)
+	}
+}
+
+func TestBuildHookCommandRejectsUnknownShell(t *testing.T) {
+	h := &Hook{ID: "bad", ExecuteCommand: "true", Shell: "zsh"}
+	if _, err := buildHookCommand(h, &Request{ID: "test"}); err == nil {
+		t.Fatal("expected error for unsupported shell")
+	}
+	if ValidShell("zsh") || !ValidShell("") || !ValidShell(ShellPowerShell) {
+		t.Fatal("unexpected ValidShell result")
+	}
+}
+
+func TestRunHookCommandTriggerOverrides(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	h := &Hook{
+		ID:                       "override-test",
+		ExecuteCommand:           `printf '%s|%s' "$1" "$DEPLOY_ENV"`,
+		Shell:                    ShellSh,
+		PassEnvironmentToCommand: []Argument{{Source: SourcePayload, Name: "env", EnvName: "DEPLOY_ENV"}},
+	}
+	r := &Request{
+		ID:        "test",
+		Payload:   map[string]interface{}{"env": "staging"},
+		ExtraArgs: []string{"v1.2.3"},
+		ExtraEnv:  []string{"DEPLOY_ENV=production"},
+	}
+
+	out, _, err := runHookCommand(h, r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "v1.2.3|production"; out != want {
+		t.Fatalf("unexpected output %q, want %q", out, want)
+	}
+}
+
+func TestRunHookCommandRequestEnv(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	h := &Hook{
+		ID:                 "env-test",
+		ExecuteCommand:     `printf '%s|%s' "$GOHOOK_HOOK_ID" "$GOHOOK_REF"`,
+		Shell:              ShellSh,
+		InheritEnvironment: &types.EnvPolicy{Mode: types.EnvInheritNone},
+	}
+	r := &Request{
+		ID:      "test",
+		Payload: map[string]interface{}{"ref": "refs/tags/v1"},
+	}
+
+	out, _, err := runHookCommand(h, r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "env-test|refs/tags/v1"; out != want {
+		t.Fatalf("unexpected output %q, want %q", out, want)
+	}
+}
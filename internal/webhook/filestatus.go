@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/stream"
+)
+
+// hooks file states of HooksFileStatus
+const (
+	HooksFileOK    = "ok"
+	HooksFileError = "error"
+)
+
+// HooksFileStatus load state of a hooks file. A file that fails to load keeps serving
+// the hooks of its last good version.
+type HooksFileStatus struct {
+	Path     string     `json:"path"`
+	Status   string     `json:"status"` // ok | error
+	Hooks    int        `json:"hooks"`  // hooks served from the file
+	Error    string     `json:"error,omitempty"`
+	Line     int        `json:"line,omitempty"` // line of the parse error, when known
+	LoadedAt *time.Time `json:"loadedAt,omitempty"`
+	FailedAt *time.Time `json:"failedAt,omitempty"`
+}
+
+// HooksFileAlertMessage WebSocket message of a hooks file that failed to load or recovered
+type HooksFileAlertMessage struct {
+	Path  string `json:"path"`
+	Error string `json:"error,omitempty"` // empty when the file loads again
+	Line  int    `json:"line,omitempty"`
+	Hooks int    `json:"hooks"` // hooks still served from the file
+}
+
+// parseErrorLine line reported by the YAML parser or the hooks template
+var parseErrorLine = regexp.MustCompile(`(?:yaml: line |template: hooks:)(\d+)`)
+
+var (
+	fileStatusMu sync.Mutex
+	fileStatuses = map[string]*HooksFileStatus{}
+)
+
+// RecordHooksFileLoad record the result of loading a hooks file, hooks is the number of hooks
+// served from it afterwards. A failure, and the first success after one, is broadcast.
+func RecordHooksFileLoad(path string, hooks int, err error) {
+	path = filepath.Clean(path)
+	fileStatusMu.Lock()
+	status := fileStatuses[path]
+	if status == nil {
+		status = &HooksFileStatus{Path: path}
+		fileStatuses[path] = status
+	}
+	recovered := status.Status == HooksFileError && err == nil
+	status.Hooks = hooks
+	now := time.Now()
+	if err != nil {
+		status.Status, status.Error, status.Line, status.FailedAt = HooksFileError, err.Error(), errorLine(err), &now
+	} else {
+		status.Status, status.Error, status.Line, status.LoadedAt = HooksFileOK, "", 0, &now
+	}
+	msg := HooksFileAlertMessage{Path: path, Error: status.Error, Line: status.Line, Hooks: hooks}
+	fileStatusMu.Unlock()
+
+	if err != nil || recovered {
+		stream.Global.Broadcast(stream.WsMessage{Type: "hooks_file", Timestamp: time.Now(), Data: msg})
+	}
+}
+
+// errorLine line number in a parse error, 0 when it carries none
+func errorLine(err error) int {
+	if m := parseErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return line
+	}
+	return 0
+}
+
+// HooksFileStatuses state of the configured hooks files and of files that failed to load,
+// sorted by path
+func HooksFileStatuses() []HooksFileStatus {
+	paths := map[string]bool{}
+	if HookManager != nil {
+		for _, path := range HookManager.HooksFiles {
+			paths[filepath.Clean(path)] = true
+		}
+	}
+
+	fileStatusMu.Lock()
+	defer fileStatusMu.Unlock()
+	for path, status := range fileStatuses {
+		if status.Status == HooksFileError {
+			paths[path] = true
+		}
+	}
+	list := make([]HooksFileStatus, 0, len(paths))
+	for path := range paths {
+		if status := fileStatuses[path]; status != nil {
+			list = append(list, *status)
+			continue
+		}
+		// configured but loaded before statuses were recorded, e.g. created through the API
+		list = append(list, HooksFileStatus{Path: path, Status: HooksFileOK, Hooks: loadedHooksCount(path)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
+
+// loadedHooksCount number of hooks served from path
+func loadedHooksCount(path string) int {
+	if HookManager == nil || HookManager.LoadedHooksFromFiles == nil {
+		return 0
+	}
+	for file, hooks := range *HookManager.LoadedHooksFromFiles {
+		if filepath.Clean(file) == path {
+			return len(hooks)
+		}
+	}
+	return 0
+}
+
+// HandleGetHooksFiles list the hooks files with their load state and parse errors
+func HandleGetHooksFiles(c *gin.Context) {
+	c.JSON(http.StatusOK, HooksFileStatuses())
+}
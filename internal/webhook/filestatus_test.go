@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReloadBrokenHooksFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "hooks.yaml")
+	other := filepath.Join(dir, "other.yaml")
+	loaded := map[string]Hooks{other: {{ID: "backup", ExecuteCommand: "/bin/true"}}}
+	saved := HookManager
+	HookManager = NewHookManager(&loaded, []string{file, other}, false)
+	defer func() { HookManager = saved }()
+
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	status := func() HooksFileStatus {
+		t.Helper()
+		for _, s := range HooksFileStatuses() {
+			if s.Path == file {
+				return s
+			}
+		}
+		t.Fatalf("%s not listed", file)
+		return HooksFileStatus{}
+	}
+
+	write("- id: deploy\n  execute-command: /bin/true\n")
+	if err := HookManager.ReloadHooks(file); err != nil {
+		t.Fatal(err)
+	}
+	if s := status(); s.Status != HooksFileOK || s.Hooks != 1 || s.LoadedAt == nil {
+		t.Fatalf("status = %+v", s)
+	}
+
+	write("- id: deploy\n  execute-command: /bin/true\n- id: build\n\texecute-command: /bin/false\n")
+	if err := HookManager.ReloadHooks(file); err == nil {
+		t.Fatal("broken file loaded")
+	}
+	if s := status(); s.Status != HooksFileError || s.Line != 4 || s.Hooks != 1 || s.Error == "" {
+		t.Fatalf("status = %+v", s)
+	}
+	if HookManager.MatchLoadedHook("deploy") == nil {
+		t.Fatal("hooks of the last good version must stay served")
+	}
+
+	write("- id: deploy\n  execute-command: /bin/true\n- id: backup\n  execute-command: /bin/true\n")
+	if err := HookManager.ReloadHooks(file); err == nil {
+		t.Fatal("duplicate id loaded")
+	}
+	if s := status(); s.Status != HooksFileError || s.Line != 0 {
+		t.Fatalf("status = %+v", s)
+	}
+
+	write("- id: deploy\n  execute-command: /bin/true\n- id: build\n  execute-command: /bin/false\n")
+	if err := HookManager.ReloadHooks(file); err != nil {
+		t.Fatal(err)
+	}
+	if s := status(); s.Status != HooksFileOK || s.Hooks != 2 || s.Error != "" {
+		t.Fatalf("status = %+v", s)
+	}
+}
+
+func TestErrorLine(t *testing.T) {
+	tests := []struct {
+		err  string
+		want int
+	}{
+		{"error converting YAML to JSON: yaml: line 12: did not find expected key", 12},
+		{"template: hooks:3: unexpected \"}\" in operand", 3},
+		{"error unmarshaling JSON: json: cannot unmarshal string into Go value of type webhook.Hooks", 0},
+	}
+	for _, tt := range tests {
+		if got := errorLine(errors.New(tt.err)); got != tt.want {
+			t.Errorf("errorLine(%q) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultForwardTimeout    = 30 * time.Second
+	defaultForwardRetryDelay = time.Second
+	maxForwardRetries        = 10
+	// forwarded response bodies are kept up to this size in the hook output
+	maxForwardOutput = 64 << 10
+)
+
+// ForwardConfig turns a hook into a gateway: instead of running a command the
+// incoming request is rendered through templates and sent to another endpoint.
+// URL, header values and Body are Go templates evaluated against forwardData.
+type ForwardConfig struct {
+	URL         string            `json:"url"`
+	Method      string            `json:"method,omitempty"`       // default POST
+	Headers     map[string]string `json:"headers,omitempty"`      // header name -> value template
+	PassHeaders []string          `json:"pass-headers,omitempty"` // incoming headers copied unchanged
+	Body        string            `json:"body,omitempty"`         // empty forwards the incoming body unchanged
+	ContentType string            `json:"content-type,omitempty"` // default: incoming content type
+	Retries     int               `json:"retries,omitempty"`      // extra attempts on network errors, 429 and 5xx
+	RetryDelay  string            `json:"retry-delay,omitempty"`  // first retry delay, doubled per attempt, default 1s
+	Timeout     string            `json:"timeout,omitempty"`      // per attempt, default 30s
+	Signature   *SignatureConfig  `json:"signature,omitempty"`    // HMAC signature of the forwarded body
+}
+
+// forwardData is the template context of a forward
+type forwardData struct {
+	ID      string
+	HookID  string
+	Method  string
+	Headers map[string]interface{}
+	Query   map[string]interface{}
+	Payload map[string]interface{}
+
+	req *Request
+}
+
+// Body the incoming body, a spooled body is only loaded when a template uses it
+func (d *forwardData) Body() (string, error) {
+	body, err := d.req.BodyBytes()
+	return string(body), err
+}
+
+// hookTemplateFuncs functions available in forward and response templates
+var hookTemplateFuncs = template.FuncMap{
+	"toJson": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"default": func(def, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return def
+		}
+		return v
+	},
+	"getenv":      os.Getenv,
+	"queryEscape": url.QueryEscape,
+	"lower":       strings.ToLower,
+	"upper":       strings.ToUpper,
+	"join":        strings.Join,
+}
+
+// Validate check the forward target and parse its templates
+func (f *ForwardConfig) Validate() error {
+	if f.URL == "" {
+		return fmt.Errorf("forward url is required")
+	}
+	if f.Method != "" {
+		switch strings.ToUpper(f.Method) {
+		case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			return fmt.Errorf("unsupported forward method: %s", f.Method)
+		}
+	}
+	if f.Retries < 0 || f.Retries > maxForwardRetries {
+		return fmt.Errorf("forward retries must be between 0 and %d", maxForwardRetries)
+	}
+	for name, value := range map[string]string{"retry-delay": f.RetryDelay, "timeout": f.Timeout} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("invalid forward %s: %s", name, value)
+		}
+	}
+	if _, err := parseForwardTemplate("url", f.URL); err != nil {
+		return err
+	}
+	if _, err := parseForwardTemplate("body", f.Body); err != nil {
+		return err
+	}
+	for name, value := range f.Headers {
+		if _, err := parseForwardTemplate("header "+name, value); err != nil {
+			return err
+		}
+	}
+	if f.Signature != nil {
+		if err := f.Signature.Validate(); err != nil {
+			return fmt.Errorf("invalid forward signature: %v", err)
+		}
+	}
+	return nil
+}
+
+func parseForwardTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(hookTemplateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid forward %s template: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+func renderForwardTemplate(name, text string, data *forwardData) (string, error) {
+	tmpl, err := parseForwardTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("render forward %s: %v", name, err)
+	}
+	return buf.String(), nil
+}
+
+// buildForwardRequest render the outgoing request of h for r, the body is sent from the
+// returned request: r itself when it is forwarded unchanged, so a spooled body stays on disk
+func buildForwardRequest(h *Hook, r *Request) (*http.Request, *Request, error) {
+	f := h.Forward
+	if err := f.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	data := &forwardData{
+		ID:      r.ID,
+		HookID:  h.ID,
+		req:     r,
+		Headers: r.Headers,
+		Query:   r.Query,
+		Payload: r.Payload,
+	}
+	if r.RawRequest != nil {
+		data.Method = r.RawRequest.Method
+	}
+
+	target, err := renderForwardTemplate("url", f.URL, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	target = strings.TrimSpace(target)
+	if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, nil, fmt.Errorf("invalid forward url: %s", target)
+	}
+
+	body := r
+	if f.Body != "" {
+		rendered, err := renderForwardTemplate("body", f.Body, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		body = &Request{ID: r.ID, Body: []byte(rendered)}
+	}
+
+	method := strings.ToUpper(f.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if r.RawRequest != nil {
+		for _, name := range f.PassHeaders {
+			if values := r.RawRequest.Header.Values(name); len(values) > 0 {
+				req.Header[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+	}
+	contentType := f.ContentType
+	if contentType == "" {
+		contentType = r.ContentType
+	}
+	if contentType != "" && body.BodySize() > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for name, value := range f.Headers {
+		rendered, err := renderForwardTemplate("header "+name, value, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header.Set(name, rendered)
+	}
+	req.Header.Set("X-GoHook-Hook", h.ID)
+	req.Header.Set("X-GoHook-Request-Id", r.ID)
+
+	if f.Signature != nil {
+		secret, err := renderForwardTemplate("signature secret", f.Signature.Secret, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		reader, err := body.OpenBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		err = f.Signature.Sign(req, secret, reader, time.Now())
+		reader.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return req, body, nil
+}
+
+// runHookForward forward r for a gateway hook, retrying network errors, 429 and 5xx answers.
+// The output is the status line and body of the last response.
+func runHookForward(h *Hook, r *Request) (string, time.Duration, error) {
+	startedAt := time.Now()
+	req, body, err := buildForwardRequest(h, r)
+	if err != nil {
+		log.Printf("[%s] error preparing forward of %s: %v\n", r.ID, h.ID, err)
+		return "", 0, err
+	}
+
+	f := h.Forward
+	timeout := parseDurationOr(f.Timeout, defaultForwardTimeout)
+	delay := parseDurationOr(f.RetryDelay, defaultForwardRetryDelay)
+	client := &http.Client{Timeout: timeout}
+
+	var output string
+	for attempt := 0; ; attempt++ {
+		if req.Body, err = body.OpenBody(); err != nil {
+			break
+		}
+		req.ContentLength = body.BodySize()
+
+		log.Printf("[%s] forwarding %s to %s %s (attempt %d)\n", r.ID, h.ID, req.Method, req.URL.Redacted(), attempt+1)
+		var retry bool
+		output, retry, err = sendForward(client, req)
+		if err == nil {
+			break
+		}
+		log.Printf("[%s] forward of %s failed: %v\n", r.ID, h.ID, err)
+		if !retry || attempt >= f.Retries {
+			break
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+
+	log.Printf("[%s] finished handling %s\n", r.ID, h.ID)
+	return output, time.Since(startedAt), err
+}
+
+// sendForward send one attempt, reporting whether a failure may be retried
+func sendForward(client *http.Client, req *http.Request) (string, bool, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", true, err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxForwardOutput))
+	output := fmt.Sprintf("HTTP %s\n%s", resp.Status, data)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return output, false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return output, retry, fmt.Errorf("forward target answered %s", resp.Status)
+}
+
+func parseDurationOr(value string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunHookForward(t *testing.T) {
+	var attempts int
+	var gotBody, gotAuth, gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		gotBody, gotAuth, gotPath = string(data), r.Header.Get("Authorization"), r.URL.Path
+		_, _ = w.Write([]byte("accepted"))
+	}))
+	defer srv.Close()
+
+	h := &Hook{
+		ID: "relay",
+		Forward: &ForwardConfig{
+			URL:        srv.URL + "/repos/{{.Payload.repository.name}}",
+			Headers:    map[string]string{"Authorization": "Bearer {{.Query.token}}"},
+			Body:       `{"text":"{{.Payload.pusher}} pushed","raw":{{toJson .Payload.repository}}}`,
+			Retries:    1,
+			RetryDelay: "1ms",
+		},
+	}
+	r := &Request{
+		ID:          "1",
+		ContentType: "application/json",
+		Body:        []byte(`{"pusher":"alice","repository":{"name":"app"}}`),
+		Query:       map[string]interface{}{"token": "secret"},
+	}
+	if err := r.ParseJSONPayload(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, _, err := runHookCommand(h, r)
+	if err != nil {
+		t.Fatalf("forward failed: %v", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("expected a retry after 503, got %d attempts", attempts)
+	}
+	if gotPath != "/repos/app" || gotAuth != "Bearer secret" {
+		t.Fatalf("unexpected request: path=%q auth=%q", gotPath, gotAuth)
+	}
+	if gotBody != `{"text":"alice pushed","raw":{"name":"app"}}` {
+		t.Fatalf("unexpected body: %s", gotBody)
+	}
+	if !strings.HasPrefix(out, "HTTP 200 OK") || !strings.HasSuffix(out, "accepted") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestForwardConfigValidate(t *testing.T) {
+	for _, f := range []ForwardConfig{
+		{},
+		{URL: "http://example.com", Method: "TRACE"},
+		{URL: "http://example.com", Retries: -1},
+		{URL: "http://example.com", Timeout: "soon"},
+		{URL: "http://example.com", Body: "{{.Payload"},
+		{URL: "http://example.com", Signature: &SignatureConfig{}},
+		{URL: "http://example.com", Signature: &SignatureConfig{Secret: "s", Algorithm: "md5"}},
+		{URL: "http://example.com", Signature: &SignatureConfig{Secret: "s", Header: "X Signature"}},
+		{URL: "http://example.com", Signature: &SignatureConfig{Secret: "{{getenv"}},
+	} {
+		if err := f.Validate(); err == nil {
+			t.Errorf("expected error for %+v", f)
+		}
+	}
+	if err := (&ForwardConfig{URL: "https://example.com/{{.HookID}}", Retries: 3, Timeout: "5s"}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestForwardSignature(t *testing.T) {
+	t.Setenv("FORWARD_SECRET", "s3cret")
+	body := `{"ref":"main"}`
+	tests := []struct {
+		name      string
+		signature SignatureConfig
+		header    string
+		signed    func(r *http.Request) string
+		newHash   func() hash.Hash
+	}{
+		{"default", SignatureConfig{Secret: "s3cret"}, "X-GoHook-Signature", func(*http.Request) string { return body }, sha256.New},
+		{"sha1 custom header", SignatureConfig{Secret: `{{getenv "FORWARD_SECRET"}}`, Algorithm: "sha1", Header: "X-Hub-Signature"}, "X-Hub-Signature", func(*http.Request) string { return body }, sha1.New},
+		{"timestamp", SignatureConfig{Secret: "s3cret", Algorithm: "sha512", Timestamp: true}, "X-GoHook-Signature", func(r *http.Request) string {
+			return r.Header.Get(SignatureTimestampHeader) + "." + body
+		}, sha512.New},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Hook{ID: "relay", Forward: &ForwardConfig{URL: "http://example.com", Signature: &tt.signature}}
+			r := &Request{ID: "1", ContentType: "application/json", Body: []byte(body)}
+			req, _, err := buildForwardRequest(h, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mac := hmac.New(tt.newHash, []byte("s3cret"))
+			mac.Write([]byte(tt.signed(req)))
+			want := tt.signature.algorithm() + "=" + hex.EncodeToString(mac.Sum(nil))
+			if got := req.Header.Get(tt.header); got != want {
+				t.Errorf("%s = %q, want %q", tt.header, got, want)
+			}
+		})
+	}
+
+	// a gohook receiving the forward verifies it with a payload-hmac rule
+	h := &Hook{ID: "relay", Forward: &ForwardConfig{URL: "http://example.com", Signature: &SignatureConfig{Secret: "s3cret"}}}
+	req, _, err := buildForwardRequest(h, &Request{ID: "1", Body: []byte(body)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := MatchRule{Type: MatchHMACSHA256, Secret: "s3cret", Parameter: Argument{Source: SourceHeader, Name: "X-GoHook-Signature"}}
+	received := &Request{Body: []byte(body), Headers: map[string]interface{}{"X-Gohook-Signature": req.Header.Get("X-GoHook-Signature")}}
+	if ok, err := rule.Evaluate(received); !ok || err != nil {
+		t.Errorf("payload-hmac-sha256 rule = %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,177 @@
+package webhook
+
+import (
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/urls"
+)
+
+// graph node types
+const (
+	GraphNodeHook     = "hook"
+	GraphNodeProject  = "project"
+	GraphNodeEndpoint = "endpoint"
+)
+
+// graph edge types
+const (
+	GraphEdgeForwards = "forwards" // hook forwards deliveries to a hook or an external endpoint
+	GraphEdgeDeploys  = "deploys"  // hook runs its command inside a project
+	GraphEdgePromotes = "promotes" // project revisions can be promoted into another project
+)
+
+// GraphNode hook, project or external endpoint of the automation graph
+type GraphNode struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Label     string `json:"label"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// GraphEdge relation between two graph nodes
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+}
+
+// HookGraph dependency graph of hooks and projects
+type HookGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// BuildHookGraph build the dependency graph of hooks and projects. A forward whose URL path
+// is the URL of a hook in hooks under layout, or of one of its aliases, points at that hook;
+// other forward URLs become endpoint nodes.
+func BuildHookGraph(hooks []Hook, projects []types.ProjectConfig, layout urls.Layout) HookGraph {
+	graph := HookGraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	seen := map[string]bool{}
+	addNode := func(n GraphNode) {
+		if !seen[n.ID] {
+			seen[n.ID] = true
+			graph.Nodes = append(graph.Nodes, n)
+		}
+	}
+
+	hookIDs := map[string]string{} // id or alias -> id
+	for _, h := range hooks {
+		for _, alias := range h.Aliases {
+			hookIDs[alias] = h.ID
+		}
+		for _, e := range h.Endpoints {
+			hookIDs[e.ID] = h.ID
+		}
+	}
+	for _, h := range hooks {
+		hookIDs[h.ID] = h.ID
+		addNode(GraphNode{ID: GraphNodeHook + ":" + h.ID, Type: GraphNodeHook, Label: h.ID, Namespace: namespace.Normalize(h.Namespace)})
+	}
+	projectNames := map[string]bool{}
+	for _, p := range projects {
+		projectNames[p.Name] = true
+		addNode(GraphNode{ID: GraphNodeProject + ":" + p.Name, Type: GraphNodeProject, Label: p.Name, Namespace: namespace.Normalize(p.Namespace)})
+	}
+
+	for _, h := range hooks {
+		from := GraphNodeHook + ":" + h.ID
+		if h.Forward != nil && h.Forward.URL != "" {
+			if id := hookIDs[localHookID(h.Forward.URL, layout)]; id != "" {
+				graph.Edges = append(graph.Edges, GraphEdge{From: from, To: GraphNodeHook + ":" + id, Type: GraphEdgeForwards})
+			} else {
+				target := forwardTarget(h.Forward.URL)
+				addNode(GraphNode{ID: GraphNodeEndpoint + ":" + target, Type: GraphNodeEndpoint, Label: target})
+				graph.Edges = append(graph.Edges, GraphEdge{From: from, To: GraphNodeEndpoint + ":" + target, Type: GraphEdgeForwards})
+			}
+		}
+		if name := DeployedProject(&h, projects); name != "" {
+			graph.Edges = append(graph.Edges, GraphEdge{From: from, To: GraphNodeProject + ":" + name, Type: GraphEdgeDeploys})
+		}
+	}
+
+	for _, p := range projects {
+		if p.Promotion == nil {
+			continue
+		}
+		for _, upstream := range p.Promotion.From {
+			if projectNames[upstream] && upstream != p.Name {
+				graph.Edges = append(graph.Edges, GraphEdge{From: GraphNodeProject + ":" + upstream, To: GraphNodeProject + ":" + p.Name, Type: GraphEdgePromotes})
+			}
+		}
+	}
+
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Type < b.Type
+	})
+	return graph
+}
+
+// localHookID hook id addressed by a forward URL, empty when the URL is not a hook URL.
+// The base path is optional, a proxy in front of the app may have stripped it.
+func localHookID(rawURL string, layout urls.Layout) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	p := u.Path
+	if layout.BasePath != "" && strings.HasPrefix(p, layout.BasePath+"/") {
+		p = p[len(layout.BasePath):]
+	}
+	id, _, ok := layout.MatchHook(p)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSuffix(id, "/")
+}
+
+// forwardTarget URL of a forward without query and credentials, the raw URL when it can not be parsed
+func forwardTarget(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Scheme + "://" + u.Host + u.Path
+}
+
+// DeployedProject name of the project the command of h runs in: the project whose path holds
+// the working directory, or the command when no working directory is set. The deepest
+// project path wins.
+func DeployedProject(h *Hook, projects []types.ProjectConfig) string {
+	dir := h.CommandWorkingDirectory
+	if dir == "" && filepath.IsAbs(h.ExecuteCommand) {
+		dir = filepath.Dir(h.ExecuteCommand)
+	}
+	if dir == "" {
+		return ""
+	}
+	dir = filepath.Clean(dir)
+
+	name, depth := "", -1
+	for _, p := range projects {
+		if p.Path == "" {
+			continue
+		}
+		root := filepath.Clean(p.Path)
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if d := len(root); d > depth {
+			name, depth = p.Name, d
+		}
+	}
+	return name
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/urls"
+)
+
+func TestBuildHookGraph(t *testing.T) {
+	hooks := []Hook{
+		{ID: "gateway", Forward: &ForwardConfig{URL: "http://127.0.0.1:9000/hooks/deploy-site?token=x"}},
+		{ID: "relay", Namespace: "team", Forward: &ForwardConfig{URL: "http://gohook.local/ns/team/hooks/deploy-api/"}},
+		{ID: "notify", Forward: &ForwardConfig{URL: "https://chat.example.com/api/hook?key=secret"}},
+		{ID: "deploy-site", CommandWorkingDirectory: "/srv/site/current"},
+		{ID: "deploy-api", Aliases: []string{"deploy-api-old"}, ExecuteCommand: "/srv/api/scripts/deploy.sh"},
+		{ID: "legacy", Forward: &ForwardConfig{URL: "http://127.0.0.1:9000/hooks/deploy-api-old"}},
+		{ID: "cleanup", ExecuteCommand: "cleanup.sh", CommandWorkingDirectory: "/tmp"},
+	}
+	projects := []types.ProjectConfig{
+		{Name: "site", Path: "/srv/site"},
+		{Name: "site-current", Path: "/srv/site/current"},
+		{Name: "api", Path: "/srv/api"},
+		{Name: "api-prod", Path: "/srv/api-prod", Promotion: &types.ProjectPromotionConfig{From: []string{"api", "missing"}}},
+	}
+
+	graph := BuildHookGraph(hooks, projects, urls.Layout{HooksPrefix: "hooks"})
+
+	wantEdges := []GraphEdge{
+		{From: "hook:deploy-api", To: "project:api", Type: GraphEdgeDeploys},
+		{From: "hook:deploy-site", To: "project:site-current", Type: GraphEdgeDeploys},
+		{From: "hook:gateway", To: "hook:deploy-site", Type: GraphEdgeForwards},
+		{From: "hook:legacy", To: "hook:deploy-api", Type: GraphEdgeForwards},
+		{From: "hook:notify", To: "endpoint:https://chat.example.com/api/hook", Type: GraphEdgeForwards},
+		{From: "hook:relay", To: "hook:deploy-api", Type: GraphEdgeForwards},
+		{From: "project:api", To: "project:api-prod", Type: GraphEdgePromotes},
+	}
+	if !reflect.DeepEqual(graph.Edges, wantEdges) {
+		t.Errorf("edges = %+v\nwant %+v", graph.Edges, wantEdges)
+	}
+
+	nodeTypes := map[string]string{}
+	for _, n := range graph.Nodes {
+		nodeTypes[n.ID] = n.Type
+	}
+	if len(graph.Nodes) != len(hooks)+len(projects)+1 {
+		t.Errorf("got %d nodes, want %d", len(graph.Nodes), len(hooks)+len(projects)+1)
+	}
+	if nodeTypes["endpoint:https://chat.example.com/api/hook"] != GraphNodeEndpoint || nodeTypes["hook:cleanup"] != GraphNodeHook || nodeTypes["project:api-prod"] != GraphNodeProject {
+		t.Errorf("unexpected node types: %v", nodeTypes)
+	}
+}
+
+func TestLocalHookID(t *testing.T) {
+	hooksLayout := urls.Layout{HooksPrefix: "hooks"}
+	tests := []struct {
+		url    string
+		layout urls.Layout
+		want   string
+	}{
+		{"http://localhost:9000/hooks/build", hooksLayout, "build"},
+		{"http://localhost:9000/ns/team/hooks/build/", hooksLayout, "build"},
+		{"http://localhost:9000/api/build", hooksLayout, ""},
+		{"https://example.com/hooksx/build", hooksLayout, ""},
+		{"http://localhost:9000/build", urls.Layout{}, "build"},
+		{"http://localhost:9000/ns/team/build", urls.Layout{}, "build"},
+		{"https://example.com/gohook/in/build", urls.Layout{BasePath: "/gohook", HooksPrefix: "in"}, "build"},
+		{"http://localhost:9000/in/build", urls.Layout{BasePath: "/gohook", HooksPrefix: "in"}, "build"},
+		{"https://example.com/gohook/hooks/build", urls.Layout{BasePath: "/gohook", HooksPrefix: "in"}, ""},
+	}
+	for _, tt := range tests {
+		if got := localHookID(tt.url, tt.layout); got != tt.want {
+			t.Errorf("localHookID(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/ghodss/yaml"
+	"github.com/mycoool/gohook/internal/types"
 )
 
 // Constants used to specify the parameter source
@@ -180,20 +181,34 @@ func ValidateMAC(payload []byte, mac hash.Hash, signatures []string) (string, er
 		return "", err
 	}
 
+	return matchMAC(mac, signatures, len(payload) == 0)
+}
+
+// matchMAC compare the sum of mac, which the payload was written to, with the signatures
+func matchMAC(mac hash.Hash, signatures []string, emptyPayload bool) (string, error) {
 	actualMAC := hex.EncodeToString(mac.Sum(nil))
 
 	for _, signature := range signatures {
 		if hmac.Equal([]byte(signature), []byte(actualMAC)) {
-			return actualMAC, err
+			return actualMAC, nil
 		}
 	}
 
-	e := &SignatureError{Signatures: signatures}
-	if len(payload) == 0 {
-		e.emptyPayload = true
+	return actualMAC, &SignatureError{Signatures: signatures, emptyPayload: emptyPayload}
+}
+
+// checkBodySignature verify the HMAC signature of the request body, a spooled body
+// is streamed from its temp file instead of being loaded into memory
+func checkBodySignature(r *Request, newHash func() hash.Hash, prefix, secret, signature string) (string, error) {
+	if secret == "" {
+		return "", errors.New("signature validation secret can not be empty")
 	}
 
-	return actualMAC, e
+	mac := hmac.New(newHash, []byte(secret))
+	if _, err := r.writeBody(mac); err != nil {
+		return "", err
+	}
+	return matchMAC(mac, ExtractSignatures(signature, prefix), r.BodySize() == 0)
 }
 
 // CheckPayloadSignature calculates and verifies SHA1 signature of the given payload
@@ -254,7 +269,9 @@ func CheckScalrSignature(r *Request, signingKey string, checkDate bool) (bool, e
 	providedSignature := r.Headers["X-Signature"].(string)
 	dateHeader := r.Headers["Date"].(string)
 	mac := hmac.New(sha1.New, []byte(signingKey))
-	mac.Write(r.Body)
+	if _, err := r.writeBody(mac); err != nil {
+		return false, err
+	}
 	mac.Write([]byte(dateHeader))
 	expectedSignature := hex.EncodeToString(mac.Sum(nil))
 
@@ -284,15 +301,19 @@ func CheckScalrSignature(r *Request, signingKey string, checkDate bool) (bool, e
 func CheckIPWhitelist(remoteAddr, ipRange string) (bool, error) {
 	// Extract IP address from remote address.
 
-	// IPv6 addresses will likely be surrounded by [].
-	ip := strings.Trim(remoteAddr, " []")
+	// A client IP resolved behind a proxy has no port, IPv6 addresses included.
+	parsedIP := net.ParseIP(strings.TrimSpace(remoteAddr))
+	if parsedIP == nil {
+		// IPv6 addresses will likely be surrounded by [].
+		ip := strings.Trim(remoteAddr, " []")
+
+		if i := strings.LastIndex(ip, ":"); i != -1 {
+			ip = ip[:i]
+			ip = strings.Trim(ip, " []")
+		}
 
-	if i := strings.LastIndex(ip, ":"); i != -1 {
-		ip = ip[:i]
-		ip = strings.Trim(ip, " []")
+		parsedIP = net.ParseIP(ip)
 	}
-
-	parsedIP := net.ParseIP(ip)
 	if parsedIP == nil {
 		return false, fmt.Errorf("invalid IP address found in remote address '%s'", remoteAddr)
 	}
@@ -301,7 +322,11 @@ func CheckIPWhitelist(remoteAddr, ipRange string) (bool, error) {
 		// Extract IP range in CIDR form.  If a single IP address is provided, turn it into CIDR form.
 
 		if !strings.Contains(r, "/") {
-			r = r + "/32"
+			if strings.Contains(r, ":") {
+				r = r + "/128"
+			} else {
+				r = r + "/32"
+			}
 		}
 
 		_, cidr, err := net.ParseCIDR(r)
@@ -461,7 +486,22 @@ func (ha *Argument) Get(r *Request) (string, error) {
 	case SourceString:
 		return ha.Name, nil
 
+	case SourceStarlark:
+		if r.Starlark == nil {
+			return "", fmt.Errorf("starlark argument %s but the hook has no starlark program", ha.Name)
+		}
+		v, err := r.Starlark.Call(ha.Name, r)
+		if err != nil {
+			// callers report only the argument, keep the script error in the log
+			log.Printf("[%s] %v", r.ID, err)
+			return "", err
+		}
+		return starlarkString(v)
+
 	case SourceRawRequestBody:
+		if r.Spooled() {
+			return "", fmt.Errorf("request body of %d bytes is spooled to disk, read the file named by %s", r.BodySize(), EnvRequestBodyFile)
+		}
 		return string(r.Body), nil
 
 	case SourceRequest:
@@ -569,23 +609,49 @@ func (h *HooksFiles) Set(value string) error {
 
 // Hook type is a structure containing details for a single hook
 type Hook struct {
-	ID                                  string          `json:"id,omitempty"`
-	ExecuteCommand                      string          `json:"execute-command,omitempty"`
-	CommandWorkingDirectory             string          `json:"command-working-directory,omitempty"`
-	ResponseMessage                     string          `json:"response-message,omitempty"`
-	ResponseHeaders                     ResponseHeaders `json:"response-headers,omitempty"`
-	CaptureCommandOutput                bool            `json:"include-command-output-in-response,omitempty"`
-	CaptureCommandOutputOnError         bool            `json:"include-command-output-in-response-on-error,omitempty"`
-	PassEnvironmentToCommand            []Argument      `json:"pass-environment-to-command,omitempty"`
-	PassArgumentsToCommand              []Argument      `json:"pass-arguments-to-command,omitempty"`
-	PassFileToCommand                   []Argument      `json:"pass-file-to-command,omitempty"`
-	JSONStringParameters                []Argument      `json:"parse-parameters-as-json,omitempty"`
-	TriggerRule                         *Rules          `json:"trigger-rule,omitempty"`
-	TriggerRuleMismatchHttpResponseCode int             `json:"trigger-rule-mismatch-http-response-code,omitempty"`
-	TriggerSignatureSoftFailures        bool            `json:"trigger-signature-soft-failures,omitempty"`
-	IncomingPayloadContentType          string          `json:"incoming-payload-content-type,omitempty"`
-	SuccessHttpResponseCode             int             `json:"success-http-response-code,omitempty"`
-	HTTPMethods                         []string        `json:"http-methods"`
+	ID                                  string                `json:"id,omitempty"`
+	Aliases                             []string              `json:"aliases,omitempty"`        // previous ids, still accepted in hook URLs
+	Endpoints                           []Endpoint            `json:"endpoints,omitempty"`      // additional ids with their own trigger rules
+	EndpointsOnly                       bool                  `json:"endpoints-only,omitempty"` // only the endpoints are served, deliveries to the id and aliases answer 404
+	Mirror                              string                `json:"mirror,omitempty"`         // shadow hook running a copy of each triggered delivery
+	Namespace                           string                `json:"namespace,omitempty"`      // empty means types.DefaultNamespace
+	ExecuteCommand                      string                `json:"execute-command,omitempty"`
+	Shell                               string                `json:"shell,omitempty"`               // none (default) | sh | bash | powershell
+	Sandbox                             string                `json:"sandbox,omitempty"`             // none (default) | standard | strict, Linux only
+	InheritEnvironment                  *types.EnvPolicy      `json:"inherit-environment,omitempty"` // overrides the global hook_env
+	CommandWorkingDirectory             string                `json:"command-working-directory,omitempty"`
+	ResponseMessage                     string                `json:"response-message,omitempty"`
+	ResponseHeaders                     ResponseHeaders       `json:"response-headers,omitempty"`
+	CaptureCommandOutput                bool                  `json:"include-command-output-in-response,omitempty"`
+	CaptureCommandOutputOnError         bool                  `json:"include-command-output-in-response-on-error,omitempty"`
+	PassEnvironmentToCommand            []Argument            `json:"pass-environment-to-command,omitempty"`
+	PassArgumentsToCommand              []Argument            `json:"pass-arguments-to-command,omitempty"`
+	PassFileToCommand                   []Argument            `json:"pass-file-to-command,omitempty"`
+	PassRequestBodyToStdin              bool                  `json:"pass-request-body-to-stdin,omitempty"`
+	StdinMaxBytes                       int64                 `json:"stdin-max-bytes,omitempty"` // 0 means no limit
+	StdinCharset                        string                `json:"stdin-charset,omitempty"`   // empty keeps the raw body, auto uses the Content-Type charset
+	JSONStringParameters                []Argument            `json:"parse-parameters-as-json,omitempty"`
+	TriggerRule                         *Rules                `json:"trigger-rule,omitempty"`
+	TriggerRuleMismatchHttpResponseCode int                   `json:"trigger-rule-mismatch-http-response-code,omitempty"`
+	TriggerSignatureSoftFailures        bool                  `json:"trigger-signature-soft-failures,omitempty"`
+	IncomingPayloadContentType          string                `json:"incoming-payload-content-type,omitempty"`
+	SuccessHttpResponseCode             int                   `json:"success-http-response-code,omitempty"`
+	FailureHttpResponseCode             int                   `json:"failure-http-response-code,omitempty"`
+	SuccessOutputPattern                string                `json:"success-output-pattern,omitempty"` // the run fails unless the output matches
+	FailureOutputPattern                string                `json:"failure-output-pattern,omitempty"` // the run fails when the output matches
+	HTTPMethods                         []string              `json:"http-methods"`
+	PauseWindows                        []types.PauseWindow   `json:"pause-windows,omitempty"`
+	Forward                             *ForwardConfig        `json:"forward,omitempty"`
+	ResponseTemplate                    string                `json:"response-template,omitempty"`
+	ResponseContentType                 string                `json:"response-content-type,omitempty"`
+	Idempotency                         *IdempotencyConfig    `json:"idempotency,omitempty"`
+	Artifacts                           *ArtifactsConfig      `json:"artifacts,omitempty"`
+	ObjectEvents                        *ObjectEventsConfig   `json:"object-events,omitempty"`     // S3 notifications through SNS and MinIO bucket webhooks
+	TransformPlugins                    []string              `json:"transform-plugins,omitempty"` // transformer plugins rewriting the payload, in order
+	Starlark                            *StarlarkConfig       `json:"starlark,omitempty"`          // program called by starlark rules and arguments
+	SecretRotation                      *types.SecretRotation `json:"secret-rotation,omitempty"`   // previous secret of the signature rules during its grace period
+	Budget                              *BudgetConfig         `json:"budget,omitempty"`            // failure and duration alerts, circuit breaker
+	Quota                               *types.QuotaConfig    `json:"quota,omitempty"`             // daily execution and storage limits
 }
 
 // ParseJSONParameters decodes specified arguments to JSON objects and replaces the
@@ -873,10 +939,11 @@ func (h *Hooks) Match(id string) *Hook {
 
 // Rules is a structure that contains one of the valid rule types
 type Rules struct {
-	And   *AndRule   `json:"and,omitempty"`
-	Or    *OrRule    `json:"or,omitempty"`
-	Not   *NotRule   `json:"not,omitempty"`
-	Match *MatchRule `json:"match,omitempty"`
+	And      *AndRule      `json:"and,omitempty"`
+	Or       *OrRule       `json:"or,omitempty"`
+	Not      *NotRule      `json:"not,omitempty"`
+	Match    *MatchRule    `json:"match,omitempty"`
+	Starlark *StarlarkRule `json:"starlark,omitempty"`
 }
 
 // Evaluate finds the first rule property that is not nil and returns the value
@@ -891,6 +958,8 @@ func (r Rules) Evaluate(req *Request) (bool, error) {
 		return r.Not.Evaluate(req)
 	case r.Match != nil:
 		return r.Match.Evaluate(req)
+	case r.Starlark != nil:
+		return r.Starlark.Evaluate(req)
 	}
 
 	return false, nil
@@ -988,7 +1057,7 @@ func (r MatchRule) Evaluate(req *Request) (bool, error) {
 		return CheckIPWhitelist(clientIP, r.IPRange)
 	}
 	if r.Type == ScalrSignature {
-		return CheckScalrSignature(req, r.Secret, true)
+		return r.checkScalrSignature(req)
 	}
 
 	arg, err := r.Parameter.Get(req)
@@ -1002,20 +1071,17 @@ func (r MatchRule) Evaluate(req *Request) (bool, error) {
 			log.Print(`warn: use of deprecated option payload-hash-sha1; use payload-hmac-sha1 instead`)
 			fallthrough
 		case MatchHMACSHA1:
-			_, err := CheckPayloadSignature(req.Body, r.Secret, arg)
-			return err == nil, err
+			return r.checkSignature(req, sha1.New, "sha1=", arg)
 		case MatchHashSHA256:
 			log.Print(`warn: use of deprecated option payload-hash-sha256: use payload-hmac-sha256 instead`)
 			fallthrough
 		case MatchHMACSHA256:
-			_, err := CheckPayloadSignature256(req.Body, r.Secret, arg)
-			return err == nil, err
+			return r.checkSignature(req, sha256.New, "sha256=", arg)
 		case MatchHashSHA512:
 			log.Print(`warn: use of deprecated option payload-hash-sha512: use payload-hmac-sha512 instead`)
 			fallthrough
 		case MatchHMACSHA512:
-			_, err := CheckPayloadSignature512(req.Body, r.Secret, arg)
-			return err == nil, err
+			return r.checkSignature(req, sha512.New, "sha512=", arg)
 		}
 	}
 	return false, err
@@ -205,6 +205,10 @@ var checkIPWhitelistTests = []struct {
 	{" [2001:db8:1:2::1:1234] ", "  2001:db8:1::/48 ", true, true},
 	{" [2001:db8:1:2::1:1234] ", "  2001:db8:1::/48 2001:db8:1::/64", true, true},
 	{" [2001:db8:1:2::1:1234] ", "  2001:db8:1::/64 ", false, true},
+	{"10.0.0.1", "10.0.0.0/24", true, true},
+	{"2001:db8:1:2::1", "2001:db8:1::/48", true, true},
+	{"2001:db8:1:2::1", "2001:db8:1:2::1", true, true},
+	{"2001:db8:1:2::1", "2001:db8:1:2::2", false, true},
 }
 
 func TestCheckIPWhitelist(t *testing.T) {
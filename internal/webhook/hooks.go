@@ -1,21 +1,33 @@
 package webhook
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/cluster"
 	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/httpcache"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/listing"
+	"github.com/mycoool/gohook/internal/locale"
 	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/quota"
+	"github.com/mycoool/gohook/internal/sandbox"
 	"github.com/mycoool/gohook/internal/stream"
 	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/urls"
 )
 
 // HookManager manage hook and config file loading
@@ -29,53 +41,125 @@ type hookManager struct {
 var LoadedHooksFromFiles *map[string]Hooks
 var HookManager *hookManager
 
-// GetAllHooks get all hooks
+// HandleGetAllHooks list the hooks visible to the request, by id unless ?sort names another
+// order, paginated with ?limit and ?offset and trimmed to ?fields
 func HandleGetAllHooks(c *gin.Context) {
 	if LoadedHooksFromFiles == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "hooks not loaded"})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeHooksNotLoaded, nil)
+		return
+	}
+	opts, err := listing.Parse(c, "name", "last-used", "status")
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidListQuery, err)
+		return
+	}
+	if hooksNotModified(c) {
 		return
 	}
 
-	var hooks []types.HookResponse
+	lastUsed, err := database.NewLogService().InZone(locale.FromContext(c)).LastUsed(database.HookTypeWebhook)
+	if err != nil {
+		log.Printf("failed to read the last use of hooks: %v", err)
+	}
+	hooks := []types.HookResponse{}
 	for _, hooksInFile := range *LoadedHooksFromFiles {
 		for _, h := range hooksInFile {
+			if !namespace.Allowed(c, h.Namespace) {
+				continue
+			}
 			hookResponse := convertHookToResponse(&h)
+			if at, ok := lastUsed[h.ID]; ok {
+				hookResponse.LastUsed = &at
+			}
 			hooks = append(hooks, hookResponse)
 		}
 	}
 
-	c.JSON(http.StatusOK, hooks)
+	// hooks of several files come in map order, the id gives pages a stable order
+	sort.SliceStable(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	switch opts.Sort {
+	case "name":
+		opts.SortBy(hooks, func(i, j int) int { return strings.Compare(hooks[i].Name, hooks[j].Name) })
+	case "last-used":
+		opts.SortBy(hooks, func(i, j int) int { return listing.CompareTimes(hooks[i].LastUsed, hooks[j].LastUsed) })
+	case "status":
+		opts.SortBy(hooks, func(i, j int) int { return strings.Compare(hooks[i].Status, hooks[j].Status) })
+	}
+	if err := listing.Respond(c, opts, hooks); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidListQuery, err)
+	}
+}
+
+// HandleGetHookGraph dependency graph of the hooks and projects visible to the request
+func HandleGetHookGraph(c *gin.Context) {
+	if LoadedHooksFromFiles == nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeHooksNotLoaded, nil)
+		return
+	}
+
+	var hooks []Hook
+	for _, hooksInFile := range *LoadedHooksFromFiles {
+		for _, h := range hooksInFile {
+			if namespace.Allowed(c, h.Namespace) {
+				hooks = append(hooks, h)
+			}
+		}
+	}
+	var projects []types.ProjectConfig
+	if types.GoHookVersionData != nil {
+		for _, p := range types.GoHookVersionData.Projects {
+			if namespace.Allowed(c, p.Namespace) {
+				projects = append(projects, p)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, BuildHookGraph(hooks, projects, urls.Current()))
 }
 
 // HandleGetHook 获取单个Hook的详细信息
 func HandleGetHook(c *gin.Context) {
 	hookID := c.Param("id")
 	if hookID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Hook ID is required"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeHookIDRequired, nil)
 		return
 	}
 
 	// 查找Hook
 	hook := HookManager.MatchLoadedHook(hookID)
 	if hook == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Hook not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+	if httpcache.NotModified(c, HookRevision(hook)) {
 		return
 	}
 
+	c.JSON(http.StatusOK, hookDetail(hook))
+}
+
+// hookDetail hook in the format of the hook editor
+func hookDetail(hook *Hook) map[string]interface{} {
 	// 转换Hook为前端需要的格式
 	hookResponse := map[string]interface{}{
-		"id":                                          hook.ID,
-		"execute-command":                             hook.ExecuteCommand,
-		"command-working-directory":                   hook.CommandWorkingDirectory,
-		"response-message":                            hook.ResponseMessage,
-		"http-methods":                                hook.HTTPMethods,
-		"pass-arguments-to-command":                   hook.PassArgumentsToCommand,
-		"pass-environment-to-command":                 hook.PassEnvironmentToCommand,
-		"parse-parameters-as-json":                    hook.JSONStringParameters,
-		"trigger-rule":                                hook.TriggerRule,
+		"id":                          hook.ID,
+		"namespace":                   namespace.Normalize(hook.Namespace),
+		"execute-command":             hook.ExecuteCommand,
+		"command-working-directory":   hook.CommandWorkingDirectory,
+		"response-message":            hook.ResponseMessage,
+		"http-methods":                hook.HTTPMethods,
+		"pass-arguments-to-command":   hook.PassArgumentsToCommand,
+		"pass-environment-to-command": hook.PassEnvironmentToCommand,
+		"parse-parameters-as-json":    hook.JSONStringParameters,
+		"trigger-rule":                hook.TriggerRule,
 		"trigger-rule-mismatch-http-response-code":    hook.TriggerRuleMismatchHttpResponseCode,
+		"success-http-response-code":                  hook.SuccessHttpResponseCode,
+		"failure-http-response-code":                  hook.FailureHttpResponseCode,
 		"include-command-output-in-response":          hook.CaptureCommandOutput,
 		"include-command-output-in-response-on-error": hook.CaptureCommandOutputOnError,
+		"success-output-pattern":                      hook.SuccessOutputPattern,
+		"failure-output-pattern":                      hook.FailureOutputPattern,
+		"revision":                                    HookRevision(hook),
 	}
 
 	// 转换ResponseHeaders为前端期望的map格式
@@ -85,7 +169,7 @@ func HandleGetHook(c *gin.Context) {
 	}
 	hookResponse["response-headers"] = responseHeaders
 
-	c.JSON(http.StatusOK, hookResponse)
+	return hookResponse
 }
 
 // GetHookByID get Hook by ID
@@ -129,10 +213,25 @@ func convertHookToResponse(h *Hook) types.HookResponse {
 		environmentCount = len(h.PassEnvironmentToCommand)
 	}
 
+	// a hook whose circuit breaker is open rejects its deliveries
+	open, status := circuitOpen(h), "active"
+	if open {
+		status = "inactive"
+	}
+
 	return types.HookResponse{
 		ID:                     h.ID,
 		Name:                   h.ID, // use ID as name
+		Aliases:                h.Aliases,
+		Endpoints:              h.Endpoints,
+		EndpointsOnly:          h.EndpointsOnly,
+		Mirror:                 h.Mirror,
+		SecretExpires:          rotationExpires(h.SecretRotation),
+		URL:                    urls.Current().PublicHookPath(h.ID),
+		Namespace:              namespace.Normalize(h.Namespace),
 		ExecuteCommand:         h.ExecuteCommand,
+		Shell:                  h.Shell,
+		Sandbox:                h.Sandbox,
 		WorkingDirectory:       h.CommandWorkingDirectory,
 		ResponseMessage:        h.ResponseMessage,
 		HTTPMethods:            httpMethods,
@@ -140,8 +239,26 @@ func convertHookToResponse(h *Hook) types.HookResponse {
 		EnvironmentCount:       environmentCount,
 		TriggerRuleDescription: triggerDesc,
 		TriggerRule:            h.TriggerRule,
-		LastUsed:               nil, // TODO: can add actual usage time tracking
-		Status:                 "active",
+		PauseWindows:           h.PauseWindows,
+		Forward:                h.Forward,
+		Idempotency:            h.Idempotency,
+		Artifacts:              h.Artifacts,
+		ObjectEvents:           h.ObjectEvents,
+		TransformPlugins:       h.TransformPlugins,
+		Starlark:               h.Starlark,
+		Budget:                 h.Budget,
+		CircuitOpen:            open,
+		Quota:                  h.Quota,
+		InheritEnvironment:     h.InheritEnvironment,
+		ResponseTemplate:       h.ResponseTemplate,
+		ResponseContentType:    h.ResponseContentType,
+		SuccessHTTPCode:        h.SuccessHttpResponseCode,
+		FailureHTTPCode:        h.FailureHttpResponseCode,
+		SuccessOutputPattern:   h.SuccessOutputPattern,
+		FailureOutputPattern:   h.FailureOutputPattern,
+		LastUsed:               nil, // filled in by the hook list
+		Revision:               HookRevision(h),
+		Status:                 status,
 	}
 }
 
@@ -167,6 +284,10 @@ func describeTriggerRule(rules *Rules) string {
 		return "Negated condition"
 	}
 
+	if rules.Starlark != nil {
+		return fmt.Sprintf("Starlark function %s", rules.Starlark.function())
+	}
+
 	return "Complex rules"
 }
 
@@ -215,6 +336,8 @@ func (hm *hookManager) ReloadHooks(hooksFilePath string) error {
 
 	if err != nil {
 		log.Printf("couldn't load hooks from file! %+v\n", err)
+		// the hooks of the last good version stay served
+		RecordHooksFileLoad(hooksFilePath, hm.loadedCount(hooksFilePath), err)
 		return err
 	}
 
@@ -240,7 +363,9 @@ func (hm *hookManager) ReloadHooks(hooksFilePath string) error {
 		if (hm.MatchLoadedHook(hook.ID) != nil && !wasHookIDAlreadyLoaded) || seenHooksIds[hook.ID] {
 			log.Printf("error: hook with the id %s has already been loaded!\nplease check your hooks file for duplicate hooks ids!", hook.ID)
 			log.Println("reverting hooks back to the previous configuration")
-			return nil // don't return error, just revert to previous configuration
+			err := fmt.Errorf("hook with the id %s has already been loaded", hook.ID)
+			RecordHooksFileLoad(hooksFilePath, hm.loadedCount(hooksFilePath), err)
+			return err
 		}
 
 		seenHooksIds[hook.ID] = true
@@ -251,10 +376,19 @@ func (hm *hookManager) ReloadHooks(hooksFilePath string) error {
 	if hm.LoadedHooksFromFiles != nil {
 		(*hm.LoadedHooksFromFiles)[hooksFilePath] = newHooks
 	}
+	RecordHooksFileLoad(hooksFilePath, len(newHooks), nil)
 
 	return nil
 }
 
+// loadedCount number of hooks loaded from hooksFilePath
+func (hm *hookManager) loadedCount(hooksFilePath string) int {
+	if hm.LoadedHooksFromFiles == nil {
+		return 0
+	}
+	return len((*hm.LoadedHooksFromFiles)[hooksFilePath])
+}
+
 // ReloadAllHooks load all hooks files
 func (hm *hookManager) ReloadAllHooks() error {
 	var lastError error
@@ -335,7 +469,11 @@ func (hm *hookManager) SaveHooksToFile(filePath string) error {
 		return fmt.Errorf("hooks file %s not found in loaded hooks", filePath)
 	}
 
-	return hooks.SaveToFile(filePath)
+	if err := hooks.SaveToFile(filePath); err != nil {
+		return err
+	}
+	cluster.ConfigChanged(cluster.ConfigHooks)
+	return nil
 }
 
 // SaveHookChanges 保存Hook的更改到对应的配置文件
@@ -452,9 +590,12 @@ func WatchForFileChange(watcher *fsnotify.Watcher, loadedHooksFromFiles *map[str
 	for {
 		select {
 		case event := <-(*watcher).Events:
-			if !isWatchedHooksFile(event.Name, hooksFiles) {
+			// events of the directory watch name the file differently, e.g. ./hooks.json
+			name, ok := watchedHooksFile(event.Name, hooksFiles)
+			if !ok {
 				continue
 			}
+			event.Name = name
 			if event.Op&fsnotify.Write == fsnotify.Write {
 				log.Printf("hooks file %s modified\n", event.Name)
 				reloadHooks(event.Name, asTemplate)
@@ -497,112 +638,28 @@ func WatchForFileChange(watcher *fsnotify.Watcher, loadedHooksFromFiles *map[str
 	}
 }
 
-func isWatchedHooksFile(path string, hooksFiles []string) bool {
+// watchedHooksFile configured hooks file path refers to
+func watchedHooksFile(path string, hooksFiles []string) (string, bool) {
 	path = filepath.Clean(path)
 	for _, f := range hooksFiles {
 		if filepath.Clean(f) == path {
-			return true
+			return f, true
 		}
 	}
-	return false
+	return "", false
 }
 
-// makeHumanPattern builds a human-friendly URL for display.
-func MakeHumanPattern(prefix *string) string {
-	if prefix == nil || *prefix == "" {
-		return "/{id}"
+// loggedBody request body stored in the execution log, a spooled body is left out
+func loggedBody(r *Request) string {
+	if r.Spooled() {
+		return fmt.Sprintf("[request body of %d bytes spooled to disk, not logged]", r.BodySize())
 	}
-	return "/" + *prefix + "/{id}"
+	return string(r.Body)
 }
 
 func HandleHook(h *Hook, r *Request) (string, error) {
-	var errors []error
-
-	// check the command exists
-	var lookpath string
-	if filepath.IsAbs(h.ExecuteCommand) || h.CommandWorkingDirectory == "" {
-		lookpath = h.ExecuteCommand
-	} else {
-		lookpath = filepath.Join(h.CommandWorkingDirectory, h.ExecuteCommand)
-	}
-
-	cmdPath, err := exec.LookPath(lookpath)
-	if err != nil {
-		log.Printf("[%s] error in %s", r.ID, err)
-
-		// check if parameters specified in execute-command by mistake
-		if strings.IndexByte(h.ExecuteCommand, ' ') != -1 {
-			s := strings.Fields(h.ExecuteCommand)[0]
-			log.Printf("[%s] use 'pass-arguments-to-command' to specify args for '%s'", r.ID, s)
-		}
-
-		return "", err
-	}
-
-	cmd := exec.Command(cmdPath)
-	cmd.Dir = h.CommandWorkingDirectory
-
-	cmd.Args, errors = h.ExtractCommandArguments(r)
-	for _, err := range errors {
-		log.Printf("[%s] error extracting command arguments: %s\n", r.ID, err)
-	}
-
-	var envs []string
-	envs, errors = h.ExtractCommandArgumentsForEnv(r)
-
-	for _, err := range errors {
-		log.Printf("[%s] error extracting command arguments for environment: %s\n", r.ID, err)
-	}
-
-	files, errors := h.ExtractCommandArgumentsForFile(r)
-
-	for _, err := range errors {
-		log.Printf("[%s] error extracting command arguments for file: %s\n", r.ID, err)
-	}
-
-	for i := range files {
-		tmpfile, err := os.CreateTemp(h.CommandWorkingDirectory, files[i].EnvName)
-		if err != nil {
-			log.Printf("[%s] error creating temp file [%s]", r.ID, err)
-			continue
-		}
-		log.Printf("[%s] writing env %s file %s", r.ID, files[i].EnvName, tmpfile.Name())
-		if _, err := tmpfile.Write(files[i].Data); err != nil {
-			log.Printf("[%s] error writing file %s [%s]", r.ID, tmpfile.Name(), err)
-			continue
-		}
-		if err := tmpfile.Close(); err != nil {
-			log.Printf("[%s] error closing file %s [%s]", r.ID, tmpfile.Name(), err)
-			continue
-		}
-
-		files[i].File = tmpfile
-		envs = append(envs, files[i].EnvName+"="+tmpfile.Name())
-	}
-
-	cmd.Env = append(os.Environ(), envs...)
-
-	log.Printf("[%s] executing %s (%s) with arguments %q and environment %s using %s as cwd\n", r.ID, h.ExecuteCommand, cmd.Path, cmd.Args, envs, cmd.Dir)
-
-	out, err := cmd.CombinedOutput()
-
-	log.Printf("[%s] command output: %s\n", r.ID, out)
-
-	if err != nil {
-		log.Printf("[%s] error occurred: %+v\n", r.ID, err)
-	}
-
-	for i := range files {
-		if files[i].File != nil {
-			log.Printf("[%s] removing file %s\n", r.ID, files[i].File.Name())
-			err := os.Remove(files[i].File.Name())
-			if err != nil {
-				log.Printf("[%s] error removing file %s [%s]", r.ID, files[i].File.Name(), err)
-			}
-		}
-	}
-
-	log.Printf("[%s] finished handling %s\n", r.ID, h.ID)
+	out, duration, err := runHookCommand(h, r)
+	recordBudget(h, duration, err != nil, time.Now())
 
 	// 记录Webhook执行日志到数据库
 	method := ""
@@ -614,6 +671,10 @@ func HandleHook(h *Hook, r *Request) (string, error) {
 	if r.RawRequest != nil {
 		method = r.RawRequest.Method
 		remoteAddr = r.RawRequest.RemoteAddr
+		if r.ClientIP != "" {
+			// the client behind a trusted proxy, not the proxy itself
+			remoteAddr = r.ClientIP
+		}
 		headers = r.RawRequest.Header
 		userAgent = r.RawRequest.UserAgent()
 
@@ -623,27 +684,32 @@ func HandleHook(h *Hook, r *Request) (string, error) {
 		}
 	}
 
+	// the output is logged and broadcast without the secrets of the command environment
+	loggedOut := r.redacted(out)
+
 	// 使用database包记录Hook执行日志
-	database.LogHookExecution(
-		h.ID,           // hookID
-		h.ID,           // hookName
-		"webhook",      // hookType
-		method,         // method
-		remoteAddr,     // remoteAddr
-		headers,        // headers
-		string(r.Body), // body
-		err == nil,     // success
-		string(out),    // output
+	logID := database.LogHookExecution(
+		h.ID,          // hookID
+		r.Alias,       // alias
+		h.ID,          // hookName
+		"webhook",     // hookType
+		method,        // method
+		remoteAddr,    // remoteAddr
+		headers,       // headers
+		loggedBody(r), // body
+		err == nil,    // success
+		loggedOut,     // output
 		func() string { // error
 			if err != nil {
-				return err.Error()
+				return r.redacted(err.Error())
 			}
 			return ""
 		}(),
-		cmd.ProcessState.UserTime().Nanoseconds()/1000000, // duration (毫秒)
-		userAgent,   // userAgent
-		queryParams, // queryParams
+		duration.Milliseconds(), // duration (毫秒)
+		userAgent,               // userAgent
+		queryParams,             // queryParams
 	)
+	saveArtifacts(h, r.ID, logID)
 
 	// push WebSocket message to notify hook execution completed
 	wsMessage := stream.WsMessage{
@@ -655,26 +721,118 @@ func HandleHook(h *Hook, r *Request) (string, error) {
 			Method:     method,
 			RemoteAddr: remoteAddr,
 			Success:    err == nil,
-			Output:     string(out),
+			Output:     loggedOut,
 			Error: func() string {
 				if err != nil {
-					return err.Error()
+					return r.redacted(err.Error())
 				} else {
 					return ""
 				}
 			}(),
+			LogID: logID,
 		},
+		Namespace: namespace.Normalize(h.Namespace),
+		Sealed:    database.HookLogsEncrypted(),
 	}
 	stream.Global.Broadcast(wsMessage)
 
-	return string(out), err
+	return out, err
+}
+
+// triggerRequest optional body of a manual trigger
+type triggerRequest struct {
+	// Payload is a synthetic webhook payload used for argument extraction
+	Payload map[string]interface{} `json:"payload"`
+	// Headers and Query simulate request headers and URL query values
+	Headers map[string]string `json:"headers"`
+	Query   map[string]string `json:"query"`
+	// Args are appended to the extracted command arguments
+	Args []string `json:"args"`
+	// Env overrides environment variables of the command
+	Env map[string]string `json:"env"`
+}
+
+// envNamePattern valid environment variable name for trigger overrides
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// newTriggerRequest build a synthetic webhook request for a manual trigger
+func newTriggerRequest(c *gin.Context, hookID string, req triggerRequest) (*Request, error) {
+	r := &Request{
+		ID:          fmt.Sprintf("manual-%s-%d", hookID, time.Now().UnixNano()),
+		ContentType: "application/json",
+		Payload:     req.Payload,
+		RawRequest:  c.Request,
+		ClientIP:    middleware.GetClientIP(c),
+		ExtraArgs:   req.Args,
+	}
+	if r.Payload == nil {
+		r.Payload = make(map[string]interface{})
+	}
+	body, err := json.Marshal(r.Payload)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = body
+
+	headers := http.Header{}
+	for k, v := range req.Headers {
+		headers.Set(k, v)
+	}
+	if ct := headers.Get("Content-Type"); ct != "" {
+		r.ContentType = ct
+	}
+	r.ParseHeaders(headers)
+
+	query := url.Values{}
+	for k, v := range req.Query {
+		query.Set(k, v)
+	}
+	r.ParseQuery(query)
+
+	envNames := make([]string, 0, len(req.Env))
+	for k := range req.Env {
+		if !envNamePattern.MatchString(k) {
+			return nil, fmt.Errorf("invalid environment variable name: %s", k)
+		}
+		envNames = append(envNames, k)
+	}
+	sort.Strings(envNames)
+	for _, k := range envNames {
+		r.ExtraEnv = append(r.ExtraEnv, k+"="+req.Env[k])
+	}
+	return r, nil
 }
 
 func HandleTriggerHook(c *gin.Context) {
 	hookID := c.Param("id")
-	hookResponse := GetHookByID(hookID)
-	if hookResponse == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Hook not found"})
+	hook := HookManager.MatchLoadedHook(hookID)
+	if hook == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+	hookResponse := convertHookToResponse(hook)
+
+	var req triggerRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+			return
+		}
+	}
+	r, err := newTriggerRequest(c, hookID, req)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidTriggerParams, err)
+		return
+	}
+	r.Starlark = hook.Starlark
+	for _, err := range hook.ParseJSONParameters(r) {
+		log.Printf("[%s] error parsing JSON parameters: %s", r.ID, err)
+	}
+
+	// manual triggers count against the quotas of the user, the hook and its namespace
+	username := c.GetString("username")
+	if usage := quota.Check(quotaSubject(hook, username)); usage != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": i18n.Message(c, i18n.CodeHookQuotaExceeded, usage.Reason()), "code": i18n.CodeHookQuotaExceeded, "quota": usage})
 		return
 	}
 
@@ -682,31 +840,27 @@ func HandleTriggerHook(c *gin.Context) {
 	success := false
 	output := ""
 	errorMsg := ""
+	errorCode := ""
+	var duration time.Duration
 
-	if hookResponse.ExecuteCommand != "" {
-		// execute command
-		var cmd *exec.Cmd
-
+	if hook.ExecuteCommand != "" || hook.Forward != nil {
 		// 检查工作目录是否存在
-		if hookResponse.WorkingDirectory != "" {
-			if _, err := os.Stat(hookResponse.WorkingDirectory); os.IsNotExist(err) {
-				errorMsg = fmt.Sprintf("工作目录不存在: %s", hookResponse.WorkingDirectory)
-				output = fmt.Sprintf("错误：工作目录 '%s' 不存在，请检查Hook配置", hookResponse.WorkingDirectory)
-			} else {
-				cmd = exec.Command("bash", "-c", hookResponse.ExecuteCommand)
-				cmd.Dir = hookResponse.WorkingDirectory
+		if hook.Forward == nil && hook.CommandWorkingDirectory != "" {
+			if _, err := os.Stat(hook.CommandWorkingDirectory); os.IsNotExist(err) {
+				errorCode = i18n.CodeWorkingDirNotFound
+				errorMsg = i18n.Message(c, errorCode, hook.CommandWorkingDirectory)
+				output = errorMsg
 			}
-		} else {
-			cmd = exec.Command("bash", "-c", hookResponse.ExecuteCommand)
 		}
 
-		if cmd != nil {
-			result, err := cmd.CombinedOutput()
-			output = string(result)
+		if errorMsg == "" {
+			var err error
+			output, duration, err = runHookCommand(hook, r)
 			if err != nil {
-				errorMsg = fmt.Sprintf("命令执行失败: %v", err)
+				errorCode = i18n.CodeCommandFailed
+				errorMsg = i18n.Message(c, errorCode) + ": " + err.Error()
 				if output == "" {
-					output = fmt.Sprintf("命令执行出错: %v", err)
+					output = errorMsg
 				}
 			} else {
 				success = true
@@ -714,27 +868,39 @@ func HandleTriggerHook(c *gin.Context) {
 		}
 	} else {
 		success = true
-		output = "Hook triggered successfully (no execute command)"
+		output = i18n.Message(c, i18n.CodeHookNoCommand)
 	}
 
 	// 记录手动触发的Webhook执行日志到数据库
-	database.LogHookExecution(
+	logID := database.LogHookExecution(
 		hookID,                    // hookID
+		"",                        // alias
 		hookResponse.Name,         // hookName
 		"webhook",                 // hookType
 		c.Request.Method,          // method
 		middleware.GetClientIP(c), // remoteAddr
 		c.Request.Header,          // headers
-		"",                        // body (手动触发无请求体)
+		string(r.Body),            // body (合成的 payload)
 		success,                   // success
-		output,                    // output
-		errorMsg,                  // error
-		0,                         // duration (手动触发无精确执行时间)
+		r.redacted(output),        // output
+		r.redacted(errorMsg),      // error
+		duration.Milliseconds(),   // duration
 		c.Request.UserAgent(),     // userAgent
 		map[string][]string{ // queryParams
 			"trigger": {"manual"},
 		},
 	)
+	saveArtifacts(hook, r.ID, logID)
+	database.LogHookManagement(
+		database.UserActionTriggerHook,
+		hookID,
+		hookID,
+		username,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		success,
+		map[string]interface{}{"logId": logID},
+	)
 
 	// push WebSocket message
 	wsMessage := stream.WsMessage{
@@ -746,33 +912,71 @@ func HandleTriggerHook(c *gin.Context) {
 			Method:     c.Request.Method,
 			RemoteAddr: middleware.GetClientIP(c),
 			Success:    success,
-			Output:     output,
-			Error:      errorMsg,
+			Output:     r.redacted(output),
+			Error:      r.redacted(errorMsg),
+			LogID:      logID,
 		},
+		Namespace: namespace.Normalize(hook.Namespace),
+		Sealed:    database.HookLogsEncrypted(),
 	}
 	stream.Global.Broadcast(wsMessage)
 
 	if success {
 		c.JSON(http.StatusOK, gin.H{
-			"message": "Hook triggered successfully",
+			"message": i18n.Message(c, i18n.CodeHookTriggered),
+			"code":    i18n.CodeHookTriggered,
 			"hook":    hookResponse.Name,
 			"output":  output,
+			"logId":   logID,
 		})
 	} else {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Hook triggered failed",
-			"hook":    hookResponse.Name,
-			"error":   errorMsg,
-			"output":  output,
+			"error":  errorMsg,
+			"code":   errorCode,
+			"hook":   hookResponse.Name,
+			"output": output,
+			"logId":  logID,
 		})
 	}
 }
 
+// HandleTestHook send a synthetic GitHub, GitLab or Gitea event to the hook endpoint
+func HandleTestHook(c *gin.Context) {
+	hookID := c.Param("id")
+	hook := HookManager.MatchLoadedHook(hookID)
+	if hook == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+
+	var opts TestEventOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	delivery, err := BuildTestDelivery(hook, opts)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidTestEvent, err)
+		return
+	}
+	if opts.DryRun {
+		c.JSON(http.StatusOK, gin.H{"request": delivery})
+		return
+	}
+
+	result, err := SendTestDelivery(delivery)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": i18n.Message(c, i18n.CodeTestDeliveryFailed) + ": " + err.Error(), "code": i18n.CodeTestDeliveryFailed, "request": delivery})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"request": delivery, "response": result})
+}
+
 func HandleGetHookByID(c *gin.Context) {
 	hookID := c.Param("id")
 	hookResponse := GetHookByID(hookID)
 	if hookResponse == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Hook not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
 		return
 	}
 	c.JSON(http.StatusOK, hookResponse)
@@ -780,9 +984,7 @@ func HandleGetHookByID(c *gin.Context) {
 
 func HandleReloadHooksConfig(c *gin.Context) {
 	if HookManager == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Hook manager not initialized",
-		})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeHooksNotLoaded, nil)
 		return
 	}
 
@@ -790,9 +992,11 @@ func HandleReloadHooksConfig(c *gin.Context) {
 	err := HookManager.ReloadAllHooks()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":     "Load Hook failed",
+			"error":     i18n.Message(c, i18n.CodeReloadHooksFailed),
+			"code":      i18n.CodeReloadHooksFailed,
 			"details":   err.Error(),
 			"hookCount": HookManager.GetHookCount(),
+			"files":     HooksFileStatuses(), // broken files keep serving their previous hooks
 		})
 		return
 	}
@@ -801,7 +1005,8 @@ func HandleReloadHooksConfig(c *gin.Context) {
 	hookCount := HookManager.GetHookCount()
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":   "Hooks config loaded successfully",
+		"message":   i18n.Message(c, i18n.CodeHooksReloaded),
+		"code":      i18n.CodeHooksReloaded,
 		"hookCount": hookCount,
 	})
 }
@@ -881,14 +1086,19 @@ func HandleGetHookScript(c *gin.Context) {
 	// 检查 hook 是否存在，并获取配置
 	hook := HookManager.MatchLoadedHook(hookID)
 	if hook == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Hook not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
 		return
 	}
 
 	// 使用hook配置中的execute-command作为脚本路径
 	scriptPath := hook.ExecuteCommand
 	if scriptPath == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Hook has no execute-command configured"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeHookNoCommandSet, nil)
+		return
+	}
+
+	if !ScriptPathAllowed(scriptPath) {
+		denyScriptPath(c, hookID, scriptPath, "read_hook_script")
 		return
 	}
 
@@ -900,8 +1110,9 @@ func HandleGetHookScript(c *gin.Context) {
 			"path":         scriptPath,
 			"isExecutable": true,
 			"editable":     false,
-			"message":      "这是一个系统可执行文件，不可编辑",
-			"suggestion":   "如需使用脚本，请在基本信息中将执行命令修改为脚本文件路径，例如：/path/to/your-script.sh",
+			"message":      i18n.Message(c, i18n.CodeScriptIsSystemExecutable),
+			"code":         i18n.CodeScriptIsSystemExecutable,
+			"suggestion":   i18n.Message(c, i18n.CodeScriptPathSuggestion),
 		})
 		return
 	}
@@ -922,10 +1133,11 @@ func HandleGetHookScript(c *gin.Context) {
 	// 检查是否为目录
 	if fileInfo.IsDir() {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error":      "指定的路径是一个目录，不是文件",
+			"error":      i18n.Message(c, i18n.CodeScriptPathIsDirectory),
+			"code":       i18n.CodeScriptPathIsDirectory,
 			"path":       scriptPath,
 			"editable":   false,
-			"suggestion": "请在基本信息中修改执行命令为具体的脚本文件路径",
+			"suggestion": i18n.Message(c, i18n.CodeScriptDirSuggestion),
 		})
 		return
 	}
@@ -933,7 +1145,7 @@ func HandleGetHookScript(c *gin.Context) {
 	// 读取文件内容
 	content, err := os.ReadFile(scriptPath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取脚本文件失败: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeReadScriptFailed, err)
 		return
 	}
 
@@ -945,8 +1157,9 @@ func HandleGetHookScript(c *gin.Context) {
 			"path":         scriptPath,
 			"isExecutable": true,
 			"editable":     false,
-			"message":      "这是一个二进制可执行文件，不可编辑",
-			"suggestion":   "如需使用脚本，请在基本信息中将执行命令修改为脚本文件路径，例如：/path/to/your-script.sh",
+			"message":      i18n.Message(c, i18n.CodeScriptIsBinary),
+			"code":         i18n.CodeScriptIsBinary,
+			"suggestion":   i18n.Message(c, i18n.CodeScriptPathSuggestion),
 		})
 		return
 	}
@@ -965,7 +1178,7 @@ func HandleUpdateHookResponse(c *gin.Context) {
 	hookID := c.Param("id")
 	existingHook := HookManager.MatchLoadedHook(hookID)
 	if existingHook == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Hook not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
 		return
 	}
 
@@ -974,18 +1187,47 @@ func HandleUpdateHookResponse(c *gin.Context) {
 		ResponseHeaders                       map[string]string `json:"response-headers,omitempty"`
 		IncludeCommandOutputInResponse        bool              `json:"include-command-output-in-response,omitempty"`
 		IncludeCommandOutputInResponseOnError bool              `json:"include-command-output-in-response-on-error,omitempty"`
+		ResponseTemplate                      *string           `json:"response-template"`
+		ResponseContentType                   *string           `json:"response-content-type"`
+		SuccessHTTPResponseCode               *int              `json:"success-http-response-code"`
+		FailureHTTPResponseCode               *int              `json:"failure-http-response-code"`
+		SuccessOutputPattern                  *string           `json:"success-output-pattern"`
+		FailureOutputPattern                  *string           `json:"failure-output-pattern"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
+	for _, code := range []*int{request.SuccessHTTPResponseCode, request.FailureHTTPResponseCode} {
+		if code != nil && *code != 0 && !validStatusCode(*code) {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidResponseCode, nil)
+			return
+		}
+	}
+	if request.ResponseTemplate != nil {
+		if _, err := parseResponseTemplate(*request.ResponseTemplate); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidResponseTemplate, err)
+			return
+		}
+	}
+	for name, pattern := range map[string]*string{
+		"success-output-pattern": request.SuccessOutputPattern,
+		"failure-output-pattern": request.FailureOutputPattern,
+	} {
+		if pattern != nil {
+			if _, err := regexp.Compile(*pattern); err != nil {
+				i18n.JSONErrorf(c, http.StatusBadRequest, i18n.CodeInvalidOutputPattern, name, err)
+				return
+			}
+		}
+	}
 
 	// 验证HTTP方法
 	validMethods := map[string]bool{"GET": true, "POST": true, "PUT": true, "DELETE": true, "PATCH": true}
 	for _, method := range request.HTTPMethods {
 		if !validMethods[strings.ToUpper(method)] {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("无效的HTTP方法: %s", method)})
+			i18n.JSONErrorf(c, http.StatusBadRequest, i18n.CodeInvalidHTTPMethod, method)
 			return
 		}
 	}
@@ -995,6 +1237,12 @@ func HandleUpdateHookResponse(c *gin.Context) {
 	originalResponseHeaders := existingHook.ResponseHeaders
 	originalCaptureCommandOutput := existingHook.CaptureCommandOutput
 	originalCaptureCommandOutputOnError := existingHook.CaptureCommandOutputOnError
+	originalResponseTemplate := existingHook.ResponseTemplate
+	originalResponseContentType := existingHook.ResponseContentType
+	originalSuccessCode := existingHook.SuccessHttpResponseCode
+	originalFailureCode := existingHook.FailureHttpResponseCode
+	originalSuccessPattern := existingHook.SuccessOutputPattern
+	originalFailurePattern := existingHook.FailureOutputPattern
 
 	// 更新响应配置
 	if len(request.HTTPMethods) > 0 {
@@ -1014,6 +1262,24 @@ func HandleUpdateHookResponse(c *gin.Context) {
 
 	existingHook.CaptureCommandOutput = request.IncludeCommandOutputInResponse
 	existingHook.CaptureCommandOutputOnError = request.IncludeCommandOutputInResponseOnError
+	if request.ResponseTemplate != nil {
+		existingHook.ResponseTemplate = *request.ResponseTemplate
+	}
+	if request.ResponseContentType != nil {
+		existingHook.ResponseContentType = *request.ResponseContentType
+	}
+	if request.SuccessHTTPResponseCode != nil {
+		existingHook.SuccessHttpResponseCode = *request.SuccessHTTPResponseCode
+	}
+	if request.FailureHTTPResponseCode != nil {
+		existingHook.FailureHttpResponseCode = *request.FailureHTTPResponseCode
+	}
+	if request.SuccessOutputPattern != nil {
+		existingHook.SuccessOutputPattern = *request.SuccessOutputPattern
+	}
+	if request.FailureOutputPattern != nil {
+		existingHook.FailureOutputPattern = *request.FailureOutputPattern
+	}
 
 	// 保存到配置文件
 	if err := HookManager.SaveHookChanges(hookID); err != nil {
@@ -1022,6 +1288,12 @@ func HandleUpdateHookResponse(c *gin.Context) {
 		existingHook.ResponseHeaders = originalResponseHeaders
 		existingHook.CaptureCommandOutput = originalCaptureCommandOutput
 		existingHook.CaptureCommandOutputOnError = originalCaptureCommandOutputOnError
+		existingHook.ResponseTemplate = originalResponseTemplate
+		existingHook.ResponseContentType = originalResponseContentType
+		existingHook.SuccessHttpResponseCode = originalSuccessCode
+		existingHook.FailureHttpResponseCode = originalFailureCode
+		existingHook.SuccessOutputPattern = originalSuccessPattern
+		existingHook.FailureOutputPattern = originalFailurePattern
 
 		// 记录失败的日志
 		username, _ := c.Get("username")
@@ -1046,6 +1318,15 @@ func HandleUpdateHookResponse(c *gin.Context) {
 					"responseHeaders": request.ResponseHeaders,
 					"captureOutput":   request.IncludeCommandOutputInResponse,
 					"captureError":    request.IncludeCommandOutputInResponseOnError,
+					"responseTemplate": map[string]interface{}{
+						"old": originalResponseTemplate,
+						"new": existingHook.ResponseTemplate,
+					},
+					"responseContentType": existingHook.ResponseContentType,
+					"successCode":         existingHook.SuccessHttpResponseCode,
+					"failureCode":         existingHook.FailureHttpResponseCode,
+					"successPattern":      existingHook.SuccessOutputPattern,
+					"failurePattern":      existingHook.FailureOutputPattern,
 				},
 			},
 		)
@@ -1064,7 +1345,7 @@ func HandleUpdateHookResponse(c *gin.Context) {
 		}
 		stream.Global.Broadcast(wsMessage)
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save hook changes: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
 		return
 	}
 
@@ -1090,6 +1371,15 @@ func HandleUpdateHookResponse(c *gin.Context) {
 				"responseHeaders": request.ResponseHeaders,
 				"captureOutput":   request.IncludeCommandOutputInResponse,
 				"captureError":    request.IncludeCommandOutputInResponseOnError,
+				"responseTemplate": map[string]interface{}{
+					"old": originalResponseTemplate,
+					"new": existingHook.ResponseTemplate,
+				},
+				"responseContentType": existingHook.ResponseContentType,
+				"successCode":         existingHook.SuccessHttpResponseCode,
+				"failureCode":         existingHook.FailureHttpResponseCode,
+				"successPattern":      existingHook.SuccessOutputPattern,
+				"failurePattern":      existingHook.FailureOutputPattern,
 			},
 		},
 	)
@@ -1108,7 +1398,8 @@ func HandleUpdateHookResponse(c *gin.Context) {
 	stream.Global.Broadcast(wsMessage)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Hook响应配置更新成功",
+		"message": i18n.Message(c, i18n.CodeHookResponseUpdated),
+		"code":    i18n.CodeHookResponseUpdated,
 		"hookId":  hookID,
 	})
 }
@@ -1120,7 +1411,7 @@ func HandleSaveHookScript(c *gin.Context) {
 	// 检查 hook 是否存在，并获取配置
 	hook := HookManager.MatchLoadedHook(hookID)
 	if hook == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Hook not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
 		return
 	}
 
@@ -1129,7 +1420,7 @@ func HandleSaveHookScript(c *gin.Context) {
 		Path    string `json:"path,omitempty"` // 允许前端指定脚本路径
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, nil)
 		return
 	}
 
@@ -1140,11 +1431,43 @@ func HandleSaveHookScript(c *gin.Context) {
 	} else {
 		scriptPath = hook.ExecuteCommand
 		if scriptPath == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Hook has no execute-command configured and no path provided"})
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeScriptPathRequired, nil)
 			return
 		}
 	}
 
+	if !ScriptPathAllowed(scriptPath) {
+		denyScriptPath(c, hookID, scriptPath, "save_hook_script")
+		return
+	}
+
+	// 语法检查，失败时按配置拒绝保存
+	check := CheckScript(c.Request.Context(), scriptPath, []byte(req.Content))
+	if !check.OK && scriptRejectOnError() {
+		username := c.GetString("username")
+		if username == "" {
+			username = "unknown"
+		}
+		database.LogHookManagement(
+			database.UserActionSaveHookScript,
+			hookID,
+			hookID,
+			username,
+			middleware.GetClientIP(c),
+			c.Request.UserAgent(),
+			false,
+			map[string]interface{}{
+				"hookId":     hookID,
+				"error":      "script syntax check failed",
+				"action":     "save_hook_script",
+				"scriptPath": scriptPath,
+				"issues":     check.Issues,
+			},
+		)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": i18n.Message(c, i18n.CodeScriptCheckFailed), "code": i18n.CodeScriptCheckFailed, "check": check})
+		return
+	}
+
 	// 确保脚本文件所在目录存在
 	scriptDir := filepath.Dir(scriptPath)
 	if err := os.MkdirAll(scriptDir, 0755); err != nil {
@@ -1184,7 +1507,7 @@ func HandleSaveHookScript(c *gin.Context) {
 		}
 		stream.Global.Broadcast(wsMessage)
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建脚本目录失败: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeCreateScriptDirFailed, err)
 		return
 	}
 
@@ -1227,7 +1550,7 @@ func HandleSaveHookScript(c *gin.Context) {
 		}
 		stream.Global.Broadcast(wsMessage)
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存脚本文件失败: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveScriptFailed, err)
 		return
 	}
 
@@ -1267,34 +1590,90 @@ func HandleSaveHookScript(c *gin.Context) {
 	stream.Global.Broadcast(wsMessage)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "脚本文件保存成功",
+		"message": i18n.Message(c, i18n.CodeScriptSaved),
+		"code":    i18n.CodeScriptSaved,
 		"path":    scriptPath,
+		"check":   check,
 	})
 }
 
+// HandleCheckHookScript run the syntax checks on a script without saving it
+func HandleCheckHookScript(c *gin.Context) {
+	hookID := c.Param("id")
+	hook := HookManager.MatchLoadedHook(hookID)
+	if hook == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+
+	var req struct {
+		Content string `json:"content"`
+		Path    string `json:"path,omitempty"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, nil)
+		return
+	}
+	scriptPath := req.Path
+	if scriptPath == "" {
+		scriptPath = hook.ExecuteCommand
+	}
+
+	c.JSON(http.StatusOK, CheckScript(c.Request.Context(), scriptPath, []byte(req.Content)))
+}
+
 // HandleCreateHook 创建新的Hook
 func HandleCreateHook(c *gin.Context) {
 	var request struct {
-		ID                      string `json:"id" binding:"required"`
-		ExecuteCommand          string `json:"execute-command" binding:"required"`
-		CommandWorkingDirectory string `json:"command-working-directory,omitempty"`
-		ResponseMessage         string `json:"response-message,omitempty"`
+		ID                      string         `json:"id" binding:"required"`
+		ExecuteCommand          string         `json:"execute-command"`
+		CommandWorkingDirectory string         `json:"command-working-directory,omitempty"`
+		ResponseMessage         string         `json:"response-message,omitempty"`
+		Forward                 *ForwardConfig `json:"forward,omitempty"`
+		Namespace               string         `json:"namespace,omitempty"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	hookNamespace := namespace.ForCreate(c, request.Namespace)
+	if !namespace.Exists(namespace.Normalize(hookNamespace)) {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeNamespaceNotFound, nil)
+		return
+	}
+	// a hook either runs a command or forwards the request
+	if request.Forward != nil {
+		if err := request.Forward.Validate(); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidForward, err)
+			return
+		}
+	} else if request.ExecuteCommand == "" {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeCommandOrForwardRequired, nil)
 		return
 	}
 
-	// 检查Hook ID是否已存在
-	if HookManager.MatchLoadedHook(request.ID) != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Hook with this ID already exists"})
+	if request.ExecuteCommand != "" && !CommandAllowed(request.ExecuteCommand, request.CommandWorkingDirectory, "") {
+		denyScriptPath(c, request.ID, request.ExecuteCommand, "create_hook")
+		return
+	}
+	if request.ExecuteCommand != "" {
+		if err := CheckCommandPolicy(request.ExecuteCommand, request.CommandWorkingDirectory, ""); err != nil {
+			denyCommand(c, request.ID, request.ExecuteCommand, "create_hook", err)
+			return
+		}
+	}
+
+	// 检查Hook ID是否已存在（包括重命名Hook保留的旧ID）
+	if HookManager.IDInUse(request.ID) {
+		i18n.JSONError(c, http.StatusConflict, i18n.CodeHookIDExists, nil)
 		return
 	}
 
 	// 创建新的Hook，使用默认值
 	newHook := Hook{
 		ID:                                  request.ID,
+		Namespace:                           hookNamespace,
 		ExecuteCommand:                      request.ExecuteCommand,
 		CommandWorkingDirectory:             request.CommandWorkingDirectory,
 		ResponseMessage:                     request.ResponseMessage,
@@ -1307,6 +1686,7 @@ func HandleCreateHook(c *gin.Context) {
 		TriggerRule:                         nil,               // 默认无触发规则
 		TriggerRuleMismatchHttpResponseCode: 400,               // 默认错误码
 		ResponseHeaders:                     ResponseHeaders{}, // 默认无响应头
+		Forward:                             request.Forward,
 	}
 
 	// 添加到内存中的第一个配置文件
@@ -1369,7 +1749,7 @@ func HandleCreateHook(c *gin.Context) {
 			}
 			stream.Global.Broadcast(wsMessage)
 
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save hook to file: " + err.Error()})
+			i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
 			return
 		}
 	}
@@ -1412,7 +1792,8 @@ func HandleCreateHook(c *gin.Context) {
 	stream.Global.Broadcast(wsMessage)
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Hook创建成功",
+		"message": i18n.Message(c, i18n.CodeHookCreated),
+		"code":    i18n.CodeHookCreated,
 		"hookId":  request.ID,
 	})
 }
@@ -1422,7 +1803,7 @@ func HandleUpdateHookBasic(c *gin.Context) {
 	hookID := c.Param("id")
 	existingHook := HookManager.MatchLoadedHook(hookID)
 	if existingHook == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Hook not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
 		return
 	}
 
@@ -1433,9 +1814,21 @@ func HandleUpdateHookBasic(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	commandChanged := request.ExecuteCommand != existingHook.ExecuteCommand || request.CommandWorkingDirectory != existingHook.CommandWorkingDirectory
+	if commandChanged && !CommandAllowed(request.ExecuteCommand, request.CommandWorkingDirectory, existingHook.Shell) {
+		denyScriptPath(c, hookID, request.ExecuteCommand, "update_hook_basic")
 		return
 	}
+	if commandChanged {
+		if err := CheckCommandPolicy(request.ExecuteCommand, request.CommandWorkingDirectory, existingHook.Shell); err != nil {
+			denyCommand(c, hookID, request.ExecuteCommand, "update_hook_basic", err)
+			return
+		}
+	}
 
 	// 备份原值，以便保存失败时恢复和记录日志
 	originalExecuteCommand := existingHook.ExecuteCommand
@@ -1494,7 +1887,7 @@ func HandleUpdateHookBasic(c *gin.Context) {
 		}
 		stream.Global.Broadcast(wsMessage)
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save hook changes: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
 		return
 	}
 
@@ -1546,7 +1939,8 @@ func HandleUpdateHookBasic(c *gin.Context) {
 	stream.Global.Broadcast(wsMessage)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Hook基本信息更新成功",
+		"message": i18n.Message(c, i18n.CodeHookBasicUpdated),
+		"code":    i18n.CodeHookBasicUpdated,
 		"hookId":  hookID,
 	})
 }
@@ -1556,7 +1950,7 @@ func HandleUpdateHookParameters(c *gin.Context) {
 	hookID := c.Param("id")
 	existingHook := HookManager.MatchLoadedHook(hookID)
 	if existingHook == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Hook not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
 		return
 	}
 
@@ -1567,32 +1961,37 @@ func HandleUpdateHookParameters(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
 	// 验证参数配置
 	for i, arg := range request.PassArgumentsToCommand {
 		if arg.Name == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("参数%d的名称不能为空", i+1)})
+			i18n.JSONErrorf(c, http.StatusBadRequest, i18n.CodeParameterNameRequired, i+1)
 			return
 		}
 		if arg.Source == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("参数%d的来源不能为空", i+1)})
+			i18n.JSONErrorf(c, http.StatusBadRequest, i18n.CodeParameterSourceMissing, i+1)
 			return
 		}
 	}
 
 	for i, env := range request.PassEnvironmentToCommand {
 		if env.Name == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("环境变量%d的名称不能为空", i+1)})
+			i18n.JSONErrorf(c, http.StatusBadRequest, i18n.CodeEnvNameRequired, i+1)
 			return
 		}
 		if env.Source == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("环境变量%d的来源不能为空", i+1)})
+			i18n.JSONErrorf(c, http.StatusBadRequest, i18n.CodeEnvSourceMissing, i+1)
 			return
 		}
 	}
+	if err := existingHook.Starlark.CheckFunctions(starlarkFunctions(nil,
+		request.PassArgumentsToCommand, request.PassEnvironmentToCommand, request.ParseParametersAsJSON)); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidStarlark, err)
+		return
+	}
 
 	// 备份原值，以便保存失败时恢复和记录日志
 	originalPassArgumentsToCommand := existingHook.PassArgumentsToCommand
@@ -1651,7 +2050,7 @@ func HandleUpdateHookParameters(c *gin.Context) {
 		}
 		stream.Global.Broadcast(wsMessage)
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save hook changes: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
 		return
 	}
 
@@ -1703,7 +2102,8 @@ func HandleUpdateHookParameters(c *gin.Context) {
 	stream.Global.Broadcast(wsMessage)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Hook参数配置更新成功",
+		"message": i18n.Message(c, i18n.CodeHookParametersUpdated),
+		"code":    i18n.CodeHookParametersUpdated,
 		"hookId":  hookID,
 	})
 }
@@ -1713,7 +2113,7 @@ func HandleUpdateHookTriggers(c *gin.Context) {
 	hookID := c.Param("id")
 	existingHook := HookManager.MatchLoadedHook(hookID)
 	if existingHook == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Hook not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
 		return
 	}
 
@@ -1723,14 +2123,18 @@ func HandleUpdateHookTriggers(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
 	// 验证触发规则
 	if request.TriggerRuleMismatchHTTPResponseCode != 0 &&
 		(request.TriggerRuleMismatchHTTPResponseCode < 200 || request.TriggerRuleMismatchHTTPResponseCode > 599) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "HTTP响应码必须在200-599范围内"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidResponseCode, nil)
+		return
+	}
+	if err := existingHook.Starlark.CheckFunctions(starlarkFunctions(request.TriggerRule)); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidStarlark, err)
 		return
 	}
 
@@ -1789,7 +2193,7 @@ func HandleUpdateHookTriggers(c *gin.Context) {
 		}
 		stream.Global.Broadcast(wsMessage)
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save hook changes: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
 		return
 	}
 
@@ -1837,7 +2241,8 @@ func HandleUpdateHookTriggers(c *gin.Context) {
 	stream.Global.Broadcast(wsMessage)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Hook触发规则更新成功",
+		"message": i18n.Message(c, i18n.CodeHookTriggersUpdated),
+		"code":    i18n.CodeHookTriggersUpdated,
 		"hookId":  hookID,
 	})
 }
@@ -1847,29 +2252,62 @@ func HandleUpdateHookExecuteCommand(c *gin.Context) {
 	hookID := c.Param("id")
 	existingHook := HookManager.MatchLoadedHook(hookID)
 	if existingHook == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Hook not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
 		return
 	}
 
 	var request struct {
-		ExecuteCommand string `json:"execute-command" binding:"required"`
+		ExecuteCommand string  `json:"execute-command" binding:"required"`
+		Shell          *string `json:"shell"`
+		Sandbox        *string `json:"sandbox"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if request.Shell != nil && !ValidShell(*request.Shell) {
+		i18n.JSONErrorf(c, http.StatusBadRequest, i18n.CodeUnsupportedShell, *request.Shell)
+		return
+	}
+	if request.Sandbox != nil && !sandbox.Valid(*request.Sandbox) {
+		i18n.JSONErrorf(c, http.StatusBadRequest, i18n.CodeUnsupportedSandbox, *request.Sandbox)
+		return
+	}
+
+	shell := existingHook.Shell
+	if request.Shell != nil {
+		shell = *request.Shell
+	}
+	if !CommandAllowed(request.ExecuteCommand, existingHook.CommandWorkingDirectory, shell) {
+		denyScriptPath(c, hookID, request.ExecuteCommand, "update_execute_command")
+		return
+	}
+	if err := CheckCommandPolicy(request.ExecuteCommand, existingHook.CommandWorkingDirectory, shell); err != nil {
+		denyCommand(c, hookID, request.ExecuteCommand, "update_execute_command", err)
 		return
 	}
 
 	// 备份原值，以便保存失败时恢复
 	originalExecuteCommand := existingHook.ExecuteCommand
+	originalShell := existingHook.Shell
+	originalSandbox := existingHook.Sandbox
 
 	// 更新执行命令
 	existingHook.ExecuteCommand = request.ExecuteCommand
+	if request.Shell != nil {
+		existingHook.Shell = *request.Shell
+	}
+	if request.Sandbox != nil {
+		existingHook.Sandbox = *request.Sandbox
+	}
 
 	// 保存到配置文件
 	if err := HookManager.SaveHookChanges(hookID); err != nil {
 		// 保存失败，恢复原值
 		existingHook.ExecuteCommand = originalExecuteCommand
+		existingHook.Shell = originalShell
+		existingHook.Sandbox = originalSandbox
 
 		// 记录失败的日志
 		username, _ := c.Get("username")
@@ -1894,7 +2332,7 @@ func HandleUpdateHookExecuteCommand(c *gin.Context) {
 			},
 		)
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save hook changes: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
 		return
 	}
 
@@ -1920,12 +2358,21 @@ func HandleUpdateHookExecuteCommand(c *gin.Context) {
 					"old": originalExecuteCommand,
 					"new": request.ExecuteCommand,
 				},
+				"shell": map[string]interface{}{
+					"old": originalShell,
+					"new": existingHook.Shell,
+				},
+				"sandbox": map[string]interface{}{
+					"old": originalSandbox,
+					"new": existingHook.Sandbox,
+				},
 			},
 		},
 	)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Hook执行命令更新成功",
+		"message": i18n.Message(c, i18n.CodeHookCommandUpdated),
+		"code":    i18n.CodeHookCommandUpdated,
 		"hookId":  hookID,
 	})
 }
@@ -1934,14 +2381,14 @@ func HandleUpdateHookExecuteCommand(c *gin.Context) {
 func HandleDeleteHook(c *gin.Context) {
 	hookID := c.Param("id")
 	if hookID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Hook ID is required"})
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeHookIDRequired, nil)
 		return
 	}
 
 	// 查找Hook是否存在
 	existingHook := HookManager.MatchLoadedHook(hookID)
 	if existingHook == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Hook not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
 		return
 	}
 
@@ -1964,20 +2411,32 @@ func HandleDeleteHook(c *gin.Context) {
 	}
 
 	if targetFilePath == "" || hookIndex == -1 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Hook configuration not found"})
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+
+	// 删除前将Hook快照放入回收站，保留期内可恢复；existingHook指向切片元素，删除后会被覆盖，先复制
+	deletedHook := *existingHook
+	deletedBy, _ := c.Get("username")
+	deletedByStr, _ := deletedBy.(string)
+	trashItem, err := database.MoveToTrash(database.TrashKindHook, hookID, namespace.Normalize(deletedHook.Namespace), targetFilePath, deletedHook, deletedByStr)
+	if err != nil {
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeTrashFailed, err)
 		return
 	}
 
 	// 从内存中删除Hook
 	hooks := (*LoadedHooksFromFiles)[targetFilePath]
-	updatedHooks := append(hooks[:hookIndex], hooks[hookIndex+1:]...)
+	updatedHooks := append(append(Hooks{}, hooks[:hookIndex]...), hooks[hookIndex+1:]...) // keep hooks intact for the rollback
 	(*LoadedHooksFromFiles)[targetFilePath] = updatedHooks
 
 	// 保存配置文件
 	if err := HookManager.SaveHooksToFile(targetFilePath); err != nil {
 		// 保存失败，恢复Hook到内存中
-		hooks = append(hooks[:hookIndex], append([]Hook{*existingHook}, hooks[hookIndex:]...)...)
 		(*LoadedHooksFromFiles)[targetFilePath] = hooks
+		if trashItem != nil {
+			_, _ = database.PurgeTrashItems([]uint{trashItem.ID})
+		}
 
 		// 记录失败的日志
 		username, _ := c.Get("username")
@@ -2012,10 +2471,11 @@ func HandleDeleteHook(c *gin.Context) {
 				Success:  false,
 				Error:    "保存Hook配置失败: " + err.Error(),
 			},
+			Namespace: namespace.Normalize(deletedHook.Namespace),
 		}
 		stream.Global.Broadcast(wsMessage)
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save hook configuration: " + err.Error()})
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
 		return
 	}
 
@@ -2025,6 +2485,19 @@ func HandleDeleteHook(c *gin.Context) {
 	if username != nil {
 		usernameStr = username.(string)
 	}
+	details := map[string]interface{}{
+		"hookId":         hookID,
+		"executeCommand": deletedHook.ExecuteCommand,
+		"action":         "delete_hook",
+		"filePath":       targetFilePath,
+		"remainingHooks": len(updatedHooks),
+	}
+	var trashID uint
+	if trashItem != nil {
+		trashID = trashItem.ID
+		details["trashId"] = trashItem.ID
+		details["snapshot"] = database.TrashSnapshotPath(trashItem.ID)
+	}
 	database.LogHookManagement(
 		database.UserActionDeleteHook,
 		hookID,
@@ -2033,13 +2506,7 @@ func HandleDeleteHook(c *gin.Context) {
 		middleware.GetClientIP(c),
 		c.Request.UserAgent(),
 		true,
-		map[string]interface{}{
-			"hookId":         hookID,
-			"executeCommand": existingHook.ExecuteCommand,
-			"action":         "delete_hook",
-			"filePath":       targetFilePath,
-			"remainingHooks": len(updatedHooks),
-		},
+		details,
 	)
 
 	// 推送成功消息
@@ -2052,11 +2519,551 @@ func HandleDeleteHook(c *gin.Context) {
 			HookName: hookID,
 			Success:  true,
 		},
+		Namespace: namespace.Normalize(deletedHook.Namespace),
 	}
 	stream.Global.Broadcast(wsMessage)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Hook删除成功",
+		"message": i18n.Message(c, i18n.CodeHookDeleted),
+		"code":    i18n.CodeHookDeleted,
 		"hookId":  hookID,
+		"trashId": trashID,
+	})
+}
+
+// HandleUpdateHookForward set or clear the gateway target of a hook, a null forward turns it back into a command hook
+func HandleUpdateHookForward(c *gin.Context) {
+	hookID := c.Param("id")
+	existingHook := HookManager.MatchLoadedHook(hookID)
+	if existingHook == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+
+	var request struct {
+		Forward *ForwardConfig `json:"forward"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if request.Forward != nil {
+		if err := request.Forward.Validate(); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidForward, err)
+			return
+		}
+	} else if existingHook.ExecuteCommand == "" {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeForwardRequired, nil)
+		return
+	}
+
+	originalForward := existingHook.Forward
+	existingHook.Forward = request.Forward
+
+	username := c.GetString("username")
+	if username == "" {
+		username = "unknown"
+	}
+	if err := HookManager.SaveHookChanges(hookID); err != nil {
+		existingHook.Forward = originalForward
+		database.LogHookManagement(
+			database.UserActionUpdateHookForward,
+			hookID,
+			hookID,
+			username,
+			middleware.GetClientIP(c),
+			c.Request.UserAgent(),
+			false,
+			map[string]interface{}{
+				"hookId": hookID,
+				"error":  err.Error(),
+			},
+		)
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
+		return
+	}
+
+	database.LogHookManagement(
+		database.UserActionUpdateHookForward,
+		hookID,
+		hookID,
+		username,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		true,
+		map[string]interface{}{
+			"hookId": hookID,
+			"changes": map[string]interface{}{
+				"forward": map[string]interface{}{
+					"old": originalForward,
+					"new": request.Forward,
+				},
+			},
+		},
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeHookForwardUpdated),
+		"code":    i18n.CodeHookForwardUpdated,
+		"hook":    convertHookToResponse(existingHook),
+	})
+}
+
+// HandleUpdateHookIdempotency set or clear the idempotency settings of a hook, a null idempotency disables it
+func HandleUpdateHookIdempotency(c *gin.Context) {
+	hookID := c.Param("id")
+	existingHook := HookManager.MatchLoadedHook(hookID)
+	if existingHook == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+
+	var request struct {
+		Idempotency *IdempotencyConfig `json:"idempotency"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if request.Idempotency != nil {
+		if err := request.Idempotency.Validate(); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidIdempotency, err)
+			return
+		}
+	}
+
+	originalIdempotency := existingHook.Idempotency
+	existingHook.Idempotency = request.Idempotency
+
+	username := c.GetString("username")
+	if username == "" {
+		username = "unknown"
+	}
+	if err := HookManager.SaveHookChanges(hookID); err != nil {
+		existingHook.Idempotency = originalIdempotency
+		database.LogHookManagement(
+			database.UserActionUpdateHookIdempotency,
+			hookID,
+			hookID,
+			username,
+			middleware.GetClientIP(c),
+			c.Request.UserAgent(),
+			false,
+			map[string]interface{}{
+				"hookId": hookID,
+				"error":  err.Error(),
+			},
+		)
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
+		return
+	}
+
+	database.LogHookManagement(
+		database.UserActionUpdateHookIdempotency,
+		hookID,
+		hookID,
+		username,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		true,
+		map[string]interface{}{
+			"hookId": hookID,
+			"changes": map[string]interface{}{
+				"idempotency": map[string]interface{}{
+					"old": originalIdempotency,
+					"new": request.Idempotency,
+				},
+			},
+		},
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeHookIdempotencyUpdated),
+		"code":    i18n.CodeHookIdempotencyUpdated,
+		"hook":    convertHookToResponse(existingHook),
+	})
+}
+
+// HandleUpdateHookEnvironment set or clear the inherit-environment policy of a hook, null falls back to the global hook_env
+func HandleUpdateHookEnvironment(c *gin.Context) {
+	hookID := c.Param("id")
+	existingHook := HookManager.MatchLoadedHook(hookID)
+	if existingHook == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+
+	var request struct {
+		InheritEnvironment *types.EnvPolicy `json:"inheritEnvironment"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if request.InheritEnvironment != nil {
+		if err := request.InheritEnvironment.Validate(); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidEnvPolicy, err)
+			return
+		}
+	}
+
+	originalEnvironment := existingHook.InheritEnvironment
+	existingHook.InheritEnvironment = request.InheritEnvironment
+
+	username := c.GetString("username")
+	if username == "" {
+		username = "unknown"
+	}
+	if err := HookManager.SaveHookChanges(hookID); err != nil {
+		existingHook.InheritEnvironment = originalEnvironment
+		database.LogHookManagement(
+			database.UserActionUpdateHookEnvironment,
+			hookID,
+			hookID,
+			username,
+			middleware.GetClientIP(c),
+			c.Request.UserAgent(),
+			false,
+			map[string]interface{}{
+				"hookId": hookID,
+				"error":  err.Error(),
+			},
+		)
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
+		return
+	}
+
+	database.LogHookManagement(
+		database.UserActionUpdateHookEnvironment,
+		hookID,
+		hookID,
+		username,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		true,
+		map[string]interface{}{
+			"hookId": hookID,
+			"changes": map[string]interface{}{
+				"inheritEnvironment": map[string]interface{}{
+					"old": originalEnvironment,
+					"new": request.InheritEnvironment,
+				},
+			},
+		},
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeHookEnvPolicyUpdated),
+		"code":    i18n.CodeHookEnvPolicyUpdated,
+		"hook":    convertHookToResponse(existingHook),
+	})
+}
+
+// HandleUpdateHookArtifacts set or clear the files collected after each run of a hook
+func HandleUpdateHookArtifacts(c *gin.Context) {
+	hookID := c.Param("id")
+	existingHook := HookManager.MatchLoadedHook(hookID)
+	if existingHook == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+
+	var request struct {
+		Artifacts *ArtifactsConfig `json:"artifacts"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if request.Artifacts != nil {
+		if err := request.Artifacts.Validate(); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidArtifacts, err)
+			return
+		}
+	}
+
+	originalArtifacts := existingHook.Artifacts
+	existingHook.Artifacts = request.Artifacts
+
+	username := c.GetString("username")
+	if username == "" {
+		username = "unknown"
+	}
+	if err := HookManager.SaveHookChanges(hookID); err != nil {
+		existingHook.Artifacts = originalArtifacts
+		database.LogHookManagement(
+			database.UserActionUpdateHookArtifacts,
+			hookID,
+			hookID,
+			username,
+			middleware.GetClientIP(c),
+			c.Request.UserAgent(),
+			false,
+			map[string]interface{}{
+				"hookId": hookID,
+				"error":  err.Error(),
+			},
+		)
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
+		return
+	}
+
+	database.LogHookManagement(
+		database.UserActionUpdateHookArtifacts,
+		hookID,
+		hookID,
+		username,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		true,
+		map[string]interface{}{
+			"hookId": hookID,
+			"changes": map[string]interface{}{
+				"artifacts": map[string]interface{}{
+					"old": originalArtifacts,
+					"new": request.Artifacts,
+				},
+			},
+		},
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeHookArtifactsUpdated),
+		"code":    i18n.CodeHookArtifactsUpdated,
+		"hook":    convertHookToResponse(existingHook),
+	})
+}
+
+// HandleUpdateHookObjectEvents set or clear the S3 and MinIO event notification options of a hook
+func HandleUpdateHookObjectEvents(c *gin.Context) {
+	hookID := c.Param("id")
+	existingHook := HookManager.MatchLoadedHook(hookID)
+	if existingHook == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+
+	var request struct {
+		ObjectEvents *ObjectEventsConfig `json:"object-events"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if request.ObjectEvents != nil {
+		if err := request.ObjectEvents.Validate(); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidObjectEvents, err)
+			return
+		}
+	}
+
+	originalObjectEvents := existingHook.ObjectEvents
+	existingHook.ObjectEvents = request.ObjectEvents
+
+	username := c.GetString("username")
+	if username == "" {
+		username = "unknown"
+	}
+	if err := HookManager.SaveHookChanges(hookID); err != nil {
+		existingHook.ObjectEvents = originalObjectEvents
+		database.LogHookManagement(
+			database.UserActionUpdateHookObjectEvents,
+			hookID,
+			hookID,
+			username,
+			middleware.GetClientIP(c),
+			c.Request.UserAgent(),
+			false,
+			map[string]interface{}{
+				"hookId": hookID,
+				"error":  err.Error(),
+			},
+		)
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
+		return
+	}
+
+	database.LogHookManagement(
+		database.UserActionUpdateHookObjectEvents,
+		hookID,
+		hookID,
+		username,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		true,
+		map[string]interface{}{
+			"hookId": hookID,
+			"changes": map[string]interface{}{
+				"objectEvents": map[string]interface{}{
+					"old": originalObjectEvents,
+					"new": request.ObjectEvents,
+				},
+			},
+		},
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeHookObjectEventsUpdated),
+		"code":    i18n.CodeHookObjectEventsUpdated,
+		"hook":    convertHookToResponse(existingHook),
+	})
+}
+
+// HandleUpdateHookTransformPlugins set the transformer plugins rewriting the payload of a hook,
+// an empty list removes them
+func HandleUpdateHookTransformPlugins(c *gin.Context) {
+	hookID := c.Param("id")
+	existingHook := HookManager.MatchLoadedHook(hookID)
+	if existingHook == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+
+	var request struct {
+		TransformPlugins []string `json:"transform-plugins"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	for _, name := range request.TransformPlugins {
+		if strings.TrimSpace(name) == "" {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeEmptyPluginName, nil)
+			return
+		}
+	}
+	if len(request.TransformPlugins) == 0 {
+		request.TransformPlugins = nil
+	}
+
+	originalTransformPlugins := existingHook.TransformPlugins
+	existingHook.TransformPlugins = request.TransformPlugins
+
+	username := c.GetString("username")
+	if username == "" {
+		username = "unknown"
+	}
+	if err := HookManager.SaveHookChanges(hookID); err != nil {
+		existingHook.TransformPlugins = originalTransformPlugins
+		database.LogHookManagement(
+			database.UserActionUpdateHookTransformPlugins,
+			hookID,
+			hookID,
+			username,
+			middleware.GetClientIP(c),
+			c.Request.UserAgent(),
+			false,
+			map[string]interface{}{
+				"hookId": hookID,
+				"error":  err.Error(),
+			},
+		)
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
+		return
+	}
+
+	database.LogHookManagement(
+		database.UserActionUpdateHookTransformPlugins,
+		hookID,
+		hookID,
+		username,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		true,
+		map[string]interface{}{
+			"hookId": hookID,
+			"changes": map[string]interface{}{
+				"transformPlugins": map[string]interface{}{
+					"old": originalTransformPlugins,
+					"new": request.TransformPlugins,
+				},
+			},
+		},
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeHookPluginsUpdated),
+		"code":    i18n.CodeHookPluginsUpdated,
+		"hook":    convertHookToResponse(existingHook),
+	})
+}
+
+// HandleUpdateHookStarlark set or clear the Starlark program of a hook, the functions its
+// trigger rule and arguments call must stay defined
+func HandleUpdateHookStarlark(c *gin.Context) {
+	hookID := c.Param("id")
+	existingHook := HookManager.MatchLoadedHook(hookID)
+	if existingHook == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+
+	var request struct {
+		Starlark *StarlarkConfig `json:"starlark"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if request.Starlark != nil {
+		if err := request.Starlark.Validate(); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidStarlark, err)
+			return
+		}
+	}
+	if err := request.Starlark.CheckFunctions(existingHook.StarlarkFunctions()); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidStarlark, err)
+		return
+	}
+
+	originalStarlark := existingHook.Starlark
+	existingHook.Starlark = request.Starlark
+
+	username := c.GetString("username")
+	if username == "" {
+		username = "unknown"
+	}
+	if err := HookManager.SaveHookChanges(hookID); err != nil {
+		existingHook.Starlark = originalStarlark
+		database.LogHookManagement(
+			database.UserActionUpdateHookStarlark,
+			hookID,
+			hookID,
+			username,
+			middleware.GetClientIP(c),
+			c.Request.UserAgent(),
+			false,
+			map[string]interface{}{
+				"hookId": hookID,
+				"error":  err.Error(),
+			},
+		)
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
+		return
+	}
+
+	database.LogHookManagement(
+		database.UserActionUpdateHookStarlark,
+		hookID,
+		hookID,
+		username,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		true,
+		map[string]interface{}{
+			"hookId": hookID,
+			"changes": map[string]interface{}{
+				"starlark": map[string]interface{}{
+					"old": originalStarlark,
+					"new": request.Starlark,
+				},
+			},
+		},
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeHookStarlarkUpdated),
+		"code":    i18n.CodeHookStarlarkUpdated,
+		"hook":    convertHookToResponse(existingHook),
 	})
 }
@@ -0,0 +1,182 @@
+package webhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultIdempotencyTTL how long a response is reused when idempotency ttl is not set
+const defaultIdempotencyTTL = 10 * time.Minute
+
+// idempotencySweepInterval expired responses are dropped at most this often
+const idempotencySweepInterval = time.Minute
+
+// idempotencyHeaders headers holding a delivery id, tried in order when idempotency key is not set
+var idempotencyHeaders = []string{
+	"Idempotency-Key",
+	"X-GitHub-Delivery",
+	"X-Gitea-Delivery",
+	"X-Gogs-Delivery",
+	"X-Gitlab-Event-UUID",
+	"X-Request-UUID", // Bitbucket Cloud
+}
+
+// ErrIdempotencyMismatch the idempotency key was used before with a different body
+var ErrIdempotencyMismatch = errors.New("idempotency key reused with a different request body")
+
+// IdempotencyConfig answers repeated deliveries carrying the same key with the response
+// of the first one instead of running the command again
+type IdempotencyConfig struct {
+	TTL string    `json:"ttl,omitempty"` // how long the response is reused, default 10m
+	Key *Argument `json:"key,omitempty"` // default: Idempotency-Key header or the provider delivery id
+}
+
+// Validate check the idempotency ttl and key source
+func (i *IdempotencyConfig) Validate() error {
+	if i.TTL != "" {
+		if d, err := time.ParseDuration(i.TTL); err != nil || d <= 0 {
+			return fmt.Errorf("invalid idempotency ttl: %s", i.TTL)
+		}
+	}
+	if i.Key != nil && i.Key.Name == "" {
+		return fmt.Errorf("idempotency key name is required")
+	}
+	return nil
+}
+
+// IdempotencyKey key identifying the delivery r of hook h, empty when idempotency is
+// disabled or the request carries no key
+func (h *Hook) IdempotencyKey(r *Request) string {
+	if h.Idempotency == nil {
+		return ""
+	}
+
+	var key string
+	if h.Idempotency.Key != nil {
+		key, _ = h.Idempotency.Key.Get(r)
+	} else {
+		for _, name := range idempotencyHeaders {
+			arg := Argument{Source: SourceHeader, Name: name}
+			if key, _ = arg.Get(r); key != "" {
+				break
+			}
+		}
+	}
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(h.ID + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IdempotencyTTL how long the response of hook h is reused
+func (h *Hook) IdempotencyTTL() time.Duration {
+	if h.Idempotency == nil {
+		return defaultIdempotencyTTL
+	}
+	return parseDurationOr(h.Idempotency.TTL, defaultIdempotencyTTL)
+}
+
+// CachedResponse response replayed for repeated deliveries
+type CachedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+type idempotencyEntry struct {
+	fingerprint string
+	expires     time.Time
+	done        chan struct{}
+	response    *CachedResponse // nil while running or when the delivery was not cached
+}
+
+// IdempotencyCache responses of deliveries by idempotency key
+type IdempotencyCache struct {
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	lastSweep time.Time
+}
+
+// Idempotency responses of the hooks served by this instance
+var Idempotency = NewIdempotencyCache()
+
+// NewIdempotencyCache create an empty cache
+func NewIdempotencyCache() *IdempotencyCache {
+	return &IdempotencyCache{entries: map[string]*idempotencyEntry{}}
+}
+
+// Acquire look up key. When an earlier delivery with the key is cached its response is
+// returned; when it is still running Acquire waits for it. Otherwise the key is reserved
+// and complete must be called with the response to cache, or nil to release the key.
+func (ic *IdempotencyCache) Acquire(ctx context.Context, key string, body []byte, ttl time.Duration) (cached *CachedResponse, complete func(*CachedResponse), err error) {
+	sum := sha256.Sum256(body)
+	return ic.AcquireDigest(ctx, key, hex.EncodeToString(sum[:]), ttl)
+}
+
+// AcquireDigest Acquire with the hex encoded SHA-256 of the body, see Request.BodyDigest
+func (ic *IdempotencyCache) AcquireDigest(ctx context.Context, key, fingerprint string, ttl time.Duration) (cached *CachedResponse, complete func(*CachedResponse), err error) {
+
+	for {
+		now := time.Now()
+		ic.mu.Lock()
+		ic.sweep(now)
+		entry, ok := ic.entries[key]
+		if !ok || (entry.response != nil && now.After(entry.expires)) {
+			entry = &idempotencyEntry{fingerprint: fingerprint, done: make(chan struct{})}
+			ic.entries[key] = entry
+			ic.mu.Unlock()
+			return nil, ic.completer(key, entry, ttl), nil
+		}
+		ic.mu.Unlock()
+
+		if entry.fingerprint != fingerprint {
+			return nil, nil, ErrIdempotencyMismatch
+		}
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		if entry.response != nil {
+			return entry.response, nil, nil
+		}
+		// the earlier delivery was not cached, try to reserve the key again
+	}
+}
+
+func (ic *IdempotencyCache) completer(key string, entry *idempotencyEntry, ttl time.Duration) func(*CachedResponse) {
+	var once sync.Once
+	return func(resp *CachedResponse) {
+		once.Do(func() {
+			ic.mu.Lock()
+			if resp == nil {
+				delete(ic.entries, key)
+			} else {
+				entry.response = resp
+				entry.expires = time.Now().Add(ttl)
+			}
+			ic.mu.Unlock()
+			close(entry.done)
+		})
+	}
+}
+
+// sweep drop expired responses, the caller holds ic.mu
+func (ic *IdempotencyCache) sweep(now time.Time) {
+	if now.Sub(ic.lastSweep) < idempotencySweepInterval {
+		return
+	}
+	ic.lastSweep = now
+	for key, entry := range ic.entries {
+		if entry.response != nil && now.After(entry.expires) {
+			delete(ic.entries, key)
+		}
+	}
+}
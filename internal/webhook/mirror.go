@@ -0,0 +1,136 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/mycoool/gohook/internal/maintenance"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/pool"
+	"github.com/mycoool/gohook/internal/urls"
+)
+
+// MirrorHeader header added to the deliveries copied to a shadow hook, holds the id of the hook
+// the delivery was sent to
+const MirrorHeader = "X-GoHook-Mirror-Of"
+
+// validateMirror check the mirror option of the hook. shadow is the hook named by mirror when
+// it is known, deliveries are only mirrored within a namespace.
+func (h *Hook) validateMirror(shadow *Hook) error {
+	if h.Mirror == "" {
+		return nil
+	}
+	if h.Mirror == h.ID {
+		return fmt.Errorf("invalid mirror: hook %s cannot mirror to itself", h.ID)
+	}
+	if shadow != nil && !sameNamespace(h, shadow) {
+		return fmt.Errorf("invalid mirror: hook %s is in namespace %s, not %s", shadow.ID,
+			namespace.Normalize(shadow.Namespace), namespace.Normalize(h.Namespace))
+	}
+	return nil
+}
+
+// ValidateMirrors check the mirror option of each hook against its shadow hook, looked up in
+// hooks first and then among the loaded hooks
+func ValidateMirrors(hooks Hooks) error {
+	for i := range hooks {
+		if hooks[i].Mirror == "" {
+			continue
+		}
+		shadow := hooks.Match(hooks[i].Mirror)
+		if shadow == nil && HookManager != nil {
+			shadow = HookManager.MatchLoadedHook(hooks[i].Mirror)
+		}
+		if err := hooks[i].validateMirror(shadow); err != nil {
+			return fmt.Errorf("hook %s: %v", hooks[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// sameNamespace reports whether a and b belong to the same namespace
+func sameNamespace(a, b *Hook) bool {
+	return namespace.Normalize(a.Namespace) == namespace.Normalize(b.Namespace)
+}
+
+// MirrorDelivery copy a triggered delivery of h to its shadow hook, which runs in the
+// background. The shadow run is logged under the shadow hook; its trigger rules are not
+// evaluated and it never changes the response of the delivery.
+func MirrorDelivery(h *Hook, r *Request) {
+	if h.Mirror == "" {
+		return
+	}
+	shadow := HookManager.MatchLoadedHook(h.Mirror)
+	if shadow == nil || shadow.ID == h.ID {
+		log.Printf("[%s] %s: mirror hook %s not found\n", r.ID, h.ID, h.Mirror)
+		return
+	}
+	// a delivery never leaves its namespace
+	if err := h.validateMirror(shadow); err != nil {
+		log.Printf("[%s] %s: mirror skipped: %v\n", r.ID, h.ID, err)
+		return
+	}
+	if open, _ := CircuitOpen(shadow); open {
+		log.Printf("[%s] %s: mirror hook %s skipped, circuit breaker open\n", r.ID, h.ID, shadow.ID)
+		return
+	}
+	if decision := maintenance.Check(maintenance.KindHook, shadow.ID); decision.Paused {
+		log.Printf("[%s] %s: mirror hook %s skipped: %s\n", r.ID, h.ID, shadow.ID, decision.Reason)
+		return
+	}
+
+	mirrored, err := r.mirror(h, shadow)
+	if err != nil {
+		log.Printf("[%s] %s: error copying the delivery for mirror hook %s: %v\n", r.ID, h.ID, shadow.ID, err)
+		return
+	}
+	err = pool.Executions.Submit(func() {
+		if _, err := HandleHook(shadow, mirrored); err != nil {
+			log.Printf("[%s] mirror hook %s failed: %v\n", mirrored.ID, shadow.ID, err)
+		}
+	})
+	if err != nil {
+		log.Printf("[%s] %s: mirror hook %s skipped: %v\n", r.ID, h.ID, shadow.ID, err)
+	}
+}
+
+// mirror copy of the request for the shadow hook, independent of the delivery it was made
+// from: the body is held in memory and the payload is copied
+func (r *Request) mirror(h, shadow *Hook) (*Request, error) {
+	body, err := r.BodyBytes()
+	if err != nil {
+		return nil, err
+	}
+	m := &Request{
+		ID:          r.ID + "-mirror",
+		ContentType: r.ContentType,
+		Body:        body,
+		ClientIP:    r.ClientIP,
+		Starlark:    shadow.Starlark,
+	}
+	if m.Payload, err = decodeQueuedMap(maintenance.EncodeJSON(r.Payload)); err != nil {
+		return nil, err
+	}
+
+	// the raw request outlives the delivery, its headers mark the run as mirrored
+	raw, err := http.NewRequestWithContext(context.Background(), http.MethodPost, urls.Current().HookPath(shadow.ID), nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.RawRequest != nil {
+		raw.Method = r.RawRequest.Method
+		raw.RemoteAddr = r.RawRequest.RemoteAddr
+		raw.Header = r.RawRequest.Header.Clone()
+		raw.URL.RawQuery = r.RawRequest.URL.RawQuery
+	}
+	if raw.Header == nil {
+		raw.Header = http.Header{}
+	}
+	raw.Header.Set(MirrorHeader, h.ID)
+	m.RawRequest = raw
+	m.ParseHeaders(raw.Header)
+	m.ParseQuery(raw.URL.Query())
+	return m, nil
+}
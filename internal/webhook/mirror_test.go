@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRequestMirror(t *testing.T) {
+	raw, err := http.NewRequest(http.MethodPut, "/hooks/deploy?ref=main", strings.NewReader(`{"ref":"main"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.Header.Set("X-Event", "push")
+	r := &Request{
+		ID:          "42",
+		ContentType: "application/json",
+		Body:        []byte(`{"ref":"main"}`),
+		Payload:     map[string]interface{}{"repo": map[string]interface{}{"name": "app"}},
+		RawRequest:  raw,
+		ClientIP:    "10.0.0.1",
+	}
+	shadow := &Hook{ID: "deploy-next", Starlark: &StarlarkConfig{}}
+
+	m, err := r.mirror(&Hook{ID: "deploy"}, shadow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != "42-mirror" || m.ClientIP != "10.0.0.1" || string(m.Body) != `{"ref":"main"}` || m.Starlark != shadow.Starlark {
+		t.Errorf("mirror = %+v", m)
+	}
+	if m.RawRequest.Method != http.MethodPut || m.RawRequest.Header.Get(MirrorHeader) != "deploy" {
+		t.Errorf("mirrored raw request = %s %v", m.RawRequest.Method, m.RawRequest.Header)
+	}
+	if m.Headers["X-Event"] != "push" || m.Query["ref"] != "main" {
+		t.Errorf("mirrored headers %v, query %v", m.Headers, m.Query)
+	}
+	if raw.Header.Get(MirrorHeader) != "" {
+		t.Error("mirror changed the headers of the delivery")
+	}
+
+	// the shadow run cannot change the payload of the delivery
+	m.Payload["repo"].(map[string]interface{})["name"] = "changed"
+	if r.Payload["repo"].(map[string]interface{})["name"] != "app" {
+		t.Error("mirror shares the payload with the delivery")
+	}
+}
+
+func TestValidateMirror(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    Hook
+		shadow  *Hook
+		wantErr bool
+	}{
+		{"no mirror", Hook{ID: "deploy"}, nil, false},
+		{"other hook", Hook{ID: "deploy", Mirror: "deploy-next"}, nil, false},
+		{"itself", Hook{ID: "deploy", Mirror: "deploy"}, nil, true},
+		{"same namespace", Hook{ID: "deploy", Mirror: "deploy-next"}, &Hook{ID: "deploy-next", Namespace: "default"}, false},
+		{"other namespace", Hook{ID: "deploy", Mirror: "deploy-next"}, &Hook{ID: "deploy-next", Namespace: "team"}, true},
+		{"namespaced pair", Hook{ID: "deploy", Namespace: "team", Mirror: "deploy-next"}, &Hook{ID: "deploy-next", Namespace: "team"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.hook.validateMirror(tt.shadow); (err != nil) != tt.wantErr {
+				t.Errorf("validateMirror() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMirrors(t *testing.T) {
+	hooks := Hooks{
+		{ID: "deploy", Mirror: "deploy-next"},
+		{ID: "deploy-next"},
+		{ID: "team-deploy", Namespace: "team", Mirror: "deploy-next"},
+	}
+	err := ValidateMirrors(hooks)
+	if err == nil || !strings.Contains(err.Error(), "hook team-deploy: invalid mirror: hook deploy-next is in namespace default, not team") {
+		t.Errorf("ValidateMirrors() = %v", err)
+	}
+	if err := ValidateMirrors(hooks[:2]); err != nil {
+		t.Errorf("ValidateMirrors() = %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package webhook
+
+import "github.com/mycoool/gohook/internal/namespace"
+
+func init() {
+	namespace.Register(namespace.KindHook, namespace.Resource{
+		Lookup: func(id string) (string, bool) {
+			if HookManager == nil {
+				return "", false
+			}
+			if h := HookManager.MatchLoadedHook(id); h != nil {
+				return namespace.Normalize(h.Namespace), true
+			}
+			return "", false
+		},
+		Names: func(ns string) []string {
+			var ids []string
+			if HookManager == nil {
+				return ids
+			}
+			for _, h := range HookManager.GetAllHooks() {
+				if namespace.Normalize(h.Namespace) == ns {
+					ids = append(ids, h.ID)
+				}
+			}
+			return ids
+		},
+	})
+}
@@ -0,0 +1,366 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SNS message types, sent in the x-amz-sns-message-type header
+const (
+	snsNotification             = "Notification"
+	snsSubscriptionConfirmation = "SubscriptionConfirmation"
+	snsUnsubscribeConfirmation  = "UnsubscribeConfirmation"
+)
+
+// s3TestEvent sent by S3 when a notification configuration is saved
+const s3TestEvent = "s3:TestEvent"
+
+// maxSNSResponse signing certificates and confirmation answers are cut after this many bytes
+const maxSNSResponse = 64 << 10
+
+// snsHostPattern hosts SNS signing certificates and subscription URLs are accepted from
+var snsHostPattern = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+var snsClient = &http.Client{Timeout: 10 * time.Second}
+
+// snsGet fetch an SNS URL, replaced in tests
+var snsGet = func(rawURL string) ([]byte, error) {
+	resp, err := snsClient.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %s", rawURL, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSNSResponse))
+}
+
+// snsCerts signing certificates by URL
+var snsCerts sync.Map
+
+// ObjectEventsConfig accepts S3 event notifications delivered through Amazon SNS and MinIO
+// bucket webhooks. The objects of the event are exposed to argument extraction as the payload
+// fields object (the first one) and objects.
+type ObjectEventsConfig struct {
+	AuthToken     string   `json:"auth-token,omitempty"`     // MinIO auth_token, compared with the Authorization header
+	TopicARNs     []string `json:"topic-arns,omitempty"`     // SNS topics allowed to deliver, SNS is refused when empty
+	AllowUnsigned bool     `json:"allow-unsigned,omitempty"` // accept events without SNS signature or token, protect them with trigger rules
+	Buckets       []string `json:"buckets,omitempty"`        // only objects of these buckets
+	Events        []string `json:"events,omitempty"`         // event names like s3:ObjectCreated:*, a trailing * matches any suffix
+	Prefix        string   `json:"prefix,omitempty"`         // only keys starting with prefix
+	Suffix        string   `json:"suffix,omitempty"`         // only keys ending with suffix
+}
+
+// Validate check the event name patterns and topic ARNs
+func (o *ObjectEventsConfig) Validate() error {
+	for _, e := range o.Events {
+		if e == "" || strings.Contains(strings.TrimSuffix(e, "*"), "*") {
+			return fmt.Errorf("invalid object-events event %q, only a trailing * is supported", e)
+		}
+	}
+	for _, arn := range o.TopicARNs {
+		if !strings.HasPrefix(arn, "arn:") {
+			return fmt.Errorf("invalid object-events topic ARN %q", arn)
+		}
+	}
+	return nil
+}
+
+// snsMessage envelope of an SNS HTTP delivery
+type snsMessage struct {
+	Type             string
+	MessageId        string
+	Token            string
+	TopicArn         string
+	Subject          string
+	Message          string
+	Timestamp        string
+	SignatureVersion string
+	Signature        string
+	SigningCertURL   string
+	SubscribeURL     string
+}
+
+// s3Record record of an S3 or MinIO event notification
+type s3Record struct {
+	EventName string `json:"eventName"`
+	EventTime string `json:"eventTime"`
+	AWSRegion string `json:"awsRegion"`
+	S3        struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key         string `json:"key"`
+			Size        int64  `json:"size"`
+			ETag        string `json:"eTag"`
+			VersionID   string `json:"versionId"`
+			ContentType string `json:"contentType"` // MinIO only
+			Sequencer   string `json:"sequencer"`
+		} `json:"object"`
+	} `json:"s3"`
+}
+
+// ParseObjectEvent authenticate an object storage event and replace the payload of r with the
+// event plus its object and objects fields. A status other than 0 is answered without running
+// the hook: SNS subscription handshakes, S3 test events, rejected deliveries and events without
+// objects matching the filters.
+func (h *Hook) ParseObjectEvent(r *Request) (int, string) {
+	cfg := h.ObjectEvents
+	body, err := r.BodyBytes()
+	if err != nil {
+		return http.StatusInternalServerError, "Error occurred while reading the object event."
+	}
+
+	typeHeader := Argument{Source: SourceHeader, Name: "X-Amz-Sns-Message-Type"}
+	var event map[string]interface{}
+	var sns *snsMessage
+	if messageType, _ := typeHeader.Get(r); messageType != "" {
+		sns = &snsMessage{}
+		if err := json.Unmarshal(body, sns); err != nil {
+			return http.StatusBadRequest, "Invalid SNS message."
+		}
+		// only listed topics, any signed topic could otherwise subscribe the hook
+		if !slices.Contains(cfg.TopicARNs, sns.TopicArn) {
+			return http.StatusForbidden, fmt.Sprintf("SNS topic %s is not allowed, list it in topic-arns.", sns.TopicArn)
+		}
+		if err := verifySNSMessage(sns); err != nil {
+			return http.StatusForbidden, fmt.Sprintf("SNS signature verification failed: %v", err)
+		}
+		switch sns.Type {
+		case snsSubscriptionConfirmation:
+			if err := confirmSNSSubscription(sns.SubscribeURL); err != nil {
+				return http.StatusBadGateway, fmt.Sprintf("SNS subscription confirmation failed: %v", err)
+			}
+			return http.StatusOK, fmt.Sprintf("SNS subscription to %s confirmed.", sns.TopicArn)
+		case snsUnsubscribeConfirmation:
+			return http.StatusOK, fmt.Sprintf("SNS unsubscribe from %s acknowledged.", sns.TopicArn)
+		case snsNotification:
+			body = []byte(sns.Message)
+		default:
+			return http.StatusBadRequest, fmt.Sprintf("Unsupported SNS message type %s.", sns.Type)
+		}
+	} else if !cfg.AllowUnsigned {
+		if cfg.AuthToken == "" {
+			return http.StatusUnauthorized, "Object event is not signed by SNS."
+		}
+		authHeader := Argument{Source: SourceHeader, Name: "Authorization"}
+		auth, _ := authHeader.Get(r)
+		if subtle.ConstantTimeCompare([]byte(bearerToken(auth)), []byte(bearerToken(cfg.AuthToken))) != 1 {
+			return http.StatusUnauthorized, "Invalid object event token."
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&event); err != nil {
+		return http.StatusBadRequest, "Invalid object event."
+	}
+	if name, _ := event["Event"].(string); name == s3TestEvent {
+		return http.StatusOK, "S3 test event ignored."
+	}
+
+	var parsed struct {
+		Records []s3Record `json:"Records"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return http.StatusBadRequest, "Invalid object event records."
+	}
+	objects := make([]interface{}, 0, len(parsed.Records))
+	for _, rec := range parsed.Records {
+		if obj, ok := cfg.object(rec); ok {
+			objects = append(objects, obj)
+		}
+	}
+	if len(objects) == 0 {
+		return h.MismatchStatus(), "No objects matching the object-events filters."
+	}
+
+	event["object"] = objects[0]
+	event["objects"] = objects
+	if sns != nil {
+		event["sns"] = map[string]interface{}{
+			"messageId": sns.MessageId,
+			"topicArn":  sns.TopicArn,
+			"subject":   sns.Subject,
+			"timestamp": sns.Timestamp,
+		}
+	}
+	r.Payload = event
+	return 0, ""
+}
+
+// object normalized fields of rec, false when the filters exclude it
+func (o *ObjectEventsConfig) object(rec s3Record) (map[string]interface{}, bool) {
+	// S3 omits the s3: prefix MinIO sends
+	event := rec.EventName
+	if !strings.HasPrefix(event, "s3:") {
+		event = "s3:" + event
+	}
+	// keys are URL encoded with + for spaces
+	key, err := url.QueryUnescape(rec.S3.Object.Key)
+	if err != nil {
+		key = rec.S3.Object.Key
+	}
+	bucket := rec.S3.Bucket.Name
+
+	if len(o.Buckets) > 0 && !slices.Contains(o.Buckets, bucket) {
+		return nil, false
+	}
+	if len(o.Events) > 0 && !matchObjectEvent(o.Events, event) {
+		return nil, false
+	}
+	if !strings.HasPrefix(key, o.Prefix) || !strings.HasSuffix(key, o.Suffix) {
+		return nil, false
+	}
+	return map[string]interface{}{
+		"event":       event,
+		"bucket":      bucket,
+		"key":         key,
+		"size":        rec.S3.Object.Size,
+		"etag":        strings.Trim(rec.S3.Object.ETag, `"`),
+		"versionId":   rec.S3.Object.VersionID,
+		"contentType": rec.S3.Object.ContentType,
+		"sequencer":   rec.S3.Object.Sequencer,
+		"region":      rec.AWSRegion,
+		"time":        rec.EventTime,
+		"url":         "s3://" + bucket + "/" + key,
+	}, true
+}
+
+// matchObjectEvent report whether event matches one of patterns
+func matchObjectEvent(patterns []string, event string) bool {
+	for _, p := range patterns {
+		if p == event {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(event, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySNSMessage check the signature of m against the SNS signing certificate
+func verifySNSMessage(m *snsMessage) error {
+	var hash crypto.Hash
+	switch m.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return fmt.Errorf("unsupported signature version %q", m.SignatureVersion)
+	}
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return errors.New("invalid signature encoding")
+	}
+	cert, err := snsCertificate(m.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("signing certificate has no RSA key")
+	}
+
+	var digest []byte
+	if hash == crypto.SHA1 {
+		sum := sha1.Sum(snsStringToSign(m))
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256(snsStringToSign(m))
+		digest = sum[:]
+	}
+	return rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+}
+
+// snsStringToSign canonical form of the signed fields of m
+func snsStringToSign(m *snsMessage) []byte {
+	var fields [][2]string
+	if m.Type == snsNotification {
+		fields = [][2]string{{"Message", m.Message}, {"MessageId", m.MessageId}}
+		if m.Subject != "" {
+			fields = append(fields, [2]string{"Subject", m.Subject})
+		}
+		fields = append(fields, [2]string{"Timestamp", m.Timestamp}, [2]string{"TopicArn", m.TopicArn}, [2]string{"Type", m.Type})
+	} else {
+		fields = [][2]string{{"Message", m.Message}, {"MessageId", m.MessageId}, {"SubscribeURL", m.SubscribeURL},
+			{"Timestamp", m.Timestamp}, {"Token", m.Token}, {"TopicArn", m.TopicArn}, {"Type", m.Type}}
+	}
+	var b bytes.Buffer
+	for _, f := range fields {
+		b.WriteString(f[0] + "\n" + f[1] + "\n")
+	}
+	return b.Bytes()
+}
+
+// snsCertificate download and cache the signing certificate at rawURL
+func snsCertificate(rawURL string) (*x509.Certificate, error) {
+	if cert, ok := snsCerts.Load(rawURL); ok {
+		return cert.(*x509.Certificate), nil
+	}
+	if err := checkSNSURL(rawURL); err != nil {
+		return nil, err
+	}
+	data, err := snsGet(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetch signing certificate: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid signing certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing certificate: %v", err)
+	}
+	snsCerts.Store(rawURL, cert)
+	return cert, nil
+}
+
+// confirmSNSSubscription visit the SubscribeURL of a subscription confirmation
+func confirmSNSSubscription(rawURL string) error {
+	if err := checkSNSURL(rawURL); err != nil {
+		return err
+	}
+	_, err := snsGet(rawURL)
+	return err
+}
+
+// checkSNSURL only https URLs of SNS endpoints are fetched
+func checkSNSURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || !snsHostPattern.MatchString(u.Hostname()) {
+		return fmt.Errorf("%q is not an SNS URL", rawURL)
+	}
+	return nil
+}
+
+// bearerToken token of an Authorization header value, with or without the Bearer scheme
+func bearerToken(s string) string {
+	if len(s) > 7 && strings.EqualFold(s[:7], "bearer ") {
+		return strings.TrimSpace(s[7:])
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,206 @@
+package webhook
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const testCertURL = "https://sns.eu-west-1.amazonaws.com/SimpleNotificationService-test.pem"
+
+const testS3Event = `{"Records":[
+ {"eventName":"ObjectCreated:Put","eventTime":"2026-10-05T08:30:00.000Z","awsRegion":"eu-west-1",
+  "s3":{"bucket":{"name":"uploads"},"object":{"key":"photos/my+cat%21.jpg","size":2048,"eTag":"abc","sequencer":"01"}}},
+ {"eventName":"ObjectRemoved:Delete","awsRegion":"eu-west-1",
+  "s3":{"bucket":{"name":"uploads"},"object":{"key":"photos/old.jpg"}}}]}`
+
+const testMinIOEvent = `{"EventName":"s3:ObjectCreated:Put","Key":"backups/db.tar.gz","Records":[
+ {"eventName":"s3:ObjectCreated:Put","s3":{"bucket":{"name":"backups"},"object":{"key":"db.tar.gz","size":10,"contentType":"application/gzip"}}}]}`
+
+// testSNS signs SNS messages with a throwaway certificate served at testCertURL
+type testSNS struct {
+	key       *rsa.PrivateKey
+	confirmed []string
+}
+
+func newTestSNS(t *testing.T) *testSNS {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testSNS{key: key}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	snsCerts.Delete(testCertURL)
+	orig := snsGet
+	snsGet = func(rawURL string) ([]byte, error) {
+		if rawURL == testCertURL {
+			return certPEM, nil
+		}
+		s.confirmed = append(s.confirmed, rawURL)
+		return []byte("<ConfirmSubscriptionResponse/>"), nil
+	}
+	t.Cleanup(func() {
+		snsGet = orig
+		snsCerts.Delete(testCertURL)
+	})
+	return s
+}
+
+// message sign m with signature version 2
+func (s *testSNS) message(t *testing.T, m snsMessage) []byte {
+	t.Helper()
+	m.SignatureVersion, m.SigningCertURL = "2", testCertURL
+	sum := sha256.Sum256(snsStringToSign(&m))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Signature = base64.StdEncoding.EncodeToString(sig)
+	body, _ := json.Marshal(m)
+	return body
+}
+
+func TestParseObjectEvent(t *testing.T) {
+	sns := newTestSNS(t)
+	topic := "arn:aws:sns:eu-west-1:123456789012:uploads"
+	notification := snsMessage{Type: snsNotification, MessageId: "m1", TopicArn: topic, Message: testS3Event, Timestamp: "2026-10-05T08:30:01.000Z"}
+	var signed snsMessage
+	json.Unmarshal(sns.message(t, notification), &signed)
+	signed.Message = `{"Records":[]}`
+	tampered, _ := json.Marshal(signed)
+	snsHeaders := map[string]interface{}{"X-Amz-Sns-Message-Type": snsNotification}
+
+	tests := []struct {
+		name    string
+		config  ObjectEventsConfig
+		headers map[string]interface{}
+		body    []byte
+		status  int
+		key     string // key of the object field
+		objects int
+	}{
+		{"sns notification", ObjectEventsConfig{TopicARNs: []string{topic}}, snsHeaders, sns.message(t, notification), 0, "photos/my cat!.jpg", 2},
+		{"sns event filter", ObjectEventsConfig{TopicARNs: []string{topic}, Events: []string{"s3:ObjectRemoved:*"}}, snsHeaders, sns.message(t, notification), 0, "photos/old.jpg", 1},
+		{"sns tampered", ObjectEventsConfig{TopicARNs: []string{topic}}, snsHeaders, tampered, http.StatusForbidden, "", 0},
+		{"sns topic not allowed", ObjectEventsConfig{TopicARNs: []string{"arn:aws:sns:eu-west-1:123456789012:other"}}, snsHeaders,
+			sns.message(t, notification), http.StatusForbidden, "", 0},
+		{"sns without topics", ObjectEventsConfig{}, snsHeaders, sns.message(t, notification), http.StatusForbidden, "", 0},
+		{"sns subscription not allowed", ObjectEventsConfig{}, map[string]interface{}{"X-Amz-Sns-Message-Type": snsSubscriptionConfirmation},
+			sns.message(t, snsMessage{Type: snsSubscriptionConfirmation, MessageId: "m5", TopicArn: topic, Token: "tok",
+				SubscribeURL: "https://sns.eu-west-1.amazonaws.com/?Action=ConfirmSubscription&Token=tok"}), http.StatusForbidden, "", 0},
+		{"sns subscription", ObjectEventsConfig{TopicARNs: []string{topic}}, map[string]interface{}{"X-Amz-Sns-Message-Type": snsSubscriptionConfirmation},
+			sns.message(t, snsMessage{Type: snsSubscriptionConfirmation, MessageId: "m2", TopicArn: topic, Token: "tok",
+				SubscribeURL: "https://sns.eu-west-1.amazonaws.com/?Action=ConfirmSubscription&Token=tok"}), http.StatusOK, "", 0},
+		{"sns subscription elsewhere", ObjectEventsConfig{TopicARNs: []string{topic}}, map[string]interface{}{"X-Amz-Sns-Message-Type": snsSubscriptionConfirmation},
+			sns.message(t, snsMessage{Type: snsSubscriptionConfirmation, MessageId: "m3", TopicArn: topic, Token: "tok",
+				SubscribeURL: "https://attacker.example.com/confirm"}), http.StatusBadGateway, "", 0},
+		{"s3 test event", ObjectEventsConfig{TopicARNs: []string{topic}}, snsHeaders,
+			sns.message(t, snsMessage{Type: snsNotification, MessageId: "m4", TopicArn: topic, Message: `{"Event":"s3:TestEvent","Bucket":"uploads"}`}),
+			http.StatusOK, "", 0},
+		{"minio token", ObjectEventsConfig{AuthToken: "secret"}, map[string]interface{}{"Authorization": "Bearer secret"},
+			[]byte(testMinIOEvent), 0, "db.tar.gz", 1},
+		{"minio wrong token", ObjectEventsConfig{AuthToken: "secret"}, map[string]interface{}{"Authorization": "Bearer nope"},
+			[]byte(testMinIOEvent), http.StatusUnauthorized, "", 0},
+		{"unsigned rejected", ObjectEventsConfig{}, nil, []byte(testMinIOEvent), http.StatusUnauthorized, "", 0},
+		{"unsigned allowed", ObjectEventsConfig{AllowUnsigned: true, Buckets: []string{"backups"}, Suffix: ".gz"}, nil,
+			[]byte(testMinIOEvent), 0, "db.tar.gz", 1},
+		{"bucket filtered", ObjectEventsConfig{AllowUnsigned: true, Buckets: []string{"uploads"}}, nil, []byte(testMinIOEvent), http.StatusOK, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Hook{ID: "objects", ObjectEvents: &tt.config}
+			r := &Request{Headers: tt.headers, Body: tt.body}
+			status, msg := h.ParseObjectEvent(r)
+			if status != tt.status {
+				t.Fatalf("status = %d (%s), want %d", status, msg, tt.status)
+			}
+			if status != 0 {
+				return
+			}
+			key := Argument{Source: SourcePayload, Name: "object.key"}
+			if got, err := key.Get(r); err != nil || got != tt.key {
+				t.Errorf("object.key = %q, %v, want %q", got, err, tt.key)
+			}
+			if objects := r.Payload["objects"].([]interface{}); len(objects) != tt.objects {
+				t.Errorf("got %d objects, want %d", len(objects), tt.objects)
+			}
+		})
+	}
+	if len(sns.confirmed) != 1 {
+		t.Errorf("subscription confirmed %d times, want once: %v", len(sns.confirmed), sns.confirmed)
+	}
+}
+
+func TestObjectEventFields(t *testing.T) {
+	sns := newTestSNS(t)
+	r := &Request{
+		Headers: map[string]interface{}{"X-Amz-Sns-Message-Type": snsNotification},
+		Body:    sns.message(t, snsMessage{Type: snsNotification, MessageId: "m1", TopicArn: "arn:aws:sns:eu-west-1:1:uploads", Message: testS3Event}),
+	}
+	h := &Hook{ID: "objects", ObjectEvents: &ObjectEventsConfig{TopicARNs: []string{"arn:aws:sns:eu-west-1:1:uploads"}}}
+	if status, msg := h.ParseObjectEvent(r); status != 0 {
+		t.Fatalf("status %d: %s", status, msg)
+	}
+	want := map[string]string{
+		"object.event":  "s3:ObjectCreated:Put",
+		"object.bucket": "uploads",
+		"object.size":   "2048",
+		"object.url":    "s3://uploads/photos/my cat!.jpg",
+		"object.region": "eu-west-1",
+		"sns.messageId": "m1",
+		"objects.1.key": "photos/old.jpg",
+	}
+	for name, value := range want {
+		arg := Argument{Source: SourcePayload, Name: name}
+		if got, err := arg.Get(r); err != nil || got != value {
+			t.Errorf("%s = %q, %v, want %q", name, got, err, value)
+		}
+	}
+}
+
+func TestObjectEventsValidate(t *testing.T) {
+	tests := []struct {
+		config  ObjectEventsConfig
+		wantErr bool
+	}{
+		{ObjectEventsConfig{Events: []string{"s3:ObjectCreated:*", "s3:ObjectRemoved:Delete"}}, false},
+		{ObjectEventsConfig{Events: []string{"s3:*:Put"}}, true},
+		{ObjectEventsConfig{TopicARNs: []string{"uploads"}}, true},
+	}
+	for i, tt := range tests {
+		if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("case %d: err = %v, wantErr %v", i, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckSNSURL(t *testing.T) {
+	for rawURL, ok := range map[string]bool{
+		testCertURL: true,
+		"https://sns.cn-north-1.amazonaws.com.cn/cert.pem":      true,
+		"http://sns.eu-west-1.amazonaws.com/cert.pem":           false,
+		"https://sns.eu-west-1.amazonaws.com.evil.com/cert.pem": false,
+		"https://evil.com/sns.eu-west-1.amazonaws.com":          false,
+	} {
+		if err := checkSNSURL(rawURL); (err == nil) != ok {
+			t.Errorf("checkSNSURL(%q) = %v", rawURL, err)
+		}
+	}
+}
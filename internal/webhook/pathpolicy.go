@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// scriptRoots configured script root directories, nil when any path is allowed
+func scriptRoots() []string {
+	cfg := types.GoHookAppConfig
+	if cfg == nil || cfg.Scripts == nil {
+		return nil
+	}
+	return cfg.Scripts.Roots
+}
+
+// resolvePath absolute path of p with symlinks resolved, missing trailing elements are kept as is
+func resolvePath(p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return filepath.Clean(p)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	parent := filepath.Dir(abs)
+	if parent == abs {
+		return abs
+	}
+	return filepath.Join(resolvePath(parent), filepath.Base(abs))
+}
+
+// pathWithin reports whether path is root or lies below it
+func pathWithin(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// ScriptPathAllowed reports whether path lies inside one of the configured script roots,
+// symlinks are followed. Any path is allowed when no roots are configured.
+func ScriptPathAllowed(path string) bool {
+	roots := scriptRoots()
+	if len(roots) == 0 {
+		return true
+	}
+	if path == "" {
+		return false
+	}
+	resolved := resolvePath(path)
+	for _, root := range roots {
+		if root != "" && pathWithin(resolved, resolvePath(root)) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandPath file executed for execute-command run in workDir, like buildHookCommand
+// resolves it. Empty for inline shell commands and commands that can not be resolved.
+func commandPath(command, workDir, shell string) string {
+	if shell != "" && shell != ShellNone {
+		return ""
+	}
+	lookpath := command
+	if !filepath.IsAbs(command) && workDir != "" {
+		lookpath = filepath.Join(workDir, command)
+	}
+	if p, err := exec.LookPath(lookpath); err == nil {
+		return p
+	}
+	if strings.ContainsRune(lookpath, filepath.Separator) || strings.Contains(lookpath, "/") {
+		// the script does not exist yet
+		return lookpath
+	}
+	return ""
+}
+
+// CommandAllowed reports whether execute-command, run in workDir with shell, is inside the
+// configured script roots. An inline shell command can run anything, with roots configured
+// it is refused.
+func CommandAllowed(command, workDir, shell string) bool {
+	if len(scriptRoots()) == 0 {
+		return true
+	}
+	if shell != "" && shell != ShellNone {
+		return false
+	}
+	return ScriptPathAllowed(commandPath(command, workDir, shell))
+}
+
+// denyScriptPath audit a path rejected by the script roots and answer 403
+func denyScriptPath(c *gin.Context, hookID, path, action string) {
+	username := c.GetString("username")
+	if username == "" {
+		username = "unknown"
+	}
+	database.LogHookManagement(
+		database.UserActionScriptPathDenied,
+		hookID,
+		hookID,
+		username,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		false,
+		map[string]interface{}{
+			"hookId": hookID,
+			"path":   path,
+			"action": action,
+			"roots":  scriptRoots(),
+		},
+	)
+	i18n.JSONErrorf(c, http.StatusForbidden, i18n.CodeScriptPathDenied, path)
+}
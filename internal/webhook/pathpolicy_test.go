@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestScriptPathAllowed(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "scripts")
+	outside := filepath.Join(base, "etc")
+	for _, dir := range []string{root, outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "deploy.sh"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// a symlink inside the root pointing outside of it
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	saved := types.GoHookAppConfig
+	defer func() { types.GoHookAppConfig = saved }()
+
+	types.GoHookAppConfig = &types.AppConfig{}
+	if !ScriptPathAllowed(filepath.Join(outside, "passwd")) {
+		t.Errorf("any path must be allowed without script roots")
+	}
+	if !CommandAllowed("echo $1", "", ShellBash) {
+		t.Errorf("inline commands must be allowed without script roots")
+	}
+
+	types.GoHookAppConfig = &types.AppConfig{Scripts: &types.ScriptsConfig{Roots: []string{root}}}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join(root, "deploy.sh"), true},
+		{filepath.Join(root, "new", "build.sh"), true},
+		{filepath.Join(root, "..", "etc", "passwd"), false},
+		{filepath.Join(outside, "passwd"), false},
+		{filepath.Join(root, "escape", "passwd"), false},
+		{root + "-other/deploy.sh", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := ScriptPathAllowed(tt.path); got != tt.want {
+			t.Errorf("ScriptPathAllowed(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	commands := []struct {
+		command string
+		workDir string
+		shell   string
+		want    bool
+	}{
+		{filepath.Join(root, "deploy.sh"), "", "", true},
+		{"deploy.sh", root, "", true},
+		{"./missing.sh", root, "", true},
+		{"../etc/run.sh", root, "", false},
+		{"sh", "", "", false},
+		{"echo $1", "", ShellBash, false},
+		{filepath.Join(root, "deploy.sh"), "", ShellSh, false},
+		{"deploy.sh", root, ShellNone, true},
+	}
+	for _, tt := range commands {
+		if got := CommandAllowed(tt.command, tt.workDir, tt.shell); got != tt.want {
+			t.Errorf("CommandAllowed(%q, %q, %q) = %v, want %v", tt.command, tt.workDir, tt.shell, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/maintenance"
+	"github.com/mycoool/gohook/internal/types"
+	"github.com/mycoool/gohook/internal/urls"
+)
+
+func init() {
+	maintenance.Register(maintenance.KindHook, maintenance.Handler{
+		Windows: func(target string) []types.PauseWindow {
+			if h := HookManager.MatchLoadedHook(target); h != nil {
+				return h.PauseWindows
+			}
+			return nil
+		},
+		Replay: replayQueuedHook,
+		Hold:   holdOverQuota,
+	})
+}
+
+// QueueHookDelivery store a matched delivery so it runs once the hook is no longer paused
+func QueueHookDelivery(h *Hook, r *Request, reason string) error {
+	// the queued delivery outlives the temp file of a spooled body
+	body, err := r.BodyBytes()
+	if err != nil {
+		return err
+	}
+	d := &database.QueuedDelivery{
+		Kind:      maintenance.KindHook,
+		Target:    h.ID,
+		RequestID: r.ID,
+		Headers:   maintenance.EncodeJSON(r.Headers),
+		Query:     maintenance.EncodeJSON(r.Query),
+		Payload:   maintenance.EncodeJSON(r.Payload),
+		Body:      string(body),
+		Reason:    reason,
+	}
+	if r.RawRequest != nil {
+		d.Method = r.RawRequest.Method
+		d.RemoteAddr = r.RawRequest.RemoteAddr
+	}
+	if r.ClientIP != "" {
+		d.RemoteAddr = r.ClientIP
+	}
+	return maintenance.Enqueue(d)
+}
+
+// decodeQueuedMap restore a map stored by maintenance.EncodeJSON
+func decodeQueuedMap(data string) (map[string]interface{}, error) {
+	if data == "" || data == "null" {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	var m map[string]interface{}
+	if err := decoder.Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// replayQueuedHook execute a queued delivery with the parsed request it was received with
+func replayQueuedHook(d *database.QueuedDelivery) error {
+	h := HookManager.MatchLoadedHook(d.Target)
+	if h == nil {
+		return fmt.Errorf("hook %s not found", d.Target)
+	}
+
+	r := &Request{
+		ID:       d.RequestID,
+		Body:     []byte(d.Body),
+		ClientIP: d.RemoteAddr,
+		Starlark: h.Starlark,
+	}
+	var err error
+	if r.Headers, err = decodeQueuedMap(d.Headers); err != nil {
+		return fmt.Errorf("decode headers: %w", err)
+	}
+	if r.Query, err = decodeQueuedMap(d.Query); err != nil {
+		return fmt.Errorf("decode query: %w", err)
+	}
+	if r.Payload, err = decodeQueuedMap(d.Payload); err != nil {
+		return fmt.Errorf("decode payload: %w", err)
+	}
+
+	// keep method and client address for the execution log
+	method := d.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	if raw, err := http.NewRequest(method, urls.Current().HookPath(h.ID), bytes.NewReader(r.Body)); err == nil {
+		raw.RemoteAddr = d.RemoteAddr
+		r.RawRequest = raw
+	}
+
+	_, err = HandleHook(h, r)
+	return err
+}
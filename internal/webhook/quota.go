@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/maintenance"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/quota"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// quotaSubject quotas an execution of h by username counts against, username is empty for deliveries
+func quotaSubject(h *Hook, username string) quota.Subject {
+	return quota.Subject{Hook: h.ID, HookQuota: h.Quota, Namespace: h.Namespace, User: username}
+}
+
+// holdOverQuota maintenance decision of a delivery to the hook target
+func holdOverQuota(target string) maintenance.Decision {
+	h := HookManager.MatchLoadedHook(target)
+	if h == nil {
+		return maintenance.Decision{}
+	}
+	return quota.Hold(quotaSubject(h, ""))
+}
+
+// HookQuotas usage of the quotas of the hook and of its namespace
+func HookQuotas(h *Hook) []*quota.Usage {
+	usages := []*quota.Usage{}
+	if h.Quota != nil {
+		usages = append(usages, quota.Measure(quota.KindHook, h.ID, h.Quota))
+	}
+	if q := quota.NamespaceQuota(h.Namespace); q != nil {
+		usages = append(usages, quota.Measure(quota.KindNamespace, namespace.Normalize(h.Namespace), q))
+	}
+	return usages
+}
+
+// HandleGetHookQuota get the quotas a hook counts against with their usage
+func HandleGetHookQuota(c *gin.Context) {
+	h := HookManager.MatchLoadedHook(c.Param("id"))
+	if h == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"quota": h.Quota, "usage": HookQuotas(h)})
+}
+
+// HandleUpdateHookQuota set or clear the quota of a hook, a null quota removes it
+func HandleUpdateHookQuota(c *gin.Context) {
+	hookID := c.Param("id")
+	existingHook := HookManager.MatchLoadedHook(hookID)
+	if existingHook == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+
+	var request struct {
+		Quota *types.QuotaConfig `json:"quota"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if err := quota.Validate(request.Quota); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	originalQuota := existingHook.Quota
+	existingHook.Quota = request.Quota
+
+	username := c.GetString("username")
+	if username == "" {
+		username = "unknown"
+	}
+	if err := HookManager.SaveHookChanges(hookID); err != nil {
+		existingHook.Quota = originalQuota
+		database.LogHookManagement(
+			database.UserActionUpdateHookQuota,
+			hookID,
+			hookID,
+			username,
+			middleware.GetClientIP(c),
+			c.Request.UserAgent(),
+			false,
+			map[string]interface{}{
+				"hookId": hookID,
+				"error":  err.Error(),
+			},
+		)
+		i18n.JSONError(c, http.StatusInternalServerError, i18n.CodeSaveHookFailed, err)
+		return
+	}
+
+	database.LogHookManagement(
+		database.UserActionUpdateHookQuota,
+		hookID,
+		hookID,
+		username,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		true,
+		map[string]interface{}{
+			"hookId": hookID,
+			"changes": map[string]interface{}{
+				"quota": map[string]interface{}{
+					"old": originalQuota,
+					"new": request.Quota,
+				},
+			},
+		},
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeHookQuotaUpdated),
+		"code":    i18n.CodeHookQuotaUpdated,
+		"hook":    convertHookToResponse(existingHook),
+	})
+}
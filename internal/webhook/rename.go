@@ -0,0 +1,268 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/stream"
+)
+
+var (
+	// ErrHookIDInUse the id is taken by a hook or an alias of a renamed hook
+	ErrHookIDInUse = errors.New("hook id already in use")
+	// ErrInvalidHookID the id cannot be used in hook URLs
+	ErrInvalidHookID = errors.New("invalid hook id")
+)
+
+// MatchAlias return the hook that was renamed from id
+func (h *Hooks) MatchAlias(id string) *Hook {
+	for i := range *h {
+		for _, alias := range (*h)[i].Aliases {
+			if alias == id {
+				return &(*h)[i]
+			}
+		}
+	}
+	return nil
+}
+
+// ResolveHook return the hook with the given id, one of its previous ids or one of its
+// endpoints. alias is the id that was resolved through an alias or endpoint, empty otherwise.
+func (hm *hookManager) ResolveHook(id string) (h *Hook, alias string) {
+	if h := hm.MatchLoadedHook(id); h != nil {
+		return h, ""
+	}
+	if hm.LoadedHooksFromFiles == nil {
+		return nil, ""
+	}
+	for _, hooks := range *hm.LoadedHooksFromFiles {
+		if h := hooks.MatchAlias(id); h != nil {
+			return h, id
+		}
+		if h, _ := hooks.MatchEndpoint(id); h != nil {
+			return h, id
+		}
+	}
+	return nil, ""
+}
+
+// IDInUse report whether id is the id or an alias of a loaded hook
+func (hm *hookManager) IDInUse(id string) bool {
+	h, _ := hm.ResolveHook(id)
+	return h != nil
+}
+
+// RenameHook change the id of a hook and save its hooks file. With keepAlias the old id
+// keeps resolving to the hook so deliveries configured with the old URL still arrive.
+// Logs and queued deliveries of the hook are moved to the new id.
+func RenameHook(oldID, newID string, keepAlias bool) error {
+	if HookManager == nil {
+		return fmt.Errorf("no hooks loaded")
+	}
+	newID = strings.TrimSpace(newID)
+	if newID == "" || strings.ContainsAny(newID, " \t\r\n") {
+		return fmt.Errorf("%w %q", ErrInvalidHookID, newID)
+	}
+	h := HookManager.MatchLoadedHook(oldID)
+	if h == nil {
+		return fmt.Errorf("hook %s not found", oldID)
+	}
+	if newID == oldID {
+		return nil
+	}
+	// renaming back to a previous id takes that alias over
+	if owner, _ := HookManager.ResolveHook(newID); owner != nil && owner != h || h.Endpoint(newID) != nil {
+		return fmt.Errorf("%w: %s", ErrHookIDInUse, newID)
+	}
+	filePath := HookManager.FindHookFile(oldID)
+
+	oldAliases := h.Aliases
+	aliases := make([]string, 0, len(h.Aliases)+1)
+	for _, alias := range h.Aliases {
+		if alias != newID && alias != oldID {
+			aliases = append(aliases, alias)
+		}
+	}
+	if keepAlias {
+		aliases = append(aliases, oldID)
+	}
+	if len(aliases) == 0 {
+		aliases = nil
+	}
+	h.ID, h.Aliases = newID, aliases
+
+	if err := HookManager.SaveHooksToFile(filePath); err != nil {
+		h.ID, h.Aliases = oldID, oldAliases
+		return err
+	}
+	if err := database.RenameHookReferences(oldID, newID); err != nil {
+		return fmt.Errorf("hook renamed, but its logs still use the old id: %v", err)
+	}
+	return nil
+}
+
+// SetHookAliases replace the aliases of a hook, custom slugs or previous ids the hook is
+// also served under, and save its hooks file
+func SetHookAliases(id string, aliases []string) error {
+	if HookManager == nil {
+		return fmt.Errorf("no hooks loaded")
+	}
+	h := HookManager.MatchLoadedHook(id)
+	if h == nil {
+		return fmt.Errorf("hook %s not found", id)
+	}
+	var cleaned []string
+	seen := map[string]bool{}
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		if alias == "" || strings.ContainsAny(alias, " \t\r\n") {
+			return fmt.Errorf("%w %q", ErrInvalidHookID, alias)
+		}
+		if alias == id || seen[alias] {
+			continue
+		}
+		if owner, _ := HookManager.ResolveHook(alias); owner != nil && owner != h || h.Endpoint(alias) != nil {
+			return fmt.Errorf("%w: %s", ErrHookIDInUse, alias)
+		}
+		seen[alias] = true
+		cleaned = append(cleaned, alias)
+	}
+
+	oldAliases := h.Aliases
+	h.Aliases = cleaned
+	if err := HookManager.SaveHookChanges(id); err != nil {
+		h.Aliases = oldAliases
+		return err
+	}
+	return nil
+}
+
+// HandleUpdateHookAliases replace the aliases of a hook, {"aliases": ["slug"]}
+func HandleUpdateHookAliases(c *gin.Context) {
+	hookID := c.Param("id")
+	existingHook := HookManager.MatchLoadedHook(hookID)
+	if existingHook == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+	var request struct {
+		Aliases []string `json:"aliases"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	originalAliases := existingHook.Aliases
+	err := SetHookAliases(hookID, request.Aliases)
+	details := map[string]interface{}{"hookId": hookID}
+	if err != nil {
+		details["error"] = err.Error()
+	} else {
+		details["changes"] = map[string]interface{}{
+			"aliases": map[string]interface{}{"old": originalAliases, "new": existingHook.Aliases},
+		}
+	}
+	database.LogHookManagement(
+		database.UserActionUpdateHookAliases,
+		hookID,
+		hookID,
+		c.GetString("username"),
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		err == nil,
+		details,
+	)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrHookIDInUse) {
+			status = http.StatusConflict
+		} else if errors.Is(err, ErrInvalidHookID) {
+			status = http.StatusBadRequest
+		}
+		i18n.JSONError(c, status, i18n.CodeUpdateAliasesFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeHookAliasesUpdated),
+		"code":    i18n.CodeHookAliasesUpdated,
+		"hook":    convertHookToResponse(existingHook),
+	})
+}
+
+// HandleRenameHook rename a hook, {"id": "new", "keepAlias": true}
+func HandleRenameHook(c *gin.Context) {
+	hookID := c.Param("id")
+	var request struct {
+		ID        string `json:"id" binding:"required"`
+		KeepAlias *bool  `json:"keepAlias"` // default true
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	keepAlias := request.KeepAlias == nil || *request.KeepAlias
+	request.ID = strings.TrimSpace(request.ID)
+
+	if HookManager.MatchLoadedHook(hookID) == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+
+	err := RenameHook(hookID, request.ID, keepAlias)
+	details := map[string]interface{}{
+		"action":    "rename_hook",
+		"oldId":     hookID,
+		"newId":     request.ID,
+		"keepAlias": keepAlias,
+	}
+	resourceID := request.ID
+	if err != nil {
+		details["error"] = err.Error()
+		resourceID = hookID
+	}
+	username, _ := c.Get("username")
+	usernameStr, _ := username.(string)
+	database.LogHookManagement(
+		database.UserActionRenameHook,
+		resourceID,
+		hookID+" -> "+request.ID,
+		usernameStr,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		err == nil,
+		details,
+	)
+
+	msg := stream.HookManageMessage{Action: "rename", HookID: resourceID, HookName: resourceID, Success: err == nil}
+	if err != nil {
+		msg.Error = err.Error()
+	}
+	stream.Global.Broadcast(stream.WsMessage{Type: "hook_managed", Timestamp: time.Now(), Data: msg})
+
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrHookIDInUse) {
+			status = http.StatusConflict
+		} else if errors.Is(err, ErrInvalidHookID) {
+			status = http.StatusBadRequest
+		}
+		i18n.JSONError(c, status, i18n.CodeRenameHookFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Message(c, i18n.CodeHookRenamed),
+		"code":    i18n.CodeHookRenamed,
+		"hookId":  request.ID,
+		"oldId":   hookID,
+	})
+}
@@ -0,0 +1,98 @@
+package webhook
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRenameHook(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hooks.json")
+	loaded := map[string]Hooks{
+		file: {{ID: "deploy", ExecuteCommand: "/bin/true"}, {ID: "backup", ExecuteCommand: "/bin/true"}},
+	}
+	saved := HookManager
+	HookManager = NewHookManager(&loaded, []string{file}, false)
+	defer func() { HookManager = saved }()
+
+	if err := RenameHook("deploy", "deploy-site", true); err != nil {
+		t.Fatal(err)
+	}
+	h, alias := HookManager.ResolveHook("deploy")
+	if h == nil || h.ID != "deploy-site" || alias != "deploy" {
+		t.Fatalf("ResolveHook(old id) = %+v, %q", h, alias)
+	}
+	if h, alias := HookManager.ResolveHook("deploy-site"); h == nil || alias != "" {
+		t.Fatalf("ResolveHook(new id) = %+v, %q", h, alias)
+	}
+
+	tests := []struct {
+		name    string
+		oldID   string
+		newID   string
+		wantErr error
+	}{
+		{"id of another hook", "backup", "deploy-site", ErrHookIDInUse},
+		{"alias of another hook", "backup", "deploy", ErrHookIDInUse},
+		{"blank id", "backup", "  ", ErrInvalidHookID},
+		{"id with spaces", "backup", "my backup", ErrInvalidHookID},
+	}
+	for _, tt := range tests {
+		if err := RenameHook(tt.oldID, tt.newID, true); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: RenameHook() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// renaming back takes the alias over
+	if err := RenameHook("deploy-site", "deploy", true); err != nil {
+		t.Fatal(err)
+	}
+	h = HookManager.MatchLoadedHook("deploy")
+	if h == nil || !reflect.DeepEqual(h.Aliases, []string{"deploy-site"}) {
+		t.Fatalf("after renaming back: %+v", h)
+	}
+	if err := RenameHook("deploy", "site", false); err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := HookManager.ResolveHook("deploy"); h != nil {
+		t.Errorf("old id resolves without keepAlias: %+v", h)
+	}
+	if HookManager.IDInUse("deploy-site") != true {
+		t.Error("earlier aliases must be kept")
+	}
+}
+
+func TestSetHookAliases(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hooks.json")
+	loaded := map[string]Hooks{
+		file: {{ID: "deploy", ExecuteCommand: "/bin/true"}, {ID: "backup", Aliases: []string{"nightly"}, ExecuteCommand: "/bin/true"}},
+	}
+	saved := HookManager
+	HookManager = NewHookManager(&loaded, []string{file}, false)
+	defer func() { HookManager = saved }()
+
+	tests := []struct {
+		name    string
+		aliases []string
+		want    []string
+		wantErr error
+	}{
+		{"slugs", []string{" site ", "releases/site", "site", "deploy"}, []string{"site", "releases/site"}, nil},
+		{"clear", nil, nil, nil},
+		{"id of another hook", []string{"backup"}, nil, ErrHookIDInUse},
+		{"alias of another hook", []string{"nightly"}, nil, ErrHookIDInUse},
+		{"blank", []string{""}, nil, ErrInvalidHookID},
+		{"with spaces", []string{"my site"}, nil, ErrInvalidHookID},
+	}
+	for _, tt := range tests {
+		err := SetHookAliases("deploy", tt.aliases)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: SetHookAliases() error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(HookManager.MatchLoadedHook("deploy").Aliases, tt.want) {
+			t.Errorf("%s: aliases = %q, want %q", tt.name, HookManager.MatchLoadedHook("deploy").Aliases, tt.want)
+		}
+	}
+}
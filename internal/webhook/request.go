@@ -1,7 +1,7 @@
 package webhook
 
 import (
-	"bytes"
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -9,6 +9,8 @@ import (
 	"unicode"
 
 	"github.com/clbanning/mxj/v2"
+	"github.com/mycoool/gohook/internal/redact"
+	"go.starlark.net/starlark"
 )
 
 // Request represents a webhook request.
@@ -16,12 +18,22 @@ type Request struct {
 	// The request ID set by the RequestID middleware.
 	ID string
 
+	// Alias is the previous hook id or the endpoint the request addressed, empty when it used the current id.
+	Alias string
+
 	// The Content-Type of the request.
 	ContentType string
 
-	// The raw request body.
+	// The raw request body, nil when it is spooled to BodyFile.
 	Body []byte
 
+	// BodyFile is the temp file holding a body larger than SpoolThreshold, see ReadBody.
+	BodyFile string
+
+	// size and SHA-256 of a spooled body, computed while it was written
+	bodySize   int64
+	bodyDigest string
+
 	// Headers is a map of the parsed headers.
 	Headers map[string]interface{}
 
@@ -37,23 +49,63 @@ type Request struct {
 	// Treat signature errors as simple validate failures.
 	AllowSignatureErrors bool
 
+	// PreviousSecret is accepted by signature rules besides their secret, set during the grace
+	// period of a secret rotation.
+	PreviousSecret string
+
+	// PreviousSecretUsed is set when a signature only matched the previous secret.
+	PreviousSecretUsed bool
+
 	// ClientIP is the real client IP address obtained through proxy-aware detection.
 	ClientIP string
+
+	// ExtraArgs are appended after the extracted command arguments (manual triggers only).
+	ExtraArgs []string
+
+	// ExtraEnv holds KEY=value overrides applied last to the command environment (manual triggers only).
+	ExtraEnv []string
+
+	// Starlark is the program of the hook called by starlark rules and arguments.
+	Starlark *StarlarkConfig
+
+	// the request as passed to Starlark functions, see starlarkValue
+	starlark starlark.Value
+
+	// values of the secret environment variables of the hook command, kept out of its
+	// logged and broadcast output
+	secrets []string
+}
+
+// redacted s without the secrets of the request, with the redaction rules of app.yaml
+func (r *Request) redacted(s string) string {
+	return redact.String(s, r.secrets...)
 }
 
 func (r *Request) ParseJSONPayload() error {
-	decoder := json.NewDecoder(bytes.NewReader(r.Body))
-	decoder.UseNumber()
+	body, err := r.OpenBody()
+	if err != nil {
+		return fmt.Errorf("error reading JSON payload %+v", err)
+	}
+	defer body.Close()
 
+	reader := bufio.NewReader(body)
 	var firstChar byte
-	for i := 0; i < len(r.Body); i++ {
-		if unicode.IsSpace(rune(r.Body[i])) {
+	for {
+		c, err := reader.ReadByte()
+		if err != nil {
+			break
+		}
+		if unicode.IsSpace(rune(c)) {
 			continue
 		}
-		firstChar = r.Body[i]
+		firstChar = c
+		reader.UnreadByte()
 		break
 	}
 
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
 	if firstChar == byte('[') {
 		var arrayPayload interface{}
 		err := decoder.Decode(&arrayPayload)
@@ -94,7 +146,12 @@ func (r *Request) ParseQuery(query map[string][]string) {
 }
 
 func (r *Request) ParseFormPayload() error {
-	fd, err := url.ParseQuery(string(r.Body))
+	body, err := r.BodyBytes()
+	if err != nil {
+		return fmt.Errorf("error reading form payload %+v", err)
+	}
+
+	fd, err := url.ParseQuery(string(body))
 	if err != nil {
 		return fmt.Errorf("error parsing form payload %+v", err)
 	}
@@ -111,9 +168,13 @@ func (r *Request) ParseFormPayload() error {
 }
 
 func (r *Request) ParseXMLPayload() error {
-	var err error
+	body, err := r.OpenBody()
+	if err != nil {
+		return fmt.Errorf("error reading XML payload: %+v", err)
+	}
+	defer body.Close()
 
-	r.Payload, err = mxj.NewMapXmlReader(bytes.NewReader(r.Body))
+	r.Payload, err = mxj.NewMapXmlReader(body)
 	if err != nil {
 		return fmt.Errorf("error parsing XML payload: %+v", err)
 	}
@@ -0,0 +1,233 @@
+package webhook
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"text/template"
+
+	"github.com/mycoool/gohook/internal/quota"
+	"github.com/mycoool/gohook/internal/sandbox"
+)
+
+// responseData is the template context of response-template
+type responseData struct {
+	ID      string
+	HookID  string
+	Headers map[string]interface{}
+	Query   map[string]interface{}
+	Payload map[string]interface{}
+	// Async is true when the command runs in the background and its result is not known yet
+	Async    bool
+	Success  bool
+	ExitCode int
+	Output   string
+	Error    string
+}
+
+// Validate check the response status codes, stdin, endpoints, mirror, idempotency, output, artifact, object event and Starlark options and templates of the hook
+func (h *Hook) Validate() error {
+	for name, code := range map[string]int{
+		"success-http-response-code":               h.SuccessHttpResponseCode,
+		"failure-http-response-code":               h.FailureHttpResponseCode,
+		"trigger-rule-mismatch-http-response-code": h.TriggerRuleMismatchHttpResponseCode,
+	} {
+		if code != 0 && !validStatusCode(code) {
+			return fmt.Errorf("invalid %s: %d", name, code)
+		}
+	}
+	if h.StdinMaxBytes < 0 {
+		return fmt.Errorf("invalid stdin-max-bytes: %d", h.StdinMaxBytes)
+	}
+	if !validStdinCharset(h.StdinCharset) {
+		return fmt.Errorf("unsupported stdin-charset: %s", h.StdinCharset)
+	}
+	if err := h.validateEndpoints(); err != nil {
+		return err
+	}
+	if err := h.validateMirror(nil); err != nil {
+		return err
+	}
+	if !sandbox.Valid(h.Sandbox) {
+		return fmt.Errorf("unsupported sandbox: %s", h.Sandbox)
+	}
+	if h.InheritEnvironment != nil {
+		if err := h.InheritEnvironment.Validate(); err != nil {
+			return fmt.Errorf("invalid inherit-environment: %v", err)
+		}
+	}
+	if h.Idempotency != nil {
+		if err := h.Idempotency.Validate(); err != nil {
+			return err
+		}
+	}
+	if h.Artifacts != nil {
+		if err := h.Artifacts.Validate(); err != nil {
+			return err
+		}
+	}
+	if h.ObjectEvents != nil {
+		if err := h.ObjectEvents.Validate(); err != nil {
+			return err
+		}
+	}
+	if h.Starlark != nil {
+		if err := h.Starlark.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := h.Budget.Validate(); err != nil {
+		return err
+	}
+	if err := quota.Validate(h.Quota); err != nil {
+		return err
+	}
+	if err := h.Starlark.CheckFunctions(h.StarlarkFunctions()); err != nil {
+		return err
+	}
+	for name, pattern := range map[string]string{
+		"success-output-pattern": h.SuccessOutputPattern,
+		"failure-output-pattern": h.FailureOutputPattern,
+	} {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+	}
+	return h.ValidateTemplates()
+}
+
+// SuccessStatus HTTP status answered when the hook ran successfully (or was started in the background)
+func (h *Hook) SuccessStatus() int {
+	return statusOr(h.SuccessHttpResponseCode, http.StatusOK)
+}
+
+// FailureStatus HTTP status answered when the command failed
+func (h *Hook) FailureStatus() int {
+	return statusOr(h.FailureHttpResponseCode, http.StatusInternalServerError)
+}
+
+// MismatchStatus HTTP status answered when the trigger rules were not satisfied
+func (h *Hook) MismatchStatus() int {
+	return statusOr(h.TriggerRuleMismatchHttpResponseCode, http.StatusOK)
+}
+
+// statusOr configured status code, def when it is unset or outside 100-599
+func statusOr(code, def int) int {
+	if validStatusCode(code) {
+		return code
+	}
+	return def
+}
+
+func validStatusCode(code int) bool {
+	return code >= 100 && code <= 599
+}
+
+// ValidateTemplates parse the templates of the hook so mistakes are reported when it is loaded or saved
+func (h *Hook) ValidateTemplates() error {
+	if h.Forward != nil {
+		if err := h.Forward.Validate(); err != nil {
+			return err
+		}
+	}
+	if _, err := parseResponseTemplate(h.ResponseTemplate); err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseResponseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("response").Funcs(hookTemplateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response-template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// RenderResponse render the response-template of h.
+// With async the command was started in the background and output and err are not used.
+func RenderResponse(h *Hook, r *Request, output string, err error, async bool) (string, error) {
+	tmpl, perr := parseResponseTemplate(h.ResponseTemplate)
+	if perr != nil {
+		return "", perr
+	}
+
+	data := responseData{
+		ID:      r.ID,
+		HookID:  h.ID,
+		Headers: r.Headers,
+		Query:   r.Query,
+		Payload: r.Payload,
+		Async:   async,
+	}
+	if !async {
+		data.Success = err == nil
+		data.ExitCode = ExitCode(err)
+		data.Output = output
+		if err != nil {
+			data.Error = err.Error()
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("render response-template: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// OutputMismatchError the command exited successfully but its output failed the success criteria
+type OutputMismatchError struct {
+	Reason string
+}
+
+func (e *OutputMismatchError) Error() string {
+	return "command output " + e.Reason
+}
+
+// CheckOutput apply failure-output-pattern and success-output-pattern to the output of a run
+// that succeeded, a failed run keeps its error
+func (h *Hook) CheckOutput(output string, err error) error {
+	if err != nil {
+		return err
+	}
+	if h.FailureOutputPattern != "" {
+		re, err := regexp.Compile(h.FailureOutputPattern)
+		if err != nil {
+			return fmt.Errorf("invalid failure-output-pattern: %v", err)
+		}
+		if loc := re.FindStringIndex(output); loc != nil {
+			return &OutputMismatchError{Reason: fmt.Sprintf("matches failure-output-pattern: %q", output[loc[0]:loc[1]])}
+		}
+	}
+	if h.SuccessOutputPattern != "" {
+		re, err := regexp.Compile(h.SuccessOutputPattern)
+		if err != nil {
+			return fmt.Errorf("invalid success-output-pattern: %v", err)
+		}
+		if !re.MatchString(output) {
+			return &OutputMismatchError{Reason: "does not match success-output-pattern"}
+		}
+	}
+	return nil
+}
+
+// ExitCode exit status of a finished command: 0 on success, -1 when it did not run to completion
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	var mismatch *OutputMismatchError
+	if errors.As(err, &mismatch) {
+		// the command itself exited successfully
+		return 0
+	}
+	return -1
+}
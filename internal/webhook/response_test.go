@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestRenderResponse(t *testing.T) {
+	h := &Hook{
+		ID:               "build",
+		ResponseTemplate: `{"ok":{{.Success}},"code":{{.ExitCode}},"ref":{{toJson .Payload.ref}},"output":{{toJson .Output}}}`,
+	}
+	r := &Request{ID: "1", Payload: map[string]interface{}{"ref": "main"}}
+
+	got, err := RenderResponse(h, r, "done\n", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"ok":true,"code":0,"ref":"main","output":"done\n"}`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	runErr := exec.Command("sh", "-c", "exit 3").Run()
+	got, err = RenderResponse(h, r, "", runErr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"ok":false,"code":3,"ref":"main","output":""}`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	h.ResponseTemplate = `{{if .Async}}queued{{end}}`
+	if got, _ := RenderResponse(h, r, "", nil, true); got != "queued" {
+		t.Fatalf("async response = %q", got)
+	}
+
+	h.ResponseTemplate = `{{.Payload`
+	if err := h.ValidateTemplates(); err == nil {
+		t.Fatal("expected template parse error")
+	}
+}
+
+func TestHookStatusCodes(t *testing.T) {
+	h := &Hook{ID: "status"}
+	if h.SuccessStatus() != 200 || h.FailureStatus() != 500 || h.MismatchStatus() != 200 {
+		t.Fatalf("unexpected defaults: %d %d %d", h.SuccessStatus(), h.FailureStatus(), h.MismatchStatus())
+	}
+
+	h.SuccessHttpResponseCode, h.FailureHttpResponseCode, h.TriggerRuleMismatchHttpResponseCode = 201, 503, 204
+	if h.SuccessStatus() != 201 || h.FailureStatus() != 503 || h.MismatchStatus() != 204 {
+		t.Fatalf("configured codes ignored: %d %d %d", h.SuccessStatus(), h.FailureStatus(), h.MismatchStatus())
+	}
+	if err := h.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	h.FailureHttpResponseCode = 700
+	if h.FailureStatus() != 500 {
+		t.Fatalf("invalid code must fall back to 500, got %d", h.FailureStatus())
+	}
+	if err := h.Validate(); err == nil {
+		t.Fatal("expected invalid status code error")
+	}
+}
+
+func TestHookCheckOutput(t *testing.T) {
+	runErr := exec.Command("sh", "-c", "exit 2").Run()
+	tests := []struct {
+		name     string
+		success  string
+		failure  string
+		output   string
+		err      error
+		wantErr  bool
+		mismatch bool
+	}{
+		{"no criteria", "", "", "ERROR: disk full", nil, false, false},
+		{"failure pattern matches", "", "ERROR|FATAL", "step 1\nFATAL: out of memory\n", nil, true, true},
+		{"failure pattern absent", "", "ERROR|FATAL", "all good\n", nil, false, false},
+		{"success marker present", "DEPLOY OK", "", "building\nDEPLOY OK\n", nil, false, false},
+		{"success marker missing", "DEPLOY OK", "", "building\n", nil, true, true},
+		{"failure wins over marker", "DEPLOY OK", "ERROR", "ERROR\nDEPLOY OK\n", nil, true, true},
+		{"exit code failure kept", "DEPLOY OK", "", "DEPLOY OK\n", runErr, true, false},
+	}
+	for _, tt := range tests {
+		h := &Hook{ID: "check", SuccessOutputPattern: tt.success, FailureOutputPattern: tt.failure}
+		err := h.CheckOutput(tt.output, tt.err)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: CheckOutput() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		var mismatch *OutputMismatchError
+		if errors.As(err, &mismatch) != tt.mismatch {
+			t.Errorf("%s: CheckOutput() error = %v, mismatch %v", tt.name, err, tt.mismatch)
+		}
+		if tt.mismatch && ExitCode(err) != 0 {
+			t.Errorf("%s: ExitCode() = %d, want 0", tt.name, ExitCode(err))
+		}
+	}
+
+	if err := (&Hook{ID: "bad", FailureOutputPattern: "(ERROR"}).Validate(); err == nil {
+		t.Error("expected invalid failure-output-pattern error")
+	}
+}
@@ -0,0 +1,269 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mycoool/gohook/internal/database"
+	"github.com/mycoool/gohook/internal/i18n"
+	"github.com/mycoool/gohook/internal/middleware"
+	"github.com/mycoool/gohook/internal/namespace"
+	"github.com/mycoool/gohook/internal/stream"
+	"github.com/mycoool/gohook/internal/types"
+)
+
+var (
+	// ErrNoSecret the trigger rule of the hook has no signature rule with a secret to rotate
+	ErrNoSecret = errors.New("trigger rule has no signature rule with a secret")
+	// ErrMixedSecrets the signature rules of the hook use different secrets
+	ErrMixedSecrets = errors.New("signature rules of the trigger rule use different secrets")
+)
+
+// rotation actions of stream.SecretRotationMessage
+const (
+	SecretRotated = "rotated"
+	SecretRetired = "retired"
+)
+
+// GenerateSecret random secret for webhook signatures, 64 hex characters
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ParseGracePeriod time the previous secret stays valid, e.g. "48h". Empty is
+// types.DefaultSecretGracePeriod, 0 retires the previous secret right away.
+func ParseGracePeriod(s string) (time.Duration, error) {
+	if s == "" {
+		return types.DefaultSecretGracePeriod, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid grace period: %v", err)
+	}
+	if d < 0 || d > types.MaxSecretGracePeriod {
+		return 0, fmt.Errorf("grace period must be between 0 and %s", types.MaxSecretGracePeriod)
+	}
+	return d, nil
+}
+
+// NewSecretRotation rotation keeping previous valid for grace, nil when there is nothing to keep
+func NewSecretRotation(previous, by string, grace time.Duration) *types.SecretRotation {
+	if previous == "" || grace <= 0 {
+		return nil
+	}
+	now := time.Now()
+	return &types.SecretRotation{PreviousSecret: previous, RotatedBy: by, RotatedAt: now, Expires: now.Add(grace)}
+}
+
+// rotationExpires end of the grace period of an active rotation, nil otherwise
+func rotationExpires(r *types.SecretRotation) *time.Time {
+	if !r.Active(time.Now()) {
+		return nil
+	}
+	return &r.Expires
+}
+
+// signatureRules match rules of rules that verify a signature with their secret
+func signatureRules(rules *Rules) []*MatchRule {
+	if rules == nil {
+		return nil
+	}
+	var found []*MatchRule
+	switch {
+	case rules.And != nil:
+		for i := range *rules.And {
+			found = append(found, signatureRules(&(*rules.And)[i])...)
+		}
+	case rules.Or != nil:
+		for i := range *rules.Or {
+			found = append(found, signatureRules(&(*rules.Or)[i])...)
+		}
+	case rules.Not != nil:
+		found = signatureRules((*Rules)(rules.Not))
+	case rules.Match != nil:
+		switch rules.Match.Type {
+		case MatchHMACSHA1, MatchHMACSHA256, MatchHMACSHA512,
+			MatchHashSHA1, MatchHashSHA256, MatchHashSHA512, ScalrSignature:
+			found = []*MatchRule{rules.Match}
+		}
+	}
+	return found
+}
+
+// checkSignature verify the body signature with the secret of the rule or, during the grace
+// period of a rotation, with the previous secret
+func (r MatchRule) checkSignature(req *Request, newHash func() hash.Hash, prefix, signature string) (bool, error) {
+	_, err := checkBodySignature(req, newHash, prefix, r.Secret, signature)
+	if err != nil && req.PreviousSecret != "" {
+		if _, prevErr := checkBodySignature(req, newHash, prefix, req.PreviousSecret, signature); prevErr == nil {
+			req.PreviousSecretUsed = true
+			return true, nil
+		}
+	}
+	return err == nil, err
+}
+
+// checkScalrSignature CheckScalrSignature with the previous secret as fallback
+func (r MatchRule) checkScalrSignature(req *Request) (bool, error) {
+	ok, err := CheckScalrSignature(req, r.Secret, true)
+	if !ok && req.PreviousSecret != "" {
+		if prevOK, _ := CheckScalrSignature(req, req.PreviousSecret, true); prevOK {
+			req.PreviousSecretUsed = true
+			return true, nil
+		}
+	}
+	return ok, err
+}
+
+// RotateHookSecret replace the secret of the signature rules of a hook by a new random one.
+// The replaced secret stays valid for grace; an earlier previous secret is retired at once.
+func RotateHookSecret(id, by string, grace time.Duration) (string, error) {
+	if HookManager == nil {
+		return "", fmt.Errorf("no hooks loaded")
+	}
+	h := HookManager.MatchLoadedHook(id)
+	if h == nil {
+		return "", fmt.Errorf("hook %s not found", id)
+	}
+	rules := signatureRules(h.TriggerRule)
+	if len(rules) == 0 {
+		return "", ErrNoSecret
+	}
+	current := rules[0].Secret
+	for _, r := range rules[1:] {
+		if r.Secret != current {
+			return "", ErrMixedSecrets
+		}
+	}
+	secret, err := GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	oldRotation := h.SecretRotation
+	for _, r := range rules {
+		r.Secret = secret
+	}
+	h.SecretRotation = NewSecretRotation(current, by, grace)
+	if err := HookManager.SaveHookChanges(id); err != nil {
+		for _, r := range rules {
+			r.Secret = current
+		}
+		h.SecretRotation = oldRotation
+		return "", err
+	}
+	return secret, nil
+}
+
+// RetireHookSecrets drop the previous secrets of hooks whose grace period ended before now
+func RetireHookSecrets(now time.Time) {
+	if HookManager == nil || HookManager.LoadedHooksFromFiles == nil {
+		return
+	}
+	var retired []*Hook
+	for _, hooks := range *HookManager.LoadedHooksFromFiles {
+		for i := range hooks {
+			if hooks[i].SecretRotation != nil && !hooks[i].SecretRotation.Active(now) {
+				retired = append(retired, &hooks[i])
+			}
+		}
+	}
+	for _, h := range retired {
+		rotation := h.SecretRotation
+		h.SecretRotation = nil
+		if err := HookManager.SaveHookChanges(h.ID); err != nil {
+			h.SecretRotation = rotation
+			log.Printf("retiring the previous secret of hook %s failed: %v", h.ID, err)
+			continue
+		}
+		LogSecretRetired(namespace.KindHook, h.ID, rotation)
+	}
+}
+
+// LogSecretRetired record and announce that the previous secret of a hook or project expired
+func LogSecretRetired(kind, name string, rotation *types.SecretRotation) {
+	log.Printf("previous secret of %s %s retired, rotated by %s at %s", kind, name, rotation.RotatedBy, rotation.RotatedAt.Format(time.RFC3339))
+	category := database.LogCategoryHook
+	if kind == namespace.KindProject {
+		category = database.LogCategoryProject
+	}
+	database.LogSystemEvent(database.LogLevelInfo, category,
+		fmt.Sprintf("previous secret of %s %s retired", kind, name),
+		map[string]interface{}{"kind": kind, "name": name, "rotatedBy": rotation.RotatedBy, "rotatedAt": rotation.RotatedAt, "expires": rotation.Expires},
+		"", "", "")
+	stream.Global.Broadcast(stream.WsMessage{
+		Type:      "secret_rotation",
+		Timestamp: time.Now(),
+		Data:      stream.SecretRotationMessage{Kind: kind, Name: name, Action: SecretRetired, By: rotation.RotatedBy, Expires: rotation.Expires},
+	})
+}
+
+// HandleRotateHookSecret generate a new secret for the signature rules of a hook,
+// {"gracePeriod": "24h"} keeps the previous secret valid meanwhile
+func HandleRotateHookSecret(c *gin.Context) {
+	hookID := c.Param("id")
+	if HookManager.MatchLoadedHook(hookID) == nil {
+		i18n.JSONError(c, http.StatusNotFound, i18n.CodeHookNotFound, nil)
+		return
+	}
+	var request struct {
+		GracePeriod string `json:"gracePeriod"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+			return
+		}
+	}
+	grace, err := ParseGracePeriod(request.GracePeriod)
+	if err != nil {
+		i18n.JSONError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	username := c.GetString("username")
+	secret, err := RotateHookSecret(hookID, username, grace)
+	details := map[string]interface{}{"hookId": hookID, "gracePeriod": grace.String()}
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	database.LogHookManagement(
+		database.UserActionRotateHookSecret,
+		hookID,
+		hookID,
+		username,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+		err == nil,
+		details,
+	)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrNoSecret) || errors.Is(err, ErrMixedSecrets) {
+			status = http.StatusBadRequest
+		}
+		i18n.JSONError(c, status, i18n.CodeRotateSecretFailed, err)
+		return
+	}
+
+	h := HookManager.MatchLoadedHook(hookID)
+	msg := stream.SecretRotationMessage{Kind: namespace.KindHook, Name: hookID, Action: SecretRotated, By: username}
+	response := gin.H{"message": i18n.Message(c, i18n.CodeHookSecretRotated), "code": i18n.CodeHookSecretRotated, "secret": secret}
+	if h.SecretRotation != nil {
+		msg.Expires = h.SecretRotation.Expires
+		response["previousSecretExpires"] = h.SecretRotation.Expires
+	}
+	stream.Global.Broadcast(stream.WsMessage{Type: "secret_rotation", Timestamp: time.Now(), Data: msg})
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func signedRequest(secret string, body []byte) *Request {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return &Request{Body: body, Headers: map[string]interface{}{"X-Env": "prod", "X-Signature": "sha256=" + hex.EncodeToString(mac.Sum(nil))}}
+}
+
+func TestRotateHookSecret(t *testing.T) {
+	signature := func(secret string) Rules {
+		return Rules{Match: &MatchRule{Type: MatchHMACSHA256, Secret: secret, Parameter: Argument{Source: SourceHeader, Name: "X-Signature"}}}
+	}
+	file := filepath.Join(t.TempDir(), "hooks.json")
+	loaded := map[string]Hooks{
+		file: {
+			{ID: "deploy", ExecuteCommand: "/bin/true", TriggerRule: &Rules{And: &AndRule{signature("old"), {Match: &MatchRule{Type: MatchValue, Value: "prod", Parameter: Argument{Source: SourceHeader, Name: "X-Env"}}}}}},
+			{ID: "plain", ExecuteCommand: "/bin/true"},
+			{ID: "mixed", ExecuteCommand: "/bin/true", TriggerRule: &Rules{Or: &OrRule{signature("a"), signature("b")}}},
+		},
+	}
+	saved := HookManager
+	HookManager = NewHookManager(&loaded, []string{file}, false)
+	defer func() { HookManager = saved }()
+
+	for id, want := range map[string]error{"plain": ErrNoSecret, "mixed": ErrMixedSecrets} {
+		if _, err := RotateHookSecret(id, "admin", time.Hour); !errors.Is(err, want) {
+			t.Errorf("RotateHookSecret(%s) error = %v, want %v", id, err, want)
+		}
+	}
+
+	secret, err := RotateHookSecret("deploy", "admin", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := HookManager.MatchLoadedHook("deploy")
+	if got := signatureRules(h.TriggerRule)[0].Secret; got != secret || len(secret) != 64 {
+		t.Fatalf("rule secret = %q, want %q", got, secret)
+	}
+	if !h.SecretRotation.Active(time.Now()) || h.SecretRotation.PreviousSecret != "old" || h.SecretRotation.RotatedBy != "admin" {
+		t.Fatalf("rotation = %+v", h.SecretRotation)
+	}
+
+	body := []byte(`{"ref":"main"}`)
+	tests := []struct {
+		name     string
+		secret   string
+		previous string
+		ok       bool
+		usedPrev bool
+	}{
+		{"new secret", secret, "old", true, false},
+		{"previous secret in grace period", "old", "old", true, true},
+		{"previous secret after grace period", "old", "", false, false},
+		{"unknown secret", "other", "old", false, false},
+	}
+	for _, tt := range tests {
+		req := signedRequest(tt.secret, body)
+		req.PreviousSecret = tt.previous
+		ok, _ := h.TriggerRule.Evaluate(req)
+		if ok != tt.ok || req.PreviousSecretUsed != tt.usedPrev {
+			t.Errorf("%s: ok = %t, previous used = %t", tt.name, ok, req.PreviousSecretUsed)
+		}
+	}
+
+	RetireHookSecrets(time.Now())
+	if h.SecretRotation == nil {
+		t.Fatal("active rotation retired")
+	}
+	RetireHookSecrets(time.Now().Add(2 * time.Hour))
+	if h.SecretRotation != nil {
+		t.Errorf("expired rotation kept: %+v", h.SecretRotation)
+	}
+
+	// without grace period the replaced secret is dropped at once
+	if _, err := RotateHookSecret("deploy", "admin", 0); err != nil || h.SecretRotation != nil {
+		t.Errorf("rotation without grace period: %v, %+v", err, h.SecretRotation)
+	}
+}
+
+func TestParseGracePeriod(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", types.DefaultSecretGracePeriod, false},
+		{"48h", 48 * time.Hour, false},
+		{"0", 0, false},
+		{"-1h", 0, true},
+		{"800h", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseGracePeriod(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseGracePeriod(%q) = %s, %v", tt.in, got, err)
+		}
+	}
+}
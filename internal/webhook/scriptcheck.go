@@ -0,0 +1,199 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+// scriptCheckTimeout limit of a single syntax check or shellcheck run
+const scriptCheckTimeout = 10 * time.Second
+
+// defaultScriptCheckers syntax check commands by file extension, the script path is appended
+var defaultScriptCheckers = map[string]string{
+	".sh":   "bash -n",
+	".bash": "bash -n",
+	".py":   "python3 -m py_compile",
+}
+
+// shellExtensions extensions checked by shellcheck
+var shellExtensions = map[string]bool{".sh": true, ".bash": true}
+
+var lineNumberPattern = regexp.MustCompile(`line (\d+)`)
+
+// ScriptIssue problem reported by a script checker
+type ScriptIssue struct {
+	Tool     string `json:"tool"`
+	Severity string `json:"severity"` // error | warning
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ScriptCheckResult outcome of checking a script
+type ScriptCheckResult struct {
+	Checked bool          `json:"checked"` // at least one checker ran
+	OK      bool          `json:"ok"`      // no errors were reported
+	Tools   []string      `json:"tools"`
+	Issues  []ScriptIssue `json:"issues"`
+}
+
+// scriptChecker syntax check command for ext, configured checkers override the defaults
+func scriptChecker(ext string) string {
+	if cfg := types.GoHookAppConfig; cfg != nil && cfg.Scripts != nil {
+		if cmd, ok := cfg.Scripts.Checkers[ext]; ok {
+			return cmd
+		}
+	}
+	return defaultScriptCheckers[ext]
+}
+
+func shellcheckEnabled() bool {
+	cfg := types.GoHookAppConfig
+	return cfg == nil || cfg.Scripts == nil || cfg.Scripts.Shellcheck == nil || *cfg.Scripts.Shellcheck
+}
+
+// scriptRejectOnError whether scripts failing the syntax check are not saved
+func scriptRejectOnError() bool {
+	cfg := types.GoHookAppConfig
+	return cfg != nil && cfg.Scripts != nil && cfg.Scripts.RejectOnError
+}
+
+// scriptExtension extension of path, for files without one it is guessed from the shebang
+func scriptExtension(path string, content []byte) string {
+	if ext := strings.ToLower(filepath.Ext(path)); ext != "" {
+		return ext
+	}
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return ""
+	}
+	line, _, _ := bytes.Cut(content, []byte("\n"))
+	interpreter := string(line)
+	switch {
+	case strings.Contains(interpreter, "python"):
+		return ".py"
+	case strings.Contains(interpreter, "bash"):
+		return ".bash"
+	case strings.HasSuffix(strings.Fields(interpreter)[0], "/sh"), strings.HasSuffix(interpreter, " sh"):
+		return ".sh"
+	}
+	return ""
+}
+
+// CheckScript run the syntax checker for the language of content and shellcheck on shell
+// scripts. Checkers that are not installed are skipped. path is only used to pick the
+// language and in messages, the file itself is not touched.
+func CheckScript(ctx context.Context, path string, content []byte) ScriptCheckResult {
+	result := ScriptCheckResult{OK: true, Tools: []string{}, Issues: []ScriptIssue{}}
+	ext := scriptExtension(path, content)
+	if ext == "" {
+		return result
+	}
+
+	dir, err := os.MkdirTemp("", "gohook-check-")
+	if err != nil {
+		return result
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "script"+ext)
+	if err := os.WriteFile(file, content, 0600); err != nil {
+		return result
+	}
+	display := path
+	if display == "" {
+		display = "script" + ext
+	}
+
+	if args := strings.Fields(scriptChecker(ext)); len(args) > 0 {
+		if _, err := exec.LookPath(args[0]); err == nil {
+			result.Checked = true
+			result.Tools = append(result.Tools, args[0])
+			if issue := runSyntaxCheck(ctx, args, file, display); issue != nil {
+				result.Issues = append(result.Issues, *issue)
+				result.OK = false
+			}
+		}
+	}
+
+	if shellExtensions[ext] && shellcheckEnabled() {
+		if _, err := exec.LookPath("shellcheck"); err == nil {
+			result.Checked = true
+			result.Tools = append(result.Tools, "shellcheck")
+			for _, issue := range runShellcheck(ctx, file) {
+				if issue.Severity == "error" {
+					result.OK = false
+				}
+				result.Issues = append(result.Issues, issue)
+			}
+		}
+	}
+	return result
+}
+
+// runSyntaxCheck run a syntax check command, a failing command is one error
+func runSyntaxCheck(ctx context.Context, args []string, file, display string) *ScriptIssue {
+	ctx, cancel := context.WithTimeout(ctx, scriptCheckTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], append(args[1:], file)...)
+	cmd.Dir = filepath.Dir(file)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	msg := strings.TrimSpace(strings.ReplaceAll(string(out), file, display))
+	if msg == "" {
+		msg = err.Error()
+	}
+	issue := &ScriptIssue{Tool: args[0], Severity: "error", Message: msg}
+	if m := lineNumberPattern.FindStringSubmatch(msg); m != nil {
+		issue.Line, _ = strconv.Atoi(m[1])
+	}
+	return issue
+}
+
+// runShellcheck run shellcheck, errors stay errors, other levels become warnings
+func runShellcheck(ctx context.Context, file string) []ScriptIssue {
+	ctx, cancel := context.WithTimeout(ctx, scriptCheckTimeout)
+	defer cancel()
+
+	// shellcheck exits non-zero when it reports anything, the JSON output is what counts
+	out, _ := exec.CommandContext(ctx, "shellcheck", "-f", "json", file).Output()
+	var comments []struct {
+		Line    int    `json:"line"`
+		Column  int    `json:"column"`
+		Level   string `json:"level"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(out, &comments); err != nil {
+		return nil
+	}
+
+	issues := make([]ScriptIssue, 0, len(comments))
+	for _, c := range comments {
+		severity := "warning"
+		if c.Level == "error" {
+			severity = "error"
+		}
+		issues = append(issues, ScriptIssue{
+			Tool:     "shellcheck",
+			Severity: severity,
+			Line:     c.Line,
+			Column:   c.Column,
+			Code:     "SC" + strconv.Itoa(c.Code),
+			Message:  c.Message,
+		})
+	}
+	return issues
+}
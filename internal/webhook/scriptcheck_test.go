@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestScriptExtension(t *testing.T) {
+	tests := []struct {
+		path    string
+		content string
+		want    string
+	}{
+		{"/srv/deploy.sh", "", ".sh"},
+		{"/srv/deploy.PY", "", ".py"},
+		{"/srv/deploy", "#!/bin/bash\necho", ".bash"},
+		{"/srv/deploy", "#!/usr/bin/env python3\nprint(1)", ".py"},
+		{"/srv/deploy", "#!/bin/sh\necho", ".sh"},
+		{"/srv/deploy", "#!/usr/bin/env sh\necho", ".sh"},
+		{"/srv/deploy", "echo", ""},
+	}
+	for _, tt := range tests {
+		if got := scriptExtension(tt.path, []byte(tt.content)); got != tt.want {
+			t.Errorf("scriptExtension(%q, %q) = %q, want %q", tt.path, tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestCheckScript(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not installed")
+	}
+	tests := []struct {
+		name     string
+		path     string
+		content  string
+		wantOK   bool
+		wantLine int
+	}{
+		{"valid shell", "/srv/deploy.sh", "#!/bin/bash\necho ok\n", true, 0},
+		{"broken shell", "/srv/deploy.sh", "#!/bin/bash\nif true; then\necho ok\n", false, 4},
+		{"unknown language", "/srv/deploy.txt", "if then fi", true, 0},
+	}
+	for _, tt := range tests {
+		result := CheckScript(context.Background(), tt.path, []byte(tt.content))
+		if result.OK != tt.wantOK {
+			t.Errorf("%s: ok = %v, want %v (issues %+v)", tt.name, result.OK, tt.wantOK, result.Issues)
+		}
+		if tt.wantLine > 0 {
+			found := false
+			for _, issue := range result.Issues {
+				if issue.Tool == "bash" && issue.Line == tt.wantLine && strings.Contains(issue.Message, tt.path) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%s: no bash error on line %d: %+v", tt.name, tt.wantLine, result.Issues)
+			}
+		}
+	}
+}
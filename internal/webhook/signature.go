@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signature algorithms of outgoing requests
+const (
+	SignatureSHA1   = "sha1"
+	SignatureSHA256 = "sha256"
+	SignatureSHA512 = "sha512"
+)
+
+const (
+	// defaultSignatureHeader header carrying the signature of an outgoing request
+	defaultSignatureHeader = "X-GoHook-Signature"
+	// SignatureTimestampHeader header carrying the signing time when the timestamp is signed
+	SignatureTimestampHeader = "X-GoHook-Timestamp"
+)
+
+var signatureHashes = map[string]func() hash.Hash{
+	SignatureSHA1:   sha1.New,
+	SignatureSHA256: sha256.New,
+	SignatureSHA512: sha512.New,
+}
+
+// SignatureConfig HMAC signature of outgoing requests, so receivers can verify a call comes
+// from gohook. The header value is "<algorithm>=<hex digest>" like the signatures of GitHub,
+// which the payload-hmac trigger rules of another gohook check.
+type SignatureConfig struct {
+	Secret    string `json:"secret"`              // template, e.g. {{getenv "FORWARD_SECRET"}}
+	Algorithm string `json:"algorithm,omitempty"` // sha1 | sha256 (default) | sha512
+	Header    string `json:"header,omitempty"`    // default X-GoHook-Signature
+	Timestamp bool   `json:"timestamp,omitempty"` // sign "<unix time>.<body>" and send the time in X-GoHook-Timestamp
+}
+
+// Validate check the secret template, algorithm and header name
+func (s *SignatureConfig) Validate() error {
+	if s.Secret == "" {
+		return fmt.Errorf("signature secret is required")
+	}
+	if _, err := parseForwardTemplate("signature secret", s.Secret); err != nil {
+		return err
+	}
+	if _, ok := signatureHashes[s.algorithm()]; !ok {
+		return fmt.Errorf("unsupported signature algorithm: %s", s.Algorithm)
+	}
+	if s.Header != "" && !validHeaderName(s.Header) {
+		return fmt.Errorf("invalid signature header: %s", s.Header)
+	}
+	return nil
+}
+
+func (s *SignatureConfig) algorithm() string {
+	if s.Algorithm == "" {
+		return SignatureSHA256
+	}
+	return strings.ToLower(s.Algorithm)
+}
+
+func (s *SignatureConfig) header() string {
+	if s.Header == "" {
+		return defaultSignatureHeader
+	}
+	return s.Header
+}
+
+// validHeaderName whether name is an HTTP header field name
+func validHeaderName(name string) bool {
+	for _, c := range name {
+		if c <= ' ' || c >= 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// Sign set the signature headers of req for the body read from body, signed at now
+func (s *SignatureConfig) Sign(req *http.Request, secret string, body io.Reader, now time.Time) error {
+	mac := hmac.New(signatureHashes[s.algorithm()], []byte(secret))
+	if s.Timestamp {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		req.Header.Set(SignatureTimestampHeader, timestamp)
+		mac.Write([]byte(timestamp + "."))
+	}
+	if body != nil {
+		if _, err := io.Copy(mac, body); err != nil {
+			return fmt.Errorf("sign body: %v", err)
+		}
+	}
+	req.Header.Set(s.header(), s.algorithm()+"="+hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// DefaultSpoolThreshold request bodies larger than this are spooled to a temp file
+const DefaultSpoolThreshold = 1 << 20
+
+// EnvRequestBodyFile environment variable holding the path of a spooled request body
+const EnvRequestBodyFile = EnvNamespace + "REQUEST_BODY_FILE"
+
+var (
+	// SpoolThreshold set by -spool-threshold, 0 keeps every request body in memory
+	SpoolThreshold int64 = DefaultSpoolThreshold
+	// SpoolDir set by -spool-dir, empty uses the system temp directory
+	SpoolDir string
+)
+
+// ReadBody read the request body from body. Up to threshold bytes are kept in Body, a larger
+// body is written to a temp file named by BodyFile and Body stays nil; threshold 0 disables
+// spooling. RemoveBody must be called once the request is handled.
+func (r *Request) ReadBody(body io.Reader, threshold int64) error {
+	if threshold <= 0 {
+		var err error
+		r.Body, err = io.ReadAll(body)
+		return err
+	}
+
+	head, err := io.ReadAll(io.LimitReader(body, threshold+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(head)) <= threshold {
+		r.Body = head
+		return nil
+	}
+
+	f, err := os.CreateTemp(SpoolDir, "gohook-body-*")
+	if err != nil {
+		return fmt.Errorf("spool request body: %v", err)
+	}
+	digest := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, digest), io.MultiReader(bytes.NewReader(head), body))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("spool request body: %w", err)
+	}
+
+	r.Body = nil
+	r.BodyFile = f.Name()
+	r.bodySize = size
+	r.bodyDigest = hex.EncodeToString(digest.Sum(nil))
+	return nil
+}
+
+// Spooled reports whether the body is kept in a temp file instead of memory
+func (r *Request) Spooled() bool {
+	return r.BodyFile != ""
+}
+
+// BodySize size of the request body in bytes
+func (r *Request) BodySize() int64 {
+	if r.Spooled() {
+		return r.bodySize
+	}
+	return int64(len(r.Body))
+}
+
+// OpenBody read the request body from memory or from its temp file
+func (r *Request) OpenBody() (io.ReadCloser, error) {
+	if !r.Spooled() {
+		return io.NopCloser(bytes.NewReader(r.Body)), nil
+	}
+	return os.Open(r.BodyFile)
+}
+
+// BodyBytes the request body, a spooled body is loaded into memory
+func (r *Request) BodyBytes() ([]byte, error) {
+	if !r.Spooled() {
+		return r.Body, nil
+	}
+	return os.ReadFile(r.BodyFile)
+}
+
+// BodyDigest hex encoded SHA-256 of the request body
+func (r *Request) BodyDigest() string {
+	if r.Spooled() {
+		return r.bodyDigest
+	}
+	sum := sha256.Sum256(r.Body)
+	return hex.EncodeToString(sum[:])
+}
+
+// RemoveBody delete the temp file of a spooled body
+func (r *Request) RemoveBody() {
+	if !r.Spooled() {
+		return
+	}
+	if err := os.Remove(r.BodyFile); err != nil && !os.IsNotExist(err) {
+		log.Printf("[%s] error removing request body file %s: %v", r.ID, r.BodyFile, err)
+	}
+}
+
+// writeBody copy the request body to w without loading a spooled body into memory
+func (r *Request) writeBody(w io.Writer) (int64, error) {
+	body, err := r.OpenBody()
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	return io.Copy(w, body)
+}
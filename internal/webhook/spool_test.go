@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRequestReadBody(t *testing.T) {
+	body := `{"ref":"refs/heads/main","commits":["` + strings.Repeat("a", 64) + `"]}`
+	tests := []struct {
+		name      string
+		threshold int64
+		spooled   bool
+	}{
+		{"no spooling", 0, false},
+		{"below threshold", int64(len(body)), false},
+		{"above threshold", 16, true},
+	}
+	for _, tt := range tests {
+		r := &Request{ID: tt.name}
+		if err := r.ReadBody(strings.NewReader(body), tt.threshold); err != nil {
+			t.Fatalf("%s: ReadBody() error = %v", tt.name, err)
+		}
+		if r.Spooled() != tt.spooled {
+			t.Errorf("%s: Spooled() = %t, want %t", tt.name, r.Spooled(), tt.spooled)
+		}
+		if r.BodySize() != int64(len(body)) {
+			t.Errorf("%s: BodySize() = %d, want %d", tt.name, r.BodySize(), len(body))
+		}
+		got, err := r.BodyBytes()
+		if err != nil || string(got) != body {
+			t.Errorf("%s: BodyBytes() = %q, %v", tt.name, got, err)
+		}
+		sum := sha256.Sum256([]byte(body))
+		if r.BodyDigest() != hex.EncodeToString(sum[:]) {
+			t.Errorf("%s: BodyDigest() = %s", tt.name, r.BodyDigest())
+		}
+		if err := r.ParseJSONPayload(); err != nil || r.Payload["ref"] != "refs/heads/main" {
+			t.Errorf("%s: ParseJSONPayload() = %v, %v", tt.name, r.Payload, err)
+		}
+
+		file := r.BodyFile
+		r.RemoveBody()
+		if file != "" {
+			if _, err := os.Stat(file); !os.IsNotExist(err) {
+				t.Errorf("%s: body file %s not removed", tt.name, file)
+			}
+		}
+	}
+}
+
+func TestSpooledBodySignature(t *testing.T) {
+	body := strings.Repeat("payload ", 32)
+	r := &Request{ID: "sig"}
+	if err := r.ReadBody(strings.NewReader(body), 16); err != nil {
+		t.Fatal(err)
+	}
+	defer r.RemoveBody()
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	r.Headers = map[string]interface{}{"X-Hub-Signature-256": signature}
+
+	rule := MatchRule{Type: MatchHMACSHA256, Secret: "secret", Parameter: Argument{Source: SourceHeader, Name: "X-Hub-Signature-256"}}
+	if ok, err := rule.Evaluate(r); !ok || err != nil {
+		t.Errorf("Evaluate() = %t, %v, want true", ok, err)
+	}
+	rule.Secret = "other"
+	if ok, _ := rule.Evaluate(r); ok {
+		t.Error("Evaluate() with a wrong secret = true")
+	}
+
+	if _, err := (&Argument{Source: SourceRawRequestBody}).Get(r); err == nil {
+		t.Error("raw-request-body of a spooled body must fail")
+	}
+}
+
+func TestRunHookCommandSpooledBody(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	body := strings.Repeat("x", 100)
+	r := &Request{ID: "spool"}
+	if err := r.ReadBody(strings.NewReader(body), 10); err != nil {
+		t.Fatal(err)
+	}
+	defer r.RemoveBody()
+
+	h := &Hook{ID: "spool", ExecuteCommand: `cat "$` + EnvRequestBodyFile + `"; echo; cat`, Shell: ShellSh, PassRequestBodyToStdin: true}
+	out, _, err := runHookCommand(h, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != body+"\n"+body {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
@@ -0,0 +1,360 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// SourceStarlark argument source calling the function Name of the hook's Starlark program
+const SourceStarlark string = "starlark"
+
+// defaults of a Starlark program, per call
+const (
+	defaultStarlarkTimeout  = time.Second
+	defaultStarlarkMaxSteps = 10000000
+	defaultStarlarkRule     = "trigger"
+)
+
+// starlarkFileOptions language options of hook programs
+var starlarkFileOptions = &syntax.FileOptions{Set: true, While: true, TopLevelControl: true, Recursion: true}
+
+// starlarkPredeclared modules available to hook programs, they have no file, network or
+// process access and load() is not supported
+var starlarkPredeclared = starlark.StringDict{
+	"json":   starlarkjson.Module,
+	"struct": starlark.NewBuiltin("struct", starlarkstruct.Make),
+}
+
+// StarlarkConfig Starlark program of a hook, for trigger rules and argument values the
+// declarative rules cannot express. Its functions are called with the request as their only
+// argument: "starlark" rules decide whether the hook runs and the "starlark" argument source
+// computes a value. Every call is limited in time and execution steps.
+type StarlarkConfig struct {
+	Source   string `json:"source"`              // the program, stored with the hook
+	Timeout  string `json:"timeout,omitempty"`   // per call, default 1s
+	MaxSteps uint64 `json:"max-steps,omitempty"` // per call, default 10000000
+
+	once    sync.Once
+	globals starlark.StringDict
+	err     error
+}
+
+// Validate compile the program and run its top level statements
+func (s *StarlarkConfig) Validate() error {
+	if s.Timeout != "" {
+		if d, err := time.ParseDuration(s.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid starlark timeout: %s", s.Timeout)
+		}
+	}
+	_, err := s.program()
+	return err
+}
+
+// CheckFunctions check the program defines the functions, a nil program defines none
+func (s *StarlarkConfig) CheckFunctions(functions []string) error {
+	for _, name := range functions {
+		if s == nil {
+			return fmt.Errorf("starlark function %s called but the hook has no starlark program", name)
+		}
+		globals, err := s.program()
+		if err != nil {
+			return err
+		}
+		if _, ok := globals[name].(starlark.Callable); !ok {
+			return fmt.Errorf("starlark function %s is not defined", name)
+		}
+	}
+	return nil
+}
+
+// program globals of the program, it is compiled and initialized once
+func (s *StarlarkConfig) program() (starlark.StringDict, error) {
+	s.once.Do(func() {
+		_, prog, err := starlark.SourceProgramOptions(starlarkFileOptions, "starlark", s.Source, starlarkPredeclared.Has)
+		if err != nil {
+			s.err = fmt.Errorf("invalid starlark program: %v", err)
+			return
+		}
+		thread, stop := s.thread("")
+		defer stop()
+		globals, err := prog.Init(thread, starlarkPredeclared)
+		if err != nil {
+			s.err = fmt.Errorf("starlark program: %v", err)
+			return
+		}
+		globals.Freeze()
+		s.globals = globals
+	})
+	return s.globals, s.err
+}
+
+// thread new thread limited to the timeout and steps of a call, stop releases its timer
+func (s *StarlarkConfig) thread(requestID string) (*starlark.Thread, func()) {
+	thread := &starlark.Thread{
+		Name: "hook",
+		Print: func(_ *starlark.Thread, msg string) {
+			log.Printf("[%s] starlark: %s", requestID, msg)
+		},
+	}
+	maxSteps := s.MaxSteps
+	if maxSteps == 0 {
+		maxSteps = defaultStarlarkMaxSteps
+	}
+	thread.SetMaxExecutionSteps(maxSteps)
+	timeout := parseDurationOr(s.Timeout, defaultStarlarkTimeout)
+	timer := time.AfterFunc(timeout, func() {
+		thread.Cancel(fmt.Sprintf("timeout after %s", timeout))
+	})
+	return thread, func() { timer.Stop() }
+}
+
+// Call call the function of the program with the request
+func (s *StarlarkConfig) Call(function string, r *Request) (starlark.Value, error) {
+	globals, err := s.program()
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := globals[function].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("starlark function %s is not defined", function)
+	}
+	thread, stop := s.thread(r.ID)
+	defer stop()
+	v, err := starlark.Call(thread, fn, starlark.Tuple{r.starlarkValue()}, nil)
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			// report the innermost line of the program, not the whole backtrace
+			for i := range evalErr.CallStack {
+				if frame := evalErr.CallStack.At(i); frame.Pos.Filename() == "starlark" {
+					return nil, fmt.Errorf("starlark %s: %s: %s", function, frame.Pos, evalErr.Msg)
+				}
+			}
+		}
+		return nil, fmt.Errorf("starlark %s: %v", function, err)
+	}
+	return v, nil
+}
+
+// starlarkValue the request as a frozen struct with the fields id, method, remote_addr,
+// content_type, headers, query, payload and body, built once per request
+func (r *Request) starlarkValue() starlark.Value {
+	if r.starlark != nil {
+		return r.starlark
+	}
+	method, remoteAddr := "", r.ClientIP
+	if r.RawRequest != nil {
+		method = r.RawRequest.Method
+		if remoteAddr == "" {
+			remoteAddr = r.RawRequest.RemoteAddr
+		}
+	}
+	v := starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"id":           starlark.String(r.ID),
+		"method":       starlark.String(method),
+		"remote_addr":  starlark.String(remoteAddr),
+		"content_type": starlark.String(r.ContentType),
+		"headers":      toStarlark(r.Headers),
+		"query":        toStarlark(r.Query),
+		"payload":      toStarlark(r.Payload),
+		"body":         starlark.String(r.Body),
+	})
+	v.Freeze()
+	r.starlark = v
+	return v
+}
+
+// toStarlark convert a parsed header, query or payload value
+func toStarlark(v interface{}) starlark.Value {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None
+	case bool:
+		return starlark.Bool(v)
+	case string:
+		return starlark.String(v)
+	case json.Number:
+		if i, ok := new(big.Int).SetString(string(v), 10); ok {
+			return starlark.MakeBigInt(i)
+		}
+		if f, err := v.Float64(); err == nil {
+			return starlark.Float(f)
+		}
+		return starlark.String(v)
+	case float64:
+		return starlark.Float(v)
+	case int:
+		return starlark.MakeInt(v)
+	case int64:
+		return starlark.MakeInt64(v)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		d := starlark.NewDict(len(v))
+		for _, k := range keys {
+			d.SetKey(starlark.String(k), toStarlark(v[k]))
+		}
+		return d
+	case []interface{}:
+		elems := make([]starlark.Value, len(v))
+		for i := range v {
+			elems[i] = toStarlark(v[i])
+		}
+		return starlark.NewList(elems)
+	case []string:
+		elems := make([]starlark.Value, len(v))
+		for i := range v {
+			elems[i] = starlark.String(v[i])
+		}
+		return starlark.NewList(elems)
+	default:
+		return starlark.String(fmt.Sprint(v))
+	}
+}
+
+// starlarkString argument value of a function result: strings as is, None as the empty
+// string, numbers and booleans in their usual form and lists and dicts as JSON
+func starlarkString(v starlark.Value) (string, error) {
+	switch v := v.(type) {
+	case starlark.String:
+		return string(v), nil
+	case starlark.NoneType:
+		return "", nil
+	case starlark.Bool:
+		return fmt.Sprint(bool(v)), nil
+	case starlark.Int, starlark.Float:
+		return v.String(), nil
+	}
+	goValue, err := fromStarlark(v)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(goValue)
+	return string(b), err
+}
+
+// fromStarlark convert a function result to a value encoding as JSON
+func fromStarlark(v starlark.Value) (interface{}, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Int:
+		return json.Number(v.String()), nil
+	case starlark.Float:
+		return float64(v), nil
+	case *starlark.Dict:
+		m := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			k, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, fmt.Errorf("starlark dict key %s is not a string", item[0])
+			}
+			value, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			m[k] = value
+		}
+		return m, nil
+	case starlark.Indexable: // list and tuple
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			value, err := fromStarlark(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			s[i] = value
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("cannot convert starlark %s to a value", v.Type())
+	}
+}
+
+// StarlarkRule evaluates to the truth of a function of the hook's Starlark program
+type StarlarkRule struct {
+	Function string `json:"function,omitempty"` // default trigger
+}
+
+// function name of the called function
+func (r StarlarkRule) function() string {
+	if r.Function == "" {
+		return defaultStarlarkRule
+	}
+	return r.Function
+}
+
+// Evaluate StarlarkRule will return the truth of the function result
+func (r StarlarkRule) Evaluate(req *Request) (bool, error) {
+	if req.Starlark == nil {
+		return false, fmt.Errorf("starlark rule %s but the hook has no starlark program", r.function())
+	}
+	v, err := req.Starlark.Call(r.function(), req)
+	if err != nil {
+		return false, err
+	}
+	return bool(v.Truth()), nil
+}
+
+// starlarkFunctions functions of the Starlark program called by rules and arguments
+func starlarkFunctions(rules *Rules, args ...[]Argument) []string {
+	var functions []string
+	var walk func(r *Rules)
+	walk = func(r *Rules) {
+		switch {
+		case r == nil:
+		case r.And != nil:
+			for i := range *r.And {
+				walk(&(*r.And)[i])
+			}
+		case r.Or != nil:
+			for i := range *r.Or {
+				walk(&(*r.Or)[i])
+			}
+		case r.Not != nil:
+			walk((*Rules)(r.Not))
+		case r.Starlark != nil:
+			functions = append(functions, r.Starlark.function())
+		case r.Match != nil && r.Match.Parameter.Source == SourceStarlark:
+			functions = append(functions, r.Match.Parameter.Name)
+		}
+	}
+	walk(rules)
+	for _, list := range args {
+		for _, arg := range list {
+			if arg.Source == SourceStarlark {
+				functions = append(functions, arg.Name)
+			}
+		}
+	}
+	return functions
+}
+
+// StarlarkFunctions functions of the Starlark program the hook calls
+func (h *Hook) StarlarkFunctions() []string {
+	args := [][]Argument{h.PassArgumentsToCommand, h.PassEnvironmentToCommand, h.PassFileToCommand, h.JSONStringParameters}
+	if h.Idempotency != nil && h.Idempotency.Key != nil {
+		args = append(args, []Argument{*h.Idempotency.Key})
+	}
+	functions := starlarkFunctions(h.TriggerRule, args...)
+	for _, e := range h.Endpoints {
+		functions = append(functions, starlarkFunctions(e.TriggerRule)...)
+	}
+	return functions
+}
@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testStarlarkProgram = `
+BRANCHES = ["main", "release"]
+
+def trigger(req):
+    branch = req.payload["ref"].split("/")[-1]
+    return branch in BRANCHES and req.headers.get("X-Event") == "push"
+
+def version(req):
+    return req.payload["ref"].split("/")[-1] + "-" + str(req.payload["build"] + 1)
+
+def commits(req):
+    return [c["id"] for c in req.payload["commits"]]
+
+def nothing(req):
+    return None
+
+def method(req):
+    return req.method
+
+def loop(req):
+    while True:
+        pass
+
+def reject(req):
+    fail("bad request")
+`
+
+func newStarlarkRequest(t *testing.T, cfg *StarlarkConfig, payload string) *Request {
+	t.Helper()
+	raw, _ := http.NewRequest(http.MethodPost, "/hooks/test", nil)
+	r := &Request{
+		ID:          "test",
+		ContentType: "application/json",
+		Body:        []byte(payload),
+		RawRequest:  raw,
+		Headers:     map[string]interface{}{"X-Event": "push"},
+		Starlark:    cfg,
+	}
+	if err := r.ParseJSONPayload(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestStarlarkArgument(t *testing.T) {
+	cfg := &StarlarkConfig{Source: testStarlarkProgram, Timeout: "200ms", MaxSteps: 1 << 40}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	r := newStarlarkRequest(t, cfg, `{"ref":"refs/heads/main","build":41,"commits":[{"id":"a1"},{"id":"b2"}]}`)
+
+	tests := []struct {
+		function string
+		want     string
+		wantErr  string
+	}{
+		{"version", "main-42", ""},
+		{"commits", `["a1","b2"]`, ""},
+		{"nothing", "", ""},
+		{"method", "POST", ""},
+		{"missing", "", "not defined"},
+		{"loop", "", "timeout"},
+		{"reject", "", "starlark:25:9: fail: bad request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.function, func(t *testing.T) {
+			arg := Argument{Source: SourceStarlark, Name: tt.function}
+			got, err := arg.Get(r)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Get() = %q, %v, want error %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Get() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStarlarkRule(t *testing.T) {
+	cfg := &StarlarkConfig{Source: testStarlarkProgram}
+	tests := []struct {
+		name    string
+		rules   string
+		payload string
+		want    bool
+	}{
+		{"default function", `{"starlark":{}}`, `{"ref":"refs/heads/main"}`, true},
+		{"other branch", `{"starlark":{}}`, `{"ref":"refs/heads/dev"}`, false},
+		{"in and", `{"and":[{"starlark":{"function":"trigger"}},{"match":{"type":"value","value":"main-1","parameter":{"source":"starlark","name":"version"}}}]}`, `{"ref":"refs/heads/main","build":0}`, true},
+		{"negated", `{"not":{"starlark":{"function":"trigger"}}}`, `{"ref":"refs/heads/main"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules Rules
+			if err := json.Unmarshal([]byte(tt.rules), &rules); err != nil {
+				t.Fatal(err)
+			}
+			got, err := rules.Evaluate(newStarlarkRequest(t, cfg, tt.payload))
+			if err != nil || got != tt.want {
+				t.Errorf("Evaluate() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := (StarlarkRule{}).Evaluate(&Request{}); err == nil {
+		t.Error("Evaluate() without a program succeeded")
+	}
+}
+
+func TestStarlarkSteps(t *testing.T) {
+	cfg := &StarlarkConfig{Source: "def trigger(req):\n    return len([i for i in range(100000)]) > 0\n", MaxSteps: 1000}
+	_, err := (StarlarkRule{}).Evaluate(newStarlarkRequest(t, cfg, `{}`))
+	if err == nil || !strings.Contains(err.Error(), "too many steps") {
+		t.Errorf("Evaluate() = %v, want too many steps", err)
+	}
+}
+
+func TestValidateStarlark(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    string
+		wantErr string
+	}{
+		{"valid", `{"id":"h","starlark":{"source":"def trigger(req):\n    return True\n"},"trigger-rule":{"starlark":{}}}`, ""},
+		{"syntax error", `{"id":"h","starlark":{"source":"def trigger(req) return True"}}`, "invalid starlark program"},
+		{"load", `{"id":"h","starlark":{"source":"load('os.star', 'system')"}}`, "load"},
+		{"no file access", `{"id":"h","starlark":{"source":"x = open('/etc/passwd')"}}`, "undefined: open"},
+		{"top level loop", `{"id":"h","starlark":{"source":"while True:\n    pass\n","max-steps":1000}}`, "too many steps"},
+		{"invalid timeout", `{"id":"h","starlark":{"source":"","timeout":"soon"}}`, "invalid starlark timeout"},
+		{"missing rule function", `{"id":"h","starlark":{"source":""},"trigger-rule":{"or":[{"starlark":{"function":"check"}}]}}`, "check is not defined"},
+		{"missing argument function", `{"id":"h","starlark":{"source":"x = 1"},"pass-arguments-to-command":[{"source":"starlark","name":"x"}]}`, "x is not defined"},
+		{"no program", `{"id":"h","pass-environment-to-command":[{"source":"starlark","name":"tag","envname":"TAG"}]}`, "has no starlark program"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h Hook
+			if err := json.Unmarshal([]byte(tt.hook), &h); err != nil {
+				t.Fatal(err)
+			}
+			err := h.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// StdinCharsetAuto converts the body from the charset of its Content-Type
+const StdinCharsetAuto = "auto"
+
+// stdinBody request body passed to the command of h, converted to UTF-8 when stdin-charset asks for it
+func stdinBody(h *Hook, r *Request) ([]byte, error) {
+	if h.StdinMaxBytes > 0 && int64(len(r.Body)) > h.StdinMaxBytes {
+		return nil, fmt.Errorf("request body of %d bytes exceeds stdin-max-bytes %d", len(r.Body), h.StdinMaxBytes)
+	}
+
+	enc, err := stdinEncoding(h, r)
+	if err != nil || enc == nil || len(r.Body) == 0 {
+		return r.Body, err
+	}
+	body, err := enc.NewDecoder().Bytes(r.Body)
+	if err != nil {
+		name, _ := htmlindex.Name(enc)
+		return nil, fmt.Errorf("decode request body from %s: %v", name, err)
+	}
+	return body, nil
+}
+
+// stdinReader stdin of the command of h, a spooled body is streamed from its temp file.
+// A non-nil closer must be closed once the command finished.
+func stdinReader(h *Hook, r *Request) (io.Reader, io.Closer, error) {
+	if !r.Spooled() {
+		body, err := stdinBody(h, r)
+		return bytes.NewReader(body), nil, err
+	}
+	if h.StdinMaxBytes > 0 && r.BodySize() > h.StdinMaxBytes {
+		return nil, nil, fmt.Errorf("request body of %d bytes exceeds stdin-max-bytes %d", r.BodySize(), h.StdinMaxBytes)
+	}
+
+	enc, err := stdinEncoding(h, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := r.OpenBody()
+	if err != nil {
+		return nil, nil, err
+	}
+	if enc == nil {
+		return body, body, nil
+	}
+	return enc.NewDecoder().Reader(body), body, nil
+}
+
+// stdinEncoding charset the body is converted from, nil when it is passed unchanged
+func stdinEncoding(h *Hook, r *Request) (encoding.Encoding, error) {
+	charset := h.StdinCharset
+	if strings.EqualFold(charset, StdinCharsetAuto) {
+		charset = ""
+		if _, params, err := mime.ParseMediaType(r.ContentType); err == nil {
+			charset = params["charset"]
+		}
+	}
+	if charset == "" {
+		return nil, nil
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return enc, nil
+}
+
+// validStdinCharset reports whether charset is empty, auto or a known charset name
+func validStdinCharset(charset string) bool {
+	if charset == "" || strings.EqualFold(charset, StdinCharsetAuto) {
+		return true
+	}
+	_, err := htmlindex.Get(charset)
+	return err == nil
+}
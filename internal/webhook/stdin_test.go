@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStdinBody(t *testing.T) {
+	// "中文" encoded as GBK
+	gbk := []byte{0xd6, 0xd0, 0xce, 0xc4}
+	r := &Request{ContentType: "text/plain; charset=GBK", Body: gbk}
+
+	h := &Hook{PassRequestBodyToStdin: true}
+	if got, _ := stdinBody(h, r); string(got) != string(gbk) {
+		t.Fatalf("raw body must be passed unchanged, got %q", got)
+	}
+
+	h.StdinCharset = StdinCharsetAuto
+	if got, err := stdinBody(h, r); err != nil || string(got) != "中文" {
+		t.Fatalf("auto charset: got %q, %v", got, err)
+	}
+
+	h.StdinCharset = "gb18030"
+	r.ContentType = "text/plain"
+	if got, err := stdinBody(h, r); err != nil || string(got) != "中文" {
+		t.Fatalf("explicit charset: got %q, %v", got, err)
+	}
+
+	h.StdinMaxBytes = 3
+	if _, err := stdinBody(h, r); err == nil {
+		t.Fatal("expected size limit error")
+	}
+
+	if err := (&Hook{StdinCharset: "klingon"}).Validate(); err == nil {
+		t.Fatal("expected unsupported charset error")
+	}
+}
+
+func TestRunHookCommandStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	h := &Hook{ID: "stdin", ExecuteCommand: "cat", Shell: ShellSh, PassRequestBodyToStdin: true}
+	r := &Request{ID: "1", Body: []byte(`{"ref":"main"}`)}
+	out, _, err := runHookCommand(h, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(out) != `{"ref":"main"}` {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
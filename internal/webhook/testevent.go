@@ -0,0 +1,346 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mycoool/gohook/internal/urls"
+)
+
+// test event providers
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea"
+)
+
+// test event kinds
+const (
+	TestEventPush    = "push"
+	TestEventTag     = "tag"
+	TestEventRelease = "release"
+)
+
+// maxTestResponse response bodies of test deliveries are cut after this many bytes
+const maxTestResponse = 64 << 10
+
+var hookEndpoint struct {
+	handler http.Handler
+}
+
+// SetHookEndpoint install the handler serving hook deliveries below the base path,
+// synthetic test events are delivered through it
+func SetHookEndpoint(handler http.Handler) {
+	hookEndpoint.handler = handler
+}
+
+// TestEventOptions describe the synthetic provider event to send
+type TestEventOptions struct {
+	Provider   string `json:"provider"`             // github | gitlab | gitea
+	Event      string `json:"event,omitempty"`      // push (default) | tag | release
+	Ref        string `json:"ref,omitempty"`        // branch or tag name, default main or v1.0.0
+	Repository string `json:"repository,omitempty"` // owner/name, default gohook/example
+	DryRun     bool   `json:"dryRun,omitempty"`     // only build the delivery, do not send it
+}
+
+// TestDelivery HTTP request of a synthetic event
+type TestDelivery struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// TestResult answer of the hook endpoint to a synthetic event
+type TestResult struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// BuildTestDelivery build the request a provider would send to hook h for opts. Headers
+// checked by the trigger rules of h are filled in, signatures are computed from the
+// configured secrets.
+func BuildTestDelivery(h *Hook, opts TestEventOptions) (*TestDelivery, error) {
+	if opts.Event == "" {
+		opts.Event = TestEventPush
+	}
+	if opts.Repository == "" {
+		opts.Repository = "gohook/example"
+	}
+	if opts.Ref == "" {
+		opts.Ref = "main"
+		if opts.Event != TestEventPush {
+			opts.Ref = "v1.0.0"
+		}
+	}
+	if !strings.Contains(opts.Repository, "/") {
+		return nil, fmt.Errorf("repository must be owner/name")
+	}
+
+	headers, payload, err := providerEvent(opts)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		if canonical := textproto.CanonicalMIMEHeaderKey(name); canonical != name {
+			delete(headers, name)
+			headers[canonical] = value
+		}
+	}
+	headers["Content-Type"] = "application/json"
+
+	query := url.Values{}
+	if h.TriggerRule != nil {
+		fillRuleValues(*h.TriggerRule, body, headers, query)
+	}
+
+	u := urls.Current().HookPath(h.ID)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return &TestDelivery{Method: http.MethodPost, URL: u, Headers: headers, Body: string(body)}, nil
+}
+
+// SendTestDelivery pass d to the hook endpoint and return its answer
+func SendTestDelivery(d *TestDelivery) (*TestResult, error) {
+	if hookEndpoint.handler == nil {
+		return nil, fmt.Errorf("hook endpoint not available")
+	}
+
+	req := httptest.NewRequest(d.Method, d.URL, strings.NewReader(d.Body))
+	for name, value := range d.Headers {
+		req.Header.Set(name, value)
+	}
+	req.RemoteAddr = "127.0.0.1:0"
+	rec := httptest.NewRecorder()
+	hookEndpoint.handler.ServeHTTP(rec, req)
+
+	result := &TestResult{Status: rec.Code, Headers: map[string]string{}, Body: rec.Body.String()}
+	if len(result.Body) > maxTestResponse {
+		result.Body = result.Body[:maxTestResponse] + "... (truncated)"
+	}
+	for name := range rec.Header() {
+		result.Headers[name] = rec.Header().Get(name)
+	}
+	return result, nil
+}
+
+// fillRuleValues set the headers and query values checked by the match rules of r.
+// Values the provider preset already set are kept, rules below a not are skipped.
+func fillRuleValues(r Rules, body []byte, headers map[string]string, query url.Values) {
+	switch {
+	case r.And != nil:
+		for _, child := range *r.And {
+			fillRuleValues(child, body, headers, query)
+		}
+	case r.Or != nil:
+		for _, child := range *r.Or {
+			fillRuleValues(child, body, headers, query)
+		}
+	case r.Match != nil:
+		m := r.Match
+		var value string
+		switch m.Type {
+		case MatchValue:
+			value = m.Value
+		case MatchHMACSHA1, MatchHashSHA1:
+			value = signature(m.Parameter.Name, "sha1=", sha1.New, m.Secret, body)
+		case MatchHMACSHA256, MatchHashSHA256:
+			value = signature(m.Parameter.Name, "sha256=", sha256.New, m.Secret, body)
+		case MatchHMACSHA512, MatchHashSHA512:
+			value = signature(m.Parameter.Name, "sha512=", sha512.New, m.Secret, body)
+		default:
+			return
+		}
+
+		switch m.Parameter.Source {
+		case SourceHeader:
+			name := textproto.CanonicalMIMEHeaderKey(m.Parameter.Name)
+			if _, ok := headers[name]; !ok {
+				headers[name] = value
+			}
+		case SourceQuery, SourceQueryAlias:
+			if query.Get(m.Parameter.Name) == "" {
+				query.Set(m.Parameter.Name, value)
+			}
+		}
+	}
+}
+
+// signature HMAC of body, GitHub style headers carry the algorithm prefix
+func signature(header, prefix string, algo func() hash.Hash, secret string, body []byte) string {
+	mac := hmac.New(algo, []byte(secret))
+	mac.Write(body)
+	sum := hex.EncodeToString(mac.Sum(nil))
+	if strings.HasPrefix(textproto.CanonicalMIMEHeaderKey(header), "X-Hub-Signature") {
+		return prefix + sum
+	}
+	return sum
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func randomUUID() string {
+	h := randomHex(16)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// providerEvent headers and payload of the preset for opts
+func providerEvent(opts TestEventOptions) (map[string]string, map[string]interface{}, error) {
+	owner, name, _ := strings.Cut(opts.Repository, "/")
+	sha := randomHex(20)
+	before := randomHex(20)
+	now := time.Now().Format(time.RFC3339)
+	delivery := randomUUID()
+
+	ref := "refs/heads/" + opts.Ref
+	if opts.Event == TestEventTag {
+		ref = "refs/tags/" + opts.Ref
+	}
+	commit := map[string]interface{}{
+		"id":        sha,
+		"message":   "Test commit from gohook",
+		"timestamp": now,
+		"author":    map[string]interface{}{"name": "gohook", "email": "gohook@example.com", "username": "gohook"},
+	}
+
+	switch opts.Provider {
+	case ProviderGitHub, ProviderGitea:
+		host := "https://github.com/"
+		headers := map[string]string{"User-Agent": "GitHub-Hookshot/gohook-test", "X-GitHub-Delivery": delivery, "X-GitHub-Hook-ID": "0"}
+		eventHeader := "X-GitHub-Event"
+		if opts.Provider == ProviderGitea {
+			host = "https://gitea.example.com/"
+			headers = map[string]string{"User-Agent": "Go-http-client/1.1", "X-Gitea-Delivery": delivery}
+			eventHeader = "X-Gitea-Event"
+		}
+		repo := map[string]interface{}{
+			"name":           name,
+			"full_name":      opts.Repository,
+			"owner":          map[string]interface{}{"login": owner, "name": owner},
+			"html_url":       host + opts.Repository,
+			"clone_url":      host + opts.Repository + ".git",
+			"default_branch": "main",
+		}
+		sender := map[string]interface{}{"login": "gohook"}
+
+		switch opts.Event {
+		case TestEventPush, TestEventTag:
+			headers[eventHeader] = "push"
+			if opts.Provider == ProviderGitea {
+				headers["X-Gitea-Event-Type"] = "push"
+			}
+			payload := map[string]interface{}{
+				"ref":         ref,
+				"before":      before,
+				"after":       sha,
+				"created":     opts.Event == TestEventTag,
+				"deleted":     false,
+				"compare":     host + opts.Repository + "/compare/" + before[:12] + "..." + sha[:12],
+				"commits":     []interface{}{commit},
+				"head_commit": commit,
+				"repository":  repo,
+				"pusher":      map[string]interface{}{"name": "gohook", "email": "gohook@example.com"},
+				"sender":      sender,
+			}
+			return headers, payload, nil
+		case TestEventRelease:
+			headers[eventHeader] = "release"
+			if opts.Provider == ProviderGitea {
+				headers["X-Gitea-Event-Type"] = "release"
+			}
+			payload := map[string]interface{}{
+				"action": "published",
+				"release": map[string]interface{}{
+					"tag_name":         opts.Ref,
+					"name":             opts.Ref,
+					"target_commitish": "main",
+					"body":             "Test release from gohook",
+					"draft":            false,
+					"prerelease":       false,
+					"html_url":         host + opts.Repository + "/releases/tag/" + opts.Ref,
+					"published_at":     now,
+				},
+				"repository": repo,
+				"sender":     sender,
+			}
+			return headers, payload, nil
+		}
+
+	case ProviderGitLab:
+		headers := map[string]string{"User-Agent": "GitLab/gohook-test", "X-Gitlab-Event-UUID": delivery, "X-Gitlab-Instance": "https://gitlab.example.com"}
+		project := map[string]interface{}{
+			"name":                name,
+			"namespace":           owner,
+			"path_with_namespace": opts.Repository,
+			"web_url":             "https://gitlab.example.com/" + opts.Repository,
+			"git_http_url":        "https://gitlab.example.com/" + opts.Repository + ".git",
+			"default_branch":      "main",
+		}
+
+		switch opts.Event {
+		case TestEventPush, TestEventTag:
+			kind, hookName := "push", "Push Hook"
+			if opts.Event == TestEventTag {
+				kind, hookName = "tag_push", "Tag Push Hook"
+			}
+			headers["X-Gitlab-Event"] = hookName
+			payload := map[string]interface{}{
+				"object_kind":         kind,
+				"event_name":          kind,
+				"ref":                 ref,
+				"before":              before,
+				"after":               sha,
+				"checkout_sha":        sha,
+				"user_name":           "gohook",
+				"user_username":       "gohook",
+				"user_email":          "gohook@example.com",
+				"project":             project,
+				"repository":          map[string]interface{}{"name": name, "homepage": project["web_url"], "git_http_url": project["git_http_url"]},
+				"commits":             []interface{}{commit},
+				"total_commits_count": 1,
+			}
+			return headers, payload, nil
+		case TestEventRelease:
+			headers["X-Gitlab-Event"] = "Release Hook"
+			payload := map[string]interface{}{
+				"object_kind": "release",
+				"action":      "create",
+				"tag":         opts.Ref,
+				"name":        opts.Ref,
+				"description": "Test release from gohook",
+				"released_at": now,
+				"url":         project["web_url"].(string) + "/-/releases/" + opts.Ref,
+				"project":     project,
+				"commit":      commit,
+			}
+			return headers, payload, nil
+		}
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported provider: %s", opts.Provider)
+	}
+	return nil, nil, fmt.Errorf("unsupported event: %s", opts.Event)
+}
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBuildTestDelivery(t *testing.T) {
+	githubRule := &Rules{And: &AndRule{
+		{Match: &MatchRule{Type: MatchHMACSHA256, Secret: "s3cret", Parameter: Argument{Source: SourceHeader, Name: "X-Hub-Signature-256"}}},
+		{Match: &MatchRule{Type: MatchValue, Value: "refs/heads/main", Parameter: Argument{Source: SourcePayload, Name: "ref"}}},
+	}}
+	gitlabRule := &Rules{Match: &MatchRule{Type: MatchValue, Value: "token", Parameter: Argument{Source: SourceHeader, Name: "X-Gitlab-Token"}}}
+	giteaRule := &Rules{Match: &MatchRule{Type: MatchHMACSHA256, Secret: "s3cret", Parameter: Argument{Source: SourceHeader, Name: "X-Gitea-Signature"}}}
+	queryRule := &Rules{Or: &OrRule{
+		{Match: &MatchRule{Type: MatchValue, Value: "key", Parameter: Argument{Source: SourceQuery, Name: "token"}}},
+	}}
+
+	tests := []struct {
+		name        string
+		rule        *Rules
+		opts        TestEventOptions
+		eventHeader string
+		event       string
+		ref         string
+		wantErr     bool
+	}{
+		{"github push", githubRule, TestEventOptions{Provider: ProviderGitHub}, "X-Github-Event", "push", "refs/heads/main", false},
+		{"github release", nil, TestEventOptions{Provider: ProviderGitHub, Event: TestEventRelease}, "X-Github-Event", "release", "", false},
+		{"gitlab tag", gitlabRule, TestEventOptions{Provider: ProviderGitLab, Event: TestEventTag, Ref: "v2"}, "X-Gitlab-Event", "Tag Push Hook", "refs/tags/v2", false},
+		{"gitlab release", gitlabRule, TestEventOptions{Provider: ProviderGitLab, Event: TestEventRelease}, "X-Gitlab-Event", "Release Hook", "", false},
+		{"gitea push", giteaRule, TestEventOptions{Provider: ProviderGitea, Ref: "dev"}, "X-Gitea-Event", "push", "refs/heads/dev", false},
+		{"query token", queryRule, TestEventOptions{Provider: ProviderGitHub}, "X-Github-Event", "push", "refs/heads/main", false},
+		{"unknown provider", nil, TestEventOptions{Provider: "svn"}, "", "", "", true},
+		{"unknown event", nil, TestEventOptions{Provider: ProviderGitHub, Event: "fork"}, "", "", "", true},
+		{"bad repository", nil, TestEventOptions{Provider: ProviderGitHub, Repository: "example"}, "", "", "", true},
+	}
+	for _, tt := range tests {
+		h := &Hook{ID: "deploy", TriggerRule: tt.rule}
+		d, err := BuildTestDelivery(h, tt.opts)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if d.Method != http.MethodPost || !strings.HasSuffix(strings.SplitN(d.URL, "?", 2)[0], "deploy") {
+			t.Errorf("%s: request %s %s", tt.name, d.Method, d.URL)
+		}
+		if got := d.Headers[tt.eventHeader]; got != tt.event {
+			t.Errorf("%s: %s = %q, want %q", tt.name, tt.eventHeader, got, tt.event)
+		}
+
+		var payload map[string]interface{}
+		if err := json.Unmarshal([]byte(d.Body), &payload); err != nil {
+			t.Errorf("%s: invalid payload: %v", tt.name, err)
+			continue
+		}
+		if tt.ref != "" && payload["ref"] != tt.ref {
+			t.Errorf("%s: ref = %v, want %s", tt.name, payload["ref"], tt.ref)
+		}
+
+		// the delivery passes the trigger rule of the hook
+		if tt.rule == nil {
+			continue
+		}
+		u, _ := url.Parse(d.URL)
+		headers := http.Header{}
+		for name, value := range d.Headers {
+			headers.Set(name, value)
+		}
+		r := &Request{ID: "test", Body: []byte(d.Body), Payload: payload}
+		r.ParseHeaders(headers)
+		r.ParseQuery(u.Query())
+		if ok, err := tt.rule.Evaluate(r); !ok || err != nil {
+			t.Errorf("%s: trigger rule not satisfied: ok=%v err=%v", tt.name, ok, err)
+		}
+	}
+}
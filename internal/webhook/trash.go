@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mycoool/gohook/internal/stream"
+)
+
+// ErrHookExists a hook with the same id is loaded
+var ErrHookExists = errors.New("hook already exists")
+
+// RestoreHook add a hook taken from the trash back to the hooks file it was deleted from,
+// or to the first hooks file when that file is no longer loaded. It returns the file used.
+func RestoreHook(h Hook, source string) (string, error) {
+	if HookManager == nil || HookManager.LoadedHooksFromFiles == nil {
+		return "", fmt.Errorf("no hooks loaded")
+	}
+	if !ValidShell(h.Shell) {
+		return "", fmt.Errorf("hook %s: unsupported shell %s", h.ID, h.Shell)
+	}
+	if err := CheckHookCommand(h); err != nil {
+		return "", err
+	}
+	if HookManager.IDInUse(h.ID) {
+		return "", fmt.Errorf("%w: %s", ErrHookExists, h.ID)
+	}
+	hooksByFile := *HookManager.LoadedHooksFromFiles
+	if _, ok := hooksByFile[source]; !ok {
+		if _, err := ImportHooks([]Hook{h}, false); err != nil {
+			return "", err
+		}
+		broadcastRestore(h.ID)
+		return HookManager.FindHookFile(h.ID), nil
+	}
+
+	hooks := hooksByFile[source]
+	hooksByFile[source] = append(hooks, h)
+	if err := HookManager.SaveHooksToFile(source); err != nil {
+		hooksByFile[source] = hooks
+		return "", err
+	}
+	broadcastRestore(h.ID)
+	return source, nil
+}
+
+func broadcastRestore(hookID string) {
+	stream.Global.Broadcast(stream.WsMessage{
+		Type:      "hook_managed",
+		Timestamp: time.Now(),
+		Data: stream.HookManageMessage{
+			Action:   "restore",
+			HookID:   hookID,
+			HookName: hookID,
+			Success:  true,
+		},
+	})
+}
@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mycoool/gohook/internal/types"
+)
+
+func TestRestoreHook(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "hooks.json")
+	second := filepath.Join(dir, "more.json")
+	loaded := map[string]Hooks{
+		first:  {{ID: "deploy", ExecuteCommand: "/bin/true"}},
+		second: {{ID: "backup", ExecuteCommand: "/bin/true"}},
+	}
+	saved := HookManager
+	HookManager = NewHookManager(&loaded, []string{first, second}, false)
+	defer func() { HookManager = saved }()
+
+	tests := []struct {
+		name     string
+		hook     Hook
+		source   string
+		wantFile string
+		wantErr  error
+	}{
+		{"back to its file", Hook{ID: "notify", ExecuteCommand: "/bin/echo"}, second, second, nil},
+		{"file no longer loaded", Hook{ID: "cleanup", ExecuteCommand: "/bin/echo"}, filepath.Join(dir, "gone.json"), first, nil},
+		{"id taken", Hook{ID: "deploy", ExecuteCommand: "/bin/echo"}, first, "", ErrHookExists},
+	}
+	for _, tt := range tests {
+		file, err := RestoreHook(tt.hook, tt.source)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: RestoreHook() error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: RestoreHook() error = %v", tt.name, err)
+			continue
+		}
+		if file != tt.wantFile || HookManager.FindHookFile(tt.hook.ID) != tt.wantFile {
+			t.Errorf("%s: restored to %s, want %s", tt.name, file, tt.wantFile)
+		}
+	}
+	if len(loaded[first]) != 2 || len(loaded[second]) != 2 {
+		t.Errorf("loaded hooks = %+v", loaded)
+	}
+
+	savedConfig := types.GoHookAppConfig
+	defer func() { types.GoHookAppConfig = savedConfig }()
+	types.GoHookAppConfig = &types.AppConfig{Scripts: &types.ScriptsConfig{Commands: &types.CommandPolicy{DenyShellMetacharacters: true}}}
+	var denied *CommandDeniedError
+	if _, err := RestoreHook(Hook{ID: "evil", ExecuteCommand: "/bin/echo $(id)"}, second); !errors.As(err, &denied) {
+		t.Errorf("RestoreHook() of a refused command error = %v", err)
+	}
+	if HookManager.MatchLoadedHook("evil") != nil {
+		t.Error("refused hook restored")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mycoool/gohook/internal/cluster"
+	"github.com/mycoool/gohook/internal/pool"
+	"github.com/mycoool/gohook/internal/syncnode"
+)
+
+// restartGracePeriod time running requests and background executions get to finish before
+// the server restarts on an updated binary
+const restartGracePeriod = 30 * time.Second
+
+var (
+	server     *http.Server
+	restarting atomic.Bool
+)
+
+// releaseResources hand over the cluster leadership, stop the project watchers and remove
+// the pid file and the Unix socket before the process exits or restarts
+func releaseResources() {
+	// hand the leadership over right away so another instance takes over
+	cluster.Stop()
+	syncnode.StopProjectWatchers()
+	if pidFile != nil {
+		err := pidFile.Remove()
+		if err != nil {
+			log.Print(err)
+		}
+	}
+	if socket != "" && !strings.HasPrefix(socket, "@") {
+		// we've been listening on a named Unix socket, delete it
+		// before we exit so subsequent runs can re-bind the same
+		// socket path
+		err := os.Remove(socket)
+		if err != nil {
+			log.Printf("Failed to remove socket file %s: %v", socket, err)
+		}
+	}
+}
+
+// gracefulRestart stop accepting requests, let running requests and background executions
+// finish, then run the binary at exe in place of this process
+func gracefulRestart(exe string) {
+	if !restarting.CompareAndSwap(false, true) {
+		return
+	}
+	log.Printf("restarting on %s", exe)
+	ctx, cancel := context.WithTimeout(context.Background(), restartGracePeriod)
+	defer cancel()
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("graceful shutdown: %v", err)
+		}
+	}
+	if !pool.Executions.Drain(ctx) {
+		log.Printf("restarting with background executions still running")
+	}
+	releaseResources()
+	if err := restartProcess(exe); err != nil {
+		log.Printf("restart on %s failed: %v", exe, err)
+		os.Exit(1)
+	}
+}
+
+// waitForRestart keep the process alive after the server stopped for a restart
+func waitForRestart() {
+	if restarting.Load() {
+		select {}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// restartProcess replace this process with the binary at exe, keeping the pid, arguments
+// and environment
+func restartProcess(exe string) error {
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
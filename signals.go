@@ -7,11 +7,9 @@ import (
 	"log"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 
 	"github.com/mycoool/gohook/internal/webhook"
-	"github.com/mycoool/gohook/internal/syncnode"
 )
 
 func setupSignals() {
@@ -42,22 +40,7 @@ func watchForSignals() {
 
 		case os.Interrupt, syscall.SIGTERM:
 			log.Printf("caught %s signal; exiting\n", sig)
-			syncnode.StopProjectWatchers()
-			if pidFile != nil {
-				err := pidFile.Remove()
-				if err != nil {
-					log.Print(err)
-				}
-			}
-			if socket != "" && !strings.HasPrefix(socket, "@") {
-				// we've been listening on a named Unix socket, delete it
-				// before we exit so subsequent runs can re-bind the same
-				// socket path
-				err := os.Remove(socket)
-				if err != nil {
-					log.Printf("Failed to remove socket file %s: %v", socket, err)
-				}
-			}
+			releaseResources()
 			os.Exit(0)
 
 		default:
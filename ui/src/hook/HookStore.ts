@@ -9,11 +9,28 @@ export class HookStore {
     @observable
     protected items: IHook[] = [];
 
+    // 每个Hook修改所基于的版本，修改时通过If-Match提交
+    private revisions: {[id: string]: string} = {};
+
     public constructor(
         private readonly snack: SnackReporter,
         private readonly tokenProvider: () => string
     ) {}
 
+    private editHeaders = (id: string): {[header: string]: string} => {
+        const headers: {[header: string]: string} = {'X-GoHook-Key': this.tokenProvider()};
+        if (this.revisions[id]) {
+            headers['If-Match'] = this.revisions[id];
+        }
+        return headers;
+    };
+
+    private rememberRevision = (id: string, revision?: string): void => {
+        if (revision) {
+            this.revisions[id] = revision;
+        }
+    };
+
     protected requestItems = (): Promise<IHook[]> =>
         axios
             .get<IHook[]>(`${config.get('url')}hook`, {
@@ -24,7 +41,7 @@ export class HookStore {
     protected requestDelete = (id: string): Promise<void> =>
         axios
             .delete(`${config.get('url')}hook/${id}`, {
-                headers: {'X-GoHook-Key': this.tokenProvider()},
+                headers: this.editHeaders(id),
             })
             .then(() => this.snack(translate('hook.snack.deleteSuccess')));
 
@@ -37,6 +54,7 @@ export class HookStore {
     @action
     public refresh = async (): Promise<void> => {
         this.items = await this.requestItems().then((items) => items || []);
+        this.items.forEach((hook) => this.rememberRevision(hook.id, hook.revision));
     };
 
     @action
@@ -124,6 +142,7 @@ export class HookStore {
             const response = await axios.get<IHook>(`${config.get('url')}hook/${id}`, {
                 headers: {'X-GoHook-Key': this.tokenProvider()},
             });
+            this.rememberRevision(id, response.headers.etag);
             return response.data;
         } catch (error: unknown) {
             const errorMessage =
@@ -196,9 +215,10 @@ export class HookStore {
                 `${config.get('url')}hook/${hookId}/basic`,
                 basicData,
                 {
-                    headers: {'X-GoHook-Key': this.tokenProvider()},
+                    headers: this.editHeaders(hookId),
                 }
             );
+            this.rememberRevision(hookId, response.headers.etag);
             this.snack(response.data.message || translate('hook.snack.updateBasicSuccess'));
         } catch (error: unknown) {
             const errorMessage =
@@ -225,9 +245,10 @@ export class HookStore {
                 `${config.get('url')}hook/${hookId}/parameters`,
                 parametersData,
                 {
-                    headers: {'X-GoHook-Key': this.tokenProvider()},
+                    headers: this.editHeaders(hookId),
                 }
             );
+            this.rememberRevision(hookId, response.headers.etag);
             this.snack(response.data.message || translate('hook.snack.updateParametersSuccess'));
         } catch (error: unknown) {
             const errorMessage =
@@ -255,9 +276,10 @@ export class HookStore {
                 `${config.get('url')}hook/${hookId}/triggers`,
                 triggersData,
                 {
-                    headers: {'X-GoHook-Key': this.tokenProvider()},
+                    headers: this.editHeaders(hookId),
                 }
             );
+            this.rememberRevision(hookId, response.headers.etag);
             this.snack(response.data.message || translate('hook.snack.updateTriggersSuccess'));
         } catch (error: unknown) {
             const errorMessage =
@@ -287,9 +309,10 @@ export class HookStore {
                 `${config.get('url')}hook/${hookId}/response`,
                 responseData,
                 {
-                    headers: {'X-GoHook-Key': this.tokenProvider()},
+                    headers: this.editHeaders(hookId),
                 }
             );
+            this.rememberRevision(hookId, response.headers.etag);
             this.snack(response.data.message || translate('hook.snack.updateResponseSuccess'));
         } catch (error: unknown) {
             const errorMessage =
@@ -375,9 +398,10 @@ export class HookStore {
                     'execute-command': executeCommand,
                 },
                 {
-                    headers: {'X-GoHook-Key': this.tokenProvider()},
+                    headers: this.editHeaders(hookId),
                 }
             );
+            this.rememberRevision(hookId, response.headers.etag);
             this.snack(response.data.message || translate('hook.snack.updateCommandSuccess'));
         } catch (error: unknown) {
             const errorMessage =
@@ -23,6 +23,7 @@ export interface IHook {
     'last-execution': string;
     argumentsCount: number;
     environmentCount: number;
+    revision?: string; // 修改时通过If-Match提交的版本
 
     // UI字段（用于显示）
     name?: string;
@@ -85,6 +86,7 @@ export interface IVersion {
     hooksecret?: string; // webhook密码
     forcesync?: boolean; // GitHook 是否使用强制同步
     sync?: IProjectSyncConfig;
+    revision?: string; // 修改时通过If-Match提交的版本
 }
 
 export interface IProjectSyncNodeConfig {
@@ -177,7 +179,10 @@ export interface IPlugin {
     author?: string;
     website?: string;
     license?: string;
-    capabilities: Array<'webhooker' | 'displayer' | 'configurer' | 'messenger' | 'storager'>;
+    kind: 'exec' | 'go' | 'wasm';
+    version?: string;
+    lastError?: string;
+    capabilities: Array<'displayer' | 'configurer' | 'notifier' | 'transformer' | 'trigger' | 'webhooker'>;
 }
 
 export interface IMessage {
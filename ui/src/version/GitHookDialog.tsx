@@ -24,6 +24,7 @@ import {
 import {styled} from '@mui/material/styles';
 import {FileCopy, Refresh} from '@mui/icons-material';
 import {IVersion} from '../types';
+import * as config from '../config';
 import {useTranslation} from '../i18n/useTranslation';
 
 const StyledDialogContent = styled(DialogContent)(({theme}) => ({
@@ -171,7 +172,8 @@ const GitHookDialog: React.FC<GitHookDialogProps> = ({open, project, onClose, on
 
     const getWebhookUrl = () => {
         if (!project) return '';
-        return `${window.location.origin}/githook/${project.name}`;
+        // the panel URL carries the base path when served below a sub-path
+        return `${config.get('url')}githook/${project.name}`;
     };
 
     if (!project) return null;
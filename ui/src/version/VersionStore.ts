@@ -36,6 +36,16 @@ export class VersionStore {
         private readonly tokenProvider: () => string
     ) {}
 
+    // 修改项目时通过If-Match提交所基于的版本
+    private editHeaders = (name: string): {[header: string]: string} => {
+        const headers: {[header: string]: string} = {'X-GoHook-Key': this.tokenProvider()};
+        const revision = this.projects.find((project) => project.name === name)?.revision;
+        if (revision) {
+            headers['If-Match'] = revision;
+        }
+        return headers;
+    };
+
     protected requestProjects = (): Promise<IVersion[]> =>
         axios
             .get<IVersion[]>(`${config.get('url')}version`, {
@@ -176,7 +186,7 @@ export class VersionStore {
                     ...(sync ? {sync} : {}),
                 },
                 {
-                    headers: {'X-GoHook-Key': this.tokenProvider()},
+                    headers: this.editHeaders(originalName),
                 }
             );
             this.snack(response.data.message || '项目编辑成功');
@@ -196,7 +206,7 @@ export class VersionStore {
     public deleteProject = async (name: string): Promise<void> => {
         try {
             const response = await axios.delete(`${config.get('url')}version/${name}`, {
-                headers: {'X-GoHook-Key': this.tokenProvider()},
+                headers: this.editHeaders(name),
             });
             this.snack(response.data.message || '项目删除成功');
             await this.refreshProjects(); // 删除后刷新项目列表
@@ -374,7 +384,7 @@ export class VersionStore {
                     forcesync: gitHookConfig.forcesync || false,
                 },
                 {
-                    headers: {'X-GoHook-Key': this.tokenProvider()},
+                    headers: this.editHeaders(projectName),
                 }
             );
             this.snack(response.data.message || 'GitHook配置保存成功');